@@ -3,13 +3,17 @@ package adminui
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"embed"
+	"encoding/base64"
+	"html"
 	"io"
 	"io/fs"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -20,23 +24,25 @@ import (
 //go:embed all:dist
 var distFS embed.FS
 
+// BuildBasePath is the base path the UI is compiled with (paths.base in
+// ui/svelte.config.js). Index responses are rewritten from this to the
+// configured admin_ui.path.
+const BuildBasePath = "/_admin"
+
 type Handler struct {
 	cfg      *config.AdminUIConfig
 	devProxy *httputil.ReverseProxy
-	fileFS   http.Handler
+	files    fs.FS
+	assets   *assetManifest
 }
 
 func New(cfg *config.AdminUIConfig) *Handler {
-	h := &Handler{
-		cfg: cfg,
-	}
-
 	subFS, err := fs.Sub(distFS, "dist")
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create sub filesystem for admin UI")
-		return h
 	}
-	h.fileFS = http.FileServer(http.FS(subFS))
+
+	h := newHandler(cfg, subFS)
 
 	if proxyURL := os.Getenv("ALYX_ADMIN_UI_DEV"); proxyURL != "" {
 		parsed, err := url.Parse(proxyURL)
@@ -67,8 +73,21 @@ func New(cfg *config.AdminUIConfig) *Handler {
 	return h
 }
 
+func newHandler(cfg *config.AdminUIConfig, files fs.FS) *Handler {
+	return &Handler{
+		cfg:    cfg,
+		files:  files,
+		assets: newAssetManifest(files),
+	}
+}
+
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "same-origin")
+
 	if h.devProxy != nil {
+		// No CSP here: the Vite dev server relies on inline scripts and HMR websockets.
 		h.devProxy.ServeHTTP(w, r)
 		return
 	}
@@ -76,42 +95,64 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.serveEmbedded(w, r)
 }
 
+// RedirectToBase returns a handler that redirects to the UI's public base path.
+func (h *Handler) RedirectToBase() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, h.publicBase(r)+"/", http.StatusMovedPermanently)
+	})
+}
+
+// publicBase is the externally visible mount point: a validated
+// X-Forwarded-Prefix from a reverse proxy, if present, followed by admin_ui.path.
+func (h *Handler) publicBase(r *http.Request) string {
+	base := strings.TrimSuffix(h.cfg.Path, "/")
+	prefix := strings.TrimSuffix(r.Header.Get("X-Forwarded-Prefix"), "/")
+	if prefix != "" && forwardedPrefixPattern.MatchString(prefix) {
+		base = prefix + base
+	}
+	return base
+}
+
 func (h *Handler) serveEmbedded(w http.ResponseWriter, r *http.Request) {
+	if h.files == nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	path := r.URL.Path
 	if path == "" || path == "/" {
-		h.serveFile(w, r, "index.html")
+		h.serveIndex(w, r)
 		return
 	}
 
 	filePath := strings.TrimPrefix(path, "/")
 
-	subFS, err := fs.Sub(distFS, "dist")
-	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if name, ok := h.assets.files[filePath]; ok {
+		h.serveFile(w, r, name, immutableCacheControl)
 		return
 	}
 
-	if _, err := fs.Stat(subFS, filePath); err == nil {
-		h.serveFile(w, r, filePath)
-		return
+	if filePath != "index.html" {
+		if _, err := fs.Stat(h.files, filePath); err == nil {
+			cacheControl := "no-cache"
+			if strings.HasPrefix(filePath, immutableDir) {
+				cacheControl = immutableCacheControl
+			}
+			h.serveFile(w, r, filePath, cacheControl)
+			return
+		}
 	}
 
 	if !hasAssetExtension(path) {
-		h.serveFile(w, r, "index.html")
+		h.serveIndex(w, r)
 		return
 	}
 
 	http.NotFound(w, r)
 }
 
-func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
-	subFS, err := fs.Sub(distFS, "dist")
-	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	f, err := subFS.Open(name)
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, name, cacheControl string) {
+	f, err := h.files.Open(name)
 	if err != nil {
 		http.NotFound(w, r)
 		return
@@ -134,9 +175,103 @@ func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, name string)
 		content = bytes.NewReader(data)
 	}
 
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy(nil))
+	w.Header().Set("Cache-Control", cacheControl)
+	if etag, ok := h.assets.etags[name]; ok {
+		w.Header().Set("ETag", etag)
+	}
 	http.ServeContent(w, r, name, stat.ModTime(), content)
 }
 
+// serveIndex serves index.html rewritten for the public base path and the
+// hashed asset paths, with a CSP that allows exactly the inline scripts it
+// contains. It is never cached without revalidation, so an upgrade takes
+// effect on the next load.
+func (h *Handler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	data, err := fs.ReadFile(h.files, "index.html")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	page := rewriteIndex(h.assets.rewrite(data), h.publicBase(r))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy(inlineScriptHashes(page)))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(page)
+}
+
+var (
+	forwardedPrefixPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+	baseTagPattern         = regexp.MustCompile(`(?i)<base\s[^>]*>`)
+	headTagPattern         = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+	inlineScriptPattern    = regexp.MustCompile(`(?is)<script(\s[^>]*)?>(.*?)</script>`)
+	scriptSrcPattern       = regexp.MustCompile(`(?i)\ssrc\s*=`)
+)
+
+// rewriteIndex points index.html at base: absolute references to the build
+// base path are rebased, and a <base href> is set (or replaced) so relative
+// asset links resolve under base as well.
+func rewriteIndex(page []byte, base string) []byte {
+	if base != BuildBasePath {
+		for _, quote := range []string{`"`, `'`} {
+			page = bytes.ReplaceAll(page, []byte(quote+BuildBasePath+"/"), []byte(quote+base+"/"))
+			page = bytes.ReplaceAll(page, []byte(quote+BuildBasePath+quote), []byte(quote+base+quote))
+		}
+	}
+
+	baseTag := []byte(`<base href="` + html.EscapeString(base+"/") + `">`)
+	if baseTagPattern.Match(page) {
+		return baseTagPattern.ReplaceAllLiteral(page, baseTag)
+	}
+
+	loc := headTagPattern.FindIndex(page)
+	if loc == nil {
+		return page
+	}
+
+	out := make([]byte, 0, len(page)+len(baseTag))
+	out = append(out, page[:loc[1]]...)
+	out = append(out, baseTag...)
+	out = append(out, page[loc[1]:]...)
+	return out
+}
+
+// inlineScriptHashes returns CSP source expressions for each inline script.
+func inlineScriptHashes(page []byte) []string {
+	var hashes []string
+	for _, m := range inlineScriptPattern.FindAllSubmatch(page, -1) {
+		if scriptSrcPattern.Match(m[1]) || len(bytes.TrimSpace(m[2])) == 0 {
+			continue
+		}
+		sum := sha256.Sum256(m[2])
+		hashes = append(hashes, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+	}
+	return hashes
+}
+
+func contentSecurityPolicy(scriptHashes []string) string {
+	scriptSrc := "script-src 'self'"
+	if len(scriptHashes) > 0 {
+		scriptSrc += " " + strings.Join(scriptHashes, " ")
+	}
+
+	return strings.Join([]string{
+		"default-src 'self'",
+		scriptSrc,
+		"style-src 'self' 'unsafe-inline'",
+		"img-src 'self' data: blob:",
+		"font-src 'self' data:",
+		"connect-src 'self'",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+	}, "; ")
+}
+
 func hasAssetExtension(path string) bool {
 	extensions := []string{
 		".js", ".css", ".map", ".json",
@@ -0,0 +1,273 @@
+package adminui
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+const testIndex = `<!doctype html>
+<html lang="en">
+	<head>
+		<meta charset="utf-8" />
+		<link rel="icon" href="/_admin/favicon.png" />
+		<link rel="modulepreload" href="/_admin/_app/immutable/entry/start.js">
+		<link href="/_admin/_app/immutable/assets/app.css" rel="stylesheet">
+	</head>
+	<body>
+		<div style="display: contents">
+			<script>
+				{
+					__sveltekit = { base: "/_admin", assets: "/_admin" };
+					Promise.all([import("/_admin/_app/immutable/entry/start.js")]);
+				}
+			</script>
+		</div>
+	</body>
+</html>
+`
+
+func testFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":                      {Data: []byte(testIndex)},
+		"favicon.png":                     {Data: []byte("png")},
+		"_app/immutable/entry/start.js":   {Data: []byte("export {}")},
+		"_app/immutable/assets/app.css":   {Data: []byte("body{}")},
+		"_app/immutable/chunks/routes.js": {Data: []byte("export {}")},
+	}
+}
+
+// newProxiedServer mounts the handler the way the router does and puts it
+// behind a reverse proxy that strips prefix and sends X-Forwarded-Prefix.
+func newProxiedServer(t *testing.T, uiPath, prefix string) *httptest.Server {
+	t.Helper()
+
+	h := newHandler(&config.AdminUIConfig{Enabled: true, Path: uiPath}, testFiles())
+	mux := http.NewServeMux()
+	mux.Handle("GET "+uiPath+"/{path...}", http.StripPrefix(uiPath, h))
+	mux.Handle("GET "+uiPath, h.RedirectToBase())
+
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, prefix+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		r2.Header.Set("X-Forwarded-Prefix", prefix)
+		mux.ServeHTTP(w, r2)
+	})
+
+	srv := httptest.NewServer(proxy)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func fetch(t *testing.T, client *http.Client, rawURL string) (*http.Response, string) {
+	t.Helper()
+
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading %s: %v", rawURL, err)
+	}
+	return resp, string(body)
+}
+
+func TestIndexThroughProxyPrefix(t *testing.T) {
+	const prefix = "/internal/alyx"
+	srv := newProxiedServer(t, "/console", prefix)
+
+	pageURL := srv.URL + prefix + "/console/"
+	resp, body := fetch(t, srv.Client(), pageURL)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	if !strings.Contains(body, `<base href="/internal/alyx/console/">`) {
+		t.Errorf("expected base href for proxy prefix, got:\n%s", body)
+	}
+	if strings.Contains(body, `"/_admin/`) {
+		t.Errorf("expected build base path to be rewritten, got:\n%s", body)
+	}
+	if !strings.Contains(body, `base: "/internal/alyx/console"`) {
+		t.Errorf("expected client base to be rewritten, got:\n%s", body)
+	}
+
+	// Every asset link must resolve, through the proxy, to a real file.
+	base, _ := url.Parse(pageURL)
+	links := regexp.MustCompile(`(?:href|import\()\s*=?\s*\(?"([^"]+)"`).FindAllStringSubmatch(body, -1)
+	if len(links) < 4 {
+		t.Fatalf("expected asset links in index, found %v", links)
+	}
+	for _, link := range links {
+		ref, err := url.Parse(link[1])
+		if err != nil {
+			t.Fatalf("parse link %q: %v", link[1], err)
+		}
+		assetURL := base.ResolveReference(ref).String()
+		assetResp, _ := fetch(t, srv.Client(), assetURL)
+		if assetResp.StatusCode != http.StatusOK {
+			t.Errorf("asset %q (%s) returned %d", link[1], assetURL, assetResp.StatusCode)
+		}
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	h := newHandler(&config.AdminUIConfig{Enabled: true, Path: "/_admin"}, testFiles())
+
+	for _, path := range []string{"/", "/settings", "/_app/immutable/entry/start.js"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if got := w.Header().Get("X-Frame-Options"); got != "DENY" {
+			t.Errorf("%s: expected X-Frame-Options DENY, got %q", path, got)
+		}
+		csp := w.Header().Get("Content-Security-Policy")
+		if !strings.Contains(csp, "frame-ancestors 'none'") || !strings.Contains(csp, "default-src 'self'") {
+			t.Errorf("%s: expected strict CSP, got %q", path, csp)
+		}
+		for _, directive := range strings.Split(csp, ";") {
+			if strings.HasPrefix(strings.TrimSpace(directive), "script-src") && strings.Contains(directive, "unsafe-inline") {
+				t.Errorf("%s: script-src must not allow unsafe-inline: %q", path, csp)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	script := regexp.MustCompile(`(?s)<script>(.*?)</script>`).FindStringSubmatch(w.Body.String())
+	if script == nil {
+		t.Fatal("expected inline script in index")
+	}
+	sum := sha256.Sum256([]byte(script[1]))
+	hash := "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+	if !strings.Contains(w.Header().Get("Content-Security-Policy"), hash) {
+		t.Errorf("expected CSP to allow the inline bootstrap script %s", hash)
+	}
+}
+
+func TestRedirectToBase(t *testing.T) {
+	h := newHandler(&config.AdminUIConfig{Enabled: true, Path: "/console"}, testFiles())
+
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	w := httptest.NewRecorder()
+	h.RedirectToBase().ServeHTTP(w, req)
+
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/console/" {
+		t.Errorf("expected redirect to /console/, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("X-Forwarded-Prefix", `/evil"><script>`)
+	w = httptest.NewRecorder()
+	h.RedirectToBase().ServeHTTP(w, req)
+
+	if w.Header().Get("Location") != "/console/" {
+		t.Errorf("expected invalid forwarded prefix to be ignored, got %q", w.Header().Get("Location"))
+	}
+}
+
+// assetLinks returns the asset references in an index page, favicon first.
+func assetLinks(page string) []string {
+	var links []string
+	for _, m := range regexp.MustCompile(`(?:<link [^>]*href=|import\()"([^"]+)"`).FindAllStringSubmatch(page, -1) {
+		links = append(links, m[1])
+	}
+	return links
+}
+
+func serve(h *Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestAssetCaching(t *testing.T) {
+	h := newHandler(&config.AdminUIConfig{Enabled: true, Path: "/_admin"}, testFiles())
+
+	index := serve(h, "/", nil)
+	if got := index.Header().Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("index: expected no-cache, got %q", got)
+	}
+	links := assetLinks(index.Body.String())
+	favicon := regexp.MustCompile(`^/_admin/favicon\.[0-9a-f]{16}\.png$`)
+	if len(links) == 0 || !favicon.MatchString(links[0]) {
+		t.Fatalf("expected hashed favicon link, got %v", links)
+	}
+
+	for _, link := range links {
+		w := serve(h, strings.TrimPrefix(link, "/_admin"), nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", link, w.Code)
+		}
+		if got := w.Header().Get("Cache-Control"); got != immutableCacheControl {
+			t.Errorf("%s: expected immutable caching, got %q", link, got)
+		}
+	}
+
+	w := serve(h, "/favicon.png", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-cache" || etag == "" {
+		t.Fatalf("unhashed path: expected 200 with no-cache and an ETag, got %d %v", w.Code, w.Header())
+	}
+	if w := serve(h, "/favicon.png", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", w.Code)
+	}
+}
+
+func TestAssetURLsChangeWithBundle(t *testing.T) {
+	cfg := &config.AdminUIConfig{Enabled: true, Path: "/_admin"}
+	oldIndex := serve(newHandler(cfg, testFiles()), "/", nil).Body.String()
+	oldLinks := assetLinks(oldIndex)
+
+	// An upgrade changes the favicon and, as Vite does, renames the
+	// immutable files whose content changed.
+	upgraded := testFiles()
+	upgraded["favicon.png"] = &fstest.MapFile{Data: []byte("new png")}
+	delete(upgraded, "_app/immutable/entry/start.js")
+	upgraded["_app/immutable/entry/start.v2.js"] = &fstest.MapFile{Data: []byte("export const v = 2")}
+	upgraded["index.html"] = &fstest.MapFile{Data: []byte(strings.ReplaceAll(testIndex, "entry/start.js", "entry/start.v2.js"))}
+	h := newHandler(cfg, upgraded)
+
+	newIndex := serve(h, "/", nil).Body.String()
+	newLinks := assetLinks(newIndex)
+	stale := map[string]bool{}
+	for _, link := range oldLinks {
+		stale[link] = true
+	}
+	// The stylesheet did not change, so it keeps its URL.
+	unchanged := "/_admin/_app/immutable/assets/app.css"
+	for _, link := range newLinks {
+		if stale[link] && link != unchanged {
+			t.Errorf("upgraded index still references %s:\n%s", link, newIndex)
+		}
+		if w := serve(h, strings.TrimPrefix(link, "/_admin"), nil); w.Code != http.StatusOK {
+			t.Errorf("upgraded asset %s returned %d", link, w.Code)
+		}
+	}
+
+	// The old hashed favicon URL must not serve the new content under an
+	// immutable cache header.
+	if w := serve(h, strings.TrimPrefix(oldLinks[0], "/_admin"), nil); w.Code != http.StatusNotFound {
+		t.Errorf("stale hashed asset %s: expected 404, got %d", oldLinks[0], w.Code)
+	}
+}
@@ -0,0 +1,90 @@
+package adminui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// immutableDir holds the JS and CSS SvelteKit emits with content hashes in
+// their names. They are cached under their own paths: renaming them would
+// load modules that import each other twice.
+const immutableDir = "_app/immutable/"
+
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// assetManifest maps the embedded files to paths that change with their
+// content, so they can be cached for good. It is built once per handler,
+// and the embedded files only change with the binary.
+type assetManifest struct {
+	// hashed maps a file to its hashed path.
+	hashed map[string]string
+	// files maps a hashed path back to its file.
+	files map[string]string
+	// etags holds the ETag of every file, for requests by plain path.
+	etags map[string]string
+}
+
+func newAssetManifest(files fs.FS) *assetManifest {
+	m := &assetManifest{
+		hashed: make(map[string]string),
+		files:  make(map[string]string),
+		etags:  make(map[string]string),
+	}
+	if files == nil {
+		return m
+	}
+
+	err := fs.WalkDir(files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || name == "index.html" {
+			return err
+		}
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:8])
+
+		m.etags[name] = `"` + hash + `"`
+		if !strings.HasPrefix(name, immutableDir) {
+			ext := path.Ext(name)
+			hashed := strings.TrimSuffix(name, ext) + "." + hash + ext
+			m.hashed[name] = hashed
+			m.files[hashed] = name
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to hash admin UI assets")
+	}
+	return m
+}
+
+// assetRefPattern matches quoted references to files under the build base
+// path or relative to the page.
+var assetRefPattern = regexp.MustCompile(`(["'])(` + regexp.QuoteMeta(BuildBasePath) + `/|\./)([^"'\s?#]+)(["'?#])`)
+
+// rewrite points the asset references in index.html at their hashed paths.
+func (m *assetManifest) rewrite(page []byte) []byte {
+	if len(m.hashed) == 0 {
+		return page
+	}
+	return assetRefPattern.ReplaceAllFunc(page, func(ref []byte) []byte {
+		parts := assetRefPattern.FindSubmatch(ref)
+		hashed, ok := m.hashed[string(parts[3])]
+		if !ok {
+			return ref
+		}
+		out := make([]byte, 0, len(ref)+len(hashed)-len(parts[3]))
+		out = append(out, parts[1]...)
+		out = append(out, parts[2]...)
+		out = append(out, hashed...)
+		return append(out, parts[4]...)
+	})
+}
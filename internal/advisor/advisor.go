@@ -0,0 +1,441 @@
+// Package advisor recommends indexes from the list queries recorded in the
+// request log.
+//
+// Each distinct query shape (collection, filtered fields and operators, sort
+// fields) is explained with EXPLAIN QUERY PLAN. Shapes that scan a large
+// table, or sort it with a temporary b-tree, get a composite index suggestion
+// matching their filters and sort. Declared indexes that no explained plan
+// uses are reported as unused.
+package advisor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/server/requestlog"
+)
+
+// DefaultMinRows is the table size below which full scans are not reported.
+const DefaultMinRows = 1000
+
+// Options configures an analysis.
+type Options struct {
+	// MinRows is the smallest table, in rows, whose full scans are reported.
+	// Zero means DefaultMinRows.
+	MinRows int64
+}
+
+// Report is the result of an analysis.
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	// Queries is the number of logged list requests analyzed.
+	Queries int `json:"queries"`
+	// Shapes is the number of distinct query shapes among them.
+	Shapes          int               `json:"shapes"`
+	Recommendations []*Recommendation `json:"recommendations"`
+	UnusedIndexes   []*UnusedIndex    `json:"unused_indexes"`
+}
+
+// Recommendation is a suggested index for one query shape.
+type Recommendation struct {
+	Collection string        `json:"collection"`
+	Index      *schema.Index `json:"index"`
+	// YAML is the index in schema.yaml syntax, to add under the
+	// collection's indexes.
+	YAML   string `json:"yaml"`
+	Reason string `json:"reason"`
+	// Query describes the shape, e.g. "filter status:eq, sort -created_at".
+	Query string `json:"query"`
+	// Requests is how many logged requests had this shape.
+	Requests int `json:"requests"`
+	// Plan is the current EXPLAIN QUERY PLAN output, one step per line.
+	Plan string `json:"plan"`
+	// RowsScanned is the table size the current plan scans per request.
+	RowsScanned int64 `json:"rows_scanned"`
+	// RowsReturned is the mean number of documents returned per request.
+	RowsReturned float64 `json:"rows_returned"`
+	// Benefit is RowsScanned / RowsReturned: roughly how many times fewer
+	// rows the index would visit.
+	Benefit float64 `json:"benefit"`
+}
+
+// UnusedIndex is a declared index that no analyzed query plan used.
+type UnusedIndex struct {
+	Collection string   `json:"collection"`
+	Name       string   `json:"name"`
+	Fields     []string `json:"fields"`
+	// Queries is the number of analyzed query shapes on the collection.
+	Queries int `json:"queries"`
+}
+
+// shape groups the logged requests sharing one query shape.
+type shape struct {
+	query    requestlog.ListQuery
+	requests int
+	returned int
+}
+
+// Analyze explains the query shapes in queries against db and recommends
+// indexes for s. Queries on collections or fields missing from s are
+// ignored.
+func Analyze(ctx context.Context, db *database.DB, s *schema.Schema, queries []requestlog.ListQuery, opts Options) (*Report, error) {
+	if opts.MinRows <= 0 {
+		opts.MinRows = DefaultMinRows
+	}
+
+	report := &Report{
+		GeneratedAt:     time.Now().UTC(),
+		Recommendations: []*Recommendation{},
+		UnusedIndexes:   []*UnusedIndex{},
+	}
+
+	shapes := make(map[string]*shape)
+	var keys []string
+	for _, q := range queries {
+		col := s.Collections[q.Collection]
+		if col == nil || !knownFields(col, q) {
+			continue
+		}
+		report.Queries++
+		key := q.Key()
+		sh, ok := shapes[key]
+		if !ok {
+			sh = &shape{query: q}
+			shapes[key] = sh
+			keys = append(keys, key)
+		}
+		sh.requests++
+		sh.returned += q.Returned
+	}
+	sort.Strings(keys)
+	report.Shapes = len(keys)
+
+	rowCounts := make(map[string]int64)
+	usedIndexes := make(map[string]bool)
+	shapesPerCollection := make(map[string]int)
+	recommended := make(map[string]*Recommendation)
+
+	for _, key := range keys {
+		sh := shapes[key]
+		name := sh.query.Collection
+		col := s.Collections[name]
+		shapesPerCollection[name]++
+
+		plan, err := explain(ctx, db, sh.query)
+		if err != nil {
+			return nil, fmt.Errorf("explaining %s query: %w", name, err)
+		}
+		for _, step := range plan {
+			if idx := planIndex(step); idx != "" {
+				usedIndexes[idx] = true
+			}
+		}
+
+		scan, tempSort := planCost(plan, name)
+		if !scan && !tempSort {
+			continue
+		}
+
+		rows, ok := rowCounts[name]
+		if !ok {
+			if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+name).Scan(&rows); err != nil {
+				return nil, fmt.Errorf("counting %s rows: %w", name, err)
+			}
+			rowCounts[name] = rows
+		}
+		if rows < opts.MinRows {
+			continue
+		}
+
+		index := suggestIndex(col, sh.query)
+		if index == nil || covered(col, index) {
+			continue
+		}
+
+		returned := float64(sh.returned) / float64(sh.requests)
+		var reason string
+		switch {
+		case scan && tempSort:
+			reason = fmt.Sprintf("full scan of %d rows and a temporary sort", rows)
+		case scan:
+			reason = fmt.Sprintf("full scan of %d rows", rows)
+		default:
+			reason = fmt.Sprintf("temporary sort over %d rows", rows)
+		}
+
+		// Shapes suggesting the same index are merged.
+		indexKey := name + ":" + index.Name
+		if rec, ok := recommended[indexKey]; ok {
+			rec.Requests += sh.requests
+			continue
+		}
+
+		rec := &Recommendation{
+			Collection:   name,
+			Index:        index,
+			YAML:         indexYAML(index),
+			Reason:       reason,
+			Query:        describe(sh.query),
+			Requests:     sh.requests,
+			Plan:         strings.Join(plan, "\n"),
+			RowsScanned:  rows,
+			RowsReturned: returned,
+			Benefit:      float64(rows) / max(returned, 1),
+		}
+		recommended[indexKey] = rec
+		report.Recommendations = append(report.Recommendations, rec)
+	}
+
+	sort.SliceStable(report.Recommendations, func(i, j int) bool {
+		a, b := report.Recommendations[i], report.Recommendations[j]
+		wa, wb := a.Benefit*float64(a.Requests), b.Benefit*float64(b.Requests)
+		if wa != wb {
+			return wa > wb
+		}
+		return a.Collection+a.Index.Name < b.Collection+b.Index.Name
+	})
+
+	for _, name := range sortedCollections(s) {
+		if shapesPerCollection[name] == 0 {
+			continue
+		}
+		for _, idx := range s.Collections[name].Indexes {
+			// Unique indexes enforce constraints, so they are never unused.
+			if idx.Unique || usedIndexes[idx.Name] {
+				continue
+			}
+			report.UnusedIndexes = append(report.UnusedIndexes, &UnusedIndex{
+				Collection: name,
+				Name:       idx.Name,
+				Fields:     idx.Fields,
+				Queries:    shapesPerCollection[name],
+			})
+		}
+	}
+
+	return report, nil
+}
+
+func knownFields(col *schema.Collection, q requestlog.ListQuery) bool {
+	for _, f := range q.Filters {
+		if col.Fields[f.Field] == nil {
+			return false
+		}
+	}
+	for _, s := range q.Sorts {
+		if col.Fields[s.Field] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// explain runs EXPLAIN QUERY PLAN for a representative statement of q and
+// returns the plan details.
+func explain(ctx context.Context, db *database.DB, q requestlog.ListQuery) ([]string, error) {
+	builder := database.NewQuery(q.Collection)
+	for _, f := range q.Filters {
+		var value any
+		if database.FilterOp(f.Op) == database.OpIn {
+			value = []any{nil}
+		}
+		builder.Filter(f.Field, database.FilterOp(f.Op), value)
+	}
+	for _, s := range q.Sorts {
+		order := database.SortAsc
+		if s.Desc {
+			order = database.SortDesc
+		}
+		builder.Sort(s.Field, order)
+	}
+	if q.Limit > 0 {
+		builder.Limit(q.Limit)
+	}
+	query, args := builder.Build()
+
+	rows, err := db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			return nil, err
+		}
+		plan = append(plan, detail)
+	}
+	return plan, rows.Err()
+}
+
+// planCost reports whether the plan scans table without an index and
+// whether it sorts with a temporary b-tree.
+func planCost(plan []string, table string) (scan, tempSort bool) {
+	for _, step := range plan {
+		// SQLite before 3.36 writes SCAN TABLE.
+		step = strings.Replace(step, "SCAN TABLE ", "SCAN ", 1)
+		switch {
+		case step == "SCAN "+table || strings.HasPrefix(step, "SCAN "+table+" ") && !strings.Contains(step, " INDEX "):
+			scan = true
+		case strings.HasPrefix(step, "USE TEMP B-TREE FOR ORDER BY"):
+			tempSort = true
+		}
+	}
+	return scan, tempSort
+}
+
+// planIndex returns the index a plan step uses, if any.
+func planIndex(step string) string {
+	for _, marker := range []string{"USING COVERING INDEX ", "USING INDEX "} {
+		if i := strings.Index(step, marker); i >= 0 {
+			name := step[i+len(marker):]
+			if j := strings.IndexByte(name, ' '); j >= 0 {
+				name = name[:j]
+			}
+			return name
+		}
+	}
+	return ""
+}
+
+// suggestIndex builds the composite index serving q: equality-filtered
+// fields first, then the sort fields, or a single range-filtered field when
+// there is no sort. It returns nil when no filter or sort can use an index.
+func suggestIndex(col *schema.Collection, q requestlog.ListQuery) *schema.Index {
+	var fields []string
+	seen := make(map[string]bool)
+	add := func(field string) {
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+
+	var ranges []string
+	for _, f := range q.Filters {
+		switch database.FilterOp(f.Op) {
+		case database.OpEq, database.OpIn, database.OpIsNull:
+			add(f.Field)
+		case database.OpGt, database.OpGte, database.OpLt, database.OpLte:
+			ranges = append(ranges, f.Field)
+		}
+	}
+
+	order := ""
+	if len(q.Sorts) > 0 {
+		// An index serves a sort in one direction throughout, read forwards
+		// or backwards, so stop at the first change of direction.
+		first := q.Sorts[0].Desc
+		for _, s := range q.Sorts {
+			if s.Desc != first {
+				break
+			}
+			add(s.Field)
+		}
+		if first {
+			order = "desc"
+		}
+	} else if len(ranges) > 0 {
+		add(ranges[0])
+	}
+
+	pk := col.PrimaryKeyField()
+	if len(fields) == 0 || (len(fields) == 1 && pk != nil && fields[0] == pk.Name) {
+		return nil
+	}
+	return &schema.Index{
+		Name:   "idx_" + col.Name + "_" + strings.Join(fields, "_"),
+		Fields: fields,
+		Order:  order,
+	}
+}
+
+// covered reports whether an existing index already starts with the
+// suggested fields.
+func covered(col *schema.Collection, index *schema.Index) bool {
+	if pk := col.PrimaryKeyField(); pk != nil && index.Fields[0] == pk.Name {
+		return true
+	}
+	existing := make([][]string, 0, len(col.Indexes)+len(col.Fields))
+	for _, idx := range col.Indexes {
+		existing = append(existing, idx.Fields)
+	}
+	for _, f := range col.Fields {
+		if f.Index || f.Unique {
+			existing = append(existing, []string{f.Name})
+		}
+	}
+	for _, fields := range existing {
+		if len(fields) < len(index.Fields) {
+			continue
+		}
+		prefix := true
+		for i, f := range index.Fields {
+			if fields[i] != f {
+				prefix = false
+				break
+			}
+		}
+		if prefix {
+			return true
+		}
+	}
+	return false
+}
+
+func indexYAML(index *schema.Index) string {
+	entry := struct {
+		Name   string   `yaml:"name"`
+		Fields []string `yaml:"fields,flow"`
+		Order  string   `yaml:"order,omitempty"`
+	}{index.Name, index.Fields, index.Order}
+
+	var sb strings.Builder
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode([]any{entry}); err != nil {
+		return ""
+	}
+	_ = enc.Close()
+	return sb.String()
+}
+
+func describe(q requestlog.ListQuery) string {
+	var parts []string
+	for _, f := range q.Filters {
+		parts = append(parts, "filter "+f.Field+":"+f.Op)
+	}
+	for _, s := range q.Sorts {
+		if s.Desc {
+			parts = append(parts, "sort -"+s.Field)
+		} else {
+			parts = append(parts, "sort "+s.Field)
+		}
+	}
+	if q.Search {
+		parts = append(parts, "search")
+	}
+	if len(parts) == 0 {
+		return "no filters"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedCollections(s *schema.Schema) []string {
+	names := make([]string, 0, len(s.Collections))
+	for name := range s.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
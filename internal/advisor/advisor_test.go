@@ -0,0 +1,211 @@
+package advisor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/server/requestlog"
+)
+
+const testSchemaYAML = `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      author_id:
+        type: string
+      status:
+        type: string
+      slug:
+        type: string
+        unique: true
+      views:
+        type: int
+      created_at:
+        type: timestamp
+        default: now
+    indexes:
+      - name: idx_posts_views
+        fields: [views]
+  tags:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      name:
+        type: string
+`
+
+func setup(t *testing.T, posts int) (*database.DB, *schema.Schema) {
+	t.Helper()
+	db, err := database.Open(&config.DatabaseConfig{Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(testSchemaYAML))
+	if err != nil {
+		t.Fatalf("Failed to parse test schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to execute SQL: %v\nSQL: %s", err, stmt)
+		}
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < %d)
+		INSERT INTO posts (id, author_id, status, slug, views, created_at)
+		SELECT 'p' || i, 'u' || (i %% 50), 'draft', 's' || i, i, '2026-01-01T00:00:00Z' FROM n`, posts))
+	if err != nil {
+		t.Fatalf("Failed to insert posts: %v", err)
+	}
+	return db, s
+}
+
+func listQuery(collection string, returned int, filters []requestlog.QueryFilter, sorts ...requestlog.QuerySort) requestlog.ListQuery {
+	return requestlog.ListQuery{Collection: collection, Filters: filters, Sorts: sorts, Limit: 20, Returned: returned}
+}
+
+func TestAnalyze(t *testing.T) {
+	db, s := setup(t, 2000)
+
+	byAuthor := listQuery("posts", 10,
+		[]requestlog.QueryFilter{{Field: "author_id", Op: "eq"}, {Field: "status", Op: "eq"}},
+		requestlog.QuerySort{Field: "created_at", Desc: true})
+	queries := []requestlog.ListQuery{
+		byAuthor, byAuthor, byAuthor,
+		listQuery("posts", 1, []requestlog.QueryFilter{{Field: "slug", Op: "eq"}}),
+		listQuery("posts", 20, []requestlog.QueryFilter{{Field: "views", Op: "gt"}}, requestlog.QuerySort{Field: "views"}),
+		listQuery("posts", 20, nil, requestlog.QuerySort{Field: "created_at"}),
+		listQuery("tags", 5, nil, requestlog.QuerySort{Field: "name"}),
+		listQuery("posts", 5, []requestlog.QueryFilter{{Field: "missing", Op: "eq"}}),
+		listQuery("unknown", 5, nil),
+	}
+
+	report, err := Analyze(context.Background(), db, s, queries, Options{})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if report.Queries != 7 || report.Shapes != 5 {
+		t.Errorf("queries = %d, shapes = %d; want 7 and 5", report.Queries, report.Shapes)
+	}
+
+	if len(report.Recommendations) != 2 {
+		for _, rec := range report.Recommendations {
+			t.Logf("%s: %s (%s)", rec.Collection, rec.Index.Name, rec.Query)
+		}
+		t.Fatalf("got %d recommendations, want 2", len(report.Recommendations))
+	}
+
+	rec := report.Recommendations[0]
+	if rec.Index.Name != "idx_posts_author_id_status_created_at" || rec.Index.Order != "desc" {
+		t.Errorf("first recommendation = %+v", rec.Index)
+	}
+	if rec.Requests != 3 || rec.RowsScanned != 2000 || rec.RowsReturned != 10 || rec.Benefit != 200 {
+		t.Errorf("first recommendation stats = %+v", rec)
+	}
+	if !strings.Contains(rec.Reason, "full scan of 2000 rows and a temporary sort") {
+		t.Errorf("reason = %q", rec.Reason)
+	}
+	wantYAML := "- name: idx_posts_author_id_status_created_at\n  fields: [author_id, status, created_at]\n  order: desc\n"
+	if rec.YAML != wantYAML {
+		t.Errorf("YAML = %q, want %q", rec.YAML, wantYAML)
+	}
+
+	if got := report.Recommendations[1].Index.Name; got != "idx_posts_created_at" {
+		t.Errorf("second recommendation = %s", got)
+	}
+
+	// The views query uses idx_posts_views, and tags is below MinRows.
+	if len(report.UnusedIndexes) != 0 {
+		t.Errorf("unused indexes = %+v", report.UnusedIndexes)
+	}
+}
+
+func TestAnalyze_UnusedIndex(t *testing.T) {
+	db, s := setup(t, 10)
+
+	queries := []requestlog.ListQuery{
+		listQuery("posts", 10, []requestlog.QueryFilter{{Field: "status", Op: "eq"}}),
+	}
+	report, err := Analyze(context.Background(), db, s, queries, Options{MinRows: 5})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if len(report.UnusedIndexes) != 1 || report.UnusedIndexes[0].Name != "idx_posts_views" {
+		t.Errorf("unused indexes = %+v", report.UnusedIndexes)
+	}
+	if len(report.Recommendations) != 1 || report.Recommendations[0].Index.Name != "idx_posts_status" {
+		t.Errorf("recommendations = %+v", report.Recommendations)
+	}
+}
+
+func TestSuggestIndex(t *testing.T) {
+	s, err := schema.Parse([]byte(testSchemaYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	col := s.Collections["posts"]
+
+	tests := []struct {
+		name  string
+		query requestlog.ListQuery
+		want  string
+		order string
+	}{
+		{"equality then sort", listQuery("posts", 0, []requestlog.QueryFilter{{Field: "status", Op: "eq"}}, requestlog.QuerySort{Field: "created_at"}), "status,created_at", ""},
+		{"range without sort", listQuery("posts", 0, []requestlog.QueryFilter{{Field: "status", Op: "in"}, {Field: "views", Op: "gte"}}), "status,views", ""},
+		{"range ignored with sort", listQuery("posts", 0, []requestlog.QueryFilter{{Field: "views", Op: "lt"}}, requestlog.QuerySort{Field: "created_at", Desc: true}), "created_at", "desc"},
+		{"mixed sort directions", listQuery("posts", 0, nil, requestlog.QuerySort{Field: "status"}, requestlog.QuerySort{Field: "created_at", Desc: true}), "status", ""},
+		{"unindexable operators", listQuery("posts", 0, []requestlog.QueryFilter{{Field: "status", Op: "like"}, {Field: "slug", Op: "ne"}}), "", ""},
+		{"primary key only", listQuery("posts", 0, nil, requestlog.QuerySort{Field: "id"}), "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := suggestIndex(col, tt.query)
+			if tt.want == "" {
+				if idx != nil {
+					t.Errorf("got %+v, want none", idx)
+				}
+				return
+			}
+			if idx == nil || strings.Join(idx.Fields, ",") != tt.want || idx.Order != tt.order {
+				t.Errorf("got %+v, want fields %s order %q", idx, tt.want, tt.order)
+			}
+		})
+	}
+}
+
+func TestPlanCost(t *testing.T) {
+	tests := []struct {
+		plan     []string
+		scan     bool
+		tempSort bool
+	}{
+		{[]string{"SCAN posts"}, true, false},
+		{[]string{"SCAN TABLE posts"}, true, false},
+		{[]string{"SCAN posts", "USE TEMP B-TREE FOR ORDER BY"}, true, true},
+		{[]string{"SEARCH posts USING INDEX idx_posts_status (status=?)", "USE TEMP B-TREE FOR ORDER BY"}, false, true},
+		{[]string{"SCAN posts USING INDEX idx_posts_views"}, false, false},
+	}
+	for _, tt := range tests {
+		scan, tempSort := planCost(tt.plan, "posts")
+		if scan != tt.scan || tempSort != tt.tempSort {
+			t.Errorf("planCost(%q) = %v, %v; want %v, %v", tt.plan, scan, tempSort, tt.scan, tt.tempSort)
+		}
+	}
+	if got := planIndex("SEARCH posts USING COVERING INDEX idx_a (a=?)"); got != "idx_a" {
+		t.Errorf("planIndex = %q", got)
+	}
+}
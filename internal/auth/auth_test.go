@@ -213,6 +213,53 @@ func TestJWTService_WrongSecret(t *testing.T) {
 	}
 }
 
+func TestJWTService_SecretRotation(t *testing.T) {
+	const oldSecret = "testsecret12345678901234567890123456"
+	const newSecret = "rotatedsecret123456789012345678901234"
+
+	before := NewJWTService(testJWTConfig())
+	user := &User{ID: "user123", Email: "test@example.com"}
+
+	oldAccess, _, err := before.GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken failed: %v", err)
+	}
+	oldRefresh, _, err := before.GenerateRefreshToken(user.ID)
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken failed: %v", err)
+	}
+
+	// During the overlap window both secrets verify; the new one signs.
+	cfg := testJWTConfig()
+	cfg.Secret = ""
+	cfg.Secrets = []string{newSecret, oldSecret}
+	during := NewJWTService(cfg)
+
+	if _, err := during.ValidateAccessToken(oldAccess); err != nil {
+		t.Errorf("old access token should verify during rotation: %v", err)
+	}
+	if _, err := during.ValidateRefreshToken(oldRefresh); err != nil {
+		t.Errorf("old refresh token should verify during rotation: %v", err)
+	}
+
+	newAccess, _, err := during.GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken failed: %v", err)
+	}
+	if _, err := before.ValidateAccessToken(newAccess); err == nil {
+		t.Error("new token should be signed with the new secret")
+	}
+
+	// Once the old secret is dropped its tokens are rejected.
+	during.SetSecrets([]string{newSecret})
+	if _, err := during.ValidateAccessToken(oldAccess); err == nil {
+		t.Error("old token should be rejected after the old secret is removed")
+	}
+	if _, err := during.ValidateAccessToken(newAccess); err != nil {
+		t.Errorf("new token should still verify: %v", err)
+	}
+}
+
 func TestJWTService_RefreshToken(t *testing.T) {
 	svc := NewJWTService(testJWTConfig())
 
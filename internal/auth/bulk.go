@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/events"
+)
+
+// Bulk user actions.
+const (
+	BulkActionSetRole = "set_role"
+	BulkActionVerify  = "verify"
+	BulkActionDelete  = "delete"
+)
+
+// MaxBulkUsers is the most users a single bulk operation may change.
+const MaxBulkUsers = 1000
+
+// ErrLastAdmin is returned when an operation would leave no admin user.
+var ErrLastAdmin = errors.New("cannot remove the last admin")
+
+// BulkUsersInput is a bulk operation on users.
+type BulkUsersInput struct {
+	IDs    []string `json:"ids"`
+	Action string   `json:"action"`
+	// Role is the new role for set_role.
+	Role string `json:"role,omitempty"`
+}
+
+// BulkUserResult is the outcome of a bulk operation for one user.
+type BulkUserResult struct {
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkUsers applies one action to every user in input.IDs within a single
+// transaction. Users that do not exist, or whose change would demote or
+// delete the last remaining admin, are skipped and reported in their result;
+// the others are changed. An invalid action or role is returned as an error
+// without changing anything.
+func (s *Service) BulkUsers(ctx context.Context, input BulkUsersInput) ([]BulkUserResult, error) {
+	switch input.Action {
+	case BulkActionSetRole:
+		input.Role = strings.TrimSpace(input.Role)
+		if !s.ValidRole(input.Role) {
+			return nil, fmt.Errorf("invalid role: %s", input.Role)
+		}
+	case BulkActionVerify, BulkActionDelete:
+	default:
+		return nil, fmt.Errorf("invalid action: %s", input.Action)
+	}
+	if len(input.IDs) == 0 {
+		return nil, errors.New("invalid ids: at least one user ID is required")
+	}
+	if len(input.IDs) > MaxBulkUsers {
+		return nil, fmt.Errorf("invalid ids: at most %d users can be changed at once", MaxBulkUsers)
+	}
+
+	results := make([]BulkUserResult, 0, len(input.IDs))
+	err := s.db.Transaction(ctx, func(tx *database.Tx) error {
+		results = results[:0]
+		for _, id := range input.IDs {
+			err := s.bulkApply(ctx, tx, id, input)
+			result := BulkUserResult{ID: id, Success: err == nil}
+			switch {
+			case err == nil:
+			case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrLastAdmin):
+				result.Error = err.Error()
+			default:
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Action != BulkActionDelete {
+		for _, result := range results {
+			if result.Success {
+				s.publishUser(ctx, events.UserUpdated, result.ID)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func (s *Service) bulkApply(ctx context.Context, tx *database.Tx, id string, input BulkUsersInput) error {
+	var role string
+	if err := tx.QueryRowContext(ctx, "SELECT role FROM _alyx_users WHERE id = ?", id).Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("reading user %s: %w", id, err)
+	}
+
+	removesAdmin := role == RoleAdmin &&
+		(input.Action == BulkActionDelete || (input.Action == BulkActionSetRole && input.Role != RoleAdmin))
+	if removesAdmin {
+		var admins int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM _alyx_users WHERE role = ?", RoleAdmin).Scan(&admins); err != nil {
+			return fmt.Errorf("counting admins: %w", err)
+		}
+		if admins <= 1 {
+			return ErrLastAdmin
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var err error
+	switch input.Action {
+	case BulkActionSetRole:
+		_, err = tx.ExecContext(ctx, "UPDATE _alyx_users SET role = ?, updated_at = ? WHERE id = ?", input.Role, now, id)
+	case BulkActionVerify:
+		_, err = tx.ExecContext(ctx, "UPDATE _alyx_users SET verified = 1, updated_at = ? WHERE id = ?", now, id)
+	case BulkActionDelete:
+		_, err = tx.ExecContext(ctx, "DELETE FROM _alyx_users WHERE id = ?", id)
+	}
+	if err != nil {
+		return fmt.Errorf("applying %s to user %s: %w", input.Action, id, err)
+	}
+	return nil
+}
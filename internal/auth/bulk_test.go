@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestService_BulkUsers(t *testing.T) {
+	db := testDB(t)
+	svc := NewService(db, testAuthConfig())
+	ctx := context.Background()
+
+	create := func(email, role string) *User {
+		t.Helper()
+		user, err := svc.CreateUserByAdmin(ctx, CreateUserInput{Email: email, Password: "password123", Role: role})
+		if err != nil {
+			t.Fatalf("CreateUserByAdmin failed: %v", err)
+		}
+		return user
+	}
+	first := create("first@example.com", RoleAdmin)
+	second := create("second@example.com", RoleAdmin)
+	member := create("member@example.com", RoleUser)
+
+	results, err := svc.BulkUsers(ctx, BulkUsersInput{IDs: []string{member.ID, "missing"}, Action: BulkActionVerify})
+	if err != nil {
+		t.Fatalf("BulkUsers failed: %v", err)
+	}
+	if !results[0].Success || results[1].Success || results[1].Error != ErrUserNotFound.Error() {
+		t.Errorf("unexpected verify results: %+v", results)
+	}
+	if user, _ := svc.GetUserByID(ctx, member.ID); !user.Verified {
+		t.Error("expected member to be verified")
+	}
+
+	// Demoting both admins leaves the last one in place.
+	results, err = svc.BulkUsers(ctx, BulkUsersInput{IDs: []string{first.ID, second.ID}, Action: BulkActionSetRole, Role: RoleUser})
+	if err != nil {
+		t.Fatalf("BulkUsers failed: %v", err)
+	}
+	if !results[0].Success || results[1].Success || results[1].Error != ErrLastAdmin.Error() {
+		t.Errorf("unexpected set_role results: %+v", results)
+	}
+	if user, _ := svc.GetUserByID(ctx, second.ID); user.Role != RoleAdmin {
+		t.Errorf("expected the last admin to keep their role, got %s", user.Role)
+	}
+
+	results, err = svc.BulkUsers(ctx, BulkUsersInput{IDs: []string{second.ID, member.ID}, Action: BulkActionDelete})
+	if err != nil {
+		t.Fatalf("BulkUsers failed: %v", err)
+	}
+	if results[0].Success || !results[1].Success {
+		t.Errorf("unexpected delete results: %+v", results)
+	}
+	if _, err := svc.GetUserByID(ctx, member.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected member to be deleted, got %v", err)
+	}
+
+	for _, input := range []BulkUsersInput{
+		{IDs: []string{member.ID}, Action: "promote"},
+		{IDs: []string{member.ID}, Action: BulkActionSetRole, Role: "owner"},
+		{Action: BulkActionVerify},
+	} {
+		if _, err := svc.BulkUsers(ctx, input); err == nil {
+			t.Errorf("expected an error for %+v", input)
+		}
+	}
+}
+
+func TestService_EachUser(t *testing.T) {
+	db := testDB(t)
+	svc := NewService(db, testAuthConfig())
+	ctx := context.Background()
+
+	// More users than one list page holds.
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i := range maxListLimit + 5 {
+		if _, err := db.ExecContext(ctx,
+			"INSERT INTO _alyx_users (id, email, verified, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+			fmt.Sprintf("user-%d", i), fmt.Sprintf("user%d@example.com", i), i%2 == 0, RoleUser, now, now,
+		); err != nil {
+			t.Fatalf("inserting user: %v", err)
+		}
+	}
+
+	verified := true
+	var count int
+	err := svc.EachUser(ctx, ListUsersOptions{Verified: &verified, Limit: 1}, func(u *User) error {
+		if !u.Verified {
+			t.Errorf("unexpected unverified user %s", u.Email)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachUser failed: %v", err)
+	}
+	if want := (maxListLimit + 6) / 2; count != want {
+		t.Errorf("expected %d verified users, got %d", want, count)
+	}
+}
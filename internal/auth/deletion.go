@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+var (
+	// ErrReauthRequired is returned when deleting an account without the
+	// current password or a recently issued access token.
+	ErrReauthRequired = errors.New("re-authentication required")
+	// ErrDeletionPending is returned when signing in to an account that is
+	// scheduled for deletion.
+	ErrDeletionPending = errors.New("account is scheduled for deletion")
+)
+
+// ReauthMaxAge is how recently an access token must have been issued to
+// delete an account without the password.
+const ReauthMaxAge = 5 * time.Minute
+
+// deletionSweepInterval is how often accounts past their grace period are
+// removed.
+const deletionSweepInterval = time.Hour
+
+// SetUserReferences sets the fields whose documents are deleted or cleared
+// when a user is removed.
+func (s *Service) SetUserReferences(refs []schema.UserReference) {
+	s.refsMu.Lock()
+	s.userRefs = refs
+	s.refsMu.Unlock()
+}
+
+// DeletionGracePeriod returns how long a deleted account can be restored.
+func (s *Service) DeletionGracePeriod() time.Duration {
+	return ttlOrDefault(s.cfg.DeletionGracePeriod, config.DefaultDeletionGracePeriod)
+}
+
+// RequestDeletion schedules the user's account for deletion after the grace
+// period and signs the user out everywhere. The caller must confirm the
+// current password, or present an access token issued within ReauthMaxAge;
+// accounts without a password can only use the latter. If a mailer is set,
+// the user is sent a link that restores the account during the grace period.
+func (s *Service) RequestDeletion(ctx context.Context, userID, password string, tokenIssuedAt time.Time) (*User, error) {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletionRequestedAt != nil {
+		return user, nil
+	}
+
+	if password != "" {
+		_, passwordHash, getErr := s.users.GetWithPassword(ctx, user.Email)
+		if getErr != nil {
+			return nil, getErr
+		}
+		if passwordHash == "" || VerifyPassword(password, passwordHash) != nil {
+			return nil, ErrInvalidCredentials
+		}
+	} else if tokenIssuedAt.IsZero() || time.Since(tokenIssuedAt) > ReauthMaxAge {
+		return nil, ErrReauthRequired
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	err = s.db.Transaction(ctx, func(tx *database.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE _alyx_users SET deletion_requested_at = ?, updated_at = ? WHERE id = ? AND deletion_requested_at IS NULL",
+			now, now, userID,
+		); err != nil {
+			return fmt.Errorf("marking account for deletion: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM _alyx_sessions WHERE user_id = ?", userID); err != nil {
+			return fmt.Errorf("revoking sessions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	grace := s.DeletionGracePeriod()
+	log.Info().Str("user_id", userID).Dur("grace_period", grace).Msg("Account deletion requested")
+	s.publishSessionRevoked(userID, "", RevokeReasonDeletionRequested)
+
+	if s.mailer != nil {
+		token, tokenErr := s.issueToken(ctx, userID, tokenTypeAccountRestore, grace)
+		if tokenErr != nil {
+			log.Error().Err(tokenErr).Str("user_id", userID).Msg("Failed to issue account restore token")
+		} else {
+			s.sendMail(ctx, user, "account deletion", func(ctx context.Context, m Mailer) error {
+				return m.SendAccountDeletion(ctx, user.Email, token, grace)
+			})
+		}
+	}
+
+	return s.updatedUser(ctx, userID)
+}
+
+// RestoreAccount cancels a pending deletion using the token from the
+// deletion email. The user signs in again afterwards.
+func (s *Service) RestoreAccount(ctx context.Context, token string) (*User, error) {
+	var userID string
+	err := s.db.Transaction(ctx, func(tx *database.Tx) error {
+		var err error
+		userID, err = consumeToken(ctx, tx, tokenTypeAccountRestore, token)
+		if err != nil {
+			return err
+		}
+		return clearDeletion(ctx, tx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", userID).Msg("Account restored")
+	return s.updatedUser(ctx, userID)
+}
+
+// RestoreUser cancels a pending deletion (admin operation).
+func (s *Service) RestoreUser(ctx context.Context, userID string) (*User, error) {
+	if _, err := s.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(ctx, func(tx *database.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM _alyx_auth_tokens WHERE user_id = ? AND type = ?",
+			userID, tokenTypeAccountRestore,
+		); err != nil {
+			return err
+		}
+		return clearDeletion(ctx, tx, userID)
+	})
+	if errors.Is(err, ErrInvalidEmailToken) {
+		return s.GetUserByID(ctx, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", userID).Msg("Account restored by admin")
+	return s.updatedUser(ctx, userID)
+}
+
+func clearDeletion(ctx context.Context, tx *database.Tx, userID string) error {
+	result, err := tx.ExecContext(ctx,
+		"UPDATE _alyx_users SET deletion_requested_at = NULL, updated_at = ? WHERE id = ? AND deletion_requested_at IS NOT NULL",
+		time.Now().UTC().Format(time.RFC3339), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("restoring account: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrInvalidEmailToken
+	}
+	return nil
+}
+
+// PurgeDeletedUsers removes accounts whose grace period has passed, applying
+// each field's onUserDelete action to the documents that reference them. It
+// returns the number of accounts removed.
+func (s *Service) PurgeDeletedUsers(ctx context.Context) (int, error) {
+	cutoff := time.Now().UTC().Add(-s.DeletionGracePeriod()).Format(time.RFC3339)
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id FROM _alyx_users WHERE deletion_requested_at IS NOT NULL AND deletion_requested_at <= ?",
+		cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("listing accounts to delete: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning account: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("listing accounts to delete: %w", err)
+	}
+
+	purged := 0
+	for _, id := range ids {
+		ok, err := s.purgeUser(ctx, id, cutoff)
+		if err != nil {
+			return purged, fmt.Errorf("deleting account %s: %w", id, err)
+		}
+		if ok {
+			purged++
+			log.Info().Str("user_id", id).Msg("Deleted account after grace period")
+		}
+	}
+	return purged, nil
+}
+
+// purgeUser deletes one account and applies onUserDelete actions in a single
+// transaction. It reports false if the account was restored in the meantime.
+func (s *Service) purgeUser(ctx context.Context, id, cutoff string) (bool, error) {
+	s.refsMu.RLock()
+	refs := s.userRefs
+	s.refsMu.RUnlock()
+
+	deleted := false
+	err := s.db.Transaction(ctx, func(tx *database.Tx) error {
+		for _, ref := range refs {
+			var query string
+			switch ref.Action {
+			case schema.UserDeleteCascade:
+				query = fmt.Sprintf("DELETE FROM %q WHERE %q = ?", ref.Collection, ref.Field)
+			case schema.UserDeleteSetNull:
+				query = fmt.Sprintf("UPDATE %q SET %q = NULL WHERE %q = ?", ref.Collection, ref.Field, ref.Field)
+			default:
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, query, id); err != nil {
+				return fmt.Errorf("applying onUserDelete to %s.%s: %w", ref.Collection, ref.Field, err)
+			}
+		}
+
+		result, err := tx.ExecContext(ctx,
+			"DELETE FROM _alyx_users WHERE id = ? AND deletion_requested_at IS NOT NULL AND deletion_requested_at <= ?",
+			id, cutoff,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return sql.ErrNoRows
+		}
+		deleted = true
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return deleted, err
+}
+
+// StartDeletionSweeper removes accounts past their grace period in the
+// background until Stop is called.
+func (s *Service) StartDeletionSweeper(ctx context.Context) {
+	s.sweepStop = make(chan struct{})
+	s.sweepWG.Add(1)
+
+	go func() {
+		defer s.sweepWG.Done()
+
+		ticker := time.NewTicker(deletionSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.PurgeDeletedUsers(ctx); err != nil {
+				log.Error().Err(err).Msg("Account deletion sweep failed")
+			}
+
+			select {
+			case <-s.sweepStop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func TestService_RequestDeletion(t *testing.T) {
+	svc := NewService(testDB(t), testAuthConfig())
+	mailer := newFakeMailer()
+	svc.SetMailer(mailer)
+	ctx := context.Background()
+
+	user, tokens, err := svc.Register(ctx, RegisterInput{Email: "user@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	mailer.next(t, "welcome")
+
+	stale := time.Now().Add(-ReauthMaxAge - time.Minute)
+	if _, err := svc.RequestDeletion(ctx, user.ID, "", stale); !errors.Is(err, ErrReauthRequired) {
+		t.Errorf("expected stale token to require re-authentication, got %v", err)
+	}
+	if _, err := svc.RequestDeletion(ctx, user.ID, "wrongpassword", time.Now()); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected wrong password to be rejected, got %v", err)
+	}
+
+	pending, err := svc.RequestDeletion(ctx, user.ID, "password123", stale)
+	if err != nil {
+		t.Fatalf("request deletion: %v", err)
+	}
+	if pending.DeletionRequestedAt == nil {
+		t.Fatal("expected deletion_requested_at to be set")
+	}
+	restore := mailer.next(t, "account_deletion")
+
+	if _, _, err := svc.Refresh(ctx, tokens.RefreshToken); err == nil {
+		t.Error("expected sessions to be revoked")
+	}
+	if _, _, err := svc.Login(ctx, LoginInput{Email: "user@example.com", Password: "password123"}, "", ""); !errors.Is(err, ErrDeletionPending) {
+		t.Errorf("expected login to be blocked, got %v", err)
+	}
+
+	list, err := svc.ListUsers(ctx, ListUsersOptions{PendingDeletion: true})
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	if list.Total != 1 || list.Users[0].ID != user.ID {
+		t.Errorf("expected pending user in filtered list, got %+v", list)
+	}
+
+	if _, err := svc.RestoreAccount(ctx, "bogus"); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("expected invalid token error, got %v", err)
+	}
+	restored, err := svc.RestoreAccount(ctx, restore.token)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.DeletionRequestedAt != nil {
+		t.Error("expected deletion to be cancelled")
+	}
+	if _, _, err := svc.Login(ctx, LoginInput{Email: "user@example.com", Password: "password123"}, "", ""); err != nil {
+		t.Errorf("expected login after restore, got %v", err)
+	}
+	svc.Stop()
+}
+
+func TestService_PurgeDeletedUsers(t *testing.T) {
+	db := testDB(t)
+	cfg := testAuthConfig()
+	cfg.DeletionGracePeriod = time.Hour
+	svc := NewService(db, cfg)
+	ctx := context.Background()
+
+	for _, stmt := range []string{
+		`CREATE TABLE posts (id TEXT PRIMARY KEY, author_id TEXT NOT NULL)`,
+		`CREATE TABLE comments (id TEXT PRIMARY KEY, author_id TEXT)`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("create table: %v", err)
+		}
+	}
+	svc.SetUserReferences([]schema.UserReference{
+		{Collection: "posts", Field: "author_id", Action: schema.UserDeleteCascade},
+		{Collection: "comments", Field: "author_id", Action: schema.UserDeleteSetNull},
+	})
+
+	gone, _, err := svc.Register(ctx, RegisterInput{Email: "gone@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	recent, _, err := svc.Register(ctx, RegisterInput{Email: "recent@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	for _, u := range []*User{gone, recent} {
+		if _, err := svc.RequestDeletion(ctx, u.ID, "password123", time.Time{}); err != nil {
+			t.Fatalf("request deletion: %v", err)
+		}
+		if _, err := db.ExecContext(ctx, `INSERT INTO posts (id, author_id) VALUES (?, ?)`, "post-"+u.ID, u.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.ExecContext(ctx, `INSERT INTO comments (id, author_id) VALUES (?, ?)`, "comment-"+u.ID, u.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().UTC().Add(-2 * time.Hour).Format(time.RFC3339)
+	if _, err := db.ExecContext(ctx, `UPDATE _alyx_users SET deletion_requested_at = ? WHERE id = ?`, past, gone.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := svc.PurgeDeletedUsers(ctx)
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 account purged, got %d", n)
+	}
+
+	if _, err := svc.GetUserByID(ctx, gone.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected purged user to be gone, got %v", err)
+	}
+	if _, err := svc.GetUserByID(ctx, recent.ID); err != nil {
+		t.Errorf("expected user within grace period to remain, got %v", err)
+	}
+
+	var posts int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM posts WHERE author_id = ?`, gone.ID).Scan(&posts); err != nil {
+		t.Fatal(err)
+	}
+	if posts != 0 {
+		t.Errorf("expected cascaded posts to be deleted, got %d", posts)
+	}
+	var author sql.NullString
+	if err := db.QueryRowContext(ctx, `SELECT author_id FROM comments WHERE id = ?`, "comment-"+gone.ID).Scan(&author); err != nil {
+		t.Fatalf("expected comment to be kept: %v", err)
+	}
+	if author.Valid {
+		t.Errorf("expected comment author to be cleared, got %q", author.String)
+	}
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MaxIntrospectTokens is the most tokens one introspection request may check.
+const MaxIntrospectTokens = 20
+
+// TokenIntrospection describes an access token, modeled on RFC 7662. An
+// inactive token reveals nothing else.
+type TokenIntrospection struct {
+	Active    bool       `json:"active"`
+	UserID    string     `json:"user_id,omitempty"`
+	Role      string     `json:"role,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Introspect reports whether token would authenticate a request right now.
+// Besides a valid signature and expiry, that needs the token not to be
+// revoked and its user to exist without a pending deletion, the same checks
+// the auth middleware makes. The role is the user's current role.
+func (s *Service) Introspect(ctx context.Context, token string) (*TokenIntrospection, error) {
+	if token == "" || s.IsTokenRevoked(token) {
+		return &TokenIntrospection{}, nil
+	}
+	claims, err := s.ValidateToken(token)
+	if err != nil {
+		return &TokenIntrospection{}, nil
+	}
+
+	user, err := s.GetUserByID(ctx, claims.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return &TokenIntrospection{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletionRequestedAt != nil {
+		return &TokenIntrospection{}, nil
+	}
+
+	expiresAt := claims.ExpiresAt
+	return &TokenIntrospection{
+		Active:    true,
+		UserID:    user.ID,
+		Role:      user.Role,
+		ExpiresAt: &expiresAt,
+	}, nil
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	// RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public key tokens are verified with, or false when tokens
+// are signed with a shared secret and there is nothing to publish.
+func (s *JWTService) JWKS() (*JWKS, bool) {
+	if s.key == nil {
+		return nil, false
+	}
+	jwk := publicJWK(s.key.Public())
+	jwk.Use = "sig"
+	jwk.Alg = s.method.Alg()
+	jwk.Kid = s.keyID
+	return &JWKS{Keys: []JWK{jwk}}, true
+}
+
+// JWKS returns the public keys access tokens are signed with; see
+// JWTService.JWKS.
+func (s *Service) JWKS() (*JWKS, bool) {
+	return s.jwt.JWKS()
+}
+
+// publicJWK returns the members of key that identify it.
+func publicJWK(key crypto.PublicKey) JWK {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   b64(k.N.Bytes()),
+			E:   b64(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: b64(k)}
+	default:
+		return JWK{}
+	}
+}
+
+// keyThumbprint returns the RFC 7638 thumbprint of key, used as its key ID.
+// The thumbprint hashes the key's required members in lexical order.
+func keyThumbprint(key crypto.PublicKey) string {
+	jwk := publicJWK(key)
+	var members any
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return b64(sum[:])
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+// writeKey writes key to a PEM file and returns a config signing with it.
+func writeKey(t *testing.T, algorithm, blockType string, der []byte) config.JWTConfig {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testJWTConfig()
+	cfg.Algorithm = algorithm
+	cfg.PrivateKeyFile = path
+	return cfg
+}
+
+func TestJWTService_HS256HasNoJWKS(t *testing.T) {
+	svc := NewJWTService(testJWTConfig())
+	if svc.Algorithm() != "HS256" {
+		t.Errorf("algorithm = %s, want HS256", svc.Algorithm())
+	}
+	if _, ok := svc.JWKS(); ok {
+		t.Error("expected no JWKS for a shared secret")
+	}
+
+	token, _, err := svc.GenerateAccessToken(&User{ID: "user123"})
+	if err != nil {
+		t.Fatalf("GenerateAccessToken failed: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Method.Alg() != "HS256" || parsed.Header["kid"] != nil {
+		t.Errorf("unexpected header %v", parsed.Header)
+	}
+}
+
+func TestJWTService_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewJWTService(writeKey(t, config.JWTAlgorithmRS256, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)))
+
+	user := &User{ID: "user123", Email: "test@example.com"}
+	token, _, err := svc.GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken failed: %v", err)
+	}
+	claims, err := svc.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken failed: %v", err)
+	}
+	if claims.UserID != user.ID {
+		t.Errorf("UserID = %s, want %s", claims.UserID, user.ID)
+	}
+	refresh, _, err := svc.GenerateRefreshToken(user.ID)
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken failed: %v", err)
+	}
+	if _, err := svc.ValidateRefreshToken(refresh); err != nil {
+		t.Errorf("ValidateRefreshToken failed: %v", err)
+	}
+
+	// An edge that only has the JWKS can verify the token.
+	jwks, ok := svc.JWKS()
+	if !ok || len(jwks.Keys) != 1 {
+		t.Fatalf("expected one JWK, got %+v", jwks)
+	}
+	jwk := jwks.Keys[0]
+	if jwk.Kty != "RSA" || jwk.Alg != "RS256" || jwk.Use != "sig" || jwk.Kid == "" {
+		t.Errorf("unexpected JWK %+v", jwk)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+	e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+	public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	parsed, err := jwt.Parse(token, func(tok *jwt.Token) (any, error) {
+		if tok.Header["kid"] != jwk.Kid {
+			t.Errorf("token kid %v, want %s", tok.Header["kid"], jwk.Kid)
+		}
+		return public, nil
+	}, jwt.WithValidMethods([]string{"RS256"}))
+	if err != nil || !parsed.Valid {
+		t.Errorf("verifying with the JWKS key failed: %v", err)
+	}
+
+	// Tokens signed with a shared secret are not accepted.
+	hsToken, _, err := NewJWTService(testJWTConfig()).GenerateAccessToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ValidateAccessToken(hsToken); err == nil {
+		t.Error("expected an HS256 token to be rejected")
+	}
+}
+
+func TestJWTService_EdDSA(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewJWTService(writeKey(t, config.JWTAlgorithmEdDSA, "PRIVATE KEY", der))
+
+	token, _, err := svc.GenerateAccessToken(&User{ID: "user123"})
+	if err != nil {
+		t.Fatalf("GenerateAccessToken failed: %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(token); err != nil {
+		t.Errorf("ValidateAccessToken failed: %v", err)
+	}
+	jwks, ok := svc.JWKS()
+	if !ok || jwks.Keys[0].Kty != "OKP" || jwks.Keys[0].Crv != "Ed25519" || jwks.Keys[0].X == "" {
+		t.Errorf("unexpected JWKS %+v", jwks)
+	}
+}
+
+func TestJWTService_MissingKey(t *testing.T) {
+	cfg := testJWTConfig()
+	cfg.Algorithm = config.JWTAlgorithmRS256
+	cfg.PrivateKeyFile = filepath.Join(t.TempDir(), "missing.pem")
+	svc := NewJWTService(cfg)
+
+	if _, _, err := svc.GenerateAccessToken(&User{ID: "user123"}); err == nil {
+		t.Error("expected signing without a key to fail")
+	}
+}
+
+func TestService_Introspect(t *testing.T) {
+	svc := NewService(testDB(t), testAuthConfig())
+	defer svc.Stop()
+	ctx := context.Background()
+
+	user, tokens, err := svc.Register(ctx, RegisterInput{Email: "i@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	got, err := svc.Introspect(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Introspect failed: %v", err)
+	}
+	if !got.Active || got.UserID != user.ID || got.Role != user.Role || got.ExpiresAt == nil || !got.ExpiresAt.After(time.Now()) {
+		t.Errorf("unexpected introspection %+v", got)
+	}
+
+	for name, token := range map[string]string{"garbage": "not-a-token", "empty": ""} {
+		got, err := svc.Introspect(ctx, token)
+		if err != nil {
+			t.Fatalf("Introspect(%s) failed: %v", name, err)
+		}
+		if *got != (TokenIntrospection{}) {
+			t.Errorf("%s token: expected an inactive result, got %+v", name, got)
+		}
+	}
+
+	svc.RevokeToken(tokens.AccessToken, time.Now().Add(time.Hour))
+	if got, _ := svc.Introspect(ctx, tokens.AccessToken); got.Active {
+		t.Error("expected a revoked token to be inactive")
+	}
+}
@@ -1,10 +1,14 @@
 package auth
 
 import (
+	"crypto"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
 
 	"github.com/watzon/alyx/internal/config"
 )
@@ -25,24 +29,115 @@ type jwtClaims struct {
 	Role     string `json:"role,omitempty"`
 }
 
-// JWTService handles JWT token generation and validation.
+// JWTService handles JWT token generation and validation. With HS256, tokens
+// are signed with the first secret and verified against all of them, so a
+// rotated-out secret keeps working until the tokens signed with it expire.
+// With RS256 or EdDSA they are signed with a private key, and the public key
+// is published as a JWKS so other services can verify them.
 type JWTService struct {
-	secret     []byte
+	mu      sync.RWMutex
+	secrets [][]byte
+
+	// key signs tokens in place of the secrets when an asymmetric algorithm
+	// is configured; keyErr is why it could not be loaded.
+	method jwt.SigningMethod
+	key    crypto.Signer
+	keyID  string
+	keyErr error
+
 	issuer     string
 	audience   []string
 	accessTTL  time.Duration
 	refreshTTL time.Duration
 }
 
-// NewJWTService creates a new JWT service from config.
+// NewJWTService creates a new JWT service from config. If the private key of
+// an asymmetric algorithm cannot be loaded, the error is logged and returned
+// whenever a token is generated.
 func NewJWTService(cfg config.JWTConfig) *JWTService {
-	return &JWTService{
-		secret:     []byte(cfg.Secret),
+	s := &JWTService{
+		method:     jwt.SigningMethodHS256,
 		issuer:     cfg.Issuer,
 		audience:   cfg.Audience,
 		accessTTL:  cfg.AccessTTL,
 		refreshTTL: cfg.RefreshTTL,
 	}
+	s.SetSecrets(cfg.VerificationSecrets())
+
+	if cfg.Asymmetric() {
+		s.method = jwt.GetSigningMethod(cfg.Algorithm)
+		key, err := cfg.LoadPrivateKey()
+		if err != nil {
+			log.Error().Err(err).Str("path", cfg.PrivateKeyFile).Msg("Failed to load JWT private key")
+			s.keyErr = fmt.Errorf("loading JWT private key: %w", err)
+		} else {
+			s.key = key
+			s.keyID = keyThumbprint(key.Public())
+		}
+	}
+	return s
+}
+
+// SetSecrets replaces the signing and verification secrets. The first secret
+// signs new tokens.
+func (s *JWTService) SetSecrets(secrets []string) {
+	keys := make([][]byte, 0, len(secrets))
+	for _, secret := range secrets {
+		keys = append(keys, []byte(secret))
+	}
+	if len(keys) == 0 {
+		keys = append(keys, []byte{})
+	}
+
+	s.mu.Lock()
+	s.secrets = keys
+	s.mu.Unlock()
+}
+
+// Algorithm returns the name of the algorithm tokens are signed with.
+func (s *JWTService) Algorithm() string {
+	return s.method.Alg()
+}
+
+// sign signs claims with the configured algorithm.
+func (s *JWTService) sign(claims jwt.Claims) (string, error) {
+	if s.keyErr != nil {
+		return "", s.keyErr
+	}
+
+	token := jwt.NewWithClaims(s.method, claims)
+	if s.key != nil {
+		token.Header["kid"] = s.keyID
+		return token.SignedString(s.key)
+	}
+
+	s.mu.RLock()
+	key := s.secrets[0]
+	s.mu.RUnlock()
+	return token.SignedString(key)
+}
+
+// keyFunc returns the keys a token may be verified with. Only tokens signed
+// with the configured algorithm are accepted, so a public key can never be
+// used as an HMAC secret.
+func (s *JWTService) keyFunc(token *jwt.Token) (any, error) {
+	if token.Method.Alg() != s.method.Alg() {
+		return nil, ErrInvalidSignature
+	}
+	if s.key != nil {
+		return s.key.Public(), nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.secrets) == 1 {
+		return s.secrets[0], nil
+	}
+	keys := make([]jwt.VerificationKey, len(s.secrets))
+	for i, key := range s.secrets {
+		keys[i] = key
+	}
+	return jwt.VerificationKeySet{Keys: keys}, nil
 }
 
 // GenerateAccessToken creates a new access token for the user.
@@ -66,8 +161,7 @@ func (s *JWTService) GenerateAccessToken(user *User) (string, time.Time, error)
 		claims.Audience = s.audience
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(s.secret)
+	signedToken, err := s.sign(claims)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -92,8 +186,7 @@ func (s *JWTService) GenerateRefreshToken(userID string) (string, time.Time, err
 		claims.Audience = s.audience
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(s.secret)
+	signedToken, err := s.sign(claims)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -103,12 +196,7 @@ func (s *JWTService) GenerateRefreshToken(userID string) (string, time.Time, err
 
 // ValidateAccessToken validates an access token and returns the claims.
 func (s *JWTService) ValidateAccessToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwtClaims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidSignature
-		}
-		return s.secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &jwtClaims{}, s.keyFunc)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -149,24 +237,24 @@ func (s *JWTService) ValidateAccessToken(tokenString string) (*Claims, error) {
 	if claims.ExpiresAt != nil {
 		expiresAt = claims.ExpiresAt.Time
 	}
+	issuedAt := time.Time{}
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
 
 	return &Claims{
 		UserID:    claims.Subject,
 		Email:     claims.Email,
 		Verified:  claims.Verified,
 		Role:      claims.Role,
+		IssuedAt:  issuedAt,
 		ExpiresAt: expiresAt,
 	}, nil
 }
 
 // ValidateRefreshToken validates a refresh token and returns the user ID.
 func (s *JWTService) ValidateRefreshToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidSignature
-		}
-		return s.secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, s.keyFunc)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
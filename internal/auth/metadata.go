@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// MetadataError is returned when user metadata does not match the schema's
+// userMetadata declaration.
+type MetadataError struct {
+	Errors schema.ValidationErrors
+}
+
+func (e *MetadataError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "invalid metadata: " + strings.Join(msgs, "; ")
+}
+
+// SetUserMetadata sets the declared shape of user metadata. With a nil
+// schema any JSON object is accepted.
+func (s *Service) SetUserMetadata(m *schema.MetadataSchema) {
+	s.metadataMu.Lock()
+	s.metadataSchema = m
+	s.metadataMu.Unlock()
+}
+
+// UserMetadata returns the declared shape of user metadata, or nil.
+func (s *Service) UserMetadata() *schema.MetadataSchema {
+	s.metadataMu.RLock()
+	defer s.metadataMu.RUnlock()
+	return s.metadataSchema
+}
+
+// SetTenantMetadataKeys sets the metadata keys that name a user's tenant.
+// Users cannot set them on registration or through UpdateMetadata; only
+// admins can.
+func (s *Service) SetTenantMetadataKeys(keys []string) {
+	s.metadataMu.Lock()
+	s.tenantKeys = keys
+	s.metadataMu.Unlock()
+}
+
+// checkTenantKeys rejects self-service metadata that sets a tenant key.
+func (s *Service) checkTenantKeys(metadata map[string]any) error {
+	s.metadataMu.RLock()
+	defer s.metadataMu.RUnlock()
+
+	var errs schema.ValidationErrors
+	for _, key := range s.tenantKeys {
+		if _, ok := metadata[key]; ok {
+			errs = append(errs, &schema.ValidationError{
+				Path:    "metadata." + key,
+				Message: "identifies the user's tenant and can only be set by an admin",
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return &MetadataError{Errors: errs}
+	}
+	return nil
+}
+
+// keepTenantKeys returns the tenant keys of metadata, or nil if it has none.
+func (s *Service) keepTenantKeys(metadata map[string]any) map[string]any {
+	s.metadataMu.RLock()
+	defer s.metadataMu.RUnlock()
+
+	var kept map[string]any
+	for _, key := range s.tenantKeys {
+		if value, ok := metadata[key]; ok {
+			if kept == nil {
+				kept = make(map[string]any)
+			}
+			kept[key] = value
+		}
+	}
+	return kept
+}
+
+// ValidateMetadata checks metadata against the userMetadata declaration.
+func (s *Service) ValidateMetadata(metadata map[string]any) error {
+	m := s.UserMetadata()
+	if m == nil {
+		return nil
+	}
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	if errs := m.Validate(metadata); len(errs) > 0 {
+		return &MetadataError{Errors: errs}
+	}
+	return nil
+}
+
+// UpdateMetadata applies a JSON merge patch (RFC 7386) to a user's metadata:
+// keys set to null are removed, nested objects are merged and anything else
+// replaces the stored value. A nil patch clears the metadata. The result is
+// validated before it is stored. Tenant keys cannot be patched, and a nil
+// patch leaves them in place.
+func (s *Service) UpdateMetadata(ctx context.Context, id string, patch map[string]any) (*User, error) {
+	if err := s.checkTenantKeys(patch); err != nil {
+		return nil, err
+	}
+
+	err := s.users.UpdateMetadata(ctx, id, func(metadata map[string]any) (map[string]any, error) {
+		if patch != nil {
+			metadata = mergePatch(metadata, patch)
+		} else {
+			metadata = s.keepTenantKeys(metadata)
+		}
+		if err := s.ValidateMetadata(metadata); err != nil {
+			return nil, err
+		}
+		return metadata, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug().Str("user_id", id).Msg("User metadata updated")
+	return s.updatedUser(ctx, id)
+}
+
+// mergePatch applies patch to target following RFC 7386.
+func mergePatch(target, patch map[string]any) map[string]any {
+	if target == nil {
+		target = map[string]any{}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if nested, ok := value.(map[string]any); ok {
+			existing, _ := target[key].(map[string]any)
+			target[key] = mergePatch(existing, nested)
+			continue
+		}
+		target[key] = value
+	}
+	return target
+}
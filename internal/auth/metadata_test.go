@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func TestService_UpdateMetadata(t *testing.T) {
+	svc := NewService(testDB(t), testAuthConfig())
+	ctx := context.Background()
+
+	user, _, err := svc.Register(ctx, RegisterInput{
+		Email:    "meta@example.com",
+		Password: "password123",
+		Metadata: map[string]any{"name": "Ada", "prefs": map[string]any{"theme": "dark", "lang": "en"}},
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	updated, err := svc.UpdateMetadata(ctx, user.ID, map[string]any{
+		"name":  nil,
+		"bio":   "hello",
+		"prefs": map[string]any{"theme": "light", "lang": nil},
+	})
+	if err != nil {
+		t.Fatalf("update metadata: %v", err)
+	}
+
+	if _, ok := updated.Metadata["name"]; ok {
+		t.Error("expected null to remove name")
+	}
+	if updated.Metadata["bio"] != "hello" {
+		t.Errorf("expected bio to be set, got %v", updated.Metadata)
+	}
+	prefs, _ := updated.Metadata["prefs"].(map[string]any)
+	if prefs["theme"] != "light" || len(prefs) != 1 {
+		t.Errorf("expected nested merge, got %v", prefs)
+	}
+
+	cleared, err := svc.UpdateMetadata(ctx, user.ID, nil)
+	if err != nil {
+		t.Fatalf("clear metadata: %v", err)
+	}
+	if cleared.Metadata != nil {
+		t.Errorf("expected metadata to be cleared, got %v", cleared.Metadata)
+	}
+
+	if _, err := svc.UpdateMetadata(ctx, "missing", map[string]any{"a": 1.0}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestService_MetadataSchemaValidation(t *testing.T) {
+	svc := NewService(testDB(t), testAuthConfig())
+	noExtra := false
+	svc.SetUserMetadata(&schema.MetadataSchema{
+		AdditionalProperties: &noExtra,
+		Properties: map[string]*schema.MetadataSchema{
+			"name": {Type: schema.MetadataTypeString},
+		},
+	})
+	ctx := context.Background()
+
+	var metaErr *MetadataError
+	_, _, err := svc.Register(ctx, RegisterInput{
+		Email:    "bad@example.com",
+		Password: "password123",
+		Metadata: map[string]any{"plan": "pro"},
+	})
+	if !errors.As(err, &metaErr) || metaErr.Errors[0].Path != "metadata.plan" {
+		t.Fatalf("expected unknown key to be rejected on register, got %v", err)
+	}
+
+	user, _, err := svc.Register(ctx, RegisterInput{Email: "good@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if _, err := svc.UpdateMetadata(ctx, user.ID, map[string]any{"name": 42.0}); !errors.As(err, &metaErr) {
+		t.Errorf("expected wrong type to be rejected, got %v", err)
+	}
+
+	bad := map[string]any{"plan": "pro"}
+	if _, err := svc.UpdateUser(ctx, user.ID, UpdateUserInput{Metadata: &bad}); !errors.As(err, &metaErr) {
+		t.Errorf("expected admin update to be validated, got %v", err)
+	}
+
+	good := map[string]any{"name": "Grace"}
+	updated, err := svc.UpdateUser(ctx, user.ID, UpdateUserInput{Metadata: &good})
+	if err != nil {
+		t.Fatalf("admin update: %v", err)
+	}
+	if updated.Metadata["name"] != "Grace" {
+		t.Errorf("expected metadata to be replaced, got %v", updated.Metadata)
+	}
+}
+
+func TestService_TenantMetadataKeys(t *testing.T) {
+	svc := NewService(testDB(t), testAuthConfig())
+	svc.SetTenantMetadataKeys([]string{"org_id"})
+	ctx := context.Background()
+
+	var metaErr *MetadataError
+	_, _, err := svc.Register(ctx, RegisterInput{
+		Email:    "tenant@example.com",
+		Password: "password123",
+		Metadata: map[string]any{"org_id": "acme"},
+	})
+	if !errors.As(err, &metaErr) || metaErr.Errors[0].Path != "metadata.org_id" {
+		t.Fatalf("expected tenant key to be rejected on register, got %v", err)
+	}
+
+	user, _, err := svc.Register(ctx, RegisterInput{Email: "tenant@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	// Admins assign tenants.
+	assigned := map[string]any{"org_id": "acme", "name": "Ada"}
+	if _, err := svc.UpdateUser(ctx, user.ID, UpdateUserInput{Metadata: &assigned}); err != nil {
+		t.Fatalf("admin update: %v", err)
+	}
+
+	if _, err := svc.UpdateMetadata(ctx, user.ID, map[string]any{"org_id": "globex"}); !errors.As(err, &metaErr) {
+		t.Errorf("expected tenant key patch to be rejected, got %v", err)
+	}
+	if _, err := svc.UpdateMetadata(ctx, user.ID, map[string]any{"org_id": nil}); !errors.As(err, &metaErr) {
+		t.Errorf("expected tenant key removal to be rejected, got %v", err)
+	}
+
+	cleared, err := svc.UpdateMetadata(ctx, user.ID, nil)
+	if err != nil {
+		t.Fatalf("clear metadata: %v", err)
+	}
+	if len(cleared.Metadata) != 1 || cleared.Metadata["org_id"] != "acme" {
+		t.Errorf("expected clearing to keep the tenant key, got %v", cleared.Metadata)
+	}
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// sessionMetricsInterval is how often the number of active sessions is
+// reported.
+const sessionMetricsInterval = time.Minute
+
+// Metrics receives counts of auth activity. The service records nothing until
+// SetMetrics is called.
+type Metrics interface {
+	Login(success bool)
+	Registration()
+	TokenRefresh(success bool)
+	ActiveSessions(n int)
+	PasswordRehash()
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Login(bool)         {}
+func (nopMetrics) Registration()      {}
+func (nopMetrics) TokenRefresh(bool)  {}
+func (nopMetrics) ActiveSessions(int) {}
+func (nopMetrics) PasswordRehash()    {}
+
+// SetMetrics sets where auth activity is recorded.
+func (s *Service) SetMetrics(m Metrics) {
+	if m == nil {
+		m = nopMetrics{}
+	}
+	s.metrics = m
+}
+
+// CountActiveSessions returns the number of sessions that have not expired.
+func (s *Service) CountActiveSessions(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM _alyx_sessions WHERE expires_at > ?",
+		time.Now().UTC().Format(time.RFC3339),
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("counting sessions: %w", err)
+	}
+	return n, nil
+}
+
+// StartSessionMetrics reports the number of active sessions in the background
+// until Stop is called.
+func (s *Service) StartSessionMetrics(ctx context.Context) {
+	s.metricsStop = make(chan struct{})
+	s.metricsWG.Add(1)
+
+	go func() {
+		defer s.metricsWG.Done()
+
+		ticker := time.NewTicker(sessionMetricsInterval)
+		defer ticker.Stop()
+
+		for {
+			if n, err := s.CountActiveSessions(ctx); err != nil {
+				log.Warn().Err(err).Msg("Failed to count active sessions")
+			} else {
+				s.metrics.ActiveSessions(n)
+			}
+
+			select {
+			case <-s.metricsStop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
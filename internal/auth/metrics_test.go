@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+type recordingMetrics struct {
+	logins, failedLogins       int
+	registrations              int
+	refreshes, failedRefreshes int
+	sessions                   int
+	rehashes                   int
+}
+
+func (m *recordingMetrics) Login(success bool) {
+	if success {
+		m.logins++
+	} else {
+		m.failedLogins++
+	}
+}
+
+func (m *recordingMetrics) Registration() { m.registrations++ }
+
+func (m *recordingMetrics) TokenRefresh(success bool) {
+	if success {
+		m.refreshes++
+	} else {
+		m.failedRefreshes++
+	}
+}
+
+func (m *recordingMetrics) ActiveSessions(n int) { m.sessions = n }
+
+func (m *recordingMetrics) PasswordRehash() { m.rehashes++ }
+
+func TestService_Metrics(t *testing.T) {
+	svc := NewService(testDB(t), testAuthConfig())
+	defer svc.Stop()
+	m := &recordingMetrics{}
+	svc.SetMetrics(m)
+	ctx := context.Background()
+
+	if _, _, err := svc.Register(ctx, RegisterInput{Email: "m@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	_, tokens, err := svc.Login(ctx, LoginInput{Email: "m@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if _, _, err := svc.Login(ctx, LoginInput{Email: "m@example.com", Password: "wrong"}, "", ""); err == nil {
+		t.Fatal("expected login with a wrong password to fail")
+	}
+	if _, _, err := svc.Refresh(ctx, tokens.RefreshToken); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, _, err := svc.Refresh(ctx, "not-a-token"); err == nil {
+		t.Fatal("expected refreshing an invalid token to fail")
+	}
+
+	want := recordingMetrics{logins: 1, failedLogins: 1, registrations: 1, refreshes: 1, failedRefreshes: 1}
+	if *m != want {
+		t.Errorf("metrics = %+v, want %+v", *m, want)
+	}
+
+	// Registering and refreshing each left one session.
+	n, err := svc.CountActiveSessions(ctx)
+	if err != nil {
+		t.Fatalf("CountActiveSessions failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("active sessions = %d, want 2", n)
+	}
+}
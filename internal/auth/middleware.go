@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"encoding/json"
 	"net/http"
 	"strings"
+
+	"github.com/watzon/alyx/internal/tracing"
 )
 
 type MiddlewareConfig struct {
@@ -18,7 +21,7 @@ func Middleware(cfg MiddlewareConfig) func(http.Handler) http.Handler {
 
 			if token == "" {
 				if cfg.RequireAuth && !cfg.AllowAnonymous {
-					http.Error(w, `{"error":"Authentication required","code":"UNAUTHORIZED"}`, http.StatusUnauthorized)
+					cfg.Service.writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required")
 					return
 				}
 				next.ServeHTTP(w, r)
@@ -27,7 +30,7 @@ func Middleware(cfg MiddlewareConfig) func(http.Handler) http.Handler {
 
 			if cfg.Service.IsTokenRevoked(token) {
 				if cfg.RequireAuth {
-					http.Error(w, `{"error":"Token has been revoked","code":"TOKEN_REVOKED"}`, http.StatusUnauthorized)
+					cfg.Service.writeError(w, r, http.StatusUnauthorized, "TOKEN_REVOKED", "Token has been revoked")
 					return
 				}
 				next.ServeHTTP(w, r)
@@ -37,7 +40,7 @@ func Middleware(cfg MiddlewareConfig) func(http.Handler) http.Handler {
 			claims, err := cfg.Service.ValidateToken(token)
 			if err != nil {
 				if cfg.RequireAuth {
-					http.Error(w, `{"error":"Invalid or expired token","code":"INVALID_TOKEN"}`, http.StatusUnauthorized)
+					cfg.Service.writeError(w, r, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid or expired token")
 					return
 				}
 				next.ServeHTTP(w, r)
@@ -48,9 +51,20 @@ func Middleware(cfg MiddlewareConfig) func(http.Handler) http.Handler {
 
 			if claims.UserID != "" {
 				user, err := cfg.Service.GetUserByID(r.Context(), claims.UserID)
+				if err == nil && user.DeletionRequestedAt != nil {
+					// Access tokens issued before a deletion request stop
+					// working immediately, like the revoked sessions.
+					if cfg.RequireAuth {
+						cfg.Service.writeError(w, r, http.StatusUnauthorized, "ACCOUNT_PENDING_DELETION", "Account is scheduled for deletion")
+						return
+					}
+					next.ServeHTTP(w, r)
+					return
+				}
 				if err == nil {
 					ctx = ContextWithUser(ctx, user)
 				}
+				tracing.SetUserID(ctx, claims.UserID)
 			}
 
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -58,6 +72,30 @@ func Middleware(cfg MiddlewareConfig) func(http.Handler) http.Handler {
 	}
 }
 
+// ErrorWriter writes an error response for the auth middleware.
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, status int, code, message string)
+
+// SetErrorWriter sets how the auth middleware writes its errors, so they
+// follow the server's error format. By default they are written as
+// {"error": message, "code": code}.
+func (s *Service) SetErrorWriter(fn ErrorWriter) {
+	s.errorWriter = fn
+}
+
+func (s *Service) writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if s.errorWriter == nil {
+		writeJSONError(w, r, status, code, message)
+		return
+	}
+	s.errorWriter(w, r, status, code, message)
+}
+
+func writeJSONError(w http.ResponseWriter, _ *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message, "code": code})
+}
+
 func RequireAuth(service *Service) func(http.Handler) http.Handler {
 	return Middleware(MiddlewareConfig{
 		Service:     service,
@@ -2,7 +2,9 @@ package auth
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -32,6 +34,8 @@ var (
 	ErrOAuthEmailNotVerified = errors.New("email not verified by provider")
 	ErrEmailRequired         = errors.New("email is required from oauth provider")
 	ErrAccountAlreadyLinked  = errors.New("oauth account already linked to another user")
+	ErrInvalidLoginCode      = errors.New("invalid oauth login code")
+	ErrLoginCodeExpired      = errors.New("oauth login code expired")
 )
 
 type OAuthUserInfo struct {
@@ -59,10 +63,20 @@ type OAuthToken struct {
 	Scope        string
 }
 
+// stateTTL is how long a user has to complete an OAuth login.
+const stateTTL = 10 * time.Minute
+
+// loginCodeTTL is how long the one-time code handed to a redirect URI can
+// be exchanged for tokens.
+const loginCodeTTL = time.Minute
+
 type OAuthManager struct {
 	providers map[string]OAuthProvider
 	states    *stateStore
 	mu        sync.RWMutex
+
+	// keys sign the state, the first one signing new states.
+	keys [][]byte
 }
 
 func NewOAuthManager(cfg map[string]config.OAuthProviderConfig) *OAuthManager {
@@ -71,6 +85,14 @@ func NewOAuthManager(cfg map[string]config.OAuthProviderConfig) *OAuthManager {
 		states:    newStateStore(),
 	}
 
+	// Until SetStateSecrets is called, states are signed with a random key
+	// and only this process can validate them.
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("generating oauth state key: %v", err))
+	}
+	m.keys = [][]byte{key}
+
 	for name, providerCfg := range cfg {
 		if providerCfg.ClientID == "" || providerCfg.ClientSecret == "" {
 			continue
@@ -118,26 +140,158 @@ func (m *OAuthManager) ListProviders() []string {
 	return names
 }
 
-func (m *OAuthManager) GenerateState() (string, error) {
-	return m.states.generate()
+// SetStateSecrets replaces the keys states are signed with. The first key
+// signs new states; all of them are accepted, as with JWT secrets.
+func (m *OAuthManager) SetStateSecrets(secrets []string) {
+	if len(secrets) == 0 {
+		return
+	}
+	keys := make([][]byte, 0, len(secrets))
+	for _, secret := range secrets {
+		keys = append(keys, []byte(secret))
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
 }
 
-func (m *OAuthManager) ValidateState(state string) error {
-	return m.states.validate(state)
+// OAuthState is what the state parameter of an OAuth login carries through
+// the provider.
+type OAuthState struct {
+	Nonce    string `json:"n"`
+	Provider string `json:"p"`
+	// RedirectURI is where the login finishes, or empty to answer the
+	// callback with the tokens as JSON.
+	RedirectURI string `json:"r,omitempty"`
+	ExpiresAt   int64  `json:"e"`
+}
+
+// GenerateState returns a signed state for a login with provider that
+// expires after stateTTL. redirectURI must already be allowed.
+func (m *OAuthManager) GenerateState(provider, redirectURI string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(OAuthState{
+		Nonce:       base64.RawURLEncoding.EncodeToString(nonce),
+		Provider:    strings.ToLower(provider),
+		RedirectURI: redirectURI,
+		ExpiresAt:   time.Now().Add(stateTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	key := m.keys[0]
+	m.mu.RUnlock()
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signState(key, encoded)), nil
 }
 
+// ValidateState checks the signature and expiry of state and returns what
+// it carries. Each state is accepted once.
+func (m *OAuthManager) ValidateState(state string) (*OAuthState, error) {
+	encoded, sig, ok := strings.Cut(state, ".")
+	if !ok {
+		return nil, ErrInvalidState
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+
+	m.mu.RLock()
+	keys := m.keys
+	m.mu.RUnlock()
+
+	valid := false
+	for _, key := range keys {
+		if hmac.Equal(mac, signState(key, encoded)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	var st OAuthState
+	if err := json.Unmarshal(payload, &st); err != nil || st.Nonce == "" {
+		return nil, ErrInvalidState
+	}
+	expiresAt := time.Unix(st.ExpiresAt, 0)
+	if time.Now().After(expiresAt) {
+		return nil, ErrStateExpired
+	}
+	if !m.states.use(st.Nonce, expiresAt) {
+		return nil, ErrInvalidState
+	}
+
+	return &st, nil
+}
+
+func signState(key []byte, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// IssueLoginCode returns a one-time code that RedeemLoginCode exchanges for
+// user and tokens within loginCodeTTL. It is what the callback hands to a
+// redirect URI in place of the tokens.
+func (m *OAuthManager) IssueLoginCode(user *User, tokens *TokenPair) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+	m.states.addCode(code, &loginCode{user: user, tokens: tokens, expiresAt: time.Now().Add(loginCodeTTL)})
+	return code, nil
+}
+
+// RedeemLoginCode returns the login a code was issued for. A code can be
+// redeemed once.
+func (m *OAuthManager) RedeemLoginCode(code string) (*User, *TokenPair, error) {
+	login, ok := m.states.takeCode(code)
+	if !ok {
+		return nil, nil, ErrInvalidLoginCode
+	}
+	if time.Now().After(login.expiresAt) {
+		return nil, nil, ErrLoginCodeExpired
+	}
+	return login.user, login.tokens, nil
+}
+
+type loginCode struct {
+	user      *User
+	tokens    *TokenPair
+	expiresAt time.Time
+}
+
+// stateStore remembers the nonces of states that were used, until they
+// expire, and the login codes waiting to be redeemed. Both live in memory,
+// so with several instances a login must be completed and redeemed on the
+// instance that handled the callback.
 type stateStore struct {
-	states map[string]time.Time
+	used   map[string]time.Time
+	codes  map[string]*loginCode
 	mu     sync.Mutex
-	ttl    time.Duration
 	wg     sync.WaitGroup
 	stopCh chan struct{}
 }
 
 func newStateStore() *stateStore {
 	s := &stateStore{
-		states: make(map[string]time.Time),
-		ttl:    10 * time.Minute,
+		used:   make(map[string]time.Time),
+		codes:  make(map[string]*loginCode),
 		stopCh: make(chan struct{}),
 	}
 
@@ -150,36 +304,31 @@ func newStateStore() *stateStore {
 	return s
 }
 
-func (s *stateStore) generate() (string, error) {
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
+// use records nonce as used and reports whether it was not used before.
+func (s *stateStore) use(nonce string, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.used[nonce]; ok {
+		return false
 	}
-	state := base64.URLEncoding.EncodeToString(b)
+	s.used[nonce] = expiresAt
+	return true
+}
 
+func (s *stateStore) addCode(code string, login *loginCode) {
 	s.mu.Lock()
-	s.states[state] = time.Now().Add(s.ttl)
+	s.codes[code] = login
 	s.mu.Unlock()
-
-	return state, nil
 }
 
-func (s *stateStore) validate(state string) error {
+func (s *stateStore) takeCode(code string) (*loginCode, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	expiry, ok := s.states[state]
-	if !ok {
-		return ErrInvalidState
-	}
-
-	delete(s.states, state)
-
-	if time.Now().After(expiry) {
-		return ErrStateExpired
-	}
-
-	return nil
+	login, ok := s.codes[code]
+	delete(s.codes, code)
+	return login, ok
 }
 
 func (s *stateStore) cleanup() {
@@ -191,9 +340,14 @@ func (s *stateStore) cleanup() {
 		case <-ticker.C:
 			s.mu.Lock()
 			now := time.Now()
-			for state, expiry := range s.states {
+			for nonce, expiry := range s.used {
 				if now.After(expiry) {
-					delete(s.states, state)
+					delete(s.used, nonce)
+				}
+			}
+			for code, login := range s.codes {
+				if now.After(login.expiresAt) {
+					delete(s.codes, code)
 				}
 			}
 			s.mu.Unlock()
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -167,7 +168,7 @@ func TestOAuthManager_StateValidation(t *testing.T) {
 	m := NewOAuthManager(nil)
 
 	t.Run("valid state", func(t *testing.T) {
-		state, err := m.GenerateState()
+		state, err := m.GenerateState("github", "")
 		if err != nil {
 			t.Fatalf("GenerateState failed: %v", err)
 		}
@@ -176,36 +177,73 @@ func TestOAuthManager_StateValidation(t *testing.T) {
 			t.Fatal("GenerateState returned empty state")
 		}
 
-		err = m.ValidateState(state)
+		st, err := m.ValidateState(state)
 		if err != nil {
-			t.Errorf("ValidateState failed for valid state: %v", err)
+			t.Fatalf("ValidateState failed for valid state: %v", err)
+		}
+		if st.Provider != "github" || st.RedirectURI != "" {
+			t.Errorf("unexpected state %+v", st)
+		}
+	})
+
+	t.Run("state carries the redirect URI", func(t *testing.T) {
+		state, _ := m.GenerateState("github", "https://app.example.com/callback")
+
+		st, err := m.ValidateState(state)
+		if err != nil {
+			t.Fatalf("ValidateState failed: %v", err)
+		}
+		if st.RedirectURI != "https://app.example.com/callback" {
+			t.Errorf("expected redirect URI to round-trip, got %q", st.RedirectURI)
 		}
 	})
 
 	t.Run("state can only be used once", func(t *testing.T) {
-		state, _ := m.GenerateState()
+		state, _ := m.GenerateState("github", "")
 
-		err := m.ValidateState(state)
+		_, err := m.ValidateState(state)
 		if err != nil {
 			t.Fatalf("First validation failed: %v", err)
 		}
 
-		err = m.ValidateState(state)
+		_, err = m.ValidateState(state)
 		if !errors.Is(err, ErrInvalidState) {
 			t.Errorf("expected ErrInvalidState on second use, got %v", err)
 		}
 	})
 
 	t.Run("invalid state", func(t *testing.T) {
-		err := m.ValidateState("invalid-state-token")
+		_, err := m.ValidateState("invalid-state-token")
+		if !errors.Is(err, ErrInvalidState) {
+			t.Errorf("expected ErrInvalidState, got %v", err)
+		}
+	})
+
+	t.Run("tampered state", func(t *testing.T) {
+		state, _ := m.GenerateState("github", "https://app.example.com/callback")
+		_, sig, _ := strings.Cut(state, ".")
+		forged, _ := json.Marshal(OAuthState{Nonce: "n", Provider: "github", RedirectURI: "https://evil.example.com", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+
+		_, err := m.ValidateState(base64.RawURLEncoding.EncodeToString(forged) + "." + sig)
+		if !errors.Is(err, ErrInvalidState) {
+			t.Errorf("expected ErrInvalidState, got %v", err)
+		}
+	})
+
+	t.Run("state signed with another secret", func(t *testing.T) {
+		other := NewOAuthManager(nil)
+		other.SetStateSecrets([]string{"another-secret-that-is-long-enough"})
+		state, _ := other.GenerateState("github", "")
+
+		_, err := m.ValidateState(state)
 		if !errors.Is(err, ErrInvalidState) {
 			t.Errorf("expected ErrInvalidState, got %v", err)
 		}
 	})
 
 	t.Run("unique states", func(t *testing.T) {
-		state1, _ := m.GenerateState()
-		state2, _ := m.GenerateState()
+		state1, _ := m.GenerateState("github", "")
+		state2, _ := m.GenerateState("github", "")
 
 		if state1 == state2 {
 			t.Error("GenerateState should produce unique states")
@@ -213,6 +251,34 @@ func TestOAuthManager_StateValidation(t *testing.T) {
 	})
 }
 
+func TestOAuthManager_LoginCodes(t *testing.T) {
+	m := NewOAuthManager(nil)
+	user := &User{ID: "u1"}
+	tokens := &TokenPair{AccessToken: "access"}
+
+	code, err := m.IssueLoginCode(user, tokens)
+	if err != nil {
+		t.Fatalf("IssueLoginCode failed: %v", err)
+	}
+
+	gotUser, gotTokens, err := m.RedeemLoginCode(code)
+	if err != nil {
+		t.Fatalf("RedeemLoginCode failed: %v", err)
+	}
+	if gotUser.ID != "u1" || gotTokens.AccessToken != "access" {
+		t.Errorf("unexpected login %+v %+v", gotUser, gotTokens)
+	}
+
+	if _, _, err := m.RedeemLoginCode(code); !errors.Is(err, ErrInvalidLoginCode) {
+		t.Errorf("expected ErrInvalidLoginCode on second use, got %v", err)
+	}
+
+	m.states.addCode("expired", &loginCode{user: user, tokens: tokens, expiresAt: time.Now().Add(-time.Second)})
+	if _, _, err := m.RedeemLoginCode("expired"); !errors.Is(err, ErrLoginCodeExpired) {
+		t.Errorf("expected ErrLoginCodeExpired, got %v", err)
+	}
+}
+
 func TestBaseProvider_AuthURL(t *testing.T) {
 	p := &baseProvider{
 		name:     "test",
@@ -6,15 +6,9 @@ import (
 	"errors"
 	"unicode"
 
-	"golang.org/x/crypto/bcrypt"
-
 	"github.com/watzon/alyx/internal/config"
 )
 
-const (
-	bcryptCost = 12
-)
-
 var (
 	ErrPasswordTooShort     = errors.New("password is too short")
 	ErrPasswordNoUppercase  = errors.New("password must contain at least one uppercase letter")
@@ -25,21 +19,18 @@ var (
 	ErrPasswordHashMismatch = errors.New("password does not match")
 )
 
-// HashPassword hashes a password using bcrypt.
+// defaultHasher hashes with the default bcrypt configuration.
+var defaultHasher = NewPasswordHasher(config.PasswordHashConfig{})
+
+// HashPassword hashes a password using bcrypt with the default cost.
 func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hash), nil
+	return defaultHasher.Hash(password)
 }
 
-// VerifyPassword checks if a password matches a hash.
+// VerifyPassword checks if a password matches a hash made with any supported
+// algorithm.
 func VerifyPassword(password, hash string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-		return ErrPasswordHashMismatch
-	}
+	_, err := defaultHasher.Verify(password, hash)
 	return err
 }
 
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// ErrUnknownPasswordHash is returned when a stored hash was not made by a
+// supported algorithm.
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords with the configured algorithm and
+// parameters, and verifies hashes made with any supported algorithm.
+type PasswordHasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     argon2Params
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	keyLen      uint32
+}
+
+// NewPasswordHasher returns a hasher for cfg. Unset parameters take their
+// defaults.
+func NewPasswordHasher(cfg config.PasswordHashConfig) *PasswordHasher {
+	h := &PasswordHasher{
+		algorithm:  cfg.Algorithm,
+		bcryptCost: cfg.Bcrypt.Cost,
+		argon2: argon2Params{
+			memory:      uint32(cfg.Argon2id.Memory),
+			iterations:  uint32(cfg.Argon2id.Iterations),
+			parallelism: uint8(cfg.Argon2id.Parallelism),
+			keyLen:      argon2KeyLen,
+		},
+	}
+	if h.algorithm == "" {
+		h.algorithm = config.PasswordHashBcrypt
+	}
+	if h.bcryptCost == 0 {
+		h.bcryptCost = config.DefaultBcryptCost
+	}
+	if h.argon2.memory == 0 {
+		h.argon2.memory = config.DefaultArgon2idMemory
+	}
+	if h.argon2.iterations == 0 {
+		h.argon2.iterations = config.DefaultArgon2idIterations
+	}
+	if h.argon2.parallelism == 0 {
+		h.argon2.parallelism = config.DefaultArgon2idParallelism
+	}
+	return h
+}
+
+// Hash hashes password. The result records the algorithm and parameters, so
+// it can be verified after the configuration changes.
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.algorithm == config.PasswordHashArgon2id {
+		return hashArgon2id(password, h.argon2)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify checks password against hash. When they match, rehash reports
+// whether hash was made with another algorithm or other parameters than the
+// hasher's, and should be replaced with a new Hash.
+func (h *PasswordHasher) Verify(password, hash string) (rehash bool, err error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+		got := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, params.keyLen)
+		if subtle.ConstantTimeCompare(got, key) != 1 {
+			return false, ErrPasswordHashMismatch
+		}
+		return h.algorithm != config.PasswordHashArgon2id || params != h.argon2, nil
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, ErrPasswordHashMismatch
+	}
+	if err != nil {
+		return false, err
+	}
+	cost, _ := bcrypt.Cost([]byte(hash))
+	return h.algorithm != config.PasswordHashBcrypt || cost != h.bcryptCost, nil
+}
+
+// rehashPassword replaces oldHash, which password has just matched, with a
+// hash made with the current configuration. The login has already succeeded,
+// so failures are only logged. A password changed in the meantime is left
+// alone.
+func (s *Service) rehashPassword(ctx context.Context, userID, password, oldHash string) {
+	newHash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Msg("Failed to rehash password")
+		return
+	}
+	replaced, err := s.users.ReplacePasswordHash(ctx, userID, oldHash, newHash)
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Msg("Failed to store rehashed password")
+		return
+	}
+	if replaced {
+		s.metrics.PasswordRehash()
+		log.Debug().Str("user_id", userID).Str("algorithm", s.hasher.algorithm).Msg("Password rehashed")
+	}
+}
+
+// hashArgon2id hashes password in the PHC string format,
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>.
+func hashArgon2id(password string, p argon2Params) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generating salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.iterations, p.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func parseArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil ||
+		p.iterations == 0 || p.parallelism == 0 {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+	p.keyLen = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+var testArgon2id = config.PasswordHashConfig{
+	Algorithm: config.PasswordHashArgon2id,
+	Argon2id:  config.Argon2idConfig{Memory: 64, Iterations: 1, Parallelism: 1},
+}
+
+func TestPasswordHasher_Argon2id(t *testing.T) {
+	h := NewPasswordHasher(testArgon2id)
+
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("unexpected hash format %q", hash)
+	}
+
+	rehash, err := h.Verify("correct horse", hash)
+	if err != nil || rehash {
+		t.Errorf("Verify = %v, %v; want false, nil", rehash, err)
+	}
+	if _, err := h.Verify("wrong horse", hash); !errors.Is(err, ErrPasswordHashMismatch) {
+		t.Errorf("expected ErrPasswordHashMismatch, got %v", err)
+	}
+
+	stronger := testArgon2id
+	stronger.Argon2id.Iterations = 2
+	if rehash, err := NewPasswordHasher(stronger).Verify("correct horse", hash); err != nil || !rehash {
+		t.Errorf("Verify with more iterations = %v, %v; want true, nil", rehash, err)
+	}
+
+	if _, err := h.Verify("correct horse", "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5"); !errors.Is(err, ErrUnknownPasswordHash) {
+		t.Errorf("expected ErrUnknownPasswordHash, got %v", err)
+	}
+}
+
+func TestPasswordHasher_Bcrypt(t *testing.T) {
+	old := NewPasswordHasher(config.PasswordHashConfig{Bcrypt: config.BcryptConfig{Cost: 4}})
+	hash, err := old.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+
+	if rehash, err := old.Verify("correct horse", hash); err != nil || rehash {
+		t.Errorf("Verify = %v, %v; want false, nil", rehash, err)
+	}
+
+	higher := NewPasswordHasher(config.PasswordHashConfig{Bcrypt: config.BcryptConfig{Cost: 5}})
+	if rehash, err := higher.Verify("correct horse", hash); err != nil || !rehash {
+		t.Errorf("Verify with a higher cost = %v, %v; want true, nil", rehash, err)
+	}
+
+	if rehash, err := NewPasswordHasher(testArgon2id).Verify("correct horse", hash); err != nil || !rehash {
+		t.Errorf("Verify with argon2id configured = %v, %v; want true, nil", rehash, err)
+	}
+	if _, err := NewPasswordHasher(testArgon2id).Verify("wrong horse", hash); !errors.Is(err, ErrPasswordHashMismatch) {
+		t.Errorf("expected ErrPasswordHashMismatch, got %v", err)
+	}
+}
+
+func TestService_RehashOnLogin(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	// Register under the old bcrypt configuration.
+	cfg := testAuthConfig()
+	if _, _, err := NewService(db, cfg).Register(ctx, RegisterInput{Email: "old@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	storedHash := func() string {
+		t.Helper()
+		var hash string
+		if err := db.QueryRowContext(ctx, "SELECT password_hash FROM _alyx_users WHERE email = ?", "old@example.com").Scan(&hash); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	if hash := storedHash(); !strings.HasPrefix(hash, "$2") {
+		t.Fatalf("expected a bcrypt hash, got %q", hash)
+	}
+
+	upgraded := testAuthConfig()
+	upgraded.Password.Hash = testArgon2id
+	svc := NewService(db, upgraded)
+	m := &recordingMetrics{}
+	svc.SetMetrics(m)
+
+	for range 2 {
+		if _, _, err := svc.Login(ctx, LoginInput{Email: "old@example.com", Password: "password123"}, "", ""); err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+	}
+	if hash := storedHash(); !strings.HasPrefix(hash, "$argon2id$") {
+		t.Errorf("expected the hash to be upgraded to argon2id, got %q", hash)
+	}
+	if m.rehashes != 1 {
+		t.Errorf("rehashes = %d, want 1", m.rehashes)
+	}
+
+	if _, _, err := svc.Login(ctx, LoginInput{Email: "old@example.com", Password: "wrong-password"}, "", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
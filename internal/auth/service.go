@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,10 +14,13 @@ import (
 
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/events"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/store"
 )
 
 var (
-	ErrUserNotFound       = errors.New("user not found")
+	ErrUserNotFound       = store.ErrUserNotFound
 	ErrUserAlreadyExists  = errors.New("user with this email already exists")
 	ErrInvalidCredentials = errors.New("invalid email or password")
 	ErrSessionNotFound    = errors.New("session not found")
@@ -28,11 +32,35 @@ var (
 // Service provides authentication operations.
 type Service struct {
 	db          *database.DB
+	users       store.UserStore
 	jwt         *JWTService
+	hasher      *PasswordHasher
 	cfg         *config.AuthConfig
 	oauth       *OAuthManager
 	hookTrigger HookTrigger
 	blacklist   *TokenBlacklist
+	mailer      Mailer
+	mailWG      sync.WaitGroup
+
+	rolesMu sync.RWMutex
+	roles   map[string]bool
+
+	metadataMu     sync.RWMutex
+	metadataSchema *schema.MetadataSchema
+	tenantKeys     []string
+
+	refsMu    sync.RWMutex
+	userRefs  []schema.UserReference
+	sweepStop chan struct{}
+	sweepWG   sync.WaitGroup
+
+	metrics     Metrics
+	metricsStop chan struct{}
+	metricsWG   sync.WaitGroup
+
+	events events.Publisher
+
+	errorWriter ErrorWriter
 }
 
 // HookTrigger defines the interface for auth event hooks.
@@ -46,12 +74,18 @@ type HookTrigger interface {
 
 // NewService creates a new auth service.
 func NewService(db *database.DB, cfg *config.AuthConfig) *Service {
+	oauth := NewOAuthManager(cfg.OAuth)
+	oauth.SetStateSecrets(cfg.JWT.VerificationSecrets())
 	return &Service{
 		db:        db,
+		users:     store.NewSQLiteUsers(db),
 		jwt:       NewJWTService(cfg.JWT),
+		hasher:    NewPasswordHasher(cfg.Password.Hash),
 		cfg:       cfg,
-		oauth:     NewOAuthManager(cfg.OAuth),
+		oauth:     oauth,
 		blacklist: NewTokenBlacklist(),
+		metrics:   nopMetrics{},
+		events:    events.Nop,
 	}
 }
 
@@ -60,11 +94,85 @@ func (s *Service) SetHookTrigger(trigger HookTrigger) {
 	s.hookTrigger = trigger
 }
 
+// SetEventPublisher sets where user and session events are published, for
+// the realtime _auth channel.
+func (s *Service) SetEventPublisher(p events.Publisher) {
+	if p == nil {
+		p = events.Nop
+	}
+	s.events = p
+}
+
+// publishUser publishes a user event with the stored state of the user.
+func (s *Service) publishUser(ctx context.Context, event events.Type, userID string) {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return
+	}
+	s.events.Publish(events.ChannelAuth, event, user)
+}
+
+// updatedUser returns the stored state of a user that was just changed and
+// publishes it as a user.updated event.
+func (s *Service) updatedUser(ctx context.Context, userID string) (*User, error) {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.events.Publish(events.ChannelAuth, events.UserUpdated, user)
+	return user, nil
+}
+
+// publishSessionRevoked publishes a session.revoked event. An empty
+// sessionID means every session of the user was revoked.
+func (s *Service) publishSessionRevoked(userID, sessionID, reason string) {
+	s.events.Publish(events.ChannelAuth, events.SessionRevoked, &RevokedSession{
+		UserID:    userID,
+		SessionID: sessionID,
+		Reason:    reason,
+	})
+}
+
+// SetRoles replaces the set of roles users may be assigned. The built-in user
+// and admin roles are always allowed.
+func (s *Service) SetRoles(roles []string) {
+	set := map[string]bool{RoleUser: true, RoleAdmin: true}
+	for _, r := range roles {
+		set[r] = true
+	}
+
+	s.rolesMu.Lock()
+	s.roles = set
+	s.rolesMu.Unlock()
+}
+
+// ValidRole reports whether role may be assigned to a user.
+func (s *Service) ValidRole(role string) bool {
+	s.rolesMu.RLock()
+	defer s.rolesMu.RUnlock()
+
+	if s.roles == nil {
+		return role == RoleUser || role == RoleAdmin
+	}
+	return s.roles[role]
+}
+
 // Stop stops the auth service and cleans up resources.
 func (s *Service) Stop() {
 	if s.blacklist != nil {
 		s.blacklist.Stop()
 	}
+	if s.sweepStop != nil {
+		close(s.sweepStop)
+		s.sweepWG.Wait()
+		s.sweepStop = nil
+	}
+	if s.metricsStop != nil {
+		close(s.metricsStop)
+		s.metricsWG.Wait()
+		s.metricsStop = nil
+	}
+	s.mailWG.Wait()
 }
 
 // OAuth returns the OAuth manager.
@@ -87,9 +195,16 @@ func (s *Service) Register(ctx context.Context, input RegisterInput) (*User, *To
 		return nil, nil, fmt.Errorf("password validation: %w", validationErr)
 	}
 
+	if metadataErr := s.checkTenantKeys(input.Metadata); metadataErr != nil {
+		return nil, nil, metadataErr
+	}
+	if metadataErr := s.ValidateMetadata(input.Metadata); metadataErr != nil {
+		return nil, nil, metadataErr
+	}
+
 	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
 
-	existing, existingErr := s.getUserByEmail(ctx, input.Email)
+	existing, existingErr := s.users.GetByEmail(ctx, input.Email)
 	if existingErr != nil && !errors.Is(existingErr, ErrUserNotFound) {
 		return nil, nil, fmt.Errorf("checking existing user: %w", existingErr)
 	}
@@ -97,32 +212,27 @@ func (s *Service) Register(ctx context.Context, input RegisterInput) (*User, *To
 		return nil, nil, ErrUserAlreadyExists
 	}
 
-	passwordHash, err := HashPassword(input.Password)
+	passwordHash, err := s.hasher.Hash(input.Password)
 	if err != nil {
 		return nil, nil, fmt.Errorf("hashing password: %w", err)
 	}
 
-	// First user becomes admin, subsequent users get default role
-	role := RoleUser
-	if !hasUsers {
-		role = RoleAdmin
-	}
-
 	user := &User{
 		ID:        uuid.New().String(),
 		Email:     input.Email,
-		Role:      role,
 		Verified:  !s.cfg.RequireVerification,
 		CreatedAt: time.Now().UTC(),
 		UpdatedAt: time.Now().UTC(),
 		Metadata:  input.Metadata,
 	}
 
-	if createErr := s.createUser(ctx, user, passwordHash); createErr != nil {
+	if createErr := s.users.CreateRegistered(ctx, user, passwordHash, s.cfg.FirstUserAdmin); createErr != nil {
 		return nil, nil, fmt.Errorf("creating user: %w", createErr)
 	}
 
-	log.Info().Str("user_id", user.ID).Str("email", user.Email).Msg("User registered")
+	log.Info().Str("user_id", user.ID).Str("email", user.Email).Str("role", user.Role).Msg("User registered")
+	s.metrics.Registration()
+	s.publishUser(ctx, events.UserRegistered, user.ID)
 
 	if s.hookTrigger != nil {
 		if hookErr := s.hookTrigger.OnSignup(ctx, user, nil); hookErr != nil {
@@ -130,6 +240,14 @@ func (s *Service) Register(ctx context.Context, input RegisterInput) (*User, *To
 		}
 	}
 
+	if user.Verified {
+		s.sendMail(ctx, user, "welcome", func(ctx context.Context, m Mailer) error {
+			return m.SendWelcome(ctx, user.Email)
+		})
+	} else if mailErr := s.sendVerification(ctx, user); mailErr != nil {
+		log.Error().Err(mailErr).Str("user_id", user.ID).Msg("Failed to send verification email")
+	}
+
 	tokens, err := s.createSession(ctx, user, "", "")
 	if err != nil {
 		return nil, nil, fmt.Errorf("creating session: %w", err)
@@ -140,9 +258,15 @@ func (s *Service) Register(ctx context.Context, input RegisterInput) (*User, *To
 
 // Login authenticates a user and returns tokens.
 func (s *Service) Login(ctx context.Context, input LoginInput, userAgent, ipAddress string) (*User, *TokenPair, error) {
+	user, tokens, err := s.login(ctx, input, userAgent, ipAddress)
+	s.metrics.Login(err == nil)
+	return user, tokens, err
+}
+
+func (s *Service) login(ctx context.Context, input LoginInput, userAgent, ipAddress string) (*User, *TokenPair, error) {
 	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
 
-	user, passwordHash, err := s.getUserWithPassword(ctx, input.Email)
+	user, passwordHash, err := s.users.GetWithPassword(ctx, input.Email)
 	if err != nil {
 		if errors.Is(err, ErrUserNotFound) {
 			return nil, nil, ErrInvalidCredentials
@@ -154,14 +278,22 @@ func (s *Service) Login(ctx context.Context, input LoginInput, userAgent, ipAddr
 		return nil, nil, ErrInvalidCredentials
 	}
 
-	if verifyErr := VerifyPassword(input.Password, passwordHash); verifyErr != nil {
+	rehash, verifyErr := s.hasher.Verify(input.Password, passwordHash)
+	if verifyErr != nil {
 		return nil, nil, ErrInvalidCredentials
 	}
+	if rehash {
+		s.rehashPassword(ctx, user.ID, input.Password, passwordHash)
+	}
 
 	if s.cfg.RequireVerification && !user.Verified {
 		return nil, nil, ErrEmailNotVerified
 	}
 
+	if user.DeletionRequestedAt != nil {
+		return nil, nil, ErrDeletionPending
+	}
+
 	log.Info().Str("user_id", user.ID).Str("email", user.Email).Msg("User logged in")
 
 	if s.hookTrigger != nil {
@@ -184,6 +316,12 @@ func (s *Service) Login(ctx context.Context, input LoginInput, userAgent, ipAddr
 
 // Refresh exchanges a refresh token for new tokens.
 func (s *Service) Refresh(ctx context.Context, refreshToken string) (*User, *TokenPair, error) {
+	user, tokens, err := s.refresh(ctx, refreshToken)
+	s.metrics.TokenRefresh(err == nil)
+	return user, tokens, err
+}
+
+func (s *Service) refresh(ctx context.Context, refreshToken string) (*User, *TokenPair, error) {
 	userID, err := s.jwt.ValidateRefreshToken(refreshToken)
 	if err != nil {
 		return nil, nil, fmt.Errorf("validating refresh token: %w", err)
@@ -245,7 +383,11 @@ func (s *Service) Logout(ctx context.Context, refreshToken string) error {
 		}
 	}
 
-	return s.deleteSession(ctx, session.ID)
+	if err := s.deleteSession(ctx, session.ID); err != nil {
+		return err
+	}
+	s.publishSessionRevoked(session.UserID, session.ID, RevokeReasonLogout)
+	return nil
 }
 
 // ValidateToken validates an access token and returns the claims.
@@ -253,6 +395,19 @@ func (s *Service) ValidateToken(token string) (*Claims, error) {
 	return s.jwt.ValidateAccessToken(token)
 }
 
+// IssueAccessToken signs an access token for user without creating a
+// session. It is meant for trusted callers such as test harnesses.
+func (s *Service) IssueAccessToken(user *User) (string, time.Time, error) {
+	return s.jwt.GenerateAccessToken(user)
+}
+
+// SetJWTSecrets replaces the secrets tokens and OAuth states are signed and
+// verified with, first one signing, without restarting the service.
+func (s *Service) SetJWTSecrets(secrets []string) {
+	s.jwt.SetSecrets(secrets)
+	s.oauth.SetStateSecrets(secrets)
+}
+
 // RevokeToken adds a token to the blacklist.
 func (s *Service) RevokeToken(token string, expiresAt time.Time) {
 	s.blacklist.Revoke(token, expiresAt)
@@ -265,100 +420,24 @@ func (s *Service) IsTokenRevoked(token string) bool {
 
 // HasUsers returns true if any users exist in the system.
 func (s *Service) HasUsers(ctx context.Context) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM _alyx_users LIMIT 1)`
-	var exists bool
-	err := s.db.QueryRowContext(ctx, query).Scan(&exists)
-	if err != nil {
-		return false, fmt.Errorf("checking for users: %w", err)
-	}
-	return exists, nil
+	return s.users.HasUsers(ctx)
 }
 
 // GetUserByID retrieves a user by ID.
 func (s *Service) GetUserByID(ctx context.Context, id string) (*User, error) {
-	query := `SELECT id, email, verified, role, created_at, updated_at, metadata FROM _alyx_users WHERE id = ?`
-	return s.scanUserRow(s.db.QueryRowContext(ctx, query, id))
+	return s.users.Get(ctx, id)
 }
 
-func (s *Service) getUserByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id, email, verified, role, created_at, updated_at, metadata FROM _alyx_users WHERE email = ?`
-	return s.scanUserRow(s.db.QueryRowContext(ctx, query, email))
-}
-
-func (s *Service) scanUserRow(row *sql.Row) (*User, error) {
-	user := &User{}
-	var metadataJSON sql.NullString
-	var role sql.NullString
-	var createdAt, updatedAt string
-
-	err := row.Scan(&user.ID, &user.Email, &user.Verified, &role, &createdAt, &updatedAt, &metadataJSON)
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, ErrUserNotFound
-	}
-	if err != nil {
-		return nil, fmt.Errorf("scanning user: %w", err)
-	}
-
-	user.Role = role.String
-	if user.Role == "" {
-		user.Role = RoleUser
-	}
-	user.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-	user.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
-
-	return user, nil
-}
-
-func (s *Service) getUserWithPassword(ctx context.Context, email string) (*User, string, error) {
-	query := `SELECT id, email, password_hash, verified, role, created_at, updated_at, metadata FROM _alyx_users WHERE email = ?`
-	row := s.db.QueryRowContext(ctx, query, email)
-
-	user := &User{}
-	var passwordHash sql.NullString
-	var metadataJSON sql.NullString
-	var role sql.NullString
-	var createdAt, updatedAt string
-
-	err := row.Scan(&user.ID, &user.Email, &passwordHash, &user.Verified, &role, &createdAt, &updatedAt, &metadataJSON)
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, "", ErrUserNotFound
-	}
-	if err != nil {
-		return nil, "", fmt.Errorf("scanning user: %w", err)
-	}
-
-	user.Role = role.String
-	if user.Role == "" {
-		user.Role = RoleUser
-	}
-	user.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-	user.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
-
-	return user, passwordHash.String, nil
+// GetUserByEmail retrieves a user by email address.
+func (s *Service) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	return s.users.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
 }
 
-func (s *Service) createUser(ctx context.Context, user *User, passwordHash string) error {
-	query := `INSERT INTO _alyx_users (id, email, password_hash, verified, created_at, updated_at, metadata) VALUES (?, ?, ?, ?, ?, ?, ?)`
-
-	var metadata any
-	if user.Metadata != nil {
-		metadata = user.Metadata
+func (s *Service) createSession(ctx context.Context, user *User, userAgent, ipAddress string) (*TokenPair, error) {
+	if user.DeletionRequestedAt != nil {
+		return nil, ErrDeletionPending
 	}
 
-	_, err := s.db.ExecContext(ctx, query,
-		user.ID,
-		user.Email,
-		passwordHash,
-		user.Verified,
-		user.CreatedAt.Format(time.RFC3339),
-		user.UpdatedAt.Format(time.RFC3339),
-		metadata,
-	)
-
-	return err
-}
-
-func (s *Service) createSession(ctx context.Context, user *User, userAgent, ipAddress string) (*TokenPair, error) {
 	accessToken, expiresAt, err := s.jwt.GenerateAccessToken(user)
 	if err != nil {
 		return nil, fmt.Errorf("generating access token: %w", err)
@@ -405,7 +484,7 @@ func (s *Service) createSession(ctx context.Context, user *User, userAgent, ipAd
 
 func (s *Service) getSessionByRefreshHash(ctx context.Context, refreshHash string) (*Session, error) {
 	query := `SELECT id, user_id, refresh_token_hash, expires_at, created_at, user_agent, ip_address FROM _alyx_sessions WHERE refresh_token_hash = ?`
-	row := s.db.QueryRowContext(ctx, query, refreshHash)
+	row := s.db.Stmts().QueryRowContext(ctx, query, refreshHash)
 
 	session := &Session{}
 	var expiresAt, createdAt string
@@ -429,7 +508,7 @@ func (s *Service) getSessionByRefreshHash(ctx context.Context, refreshHash strin
 
 func (s *Service) deleteSession(ctx context.Context, id string) error {
 	query := `DELETE FROM _alyx_sessions WHERE id = ?`
-	_, err := s.db.ExecContext(ctx, query, id)
+	_, err := s.db.Stmts().ExecContext(ctx, query, id)
 	return err
 }
 
@@ -456,7 +535,7 @@ func (s *Service) OAuthLogin(ctx context.Context, userInfo *OAuthUserInfo, userA
 		return user, tokens, nil
 	}
 
-	existingUser, getUserErr := s.getUserByEmail(ctx, userInfo.Email)
+	existingUser, getUserErr := s.users.GetByEmail(ctx, userInfo.Email)
 	if getUserErr != nil && !errors.Is(getUserErr, ErrUserNotFound) {
 		return nil, nil, fmt.Errorf("checking existing user: %w", getUserErr)
 	}
@@ -484,7 +563,7 @@ func (s *Service) OAuthLogin(ctx context.Context, userInfo *OAuthUserInfo, userA
 		UpdatedAt: time.Now().UTC(),
 	}
 
-	if createErr := s.createUser(ctx, user, ""); createErr != nil {
+	if createErr := s.users.Create(ctx, user, ""); createErr != nil {
 		return nil, nil, fmt.Errorf("creating user: %w", createErr)
 	}
 
@@ -493,6 +572,7 @@ func (s *Service) OAuthLogin(ctx context.Context, userInfo *OAuthUserInfo, userA
 	}
 
 	log.Info().Str("user_id", user.ID).Str("email", user.Email).Str("provider", userInfo.Provider).Msg("User registered via OAuth")
+	s.publishUser(ctx, events.UserRegistered, user.ID)
 
 	tokens, err := s.createSession(ctx, user, userAgent, ipAddress)
 	if err != nil {
@@ -555,28 +635,25 @@ const (
 )
 
 var allowedSortFields = map[string]bool{
-	"id": true, "email": true, "verified": true, "role": true, "created_at": true, "updated_at": true,
+	"id": true, "email": true, "verified": true, "role": true, "created_at": true, "updated_at": true, "deletion_requested_at": true,
 }
 
 // ListUsers returns a paginated list of users with optional filtering.
 func (s *Service) ListUsers(ctx context.Context, opts ListUsersOptions) (*ListUsersResult, error) {
-	opts = normalizeListOptions(opts)
-
-	whereClause, args := buildUserWhereClause(opts)
-
-	total, err := s.countUsers(ctx, whereClause, args)
-	if err != nil {
-		return nil, err
-	}
-
-	users, err := s.queryUsers(ctx, whereClause, args, opts)
+	users, total, err := s.users.List(ctx, normalizeListOptions(opts))
 	if err != nil {
 		return nil, err
 	}
-
 	return &ListUsersResult{Users: users, Total: total}, nil
 }
 
+// EachUser calls fn for every user matching the filters in opts, in the
+// requested order, without a page limit. Limit and Offset are ignored.
+// Iteration stops at the first error fn returns.
+func (s *Service) EachUser(ctx context.Context, opts ListUsersOptions, fn func(*User) error) error {
+	return s.users.Each(ctx, normalizeListOptions(opts), fn)
+}
+
 func normalizeListOptions(opts ListUsersOptions) ListUsersOptions {
 	if opts.Limit <= 0 {
 		opts.Limit = defaultListLimit
@@ -593,82 +670,6 @@ func normalizeListOptions(opts ListUsersOptions) ListUsersOptions {
 	return opts
 }
 
-func buildUserWhereClause(opts ListUsersOptions) (string, []any) {
-	var conditions []string
-	var args []any
-
-	if opts.Search != "" {
-		conditions = append(conditions, "email LIKE ?")
-		args = append(args, "%"+opts.Search+"%")
-	}
-	if opts.Role != "" {
-		conditions = append(conditions, "role = ?")
-		args = append(args, opts.Role)
-	}
-
-	if len(conditions) == 0 {
-		return "", args
-	}
-	return " WHERE " + strings.Join(conditions, " AND "), args
-}
-
-func (s *Service) countUsers(ctx context.Context, whereClause string, args []any) (int, error) {
-	var total int
-	query := "SELECT COUNT(*) FROM _alyx_users" + whereClause
-	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
-		return 0, fmt.Errorf("counting users: %w", err)
-	}
-	return total, nil
-}
-
-func (s *Service) queryUsers(ctx context.Context, whereClause string, args []any, opts ListUsersOptions) ([]*User, error) {
-	query := fmt.Sprintf(
-		"SELECT id, email, verified, role, created_at, updated_at, metadata FROM _alyx_users%s ORDER BY %s %s LIMIT ? OFFSET ?",
-		whereClause, opts.SortBy, strings.ToUpper(opts.SortDir),
-	)
-	args = append(args, opts.Limit, opts.Offset)
-
-	rows, err := s.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("querying users: %w", err)
-	}
-	defer rows.Close()
-
-	users := make([]*User, 0)
-	for rows.Next() {
-		user, scanErr := s.scanUserFromRows(rows)
-		if scanErr != nil {
-			return nil, scanErr
-		}
-		users = append(users, user)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterating users: %w", err)
-	}
-
-	return users, nil
-}
-
-func (s *Service) scanUserFromRows(rows *sql.Rows) (*User, error) {
-	user := &User{}
-	var metadataJSON sql.NullString
-	var role sql.NullString
-	var createdAt, updatedAt string
-
-	if err := rows.Scan(&user.ID, &user.Email, &user.Verified, &role, &createdAt, &updatedAt, &metadataJSON); err != nil {
-		return nil, fmt.Errorf("scanning user: %w", err)
-	}
-
-	user.Role = role.String
-	if user.Role == "" {
-		user.Role = RoleUser
-	}
-	user.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-	user.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
-	return user, nil
-}
-
 // UpdateUser updates a user's information by ID.
 func (s *Service) UpdateUser(ctx context.Context, id string, input UpdateUserInput) (*User, error) {
 	user, err := s.GetUserByID(ctx, id)
@@ -676,82 +677,52 @@ func (s *Service) UpdateUser(ctx context.Context, id string, input UpdateUserInp
 		return nil, err
 	}
 
-	var updates []string
-	var args []any
+	var update store.UserUpdate
 
 	if input.Email != nil {
 		email := strings.ToLower(strings.TrimSpace(*input.Email))
-		existing, existingErr := s.getUserByEmail(ctx, email)
+		existing, existingErr := s.users.GetByEmail(ctx, email)
 		if existingErr != nil && !errors.Is(existingErr, ErrUserNotFound) {
 			return nil, fmt.Errorf("checking existing email: %w", existingErr)
 		}
 		if existing != nil && existing.ID != id {
 			return nil, ErrUserAlreadyExists
 		}
-		updates = append(updates, "email = ?")
-		args = append(args, email)
+		update.Email = &email
 	}
 
-	if input.Verified != nil {
-		updates = append(updates, "verified = ?")
-		args = append(args, *input.Verified)
-	}
+	update.Verified = input.Verified
 
 	if input.Role != nil {
 		role := strings.TrimSpace(*input.Role)
-		if role != RoleUser && role != RoleAdmin {
+		if !s.ValidRole(role) {
 			return nil, fmt.Errorf("invalid role: %s", role)
 		}
-		updates = append(updates, "role = ?")
-		args = append(args, role)
+		update.Role = &role
 	}
 
 	if input.Metadata != nil {
-		updates = append(updates, "metadata = ?")
-		args = append(args, *input.Metadata)
+		if metadataErr := s.ValidateMetadata(*input.Metadata); metadataErr != nil {
+			return nil, metadataErr
+		}
+		update.Metadata = input.Metadata
 	}
 
-	if len(updates) == 0 {
+	if update == (store.UserUpdate{}) {
 		return user, nil
 	}
 
-	// Defensive validation: ensure only allowed columns are updated
-	allowedColumns := map[string]bool{
-		"email": true, "verified": true, "role": true, "metadata": true,
-	}
-	for _, update := range updates {
-		col := strings.Split(update, " ")[0]
-		if !allowedColumns[col] {
-			return nil, fmt.Errorf("invalid column: %s", col)
-		}
-	}
-
-	updates = append(updates, "updated_at = ?")
-	args = append(args, time.Now().UTC().Format(time.RFC3339))
-	args = append(args, id)
-
-	query := fmt.Sprintf("UPDATE _alyx_users SET %s WHERE id = ?", strings.Join(updates, ", "))
-	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
-		return nil, fmt.Errorf("updating user: %w", err)
+	if err := s.users.Update(ctx, id, update); err != nil {
+		return nil, err
 	}
 
-	return s.GetUserByID(ctx, id)
+	return s.updatedUser(ctx, id)
 }
 
 // DeleteUser deletes a user by ID.
 func (s *Service) DeleteUser(ctx context.Context, id string) error {
-	result, err := s.db.ExecContext(ctx, "DELETE FROM _alyx_users WHERE id = ?", id)
-	if err != nil {
-		return fmt.Errorf("deleting user: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("checking rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return ErrUserNotFound
+	if err := s.users.Delete(ctx, id); err != nil {
+		return err
 	}
 
 	log.Info().Str("user_id", id).Msg("User deleted")
@@ -762,7 +733,7 @@ func (s *Service) DeleteUser(ctx context.Context, id string) error {
 func (s *Service) CreateUserByAdmin(ctx context.Context, input CreateUserInput) (*User, error) {
 	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
 
-	existing, existingErr := s.getUserByEmail(ctx, input.Email)
+	existing, existingErr := s.users.GetByEmail(ctx, input.Email)
 	if existingErr != nil && !errors.Is(existingErr, ErrUserNotFound) {
 		return nil, fmt.Errorf("checking existing user: %w", existingErr)
 	}
@@ -774,7 +745,7 @@ func (s *Service) CreateUserByAdmin(ctx context.Context, input CreateUserInput)
 		return nil, fmt.Errorf("password validation: %w", validationErr)
 	}
 
-	passwordHash, err := HashPassword(input.Password)
+	passwordHash, err := s.hasher.Hash(input.Password)
 	if err != nil {
 		return nil, fmt.Errorf("hashing password: %w", err)
 	}
@@ -783,9 +754,12 @@ func (s *Service) CreateUserByAdmin(ctx context.Context, input CreateUserInput)
 	if role == "" {
 		role = RoleUser
 	}
-	if role != RoleUser && role != RoleAdmin {
+	if !s.ValidRole(role) {
 		return nil, fmt.Errorf("invalid role: %s", role)
 	}
+	if metadataErr := s.ValidateMetadata(input.Metadata); metadataErr != nil {
+		return nil, metadataErr
+	}
 
 	user := &User{
 		ID:        uuid.New().String(),
@@ -797,63 +771,29 @@ func (s *Service) CreateUserByAdmin(ctx context.Context, input CreateUserInput)
 		Metadata:  input.Metadata,
 	}
 
-	if createErr := s.createUserWithRole(ctx, user, passwordHash); createErr != nil {
+	if createErr := s.users.Create(ctx, user, passwordHash); createErr != nil {
 		return nil, fmt.Errorf("creating user: %w", createErr)
 	}
 
 	log.Info().Str("user_id", user.ID).Str("email", user.Email).Str("role", user.Role).Msg("User created by admin")
+	s.publishUser(ctx, events.UserRegistered, user.ID)
 
 	return user, nil
 }
 
-func (s *Service) createUserWithRole(ctx context.Context, user *User, passwordHash string) error {
-	query := `INSERT INTO _alyx_users (id, email, password_hash, verified, role, created_at, updated_at, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-
-	var metadata any
-	if user.Metadata != nil {
-		metadata = user.Metadata
-	}
-
-	_, err := s.db.ExecContext(ctx, query,
-		user.ID,
-		user.Email,
-		passwordHash,
-		user.Verified,
-		user.Role,
-		user.CreatedAt.Format(time.RFC3339),
-		user.UpdatedAt.Format(time.RFC3339),
-		metadata,
-	)
-
-	return err
-}
-
 // SetPassword sets a new password for a user (admin operation).
 func (s *Service) SetPassword(ctx context.Context, userID, newPassword string) error {
 	if validationErr := ValidatePassword(newPassword, s.cfg.Password); validationErr != nil {
 		return fmt.Errorf("password validation: %w", validationErr)
 	}
 
-	passwordHash, err := HashPassword(newPassword)
+	passwordHash, err := s.hasher.Hash(newPassword)
 	if err != nil {
 		return fmt.Errorf("hashing password: %w", err)
 	}
 
-	result, err := s.db.ExecContext(ctx,
-		"UPDATE _alyx_users SET password_hash = ?, updated_at = ? WHERE id = ?",
-		passwordHash, time.Now().UTC().Format(time.RFC3339), userID,
-	)
-	if err != nil {
-		return fmt.Errorf("updating password: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("checking rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return ErrUserNotFound
+	if err := s.users.SetPasswordHash(ctx, userID, passwordHash); err != nil {
+		return err
 	}
 
 	log.Info().Str("user_id", userID).Msg("Password reset by admin")
@@ -2,11 +2,14 @@ package auth
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/events"
 )
 
 func testDB(t *testing.T) *database.DB {
@@ -104,6 +107,46 @@ func TestService_CreateUserByAdmin_InvalidRole(t *testing.T) {
 	}
 }
 
+func TestService_CustomRoles(t *testing.T) {
+	db := testDB(t)
+	svc := NewService(db, testAuthConfig())
+
+	ctx := context.Background()
+
+	_, err := svc.CreateUserByAdmin(ctx, CreateUserInput{
+		Email:    "editor@example.com",
+		Password: "password123",
+		Role:     "editor",
+	})
+	if err == nil {
+		t.Fatal("Expected error for undeclared role")
+	}
+
+	svc.SetRoles([]string{"editor"})
+
+	user, err := svc.CreateUserByAdmin(ctx, CreateUserInput{
+		Email:    "editor@example.com",
+		Password: "password123",
+		Role:     "editor",
+	})
+	if err != nil {
+		t.Fatalf("CreateUserByAdmin failed: %v", err)
+	}
+	if user.Role != "editor" {
+		t.Errorf("Expected role editor, got %s", user.Role)
+	}
+
+	admin := "admin"
+	if _, err := svc.UpdateUser(ctx, user.ID, UpdateUserInput{Role: &admin}); err != nil {
+		t.Errorf("Built-in roles should remain valid: %v", err)
+	}
+
+	moderator := "moderator"
+	if _, err := svc.UpdateUser(ctx, user.ID, UpdateUserInput{Role: &moderator}); err == nil {
+		t.Error("Expected error for undeclared role")
+	}
+}
+
 func TestService_CreateUserByAdmin_DuplicateEmail(t *testing.T) {
 	db := testDB(t)
 	svc := NewService(db, testAuthConfig())
@@ -392,3 +435,152 @@ func TestService_GetUserByID_WithRole(t *testing.T) {
 		t.Errorf("Role mismatch: got %s, want %s", user.Role, RoleAdmin)
 	}
 }
+
+func TestService_Register_FirstUserAdmin(t *testing.T) {
+	db := testDB(t)
+	cfg := testAuthConfig()
+	cfg.FirstUserAdmin = true
+	svc := NewService(db, cfg)
+	defer svc.Stop()
+
+	ctx := context.Background()
+
+	first, _, err := svc.Register(ctx, RegisterInput{Email: "first@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	second, _, err := svc.Register(ctx, RegisterInput{Email: "second@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if first.Role != RoleAdmin {
+		t.Errorf("first user role = %s, want %s", first.Role, RoleAdmin)
+	}
+	if second.Role != RoleUser {
+		t.Errorf("second user role = %s, want %s", second.Role, RoleUser)
+	}
+
+	stored, err := svc.GetUserByID(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if stored.Role != RoleAdmin {
+		t.Errorf("stored first user role = %s, want %s", stored.Role, RoleAdmin)
+	}
+}
+
+func TestService_Register_FirstUserAdminDisabled(t *testing.T) {
+	db := testDB(t)
+	cfg := testAuthConfig()
+	cfg.FirstUserAdmin = false
+	svc := NewService(db, cfg)
+	defer svc.Stop()
+
+	user, _, err := svc.Register(context.Background(), RegisterInput{Email: "first@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if user.Role != RoleUser {
+		t.Errorf("first user role = %s, want %s", user.Role, RoleUser)
+	}
+}
+
+func TestService_Register_ConcurrentFirstUsers(t *testing.T) {
+	db := testDB(t)
+	cfg := testAuthConfig()
+	cfg.FirstUserAdmin = true
+	svc := NewService(db, cfg)
+	defer svc.Stop()
+
+	ctx := context.Background()
+	const signups = 8
+
+	var wg sync.WaitGroup
+	for i := range signups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _ = svc.Register(ctx, RegisterInput{Email: fmt.Sprintf("user%d@example.com", i), Password: "password123"})
+		}()
+	}
+	wg.Wait()
+
+	admins, err := svc.ListUsers(ctx, ListUsersOptions{Role: RoleAdmin})
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if admins.Total != 1 {
+		t.Errorf("expected exactly one admin after concurrent signups, got %d", admins.Total)
+	}
+}
+
+type publishedEvent struct {
+	channel string
+	event   events.Type
+	data    any
+}
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []publishedEvent
+}
+
+func (r *eventRecorder) Publish(channel string, event events.Type, data any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, publishedEvent{channel, event, data})
+}
+
+func (r *eventRecorder) take() []publishedEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	taken := r.events
+	r.events = nil
+	return taken
+}
+
+func TestService_Events(t *testing.T) {
+	db := testDB(t)
+	svc := NewService(db, testAuthConfig())
+	defer svc.Stop()
+	recorder := &eventRecorder{}
+	svc.SetEventPublisher(recorder)
+
+	ctx := context.Background()
+	user, tokens, err := svc.Register(ctx, RegisterInput{Email: "user@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	got := recorder.take()
+	if len(got) != 1 || got[0].channel != events.ChannelAuth || got[0].event != events.UserRegistered || got[0].data.(*User).ID != user.ID {
+		t.Fatalf("expected user.registered on _auth, got %+v", got)
+	}
+
+	role := RoleAdmin
+	if _, err := svc.UpdateUser(ctx, user.ID, UpdateUserInput{Role: &role}); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	got = recorder.take()
+	if len(got) != 1 || got[0].event != events.UserUpdated || got[0].data.(*User).Role != RoleAdmin {
+		t.Fatalf("expected user.updated with the new role, got %+v", got)
+	}
+
+	if err := svc.Logout(ctx, tokens.RefreshToken); err != nil {
+		t.Fatalf("Logout failed: %v", err)
+	}
+	got = recorder.take()
+	if len(got) != 1 || got[0].event != events.SessionRevoked {
+		t.Fatalf("expected session.revoked, got %+v", got)
+	}
+	if revoked := got[0].data.(*RevokedSession); revoked.UserID != user.ID || revoked.SessionID == "" || revoked.Reason != RevokeReasonLogout {
+		t.Errorf("unexpected revoked session %+v", revoked)
+	}
+
+	if _, err := svc.BulkUsers(ctx, BulkUsersInput{Action: BulkActionVerify, IDs: []string{user.ID, "missing"}}); err != nil {
+		t.Fatalf("BulkUsers failed: %v", err)
+	}
+	if got = recorder.take(); len(got) != 1 || got[0].event != events.UserUpdated {
+		t.Errorf("expected one user.updated for the user that exists, got %+v", got)
+	}
+}
@@ -4,39 +4,22 @@ package auth
 import (
 	"context"
 	"time"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/store"
 )
 
 // User represents an authenticated user.
-type User struct {
-	ID        string         `json:"id"`
-	Email     string         `json:"email"`
-	Verified  bool           `json:"verified"`
-	Role      string         `json:"role"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	Metadata  map[string]any `json:"metadata,omitempty"`
-}
+type User = store.User
 
 // UserRole constants for the built-in role system.
 const (
-	RoleUser  = "user"
-	RoleAdmin = "admin"
+	RoleUser  = store.RoleUser
+	RoleAdmin = store.RoleAdmin
 )
 
-// IsAdmin returns true if the user has admin role.
-func (u *User) IsAdmin() bool {
-	return u.Role == RoleAdmin
-}
-
 // ListUsersOptions contains options for listing users.
-type ListUsersOptions struct {
-	Limit   int
-	Offset  int
-	SortBy  string
-	SortDir string // "asc" or "desc"
-	Search  string // Search in email
-	Role    string // Filter by role
-}
+type ListUsersOptions = store.ListUsersOptions
 
 // ListUsersResult contains the result of listing users.
 type ListUsersResult struct {
@@ -72,6 +55,21 @@ type Session struct {
 	IPAddress        string    `json:"ip_address,omitempty"`
 }
 
+// RevokedSession is the payload of a session.revoked event. SessionID is
+// empty when every session of the user was revoked.
+type RevokedSession struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// Reasons a session is revoked.
+const (
+	RevokeReasonLogout            = "logout"
+	RevokeReasonPasswordReset     = "password_reset"
+	RevokeReasonDeletionRequested = "deletion_requested"
+)
+
 // OAuthAccount represents a linked OAuth provider account.
 type OAuthAccount struct {
 	ID             string    `json:"id"`
@@ -95,6 +93,7 @@ type Claims struct {
 	Email     string    `json:"email"`
 	Verified  bool      `json:"verified"`
 	Role      string    `json:"role,omitempty"`
+	IssuedAt  time.Time `json:"iat"`
 	ExpiresAt time.Time `json:"exp"`
 }
 
@@ -142,8 +141,12 @@ func ClaimsFromContext(ctx context.Context) *Claims {
 	return nil
 }
 
-// ContextWithUser returns a new context with the user attached.
+// ContextWithUser returns a new context with the user attached. The user is
+// also recorded as the actor of changes made through the context.
 func ContextWithUser(ctx context.Context, user *User) context.Context {
+	if user != nil {
+		ctx = database.WithActor(ctx, user.ID)
+	}
 	return context.WithValue(ctx, userContextKey, user)
 }
 
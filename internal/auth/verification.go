@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/events"
+)
+
+// ErrInvalidEmailToken is returned for unknown, used or expired verification
+// and password reset tokens.
+var ErrInvalidEmailToken = errors.New("invalid or expired link")
+
+// Token types stored in _alyx_auth_tokens.
+const (
+	tokenTypeVerification   = "verification"
+	tokenTypePasswordReset  = "password_reset"
+	tokenTypeAccountRestore = "account_restore"
+)
+
+// mailTimeout bounds background email delivery.
+const mailTimeout = time.Minute
+
+// Mailer sends auth emails.
+type Mailer interface {
+	SendVerification(ctx context.Context, to, token string, ttl time.Duration) error
+	SendPasswordReset(ctx context.Context, to, token string, ttl time.Duration) error
+	SendWelcome(ctx context.Context, to string) error
+	SendAccountDeletion(ctx context.Context, to, token string, ttl time.Duration) error
+}
+
+// SetMailer sets the mailer used for verification, password reset and
+// welcome emails. Without one, no emails are sent.
+func (s *Service) SetMailer(m Mailer) {
+	s.mailer = m
+}
+
+// RequestVerification sends a new verification link to an unverified user.
+// Unknown and already verified addresses are ignored, so callers cannot
+// probe which accounts exist.
+func (s *Service) RequestVerification(ctx context.Context, email string) error {
+	user, err := s.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Verified {
+		return nil
+	}
+	return s.sendVerification(ctx, user)
+}
+
+// VerifyEmail marks the user a verification token was issued to as verified.
+func (s *Service) VerifyEmail(ctx context.Context, token string) (*User, error) {
+	var userID string
+	err := s.db.Transaction(ctx, func(tx *database.Tx) error {
+		var err error
+		userID, err = consumeToken(ctx, tx, tokenTypeVerification, token)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE _alyx_users SET verified = 1, updated_at = ? WHERE id = ?",
+			time.Now().UTC().Format(time.RFC3339), userID,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", user.ID).Msg("Email verified")
+	s.events.Publish(events.ChannelAuth, events.UserUpdated, user)
+
+	if s.hookTrigger != nil {
+		if hookErr := s.hookTrigger.OnEmailVerify(ctx, user, nil); hookErr != nil {
+			log.Error().Err(hookErr).Str("user_id", user.ID).Msg("Email verify hook failed")
+		}
+	}
+
+	s.sendMail(ctx, user, "welcome", func(ctx context.Context, m Mailer) error {
+		return m.SendWelcome(ctx, user.Email)
+	})
+
+	return user, nil
+}
+
+// RequestPasswordReset sends a password reset link. Unknown addresses are
+// ignored, so callers cannot probe which accounts exist.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.mailer == nil {
+		return nil
+	}
+
+	ttl := ttlOrDefault(s.cfg.PasswordResetTTL, config.DefaultPasswordResetTTL)
+	token, err := s.issueToken(ctx, user.ID, tokenTypePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(ctx, user, "password reset", func(ctx context.Context, m Mailer) error {
+		return m.SendPasswordReset(ctx, user.Email, token, ttl)
+	})
+	return nil
+}
+
+// ResetPassword sets a new password using a password reset token and signs
+// the user out everywhere. The token stays valid if the password is rejected
+// by the password policy.
+func (s *Service) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if validationErr := ValidatePassword(newPassword, s.cfg.Password); validationErr != nil {
+		return fmt.Errorf("password validation: %w", validationErr)
+	}
+
+	passwordHash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("hashing password: %w", err)
+	}
+
+	var userID string
+	err = s.db.Transaction(ctx, func(tx *database.Tx) error {
+		var err error
+		userID, err = consumeToken(ctx, tx, tokenTypePasswordReset, token)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE _alyx_users SET password_hash = ?, updated_at = ? WHERE id = ?",
+			passwordHash, time.Now().UTC().Format(time.RFC3339), userID,
+		); err != nil {
+			return fmt.Errorf("updating password: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM _alyx_sessions WHERE user_id = ?", userID); err != nil {
+			return fmt.Errorf("revoking sessions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("user_id", userID).Msg("Password reset by user")
+	s.publishSessionRevoked(userID, "", RevokeReasonPasswordReset)
+
+	if s.hookTrigger != nil {
+		if user, getUserErr := s.GetUserByID(ctx, userID); getUserErr == nil {
+			if hookErr := s.hookTrigger.OnPasswordReset(ctx, user, nil); hookErr != nil {
+				log.Error().Err(hookErr).Str("user_id", userID).Msg("Password reset hook failed")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) sendVerification(ctx context.Context, user *User) error {
+	if s.mailer == nil {
+		return nil
+	}
+
+	ttl := ttlOrDefault(s.cfg.VerificationTTL, config.DefaultVerificationTTL)
+	token, err := s.issueToken(ctx, user.ID, tokenTypeVerification, ttl)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(ctx, user, "verification", func(ctx context.Context, m Mailer) error {
+		return m.SendVerification(ctx, user.Email, token, ttl)
+	})
+	return nil
+}
+
+// sendMail delivers an email in the background so slow SMTP servers do not
+// hold up the request, and so response times do not reveal whether an
+// address has an account. Failures are logged.
+func (s *Service) sendMail(ctx context.Context, user *User, kind string, send func(context.Context, Mailer) error) {
+	if s.mailer == nil {
+		return
+	}
+
+	s.mailWG.Add(1)
+	go func() {
+		defer s.mailWG.Done()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), mailTimeout)
+		defer cancel()
+
+		if err := send(ctx, s.mailer); err != nil {
+			log.Error().Err(err).Str("user_id", user.ID).Str("email", kind).Msg("Failed to send email")
+		}
+	}()
+}
+
+// issueToken creates a single-use token for userID, replacing any earlier
+// token of the same type. Only its hash is stored.
+func (s *Service) issueToken(ctx context.Context, userID, tokenType string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now().UTC()
+	err := s.db.Transaction(ctx, func(tx *database.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM _alyx_auth_tokens WHERE user_id = ? AND type = ?",
+			userID, tokenType,
+		); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO _alyx_auth_tokens (id, user_id, type, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			uuid.New().String(), userID, tokenType, HashToken(token),
+			now.Add(ttl).Format(time.RFC3339), now.Format(time.RFC3339),
+		)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("storing %s token: %w", tokenType, err)
+	}
+	return token, nil
+}
+
+// consumeToken deletes a token and returns the user it was issued to.
+func consumeToken(ctx context.Context, tx *database.Tx, tokenType, token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidEmailToken
+	}
+
+	var userID, expiresAt string
+	err := tx.QueryRowContext(ctx,
+		"DELETE FROM _alyx_auth_tokens WHERE token_hash = ? AND type = ? RETURNING user_id, expires_at",
+		HashToken(token), tokenType,
+	).Scan(&userID, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidEmailToken
+	}
+	if err != nil {
+		return "", fmt.Errorf("consuming token: %w", err)
+	}
+
+	expires, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil || time.Now().After(expires) {
+		return "", ErrInvalidEmailToken
+	}
+	return userID, nil
+}
+
+func ttlOrDefault(ttl, def time.Duration) time.Duration {
+	if ttl > 0 {
+		return ttl
+	}
+	return def
+}
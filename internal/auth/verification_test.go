@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type sentMail struct {
+	kind, to, token string
+}
+
+type fakeMailer struct {
+	sent chan sentMail
+}
+
+func newFakeMailer() *fakeMailer {
+	return &fakeMailer{sent: make(chan sentMail, 10)}
+}
+
+func (m *fakeMailer) SendVerification(_ context.Context, to, token string, _ time.Duration) error {
+	m.sent <- sentMail{"verification", to, token}
+	return nil
+}
+
+func (m *fakeMailer) SendPasswordReset(_ context.Context, to, token string, _ time.Duration) error {
+	m.sent <- sentMail{"password_reset", to, token}
+	return nil
+}
+
+func (m *fakeMailer) SendWelcome(_ context.Context, to string) error {
+	m.sent <- sentMail{"welcome", to, ""}
+	return nil
+}
+
+func (m *fakeMailer) SendAccountDeletion(_ context.Context, to, token string, _ time.Duration) error {
+	m.sent <- sentMail{"account_deletion", to, token}
+	return nil
+}
+
+func (m *fakeMailer) next(t *testing.T, kind string) sentMail {
+	t.Helper()
+	select {
+	case mail := <-m.sent:
+		if mail.kind != kind {
+			t.Fatalf("expected %s email, got %s", kind, mail.kind)
+		}
+		return mail
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s email", kind)
+		return sentMail{}
+	}
+}
+
+func TestService_EmailVerification(t *testing.T) {
+	cfg := testAuthConfig()
+	cfg.RequireVerification = true
+	svc := NewService(testDB(t), cfg)
+	mailer := newFakeMailer()
+	svc.SetMailer(mailer)
+	ctx := context.Background()
+
+	user, _, err := svc.Register(ctx, RegisterInput{Email: "new@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	first := mailer.next(t, "verification")
+
+	if err := svc.RequestVerification(ctx, "NEW@example.com"); err != nil {
+		t.Fatalf("request verification: %v", err)
+	}
+	second := mailer.next(t, "verification")
+
+	if _, err := svc.VerifyEmail(ctx, first.token); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("expected a reissued link to invalidate the old one, got %v", err)
+	}
+
+	verified, err := svc.VerifyEmail(ctx, second.token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if verified.ID != user.ID || !verified.Verified {
+		t.Errorf("expected user %s to be verified, got %+v", user.ID, verified)
+	}
+	mailer.next(t, "welcome")
+
+	if _, err := svc.VerifyEmail(ctx, second.token); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("expected token to be single-use, got %v", err)
+	}
+
+	if err := svc.RequestVerification(ctx, "missing@example.com"); err != nil {
+		t.Errorf("expected unknown address to be ignored, got %v", err)
+	}
+	if err := svc.RequestVerification(ctx, "new@example.com"); err != nil {
+		t.Errorf("expected verified address to be ignored, got %v", err)
+	}
+	svc.Stop()
+	if len(mailer.sent) != 0 {
+		t.Errorf("expected no further emails, got %d", len(mailer.sent))
+	}
+}
+
+func TestService_PasswordReset(t *testing.T) {
+	svc := NewService(testDB(t), testAuthConfig())
+	mailer := newFakeMailer()
+	svc.SetMailer(mailer)
+	ctx := context.Background()
+
+	if _, _, err := svc.Register(ctx, RegisterInput{Email: "user@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	mailer.next(t, "welcome")
+
+	_, tokens, err := svc.Login(ctx, LoginInput{Email: "user@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	if err := svc.RequestPasswordReset(ctx, "user@example.com"); err != nil {
+		t.Fatalf("request reset: %v", err)
+	}
+	reset := mailer.next(t, "password_reset")
+
+	if err := svc.ResetPassword(ctx, reset.token, "short"); err == nil {
+		t.Fatal("expected password policy to be enforced")
+	}
+	if err := svc.ResetPassword(ctx, "bogus", "newpassword123"); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("expected invalid token error, got %v", err)
+	}
+	if err := svc.ResetPassword(ctx, reset.token, "newpassword123"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	if _, _, err := svc.Refresh(ctx, tokens.RefreshToken); err == nil {
+		t.Error("expected existing sessions to be revoked")
+	}
+	if _, _, err := svc.Login(ctx, LoginInput{Email: "user@example.com", Password: "newpassword123"}, "", ""); err != nil {
+		t.Errorf("expected login with new password, got %v", err)
+	}
+
+	if err := svc.RequestPasswordReset(ctx, "missing@example.com"); err != nil {
+		t.Errorf("expected unknown address to be ignored, got %v", err)
+	}
+	svc.Stop()
+}
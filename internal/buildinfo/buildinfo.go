@@ -0,0 +1,80 @@
+// Package buildinfo describes the running binary. Version, Commit and Date
+// are set at build time with -ldflags, for example:
+//
+//	go build -ldflags "-X github.com/watzon/alyx/internal/buildinfo.Version=1.2.0" ./cmd/alyx
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+var (
+	// Version is the release version of the binary.
+	Version = "0.1.0-dev"
+	// Commit is the VCS revision the binary was built from. Unset, the
+	// revision Go records in the binary is used.
+	Commit = ""
+	// Date is when the binary was built. Unset, the commit time Go records
+	// in the binary is used.
+	Date = ""
+)
+
+// Info is the build information reported by alyx --version and
+// GET /api/version.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	// Features are the optional subsystems enabled in the configuration.
+	Features []string `json:"features"`
+}
+
+// Get returns the build information with the given enabled features.
+func Get(features []string) Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: Date,
+		GoVersion: runtime.Version(),
+		Features:  features,
+	}
+	if info.Features == nil {
+		info.Features = []string{}
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+	return info
+}
+
+// String returns the human-readable form printed by alyx --version.
+func (i Info) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "alyx version %s\n", i.Version)
+	if i.Commit != "" {
+		fmt.Fprintf(&b, "  commit:   %s\n", i.Commit)
+	}
+	if i.BuildDate != "" {
+		fmt.Fprintf(&b, "  built:    %s\n", i.BuildDate)
+	}
+	fmt.Fprintf(&b, "  go:       %s\n", i.GoVersion)
+	if len(i.Features) > 0 {
+		fmt.Fprintf(&b, "  features: %s\n", strings.Join(i.Features, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,327 @@
+// Package changefeed serves the ordered log of committed document changes
+// recorded in _alyx_changes to downstream pipelines.
+package changefeed
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const (
+	// DefaultLimit is the batch size when a read does not ask for one.
+	DefaultLimit = 100
+	// MaxLimit is the largest batch a single read returns.
+	MaxLimit = 1000
+
+	pruneInterval = time.Hour
+)
+
+// ErrCursorExpired is returned when changes after a cursor have already
+// been pruned, or the cursor is newer than any recorded change.
+var ErrCursorExpired = errors.New("change cursor expired")
+
+// Change is one committed insert, update or delete.
+type Change struct {
+	// Seq orders changes in commit order and is the cursor for the next read.
+	Seq        int64  `json:"seq"`
+	Collection string `json:"collection"`
+	Operation  string `json:"operation"`
+	DocID      string `json:"doc_id"`
+	// ChangedFields lists the fields an update modified.
+	ChangedFields []string  `json:"changed_fields,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+	// Document is the document's current state, when the feed includes
+	// documents and it still exists.
+	Document database.Row `json:"document,omitempty"`
+}
+
+// Batch is the result of a read.
+type Batch struct {
+	Changes []*Change `json:"changes"`
+	// NextSeq is the since_seq to pass to the next read.
+	NextSeq int64 `json:"next_seq"`
+	// HasMore reports whether more changes were already available.
+	HasMore bool `json:"has_more"`
+	// SchemaVersion identifies the schema the server was running when the
+	// batch was read. It changes whenever the schema does.
+	SchemaVersion string `json:"schema_version"`
+}
+
+// Feed reads and prunes the change log.
+type Feed struct {
+	db  *database.DB
+	cfg *config.ChangesConfig
+	// realtime is set when the realtime broker also consumes the log, so
+	// changes it has not processed yet are kept.
+	realtime bool
+
+	mu            sync.RWMutex
+	schema        *schema.Schema
+	schemaVersion string
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a change feed.
+func New(db *database.DB, s *schema.Schema, cfg *config.ChangesConfig, realtime bool) *Feed {
+	f := &Feed{
+		db:       db,
+		cfg:      cfg,
+		realtime: realtime,
+		done:     make(chan struct{}),
+	}
+	f.UpdateSchema(s)
+	return f
+}
+
+// UpdateSchema replaces the schema used to look up documents and recomputes
+// the schema version.
+func (f *Feed) UpdateSchema(s *schema.Schema) {
+	version := ""
+	if s != nil {
+		if data, err := schema.Marshal(s); err == nil {
+			sum := sha256.Sum256(data)
+			version = hex.EncodeToString(sum[:])
+		} else {
+			log.Warn().Err(err).Msg("Failed to compute change feed schema version")
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schema = s
+	f.schemaVersion = version
+}
+
+// SchemaVersion returns the version of the current schema.
+func (f *Feed) SchemaVersion() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.schemaVersion
+}
+
+// Read returns up to limit changes after since, oldest first. A since of 0
+// starts at the oldest retained change.
+func (f *Feed) Read(ctx context.Context, since int64, limit int) (*Batch, error) {
+	f.mu.RLock()
+	s := f.schema
+	f.mu.RUnlock()
+
+	batch, err := f.read(ctx, "", since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.cfg.Include == config.ChangesIncludeDocument && s != nil {
+		if err := f.loadDocuments(ctx, s, batch.Changes); err != nil {
+			return nil, err
+		}
+	}
+
+	return batch, nil
+}
+
+// ReadCollection returns up to limit changes to collection after since,
+// oldest first, without documents. Once it has caught up, NextSeq is the
+// newest change of any collection, so that a cursor for a collection that
+// rarely changes does not fall behind the retained history.
+func (f *Feed) ReadCollection(ctx context.Context, collection string, since int64, limit int) (*Batch, error) {
+	return f.read(ctx, collection, since, limit)
+}
+
+// Head returns the sequence number of the newest recorded change, or 0 if
+// there is none.
+func (f *Feed) Head(ctx context.Context) (int64, error) {
+	var head int64
+	if err := f.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM _alyx_changes").Scan(&head); err != nil {
+		return 0, fmt.Errorf("reading change history: %w", err)
+	}
+	return head, nil
+}
+
+// read returns the changes after since, restricted to collection unless it
+// is empty.
+func (f *Feed) read(ctx context.Context, collection string, since int64, limit int) (*Batch, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	f.mu.RLock()
+	version := f.schemaVersion
+	f.mu.RUnlock()
+
+	var oldest, head int64
+	if err := f.db.QueryRowContext(ctx,
+		"SELECT COALESCE(MIN(id), 0), COALESCE(MAX(id), 0) FROM _alyx_changes",
+	).Scan(&oldest, &head); err != nil {
+		return nil, fmt.Errorf("reading change history: %w", err)
+	}
+	if since < 0 || since > head || (since > 0 && since < oldest-1) {
+		return nil, ErrCursorExpired
+	}
+
+	// Changes committed after head was read are left for the next read, so
+	// that NextSeq never skips them.
+	rows, err := f.db.QueryContext(ctx, `
+		SELECT id, collection, operation, doc_id, changed_fields, timestamp
+		FROM _alyx_changes
+		WHERE id > ? AND id <= ? AND (? = '' OR collection = ?)
+		ORDER BY id ASC
+		LIMIT ?
+	`, since, head, collection, collection, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("reading changes: %w", err)
+	}
+	defer rows.Close()
+
+	batch := &Batch{Changes: []*Change{}, NextSeq: since, SchemaVersion: version}
+	for rows.Next() {
+		change, err := scanChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch.Changes) == limit {
+			batch.HasMore = true
+			break
+		}
+		batch.Changes = append(batch.Changes, change)
+		batch.NextSeq = change.Seq
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading changes: %w", err)
+	}
+	if !batch.HasMore && collection != "" {
+		batch.NextSeq = head
+	}
+
+	return batch, nil
+}
+
+func scanChange(rows *sql.Rows) (*Change, error) {
+	var (
+		change        Change
+		changedFields sql.NullString
+		timestamp     string
+	)
+	if err := rows.Scan(&change.Seq, &change.Collection, &change.Operation, &change.DocID, &changedFields, &timestamp); err != nil {
+		return nil, fmt.Errorf("scanning change: %w", err)
+	}
+
+	if changedFields.Valid {
+		var fields []string
+		if err := json.Unmarshal([]byte(changedFields.String), &fields); err == nil {
+			// The update trigger records NULL for unchanged fields.
+			for _, field := range fields {
+				if field != "" {
+					change.ChangedFields = append(change.ChangedFields, field)
+				}
+			}
+		}
+	}
+
+	if ts, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		change.Timestamp = ts
+	} else if ts, err := time.Parse("2006-01-02 15:04:05", timestamp); err == nil {
+		change.Timestamp = ts
+	}
+
+	return &change, nil
+}
+
+// loadDocuments attaches the current state of every inserted or updated
+// document that still exists.
+func (f *Feed) loadDocuments(ctx context.Context, s *schema.Schema, changes []*Change) error {
+	for _, change := range changes {
+		if change.Operation == "DELETE" {
+			continue
+		}
+		col, ok := s.Collections[change.Collection]
+		if !ok {
+			continue
+		}
+		doc, err := database.NewCollection(f.db, col).FindOne(ctx, change.DocID)
+		if errors.Is(err, database.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("loading %s/%s: %w", change.Collection, change.DocID, err)
+		}
+		change.Document = doc
+	}
+	return nil
+}
+
+// Prune deletes changes older than the retention period and returns how many
+// were deleted. The newest change is always kept so sequence numbers keep
+// increasing.
+func (f *Feed) Prune(ctx context.Context) (int64, error) {
+	// Match the format of datetime('now') used by the change triggers.
+	cutoff := time.Now().UTC().Add(-f.cfg.Retention).Format("2006-01-02 15:04:05")
+	query := `DELETE FROM _alyx_changes
+		WHERE timestamp < ?
+		AND id < (SELECT MAX(id) FROM _alyx_changes)`
+	if f.realtime {
+		query += " AND processed = 1"
+	}
+
+	result, err := f.db.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("pruning changes: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Start begins pruning expired changes in the background.
+func (f *Feed) Start(ctx context.Context) {
+	f.wg.Add(1)
+	go f.loop(ctx)
+
+	log.Info().
+		Dur("retention", f.cfg.Retention).
+		Str("include", f.cfg.Include).
+		Msg("Change feed started")
+}
+
+// Stop halts background pruning and waits for it to finish.
+func (f *Feed) Stop() {
+	close(f.done)
+	f.wg.Wait()
+}
+
+func (f *Feed) loop(ctx context.Context) {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := f.Prune(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("Change feed pruning failed")
+			} else if pruned > 0 {
+				log.Info().Int64("deleted", pruned).Msg("Pruned expired changes")
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package changefeed
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const testSchema = `
+version: 1
+collections:
+  notes:
+    fields:
+      id:
+        type: string
+        primary: true
+      title:
+        type: string
+      body:
+        type: string
+        nullable: true
+`
+
+func setup(t *testing.T, include string) (*Feed, *database.DB) {
+	t.Helper()
+	db, err := database.Open(&config.DatabaseConfig{Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Failed to parse test schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to execute SQL: %v\nSQL: %s", err, stmt)
+		}
+	}
+
+	cfg := &config.ChangesConfig{Enabled: true, Retention: time.Hour, Include: include}
+	return New(db, s, cfg, false), db
+}
+
+func exec(t *testing.T, db *database.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("Failed to execute %q: %v", query, err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	feed, db := setup(t, config.ChangesIncludeKeys)
+	ctx := context.Background()
+
+	exec(t, db, "INSERT INTO notes (id, title) VALUES ('a', 'First')")
+	exec(t, db, "INSERT INTO notes (id, title) VALUES ('b', 'Second')")
+	exec(t, db, "UPDATE notes SET title = 'First!' WHERE id = 'a'")
+	exec(t, db, "DELETE FROM notes WHERE id = 'b'")
+
+	batch, err := feed.Read(ctx, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Changes) != 3 || !batch.HasMore || batch.NextSeq != batch.Changes[2].Seq {
+		t.Fatalf("unexpected first batch: %+v", batch)
+	}
+	if batch.SchemaVersion == "" || batch.SchemaVersion != feed.SchemaVersion() {
+		t.Errorf("expected the batch to carry the schema version, got %q", batch.SchemaVersion)
+	}
+	update := batch.Changes[2]
+	if update.Operation != "UPDATE" || update.DocID != "a" || len(update.ChangedFields) != 1 || update.ChangedFields[0] != "title" {
+		t.Errorf("unexpected update: %+v", update)
+	}
+	if update.Document != nil {
+		t.Error("expected no document when the feed includes keys only")
+	}
+
+	batch, err = feed.Read(ctx, batch.NextSeq, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Changes) != 1 || batch.HasMore || batch.Changes[0].Operation != "DELETE" {
+		t.Fatalf("unexpected second batch: %+v", batch)
+	}
+
+	next := batch.NextSeq
+	batch, err = feed.Read(ctx, next, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Changes) != 0 || batch.NextSeq != next {
+		t.Errorf("expected an empty batch at the head, got %+v", batch)
+	}
+
+	if _, err := feed.Read(ctx, next+1, 3); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("expected ErrCursorExpired past the head, got %v", err)
+	}
+}
+
+func TestReadCollection(t *testing.T) {
+	feed, db := setup(t, config.ChangesIncludeDocument)
+	ctx := context.Background()
+
+	exec(t, db, "INSERT INTO notes (id, title) VALUES ('a', 'First')")
+	exec(t, db, "INSERT INTO _alyx_changes (collection, operation, doc_id) VALUES ('other', 'INSERT', 'x')")
+	exec(t, db, "INSERT INTO notes (id, title) VALUES ('b', 'Second')")
+	exec(t, db, "INSERT INTO _alyx_changes (collection, operation, doc_id) VALUES ('other', 'INSERT', 'y')")
+
+	batch, err := feed.ReadCollection(ctx, "notes", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Changes) != 1 || !batch.HasMore || batch.NextSeq != batch.Changes[0].Seq {
+		t.Fatalf("unexpected first batch: %+v", batch)
+	}
+	if batch.Changes[0].Document != nil {
+		t.Error("expected no document from a collection read")
+	}
+
+	batch, err = feed.ReadCollection(ctx, "notes", batch.NextSeq, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Changes) != 1 || batch.HasMore || batch.Changes[0].DocID != "b" {
+		t.Fatalf("unexpected second batch: %+v", batch)
+	}
+	if batch.NextSeq != 4 {
+		t.Errorf("expected a caught up read to advance to the head, got %d", batch.NextSeq)
+	}
+}
+
+func TestReadDocuments(t *testing.T) {
+	feed, db := setup(t, config.ChangesIncludeDocument)
+
+	exec(t, db, "INSERT INTO notes (id, title) VALUES ('a', 'First')")
+	exec(t, db, "UPDATE notes SET title = 'First!' WHERE id = 'a'")
+	exec(t, db, "INSERT INTO notes (id, title) VALUES ('b', 'Second')")
+	exec(t, db, "DELETE FROM notes WHERE id = 'b'")
+
+	batch, err := feed.Read(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Changes) != 4 {
+		t.Fatalf("expected 4 changes, got %d", len(batch.Changes))
+	}
+	for _, change := range batch.Changes[:2] {
+		if change.Document == nil || change.Document["title"] != "First!" {
+			t.Errorf("expected the current document on %s, got %v", change.Operation, change.Document)
+		}
+	}
+	for _, change := range batch.Changes[2:] {
+		if change.Document != nil {
+			t.Errorf("expected no document for deleted b on %s, got %v", change.Operation, change.Document)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	feed, db := setup(t, config.ChangesIncludeKeys)
+	ctx := context.Background()
+
+	exec(t, db, "INSERT INTO notes (id, title) VALUES ('a', 'First')")
+	exec(t, db, "INSERT INTO notes (id, title) VALUES ('b', 'Second')")
+	exec(t, db, "INSERT INTO notes (id, title) VALUES ('c', 'Third')")
+	exec(t, db, "UPDATE _alyx_changes SET timestamp = datetime('now', '-2 hours')")
+
+	pruned, err := feed.Prune(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 2 {
+		t.Errorf("expected 2 pruned changes, the newest kept, got %d", pruned)
+	}
+
+	if _, err := feed.Read(ctx, 1, 10); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("expected ErrCursorExpired for a pruned cursor, got %v", err)
+	}
+
+	batch, err := feed.Read(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Changes) != 1 || batch.Changes[0].DocID != "c" {
+		t.Errorf("expected only the newest change to remain, got %+v", batch.Changes)
+	}
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -14,7 +15,7 @@ import (
 
 // Table formatting constants.
 const (
-	tokensTableWidth    = 90
+	tokensTableWidth    = 110
 	permissionsMaxLen   = 18
 	permissionsTruncLen = 15
 )
@@ -22,6 +23,7 @@ const (
 var (
 	adminTokenExpiry string
 	adminTokenPerms  []string
+	adminTokenSigned bool
 )
 
 var adminCmd = &cobra.Command{
@@ -32,6 +34,7 @@ var adminCmd = &cobra.Command{
 Commands:
   create-token  Create an admin token for deployment
   list-tokens   List all admin tokens
+  rotate-token  Issue a new secret for an admin token
   revoke-token  Revoke an admin token`,
 }
 
@@ -46,7 +49,8 @@ as it cannot be retrieved again.
 Examples:
   alyx admin create-token deploy-ci
   alyx admin create-token deploy-ci --permissions deploy,rollback
-  alyx admin create-token deploy-ci --expires 30d`,
+  alyx admin create-token deploy-ci --expires 30d
+  alyx admin create-token deploy-ci --require-signing`,
 	Args: cobra.ExactArgs(1),
 	RunE: runCreateToken,
 }
@@ -58,6 +62,15 @@ var listTokensCmd = &cobra.Command{
 	RunE:  runListTokens,
 }
 
+var rotateTokenCmd = &cobra.Command{
+	Use:   "rotate-token <name>",
+	Short: "Rotate an admin token",
+	Long: `Issue a new secret for an admin token, keeping its permissions and
+expiry. The previous secret stops working immediately.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRotateToken,
+}
+
 var revokeTokenCmd = &cobra.Command{
 	Use:   "revoke-token <name>",
 	Short: "Revoke an admin token",
@@ -68,17 +81,19 @@ var revokeTokenCmd = &cobra.Command{
 
 func init() {
 	createTokenCmd.Flags().StringVar(&adminTokenExpiry, "expires", "", "Token expiry duration (e.g., 30d, 1y)")
-	createTokenCmd.Flags().StringSliceVar(&adminTokenPerms, "permissions", []string{"deploy", "rollback"}, "Token permissions (deploy, rollback, admin)")
+	createTokenCmd.Flags().StringSliceVar(&adminTokenPerms, "permissions", []string{"deploy", "rollback"}, "Token permissions (read, deploy, rollback, introspect, admin)")
+	createTokenCmd.Flags().BoolVar(&adminTokenSigned, "require-signing", false, "Only accept signed requests, which alyx deploy sends automatically")
 
 	adminCmd.AddCommand(createTokenCmd)
 	adminCmd.AddCommand(listTokensCmd)
+	adminCmd.AddCommand(rotateTokenCmd)
 	adminCmd.AddCommand(revokeTokenCmd)
 
 	rootCmd.AddCommand(adminCmd)
 }
 
 func getDeployService() (*deploy.Service, *database.DB, error) {
-	cfg, err := config.LoadWithDefaults()
+	cfg, err := loadConfig()
 	if err != nil {
 		cfg = config.Default()
 	}
@@ -89,6 +104,14 @@ func getDeployService() (*deploy.Service, *database.DB, error) {
 	}
 
 	svc := deploy.NewService(db.DB, "schema.yaml", "functions", "migrations")
+	if cfg.Security.FieldEncryptionKey != "" {
+		fc, err := database.NewFieldCipher(cfg.Security.FieldEncryptionKey, cfg.Security.PreviousFieldEncryptionKeys...)
+		if err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("loading field encryption key: %w", err)
+		}
+		svc.SetKeyCipher(fc)
+	}
 	if err := svc.Init(); err != nil {
 		db.Close()
 		return nil, nil, fmt.Errorf("initializing deploy service: %w", err)
@@ -117,9 +140,10 @@ func runCreateToken(cmd *cobra.Command, args []string) error {
 	}
 
 	req := &deploy.CreateTokenRequest{
-		Name:        name,
-		Permissions: adminTokenPerms,
-		ExpiresAt:   expiresAt,
+		Name:           name,
+		Permissions:    adminTokenPerms,
+		ExpiresAt:      expiresAt,
+		RequireSigning: adminTokenSigned,
 	}
 
 	resp, err := svc.CreateToken(req, "cli")
@@ -136,6 +160,9 @@ func runCreateToken(cmd *cobra.Command, args []string) error {
 	} else {
 		fmt.Println("Expires:     never")
 	}
+	if resp.RequireSigning {
+		fmt.Println("Signing:     required")
+	}
 	fmt.Println()
 	fmt.Println("Token (store securely - shown only once):")
 	fmt.Printf("  %s\n", resp.Token)
@@ -171,7 +198,7 @@ func runListTokens(cmd *cobra.Command, args []string) error {
 
 	fmt.Println("Admin Tokens:")
 	fmt.Println()
-	fmt.Printf("%-20s %-20s %-25s %-20s\n", "NAME", "PERMISSIONS", "CREATED", "LAST USED")
+	fmt.Printf("%-20s %-20s %-20s %-20s %-20s\n", "NAME", "PERMISSIONS", "CREATED", "EXPIRES", "LAST USED")
 	fmt.Println(strings.Repeat("-", tokensTableWidth))
 
 	for _, t := range tokens {
@@ -180,18 +207,50 @@ func runListTokens(cmd *cobra.Command, args []string) error {
 			perms = perms[:permissionsTruncLen] + "..."
 		}
 
-		created := t.CreatedAt.Format("2006-01-02 15:04")
+		created := t.CreatedAt.Local().Format("2006-01-02 15:04")
+		expires := "never"
+		if t.ExpiresAt != nil {
+			expires = t.ExpiresAt.Local().Format("2006-01-02 15:04")
+			if !t.ExpiresAt.After(time.Now()) {
+				expires += " (expired)"
+			}
+		}
 		lastUsed := "never"
 		if t.LastUsedAt != nil {
-			lastUsed = t.LastUsedAt.Format("2006-01-02 15:04")
+			lastUsed = t.LastUsedAt.Local().Format("2006-01-02 15:04")
 		}
 
-		fmt.Printf("%-20s %-20s %-25s %-20s\n", t.Name, perms, created, lastUsed)
+		fmt.Printf("%-20s %-20s %-20s %-20s %-20s\n", t.Name, perms, created, expires, lastUsed)
 	}
 
 	return nil
 }
 
+func runRotateToken(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	svc, db, err := getDeployService()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	resp, err := svc.RotateToken(name)
+	if errors.Is(err, deploy.ErrTokenNotFound) {
+		return fmt.Errorf("token %q not found", name)
+	}
+	if err != nil {
+		return fmt.Errorf("rotating token: %w", err)
+	}
+
+	fmt.Printf("Token %q rotated. The previous secret no longer works.\n", resp.Name)
+	fmt.Println()
+	fmt.Println("New token (store securely - shown only once):")
+	fmt.Printf("  %s\n", resp.Token)
+
+	return nil
+}
+
 func runRevokeToken(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
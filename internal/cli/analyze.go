@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/watzon/alyx/internal/advisor"
+)
+
+var (
+	analyzeURL     string
+	analyzeToken   string
+	analyzeSince   string
+	analyzeMinRows int64
+	analyzeJSON    bool
+)
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "Analyze a running server",
+	Long: `Analyze a running Alyx server.
+
+Commands:
+  indexes  Recommend indexes from recent list queries`,
+}
+
+var analyzeIndexesCmd = &cobra.Command{
+	Use:   "indexes",
+	Short: "Recommend indexes from recent list queries",
+	Long: `Recommend indexes from the list queries in a running server's request log.
+
+Each distinct combination of filters and sorts is explained with EXPLAIN
+QUERY PLAN. Queries that scan a large table or sort it without an index get
+a suggested index in schema.yaml syntax, ranked by estimated benefit (rows
+scanned per row returned) and request count. Declared indexes that none of
+the analyzed queries use are listed as unused.
+
+The request log is kept in memory, so run this against a server that has
+been serving representative traffic.
+
+Examples:
+  alyx analyze indexes --url http://localhost:8090 --token <token>
+  alyx analyze indexes --since 1h --min-rows 10000
+  alyx analyze indexes --json
+
+Environment:
+  ALYX_DEPLOY_URL    Default server URL
+  ALYX_DEPLOY_TOKEN  Default admin token`,
+	RunE: runAnalyzeIndexes,
+}
+
+func init() {
+	analyzeIndexesCmd.Flags().StringVar(&analyzeURL, "url", "", "Alyx server URL (or ALYX_DEPLOY_URL)")
+	analyzeIndexesCmd.Flags().StringVar(&analyzeToken, "token", "", "Admin token for authentication (or ALYX_DEPLOY_TOKEN)")
+	analyzeIndexesCmd.Flags().StringVar(&analyzeSince, "since", "", "Only analyze requests newer than this duration, e.g. 1h")
+	analyzeIndexesCmd.Flags().Int64Var(&analyzeMinRows, "min-rows", advisor.DefaultMinRows, "Smallest table whose full scans are reported")
+	analyzeIndexesCmd.Flags().BoolVar(&analyzeJSON, "json", false, "Print the report as JSON")
+
+	analyzeCmd.AddCommand(analyzeIndexesCmd)
+
+	rootCmd.AddCommand(analyzeCmd)
+}
+
+func runAnalyzeIndexes(cmd *cobra.Command, args []string) error {
+	if analyzeURL == "" {
+		analyzeURL = os.Getenv("ALYX_DEPLOY_URL")
+	}
+	if analyzeToken == "" {
+		analyzeToken = os.Getenv("ALYX_DEPLOY_TOKEN")
+	}
+	if analyzeURL == "" {
+		return fmt.Errorf("--url is required (or set ALYX_DEPLOY_URL)")
+	}
+	if analyzeToken == "" {
+		return fmt.Errorf("--token is required (or set ALYX_DEPLOY_TOKEN)")
+	}
+
+	client := &deployClient{
+		baseURL: strings.TrimSuffix(analyzeURL, "/"),
+		token:   analyzeToken,
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+
+	query := url.Values{}
+	if analyzeSince != "" {
+		query.Set("since", analyzeSince)
+	}
+	query.Set("min_rows", strconv.FormatInt(analyzeMinRows, 10))
+
+	resp, err := client.doRequest(context.Background(), http.MethodGet, "/api/admin/advisor/indexes?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("requesting index advice: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return handleErrorResponse(resp)
+	}
+
+	var report advisor.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	if analyzeJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	printIndexReport(cmd, &report)
+	return nil
+}
+
+func printIndexReport(cmd *cobra.Command, report *advisor.Report) {
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Analyzed %d list request(s) across %d query shape(s)\n\n", report.Queries, report.Shapes)
+
+	if len(report.Recommendations) == 0 {
+		fmt.Fprintln(out, "  ✓ No missing indexes found")
+	} else {
+		fmt.Fprintf(out, "Recommended indexes (%d):\n", len(report.Recommendations))
+		for _, rec := range report.Recommendations {
+			fmt.Fprintln(out)
+			fmt.Fprintf(out, "  %s.%s\n", rec.Collection, rec.Index.Name)
+			fmt.Fprintf(out, "    Query:   %s (%d request(s))\n", rec.Query, rec.Requests)
+			fmt.Fprintf(out, "    Reason:  %s\n", rec.Reason)
+			fmt.Fprintf(out, "    Benefit: ~%.0fx (%d rows scanned, %.1f returned per request)\n", rec.Benefit, rec.RowsScanned, rec.RowsReturned)
+			fmt.Fprintf(out, "    Add under collections.%s.indexes:\n", rec.Collection)
+			for _, line := range strings.Split(strings.TrimRight(rec.YAML, "\n"), "\n") {
+				fmt.Fprintf(out, "      %s\n", line)
+			}
+		}
+	}
+
+	if len(report.UnusedIndexes) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Unused indexes (%d):\n", len(report.UnusedIndexes))
+		for _, idx := range report.UnusedIndexes {
+			fmt.Fprintf(out, "  %s.%s (%s): not used by %d analyzed query shape(s)\n",
+				idx.Collection, idx.Name, strings.Join(idx.Fields, ", "), idx.Queries)
+		}
+	}
+}
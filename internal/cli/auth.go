@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+)
+
+var (
+	authRotateKeep      int
+	authBenchTarget     time.Duration
+	authBenchMaxMemory  int
+	authBenchIterations int
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Authentication utilities",
+	Long: `Authentication utilities for Alyx.
+
+Commands:
+  rotate-secret   Generate a new JWT signing secret
+  benchmark-hash  Suggest password hashing parameters for this host`,
+}
+
+var authRotateSecretCmd = &cobra.Command{
+	Use:   "rotate-secret",
+	Short: "Generate a new JWT signing secret",
+	Long: `Generate a cryptographically random JWT secret and make it the signing
+secret in alyx.yaml. The previous secret moves to auth.jwt.secrets after the
+new one, where it is still accepted for verification, so existing sessions
+keep working until they expire.
+
+Once every token signed with the old secret has expired (refresh_ttl after
+the rotation), run the command again or remove the old secret by hand.
+
+Examples:
+  alyx auth rotate-secret
+  alyx auth rotate-secret --keep 2`,
+	RunE: runAuthRotateSecret,
+}
+
+var authBenchmarkHashCmd = &cobra.Command{
+	Use:   "benchmark-hash",
+	Short: "Suggest password hashing parameters for this host",
+	Long: `Time password hashing on this machine and suggest the strongest
+auth.password.hash parameters that hash one password within --target.
+
+For bcrypt the cost is raised one step at a time; for argon2id the memory is
+doubled, up to --max-memory, with one thread per CPU (at most 4). Run it on
+the hardware the server runs on: slower hashes resist offline cracking
+better, but each login spends that long on a CPU.
+
+Examples:
+  alyx auth benchmark-hash
+  alyx auth benchmark-hash --target 500ms --max-memory 256`,
+	RunE: runAuthBenchmarkHash,
+}
+
+func init() {
+	authRotateSecretCmd.Flags().IntVar(&authRotateKeep, "keep", 1, "Number of previous secrets to keep for verification")
+
+	authBenchmarkHashCmd.Flags().DurationVar(&authBenchTarget, "target", 250*time.Millisecond, "Longest time one hash may take")
+	authBenchmarkHashCmd.Flags().IntVar(&authBenchMaxMemory, "max-memory", 128, "Most memory in MiB argon2id may use per hash")
+	authBenchmarkHashCmd.Flags().IntVar(&authBenchIterations, "iterations", config.DefaultArgon2idIterations, "argon2id passes over the memory")
+
+	authCmd.AddCommand(authRotateSecretCmd)
+	authCmd.AddCommand(authBenchmarkHashCmd)
+
+	rootCmd.AddCommand(authCmd)
+}
+
+func runAuthRotateSecret(cmd *cobra.Command, args []string) error {
+	if authRotateKeep < 0 {
+		return fmt.Errorf("--keep must be non-negative")
+	}
+
+	path, err := config.ConfigFilePath(cfgFile)
+	if err != nil {
+		return fmt.Errorf("locating config file: %w", err)
+	}
+
+	secret, err := config.GenerateJWTSecret()
+	if err != nil {
+		return err
+	}
+
+	rotation, err := config.RotateJWTSecret(path, secret, authRotateKeep)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "  ✓ Rotated JWT secret in %s\n", path)
+	if rotation.Retained > 0 {
+		fmt.Fprintf(out, "    %d previous secret(s) kept for verification\n", rotation.Retained)
+	} else {
+		fmt.Fprintln(out, "    No previous secret found; existing tokens will be rejected")
+	}
+	if rotation.Dropped > 0 {
+		fmt.Fprintf(out, "    %d older secret(s) removed\n", rotation.Dropped)
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Next steps:")
+	fmt.Fprintln(out, "  1. Restart the server (or every instance) to sign with the new secret.")
+	fmt.Fprintln(out, "  2. After refresh_ttl has passed, remove the old secret from auth.jwt.secrets.")
+	if _, ok := os.LookupEnv("ALYX_AUTH_JWT_SECRET"); ok {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Warning: ALYX_AUTH_JWT_SECRET is set. It cannot be combined with")
+		fmt.Fprintln(out, "auth.jwt.secrets; unset it and add its value to the list instead.")
+	}
+
+	return nil
+}
+
+func runAuthBenchmarkHash(cmd *cobra.Command, args []string) error {
+	if authBenchTarget <= 0 {
+		return fmt.Errorf("--target must be positive")
+	}
+	if authBenchIterations < 1 {
+		return fmt.Errorf("--iterations must be at least 1")
+	}
+	if authBenchMaxMemory*1024 < config.DefaultArgon2idMemory {
+		return fmt.Errorf("--max-memory must be at least %d MiB", config.DefaultArgon2idMemory/1024)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Timing password hashes (target %s)...\n\n", authBenchTarget)
+
+	// bcrypt: each cost step doubles the work.
+	cost, costTime := config.DefaultBcryptCost-2, time.Duration(0)
+	for c := cost; c <= 31; c++ {
+		d, err := timeHash(config.PasswordHashConfig{
+			Algorithm: config.PasswordHashBcrypt,
+			Bcrypt:    config.BcryptConfig{Cost: c},
+		})
+		if err != nil {
+			return err
+		}
+		if d > authBenchTarget && c > cost {
+			break
+		}
+		cost, costTime = c, d
+		if d > authBenchTarget {
+			break
+		}
+	}
+
+	// argon2id: double the memory until the target or the cap is reached.
+	argon := config.Argon2idConfig{
+		Memory:      config.DefaultArgon2idMemory,
+		Iterations:  authBenchIterations,
+		Parallelism: min(runtime.NumCPU(), 4),
+	}
+	argonTime := time.Duration(0)
+	for memory := argon.Memory; memory <= authBenchMaxMemory*1024; memory *= 2 {
+		candidate := argon
+		candidate.Memory = memory
+		d, err := timeHash(config.PasswordHashConfig{Algorithm: config.PasswordHashArgon2id, Argon2id: candidate})
+		if err != nil {
+			return err
+		}
+		if d > authBenchTarget && memory > argon.Memory {
+			break
+		}
+		argon, argonTime = candidate, d
+		if d > authBenchTarget {
+			break
+		}
+	}
+
+	printHashSuggestion(out, cost, costTime, argon, argonTime)
+	return nil
+}
+
+// timeHash returns how long hashing one password with cfg takes.
+func timeHash(cfg config.PasswordHashConfig) (time.Duration, error) {
+	hasher := auth.NewPasswordHasher(cfg)
+	start := time.Now()
+	if _, err := hasher.Hash("benchmark-password"); err != nil {
+		return 0, fmt.Errorf("hashing with %s: %w", cfg.Algorithm, err)
+	}
+	return time.Since(start), nil
+}
+
+func printHashSuggestion(out io.Writer, cost int, costTime time.Duration, argon config.Argon2idConfig, argonTime time.Duration) {
+	fmt.Fprintf(out, "  bcrypt    cost %d: %s\n", cost, costTime.Round(time.Millisecond))
+	fmt.Fprintf(out, "  argon2id  %d MiB, %d iterations, %d threads: %s\n",
+		argon.Memory/1024, argon.Iterations, argon.Parallelism, argonTime.Round(time.Millisecond))
+	if costTime > authBenchTarget || argonTime > authBenchTarget {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Warning: the weakest recommended parameters exceed the target on this host.")
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Suggested alyx.yaml settings:")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "auth:")
+	fmt.Fprintln(out, "  password:")
+	fmt.Fprintln(out, "    hash:")
+	fmt.Fprintf(out, "      algorithm: %s\n", config.PasswordHashArgon2id)
+	fmt.Fprintln(out, "      bcrypt:")
+	fmt.Fprintf(out, "        cost: %d\n", cost)
+	fmt.Fprintln(out, "      argon2id:")
+	fmt.Fprintf(out, "        memory: %d # KiB\n", argon.Memory)
+	fmt.Fprintf(out, "        iterations: %d\n", argon.Iterations)
+	fmt.Fprintf(out, "        parallelism: %d\n", argon.Parallelism)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Existing hashes keep working and are upgraded as users log in.")
+}
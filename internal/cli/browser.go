@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// browserCommand returns the command that opens url in the default browser
+// on goos.
+func browserCommand(goos, url string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "open", []string{url}
+	case "windows":
+		return "rundll32", []string{"url.dll,FileProtocolHandler", url}
+	default:
+		return "xdg-open", []string{url}
+	}
+}
+
+// openBrowser opens url in the default browser without waiting for it.
+func openBrowser(url string) error {
+	name, args := browserCommand(runtime.GOOS, url)
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("opening browser: %w", err)
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
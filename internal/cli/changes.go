@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/watzon/alyx/internal/changefeed"
+)
+
+var (
+	changesServer   string
+	changesToken    string
+	changesSince    int64
+	changesLimit    int
+	changesFollow   bool
+	changesInterval time.Duration
+	changesJSON     bool
+)
+
+var changesCmd = &cobra.Command{
+	Use:   "changes",
+	Short: "Inspect the change feed",
+	Long: `Inspect the change feed of a running Alyx server.
+
+The change feed must be enabled with changes.enabled in alyx.yaml.`,
+}
+
+var changesTailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Print changes from the change feed",
+	Long: `Print committed changes from GET /api/admin/changes, oldest first.
+
+Starts after the sequence number given by --since (default: the oldest
+retained change) and prints every available change. With --follow, keeps
+polling for new changes until interrupted.
+
+Examples:
+  alyx changes tail --server https://api.myapp.com --token <token>
+  alyx changes tail --since 12345 --follow
+  alyx changes tail --since 12345 --json
+
+Environment Variables:
+  ALYX_DEPLOY_URL    Default server URL
+  ALYX_DEPLOY_TOKEN  Default admin token`,
+	SilenceUsage: true,
+	RunE:         runChangesTail,
+}
+
+func init() {
+	changesTailCmd.Flags().StringVar(&changesServer, "server", "", "Alyx server URL (or ALYX_DEPLOY_URL)")
+	changesTailCmd.Flags().StringVar(&changesToken, "token", "", "Admin token (or ALYX_DEPLOY_TOKEN)")
+	changesTailCmd.Flags().Int64Var(&changesSince, "since", 0, "Print changes after this sequence number")
+	changesTailCmd.Flags().IntVar(&changesLimit, "limit", changefeed.DefaultLimit, "Changes fetched per request")
+	changesTailCmd.Flags().BoolVarP(&changesFollow, "follow", "f", false, "Keep polling for new changes")
+	changesTailCmd.Flags().DurationVar(&changesInterval, "interval", time.Second, "Polling interval with --follow")
+	changesTailCmd.Flags().BoolVar(&changesJSON, "json", false, "Print each change as a line of JSON")
+
+	changesCmd.AddCommand(changesTailCmd)
+	rootCmd.AddCommand(changesCmd)
+}
+
+func runChangesTail(cmd *cobra.Command, args []string) error {
+	if changesServer == "" {
+		changesServer = os.Getenv("ALYX_DEPLOY_URL")
+	}
+	if changesToken == "" {
+		changesToken = os.Getenv("ALYX_DEPLOY_TOKEN")
+	}
+	if changesServer == "" {
+		return fmt.Errorf("--server is required (or set ALYX_DEPLOY_URL)")
+	}
+
+	client := &deployClient{
+		baseURL: strings.TrimSuffix(changesServer, "/"),
+		token:   changesToken,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	since := changesSince
+	schemaVersion := ""
+	for {
+		batch, err := fetchChanges(ctx, client, since, changesLimit)
+		if err != nil {
+			return err
+		}
+
+		if !changesJSON && schemaVersion != "" && batch.SchemaVersion != schemaVersion {
+			fmt.Fprintf(os.Stderr, "Schema changed (version %s)\n", truncateHash(batch.SchemaVersion))
+		}
+		schemaVersion = batch.SchemaVersion
+
+		for _, change := range batch.Changes {
+			if err := printChange(change); err != nil {
+				return err
+			}
+		}
+		since = batch.NextSeq
+
+		if batch.HasMore {
+			continue
+		}
+		if !changesFollow {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(changesInterval):
+		}
+	}
+}
+
+func fetchChanges(ctx context.Context, client *deployClient, since int64, limit int) (*changefeed.Batch, error) {
+	path := fmt.Sprintf("/api/admin/changes?since_seq=%d&limit=%d", since, limit)
+	resp, err := client.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusGone:
+		return nil, fmt.Errorf("changes after sequence %d have been pruned", since)
+	default:
+		return nil, handleErrorResponse(resp)
+	}
+
+	var batch changefeed.Batch
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, fmt.Errorf("parsing change feed response: %w", err)
+	}
+	return &batch, nil
+}
+
+func printChange(change *changefeed.Change) error {
+	if changesJSON {
+		data, err := json.Marshal(change)
+		if err != nil {
+			return fmt.Errorf("encoding change: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	line := fmt.Sprintf("%d  %s  %-6s %s/%s",
+		change.Seq, change.Timestamp.Local().Format("2006-01-02 15:04:05"),
+		change.Operation, change.Collection, change.DocID)
+	if len(change.ChangedFields) > 0 {
+		line += "  [" + strings.Join(change.ChangedFields, ", ") + "]"
+	}
+	fmt.Println(line)
+	return nil
+}
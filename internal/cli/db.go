@@ -99,7 +99,7 @@ func runDBSeed(cmd *cobra.Command, args []string) error {
 	}
 
 	// Load config and schema
-	cfg, err := config.LoadWithDefaults()
+	cfg, err := loadConfig()
 	if err != nil {
 		log.Warn().Err(err).Msg("No config file found, using defaults")
 		cfg = config.Default()
@@ -110,7 +110,7 @@ func runDBSeed(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no schema file found")
 	}
 
-	s, err := schema.ParseFile(schemaPath)
+	s, err := loadSchema(schemaPath)
 	if err != nil {
 		return fmt.Errorf("parsing schema: %w", err)
 	}
@@ -202,7 +202,7 @@ func runDBDump(cmd *cobra.Command, args []string) error {
 	outputFile := args[0]
 
 	// Load config and schema
-	cfg, err := config.LoadWithDefaults()
+	cfg, err := loadConfig()
 	if err != nil {
 		log.Warn().Err(err).Msg("No config file found, using defaults")
 		cfg = config.Default()
@@ -213,7 +213,7 @@ func runDBDump(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no schema file found")
 	}
 
-	s, err := schema.ParseFile(schemaPath)
+	s, err := loadSchema(schemaPath)
 	if err != nil {
 		return fmt.Errorf("parsing schema: %w", err)
 	}
@@ -318,7 +318,7 @@ func confirmReset() bool {
 }
 
 func loadConfigAndSchema() (*config.Config, *schema.Schema, error) {
-	cfg, err := config.LoadWithDefaults()
+	cfg, err := loadConfig()
 	if err != nil {
 		log.Warn().Err(err).Msg("No config file found, using defaults")
 		cfg = config.Default()
@@ -329,7 +329,7 @@ func loadConfigAndSchema() (*config.Config, *schema.Schema, error) {
 		return nil, nil, fmt.Errorf("no schema file found")
 	}
 
-	s, err := schema.ParseFile(schemaPath)
+	s, err := loadSchema(schemaPath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("parsing schema: %w", err)
 	}
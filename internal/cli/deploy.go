@@ -32,6 +32,7 @@ var (
 	deployRollback string
 	deployHistory  bool
 	deployDesc     string
+	deployBundle   string
 )
 
 var deployCmd = &cobra.Command{
@@ -47,6 +48,7 @@ Examples:
   alyx deploy --url https://api.myapp.com --token <token> --dry-run
   alyx deploy --url https://api.myapp.com --token <token> --rollback v2
   alyx deploy --url https://api.myapp.com --token <token> --history
+  alyx deploy --url https://api.myapp.com --token <token> --bundle alyx-bundle.tar.gz
 
 Environment Variables:
   ALYX_DEPLOY_URL    Default deployment URL
@@ -62,6 +64,7 @@ func init() {
 	deployCmd.Flags().StringVar(&deployRollback, "rollback", "", "Rollback to specified version")
 	deployCmd.Flags().BoolVar(&deployHistory, "history", false, "Show deployment history")
 	deployCmd.Flags().StringVar(&deployDesc, "description", "", "Deployment description")
+	deployCmd.Flags().StringVar(&deployBundle, "bundle", "", "Deploy a bundle made with alyx deploy pack")
 
 	rootCmd.AddCommand(deployCmd)
 }
@@ -103,6 +106,10 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 		return doRollback(client, deployRollback)
 	}
 
+	if deployBundle != "" {
+		return doBundleDeploy(client, deployBundle)
+	}
+
 	// Normal deployment
 	return doDeploy(client)
 }
@@ -114,22 +121,34 @@ type deployClient struct {
 }
 
 func (c *deployClient) doRequest(ctx context.Context, method, path string, body any) (*http.Response, error) {
-	var bodyReader io.Reader
+	var data []byte
 	if body != nil {
-		data, err := json.Marshal(body)
+		var err error
+		data, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("marshaling request: %w", err)
 		}
-		bodyReader = bytes.NewReader(data)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bodyReader)
+	return c.doRaw(ctx, method, path, "application/json", data)
+}
+
+// doRaw sends data as the request body with the given content type.
+func (c *deployClient) doRaw(ctx context.Context, method, path, contentType string, data []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	req.Header.Set("Content-Type", "application/json")
+	// Tokens created with require_signing never send their secret.
+	if deploy.IsSigningToken(c.token) {
+		if err := deploy.SignRequest(req, c.token, data, time.Now()); err != nil {
+			return nil, fmt.Errorf("signing request: %w", err)
+		}
+	} else if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Content-Type", contentType)
 
 	return c.client.Do(req)
 }
@@ -184,6 +203,7 @@ func createDeployBundle() (*deploy.Bundle, *deploy.Bundler, error) {
 	}
 
 	bundler := deploy.NewBundler(schemaPath, "functions")
+	bundler.SetEnv(activeEnv())
 	bundle, err := bundler.CreateBundle()
 	if err != nil {
 		return nil, nil, fmt.Errorf("creating bundle: %w", err)
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/watzon/alyx/internal/deploy"
+)
+
+var (
+	packOutput     string
+	packFunctions  string
+	packMigrations string
+)
+
+var deployPackCmd = &cobra.Command{
+	Use:   "pack",
+	Short: "Pack the schema, functions and migrations into a deploy bundle",
+	Long: `Pack the schema, functions and migrations into a deploy bundle.
+
+The bundle is a tar.gz archive with the schema as a single schema.yaml (any
+environment overlay merged), the files each function runs from, the migration
+files and a manifest with their hashes. A function with a build output
+contributes only its entrypoint and that output, so build functions first.
+
+Deploy the bundle with alyx deploy --bundle. It is applied as a whole: schema,
+migrations and functions are rolled back together if any step fails.
+
+Examples:
+  alyx deploy pack
+  alyx deploy pack -o release.tar.gz
+  alyx deploy --url https://api.myapp.com --token <token> --bundle release.tar.gz`,
+	SilenceUsage: true,
+	RunE:         runDeployPack,
+}
+
+func init() {
+	deployPackCmd.Flags().StringVarP(&packOutput, "output", "o", "alyx-bundle.tar.gz", "Bundle file to write")
+	deployPackCmd.Flags().StringVar(&packFunctions, "functions", "functions", "Functions directory")
+	deployPackCmd.Flags().StringVar(&packMigrations, "migrations", "migrations", "Migrations directory")
+	deployCmd.AddCommand(deployPackCmd)
+}
+
+func runDeployPack(cmd *cobra.Command, args []string) error {
+	schemaPath := resolveSchemaPath("")
+	if schemaPath == "" {
+		return fmt.Errorf("schema.yaml not found")
+	}
+
+	bundler := deploy.NewBundler(schemaPath, packFunctions)
+	bundler.SetEnv(activeEnv())
+	bundler.SetMigrations(packMigrations)
+
+	// Written beside the output and renamed, so a failed pack leaves no
+	// partial bundle behind.
+	tmp, err := os.CreateTemp(filepath.Dir(packOutput), ".alyx-bundle-*")
+	if err != nil {
+		return fmt.Errorf("creating bundle: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	manifest, err := bundler.Pack(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("packing bundle: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), packOutput); err != nil {
+		return fmt.Errorf("writing bundle: %w", err)
+	}
+
+	info, err := os.Stat(packOutput)
+	if err != nil {
+		return err
+	}
+	var migrations int
+	for _, f := range manifest.Files {
+		if strings.HasPrefix(f.Path, "migrations/") {
+			migrations++
+		}
+	}
+
+	fmt.Printf("Packed %s (%d bytes)\n", packOutput, info.Size())
+	fmt.Printf("  Schema hash:    %s\n", truncateHash(manifest.SchemaHash))
+	fmt.Printf("  Functions:      %d\n", len(manifest.Functions))
+	if manifest.FunctionsHash != "" {
+		fmt.Printf("  Functions hash: %s\n", truncateHash(manifest.FunctionsHash))
+	}
+	fmt.Printf("  Migrations:     %d\n", migrations)
+	fmt.Printf("  Files:          %d\n", len(manifest.Files))
+	return nil
+}
+
+// doBundleDeploy deploys a bundle file: it prepares with the manifest's
+// hashes and, once confirmed, uploads the archive for the server to verify
+// against the prepared deployment.
+func doBundleDeploy(client *deployClient, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading bundle: %w", err)
+	}
+	archive, err := deploy.ReadBundle(data)
+	if err != nil {
+		return err
+	}
+	manifest := archive.Manifest
+
+	fmt.Println("Preparing deployment...")
+	fmt.Printf("  Bundle:      %s (%d bytes)\n", path, archive.Size)
+	fmt.Printf("  Schema hash: %s\n", truncateHash(manifest.SchemaHash))
+	fmt.Printf("  Functions:   %d\n", len(manifest.Functions))
+
+	ctx := context.Background()
+	prepResp, err := prepareDeployment(ctx, client, &deploy.Bundle{
+		SchemaHash:    manifest.SchemaHash,
+		FunctionsHash: manifest.FunctionsHash,
+		Functions:     manifest.Functions,
+	})
+	if err != nil {
+		return err
+	}
+
+	printVersionInfo(prepResp)
+
+	if !prepResp.ChangesRequired {
+		fmt.Println()
+		fmt.Println("No changes detected. Already up to date.")
+		return nil
+	}
+
+	printChanges(prepResp)
+
+	if err := checkUnsafeChanges(prepResp); err != nil {
+		return err
+	}
+
+	if deployDryRun {
+		fmt.Println()
+		fmt.Println("Dry run complete. No changes applied.")
+		return nil
+	}
+
+	fmt.Println()
+	if !confirmAction("Deploy these changes?") {
+		fmt.Println("Deployment canceled.")
+		return nil
+	}
+
+	query := url.Values{}
+	query.Set("schema_hash", prepResp.SchemaHash)
+	query.Set("functions_hash", prepResp.FunctionsHash)
+	query.Set("current_version", prepResp.CurrentVersion)
+	if deployDesc != "" {
+		query.Set("description", deployDesc)
+	}
+
+	fmt.Println()
+	fmt.Println("Deploying...")
+
+	resp, err := client.doRaw(ctx, "POST", "/api/admin/deploy/execute?"+query.Encode(), deploy.BundleContentType, data)
+	if err != nil {
+		return fmt.Errorf("execute request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return handleErrorResponse(resp)
+	}
+
+	var execResp deploy.ExecuteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&execResp); err != nil {
+		return fmt.Errorf("parsing execute response: %w", err)
+	}
+
+	fmt.Printf("\n%s\n", execResp.Message)
+	fmt.Printf("Rollback command: %s\n", execResp.RollbackCmd)
+
+	return nil
+}
@@ -2,16 +2,22 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
-	"github.com/watzon/alyx/internal/codegen"
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database"
 	"github.com/watzon/alyx/internal/schema"
@@ -19,12 +25,25 @@ import (
 )
 
 var (
-	devPort       int
-	devHost       string
-	devSchemaPath string
-	devNoWatch    bool
+	devPort        int
+	devHost        string
+	devSchemaPath  string
+	devNoWatch     bool
+	devPortAuto    bool
+	devOpen        string
+	devSandbox     bool
+	devSandboxSeed bool
 )
 
+// Pages --open can open.
+const (
+	devOpenDocs  = "docs"
+	devOpenAdmin = "admin"
+)
+
+// maxPortProbes bounds how many ports after a busy one are tried.
+const maxPortProbes = 20
+
 var devCmd = &cobra.Command{
 	Use:   "dev",
 	Short: "Start the development server",
@@ -36,21 +55,36 @@ The development server will:
   - Start the HTTP server
   - Watch for file changes (schema, functions)
   
-Use --no-watch to disable file watching.`,
+Use --no-watch to disable file watching.
+
+If the port is in use, the next free port is offered when running in a
+terminal; --port-auto takes it without asking. --open opens the API docs,
+or the admin UI with --open=admin, once the server is listening.
+
+--sandbox routes every request to a separate database next to the primary
+one (data/alyx.sandbox.db by default), created with the current schema and
+left empty unless --sandbox-seed copies the primary data into it. Without
+--sandbox, requests opt in with an X-Alyx-Sandbox: true header. POST
+/api/admin/sandbox/reset recreates the sandbox.`,
 	RunE: runDev,
 }
 
 func init() {
 	devCmd.Flags().IntVarP(&devPort, "port", "p", 8090, "Port to listen on")
 	devCmd.Flags().StringVar(&devHost, "host", "localhost", "Host to bind to")
-	devCmd.Flags().StringVar(&devSchemaPath, "schema", "", "Path to schema file (default: schema.yaml or schema.yml)")
+	devCmd.Flags().StringVar(&devSchemaPath, "schema", "", "Path to schema file or directory (default: schema.yaml, schema.yml, or schema/)")
 	devCmd.Flags().BoolVar(&devNoWatch, "no-watch", false, "Disable file watching")
+	devCmd.Flags().BoolVar(&devPortAuto, "port-auto", false, "Use the next free port if the port is in use")
+	devCmd.Flags().StringVar(&devOpen, "open", "", "Open the API docs (docs) or admin UI (admin) in the browser")
+	devCmd.Flags().Lookup("open").NoOptDefVal = devOpenDocs
+	devCmd.Flags().BoolVar(&devSandbox, "sandbox", false, "Route all requests to the sandbox database")
+	devCmd.Flags().BoolVar(&devSandboxSeed, "sandbox-seed", false, "Copy the primary database's data into a new sandbox")
 
 	rootCmd.AddCommand(devCmd)
 }
 
 func runDev(cmd *cobra.Command, args []string) error {
-	cfg, err := config.LoadWithDefaults()
+	cfg, err := loadConfig()
 	if err != nil {
 		log.Warn().Err(err).Msg("No config file found, using defaults")
 		cfg = config.Default()
@@ -63,44 +97,71 @@ func runDev(cmd *cobra.Command, args []string) error {
 		cfg.Server.Host = devHost
 	}
 	cfg.Dev.Enabled = true
+	if cmd.Flags().Changed("sandbox") {
+		cfg.Dev.Sandbox = devSandbox
+	}
+	if cmd.Flags().Changed("sandbox-seed") {
+		cfg.Dev.SandboxSeed = devSandboxSeed
+	}
 
-	schemaPath := resolveSchemaPath(devSchemaPath)
-	if schemaPath == "" {
-		log.Error().Msg("No schema file found. Create schema.yaml or schema.yml, or specify --schema path")
-		return fmt.Errorf("no schema file found")
+	if devOpen != "" && devOpen != devOpenDocs && devOpen != devOpenAdmin {
+		return fmt.Errorf("--open must be %q or %q", devOpenDocs, devOpenAdmin)
 	}
 
+	var p prompter
+	if isTerminal(os.Stdin) {
+		p = newLinePrompter(os.Stdin, os.Stderr)
+	}
+	port, err := resolveDevPort(cfg.Server.Host, cfg.Server.Port, devPortAuto, p)
+	if err != nil {
+		return err
+	}
+	cfg.Server.Port = port
+
+	schemaPath := resolveSchemaPath(devSchemaPath)
+
 	log.Info().
 		Str("schema", schemaPath).
 		Str("addr", cfg.Server.Address()).
 		Msg("Starting development server")
 
-	s, err := schema.ParseFile(schemaPath)
+	db, err := database.Open(&cfg.Database)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to open database")
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	s, err := loadStartupSchema(db, schemaPath)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to parse schema")
-		return fmt.Errorf("parsing schema: %w", err)
+		log.Error().Err(err).Msg("Failed to load schema")
+		return err
+	}
+
+	if err := checkSchema(s); err != nil {
+		log.Error().Err(err).Msg("Invalid schema rules")
+		return err
 	}
 
 	log.Info().
 		Int("collections", len(s.Collections)).
 		Msg("Schema loaded")
 
-	db, err := database.Open(&cfg.Database)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to open database")
-		return fmt.Errorf("opening database: %w", err)
+	if err := db.ConfigureFieldEncryption(&cfg.Security, s); err != nil {
+		log.Error().Err(err).Msg("Invalid field encryption config")
+		return fmt.Errorf("configuring field encryption: %w", err)
 	}
-	defer db.Close()
 
 	if err := applySchema(db, s); err != nil {
 		return err
 	}
 
 	configPath, _ := config.ConfigFilePath("")
-	srv := server.New(cfg, db, s,
-		server.WithSchemaPath(schemaPath),
-		server.WithConfigPath(configPath),
-	)
+	opts := []server.Option{server.WithConfigPath(configPath)}
+	if schemaPath != "" {
+		opts = append(opts, server.WithSchemaPath(schemaPath))
+	}
+	srv := server.New(cfg, db, s, opts...)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -116,7 +177,7 @@ func runDev(cmd *cobra.Command, args []string) error {
 	}()
 
 	if !devNoWatch && cfg.Dev.Watch {
-		watcher, watchErr := setupDevWatcher(ctx, schemaPath, cfg.Functions.Path, db, srv, cfg)
+		watcher, watchErr := setupDevWatcher(ctx, schemaPath, cfg.Functions.Path, db, srv)
 		if watchErr != nil {
 			log.Warn().Err(watchErr).Msg("Failed to set up file watcher, continuing without hot-reload")
 		} else {
@@ -125,9 +186,22 @@ func runDev(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	addr, err := srv.Listen(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to start server")
+		return err
+	}
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		cfg.Server.Port = tcpAddr.Port
+	}
+
 	logServerInfo(cfg, s)
+	printDevSummary(cmd.OutOrStdout(), cfg, countUsers(ctx, db))
+	if devOpen != "" {
+		openDevPage(cfg, devOpen)
+	}
 
-	if err := srv.Start(ctx); err != nil {
+	if err := srv.Serve(); err != nil {
 		log.Error().Err(err).Msg("Server error")
 		return err
 	}
@@ -136,6 +210,121 @@ func runDev(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// resolveDevPort returns the port to listen on: port if it is free,
+// otherwise the next free port after it if auto is set or p, when not nil,
+// confirms it. Port 0 picks a random port and is returned as is.
+func resolveDevPort(host string, port int, auto bool, p prompter) (int, error) {
+	if port == 0 {
+		return 0, nil
+	}
+	free, err := portFree(host, port)
+	if err != nil || free {
+		return port, err
+	}
+
+	next := 0
+	for candidate := port + 1; candidate <= port+maxPortProbes && candidate <= 65535; candidate++ {
+		if free, err := portFree(host, candidate); err == nil && free {
+			next = candidate
+			break
+		}
+	}
+	if next == 0 {
+		return 0, fmt.Errorf("port %d is already in use and none of the next %d ports are free; pass --port", port, maxPortProbes)
+	}
+
+	if auto {
+		log.Warn().Int("port", port).Int("using", next).Msg("Port in use, using the next free port")
+		return next, nil
+	}
+	if p != nil {
+		ok, err := p.Confirm(fmt.Sprintf("Port %d is already in use. Use port %d instead?", port, next), true)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return next, nil
+		}
+	}
+	return 0, fmt.Errorf("port %d is already in use; stop the other process, pass --port, or use --port-auto", port)
+}
+
+// portFree reports whether host:port can be bound.
+func portFree(host string, port int) (bool, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err == nil {
+		_ = ln.Close()
+		return true, nil
+	}
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return false, nil
+	}
+	return false, fmt.Errorf("checking port %d: %w", port, err)
+}
+
+// countUsers returns the number of users, or -1 if they cannot be counted.
+func countUsers(ctx context.Context, db *database.DB) int {
+	var n int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM _alyx_users").Scan(&n); err != nil {
+		return -1
+	}
+	return n
+}
+
+// printDevSummary prints the server's URLs in a box, followed by how to
+// create the first admin when there are no users yet.
+func printDevSummary(w io.Writer, cfg *config.Config, users int) {
+	base := cfg.Server.URL()
+	rows := [][2]string{{"API", base + "/api"}}
+	if cfg.Docs.Enabled {
+		rows = append(rows, [2]string{"Docs", base + "/api/docs"})
+	}
+	if cfg.AdminUI.Enabled {
+		rows = append(rows, [2]string{"Admin UI", base + cfg.AdminUI.Path})
+	}
+
+	lines := []string{"Alyx development server", ""}
+	for _, row := range rows {
+		lines = append(lines, fmt.Sprintf("%-9s %s", row[0], row[1]))
+	}
+	width := 0
+	for _, line := range lines {
+		width = max(width, len(line))
+	}
+
+	fmt.Fprintf(w, "\n┌%s┐\n", strings.Repeat("─", width+2))
+	for _, line := range lines {
+		fmt.Fprintf(w, "│ %-*s │\n", width, line)
+	}
+	fmt.Fprintf(w, "└%s┘\n", strings.Repeat("─", width+2))
+
+	if users == 0 {
+		fmt.Fprintln(w, "\nNo users yet. Create the first admin with:")
+		fmt.Fprintln(w, "  alyx users create --email you@example.com --role admin --password-stdin")
+		if cfg.Auth.FirstUserAdmin {
+			fmt.Fprintln(w, "or register: the first account to sign up becomes an admin.")
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// openDevPage opens the docs or admin UI, as named by page, in the browser.
+func openDevPage(cfg *config.Config, page string) {
+	url := cfg.Server.URL() + "/api/docs"
+	enabled := cfg.Docs.Enabled
+	if page == devOpenAdmin {
+		url = cfg.Server.URL() + cfg.AdminUI.Path
+		enabled = cfg.AdminUI.Enabled
+	}
+	if !enabled {
+		log.Warn().Str("page", page).Msg("Not opening browser: page is disabled in config")
+		return
+	}
+	if err := openBrowser(url); err != nil {
+		log.Warn().Err(err).Str("url", url).Msg("Failed to open browser")
+	}
+}
+
 func applySchema(db *database.DB, s *schema.Schema) error {
 	gen := schema.NewSQLGenerator(s)
 	for _, stmt := range gen.GenerateAll() {
@@ -146,15 +335,15 @@ func applySchema(db *database.DB, s *schema.Schema) error {
 		}
 	}
 
+	if err := schema.NewMigrator(db.DB, "", "").SaveSchemaSnapshot(s); err != nil {
+		log.Warn().Err(err).Msg("Failed to cache schema")
+	}
+
 	log.Info().Msg("Database schema applied")
 	return nil
 }
 
 func logServerInfo(cfg *config.Config, s *schema.Schema) {
-	log.Info().
-		Str("url", "http://"+cfg.Server.Address()).
-		Msg("Server started")
-
 	for name := range s.Collections {
 		log.Info().
 			Str("collection", name).
@@ -164,9 +353,8 @@ func logServerInfo(cfg *config.Config, s *schema.Schema) {
 
 	if cfg.Docs.Enabled {
 		log.Info().
-			Str("docs", "http://"+cfg.Server.Address()+"/api/docs").
-			Str("openapi", "http://"+cfg.Server.Address()+"/api/openapi.json").
-			Str("ui", cfg.Docs.UI).
+			Str("openapi", cfg.Server.URL()+"/api/openapi.json").
+			Strs("uis", cfg.Docs.EnabledUIs()).
 			Msg("API documentation")
 	}
 
@@ -181,26 +369,28 @@ func logServerInfo(cfg *config.Config, s *schema.Schema) {
 			Str("functions", cfg.Functions.Path).
 			Msg("Functions directory")
 	}
-
-	if cfg.AdminUI.Enabled {
-		log.Info().
-			Str("admin", "http://"+cfg.Server.Address()+cfg.AdminUI.Path).
-			Msg("Admin UI")
-	}
 }
 
-func setupDevWatcher(ctx context.Context, schemaPath, functionsPath string, db *database.DB, srv *server.Server, cfg *config.Config) (*DevWatcher, error) {
-	absSchemaPath, _ := filepath.Abs(schemaPath)
+func setupDevWatcher(ctx context.Context, schemaPath, functionsPath string, db *database.DB, srv *server.Server) (*DevWatcher, error) {
+	absSchemaPath := ""
+	if schemaPath != "" {
+		absSchemaPath, _ = filepath.Abs(schemaPath)
+	}
+	overlayPath := ""
+	if env := activeEnv(); env != "" && absSchemaPath != "" {
+		overlayPath = schema.OverlayPath(absSchemaPath, env)
+	}
 	absFunctionsPath := ""
 	if functionsPath != "" {
 		absFunctionsPath, _ = filepath.Abs(functionsPath)
 	}
 
 	watcher, err := NewDevWatcher(DevWatcherConfig{
-		SchemaPath:    absSchemaPath,
-		FunctionsPath: absFunctionsPath,
+		SchemaPath:        absSchemaPath,
+		SchemaOverlayPath: overlayPath,
+		FunctionsPath:     absFunctionsPath,
 		OnSchemaChange: func(path string) {
-			handleSchemaChange(path, db, srv, cfg)
+			handleSchemaChange(path, db, srv)
 		},
 		OnFunctionChange: func(path string, eventType EventType) {
 			handleFunctionChange(path, eventType, srv)
@@ -214,14 +404,18 @@ func setupDevWatcher(ctx context.Context, schemaPath, functionsPath string, db *
 	return watcher, nil
 }
 
-func handleSchemaChange(path string, db *database.DB, srv *server.Server, cfg *config.Config) {
+func handleSchemaChange(path string, db *database.DB, srv *server.Server) {
 	log.Info().Str("path", path).Msg("Schema file changed - applying all changes destructively")
 
-	newSchema, err := schema.ParseFile(path)
+	newSchema, err := loadSchema(path)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to parse updated schema")
 		return
 	}
+	if err := checkSchema(newSchema); err != nil {
+		log.Error().Err(err).Msg("Updated schema has invalid rules, not applying it")
+		return
+	}
 
 	currentSchema := srv.Schema()
 	differ := schema.NewDiffer()
@@ -276,70 +470,111 @@ func handleSchemaChange(path string, db *database.DB, srv *server.Server, cfg *c
 		}
 	}
 
-	if err := srv.UpdateSchema(newSchema); err != nil {
-		log.Error().Err(err).Msg("Failed to update server schema")
-		return
-	}
-
 	log.Info().
 		Int("safe", len(safeChanges)).
 		Int("unsafe", len(unsafeChanges)).
 		Msg("All schema changes applied successfully")
 
-	if cfg.Dev.AutoGenerate && len(cfg.Dev.GenerateLanguages) > 0 {
-		regenerateClients(newSchema, cfg)
+	if err := srv.SchemaApplied(newSchema, schema.ChangedCollections(changes)); err != nil {
+		log.Error().Err(err).Msg("Failed to update server schema")
 	}
 }
 
-func regenerateClients(s *schema.Schema, cfg *config.Config) {
-	languages := make([]codegen.Language, 0, len(cfg.Dev.GenerateLanguages))
-	for _, langStr := range cfg.Dev.GenerateLanguages {
-		lang, err := codegen.ParseLanguage(langStr)
-		if err != nil {
-			log.Warn().Str("language", langStr).Msg("Unknown language, skipping")
-			continue
-		}
-		languages = append(languages, lang)
-	}
+func handleFunctionChange(path string, eventType EventType, srv *server.Server) {
+	log.Info().
+		Str("path", path).
+		Str("event", eventType.String()).
+		Msg("Function file changed")
 
-	if len(languages) == 0 {
+	if err := srv.ReloadFunctions(); err != nil {
+		log.Error().Err(err).Msg("Failed to reload functions")
 		return
 	}
 
-	genCfg := &codegen.Config{
-		OutputDir:   cfg.Dev.GenerateOutput,
-		Languages:   languages,
-		ServerURL:   fmt.Sprintf("http://%s:%d", cfg.Server.Host, cfg.Server.Port),
-		PackageName: "alyx",
+	log.Info().Msg("Functions reloaded successfully")
+}
+
+// Schema sources reported by loadStartupSchema.
+const (
+	schemaSourceFile     = "file"
+	schemaSourceDatabase = "database"
+)
+
+// loadStartupSchema returns the schema to boot with: the schema file at
+// schemaPath, or the schema last applied to db when the file is missing or
+// was modified before that schema was applied. Which one won is logged.
+func loadStartupSchema(db *database.DB, schemaPath string) (*schema.Schema, error) {
+	migrator := schema.NewMigrator(db.DB, schemaPath, "")
+	if err := migrator.Init(); err != nil {
+		return nil, fmt.Errorf("initializing migrations: %w", err)
+	}
+	snapshot, err := migrator.LoadSchemaSnapshot()
+	if err != nil {
+		log.Warn().Err(err).Msg("Ignoring unreadable cached schema")
+		snapshot = nil
 	}
 
-	if genCfg.OutputDir == "" {
-		genCfg.OutputDir = "./generated"
+	if schemaPath == "" {
+		if snapshot == nil {
+			return nil, errors.New("no schema file found and the database has no cached schema; create schema.yaml, schema.yml, or a schema/ directory, or specify --schema path")
+		}
+		logSchemaSource(schemaSourceDatabase, "no schema file found", snapshot.UpdatedAt)
+		return snapshot.Schema, nil
 	}
 
-	if err := codegen.GenerateAll(genCfg, s); err != nil {
-		log.Error().Err(err).Msg("Failed to regenerate client SDKs")
-		return
+	if snapshot != nil {
+		modTime, err := schemaModTime(schemaPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading schema: %w", err)
+		}
+		if snapshot.UpdatedAt.After(modTime) {
+			logSchemaSource(schemaSourceDatabase, "cached schema is newer than "+schemaPath, snapshot.UpdatedAt)
+			return snapshot.Schema, nil
+		}
 	}
 
-	log.Info().
-		Strs("languages", cfg.Dev.GenerateLanguages).
-		Str("output", genCfg.OutputDir).
-		Msg("Client SDKs regenerated")
+	s, err := loadSchema(schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema: %w", err)
+	}
+	logSchemaSource(schemaSourceFile, schemaPath, time.Time{})
+	return s, nil
 }
 
-func handleFunctionChange(path string, eventType EventType, srv *server.Server) {
-	log.Info().
-		Str("path", path).
-		Str("event", eventType.String()).
-		Msg("Function file changed")
+func logSchemaSource(source, reason string, cachedAt time.Time) {
+	event := log.Info().Str("source", source).Str("reason", reason)
+	if !cachedAt.IsZero() {
+		event = event.Time("cached_at", cachedAt)
+	}
+	event.Msg("Using schema")
+}
 
-	if err := srv.ReloadFunctions(); err != nil {
-		log.Error().Err(err).Msg("Failed to reload functions")
-		return
+// schemaModTime returns when the schema file, or the newest file of a schema
+// directory, was last modified.
+func schemaModTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !info.IsDir() {
+		return info.ModTime(), nil
 	}
 
-	log.Info().Msg("Functions reloaded successfully")
+	var newest time.Time
+	err = filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if fi.ModTime().After(newest) {
+			newest = fi.ModTime()
+		}
+		return nil
+	})
+	return newest, err
 }
 
 func resolveSchemaPath(explicit string) string {
@@ -350,11 +585,22 @@ func resolveSchemaPath(explicit string) string {
 		return ""
 	}
 
+	if configured := viper.GetString("schema"); configured != "" {
+		if _, err := os.Stat(configured); err == nil {
+			return configured
+		}
+		return ""
+	}
+
 	candidates := []string{"schema.yaml", "schema.yml"}
 	for _, c := range candidates {
 		if _, err := os.Stat(c); err == nil {
 			return c
 		}
 	}
+
+	if schema.IsDir("schema") {
+		return "schema"
+	}
 	return ""
 }
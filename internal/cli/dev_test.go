@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/server"
+)
+
+// busyPort listens on a random local port until the test ends, skipping
+// the test if the port after it is taken too.
+func busyPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	port := ln.Addr().(*net.TCPAddr).Port
+	if free, err := portFree("127.0.0.1", port+1); err != nil || !free {
+		t.Skipf("port %d is not free", port+1)
+	}
+	return port
+}
+
+func TestResolveDevPort(t *testing.T) {
+	port := busyPort(t)
+
+	if got, err := resolveDevPort("127.0.0.1", port+1, false, nil); err != nil || got != port+1 {
+		t.Errorf("free port: got %d, %v; want %d", got, err, port+1)
+	}
+
+	if got, err := resolveDevPort("127.0.0.1", port, true, nil); err != nil || got != port+1 {
+		t.Errorf("--port-auto: got %d, %v; want %d", got, err, port+1)
+	}
+
+	_, err := resolveDevPort("127.0.0.1", port, false, nil)
+	if err == nil || !strings.Contains(err.Error(), "--port-auto") {
+		t.Errorf("non-interactive: expected an error suggesting --port-auto, got %v", err)
+	}
+
+	if got, err := resolveDevPort("127.0.0.1", port, false, &scriptedPrompter{t: t, answers: []string{""}}); err != nil || got != port+1 {
+		t.Errorf("accepted prompt: got %d, %v; want %d", got, err, port+1)
+	}
+
+	if _, err := resolveDevPort("127.0.0.1", port, false, &scriptedPrompter{t: t, answers: []string{"n"}}); err == nil {
+		t.Error("declined prompt: expected an error")
+	}
+
+	if got, err := resolveDevPort("127.0.0.1", 0, false, nil); err != nil || got != 0 {
+		t.Errorf("random port: got %d, %v; want 0", got, err)
+	}
+}
+
+func TestPrintDevSummary(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.Host = "0.0.0.0"
+	cfg.Server.Port = 8091
+	cfg.Docs.Enabled = true
+	cfg.AdminUI.Enabled = true
+
+	var out bytes.Buffer
+	printDevSummary(&out, cfg, 0)
+	for _, want := range []string{
+		"http://localhost:8091/api ",
+		"http://localhost:8091/api/docs",
+		"http://localhost:8091" + cfg.AdminUI.Path,
+		"alyx users create",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary is missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "http://localhost:8091/api\n") {
+		t.Errorf("rows are not padded to the box:\n%s", out.String())
+	}
+
+	out.Reset()
+	cfg.AdminUI.Enabled = false
+	printDevSummary(&out, cfg, 1)
+	if strings.Contains(out.String(), "Admin UI") || strings.Contains(out.String(), "alyx users create") {
+		t.Errorf("expected no admin UI row or bootstrap hint:\n%s", out.String())
+	}
+}
+
+func TestBrowserCommand(t *testing.T) {
+	tests := []struct {
+		goos string
+		name string
+	}{
+		{"darwin", "open"},
+		{"windows", "rundll32"},
+		{"linux", "xdg-open"},
+		{"freebsd", "xdg-open"},
+	}
+	for _, tt := range tests {
+		name, args := browserCommand(tt.goos, "http://localhost:8090")
+		if name != tt.name || args[len(args)-1] != "http://localhost:8090" {
+			t.Errorf("%s: got %s %v", tt.goos, name, args)
+		}
+	}
+}
+
+const startupSchemaYAML = `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+    rules:
+      create: "true"
+      read: "true"
+`
+
+func TestLoadStartupSchema(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(dir, "data.db")
+	db, err := database.Open(&cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schemaPath := filepath.Join(dir, "schema.yaml")
+	if _, err := loadStartupSchema(db, ""); err == nil {
+		t.Fatal("expected an error with neither a schema file nor a cached schema")
+	}
+
+	applied, err := schema.Parse([]byte(startupSchemaYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator := schema.NewMigrator(db.DB, "", "")
+	if err := migrator.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.ApplySchema(applied); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file modified before the schema was applied loses to the cache.
+	if err := os.WriteFile(schemaPath, []byte("version: 1\ncollections:\n  notes:\n    fields:\n      id:\n        type: uuid\n        primary: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(schemaPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadStartupSchema(db, schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Collections["posts"]; !ok {
+		t.Errorf("expected the cached schema to win over an older file")
+	}
+
+	// An edited file wins.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(schemaPath, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = loadStartupSchema(db, schemaPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Collections["notes"]; !ok {
+		t.Errorf("expected the newer file to win, got collections %v", s.Collections)
+	}
+}
+
+func TestBootFromCachedSchema(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Server.Host = "127.0.0.1"
+	cfg.Server.Port = 0
+	cfg.Database.Path = filepath.Join(dir, "data.db")
+	cfg.Functions.Enabled = false
+	cfg.AdminUI.Enabled = false
+
+	// A previous run applied the schema; this one has no schema file.
+	db, err := database.Open(&cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	applied, err := schema.Parse([]byte(startupSchemaYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator := schema.NewMigrator(db.DB, "", "")
+	if err := migrator.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.ApplySchema(applied); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := loadStartupSchema(db, "")
+	if err != nil {
+		t.Fatalf("loading cached schema: %v", err)
+	}
+	if err := applySchema(db, s); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := server.New(cfg, db, s)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, err := srv.Listen(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Serve() }()
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+
+	base := "http://" + addr.String() + "/api/collections/posts"
+	resp, err := http.Post(base, "application/json", strings.NewReader(`{"title": "Hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Docs []map[string]any `json:"docs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || len(list.Docs) != 1 || list.Docs[0]["title"] != "Hello" {
+		t.Errorf("list: status %d, docs %v", resp.StatusCode, list.Docs)
+	}
+}
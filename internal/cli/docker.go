@@ -0,0 +1,360 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+var (
+	dockerizeTemplate string
+	dockerizeForce    bool
+)
+
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Manage an existing Alyx project",
+}
+
+var projectDockerizeCmd = &cobra.Command{
+	Use:   "dockerize [dir]",
+	Short: "Add a Dockerfile and compose file to a project",
+	Long: `Write a Dockerfile, docker-compose.yaml, and .dockerignore for an existing
+project, the same files alyx init --with-docker creates.
+
+The image builds the alyx binary and copies alyx.yaml, the schema, and the
+functions and migrations directories; ./data is declared as a volume. When
+alyx.yaml declares an S3 storage backend with a local endpoint, such as
+http://localhost:9000, the compose file also runs a MinIO service and points
+the backend at it.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runProjectDockerize,
+}
+
+func init() {
+	projectDockerizeCmd.Flags().StringVarP(&dockerizeTemplate, "template", "t", "basic", "Project template the files are tailored to (basic, blog, saas)")
+	projectDockerizeCmd.Flags().BoolVarP(&dockerizeForce, "force", "f", false, "Overwrite existing files")
+
+	projectCmd.AddCommand(projectDockerizeCmd)
+	rootCmd.AddCommand(projectCmd)
+}
+
+func runProjectDockerize(cmd *cobra.Command, args []string) error {
+	projectDir := "."
+	if len(args) > 0 {
+		projectDir = args[0]
+	}
+
+	tmpl, err := validateTemplate(dockerizeTemplate)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "alyx.yaml")); err != nil {
+		return fmt.Errorf("no alyx.yaml in %s: run alyx init first", projectDir)
+	}
+
+	if err := writeDockerFiles(projectDir, tmpl, dockerizeForce); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("✓ Docker files written")
+	fmt.Println()
+	fmt.Println("Next steps:")
+	if projectDir != "." {
+		fmt.Printf("  cd %s\n", projectDir)
+	}
+	fmt.Println("  JWT_SECRET=$(openssl rand -hex 32) docker compose up -d")
+	fmt.Println()
+	return nil
+}
+
+// dockerFiles are the files writeDockerFiles creates.
+var dockerFiles = []string{"Dockerfile", "docker-compose.yaml", ".dockerignore"}
+
+// writeDockerFiles renders the Docker files for the project in projectDir
+// and writes them, refusing to overwrite existing ones unless force is set.
+func writeDockerFiles(projectDir string, tmpl *Template, force bool) error {
+	if !force {
+		var existing []string
+		for _, name := range dockerFiles {
+			if _, err := os.Stat(filepath.Join(projectDir, name)); err == nil {
+				existing = append(existing, name)
+			}
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("files already exist: %s (use --force to overwrite)", strings.Join(existing, ", "))
+		}
+	}
+
+	project, err := inspectDockerProject(projectDir, tmpl)
+	if err != nil {
+		return err
+	}
+	files, err := renderDockerFiles(project)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range dockerFiles {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte(files[name]), 0o600); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+		log.Info().Str("file", name).Msg("Created")
+	}
+	return nil
+}
+
+// dockerProject is what the Docker file templates are rendered from.
+type dockerProject struct {
+	Name     string
+	Template string
+	Port     int
+	// Paths are the project files and directories copied into the image.
+	Paths []string
+	// OAuth adds the OAuth client variables the template's config mentions.
+	OAuth bool
+	// MinIO runs a MinIO service for the S3 backends with local endpoints.
+	MinIO *dockerMinIO
+}
+
+// dockerMinIO describes the MinIO service and the backends pointed at it.
+type dockerMinIO struct {
+	Port     string
+	Backends []string
+}
+
+// inspectDockerProject reads the project's config and lists the files the
+// image needs.
+func inspectDockerProject(projectDir string, tmpl *Template) (*dockerProject, error) {
+	cfg, err := config.Load(config.LoadOptions{ConfigFile: filepath.Join(projectDir, "alyx.yaml")})
+	if err != nil {
+		return nil, fmt.Errorf("loading alyx.yaml: %w", err)
+	}
+
+	abs, err := filepath.Abs(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &dockerProject{
+		Name:     dockerServiceName(filepath.Base(abs)),
+		Template: tmpl.Name,
+		Port:     cfg.Server.Port,
+		OAuth:    tmpl.Name == "saas",
+		MinIO:    localS3Backends(cfg),
+	}
+	for _, path := range []string{"alyx.yaml", "schema.yaml", "schema.yml", "schema", "functions", "migrations"} {
+		if _, err := os.Stat(filepath.Join(projectDir, path)); err == nil {
+			project.Paths = append(project.Paths, path)
+		}
+	}
+	return project, nil
+}
+
+// localS3Backends returns the MinIO service for the config's S3 backends
+// whose endpoint is on this machine, or nil when there are none.
+func localS3Backends(cfg *config.Config) *dockerMinIO {
+	var minio *dockerMinIO
+	for name, backend := range cfg.Storage.Backends {
+		if backend.Type != "s3" || backend.S3 == nil || backend.S3.Endpoint == "" {
+			continue
+		}
+		endpoint, err := url.Parse(backend.S3.Endpoint)
+		if err != nil || !isLocalHost(endpoint.Hostname()) {
+			continue
+		}
+		if minio == nil {
+			minio = &dockerMinIO{Port: "9000"}
+		}
+		if port := endpoint.Port(); port != "" {
+			minio.Port = port
+		}
+		minio.Backends = append(minio.Backends, name)
+	}
+	if minio != nil {
+		sort.Strings(minio.Backends)
+	}
+	return minio
+}
+
+func isLocalHost(host string) bool {
+	switch host {
+	case "localhost", "minio", "":
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+// dockerServiceName turns a directory name into a compose service and image
+// name: lowercase letters, digits, and dashes.
+func dockerServiceName(dir string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(dir) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	name := strings.TrimSuffix(b.String(), "-")
+	if name == "" {
+		return "alyx"
+	}
+	return name
+}
+
+// configEnvVar is the ALYX_ variable that overrides a config key.
+func configEnvVar(key string) string {
+	return "ALYX_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+var dockerTemplates = template.Must(template.New("docker").Funcs(template.FuncMap{
+	"env": configEnvVar,
+}).Parse(`{{define "Dockerfile"}}# syntax=docker/dockerfile:1
+# Dockerfile for {{.Name}} ({{.Template}} template), generated by alyx.
+# Build: docker build -t {{.Name}} .
+
+# Build stage: compile the alyx binary. To use the published image instead,
+# replace this stage with:
+#   FROM ghcr.io/watzon/alyx:<version> AS builder
+# and copy /usr/local/bin/alyx from it below.
+FROM golang:1.24-alpine AS builder
+
+ARG ALYX_VERSION=latest
+ENV CGO_ENABLED=0
+
+RUN go install -ldflags="-s -w" github.com/watzon/alyx/cmd/alyx@${ALYX_VERSION}
+
+# Runtime stage
+FROM alpine:3.20
+
+# docker-cli runs function containers through the mounted Docker socket.
+RUN apk add --no-cache ca-certificates docker-cli \
+    && addgroup -g 1001 alyx \
+    && adduser -D -u 1001 -G alyx alyx \
+    && mkdir -p /app/data \
+    && chown -R alyx:alyx /app
+
+WORKDIR /app
+
+COPY --from=builder /go/bin/alyx /usr/local/bin/alyx
+{{- range .Paths}}
+COPY --chown=alyx:alyx {{.}} ./{{.}}
+{{- end}}
+
+USER alyx
+
+EXPOSE {{.Port}}
+
+VOLUME ["/app/data"]
+
+HEALTHCHECK --interval=30s --timeout=3s --start-period=10s --retries=3 \
+    CMD wget -q --spider http://localhost:{{.Port}}/health/live || exit 1
+
+CMD ["alyx", "dev", "--host", "0.0.0.0", "--no-watch"]
+{{end}}
+
+{{define "docker-compose.yaml"}}# Compose file for {{.Name}} ({{.Template}} template), generated by alyx.
+# Start: JWT_SECRET=$(openssl rand -hex 32) docker compose up -d
+
+services:
+  {{.Name}}:
+    build: .
+    image: {{.Name}}
+    ports:
+      - "${ALYX_PORT:-{{.Port}}}:{{.Port}}"
+    volumes:
+      - alyx-data:/app/data
+      # Function containers are started through the host's Docker daemon.
+      - /var/run/docker.sock:/var/run/docker.sock
+    environment:
+      JWT_SECRET: ${JWT_SECRET:?set JWT_SECRET to a random string of at least 32 characters}
+      ALYX_DATABASE_PATH: /app/data/alyx.db
+{{- if .OAuth}}
+      GITHUB_CLIENT_ID: ${GITHUB_CLIENT_ID:-}
+      GITHUB_CLIENT_SECRET: ${GITHUB_CLIENT_SECRET:-}
+      GOOGLE_CLIENT_ID: ${GOOGLE_CLIENT_ID:-}
+      GOOGLE_CLIENT_SECRET: ${GOOGLE_CLIENT_SECRET:-}
+{{- end}}
+{{- with .MinIO}}
+{{- range .Backends}}
+      {{env (printf "storage.backends.%s.s3.endpoint" .)}}: http://minio:{{$.MinIO.Port}}
+      {{env (printf "storage.backends.%s.s3.access_key_id" .)}}: ${MINIO_ROOT_USER:-minioadmin}
+      {{env (printf "storage.backends.%s.s3.secret_access_key" .)}}: ${MINIO_ROOT_PASSWORD:-minioadmin}
+{{- end}}
+    depends_on:
+      - minio
+{{- end}}
+    restart: unless-stopped
+{{- with .MinIO}}
+
+  minio:
+    image: minio/minio:RELEASE.2024-10-13T13-34-11Z
+    command: server /data --address :{{.Port}} --console-address :9001
+    ports:
+      - "{{.Port}}:{{.Port}}"
+      - "9001:9001"
+    volumes:
+      - minio-data:/data
+    environment:
+      MINIO_ROOT_USER: ${MINIO_ROOT_USER:-minioadmin}
+      MINIO_ROOT_PASSWORD: ${MINIO_ROOT_PASSWORD:-minioadmin}
+    restart: unless-stopped
+{{- end}}
+
+volumes:
+  alyx-data:
+{{- if .MinIO}}
+  minio-data:
+{{- end}}
+{{end}}
+
+{{define ".dockerignore"}}# Local state that must not end up in the image
+data/
+*.db
+*.db-wal
+*.db-shm
+.env
+.env.*
+
+# Generated
+generated/
+node_modules/
+
+# VCS and editors
+.git/
+.idea/
+.vscode/
+
+# Docker
+Dockerfile
+docker-compose.yaml
+.dockerignore
+{{end}}`))
+
+// renderDockerFiles renders the Docker files for project, keyed by name.
+func renderDockerFiles(project *dockerProject) (map[string]string, error) {
+	files := make(map[string]string, len(dockerFiles))
+	for _, name := range dockerFiles {
+		var buf bytes.Buffer
+		if err := dockerTemplates.ExecuteTemplate(&buf, name, project); err != nil {
+			return nil, fmt.Errorf("rendering %s: %w", name, err)
+		}
+		files[name] = buf.String()
+	}
+	return files, nil
+}
@@ -0,0 +1,318 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type dockerInstruction struct {
+	line int
+	cmd  string
+	args string
+}
+
+// parseDockerfile splits a Dockerfile into instructions, joining continued
+// lines and dropping comments.
+func parseDockerfile(t *testing.T, content string) []dockerInstruction {
+	t.Helper()
+	var (
+		instructions []dockerInstruction
+		current      strings.Builder
+		start        int
+	)
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if current.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "#")) {
+			continue
+		}
+		if current.Len() == 0 {
+			start = i + 1
+		}
+		if strings.HasSuffix(trimmed, "\\") {
+			current.WriteString(strings.TrimSuffix(trimmed, "\\") + " ")
+			continue
+		}
+		current.WriteString(trimmed)
+		cmd, args, _ := strings.Cut(current.String(), " ")
+		instructions = append(instructions, dockerInstruction{line: start, cmd: strings.ToUpper(cmd), args: strings.TrimSpace(args)})
+		current.Reset()
+	}
+	if current.Len() > 0 {
+		t.Fatalf("Dockerfile ends in a line continuation")
+	}
+	return instructions
+}
+
+// lintDockerfile applies a subset of hadolint's rules, plus checks that every
+// COPY source is in the build context and not excluded by .dockerignore.
+func lintDockerfile(t *testing.T, content, projectDir string, ignored []string) {
+	t.Helper()
+	known := map[string]bool{
+		"FROM": true, "ARG": true, "ENV": true, "RUN": true, "WORKDIR": true, "COPY": true,
+		"USER": true, "EXPOSE": true, "VOLUME": true, "HEALTHCHECK": true, "CMD": true,
+	}
+	stages := map[string]bool{}
+	var cmds, users []string
+	var sawVolume bool
+
+	instructions := parseDockerfile(t, content)
+	for i, in := range instructions {
+		if !known[in.cmd] {
+			t.Errorf("line %d: unknown instruction %s", in.line, in.cmd)
+		}
+		if i == 0 && in.cmd != "FROM" && in.cmd != "ARG" {
+			t.Errorf("line %d: first instruction is %s, want FROM", in.line, in.cmd)
+		}
+		switch in.cmd {
+		case "FROM":
+			fields := strings.Fields(in.args)
+			image := fields[0]
+			if !strings.Contains(image, ":") || strings.HasSuffix(image, ":latest") {
+				t.Errorf("line %d: DL3006/DL3007: image %s must be pinned to a version", in.line, image)
+			}
+			if len(fields) == 3 && strings.EqualFold(fields[1], "AS") {
+				stages[fields[2]] = true
+			}
+		case "WORKDIR":
+			if !strings.HasPrefix(in.args, "/") {
+				t.Errorf("line %d: DL3000: WORKDIR %s must be absolute", in.line, in.args)
+			}
+		case "RUN":
+			if strings.Contains(in.args, "cd ") {
+				t.Errorf("line %d: DL3003: use WORKDIR instead of cd", in.line)
+			}
+			if strings.Contains(in.args, "apk add") && !strings.Contains(in.args, "--no-cache") {
+				t.Errorf("line %d: DL3019: apk add without --no-cache", in.line)
+			}
+		case "COPY":
+			fields := strings.Fields(in.args)
+			var sources []string
+			fromStage := false
+			for _, f := range fields[:len(fields)-1] {
+				if stage, ok := strings.CutPrefix(f, "--from="); ok {
+					fromStage = true
+					if !stages[stage] {
+						t.Errorf("line %d: DL3022: COPY --from=%s does not name an earlier stage", in.line, stage)
+					}
+					continue
+				}
+				if !strings.HasPrefix(f, "--") {
+					sources = append(sources, f)
+				}
+			}
+			if fromStage {
+				continue
+			}
+			for _, src := range sources {
+				if _, err := os.Stat(filepath.Join(projectDir, src)); err != nil {
+					t.Errorf("line %d: COPY source %s is not in the build context", in.line, src)
+				}
+				for _, pattern := range ignored {
+					if strings.TrimSuffix(pattern, "/") == src {
+						t.Errorf("line %d: COPY source %s is excluded by .dockerignore", in.line, src)
+					}
+				}
+			}
+		case "CMD":
+			var argv []string
+			if err := json.Unmarshal([]byte(in.args), &argv); err != nil {
+				t.Errorf("line %d: DL3025: CMD must use the JSON form: %v", in.line, err)
+			}
+			cmds = append(cmds, in.args)
+		case "USER":
+			users = append(users, in.args)
+		case "VOLUME":
+			sawVolume = strings.Contains(in.args, "/app/data")
+		}
+	}
+
+	if len(cmds) != 1 {
+		t.Errorf("DL4003: got %d CMD instructions, want 1", len(cmds))
+	}
+	if len(users) == 0 || users[len(users)-1] == "root" {
+		t.Errorf("DL3002: the image must not run as root")
+	}
+	if !sawVolume {
+		t.Errorf("no VOLUME for /app/data")
+	}
+}
+
+func dockerignorePatterns(content string) []string {
+	var patterns []string
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns
+}
+
+type composeFile struct {
+	Services map[string]struct {
+		Build       string            `yaml:"build"`
+		Image       string            `yaml:"image"`
+		Ports       []string          `yaml:"ports"`
+		Volumes     []string          `yaml:"volumes"`
+		Environment map[string]string `yaml:"environment"`
+		DependsOn   []string          `yaml:"depends_on"`
+	} `yaml:"services"`
+	Volumes map[string]any `yaml:"volumes"`
+}
+
+func TestInitWithDocker(t *testing.T) {
+	for name, tmpl := range getTemplates() {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "My App")
+			if err := prepareProjectDir(dir, false); err != nil {
+				t.Fatal(err)
+			}
+			if err := createProjectStructure(dir); err != nil {
+				t.Fatal(err)
+			}
+			if err := writeTemplateFiles(dir, tmpl, defaultTemplateValues()); err != nil {
+				t.Fatal(err)
+			}
+			if err := writeDockerFiles(dir, tmpl, false); err != nil {
+				t.Fatalf("writeDockerFiles: %v", err)
+			}
+
+			read := func(name string) string {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(data)
+			}
+
+			dockerfile := read("Dockerfile")
+			lintDockerfile(t, dockerfile, dir, dockerignorePatterns(read(".dockerignore")))
+			for _, path := range []string{"alyx.yaml", "schema.yaml", "functions", "migrations"} {
+				if !strings.Contains(dockerfile, "COPY --chown=alyx:alyx "+path+" ") {
+					t.Errorf("Dockerfile does not copy %s", path)
+				}
+			}
+
+			var compose composeFile
+			if err := yaml.Unmarshal([]byte(read("docker-compose.yaml")), &compose); err != nil {
+				t.Fatalf("parse compose file: %v", err)
+			}
+			service, ok := compose.Services["my-app"]
+			if !ok {
+				t.Fatalf("no my-app service in %v", compose.Services)
+			}
+			if service.Build != "." || len(service.Ports) != 1 || service.Ports[0] != "${ALYX_PORT:-8090}:8090" {
+				t.Errorf("service = %+v", service)
+			}
+			if !strings.HasPrefix(service.Environment["JWT_SECRET"], "${JWT_SECRET:?") {
+				t.Errorf("JWT_SECRET = %q, want it required", service.Environment["JWT_SECRET"])
+			}
+			if _, ok := service.Environment["GITHUB_CLIENT_ID"]; ok != (name == "saas") {
+				t.Errorf("OAuth variables present = %v for %s template", ok, name)
+			}
+			if _, ok := compose.Services["minio"]; ok {
+				t.Error("unexpected minio service without an S3 backend")
+			}
+			if _, ok := compose.Volumes["alyx-data"]; !ok {
+				t.Error("no alyx-data volume")
+			}
+
+			if err := writeDockerFiles(dir, tmpl, false); err == nil || !strings.Contains(err.Error(), "already exist") {
+				t.Errorf("expected existing files error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDockerizeWithLocalS3(t *testing.T) {
+	dir := t.TempDir()
+	config := `server:
+  port: 9100
+storage:
+  backends:
+    uploads:
+      type: s3
+      s3:
+        endpoint: http://localhost:9002
+        region: us-east-1
+        access_key_id: minioadmin
+        secret_access_key: minioadmin
+        force_path_style: true
+    archive:
+      type: s3
+      s3:
+        endpoint: https://s3.amazonaws.com
+        region: us-east-1
+        access_key_id: AKIAEXAMPLE
+        secret_access_key: secret
+`
+	if err := os.WriteFile(filepath.Join(dir, "alyx.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "schema.yaml"), []byte(basicSchemaYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, _ := validateTemplate("basic")
+	if err := writeDockerFiles(dir, tmpl, false); err != nil {
+		t.Fatalf("writeDockerFiles: %v", err)
+	}
+
+	dockerfile, _ := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	lintDockerfile(t, string(dockerfile), dir, nil)
+	if strings.Contains(string(dockerfile), "COPY --chown=alyx:alyx functions") {
+		t.Error("Dockerfile copies a functions directory the project does not have")
+	}
+	if !strings.Contains(string(dockerfile), "EXPOSE 9100") {
+		t.Error("Dockerfile does not expose the configured port")
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "docker-compose.yaml"))
+	var compose composeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		t.Fatalf("parse compose file: %v", err)
+	}
+	minio, ok := compose.Services["minio"]
+	if !ok {
+		t.Fatal("expected a minio service")
+	}
+	if len(minio.Ports) == 0 || minio.Ports[0] != "9002:9002" {
+		t.Errorf("minio ports = %v", minio.Ports)
+	}
+
+	var app string
+	for name := range compose.Services {
+		if name != "minio" {
+			app = name
+		}
+	}
+	env := compose.Services[app].Environment
+	if env["ALYX_STORAGE_BACKENDS_UPLOADS_S3_ENDPOINT"] != "http://minio:9002" {
+		t.Errorf("uploads endpoint = %q", env["ALYX_STORAGE_BACKENDS_UPLOADS_S3_ENDPOINT"])
+	}
+	if _, ok := env["ALYX_STORAGE_BACKENDS_ARCHIVE_S3_ENDPOINT"]; ok {
+		t.Error("remote S3 backend was pointed at minio")
+	}
+	if deps := compose.Services[app].DependsOn; len(deps) != 1 || deps[0] != "minio" {
+		t.Errorf("depends_on = %v", deps)
+	}
+}
+
+func TestDockerServiceName(t *testing.T) {
+	tests := map[string]string{
+		"my-app":    "my-app",
+		"My App":    "my-app",
+		"app_2.0":   "app-2-0",
+		"--":        "alyx",
+		"Über Café": "ber-caf",
+	}
+	for dir, want := range tests {
+		if got := dockerServiceName(dir); got != want {
+			t.Errorf("dockerServiceName(%q) = %q, want %q", dir, got, want)
+		}
+	}
+}
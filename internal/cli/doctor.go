@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/doctor"
+)
+
+var (
+	doctorJSON           bool
+	doctorSchemaPath     string
+	doctorMigrationsPath string
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose common project problems",
+	Long: `Run diagnostic checks against the current project.
+
+Checks:
+  - config loads and validates, and the JWT secret is at least 32 characters
+  - schema parses, and every collection operation has an access rule
+  - database file is writable and in WAL mode
+  - PRAGMA foreign_key_check reports no violations
+  - applied migrations match the checksums of their files
+  - interpreters for function runtimes are installed (when functions are enabled)
+  - function entrypoints exist
+  - storage backends are reachable
+  - the server port is free
+
+The database is opened read-only, so doctor never modifies it. The command
+exits with a non-zero status if any check fails.
+
+Examples:
+  alyx doctor
+  alyx doctor --json`,
+	SilenceUsage: true,
+	RunE:         runDoctor,
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "Output results as JSON")
+	doctorCmd.Flags().StringVar(&doctorSchemaPath, "schema", "", "Path to schema file (default: schema.yaml)")
+	doctorCmd.Flags().StringVar(&doctorMigrationsPath, "migrations", "migrations", "Path to migrations directory")
+
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	env := &doctor.Env{MigrationsPath: doctorMigrationsPath}
+	defer env.Close()
+
+	env.Config, env.ConfigErr = loadConfig()
+	if env.ConfigErr != nil {
+		env.Config = config.Default()
+	}
+
+	env.SchemaPath = resolveSchemaPath(doctorSchemaPath)
+	if env.SchemaPath != "" {
+		env.Schema, env.SchemaErr = loadSchema(env.SchemaPath)
+	} else if doctorSchemaPath != "" {
+		env.SchemaErr = fmt.Errorf("schema file not found: %s", doctorSchemaPath)
+	}
+
+	results := doctor.Run(cmd.Context(), env, doctor.DefaultChecks())
+	summary := doctor.Summarize(results)
+
+	if doctorJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			Results []doctor.Result `json:"results"`
+			Summary doctor.Summary  `json:"summary"`
+		}{results, summary}); err != nil {
+			return fmt.Errorf("encoding results: %w", err)
+		}
+	} else {
+		printDoctorResults(results, summary)
+	}
+
+	if summary.Fail > 0 {
+		return fmt.Errorf("%d check(s) failed", summary.Fail)
+	}
+	return nil
+}
+
+func printDoctorResults(results []doctor.Result, summary doctor.Summary) {
+	for _, r := range results {
+		fmt.Printf("  %s %-22s %s\n", doctorSymbol(r.Status), r.Name, r.Message)
+		for _, d := range r.Details {
+			fmt.Printf("      - %s\n", d)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("%d passed, %d warnings, %d failed, %d skipped\n", summary.Pass, summary.Warn, summary.Fail, summary.Skip)
+}
+
+func doctorSymbol(status doctor.Status) string {
+	switch status {
+	case doctor.StatusPass:
+		return "✓"
+	case doctor.StatusWarn:
+		return "!"
+	case doctor.StatusFail:
+		return "✗"
+	default:
+		return "-"
+	}
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/fake"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const fakeTestSchema = `version: 1
+collections:
+  authors:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      name:
+        type: string
+  posts:
+    fields:
+      id:
+        type: id
+        primary: true
+        default: auto
+      author_id:
+        type: uuid
+        references: authors.id
+      title:
+        type: string
+`
+
+// runFakeCommand runs "alyx fake ..." against a project in dir.
+func runFakeCommand(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+
+	fakeCollection, fakeCount, fakeSeed, fakeWindow, fakeUntil = "", 10, 0, "90d", ""
+	fakeParents, fakeTenant, fakeForce, fakeJSON = fake.DefaultParents, "", false, false
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs(append([]string{"--config", filepath.Join(dir, "alyx.yaml"), "fake"}, args...))
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		cfgFile = ""
+	})
+
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestFake(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "alyx.db")
+	schemaPath := filepath.Join(dir, "schema.yaml")
+	if err := os.WriteFile(schemaPath, []byte(fakeTestSchema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	yaml := "schema: " + schemaPath + "\ndatabase:\n  path: " + dbPath + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "alyx.yaml"), []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := schema.ParseFile(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.Open(&config.DatabaseConfig{Path: dbPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	if _, err := runFakeCommand(t, dir, "--collection", "posts", "--count", "5"); err == nil || !strings.Contains(err.Error(), "dev.enabled") {
+		t.Fatalf("Expected fake to refuse to run outside dev mode, got %v", err)
+	}
+
+	out, err := runFakeCommand(t, dir, "--collection", "posts", "--count", "5", "--seed", "3", "--parents", "2", "--force")
+	if err != nil {
+		t.Fatalf("fake --force: %v\n%s", err, out)
+	}
+	for _, want := range []string{"authors: 2 document(s)", "posts: 5 document(s)", "Generated 7 document(s) with seed 3"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -10,7 +10,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/watzon/alyx/internal/codegen"
-	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/config"
 )
 
 var generateCmd = &cobra.Command{
@@ -40,17 +40,43 @@ var (
 	generateOutput string
 	generateURL    string
 	generatePkg    string
+	generateDates  string
 )
 
 func init() {
 	generateCmd.Flags().StringVarP(&generateLangs, "lang", "l", "typescript", "Languages to generate (comma-separated)")
 	generateCmd.Flags().StringVarP(&generateOutput, "output", "o", "", "Output directory (default: ./generated)")
-	generateCmd.Flags().StringVarP(&generateURL, "url", "u", "", "Server URL for client (default: http://localhost:8080)")
+	generateCmd.Flags().StringVarP(&generateURL, "url", "u", "", "Server URL for client (default: the configured server address)")
 	generateCmd.Flags().StringVar(&generatePkg, "package", "", "Package name for Go client (default: alyx)")
+	generateCmd.Flags().StringVar(&generateDates, "dates", "", "TypeScript type of timestamp fields: string or Date (default: dev.generate_dates, else string)")
 
 	AddCommand(generateCmd)
 }
 
+// generateConfig loads the config the generate commands take their defaults
+// from, with the active environment overlay applied.
+func generateConfig() *config.Config {
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load config, using defaults")
+		return config.Default()
+	}
+	return cfg
+}
+
+// clientURL returns the server URL generated clients connect to by default.
+func clientURL(cfg *config.Config) string {
+	host := cfg.Server.Host
+	if host == "" {
+		host = config.DefaultHost
+	}
+	port := cfg.Server.Port
+	if port == 0 {
+		port = config.DefaultPort
+	}
+	return fmt.Sprintf("http://%s:%d", host, port)
+}
+
 func runGenerate(cmd *cobra.Command, args []string) error {
 	// Find and parse schema
 	schemaPath := viper.GetString("schema")
@@ -63,7 +89,7 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("resolving schema path: %w", err)
 	}
 
-	s, err := schema.ParseFile(absSchemaPath)
+	s, err := loadSchema(absSchemaPath)
 	if err != nil {
 		return fmt.Errorf("parsing schema: %w", err)
 	}
@@ -75,32 +101,33 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Build config
+	appCfg := generateConfig()
 	cfg := codegen.DefaultConfig()
 
 	if generateOutput != "" {
 		cfg.OutputDir = generateOutput
-	} else if viper.IsSet("dev.generate_output") {
-		cfg.OutputDir = viper.GetString("dev.generate_output")
+	} else if appCfg.Dev.GenerateOutput != "" {
+		cfg.OutputDir = appCfg.Dev.GenerateOutput
 	}
 
 	if generateURL != "" {
 		cfg.ServerURL = generateURL
 	} else {
-		host := viper.GetString("server.host")
-		if host == "" {
-			host = "localhost"
-		}
-		port := viper.GetInt("server.port")
-		if port == 0 {
-			port = 8080
-		}
-		cfg.ServerURL = fmt.Sprintf("http://%s:%d", host, port)
+		cfg.ServerURL = clientURL(appCfg)
 	}
 
 	if generatePkg != "" {
 		cfg.PackageName = generatePkg
 	}
 
+	cfg.Dates = generateDates
+	if cfg.Dates == "" {
+		cfg.Dates = appCfg.Dev.GenerateDates
+	}
+	if cfg.Dates != "" && cfg.Dates != "string" && cfg.Dates != "Date" {
+		return fmt.Errorf("invalid --dates %q: must be string or Date", cfg.Dates)
+	}
+
 	cfg.Languages = languages
 
 	log.Info().
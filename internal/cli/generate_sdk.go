@@ -9,7 +9,6 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/watzon/alyx/internal/openapi"
-	"github.com/watzon/alyx/internal/schema"
 	"github.com/watzon/alyx/internal/sdk/typescript"
 )
 
@@ -17,6 +16,7 @@ var (
 	sdkLang   string
 	sdkOutput string
 	sdkURL    string
+	sdkDates  string
 )
 
 var generateSDKCmd = &cobra.Command{
@@ -30,15 +30,21 @@ The SDK includes:
   - Hook helpers with event types and payload types
   - Runtime context helpers for function development
 
+Timestamp fields are typed as ISO strings by default. With --dates Date (or
+dev.generate_dates: Date in alyx.yaml) they are typed as Dates and the client
+converts them when it reads documents.
+
 Example:
-  alyx generate sdk --lang typescript --output ./sdk`,
+  alyx generate sdk --lang typescript --output ./sdk
+  alyx generate sdk --dates Date`,
 	RunE: runGenerateSDK,
 }
 
 func init() {
 	generateSDKCmd.Flags().StringVarP(&sdkLang, "lang", "l", "typescript", "SDK language (currently only typescript supported)")
 	generateSDKCmd.Flags().StringVarP(&sdkOutput, "output", "o", "./sdk", "Output directory for generated SDK")
-	generateSDKCmd.Flags().StringVarP(&sdkURL, "url", "u", "", "Server URL for client (default: http://localhost:8090)")
+	generateSDKCmd.Flags().StringVarP(&sdkURL, "url", "u", "", "Server URL for client (default: the configured server address)")
+	generateSDKCmd.Flags().StringVar(&sdkDates, "dates", "", "Type of timestamp fields: string or Date (default: dev.generate_dates, else string)")
 
 	generateCmd.AddCommand(generateSDKCmd)
 }
@@ -60,33 +66,33 @@ func runGenerateSDK(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("resolving schema path: %w", err)
 	}
 
-	s, err := schema.ParseFile(absSchemaPath)
+	s, err := loadSchema(absSchemaPath)
 	if err != nil {
 		return fmt.Errorf("parsing schema: %w", err)
 	}
 
+	appCfg := generateConfig()
+
 	// Determine server URL
 	serverURL := sdkURL
 	if serverURL == "" {
-		host := viper.GetString("server.host")
-		if host == "" {
-			host = "localhost"
-		}
-		port := viper.GetInt("server.port")
-		if port == 0 {
-			port = 8090
-		}
-		serverURL = fmt.Sprintf("http://%s:%d", host, port)
+		serverURL = clientURL(appCfg)
 	}
 
 	// Generate OpenAPI spec
 	spec := openapi.Generate(s, openapi.GeneratorConfig{
 		Title:       "Alyx API",
 		Description: "Generated API for Alyx Backend-as-a-Service",
-		Version:     "1.0.0",
+		Version:     appCfg.Docs.Version,
 		ServerURL:   serverURL,
+		ErrorFormat: appCfg.Server.ErrorFormat,
 	})
 
+	dates := sdkDates
+	if dates == "" {
+		dates = appCfg.Dev.GenerateDates
+	}
+
 	// Resolve output directory
 	outputDir, err := filepath.Abs(sdkOutput)
 	if err != nil {
@@ -103,6 +109,7 @@ func runGenerateSDK(cmd *cobra.Command, args []string) error {
 	generator := typescript.NewGenerator(typescript.Config{
 		OutputDir: outputDir,
 		ServerURL: serverURL,
+		Dates:     dates,
 	})
 
 	if err := generator.Generate(spec, s); err != nil {
@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/watzon/alyx/internal/codegen"
@@ -129,3 +131,26 @@ func TestLanguageStrings(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateConfigOverlay(t *testing.T) {
+	dir := t.TempDir()
+	base := "server:\n  port: 9000\ndev:\n  generate_dates: string\n"
+	overlay := "server:\n  port: 9100\ndev:\n  generate_dates: Date\n"
+	if err := os.WriteFile(filepath.Join(dir, "alyx.yaml"), []byte(base), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "alyx.staging.yaml"), []byte(overlay), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfgFile, envName = filepath.Join(dir, "alyx.yaml"), "staging"
+	t.Cleanup(func() { cfgFile, envName = "", "" })
+
+	cfg := generateConfig()
+	if got := clientURL(cfg); got != "http://localhost:9100" {
+		t.Errorf("clientURL = %q, want the overlay's port", got)
+	}
+	if cfg.Dev.GenerateDates != "Date" {
+		t.Errorf("GenerateDates = %q, want the overlay's Date", cfg.Dev.GenerateDates)
+	}
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/watzon/alyx/internal/openapi"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/sdk/typescript"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+// generateTemplateOutputs runs every generator on a template's schema and
+// returns the outputs keyed by path relative to the template's golden
+// directory.
+func generateTemplateOutputs(t *testing.T, tmpl *Template) map[string][]byte {
+	t.Helper()
+
+	s, err := schema.Parse([]byte(tmpl.Files["schema.yaml"]))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+
+	outputs := make(map[string][]byte)
+	dir := t.TempDir()
+
+	schemaPath := filepath.Join(dir, "schema.yaml")
+	if err := schema.WriteFile(schemaPath, s); err != nil {
+		t.Fatalf("write schema: %v", err)
+	}
+	data, err := os.ReadFile(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs["schema.yaml"] = data
+
+	spec := openapi.Generate(s, openapi.GeneratorConfig{
+		Title:     "Alyx API",
+		Version:   "1.0.0",
+		ServerURL: "http://localhost:8090",
+	})
+	data, err = spec.JSON()
+	if err != nil {
+		t.Fatalf("encode OpenAPI spec: %v", err)
+	}
+	outputs["openapi.json"] = data
+
+	sdkDir := filepath.Join(dir, "sdk")
+	generator := typescript.NewGenerator(typescript.Config{OutputDir: sdkDir, ServerURL: "http://localhost:8090", Version: "1.0.0"})
+	if err := generator.Generate(spec, s); err != nil {
+		t.Fatalf("generate SDK: %v", err)
+	}
+	for path, data := range readTree(t, sdkDir) {
+		outputs[filepath.Join("sdk", path)] = data
+	}
+
+	// Only the collection types and resource depend on the dates setting.
+	datesDir := filepath.Join(dir, "sdk-dates")
+	generator = typescript.NewGenerator(typescript.Config{OutputDir: datesDir, ServerURL: "http://localhost:8090", Dates: typescript.DatesDate})
+	if err := generator.Generate(spec, s); err != nil {
+		t.Fatalf("generate SDK with Date timestamps: %v", err)
+	}
+	for _, path := range []string{"types/collections.ts", "resources/collections.ts"} {
+		data, err := os.ReadFile(filepath.Join(datesDir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs[filepath.Join("sdk-dates", path)] = data
+	}
+
+	return outputs
+}
+
+func readTree(t *testing.T, root string) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("read %s: %v", root, err)
+	}
+	return files
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TestTemplateGoldenOutput checks that the schema writer, OpenAPI generator
+// and TypeScript SDK generator produce byte-identical output across runs for
+// the init templates. Run with -update after an intended output change.
+func TestTemplateGoldenOutput(t *testing.T) {
+	for name, tmpl := range getTemplates() {
+		t.Run(name, func(t *testing.T) {
+			outputs := generateTemplateOutputs(t, tmpl)
+
+			for i := 0; i < 5; i++ {
+				again := generateTemplateOutputs(t, tmpl)
+				for _, path := range sortedKeys(outputs) {
+					if !bytes.Equal(outputs[path], again[path]) {
+						t.Fatalf("%s differs between runs", path)
+					}
+				}
+				if len(again) != len(outputs) {
+					t.Fatalf("generated %d files, then %d", len(outputs), len(again))
+				}
+			}
+
+			written, err := schema.Parse(outputs["schema.yaml"])
+			if err != nil {
+				t.Fatalf("parse written schema: %v", err)
+			}
+			if changes := schema.NewDiffer().Diff(written, mustParse(t, tmpl.Files["schema.yaml"])); len(changes) > 0 {
+				t.Errorf("written schema differs from template: %v", changes)
+			}
+
+			goldenDir := filepath.Join("testdata", "golden", name)
+			if *updateGolden {
+				if err := os.RemoveAll(goldenDir); err != nil {
+					t.Fatal(err)
+				}
+				for path, data := range outputs {
+					dst := filepath.Join(goldenDir, filepath.FromSlash(path))
+					if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(dst, data, 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return
+			}
+
+			golden := readTree(t, goldenDir)
+			for _, path := range sortedKeys(outputs) {
+				want, ok := golden[path]
+				if !ok {
+					t.Errorf("%s: missing golden file (run go test ./internal/cli -run TestTemplateGoldenOutput -update)", path)
+					continue
+				}
+				if !bytes.Equal(outputs[path], want) {
+					t.Errorf("%s: output does not match golden file (run go test ./internal/cli -run TestTemplateGoldenOutput -update)", path)
+				}
+			}
+			for _, path := range sortedKeys(golden) {
+				if _, ok := outputs[path]; !ok {
+					t.Errorf("%s: golden file is no longer generated", path)
+				}
+			}
+		})
+	}
+}
+
+func mustParse(t *testing.T, data string) *schema.Schema {
+	t.Helper()
+	s, err := schema.Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	return s
+}
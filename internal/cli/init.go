@@ -1,10 +1,12 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"text/template"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -13,6 +15,8 @@ import (
 var (
 	initTemplate string
 	initForce    bool
+	initDocker   bool
+	initYes      bool
 )
 
 var initCmd = &cobra.Command{
@@ -29,7 +33,11 @@ Creates the project directory structure with:
 Templates:
   basic   Minimal starter with a single collection (default)
   blog    Blog application with posts, users, and comments
-  saas    SaaS starter with organizations and members`,
+  saas    SaaS starter with organizations and members
+
+When run in a terminal without --template, init asks for the template, project
+name, port and features, and writes a random JWT secret to .env. Pass --yes to
+skip the questions and use the defaults.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runInit,
 }
@@ -37,6 +45,8 @@ Templates:
 func init() {
 	initCmd.Flags().StringVarP(&initTemplate, "template", "t", "basic", "Project template (basic, blog, saas)")
 	initCmd.Flags().BoolVarP(&initForce, "force", "f", false, "Overwrite existing files")
+	initCmd.Flags().BoolVar(&initDocker, "with-docker", false, "Also write a Dockerfile, docker-compose.yaml, and .dockerignore")
+	initCmd.Flags().BoolVarP(&initYes, "yes", "y", false, "Use the defaults without asking any questions")
 
 	rootCmd.AddCommand(initCmd)
 }
@@ -47,7 +57,24 @@ func runInit(cmd *cobra.Command, args []string) error {
 		projectDir = args[0]
 	}
 
-	tmpl, err := validateTemplate(initTemplate)
+	templateName := initTemplate
+	values := defaultTemplateValues()
+	var jwtSecret string
+	if !initYes && !cmd.Flags().Changed("template") && isTerminal(os.Stdin) {
+		answers, err := runInitWizard(newLinePrompter(os.Stdin, cmd.OutOrStdout()), projectName(projectDir))
+		if err != nil {
+			return err
+		}
+		templateName = answers.Template
+		values = answers.Values
+
+		jwtSecret, err = generateJWTSecret()
+		if err != nil {
+			return err
+		}
+	}
+
+	tmpl, err := validateTemplate(templateName)
 	if err != nil {
 		return err
 	}
@@ -55,12 +82,17 @@ func runInit(cmd *cobra.Command, args []string) error {
 	if err := prepareProjectDir(projectDir, initForce); err != nil {
 		return err
 	}
+	if jwtSecret != "" && !initForce {
+		if _, err := os.Stat(filepath.Join(projectDir, ".env")); err == nil {
+			return errors.New("files already exist: .env (use --force to overwrite)")
+		}
+	}
 
 	if err := createProjectStructure(projectDir); err != nil {
 		return err
 	}
 
-	if err := writeTemplateFiles(projectDir, tmpl); err != nil {
+	if err := writeTemplateFiles(projectDir, tmpl, values); err != nil {
 		return err
 	}
 
@@ -68,7 +100,19 @@ func runInit(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	printSuccessMessage(projectDir, initTemplate)
+	if jwtSecret != "" {
+		if err := writeEnvFile(projectDir, jwtSecret); err != nil {
+			return err
+		}
+	}
+
+	if initDocker {
+		if err := writeDockerFiles(projectDir, tmpl, initForce); err != nil {
+			return err
+		}
+	}
+
+	printSuccessMessage(projectDir, templateName, jwtSecret != "")
 	return nil
 }
 
@@ -109,8 +153,12 @@ func createProjectStructure(projectDir string) error {
 	return nil
 }
 
-func writeTemplateFiles(projectDir string, tmpl *Template) error {
-	for filename, content := range tmpl.Files {
+func writeTemplateFiles(projectDir string, tmpl *Template, values templateValues) error {
+	files, err := tmpl.Render(values)
+	if err != nil {
+		return err
+	}
+	for filename, content := range files {
 		if err := writeTemplateFile(projectDir, filename, content); err != nil {
 			return err
 		}
@@ -158,7 +206,18 @@ generated/
 	return nil
 }
 
-func printSuccessMessage(projectDir, templateName string) {
+// writeEnvFile writes a .env file setting JWT_SECRET, which the templates'
+// alyx.yaml and the generated docker-compose.yaml read.
+func writeEnvFile(projectDir, jwtSecret string) error {
+	content := "# Secrets for local development. Do not commit this file.\nJWT_SECRET=" + jwtSecret + "\n"
+	if err := os.WriteFile(filepath.Join(projectDir, ".env"), []byte(content), 0o600); err != nil {
+		return fmt.Errorf("writing .env: %w", err)
+	}
+	log.Info().Str("file", ".env").Msg("Created")
+	return nil
+}
+
+func printSuccessMessage(projectDir, templateName string, wroteEnv bool) {
 	fmt.Println()
 	fmt.Printf("✓ Project initialized with %q template\n", templateName)
 	fmt.Println()
@@ -166,6 +225,9 @@ func printSuccessMessage(projectDir, templateName string) {
 	if projectDir != "." {
 		fmt.Printf("  cd %s\n", projectDir)
 	}
+	if wroteEnv {
+		fmt.Println("  set -a; . ./.env; set +a    # Load JWT_SECRET into the environment")
+	}
 	fmt.Println("  alyx dev    # Start the development server")
 	fmt.Println()
 }
@@ -181,13 +243,45 @@ func checkExistingFiles(dir string) []string {
 	return existing
 }
 
-// Template represents a project template.
+// Template represents a project template. Its files are text/template
+// sources rendered with templateValues.
 type Template struct {
 	Name        string
 	Description string
 	Files       map[string]string
 }
 
+// templateValues are substituted into a template's files. An empty Title
+// keeps the template's own API docs title.
+type templateValues struct {
+	Title     string
+	Port      int
+	Functions bool
+	Realtime  bool
+}
+
+// defaultTemplateValues are the values init uses without the wizard.
+func defaultTemplateValues() templateValues {
+	return templateValues{Port: 8090, Functions: true, Realtime: true}
+}
+
+// Render returns the template's files with values substituted.
+func (t *Template) Render(values templateValues) (map[string]string, error) {
+	files := make(map[string]string, len(t.Files))
+	for filename, content := range t.Files {
+		tpl, err := template.New(filename).Option("missingkey=error").Parse(content)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template file %s: %w", filename, err)
+		}
+		var buf strings.Builder
+		if err := tpl.Execute(&buf, values); err != nil {
+			return nil, fmt.Errorf("rendering template file %s: %w", filename, err)
+		}
+		files[filename] = buf.String()
+	}
+	return files, nil
+}
+
 func getTemplates() map[string]*Template {
 	return map[string]*Template{
 		"basic": {
@@ -235,12 +329,13 @@ server:
   host: localhost
   
   # Port to listen on
-  port: 8090
+  port: {{.Port}}
   
   # CORS (Cross-Origin Resource Sharing) settings
   cors:
     enabled: true
     allowed_origins: ["*"]
+    # allowed_origin_patterns: ["https://*.example.com"]
     # allowed_methods: ["GET", "POST", "PATCH", "DELETE", "OPTIONS"]
     # allowed_headers: ["Content-Type", "Authorization"]
     # exposed_headers: []
@@ -252,8 +347,11 @@ server:
   # write_timeout: 30s
   # idle_timeout: 120s
   
-  # Maximum request body size in bytes (default: 10MB)
-  # max_body_size: 10485760
+  # Maximum request body size in bytes (default: 1MB)
+  # max_body_size: 1048576
+
+  # Maximum upload size in bytes (default: 100MB)
+  # max_upload_size: 104857600
   
   # Enable embedded admin UI (coming soon)
   # admin_ui: true
@@ -362,7 +460,7 @@ auth:
 # Serverless Functions Configuration
 # -----------------------------------------------------------------------------
 functions:
-  enabled: true
+  enabled: {{.Functions}}
   
   # Path to functions directory
   path: ./functions
@@ -398,7 +496,7 @@ functions:
 # Real-time Subscriptions Configuration
 # -----------------------------------------------------------------------------
 realtime:
-  enabled: true
+  enabled: {{.Realtime}}
   
   # How often to poll for changes (lower = faster updates, more CPU)
   poll_interval: 50ms
@@ -415,6 +513,14 @@ realtime:
   # Cleanup settings for processed changes
   # cleanup_interval: 5m
   # cleanup_age: 1h
+  
+  # Keepalive: ping clients and disconnect those missing pongs
+  # ping_interval: 30s
+  # max_missed_pongs: 2
+  
+  # Disconnect clients that cannot keep up with their updates
+  # write_timeout: 10s
+  # slow_client_timeout: 5s
 
 # -----------------------------------------------------------------------------
 # API Documentation Configuration
@@ -426,9 +532,9 @@ docs:
   ui: scalar
   
   # API info
-  # title: My API
+  {{with .Title}}title: {{.}}{{else}}# title: My API{{end}}
   # description: API documentation
-  # version: 1.0.0
+  # version: 1.0.0 (defaults to the Alyx version)
 
 # -----------------------------------------------------------------------------
 # Logging Configuration
@@ -470,6 +576,9 @@ dev:
   
   # Output directory for generated clients
   # generate_output: ./generated
+
+  # TypeScript type of timestamp fields: string (ISO strings) or Date
+  # generate_dates: string
 `
 
 const basicSchemaYAML = `# =============================================================================
@@ -491,13 +600,11 @@ collections:
   # Items Collection - A simple example to get started
   # ---------------------------------------------------------------------------
   items:
+    # Built-in field presets: an auto-generated 15-character "id" primary
+    # key, and "created_at"/"updated_at" timestamps managed for you
+    use: [id, timestamps]
+
     fields:
-      # Primary key - auto-generated 15-character ID
-      id:
-        type: id
-        primary: true
-        default: auto
-      
       # Required string field with max length
       name:
         type: string
@@ -507,15 +614,6 @@ collections:
       description:
         type: text
         nullable: true
-      
-      # Auto-managed timestamps
-      created_at:
-        type: timestamp
-        default: now
-      updated_at:
-        type: timestamp
-        default: now
-        onUpdate: now
 
     # Access control rules using CEL (Common Expression Language)
     # Available variables:
@@ -708,7 +806,7 @@ const blogConfigYAML = `# ======================================================
 
 server:
   host: localhost
-  port: 8090
+  port: {{.Port}}
   cors:
     enabled: true
     allowed_origins: ["*"]
@@ -733,18 +831,18 @@ auth:
   #     scopes: [user:email]
 
 functions:
-  enabled: true
+  enabled: {{.Functions}}
   path: ./functions
   runtime: docker
 
 realtime:
-  enabled: true
+  enabled: {{.Realtime}}
   poll_interval: 50ms
 
 docs:
   enabled: true
   ui: scalar
-  title: Blog API
+  title: {{or .Title "Blog API"}}
   description: Blog backend powered by Alyx
 
 dev:
@@ -767,11 +865,8 @@ collections:
   # Users - Blog authors and readers
   # ---------------------------------------------------------------------------
   users:
+    use: [id, timestamps]
     fields:
-      id:
-        type: id
-        primary: true
-        default: auto
       email:
         type: string
         unique: true
@@ -790,13 +885,6 @@ collections:
         default: "user"
         validate:
           enum: [user, author, admin]
-      created_at:
-        type: timestamp
-        default: now
-      updated_at:
-        type: timestamp
-        default: now
-        onUpdate: now
 
     # Access rules:
     # - Anyone can register (create)
@@ -813,19 +901,22 @@ collections:
   # Posts - Blog articles
   # ---------------------------------------------------------------------------
   posts:
+    use: [id, timestamps]
     fields:
-      id:
-        type: id
-        primary: true
-        default: auto
       title:
         type: string
         minLength: 1
         maxLength: 200
+      # Generated from the title when omitted ("Hello, World!" -> hello-world),
+      # with -2, -3, ... appended if taken. Send regenerate_slug: true on
+      # update to derive it again from a new title.
       slug:
         type: string
         unique: true
         index: true
+        slug:
+          from: title
+          maxLength: 80
       content:
         type: text
       excerpt:
@@ -846,16 +937,10 @@ collections:
       tags:
         type: json
         nullable: true
+        tsType: string[]
       view_count:
         type: int
         default: 0
-      created_at:
-        type: timestamp
-        default: now
-      updated_at:
-        type: timestamp
-        default: now
-        onUpdate: now
 
     # Composite indexes for common query patterns
     indexes:
@@ -880,11 +965,8 @@ collections:
   # Comments - User comments on posts
   # ---------------------------------------------------------------------------
   comments:
+    use: [id]
     fields:
-      id:
-        type: id
-        primary: true
-        default: auto
       post_id:
         type: uuid
         references: posts.id
@@ -963,7 +1045,7 @@ const saasConfigYAML = `# ======================================================
 
 server:
   host: localhost
-  port: 8090
+  port: {{.Port}}
   cors:
     enabled: true
     allowed_origins: ["*"]
@@ -992,18 +1074,18 @@ auth:
   #     scopes: [email, profile]
 
 functions:
-  enabled: true
+  enabled: {{.Functions}}
   path: ./functions
   runtime: docker
 
 realtime:
-  enabled: true
+  enabled: {{.Realtime}}
   poll_interval: 50ms
 
 docs:
   enabled: true
   ui: scalar
-  title: SaaS API
+  title: {{or .Title "SaaS API"}}
   description: SaaS backend powered by Alyx
 
 dev:
@@ -1026,11 +1108,8 @@ collections:
   # Users - Individual user accounts
   # ---------------------------------------------------------------------------
   users:
+    use: [id, timestamps]
     fields:
-      id:
-        type: id
-        primary: true
-        default: auto
       email:
         type: string
         unique: true
@@ -1044,13 +1123,6 @@ collections:
       avatar_url:
         type: string
         nullable: true
-      created_at:
-        type: timestamp
-        default: now
-      updated_at:
-        type: timestamp
-        default: now
-        onUpdate: now
 
     # Users can only access their own data
     rules:
@@ -1063,11 +1135,8 @@ collections:
   # Organizations - Teams/companies (tenants)
   # ---------------------------------------------------------------------------
   organizations:
+    use: [id, timestamps]
     fields:
-      id:
-        type: id
-        primary: true
-        default: auto
       name:
         type: string
         maxLength: 100
@@ -1087,13 +1156,6 @@ collections:
       settings:
         type: json
         nullable: true
-      created_at:
-        type: timestamp
-        default: now
-      updated_at:
-        type: timestamp
-        default: now
-        onUpdate: now
 
     # Only owner can manage organization
     # Note: In production, you'd check membership for read access
@@ -1107,11 +1169,8 @@ collections:
   # Members - Organization memberships (many-to-many)
   # ---------------------------------------------------------------------------
   members:
+    use: [id]
     fields:
-      id:
-        type: id
-        primary: true
-        default: auto
       org_id:
         type: uuid
         references: organizations.id
@@ -1154,11 +1213,8 @@ collections:
   # Invitations - Pending team invites
   # ---------------------------------------------------------------------------
   invitations:
+    use: [id]
     fields:
-      id:
-        type: id
-        primary: true
-        default: auto
       org_id:
         type: uuid
         references: organizations.id
@@ -1199,6 +1255,7 @@ collections:
 # =============================================================================
 #
 # projects:
+#   tenant: { field: org_id, source: auth.metadata.org_id }  # Scope to the user's org
 #   fields:
 #     id: { type: id, primary: true, default: auto }
 #     org_id: { type: uuid, references: organizations.id, onDelete: cascade, index: true }
@@ -3,7 +3,14 @@ package cli
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
 )
 
 func TestValidateTemplate(t *testing.T) {
@@ -175,7 +182,7 @@ func TestWriteTemplateFiles(t *testing.T) {
 		},
 	}
 
-	if err := writeTemplateFiles(tmpDir, tmpl); err != nil {
+	if err := writeTemplateFiles(tmpDir, tmpl, defaultTemplateValues()); err != nil {
 		t.Fatalf("writeTemplateFiles() failed: %v", err)
 	}
 
@@ -268,6 +275,62 @@ func TestGetTemplates(t *testing.T) {
 	}
 }
 
+// TestTemplateSchemaRoundTrip checks that applying each template's schema
+// and inferring it back from the database loses nothing the differ or the
+// admin draft preview could report as a change.
+func TestTemplateSchemaRoundTrip(t *testing.T) {
+	for name, tmpl := range getTemplates() {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := schema.Parse([]byte(tmpl.Files["schema.yaml"]))
+			if err != nil {
+				t.Fatalf("parse schema: %v", err)
+			}
+
+			db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+			if err != nil {
+				t.Fatalf("open database: %v", err)
+			}
+			defer db.Close()
+
+			migrator := schema.NewMigrator(db.DB, "", "")
+			if err := migrator.Init(); err != nil {
+				t.Fatalf("init migrator: %v", err)
+			}
+			if err := migrator.ApplySchema(parsed); err != nil {
+				t.Fatalf("apply schema: %v", err)
+			}
+
+			inferred, err := schema.InferFromDB(db.DB)
+			if err != nil {
+				t.Fatalf("infer schema: %v", err)
+			}
+
+			for _, change := range schema.NewDiffer().Diff(inferred, parsed) {
+				t.Errorf("unexpected change: %s (%s)", change, change.Description)
+			}
+
+			for colName, want := range parsed.Collections {
+				got := inferred.Collections[colName]
+				if got == nil {
+					t.Errorf("collection %s was not inferred", colName)
+					continue
+				}
+				for fieldName, wantField := range want.Fields {
+					if !reflect.DeepEqual(got.Fields[fieldName], wantField) {
+						t.Errorf("%s.%s: inferred %+v, want %+v", colName, fieldName, got.Fields[fieldName], wantField)
+					}
+				}
+				if len(got.Indexes) != len(want.Indexes) {
+					t.Errorf("%s: inferred %d indexes, want %d", colName, len(got.Indexes), len(want.Indexes))
+				}
+				if !reflect.DeepEqual(got.Rules, want.Rules) || !reflect.DeepEqual(got.Retention, want.Retention) || !reflect.DeepEqual(got.Docs, want.Docs) {
+					t.Errorf("%s: collection config was not round-tripped", colName)
+				}
+			}
+		})
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || findSubstring(s, substr))
 }
@@ -280,3 +343,144 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+// scriptedPrompter answers the init wizard from a fixed list of answers,
+// with "" taking the default.
+type scriptedPrompter struct {
+	t       *testing.T
+	answers []string
+}
+
+func (p *scriptedPrompter) next() string {
+	p.t.Helper()
+	if len(p.answers) == 0 {
+		p.t.Fatal("wizard asked more questions than scripted")
+	}
+	answer := p.answers[0]
+	p.answers = p.answers[1:]
+	return answer
+}
+
+func (p *scriptedPrompter) Select(_ string, options []promptOption, def string) (string, error) {
+	answer := p.next()
+	if answer == "" {
+		return def, nil
+	}
+	for _, o := range options {
+		if o.Value == answer {
+			return answer, nil
+		}
+	}
+	p.t.Fatalf("%q is not an option", answer)
+	return "", nil
+}
+
+func (p *scriptedPrompter) Input(_, def string, validate func(string) error) (string, error) {
+	for {
+		answer := p.next()
+		if answer == "" {
+			answer = def
+		}
+		if validate(answer) == nil {
+			return answer, nil
+		}
+	}
+}
+
+func (p *scriptedPrompter) Confirm(_ string, def bool) (bool, error) {
+	switch p.next() {
+	case "y":
+		return true, nil
+	case "n":
+		return false, nil
+	}
+	return def, nil
+}
+
+func TestRunInitWizard(t *testing.T) {
+	p := &scriptedPrompter{t: t, answers: []string{"blog", "not: valid", "Acme Blog", "99999", "3000", "n", ""}}
+	answers, err := runInitWizard(p, "acme")
+	if err != nil {
+		t.Fatalf("runInitWizard() error = %v", err)
+	}
+	if len(p.answers) != 0 {
+		t.Errorf("wizard left %d answers unused", len(p.answers))
+	}
+
+	want := templateValues{Title: "Acme Blog", Port: 3000, Functions: false, Realtime: true}
+	if answers.Template != "blog" || answers.Values != want {
+		t.Fatalf("answers = %+v, want blog with %+v", answers, want)
+	}
+
+	files, err := getTemplates()["blog"].Render(answers.Values)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	cfg := loadRenderedConfig(t, files["alyx.yaml"])
+	if cfg.Server.Port != 3000 || cfg.Functions.Enabled || !cfg.Realtime.Enabled || cfg.Docs.Title != "Acme Blog" {
+		t.Errorf("rendered config = port %d, functions %v, realtime %v, title %q",
+			cfg.Server.Port, cfg.Functions.Enabled, cfg.Realtime.Enabled, cfg.Docs.Title)
+	}
+}
+
+func TestTemplateRenderDefaults(t *testing.T) {
+	titles := map[string]string{"basic": "Alyx API", "blog": "Blog API", "saas": "SaaS API"}
+	for name, tmpl := range getTemplates() {
+		files, err := tmpl.Render(defaultTemplateValues())
+		if err != nil {
+			t.Fatalf("%s: Render() error = %v", name, err)
+		}
+		if files["schema.yaml"] != tmpl.Files["schema.yaml"] {
+			t.Errorf("%s: rendering changed schema.yaml", name)
+		}
+		cfg := loadRenderedConfig(t, files["alyx.yaml"])
+		if cfg.Server.Port != 8090 || !cfg.Functions.Enabled || !cfg.Realtime.Enabled || cfg.Docs.Title != titles[name] {
+			t.Errorf("%s: rendered config = port %d, functions %v, realtime %v, title %q",
+				name, cfg.Server.Port, cfg.Functions.Enabled, cfg.Realtime.Enabled, cfg.Docs.Title)
+		}
+	}
+}
+
+func loadRenderedConfig(t *testing.T, content string) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "alyx.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("load rendered config: %v", err)
+	}
+	return cfg
+}
+
+func TestLinePrompter(t *testing.T) {
+	var out strings.Builder
+	p := newLinePrompter(strings.NewReader("7\nsaas\n\nabc\n42\nmaybe\nno\n"), &out)
+
+	options := []promptOption{{Value: "basic"}, {Value: "saas"}}
+	if got, err := p.Select("Template", options, "basic"); err != nil || got != "saas" {
+		t.Errorf("Select() = %q, %v; want saas after an out-of-range answer", got, err)
+	}
+
+	isNumber := func(s string) error {
+		_, err := strconv.Atoi(s)
+		return err
+	}
+	if got, err := p.Input("Port", "8090", isNumber); err != nil || got != "8090" {
+		t.Errorf("Input() = %q, %v; want the default", got, err)
+	}
+	if got, err := p.Input("Port", "8090", isNumber); err != nil || got != "42" {
+		t.Errorf("Input() = %q, %v; want 42 after an invalid answer", got, err)
+	}
+	if got, err := p.Confirm("Enable?", true); err != nil || got {
+		t.Errorf("Confirm() = %v, %v; want false after an unclear answer", got, err)
+	}
+	if _, err := p.Confirm("Enable?", true); err == nil {
+		t.Error("Confirm() at end of input: expected error")
+	}
+
+	if !strings.Contains(out.String(), "Invalid answer") || !strings.Contains(out.String(), "Answer y or n.") {
+		t.Errorf("prompter did not explain rejected answers:\n%s", out.String())
+	}
+}
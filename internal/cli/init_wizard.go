@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// prompter asks the questions of the init wizard. Each method returns def
+// when the answer is empty.
+type prompter interface {
+	// Select asks for one of options, returning its value.
+	Select(question string, options []promptOption, def string) (string, error)
+	// Input asks for a line of text, asking again while validate rejects
+	// the answer.
+	Input(question, def string, validate func(string) error) (string, error)
+	// Confirm asks a yes/no question.
+	Confirm(question string, def bool) (bool, error)
+}
+
+// promptOption is a choice offered by prompter.Select.
+type promptOption struct {
+	Value       string
+	Description string
+}
+
+// initAnswers are the answers collected by the init wizard.
+type initAnswers struct {
+	Template string
+	Values   templateValues
+}
+
+// projectNameRegex limits project names to ones that are safe unquoted in
+// YAML.
+var projectNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]*$`)
+
+// runInitWizard asks which template to use and how to configure it. name is
+// the default project name.
+func runInitWizard(p prompter, name string) (*initAnswers, error) {
+	templates := getTemplates()
+	names := make([]string, 0, len(templates))
+	for n := range templates {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	options := make([]promptOption, 0, len(names))
+	for _, n := range names {
+		options = append(options, promptOption{Value: n, Description: templates[n].Description})
+	}
+
+	answers := &initAnswers{Values: defaultTemplateValues()}
+
+	var err error
+	if answers.Template, err = p.Select("Template", options, "basic"); err != nil {
+		return nil, err
+	}
+
+	answers.Values.Title, err = p.Input("Project name", name, func(s string) error {
+		if !projectNameRegex.MatchString(s) {
+			return errors.New("use letters, digits, spaces, '.', '_' and '-', starting with a letter or digit")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := p.Input("Port", strconv.Itoa(answers.Values.Port), func(s string) error {
+		if n, err := strconv.Atoi(s); err != nil || n < 1 || n > 65535 {
+			return errors.New("enter a port between 1 and 65535")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	answers.Values.Port, _ = strconv.Atoi(port)
+
+	if answers.Values.Functions, err = p.Confirm("Enable serverless functions (requires Docker or Podman)?", true); err != nil {
+		return nil, err
+	}
+	if answers.Values.Realtime, err = p.Confirm("Enable realtime subscriptions?", true); err != nil {
+		return nil, err
+	}
+
+	return answers, nil
+}
+
+// projectName returns the default project name for projectDir: the name of
+// the directory, if it is a valid project name.
+func projectName(projectDir string) string {
+	abs, err := filepath.Abs(projectDir)
+	if err != nil {
+		return ""
+	}
+	if name := filepath.Base(abs); projectNameRegex.MatchString(name) {
+		return name
+	}
+	return ""
+}
+
+// generateJWTSecret returns a random JWT signing secret.
+func generateJWTSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating JWT secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// linePrompter is a prompter reading answers a line at a time.
+type linePrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newLinePrompter(in io.Reader, out io.Writer) *linePrompter {
+	return &linePrompter{in: bufio.NewReader(in), out: out}
+}
+
+// readLine reads the next answer, trimmed. It fails at the end of input
+// unless a final unterminated line was read.
+func (p *linePrompter) readLine() (string, error) {
+	line, err := p.in.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		return "", errors.New("init cancelled: no answer given")
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func (p *linePrompter) Select(question string, options []promptOption, def string) (string, error) {
+	fmt.Fprintf(p.out, "%s:\n", question)
+	for i, o := range options {
+		fmt.Fprintf(p.out, "  %d) %-8s %s\n", i+1, o.Value, o.Description)
+	}
+	for {
+		fmt.Fprintf(p.out, "Choose [%s]: ", def)
+		answer, err := p.readLine()
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			return def, nil
+		}
+		for i, o := range options {
+			if answer == o.Value || answer == strconv.Itoa(i+1) {
+				return o.Value, nil
+			}
+		}
+		fmt.Fprintf(p.out, "Choose a number between 1 and %d or a name.\n", len(options))
+	}
+}
+
+func (p *linePrompter) Input(question, def string, validate func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", question)
+		}
+		answer, err := p.readLine()
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			answer = def
+		}
+		if err := validate(answer); err != nil {
+			fmt.Fprintf(p.out, "Invalid answer: %v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+func (p *linePrompter) Confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		fmt.Fprintf(p.out, "%s [%s] ", question, hint)
+		answer, err := p.readLine()
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(p.out, "Answer y or n.")
+	}
+}
@@ -15,6 +15,7 @@ import (
 var (
 	migrateSchemaPath     string
 	migrateMigrationsPath string
+	migrateBackfill       bool
 )
 
 var migrateCmd = &cobra.Command{
@@ -49,7 +50,11 @@ This will:
   2. Apply safe schema changes from schema.yaml (additive only)
 
 For destructive changes (removing fields, changing types), create a
-migration file using 'alyx migrate create'.`,
+migration file using 'alyx migrate create'.
+
+Changing a field's default only affects new records, and a new counter
+starts from the target field's current values. Use --backfill to also set
+existing NULL values to the new default and to recount new counters.`,
 	RunE: runMigrateApply,
 }
 
@@ -82,6 +87,7 @@ Example:
 func init() {
 	migrateCmd.PersistentFlags().StringVar(&migrateSchemaPath, "schema", "", "Path to schema file (default: schema.yaml)")
 	migrateCmd.PersistentFlags().StringVar(&migrateMigrationsPath, "migrations", "migrations", "Path to migrations directory")
+	migrateApplyCmd.Flags().BoolVar(&migrateBackfill, "backfill", false, "Set existing NULL values to changed field defaults and recount new counters")
 
 	migrateCmd.AddCommand(migrateStatusCmd)
 	migrateCmd.AddCommand(migrateApplyCmd)
@@ -92,7 +98,7 @@ func init() {
 }
 
 func getMigrator() (*schema.Migrator, *database.DB, error) {
-	cfg, err := config.LoadWithDefaults()
+	cfg, err := loadConfig()
 	if err != nil {
 		log.Warn().Err(err).Msg("No config file found, using defaults")
 		cfg = config.Default()
@@ -210,7 +216,7 @@ func runMigrateApply(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	newSchema, err := schema.ParseFile(schemaPath)
+	newSchema, err := loadSchema(schemaPath)
 	if err != nil {
 		return fmt.Errorf("parsing schema: %w", err)
 	}
@@ -230,6 +236,10 @@ func runMigrateApply(cmd *cobra.Command, args []string) error {
 
 	fmt.Println("Applying schema changes...")
 	for _, c := range safeChanges {
+		switch c.Type {
+		case schema.ChangeModifyDefault, schema.ChangeAddCounter, schema.ChangeModifyCounter:
+			c.Backfill = migrateBackfill
+		}
 		fmt.Printf("  ✓ %s\n", c)
 	}
 
@@ -282,7 +292,7 @@ func runMigrateCreate(cmd *cobra.Command, args []string) error {
 }
 
 func checkSchemaChanges(db *database.DB, schemaPath string) ([]*schema.Change, error) {
-	newSchema, err := schema.ParseFile(schemaPath)
+	newSchema, err := loadSchema(schemaPath)
 	if err != nil {
 		return nil, fmt.Errorf("parsing schema: %w", err)
 	}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -8,11 +9,19 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/watzon/alyx/internal/buildinfo"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/schema"
 )
 
 var (
 	cfgFile string
+	envName string
 	verbose bool
+
+	showVersion bool
+	versionJSON bool
 )
 
 // rootCmd represents the base command when called without any subcommands.
@@ -36,6 +45,7 @@ Initialize a new project:
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		setupLogging()
 	},
+	RunE: runRoot,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -47,7 +57,60 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./alyx.yaml)")
+	rootCmd.PersistentFlags().StringVar(&envName, "env", "", "environment overlay to apply, e.g. production (default is $ALYX_ENV)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+
+	rootCmd.Flags().BoolVar(&showVersion, "version", false, "print version information and exit")
+	rootCmd.Flags().BoolVar(&versionJSON, "json", false, "with --version, print version information as JSON")
+}
+
+func runRoot(cmd *cobra.Command, args []string) error {
+	if !showVersion {
+		if versionJSON {
+			return fmt.Errorf("--json requires --version")
+		}
+		return cmd.Help()
+	}
+
+	// The features are those of the project's config, or the defaults
+	// outside a project.
+	cfg, err := loadConfig()
+	if err != nil {
+		cfg = config.Default()
+	}
+	info := buildinfo.Get(cfg.Features())
+
+	if versionJSON {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(data))
+		return nil
+	}
+	fmt.Fprint(cmd.OutOrStdout(), info)
+	return nil
+}
+
+// activeEnv returns the environment selected with --env, falling back to ALYX_ENV.
+func activeEnv() string {
+	if envName != "" {
+		return envName
+	}
+	return os.Getenv("ALYX_ENV")
+}
+
+// loadConfig loads alyx.yaml (or --config) with the active environment overlay applied.
+func loadConfig() (*config.Config, error) {
+	return config.Load(config.LoadOptions{
+		ConfigFile: cfgFile,
+		Env:        activeEnv(),
+	})
+}
+
+// loadSchema parses the schema at path with the active environment overlay applied.
+func loadSchema(path string) (*schema.Schema, error) {
+	return schema.ParseFileWithEnv(path, activeEnv())
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -95,5 +158,5 @@ func AddCommand(cmd *cobra.Command) {
 
 // Version returns the version string.
 func Version() string {
-	return fmt.Sprintf("alyx version %s", "0.1.0-dev")
+	return fmt.Sprintf("alyx version %s", buildinfo.Version)
 }
@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/schema/importers"
+)
+
+var (
+	schemaImportFrom     string
+	schemaImportInput    string
+	schemaImportDSN      string
+	schemaImportPGSchema string
+	schemaImportOutput   string
+	schemaImportForce    bool
+
+	schemaGraphFormat string
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Schema utilities",
+	Long: `Schema utilities for Alyx.
+
+Commands:
+  check   Validate the schema the way the server does at startup
+  import  Convert a schema from another system into schema.yaml
+  graph   Print the schema's entity-relationship graph`,
+}
+
+var schemaCheckCmd = &cobra.Command{
+	Use:   "check [path]",
+	Short: "Validate the schema the way the server does at startup",
+	Long: `Validate a schema file or directory without starting a server, for CI.
+
+Runs the same checks alyx dev runs before starting or applying a reloaded
+schema: the schema is parsed and validated, including hook source
+collections, schedule expressions and route paths, and every collection,
+field, bucket, view and function rule is compiled with the runtime CEL
+environment. Every problem is listed, and the command exits non-zero if
+there are any.
+
+One-time schedules whose time has passed are reported as warnings.
+
+Examples:
+  alyx schema check
+  alyx schema check schema/`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runSchemaCheck,
+}
+
+var schemaImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Convert a schema from another system into schema.yaml",
+	Long: `Convert an external schema definition into an Alyx schema.yaml.
+
+Sources:
+  pocketbase  A PocketBase collections export (Settings > Export collections),
+              read from --input
+  postgres    A live Postgres database, read from information_schema through
+              --dsn
+
+Types, primary keys, unique constraints, indexes and relations are mapped to
+the closest Alyx field config. Anything without an equivalent, such as views,
+check constraints or expression indexes, is left as a TODO comment at the top
+of the generated file. Review those before applying the schema.
+
+An existing output file is never overwritten unless --force is given.
+
+Examples:
+  alyx schema import --from pocketbase --input pb_schema.json
+  alyx schema import --from postgres --dsn postgres://localhost/app?sslmode=disable
+  alyx schema import --from postgres --dsn "$DATABASE_URL" --pg-schema app --output imported.yaml`,
+	RunE: runSchemaImport,
+}
+
+var schemaGraphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Print the schema's entity-relationship graph",
+	Long: `Print the entity-relationship graph of schema.yaml: collections and buckets
+as nodes, and references, relations and file fields as edges.
+
+The graph is built from the schema file alone, so it works before the schema
+has been migrated. The default format is Graphviz DOT; --format json prints
+the same graph the admin API serves at /api/admin/schema/graph, without row
+counts.
+
+Examples:
+  alyx schema graph | dot -Tsvg > schema.svg
+  alyx schema graph --format json`,
+	RunE: runSchemaGraph,
+}
+
+func init() {
+	schemaImportCmd.Flags().StringVar(&schemaImportFrom, "from", "", "Source system: pocketbase or postgres")
+	schemaImportCmd.Flags().StringVarP(&schemaImportInput, "input", "i", "", "PocketBase export file")
+	schemaImportCmd.Flags().StringVar(&schemaImportDSN, "dsn", "", "Postgres connection string")
+	schemaImportCmd.Flags().StringVar(&schemaImportPGSchema, "pg-schema", "public", "Postgres schema to import")
+	schemaImportCmd.Flags().StringVarP(&schemaImportOutput, "output", "o", "", "Output file (default: the configured schema path, or schema.yaml)")
+	schemaImportCmd.Flags().BoolVar(&schemaImportForce, "force", false, "Overwrite an existing output file")
+	_ = schemaImportCmd.MarkFlagRequired("from")
+
+	schemaGraphCmd.Flags().StringVarP(&schemaGraphFormat, "format", "f", "dot", "Output format: dot or json")
+
+	schemaCmd.AddCommand(schemaCheckCmd)
+	schemaCmd.AddCommand(schemaImportCmd)
+	schemaCmd.AddCommand(schemaGraphCmd)
+
+	rootCmd.AddCommand(schemaCmd)
+}
+
+func runSchemaImport(cmd *cobra.Command, args []string) error {
+	output := schemaImportOutput
+	if output == "" {
+		output = viper.GetString("schema")
+	}
+	if output == "" {
+		output = "schema.yaml"
+	}
+
+	if !schemaImportForce {
+		if _, err := os.Stat(output); err == nil {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", output)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("checking %s: %w", output, err)
+		}
+	}
+
+	var (
+		res *importers.Result
+		err error
+	)
+	switch schemaImportFrom {
+	case "pocketbase":
+		res, err = importPocketBase()
+	case "postgres":
+		res, err = importPostgres(cmd.Context())
+	default:
+		return fmt.Errorf("unknown source %q (expected pocketbase or postgres)", schemaImportFrom)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := res.YAML()
+	if err != nil {
+		return fmt.Errorf("encoding schema: %w", err)
+	}
+	if err := os.WriteFile(output, data, 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", output, err)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "  ✓ Imported %d collection(s) from %s into %s\n", len(res.Schema.Collections), res.Source, output)
+	if len(res.TODOs) > 0 {
+		fmt.Fprintf(out, "    %d item(s) need review; see the TODO comments at the top of the file\n", len(res.TODOs))
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Next steps:")
+	fmt.Fprintf(out, "  Review %s, then run alyx dev to apply it\n", output)
+	return nil
+}
+
+func importPocketBase() (*importers.Result, error) {
+	if schemaImportInput == "" {
+		return nil, fmt.Errorf("--input is required with --from pocketbase")
+	}
+	data, err := os.ReadFile(schemaImportInput)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", schemaImportInput, err)
+	}
+	return importers.FromPocketBase(data)
+}
+
+func importPostgres(ctx context.Context) (*importers.Result, error) {
+	if schemaImportDSN == "" {
+		return nil, fmt.Errorf("--dsn is required with --from postgres")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	db, err := sql.Open("postgres", schemaImportDSN)
+	if err != nil {
+		return nil, fmt.Errorf("opening Postgres connection: %w", err)
+	}
+	defer db.Close()
+
+	cat, err := importers.LoadPostgresCatalog(ctx, db, schemaImportPGSchema)
+	if err != nil {
+		return nil, err
+	}
+	return importers.FromPostgres(cat)
+}
+
+func runSchemaGraph(cmd *cobra.Command, args []string) error {
+	if schemaGraphFormat != "dot" && schemaGraphFormat != "json" {
+		return fmt.Errorf("unknown format %q (expected dot or json)", schemaGraphFormat)
+	}
+
+	schemaPath := viper.GetString("schema")
+	if schemaPath == "" {
+		schemaPath = "schema.yaml"
+	}
+	s, err := loadSchema(schemaPath)
+	if err != nil {
+		return fmt.Errorf("parsing schema: %w", err)
+	}
+
+	graph := schema.BuildGraph(s)
+	out := cmd.OutOrStdout()
+	if schemaGraphFormat == "dot" {
+		_, err := fmt.Fprint(out, graph.DOT())
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(graph)
+}
+
+func runSchemaCheck(cmd *cobra.Command, args []string) error {
+	schemaPath := viper.GetString("schema")
+	if len(args) > 0 {
+		schemaPath = args[0]
+	}
+	if schemaPath == "" {
+		schemaPath = resolveSchemaPath("")
+	}
+	if schemaPath == "" {
+		return errors.New("no schema file found; create schema.yaml, schema.yml, or a schema/ directory, or pass a path")
+	}
+
+	out := cmd.OutOrStdout()
+	s, err := loadSchema(schemaPath)
+	if err == nil {
+		err = rules.Check(s)
+	}
+	if errs := schema.AsValidationErrors(err); errs != nil {
+		for _, e := range errs {
+			fmt.Fprintf(out, "  ✗ %s\n", e.Error())
+		}
+		return fmt.Errorf("%s has %d problem(s)", schemaPath, len(errs))
+	}
+	if err != nil {
+		return fmt.Errorf("parsing schema: %w", err)
+	}
+
+	for _, path := range s.StaleSchedules(time.Now()) {
+		fmt.Fprintf(out, "  ! %s: one-time schedule is in the past and will not run\n", path)
+	}
+	fmt.Fprintf(out, "  ✓ %s is valid\n", schemaPath)
+	return nil
+}
+
+// checkSchema compiles every rule of s, as the server does, and warns about
+// one-time schedules that already passed.
+func checkSchema(s *schema.Schema) error {
+	for _, path := range s.StaleSchedules(time.Now()) {
+		log.Warn().Str("schedule", path).Msg("One-time schedule is in the past and will not run")
+	}
+	return rules.Check(s)
+}
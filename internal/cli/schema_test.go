@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// runSchemaImportCommand runs "alyx schema import ..." with output into dir.
+func runSchemaImportCommand(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+
+	schemaImportFrom, schemaImportInput, schemaImportDSN = "", "", ""
+	schemaImportPGSchema, schemaImportOutput, schemaImportForce = "public", "", false
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs(append([]string{"--config", filepath.Join(dir, "alyx.yaml"), "schema", "import",
+		"--output", filepath.Join(dir, "schema.yaml")}, args...))
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		cfgFile = ""
+	})
+
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestSchemaImportPocketBase(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join("..", "schema", "importers", "testdata", "pocketbase_v0.22.json")
+
+	out, err := runSchemaImportCommand(t, dir, "--from", "pocketbase", "--input", input)
+	if err != nil {
+		t.Fatalf("import: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Imported 2 collection(s) from PocketBase") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "schema.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# TODO: view collection post_stats") {
+		t.Errorf("schema.yaml is missing TODO comments:\n%s", data)
+	}
+	if _, err := schema.Parse(data); err != nil {
+		t.Fatalf("imported schema does not parse: %v", err)
+	}
+
+	if _, err := runSchemaImportCommand(t, dir, "--from", "pocketbase", "--input", input); err == nil ||
+		!strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected refusal to overwrite, got %v", err)
+	}
+	if _, err := runSchemaImportCommand(t, dir, "--from", "pocketbase", "--input", input, "--force"); err != nil {
+		t.Fatalf("import with --force: %v", err)
+	}
+}
+
+func TestSchemaImportFlags(t *testing.T) {
+	dir := t.TempDir()
+	tests := [][]string{
+		{"--from", "pocketbase"},
+		{"--from", "postgres"},
+		{"--from", "mysql"},
+	}
+	for _, args := range tests {
+		if _, err := runSchemaImportCommand(t, dir, args...); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "schema.yaml")); !os.IsNotExist(err) {
+		t.Errorf("schema.yaml was written on failure")
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.yaml")
+	run := func(content string) (string, error) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetErr(&out)
+		rootCmd.SetArgs([]string{"--config", filepath.Join(dir, "alyx.yaml"), "schema", "check", path})
+		t.Cleanup(func() {
+			rootCmd.SetOut(nil)
+			rootCmd.SetErr(nil)
+			rootCmd.SetArgs(nil)
+			cfgFile = ""
+		})
+		err := rootCmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run(`
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+    rules:
+      read: "true"
+      update: "doc.owner == usr.id"
+      delete: "auth.role =="
+`)
+	if err == nil || !strings.Contains(err.Error(), "2 problem(s)") {
+		t.Fatalf("expected two problems, got %v\n%s", err, out)
+	}
+	for _, path := range []string{"collections.posts.rules.update", "collections.posts.rules.delete"} {
+		if !strings.Contains(out, path) {
+			t.Errorf("expected %s in the report:\n%s", path, out)
+		}
+	}
+
+	out, err = run(`
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+    rules:
+      read: "true"
+`)
+	if err != nil || !strings.Contains(out, "is valid") {
+		t.Fatalf("expected a valid schema, got %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/watzon/alyx/internal/database"
+)
+
+var (
+	securityBatchSize  int
+	securityCollection string
+)
+
+var securityCmd = &cobra.Command{
+	Use:   "security",
+	Short: "Security utilities",
+	Long: `Security utilities for Alyx.
+
+Commands:
+  rotate-field-key  Re-encrypt encrypted fields with the current key`,
+}
+
+var securityRotateFieldKeyCmd = &cobra.Command{
+	Use:   "rotate-field-key",
+	Short: "Re-encrypt encrypted fields with the current key",
+	Long: `Re-encrypt every encrypted field value that is not yet encrypted with
+security.field_encryption_key. Values written before a field was marked
+encrypted are encrypted as well.
+
+To rotate the key:
+  1. Generate a new key: openssl rand -base64 32
+  2. Set it as security.field_encryption_key and move the old key to
+     security.previous_field_encryption_keys.
+  3. Restart the server, so new writes use the new key.
+  4. Run this command, then remove the old key from the config.
+
+Rows are rewritten in batches, one transaction per batch, so the server can
+keep running while the command works through large collections.
+
+Examples:
+  alyx security rotate-field-key
+  alyx security rotate-field-key --collection patients --batch-size 200`,
+	RunE: runSecurityRotateFieldKey,
+}
+
+func init() {
+	securityRotateFieldKeyCmd.Flags().IntVar(&securityBatchSize, "batch-size", 500, "Rows re-encrypted per transaction")
+	securityRotateFieldKeyCmd.Flags().StringVar(&securityCollection, "collection", "", "Only rotate this collection")
+
+	securityCmd.AddCommand(securityRotateFieldKeyCmd)
+
+	rootCmd.AddCommand(securityCmd)
+}
+
+func runSecurityRotateFieldKey(cmd *cobra.Command, args []string) error {
+	if securityBatchSize < 1 {
+		return fmt.Errorf("--batch-size must be at least 1")
+	}
+
+	cfg, s, err := loadConfigAndSchema()
+	if err != nil {
+		return err
+	}
+	if cfg.Security.FieldEncryptionKey == "" {
+		return database.ErrFieldKeyMissing
+	}
+
+	var names []string
+	for name, col := range s.Collections {
+		if len(col.EncryptedFields()) == 0 {
+			continue
+		}
+		if securityCollection != "" && name != securityCollection {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if securityCollection != "" && len(names) == 0 {
+		return fmt.Errorf("collection %q has no encrypted fields", securityCollection)
+	}
+
+	db, err := database.Open(&cfg.Database)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.ConfigureFieldEncryption(&cfg.Security, s); err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(names) == 0 {
+		fmt.Fprintln(out, "No collections declare encrypted fields")
+		return nil
+	}
+
+	ctx := context.Background()
+	total := 0
+	for _, name := range names {
+		coll := database.NewCollection(db, s.Collections[name])
+		rotated, err := coll.RotateEncryptedFields(ctx, securityBatchSize)
+		total += rotated
+		if err != nil {
+			return fmt.Errorf("rotating %s after %d row(s): %w", name, rotated, err)
+		}
+		fmt.Fprintf(out, "  ✓ %s: re-encrypted %d row(s)\n", name, rotated)
+	}
+
+	fmt.Fprintf(out, "\nRe-encrypted %d row(s) across %d collection(s).\n", total, len(names))
+	if len(cfg.Security.PreviousFieldEncryptionKeys) > 0 {
+		fmt.Fprintln(out, "The keys in security.previous_field_encryption_keys can now be removed.")
+	}
+	return nil
+}
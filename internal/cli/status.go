@@ -0,0 +1,348 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusServer string
+	statusToken  string
+	statusJSON   bool
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of a running Alyx server",
+	Long: `Summarize the health of a running Alyx server.
+
+Reports the version, uptime and component health from /health, the
+database size from /health/stats, pending schema changes, the latest
+deployment, and function pool readiness. Sections the token may not read
+are shown as "no access" rather than failing the command.
+
+The command exits with a non-zero status unless the server reports itself
+healthy.
+
+Examples:
+  alyx status --server https://api.myapp.com --token <token>
+  alyx status --server https://api.myapp.com --token <token> --json
+
+Environment Variables:
+  ALYX_DEPLOY_URL    Default server URL
+  ALYX_DEPLOY_TOKEN  Default admin token`,
+	SilenceUsage: true,
+	RunE:         runStatus,
+}
+
+func init() {
+	statusCmd.Flags().StringVar(&statusServer, "server", "", "Alyx server URL (or ALYX_DEPLOY_URL)")
+	statusCmd.Flags().StringVar(&statusToken, "token", "", "Admin token for the admin sections (or ALYX_DEPLOY_TOKEN)")
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "Output the status as JSON")
+
+	rootCmd.AddCommand(statusCmd)
+}
+
+// Access states of a status section.
+const (
+	statusAccessOK          = "ok"
+	statusAccessDenied      = "no_access"
+	statusAccessUnavailable = "unavailable"
+	statusAccessError       = "error"
+)
+
+// statusSection records whether a section of the report could be read.
+type statusSection struct {
+	Access string `json:"access"`
+	Error  string `json:"error,omitempty"`
+}
+
+type statusComponent struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type statusHealth struct {
+	statusSection
+	Status     string                     `json:"status,omitempty"`
+	Version    string                     `json:"version,omitempty"`
+	Uptime     string                     `json:"uptime,omitempty"`
+	Components map[string]statusComponent `json:"components,omitempty"`
+}
+
+type statusDatabase struct {
+	statusSection
+	Size            int64 `json:"size"`
+	WALSize         int64 `json:"wal_size"`
+	OpenConnections int   `json:"open_connections"`
+}
+
+type statusPending struct {
+	statusSection
+	Pending bool `json:"pending"`
+	Total   int  `json:"total"`
+}
+
+type statusDeploy struct {
+	statusSection
+	Version     string     `json:"version,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	DeployedAt  *time.Time `json:"deployed_at,omitempty"`
+	DeployedBy  string     `json:"deployed_by,omitempty"`
+	Description string     `json:"description,omitempty"`
+}
+
+type statusPool struct {
+	Ready int `json:"ready"`
+	Busy  int `json:"busy"`
+	Total int `json:"total"`
+}
+
+type statusFunctions struct {
+	statusSection
+	Pools map[string]statusPool `json:"pools,omitempty"`
+}
+
+// statusReport is the output of alyx status.
+type statusReport struct {
+	Server         string          `json:"server"`
+	Health         statusHealth    `json:"health"`
+	Database       statusDatabase  `json:"database"`
+	PendingChanges statusPending   `json:"pending_changes"`
+	LastDeploy     statusDeploy    `json:"last_deploy"`
+	Functions      statusFunctions `json:"functions"`
+}
+
+// Healthy reports whether the server said it is healthy.
+func (r *statusReport) Healthy() bool {
+	return r.Health.Access == statusAccessOK && r.Health.Status == "healthy"
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	if statusServer == "" {
+		statusServer = os.Getenv("ALYX_DEPLOY_URL")
+	}
+	if statusToken == "" {
+		statusToken = os.Getenv("ALYX_DEPLOY_TOKEN")
+	}
+	if statusServer == "" {
+		return fmt.Errorf("--server is required (or set ALYX_DEPLOY_URL)")
+	}
+
+	client := &deployClient{
+		baseURL: strings.TrimSuffix(statusServer, "/"),
+		token:   statusToken,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+	report := collectStatus(cmd.Context(), client)
+
+	if statusJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("encoding status: %w", err)
+		}
+	} else {
+		printStatus(report)
+	}
+
+	if !report.Healthy() {
+		if report.Health.Access != statusAccessOK {
+			return fmt.Errorf("health check failed: %s", report.Health.Error)
+		}
+		return fmt.Errorf("server is %s", report.Health.Status)
+	}
+	return nil
+}
+
+// collectStatus queries each endpoint of the report. A failed request only
+// marks its own section.
+func collectStatus(ctx context.Context, client *deployClient) *statusReport {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	report := &statusReport{Server: client.baseURL}
+
+	// /health answers 503 with a body when the server is unhealthy.
+	report.Health.statusSection = fetchStatus(ctx, client, "/health", &report.Health, http.StatusServiceUnavailable)
+
+	var stats struct {
+		Database struct {
+			Size            int64 `json:"size"`
+			WALSize         int64 `json:"wal_size"`
+			OpenConnections int   `json:"open_connections"`
+		} `json:"database"`
+	}
+	report.Database.statusSection = fetchStatus(ctx, client, "/health/stats", &stats)
+	report.Database.Size = stats.Database.Size
+	report.Database.WALSize = stats.Database.WALSize
+	report.Database.OpenConnections = stats.Database.OpenConnections
+
+	report.PendingChanges.statusSection = fetchStatus(ctx, client, "/api/admin/schema/pending-changes", &report.PendingChanges)
+
+	var history struct {
+		Deployments []statusDeploy `json:"deployments"`
+	}
+	section := fetchStatus(ctx, client, "/api/admin/deploy/history?limit=1", &history)
+	if len(history.Deployments) > 0 {
+		report.LastDeploy = history.Deployments[0]
+	}
+	report.LastDeploy.statusSection = section
+
+	report.Functions.statusSection = fetchStatus(ctx, client, "/api/functions/stats", &report.Functions)
+
+	return report
+}
+
+// fetchStatus decodes the response of a GET request into v. Statuses other
+// than 200 and those in accept mark the section instead: 401 and 403 as no
+// access, 404 and 503 as unavailable, and anything else as an error.
+func fetchStatus(ctx context.Context, client *deployClient, path string, v any, accept ...int) statusSection {
+	resp, err := client.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return statusSection{Access: statusAccessError, Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && !slices.Contains(accept, resp.StatusCode) {
+		switch resp.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return statusSection{Access: statusAccessDenied}
+		case http.StatusNotFound, http.StatusServiceUnavailable:
+			return statusSection{Access: statusAccessUnavailable}
+		default:
+			return statusSection{Access: statusAccessError, Error: handleErrorResponse(resp).Error()}
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return statusSection{Access: statusAccessError, Error: fmt.Sprintf("parsing %s response: %v", path, err)}
+	}
+	return statusSection{Access: statusAccessOK}
+}
+
+func printStatus(report *statusReport) {
+	fmt.Printf("Server:  %s\n", report.Server)
+	if report.Health.Access != statusAccessOK {
+		fmt.Printf("Health:  %s\n", statusUnreadable(report.Health.statusSection))
+	} else {
+		fmt.Printf("Health:  %s\n", report.Health.Status)
+		fmt.Printf("Version: %s\n", report.Health.Version)
+		fmt.Printf("Uptime:  %s\n", report.Health.Uptime)
+
+		names := make([]string, 0, len(report.Health.Components))
+		for name := range report.Health.Components {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		fmt.Println()
+		fmt.Println("Components:")
+		for _, name := range names {
+			c := report.Health.Components[name]
+			line := c.Status
+			if c.Latency != "" {
+				line += " (" + c.Latency + ")"
+			}
+			if c.Message != "" {
+				line += ": " + c.Message
+			}
+			fmt.Printf("  %s %-10s %s\n", statusSymbol(c.Status), name, line)
+		}
+	}
+	fmt.Println()
+
+	database := statusUnreadable(report.Database.statusSection)
+	if report.Database.Access == statusAccessOK {
+		database = fmt.Sprintf("%s (WAL %s, %d open connections)",
+			formatStatusBytes(report.Database.Size), formatStatusBytes(report.Database.WALSize), report.Database.OpenConnections)
+	}
+	fmt.Printf("Database:        %s\n", database)
+
+	pending := statusUnreadable(report.PendingChanges.statusSection)
+	if report.PendingChanges.Access == statusAccessOK {
+		pending = "none"
+		if report.PendingChanges.Pending {
+			pending = fmt.Sprintf("%d unsafe change(s) awaiting confirmation", report.PendingChanges.Total)
+		}
+	}
+	fmt.Printf("Pending changes: %s\n", pending)
+
+	lastDeploy := statusUnreadable(report.LastDeploy.statusSection)
+	if report.LastDeploy.Access == statusAccessOK {
+		lastDeploy = "none"
+		if d := report.LastDeploy; d.Version != "" {
+			lastDeploy = fmt.Sprintf("%s (%s)", d.Version, d.Status)
+			if d.DeployedBy != "" {
+				lastDeploy += " by " + d.DeployedBy
+			}
+			if d.DeployedAt != nil {
+				lastDeploy += " at " + d.DeployedAt.Local().Format("2006-01-02 15:04:05")
+			}
+		}
+	}
+	fmt.Printf("Last deploy:     %s\n", lastDeploy)
+
+	funcs := statusUnreadable(report.Functions.statusSection)
+	if report.Functions.Access == statusAccessOK {
+		funcs = "no pools"
+		runtimes := make([]string, 0, len(report.Functions.Pools))
+		for rt := range report.Functions.Pools {
+			runtimes = append(runtimes, rt)
+		}
+		slices.Sort(runtimes)
+		parts := make([]string, 0, len(runtimes))
+		for _, rt := range runtimes {
+			p := report.Functions.Pools[rt]
+			parts = append(parts, fmt.Sprintf("%s %d/%d ready", rt, p.Ready, p.Total))
+		}
+		if len(parts) > 0 {
+			funcs = strings.Join(parts, ", ")
+		}
+	}
+	fmt.Printf("Functions:       %s\n", funcs)
+}
+
+// statusUnreadable describes a section that could not be read.
+func statusUnreadable(s statusSection) string {
+	switch s.Access {
+	case statusAccessDenied:
+		return "no access"
+	case statusAccessUnavailable:
+		return "unavailable"
+	default:
+		return "error: " + s.Error
+	}
+}
+
+func statusSymbol(status string) string {
+	switch status {
+	case "healthy":
+		return "✓"
+	case "degraded":
+		return "!"
+	default:
+		return "✗"
+	}
+}
+
+func formatStatusBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
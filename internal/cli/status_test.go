@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCollectStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"unhealthy","version":"1.2.3","uptime":"1h0m0s","components":{"database":{"status":"unhealthy","message":"locked"}}}`))
+	})
+	mux.HandleFunc("GET /health/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"database":{"size":2097152,"wal_size":4096,"open_connections":3}}`))
+	})
+	mux.HandleFunc("GET /api/admin/schema/pending-changes", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"code":"FORBIDDEN","message":"insufficient permissions"}`))
+	})
+	mux.HandleFunc("GET /api/admin/deploy/history", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"deployments":[{"version":"v7","status":"active","deployed_at":"2026-01-02T03:04:05Z","deployed_by":"ci"}],"total":7}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &deployClient{baseURL: server.URL, token: "secret", client: server.Client()}
+	report := collectStatus(context.Background(), client)
+
+	if report.Health.Access != statusAccessOK || report.Health.Status != "unhealthy" || report.Health.Version != "1.2.3" {
+		t.Errorf("health: unexpected %+v", report.Health)
+	}
+	if report.Health.Components["database"].Message != "locked" {
+		t.Errorf("health: expected the database component, got %+v", report.Health.Components)
+	}
+	if report.Healthy() {
+		t.Error("expected an unhealthy report")
+	}
+	if report.Database.Access != statusAccessOK || report.Database.Size != 2097152 || report.Database.OpenConnections != 3 {
+		t.Errorf("database: unexpected %+v", report.Database)
+	}
+	if report.PendingChanges.Access != statusAccessDenied {
+		t.Errorf("pending changes: expected no access, got %+v", report.PendingChanges)
+	}
+	if d := report.LastDeploy; d.Access != statusAccessOK || d.Version != "v7" || d.DeployedBy != "ci" || d.DeployedAt == nil {
+		t.Errorf("last deploy: unexpected %+v", d)
+	}
+	if report.Functions.Access != statusAccessUnavailable {
+		t.Errorf("functions: expected unavailable, got %+v", report.Functions)
+	}
+
+	client.token = ""
+	if report := collectStatus(context.Background(), client); report.LastDeploy.Access != statusAccessDenied {
+		t.Errorf("last deploy without a token: expected no access, got %+v", report.LastDeploy)
+	}
+}
+
+func TestFormatStatusBytes(t *testing.T) {
+	tests := map[int64]string{
+		512:             "512 B",
+		2048:            "2.0 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+	}
+	for n, want := range tests {
+		if got := formatStatusBytes(n); got != want {
+			t.Errorf("formatStatusBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
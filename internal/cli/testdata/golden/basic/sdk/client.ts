@@ -0,0 +1,46 @@
+// Auto-generated Alyx client
+
+import { CollectionClient } from './resources/collections';
+import { AuthClient } from './resources/auth';
+import { FunctionsClient } from './resources/functions';
+import { EventsClient } from './resources/events';
+import { Items, ItemsInput, ItemsPatch } from './types/collections';
+
+export interface AlyxConfig {
+  url: string;
+  token?: string;
+  parentRequestId?: string;
+}
+
+export class AlyxClient {
+  private config: AlyxConfig;
+  public collections: {
+    items: CollectionClient<Items, ItemsInput, ItemsPatch>;
+  };
+  public auth: AuthClient;
+  public functions: FunctionsClient;
+  public events: EventsClient;
+
+  constructor(config: AlyxConfig) {
+    this.config = config;
+
+    this.collections = {
+      items: new CollectionClient<Items, ItemsInput, ItemsPatch>(this.config.url, 'items', () => this.getHeaders())
+    };
+
+    this.auth = new AuthClient(this.config.url, () => this.getHeaders());
+    this.functions = new FunctionsClient(this.config.url, () => this.getHeaders());
+    this.events = new EventsClient(this.config.url, () => this.getHeaders());
+  }
+
+  private getHeaders(): Record<string, string> {
+    const headers: Record<string, string> = {};
+    if (this.config.token) {
+      headers['Authorization'] = `Bearer ${this.config.token}`;
+    }
+    if (this.config.parentRequestId) {
+      headers['X-Parent-Request-ID'] = this.config.parentRequestId;
+    }
+    return headers;
+  }
+}
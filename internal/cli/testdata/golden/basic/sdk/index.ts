@@ -0,0 +1,13 @@
+// Auto-generated SDK exports
+
+export * from './client';
+export * from './context';
+export * from './types/collections';
+export * from './types/auth';
+export * from './types/functions';
+export * from './types/events';
+export * from './resources/request';
+export * from './resources/collections';
+export * from './resources/auth';
+export * from './resources/functions';
+export * from './resources/events';
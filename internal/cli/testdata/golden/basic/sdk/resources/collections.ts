@@ -0,0 +1,127 @@
+// Auto-generated collections resource
+
+import { ListResponse, SyncMutation, SyncPullResponse, SyncPushResponse } from '../types/collections';
+import { request, RequestOptions } from './request';
+
+export class CollectionClient<T, TInput = Partial<T>, TPatch = Partial<TInput>> {
+  constructor(
+    private baseURL: string,
+    private collectionName: string,
+    private getHeaders: () => Record<string, string>
+  ) {}
+
+  async list(params?: {
+    limit?: number;
+    offset?: number;
+    sort?: string | string[];
+    filter?: string[];
+    total?: 'exact' | 'none' | 'estimate';
+  }, options?: RequestOptions): Promise<ListResponse<T>> {
+    const query = new URLSearchParams();
+    if (params?.limit) query.set('limit', params.limit.toString());
+    if (params?.offset) query.set('offset', params.offset.toString());
+    if (params?.sort) query.set('sort', Array.isArray(params.sort) ? params.sort.join(',') : params.sort);
+    if (params?.filter) params.filter.forEach(f => query.append('filter', f));
+    if (params?.total) query.set('total', params.total);
+
+    return request<ListResponse<T>>(
+      `${this.baseURL}/api/collections/${this.collectionName}?${query}`,
+      { headers: this.getHeaders() },
+      options
+    );
+  }
+
+  async get(id: string, options?: RequestOptions): Promise<T> {
+    return request<T>(
+      `${this.baseURL}/api/collections/${this.collectionName}/${id}`,
+      { headers: this.getHeaders() },
+      options
+    );
+  }
+
+  async create(data: TInput, options?: RequestOptions): Promise<T> {
+    return request<T>(
+      `${this.baseURL}/api/collections/${this.collectionName}`,
+      {
+        method: 'POST',
+        headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
+        body: JSON.stringify(data),
+      },
+      options
+    );
+  }
+
+  async update(id: string, data: TPatch, options?: RequestOptions): Promise<T> {
+    return request<T>(
+      `${this.baseURL}/api/collections/${this.collectionName}/${id}`,
+      {
+        method: 'PATCH',
+        headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
+        body: JSON.stringify(data),
+      },
+      options
+    );
+  }
+
+  async upsert(key: string, data: TInput, options?: RequestOptions): Promise<{ created: boolean; document: T }> {
+    return request<{ created: boolean; document: T }>(
+      `${this.baseURL}/api/collections/${this.collectionName}/upsert?key=${encodeURIComponent(key)}`,
+      {
+        method: 'PUT',
+        headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
+        body: JSON.stringify(data),
+      },
+      options
+    );
+  }
+
+  async delete(id: string, options?: RequestOptions): Promise<void> {
+    await request<void>(
+      `${this.baseURL}/api/collections/${this.collectionName}/${id}`,
+      { method: 'DELETE', headers: this.getHeaders() },
+      options
+    );
+  }
+
+  /**
+   * Returns the documents changed since the cursor of the previous pull.
+   * Without since, pages through a snapshot of every readable document.
+   * Keep pulling with the returned cursor while has_more is set.
+   */
+  async pull(since?: string, params?: { limit?: number }, options?: RequestOptions): Promise<SyncPullResponse<T>> {
+    const query = new URLSearchParams();
+    if (since) query.set('since', since);
+    if (params?.limit) query.set('limit', params.limit.toString());
+    let body: SyncPullResponse<T>;
+    try {
+      body = await request<SyncPullResponse<T>>(
+        `${this.baseURL}/api/sync/${this.collectionName}?${query}`,
+        { headers: this.getHeaders() },
+        options
+      );
+    } catch (err) {
+      if (err instanceof Error && err.message.startsWith('HTTP 410:') && err.message.includes('RESYNC_REQUIRED')) {
+        return { changes: [], cursor: '', has_more: true, resync_required: true };
+      }
+      throw err;
+    }
+    return body;
+  }
+
+  /**
+   * Applies offline mutations in a single transaction. Mutations whose
+   * base_rev is out of date are not applied; their results are marked
+   * conflict and carry the server's document.
+   */
+  async push(mutations: SyncMutation<TInput, TPatch>[], options?: RequestOptions): Promise<SyncPushResponse<T>> {
+    return request<SyncPushResponse<T>>(
+      `${this.baseURL}/api/sync/${this.collectionName}`,
+      {
+        method: 'POST',
+        headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
+        body: JSON.stringify({ mutations }),
+      },
+      options
+    );
+  }
+}
@@ -0,0 +1,56 @@
+// Auto-generated functions resource
+
+import { FunctionInfo, FunctionInput, FunctionError, FunctionResponse } from '../types/functions';
+import { request, RequestOptions } from './request';
+
+/** Thrown by typed function methods when the function reports an error. */
+export class FunctionInvocationError extends Error {
+  constructor(public code: string, message: string, public details?: Record<string, any>) {
+    super(message);
+    this.name = 'FunctionInvocationError';
+  }
+}
+
+export class FunctionsClient {
+  constructor(
+    private baseURL: string,
+    private getHeaders: () => Record<string, string>
+  ) {}
+
+  async list(options?: RequestOptions): Promise<{ functions: FunctionInfo[]; count: number }> {
+    return request<{ functions: FunctionInfo[]; count: number }>(`${this.baseURL}/api/functions`, {
+      headers: this.getHeaders(),
+    }, options);
+  }
+
+  async invoke<TOutput = Record<string, any>>(name: string, input?: FunctionInput, options?: RequestOptions): Promise<FunctionResponse<TOutput>> {
+    return this.call<TOutput>(name, input || {}, options);
+  }
+
+  async stats(options?: RequestOptions): Promise<{
+    pools: Record<string, { ready: number; busy: number; total: number }>;
+    builds: Record<string, { status: 'success' | 'failed'; started_at: string; duration_ms: number; output?: string; error?: string } | null>;
+  }> {
+    return request<{
+      pools: Record<string, { ready: number; busy: number; total: number }>;
+      builds: Record<string, { status: 'success' | 'failed'; started_at: string; duration_ms: number; output?: string; error?: string } | null>;
+    }>(`${this.baseURL}/api/functions/stats`, {
+      headers: this.getHeaders(),
+    }, options);
+  }
+
+  async reload(options?: RequestOptions): Promise<{ success: boolean; count: number; ready: number; failed: { name: string; error: string }[]; message: string }> {
+    return request<{ success: boolean; count: number; ready: number; failed: { name: string; error: string }[]; message: string }>(`${this.baseURL}/api/functions/reload`, {
+      method: 'POST',
+      headers: this.getHeaders(),
+    }, options);
+  }
+
+  private async call<TOutput>(name: string, input: unknown, options?: RequestOptions): Promise<FunctionResponse<TOutput>> {
+    return request<FunctionResponse<TOutput>>(`${this.baseURL}/api/functions/${name}`, {
+      method: 'POST',
+      headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
+      body: JSON.stringify(input),
+    }, options);
+  }
+}
@@ -0,0 +1,70 @@
+// Auto-generated request helper
+
+/** Options accepted as the last argument of every request method. */
+export interface RequestOptions {
+  /** Cancels the request when aborted. */
+  signal?: AbortSignal;
+  /** Cancels the request if it has not finished after this many milliseconds. */
+  timeoutMs?: number;
+  /** Headers to send in addition to, or instead of, the client's. */
+  headers?: Record<string, string>;
+}
+
+/** Thrown when a request is cancelled through its signal or its timeout. */
+export class AlyxAbortError extends Error {
+  /** Whether the request was cancelled because timeoutMs elapsed. */
+  readonly timedOut: boolean;
+
+  constructor(message: string, timedOut: boolean) {
+    super(message);
+    this.name = 'AlyxAbortError';
+    this.timedOut = timedOut;
+  }
+}
+
+/**
+ * Sends a request and returns its decoded JSON body, or undefined when it has
+ * none. Throws for a non-2xx response, and an AlyxAbortError when the request
+ * is cancelled before the body has been read.
+ */
+export async function request<T>(
+  url: string,
+  init: { method?: string; headers?: Record<string, string>; body?: string },
+  options?: RequestOptions
+): Promise<T> {
+  const controller = new AbortController();
+  const abort = () => controller.abort();
+  let timedOut = false;
+  let timer: ReturnType<typeof setTimeout> | undefined;
+
+  if (options?.signal?.aborted) {
+    abort();
+  } else {
+    options?.signal?.addEventListener('abort', abort);
+  }
+  if (options?.timeoutMs !== undefined) {
+    timer = setTimeout(() => {
+      timedOut = true;
+      abort();
+    }, options.timeoutMs);
+  }
+
+  try {
+    const response = await fetch(url, {
+      ...init,
+      headers: { ...init.headers, ...options?.headers },
+      signal: controller.signal,
+    });
+    if (!response.ok) throw new Error(`HTTP ${response.status}: ${await response.text()}`);
+    const text = await response.text();
+    return (text ? JSON.parse(text) : undefined) as T;
+  } catch (err) {
+    if (controller.signal.aborted) {
+      throw new AlyxAbortError(timedOut ? `Request timed out after ${options?.timeoutMs}ms` : 'Request aborted', timedOut);
+    }
+    throw err;
+  } finally {
+    clearTimeout(timer);
+    options?.signal?.removeEventListener('abort', abort);
+  }
+}
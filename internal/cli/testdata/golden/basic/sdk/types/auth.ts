@@ -0,0 +1,49 @@
+// Auto-generated auth types
+
+export type UserRole = 'user' | 'admin';
+
+export type UserMetadata = Record<string, any>;
+
+/** A JSON merge patch for UserMetadata: null removes a key. */
+export type UserMetadataPatch = { [K in keyof UserMetadata]?: UserMetadata[K] | null };
+
+export interface User {
+  id: string;
+  email: string;
+  verified: boolean;
+  role: UserRole;
+  created_at: string;
+  updated_at: string;
+  metadata?: UserMetadata;
+}
+
+export interface TokenPair {
+  access_token: string;
+  refresh_token: string;
+  expires_at: string;
+  token_type: string;
+}
+
+export interface AuthResponse {
+  user: User;
+  tokens: TokenPair;
+}
+
+export interface RegisterInput {
+  email: string;
+  password: string;
+  metadata?: UserMetadata;
+}
+
+export interface UpdateMeInput {
+  metadata: UserMetadataPatch | null;
+}
+
+export interface LoginInput {
+  email: string;
+  password: string;
+}
+
+export interface RefreshInput {
+  refresh_token: string;
+}
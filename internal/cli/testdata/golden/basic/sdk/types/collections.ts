@@ -0,0 +1,76 @@
+// Auto-generated collection types
+
+export interface Items {
+  created_at?: string;
+  description?: string;
+  id?: string;
+  name: string;
+  updated_at?: string;
+}
+
+export interface ItemsInput {
+  description?: string | null;
+  name: string;
+}
+
+/** Fields to update. Absent fields are left unchanged; null clears a nullable field. */
+export interface ItemsPatch {
+  description?: string | null;
+  name?: string;
+}
+
+export type FieldKind = 'timestamp' | 'json';
+
+/** The fields of each collection with a kind that needs converting, by collection and field. */
+export const fieldKinds: Record<string, Record<string, FieldKind>> = {
+  items: { created_at: 'timestamp', updated_at: 'timestamp' },
+};
+
+export interface ListResponse<T> {
+  docs: T[];
+  /** Omitted when the list was requested with total: 'none'. */
+  total?: number;
+  /** True when total is an estimate. */
+  total_estimated?: boolean;
+  limit: number;
+  offset: number;
+}
+
+/** A document changed since the last pull, or the tombstone of a deleted one. */
+export interface SyncChange<T> {
+  id: string;
+  deleted?: boolean;
+  /** Revision of the document; send it as base_rev when changing the document. */
+  rev?: string;
+  document?: T;
+}
+
+export interface SyncPullResponse<T> {
+  changes: SyncChange<T>[];
+  /** The since of the next pull. */
+  cursor: string;
+  /** Whether the next pull already has changes. */
+  has_more: boolean;
+  /**
+   * Set when the changes after since are no longer retained. Discard the
+   * local copy of the collection and pull again from cursor, which starts a
+   * new snapshot.
+   */
+  resync_required?: boolean;
+}
+
+/** A change made while offline. Updates and deletes need the rev they were made to. */
+export type SyncMutation<TInput, TPatch = Partial<TInput>> =
+  | { op: 'create'; id: string; data: TInput }
+  | { op: 'update'; id: string; base_rev: string; data: TPatch }
+  | { op: 'delete'; id: string; base_rev: string };
+
+/** The outcome of a mutation. On conflict, document is the server's, or deleted is set. */
+export interface SyncResult<T> extends SyncChange<T> {
+  conflict?: boolean;
+}
+
+export interface SyncPushResponse<T> {
+  /** A result per mutation, in the order they were pushed. */
+  results: SyncResult<T>[];
+}
@@ -0,0 +1,69 @@
+// Auto-generated event types
+
+export type EventType = 'http' | 'database' | 'auth' | 'schedule' | 'webhook' | 'custom';
+
+export interface EventPayload {
+  [key: string]: any;
+}
+
+export interface EventMetadata {
+  user_id?: string;
+  ip_address?: string;
+  user_agent?: string;
+  extra?: Record<string, any>;
+}
+
+export interface Event {
+  id: string;
+  type: EventType;
+  source: string;
+  action: string;
+  payload: EventPayload;
+  metadata?: EventMetadata;
+  status: 'pending' | 'processing' | 'completed' | 'failed';
+  created_at: string;
+  process_at?: string;
+  processed_at?: string;
+}
+
+// Hook event payload types
+export interface DatabaseEventPayload {
+  document: Record<string, any>;
+  previous_document?: Record<string, any>;
+  action: 'insert' | 'update' | 'delete';
+  collection: string;
+  changed_fields?: string[];
+}
+
+export interface AuthEventPayload {
+  user: {
+    id: string;
+    email: string;
+    verified: boolean;
+    role: string;
+    created_at: string;
+  };
+  action: 'signup' | 'login' | 'logout' | 'password_reset' | 'email_verify';
+  metadata?: {
+    ip_address?: string;
+    user_agent?: string;
+  };
+}
+
+export interface WebhookEventPayload {
+  method: string;
+  path: string;
+  headers: Record<string, string>;
+  body: string;
+  query: Record<string, string>;
+  verified: boolean;
+  webhook_id: string;
+  verification_error?: string;
+}
+
+export interface ScheduleEventPayload {
+  schedule_id: string;
+  schedule_name: string;
+  function_id: string;
+  input?: Record<string, any>;
+}
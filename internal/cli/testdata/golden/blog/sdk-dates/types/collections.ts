@@ -0,0 +1,149 @@
+// Auto-generated collection types
+
+export interface Comments {
+  author_id: string;
+  content: string;
+  created_at?: Date;
+  id?: string;
+  post_id: string;
+}
+
+export interface CommentsInput {
+  author_id: string;
+  content: string;
+  post_id: string;
+}
+
+/** Fields to update. Absent fields are left unchanged; null clears a nullable field. */
+export interface CommentsPatch {
+  author_id?: string;
+  content?: string;
+  post_id?: string;
+}
+
+export type PostsTags = string[];
+
+export interface Posts {
+  author_id: string;
+  content: string;
+  created_at?: Date;
+  excerpt?: string;
+  id?: string;
+  published?: boolean;
+  published_at?: Date;
+  slug: string;
+  tags?: PostsTags;
+  title: string;
+  updated_at?: Date;
+  view_count?: number;
+}
+
+export interface PostsInput {
+  author_id: string;
+  content: string;
+  excerpt?: string | null;
+  published?: boolean;
+  published_at?: Date | string | null;
+  regenerate_slug?: boolean;
+  slug?: string;
+  tags?: PostsTags | null;
+  title: string;
+  view_count?: number;
+}
+
+/** Fields to update. Absent fields are left unchanged; null clears a nullable field. */
+export interface PostsPatch {
+  author_id?: string;
+  content?: string;
+  excerpt?: string | null;
+  published?: boolean;
+  published_at?: Date | string | null;
+  regenerate_slug?: boolean;
+  slug?: string;
+  tags?: PostsTags | null;
+  title?: string;
+  view_count?: number;
+}
+
+export interface Users {
+  avatar_url?: string;
+  created_at?: Date;
+  email: string;
+  id?: string;
+  name?: string;
+  role?: 'user' | 'author' | 'admin';
+  updated_at?: Date;
+}
+
+export interface UsersInput {
+  avatar_url?: string | null;
+  email: string;
+  name?: string | null;
+  role?: 'user' | 'author' | 'admin';
+}
+
+/** Fields to update. Absent fields are left unchanged; null clears a nullable field. */
+export interface UsersPatch {
+  avatar_url?: string | null;
+  email?: string;
+  name?: string | null;
+  role?: 'user' | 'author' | 'admin';
+}
+
+export type FieldKind = 'timestamp' | 'json';
+
+/** The fields of each collection with a kind that needs converting, by collection and field. */
+export const fieldKinds: Record<string, Record<string, FieldKind>> = {
+  comments: { created_at: 'timestamp' },
+  posts: { created_at: 'timestamp', updated_at: 'timestamp', published_at: 'timestamp', tags: 'json' },
+  users: { created_at: 'timestamp', updated_at: 'timestamp' },
+};
+
+export interface ListResponse<T> {
+  docs: T[];
+  /** Omitted when the list was requested with total: 'none'. */
+  total?: number;
+  /** True when total is an estimate. */
+  total_estimated?: boolean;
+  limit: number;
+  offset: number;
+}
+
+/** A document changed since the last pull, or the tombstone of a deleted one. */
+export interface SyncChange<T> {
+  id: string;
+  deleted?: boolean;
+  /** Revision of the document; send it as base_rev when changing the document. */
+  rev?: string;
+  document?: T;
+}
+
+export interface SyncPullResponse<T> {
+  changes: SyncChange<T>[];
+  /** The since of the next pull. */
+  cursor: string;
+  /** Whether the next pull already has changes. */
+  has_more: boolean;
+  /**
+   * Set when the changes after since are no longer retained. Discard the
+   * local copy of the collection and pull again from cursor, which starts a
+   * new snapshot.
+   */
+  resync_required?: boolean;
+}
+
+/** A change made while offline. Updates and deletes need the rev they were made to. */
+export type SyncMutation<TInput, TPatch = Partial<TInput>> =
+  | { op: 'create'; id: string; data: TInput }
+  | { op: 'update'; id: string; base_rev: string; data: TPatch }
+  | { op: 'delete'; id: string; base_rev: string };
+
+/** The outcome of a mutation. On conflict, document is the server's, or deleted is set. */
+export interface SyncResult<T> extends SyncChange<T> {
+  conflict?: boolean;
+}
+
+export interface SyncPushResponse<T> {
+  /** A result per mutation, in the order they were pushed. */
+  results: SyncResult<T>[];
+}
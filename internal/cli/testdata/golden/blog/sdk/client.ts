@@ -0,0 +1,52 @@
+// Auto-generated Alyx client
+
+import { CollectionClient } from './resources/collections';
+import { AuthClient } from './resources/auth';
+import { FunctionsClient } from './resources/functions';
+import { EventsClient } from './resources/events';
+import { Comments, CommentsInput, CommentsPatch } from './types/collections';
+import { Posts, PostsInput, PostsPatch } from './types/collections';
+import { Users, UsersInput, UsersPatch } from './types/collections';
+
+export interface AlyxConfig {
+  url: string;
+  token?: string;
+  parentRequestId?: string;
+}
+
+export class AlyxClient {
+  private config: AlyxConfig;
+  public collections: {
+    comments: CollectionClient<Comments, CommentsInput, CommentsPatch>;
+    posts: CollectionClient<Posts, PostsInput, PostsPatch>;
+    users: CollectionClient<Users, UsersInput, UsersPatch>;
+  };
+  public auth: AuthClient;
+  public functions: FunctionsClient;
+  public events: EventsClient;
+
+  constructor(config: AlyxConfig) {
+    this.config = config;
+
+    this.collections = {
+      comments: new CollectionClient<Comments, CommentsInput, CommentsPatch>(this.config.url, 'comments', () => this.getHeaders()),
+      posts: new CollectionClient<Posts, PostsInput, PostsPatch>(this.config.url, 'posts', () => this.getHeaders()),
+      users: new CollectionClient<Users, UsersInput, UsersPatch>(this.config.url, 'users', () => this.getHeaders())
+    };
+
+    this.auth = new AuthClient(this.config.url, () => this.getHeaders());
+    this.functions = new FunctionsClient(this.config.url, () => this.getHeaders());
+    this.events = new EventsClient(this.config.url, () => this.getHeaders());
+  }
+
+  private getHeaders(): Record<string, string> {
+    const headers: Record<string, string> = {};
+    if (this.config.token) {
+      headers['Authorization'] = `Bearer ${this.config.token}`;
+    }
+    if (this.config.parentRequestId) {
+      headers['X-Parent-Request-ID'] = this.config.parentRequestId;
+    }
+    return headers;
+  }
+}
@@ -0,0 +1,76 @@
+// Auto-generated auth resource
+
+import { User, AuthResponse, RegisterInput, LoginInput, RefreshInput, UpdateMeInput } from '../types/auth';
+import { request, RequestOptions } from './request';
+
+export class AuthClient {
+  constructor(
+    private baseURL: string,
+    private getHeaders: () => Record<string, string>
+  ) {}
+
+  async register(input: RegisterInput, options?: RequestOptions): Promise<AuthResponse> {
+    return request<AuthResponse>(`${this.baseURL}/api/auth/register`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(input),
+    }, options);
+  }
+
+  async login(input: LoginInput, options?: RequestOptions): Promise<AuthResponse> {
+    return request<AuthResponse>(`${this.baseURL}/api/auth/login`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(input),
+    }, options);
+  }
+
+  async refresh(input: RefreshInput, options?: RequestOptions): Promise<AuthResponse> {
+    return request<AuthResponse>(`${this.baseURL}/api/auth/refresh`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(input),
+    }, options);
+  }
+
+  async logout(refreshToken: string, options?: RequestOptions): Promise<void> {
+    await request<void>(`${this.baseURL}/api/auth/logout`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    }, options);
+  }
+
+  async me(options?: RequestOptions): Promise<User> {
+    return request<User>(`${this.baseURL}/api/auth/me`, {
+      headers: this.getHeaders(),
+    }, options);
+  }
+
+  async updateMe(input: UpdateMeInput, options?: RequestOptions): Promise<User> {
+    return request<User>(`${this.baseURL}/api/auth/me`, {
+      method: 'PATCH',
+      headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
+      body: JSON.stringify(input),
+    }, options);
+  }
+
+  async listProviders(options?: RequestOptions): Promise<{ providers: string[] }> {
+    return request<{ providers: string[] }>(`${this.baseURL}/api/auth/providers`, {}, options);
+  }
+
+  /** URL that starts an OAuth login. With a redirectUri from the server's allowlist, the login finishes there with a code for exchangeOAuthCode. */
+  getOAuthUrl(provider: string, redirectUri?: string): string {
+    const query = redirectUri ? `?redirect_uri=${encodeURIComponent(redirectUri)}` : '';
+    return `${this.baseURL}/api/auth/oauth/${encodeURIComponent(provider)}${query}`;
+  }
+
+  /** Exchanges the one-time code an OAuth login sent to its redirect URI for the token pair. */
+  async exchangeOAuthCode(code: string, options?: RequestOptions): Promise<AuthResponse> {
+    return request<AuthResponse>(`${this.baseURL}/api/auth/oauth/exchange`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ code }),
+    }, options);
+  }
+}
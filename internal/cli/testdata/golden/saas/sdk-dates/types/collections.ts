@@ -0,0 +1,164 @@
+// Auto-generated collection types
+
+export interface Invitations {
+  created_at?: Date;
+  email: string;
+  expires_at: Date;
+  id?: string;
+  invited_by: string;
+  org_id: string;
+  role?: 'member' | 'admin';
+  token: string;
+}
+
+export interface InvitationsInput {
+  email: string;
+  expires_at: Date | string;
+  invited_by: string;
+  org_id: string;
+  role?: 'member' | 'admin';
+  token: string;
+}
+
+/** Fields to update. Absent fields are left unchanged; null clears a nullable field. */
+export interface InvitationsPatch {
+  email?: string;
+  expires_at?: Date | string;
+  invited_by?: string;
+  org_id?: string;
+  role?: 'member' | 'admin';
+  token?: string;
+}
+
+export interface Members {
+  created_at?: Date;
+  id?: string;
+  invited_by?: string;
+  org_id: string;
+  role?: 'member' | 'admin' | 'owner';
+  user_id: string;
+}
+
+export interface MembersInput {
+  invited_by?: string | null;
+  org_id: string;
+  role?: 'member' | 'admin' | 'owner';
+  user_id: string;
+}
+
+/** Fields to update. Absent fields are left unchanged; null clears a nullable field. */
+export interface MembersPatch {
+  invited_by?: string | null;
+  org_id?: string;
+  role?: 'member' | 'admin' | 'owner';
+  user_id?: string;
+}
+
+export interface Organizations {
+  created_at?: Date;
+  id?: string;
+  name: string;
+  owner_id: string;
+  plan?: 'free' | 'starter' | 'pro' | 'enterprise';
+  settings?: Record<string, any>;
+  slug: string;
+  updated_at?: Date;
+}
+
+export interface OrganizationsInput {
+  name: string;
+  owner_id: string;
+  plan?: 'free' | 'starter' | 'pro' | 'enterprise';
+  settings?: Record<string, any> | null;
+  slug: string;
+}
+
+/** Fields to update. Absent fields are left unchanged; null clears a nullable field. */
+export interface OrganizationsPatch {
+  name?: string;
+  owner_id?: string;
+  plan?: 'free' | 'starter' | 'pro' | 'enterprise';
+  settings?: Record<string, any> | null;
+  slug?: string;
+}
+
+export interface Users {
+  avatar_url?: string;
+  created_at?: Date;
+  email: string;
+  id?: string;
+  name?: string;
+  updated_at?: Date;
+}
+
+export interface UsersInput {
+  avatar_url?: string | null;
+  email: string;
+  name?: string | null;
+}
+
+/** Fields to update. Absent fields are left unchanged; null clears a nullable field. */
+export interface UsersPatch {
+  avatar_url?: string | null;
+  email?: string;
+  name?: string | null;
+}
+
+export type FieldKind = 'timestamp' | 'json';
+
+/** The fields of each collection with a kind that needs converting, by collection and field. */
+export const fieldKinds: Record<string, Record<string, FieldKind>> = {
+  invitations: { expires_at: 'timestamp', created_at: 'timestamp' },
+  members: { created_at: 'timestamp' },
+  organizations: { created_at: 'timestamp', updated_at: 'timestamp', settings: 'json' },
+  users: { created_at: 'timestamp', updated_at: 'timestamp' },
+};
+
+export interface ListResponse<T> {
+  docs: T[];
+  /** Omitted when the list was requested with total: 'none'. */
+  total?: number;
+  /** True when total is an estimate. */
+  total_estimated?: boolean;
+  limit: number;
+  offset: number;
+}
+
+/** A document changed since the last pull, or the tombstone of a deleted one. */
+export interface SyncChange<T> {
+  id: string;
+  deleted?: boolean;
+  /** Revision of the document; send it as base_rev when changing the document. */
+  rev?: string;
+  document?: T;
+}
+
+export interface SyncPullResponse<T> {
+  changes: SyncChange<T>[];
+  /** The since of the next pull. */
+  cursor: string;
+  /** Whether the next pull already has changes. */
+  has_more: boolean;
+  /**
+   * Set when the changes after since are no longer retained. Discard the
+   * local copy of the collection and pull again from cursor, which starts a
+   * new snapshot.
+   */
+  resync_required?: boolean;
+}
+
+/** A change made while offline. Updates and deletes need the rev they were made to. */
+export type SyncMutation<TInput, TPatch = Partial<TInput>> =
+  | { op: 'create'; id: string; data: TInput }
+  | { op: 'update'; id: string; base_rev: string; data: TPatch }
+  | { op: 'delete'; id: string; base_rev: string };
+
+/** The outcome of a mutation. On conflict, document is the server's, or deleted is set. */
+export interface SyncResult<T> extends SyncChange<T> {
+  conflict?: boolean;
+}
+
+export interface SyncPushResponse<T> {
+  /** A result per mutation, in the order they were pushed. */
+  results: SyncResult<T>[];
+}
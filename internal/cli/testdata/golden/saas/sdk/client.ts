@@ -0,0 +1,55 @@
+// Auto-generated Alyx client
+
+import { CollectionClient } from './resources/collections';
+import { AuthClient } from './resources/auth';
+import { FunctionsClient } from './resources/functions';
+import { EventsClient } from './resources/events';
+import { Invitations, InvitationsInput, InvitationsPatch } from './types/collections';
+import { Members, MembersInput, MembersPatch } from './types/collections';
+import { Organizations, OrganizationsInput, OrganizationsPatch } from './types/collections';
+import { Users, UsersInput, UsersPatch } from './types/collections';
+
+export interface AlyxConfig {
+  url: string;
+  token?: string;
+  parentRequestId?: string;
+}
+
+export class AlyxClient {
+  private config: AlyxConfig;
+  public collections: {
+    invitations: CollectionClient<Invitations, InvitationsInput, InvitationsPatch>;
+    members: CollectionClient<Members, MembersInput, MembersPatch>;
+    organizations: CollectionClient<Organizations, OrganizationsInput, OrganizationsPatch>;
+    users: CollectionClient<Users, UsersInput, UsersPatch>;
+  };
+  public auth: AuthClient;
+  public functions: FunctionsClient;
+  public events: EventsClient;
+
+  constructor(config: AlyxConfig) {
+    this.config = config;
+
+    this.collections = {
+      invitations: new CollectionClient<Invitations, InvitationsInput, InvitationsPatch>(this.config.url, 'invitations', () => this.getHeaders()),
+      members: new CollectionClient<Members, MembersInput, MembersPatch>(this.config.url, 'members', () => this.getHeaders()),
+      organizations: new CollectionClient<Organizations, OrganizationsInput, OrganizationsPatch>(this.config.url, 'organizations', () => this.getHeaders()),
+      users: new CollectionClient<Users, UsersInput, UsersPatch>(this.config.url, 'users', () => this.getHeaders())
+    };
+
+    this.auth = new AuthClient(this.config.url, () => this.getHeaders());
+    this.functions = new FunctionsClient(this.config.url, () => this.getHeaders());
+    this.events = new EventsClient(this.config.url, () => this.getHeaders());
+  }
+
+  private getHeaders(): Record<string, string> {
+    const headers: Record<string, string> = {};
+    if (this.config.token) {
+      headers['Authorization'] = `Bearer ${this.config.token}`;
+    }
+    if (this.config.parentRequestId) {
+      headers['X-Parent-Request-ID'] = this.config.parentRequestId;
+    }
+    return headers;
+  }
+}
@@ -0,0 +1,33 @@
+// Auto-generated context helper for function runtime
+
+import { AlyxClient, AlyxConfig } from './client';
+import { User } from './types/auth';
+
+export interface FunctionContext {
+  alyx: AlyxClient;
+  auth: User | null;
+  env: Record<string, string | undefined>;
+}
+
+export function getContext(): FunctionContext {
+  const config: AlyxConfig = {
+    url: process.env.ALYX_URL || 'http://localhost:8090',
+    token: process.env.ALYX_INTERNAL_TOKEN,
+    parentRequestId: process.env.ALYX_REQUEST_ID,
+  };
+
+  let auth: User | null = null;
+  if (process.env.ALYX_AUTH) {
+    try {
+      auth = JSON.parse(process.env.ALYX_AUTH);
+    } catch (e) {
+      console.error('Failed to parse ALYX_AUTH:', e);
+    }
+  }
+
+  return {
+    alyx: new AlyxClient(config),
+    auth,
+    env: process.env as Record<string, string | undefined>,
+  };
+}
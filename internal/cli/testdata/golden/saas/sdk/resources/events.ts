@@ -0,0 +1,26 @@
+// Auto-generated events resource
+
+import { Event, EventType, EventPayload, EventMetadata } from '../types/events';
+import { request, RequestOptions } from './request';
+
+export class EventsClient {
+  constructor(
+    private baseURL: string,
+    private getHeaders: () => Record<string, string>
+  ) {}
+
+  async publish(event: {
+    type: EventType;
+    source: string;
+    action: string;
+    payload: EventPayload;
+    metadata?: EventMetadata;
+    process_at?: string;
+  }, options?: RequestOptions): Promise<Event> {
+    return request<Event>(`${this.baseURL}/api/events`, {
+      method: 'POST',
+      headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
+      body: JSON.stringify(event),
+    }, options);
+  }
+}
@@ -0,0 +1,71 @@
+// Auto-generated tests for the request helper. Run with npm test.
+
+import { test } from 'node:test';
+import assert from 'node:assert/strict';
+import { request, AlyxAbortError } from './request';
+
+/** Replaces fetch for the duration of fn. */
+async function withFetch(mock: typeof fetch, fn: () => Promise<void>): Promise<void> {
+  const original = globalThis.fetch;
+  globalThis.fetch = mock;
+  try {
+    await fn();
+  } finally {
+    globalThis.fetch = original;
+  }
+}
+
+/** A fetch that never responds, and rejects once its signal is aborted, like fetch does. */
+const hangingFetch = ((_url: string | URL | Request, init?: RequestInit) =>
+  new Promise<Response>((_resolve, reject) => {
+    const aborted = () => reject(new DOMException('Aborted', 'AbortError'));
+    if (init?.signal?.aborted) aborted();
+    init?.signal?.addEventListener('abort', aborted);
+  })) as typeof fetch;
+
+test('a signal cancels a pending request', async () => {
+  await withFetch(hangingFetch, async () => {
+    const controller = new AbortController();
+    const pending = request('http://alyx.test/api/collections/posts', {}, { signal: controller.signal });
+    controller.abort();
+    await assert.rejects(pending, (err: unknown) => err instanceof AlyxAbortError && !err.timedOut);
+  });
+});
+
+test('an aborted signal cancels the request before it is sent', async () => {
+  await withFetch(hangingFetch, async () => {
+    const controller = new AbortController();
+    controller.abort();
+    await assert.rejects(
+      request('http://alyx.test/api/collections/posts', {}, { signal: controller.signal }),
+      AlyxAbortError
+    );
+  });
+});
+
+test('timeoutMs cancels a request that takes too long', async () => {
+  await withFetch(hangingFetch, async () => {
+    await assert.rejects(
+      request('http://alyx.test/api/collections/posts', {}, { timeoutMs: 10 }),
+      (err: unknown) => err instanceof AlyxAbortError && err.timedOut
+    );
+  });
+});
+
+test('headers are added to the request', async () => {
+  let sent: Record<string, string> = {};
+  const mock = (async (_url: string | URL | Request, init?: RequestInit) => {
+    sent = init?.headers as Record<string, string>;
+    return new Response('{"ok":true}', { status: 200 });
+  }) as typeof fetch;
+
+  await withFetch(mock, async () => {
+    const body = await request<{ ok: boolean }>(
+      'http://alyx.test/api/collections/posts',
+      { headers: { Authorization: 'Bearer token' } },
+      { headers: { 'X-Request-ID': 'abc' }, timeoutMs: 1000 }
+    );
+    assert.deepEqual(body, { ok: true });
+    assert.deepEqual(sent, { Authorization: 'Bearer token', 'X-Request-ID': 'abc' });
+  });
+});
@@ -0,0 +1,34 @@
+// Auto-generated function types
+
+export interface FunctionInfo {
+  name: string;
+  runtime: 'node' | 'python' | 'go';
+  status: 'ready' | 'error';
+  error?: string;
+}
+
+export interface FunctionInput {
+  input?: Record<string, any>;
+}
+
+export interface FunctionError {
+  code: string;
+  message: string;
+  details?: Record<string, any>;
+}
+
+export interface LogEntry {
+  level: 'debug' | 'info' | 'warn' | 'error';
+  message: string;
+  data?: Record<string, any>;
+  timestamp?: string;
+  request_id?: string;
+}
+
+export interface FunctionResponse<TOutput = Record<string, any>> {
+  success: boolean;
+  output?: TOutput;
+  error?: FunctionError;
+  logs?: LogEntry[];
+  duration_ms: number;
+}
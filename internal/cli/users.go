@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+)
+
+// usersTableWidth is the width of the separator under the users table header.
+const usersTableWidth = 100
+
+var (
+	usersEmail         string
+	usersRole          string
+	usersVerified      bool
+	usersPasswordStdin bool
+	usersListRole      string
+	usersListSearch    string
+	usersListLimit     int
+)
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage users",
+	Long: `Manage users directly in the configured database.
+
+These commands do not need the server to be running, which makes them
+suitable for bootstrapping the first admin in production.
+
+Commands:
+  create        Create a user
+  list          List users
+  set-password  Set a user's password`,
+}
+
+var usersCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a user",
+	Long: `Create a user. The password is read from stdin and must satisfy the
+password policy in alyx.yaml.
+
+Examples:
+  echo "$ADMIN_PASSWORD" | alyx users create --email admin@example.com --role admin --password-stdin`,
+	RunE: runUsersCreate,
+}
+
+var usersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Long:  `List users, newest first.`,
+	RunE:  runUsersList,
+}
+
+var usersSetPasswordCmd = &cobra.Command{
+	Use:   "set-password",
+	Short: "Set a user's password",
+	Long: `Set a user's password. The password is read from stdin and must satisfy
+the password policy in alyx.yaml.
+
+Examples:
+  echo "$NEW_PASSWORD" | alyx users set-password --email admin@example.com --password-stdin`,
+	RunE: runUsersSetPassword,
+}
+
+func init() {
+	usersCreateCmd.Flags().StringVar(&usersEmail, "email", "", "Email address (required)")
+	usersCreateCmd.Flags().StringVar(&usersRole, "role", auth.RoleUser, "Role (user, admin, or a role declared in the schema)")
+	usersCreateCmd.Flags().BoolVar(&usersVerified, "verified", true, "Mark the email address as verified")
+	usersCreateCmd.Flags().BoolVar(&usersPasswordStdin, "password-stdin", false, "Read the password from stdin (required)")
+	_ = usersCreateCmd.MarkFlagRequired("email")
+
+	usersListCmd.Flags().StringVar(&usersListRole, "role", "", "Only list users with this role")
+	usersListCmd.Flags().StringVar(&usersListSearch, "search", "", "Only list users whose email contains this text")
+	usersListCmd.Flags().IntVar(&usersListLimit, "limit", 50, "Maximum number of users to list")
+
+	usersSetPasswordCmd.Flags().StringVar(&usersEmail, "email", "", "Email address (required)")
+	usersSetPasswordCmd.Flags().BoolVar(&usersPasswordStdin, "password-stdin", false, "Read the password from stdin (required)")
+	_ = usersSetPasswordCmd.MarkFlagRequired("email")
+
+	usersCmd.AddCommand(usersCreateCmd)
+	usersCmd.AddCommand(usersListCmd)
+	usersCmd.AddCommand(usersSetPasswordCmd)
+
+	rootCmd.AddCommand(usersCmd)
+}
+
+func getAuthService() (*auth.Service, *database.DB, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		cfg = config.Default()
+	}
+
+	db, err := database.Open(&cfg.Database)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	svc := auth.NewService(db, &cfg.Auth)
+	if path := resolveSchemaPath(""); path != "" {
+		if s, err := loadSchema(path); err == nil {
+			svc.SetRoles(s.AllRoles())
+			svc.SetUserMetadata(s.UserMetadata)
+			svc.SetUserReferences(s.UserReferences())
+		}
+	}
+
+	return svc, db, nil
+}
+
+// readPassword reads a single line from r, without its line ending.
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("no password provided on stdin")
+	}
+	return password, nil
+}
+
+func runUsersCreate(cmd *cobra.Command, args []string) error {
+	if !usersPasswordStdin {
+		return fmt.Errorf("--password-stdin is required; pipe the password to this command")
+	}
+	password, err := readPassword(cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+
+	svc, db, err := getAuthService()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer svc.Stop()
+
+	user, err := svc.CreateUserByAdmin(context.Background(), auth.CreateUserInput{
+		Email:    usersEmail,
+		Password: password,
+		Role:     usersRole,
+		Verified: usersVerified,
+	})
+	if err != nil {
+		return fmt.Errorf("creating user: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "  ✓ Created %s user %s (%s)\n", user.Role, user.Email, user.ID)
+	return nil
+}
+
+func runUsersList(cmd *cobra.Command, args []string) error {
+	svc, db, err := getAuthService()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer svc.Stop()
+
+	result, err := svc.ListUsers(context.Background(), auth.ListUsersOptions{
+		Limit:  usersListLimit,
+		Role:   usersListRole,
+		Search: usersListSearch,
+	})
+	if err != nil {
+		return fmt.Errorf("listing users: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	if len(result.Users) == 0 {
+		fmt.Fprintln(out, "No users found.")
+		return nil
+	}
+
+	fmt.Fprintf(out, "%-38s %-32s %-8s %-9s %-16s\n", "ID", "EMAIL", "ROLE", "VERIFIED", "CREATED")
+	fmt.Fprintln(out, strings.Repeat("-", usersTableWidth))
+	for _, u := range result.Users {
+		fmt.Fprintf(out, "%-38s %-32s %-8s %-9t %-16s\n", u.ID, u.Email, u.Role, u.Verified, u.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	if result.Total > len(result.Users) {
+		fmt.Fprintf(out, "\nShowing %d of %d users.\n", len(result.Users), result.Total)
+	}
+
+	return nil
+}
+
+func runUsersSetPassword(cmd *cobra.Command, args []string) error {
+	if !usersPasswordStdin {
+		return fmt.Errorf("--password-stdin is required; pipe the password to this command")
+	}
+	password, err := readPassword(cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+
+	svc, db, err := getAuthService()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer svc.Stop()
+
+	ctx := context.Background()
+	user, err := svc.GetUserByEmail(ctx, usersEmail)
+	if err != nil {
+		return fmt.Errorf("finding user: %w", err)
+	}
+
+	if err := svc.SetPassword(ctx, user.ID, password); err != nil {
+		return fmt.Errorf("setting password: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "  ✓ Password updated for %s\n", user.Email)
+	return nil
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+)
+
+// runUsersCommand runs "alyx users ..." against a project config in dir.
+func runUsersCommand(t *testing.T, dir, stdin string, args ...string) (string, error) {
+	t.Helper()
+
+	usersEmail, usersRole, usersVerified, usersPasswordStdin = "", auth.RoleUser, true, false
+	usersListRole, usersListSearch, usersListLimit = "", "", 50
+
+	var out bytes.Buffer
+	rootCmd.SetIn(strings.NewReader(stdin))
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs(append([]string{"--config", filepath.Join(dir, "alyx.yaml"), "users"}, args...))
+	t.Cleanup(func() {
+		rootCmd.SetIn(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		cfgFile = ""
+	})
+
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func usersTestProject(t *testing.T) (string, *config.Config) {
+	t.Helper()
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "data", "alyx.db")
+	yaml := "database:\n  path: " + dbPath + "\nauth:\n  password:\n    min_length: 12\n"
+	if err := os.WriteFile(filepath.Join(dir, "alyx.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Database.Path = dbPath
+	cfg.Auth.Password.MinLength = 12
+	return dir, cfg
+}
+
+func TestUsersCreateAndSetPassword(t *testing.T) {
+	dir, cfg := usersTestProject(t)
+
+	out, err := runUsersCommand(t, dir, "correct-horse-battery\n", "create", "--email", "Admin@Example.com", "--role", "admin", "--password-stdin")
+	if err != nil {
+		t.Fatalf("users create failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "admin user admin@example.com") {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	if _, err := runUsersCommand(t, dir, "short\n", "create", "--email", "weak@example.com", "--password-stdin"); err == nil || !strings.Contains(err.Error(), "password validation") {
+		t.Errorf("expected password policy from alyx.yaml to be enforced, got %v", err)
+	}
+	if _, err := runUsersCommand(t, dir, "", "create", "--email", "nopass@example.com"); err == nil {
+		t.Error("expected create without --password-stdin to fail")
+	}
+
+	out, err = runUsersCommand(t, dir, "", "list", "--role", "admin")
+	if err != nil {
+		t.Fatalf("users list failed: %v", err)
+	}
+	if !strings.Contains(out, "admin@example.com") || strings.Contains(out, "weak@example.com") {
+		t.Errorf("unexpected list output: %s", out)
+	}
+
+	if out, err := runUsersCommand(t, dir, "a-brand-new-password\n", "set-password", "--email", "admin@example.com", "--password-stdin"); err != nil {
+		t.Fatalf("users set-password failed: %v\n%s", err, out)
+	}
+
+	db, err := database.Open(&cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	svc := auth.NewService(db, &cfg.Auth)
+	defer svc.Stop()
+
+	user, _, err := svc.Login(context.Background(), auth.LoginInput{Email: "admin@example.com", Password: "a-brand-new-password"}, "", "")
+	if err != nil {
+		t.Fatalf("login with new password failed: %v", err)
+	}
+	if user.Role != auth.RoleAdmin || !user.Verified {
+		t.Errorf("expected a verified admin, got role=%s verified=%t", user.Role, user.Verified)
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	got, err := readPassword(strings.NewReader("s3cret value\r\nignored\n"))
+	if err != nil || got != "s3cret value" {
+		t.Errorf("readPassword() = %q, %v", got, err)
+	}
+	if _, err := readPassword(strings.NewReader("")); err == nil {
+		t.Error("expected error for empty stdin")
+	}
+}
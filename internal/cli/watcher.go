@@ -9,6 +9,8 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/schema"
 )
 
 // EventType represents the type of file change event.
@@ -252,17 +254,19 @@ func matchesPattern(path, pattern string) bool {
 	return filepath.Dir(path) == pattern
 }
 
-// SchemaWatcher is a specialized watcher for schema file changes.
+// SchemaWatcher is a specialized watcher for schema file or directory changes.
 type SchemaWatcher struct {
 	watcher    *Watcher
 	schemaPath string
 	onChange   func(path string)
 }
 
-// NewSchemaWatcher creates a watcher for schema file changes.
 const watchDebounce = 200 * time.Millisecond
 
-func NewSchemaWatcher(schemaPath string, onChange func(path string)) (*SchemaWatcher, error) {
+// NewSchemaWatcher creates a watcher for schema file changes. When
+// overlayPath is set, changes to the environment overlay there also call
+// onChange with schemaPath.
+func NewSchemaWatcher(schemaPath, overlayPath string, onChange func(path string)) (*SchemaWatcher, error) {
 	w, err := NewWatcher(WithDebounce(watchDebounce))
 	if err != nil {
 		return nil, err
@@ -274,6 +278,41 @@ func NewSchemaWatcher(schemaPath string, onChange func(path string)) (*SchemaWat
 		onChange:   onChange,
 	}
 
+	if overlayPath != "" {
+		// The overlay may not exist yet, so its directory is watched instead.
+		if err := w.WatchDir(filepath.Dir(overlayPath), func(event FileEvent) {
+			if event.Path != overlayPath {
+				return
+			}
+			log.Debug().Str("event", event.Type.String()).Str("path", event.Path).Msg("Schema overlay changed")
+			if sw.onChange != nil {
+				sw.onChange(schemaPath)
+			}
+		}); err != nil {
+			_ = w.Stop()
+			return nil, err
+		}
+	}
+
+	if schema.IsDir(schemaPath) {
+		// Any file added, edited, or removed in a schema directory changes the
+		// merged schema, so the whole directory is reloaded.
+		if err := w.WatchDir(schemaPath, func(event FileEvent) {
+			if !schema.IsSchemaFile(event.Path) {
+				return
+			}
+			log.Debug().Str("event", event.Type.String()).Str("path", event.Path).Msg("Schema file changed")
+			if sw.onChange != nil {
+				sw.onChange(schemaPath)
+			}
+		}); err != nil {
+			_ = w.Stop()
+			return nil, err
+		}
+
+		return sw, nil
+	}
+
 	if err := w.Watch(schemaPath, func(event FileEvent) {
 		if event.Type == EventModified || event.Type == EventCreated {
 			log.Debug().Str("event", event.Type.String()).Str("path", event.Path).Msg("Schema file changed")
@@ -370,10 +409,12 @@ type DevWatcher struct {
 
 // DevWatcherConfig configures the development watcher.
 type DevWatcherConfig struct {
-	SchemaPath       string
-	FunctionsPath    string
-	OnSchemaChange   func(path string)
-	OnFunctionChange func(path string, eventType EventType)
+	SchemaPath string
+	// SchemaOverlayPath is the active environment's schema overlay, if any.
+	SchemaOverlayPath string
+	FunctionsPath     string
+	OnSchemaChange    func(path string)
+	OnFunctionChange  func(path string, eventType EventType)
 }
 
 // NewDevWatcher creates a combined watcher for development mode.
@@ -383,7 +424,7 @@ func NewDevWatcher(cfg DevWatcherConfig) (*DevWatcher, error) {
 	var err error
 
 	if cfg.SchemaPath != "" {
-		schemaWatcher, err = NewSchemaWatcher(cfg.SchemaPath, cfg.OnSchemaChange)
+		schemaWatcher, err = NewSchemaWatcher(cfg.SchemaPath, cfg.SchemaOverlayPath, cfg.OnSchemaChange)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSchemaWatcherOverlay(t *testing.T) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "schema.yaml")
+	overlayPath := filepath.Join(dir, "schema.staging.yaml")
+	if err := os.WriteFile(schemaPath, []byte("collections: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan string, 10)
+	sw, err := NewSchemaWatcher(schemaPath, overlayPath, func(path string) { changed <- path })
+	if err != nil {
+		t.Fatalf("NewSchemaWatcher: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sw.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		_ = sw.Stop()
+	})
+
+	// Creating the overlay reloads the schema it applies to.
+	if err := os.WriteFile(overlayPath, []byte("collections: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case path := <-changed:
+		if path != schemaPath {
+			t.Errorf("onChange called with %q, want %q", path, schemaPath)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("overlay change did not reload the schema")
+	}
+}
@@ -64,6 +64,9 @@ type Config struct {
 	ServerURL string
 	// PackageName is used for Go package naming.
 	PackageName string
+	// Dates is the TypeScript type of timestamp fields: "string" (the
+	// default) or "Date", in which case the client revives them.
+	Dates string
 }
 
 // DefaultConfig returns a default configuration.
@@ -193,11 +196,15 @@ func isUpper(r rune) bool {
 	return r >= 'A' && r <= 'Z'
 }
 
-// sortedCollectionNames returns collection names in sorted order for deterministic output.
+// sortedCollectionNames returns the names of the exposed collections in
+// sorted order for deterministic output. Collections with expose: false have
+// no REST API, so clients get no types for them.
 func sortedCollectionNames(s *schema.Schema) []string {
 	names := make([]string, 0, len(s.Collections))
-	for name := range s.Collections {
-		names = append(names, name)
+	for name, coll := range s.Collections {
+		if coll.Exposed() {
+			names = append(names, name)
+		}
 	}
 	sort.Strings(names)
 	return names
@@ -51,8 +51,8 @@ func (g *GoGenerator) generateTypes(s *schema.Schema) string {
 
 	// Check if we need time import
 	needsTime := false
-	for _, coll := range s.Collections {
-		for _, field := range coll.Fields {
+	for _, name := range sortedCollectionNames(s) {
+		for _, field := range s.Collections[name].Fields {
 			if field.Type == schema.FieldTypeTimestamp {
 				needsTime = true
 				break
@@ -138,13 +138,14 @@ func (g *GoGenerator) generateInputStructs(b *strings.Builder, name string, coll
 
 		// For optional fields, use pointers
 		goType := field.Type.GoType(false)
-		if field.Nullable || field.HasDefault() {
+		optional := coll.OptionalOnCreate(field)
+		if optional {
 			goType = "*" + goType
 		}
 
 		fieldName := toPascalCase(field.Name)
 		jsonTag := field.Name
-		if field.Nullable || field.HasDefault() {
+		if optional {
 			jsonTag += ",omitempty"
 		}
 
@@ -174,6 +175,10 @@ func (g *GoGenerator) generateInputStructs(b *strings.Builder, name string, coll
 
 		b.WriteString(fmt.Sprintf("\t%s %s `json:\"%s\"`\n", fieldName, goType, jsonTag))
 	}
+	if coll.HasSlugFields() {
+		b.WriteString("\t// RegenerateSlug regenerates slug fields from their source fields.\n")
+		b.WriteString(fmt.Sprintf("\tRegenerateSlug bool `json:\"%s,omitempty\"`\n", schema.RegenerateSlugKey))
+	}
 
 	b.WriteString("}\n")
 }
@@ -144,7 +144,7 @@ func (g *PythonGenerator) generateCreateInput(b *strings.Builder, className stri
 		pyType := field.Type.PythonType(false)
 		fieldName := toSnakeCase(field.Name)
 
-		if field.Nullable || field.HasDefault() {
+		if coll.OptionalOnCreate(field) {
 			optional = append(optional, fmt.Sprintf("    %s: Optional[%s] = None", fieldName, pyType))
 		} else {
 			required = append(required, fmt.Sprintf("    %s: %s", fieldName, pyType))
@@ -182,6 +182,10 @@ func (g *PythonGenerator) generateUpdateInput(b *strings.Builder, className stri
 		b.WriteString(fmt.Sprintf("    %s: Optional[%s] = None\n", fieldName, pyType))
 		hasFields = true
 	}
+	if coll.HasSlugFields() {
+		b.WriteString(fmt.Sprintf("    %s: Optional[bool] = None\n", schema.RegenerateSlugKey))
+		hasFields = true
+	}
 
 	if !hasFields {
 		b.WriteString("    pass\n")
@@ -55,9 +55,34 @@ func (g *TypeScriptGenerator) generateTypes(s *schema.Schema) string {
 
 	b.WriteString("// Generated by Alyx - DO NOT EDIT\n\n")
 
+	// Roles declared in the schema, plus the built-in user and admin
+	roles := make([]string, 0, len(s.AllRoles()))
+	for _, r := range s.AllRoles() {
+		roles = append(roles, fmt.Sprintf("'%s'", r))
+	}
+	b.WriteString("/** A role that can be assigned to a user. */\n")
+	b.WriteString(fmt.Sprintf("export type UserRole = %s;\n\n", strings.Join(roles, " | ")))
+
+	g.generateUserMetadata(&b, s.UserMetadata)
+
+	if hasBlobFields(s) {
+		b.WriteString(`/** Metadata of a blob field's content, read and written with downloadBlob and uploadBlob. */
+export interface BlobInfo {
+  /** Content length in bytes. */
+  size: number;
+  /** Content-Type stored with the content. */
+  mime: string;
+  /** Hex SHA-256 of the content. */
+  etag: string;
+}
+
+`)
+	}
+
 	// Generate interface for each collection
 	for _, name := range sortedCollectionNames(s) {
 		coll := s.Collections[name]
+		g.generateFieldTypes(&b, name, coll)
 		g.generateCollectionInterface(&b, name, coll)
 		b.WriteString("\n")
 	}
@@ -69,9 +94,166 @@ func (g *TypeScriptGenerator) generateTypes(s *schema.Schema) string {
 		b.WriteString("\n")
 	}
 
+	g.generateFieldKinds(&b, s)
+
 	return b.String()
 }
 
+// generateFieldTypes declares the types of a collection's json fields that
+// set tsType.
+func (g *TypeScriptGenerator) generateFieldTypes(b *strings.Builder, name string, coll *schema.Collection) {
+	for _, field := range coll.OrderedFields() {
+		if field.Internal || field.TSType == "" {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("/** Type of %s.%s. */\n", name, field.Name))
+		b.WriteString(fmt.Sprintf("export type %s = %s;\n\n", fieldTypeName(name, field.Name), field.TSType))
+	}
+}
+
+// generateFieldKinds writes the fieldKinds map of the timestamp and json
+// fields of each collection, which the client uses to revive documents.
+func (g *TypeScriptGenerator) generateFieldKinds(b *strings.Builder, s *schema.Schema) {
+	b.WriteString("/** Kind of a field whose JSON value needs converting. */\n")
+	b.WriteString("export type FieldKind = 'timestamp' | 'json';\n\n")
+	b.WriteString("/** Fields with a kind that needs converting, by collection and field. */\n")
+	b.WriteString("export const fieldKinds: Record<string, Record<string, FieldKind>> = {\n")
+	for _, name := range sortedCollectionNames(s) {
+		var kinds []string
+		for _, field := range s.Collections[name].OrderedFields() {
+			if field.Internal {
+				continue
+			}
+			switch field.Type {
+			case schema.FieldTypeTimestamp:
+				kinds = append(kinds, fmt.Sprintf("%s: 'timestamp'", field.Name))
+			case schema.FieldTypeJSON:
+				kinds = append(kinds, fmt.Sprintf("%s: 'json'", field.Name))
+			}
+		}
+		if len(kinds) == 0 {
+			b.WriteString(fmt.Sprintf("  %s: {},\n", name))
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  %s: { %s },\n", name, strings.Join(kinds, ", ")))
+	}
+	b.WriteString("};\n")
+}
+
+// fieldTSType returns the TypeScript type of a field in documents, or in
+// inputs when input is set. Timestamps are ISO strings unless the Dates
+// setting is Date; inputs then accept either.
+func (g *TypeScriptGenerator) fieldTSType(name string, field *schema.Field, nullable, input bool) string {
+	var base string
+	switch {
+	case field.Type == schema.FieldTypeTimestamp && g.cfg.Dates == "Date":
+		base = "Date"
+		if input {
+			base = "Date | string"
+		}
+	case field.Type == schema.FieldTypeTimestamp:
+		base = "string"
+	case field.TSType != "":
+		base = fieldTypeName(name, field.Name)
+	default:
+		return field.Type.TypeScriptType(nullable)
+	}
+	if nullable {
+		return base + " | null"
+	}
+	return base
+}
+
+// fieldTypeName is the name of the type declared for a field's tsType, such
+// as PostsTags for posts.tags.
+func fieldTypeName(collection, field string) string {
+	return toPascalCase(collection) + toPascalCase(field)
+}
+
+// generateUserMetadata writes the UserMetadata type from the schema's
+// userMetadata declaration, and the merge patch type accepted when updating it.
+func (g *TypeScriptGenerator) generateUserMetadata(b *strings.Builder, m *schema.MetadataSchema) {
+	b.WriteString("/** Custom data stored on a user. */\n")
+	if m == nil || len(m.Properties) == 0 {
+		if m != nil && !m.AllowsAdditional() {
+			b.WriteString("export type UserMetadata = Record<string, never>;\n\n")
+		} else {
+			b.WriteString("export type UserMetadata = Record<string, unknown>;\n\n")
+		}
+	} else {
+		b.WriteString("export interface UserMetadata {\n")
+		for _, name := range m.SortedProperties() {
+			prop := m.Properties[name]
+			if prop.Description != "" {
+				b.WriteString(fmt.Sprintf("  /** %s */\n", prop.Description))
+			}
+			optional := "?"
+			if m.IsRequired(name) {
+				optional = ""
+			}
+			b.WriteString(fmt.Sprintf("  %s%s: %s;\n", name, optional, metadataTSType(prop)))
+		}
+		if m.AllowsAdditional() {
+			b.WriteString("  [key: string]: unknown;\n")
+		}
+		b.WriteString("}\n\n")
+	}
+
+	b.WriteString("/** A JSON merge patch for UserMetadata: null removes a key. */\n")
+	b.WriteString("export type UserMetadataPatch = { [K in keyof UserMetadata]?: UserMetadata[K] | null };\n\n")
+}
+
+// metadataTSType returns the TypeScript type for a userMetadata value.
+func metadataTSType(m *schema.MetadataSchema) string {
+	if len(m.Enum) > 0 {
+		values := make([]string, len(m.Enum))
+		for i, e := range m.Enum {
+			if str, ok := e.(string); ok {
+				values[i] = fmt.Sprintf("'%s'", str)
+			} else {
+				values[i] = fmt.Sprint(e)
+			}
+		}
+		return strings.Join(values, " | ")
+	}
+
+	switch m.ValueType() {
+	case schema.MetadataTypeString:
+		return "string"
+	case schema.MetadataTypeInteger, schema.MetadataTypeNumber:
+		return "number"
+	case schema.MetadataTypeBoolean:
+		return "boolean"
+	case schema.MetadataTypeArray:
+		if m.Items == nil {
+			return "unknown[]"
+		}
+		item := metadataTSType(m.Items)
+		if strings.Contains(item, " | ") {
+			item = "(" + item + ")"
+		}
+		return item + "[]"
+	case schema.MetadataTypeObject:
+		if len(m.Properties) == 0 {
+			return "Record<string, unknown>"
+		}
+		parts := make([]string, 0, len(m.Properties)+1)
+		for _, name := range m.SortedProperties() {
+			optional := "?"
+			if m.IsRequired(name) {
+				optional = ""
+			}
+			parts = append(parts, fmt.Sprintf("%s%s: %s", name, optional, metadataTSType(m.Properties[name])))
+		}
+		if m.AllowsAdditional() {
+			parts = append(parts, "[key: string]: unknown")
+		}
+		return "{ " + strings.Join(parts, "; ") + " }"
+	default:
+		return "unknown"
+	}
+}
+
 func (g *TypeScriptGenerator) generateCollectionInterface(b *strings.Builder, name string, coll *schema.Collection) {
 	typeName := toPascalCase(name)
 
@@ -84,14 +266,22 @@ func (g *TypeScriptGenerator) generateCollectionInterface(b *strings.Builder, na
 			continue
 		}
 
-		tsType := field.Type.TypeScriptType(field.Nullable)
+		tsType := g.fieldTSType(name, field, field.Nullable, false)
+		if field.Type == schema.FieldTypeBlob {
+			// Documents carry the blob's metadata, not its content.
+			tsType = "BlobInfo"
+			if field.Nullable {
+				tsType += " | null"
+			}
+		}
+		// Fields with a read rule are omitted for callers it denies.
 		optional := ""
-		if field.Nullable {
+		if field.Nullable || field.ReadRule != "" {
 			optional = "?"
 		}
 
 		// Add JSDoc comment for field if it has validation
-		if field.Validate != nil || field.References != "" {
+		if field.Validate != nil || field.References != "" || field.ReadRule != "" {
 			b.WriteString(fmt.Sprintf("  /** %s */\n", g.fieldDoc(field)))
 		}
 
@@ -129,9 +319,9 @@ func (g *TypeScriptGenerator) generateInputTypes(b *strings.Builder, name string
 			continue
 		}
 
-		tsType := field.Type.TypeScriptType(false)
+		tsType := g.inputType(name, field)
 		optional := ""
-		if field.Nullable || field.HasDefault() {
+		if coll.OptionalOnCreate(field) {
 			optional = "?"
 		}
 
@@ -140,8 +330,8 @@ func (g *TypeScriptGenerator) generateInputTypes(b *strings.Builder, name string
 
 	b.WriteString("}\n\n")
 
-	// Update input type (all fields optional)
-	b.WriteString(fmt.Sprintf("/** Input for updating a %s. */\n", typeName))
+	// Update input type (all fields optional, null clears nullable fields)
+	b.WriteString(fmt.Sprintf("/** Input for updating a %s. Absent fields are left unchanged; null clears a nullable field. */\n", typeName))
 	b.WriteString(fmt.Sprintf("export interface %sUpdateInput {\n", typeName))
 
 	for _, field := range coll.OrderedFields() {
@@ -156,13 +346,45 @@ func (g *TypeScriptGenerator) generateInputTypes(b *strings.Builder, name string
 			continue
 		}
 
-		tsType := field.Type.TypeScriptType(false)
+		tsType := g.inputType(name, field)
+		if field.Nullable && tsType != "null" {
+			tsType += " | null"
+		}
 		b.WriteString(fmt.Sprintf("  %s?: %s;\n", field.Name, tsType))
 	}
+	if coll.HasSlugFields() {
+		b.WriteString("  /** Regenerate slug fields from their source fields. */\n")
+		b.WriteString(fmt.Sprintf("  %s?: boolean;\n", schema.RegenerateSlugKey))
+	}
 
 	b.WriteString("}\n")
 }
 
+// inputType returns the TypeScript type of a field in create and update
+// inputs. Blob content is sent base64 encoded; content stored in a bucket can
+// only be cleared, and is uploaded with uploadBlob.
+func (g *TypeScriptGenerator) inputType(name string, field *schema.Field) string {
+	if field.Type != schema.FieldTypeBlob {
+		return g.fieldTSType(name, field, false, true)
+	}
+	if field.Storage != "" {
+		return "null"
+	}
+	return "string"
+}
+
+// hasBlobFields reports whether any collection has a public blob field.
+func hasBlobFields(s *schema.Schema) bool {
+	for _, name := range sortedCollectionNames(s) {
+		for _, field := range s.Collections[name].Fields {
+			if field.Type == schema.FieldTypeBlob && !field.Internal {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (g *TypeScriptGenerator) fieldDoc(field *schema.Field) string {
 	var parts []string
 
@@ -191,6 +413,14 @@ func (g *TypeScriptGenerator) fieldDoc(field *schema.Field) string {
 		}
 	}
 
+	if field.Encrypted {
+		parts = append(parts, "encrypted at rest; cannot be filtered or sorted")
+	}
+
+	if field.ReadRule != "" {
+		parts = append(parts, fmt.Sprintf("only included when %s", field.ReadRule))
+	}
+
 	if len(parts) == 0 {
 		return field.Name
 	}
@@ -204,13 +434,37 @@ func (g *TypeScriptGenerator) generateClient(s *schema.Schema) string {
 
 	// Import types
 	b.WriteString("import type {\n")
+	b.WriteString("  UserRole,\n")
+	b.WriteString("  UserMetadata,\n")
+	b.WriteString("  UserMetadataPatch,\n")
+	blobs := hasBlobFields(s)
+	if blobs {
+		b.WriteString("  BlobInfo,\n")
+	}
 	for _, name := range sortedCollectionNames(s) {
 		typeName := toPascalCase(name)
 		b.WriteString(fmt.Sprintf("  %s,\n", typeName))
 		b.WriteString(fmt.Sprintf("  %sCreateInput,\n", typeName))
 		b.WriteString(fmt.Sprintf("  %sUpdateInput,\n", typeName))
 	}
-	b.WriteString("} from './types';\n\n")
+	b.WriteString("} from './types';\n")
+	revive := g.cfg.Dates == "Date"
+	if revive {
+		b.WriteString("import { fieldKinds } from './types';\n\n")
+		b.WriteString(`/** Converts the ISO strings in a document's timestamp fields to Dates. */
+function revive<T>(collection: string, doc: any): T {
+  const kinds = fieldKinds[collection];
+  if (!kinds || !doc || typeof doc !== 'object') return doc;
+  for (const field of Object.keys(kinds)) {
+    if (kinds[field] === 'timestamp' && typeof doc[field] === 'string') {
+      doc[field] = new Date(doc[field]);
+    }
+  }
+  return doc;
+}
+`)
+	}
+	b.WriteString("\n")
 
 	if len(s.Buckets) > 0 {
 		g.generateStorageTypes(&b)
@@ -231,22 +485,39 @@ export interface QueryOptions<T> {
   limit?: number;
   offset?: number;
   expand?: string[];
+  /** How to compute total: 'exact' (default), 'none' to skip counting, or 'estimate'. */
+  total?: 'exact' | 'none' | 'estimate';
 }
 
 /** Paginated response. */
 export interface PaginatedResponse<T> {
   items: T[];
-  total: number;
+  /** Omitted when the list was requested with total: 'none'. */
+  total?: number;
   page: number;
   perPage: number;
 }
 
-/** Subscription callback. */
+/**
+ * Subscription callback. 'synced' follows the initial snapshot or catch-up;
+ * 'expired' means the since cursor is too old and the subscription should be
+ * restarted with includeSnapshot.
+ */
 export type SubscriptionCallback<T> = (event: {
-  type: 'snapshot' | 'insert' | 'update' | 'delete';
+  type: 'snapshot' | 'insert' | 'update' | 'delete' | 'synced' | 'expired' | 'error';
   data: T | T[];
+  /** Change feed position; pass the latest one as since to resume. */
+  cursor?: number;
 }) => void;
 
+/** Options for realtime subscriptions. */
+export interface SubscribeOptions<T> extends Pick<QueryOptions<T>, 'filter' | 'sort' | 'limit' | 'expand'> {
+  /** Deliver current documents as insert events before live changes. */
+  includeSnapshot?: boolean;
+  /** Replay changes after this cursor (change ID or ISO timestamp) before live changes. */
+  since?: number | string;
+}
+
 /** Alyx client configuration. */
 export interface AlyxClientConfig {
   url: string;
@@ -255,7 +526,24 @@ export interface AlyxClientConfig {
 
 `)
 
-	// Collection class
+	// Collection class. Documents in responses are revived when timestamps
+	// are Dates.
+	docOpen, docClose := "return ", ""
+	listBody := `    return this.client.request<PaginatedResponse<T>>(` + "`" + `GET /api/collections/${this.name}?${params}` + "`" + `);
+`
+	upsertBody := `    return this.client.request<{ created: boolean; document: T }>(` + "`" + `PUT /api/collections/${this.name}/upsert?key=${encodeURIComponent(key)}` + "`" + `, { body: data });
+`
+	if revive {
+		docOpen, docClose = "return revive<T>(this.name, await ", ")"
+		listBody = `    const res = await this.client.request<PaginatedResponse<T>>(` + "`" + `GET /api/collections/${this.name}?${params}` + "`" + `);
+    res.items = res.items.map((doc) => revive<T>(this.name, doc));
+    return res;
+`
+		upsertBody = `    const res = await this.client.request<{ created: boolean; document: T }>(` + "`" + `PUT /api/collections/${this.name}/upsert?key=${encodeURIComponent(key)}` + "`" + `, { body: data });
+    res.document = revive<T>(this.name, res.document);
+    return res;
+`
+	}
 	b.WriteString(`/** Collection provides CRUD operations for a specific collection. */
 export class Collection<T, TCreate, TUpdate> {
   constructor(
@@ -266,34 +554,46 @@ export class Collection<T, TCreate, TUpdate> {
   /** List documents with optional filtering. */
   async list(options?: QueryOptions<T>): Promise<PaginatedResponse<T>> {
     const params = this.buildQueryParams(options);
-    return this.client.request<PaginatedResponse<T>>(` + "`" + `GET /api/collections/${this.name}?${params}` + "`" + `);
-  }
+` + listBody + `  }
 
   /** Get a single document by ID. */
   async get(id: string, expand?: string[]): Promise<T> {
     const params = expand?.length ? ` + "`" + `?expand=${expand.join(',')}` + "`" + ` : '';
-    return this.client.request<T>(` + "`" + `GET /api/collections/${this.name}/${id}${params}` + "`" + `);
+    ` + docOpen + `this.client.request<T>(` + "`" + `GET /api/collections/${this.name}/${id}${params}` + "`" + `)` + docClose + `;
   }
 
   /** Create a new document. */
   async create(data: TCreate): Promise<T> {
-    return this.client.request<T>(` + "`" + `POST /api/collections/${this.name}` + "`" + `, { body: data });
+    ` + docOpen + `this.client.request<T>(` + "`" + `POST /api/collections/${this.name}` + "`" + `, { body: data })` + docClose + `;
   }
 
   /** Update an existing document. */
   async update(id: string, data: TUpdate): Promise<T> {
-    return this.client.request<T>(` + "`" + `PATCH /api/collections/${this.name}/${id}` + "`" + `, { body: data });
+    ` + docOpen + `this.client.request<T>(` + "`" + `PATCH /api/collections/${this.name}/${id}` + "`" + `, { body: data })` + docClose + `;
   }
 
+  /**
+   * Update the document whose key field (the primary key or a unique field)
+   * matches data's, or create data when there is none.
+   */
+  async upsert(key: keyof T & string, data: TCreate): Promise<{ created: boolean; document: T }> {
+` + upsertBody + `  }
+
   /** Delete a document. */
   async delete(id: string): Promise<void> {
     return this.client.request<void>(` + "`" + `DELETE /api/collections/${this.name}/${id}` + "`" + `);
   }
 
-  /** Subscribe to changes in this collection. */
+`)
+
+	if blobs {
+		g.generateBlobMethods(&b)
+	}
+
+	b.WriteString(`  /** Subscribe to changes in this collection. */
   subscribe(
     callback: SubscriptionCallback<T>,
-    options?: QueryOptions<T>,
+    options?: SubscribeOptions<T>,
   ): () => void {
     return this.client.subscribe(this.name, callback, options);
   }
@@ -326,6 +626,7 @@ export class Collection<T, TCreate, TUpdate> {
     if (options.limit) params.set('limit', String(options.limit));
     if (options.offset) params.set('offset', String(options.offset));
     if (options.expand?.length) params.set('expand', options.expand.join(','));
+    if (options.total) params.set('total', options.total);
 
     return params.toString();
   }
@@ -333,6 +634,8 @@ export class Collection<T, TCreate, TUpdate> {
 
 `)
 
+	g.generateListCollections(&b, s)
+
 	// Auth types
 	b.WriteString(`/** Auth credentials for login. */
 export interface LoginCredentials {
@@ -347,9 +650,9 @@ export interface AuthResponse {
   user: {
     id: string;
     email: string;
-    role: string;
+    role: UserRole;
     verified: boolean;
-    metadata?: Record<string, unknown>;
+    metadata?: UserMetadata;
   };
 }
 
@@ -358,7 +661,54 @@ export interface RegisterData {
   email: string;
   password: string;
   name?: string;
-  metadata?: Record<string, unknown>;
+  metadata?: UserMetadata;
+}
+
+/** The caller's quota under one rate limit. */
+export interface RateLimitStatus {
+  /** Limit name from auth.rate_limit, such as login. */
+  name: string;
+  limit: number;
+  remaining: number;
+  window_seconds: number;
+  reset: string;
+  reset_seconds: number;
+}
+
+/** Error thrown for a failed API request. */
+export class AlyxError extends Error {
+  /** HTTP status code. */
+  readonly status: number;
+  /** Error code, such as NOT_FOUND. */
+  readonly code?: string;
+  readonly details?: unknown;
+  /** Seconds to wait before retrying, set when rate limited (429). */
+  readonly retryAfter?: number;
+
+  constructor(
+    message: string,
+    status: number,
+    options?: { code?: string; details?: unknown; retryAfter?: number },
+  ) {
+    super(message);
+    this.name = 'AlyxError';
+    this.status = status;
+    this.code = options?.code;
+    this.details = options?.details;
+    this.retryAfter = options?.retryAfter;
+  }
+
+  /** Create the error for a failed response. */
+  static async fromResponse(response: Response): Promise<AlyxError> {
+    const body = await response.json().catch(() => ({}));
+    const header = response.headers.get('Retry-After') ?? response.headers.get('RateLimit-Reset');
+    const retryAfter = response.status === 429 && header !== null ? Number(header) : undefined;
+    return new AlyxError(
+      body.error || body.detail || body.message || ` + "`" + `HTTP ${response.status}` + "`" + `,
+      response.status,
+      { code: body.code, details: body.details, retryAfter },
+    );
+  }
 }
 
 `)
@@ -366,16 +716,83 @@ export interface RegisterData {
 	// Main client class
 	b.WriteString(`/** Subscription event from WebSocket. */
 export interface SubscriptionEvent<T = unknown> {
-  type: 'snapshot' | 'insert' | 'update' | 'delete';
+  type: 'snapshot' | 'insert' | 'update' | 'delete' | 'synced' | 'expired' | 'error';
   data: T | T[];
+  cursor?: number;
+}
+
+/** Server event from the system channel, not tied to a collection. */
+export interface SystemEvent {
+  /** schema_changed follows a schema change applied by an admin or a deploy. */
+  action: 'schema_changed';
+  /** The collections the event affects. */
+  collections: string[];
+}
+
+/** A user as returned by the admin users API. */
+export interface ChannelUser {
+  id: string;
+  email: string;
+  role: UserRole;
+  verified: boolean;
+  created_at: string;
+  updated_at: string;
+  metadata?: UserMetadata;
+  /** Set while the user's deletion request is in its grace period. */
+  deletion_requested_at?: string;
+}
+
+/** A deployment as listed by GET /api/admin/deploy/history. */
+export interface ChannelDeployment {
+  id: number;
+  version: string;
+  schema_hash: string;
+  functions_hash: string;
+  schema_snapshot: string;
+  deployed_at: string;
+  deployed_by?: string;
+  status: 'active' | 'rolled_back' | 'failed';
+  description?: string;
 }
 
+/**
+ * Event from a privileged channel, sent as { type: 'event', payload }.
+ * Subscribe with { type: 'subscribe', payload: { channel: '_auth' } } or
+ * '_admin' on a connection authenticated as an admin user; other
+ * connections get a FORBIDDEN error.
+ */
+export type ChannelEvent =
+  /** A user signed up, signed in with OAuth for the first time, or was created by an admin. */
+  | { channel: '_auth'; event: 'user.registered'; data: ChannelUser }
+  /** A user's email, role, verification, metadata or deletion state changed. */
+  | { channel: '_auth'; event: 'user.updated'; data: ChannelUser }
+  /** A session ended. session_id is absent when every session of the user was revoked. */
+  | {
+      channel: '_auth';
+      event: 'session.revoked';
+      data: { user_id: string; session_id?: string; reason: 'logout' | 'password_reset' | 'deletion_requested' };
+    }
+  /** A deploy was executed. */
+  | { channel: '_admin'; event: 'deploy.executed'; data: ChannelDeployment }
+  /** A schema change was applied by an admin, a deploy or a rollback. */
+  | { channel: '_admin'; event: 'schema.changed'; data: { collections: string[] } }
+  /** The config file was changed. changed is absent when the whole file was replaced. */
+  | { channel: '_admin'; event: 'config.updated'; data: { path: string; changed?: string[]; by: string } };
+
 /** Alyx client for interacting with the Alyx API. */
 export class AlyxClient {
   private url: string;
   private token?: string;
   private ws?: WebSocket;
-  private subscriptions = new Map<string, Set<(event: SubscriptionEvent) => void>>();
+  private nextMessageId = 0;
+  private outbox: string[] = [];
+  /** Subscriptions keyed by subscribe message ID until the server assigns an ID. */
+  private pending = new Map<string, {
+    callback: (event: SubscriptionEvent) => void;
+    assign: (subscriptionId: string) => void;
+  }>();
+  private subscriptions = new Map<string, (event: SubscriptionEvent) => void>();
+  private systemListeners = new Set<(event: SystemEvent) => void>();
 
   constructor(config: AlyxClientConfig) {
     this.url = config.url.replace(/\/$/, '');
@@ -409,8 +826,7 @@ export class AlyxClient {
     });
 
     if (!response.ok) {
-      const error = await response.json().catch(() => ({}));
-      throw new Error(error.message || ` + "`" + `HTTP ${response.status}` + "`" + `);
+      throw await AlyxError.fromResponse(response);
     }
 
     if (response.status === 204) {
@@ -424,58 +840,139 @@ export class AlyxClient {
   subscribe<T>(
     collection: string,
     callback: SubscriptionCallback<T>,
-    _options?: unknown,
+    options?: SubscribeOptions<T>,
   ): () => void {
     this.ensureWebSocket();
 
-    const key = collection;
-    if (!this.subscriptions.has(key)) {
-      this.subscriptions.set(key, new Set());
+    const id = String(++this.nextMessageId);
+    const cb = callback as (event: SubscriptionEvent) => void;
+    let subscriptionId: string | undefined;
+    let closed = false;
+    this.pending.set(id, {
+      callback: (event) => {
+        if (!closed) cb(event);
+      },
+      assign: (subId) => {
+        subscriptionId = subId;
+        // Unsubscribed before the server replied.
+        if (closed) {
+          this.subscriptions.delete(subId);
+          this.send({ type: 'unsubscribe', payload: { subscription_id: subId } });
+        }
+      },
+    });
+
+    const filter: Record<string, Record<string, unknown>> = {};
+    for (const [key, value] of Object.entries(options?.filter ?? {})) {
+      if (value === undefined) continue;
+      if (typeof value === 'object' && value !== null) {
+        filter[key] = Object.fromEntries(Object.entries(value).map(([op, v]) => [` + "`" + `$${op}` + "`" + `, v]));
+      } else {
+        filter[key] = { $eq: value };
+      }
     }
-    this.subscriptions.get(key)!.add(callback as (event: SubscriptionEvent) => void);
 
-    // Send subscribe message
-    this.ws?.send(JSON.stringify({
+    this.send({
+      id,
       type: 'subscribe',
-      payload: { collection },
-    }));
+      payload: {
+        collection,
+        filter: Object.keys(filter).length ? filter : undefined,
+        sort: options?.sort === undefined || Array.isArray(options.sort) ? options?.sort : [options.sort],
+        limit: options?.limit,
+        expand: options?.expand,
+        include_snapshot: options?.includeSnapshot || undefined,
+        since: options?.since,
+      },
+    });
 
     // Return unsubscribe function
     return () => {
-      const subs = this.subscriptions.get(key);
-      if (subs) {
-        subs.delete(callback);
-        if (subs.size === 0) {
-          this.subscriptions.delete(key);
-          this.ws?.send(JSON.stringify({
-            type: 'unsubscribe',
-            payload: { collection },
-          }));
-        }
+      if (closed) return;
+      closed = true;
+      this.pending.delete(id);
+      if (subscriptionId) {
+        this.subscriptions.delete(subscriptionId);
+        this.send({ type: 'unsubscribe', payload: { subscription_id: subscriptionId } });
+      }
+    };
+  }
+
+  /** Subscribe to system events, such as schema changes, via WebSocket. */
+  onSystemEvent(callback: (event: SystemEvent) => void): () => void {
+    this.ensureWebSocket();
+    if (this.systemListeners.size === 0) {
+      this.send({ type: 'subscribe', payload: { channel: 'system' } });
+    }
+    this.systemListeners.add(callback);
+
+    return () => {
+      if (!this.systemListeners.delete(callback)) return;
+      if (this.systemListeners.size === 0) {
+        this.send({ type: 'unsubscribe', payload: { channel: 'system' } });
       }
     };
   }
 
+  private send(msg: unknown): void {
+    const data = JSON.stringify(msg);
+    if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+      this.ws.send(data);
+    } else {
+      this.outbox.push(data);
+    }
+  }
+
   private ensureWebSocket(): void {
-    if (this.ws && this.ws.readyState === WebSocket.OPEN) return;
+    if (this.ws && (this.ws.readyState === WebSocket.OPEN || this.ws.readyState === WebSocket.CONNECTING)) return;
 
     const wsUrl = this.url.replace(/^http/, 'ws') + '/api/realtime';
     this.ws = new WebSocket(wsUrl);
 
+    this.ws.onopen = () => {
+      for (const data of this.outbox.splice(0)) {
+        this.ws?.send(data);
+      }
+    };
+
     this.ws.onmessage = (event) => {
       const msg = JSON.parse(event.data);
-      if (msg.type === 'delta' || msg.type === 'snapshot') {
-        const collection = msg.payload?.subscription_id?.split(':')[0];
-        const callbacks = this.subscriptions.get(collection);
-        if (callbacks) {
-          const subscriptionEvent: SubscriptionEvent = {
-            type: msg.type === 'snapshot' ? 'snapshot' : (msg.payload?.action || 'update'),
-            data: msg.payload?.data,
-          };
-          for (const cb of callbacks) {
-            cb(subscriptionEvent);
-          }
+      const payload = msg.payload ?? {};
+
+      if (msg.type === 'system') {
+        for (const listener of this.systemListeners) listener(payload);
+        return;
+      }
+
+      // The first reply to a subscribe message carries the subscription ID.
+      const pending = msg.id ? this.pending.get(msg.id) : undefined;
+      if (pending && (msg.type === 'snapshot' || msg.type === 'subscribed')) {
+        this.pending.delete(msg.id);
+        this.subscriptions.set(payload.subscription_id, pending.callback);
+        pending.assign(payload.subscription_id);
+      } else if (pending && msg.type === 'error') {
+        this.pending.delete(msg.id);
+        pending.callback({ type: payload.code === 'CURSOR_EXPIRED' ? 'expired' : 'error', data: payload });
+        return;
+      }
+
+      const cb = this.subscriptions.get(payload.subscription_id);
+      if (!cb) return;
+
+      switch (msg.type) {
+        case 'snapshot':
+          cb({ type: 'snapshot', data: payload.docs ?? [], cursor: payload.cursor });
+          break;
+        case 'delta': {
+          const changes = payload.changes ?? {};
+          for (const doc of changes.inserts ?? []) cb({ type: 'insert', data: doc, cursor: payload.cursor });
+          for (const doc of changes.updates ?? []) cb({ type: 'update', data: doc, cursor: payload.cursor });
+          for (const id of changes.deletes ?? []) cb({ type: 'delete', data: { id }, cursor: payload.cursor });
+          break;
         }
+        case 'synced':
+          cb({ type: 'synced', data: [], cursor: payload.cursor });
+          break;
       }
     };
   }
@@ -514,6 +1011,19 @@ export class AlyxClient {
       this.token = response.access_token;
       return response;
     },
+
+    /** Get the caller's remaining quota under each rate limit, without using any. */
+    limits: async (): Promise<RateLimitStatus[]> => {
+      const response = await this.request<{ limits: RateLimitStatus[] }>('GET /api/auth/me/limits');
+      return response.limits;
+    },
+
+    /** Update the current user's metadata. Keys set to null are removed; null clears all metadata. */
+    updateMetadata: async (patch: UserMetadataPatch | null): Promise<AuthResponse['user']> => {
+      return this.request<AuthResponse['user']>('PATCH /api/auth/me', {
+        body: { metadata: patch },
+      });
+    },
   };
 
 	// Collection accessors
@@ -523,6 +1033,10 @@ export class AlyxClient {
 	for _, name := range sortedCollectionNames(s) {
 		typeName := toPascalCase(name)
 		propName := toCamelCase(name)
+		if s.Collections[name].List != nil {
+			b.WriteString(fmt.Sprintf("  %s = new %sCollection(this, '%s');\n", propName, typeName, name))
+			continue
+		}
 		b.WriteString(fmt.Sprintf("  %s = new Collection<%s, %sCreateInput, %sUpdateInput>(this, '%s');\n",
 			propName, typeName, typeName, typeName, name))
 	}
@@ -546,6 +1060,98 @@ export function createClient(config?: Partial<AlyxClientConfig>): AlyxClient {
 	return b.String()
 }
 
+// generateListCollections writes a Collection subclass for each collection
+// with list settings, documenting them on its list method.
+func (g *TypeScriptGenerator) generateListCollections(b *strings.Builder, s *schema.Schema) {
+	for _, name := range sortedCollectionNames(s) {
+		list := s.Collections[name].List
+		if list == nil {
+			continue
+		}
+		typeName := toPascalCase(name)
+		defaultLimit, maxLimit := list.Limits()
+
+		b.WriteString(fmt.Sprintf("/** Operations for the %s collection. */\n", name))
+		b.WriteString(fmt.Sprintf("export class %sCollection extends Collection<%s, %sCreateInput, %sUpdateInput> {\n",
+			typeName, typeName, typeName, typeName))
+		b.WriteString("  /**\n   * List documents with optional filtering.\n   *\n")
+		if list.DefaultSort != "" {
+			b.WriteString(fmt.Sprintf("   * Sorted by %s unless a sort is given.\n", list.DefaultSort))
+		}
+		b.WriteString(fmt.Sprintf("   * Returns %d documents per page by default and at most %d; larger\n", defaultLimit, maxLimit))
+		b.WriteString("   * limits are clamped.\n   */\n")
+		b.WriteString(fmt.Sprintf("  override async list(options?: QueryOptions<%s>): Promise<PaginatedResponse<%s>> {\n", typeName, typeName))
+		b.WriteString("    return super.list(options);\n  }\n}\n\n")
+	}
+}
+
+// generateBlobMethods writes the Collection methods for the blob endpoints.
+func (g *TypeScriptGenerator) generateBlobMethods(b *strings.Builder) {
+	b.WriteString(`  /** Replace the content of a blob field, storing contentType with it. */
+  async uploadBlob(
+    id: string,
+    field: string,
+    data: Blob | ArrayBuffer | Uint8Array,
+    contentType?: string,
+  ): Promise<BlobInfo> {
+    const headers: Record<string, string> = {};
+    const type = contentType ?? (data instanceof Blob ? data.type : '');
+    if (type) headers['Content-Type'] = type;
+    if (this.client['token']) {
+      headers['Authorization'] = ` + "`" + `Bearer ${this.client['token']}` + "`" + `;
+    }
+
+    const response = await fetch(
+      ` + "`" + `${this.client['url']}/api/collections/${this.name}/${id}/blob/${field}` + "`" + `,
+      { method: 'PUT', headers, body: data },
+    );
+
+    if (!response.ok) {
+      throw await AlyxError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  /**
+   * Download the content of a blob field, or the bytes from start to end
+   * (inclusive, or to the end of the content when end is omitted).
+   */
+  async downloadBlob(
+    id: string,
+    field: string,
+    range?: { start: number; end?: number },
+  ): Promise<Blob> {
+    const headers: Record<string, string> = {};
+    if (range) headers['Range'] = ` + "`" + `bytes=${range.start}-${range.end ?? ''}` + "`" + `;
+    if (this.client['token']) {
+      headers['Authorization'] = ` + "`" + `Bearer ${this.client['token']}` + "`" + `;
+    }
+
+    const response = await fetch(
+      ` + "`" + `${this.client['url']}/api/collections/${this.name}/${id}/blob/${field}` + "`" + `,
+      { headers },
+    );
+
+    if (!response.ok) {
+      throw await AlyxError.fromResponse(response);
+    }
+
+    return response.blob();
+  }
+
+  /** Download the content of a blob field as an ArrayBuffer. */
+  async downloadBlobBuffer(
+    id: string,
+    field: string,
+    range?: { start: number; end?: number },
+  ): Promise<ArrayBuffer> {
+    return (await this.downloadBlob(id, field, range)).arrayBuffer();
+  }
+
+`)
+}
+
 func (g *TypeScriptGenerator) generateIndex() string {
 	return `// Generated by Alyx - DO NOT EDIT
 
@@ -636,8 +1242,7 @@ export class StorageClient {
     });
 
     if (!response.ok) {
-      const error = await response.json().catch(() => ({}));
-      throw new Error(error.message || ` + "`" + `HTTP ${response.status}` + "`" + `);
+      throw await AlyxError.fromResponse(response);
     }
 
     return response.json();
@@ -654,8 +1259,7 @@ export class StorageClient {
     );
 
     if (!response.ok) {
-      const error = await response.json().catch(() => ({}));
-      throw new Error(error.message || ` + "`" + `HTTP ${response.status}` + "`" + `);
+      throw await AlyxError.fromResponse(response);
     }
 
     return response.blob();
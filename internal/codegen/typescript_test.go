@@ -256,3 +256,339 @@ func TestTypeScriptGenerator_NoStorageWhenNoBuckets(t *testing.T) {
 		t.Error("AlyxClient.storage should not exist when schema has no buckets")
 	}
 }
+
+func TestTypeScriptGenerator_SubscribeOptions(t *testing.T) {
+	gen := NewTypeScriptGenerator(&Config{ServerURL: "http://localhost:8090"})
+
+	s := &schema.Schema{
+		Collections: map[string]*schema.Collection{
+			"posts": {
+				Name: "posts",
+				Fields: map[string]*schema.Field{
+					"id": {Name: "id", Type: schema.FieldTypeUUID, Primary: true},
+				},
+			},
+		},
+	}
+
+	files, err := gen.Generate(s)
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+
+	var clientContent string
+	for _, f := range files {
+		if f.Path == "client.ts" {
+			clientContent = f.Content
+			break
+		}
+	}
+
+	for _, want := range []string{
+		"export interface SubscribeOptions<T>",
+		"includeSnapshot?: boolean;",
+		"since?: number | string;",
+		"include_snapshot: options?.includeSnapshot || undefined,",
+		"case 'synced':",
+		"payload.code === 'CURSOR_EXPIRED' ? 'expired' : 'error'",
+		"payload: { subscription_id: subscriptionId }",
+		"export interface SystemEvent {",
+		"onSystemEvent(callback: (event: SystemEvent) => void): () => void {",
+		"payload: { channel: 'system' }",
+		"if (msg.type === 'system') {",
+	} {
+		if !strings.Contains(clientContent, want) {
+			t.Errorf("client.ts missing %q", want)
+		}
+	}
+}
+
+func TestTypeScriptGenerator_UserMetadata(t *testing.T) {
+	gen := NewTypeScriptGenerator(&Config{ServerURL: "http://localhost:8090"})
+	noExtra := false
+
+	s := &schema.Schema{
+		Collections: map[string]*schema.Collection{},
+		UserMetadata: &schema.MetadataSchema{
+			AdditionalProperties: &noExtra,
+			Required:             []string{"display_name"},
+			Properties: map[string]*schema.MetadataSchema{
+				"display_name": {Type: schema.MetadataTypeString, Description: "Name shown to other users"},
+				"theme":        {Type: schema.MetadataTypeString, Enum: []any{"light", "dark"}},
+				"tags":         {Type: schema.MetadataTypeArray, Items: &schema.MetadataSchema{Type: schema.MetadataTypeString}},
+			},
+		},
+	}
+
+	files, err := gen.Generate(s)
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+
+	contents := map[string]string{}
+	for _, f := range files {
+		contents[f.Path] = f.Content
+	}
+
+	for _, want := range []string{
+		"export interface UserMetadata {\n  /** Name shown to other users */\n  display_name: string;\n  tags?: string[];\n  theme?: 'light' | 'dark';\n}",
+		"export type UserMetadataPatch = { [K in keyof UserMetadata]?: UserMetadata[K] | null };",
+	} {
+		if !strings.Contains(contents["types.ts"], want) {
+			t.Errorf("types.ts missing %q:\n%s", want, contents["types.ts"])
+		}
+	}
+	for _, want := range []string{
+		"metadata?: UserMetadata;",
+		"updateMetadata: async (patch: UserMetadataPatch | null)",
+		"'PATCH /api/auth/me'",
+	} {
+		if !strings.Contains(contents["client.ts"], want) {
+			t.Errorf("client.ts missing %q", want)
+		}
+	}
+
+	s.UserMetadata = nil
+	files, _ = gen.Generate(s)
+	if !strings.Contains(files[0].Content, "export type UserMetadata = Record<string, unknown>;") {
+		t.Error("expected untyped UserMetadata without a declaration")
+	}
+}
+
+func TestTypeScriptGenerator_BlobFields(t *testing.T) {
+	gen := NewTypeScriptGenerator(&Config{ServerURL: "http://localhost:8090"})
+
+	postsCollection := &schema.Collection{
+		Name: "posts",
+		Fields: map[string]*schema.Field{
+			"id":    {Name: "id", Type: schema.FieldTypeUUID, Primary: true},
+			"thumb": {Name: "thumb", Type: schema.FieldTypeBlob},
+			"video": {Name: "video", Type: schema.FieldTypeBlob, Nullable: true, Storage: "media"},
+		},
+	}
+	postsCollection.SetFieldOrder([]string{"id", "thumb", "video"})
+
+	files, err := gen.Generate(&schema.Schema{
+		Collections: map[string]*schema.Collection{"posts": postsCollection},
+		Buckets:     map[string]*schema.Bucket{"media": {Name: "media", Backend: "filesystem"}},
+	})
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+
+	var typesContent, clientContent string
+	for _, f := range files {
+		switch f.Path {
+		case "types.ts":
+			typesContent = f.Content
+		case "client.ts":
+			clientContent = f.Content
+		}
+	}
+
+	for _, want := range []string{
+		"export interface BlobInfo {",
+		"  thumb: BlobInfo;",
+		"  video?: BlobInfo | null;",
+		"  thumb?: string;",
+		"  video?: null;",
+	} {
+		if !strings.Contains(typesContent, want) {
+			t.Errorf("types.ts missing %q\n%s", want, typesContent)
+		}
+	}
+
+	for _, want := range []string{
+		"  BlobInfo,\n",
+		"async uploadBlob(",
+		"data: Blob | ArrayBuffer | Uint8Array",
+		"async downloadBlob(",
+		"headers['Range'] = `bytes=${range.start}-${range.end ?? ''}`",
+		"Promise<ArrayBuffer>",
+	} {
+		if !strings.Contains(clientContent, want) {
+			t.Errorf("client.ts missing %q", want)
+		}
+	}
+}
+
+func TestTypeScriptGenerator_RateLimitErrors(t *testing.T) {
+	gen := NewTypeScriptGenerator(&Config{ServerURL: "http://localhost:8090"})
+
+	files, err := gen.Generate(&schema.Schema{Collections: map[string]*schema.Collection{}})
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+
+	var clientContent string
+	for _, f := range files {
+		if f.Path == "client.ts" {
+			clientContent = f.Content
+		}
+	}
+
+	for _, want := range []string{
+		"export class AlyxError extends Error {",
+		"readonly retryAfter?: number;",
+		"response.headers.get('Retry-After')",
+		"throw await AlyxError.fromResponse(response);",
+		"limits: async (): Promise<RateLimitStatus[]> => {",
+		"'GET /api/auth/me/limits'",
+	} {
+		if !strings.Contains(clientContent, want) {
+			t.Errorf("client.ts missing %q", want)
+		}
+	}
+	if strings.Contains(clientContent, "throw new Error(error.message") {
+		t.Error("client.ts should throw AlyxError for failed responses")
+	}
+}
+
+func TestTypeScriptGenerator_ListConfig(t *testing.T) {
+	gen := NewTypeScriptGenerator(&Config{ServerURL: "http://localhost:8090"})
+
+	posts := &schema.Collection{
+		Name: "posts",
+		Fields: map[string]*schema.Field{
+			"id":         {Name: "id", Type: schema.FieldTypeUUID, Primary: true},
+			"created_at": {Name: "created_at", Type: schema.FieldTypeTimestamp, Default: "now"},
+		},
+		List: &schema.ListConfig{DefaultSort: "-created_at", DefaultLimit: 20, MaxLimit: 50},
+	}
+	posts.SetFieldOrder([]string{"id", "created_at"})
+	tags := &schema.Collection{
+		Name:   "tags",
+		Fields: map[string]*schema.Field{"id": {Name: "id", Type: schema.FieldTypeUUID, Primary: true}},
+	}
+	tags.SetFieldOrder([]string{"id"})
+
+	files, err := gen.Generate(&schema.Schema{
+		Collections: map[string]*schema.Collection{"posts": posts, "tags": tags},
+	})
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+
+	var clientContent string
+	for _, f := range files {
+		if f.Path == "client.ts" {
+			clientContent = f.Content
+		}
+	}
+
+	for _, want := range []string{
+		"export class PostsCollection extends Collection<Posts, PostsCreateInput, PostsUpdateInput> {",
+		"   * Sorted by -created_at unless a sort is given.",
+		"   * Returns 20 documents per page by default and at most 50; larger",
+		"  posts = new PostsCollection(this, 'posts');",
+		"  tags = new Collection<Tags, TagsCreateInput, TagsUpdateInput>(this, 'tags');",
+	} {
+		if !strings.Contains(clientContent, want) {
+			t.Errorf("client.ts missing %q\n%s", want, clientContent)
+		}
+	}
+	if strings.Contains(clientContent, "TagsCollection") {
+		t.Error("expected no subclass for a collection without list settings")
+	}
+}
+
+func TestTypeScriptGenerator_DatesAndTSType(t *testing.T) {
+	posts := &schema.Collection{
+		Name: "posts",
+		Fields: map[string]*schema.Field{
+			"id":           {Name: "id", Type: schema.FieldTypeUUID, Primary: true},
+			"published_at": {Name: "published_at", Type: schema.FieldTypeTimestamp, Nullable: true},
+			"tags":         {Name: "tags", Type: schema.FieldTypeJSON, TSType: "Array<{name: string}>"},
+		},
+	}
+	posts.SetFieldOrder([]string{"id", "published_at", "tags"})
+	s := &schema.Schema{Collections: map[string]*schema.Collection{"posts": posts}}
+
+	generate := func(dates string) (types, client string) {
+		t.Helper()
+		files, err := NewTypeScriptGenerator(&Config{Dates: dates}).Generate(s)
+		if err != nil {
+			t.Fatalf("Generate() failed: %v", err)
+		}
+		for _, f := range files {
+			switch f.Path {
+			case "types.ts":
+				types = f.Content
+			case "client.ts":
+				client = f.Content
+			}
+		}
+		return types, client
+	}
+
+	types, client := generate("")
+	for _, want := range []string{
+		"export type PostsTags = Array<{name: string}>;",
+		"  tags: PostsTags;",
+		"  published_at?: string | null;",
+		"posts: { published_at: 'timestamp', tags: 'json' },",
+	} {
+		if !strings.Contains(types, want) {
+			t.Errorf("types.ts missing %q\n%s", want, types)
+		}
+	}
+	if strings.Contains(client, "revive") {
+		t.Error("client.ts should not revive timestamps by default")
+	}
+
+	types, client = generate("Date")
+	for _, want := range []string{
+		"  published_at?: Date | null;",
+		"  published_at?: Date | string;",
+	} {
+		if !strings.Contains(types, want) {
+			t.Errorf("types.ts missing %q\n%s", want, types)
+		}
+	}
+	for _, want := range []string{
+		"import { fieldKinds } from './types';",
+		"return revive<T>(this.name, await this.client.request<T>(",
+		"res.document = revive<T>(this.name, res.document);",
+	} {
+		if !strings.Contains(client, want) {
+			t.Errorf("client.ts missing %q", want)
+		}
+	}
+}
+
+func TestGenerators_SkipUnexposedCollections(t *testing.T) {
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  posts:
+    use: [id]
+  job_queue:
+    expose: false
+    use: [id]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, gen := range []Generator{
+		NewTypeScriptGenerator(&Config{ServerURL: "http://localhost:8090"}),
+		NewGoGenerator(&Config{ServerURL: "http://localhost:8090", PackageName: "client"}),
+		NewPythonGenerator(&Config{ServerURL: "http://localhost:8090"}),
+	} {
+		files, err := gen.Generate(s)
+		if err != nil {
+			t.Fatalf("%s: Generate() failed: %v", gen.Language(), err)
+		}
+		var all strings.Builder
+		for _, f := range files {
+			all.WriteString(f.Content)
+		}
+		out := all.String()
+		if !strings.Contains(out, "posts") {
+			t.Errorf("%s: expected posts in the output", gen.Language())
+		}
+		if strings.Contains(out, "job_queue") || strings.Contains(out, "JobQueue") {
+			t.Errorf("%s: unexposed collection in the output", gen.Language())
+		}
+	}
+}
@@ -2,6 +2,7 @@
 package config
 
 import (
+	"slices"
 	"time"
 )
 
@@ -17,16 +18,80 @@ type Config struct {
 	Docs      DocsConfig      `mapstructure:"docs"`
 	AdminUI   AdminUIConfig   `mapstructure:"admin_ui"`
 	Storage   StorageConfig   `mapstructure:"storage"`
+	Retention RetentionConfig `mapstructure:"retention"`
+	Changes   ChangesConfig   `mapstructure:"changes"`
+	Email     EmailConfig     `mapstructure:"email"`
+	Security  SecurityConfig  `mapstructure:"security"`
+	Health    HealthConfig    `mapstructure:"health"`
+
+	Observability ObservabilityConfig `mapstructure:"observability"`
+
+	// Env is the active environment overlay (from --env or ALYX_ENV), if any.
+	Env string `mapstructure:"-"`
+
+	// Sources maps dotted config keys to where their value came from: a config
+	// file path or "environment". Keys not present use the built-in default.
+	Sources map[string]string `mapstructure:"-"`
+}
+
+// Features returns the optional subsystems the config enables, in a fixed
+// order.
+func (c *Config) Features() []string {
+	features := []string{}
+	if c.Functions.Enabled {
+		features = append(features, "functions")
+	}
+	if c.Realtime.Enabled {
+		features = append(features, "realtime")
+	}
+	if len(c.Storage.Backends) > 0 {
+		features = append(features, "storage")
+	}
+	if c.Docs.Enabled {
+		features = append(features, "docs")
+	}
+	if c.AdminUI.Enabled {
+		features = append(features, "admin_ui")
+	}
+	if c.Observability.Tracing.Enabled {
+		features = append(features, "tracing")
+	}
+	return features
 }
 
 type DocsConfig struct {
-	Enabled     bool   `mapstructure:"enabled"`
-	UI          string `mapstructure:"ui"`
+	Enabled bool `mapstructure:"enabled"`
+
+	// Documentation UIs served at /api/docs/{ui}. When empty, only UI is
+	// served, as in configs written before uis existed
+	UIs []string `mapstructure:"uis"`
+
+	// UI that /api/docs redirects to. When it is not in UIs, the first of UIs
+	// is used instead
+	UI string `mapstructure:"ui"`
+
 	Title       string `mapstructure:"title"`
 	Description string `mapstructure:"description"`
 	Version     string `mapstructure:"version"`
 }
 
+// EnabledUIs returns the documentation UIs to serve, in order.
+func (c *DocsConfig) EnabledUIs() []string {
+	if len(c.UIs) == 0 {
+		return []string{c.UI}
+	}
+	return c.UIs
+}
+
+// DefaultUI returns the documentation UI that /api/docs redirects to.
+func (c *DocsConfig) DefaultUI() string {
+	uis := c.EnabledUIs()
+	if slices.Contains(uis, c.UI) {
+		return c.UI
+	}
+	return uis[0]
+}
+
 // ServerConfig holds HTTP server settings.
 type ServerConfig struct {
 	// Host to bind the server to
@@ -38,18 +103,35 @@ type ServerConfig struct {
 	// Enable CORS
 	CORS CORSConfig `mapstructure:"cors"`
 
+	// Response compression
+	Compression CompressionConfig `mapstructure:"compression"`
+
 	// Request timeout
 	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
 	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
 
-	// Maximum request body size in bytes
+	// Maximum request body size in bytes for JSON and other non-upload routes
 	MaxBodySize int64 `mapstructure:"max_body_size"`
 
+	// Maximum request body size in bytes for file uploads (/api/files,
+	// /api/tus and multipart function calls). A bucket's max_file_size
+	// overrides it for uploads to that bucket.
+	MaxUploadSize int64 `mapstructure:"max_upload_size"`
+
+	// Error response format: "alyx" or "problem+json" (RFC 7807)
+	ErrorFormat string `mapstructure:"error_format"`
+
 	// TLS configuration (optional)
 	TLS *TLSConfig `mapstructure:"tls"`
 }
 
+// Error response formats.
+const (
+	ErrorFormatAlyx    = "alyx"
+	ErrorFormatProblem = "problem+json"
+)
+
 // CORSConfig holds CORS settings.
 type CORSConfig struct {
 	// Enable CORS
@@ -58,6 +140,10 @@ type CORSConfig struct {
 	// Allowed origins (use ["*"] for all)
 	AllowedOrigins []string `mapstructure:"allowed_origins"`
 
+	// Allowed origin patterns with a wildcard subdomain, e.g.
+	// https://*.example.com
+	AllowedOriginPatterns []string `mapstructure:"allowed_origin_patterns"`
+
 	// Exposed headers
 	ExposedHeaders []string `mapstructure:"exposed_headers"`
 
@@ -68,6 +154,18 @@ type CORSConfig struct {
 	MaxAge time.Duration `mapstructure:"max_age"`
 }
 
+// CompressionConfig holds response compression settings.
+type CompressionConfig struct {
+	// Enable gzip/brotli compression negotiated via Accept-Encoding
+	Enabled bool `mapstructure:"enabled"`
+
+	// Smallest response body in bytes that is compressed
+	MinSize int `mapstructure:"min_size"`
+
+	// Content types that are compressed
+	Types []string `mapstructure:"types"`
+}
+
 // AllowedMethods returns the hard-coded list of allowed HTTP methods
 // These are required for admin UI and API functionality
 func (c *CORSConfig) AllowedMethods() []string {
@@ -105,6 +203,40 @@ type DatabaseConfig struct {
 
 	// Turso configuration (optional, for distributed deployments)
 	Turso *TursoConfig `mapstructure:"turso"`
+
+	// Maximum number of prepared statements cached for hot queries (0 disables)
+	StmtCacheSize int `mapstructure:"stmt_cache_size"`
+
+	// Background WAL checkpoint policy
+	Checkpoint CheckpointConfig `mapstructure:"checkpoint"`
+
+	// Retries of writes that fail because the database is busy or locked
+	Retry RetryConfig `mapstructure:"retry"`
+}
+
+// RetryConfig bounds how writes that fail with SQLITE_BUSY or SQLITE_LOCKED
+// are retried.
+type RetryConfig struct {
+	// Attempts in total, including the first (1 disables retries)
+	MaxAttempts int `mapstructure:"max_attempts"`
+
+	// Longest total time spent backing off between attempts
+	MaxWait time.Duration `mapstructure:"max_wait"`
+}
+
+// CheckpointConfig controls when the WAL file is checkpointed.
+type CheckpointConfig struct {
+	// Enable background checkpoints
+	Enabled bool `mapstructure:"enabled"`
+
+	// How often the WAL size is checked
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Checkpoint mode: PASSIVE, FULL, RESTART or TRUNCATE
+	Mode string `mapstructure:"mode"`
+
+	// Checkpoint once the WAL file reaches this many bytes (0 checkpoints every interval)
+	WALSizeThreshold int64 `mapstructure:"wal_size_threshold"`
 }
 
 // WALMode returns true (always enabled for concurrency)
@@ -171,6 +303,10 @@ type AuthConfig struct {
 	// OAuth providers
 	OAuth map[string]OAuthProviderConfig `mapstructure:"oauth"`
 
+	// URIs an OAuth login started with a redirect_uri may finish at, set as
+	// auth.oauth.redirect_uri_allowlist
+	OAuthRedirectURIAllowlist []string `mapstructure:"-"`
+
 	// Rate limiting
 	RateLimit AuthRateLimitConfig `mapstructure:"rate_limit"`
 
@@ -179,6 +315,20 @@ type AuthConfig struct {
 
 	// Require email verification
 	RequireVerification bool `mapstructure:"require_verification"`
+
+	// Promote the first registered user to admin. Disable in production and
+	// create the admin with "alyx users create" instead.
+	FirstUserAdmin bool `mapstructure:"first_user_admin"`
+
+	// How long email verification links stay valid
+	VerificationTTL time.Duration `mapstructure:"verification_ttl"`
+
+	// How long password reset links stay valid
+	PasswordResetTTL time.Duration `mapstructure:"password_reset_ttl"`
+
+	// How long an account whose owner asked to delete it can still be
+	// restored before it and its data are removed
+	DeletionGracePeriod time.Duration `mapstructure:"deletion_grace_period"`
 }
 
 // JWTConfig holds JWT settings.
@@ -186,6 +336,10 @@ type JWTConfig struct {
 	// Secret key for signing tokens (required, min 32 chars)
 	Secret string `mapstructure:"secret"`
 
+	// Secrets replaces Secret during key rotation: tokens are signed with
+	// the first secret and verified against all of them
+	Secrets []string `mapstructure:"secrets"`
+
 	// Access token lifetime
 	AccessTTL time.Duration `mapstructure:"access_ttl"`
 
@@ -197,6 +351,46 @@ type JWTConfig struct {
 
 	// JWT audience claim
 	Audience []string `mapstructure:"audience"`
+
+	// Signing algorithm: HS256 signs with the secret, RS256 and EdDSA with
+	// PrivateKeyFile, whose public key is published at /.well-known/jwks.json
+	Algorithm string `mapstructure:"algorithm"`
+
+	// PEM private key for RS256 or EdDSA
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+}
+
+// JWT signing algorithms.
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// Asymmetric reports whether tokens are signed with a private key rather
+// than the secret.
+func (c *JWTConfig) Asymmetric() bool {
+	return c.Algorithm == JWTAlgorithmRS256 || c.Algorithm == JWTAlgorithmEdDSA
+}
+
+// SigningSecret returns the secret new tokens are signed with.
+func (c *JWTConfig) SigningSecret() string {
+	if len(c.Secrets) > 0 {
+		return c.Secrets[0]
+	}
+	return c.Secret
+}
+
+// VerificationSecrets returns every secret a token may be signed with,
+// current first.
+func (c *JWTConfig) VerificationSecrets() []string {
+	if len(c.Secrets) > 0 {
+		return c.Secrets
+	}
+	if c.Secret == "" {
+		return nil
+	}
+	return []string{c.Secret}
 }
 
 // PasswordConfig holds password requirements.
@@ -215,6 +409,44 @@ type PasswordConfig struct {
 
 	// Require special character
 	RequireSpecial bool `mapstructure:"require_special"`
+
+	// How passwords are hashed
+	Hash PasswordHashConfig `mapstructure:"hash"`
+}
+
+// PasswordHashConfig selects the password hashing algorithm and its
+// parameters. Stored hashes made with another algorithm or other parameters
+// keep verifying and are rehashed with these on the user's next login.
+type PasswordHashConfig struct {
+	// PasswordHashBcrypt or PasswordHashArgon2id
+	Algorithm string `mapstructure:"algorithm"`
+
+	Bcrypt   BcryptConfig   `mapstructure:"bcrypt"`
+	Argon2id Argon2idConfig `mapstructure:"argon2id"`
+}
+
+// Password hashing algorithms.
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+// BcryptConfig holds bcrypt parameters.
+type BcryptConfig struct {
+	// Cost, from 4 to 31; each step doubles the work
+	Cost int `mapstructure:"cost"`
+}
+
+// Argon2idConfig holds argon2id parameters.
+type Argon2idConfig struct {
+	// Memory in KiB
+	Memory int `mapstructure:"memory"`
+
+	// Passes over the memory
+	Iterations int `mapstructure:"iterations"`
+
+	// Threads used per hash
+	Parallelism int `mapstructure:"parallelism"`
 }
 
 // OAuthProviderConfig holds OAuth provider settings.
@@ -272,6 +504,10 @@ type FunctionsConfig struct {
 
 	// Environment variables to pass to functions
 	Env map[string]string `mapstructure:"env"`
+
+	// Reject invocations whose payload does not match the function's
+	// declared input with 422
+	ValidateInput bool `mapstructure:"validate_input"`
 }
 
 // LoggingConfig holds logging settings.
@@ -290,6 +526,10 @@ type LoggingConfig struct {
 
 	// Output file (empty for stdout)
 	Output string `mapstructure:"output"`
+
+	// Record why CEL rules denied access in the request log and 403
+	// responses. Always on in dev mode.
+	RuleDenials bool `mapstructure:"rule_denials"`
 }
 
 // DevConfig holds development mode settings.
@@ -300,7 +540,23 @@ type DevConfig struct {
 	AutoGenerate      bool     `mapstructure:"auto_generate"`
 	GenerateLanguages []string `mapstructure:"generate_languages"`
 	GenerateOutput    string   `mapstructure:"generate_output"`
-}
+	// GenerateDates is the TypeScript type generated for timestamp fields:
+	// GenerateDatesString, or GenerateDatesDate to revive them as Dates.
+	GenerateDates string `mapstructure:"generate_dates"`
+	// Sandbox routes every request to the sandbox database instead of the
+	// primary one. Without it, only requests with an X-Alyx-Sandbox: true
+	// header are sandboxed.
+	Sandbox bool `mapstructure:"sandbox"`
+	// SandboxSeed copies the primary database's data into the sandbox when it
+	// is created, instead of starting it empty.
+	SandboxSeed bool `mapstructure:"sandbox_seed"`
+}
+
+// TypeScript representations of generated timestamp fields.
+const (
+	GenerateDatesString = "string"
+	GenerateDatesDate   = "Date"
+)
 
 // AdminUIConfig holds admin UI settings.
 type AdminUIConfig struct {
@@ -311,12 +567,164 @@ type AdminUIConfig struct {
 // RealtimeConfig holds real-time subscription settings.
 type RealtimeConfig struct {
 	Enabled                   bool          `mapstructure:"enabled"`
+	Mode                      string        `mapstructure:"mode"`
 	PollInterval              time.Duration `mapstructure:"poll_interval"`
 	MaxConnections            int           `mapstructure:"max_connections"`
 	MaxSubscriptionsPerClient int           `mapstructure:"max_subscriptions_per_client"`
 	ChangeBufferSize          int           `mapstructure:"change_buffer_size"`
 	CleanupInterval           time.Duration `mapstructure:"cleanup_interval"`
 	CleanupAge                time.Duration `mapstructure:"cleanup_age"`
+
+	// Keepalive: clients are pinged every PingInterval and disconnected
+	// after MaxMissedPongs unanswered pings in a row.
+	PingInterval   time.Duration `mapstructure:"ping_interval"`
+	MaxMissedPongs int           `mapstructure:"max_missed_pongs"`
+
+	// Slow clients: each write must finish within WriteTimeout, and a
+	// client whose send buffer stays full for SlowClientTimeout is
+	// disconnected.
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	SlowClientTimeout time.Duration `mapstructure:"slow_client_timeout"`
+}
+
+// RetentionConfig holds settings for the collection retention job.
+type RetentionConfig struct {
+	// Enable background pruning of collections with a retention policy
+	Enabled bool `mapstructure:"enabled"`
+
+	// How often the retention job runs
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Maximum rows deleted per transaction
+	BatchSize int `mapstructure:"batch_size"`
+
+	// Pause between batches so writers are not starved
+	BatchSleep time.Duration `mapstructure:"batch_sleep"`
+
+	// Suppress realtime delete events for rows pruned by retention
+	SuppressRealtime bool `mapstructure:"suppress_realtime"`
+}
+
+// Change feed payloads.
+const (
+	ChangesIncludeKeys     = "keys"
+	ChangesIncludeDocument = "document"
+)
+
+// ChangesConfig holds settings for the change data capture feed.
+type ChangesConfig struct {
+	// Serve the change feed at /api/admin/changes
+	Enabled bool `mapstructure:"enabled"`
+
+	// How long changes are kept for feed consumers
+	Retention time.Duration `mapstructure:"retention"`
+
+	// What each change carries: "keys" (document ID and changed fields) or
+	// "document" (also the document's current state)
+	Include string `mapstructure:"include"`
+}
+
+// SecurityConfig holds settings for data protection at rest.
+type SecurityConfig struct {
+	// Base64-encoded 32-byte AES key for fields declared encrypted: true
+	FieldEncryptionKey string `mapstructure:"field_encryption_key"`
+
+	// Keys that were replaced by field_encryption_key. Values encrypted with
+	// them stay readable until alyx security rotate-field-key re-encrypts them
+	PreviousFieldEncryptionKeys []string `mapstructure:"previous_field_encryption_keys"`
+}
+
+// EmailConfig holds outgoing email settings.
+type EmailConfig struct {
+	// Send email through SMTP. When disabled, emails are written to the log
+	// instead, including any verification or reset links.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Sender address, e.g. "Alyx <no-reply@example.com>"
+	From string `mapstructure:"from"`
+
+	// Application name used in templates
+	AppName string `mapstructure:"app_name"`
+
+	// Directory with template overrides (verification, password_reset, welcome)
+	TemplatesDir string `mapstructure:"templates_dir"`
+
+	// Verification link; {token} is replaced with the token. Defaults to the
+	// server's verify endpoint.
+	VerifyURL string `mapstructure:"verify_url"`
+
+	// Password reset link; {token} is replaced with the token. Set this to a
+	// page in your app that posts the new password to /api/auth/password/reset.
+	ResetURL string `mapstructure:"reset_url"`
+
+	// Account restore link sent when a user deletes their account; {token}
+	// is replaced with the token. Defaults to the server's restore endpoint.
+	RestoreURL string `mapstructure:"restore_url"`
+
+	// SMTP server settings
+	SMTP SMTPConfig `mapstructure:"smtp"`
+}
+
+// SMTP TLS modes.
+const (
+	SMTPTLSStartTLS = "starttls"
+	SMTPTLSImplicit = "tls"
+	SMTPTLSNone     = "none"
+)
+
+// SMTPConfig holds SMTP server settings.
+type SMTPConfig struct {
+	// Server hostname
+	Host string `mapstructure:"host"`
+
+	// Server port (587 for STARTTLS, 465 for implicit TLS)
+	Port int `mapstructure:"port"`
+
+	// Username for PLAIN authentication (optional)
+	Username string `mapstructure:"username"`
+
+	// Password for PLAIN authentication
+	Password string `mapstructure:"password"`
+
+	// TLS mode: "starttls", "tls" or "none"
+	TLS string `mapstructure:"tls"`
+
+	// Skip certificate verification (testing only)
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
+
+	// Timeout for the whole SMTP conversation
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// HealthConfig holds health check settings.
+type HealthConfig struct {
+	// Report not ready on /health/ready while functions are enabled and
+	// their runtimes are unavailable
+	RequireFunctions bool `mapstructure:"require_functions"`
+}
+
+// ObservabilityConfig holds tracing and other telemetry settings.
+type ObservabilityConfig struct {
+	// Distributed tracing via OTLP
+	Tracing TracingConfig `mapstructure:"tracing"`
+}
+
+// TracingConfig holds OpenTelemetry tracing settings.
+type TracingConfig struct {
+	// Enable OTLP trace export
+	Enabled bool `mapstructure:"enabled"`
+
+	// OTLP/HTTP collector endpoint (e.g., "localhost:4318")
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Send spans over plain HTTP instead of HTTPS
+	Insecure bool `mapstructure:"insecure"`
+
+	// Fraction of new traces to sample, between 0 and 1
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+
+	// Service name reported on every span
+	ServiceName string `mapstructure:"service_name"`
 }
 
 // StorageConfig holds storage backend settings.
@@ -380,6 +788,20 @@ func (s *ServerConfig) Address() string {
 	return s.Host + ":" + itoa(s.Port)
 }
 
+// URL returns the base URL clients use to reach the server. Wildcard bind
+// addresses are reported as localhost.
+func (s *ServerConfig) URL() string {
+	scheme := "http"
+	if s.TLS != nil && s.TLS.Enabled {
+		scheme = "https"
+	}
+	host := s.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return scheme + "://" + host + ":" + itoa(s.Port)
+}
+
 // itoa converts int to string without importing strconv.
 func itoa(i int) string {
 	if i == 0 {
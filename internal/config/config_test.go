@@ -1,9 +1,15 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -105,6 +111,141 @@ func TestValidateJWTSecret(t *testing.T) {
 	}
 }
 
+func TestValidate_JWTSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.JWT.Secrets = []string{"this-is-a-very-long-secret-key-for-jwt-signing", "short"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "auth.jwt.secrets[1]") {
+		t.Errorf("expected error for short secret, got %v", err)
+	}
+
+	cfg.Auth.JWT.Secrets = []string{"this-is-a-very-long-secret-key-for-jwt-signing"}
+	cfg.Auth.JWT.Secret = "another-very-long-secret-key-for-jwt-signing"
+	if err := Validate(cfg); err == nil {
+		t.Error("expected error when both secret and secrets are set")
+	}
+}
+
+func TestValidate_JWTAlgorithm(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.JWT.Algorithm = "ES256"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "auth.jwt.algorithm") {
+		t.Errorf("expected error for unsupported algorithm, got %v", err)
+	}
+
+	cfg.Auth.JWT.Algorithm = JWTAlgorithmRS256
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "auth.jwt.private_key_file") {
+		t.Errorf("expected error for missing key file, got %v", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Auth.JWT.PrivateKeyFile = filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(cfg.Auth.JWT.PrivateKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "RS256 needs an RSA private key") {
+		t.Errorf("expected error for an Ed25519 key with RS256, got %v", err)
+	}
+
+	cfg.Auth.JWT.Algorithm = JWTAlgorithmEdDSA
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected EdDSA with an Ed25519 key to be valid, got %v", err)
+	}
+}
+
+func TestValidate_PasswordHash(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.Password.Hash.Algorithm = "scrypt"
+	cfg.Auth.Password.Hash.Bcrypt.Cost = 40
+	cfg.Auth.Password.Hash.Argon2id.Memory = 4
+	err := Validate(cfg)
+	for _, field := range []string{"auth.password.hash.algorithm", "auth.password.hash.bcrypt.cost", "auth.password.hash.argon2id.memory"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error for %s, got %v", field, err)
+		}
+	}
+
+	cfg = Default()
+	cfg.Auth.Password.Hash.Algorithm = PasswordHashArgon2id
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected the default argon2id parameters to be valid, got %v", err)
+	}
+}
+
+func TestRotateJWTSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alyx.yaml")
+	content := `# Project config
+server:
+  port: 9000 # custom port
+auth:
+  jwt:
+    # Signing secret
+    secret: ${ROTATE_TEST_OLD_SECRET}
+    access_ttl: 10m
+`
+	if err := os.WriteFile(path, []byte(content), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ROTATE_TEST_OLD_SECRET", "old-secret-that-is-long-enough-for-jwt")
+
+	first := strings.Repeat("a", 64)
+	rotation, err := RotateJWTSecret(path, first, 1)
+	if err != nil {
+		t.Fatalf("RotateJWTSecret() error = %v", err)
+	}
+	if rotation.Retained != 1 || rotation.Dropped != 0 {
+		t.Errorf("expected 1 retained, got %+v", rotation)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{"# Project config", "# custom port", "${ROTATE_TEST_OLD_SECRET}", "access_ttl: 10m"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("rewritten config missing %q:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "secret: ") {
+		t.Errorf("expected auth.jwt.secret to be replaced:\n%s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o640 {
+		t.Errorf("expected permissions to be kept, got %v", info.Mode().Perm())
+	}
+
+	cfg, err := Load(LoadOptions{ConfigFile: path})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []string{first, "old-secret-that-is-long-enough-for-jwt"}
+	if got := cfg.Auth.JWT.VerificationSecrets(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("VerificationSecrets() = %v, want %v", got, want)
+	}
+	if cfg.Auth.JWT.SigningSecret() != first {
+		t.Errorf("SigningSecret() = %q, want %q", cfg.Auth.JWT.SigningSecret(), first)
+	}
+
+	// A second rotation keeps only the most recent previous secret.
+	second := strings.Repeat("b", 64)
+	rotation, err = RotateJWTSecret(path, second, 1)
+	if err != nil {
+		t.Fatalf("RotateJWTSecret() error = %v", err)
+	}
+	if rotation.Retained != 1 || rotation.Dropped != 1 {
+		t.Errorf("expected 1 retained and 1 dropped, got %+v", rotation)
+	}
+	cfg, err = Load(LoadOptions{ConfigFile: path})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.Auth.JWT.Secrets; len(got) != 2 || got[0] != second || got[1] != first {
+		t.Errorf("Secrets = %v, want [%s %s]", got, second, first)
+	}
+}
+
 func TestLoadFromFile(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "alyx.yaml")
@@ -144,6 +285,70 @@ logging:
 	}
 }
 
+func TestLoadOAuthRedirectURIAllowlist(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "alyx.yaml")
+	content := `
+auth:
+  oauth:
+    redirect_uri_allowlist:
+      - https://app.example.com/auth/callback
+      - myapp://auth/callback
+    github:
+      client_id: id
+      client_secret: secret
+`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if len(cfg.Auth.OAuth) != 1 || cfg.Auth.OAuth["github"].ClientID != "id" {
+		t.Errorf("expected only the github provider, got %v", cfg.Auth.OAuth)
+	}
+	if len(cfg.Auth.OAuthRedirectURIAllowlist) != 2 {
+		t.Fatalf("expected 2 allowed redirect URIs, got %v", cfg.Auth.OAuthRedirectURIAllowlist)
+	}
+
+	tests := map[string]bool{
+		"https://app.example.com/auth/callback":          true,
+		"https://APP.example.com/auth/callback?next=%2F": true,
+		"myapp://auth/callback":                          true,
+		"https://app.example.com/auth/callback#frag":     false,
+		"https://app.example.com/auth/other":             false,
+		"https://evil.example.com/auth/callback":         false,
+		"http://app.example.com/auth/callback":           false,
+		"https://app.example.com:8443/auth/callback":     false,
+	}
+	for uri, want := range tests {
+		if got := cfg.Auth.RedirectURIAllowed(uri); got != want {
+			t.Errorf("RedirectURIAllowed(%q) = %v, want %v", uri, got, want)
+		}
+	}
+}
+
+func TestValidate_OAuthRedirectURIAllowlist(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.OAuthRedirectURIAllowlist = []string{"https://app.example.com/cb", "/relative", "https://app.example.com/cb?x=1"}
+
+	err := Validate(cfg)
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+	var fields []string
+	for _, e := range verrs {
+		fields = append(fields, e.Field)
+	}
+	want := []string{"auth.oauth.redirect_uri_allowlist[1]", "auth.oauth.redirect_uri_allowlist[2]"}
+	if !slices.Equal(fields, want) {
+		t.Errorf("expected errors on %v, got %v", want, fields)
+	}
+}
+
 func TestLoadWithEnvOverride(t *testing.T) {
 	t.Setenv("ALYX_SERVER_PORT", "7777")
 	t.Setenv("ALYX_DATABASE_PATH", "env-test.db")
@@ -162,6 +367,69 @@ func TestLoadWithEnvOverride(t *testing.T) {
 	}
 }
 
+func TestLoadWithEnvOverlay(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "alyx.yaml")
+	overlayPath := filepath.Join(tmpDir, "alyx.production.yaml")
+
+	base := `
+server:
+  port: 9000
+  host: "0.0.0.0"
+  cors:
+    allowed_origins: ["http://localhost:3000", "http://localhost:5173"]
+auth:
+  allow_registration: true
+`
+	overlay := `
+server:
+  port: 8080
+  cors:
+    allowed_origins: ["https://app.example.com"]
+auth:
+  allow_registration: false
+`
+	if err := os.WriteFile(configPath, []byte(base), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	if err := os.WriteFile(overlayPath, []byte(overlay), 0o644); err != nil {
+		t.Fatalf("failed to write overlay file: %v", err)
+	}
+
+	cfg, err := Load(LoadOptions{ConfigFile: configPath, Env: "production"})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if cfg.Server.Port != 8080 {
+		t.Errorf("expected overlay port 8080, got %d", cfg.Server.Port)
+	}
+	if cfg.Server.Host != "0.0.0.0" {
+		t.Errorf("expected base host to be kept, got %s", cfg.Server.Host)
+	}
+	if len(cfg.Server.CORS.AllowedOrigins) != 1 || cfg.Server.CORS.AllowedOrigins[0] != "https://app.example.com" {
+		t.Errorf("expected overlay to replace allowed origins, got %v", cfg.Server.CORS.AllowedOrigins)
+	}
+	if cfg.Auth.AllowRegistration {
+		t.Error("expected overlay to disable registration")
+	}
+
+	if cfg.Sources["server.port"] != overlayPath {
+		t.Errorf("expected server.port source %s, got %q", overlayPath, cfg.Sources["server.port"])
+	}
+	if cfg.Sources["server.host"] != configPath {
+		t.Errorf("expected server.host source %s, got %q", configPath, cfg.Sources["server.host"])
+	}
+
+	base1, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if base1.Server.Port != 9000 {
+		t.Errorf("expected base port without env, got %d", base1.Server.Port)
+	}
+}
+
 func TestServerAddress(t *testing.T) {
 	cfg := &ServerConfig{Host: "localhost", Port: 8090}
 	if addr := cfg.Address(); addr != "localhost:8090" {
@@ -336,6 +604,56 @@ func TestValidate_Realtime(t *testing.T) {
 	}
 }
 
+func TestValidate_Retention(t *testing.T) {
+	cfg := Default()
+	cfg.Retention.BatchSize = 0
+
+	if err := Validate(cfg); err == nil {
+		t.Error("expected validation error for zero batch size")
+	}
+
+	cfg.Retention.Enabled = false
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected disabled retention to skip validation, got %v", err)
+	}
+}
+
+func TestValidate_Security(t *testing.T) {
+	key := "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+	tests := []struct {
+		name     string
+		key      string
+		previous []string
+		want     string
+	}{
+		{"unset", "", nil, ""},
+		{"valid key", key, []string{key}, ""},
+		{"not base64", "not a key", nil, "security.field_encryption_key"},
+		{"wrong length", "c2hvcnQ=", nil, "security.field_encryption_key"},
+		{"previous without current", "", []string{key}, "requires security.field_encryption_key"},
+		{"invalid previous", key, []string{"c2hvcnQ="}, "previous_field_encryption_keys[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Security.FieldEncryptionKey = tt.key
+			cfg.Security.PreviousFieldEncryptionKeys = tt.previous
+
+			err := Validate(cfg)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
 func TestValidate_AdminUI_ReservedPath(t *testing.T) {
 	cfg := Default()
 	cfg.AdminUI.Path = "/api"
@@ -372,3 +690,225 @@ func TestValidate_CORS_Security(t *testing.T) {
 		t.Error("expected validation warning for insecure CORS config")
 	}
 }
+
+func TestValidate_CORSOrigins(t *testing.T) {
+	tests := []struct {
+		origins  []string
+		patterns []string
+		wantErr  bool
+	}{
+		{origins: []string{"https://app.example.com", "http://localhost:3000"}},
+		{origins: []string{"https://app.example.com/"}, wantErr: true},
+		{origins: []string{"https://app.example.com/path"}, wantErr: true},
+		{origins: []string{"app.example.com"}, wantErr: true},
+		{patterns: []string{"https://*.example.com", "http://*.localhost:5173"}},
+		{patterns: []string{"https://*example.com"}, wantErr: true},
+		{patterns: []string{"https://app.*.example.com"}, wantErr: true},
+		{patterns: []string{"https://*.*.example.com"}, wantErr: true},
+		{patterns: []string{"*"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.Server.CORS.AllowedOrigins = tt.origins
+		cfg.Server.CORS.AllowedOriginPatterns = tt.patterns
+		if err := Validate(cfg); (err != nil) != tt.wantErr {
+			t.Errorf("origins %v, patterns %v: got error %v, want error %v", tt.origins, tt.patterns, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWarnings_WildcardOrigin(t *testing.T) {
+	cfg := Default()
+	if len(Warnings(cfg)) != 1 {
+		t.Errorf("expected a warning for a wildcard origin outside dev mode, got %v", Warnings(cfg))
+	}
+	cfg.Dev.Enabled = true
+	if len(Warnings(cfg)) != 0 {
+		t.Errorf("expected no warning in dev mode, got %v", Warnings(cfg))
+	}
+}
+
+func TestValidate_Email(t *testing.T) {
+	cfg := Default()
+	cfg.Email.Enabled = true
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected validation error for enabled email without host and from")
+	}
+	for _, field := range []string{"email.from", "email.smtp.host"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error for %s, got %v", field, err)
+		}
+	}
+
+	cfg.Email.From = "Alyx <no-reply@example.com>"
+	cfg.Email.SMTP.Host = "smtp.example.com"
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected valid email config, got %v", err)
+	}
+
+	cfg.Email.SMTP.TLS = "ssl"
+	cfg.Email.ResetURL = "https://app.example.com/reset"
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"email.smtp.tls", "email.reset_url"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error for %s, got %v", field, err)
+		}
+	}
+}
+
+func TestPatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alyx.yaml")
+	content := `# Project config
+server:
+  port: 9000 # custom port
+  error_format: alyx
+auth:
+  jwt:
+    # Signing secret
+    secret: old-secret-that-is-long-enough-for-jwt
+    access_ttl: 10m # short on purpose
+`
+	if err := os.WriteFile(path, []byte(content), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := PatchFile(path, []PatchOp{
+		{Path: "auth.jwt.access_ttl", Value: "30m"},
+		{Path: "auth.jwt.secret", Value: strings.Repeat("s", 40)},
+		{Path: "server.cors.allowed_origins", Value: []any{"https://example.com"}},
+		{Path: "auth.oauth.github.client_id", Value: "abc"},
+		{Path: "auth.oauth.github.client_secret", Value: "github-secret"},
+		{Path: "functions.env.api_url", Value: "https://api.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("PatchFile() error = %v", err)
+	}
+	if len(fields) != 6 || fields[0].Sensitive || !fields[1].Sensitive || !fields[4].Sensitive {
+		t.Errorf("unexpected fields %+v", fields)
+	}
+
+	data, _ := os.ReadFile(path)
+	text := string(data)
+	for _, want := range []string{"# Project config", "port: 9000 # custom port", "# Signing secret", "access_ttl: 30m # short on purpose"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("patched config missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "server:") > strings.Index(text, "auth:") {
+		t.Errorf("expected key order to be kept:\n%s", text)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o640 {
+		t.Errorf("expected permissions to be kept, got %v", info.Mode().Perm())
+	}
+
+	cfg, err := Load(LoadOptions{ConfigFile: path})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Auth.JWT.AccessTTL != 30*time.Minute {
+		t.Errorf("AccessTTL = %v, want 30m", cfg.Auth.JWT.AccessTTL)
+	}
+	if !slices.Equal(cfg.Server.CORS.AllowedOrigins, []string{"https://example.com"}) {
+		t.Errorf("AllowedOrigins = %v", cfg.Server.CORS.AllowedOrigins)
+	}
+	if cfg.Auth.OAuth["github"].ClientID != "abc" {
+		t.Errorf("github client_id = %q, want abc", cfg.Auth.OAuth["github"].ClientID)
+	}
+	if cfg.Functions.Env["api_url"] != "https://api.example.com" {
+		t.Errorf("functions.env = %v", cfg.Functions.Env)
+	}
+}
+
+func TestPatchFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alyx.yaml")
+	content := "server:\n  port: 9000\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := PatchFile(path, []PatchOp{
+		{Path: "server.port", Value: float64(9100)},
+		{Path: "server.nope", Value: "x"},
+		{Path: "auth.jwt.access_ttl", Value: "soon"},
+		{Path: "server.error_format", Value: "xml"},
+		{Path: "server.port", Value: "9100"},
+		{Path: "server.cors", Value: map[string]any{"enabled": true}},
+		{Path: "server.port.value", Value: 1},
+	})
+	var errs PatchErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected PatchErrors, got %v", err)
+	}
+	var indexes []int
+	for _, e := range errs {
+		indexes = append(indexes, e.Index)
+	}
+	if !slices.Equal(indexes, []int{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("failed ops = %v (%v)", indexes, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != content {
+		t.Errorf("expected the file to be untouched, got:\n%s", data)
+	}
+}
+
+func TestLoadDocsUIs(t *testing.T) {
+	dir := t.TempDir()
+	load := func(content string) *Config {
+		t.Helper()
+		configPath := filepath.Join(dir, "alyx.yaml")
+		if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+		cfg, err := LoadFromFile(configPath)
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		return cfg
+	}
+
+	// Configs written before uis existed serve their one UI.
+	cfg := load("docs:\n  ui: redoc\n")
+	if got := cfg.Docs.EnabledUIs(); len(got) != 1 || got[0] != "redoc" || cfg.Docs.DefaultUI() != "redoc" {
+		t.Errorf("expected only redoc, got %v (default %s)", got, cfg.Docs.DefaultUI())
+	}
+
+	cfg = load("docs:\n  uis: [swagger, scalar]\n")
+	if got := cfg.Docs.EnabledUIs(); len(got) != 2 || got[0] != "swagger" || got[1] != "scalar" {
+		t.Errorf("expected swagger and scalar, got %v", got)
+	}
+	if got := cfg.Docs.DefaultUI(); got != "scalar" {
+		t.Errorf("expected the default ui scalar to stay the default, got %s", got)
+	}
+
+	cfg = load("docs:\n  uis: [swagger, redoc]\n")
+	if got := cfg.Docs.DefaultUI(); got != "swagger" {
+		t.Errorf("expected the first enabled ui as default, got %s", got)
+	}
+}
+
+func TestValidate_DocsUIs(t *testing.T) {
+	cfg := Default()
+	cfg.Docs.UIs = []string{"scalar", "rapidoc", "scalar"}
+
+	err := Validate(cfg)
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 2 {
+		t.Fatalf("expected 2 validation errors, got %v", err)
+	}
+	if verrs[0].Field != "docs.uis[1]" || verrs[1].Field != "docs.uis[2]" {
+		t.Errorf("unexpected errors: %v", verrs)
+	}
+
+	cfg.Docs.UIs = []string{"swagger"}
+	cfg.Docs.UI = ""
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected ui to be optional with uis set, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// OriginPattern matches origins with a wildcard subdomain, such as
+// https://*.example.com. The wildcard stands for exactly one DNS label, so
+// the pattern matches https://app.example.com but neither
+// https://example.com nor https://a.b.example.com.
+type OriginPattern struct {
+	scheme string
+	suffix string // host after the wildcard label, with its leading dot
+	port   string
+}
+
+// ParseOriginPattern parses a pattern of the form scheme://*.host[:port].
+func ParseOriginPattern(pattern string) (*OriginPattern, error) {
+	rest, ok := strings.CutPrefix(pattern, "https://*.")
+	scheme := "https"
+	if !ok {
+		rest, ok = strings.CutPrefix(pattern, "http://*.")
+		scheme = "http"
+	}
+	if !ok {
+		return nil, errors.New("must be of the form https://*.example.com")
+	}
+	if strings.Contains(rest, "*") {
+		return nil, errors.New("only the first host label may be a wildcard")
+	}
+
+	// Validate the rest as the origin it would be with a concrete label.
+	if err := validateOrigin(scheme + "://x." + rest); err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(scheme + "://x." + rest)
+	return &OriginPattern{
+		scheme: scheme,
+		suffix: strings.TrimPrefix(strings.ToLower(u.Hostname()), "x"),
+		port:   u.Port(),
+	}, nil
+}
+
+// Match reports whether origin, as sent in an Origin header, matches p.
+func (p *OriginPattern) Match(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme != p.scheme || u.Port() != p.port || u.Path != "" || u.User != nil {
+		return false
+	}
+	label, ok := strings.CutSuffix(strings.ToLower(u.Hostname()), p.suffix)
+	return ok && isHostLabel(label)
+}
+
+func isHostLabel(s string) bool {
+	if s == "" || len(s) > 63 || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateOrigin reports whether origin is a URL origin: a scheme and host,
+// optionally with a port, and nothing else.
+func validateOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" || u.Hostname() == "" {
+		return errors.New("must include a scheme and host, e.g. https://app.example.com")
+	}
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("must be an origin without a path, query or fragment")
+	}
+	if u.Path == "/" {
+		return errors.New("must not end with a slash")
+	}
+	return nil
+}
+
+// OriginPatterns returns the parsed allowed origin patterns, skipping any
+// that are invalid.
+func (c *CORSConfig) OriginPatterns() []*OriginPattern {
+	var patterns []*OriginPattern
+	for _, p := range c.AllowedOriginPatterns {
+		if pattern, err := ParseOriginPattern(p); err == nil {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+func validateCORS(cfg *CORSConfig) ValidationErrors {
+	var errs ValidationErrors
+	if !cfg.Enabled {
+		return errs
+	}
+
+	for i, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			if cfg.AllowCredentials {
+				errs = append(errs, ValidationError{
+					Field:   "server.cors",
+					Message: "security: allow_credentials=true with allowed_origins=[\"*\"] is insecure and rejected by browsers",
+				})
+			}
+			continue
+		}
+		if err := validateOrigin(origin); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("server.cors.allowed_origins[%d]", i),
+				Message: fmt.Sprintf("%q %v", origin, err),
+			})
+		}
+	}
+
+	for i, pattern := range cfg.AllowedOriginPatterns {
+		if _, err := ParseOriginPattern(pattern); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("server.cors.allowed_origin_patterns[%d]", i),
+				Message: fmt.Sprintf("%q %v", pattern, err),
+			})
+		}
+	}
+
+	return errs
+}
@@ -5,19 +5,30 @@ import "time"
 // Default configuration values.
 const (
 	// Server defaults.
-	DefaultHost         = "localhost"
-	DefaultPort         = 8090
-	DefaultReadTimeout  = 30 * time.Second
-	DefaultWriteTimeout = 30 * time.Second
-	DefaultIdleTimeout  = 120 * time.Second
-	DefaultMaxBodySize  = 10 * 1024 * 1024 // 10MB
+	DefaultHost          = "localhost"
+	DefaultPort          = 8090
+	DefaultReadTimeout   = 30 * time.Second
+	DefaultWriteTimeout  = 30 * time.Second
+	DefaultIdleTimeout   = 120 * time.Second
+	DefaultMaxBodySize   = 1024 * 1024       // 1MB
+	DefaultMaxUploadSize = 100 * 1024 * 1024 // 100MB
 
 	// Database defaults.
-	DefaultDBPath       = "alyx.db"
-	DefaultCacheSize    = -64000 // 64MB
-	DefaultBusyTimeout  = 5 * time.Second
-	DefaultMaxOpenConns = 1 // SQLite works best with single writer
-	DefaultMaxIdleConns = 1
+	DefaultDBPath        = "alyx.db"
+	DefaultCacheSize     = -64000 // 64MB
+	DefaultBusyTimeout   = 5 * time.Second
+	DefaultMaxOpenConns  = 1 // SQLite works best with single writer
+	DefaultMaxIdleConns  = 1
+	DefaultStmtCacheSize = 128
+
+	// WAL checkpoint defaults.
+	DefaultCheckpointInterval         = 5 * time.Minute
+	DefaultCheckpointMode             = "PASSIVE"
+	DefaultCheckpointWALSizeThreshold = 64 * 1024 * 1024 // 64MB
+
+	// Busy/locked retry defaults.
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryMaxWait     = 500 * time.Millisecond
 
 	// Auth defaults.
 	DefaultAccessTTL      = 15 * time.Minute
@@ -27,6 +38,23 @@ const (
 	DefaultLoginRateLimit = 5
 	DefaultLoginWindow    = time.Minute
 
+	// Password hashing defaults. Argon2id follows the OWASP password storage
+	// recommendation.
+	DefaultBcryptCost          = 12
+	DefaultArgon2idMemory      = 19 * 1024 // 19 MiB
+	DefaultArgon2idIterations  = 2
+	DefaultArgon2idParallelism = 1
+
+	DefaultVerificationTTL  = 24 * time.Hour
+	DefaultPasswordResetTTL = time.Hour
+	// DefaultDeletionGracePeriod is how long deleted accounts can be restored.
+	DefaultDeletionGracePeriod = 7 * 24 * time.Hour
+
+	// Email defaults.
+	DefaultEmailAppName = "Alyx"
+	DefaultSMTPPort     = 587
+	DefaultSMTPTimeout  = 10 * time.Second
+
 	// Functions defaults.
 	DefaultFunctionsPath   = "functions"
 	DefaultFunctionTimeout = 30 * time.Second
@@ -36,24 +64,44 @@ const (
 	DefaultLogFormat = "console"
 
 	// Realtime defaults.
+	DefaultRealtimeMode              = "auto"
 	DefaultPollInterval              = 50 * time.Millisecond
 	DefaultMaxConnections            = 1000
 	DefaultMaxSubscriptionsPerClient = 100
 	DefaultChangeBufferSize          = 1000
 	DefaultCleanupInterval           = 5 * time.Minute
 	DefaultCleanupAge                = time.Hour
+	DefaultPingInterval              = 30 * time.Second
+	DefaultMaxMissedPongs            = 2
+	DefaultRealtimeWriteTimeout      = 10 * time.Second
+	DefaultSlowClientTimeout         = 5 * time.Second
+
+	// Retention defaults.
+	DefaultRetentionInterval   = time.Hour
+	DefaultRetentionBatchSize  = 500
+	DefaultRetentionBatchSleep = 50 * time.Millisecond
+
+	// Change feed defaults.
+	DefaultChangesRetention = 7 * 24 * time.Hour
+
+	// Tracing defaults.
+	DefaultTracingEndpoint    = "localhost:4318"
+	DefaultTracingSampleRatio = 1.0
+	DefaultTracingServiceName = "alyx"
 )
 
 // Default returns a Config with sensible defaults.
 func Default() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host:         DefaultHost,
-			Port:         DefaultPort,
-			ReadTimeout:  DefaultReadTimeout,
-			WriteTimeout: DefaultWriteTimeout,
-			IdleTimeout:  DefaultIdleTimeout,
-			MaxBodySize:  DefaultMaxBodySize,
+			Host:          DefaultHost,
+			Port:          DefaultPort,
+			ReadTimeout:   DefaultReadTimeout,
+			WriteTimeout:  DefaultWriteTimeout,
+			IdleTimeout:   DefaultIdleTimeout,
+			MaxBodySize:   DefaultMaxBodySize,
+			MaxUploadSize: DefaultMaxUploadSize,
+			ErrorFormat:   ErrorFormatAlyx,
 			CORS: CORSConfig{
 				Enabled:          true,
 				AllowedOrigins:   []string{"*"},
@@ -61,15 +109,32 @@ func Default() *Config {
 				AllowCredentials: false,
 				MaxAge:           12 * time.Hour,
 			},
+			Compression: CompressionConfig{
+				Enabled: false,
+				MinSize: 1024,
+				Types:   []string{"application/json", "text/plain"},
+			},
 		},
 		Database: DatabaseConfig{
-			Path: DefaultDBPath,
+			Path:          DefaultDBPath,
+			StmtCacheSize: DefaultStmtCacheSize,
+			Checkpoint: CheckpointConfig{
+				Enabled:          true,
+				Interval:         DefaultCheckpointInterval,
+				Mode:             DefaultCheckpointMode,
+				WALSizeThreshold: DefaultCheckpointWALSizeThreshold,
+			},
+			Retry: RetryConfig{
+				MaxAttempts: DefaultRetryMaxAttempts,
+				MaxWait:     DefaultRetryMaxWait,
+			},
 		},
 		Auth: AuthConfig{
 			JWT: JWTConfig{
 				AccessTTL:  DefaultAccessTTL,
 				RefreshTTL: DefaultRefreshTTL,
 				Issuer:     DefaultJWTIssuer,
+				Algorithm:  JWTAlgorithmHS256,
 			},
 			Password: PasswordConfig{
 				MinLength:        DefaultMinPassword,
@@ -77,6 +142,15 @@ func Default() *Config {
 				RequireLowercase: false,
 				RequireNumber:    false,
 				RequireSpecial:   false,
+				Hash: PasswordHashConfig{
+					Algorithm: PasswordHashBcrypt,
+					Bcrypt:    BcryptConfig{Cost: DefaultBcryptCost},
+					Argon2id: Argon2idConfig{
+						Memory:      DefaultArgon2idMemory,
+						Iterations:  DefaultArgon2idIterations,
+						Parallelism: DefaultArgon2idParallelism,
+					},
+				},
 			},
 			RateLimit: AuthRateLimitConfig{
 				Login: RateLimitRule{
@@ -94,13 +168,18 @@ func Default() *Config {
 			},
 			AllowRegistration:   true,
 			RequireVerification: false,
+			FirstUserAdmin:      true,
+			VerificationTTL:     DefaultVerificationTTL,
+			PasswordResetTTL:    DefaultPasswordResetTTL,
+			DeletionGracePeriod: DefaultDeletionGracePeriod,
 			OAuth:               make(map[string]OAuthProviderConfig),
 		},
 		Functions: FunctionsConfig{
-			Enabled: true,
-			Path:    DefaultFunctionsPath,
-			Timeout: DefaultFunctionTimeout,
-			Env:     make(map[string]string),
+			Enabled:       true,
+			Path:          DefaultFunctionsPath,
+			Timeout:       DefaultFunctionTimeout,
+			Env:           make(map[string]string),
+			ValidateInput: true,
 		},
 		Logging: LoggingConfig{
 			Level:     DefaultLogLevel,
@@ -115,22 +194,28 @@ func Default() *Config {
 			AutoGenerate:      true,
 			GenerateLanguages: []string{"typescript"},
 			GenerateOutput:    "generated",
+			GenerateDates:     GenerateDatesString,
 		},
 		Docs: DocsConfig{
 			Enabled:     true,
+			UIs:         []string{},
 			UI:          "scalar",
 			Title:       "Alyx API",
 			Description: "Auto-generated API documentation",
-			Version:     "1.0.0",
 		},
 		Realtime: RealtimeConfig{
 			Enabled:                   true,
+			Mode:                      DefaultRealtimeMode,
 			PollInterval:              DefaultPollInterval,
 			MaxConnections:            DefaultMaxConnections,
 			MaxSubscriptionsPerClient: DefaultMaxSubscriptionsPerClient,
 			ChangeBufferSize:          DefaultChangeBufferSize,
 			CleanupInterval:           DefaultCleanupInterval,
 			CleanupAge:                DefaultCleanupAge,
+			PingInterval:              DefaultPingInterval,
+			MaxMissedPongs:            DefaultMaxMissedPongs,
+			WriteTimeout:              DefaultRealtimeWriteTimeout,
+			SlowClientTimeout:         DefaultSlowClientTimeout,
 		},
 		AdminUI: AdminUIConfig{
 			Enabled: true,
@@ -139,5 +224,33 @@ func Default() *Config {
 		Storage: StorageConfig{
 			Backends: make(map[string]StorageBackendConfig),
 		},
+		Retention: RetentionConfig{
+			Enabled:          true,
+			Interval:         DefaultRetentionInterval,
+			BatchSize:        DefaultRetentionBatchSize,
+			BatchSleep:       DefaultRetentionBatchSleep,
+			SuppressRealtime: false,
+		},
+		Changes: ChangesConfig{
+			Enabled:   false,
+			Retention: DefaultChangesRetention,
+			Include:   ChangesIncludeKeys,
+		},
+		Email: EmailConfig{
+			AppName: DefaultEmailAppName,
+			SMTP: SMTPConfig{
+				Port:    DefaultSMTPPort,
+				TLS:     SMTPTLSStartTLS,
+				Timeout: DefaultSMTPTimeout,
+			},
+		},
+		Observability: ObservabilityConfig{
+			Tracing: TracingConfig{
+				Enabled:     false,
+				Endpoint:    DefaultTracingEndpoint,
+				SampleRatio: DefaultTracingSampleRatio,
+				ServiceName: DefaultTracingServiceName,
+			},
+		},
 	}
 }
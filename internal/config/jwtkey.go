@@ -0,0 +1,59 @@
+package config
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LoadPrivateKey reads PrivateKeyFile and checks that it fits Algorithm: an
+// RSA key of at least 2048 bits for RS256 or an Ed25519 key for EdDSA. PKCS#8
+// keys are accepted for both, and PKCS#1 keys for RS256.
+func (c *JWTConfig) LoadPrivateKey() (crypto.Signer, error) {
+	data, err := os.ReadFile(c.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	switch c.Algorithm {
+	case JWTAlgorithmRS256:
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("RS256 needs an RSA private key")
+		}
+		if rsaKey.N.BitLen() < 2048 {
+			return nil, errors.New("RSA private key must be at least 2048 bits")
+		}
+		return rsaKey, nil
+	case JWTAlgorithmEdDSA:
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("EdDSA needs an Ed25519 private key")
+		}
+		return edKey, nil
+	default:
+		return nil, fmt.Errorf("algorithm %s does not use a private key", c.Algorithm)
+	}
+}
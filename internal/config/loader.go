@@ -1,12 +1,14 @@
 package config
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 )
 
@@ -20,8 +22,16 @@ type LoadOptions struct {
 	ConfigFile string
 	EnvPrefix  string
 	Defaults   *Config
+
+	// Env selects an environment overlay such as "production". When set,
+	// alyx.<env>.yaml next to the base config is deep-merged over it.
+	// Defaults to the <EnvPrefix>_ENV environment variable.
+	Env string
 }
 
+// SourceEnvironment marks config values that were set through environment variables.
+const SourceEnvironment = "environment"
+
 func Load(opts LoadOptions) (*Config, error) {
 	v := viper.New()
 
@@ -55,12 +65,46 @@ func Load(opts LoadOptions) (*Config, error) {
 		}
 	}
 
+	sources := make(map[string]string)
+	if baseFile := v.ConfigFileUsed(); baseFile != "" {
+		for _, key := range v.AllKeys() {
+			if v.InConfig(key) {
+				sources[key] = baseFile
+			}
+		}
+	}
+
+	env := opts.Env
+	if env == "" {
+		env = os.Getenv(opts.EnvPrefix + "_ENV")
+	}
+	if env != "" {
+		if err := mergeOverlay(v, OverlayPath(v.ConfigFileUsed(), env), sources); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range v.AllKeys() {
+		envVar := opts.EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if _, ok := os.LookupEnv(envVar); ok {
+			sources[key] = SourceEnvironment
+		}
+	}
+
 	expandEnvInConfig(v)
 
 	cfg := &Config{}
-	if err := v.Unmarshal(cfg); err != nil {
+	hook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		oauthProvidersHook,
+	))
+	if err := v.Unmarshal(cfg, hook); err != nil {
 		return nil, fmt.Errorf("unmarshaling config: %w", err)
 	}
+	cfg.Auth.OAuthRedirectURIAllowlist = v.GetStringSlice("auth.oauth." + oauthRedirectURIAllowlistKey)
+	cfg.Env = env
+	cfg.Sources = sources
 
 	if err := Validate(cfg); err != nil {
 		return nil, err
@@ -69,6 +113,44 @@ func Load(opts LoadOptions) (*Config, error) {
 	return cfg, nil
 }
 
+// OverlayPath returns the overlay file for env next to the given base config file,
+// e.g. alyx.yaml -> alyx.production.yaml. An empty base resolves to ./alyx.<env>.yaml.
+func OverlayPath(base, env string) string {
+	if base == "" {
+		return "alyx." + env + ".yaml"
+	}
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + env + ext
+}
+
+// mergeOverlay deep-merges the overlay file into v if it exists. Maps are merged
+// key by key, while scalars and arrays in the overlay replace the base value.
+func mergeOverlay(v *viper.Viper, path string, sources map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading config overlay: %w", err)
+	}
+
+	overlay := viper.New()
+	overlay.SetConfigType("yaml")
+	if err := overlay.ReadConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("parsing config overlay %s: %w", path, err)
+	}
+
+	if err := v.MergeConfigMap(overlay.AllSettings()); err != nil {
+		return fmt.Errorf("merging config overlay %s: %w", path, err)
+	}
+
+	for _, key := range overlay.AllKeys() {
+		sources[key] = path
+	}
+
+	return nil
+}
+
 func LoadFromFile(path string) (*Config, error) {
 	return Load(LoadOptions{ConfigFile: path})
 }
@@ -84,25 +166,45 @@ func setViperDefaults(v *viper.Viper, cfg *Config) {
 	v.SetDefault("server.write_timeout", cfg.Server.WriteTimeout)
 	v.SetDefault("server.idle_timeout", cfg.Server.IdleTimeout)
 	v.SetDefault("server.max_body_size", cfg.Server.MaxBodySize)
+	v.SetDefault("server.max_upload_size", cfg.Server.MaxUploadSize)
+	v.SetDefault("server.error_format", cfg.Server.ErrorFormat)
 
 	v.SetDefault("server.cors.enabled", cfg.Server.CORS.Enabled)
 	v.SetDefault("server.cors.allowed_origins", cfg.Server.CORS.AllowedOrigins)
+	v.SetDefault("server.cors.allowed_origin_patterns", cfg.Server.CORS.AllowedOriginPatterns)
 	v.SetDefault("server.cors.exposed_headers", cfg.Server.CORS.ExposedHeaders)
 	// CORS methods and headers are hard-coded (see CORSConfig methods)
 	v.SetDefault("server.cors.allow_credentials", cfg.Server.CORS.AllowCredentials)
 	v.SetDefault("server.cors.max_age", cfg.Server.CORS.MaxAge)
 
+	v.SetDefault("server.compression.enabled", cfg.Server.Compression.Enabled)
+	v.SetDefault("server.compression.min_size", cfg.Server.Compression.MinSize)
+	v.SetDefault("server.compression.types", cfg.Server.Compression.Types)
+
 	v.SetDefault("database.path", cfg.Database.Path)
+	v.SetDefault("database.stmt_cache_size", cfg.Database.StmtCacheSize)
+	v.SetDefault("database.checkpoint.enabled", cfg.Database.Checkpoint.Enabled)
+	v.SetDefault("database.checkpoint.interval", cfg.Database.Checkpoint.Interval)
+	v.SetDefault("database.checkpoint.mode", cfg.Database.Checkpoint.Mode)
+	v.SetDefault("database.checkpoint.wal_size_threshold", cfg.Database.Checkpoint.WALSizeThreshold)
+	v.SetDefault("database.retry.max_attempts", cfg.Database.Retry.MaxAttempts)
+	v.SetDefault("database.retry.max_wait", cfg.Database.Retry.MaxWait)
 	// Database connection settings are hard-coded (see DatabaseConfig methods)
 
 	v.SetDefault("auth.jwt.access_ttl", cfg.Auth.JWT.AccessTTL)
 	v.SetDefault("auth.jwt.refresh_ttl", cfg.Auth.JWT.RefreshTTL)
 	v.SetDefault("auth.jwt.issuer", cfg.Auth.JWT.Issuer)
+	v.SetDefault("auth.jwt.algorithm", cfg.Auth.JWT.Algorithm)
 	v.SetDefault("auth.password.min_length", cfg.Auth.Password.MinLength)
 	v.SetDefault("auth.password.require_uppercase", cfg.Auth.Password.RequireUppercase)
 	v.SetDefault("auth.password.require_lowercase", cfg.Auth.Password.RequireLowercase)
 	v.SetDefault("auth.password.require_number", cfg.Auth.Password.RequireNumber)
 	v.SetDefault("auth.password.require_special", cfg.Auth.Password.RequireSpecial)
+	v.SetDefault("auth.password.hash.algorithm", cfg.Auth.Password.Hash.Algorithm)
+	v.SetDefault("auth.password.hash.bcrypt.cost", cfg.Auth.Password.Hash.Bcrypt.Cost)
+	v.SetDefault("auth.password.hash.argon2id.memory", cfg.Auth.Password.Hash.Argon2id.Memory)
+	v.SetDefault("auth.password.hash.argon2id.iterations", cfg.Auth.Password.Hash.Argon2id.Iterations)
+	v.SetDefault("auth.password.hash.argon2id.parallelism", cfg.Auth.Password.Hash.Argon2id.Parallelism)
 	v.SetDefault("auth.rate_limit.login.max", cfg.Auth.RateLimit.Login.Max)
 	v.SetDefault("auth.rate_limit.login.window", cfg.Auth.RateLimit.Login.Window)
 	v.SetDefault("auth.rate_limit.register.max", cfg.Auth.RateLimit.Register.Max)
@@ -111,15 +213,21 @@ func setViperDefaults(v *viper.Viper, cfg *Config) {
 	v.SetDefault("auth.rate_limit.password_reset.window", cfg.Auth.RateLimit.PasswordReset.Window)
 	v.SetDefault("auth.allow_registration", cfg.Auth.AllowRegistration)
 	v.SetDefault("auth.require_verification", cfg.Auth.RequireVerification)
+	v.SetDefault("auth.first_user_admin", cfg.Auth.FirstUserAdmin)
+	v.SetDefault("auth.verification_ttl", cfg.Auth.VerificationTTL)
+	v.SetDefault("auth.password_reset_ttl", cfg.Auth.PasswordResetTTL)
+	v.SetDefault("auth.deletion_grace_period", cfg.Auth.DeletionGracePeriod)
 
 	v.SetDefault("functions.enabled", cfg.Functions.Enabled)
 	v.SetDefault("functions.path", cfg.Functions.Path)
 	v.SetDefault("functions.timeout", cfg.Functions.Timeout)
+	v.SetDefault("functions.validate_input", cfg.Functions.ValidateInput)
 
 	v.SetDefault("logging.level", cfg.Logging.Level)
 	v.SetDefault("logging.format", cfg.Logging.Format)
 	v.SetDefault("logging.caller", cfg.Logging.Caller)
 	v.SetDefault("logging.timestamp", cfg.Logging.Timestamp)
+	v.SetDefault("logging.rule_denials", cfg.Logging.RuleDenials)
 
 	v.SetDefault("dev.enabled", cfg.Dev.Enabled)
 	v.SetDefault("dev.watch", cfg.Dev.Watch)
@@ -127,27 +235,94 @@ func setViperDefaults(v *viper.Viper, cfg *Config) {
 	v.SetDefault("dev.auto_generate", cfg.Dev.AutoGenerate)
 	v.SetDefault("dev.generate_languages", cfg.Dev.GenerateLanguages)
 	v.SetDefault("dev.generate_output", cfg.Dev.GenerateOutput)
+	v.SetDefault("dev.generate_dates", cfg.Dev.GenerateDates)
+	v.SetDefault("dev.sandbox", cfg.Dev.Sandbox)
+	v.SetDefault("dev.sandbox_seed", cfg.Dev.SandboxSeed)
 
 	v.SetDefault("docs.enabled", cfg.Docs.Enabled)
+	v.SetDefault("docs.uis", cfg.Docs.UIs)
 	v.SetDefault("docs.ui", cfg.Docs.UI)
 	v.SetDefault("docs.title", cfg.Docs.Title)
 	v.SetDefault("docs.description", cfg.Docs.Description)
 	v.SetDefault("docs.version", cfg.Docs.Version)
 
+	v.SetDefault("realtime.enabled", cfg.Realtime.Enabled)
+	v.SetDefault("realtime.mode", cfg.Realtime.Mode)
+	v.SetDefault("realtime.poll_interval", cfg.Realtime.PollInterval)
+	v.SetDefault("realtime.max_connections", cfg.Realtime.MaxConnections)
+	v.SetDefault("realtime.max_subscriptions_per_client", cfg.Realtime.MaxSubscriptionsPerClient)
+	v.SetDefault("realtime.change_buffer_size", cfg.Realtime.ChangeBufferSize)
+	v.SetDefault("realtime.cleanup_interval", cfg.Realtime.CleanupInterval)
+	v.SetDefault("realtime.cleanup_age", cfg.Realtime.CleanupAge)
+	v.SetDefault("realtime.ping_interval", cfg.Realtime.PingInterval)
+	v.SetDefault("realtime.max_missed_pongs", cfg.Realtime.MaxMissedPongs)
+	v.SetDefault("realtime.write_timeout", cfg.Realtime.WriteTimeout)
+	v.SetDefault("realtime.slow_client_timeout", cfg.Realtime.SlowClientTimeout)
+
 	v.SetDefault("admin_ui.enabled", cfg.AdminUI.Enabled)
 	v.SetDefault("admin_ui.path", cfg.AdminUI.Path)
+
+	v.SetDefault("retention.enabled", cfg.Retention.Enabled)
+	v.SetDefault("retention.interval", cfg.Retention.Interval)
+	v.SetDefault("retention.batch_size", cfg.Retention.BatchSize)
+	v.SetDefault("retention.batch_sleep", cfg.Retention.BatchSleep)
+	v.SetDefault("retention.suppress_realtime", cfg.Retention.SuppressRealtime)
+
+	v.SetDefault("changes.enabled", cfg.Changes.Enabled)
+	v.SetDefault("changes.retention", cfg.Changes.Retention)
+	v.SetDefault("changes.include", cfg.Changes.Include)
+
+	v.SetDefault("security.field_encryption_key", cfg.Security.FieldEncryptionKey)
+
+	v.SetDefault("email.enabled", cfg.Email.Enabled)
+	v.SetDefault("email.app_name", cfg.Email.AppName)
+	v.SetDefault("email.smtp.port", cfg.Email.SMTP.Port)
+	v.SetDefault("email.smtp.tls", cfg.Email.SMTP.TLS)
+	v.SetDefault("email.smtp.timeout", cfg.Email.SMTP.Timeout)
+
+	v.SetDefault("health.require_functions", cfg.Health.RequireFunctions)
+
+	v.SetDefault("observability.tracing.enabled", cfg.Observability.Tracing.Enabled)
+	v.SetDefault("observability.tracing.endpoint", cfg.Observability.Tracing.Endpoint)
+	v.SetDefault("observability.tracing.insecure", cfg.Observability.Tracing.Insecure)
+	v.SetDefault("observability.tracing.sample_ratio", cfg.Observability.Tracing.SampleRatio)
+	v.SetDefault("observability.tracing.service_name", cfg.Observability.Tracing.ServiceName)
 }
 
 func expandEnvInConfig(v *viper.Viper) {
 	for _, key := range v.AllKeys() {
-		val := v.GetString(key)
-		if strings.HasPrefix(val, "${") && strings.HasSuffix(val, "}") {
-			envVar := val[2 : len(val)-1]
-			if envVal := os.Getenv(envVar); envVal != "" {
-				v.Set(key, envVal)
+		// Lists such as auth.jwt.secrets may reference variables per item.
+		if items, ok := v.Get(key).([]any); ok {
+			expanded := make([]any, len(items))
+			changed := false
+			for i, item := range items {
+				expanded[i] = item
+				if str, ok := item.(string); ok {
+					if envVal, ok := expandEnvRef(str); ok {
+						expanded[i] = envVal
+						changed = true
+					}
+				}
+			}
+			if changed {
+				v.Set(key, expanded)
 			}
+			continue
 		}
+
+		if envVal, ok := expandEnvRef(v.GetString(key)); ok {
+			v.Set(key, envVal)
+		}
+	}
+}
+
+// expandEnvRef resolves a whole-value ${VAR} reference to a set variable.
+func expandEnvRef(val string) (string, bool) {
+	if !strings.HasPrefix(val, "${") || !strings.HasSuffix(val, "}") {
+		return "", false
 	}
+	envVal := os.Getenv(val[2 : len(val)-1])
+	return envVal, envVal != ""
 }
 
 func ConfigFilePath(customPath string) (string, error) {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -12,6 +13,7 @@ const (
 	FieldTypeString      ConfigFieldType = "string"
 	FieldTypeInt         ConfigFieldType = "int"
 	FieldTypeInt64       ConfigFieldType = "int64"
+	FieldTypeFloat       ConfigFieldType = "float"
 	FieldTypeBool        ConfigFieldType = "bool"
 	FieldTypeDuration    ConfigFieldType = "duration"
 	FieldTypeStringArray ConfigFieldType = "stringArray"
@@ -30,6 +32,7 @@ type ConfigFieldMeta struct {
 	Required    bool            `json:"required,omitempty"`
 	Options     []string        `json:"options,omitempty"`
 	Fields      map[string]any  `json:"fields,omitempty"` // For nested objects
+	Source      string          `json:"source,omitempty"` // File (or "environment") the current value came from
 }
 
 // ConfigSectionMeta holds metadata about a configuration section.
@@ -80,10 +83,23 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 				},
 				"max_body_size": ConfigFieldMeta{
 					Type:        FieldTypeInt64,
-					Description: "Maximum request body size in bytes",
+					Description: "Maximum request body size in bytes for non-upload routes",
 					Default:     defaults.Server.MaxBodySize,
 					Current:     current.Server.MaxBodySize,
 				},
+				"max_upload_size": ConfigFieldMeta{
+					Type:        FieldTypeInt64,
+					Description: "Maximum request body size in bytes for file uploads",
+					Default:     defaults.Server.MaxUploadSize,
+					Current:     current.Server.MaxUploadSize,
+				},
+				"error_format": ConfigFieldMeta{
+					Type:        FieldTypeString,
+					Description: "Error response format",
+					Default:     defaults.Server.ErrorFormat,
+					Current:     current.Server.ErrorFormat,
+					Options:     []string{ErrorFormatAlyx, ErrorFormatProblem},
+				},
 				"cors": ConfigFieldMeta{
 					Type:        FieldTypeObject,
 					Description: "CORS settings",
@@ -100,6 +116,12 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 							Default:     defaults.Server.CORS.AllowedOrigins,
 							Current:     current.Server.CORS.AllowedOrigins,
 						},
+						"allowed_origin_patterns": ConfigFieldMeta{
+							Type:        FieldTypeStringArray,
+							Description: "Allowed origin patterns; * matches one subdomain label, e.g. https://*.example.com",
+							Default:     defaults.Server.CORS.AllowedOriginPatterns,
+							Current:     current.Server.CORS.AllowedOriginPatterns,
+						},
 						"exposed_headers": ConfigFieldMeta{
 							Type:        FieldTypeStringArray,
 							Description: "Exposed headers",
@@ -120,6 +142,30 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 						},
 					},
 				},
+				"compression": ConfigFieldMeta{
+					Type:        FieldTypeObject,
+					Description: "Response compression settings",
+					Fields: map[string]any{
+						"enabled": ConfigFieldMeta{
+							Type:        FieldTypeBool,
+							Description: "Enable gzip/brotli response compression",
+							Default:     defaults.Server.Compression.Enabled,
+							Current:     current.Server.Compression.Enabled,
+						},
+						"min_size": ConfigFieldMeta{
+							Type:        FieldTypeInt,
+							Description: "Smallest response body in bytes that is compressed",
+							Default:     defaults.Server.Compression.MinSize,
+							Current:     current.Server.Compression.MinSize,
+						},
+						"types": ConfigFieldMeta{
+							Type:        FieldTypeStringArray,
+							Description: "Content types that are compressed",
+							Default:     defaults.Server.Compression.Types,
+							Current:     current.Server.Compression.Types,
+						},
+					},
+				},
 				"tls": ConfigFieldMeta{
 					Type:        FieldTypeObject,
 					Description: "TLS configuration (optional)",
@@ -168,6 +214,61 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 					Default:     defaults.Database.Path,
 					Current:     current.Database.Path,
 				},
+				"stmt_cache_size": ConfigFieldMeta{
+					Type:        FieldTypeInt,
+					Description: "Maximum number of prepared statements cached for hot queries (0 disables)",
+					Default:     defaults.Database.StmtCacheSize,
+					Current:     current.Database.StmtCacheSize,
+				},
+				"checkpoint": ConfigFieldMeta{
+					Type:        FieldTypeObject,
+					Description: "Background WAL checkpoint policy",
+					Fields: map[string]any{
+						"enabled": ConfigFieldMeta{
+							Type:        FieldTypeBool,
+							Description: "Enable background checkpoints",
+							Default:     defaults.Database.Checkpoint.Enabled,
+							Current:     current.Database.Checkpoint.Enabled,
+						},
+						"interval": ConfigFieldMeta{
+							Type:        FieldTypeDuration,
+							Description: "How often the WAL size is checked",
+							Default:     formatDuration(defaults.Database.Checkpoint.Interval),
+							Current:     formatDuration(current.Database.Checkpoint.Interval),
+						},
+						"mode": ConfigFieldMeta{
+							Type:        FieldTypeString,
+							Description: "Checkpoint mode",
+							Default:     defaults.Database.Checkpoint.Mode,
+							Current:     current.Database.Checkpoint.Mode,
+							Options:     []string{"PASSIVE", "FULL", "RESTART", "TRUNCATE"},
+						},
+						"wal_size_threshold": ConfigFieldMeta{
+							Type:        FieldTypeInt64,
+							Description: "Checkpoint once the WAL file reaches this many bytes (0 checkpoints every interval)",
+							Default:     defaults.Database.Checkpoint.WALSizeThreshold,
+							Current:     current.Database.Checkpoint.WALSizeThreshold,
+						},
+					},
+				},
+				"retry": ConfigFieldMeta{
+					Type:        FieldTypeObject,
+					Description: "Retries of writes that fail because the database is busy or locked",
+					Fields: map[string]any{
+						"max_attempts": ConfigFieldMeta{
+							Type:        FieldTypeInt,
+							Description: "Attempts in total, including the first (1 disables retries)",
+							Default:     defaults.Database.Retry.MaxAttempts,
+							Current:     current.Database.Retry.MaxAttempts,
+						},
+						"max_wait": ConfigFieldMeta{
+							Type:        FieldTypeDuration,
+							Description: "Longest total time spent backing off between attempts",
+							Default:     formatDuration(defaults.Database.Retry.MaxWait),
+							Current:     formatDuration(current.Database.Retry.MaxWait),
+						},
+					},
+				},
 				"turso": ConfigFieldMeta{
 					Type:        FieldTypeObject,
 					Description: "Turso configuration (optional, for distributed deployments)",
@@ -211,6 +312,30 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 					Default:     defaults.Auth.RequireVerification,
 					Current:     current.Auth.RequireVerification,
 				},
+				"first_user_admin": ConfigFieldMeta{
+					Type:        FieldTypeBool,
+					Description: "Promote the first registered user to admin",
+					Default:     defaults.Auth.FirstUserAdmin,
+					Current:     current.Auth.FirstUserAdmin,
+				},
+				"verification_ttl": ConfigFieldMeta{
+					Type:        FieldTypeDuration,
+					Description: "How long email verification links stay valid",
+					Default:     formatDuration(defaults.Auth.VerificationTTL),
+					Current:     formatDuration(current.Auth.VerificationTTL),
+				},
+				"password_reset_ttl": ConfigFieldMeta{
+					Type:        FieldTypeDuration,
+					Description: "How long password reset links stay valid",
+					Default:     formatDuration(defaults.Auth.PasswordResetTTL),
+					Current:     formatDuration(current.Auth.PasswordResetTTL),
+				},
+				"deletion_grace_period": ConfigFieldMeta{
+					Type:        FieldTypeDuration,
+					Description: "How long a self-deleted account can be restored before it is removed",
+					Default:     formatDuration(defaults.Auth.DeletionGracePeriod),
+					Current:     formatDuration(current.Auth.DeletionGracePeriod),
+				},
 				"jwt": ConfigFieldMeta{
 					Type:        FieldTypeObject,
 					Description: "JWT configuration",
@@ -223,6 +348,13 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 							Default:     "",
 							Current:     isSecretSet(current.Auth.JWT.Secret),
 						},
+						"secrets": ConfigFieldMeta{
+							Type:        FieldTypeStringArray,
+							Description: "Rotation secrets: the first signs tokens, all verify them",
+							Sensitive:   true,
+							Default:     []string{},
+							Current:     maskSecrets(current.Auth.JWT.Secrets),
+						},
 						"access_ttl": ConfigFieldMeta{
 							Type:        FieldTypeDuration,
 							Description: "Access token lifetime",
@@ -247,6 +379,19 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 							Default:     defaults.Auth.JWT.Audience,
 							Current:     current.Auth.JWT.Audience,
 						},
+						"algorithm": ConfigFieldMeta{
+							Type:        FieldTypeString,
+							Description: "Signing algorithm; RS256 and EdDSA sign with private_key_file",
+							Options:     []string{JWTAlgorithmHS256, JWTAlgorithmRS256, JWTAlgorithmEdDSA},
+							Default:     defaults.Auth.JWT.Algorithm,
+							Current:     current.Auth.JWT.Algorithm,
+						},
+						"private_key_file": ConfigFieldMeta{
+							Type:        FieldTypeString,
+							Description: "PEM private key for RS256 or EdDSA",
+							Default:     "",
+							Current:     current.Auth.JWT.PrivateKeyFile,
+						},
 					},
 				},
 				"password": ConfigFieldMeta{
@@ -283,6 +428,55 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 							Default:     defaults.Auth.Password.RequireSpecial,
 							Current:     current.Auth.Password.RequireSpecial,
 						},
+						"hash": ConfigFieldMeta{
+							Type:        FieldTypeObject,
+							Description: "Password hashing; older hashes are upgraded on login",
+							Fields: map[string]any{
+								"algorithm": ConfigFieldMeta{
+									Type:        FieldTypeString,
+									Description: "Hashing algorithm",
+									Default:     defaults.Auth.Password.Hash.Algorithm,
+									Current:     current.Auth.Password.Hash.Algorithm,
+									Options:     []string{PasswordHashBcrypt, PasswordHashArgon2id},
+								},
+								"bcrypt": ConfigFieldMeta{
+									Type:        FieldTypeObject,
+									Description: "bcrypt parameters",
+									Fields: map[string]any{
+										"cost": ConfigFieldMeta{
+											Type:        FieldTypeInt,
+											Description: "Cost (4-31); each step doubles the work",
+											Default:     defaults.Auth.Password.Hash.Bcrypt.Cost,
+											Current:     current.Auth.Password.Hash.Bcrypt.Cost,
+										},
+									},
+								},
+								"argon2id": ConfigFieldMeta{
+									Type:        FieldTypeObject,
+									Description: "argon2id parameters",
+									Fields: map[string]any{
+										"memory": ConfigFieldMeta{
+											Type:        FieldTypeInt,
+											Description: "Memory in KiB",
+											Default:     defaults.Auth.Password.Hash.Argon2id.Memory,
+											Current:     current.Auth.Password.Hash.Argon2id.Memory,
+										},
+										"iterations": ConfigFieldMeta{
+											Type:        FieldTypeInt,
+											Description: "Passes over the memory",
+											Default:     defaults.Auth.Password.Hash.Argon2id.Iterations,
+											Current:     current.Auth.Password.Hash.Argon2id.Iterations,
+										},
+										"parallelism": ConfigFieldMeta{
+											Type:        FieldTypeInt,
+											Description: "Threads used per hash",
+											Default:     defaults.Auth.Password.Hash.Argon2id.Parallelism,
+											Current:     current.Auth.Password.Hash.Argon2id.Parallelism,
+										},
+									},
+								},
+							},
+						},
 					},
 				},
 				"rate_limit": ConfigFieldMeta{
@@ -330,6 +524,12 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 					Default:     defaults.Functions.Env,
 					Current:     current.Functions.Env,
 				},
+				"validate_input": ConfigFieldMeta{
+					Type:        FieldTypeBool,
+					Description: "Reject invocations that do not match the function's declared input",
+					Default:     defaults.Functions.ValidateInput,
+					Current:     current.Functions.ValidateInput,
+				},
 			},
 		},
 		"realtime": {
@@ -342,9 +542,16 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 					Default:     defaults.Realtime.Enabled,
 					Current:     current.Realtime.Enabled,
 				},
+				"mode": ConfigFieldMeta{
+					Type:        FieldTypeString,
+					Description: "Change detection: commit hooks, polling, or hooks when available",
+					Default:     defaults.Realtime.Mode,
+					Current:     current.Realtime.Mode,
+					Options:     []string{"auto", "poll", "hook"},
+				},
 				"poll_interval": ConfigFieldMeta{
 					Type:        FieldTypeDuration,
-					Description: "Polling interval for changes",
+					Description: "Polling interval for changes when commit hooks are not used",
 					Default:     formatDuration(defaults.Realtime.PollInterval),
 					Current:     formatDuration(current.Realtime.PollInterval),
 				},
@@ -368,16 +575,260 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 				},
 				"cleanup_interval": ConfigFieldMeta{
 					Type:        FieldTypeDuration,
-					Description: "Cleanup interval for stale connections",
+					Description: "Interval between pruning processed changes",
 					Default:     formatDuration(defaults.Realtime.CleanupInterval),
 					Current:     formatDuration(current.Realtime.CleanupInterval),
 				},
 				"cleanup_age": ConfigFieldMeta{
 					Type:        FieldTypeDuration,
-					Description: "Age threshold for cleanup",
+					Description: "How long processed changes are kept for subscription catch-up",
 					Default:     formatDuration(defaults.Realtime.CleanupAge),
 					Current:     formatDuration(current.Realtime.CleanupAge),
 				},
+				"ping_interval": ConfigFieldMeta{
+					Type:        FieldTypeDuration,
+					Description: "Interval between keepalive pings to each client",
+					Default:     formatDuration(defaults.Realtime.PingInterval),
+					Current:     formatDuration(current.Realtime.PingInterval),
+				},
+				"max_missed_pongs": ConfigFieldMeta{
+					Type:        FieldTypeInt,
+					Description: "Unanswered pings in a row before a client is disconnected",
+					Default:     defaults.Realtime.MaxMissedPongs,
+					Current:     current.Realtime.MaxMissedPongs,
+				},
+				"write_timeout": ConfigFieldMeta{
+					Type:        FieldTypeDuration,
+					Description: "Maximum time to write a message to a client before disconnecting it",
+					Default:     formatDuration(defaults.Realtime.WriteTimeout),
+					Current:     formatDuration(current.Realtime.WriteTimeout),
+				},
+				"slow_client_timeout": ConfigFieldMeta{
+					Type:        FieldTypeDuration,
+					Description: "How long a client's send buffer may stay full before it is disconnected",
+					Default:     formatDuration(defaults.Realtime.SlowClientTimeout),
+					Current:     formatDuration(current.Realtime.SlowClientTimeout),
+				},
+			},
+		},
+		"retention": {
+			Name:        "Retention",
+			Description: "Collection data retention settings",
+			Fields: map[string]any{
+				"enabled": ConfigFieldMeta{
+					Type:        FieldTypeBool,
+					Description: "Enable background pruning of collections with a retention policy",
+					Default:     defaults.Retention.Enabled,
+					Current:     current.Retention.Enabled,
+				},
+				"interval": ConfigFieldMeta{
+					Type:        FieldTypeDuration,
+					Description: "How often the retention job runs",
+					Default:     formatDuration(defaults.Retention.Interval),
+					Current:     formatDuration(current.Retention.Interval),
+				},
+				"batch_size": ConfigFieldMeta{
+					Type:        FieldTypeInt,
+					Description: "Maximum rows deleted per transaction",
+					Default:     defaults.Retention.BatchSize,
+					Current:     current.Retention.BatchSize,
+				},
+				"batch_sleep": ConfigFieldMeta{
+					Type:        FieldTypeDuration,
+					Description: "Pause between batches",
+					Default:     formatDuration(defaults.Retention.BatchSleep),
+					Current:     formatDuration(current.Retention.BatchSleep),
+				},
+				"suppress_realtime": ConfigFieldMeta{
+					Type:        FieldTypeBool,
+					Description: "Suppress realtime delete events for pruned rows",
+					Default:     defaults.Retention.SuppressRealtime,
+					Current:     current.Retention.SuppressRealtime,
+				},
+			},
+		},
+		"changes": {
+			Name:        "Change Feed",
+			Description: "Change data capture feed settings",
+			Fields: map[string]any{
+				"enabled": ConfigFieldMeta{
+					Type:        FieldTypeBool,
+					Description: "Serve the change feed at /api/admin/changes",
+					Default:     defaults.Changes.Enabled,
+					Current:     current.Changes.Enabled,
+				},
+				"retention": ConfigFieldMeta{
+					Type:        FieldTypeDuration,
+					Description: "How long changes are kept for feed consumers",
+					Default:     formatDuration(defaults.Changes.Retention),
+					Current:     formatDuration(current.Changes.Retention),
+				},
+				"include": ConfigFieldMeta{
+					Type:        FieldTypeString,
+					Description: "What each change carries",
+					Default:     defaults.Changes.Include,
+					Current:     current.Changes.Include,
+					Options:     []string{ChangesIncludeKeys, ChangesIncludeDocument},
+				},
+			},
+		},
+		"security": {
+			Name:        "Security",
+			Description: "Data protection at rest",
+			Fields: map[string]any{
+				"field_encryption_key": ConfigFieldMeta{
+					Type:        FieldTypeSecret,
+					Description: "Base64-encoded 32-byte key for encrypted fields",
+					Sensitive:   true,
+					Default:     "",
+					Current:     isSecretSet(current.Security.FieldEncryptionKey),
+				},
+				"previous_field_encryption_keys": ConfigFieldMeta{
+					Type:        FieldTypeStringArray,
+					Description: "Replaced keys, kept for decryption until rotate-field-key finishes",
+					Sensitive:   true,
+					Default:     []string{},
+					Current:     maskSecrets(current.Security.PreviousFieldEncryptionKeys),
+				},
+			},
+		},
+		"email": {
+			Name:        "Email",
+			Description: "Outgoing email for verification and password reset",
+			Fields: map[string]any{
+				"enabled": ConfigFieldMeta{
+					Type:        FieldTypeBool,
+					Description: "Send email through SMTP (otherwise emails are logged)",
+					Default:     defaults.Email.Enabled,
+					Current:     current.Email.Enabled,
+				},
+				"from": ConfigFieldMeta{
+					Type:        FieldTypeString,
+					Description: "Sender address",
+					Default:     defaults.Email.From,
+					Current:     current.Email.From,
+				},
+				"app_name": ConfigFieldMeta{
+					Type:        FieldTypeString,
+					Description: "Application name used in templates",
+					Default:     defaults.Email.AppName,
+					Current:     current.Email.AppName,
+				},
+				"templates_dir": ConfigFieldMeta{
+					Type:        FieldTypeString,
+					Description: "Directory with template overrides",
+					Default:     defaults.Email.TemplatesDir,
+					Current:     current.Email.TemplatesDir,
+				},
+				"verify_url": ConfigFieldMeta{
+					Type:        FieldTypeString,
+					Description: "Verification link, with {token} replaced by the token",
+					Default:     defaults.Email.VerifyURL,
+					Current:     current.Email.VerifyURL,
+				},
+				"reset_url": ConfigFieldMeta{
+					Type:        FieldTypeString,
+					Description: "Password reset link, with {token} replaced by the token",
+					Default:     defaults.Email.ResetURL,
+					Current:     current.Email.ResetURL,
+				},
+				"restore_url": ConfigFieldMeta{
+					Type:        FieldTypeString,
+					Description: "Account restore link, with {token} replaced by the token",
+					Default:     defaults.Email.RestoreURL,
+					Current:     current.Email.RestoreURL,
+				},
+				"smtp": ConfigFieldMeta{
+					Type:        FieldTypeObject,
+					Description: "SMTP server settings",
+					Fields: map[string]any{
+						"host": ConfigFieldMeta{
+							Type:        FieldTypeString,
+							Description: "Server hostname",
+							Default:     defaults.Email.SMTP.Host,
+							Current:     current.Email.SMTP.Host,
+						},
+						"port": ConfigFieldMeta{
+							Type:        FieldTypeInt,
+							Description: "Server port",
+							Default:     defaults.Email.SMTP.Port,
+							Current:     current.Email.SMTP.Port,
+						},
+						"username": ConfigFieldMeta{
+							Type:        FieldTypeString,
+							Description: "Username for authentication",
+							Default:     defaults.Email.SMTP.Username,
+							Current:     current.Email.SMTP.Username,
+						},
+						"password": ConfigFieldMeta{
+							Type:        FieldTypeSecret,
+							Description: "Password for authentication",
+							Sensitive:   true,
+							Default:     "",
+							Current:     isSecretSet(current.Email.SMTP.Password),
+						},
+						"tls": ConfigFieldMeta{
+							Type:        FieldTypeString,
+							Description: "TLS mode: starttls, tls or none",
+							Default:     defaults.Email.SMTP.TLS,
+							Current:     current.Email.SMTP.TLS,
+						},
+						"insecure_skip_verify": ConfigFieldMeta{
+							Type:        FieldTypeBool,
+							Description: "Skip certificate verification",
+							Default:     defaults.Email.SMTP.InsecureSkipVerify,
+							Current:     current.Email.SMTP.InsecureSkipVerify,
+						},
+						"timeout": ConfigFieldMeta{
+							Type:        FieldTypeDuration,
+							Description: "Timeout for the SMTP conversation",
+							Default:     formatDuration(defaults.Email.SMTP.Timeout),
+							Current:     formatDuration(current.Email.SMTP.Timeout),
+						},
+					},
+				},
+			},
+		},
+		"observability": {
+			Name:        "Observability",
+			Description: "Tracing and telemetry settings",
+			Fields: map[string]any{
+				"tracing": ConfigFieldMeta{
+					Type:        FieldTypeObject,
+					Description: "OpenTelemetry tracing",
+					Fields: map[string]any{
+						"enabled": ConfigFieldMeta{
+							Type:        FieldTypeBool,
+							Description: "Enable OTLP trace export",
+							Default:     defaults.Observability.Tracing.Enabled,
+							Current:     current.Observability.Tracing.Enabled,
+						},
+						"endpoint": ConfigFieldMeta{
+							Type:        FieldTypeString,
+							Description: "OTLP/HTTP collector endpoint",
+							Default:     defaults.Observability.Tracing.Endpoint,
+							Current:     current.Observability.Tracing.Endpoint,
+						},
+						"insecure": ConfigFieldMeta{
+							Type:        FieldTypeBool,
+							Description: "Send spans over plain HTTP",
+							Default:     defaults.Observability.Tracing.Insecure,
+							Current:     current.Observability.Tracing.Insecure,
+						},
+						"sample_ratio": ConfigFieldMeta{
+							Type:        FieldTypeFloat,
+							Description: "Fraction of new traces to sample (0-1)",
+							Default:     defaults.Observability.Tracing.SampleRatio,
+							Current:     current.Observability.Tracing.SampleRatio,
+						},
+						"service_name": ConfigFieldMeta{
+							Type:        FieldTypeString,
+							Description: "Service name reported on spans",
+							Default:     defaults.Observability.Tracing.ServiceName,
+							Current:     current.Observability.Tracing.ServiceName,
+						},
+					},
+				},
 			},
 		},
 		"logging": {
@@ -416,6 +867,12 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 					Default:     defaults.Logging.Output,
 					Current:     current.Logging.Output,
 				},
+				"rule_denials": ConfigFieldMeta{
+					Type:        FieldTypeBool,
+					Description: "Record rule denial details in request logs and 403 responses (always on in dev mode)",
+					Default:     defaults.Logging.RuleDenials,
+					Current:     current.Logging.RuleDenials,
+				},
 			},
 		},
 		"dev": {
@@ -458,6 +915,25 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 					Default:     defaults.Dev.GenerateOutput,
 					Current:     current.Dev.GenerateOutput,
 				},
+				"generate_dates": ConfigFieldMeta{
+					Type:        FieldTypeString,
+					Description: "TypeScript type for timestamp fields",
+					Default:     defaults.Dev.GenerateDates,
+					Current:     current.Dev.GenerateDates,
+					Options:     []string{GenerateDatesString, GenerateDatesDate},
+				},
+				"sandbox": ConfigFieldMeta{
+					Type:        FieldTypeBool,
+					Description: "Route all requests to the sandbox database",
+					Default:     defaults.Dev.Sandbox,
+					Current:     current.Dev.Sandbox,
+				},
+				"sandbox_seed": ConfigFieldMeta{
+					Type:        FieldTypeBool,
+					Description: "Copy the primary database's data into a new sandbox",
+					Default:     defaults.Dev.SandboxSeed,
+					Current:     current.Dev.SandboxSeed,
+				},
 			},
 		},
 		"docs": {
@@ -470,12 +946,19 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 					Default:     defaults.Docs.Enabled,
 					Current:     current.Docs.Enabled,
 				},
+				"uis": ConfigFieldMeta{
+					Type:        FieldTypeStringArray,
+					Description: "Documentation UIs served at /api/docs/{ui}; empty serves only ui",
+					Default:     defaults.Docs.UIs,
+					Current:     current.Docs.UIs,
+					Options:     []string{"scalar", "swagger", "redoc", "stoplight"},
+				},
 				"ui": ConfigFieldMeta{
 					Type:        FieldTypeString,
-					Description: "Documentation UI",
+					Description: "Default documentation UI",
 					Default:     defaults.Docs.UI,
 					Current:     current.Docs.UI,
-					Options:     []string{"scalar", "swagger", "redoc"},
+					Options:     []string{"scalar", "swagger", "redoc", "stoplight"},
 				},
 				"title": ConfigFieldMeta{
 					Type:        FieldTypeString,
@@ -491,12 +974,24 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 				},
 				"version": ConfigFieldMeta{
 					Type:        FieldTypeString,
-					Description: "API version",
+					Description: "API version (default: the Alyx version)",
 					Default:     defaults.Docs.Version,
 					Current:     current.Docs.Version,
 				},
 			},
 		},
+		"health": {
+			Name:        "Health",
+			Description: "Health check settings",
+			Fields: map[string]any{
+				"require_functions": ConfigFieldMeta{
+					Type:        FieldTypeBool,
+					Description: "Report not ready on /health/ready while functions are enabled and their runtimes are unavailable",
+					Default:     defaults.Health.RequireFunctions,
+					Current:     current.Health.RequireFunctions,
+				},
+			},
+		},
 		"admin_ui": {
 			Name:        "Admin UI",
 			Description: "Admin UI settings",
@@ -529,12 +1024,53 @@ func GetConfigSchema(current *Config, configPath string) map[string]any {
 		},
 	}
 
+	for key, section := range sections {
+		annotateSources(section.Fields, key, current.Sources)
+	}
+
 	return map[string]any{
 		"sections": sections,
 		"path":     configPath,
+		"env":      current.Env,
 	}
 }
 
+// annotateSources fills in ConfigFieldMeta.Source for each field from the loader's
+// source map. Object fields whose children come from different places report "mixed".
+func annotateSources(fields map[string]any, prefix string, sources map[string]string) {
+	for name, f := range fields {
+		meta, ok := f.(ConfigFieldMeta)
+		if !ok {
+			continue
+		}
+
+		key := prefix + "." + name
+		meta.Source = sourceFor(key, sources)
+		if meta.Type == FieldTypeObject && meta.Fields != nil {
+			annotateSources(meta.Fields, key, sources)
+		}
+		fields[name] = meta
+	}
+}
+
+func sourceFor(key string, sources map[string]string) string {
+	if src, ok := sources[key]; ok {
+		return src
+	}
+
+	found := ""
+	for k, src := range sources {
+		if !strings.HasPrefix(k, key+".") {
+			continue
+		}
+		if found != "" && found != src {
+			return "mixed"
+		}
+		found = src
+	}
+	return found
+}
+
 func formatDuration(d time.Duration) string {
 	if d == 0 {
 		return "0s"
@@ -573,6 +1109,14 @@ func isSecretSet(secret string) any {
 	return "***SET***"
 }
 
+func maskSecrets(secrets []string) []string {
+	masked := make([]string, len(secrets))
+	for i, secret := range secrets {
+		masked[i], _ = isSecretSet(secret).(string)
+	}
+	return masked
+}
+
 func buildRateLimitFields(defaultRule, currentRule RateLimitRule) map[string]any {
 	return map[string]any{
 		"max": ConfigFieldMeta{
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// oauthRedirectURIAllowlistKey is the setting under auth.oauth that lists
+// the URIs an OAuth login may finish at. It shares the map with the
+// providers, so it is read on its own and left out of OAuth.
+const oauthRedirectURIAllowlistKey = "redirect_uri_allowlist"
+
+// oauthProvidersHook drops the redirect URI allowlist from the auth.oauth
+// map before it is decoded into providers.
+func oauthProvidersHook(_, to reflect.Type, data any) (any, error) {
+	if to != reflect.TypeFor[map[string]OAuthProviderConfig]() {
+		return data, nil
+	}
+	m, ok := data.(map[string]any)
+	if !ok {
+		return data, nil
+	}
+	if _, ok := m[oauthRedirectURIAllowlistKey]; !ok {
+		return data, nil
+	}
+	m = maps.Clone(m)
+	delete(m, oauthRedirectURIAllowlistKey)
+	return m, nil
+}
+
+// RedirectURIAllowed reports whether an OAuth login may redirect to uri. The
+// scheme, host, port and path must equal those of an allowlist entry; uri
+// may add a query string but not a fragment.
+func (c *AuthConfig) RedirectURIAllowed(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Fragment != "" || u.User != nil || u.Opaque != "" {
+		return false
+	}
+	for _, entry := range c.OAuthRedirectURIAllowlist {
+		allowed, err := url.Parse(entry)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(u.Scheme, allowed.Scheme) &&
+			strings.EqualFold(u.Host, allowed.Host) &&
+			u.EscapedPath() == allowed.EscapedPath() {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRedirectURI reports whether uri can be an allowlist entry: an
+// absolute URL without a query or fragment. Custom schemes, as used by
+// mobile apps, are allowed; http and https URLs need a host.
+func validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme == "" || u.Opaque != "" {
+		return errors.New("must be an absolute URL, e.g. https://app.example.com/auth/callback")
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Hostname() == "" {
+		return errors.New("must include a host")
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("must not include credentials, a query or a fragment")
+	}
+	return nil
+}
+
+func validateOAuthRedirects(cfg *AuthConfig) ValidationErrors {
+	var errs ValidationErrors
+	for i, uri := range cfg.OAuthRedirectURIAllowlist {
+		if err := validateRedirectURI(uri); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("auth.oauth.%s[%d]", oauthRedirectURIAllowlistKey, i),
+				Message: fmt.Sprintf("%q %v", uri, err),
+			})
+		}
+	}
+	return errs
+}
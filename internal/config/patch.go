@@ -0,0 +1,283 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PatchOp sets the config value at a dotted path such as
+// auth.jwt.access_ttl.
+type PatchOp struct {
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// PatchOpError describes an operation that could not be applied.
+type PatchOpError struct {
+	Index   int    `json:"index"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// PatchErrors lists the operations of a patch that could not be applied.
+type PatchErrors []PatchOpError
+
+func (e PatchErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, op := range e {
+		msgs[i] = fmt.Sprintf("%s: %s", op.Path, op.Message)
+	}
+	return "invalid config patch: " + strings.Join(msgs, "; ")
+}
+
+// PatchedField is a field changed by PatchFile.
+type PatchedField struct {
+	Path string
+	// Sensitive fields hold secrets, whose values must not be logged or
+	// returned.
+	Sensitive bool
+}
+
+// PatchFile applies ops to the config file at path. Each value is checked
+// against the field's type in GetConfigSchema: durations must parse, options
+// must be one of the field's options, and objects can only be set field by
+// field. If any operation is invalid, the file is left untouched and the
+// returned PatchErrors lists every failure. Otherwise the values are set in
+// the parsed document, keeping its comments and key order, and the file is
+// replaced atomically.
+func PatchFile(path string, ops []PatchOp) ([]PatchedField, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("parsing config: top level must be a mapping")
+	}
+
+	sections := GetConfigSchema(Default(), path)["sections"].(map[string]ConfigSectionMeta)
+
+	var errs PatchErrors
+	fields := make([]PatchedField, 0, len(ops))
+	for i, op := range ops {
+		fail := func(msg string) {
+			errs = append(errs, PatchOpError{Index: i, Path: op.Path, Message: msg})
+		}
+
+		meta, err := lookupField(sections, op.Path)
+		if err != nil {
+			fail(err.Error())
+			continue
+		}
+		value, err := patchValue(meta, op.Value)
+		if err != nil {
+			fail(err.Error())
+			continue
+		}
+		if err := setPath(root, strings.Split(op.Path, "."), value); err != nil {
+			fail(err.Error())
+			continue
+		}
+		fields = append(fields, PatchedField{
+			Path:      op.Path,
+			Sensitive: meta.Sensitive || meta.Type == FieldTypeSecret,
+		})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encoding config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encoding config: %w", err)
+	}
+
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("writing config: %w", err)
+	}
+	return fields, nil
+}
+
+// lookupField returns the metadata of the field at a dotted path. Entries of
+// maps, such as auth.oauth.github.client_id or functions.env.api_url, are
+// resolved against the map's template, or are strings if it has none.
+func lookupField(sections map[string]ConfigSectionMeta, path string) (ConfigFieldMeta, error) {
+	segments := strings.Split(path, ".")
+	if len(segments) < 2 || slices.Contains(segments, "") {
+		return ConfigFieldMeta{}, errors.New("unknown config field")
+	}
+	section, ok := sections[segments[0]]
+	if !ok {
+		return ConfigFieldMeta{}, errors.New("unknown config field")
+	}
+
+	fields := section.Fields
+	for i := 1; i < len(segments); i++ {
+		var meta ConfigFieldMeta
+		switch f := fields[segments[i]].(type) {
+		case ConfigFieldMeta:
+			meta = f
+		case map[string]any:
+			meta = ConfigFieldMeta{Type: FieldTypeObject, Fields: f}
+		default:
+			return ConfigFieldMeta{}, errors.New("unknown config field")
+		}
+
+		last := i == len(segments)-1
+		switch {
+		case last:
+			return meta, nil
+		case meta.Type == FieldTypeObject:
+			fields = meta.Fields
+		case meta.Type == FieldTypeStringMap && meta.Fields == nil:
+			// A map of strings: the next segment is the key.
+			if i+1 != len(segments)-1 {
+				return ConfigFieldMeta{}, errors.New("unknown config field")
+			}
+			return ConfigFieldMeta{Type: FieldTypeString}, nil
+		case meta.Type == FieldTypeStringMap:
+			// A map of objects: skip the entry name.
+			i++
+			if i == len(segments)-1 {
+				return ConfigFieldMeta{Type: FieldTypeObject}, nil
+			}
+			fields = meta.Fields
+		default:
+			return ConfigFieldMeta{}, fmt.Errorf("%s is a %s, not an object", strings.Join(segments[:i+1], "."), meta.Type)
+		}
+	}
+	return ConfigFieldMeta{}, errors.New("unknown config field")
+}
+
+// patchValue checks v against the field's type and returns the YAML node to
+// store.
+func patchValue(meta ConfigFieldMeta, v any) (*yaml.Node, error) {
+	switch meta.Type {
+	case FieldTypeString, FieldTypeSecret:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %s", jsonKind(v))
+		}
+		if len(meta.Options) > 0 && !slices.Contains(meta.Options, s) {
+			return nil, fmt.Errorf("must be one of %s", strings.Join(meta.Options, ", "))
+		}
+		return stringNode(s), nil
+	case FieldTypeDuration:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a duration string such as \"30m\", got %s", jsonKind(v))
+		}
+		if _, err := time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("invalid duration %q", s)
+		}
+		return stringNode(s), nil
+	case FieldTypeInt, FieldTypeInt64:
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return nil, fmt.Errorf("expected an integer, got %s", jsonKind(v))
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(int64(n), 10)}, nil
+	case FieldTypeFloat:
+		n, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected a number, got %s", jsonKind(v))
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(n, 'g', -1, 64)}, nil
+	case FieldTypeBool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected a boolean, got %s", jsonKind(v))
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(b)}, nil
+	case FieldTypeStringArray:
+		items, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an array of strings, got %s", jsonKind(v))
+		}
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected an array of strings, got an array containing %s", jsonKind(item))
+			}
+			seq.Content = append(seq.Content, stringNode(s))
+		}
+		return seq, nil
+	default:
+		return nil, errors.New("objects cannot be set as a whole; set their fields instead")
+	}
+}
+
+func stringNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// setPath sets the value at the keys under node, adding mappings for keys
+// that are missing. The comments of a replaced value are kept.
+func setPath(node *yaml.Node, keys []string, value *yaml.Node) error {
+	for i, key := range keys {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping in the config file", strings.Join(keys[:i], "."))
+		}
+
+		keyNode, valueNode := mappingEntry(node, key)
+		if keyNode == nil {
+			keyNode = stringNode(key)
+			valueNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, keyNode, valueNode)
+		}
+
+		if i == len(keys)-1 {
+			value.HeadComment = valueNode.HeadComment
+			value.LineComment = valueNode.LineComment
+			value.FootComment = valueNode.FootComment
+			*valueNode = *value
+			return nil
+		}
+
+		if valueNode.Kind == yaml.ScalarNode && valueNode.Tag == "!!null" {
+			*valueNode = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", LineComment: valueNode.LineComment}
+		}
+		node = valueNode
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GenerateJWTSecret returns a new random 256-bit secret, hex encoded.
+func GenerateJWTSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// JWTRotation describes the result of RotateJWTSecret.
+type JWTRotation struct {
+	// Secret is the new signing secret.
+	Secret string
+	// Retained is the number of previous secrets still accepted for
+	// verification.
+	Retained int
+	// Dropped is the number of previous secrets removed from the file.
+	Dropped int
+}
+
+// RotateJWTSecret rewrites auth.jwt in the config file at path so that secret
+// signs new tokens, and up to keep previously configured secrets remain valid
+// for verification. A single auth.jwt.secret is converted to auth.jwt.secrets.
+// Values are kept as written, so ${VAR} references stay references. Only the
+// secret lines are rewritten; the rest of the file is left byte for byte.
+func RotateJWTSecret(path, secret string, keep int) (*JWTRotation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	var root, authKey, authNode, jwtKey, jwtNode *yaml.Node
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, errors.New("parsing config: top level must be a mapping")
+		}
+		authKey, authNode = mappingEntry(root, "auth")
+	}
+	if authNode != nil && authNode.Tag == "!!null" {
+		authNode = &yaml.Node{Kind: yaml.MappingNode}
+	}
+	if authNode != nil {
+		jwtKey, jwtNode = mappingEntry(authNode, "jwt")
+	}
+	if jwtNode != nil && jwtNode.Tag == "!!null" {
+		jwtNode = &yaml.Node{Kind: yaml.MappingNode}
+	}
+	for _, n := range []*yaml.Node{authNode, jwtNode} {
+		if n != nil && (n.Kind != yaml.MappingNode || n.Style&yaml.FlowStyle != 0) {
+			return nil, errors.New("parsing config: auth.jwt must be a block mapping to rotate its secret")
+		}
+	}
+
+	// Collect the current secrets and the line ranges (0-based, inclusive)
+	// holding them.
+	var previous []string
+	type span struct{ first, last int }
+	var spans []span
+	hadSecrets := false
+	if jwtNode != nil {
+		if key, val := mappingEntry(jwtNode, "secrets"); key != nil {
+			hadSecrets = true
+			last := key.Line
+			for _, item := range val.Content {
+				previous = append(previous, item.Value)
+				if val.Style&yaml.FlowStyle == 0 {
+					last = item.Line
+				}
+			}
+			spans = append(spans, span{key.Line - 1, last - 1})
+		}
+		if key, val := mappingEntry(jwtNode, "secret"); key != nil {
+			if val.Value != "" {
+				previous = append(previous, val.Value)
+			}
+			spans = append(spans, span{key.Line - 1, val.Line - 1})
+		}
+	}
+
+	values := []string{secret}
+	result := &JWTRotation{Secret: secret}
+	for _, prev := range previous {
+		if prev == secret {
+			continue
+		}
+		if result.Retained >= keep {
+			result.Dropped++
+			continue
+		}
+		values = append(values, prev)
+		result.Retained++
+	}
+
+	// Build the replacement block at the indentation of its siblings.
+	indent := func(parentKey, parent *yaml.Node) string {
+		if parent != nil && len(parent.Content) > 0 {
+			return strings.Repeat(" ", parent.Content[0].Column-1)
+		}
+		if parentKey != nil {
+			return strings.Repeat(" ", parentKey.Column+1)
+		}
+		return ""
+	}
+	ind := indent(jwtKey, jwtNode)
+	var block []string
+	if !hadSecrets {
+		block = append(block,
+			ind+"# Tokens are signed with the first secret; the rest are only accepted",
+			ind+"# for verification, until tokens signed with them expire.")
+	}
+	block = append(block, ind+"secrets:")
+	for _, v := range values {
+		block = append(block, ind+"  - "+yamlScalar(v))
+	}
+
+	switch {
+	case len(spans) > 0:
+		// Replace the earliest range with the new block and drop the other.
+		sort.Slice(spans, func(i, j int) bool { return spans[i].first > spans[j].first })
+		for i, sp := range spans {
+			var repl []string
+			if i == len(spans)-1 {
+				repl = block
+			}
+			lines = append(lines[:sp.first], append(repl, lines[sp.last+1:]...)...)
+		}
+	case jwtKey != nil:
+		lines = insertLines(lines, jwtKey.Line, block)
+	case authKey != nil:
+		authInd := indent(authKey, authNode)
+		nested := []string{authInd + "jwt:"}
+		for _, l := range block {
+			nested = append(nested, authInd+"  "+l)
+		}
+		lines = insertLines(lines, authKey.Line, nested)
+	default:
+		if n := len(lines); n > 0 && lines[n-1] == "" {
+			lines = lines[:n-1]
+		}
+		lines = append(lines, "auth:", "  jwt:")
+		for _, l := range block {
+			lines = append(lines, "    "+l)
+		}
+		lines = append(lines, "")
+	}
+
+	if err := writeFileAtomic(path, []byte(strings.Join(lines, "\n"))); err != nil {
+		return nil, fmt.Errorf("writing config: %w", err)
+	}
+	return result, nil
+}
+
+// mappingEntry returns the key and value nodes for key in a mapping.
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func insertLines(lines []string, at int, insert []string) []string {
+	return append(lines[:at], append(insert, lines[at:]...)...)
+}
+
+// yamlScalar formats s as a single-line YAML scalar, quoting it if needed.
+func yamlScalar(s string) string {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return strconv.Quote(s)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// writeFileAtomic replaces path with data, keeping its permissions.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".alyx-config-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
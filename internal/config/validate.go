@@ -1,7 +1,10 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net/mail"
+	"slices"
 	"strings"
 	"time"
 )
@@ -40,9 +43,15 @@ func Validate(cfg *Config) error {
 	errs = append(errs, validateFunctions(&cfg.Functions)...)
 	errs = append(errs, validateLogging(&cfg.Logging)...)
 	errs = append(errs, validateDocs(&cfg.Docs)...)
+	errs = append(errs, validateDev(&cfg.Dev)...)
 	errs = append(errs, validateRealtime(&cfg.Realtime)...)
 	errs = append(errs, validateAdminUI(&cfg.AdminUI)...)
 	errs = append(errs, validateStorage(&cfg.Storage)...)
+	errs = append(errs, validateRetention(&cfg.Retention)...)
+	errs = append(errs, validateChanges(&cfg.Changes)...)
+	errs = append(errs, validateEmail(&cfg.Email)...)
+	errs = append(errs, validateSecurity(&cfg.Security)...)
+	errs = append(errs, validateTracing(&cfg.Observability.Tracing)...)
 
 	if len(errs) > 0 {
 		return errs
@@ -50,6 +59,21 @@ func Validate(cfg *Config) error {
 	return nil
 }
 
+// Warnings returns settings that are valid but likely mistakes. They are
+// logged at startup rather than failing it.
+func Warnings(cfg *Config) ValidationErrors {
+	var warnings ValidationErrors
+
+	if cfg.Server.CORS.Enabled && !cfg.Dev.Enabled && slices.Contains(cfg.Server.CORS.AllowedOrigins, "*") {
+		warnings = append(warnings, ValidationError{
+			Field:   "server.cors.allowed_origins",
+			Message: "every origin is allowed outside dev mode; list your app's origins or use allowed_origin_patterns",
+		})
+	}
+
+	return warnings
+}
+
 func validateServer(cfg *ServerConfig) ValidationErrors {
 	var errs ValidationErrors
 
@@ -95,18 +119,31 @@ func validateServer(cfg *ServerConfig) ValidationErrors {
 		})
 	}
 
-	if cfg.CORS.Enabled && cfg.CORS.AllowCredentials {
-		for _, origin := range cfg.CORS.AllowedOrigins {
-			if origin == "*" {
-				errs = append(errs, ValidationError{
-					Field:   "server.cors",
-					Message: "security: allow_credentials=true with allowed_origins=[\"*\"] is insecure",
-				})
-				break
-			}
-		}
+	if cfg.MaxUploadSize < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "server.max_upload_size",
+			Message: "must be non-negative",
+		})
 	}
 
+	if cfg.Compression.MinSize < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "server.compression.min_size",
+			Message: "must be non-negative",
+		})
+	}
+
+	switch cfg.ErrorFormat {
+	case "", ErrorFormatAlyx, ErrorFormatProblem:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "server.error_format",
+			Message: fmt.Sprintf("must be %q or %q", ErrorFormatAlyx, ErrorFormatProblem),
+		})
+	}
+
+	errs = append(errs, validateCORS(&cfg.CORS)...)
+
 	if cfg.TLS != nil && cfg.TLS.Enabled {
 		if !cfg.TLS.AutoTLS {
 			if cfg.TLS.CertFile == "" {
@@ -144,6 +181,49 @@ func validateDatabase(cfg *DatabaseConfig) ValidationErrors {
 
 	// Database connection settings are hard-coded (see DatabaseConfig methods)
 
+	if cfg.StmtCacheSize < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "database.stmt_cache_size",
+			Message: "must be non-negative",
+		})
+	}
+
+	if cfg.Checkpoint.Enabled {
+		if cfg.Checkpoint.Interval <= 0 {
+			errs = append(errs, ValidationError{
+				Field:   "database.checkpoint.interval",
+				Message: "must be positive when checkpoints are enabled",
+			})
+		}
+		switch strings.ToUpper(cfg.Checkpoint.Mode) {
+		case "PASSIVE", "FULL", "RESTART", "TRUNCATE":
+		default:
+			errs = append(errs, ValidationError{
+				Field:   "database.checkpoint.mode",
+				Message: "must be PASSIVE, FULL, RESTART or TRUNCATE",
+			})
+		}
+		if cfg.Checkpoint.WALSizeThreshold < 0 {
+			errs = append(errs, ValidationError{
+				Field:   "database.checkpoint.wal_size_threshold",
+				Message: "must be non-negative",
+			})
+		}
+	}
+
+	if cfg.Retry.MaxAttempts < 1 {
+		errs = append(errs, ValidationError{
+			Field:   "database.retry.max_attempts",
+			Message: "must be at least 1",
+		})
+	}
+	if cfg.Retry.MaxWait < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "database.retry.max_wait",
+			Message: "must be non-negative",
+		})
+	}
+
 	if cfg.Turso != nil && cfg.Turso.Enabled {
 		if cfg.Turso.URL == "" {
 			errs = append(errs, ValidationError{
@@ -179,6 +259,43 @@ func validateAuth(cfg *AuthConfig) ValidationErrors {
 		})
 	}
 
+	if cfg.JWT.Secret != "" && len(cfg.JWT.Secrets) > 0 {
+		errs = append(errs, ValidationError{
+			Field:   "auth.jwt.secrets",
+			Message: "cannot be combined with auth.jwt.secret; list the current secret first in secrets",
+		})
+	}
+
+	for i, secret := range cfg.JWT.Secrets {
+		if len(secret) < 32 {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("auth.jwt.secrets[%d]", i),
+				Message: "must be at least 32 characters",
+			})
+		}
+	}
+
+	switch cfg.JWT.Algorithm {
+	case "", JWTAlgorithmHS256:
+	case JWTAlgorithmRS256, JWTAlgorithmEdDSA:
+		if cfg.JWT.PrivateKeyFile == "" {
+			errs = append(errs, ValidationError{
+				Field:   "auth.jwt.private_key_file",
+				Message: "required when algorithm is " + cfg.JWT.Algorithm,
+			})
+		} else if _, err := cfg.JWT.LoadPrivateKey(); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "auth.jwt.private_key_file",
+				Message: err.Error(),
+			})
+		}
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "auth.jwt.algorithm",
+			Message: "must be one of: HS256, RS256, EdDSA",
+		})
+	}
+
 	if cfg.Password.MinLength < 8 {
 		errs = append(errs, ValidationError{
 			Field:   "auth.password.min_length",
@@ -186,6 +303,8 @@ func validateAuth(cfg *AuthConfig) ValidationErrors {
 		})
 	}
 
+	errs = append(errs, validatePasswordHash(&cfg.Password.Hash)...)
+
 	if cfg.RateLimit.Login.Max < 1 {
 		errs = append(errs, ValidationError{
 			Field:   "auth.rate_limit.login.max",
@@ -193,6 +312,13 @@ func validateAuth(cfg *AuthConfig) ValidationErrors {
 		})
 	}
 
+	if cfg.DeletionGracePeriod < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "auth.deletion_grace_period",
+			Message: "must be non-negative",
+		})
+	}
+
 	for name, provider := range cfg.OAuth {
 		if provider.ClientID == "" {
 			errs = append(errs, ValidationError{
@@ -207,6 +333,49 @@ func validateAuth(cfg *AuthConfig) ValidationErrors {
 			})
 		}
 	}
+	errs = append(errs, validateOAuthRedirects(cfg)...)
+
+	return errs
+}
+
+func validatePasswordHash(cfg *PasswordHashConfig) ValidationErrors {
+	var errs ValidationErrors
+
+	switch cfg.Algorithm {
+	case "", PasswordHashBcrypt, PasswordHashArgon2id:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "auth.password.hash.algorithm",
+			Message: "must be one of: bcrypt, argon2id",
+		})
+	}
+
+	if cfg.Bcrypt.Cost != 0 && (cfg.Bcrypt.Cost < 4 || cfg.Bcrypt.Cost > 31) {
+		errs = append(errs, ValidationError{
+			Field:   "auth.password.hash.bcrypt.cost",
+			Message: "must be between 4 and 31",
+		})
+	}
+
+	argon := cfg.Argon2id
+	if argon.Parallelism < 0 || argon.Parallelism > 255 {
+		errs = append(errs, ValidationError{
+			Field:   "auth.password.hash.argon2id.parallelism",
+			Message: "must be between 1 and 255",
+		})
+	}
+	if argon.Iterations < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "auth.password.hash.argon2id.iterations",
+			Message: "must be at least 1",
+		})
+	}
+	if argon.Memory < 0 || (argon.Memory > 0 && argon.Memory < 8*max(argon.Parallelism, 1)) {
+		errs = append(errs, ValidationError{
+			Field:   "auth.password.hash.argon2id.memory",
+			Message: "must be at least 8 KiB per thread",
+		})
+	}
 
 	return errs
 }
@@ -270,12 +439,44 @@ func validateDocs(cfg *DocsConfig) ValidationErrors {
 	validUIs := map[string]bool{
 		"scalar": true, "swagger": true, "redoc": true, "stoplight": true,
 	}
-	if !validUIs[cfg.UI] {
+	// With uis set, ui only picks the default and may be left empty.
+	if !validUIs[cfg.UI] && (len(cfg.UIs) == 0 || cfg.UI != "") {
 		errs = append(errs, ValidationError{
 			Field:   "docs.ui",
 			Message: "must be one of: scalar, swagger, redoc, stoplight",
 		})
 	}
+	seen := make(map[string]bool, len(cfg.UIs))
+	for i, ui := range cfg.UIs {
+		switch {
+		case !validUIs[ui]:
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("docs.uis[%d]", i),
+				Message: "must be one of: scalar, swagger, redoc, stoplight",
+			})
+		case seen[ui]:
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("docs.uis[%d]", i),
+				Message: fmt.Sprintf("%q is listed more than once", ui),
+			})
+		}
+		seen[ui] = true
+	}
+
+	return errs
+}
+
+func validateDev(cfg *DevConfig) ValidationErrors {
+	var errs ValidationErrors
+
+	switch cfg.GenerateDates {
+	case "", GenerateDatesString, GenerateDatesDate:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "dev.generate_dates",
+			Message: "must be one of: string, Date",
+		})
+	}
 
 	return errs
 }
@@ -287,6 +488,15 @@ func validateRealtime(cfg *RealtimeConfig) ValidationErrors {
 		return errs
 	}
 
+	switch cfg.Mode {
+	case "", "auto", "poll", "hook":
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "realtime.mode",
+			Message: "must be auto, poll or hook",
+		})
+	}
+
 	if cfg.PollInterval < 10*time.Millisecond {
 		errs = append(errs, ValidationError{
 			Field:   "realtime.poll_interval",
@@ -336,6 +546,34 @@ func validateRealtime(cfg *RealtimeConfig) ValidationErrors {
 		})
 	}
 
+	if cfg.PingInterval < time.Second {
+		errs = append(errs, ValidationError{
+			Field:   "realtime.ping_interval",
+			Message: "must be at least 1 second",
+		})
+	}
+
+	if cfg.MaxMissedPongs < 1 {
+		errs = append(errs, ValidationError{
+			Field:   "realtime.max_missed_pongs",
+			Message: "must be at least 1",
+		})
+	}
+
+	if cfg.WriteTimeout < time.Second {
+		errs = append(errs, ValidationError{
+			Field:   "realtime.write_timeout",
+			Message: "must be at least 1 second",
+		})
+	}
+
+	if cfg.SlowClientTimeout < time.Second {
+		errs = append(errs, ValidationError{
+			Field:   "realtime.slow_client_timeout",
+			Message: "must be at least 1 second",
+		})
+	}
+
 	return errs
 }
 
@@ -467,6 +705,170 @@ func validateStorage(cfg *StorageConfig) ValidationErrors {
 	return errs
 }
 
+func validateRetention(cfg *RetentionConfig) ValidationErrors {
+	var errs ValidationErrors
+
+	if !cfg.Enabled {
+		return errs
+	}
+
+	if cfg.Interval < time.Second {
+		errs = append(errs, ValidationError{
+			Field:   "retention.interval",
+			Message: "must be at least 1 second",
+		})
+	}
+
+	if cfg.BatchSize < 1 {
+		errs = append(errs, ValidationError{
+			Field:   "retention.batch_size",
+			Message: "must be at least 1",
+		})
+	}
+
+	if cfg.BatchSleep < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "retention.batch_sleep",
+			Message: "must be non-negative",
+		})
+	}
+
+	return errs
+}
+
+func validateChanges(cfg *ChangesConfig) ValidationErrors {
+	var errs ValidationErrors
+
+	if !cfg.Enabled {
+		return errs
+	}
+
+	if cfg.Retention < time.Minute {
+		errs = append(errs, ValidationError{
+			Field:   "changes.retention",
+			Message: "must be at least 1 minute",
+		})
+	}
+
+	switch cfg.Include {
+	case ChangesIncludeKeys, ChangesIncludeDocument:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "changes.include",
+			Message: fmt.Sprintf("must be %q or %q", ChangesIncludeKeys, ChangesIncludeDocument),
+		})
+	}
+
+	return errs
+}
+
+func validateSecurity(cfg *SecurityConfig) ValidationErrors {
+	var errs ValidationErrors
+
+	if cfg.FieldEncryptionKey != "" && !isFieldEncryptionKey(cfg.FieldEncryptionKey) {
+		errs = append(errs, ValidationError{
+			Field:   "security.field_encryption_key",
+			Message: "must be a base64-encoded 32-byte key (generate one with: openssl rand -base64 32)",
+		})
+	}
+
+	if len(cfg.PreviousFieldEncryptionKeys) > 0 && cfg.FieldEncryptionKey == "" {
+		errs = append(errs, ValidationError{
+			Field:   "security.previous_field_encryption_keys",
+			Message: "requires security.field_encryption_key",
+		})
+	}
+
+	for i, key := range cfg.PreviousFieldEncryptionKeys {
+		if !isFieldEncryptionKey(key) {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("security.previous_field_encryption_keys[%d]", i),
+				Message: "must be a base64-encoded 32-byte key",
+			})
+		}
+	}
+
+	return errs
+}
+
+func isFieldEncryptionKey(key string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(decoded) == 32
+}
+
+func validateEmail(cfg *EmailConfig) ValidationErrors {
+	var errs ValidationErrors
+
+	links := []struct{ field, value string }{
+		{"email.verify_url", cfg.VerifyURL},
+		{"email.reset_url", cfg.ResetURL},
+		{"email.restore_url", cfg.RestoreURL},
+	}
+	for _, link := range links {
+		if link.value != "" && !strings.Contains(link.value, "{token}") {
+			errs = append(errs, ValidationError{
+				Field:   link.field,
+				Message: "must contain {token}",
+			})
+		}
+	}
+
+	if !cfg.Enabled {
+		return errs
+	}
+
+	if cfg.From == "" {
+		errs = append(errs, ValidationError{
+			Field:   "email.from",
+			Message: "required when email is enabled",
+		})
+	} else if _, err := mail.ParseAddress(cfg.From); err != nil {
+		errs = append(errs, ValidationError{
+			Field:   "email.from",
+			Message: fmt.Sprintf("invalid address: %v", err),
+		})
+	}
+
+	if cfg.SMTP.Host == "" {
+		errs = append(errs, ValidationError{
+			Field:   "email.smtp.host",
+			Message: "required when email is enabled",
+		})
+	}
+
+	if cfg.SMTP.Port < 1 || cfg.SMTP.Port > 65535 {
+		errs = append(errs, ValidationError{
+			Field:   "email.smtp.port",
+			Message: "must be between 1 and 65535",
+		})
+	}
+
+	switch cfg.SMTP.TLS {
+	case SMTPTLSStartTLS, SMTPTLSImplicit, SMTPTLSNone:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "email.smtp.tls",
+			Message: "must be one of: starttls, tls, none",
+		})
+	}
+
+	if cfg.SMTP.Password != "" && cfg.SMTP.Username == "" {
+		errs = append(errs, ValidationError{
+			Field:   "email.smtp.username",
+			Message: "required when a password is set",
+		})
+	}
+
+	if cfg.SMTP.Timeout <= 0 {
+		errs = append(errs, ValidationError{
+			Field:   "email.smtp.timeout",
+			Message: "must be positive",
+		})
+	}
+
+	return errs
+}
+
 func ValidateJWTSecret(secret string) error {
 	if secret == "" {
 		return &ValidationError{
@@ -482,3 +884,34 @@ func ValidateJWTSecret(secret string) error {
 	}
 	return nil
 }
+
+func validateTracing(cfg *TracingConfig) ValidationErrors {
+	var errs ValidationErrors
+
+	if !cfg.Enabled {
+		return errs
+	}
+
+	if cfg.Endpoint == "" {
+		errs = append(errs, ValidationError{
+			Field:   "observability.tracing.endpoint",
+			Message: "endpoint is required when tracing is enabled",
+		})
+	}
+
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		errs = append(errs, ValidationError{
+			Field:   "observability.tracing.sample_ratio",
+			Message: "must be between 0 and 1",
+		})
+	}
+
+	if cfg.ServiceName == "" {
+		errs = append(errs, ValidationError{
+			Field:   "observability.tracing.service_name",
+			Message: "service name is required when tracing is enabled",
+		})
+	}
+
+	return errs
+}
@@ -0,0 +1,289 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// ErrBlobNotFound is returned for a blob field that has no content.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// DefaultBlobMimeType is the content type of blob content written without one.
+const DefaultBlobMimeType = "application/octet-stream"
+
+// BlobInfo describes the content of a blob field. Documents carry it in place
+// of the content, which is read and written through the blob endpoints.
+type BlobInfo struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime"`
+	// ETag is the hex SHA-256 of the content.
+	ETag string `json:"etag"`
+	// Bucket and FileID locate content stored in a bucket; both are empty for
+	// content in the blob column.
+	Bucket    string    `json:"-"`
+	FileID    string    `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// NewBlobInfo describes content stored in the blob column.
+func NewBlobInfo(content []byte, mimeType string) *BlobInfo {
+	if mimeType == "" {
+		mimeType = DefaultBlobMimeType
+	}
+	sum := sha256.Sum256(content)
+	return &BlobInfo{Size: int64(len(content)), MimeType: mimeType, ETag: hex.EncodeToString(sum[:])}
+}
+
+// blobColumn is the value stored in the column of a blob field described by
+// info: the bucket file ID for bucket content, otherwise the content.
+func (info *BlobInfo) blobColumn(content []byte) any {
+	if info.Bucket != "" {
+		return info.FileID
+	}
+	return content
+}
+
+// hasBlobFields reports whether the collection has blob fields.
+func (c *Collection) hasBlobFields() bool {
+	for _, field := range c.schema.Fields {
+		if field.Type == schema.FieldTypeBlob {
+			return true
+		}
+	}
+	return false
+}
+
+// selectColumns returns the result columns for reading documents. Blob
+// fields are replaced by a JSON encoding of their BlobInfo, so listing
+// documents never reads blob content.
+func (c *Collection) selectColumns() []string {
+	pk := c.schema.PrimaryKeyField()
+	if pk == nil || !c.hasBlobFields() {
+		return []string{"*"}
+	}
+
+	fields := c.schema.OrderedFields()
+	columns := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field.Type != schema.FieldTypeBlob {
+			columns = append(columns, field.Name)
+			continue
+		}
+		// Content written before blob metadata existed has no row in
+		// _alyx_blobs; its size is still known.
+		columns = append(columns, fmt.Sprintf(`COALESCE(
+			(SELECT json_object('size', size, 'mime', mime_type, 'etag', etag) FROM _alyx_blobs
+				WHERE collection = '%[1]s' AND document_id = %[1]s.%[2]s AND field = '%[3]s'),
+			CASE WHEN %[3]s IS NOT NULL THEN json_object('size', length(CAST(%[3]s AS BLOB)), 'mime', '%[4]s', 'etag', '') END
+		) AS %[3]s`, c.name, pk.Name, field.Name, DefaultBlobMimeType))
+	}
+	return columns
+}
+
+// decodeBlobInfo parses the BlobInfo selectColumns reads for a blob field.
+func decodeBlobInfo(value any) any {
+	s, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	var info map[string]any
+	if err := json.Unmarshal([]byte(s), &info); err != nil {
+		return nil
+	}
+	return info
+}
+
+// blobContent converts a blob field's JSON input, base64 encoded content,
+// to the content. Values that are not base64 are stored as given.
+func blobContent(value any) any {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	if content, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return content
+	}
+	return []byte(s)
+}
+
+// writeBlobInfo records the BlobInfo of each blob field written with data,
+// using the given one where infos has it and deriving it from the content
+// otherwise. Fields written as null lose their BlobInfo.
+func (c *Collection) writeBlobInfo(ctx context.Context, id string, data Row, infos map[string]*BlobInfo) error {
+	exec := c.executor(ctx)
+	for name, value := range data {
+		field, ok := c.schema.Fields[name]
+		if !ok || field.Type != schema.FieldTypeBlob {
+			continue
+		}
+
+		if value == nil {
+			if _, err := exec.ExecContext(ctx,
+				`DELETE FROM _alyx_blobs WHERE collection = ? AND document_id = ? AND field = ?`,
+				c.name, id, name); err != nil {
+				return fmt.Errorf("deleting blob metadata: %w", err)
+			}
+			continue
+		}
+
+		info := infos[name]
+		if info == nil {
+			content, _ := value.([]byte)
+			info = NewBlobInfo(content, "")
+		}
+		if _, err := exec.ExecContext(ctx, `
+			INSERT INTO _alyx_blobs (collection, document_id, field, size, mime_type, etag, bucket, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (collection, document_id, field) DO UPDATE SET
+				size = excluded.size, mime_type = excluded.mime_type, etag = excluded.etag,
+				bucket = excluded.bucket, updated_at = excluded.updated_at`,
+			c.name, id, name, info.Size, info.MimeType, info.ETag, nullIfEmpty(info.Bucket), Now()); err != nil {
+			return fmt.Errorf("writing blob metadata: %w", err)
+		}
+	}
+	return nil
+}
+
+// deleteBlobInfo removes the BlobInfo of every blob field of a document.
+func (c *Collection) deleteBlobInfo(ctx context.Context, id string) error {
+	if !c.hasBlobFields() {
+		return nil
+	}
+	if _, err := c.executor(ctx).ExecContext(ctx,
+		`DELETE FROM _alyx_blobs WHERE collection = ? AND document_id = ?`, c.name, id); err != nil {
+		return fmt.Errorf("deleting blob metadata: %w", err)
+	}
+	return nil
+}
+
+// SetBlob replaces the content of a blob field, updating the document as
+// Update does. content is the content for the blob column; for content
+// stored in a bucket it is ignored and info names the file. A nil info
+// clears the field.
+func (c *Collection) SetBlob(ctx context.Context, id, field string, content []byte, info *BlobInfo) (Row, error) {
+	if f, ok := c.schema.Fields[field]; !ok || f.Type != schema.FieldTypeBlob {
+		return nil, fmt.Errorf("%s is not a blob field", field)
+	}
+
+	data := Row{field: nil}
+	var infos map[string]*BlobInfo
+	if info != nil {
+		data[field] = info.blobColumn(content)
+		infos = map[string]*BlobInfo{field: info}
+	}
+	return c.update(ctx, id, data, infos)
+}
+
+// GetBlobInfo returns the BlobInfo of a document's blob field, or
+// ErrBlobNotFound if the field has no content.
+func (c *Collection) GetBlobInfo(ctx context.Context, id, field string) (*BlobInfo, error) {
+	pk := c.schema.PrimaryKeyField()
+	if pk == nil {
+		return nil, errors.New("collection has no primary key")
+	}
+	if f, ok := c.schema.Fields[field]; !ok || f.Type != schema.FieldTypeBlob {
+		return nil, fmt.Errorf("%s is not a blob field", field)
+	}
+
+	var (
+		length                 sql.NullInt64
+		size                   sql.NullInt64
+		mimeType, etag, bucket sql.NullString
+		fileID, updatedAt      sql.NullString
+	)
+	err := c.executor(ctx).QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT length(CAST(t.%[1]s AS BLOB)), b.size, b.mime_type, b.etag, b.bucket,
+			CASE WHEN b.bucket IS NOT NULL THEN t.%[1]s END, b.updated_at
+		FROM %[2]s t
+		LEFT JOIN _alyx_blobs b ON b.collection = ? AND b.document_id = t.%[3]s AND b.field = ?
+		WHERE t.%[3]s = ?`, field, c.name, pk.Name),
+		c.name, field, id,
+	).Scan(&length, &size, &mimeType, &etag, &bucket, &fileID, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading blob metadata: %w", err)
+	}
+	if !length.Valid {
+		return nil, ErrBlobNotFound
+	}
+
+	if !size.Valid {
+		// Written before blob metadata existed.
+		return &BlobInfo{Size: length.Int64, MimeType: DefaultBlobMimeType}, nil
+	}
+	info := &BlobInfo{
+		Size:     size.Int64,
+		MimeType: mimeType.String,
+		ETag:     etag.String,
+		Bucket:   bucket.String,
+		FileID:   fileID.String,
+	}
+	info.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt.String)
+	return info, nil
+}
+
+// BlobReader reads the content of a blob field stored in the blob column,
+// size bytes long, without loading all of it.
+func (c *Collection) BlobReader(ctx context.Context, id, field string, size int64) (*io.SectionReader, error) {
+	pk := c.schema.PrimaryKeyField()
+	if pk == nil {
+		return nil, errors.New("collection has no primary key")
+	}
+	if f, ok := c.schema.Fields[field]; !ok || f.Type != schema.FieldTypeBlob {
+		return nil, fmt.Errorf("%s is not a blob field", field)
+	}
+
+	r := &blobReaderAt{
+		ctx:   ctx,
+		exec:  c.executor(ctx),
+		query: fmt.Sprintf(`SELECT substr(CAST(%s AS BLOB), ?, ?) FROM %s WHERE %s = ?`, field, c.name, pk.Name),
+		id:    id,
+		size:  size,
+	}
+	return io.NewSectionReader(r, 0, size), nil
+}
+
+// blobReaderAt reads ranges of blob content with substr.
+type blobReaderAt struct {
+	ctx   context.Context
+	exec  executor
+	query string
+	id    string
+	size  int64
+}
+
+func (r *blobReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	var chunk []byte
+	// substr positions start at 1.
+	if err := r.exec.QueryRowContext(r.ctx, r.query, off+1, len(p), r.id).Scan(&chunk); err != nil {
+		return 0, fmt.Errorf("reading blob: %w", err)
+	}
+	n := copy(p, chunk)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"sync"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// changesTable is the table the change-tracking triggers write to.
+const changesTable = "_alyx_changes"
+
+// changeNotifier fans out commit notifications for one database file.
+type changeNotifier struct {
+	mu     sync.Mutex
+	hooked bool
+	subs   map[chan struct{}]struct{}
+}
+
+// notifiers is keyed by DSN, which is all the driver's connection hook is
+// given. Entries live for the life of the process; there is one per database
+// file opened.
+var notifiers = struct {
+	mu sync.Mutex
+	m  map[string]*changeNotifier
+}{m: make(map[string]*changeNotifier)}
+
+func init() {
+	sqlite.RegisterConnectionHook(registerChangeHooks)
+}
+
+func notifierFor(dsn string) *changeNotifier {
+	notifiers.mu.Lock()
+	defer notifiers.mu.Unlock()
+
+	n, ok := notifiers.m[dsn]
+	if !ok {
+		n = &changeNotifier{subs: make(map[chan struct{}]struct{})}
+		notifiers.m[dsn] = n
+	}
+	return n
+}
+
+// registerChangeHooks installs update and commit hooks on every new SQLite
+// connection. A transaction that inserted into _alyx_changes notifies
+// subscribers when it commits; marking changes processed does not. Hooks run
+// on the connection's goroutine while SQLite holds its locks, so they only
+// record state and signal channels.
+func registerChangeHooks(conn sqlite.ExecQuerierContext, dsn string) error {
+	hooks, ok := conn.(sqlite.HookRegisterer)
+	if !ok {
+		return nil
+	}
+
+	n := notifierFor(dsn)
+	pending := false
+
+	hooks.RegisterPreUpdateHook(func(data sqlite.SQLitePreUpdateData) {
+		if data.Op == sqlite3.SQLITE_INSERT && data.TableName == changesTable {
+			pending = true
+		}
+	})
+	hooks.RegisterCommitHook(func() int32 {
+		if pending {
+			pending = false
+			n.notify()
+		}
+		return 0
+	})
+	hooks.RegisterRollbackHook(func() {
+		pending = false
+	})
+
+	n.mu.Lock()
+	n.hooked = true
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *changeNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for ch := range n.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// A notification is already pending; the reader will pick up
+			// this commit's changes along with it.
+		}
+	}
+}
+
+// SubscribeChanges returns a channel that receives a value after each commit
+// that recorded realtime changes, and a function that cancels the
+// subscription. The hook fires just before the commit completes, so a reader
+// on another connection may briefly not see the new rows; with the single
+// connection used for local SQLite the reader waits for the writer instead.
+//
+// ok is false when commit hooks are unavailable, such as for remote
+// databases, and callers should poll instead.
+func (db *DB) SubscribeChanges() (ch <-chan struct{}, cancel func(), ok bool) {
+	if db.cfg.Turso != nil && db.cfg.Turso.Enabled {
+		return nil, func() {}, false
+	}
+
+	n := notifierFor(buildDSN(db.cfg))
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.hooked {
+		return nil, func() {}, false
+	}
+
+	c := make(chan struct{}, 1)
+	n.subs[c] = struct{}{}
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			n.mu.Lock()
+			delete(n.subs, c)
+			n.mu.Unlock()
+		})
+	}, true
+}
@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 
 	"github.com/watzon/alyx/internal/schema"
 )
@@ -59,25 +62,53 @@ type executor interface {
 	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
+// dbFor returns the database ctx routes the collection to; see WithDB.
+func (c *Collection) dbFor(ctx context.Context) *DB {
+	return DBFromContext(ctx, c.db)
+}
+
 func (c *Collection) executor(ctx context.Context) executor {
+	db := c.dbFor(ctx)
+	stmts := db.Stmts()
 	if tx, ok := TransactionFromContext(ctx); ok {
-		return tx
+		if stmts == nil {
+			return tx
+		}
+		return stmts.Tx(tx)
 	}
-	return c.db
+	if stmts == nil {
+		return db
+	}
+	return stmts
 }
 
+// TotalMode controls how Find computes QueryResult.Total.
+type TotalMode string
+
+const (
+	// TotalExact counts matching rows, reusing a recent count when possible.
+	TotalExact TotalMode = "exact"
+	// TotalNone skips counting.
+	TotalNone TotalMode = "none"
+	// TotalEstimate uses table statistics when there are no filters, and
+	// falls back to an exact count otherwise.
+	TotalEstimate TotalMode = "estimate"
+)
+
 type QueryOptions struct {
 	Filters []*Filter
 	Sorts   []*Sort
 	Limit   int
 	Offset  int
 	Expand  []string
-	Search  string // Full-text search across string/text fields
+	Search  string    // Full-text search across string/text fields
+	Total   TotalMode // Defaults to TotalExact
 }
 
 type QueryResult struct {
-	Docs  []Row
-	Total int64
+	Docs      []Row
+	Total     int64
+	Estimated bool // Total is an estimate
 }
 
 func (c *Collection) Find(ctx context.Context, opts *QueryOptions) (*QueryResult, error) {
@@ -85,7 +116,62 @@ func (c *Collection) Find(ctx context.Context, opts *QueryOptions) (*QueryResult
 		opts = &QueryOptions{}
 	}
 
-	q := NewQuery(c.name)
+	q, err := c.findQuery(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	exec := c.executor(ctx)
+
+	result := &QueryResult{}
+	switch opts.Total {
+	case TotalNone:
+	case TotalEstimate:
+		if len(opts.Filters) == 0 && opts.Search == "" {
+			if estimate, ok := c.estimateCount(ctx, exec); ok {
+				result.Total = estimate
+				result.Estimated = true
+				break
+			}
+		}
+		fallthrough
+	default:
+		total, err := c.exactCount(ctx, exec, q, opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = total
+	}
+
+	querySQL, queryArgs := q.Build()
+	rows, err := exec.QueryContext(ctx, querySQL, queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("querying documents: %w", err)
+	}
+	defer rows.Close()
+
+	docs, err := ScanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, doc := range docs {
+		if docs[i], err = c.processRow(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Docs = docs
+	return result, nil
+}
+
+// findQuery builds the statement Find runs for opts.
+func (c *Collection) findQuery(opts *QueryOptions) (*QueryBuilder, error) {
+	if err := c.checkQueryFields(opts.Filters, opts.Sorts); err != nil {
+		return nil, err
+	}
+
+	q := NewQuery(c.name).Select(c.selectColumns()...)
 
 	for _, f := range opts.Filters {
 		q.Filter(f.Field, f.Op, f.Value)
@@ -98,9 +184,7 @@ func (c *Collection) Find(ctx context.Context, opts *QueryOptions) (*QueryResult
 		}
 	}
 
-	for _, s := range opts.Sorts {
-		q.Sort(s.Field, s.Order)
-	}
+	q.sorts = append(q.sorts, opts.Sorts...)
 
 	if opts.Limit > 0 {
 		q.Limit(opts.Limit)
@@ -110,31 +194,67 @@ func (c *Collection) Find(ctx context.Context, opts *QueryOptions) (*QueryResult
 		q.Offset(opts.Offset)
 	}
 
-	exec := c.executor(ctx)
+	return q, nil
+}
+
+// exactCount counts rows matching q. Counts outside a transaction are cached
+// briefly, since the count usually dominates list latency on large tables.
+func (c *Collection) exactCount(ctx context.Context, exec executor, q *QueryBuilder, opts *QueryOptions) (int64, error) {
+	_, inTx := TransactionFromContext(ctx)
+	cache := c.dbFor(ctx).CountCache()
+	useCache := cache != nil && !inTx
+
+	var key string
+	if useCache {
+		key = countCacheKey(opts.Filters, opts.Search)
+		if total, ok := cache.Get(c.name, key); ok {
+			return total, nil
+		}
+	}
 
 	countSQL, countArgs := q.BuildCount()
 	var total int64
 	if err := exec.QueryRowContext(ctx, countSQL, countArgs...).Scan(&total); err != nil {
-		return nil, fmt.Errorf("counting documents: %w", err)
+		return 0, fmt.Errorf("counting documents: %w", err)
 	}
 
-	querySQL, queryArgs := q.Build()
-	rows, err := exec.QueryContext(ctx, querySQL, queryArgs...)
-	if err != nil {
-		return nil, fmt.Errorf("querying documents: %w", err)
+	if useCache {
+		cache.Set(c.name, key, total)
 	}
-	defer rows.Close()
+	return total, nil
+}
 
-	docs, err := ScanRows(rows)
-	if err != nil {
-		return nil, err
+// estimateCount approximates the row count from sqlite_stat1, written by
+// ANALYZE, or from the largest rowid. Both can drift from the true count.
+func (c *Collection) estimateCount(ctx context.Context, exec executor) (int64, bool) {
+	var stat string
+	err := exec.QueryRowContext(ctx, "SELECT stat FROM sqlite_stat1 WHERE tbl = ? LIMIT 1", c.name).Scan(&stat)
+	if err == nil {
+		if fields := strings.Fields(stat); len(fields) > 0 {
+			if n, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+				return n, true
+			}
+		}
 	}
 
-	for i, doc := range docs {
-		docs[i] = c.processRow(doc)
+	var maxRowID sql.NullInt64
+	if err := exec.QueryRowContext(ctx, fmt.Sprintf("SELECT MAX(rowid) FROM %s", c.name)).Scan(&maxRowID); err == nil {
+		return maxRowID.Int64, true
 	}
 
-	return &QueryResult{Docs: docs, Total: total}, nil
+	return 0, false
+}
+
+// invalidateCounts drops cached counts after a write and tells the write
+// listener about it.
+func (c *Collection) invalidateCounts(ctx context.Context) {
+	db := c.dbFor(ctx)
+	if cache := db.CountCache(); cache != nil {
+		cache.Invalidate(c.name)
+	}
+	if db.onWrite != nil {
+		db.onWrite(c.name)
+	}
 }
 
 func (c *Collection) FindOne(ctx context.Context, id string) (Row, error) {
@@ -142,8 +262,24 @@ func (c *Collection) FindOne(ctx context.Context, id string) (Row, error) {
 	if pk == nil {
 		return nil, errors.New("collection has no primary key")
 	}
+	return c.findBy(ctx, pk.Name, id)
+}
 
-	q := NewQuery(c.name).Where(pk.Name, id).Limit(1)
+// FindBy returns the document whose field has value. The field should be the
+// primary key or unique; otherwise the first match is returned.
+func (c *Collection) FindBy(ctx context.Context, field string, value any) (Row, error) {
+	f, ok := c.schema.Fields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	if f.Encrypted {
+		return nil, fmt.Errorf("%w: %s", ErrEncryptedField, field)
+	}
+	return c.findBy(ctx, field, c.convertValue(value, f))
+}
+
+func (c *Collection) findBy(ctx context.Context, field string, value any) (Row, error) {
+	q := NewQuery(c.name).Select(c.selectColumns()...).Where(field, value).Limit(1)
 	querySQL, args := q.Build()
 
 	exec := c.executor(ctx)
@@ -162,7 +298,7 @@ func (c *Collection) FindOne(ctx context.Context, id string) (Row, error) {
 		return nil, ErrNotFound
 	}
 
-	return c.processRow(docs[0]), nil
+	return c.processRow(docs[0])
 }
 
 func (c *Collection) Create(ctx context.Context, data Row) (Row, error) {
@@ -184,48 +320,74 @@ func (c *Collection) Create(ctx context.Context, data Row) (Row, error) {
 		}
 	}
 
-	insert := NewInsert(c.name)
-	for _, field := range c.schema.OrderedFields() {
-		val, provided := processedData[field.Name]
-		useDefault := shouldUseDefault(field, val)
+	slugs := c.generateSlugs(processedData, nil, true)
+	if err := c.encryptFields(processedData); err != nil {
+		return nil, err
+	}
 
-		if provided && !useDefault {
-			insert.Set(field.Name, val)
-		} else if field.IsTimestampNow() {
-			insert.Set(field.Name, Now())
-		} else if field.HasStaticDefault() {
-			insert.Set(field.Name, field.StaticDefault())
-		} else if provided {
-			insert.Set(field.Name, val)
+	var err error
+	for {
+		insert := NewInsert(c.name)
+		for _, field := range c.schema.OrderedFields() {
+			val, provided := processedData[field.Name]
+			useDefault := shouldUseDefault(field, val)
+
+			if provided && !useDefault {
+				insert.Set(field.Name, val)
+			} else if field.IsTimestampNow() {
+				insert.Set(field.Name, Now())
+			} else if field.HasStaticDefault() {
+				insert.Set(field.Name, field.StaticDefault())
+			} else if provided {
+				insert.Set(field.Name, val)
+			}
 		}
-	}
 
-	insertSQL, args := insert.Build()
-	exec := c.executor(ctx)
-	_, err := exec.ExecContext(ctx, insertSQL, args...)
+		insertSQL, args := insert.Build()
+		_, err = c.executor(ctx).ExecContext(ctx, insertSQL, args...)
+		if !c.retrySlug(err, slugs, processedData) {
+			break
+		}
+	}
 	if err != nil {
 		if !errors.Is(ClassifyError(err), err) {
 			return nil, ClassifyError(err)
 		}
 		return nil, fmt.Errorf("inserting document: %w", err)
 	}
+	c.invalidateCounts(ctx)
 
-	doc, err := c.FindOne(ctx, fmt.Sprint(processedData[pk.Name]))
+	id := fmt.Sprint(processedData[pk.Name])
+	if err := c.writeBlobInfo(ctx, id, processedData, nil); err != nil {
+		return nil, err
+	}
+
+	doc, err := c.FindOne(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.recordHistory(ctx, schema.HistoryActionCreate, id, nil, doc); err != nil {
+		return nil, err
+	}
 
-	if c.hookTrigger != nil {
-		if hookErr := c.hookTrigger.OnInsert(ctx, c.name, doc); hookErr != nil {
-			return nil, fmt.Errorf("hook trigger failed: %w", hookErr)
-		}
+	if err := c.afterWrite(ctx, func(ctx context.Context, t HookTrigger) error {
+		return t.OnInsert(ctx, c.name, doc)
+	}); err != nil {
+		return nil, err
 	}
 
 	return doc, nil
 }
 
-//nolint:gocyclo // CRUD operations require validation and hook handling
 func (c *Collection) Update(ctx context.Context, id string, data Row) (Row, error) {
+	return c.update(ctx, id, data, nil)
+}
+
+// update updates a document, recording blobs as the BlobInfo of the blob
+// fields they name. The values of those fields in data are stored as given.
+//
+//nolint:gocyclo // CRUD operations require validation and hook handling
+func (c *Collection) update(ctx context.Context, id string, data Row, blobs map[string]*BlobInfo) (Row, error) {
 	pk := c.schema.PrimaryKeyField()
 	if pk == nil {
 		return nil, errors.New("collection has no primary key")
@@ -240,32 +402,48 @@ func (c *Collection) Update(ctx context.Context, id string, data Row) (Row, erro
 	}
 
 	processedData := c.processInput(data, false)
+	for name := range blobs {
+		processedData[name] = data[name]
+	}
 
-	update := NewUpdate(c.name).Where(pk.Name, id)
+	var slugs *slugRetry
+	if regenerate, _ := data[schema.RegenerateSlugKey].(bool); regenerate {
+		slugs = c.generateSlugs(processedData, existing, false)
+	}
+	if err := c.encryptFields(processedData); err != nil {
+		return nil, err
+	}
 
-	for fieldName, value := range processedData {
-		if fieldName == pk.Name {
-			continue
-		}
-		field, ok := c.schema.Fields[fieldName]
-		if !ok {
-			continue
+	var result sql.Result
+	for {
+		update := NewUpdate(c.name).Where(pk.Name, id)
+
+		for fieldName, value := range processedData {
+			if fieldName == pk.Name {
+				continue
+			}
+			field, ok := c.schema.Fields[fieldName]
+			if !ok {
+				continue
+			}
+			if field.IsAutoUpdateTimestamp() {
+				continue
+			}
+			update.Set(fieldName, value)
 		}
-		if field.IsAutoUpdateTimestamp() {
-			continue
+
+		for _, field := range c.schema.Fields {
+			if field.IsAutoUpdateTimestamp() {
+				update.Set(field.Name, Now())
+			}
 		}
-		update.Set(fieldName, value)
-	}
 
-	for _, field := range c.schema.Fields {
-		if field.IsAutoUpdateTimestamp() {
-			update.Set(field.Name, Now())
+		updateSQL, args := update.Build()
+		result, err = c.executor(ctx).ExecContext(ctx, updateSQL, args...)
+		if !c.retrySlug(err, slugs, processedData) {
+			break
 		}
 	}
-
-	updateSQL, args := update.Build()
-	exec := c.executor(ctx)
-	result, err := exec.ExecContext(ctx, updateSQL, args...)
 	if err != nil {
 		if !errors.Is(ClassifyError(err), err) {
 			return nil, ClassifyError(err)
@@ -276,16 +454,24 @@ func (c *Collection) Update(ctx context.Context, id string, data Row) (Row, erro
 	if affected, affectedErr := result.RowsAffected(); affectedErr == nil && affected == 0 {
 		return nil, ErrNotFound
 	}
+	c.invalidateCounts(ctx)
+
+	if err := c.writeBlobInfo(ctx, id, processedData, blobs); err != nil {
+		return nil, err
+	}
 
 	doc, err := c.FindOne(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.recordHistory(ctx, schema.HistoryActionUpdate, id, existing, doc); err != nil {
+		return nil, err
+	}
 
-	if c.hookTrigger != nil {
-		if hookErr := c.hookTrigger.OnUpdate(ctx, c.name, doc, existing); hookErr != nil {
-			return nil, fmt.Errorf("hook trigger failed: %w", hookErr)
-		}
+	if err := c.afterWrite(ctx, func(ctx context.Context, t HookTrigger) error {
+		return t.OnUpdate(ctx, c.name, doc, existing)
+	}); err != nil {
+		return nil, err
 	}
 
 	return doc, nil
@@ -312,17 +498,80 @@ func (c *Collection) Delete(ctx context.Context, id string) error {
 	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
 		return ErrNotFound
 	}
+	c.invalidateCounts(ctx)
 
-	if c.hookTrigger != nil {
-		if hookErr := c.hookTrigger.OnDelete(ctx, c.name, existing); hookErr != nil {
-			return fmt.Errorf("hook trigger failed: %w", hookErr)
-		}
+	if err := c.deleteBlobInfo(ctx, id); err != nil {
+		return err
+	}
+	if err := c.recordHistory(ctx, schema.HistoryActionDelete, id, existing, nil); err != nil {
+		return err
 	}
 
+	return c.afterWrite(ctx, func(ctx context.Context, t HookTrigger) error {
+		return t.OnDelete(ctx, c.name, existing)
+	})
+}
+
+// afterWrite calls hook with the hook trigger once a write is durable. In a
+// RunInTransaction call that is after commit, so hooks never hold the write
+// transaction; a hook failure can then no longer undo the write and is
+// logged. Otherwise hook runs right away and its error is returned.
+func (c *Collection) afterWrite(ctx context.Context, hook func(ctx context.Context, t HookTrigger) error) error {
+	if inAfterCommitScope(ctx) {
+		AfterCommit(ctx, func(ctx context.Context) {
+			// Reads made while the transaction was open may have cached
+			// counts that did not include this write.
+			c.invalidateCounts(ctx)
+			if c.hookTrigger == nil {
+				return
+			}
+			if err := hook(ctx, c.hookTrigger); err != nil {
+				log.Error().Err(err).Str("collection", c.name).Msg("Database hook failed after commit")
+			}
+		})
+		return nil
+	}
+
+	if c.hookTrigger == nil {
+		return nil
+	}
+	if err := hook(ctx, c.hookTrigger); err != nil {
+		return fmt.Errorf("hook trigger failed: %w", err)
+	}
 	return nil
 }
 
+// CollectionVersion summarizes a collection's contents cheaply enough to
+// derive list validators from on every request.
+type CollectionVersion struct {
+	// Changes counts the changes recorded for the collection in
+	// _alyx_changes. A trigger keeps it, and it never decreases, so every
+	// insert, update and delete moves it, even one whose change row is later
+	// pruned or suppressed.
+	Changes int64
+}
+
+// Version returns the collection's change count. ok is false if the
+// collection has no primary key, since its changes are then not recorded.
+func (c *Collection) Version(ctx context.Context) (version CollectionVersion, ok bool, err error) {
+	if c.schema.PrimaryKeyField() == nil {
+		return version, false, nil
+	}
+
+	err = c.executor(ctx).QueryRowContext(ctx,
+		`SELECT COALESCE((SELECT version FROM _alyx_collection_versions WHERE collection = ?), 0)`, c.name,
+	).Scan(&version.Changes)
+	if err != nil {
+		return version, false, fmt.Errorf("reading collection version: %w", err)
+	}
+	return version, true, nil
+}
+
 func (c *Collection) Count(ctx context.Context, filters []*Filter) (int64, error) {
+	if err := c.checkQueryFields(filters, nil); err != nil {
+		return 0, err
+	}
+
 	q := NewQuery(c.name)
 	for _, f := range filters {
 		q.Filter(f.Field, f.Op, f.Value)
@@ -330,7 +579,7 @@ func (c *Collection) Count(ctx context.Context, filters []*Filter) (int64, error
 
 	countSQL, args := q.BuildCount()
 	var count int64
-	if err := c.db.QueryRowContext(ctx, countSQL, args...).Scan(&count); err != nil {
+	if err := c.dbFor(ctx).QueryRowContext(ctx, countSQL, args...).Scan(&count); err != nil {
 		return 0, fmt.Errorf("counting documents: %w", err)
 	}
 
@@ -347,7 +596,7 @@ func (c *Collection) Exists(ctx context.Context, id string) (bool, error) {
 	querySQL, args := q.Build()
 
 	var exists int
-	err := c.db.QueryRowContext(ctx, querySQL, args...).Scan(&exists)
+	err := c.dbFor(ctx).QueryRowContext(ctx, querySQL, args...).Scan(&exists)
 	if errors.Is(err, sql.ErrNoRows) {
 		return false, nil
 	}
@@ -358,6 +607,78 @@ func (c *Collection) Exists(ctx context.Context, id string) (bool, error) {
 	return true, nil
 }
 
+// maxSlugAttempts bounds how many numbered suffixes are tried before a slug
+// collision is reported as a unique constraint error.
+const maxSlugAttempts = 100
+
+// slugRetry tracks the slugs generated for one write, so collisions can be
+// retried with numbered suffixes.
+type slugRetry struct {
+	bases    map[string]string
+	suffixes map[string]int
+	attempts int
+}
+
+// generateSlugs fills the slug fields of data that weren't supplied,
+// deriving them from their source fields in data or, on update, existing.
+// On create, a document whose source yields no slug falls back to its
+// primary key.
+func (c *Collection) generateSlugs(data, existing Row, isCreate bool) *slugRetry {
+	slugs := &slugRetry{bases: make(map[string]string), suffixes: make(map[string]int)}
+	for _, field := range c.schema.OrderedFields() {
+		if field.Slug == nil || !shouldUseDefault(field, data[field.Name]) {
+			continue
+		}
+
+		source, ok := data[field.Slug.From]
+		if !ok && existing != nil {
+			source = existing[field.Slug.From]
+		}
+		str, _ := source.(string)
+		base := schema.Slugify(str, field.Slug.Max())
+		if base == "" && isCreate {
+			if pk := c.schema.PrimaryKeyField(); pk != nil {
+				base = schema.Slugify(fmt.Sprint(data[pk.Name]), field.Slug.Max())
+			}
+		}
+		if base == "" {
+			continue
+		}
+		if existing != nil && existing[field.Name] == base {
+			continue
+		}
+
+		data[field.Name] = base
+		slugs.bases[field.Name] = base
+	}
+	return slugs
+}
+
+// retrySlug reports whether err is a unique violation on one of the
+// generated slugs, and if so replaces that slug in data with its next
+// numbered form: "title", then "title-2", "title-3" and so on.
+func (c *Collection) retrySlug(err error, slugs *slugRetry, data Row) bool {
+	if err == nil || slugs == nil || len(slugs.bases) == 0 || slugs.attempts >= maxSlugAttempts {
+		return false
+	}
+	var ce *ConstraintError
+	if !errors.As(ClassifyError(err), &ce) || ce.Type != "unique" || ce.Table != c.name {
+		return false
+	}
+	base, ok := slugs.bases[ce.Column]
+	if !ok {
+		return false
+	}
+
+	slugs.attempts++
+	if slugs.suffixes[ce.Column] == 0 {
+		slugs.suffixes[ce.Column] = 1
+	}
+	slugs.suffixes[ce.Column]++
+	data[ce.Column] = schema.SlugWithSuffix(base, slugs.suffixes[ce.Column], c.schema.Fields[ce.Column].Slug.Max())
+	return true
+}
+
 func (c *Collection) processInput(data Row, isCreate bool) Row {
 	result := make(Row)
 
@@ -385,7 +706,11 @@ func (c *Collection) processInput(data Row, isCreate bool) Row {
 	return result
 }
 
-func (c *Collection) processRow(row Row) Row {
+func (c *Collection) processRow(row Row) (Row, error) {
+	if err := c.decryptFields(row); err != nil {
+		return nil, err
+	}
+
 	for fieldName, value := range row {
 		field, ok := c.schema.Fields[fieldName]
 		if !ok {
@@ -424,18 +749,23 @@ func (c *Collection) processRow(row Row) Row {
 					row[fieldName] = s
 				}
 			}
+		case schema.FieldTypeBlob:
+			row[fieldName] = decodeBlobInfo(value)
 		case schema.FieldTypeID, schema.FieldTypeUUID, schema.FieldTypeString, schema.FieldTypeText, schema.FieldTypeRichText,
-			schema.FieldTypeInt, schema.FieldTypeFloat, schema.FieldTypeBlob,
+			schema.FieldTypeInt, schema.FieldTypeFloat,
 			schema.FieldTypeEmail, schema.FieldTypeURL, schema.FieldTypeDate, schema.FieldTypeRelation:
 		}
 	}
 
-	return row
+	return row, nil
 }
 
 func (c *Collection) getSearchableFields() []string {
 	var fields []string
 	for _, f := range c.schema.OrderedFields() {
+		if f.Encrypted {
+			continue
+		}
 		switch f.Type {
 		case schema.FieldTypeID, schema.FieldTypeString, schema.FieldTypeText, schema.FieldTypeUUID,
 			schema.FieldTypeEmail, schema.FieldTypeURL:
@@ -494,8 +824,10 @@ func (c *Collection) convertValue(value any, field *schema.Field) any {
 				return string(b)
 			}
 		}
+	case schema.FieldTypeBlob:
+		return blobContent(value)
 	case schema.FieldTypeID, schema.FieldTypeUUID, schema.FieldTypeString, schema.FieldTypeText, schema.FieldTypeRichText,
-		schema.FieldTypeInt, schema.FieldTypeFloat, schema.FieldTypeBlob,
+		schema.FieldTypeInt, schema.FieldTypeFloat,
 		schema.FieldTypeEmail, schema.FieldTypeURL, schema.FieldTypeDate, schema.FieldTypeRelation:
 	}
 
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCountCacheTTL is how long an exact count is reused before it is
+	// recomputed, unless a change to the collection invalidates it first.
+	DefaultCountCacheTTL = 5 * time.Second
+
+	// maxCountCacheEntries bounds the cached counts per collection.
+	maxCountCacheEntries = 256
+)
+
+// CountCache caches exact list counts per collection and normalized filter
+// set. Writes through Collection and the realtime change feed invalidate a
+// collection's entries; the TTL bounds staleness for writes made elsewhere.
+type CountCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]map[string]countEntry
+}
+
+type countEntry struct {
+	count   int64
+	expires time.Time
+}
+
+// NewCountCache creates a count cache with the given TTL.
+func NewCountCache(ttl time.Duration) *CountCache {
+	return &CountCache{
+		ttl:     ttl,
+		entries: make(map[string]map[string]countEntry),
+	}
+}
+
+// Get returns a cached count if it has not expired.
+func (c *CountCache) Get(collection, key string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[collection][key]
+	if !ok || time.Now().After(entry.expires) {
+		return 0, false
+	}
+	return entry.count, true
+}
+
+// Set caches a count.
+func (c *CountCache) Set(collection, key string, count int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := c.entries[collection]
+	if entries == nil || len(entries) >= maxCountCacheEntries {
+		entries = make(map[string]countEntry)
+		c.entries[collection] = entries
+	}
+	entries[key] = countEntry{count: count, expires: time.Now().Add(c.ttl)}
+}
+
+// Invalidate drops all cached counts for a collection.
+func (c *CountCache) Invalidate(collection string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, collection)
+}
+
+// countCacheKey normalizes filters and search so equivalent queries share an
+// entry regardless of filter order.
+func countCacheKey(filters []*Filter, search string) string {
+	parts := make([]string, 0, len(filters)+1)
+	for _, f := range filters {
+		parts = append(parts, fmt.Sprintf("%s\x1f%s\x1f%T\x1f%v", f.Field, f.Op, f.Value, f.Value))
+	}
+	sort.Strings(parts)
+	if search != "" {
+		parts = append(parts, "search\x1f"+search)
+	}
+	return strings.Join(parts, "\x1e")
+}
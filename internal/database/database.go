@@ -13,13 +13,22 @@ import (
 
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database/migrations"
+	"github.com/watzon/alyx/internal/tracing"
 )
 
 type DB struct {
 	*sql.DB
-	cfg    *config.DatabaseConfig
-	mu     sync.RWMutex
-	closed bool
+	cfg     *config.DatabaseConfig
+	counts  *CountCache
+	stmts   *StmtCache
+	retry   retryPolicy
+	cipher  *FieldCipher
+	onWrite func(collection string)
+	mu      sync.RWMutex
+	closed  bool
+
+	maintMu        sync.Mutex
+	lastCheckpoint time.Time
 }
 
 func Open(cfg *config.DatabaseConfig) (*DB, error) {
@@ -35,9 +44,13 @@ func Open(cfg *config.DatabaseConfig) (*DB, error) {
 	}
 
 	db := &DB{
-		DB:  sqlDB,
-		cfg: cfg,
+		DB:     sqlDB,
+		cfg:    cfg,
+		counts: NewCountCache(DefaultCountCacheTTL),
+		stmts:  NewStmtCache(sqlDB, cfg.StmtCacheSize),
+		retry:  newRetryPolicy(cfg.Retry),
 	}
+	db.stmts.retry = db.retry
 
 	if err := db.configure(); err != nil {
 		sqlDB.Close()
@@ -58,8 +71,38 @@ func Open(cfg *config.DatabaseConfig) (*DB, error) {
 	return db, nil
 }
 
+// CountCache returns the cache of exact list counts.
+func (db *DB) CountCache() *CountCache {
+	return db.counts
+}
+
+// Stmts returns the prepared statement cache. Its methods have the same
+// signatures as ExecContext, QueryContext and QueryRowContext.
+func (db *DB) Stmts() *StmtCache {
+	return db.stmts
+}
+
+// SetFieldCipher sets the cipher for encrypted fields.
+func (db *DB) SetFieldCipher(fc *FieldCipher) {
+	db.cipher = fc
+}
+
+// FieldCipher returns the cipher for encrypted fields, or nil if no field
+// encryption key is configured.
+func (db *DB) FieldCipher() *FieldCipher {
+	return db.cipher
+}
+
+// SetWriteListener sets fn to be called with a collection's name after each
+// write made through a Collection. fn must not block.
+func (db *DB) SetWriteListener(fn func(collection string)) {
+	db.onWrite = fn
+}
+
+// buildDSN gives every pooled connection the busy timeout, not just the one
+// configure runs on.
 func buildDSN(cfg *config.DatabaseConfig) string {
-	return cfg.Path
+	return fmt.Sprintf("%s?_pragma=busy_timeout(%d)", cfg.Path, cfg.BusyTimeout().Milliseconds())
 }
 
 func ensureDir(dbPath string) error {
@@ -109,6 +152,7 @@ func (db *DB) Close() error {
 		return nil
 	}
 	db.closed = true
+	db.stmts.Purge()
 
 	if db.cfg.WALMode() {
 		_, _ = db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
@@ -159,16 +203,50 @@ type Tx struct {
 	*sql.Tx
 }
 
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := tracing.StartQuery(ctx, query)
+	result, err := tx.Tx.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := tracing.StartQuery(ctx, query)
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := tracing.StartQuery(ctx, query)
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
+}
+
 func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
-	return db.DB.ExecContext(ctx, query, args...)
+	ctx, span := tracing.StartQuery(ctx, query)
+	var result sql.Result
+	err := db.retry.do(ctx, "exec", func() (err error) {
+		result, err = db.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	tracing.End(span, err)
+	return result, err
 }
 
 func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	return db.DB.QueryContext(ctx, query, args...)
+	ctx, span := tracing.StartQuery(ctx, query)
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
 }
 
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
-	return db.DB.QueryRowContext(ctx, query, args...)
+	ctx, span := tracing.StartQuery(ctx, query)
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
 }
 
 func (db *DB) Stats() sql.DBStats {
@@ -245,7 +323,27 @@ func Now() string {
 
 type contextKey string
 
-const txContextKey contextKey = "alyx_transaction"
+const (
+	txContextKey    contextKey = "alyx_transaction"
+	actorContextKey contextKey = "alyx_actor"
+	dbContextKey    contextKey = "alyx_database"
+)
+
+// WithDB routes the collection reads and writes made with ctx to db instead
+// of the database their collection was opened on. Dev mode uses it to send a
+// request to the sandbox database.
+func WithDB(ctx context.Context, db *DB) context.Context {
+	return context.WithValue(ctx, dbContextKey, db)
+}
+
+// DBFromContext returns the database recorded by WithDB, or fallback if
+// there is none.
+func DBFromContext(ctx context.Context, fallback *DB) *DB {
+	if db, ok := ctx.Value(dbContextKey).(*DB); ok && db != nil {
+		return db
+	}
+	return fallback
+}
 
 func WithTransaction(ctx context.Context, tx *sql.Tx) context.Context {
 	return context.WithValue(ctx, txContextKey, tx)
@@ -255,3 +353,15 @@ func TransactionFromContext(ctx context.Context) (*sql.Tx, bool) {
 	tx, ok := ctx.Value(txContextKey).(*sql.Tx)
 	return tx, ok
 }
+
+// WithActor records the ID of the user making changes through ctx, for
+// collection history.
+func WithActor(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, actorContextKey, userID)
+}
+
+// ActorFromContext returns the user ID recorded by WithActor, if any.
+func ActorFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(actorContextKey).(string)
+	return id, ok && id != ""
+}
@@ -3,9 +3,13 @@ package database
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/schema"
@@ -314,6 +318,241 @@ collections:
 	}
 }
 
+func TestCollection_Slugs(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	schemaYAML := `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+      slug:
+        type: string
+        unique: true
+        slug:
+          from: title
+          maxLength: 12
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, execErr := db.ExecContext(ctx, stmt); execErr != nil {
+			t.Fatalf("execute DDL: %v", execErr)
+		}
+	}
+	col := NewCollection(db, s.Collections["posts"])
+
+	create := func(data Row) Row {
+		t.Helper()
+		doc, err := col.Create(ctx, data)
+		if err != nil {
+			t.Fatalf("create %v: %v", data, err)
+		}
+		return doc
+	}
+
+	first := create(Row{"title": "Crème Brûlée!"})
+	if first["slug"] != "creme-brulee" {
+		t.Errorf("slug = %v, want creme-brulee", first["slug"])
+	}
+	if got := create(Row{"title": "Creme brulee"})["slug"]; got != "creme-brul-2" {
+		t.Errorf("second slug = %v, want creme-brul-2", got)
+	}
+	if got := create(Row{"title": "crème brûlée", "slug": ""})["slug"]; got != "creme-brul-3" {
+		t.Errorf("third slug = %v, want creme-brul-3", got)
+	}
+	if got := create(Row{"title": "Anything", "slug": "custom"})["slug"]; got != "custom" {
+		t.Errorf("explicit slug = %v, want custom", got)
+	}
+	if _, err := col.Create(ctx, Row{"title": "Other", "slug": "custom"}); !IsUniqueError(err) {
+		t.Errorf("explicit duplicate slug: expected unique error, got %v", err)
+	}
+
+	id := first["id"].(string)
+	updated, err := col.Update(ctx, id, Row{"title": "Tarte Tatin"})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated["slug"] != "creme-brulee" {
+		t.Errorf("slug changed without regenerate_slug: %v", updated["slug"])
+	}
+
+	updated, err = col.Update(ctx, id, Row{schema.RegenerateSlugKey: true})
+	if err != nil {
+		t.Fatalf("regenerate: %v", err)
+	}
+	if updated["slug"] != "tarte-tatin" {
+		t.Errorf("regenerated slug = %v, want tarte-tatin", updated["slug"])
+	}
+
+	if verrs := ValidateInput(s.Collections["posts"], Row{"title": "x"}, true); verrs.HasErrors() {
+		t.Errorf("omitted slug should be valid, got %v", verrs.Errors)
+	}
+	verrs := ValidateInput(s.Collections["posts"], Row{"title": "x", "slug": "Not A Slug"}, true)
+	if !verrs.HasErrors() || verrs.Errors[0].Code != "invalid_slug" {
+		t.Errorf("expected invalid_slug, got %v", verrs.Errors)
+	}
+}
+
+func TestCollection_FindTotalModes(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  notes:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+`))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, execErr := db.ExecContext(ctx, stmt); execErr != nil {
+			t.Fatalf("execute DDL: %v", execErr)
+		}
+	}
+
+	col := NewCollection(db, s.Collections["notes"])
+	for _, title := range []string{"a", "b", "c"} {
+		if _, err := col.Create(ctx, Row{"title": title}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	find := func(opts *QueryOptions) *QueryResult {
+		t.Helper()
+		result, err := col.Find(ctx, opts)
+		if err != nil {
+			t.Fatalf("find: %v", err)
+		}
+		return result
+	}
+
+	if got := find(&QueryOptions{}).Total; got != 3 {
+		t.Fatalf("expected exact total 3, got %d", got)
+	}
+
+	// A write that bypasses Collection is only seen once the cache is invalidated.
+	if _, err := db.ExecContext(ctx, "INSERT INTO notes (id, title) VALUES ('raw', 'd')"); err != nil {
+		t.Fatal(err)
+	}
+	if got := find(&QueryOptions{}).Total; got != 3 {
+		t.Errorf("expected cached total 3, got %d", got)
+	}
+	db.CountCache().Invalidate("notes")
+	if got := find(&QueryOptions{}).Total; got != 4 {
+		t.Errorf("expected total 4 after invalidation, got %d", got)
+	}
+
+	if _, err := col.Create(ctx, Row{"title": "e"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got := find(&QueryOptions{}).Total; got != 5 {
+		t.Errorf("expected Create to invalidate the cached total, got %d", got)
+	}
+
+	none := find(&QueryOptions{Total: TotalNone})
+	if none.Total != 0 || len(none.Docs) != 5 {
+		t.Errorf("expected docs without a total, got total %d and %d docs", none.Total, len(none.Docs))
+	}
+
+	estimate := find(&QueryOptions{Total: TotalEstimate})
+	if !estimate.Estimated || estimate.Total != 5 {
+		t.Errorf("expected estimated total 5, got %d (estimated=%v)", estimate.Total, estimate.Estimated)
+	}
+
+	filtered := find(&QueryOptions{Total: TotalEstimate, Filters: []*Filter{{Field: "title", Op: OpEq, Value: "a"}}})
+	if filtered.Estimated || filtered.Total != 1 {
+		t.Errorf("expected exact total 1 with a filter, got %d (estimated=%v)", filtered.Total, filtered.Estimated)
+	}
+}
+
+func TestCollection_Explain(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  notes:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      status:
+        type: string
+        index: true
+      title:
+        type: string
+`))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, execErr := db.ExecContext(ctx, stmt); execErr != nil {
+			t.Fatalf("execute DDL: %v", execErr)
+		}
+	}
+	col := NewCollection(db, s.Collections["notes"])
+
+	indexed, err := col.Explain(ctx, &QueryOptions{Filters: []*Filter{{Field: "status", Op: OpEq, Value: "draft"}}, Limit: 10})
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if !indexed.UsesIndex || len(indexed.Indexes) != 1 {
+		t.Errorf("expected an indexed filter to use an index, got %+v", indexed)
+	}
+	if !strings.Contains(indexed.SQL, "LIMIT") || len(indexed.Args) != 1 || indexed.Args[0] != "draft" {
+		t.Errorf("unexpected statement %q %v", indexed.SQL, indexed.Args)
+	}
+
+	scanned, err := col.Explain(ctx, &QueryOptions{Filters: []*Filter{{Field: "title", Op: OpEq, Value: "a"}}})
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if scanned.UsesIndex || !strings.Contains(scanned.Note, "Full scan of notes") {
+		t.Errorf("expected an unindexed filter to scan, got %+v", scanned)
+	}
+	if len(scanned.Steps) == 0 || !strings.HasPrefix(scanned.Steps[0].Detail, "SCAN") {
+		t.Errorf("expected a SCAN step, got %+v", scanned.Steps)
+	}
+
+	if _, err := col.Explain(ctx, &QueryOptions{Filters: []*Filter{{Field: "missing", Op: OpEq, Value: "a"}}}); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestCountCacheKey(t *testing.T) {
+	a := []*Filter{{Field: "a", Op: OpEq, Value: 1}, {Field: "b", Op: OpGt, Value: "x"}}
+	b := []*Filter{{Field: "b", Op: OpGt, Value: "x"}, {Field: "a", Op: OpEq, Value: 1}}
+	if countCacheKey(a, "") != countCacheKey(b, "") {
+		t.Error("expected filter order not to affect the key")
+	}
+	if countCacheKey(a, "") == countCacheKey(a, "q") {
+		t.Error("expected search to affect the key")
+	}
+	if countCacheKey([]*Filter{{Field: "a", Op: OpEq, Value: 1}}, "") == countCacheKey([]*Filter{{Field: "a", Op: OpEq, Value: "1"}}, "") {
+		t.Error("expected value types to affect the key")
+	}
+}
+
 func TestScanRows(t *testing.T) {
 	db := testDB(t)
 	ctx := context.Background()
@@ -370,6 +609,39 @@ func TestParseSortString(t *testing.T) {
 	}
 }
 
+func TestParseSorts(t *testing.T) {
+	sorts, err := ParseSorts("-published:nullslast, created_at,title:nullsfirst")
+	if err != nil {
+		t.Fatalf("ParseSorts: %v", err)
+	}
+	want := []Sort{
+		{Field: "published", Order: SortDesc, Nulls: NullsLast},
+		{Field: "created_at", Order: SortAsc},
+		{Field: "title", Order: SortAsc, Nulls: NullsFirst},
+	}
+	if len(sorts) != len(want) {
+		t.Fatalf("got %d sorts, want %d", len(sorts), len(want))
+	}
+	for i, s := range sorts {
+		if *s != want[i] {
+			t.Errorf("sort %d = %+v, want %+v", i, *s, want[i])
+		}
+	}
+
+	q := NewQuery("posts")
+	q.sorts = sorts
+	query, _ := q.Build()
+	if !strings.Contains(query, "ORDER BY published DESC NULLS LAST, created_at ASC, title ASC NULLS FIRST") {
+		t.Errorf("unexpected ORDER BY in %q", query)
+	}
+
+	for _, bad := range []string{"", "-", "title:nullsmiddle", "title,,created_at"} {
+		if _, err := ParseSorts(bad); !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("ParseSorts(%q) error = %v, want ErrInvalidSort", bad, err)
+		}
+	}
+}
+
 func TestParseFilterString(t *testing.T) {
 	tests := []struct {
 		input   string
@@ -407,3 +679,274 @@ func TestParseFilterString(t *testing.T) {
 func init() {
 	os.Setenv("TZ", "UTC")
 }
+
+// recordingHooks records, for each hook call, how many users it could see
+// from outside the request's transaction.
+type recordingHooks struct {
+	db    *DB
+	calls []string
+	fail  bool
+}
+
+func (h *recordingHooks) record(ctx context.Context, action string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	var count int
+	if err := h.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		return err
+	}
+	h.calls = append(h.calls, fmt.Sprintf("%s:%d", action, count))
+	if h.fail {
+		return errors.New("hook failed")
+	}
+	return nil
+}
+
+func (h *recordingHooks) OnInsert(ctx context.Context, _ string, _ map[string]any) error {
+	return h.record(ctx, "insert")
+}
+
+func (h *recordingHooks) OnUpdate(ctx context.Context, _ string, _, _ map[string]any) error {
+	return h.record(ctx, "update")
+}
+
+func (h *recordingHooks) OnDelete(ctx context.Context, _ string, _ map[string]any) error {
+	return h.record(ctx, "delete")
+}
+
+func TestRunInTransaction(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  users:
+    fields:
+      id: { type: uuid, primary: true, default: auto }
+      email: { type: string, unique: true }
+`))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, execErr := db.ExecContext(ctx, stmt); execErr != nil {
+			t.Fatalf("execute DDL: %v", execErr)
+		}
+	}
+
+	hooks := &recordingHooks{db: db}
+	col := NewCollection(db, s.Collections["users"])
+	col.SetHookTrigger(hooks)
+
+	// A failure after the first write rolls back both writes, and hooks
+	// for the rolled back insert never fire.
+	err = db.RunInTransaction(ctx, func(ctx context.Context) error {
+		if _, err := col.Create(ctx, Row{"email": "a@example.com"}); err != nil {
+			return err
+		}
+		_, err := col.Create(ctx, Row{"email": "a@example.com"})
+		return err
+	})
+	if AsConstraintError(err) == nil {
+		t.Fatalf("expected a constraint error, got %v", err)
+	}
+	if count, _ := col.Count(ctx, nil); count != 0 {
+		t.Errorf("expected rollback to leave no users, got %d", count)
+	}
+	if len(hooks.calls) != 0 {
+		t.Errorf("expected no hooks after rollback, got %v", hooks.calls)
+	}
+
+	// Hooks run after commit, outside the transaction, so they see the
+	// write and a failing hook no longer fails it.
+	hooks.fail = true
+	var created Row
+	err = db.RunInTransaction(ctx, func(ctx context.Context) error {
+		created, err = col.Create(ctx, Row{"email": "b@example.com"})
+		if err != nil {
+			return err
+		}
+		if len(hooks.calls) != 0 {
+			t.Error("expected hooks to wait for commit")
+		}
+		_, err = col.Update(ctx, created["id"].(string), Row{"email": "c@example.com"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction: %v", err)
+	}
+	if fmt.Sprint(hooks.calls) != "[insert:1 update:1]" {
+		t.Errorf("hook calls = %v", hooks.calls)
+	}
+
+	// Outside RunInTransaction, hooks run right away and their errors are
+	// returned as before.
+	hooks.calls = nil
+	if err := col.Delete(ctx, created["id"].(string)); err == nil || !strings.Contains(err.Error(), "hook trigger failed") {
+		t.Errorf("expected hook failure, got %v", err)
+	}
+	if fmt.Sprint(hooks.calls) != "[delete:0]" {
+		t.Errorf("hook calls = %v", hooks.calls)
+	}
+
+	// Nested calls join the outer transaction and defer to its commit.
+	var ran []string
+	err = db.RunInTransaction(ctx, func(ctx context.Context) error {
+		err := db.RunInTransaction(ctx, func(ctx context.Context) error {
+			AfterCommit(ctx, func(context.Context) { ran = append(ran, "inner") })
+			return nil
+		})
+		ran = append(ran, "outer")
+		return err
+	})
+	if err != nil || fmt.Sprint(ran) != "[outer inner]" {
+		t.Errorf("ran = %v, err = %v", ran, err)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	// A real busy error: a second connection that won't wait for the lock.
+	other, err := Open(&config.DatabaseConfig{Path: db.cfg.Path, Retry: config.RetryConfig{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.ExecContext(ctx, "PRAGMA busy_timeout = 0"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, beginWriteSQL); err != nil {
+		t.Fatal(err)
+	}
+	_, busyErr := other.ExecContext(ctx, "CREATE TABLE busy (id INTEGER)")
+	tx.Rollback()
+	if !IsBusy(busyErr) {
+		t.Fatalf("expected a busy error, got %v", busyErr)
+	}
+	if IsBusy(errors.New("database is locked")) || IsBusy(nil) {
+		t.Error("expected only SQLite errors to be busy errors")
+	}
+
+	failing := func(times int, calls *int) func() error {
+		return func() error {
+			*calls++
+			if *calls <= times {
+				return busyErr
+			}
+			return nil
+		}
+	}
+
+	tests := []struct {
+		name    string
+		policy  retryPolicy
+		fails   int
+		calls   int
+		wantErr bool
+	}{
+		{"recovers", retryPolicy{attempts: 3, maxWait: time.Second}, 2, 3, false},
+		{"attempts exhausted", retryPolicy{attempts: 3, maxWait: time.Second}, 5, 3, true},
+		{"wait budget exhausted", retryPolicy{attempts: 10, maxWait: 0}, 5, 1, true},
+		{"disabled", retryPolicy{attempts: 1, maxWait: time.Second}, 1, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := tt.policy.do(ctx, "exec", failing(tt.fails, &calls))
+			if calls != tt.calls || (err != nil) != tt.wantErr {
+				t.Errorf("calls = %d, err = %v; want %d calls, error %v", calls, err, tt.calls, tt.wantErr)
+			}
+		})
+	}
+
+	calls := 0
+	errOther := errors.New("constraint failed")
+	err = retryPolicy{attempts: 3, maxWait: time.Second}.do(ctx, "exec", func() error {
+		calls++
+		return errOther
+	})
+	if calls != 1 || err != errOther {
+		t.Errorf("expected other errors not to be retried, got %d calls and %v", calls, err)
+	}
+}
+
+func TestRunInTransaction_ConcurrentWriters(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  counters:
+    fields:
+      id: { type: string, primary: true }
+      value: { type: int }
+`))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, execErr := db.ExecContext(ctx, stmt); execErr != nil {
+			t.Fatalf("execute DDL: %v", execErr)
+		}
+	}
+
+	// A second handle on the same file is a second connection, as another
+	// server process would be.
+	other, err := Open(&config.DatabaseConfig{Path: db.cfg.Path})
+	if err != nil {
+		t.Fatalf("open second connection: %v", err)
+	}
+	defer other.Close()
+
+	dbs := []*DB{db, other}
+	cols := []*Collection{NewCollection(db, s.Collections["counters"]), NewCollection(other, s.Collections["counters"])}
+	if _, err := cols[0].Create(ctx, Row{"id": "hits", "value": 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, increments = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*increments)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(conn *DB, col *Collection) {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				errs <- conn.RunInTransaction(ctx, func(ctx context.Context) error {
+					doc, err := col.FindOne(ctx, "hits")
+					if err != nil {
+						return err
+					}
+					// Widen the window for the other connection to commit
+					// between this transaction's read and its write.
+					time.Sleep(time.Millisecond)
+					_, err = col.Update(ctx, "hits", Row{"value": doc["value"].(int64) + 1})
+					return err
+				})
+			}
+		}(dbs[w%2], cols[w%2])
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("write failed: %v", err)
+		}
+	}
+	doc, err := cols[0].FindOne(ctx, "hits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["value"] != int64(writers*increments) {
+		t.Errorf("expected %d increments, got %v", writers*increments, doc["value"])
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package database
+
+// freeDiskSpace is not implemented on this platform.
+func freeDiskSpace(string) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package database
+
+import "syscall"
+
+// freeDiskSpace returns the bytes available to unprivileged users on the
+// volume containing dir.
+func freeDiskSpace(dir string) (uint64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, false
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), true
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PlanStep is one step of an EXPLAIN QUERY PLAN, nested under its parent.
+type PlanStep struct {
+	ID       int         `json:"id"`
+	Detail   string      `json:"detail"`
+	Children []*PlanStep `json:"children,omitempty"`
+}
+
+// QueryPlan is SQLite's plan for the statement Find runs.
+type QueryPlan struct {
+	SQL   string      `json:"sql"`
+	Args  []any       `json:"args"`
+	Steps []*PlanStep `json:"steps"`
+	// UsesIndex is true when the collection's table is searched through an
+	// index or its primary key rather than scanned.
+	UsesIndex bool     `json:"uses_index"`
+	Indexes   []string `json:"indexes,omitempty"`
+	// EstimatedRows is the approximate number of rows a full scan reads.
+	EstimatedRows int64  `json:"estimated_rows,omitempty"`
+	Note          string `json:"note"`
+}
+
+// Explain returns the query plan for the statement Find runs for opts,
+// without running it.
+func (c *Collection) Explain(ctx context.Context, opts *QueryOptions) (*QueryPlan, error) {
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+
+	q, err := c.findQuery(opts)
+	if err != nil {
+		return nil, err
+	}
+	query, args := q.Build()
+	if args == nil {
+		args = []any{}
+	}
+
+	exec := c.executor(ctx)
+	rows, err := exec.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("explaining query: %w", err)
+	}
+	defer rows.Close()
+
+	plan := &QueryPlan{SQL: query, Args: args}
+	byID := make(map[int]*PlanStep)
+	scan := false
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			return nil, err
+		}
+
+		step := &PlanStep{ID: id, Detail: detail}
+		byID[id] = step
+		if p, ok := byID[parent]; ok {
+			p.Children = append(p.Children, step)
+		} else {
+			plan.Steps = append(plan.Steps, step)
+		}
+
+		// SQLite before 3.36 writes SCAN TABLE and SEARCH TABLE.
+		detail = strings.Replace(detail, " TABLE ", " ", 1)
+		switch {
+		case strings.HasPrefix(detail, "SEARCH "+c.name+" ") || strings.HasPrefix(detail, "SCAN "+c.name+" USING "):
+			plan.UsesIndex = true
+			if index := planIndexName(detail); index != "" {
+				plan.Indexes = append(plan.Indexes, index)
+			}
+		case detail == "SCAN "+c.name || strings.HasPrefix(detail, "SCAN "+c.name+" "):
+			scan = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case scan:
+		plan.UsesIndex = false
+		if n, ok := c.estimateCount(ctx, exec); ok {
+			plan.EstimatedRows = n
+			plan.Note = fmt.Sprintf("Full scan of %s: reads about %d rows", c.name, n)
+		} else {
+			plan.Note = fmt.Sprintf("Full scan of %s: reads every row", c.name)
+		}
+	case plan.UsesIndex:
+		plan.Note = fmt.Sprintf("Searches %s through an index: reads only matching rows", c.name)
+	default:
+		plan.Note = fmt.Sprintf("Does not read %s", c.name)
+	}
+	return plan, nil
+}
+
+// planIndexName returns the index a plan step uses, if it names one.
+func planIndexName(detail string) string {
+	for _, marker := range []string{"USING COVERING INDEX ", "USING INDEX "} {
+		if i := strings.Index(detail, marker); i >= 0 {
+			name := detail[i+len(marker):]
+			if j := strings.IndexByte(name, ' '); j >= 0 {
+				name = name[:j]
+			}
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,338 @@
+package database
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// encryptedPrefix starts every stored encrypted value. The full format is
+// "enc:v1:<key id>:<base64 nonce and ciphertext>", where the key ID lets a
+// value be decrypted after the key that wrote it has been rotated out.
+const encryptedPrefix = "enc:v1:"
+
+var (
+	// ErrFieldKeyMissing is returned when a collection with encrypted fields
+	// is written or read without a field encryption key.
+	ErrFieldKeyMissing = errors.New("security.field_encryption_key is not configured")
+
+	// ErrUnknownFieldKey is returned for values encrypted with a key that is
+	// neither the current key nor one of the previous keys.
+	ErrUnknownFieldKey = errors.New("value was encrypted with an unknown field encryption key")
+
+	// ErrEncryptedField is returned when a query filters or sorts on an
+	// encrypted field.
+	ErrEncryptedField = errors.New("encrypted fields cannot be filtered or sorted")
+)
+
+// FieldCipher encrypts and decrypts the values of encrypted fields with
+// AES-256-GCM.
+type FieldCipher struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewFieldCipher creates a cipher that encrypts with key and decrypts with
+// key or any of the previous keys. Keys are base64-encoded 32-byte values.
+func NewFieldCipher(key string, previous ...string) (*FieldCipher, error) {
+	fc := &FieldCipher{keys: make(map[string]cipher.AEAD)}
+	for i, k := range append([]string{key}, previous...) {
+		id, aead, err := newFieldKey(k)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			fc.current = id
+		}
+		if _, ok := fc.keys[id]; !ok {
+			fc.keys[id] = aead
+		}
+	}
+	return fc, nil
+}
+
+// ConfigureFieldEncryption sets db's field cipher from cfg. A schema with
+// encrypted fields and no configured key is a configuration error.
+func (db *DB) ConfigureFieldEncryption(cfg *config.SecurityConfig, s *schema.Schema) error {
+	if cfg.FieldEncryptionKey == "" {
+		if s.HasEncryptedFields() {
+			return fmt.Errorf("%w: the schema declares encrypted fields", ErrFieldKeyMissing)
+		}
+		return nil
+	}
+	fc, err := NewFieldCipher(cfg.FieldEncryptionKey, cfg.PreviousFieldEncryptionKeys...)
+	if err != nil {
+		return err
+	}
+	db.SetFieldCipher(fc)
+	return nil
+}
+
+func newFieldKey(key string) (string, cipher.AEAD, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return "", nil, errors.New("field encryption key must be a base64-encoded 32-byte key")
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:4]), aead, nil
+}
+
+// Encrypt encrypts plaintext with the current key. aad binds the value to
+// where it is stored, so a value copied to another field fails to decrypt.
+func (fc *FieldCipher) Encrypt(plaintext, aad string) (string, error) {
+	aead := fc.keys[fc.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return encryptedPrefix + fc.current + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value written by Encrypt. Values without the encrypted
+// prefix, stored before the field was encrypted, are returned unchanged.
+func (fc *FieldCipher) Decrypt(value, aad string) (string, error) {
+	id, payload, ok := splitEncrypted(value)
+	if !ok {
+		return value, nil
+	}
+	aead, ok := fc.keys[id]
+	if !ok {
+		return "", ErrUnknownFieldKey
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("decrypting value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// IsCurrent reports whether value is encrypted with the current key.
+func (fc *FieldCipher) IsCurrent(value string) bool {
+	id, _, ok := splitEncrypted(value)
+	return ok && id == fc.current
+}
+
+func splitEncrypted(value string) (id, payload string, ok bool) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
+
+// fieldAAD is the additional data an encrypted field's values are sealed
+// with.
+func fieldAAD(collection string, f *schema.Field) string {
+	return collection + "." + f.Name
+}
+
+// encryptFields replaces the values of encrypted fields in data with their
+// ciphertext.
+func (c *Collection) encryptFields(data Row) error {
+	for _, f := range c.schema.EncryptedFields() {
+		s, ok := data[f.Name].(string)
+		if !ok {
+			continue
+		}
+		fc := c.db.FieldCipher()
+		if fc == nil {
+			return ErrFieldKeyMissing
+		}
+		encrypted, err := fc.Encrypt(s, fieldAAD(c.name, f))
+		if err != nil {
+			return fmt.Errorf("encrypting %s: %w", f.Name, err)
+		}
+		data[f.Name] = encrypted
+	}
+	return nil
+}
+
+// decryptFields replaces the ciphertext of encrypted fields in row with
+// their values.
+func (c *Collection) decryptFields(row Row) error {
+	for _, f := range c.schema.EncryptedFields() {
+		s, ok := row[f.Name].(string)
+		if !ok {
+			continue
+		}
+		if _, _, encrypted := splitEncrypted(s); !encrypted {
+			continue
+		}
+		fc := c.db.FieldCipher()
+		if fc == nil {
+			return ErrFieldKeyMissing
+		}
+		plaintext, err := fc.Decrypt(s, fieldAAD(c.name, f))
+		if err != nil {
+			return fmt.Errorf("decrypting %s.%s: %w", c.name, f.Name, err)
+		}
+		row[f.Name] = plaintext
+	}
+	return nil
+}
+
+// checkQueryFields rejects filters and sorts on encrypted fields, which would
+// compare ciphertext, and sorts on fields that do not exist or have no order.
+func (c *Collection) checkQueryFields(filters []*Filter, sorts []*Sort) error {
+	for _, f := range filters {
+		if field, ok := c.schema.Fields[f.Field]; ok && field.Encrypted {
+			return fmt.Errorf("%w: %s", ErrEncryptedField, f.Field)
+		}
+	}
+	for _, s := range sorts {
+		field, ok := c.schema.Fields[s.Field]
+		switch {
+		case !ok:
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidSort, s.Field)
+		case field.Encrypted:
+			return fmt.Errorf("%w: %s", ErrEncryptedField, s.Field)
+		case !field.Type.IsSortable():
+			return fmt.Errorf("%w: %s field %q cannot be sorted", ErrInvalidSort, field.Type, s.Field)
+		}
+	}
+	return nil
+}
+
+// RotateEncryptedFields re-encrypts the collection's encrypted values that
+// are not yet encrypted with the current key, batchSize rows per
+// transaction, and returns the number of rows rewritten. Values stored
+// before the field was encrypted are encrypted too.
+//
+// Each batch is read and rewritten in one write transaction, so concurrent
+// writes are never overwritten with stale values. Re-encryption changes no
+// document, so auto-update timestamps keep their values and the change
+// records of the rewrites are dropped: subscribers and the change feed do
+// not see them.
+func (c *Collection) RotateEncryptedFields(ctx context.Context, batchSize int) (int, error) {
+	fields := c.schema.EncryptedFields()
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	fc := c.db.FieldCipher()
+	if fc == nil {
+		return 0, ErrFieldKeyMissing
+	}
+	pk := c.schema.PrimaryKeyField()
+	if pk == nil {
+		return 0, errors.New("collection has no primary key")
+	}
+
+	columns := []string{pk.Name}
+	for _, f := range fields {
+		columns = append(columns, f.Name)
+	}
+	var timestamps []string
+	for _, f := range c.schema.OrderedFields() {
+		if f.IsAutoUpdateTimestamp() {
+			timestamps = append(timestamps, f.Name)
+			columns = append(columns, f.Name)
+		}
+	}
+
+	var after any
+	rotated := 0
+	for {
+		var batch []Row
+		n := 0
+		err := c.db.Transaction(ctx, func(tx *Tx) error {
+			if _, err := tx.ExecContext(ctx, beginWriteSQL); err != nil {
+				return fmt.Errorf("locking %s: %w", c.name, err)
+			}
+			var lastChangeID int64
+			if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM _alyx_changes").Scan(&lastChangeID); err != nil {
+				return fmt.Errorf("reading change cursor: %w", err)
+			}
+
+			q := NewQuery(c.name).Select(columns...).OrderBy(pk.Name).Limit(batchSize)
+			if after != nil {
+				q.Filter(pk.Name, OpGt, after)
+			}
+			querySQL, args := q.Build()
+			rows, err := tx.QueryContext(ctx, querySQL, args...)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", c.name, err)
+			}
+			batch, err = ScanRows(rows)
+			rows.Close()
+			if err != nil {
+				return err
+			}
+
+			for _, row := range batch {
+				update := NewUpdate(c.name).Where(pk.Name, row[pk.Name])
+				changed := false
+				for _, f := range fields {
+					s, ok := row[f.Name].(string)
+					if !ok || fc.IsCurrent(s) {
+						continue
+					}
+					aad := fieldAAD(c.name, f)
+					plaintext, err := fc.Decrypt(s, aad)
+					if err != nil {
+						return fmt.Errorf("decrypting %s.%s of %v: %w", c.name, f.Name, row[pk.Name], err)
+					}
+					encrypted, err := fc.Encrypt(plaintext, aad)
+					if err != nil {
+						return err
+					}
+					update.Set(f.Name, encrypted)
+					changed = true
+				}
+				if !changed {
+					continue
+				}
+				// Setting the timestamps explicitly overrides the trigger
+				// that would move them.
+				for _, name := range timestamps {
+					update.Set(name, row[name])
+				}
+				updateSQL, args := update.Build()
+				if _, err := tx.ExecContext(ctx, updateSQL, args...); err != nil {
+					return fmt.Errorf("updating %s: %w", c.name, err)
+				}
+				n++
+			}
+
+			if n > 0 {
+				if _, err := tx.ExecContext(ctx,
+					"DELETE FROM _alyx_changes WHERE id > ? AND collection = ?",
+					lastChangeID, c.name,
+				); err != nil {
+					return fmt.Errorf("suppressing change events: %w", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return rotated, err
+		}
+		rotated += n
+		if len(batch) < batchSize {
+			return rotated, nil
+		}
+		after = batch[len(batch)-1][pk.Name]
+	}
+}
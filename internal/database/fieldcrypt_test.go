@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const (
+	testFieldKey    = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	testNewFieldKey = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+func TestFieldCipher(t *testing.T) {
+	fc, err := NewFieldCipher(testFieldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := fc.Encrypt("123-45-6789", "patients.ssn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := fc.Encrypt("123-45-6789", "patients.ssn")
+	if !strings.HasPrefix(a, encryptedPrefix) || a == b {
+		t.Errorf("expected distinct prefixed ciphertexts, got %q and %q", a, b)
+	}
+	if got, err := fc.Decrypt(a, "patients.ssn"); err != nil || got != "123-45-6789" {
+		t.Errorf("Decrypt = %q, %v", got, err)
+	}
+	if _, err := fc.Decrypt(a, "patients.notes"); err == nil {
+		t.Error("expected a value moved to another field to fail to decrypt")
+	}
+	if got, err := fc.Decrypt("stored in plaintext", "patients.ssn"); err != nil || got != "stored in plaintext" {
+		t.Errorf("Decrypt of an unencrypted value = %q, %v", got, err)
+	}
+
+	rotated, err := NewFieldCipher(testNewFieldKey, testFieldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.IsCurrent(a) {
+		t.Error("value encrypted with the previous key reported as current")
+	}
+	if got, err := rotated.Decrypt(a, "patients.ssn"); err != nil || got != "123-45-6789" {
+		t.Errorf("Decrypt with previous key = %q, %v", got, err)
+	}
+
+	other, _ := NewFieldCipher(testNewFieldKey)
+	if _, err := other.Decrypt(a, "patients.ssn"); !errors.Is(err, ErrUnknownFieldKey) {
+		t.Errorf("expected ErrUnknownFieldKey, got %v", err)
+	}
+
+	if _, err := NewFieldCipher("c2hvcnQ="); err == nil {
+		t.Error("expected an error for a short key")
+	}
+}
+
+func TestCollection_EncryptedFields(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  patients:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      name: { type: string }
+      ssn: { type: string, encrypted: true, nullable: true }
+      updated_at: { type: timestamp, default: now, onUpdate: now }
+`))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, execErr := db.ExecContext(ctx, stmt); execErr != nil {
+			t.Fatalf("execute DDL: %v", execErr)
+		}
+	}
+	col := NewCollection(db, s.Collections["patients"])
+
+	if err := db.ConfigureFieldEncryption(&config.SecurityConfig{}, s); !errors.Is(err, ErrFieldKeyMissing) {
+		t.Fatalf("expected ErrFieldKeyMissing without a key, got %v", err)
+	}
+	if _, err := col.Create(ctx, Row{"name": "Ada", "ssn": "123-45-6789"}); !errors.Is(err, ErrFieldKeyMissing) {
+		t.Fatalf("expected writes to fail without a key, got %v", err)
+	}
+
+	if err := db.ConfigureFieldEncryption(&config.SecurityConfig{FieldEncryptionKey: testFieldKey}, s); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := col.Create(ctx, Row{"name": "Ada", "ssn": "123-45-6789"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if doc["ssn"] != "123-45-6789" {
+		t.Errorf("ssn = %v, want the decrypted value", doc["ssn"])
+	}
+	id := doc["id"].(string)
+
+	stored := func() string {
+		t.Helper()
+		var v string
+		if err := db.QueryRowContext(ctx, "SELECT ssn FROM patients WHERE id = ?", id).Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	first := stored()
+	if !strings.HasPrefix(first, encryptedPrefix) || strings.Contains(first, "6789") {
+		t.Errorf("stored ssn = %q, want ciphertext", first)
+	}
+
+	if _, err := col.Update(ctx, id, Row{"ssn": "987-65-4321"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, _ := col.FindOne(ctx, id); got["ssn"] != "987-65-4321" {
+		t.Errorf("ssn after update = %v", got["ssn"])
+	}
+
+	_, err = col.Find(ctx, &QueryOptions{Filters: []*Filter{{Field: "ssn", Op: OpEq, Value: "x"}}})
+	if !errors.Is(err, ErrEncryptedField) {
+		t.Errorf("filter on encrypted field: expected ErrEncryptedField, got %v", err)
+	}
+	_, err = col.Find(ctx, &QueryOptions{Sorts: []*Sort{{Field: "ssn", Order: SortAsc}}})
+	if !errors.Is(err, ErrEncryptedField) {
+		t.Errorf("sort on encrypted field: expected ErrEncryptedField, got %v", err)
+	}
+
+	// A row written before the field was encrypted, and a key rotation.
+	if _, err := db.ExecContext(ctx, "INSERT INTO patients (id, name, ssn) VALUES ('plaintext000001', 'Bob', '555-55-5555')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ConfigureFieldEncryption(&config.SecurityConfig{
+		FieldEncryptionKey:          testNewFieldKey,
+		PreviousFieldEncryptionKeys: []string{testFieldKey},
+	}, s); err != nil {
+		t.Fatal(err)
+	}
+	// Rotation is not a change to the documents: timestamps stay put and no
+	// change events are recorded.
+	if _, err := db.ExecContext(ctx, "UPDATE patients SET updated_at = '2020-01-01T00:00:00Z'"); err != nil {
+		t.Fatal(err)
+	}
+	var lastChange int64
+	if err := db.QueryRowContext(ctx, "SELECT MAX(id) FROM _alyx_changes").Scan(&lastChange); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := col.RotateEncryptedFields(ctx, 1)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if rotated != 2 {
+		t.Errorf("rotated %d rows, want 2", rotated)
+	}
+	var moved, changes int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM patients WHERE updated_at != '2020-01-01T00:00:00Z'").Scan(&moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM _alyx_changes WHERE id > ?", lastChange).Scan(&changes); err != nil {
+		t.Fatal(err)
+	}
+	if moved != 0 || changes != 0 {
+		t.Errorf("rotation moved %d timestamps and recorded %d changes, want none", moved, changes)
+	}
+	if again, _ := col.RotateEncryptedFields(ctx, 1); again != 0 {
+		t.Errorf("second rotation rewrote %d rows, want 0", again)
+	}
+
+	if err := db.ConfigureFieldEncryption(&config.SecurityConfig{FieldEncryptionKey: testNewFieldKey}, s); err != nil {
+		t.Fatal(err)
+	}
+	result, err := col.Find(ctx, nil)
+	if err != nil {
+		t.Fatalf("find with only the new key: %v", err)
+	}
+	for _, doc := range result.Docs {
+		if s, _ := doc["ssn"].(string); strings.HasPrefix(s, encryptedPrefix) || s == "" {
+			t.Errorf("ssn of %v = %q after rotation", doc["id"], s)
+		}
+	}
+}
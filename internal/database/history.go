@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// recordHistory writes a row to the collection's history table for a change
+// from before to after, either of which is nil for a create or delete. It
+// uses the write's executor, so in a transaction the history row commits or
+// rolls back with the change. Updates that change no field are not recorded.
+func (c *Collection) recordHistory(ctx context.Context, action, id string, before, after Row) error {
+	h := c.schema.History
+	if h == nil {
+		return nil
+	}
+
+	changes := c.historyChanges(before, after)
+	if len(changes) == 0 && action == schema.HistoryActionUpdate {
+		return nil
+	}
+
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("encoding history changes: %w", err)
+	}
+
+	insert := NewInsert(c.schema.HistoryTable()).
+		Set("doc_id", id).
+		Set("action", action).
+		Set("changes", string(changesJSON)).
+		Set("created_at", Now())
+	if actor, ok := ActorFromContext(ctx); ok {
+		insert.Set("actor", actor)
+	}
+	if h.Snapshot {
+		state := after
+		if state == nil {
+			state = before
+		}
+		snapshot := make(Row, len(state))
+		for name, value := range state {
+			if field, ok := c.schema.Fields[name]; ok && !field.Encrypted {
+				snapshot[name] = value
+			}
+		}
+		snapshotJSON, err := json.Marshal(snapshot)
+		if err != nil {
+			return fmt.Errorf("encoding history snapshot: %w", err)
+		}
+		insert.Set("snapshot", string(snapshotJSON))
+	}
+
+	insertSQL, args := insert.Build()
+	if _, err := c.executor(ctx).ExecContext(ctx, insertSQL, args...); err != nil {
+		return fmt.Errorf("recording history: %w", err)
+	}
+	return nil
+}
+
+// historyChanges returns the fields that differ between before and after,
+// each as its from and to values. Encrypted fields are marked redacted
+// instead, and auto-update timestamps are left out.
+func (c *Collection) historyChanges(before, after Row) map[string]map[string]any {
+	changes := make(map[string]map[string]any)
+	for _, field := range c.schema.OrderedFields() {
+		if field.IsAutoUpdateTimestamp() && before != nil && after != nil {
+			continue
+		}
+		from, hadFrom := before[field.Name]
+		to, hasTo := after[field.Name]
+		if !hadFrom && !hasTo {
+			continue
+		}
+		if before != nil && after != nil && reflect.DeepEqual(from, to) {
+			continue
+		}
+
+		change := make(map[string]any, 2)
+		if field.Encrypted {
+			change["redacted"] = true
+		} else {
+			if before != nil {
+				change["from"] = from
+			}
+			if after != nil {
+				change["to"] = to
+			}
+		}
+		changes[field.Name] = change
+	}
+	return changes
+}
+
+// History returns the history rows recorded for the document id, newest
+// first. Filters and paging in opts apply on top of that; its sorts are
+// ignored.
+func (c *Collection) History(ctx context.Context, id string, opts *QueryOptions) (*QueryResult, error) {
+	if c.schema.History == nil {
+		return nil, fmt.Errorf("collection %s has no history", c.name)
+	}
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+	q := *opts
+	q.Filters = append([]*Filter{{Field: "doc_id", Op: OpEq, Value: id}}, opts.Filters...)
+	q.Sorts = []*Sort{{Field: "id", Order: SortDesc}}
+	return NewCollection(c.db, c.schema.HistoryCollection()).Find(ctx, &q)
+}
+
+// LastDeleted returns the field values of the document id as of its most
+// recent delete, from its history. It returns nil if no delete was recorded.
+func (c *Collection) LastDeleted(ctx context.Context, id string) (Row, error) {
+	if c.schema.History == nil {
+		return nil, nil
+	}
+	result, err := c.History(ctx, id, &QueryOptions{
+		Filters: []*Filter{{Field: "action", Op: OpEq, Value: schema.HistoryActionDelete}},
+		Limit:   1,
+		Total:   TotalNone,
+	})
+	if err != nil || len(result.Docs) == 0 {
+		return nil, err
+	}
+
+	changes, _ := result.Docs[0]["changes"].(map[string]any)
+	doc := make(Row, len(changes))
+	for name, change := range changes {
+		if m, ok := change.(map[string]any); ok {
+			if from, ok := m["from"]; ok {
+				doc[name] = from
+			}
+		}
+	}
+	return doc, nil
+}
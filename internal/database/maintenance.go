@@ -0,0 +1,267 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+// CheckpointMode is a SQLite WAL checkpoint mode.
+type CheckpointMode string
+
+const (
+	// CheckpointPassive copies as many frames as possible without waiting for
+	// readers or writers.
+	CheckpointPassive CheckpointMode = "PASSIVE"
+	// CheckpointFull waits for writers, then checkpoints the whole WAL.
+	CheckpointFull CheckpointMode = "FULL"
+	// CheckpointRestart is FULL and also waits for readers so the next writer
+	// restarts the WAL from the beginning.
+	CheckpointRestart CheckpointMode = "RESTART"
+	// CheckpointTruncate is RESTART and also truncates the WAL file to zero bytes.
+	CheckpointTruncate CheckpointMode = "TRUNCATE"
+)
+
+// ParseCheckpointMode parses a checkpoint mode case-insensitively.
+func ParseCheckpointMode(s string) (CheckpointMode, error) {
+	switch mode := CheckpointMode(strings.ToUpper(s)); mode {
+	case CheckpointPassive, CheckpointFull, CheckpointRestart, CheckpointTruncate:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid checkpoint mode %q: must be PASSIVE, FULL, RESTART or TRUNCATE", s)
+}
+
+// ErrInsufficientDiskSpace is returned when VACUUM would not have room to
+// rewrite the database.
+var ErrInsufficientDiskSpace = errors.New("insufficient disk space")
+
+// CheckpointResult reports the outcome of a WAL checkpoint.
+type CheckpointResult struct {
+	Mode CheckpointMode `json:"mode"`
+	// Busy is true when the checkpoint could not complete because of other
+	// connections.
+	Busy bool `json:"busy"`
+	// WALFrames is the number of frames in the WAL file.
+	WALFrames int64 `json:"wal_frames"`
+	// CheckpointedFrames is the number of frames copied back to the database.
+	CheckpointedFrames int64 `json:"checkpointed_frames"`
+	// WALSizeBefore and WALSizeAfter are the WAL file sizes in bytes.
+	WALSizeBefore int64         `json:"wal_size_before"`
+	WALSizeAfter  int64         `json:"wal_size_after"`
+	Duration      time.Duration `json:"duration_ns"`
+}
+
+// WALPath returns the path of the database's write-ahead log file.
+func (db *DB) WALPath() string {
+	return db.cfg.Path + "-wal"
+}
+
+// WALSize returns the size of the WAL file in bytes, or 0 if it does not exist.
+func (db *DB) WALSize() (int64, error) {
+	info, err := os.Stat(db.WALPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// LastCheckpoint returns when the last checkpoint ran, or the zero time if
+// none has run since the database was opened.
+func (db *DB) LastCheckpoint() time.Time {
+	db.maintMu.Lock()
+	defer db.maintMu.Unlock()
+	return db.lastCheckpoint
+}
+
+// Checkpoint runs PRAGMA wal_checkpoint with the given mode.
+func (db *DB) Checkpoint(ctx context.Context, mode CheckpointMode) (*CheckpointResult, error) {
+	mode, err := ParseCheckpointMode(string(mode))
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	result := &CheckpointResult{Mode: mode}
+	result.WALSizeBefore, _ = db.WALSize()
+
+	var busy int
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("PRAGMA wal_checkpoint(%s)", mode)).
+		Scan(&busy, &result.WALFrames, &result.CheckpointedFrames); err != nil {
+		return nil, fmt.Errorf("checkpointing WAL: %w", err)
+	}
+	result.Busy = busy != 0
+	result.WALSizeAfter, _ = db.WALSize()
+	result.Duration = time.Since(start)
+
+	db.maintMu.Lock()
+	db.lastCheckpoint = time.Now()
+	db.maintMu.Unlock()
+
+	return result, nil
+}
+
+// Size returns the size of the main database file in bytes.
+func (db *DB) Size(ctx context.Context) (int64, error) {
+	var pageCount, pageSize int64
+	if err := db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, err
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pageCount * pageSize, nil
+}
+
+// Vacuum rebuilds the database file. VACUUM writes a full copy of the
+// database, so it is refused unless the volume has at least that much free
+// space on top of the current WAL.
+func (db *DB) Vacuum(ctx context.Context) error {
+	size, err := db.Size(ctx)
+	if err != nil {
+		return fmt.Errorf("reading database size: %w", err)
+	}
+	walSize, _ := db.WALSize()
+
+	free, ok := freeDiskSpace(filepath.Dir(db.cfg.Path))
+	if ok && free < uint64(size+walSize) {
+		return fmt.Errorf("%w: VACUUM needs about %d bytes, %d available", ErrInsufficientDiskSpace, size+walSize, free)
+	}
+	if !ok {
+		log.Warn().Str("path", db.cfg.Path).Msg("Could not determine free disk space before VACUUM")
+	}
+
+	if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("vacuuming database: %w", err)
+	}
+	// Statements prepared before VACUUM are recompiled by SQLite on next use,
+	// but dropping them releases their memory early.
+	db.stmts.Purge()
+	return nil
+}
+
+// Analyze gathers query planner statistics for all tables and indexes.
+func (db *DB) Analyze(ctx context.Context) error {
+	if _, err := db.ExecContext(ctx, "ANALYZE"); err != nil {
+		return fmt.Errorf("analyzing database: %w", err)
+	}
+	return nil
+}
+
+// Optimize runs PRAGMA optimize, which analyzes only tables whose statistics
+// are likely out of date.
+func (db *DB) Optimize(ctx context.Context) error {
+	if _, err := db.ExecContext(ctx, "PRAGMA optimize"); err != nil {
+		return fmt.Errorf("optimizing database: %w", err)
+	}
+	return nil
+}
+
+// Checkpointer checkpoints the WAL in the background. Under sustained writes
+// SQLite's automatic checkpoints rarely find a moment without readers, so the
+// WAL file keeps growing until something forces a checkpoint.
+type Checkpointer struct {
+	db   *DB
+	cfg  *config.CheckpointConfig
+	mode CheckpointMode
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// NewCheckpointer creates a background checkpointer for db.
+func NewCheckpointer(db *DB, cfg *config.CheckpointConfig) *Checkpointer {
+	mode, err := ParseCheckpointMode(cfg.Mode)
+	if err != nil {
+		mode = CheckpointPassive
+	}
+
+	return &Checkpointer{
+		db:   db,
+		cfg:  cfg,
+		mode: mode,
+		done: make(chan struct{}),
+	}
+}
+
+// Start begins the background checkpoint loop.
+func (c *Checkpointer) Start(ctx context.Context) {
+	c.wg.Add(1)
+	go c.loop(ctx)
+
+	log.Info().
+		Dur("interval", c.cfg.Interval).
+		Str("mode", string(c.mode)).
+		Int64("wal_size_threshold", c.cfg.WALSizeThreshold).
+		Msg("WAL checkpointer started")
+}
+
+// Stop halts the background loop and waits for it to finish.
+func (c *Checkpointer) Stop() {
+	c.once.Do(func() { close(c.done) })
+	c.wg.Wait()
+}
+
+func (c *Checkpointer) loop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.RunOnce(ctx); err != nil {
+				log.Error().Err(err).Msg("WAL checkpoint failed")
+			}
+		}
+	}
+}
+
+// RunOnce checkpoints the WAL if it has reached the size threshold. It
+// returns nil when no checkpoint was needed.
+func (c *Checkpointer) RunOnce(ctx context.Context) (*CheckpointResult, error) {
+	size, err := c.db.WALSize()
+	if err != nil {
+		return nil, fmt.Errorf("reading WAL size: %w", err)
+	}
+	if size == 0 || size < c.cfg.WALSizeThreshold {
+		return nil, nil
+	}
+
+	result, err := c.db.Checkpoint(ctx, c.mode)
+	if err != nil {
+		return nil, err
+	}
+
+	event := log.Debug()
+	if result.Busy {
+		event = log.Warn()
+	}
+	event.
+		Str("mode", string(result.Mode)).
+		Bool("busy", result.Busy).
+		Int64("wal_size_before", result.WALSizeBefore).
+		Int64("wal_size_after", result.WALSizeAfter).
+		Int64("checkpointed_frames", result.CheckpointedFrames).
+		Dur("duration", result.Duration).
+		Msg("WAL checkpoint")
+
+	return result, nil
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+func writeRows(t *testing.T, db *DB, n int) {
+	t.Helper()
+	for i := range n {
+		if _, err := db.Exec(`INSERT INTO items (id, name) VALUES (?, ?)`, fmt.Sprintf("item%d", i), "some reasonably long name to grow the wal"); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+}
+
+func TestDB_Checkpoint(t *testing.T) {
+	db := stmtCacheDB(t, 0)
+	ctx := context.Background()
+
+	writeRows(t, db, 200)
+
+	before, err := db.WALSize()
+	if err != nil {
+		t.Fatalf("wal size: %v", err)
+	}
+	if before == 0 {
+		t.Fatal("expected a non-empty WAL after writes")
+	}
+	if !db.LastCheckpoint().IsZero() {
+		t.Error("expected no checkpoint before one has run")
+	}
+
+	result, err := db.Checkpoint(ctx, CheckpointTruncate)
+	if err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	if result.Busy {
+		t.Error("expected checkpoint not to be busy")
+	}
+	if result.WALSizeBefore != before || result.WALSizeAfter != 0 {
+		t.Errorf("expected WAL %d -> 0, got %d -> %d", before, result.WALSizeBefore, result.WALSizeAfter)
+	}
+	if db.LastCheckpoint().IsZero() {
+		t.Error("expected last checkpoint time to be recorded")
+	}
+
+	if _, err := db.Checkpoint(ctx, "sometimes"); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+}
+
+func TestParseCheckpointMode(t *testing.T) {
+	mode, err := ParseCheckpointMode("truncate")
+	if err != nil || mode != CheckpointTruncate {
+		t.Errorf("expected TRUNCATE, got %q (%v)", mode, err)
+	}
+	if _, err := ParseCheckpointMode("FAST"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestCheckpointer_Threshold(t *testing.T) {
+	db := stmtCacheDB(t, 0)
+	ctx := context.Background()
+
+	writeRows(t, db, 50)
+	size, err := db.WALSize()
+	if err != nil {
+		t.Fatalf("wal size: %v", err)
+	}
+
+	cp := NewCheckpointer(db, &config.CheckpointConfig{
+		Interval:         time.Minute,
+		Mode:             "TRUNCATE",
+		WALSizeThreshold: size + 1,
+	})
+	result, err := cp.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result != nil {
+		t.Fatal("expected no checkpoint below the threshold")
+	}
+
+	for i := range 50 {
+		if _, err := db.Exec(`UPDATE items SET name = ? WHERE id = ?`, "renamed", fmt.Sprintf("item%d", i)); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	}
+
+	result, err = cp.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result == nil {
+		t.Fatal("expected a checkpoint above the threshold")
+	}
+	if result.WALSizeAfter != 0 {
+		t.Errorf("expected TRUNCATE to empty the WAL, got %d bytes", result.WALSizeAfter)
+	}
+
+	cp.Start(ctx)
+	cp.Stop()
+	cp.Stop()
+}
+
+func TestDB_MaintenanceActions(t *testing.T) {
+	db := stmtCacheDB(t, 0)
+	ctx := context.Background()
+
+	writeRows(t, db, 100)
+	if _, err := db.Exec(`DELETE FROM items`); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if err := db.Analyze(ctx); err != nil {
+		t.Errorf("analyze: %v", err)
+	}
+	if err := db.Optimize(ctx); err != nil {
+		t.Errorf("optimize: %v", err)
+	}
+	if err := db.Vacuum(ctx); err != nil {
+		t.Errorf("vacuum: %v", err)
+	}
+
+	size, err := db.Size(ctx)
+	if err != nil {
+		t.Fatalf("size: %v", err)
+	}
+	if size <= 0 {
+		t.Errorf("expected positive database size, got %d", size)
+	}
+}
@@ -153,6 +153,17 @@ func loadMigrations() ([]migration, error) {
 	return migrations, nil
 }
 
+// inTriggerBody reports whether stmt, read so far, is a CREATE TRIGGER whose
+// body has not reached its END.
+func inTriggerBody(stmt string) bool {
+	upper := strings.ToUpper(stripLeadingComments(stmt))
+	if !strings.HasPrefix(upper, "CREATE TRIGGER") {
+		return false
+	}
+	fields := strings.Fields(upper)
+	return len(fields) == 0 || fields[len(fields)-1] != "END"
+}
+
 func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
@@ -162,8 +173,8 @@ func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
 
 	statements := splitStatements(m.content)
 	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" || strings.HasPrefix(stmt, "--") {
+		stmt = stripLeadingComments(stmt)
+		if stmt == "" {
 			continue
 		}
 		if _, err := tx.ExecContext(ctx, stmt); err != nil {
@@ -180,8 +191,20 @@ func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
 	return tx.Commit()
 }
 
+// stripLeadingComments trims stmt and drops the comment lines before its SQL,
+// so that a statement documented by a comment still runs.
+func stripLeadingComments(stmt string) string {
+	stmt = strings.TrimSpace(stmt)
+	for strings.HasPrefix(stmt, "--") {
+		_, rest, _ := strings.Cut(stmt, "\n")
+		stmt = strings.TrimSpace(rest)
+	}
+	return stmt
+}
+
 // splitStatements splits SQL content into individual statements.
-// Handles semicolons inside strings and comments.
+// Handles semicolons inside strings and comments, and inside the
+// BEGIN ... END body of a CREATE TRIGGER.
 func splitStatements(content string) []string {
 	var statements []string
 	var current strings.Builder
@@ -198,6 +221,11 @@ func splitStatements(content string) []string {
 			}
 		}
 
+		if ch == ';' && !inString && inTriggerBody(current.String()) {
+			current.WriteRune(ch)
+			continue
+		}
+
 		if ch == ';' && !inString {
 			stmt := strings.TrimSpace(current.String())
 			if stmt != "" {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	_ "modernc.org/sqlite"
@@ -199,3 +200,24 @@ func TestStorageTablesMigration(t *testing.T) {
 		t.Error("idx_uploads_expires_at index does not exist")
 	}
 }
+
+func TestSplitStatements_Trigger(t *testing.T) {
+	content := `-- comment
+CREATE TABLE t (id INTEGER);
+
+CREATE TRIGGER tr AFTER INSERT ON t
+BEGIN
+    INSERT INTO t (id) VALUES (1);
+    DELETE FROM t WHERE id = 2;
+END;
+
+SELECT 1;`
+
+	statements := splitStatements(content)
+	if len(statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d: %q", len(statements), statements)
+	}
+	if !strings.HasSuffix(statements[1], "END") {
+		t.Errorf("expected the trigger to end with its END, got %q", statements[1])
+	}
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS _alyx_auth_tokens (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES _alyx_users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK(type IN ('verification', 'password_reset')),
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_tokens_user_type ON _alyx_auth_tokens(user_id, type);
@@ -0,0 +1,20 @@
+ALTER TABLE _alyx_users ADD COLUMN deletion_requested_at TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_requested_at ON _alyx_users(deletion_requested_at) WHERE deletion_requested_at IS NOT NULL;
+
+CREATE TABLE _alyx_auth_tokens_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES _alyx_users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK(type IN ('verification', 'password_reset', 'account_restore')),
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+INSERT INTO _alyx_auth_tokens_new SELECT id, user_id, type, token_hash, expires_at, created_at FROM _alyx_auth_tokens;
+
+DROP TABLE _alyx_auth_tokens;
+
+ALTER TABLE _alyx_auth_tokens_new RENAME TO _alyx_auth_tokens;
+
+CREATE INDEX IF NOT EXISTS idx_auth_tokens_user_type ON _alyx_auth_tokens(user_id, type);
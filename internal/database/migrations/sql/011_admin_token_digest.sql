@@ -0,0 +1,2 @@
+-- Admin tokens are looked up by the digest of the presented secret.
+CREATE INDEX IF NOT EXISTS idx_admin_tokens_token_hash ON _alyx_admin_tokens(token_hash);
@@ -0,0 +1,4 @@
+-- The jitter-free fire time a schedule is next due at, and the event of its
+-- latest run, which holds the schedule's overlap lock until it is processed.
+ALTER TABLE _alyx_scheduler_state ADD COLUMN next_slot_at TEXT;
+ALTER TABLE _alyx_scheduler_state ADD COLUMN lock_event_id TEXT;
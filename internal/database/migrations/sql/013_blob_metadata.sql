@@ -0,0 +1,13 @@
+-- Metadata for the content of blob fields. A blob field with a storage
+-- bucket holds the ID of the file in that bucket instead of the content.
+CREATE TABLE IF NOT EXISTS _alyx_blobs (
+    collection TEXT NOT NULL,
+    document_id TEXT NOT NULL,
+    field TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    mime_type TEXT NOT NULL,
+    etag TEXT NOT NULL,
+    bucket TEXT,
+    updated_at TEXT NOT NULL,
+    PRIMARY KEY (collection, document_id, field)
+);
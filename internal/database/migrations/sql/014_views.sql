@@ -0,0 +1,10 @@
+-- Materialized views: the definition each backing table was created from,
+-- and the outcome of the latest refresh.
+CREATE TABLE IF NOT EXISTS _alyx_views (
+    name TEXT PRIMARY KEY,
+    definition TEXT NOT NULL,
+    refreshed_at TEXT,
+    refresh_ms INTEGER,
+    row_count INTEGER,
+    error TEXT
+);
@@ -0,0 +1,4 @@
+-- Tokens created with require_signing are identified by key_id in signed
+-- requests instead of sending their secret.
+ALTER TABLE _alyx_admin_tokens ADD COLUMN key_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_admin_tokens_key_id ON _alyx_admin_tokens(key_id);
@@ -0,0 +1,3 @@
+-- The user who uploaded a file, for bucket rules such as
+-- auth.id == file.owner_id.
+ALTER TABLE _alyx_files ADD COLUMN owner_id TEXT;
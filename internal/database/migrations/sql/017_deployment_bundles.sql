@@ -0,0 +1,4 @@
+-- The archive a deployment was made from, for deployments uploaded as a
+-- bundle (alyx deploy pack).
+ALTER TABLE _alyx_deployments ADD COLUMN bundle_hash TEXT;
+ALTER TABLE _alyx_deployments ADD COLUMN bundle_size INTEGER;
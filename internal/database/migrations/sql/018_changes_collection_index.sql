@@ -0,0 +1,3 @@
+-- Lets the latest change of a collection be found without a scan, for list
+-- validators.
+CREATE INDEX IF NOT EXISTS idx_changes_collection ON _alyx_changes(collection, id);
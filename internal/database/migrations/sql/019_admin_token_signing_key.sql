@@ -0,0 +1,4 @@
+-- Signing tokens verify requests with a key derived from their secret,
+-- stored apart from token_hash and sealed with the field encryption key
+-- when one is configured.
+ALTER TABLE _alyx_admin_tokens ADD COLUMN signing_key TEXT;
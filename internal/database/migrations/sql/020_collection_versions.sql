@@ -0,0 +1,16 @@
+-- Counts the changes recorded for each collection. Unlike the IDs in
+-- _alyx_changes, the count never goes back when change rows are pruned or
+-- suppressed, so list validators derived from it always move.
+CREATE TABLE IF NOT EXISTS _alyx_collection_versions (
+    collection TEXT PRIMARY KEY,
+    version INTEGER NOT NULL
+);
+
+INSERT OR IGNORE INTO _alyx_collection_versions (collection, version)
+SELECT collection, MAX(id) FROM _alyx_changes GROUP BY collection;
+
+CREATE TRIGGER IF NOT EXISTS _alyx_changes_version AFTER INSERT ON _alyx_changes
+BEGIN
+    INSERT INTO _alyx_collection_versions (collection, version) VALUES (NEW.collection, 1)
+    ON CONFLICT(collection) DO UPDATE SET version = version + 1;
+END;
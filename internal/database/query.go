@@ -1,10 +1,15 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
 
+// ErrInvalidSort is returned for a sort on a field that does not exist or
+// cannot be sorted, or with an unknown modifier.
+var ErrInvalidSort = errors.New("invalid sort")
+
 type FilterOp string
 
 const (
@@ -34,9 +39,28 @@ const (
 	SortDesc SortOrder = "DESC"
 )
 
+// NullsOrder places NULL values before or after the others. By default
+// SQLite sorts them first ascending and last descending.
+type NullsOrder string
+
+const (
+	NullsDefault NullsOrder = ""
+	NullsFirst   NullsOrder = "FIRST"
+	NullsLast    NullsOrder = "LAST"
+)
+
 type Sort struct {
 	Field string
 	Order SortOrder
+	Nulls NullsOrder
+}
+
+func (s *Sort) sql() string {
+	clause := fmt.Sprintf("%s %s", s.Field, s.Order)
+	if s.Nulls != NullsDefault {
+		clause += " NULLS " + string(s.Nulls)
+	}
+	return clause
 }
 
 type SearchCondition struct {
@@ -124,7 +148,7 @@ func (q *QueryBuilder) Build() (string, []any) {
 		sb.WriteString(" ORDER BY ")
 		var sortClauses []string
 		for _, s := range q.sorts {
-			sortClauses = append(sortClauses, fmt.Sprintf("%s %s", s.Field, s.Order))
+			sortClauses = append(sortClauses, s.sql())
 		}
 		sb.WriteString(strings.Join(sortClauses, ", "))
 	}
@@ -371,6 +395,41 @@ func ParseSortString(s string) (field string, order SortOrder) {
 	return s, SortAsc
 }
 
+// ParseSort parses one sort field such as "title", "-created_at" or
+// "-published_at:nullslast".
+func ParseSort(s string) (*Sort, error) {
+	spec, modifier, _ := strings.Cut(s, ":")
+	field, order := ParseSortString(spec)
+	if field == "" {
+		return nil, fmt.Errorf("%w: empty field", ErrInvalidSort)
+	}
+
+	sort := &Sort{Field: field, Order: order}
+	switch modifier {
+	case "":
+	case "nullsfirst":
+		sort.Nulls = NullsFirst
+	case "nullslast":
+		sort.Nulls = NullsLast
+	default:
+		return nil, fmt.Errorf("%w: unknown modifier %q on %s: must be nullsfirst or nullslast", ErrInvalidSort, modifier, field)
+	}
+	return sort, nil
+}
+
+// ParseSorts parses a comma separated sort such as "status,-created_at".
+func ParseSorts(s string) ([]*Sort, error) {
+	var sorts []*Sort
+	for _, part := range strings.Split(s, ",") {
+		sort, err := ParseSort(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
+
 func ParseFilterString(s string) (*Filter, error) {
 	parts := strings.SplitN(s, ":", 3)
 	if len(parts) < 2 {
@@ -0,0 +1,90 @@
+package database
+
+import "context"
+
+const afterCommitContextKey contextKey = "alyx_after_commit"
+
+// beginWriteSQL changes nothing but takes the write lock, which makes the
+// transaction immediate: behind another writer it waits, within the busy
+// timeout, before doing anything. A deferred transaction that reads and then
+// writes instead fails with SQLITE_BUSY_SNAPSHOT, which the busy timeout
+// cannot help with, if another connection committed in between. The driver
+// can only make every transaction immediate, which would also serialize the
+// transactions API's long-lived ones.
+const beginWriteSQL = "UPDATE _alyx_changes SET id = id WHERE 0"
+
+// afterCommitQueue collects the work to run once a request's transaction has
+// committed.
+type afterCommitQueue struct {
+	fns []func(ctx context.Context)
+}
+
+// RunInTransaction runs fn in a transaction that Collection methods called
+// with fn's context use, so all of a request's writes commit or roll back
+// together. The transaction commits when fn returns nil and rolls back
+// otherwise. Work registered with AfterCommit runs, in order, once the
+// transaction has committed and outside it; on rollback it is dropped.
+//
+// Only database work belongs in fn. With the single connection used for
+// local SQLite, anything that reaches the database other than through fn's
+// context, such as a function invoked by a synchronous hook, waits for the
+// transaction and deadlocks. Such work is registered with AfterCommit.
+//
+// If any statement or the commit fails because the database is busy or
+// locked, the transaction is rolled back and fn runs again from the start in
+// a new one, within the database's retry budget.
+//
+// When ctx already carries a transaction, such as one opened through the
+// transactions API, fn joins it and the AfterCommit work runs as soon as fn
+// succeeds, since that transaction is committed by a later request. A joined
+// transaction is never retried.
+func (db *DB) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if inAfterCommitScope(ctx) {
+		return fn(ctx)
+	}
+
+	var queue *afterCommitQueue
+	if _, ok := TransactionFromContext(ctx); ok {
+		queue = &afterCommitQueue{}
+		if err := fn(context.WithValue(ctx, afterCommitContextKey, queue)); err != nil {
+			return err
+		}
+	} else {
+		err := db.retry.do(ctx, "transaction", func() error {
+			// Work registered by a rolled back attempt is dropped.
+			queue = &afterCommitQueue{}
+			return db.Transaction(ctx, func(tx *Tx) error {
+				if _, err := tx.ExecContext(ctx, beginWriteSQL); err != nil {
+					return err
+				}
+				return fn(context.WithValue(WithTransaction(ctx, tx.Tx), afterCommitContextKey, queue))
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, after := range queue.fns {
+		after(ctx)
+	}
+	return nil
+}
+
+// AfterCommit defers fn until the transaction RunInTransaction opened for ctx
+// has committed. fn is given a context without the transaction. Outside
+// RunInTransaction, fn runs right away.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	queue, ok := ctx.Value(afterCommitContextKey).(*afterCommitQueue)
+	if !ok {
+		fn(ctx)
+		return
+	}
+	queue.fns = append(queue.fns, fn)
+}
+
+// inAfterCommitScope reports whether ctx belongs to a RunInTransaction call.
+func inAfterCommitScope(ctx context.Context) bool {
+	_, ok := ctx.Value(afterCommitContextKey).(*afterCommitQueue)
+	return ok
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/metrics"
+)
+
+// retryBaseDelay is the backoff before the first retry. It doubles for each
+// later one.
+const retryBaseDelay = 10 * time.Millisecond
+
+// retryPolicy bounds retries of work that fails because the database is
+// busy or locked.
+type retryPolicy struct {
+	attempts int
+	maxWait  time.Duration
+}
+
+func newRetryPolicy(cfg config.RetryConfig) retryPolicy {
+	if cfg.MaxAttempts <= 0 {
+		// Unset, as in configs built without config.Default.
+		return retryPolicy{attempts: config.DefaultRetryMaxAttempts, maxWait: config.DefaultRetryMaxWait}
+	}
+	return retryPolicy{attempts: cfg.MaxAttempts, maxWait: cfg.MaxWait}
+}
+
+// IsBusy reports whether err is SQLITE_BUSY or SQLITE_LOCKED, or one of
+// their extended codes: another connection held a lock the statement needed,
+// and the statement had no effect.
+func IsBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+	return false
+}
+
+// do runs fn until it succeeds, fails with an error IsBusy does not match,
+// or the policy's attempts or wait budget run out, and returns fn's last
+// error. Retries back off exponentially with jitter, and each one is counted
+// in the alyx_db_retries_total metric under op.
+//
+// fn must be safe to repeat: a single autocommit statement, or a whole
+// transaction. A statement that failed partway through a transaction is
+// never retried on its own, since the statements before it may only have
+// taken effect in a snapshot that is no longer current.
+func (p retryPolicy) do(ctx context.Context, op string, fn func() error) error {
+	var waited time.Duration
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.attempts || !IsBusy(err) {
+			return err
+		}
+
+		wait := min(delay/2+rand.N(delay/2+1), p.maxWait-waited)
+		if wait <= 0 {
+			return err
+		}
+		metrics.RecordDBRetry(op)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		waited += wait
+		delay *= 2
+	}
+}
@@ -0,0 +1,300 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+
+	"github.com/watzon/alyx/internal/tracing"
+)
+
+// StmtCache is a bounded LRU cache of prepared statements keyed by SQL text.
+//
+// Statements are prepared on the pool, and database/sql re-prepares them on
+// whichever connection runs them. Inside a transaction a cached statement is
+// bound to the transaction with Tx.StmtContext, which the transaction closes
+// when it ends; a miss runs unprepared, since preparing on the pool could wait
+// for the connection the transaction holds.
+type StmtCache struct {
+	db    *sql.DB
+	size  int
+	retry retryPolicy
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+// StmtCacheStats reports prepared statement cache usage.
+type StmtCacheStats struct {
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// NewStmtCache creates a statement cache holding up to size statements. A
+// size of zero disables caching.
+func NewStmtCache(db *sql.DB, size int) *StmtCache {
+	return &StmtCache{
+		db:      db,
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// acquire returns a prepared statement for query, preparing it on a miss. The
+// caller must release it. When prepare is false, only cached statements are
+// returned.
+func (c *StmtCache) acquire(ctx context.Context, query string, prepare bool) (*cachedStmt, error) {
+	if c.size <= 0 {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[query]; ok {
+		c.lru.MoveToFront(el)
+		cs := el.Value.(*cachedStmt)
+		cs.refs++
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return cs, nil
+	}
+	c.mu.Unlock()
+
+	c.misses.Add(1)
+	if !prepare {
+		return nil, nil
+	}
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another caller may have prepared the same statement concurrently.
+	if el, ok := c.entries[query]; ok {
+		stmt.Close()
+		c.lru.MoveToFront(el)
+		cs := el.Value.(*cachedStmt)
+		cs.refs++
+		return cs, nil
+	}
+
+	cs := &cachedStmt{query: query, stmt: stmt, refs: 1}
+	c.entries[query] = c.lru.PushFront(cs)
+	for c.lru.Len() > c.size {
+		c.evict(c.lru.Back())
+	}
+	return cs, nil
+}
+
+// release drops a reference taken by acquire. Rows and Row values keep their
+// own reference inside database/sql, so they stay valid after release even if
+// the statement was evicted.
+func (c *StmtCache) release(cs *cachedStmt) {
+	if cs == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cs.refs--
+	if cs.evicted && cs.refs == 0 {
+		cs.stmt.Close()
+	}
+}
+
+// evict removes an entry; the caller must hold c.mu. The statement is closed
+// once no caller is using it.
+func (c *StmtCache) evict(el *list.Element) {
+	cs := el.Value.(*cachedStmt)
+	c.lru.Remove(el)
+	delete(c.entries, cs.query)
+	cs.evicted = true
+	c.evictions.Add(1)
+	if cs.refs == 0 {
+		cs.stmt.Close()
+	}
+}
+
+// Purge closes every cached statement. Call it after the schema changes so
+// statements are prepared against the new tables.
+func (c *StmtCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.lru.Len() > 0 {
+		el := c.lru.Back()
+		cs := el.Value.(*cachedStmt)
+		c.lru.Remove(el)
+		delete(c.entries, cs.query)
+		cs.evicted = true
+		if cs.refs == 0 {
+			cs.stmt.Close()
+		}
+	}
+}
+
+// Stats returns cache usage counters.
+func (c *StmtCache) Stats() StmtCacheStats {
+	c.mu.Lock()
+	size := c.lru.Len()
+	c.mu.Unlock()
+
+	return StmtCacheStats{
+		Size:      size,
+		Capacity:  c.size,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+func (c *StmtCache) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := tracing.StartQuery(ctx, query)
+
+	cs, err := c.acquire(ctx, query, true)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	defer c.release(cs)
+
+	var result sql.Result
+	err = c.retry.do(ctx, "exec", func() (err error) {
+		if cs != nil {
+			result, err = cs.stmt.ExecContext(ctx, args...)
+		} else {
+			result, err = c.db.ExecContext(ctx, query, args...)
+		}
+		return err
+	})
+	tracing.End(span, err)
+	return result, err
+}
+
+func (c *StmtCache) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := tracing.StartQuery(ctx, query)
+
+	cs, err := c.acquire(ctx, query, true)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	defer c.release(cs)
+
+	var rows *sql.Rows
+	if cs != nil {
+		rows, err = cs.stmt.QueryContext(ctx, args...)
+	} else {
+		rows, err = c.db.QueryContext(ctx, query, args...)
+	}
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (c *StmtCache) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := tracing.StartQuery(ctx, query)
+
+	// A failed prepare still has to surface through Row.Scan, which the
+	// unprepared query does with the same error.
+	cs, err := c.acquire(ctx, query, true)
+	if err != nil {
+		row := c.db.QueryRowContext(ctx, query, args...)
+		tracing.End(span, row.Err())
+		return row
+	}
+	defer c.release(cs)
+
+	var row *sql.Row
+	if cs != nil {
+		row = cs.stmt.QueryRowContext(ctx, args...)
+	} else {
+		row = c.db.QueryRowContext(ctx, query, args...)
+	}
+	tracing.End(span, row.Err())
+	return row
+}
+
+// Tx returns an executor that runs queries in tx, using cached statements
+// where they already exist.
+func (c *StmtCache) Tx(tx *sql.Tx) *TxStmts {
+	return &TxStmts{cache: c, tx: tx}
+}
+
+// TxStmts runs queries in a transaction through a StmtCache.
+type TxStmts struct {
+	cache *StmtCache
+	tx    *sql.Tx
+}
+
+// stmt returns the transaction-bound form of a cached statement, or nil.
+func (t *TxStmts) stmt(ctx context.Context, query string) *sql.Stmt {
+	cs, _ := t.cache.acquire(ctx, query, false)
+	if cs == nil {
+		return nil
+	}
+	defer t.cache.release(cs)
+	return t.tx.StmtContext(ctx, cs.stmt)
+}
+
+func (t *TxStmts) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := tracing.StartQuery(ctx, query)
+
+	var result sql.Result
+	var err error
+	if stmt := t.stmt(ctx, query); stmt != nil {
+		result, err = stmt.ExecContext(ctx, args...)
+	} else {
+		result, err = t.tx.ExecContext(ctx, query, args...)
+	}
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *TxStmts) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := tracing.StartQuery(ctx, query)
+
+	var rows *sql.Rows
+	var err error
+	if stmt := t.stmt(ctx, query); stmt != nil {
+		rows, err = stmt.QueryContext(ctx, args...)
+	} else {
+		rows, err = t.tx.QueryContext(ctx, query, args...)
+	}
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (t *TxStmts) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := tracing.StartQuery(ctx, query)
+
+	var row *sql.Row
+	if stmt := t.stmt(ctx, query); stmt != nil {
+		row = stmt.QueryRowContext(ctx, args...)
+	} else {
+		row = t.tx.QueryRowContext(ctx, query, args...)
+	}
+	tracing.End(span, row.Err())
+	return row
+}
@@ -0,0 +1,248 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func stmtCacheDB(t testing.TB, size int) *DB {
+	t.Helper()
+
+	db, err := Open(&config.DatabaseConfig{
+		Path:          filepath.Join(t.TempDir(), "test.db"),
+		StmtCacheSize: size,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE items (id TEXT PRIMARY KEY, name TEXT)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	return db
+}
+
+func TestStmtCache_HitsAndEviction(t *testing.T) {
+	db := stmtCacheDB(t, 2)
+	ctx := context.Background()
+	stmts := db.Stmts()
+
+	if _, err := stmts.ExecContext(ctx, `INSERT INTO items (id, name) VALUES (?, ?)`, "a", "Alpha"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := stmts.ExecContext(ctx, `INSERT INTO items (id, name) VALUES (?, ?)`, "b", "Beta"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	var name string
+	if err := stmts.QueryRowContext(ctx, `SELECT name FROM items WHERE id = ?`, "b").Scan(&name); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if name != "Beta" {
+		t.Errorf("expected Beta, got %q", name)
+	}
+
+	stats := stmts.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Size != 2 {
+		t.Fatalf("unexpected stats after two statements: %+v", stats)
+	}
+
+	// A third statement evicts the least recently used one (the insert).
+	rows, err := stmts.QueryContext(ctx, `SELECT id FROM items ORDER BY id`)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	rows.Close()
+	if count != 2 {
+		t.Errorf("expected 2 rows, got %d", count)
+	}
+
+	stats = stmts.Stats()
+	if stats.Size != 2 || stats.Evictions != 1 {
+		t.Fatalf("expected one eviction with 2 cached, got %+v", stats)
+	}
+
+	if err := stmts.QueryRowContext(ctx, `SELECT name FROM items WHERE id = ?`, "a").Scan(&name); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if stmts.Stats().Hits != 2 {
+		t.Errorf("expected the select to still be cached, got %+v", stmts.Stats())
+	}
+
+	stmts.Purge()
+	if stmts.Stats().Size != 0 {
+		t.Errorf("expected empty cache after purge, got %+v", stmts.Stats())
+	}
+	if err := stmts.QueryRowContext(ctx, `SELECT name FROM items WHERE id = ?`, "a").Scan(&name); err != nil {
+		t.Fatalf("select after purge: %v", err)
+	}
+}
+
+func TestStmtCache_Disabled(t *testing.T) {
+	db := stmtCacheDB(t, 0)
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := db.Stmts().ExecContext(ctx, `INSERT OR REPLACE INTO items (id, name) VALUES (?, ?)`, "a", "Alpha"); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if stats := db.Stmts().Stats(); stats.Size != 0 || stats.Hits != 0 {
+		t.Errorf("expected no caching, got %+v", stats)
+	}
+}
+
+func TestStmtCache_PrepareError(t *testing.T) {
+	db := stmtCacheDB(t, 4)
+	ctx := context.Background()
+
+	var name string
+	if err := db.Stmts().QueryRowContext(ctx, `SELECT name FROM missing WHERE id = ?`, "a").Scan(&name); err == nil {
+		t.Fatal("expected error for missing table")
+	}
+	if _, err := db.Stmts().ExecContext(ctx, `DELETE FROM missing`); err == nil {
+		t.Fatal("expected error for missing table")
+	}
+	if db.Stmts().Stats().Size != 0 {
+		t.Errorf("failed statements should not be cached, got %+v", db.Stmts().Stats())
+	}
+}
+
+func TestStmtCache_Transaction(t *testing.T) {
+	db := stmtCacheDB(t, 4)
+	ctx := context.Background()
+
+	const insert = `INSERT INTO items (id, name) VALUES (?, ?)`
+	if _, err := db.Stmts().ExecContext(ctx, insert, "a", "Alpha"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	txStmts := db.Stmts().Tx(tx)
+
+	// A cached statement is bound to the transaction.
+	if _, err := txStmts.ExecContext(ctx, insert, "b", "Beta"); err != nil {
+		t.Fatalf("insert in tx: %v", err)
+	}
+	// An uncached statement runs directly on the transaction.
+	var count int
+	if err := txStmts.QueryRowContext(ctx, `SELECT COUNT(*) FROM items`).Scan(&count); err != nil {
+		t.Fatalf("count in tx: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 rows inside tx, got %d", count)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&count); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected rollback to leave 1 row, got %d", count)
+	}
+	if db.Stmts().Stats().Size != 1 {
+		t.Errorf("transaction misses should not be cached, got %+v", db.Stmts().Stats())
+	}
+}
+
+func TestCollection_UsesStmtCache(t *testing.T) {
+	db := stmtCacheDB(t, 16)
+	ctx := context.Background()
+
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  notes:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+`))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+
+	col := NewCollection(db, s.Collections["notes"])
+	created, err := col.Create(ctx, Row{"title": "a"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	id := fmt.Sprint(created["id"])
+
+	before := db.Stmts().Stats().Hits
+	for range 3 {
+		if _, err := col.FindOne(ctx, id); err != nil {
+			t.Fatalf("find: %v", err)
+		}
+	}
+	if hits := db.Stmts().Stats().Hits - before; hits < 3 {
+		t.Errorf("expected repeated FindOne to hit the cache, got %d hits", hits)
+	}
+
+	err = db.Transaction(ctx, func(tx *Tx) error {
+		txCtx := WithTransaction(ctx, tx.Tx)
+		_, err := col.Update(txCtx, id, Row{"title": "b"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("update in transaction: %v", err)
+	}
+
+	doc, err := col.FindOne(ctx, id)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if doc["title"] != "b" {
+		t.Errorf("expected title b, got %v", doc["title"])
+	}
+}
+
+// BenchmarkFindOne compares the get-by-id path with and without the
+// prepared statement cache.
+func BenchmarkFindOne(b *testing.B) {
+	for _, size := range []int{0, config.DefaultStmtCacheSize} {
+		b.Run(fmt.Sprintf("cache=%d", size), func(b *testing.B) {
+			db := stmtCacheDB(b, size)
+			ctx := context.Background()
+			col := NewCollection(db, &schema.Collection{
+				Name: "items",
+				Fields: map[string]*schema.Field{
+					"id":   {Name: "id", Type: schema.FieldTypeString, Primary: true},
+					"name": {Name: "name", Type: schema.FieldTypeString},
+				},
+			})
+			if _, err := db.Exec(`INSERT INTO items (id, name) VALUES ('a', 'Alpha')`); err != nil {
+				b.Fatal(err)
+			}
+
+			for b.Loop() {
+				if _, err := col.FindOne(ctx, "a"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -54,7 +54,7 @@ func ValidateInput(s *schema.Collection, data Row, isCreate bool) *ValidationErr
 			continue
 		}
 
-		if isCreate && !provided && !field.Nullable && !field.HasDefault() {
+		if isCreate && !provided && !s.OptionalOnCreate(field) {
 			errs.Add(field.Name, "required", fmt.Sprintf("Field '%s' is required", field.Name))
 			continue
 		}
@@ -69,6 +69,26 @@ func ValidateInput(s *schema.Collection, data Row, isCreate bool) *ValidationErr
 	return errs
 }
 
+// ValidateNulls checks the keys of a partial update set to null. Null clears
+// a nullable field; any other field rejects it. Absent keys are not checked,
+// since an update leaves them untouched.
+func ValidateNulls(s *schema.Collection, data Row) *ValidationErrors {
+	errs := &ValidationErrors{}
+
+	for _, field := range s.OrderedFields() {
+		value, provided := data[field.Name]
+		if !provided || value != nil {
+			continue
+		}
+		if field.Nullable || field.Primary || field.Internal || field.IsAutoUpdateTimestamp() {
+			continue
+		}
+		errs.Add(field.Name, "not_nullable", fmt.Sprintf("Field '%s' cannot be null", field.Name))
+	}
+
+	return errs
+}
+
 func validateFieldValue(field *schema.Field, value any, errs *ValidationErrors) {
 	switch field.Type {
 	case schema.FieldTypeString, schema.FieldTypeText, schema.FieldTypeRichText:
@@ -89,7 +109,13 @@ func validateFieldValue(field *schema.Field, value any, errs *ValidationErrors)
 		validateDate(field, value, errs)
 	case schema.FieldTypeSelect:
 		validateSelect(field, value, errs)
-	case schema.FieldTypeBool, schema.FieldTypeTimestamp, schema.FieldTypeJSON, schema.FieldTypeBlob:
+	case schema.FieldTypeBlob:
+		validateBlob(field, value, errs)
+	case schema.FieldTypeBool, schema.FieldTypeTimestamp, schema.FieldTypeJSON:
+	}
+
+	if field.Slug != nil {
+		validateSlug(field, value, errs)
 	}
 
 	if field.Validate != nil {
@@ -112,6 +138,22 @@ func validateString(field *schema.Field, value any, errs *ValidationErrors) {
 	}
 }
 
+// validateSlug checks an explicitly supplied slug. An empty string asks the
+// server to generate one, like omitting the field.
+func validateSlug(field *schema.Field, value any, errs *ValidationErrors) {
+	str, ok := toString(value)
+	if !ok || str == "" {
+		return
+	}
+	if !schema.IsValidSlug(str) {
+		errs.Add(field.Name, "invalid_slug", fmt.Sprintf("Field '%s' must contain only lowercase letters, numbers, and single hyphens", field.Name))
+		return
+	}
+	if max := field.Slug.Max(); len(str) > max {
+		errs.Add(field.Name, "max_length", fmt.Sprintf("Field '%s' must be at most %d characters", field.Name, max))
+	}
+}
+
 func validateInt(field *schema.Field, value any, errs *ValidationErrors) {
 	_, ok := toInt(value)
 	if !ok {
@@ -188,6 +230,20 @@ func validateDate(field *schema.Field, value any, errs *ValidationErrors) {
 	}
 }
 
+// validateBlob checks a blob field's JSON input, base64 encoded content.
+// Content stored in a bucket can only be written through the blob endpoint.
+func validateBlob(field *schema.Field, value any, errs *ValidationErrors) {
+	if field.Storage != "" {
+		errs.Add(field.Name, "invalid_type", fmt.Sprintf("Field '%s' can only be set to null; upload its content to /blob/%s", field.Name, field.Name))
+		return
+	}
+	switch value.(type) {
+	case string, []byte:
+	default:
+		errs.Add(field.Name, "invalid_type", fmt.Sprintf("Field '%s' must be a base64 encoded string", field.Name))
+	}
+}
+
 func validateSelect(field *schema.Field, value any, errs *ValidationErrors) {
 	if field.Select == nil {
 		return
@@ -0,0 +1,426 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// BundleContentType is the content type of a bundle archive uploaded to
+// POST /api/admin/deploy/execute.
+const BundleContentType = "application/x-alyx-bundle"
+
+// BundleFormat is the manifest format version written by Pack.
+const BundleFormat = 1
+
+// MaxBundleSize is the largest bundle archive the server accepts.
+const MaxBundleSize = 256 << 20
+
+// Paths inside a bundle archive.
+const (
+	bundleManifest   = "manifest.json"
+	bundleSchema     = "schema.yaml"
+	bundleFunctions  = "functions"
+	bundleMigrations = "migrations"
+)
+
+// ErrInvalidBundle is returned for an archive that is malformed or does not
+// match its own manifest.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// BundleManifest lists every file in a bundle archive with its hash.
+type BundleManifest struct {
+	Format    int       `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+	// SchemaHash is the hash of schema.yaml and FunctionsHash the combined
+	// hash of the files under functions/, as sent to deploy prepare.
+	SchemaHash    string          `json:"schema_hash"`
+	FunctionsHash string          `json:"functions_hash"`
+	Functions     []*FunctionInfo `json:"functions,omitempty"`
+	Files         []*BundleFile   `json:"files"`
+}
+
+// BundleFile is one file in a bundle archive.
+type BundleFile struct {
+	Path       string `json:"path"`
+	Hash       string `json:"hash"`
+	Size       int64  `json:"size"`
+	Executable bool   `json:"executable,omitempty"`
+}
+
+// BundleArchive is a bundle read and verified by ReadBundle.
+type BundleArchive struct {
+	Manifest *BundleManifest
+	// Hash is the SHA-256 of the archive itself.
+	Hash  string
+	Size  int64
+	files map[string][]byte
+}
+
+// SetMigrations sets the migrations directory packed by Pack.
+func (b *Bundler) SetMigrations(path string) {
+	b.migrationsPath = path
+}
+
+// Pack writes a bundle archive to w: the schema as a single schema.yaml (with
+// the environment overlay merged), the files each schema function runs from,
+// the migration files and a manifest with their hashes. A function with a
+// build output contributes only its entrypoint and that output; any other
+// function contributes its whole directory except hidden files.
+func (b *Bundler) Pack(w io.Writer) (*BundleManifest, error) {
+	schemaData, err := schema.ReadSourceWithEnv(b.schemaPath, b.env)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema: %w", err)
+	}
+	parsed, err := schema.Parse(schemaData)
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema: %w", err)
+	}
+
+	files := map[string][]byte{bundleSchema: schemaData}
+	executable := make(map[string]bool)
+
+	for _, name := range sortedNames(parsed.Functions) {
+		fn := parsed.Functions[name]
+		if fn.Path != "" {
+			return nil, fmt.Errorf("function %s sets path; bundles only carry functions in the functions directory", name)
+		}
+		dir := filepath.Join(b.functionsPath, name)
+		include := []string{"."}
+		if fn.Build != nil && fn.Build.Output != "" {
+			include = []string{fn.Entrypoint, fn.Build.Output}
+		}
+		for _, rel := range include {
+			if err := addTree(files, executable, filepath.Join(dir, rel), path.Join(bundleFunctions, name, filepath.ToSlash(rel))); err != nil {
+				return nil, fmt.Errorf("packing function %s: %w", name, err)
+			}
+		}
+	}
+
+	if b.migrationsPath != "" {
+		entries, err := os.ReadDir(b.migrationsPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("reading migrations directory: %w", err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || (!strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml")) {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(b.migrationsPath, name))
+			if err != nil {
+				return nil, fmt.Errorf("reading migration %s: %w", name, err)
+			}
+			files[path.Join(bundleMigrations, name)] = data
+		}
+	}
+
+	manifest := newManifest(parsed, files, executable)
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte, mode int64) error {
+		hdr := &tar.Header{Name: name, Mode: mode, Size: int64(len(data)), ModTime: manifest.CreatedAt, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := write(bundleManifest, manifestData, 0o644); err != nil {
+		return nil, fmt.Errorf("writing bundle: %w", err)
+	}
+	for _, f := range manifest.Files {
+		mode := int64(0o644)
+		if f.Executable {
+			mode = 0o755
+		}
+		if err := write(f.Path, files[f.Path], mode); err != nil {
+			return nil, fmt.Errorf("writing bundle: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("writing bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("writing bundle: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// addTree adds the file at src, or every file below it, to files under the
+// archive path dst. Hidden files and directories are skipped.
+func addTree(files map[string][]byte, executable map[string]bool, src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != src && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", p)
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name := path.Join(dst, filepath.ToSlash(rel))
+		files[name] = data
+		executable[name] = info.Mode()&0o111 != 0
+		return nil
+	})
+}
+
+// newManifest describes files, computing the schema and functions hashes and
+// a FunctionInfo for each function.
+func newManifest(s *schema.Schema, files map[string][]byte, executable map[string]bool) *BundleManifest {
+	manifest := &BundleManifest{
+		Format:    BundleFormat,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	for _, name := range sortedNames(files) {
+		manifest.Files = append(manifest.Files, &BundleFile{
+			Path:       name,
+			Hash:       hashBytes(files[name]),
+			Size:       int64(len(files[name])),
+			Executable: executable[name],
+		})
+	}
+	manifest.SchemaHash, manifest.FunctionsHash = manifestHashes(manifest.Files)
+
+	byFunction := make(map[string][]string)
+	sizes := make(map[string]int64)
+	for _, f := range manifest.Files {
+		name, ok := functionOf(f.Path)
+		if !ok {
+			continue
+		}
+		byFunction[name] = append(byFunction[name], f.Path+":"+f.Hash)
+		sizes[name] += f.Size
+	}
+	for _, name := range sortedNames(byFunction) {
+		info := &FunctionInfo{
+			Name: name,
+			Hash: hashString(strings.Join(byFunction[name], "|")),
+			Path: path.Join(bundleFunctions, name),
+			Size: sizes[name],
+		}
+		if fn := s.Functions[name]; fn != nil {
+			info.Runtime = fn.Runtime
+		}
+		manifest.Functions = append(manifest.Functions, info)
+	}
+
+	return manifest
+}
+
+// manifestHashes returns the schema hash and combined functions hash of a
+// manifest's files, which must be sorted by path.
+func manifestHashes(files []*BundleFile) (schemaHash, functionsHash string) {
+	var parts []string
+	for _, f := range files {
+		if f.Path == bundleSchema {
+			schemaHash = f.Hash
+		}
+		if _, ok := functionOf(f.Path); ok {
+			parts = append(parts, f.Path+":"+f.Hash)
+		}
+	}
+	if len(parts) > 0 {
+		functionsHash = hashString(strings.Join(parts, "|"))
+	}
+	return schemaHash, functionsHash
+}
+
+// functionOf returns the function an archive path belongs to.
+func functionOf(p string) (string, bool) {
+	rest, ok := strings.CutPrefix(p, bundleFunctions+"/")
+	if !ok {
+		return "", false
+	}
+	name, _, ok := strings.Cut(rest, "/")
+	return name, ok
+}
+
+// ReadBundle reads a bundle archive and verifies it against its manifest:
+// every file must be listed with its size and hash, every listed file must be
+// present, and the manifest's schema and functions hashes must match the
+// files. Errors wrap ErrInvalidBundle.
+func ReadBundle(data []byte) (*BundleArchive, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	tr := tar.NewReader(gz)
+
+	archive := &BundleArchive{
+		Hash:  hashBytes(data),
+		Size:  int64(len(data)),
+		files: make(map[string][]byte),
+	}
+	var manifestData []byte
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrInvalidBundle, hdr.Name)
+		}
+		if !validBundlePath(hdr.Name) {
+			return nil, fmt.Errorf("%w: unexpected path %s", ErrInvalidBundle, hdr.Name)
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, MaxBundleSize))
+		if err != nil {
+			return nil, fmt.Errorf("%w: reading %s: %v", ErrInvalidBundle, hdr.Name, err)
+		}
+		if hdr.Name == bundleManifest {
+			manifestData = content
+			continue
+		}
+		if _, dup := archive.files[hdr.Name]; dup {
+			return nil, fmt.Errorf("%w: %s appears twice", ErrInvalidBundle, hdr.Name)
+		}
+		archive.files[hdr.Name] = content
+	}
+
+	if manifestData == nil {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidBundle, bundleManifest)
+	}
+	var manifest BundleManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: parsing manifest: %v", ErrInvalidBundle, err)
+	}
+	if manifest.Format != BundleFormat {
+		return nil, fmt.Errorf("%w: unsupported format %d", ErrInvalidBundle, manifest.Format)
+	}
+
+	listed := make(map[string]bool, len(manifest.Files))
+	for _, f := range manifest.Files {
+		content, ok := archive.files[f.Path]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("%w: %s is listed but missing", ErrInvalidBundle, f.Path)
+		case listed[f.Path]:
+			return nil, fmt.Errorf("%w: %s is listed twice", ErrInvalidBundle, f.Path)
+		case int64(len(content)) != f.Size || hashBytes(content) != f.Hash:
+			return nil, fmt.Errorf("%w: %s does not match its manifest hash", ErrInvalidBundle, f.Path)
+		}
+		listed[f.Path] = true
+	}
+	for name := range archive.files {
+		if !listed[name] {
+			return nil, fmt.Errorf("%w: %s is not in the manifest", ErrInvalidBundle, name)
+		}
+	}
+	if _, ok := archive.files[bundleSchema]; !ok {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidBundle, bundleSchema)
+	}
+
+	sorted := append([]*BundleFile(nil), manifest.Files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	schemaHash, functionsHash := manifestHashes(sorted)
+	if schemaHash != manifest.SchemaHash || functionsHash != manifest.FunctionsHash {
+		return nil, fmt.Errorf("%w: manifest hashes do not match its files", ErrInvalidBundle)
+	}
+
+	archive.Manifest = &manifest
+	return archive, nil
+}
+
+// validBundlePath reports whether p is a clean relative path to the manifest,
+// the schema, or a file below functions/ or migrations/.
+func validBundlePath(p string) bool {
+	if p == bundleManifest || p == bundleSchema {
+		return true
+	}
+	if path.Clean(p) != p || path.IsAbs(p) || strings.HasPrefix(p, "../") {
+		return false
+	}
+	return strings.HasPrefix(p, bundleFunctions+"/") || strings.HasPrefix(p, bundleMigrations+"/")
+}
+
+// Schema returns the bundle's schema.yaml.
+func (a *BundleArchive) Schema() []byte {
+	return a.files[bundleSchema]
+}
+
+// Extract writes the bundle's files below dir, which must not exist yet.
+func (a *BundleArchive) Extract(dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return err
+	}
+	// Both always exist, so an empty functions directory can be swapped in
+	// and an empty migrations directory read.
+	for _, sub := range []string{bundleFunctions, bundleMigrations} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+	for _, f := range a.Manifest.Files {
+		target := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		mode := os.FileMode(0o644)
+		if f.Executable {
+			mode = 0o755
+		}
+		if err := os.WriteFile(target, a.files[f.Path], mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,220 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const bundleTestSchema = `version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+functions:
+  hello:
+    runtime: node
+    entrypoint: index.js
+`
+
+// writeProject writes a project with a schema, the hello function and the
+// given migration files, returning its directory.
+func writeProject(t *testing.T, schemaYAML, handler string, migrations map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"schema.yaml":                     schemaYAML,
+		"functions/hello/index.js":        handler,
+		"functions/hello/.cache/build":    "ignored",
+		"functions/hello/lib/greeting.js": "module.exports = 'hi';",
+	}
+	for name, content := range migrations {
+		files[filepath.Join("migrations", name)] = content
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func packProject(t *testing.T, dir string) ([]byte, *BundleManifest) {
+	t.Helper()
+	bundler := NewBundler(filepath.Join(dir, "schema.yaml"), filepath.Join(dir, "functions"))
+	bundler.SetMigrations(filepath.Join(dir, "migrations"))
+	var buf bytes.Buffer
+	manifest, err := bundler.Pack(&buf)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	return buf.Bytes(), manifest
+}
+
+// writeArchive writes a tar.gz with the given entries in order.
+func writeArchive(t *testing.T, entries ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e[0], Mode: 0o644, Size: int64(len(e[1])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBundlePackAndRead(t *testing.T) {
+	dir := writeProject(t, bundleTestSchema, "module.exports = () => 'v1';", map[string]string{
+		"001_seed.yaml": "version: 1\nname: seed\noperations: []\n",
+		"notes.txt":     "not a migration",
+	})
+
+	data, manifest := packProject(t, dir)
+
+	archive, err := ReadBundle(data)
+	if err != nil {
+		t.Fatalf("ReadBundle failed: %v", err)
+	}
+	if archive.Hash != hashBytes(data) || archive.Size != int64(len(data)) {
+		t.Errorf("archive hash/size = %s/%d, want hash of the data", archive.Hash, archive.Size)
+	}
+
+	var paths []string
+	for _, f := range archive.Manifest.Files {
+		paths = append(paths, f.Path)
+	}
+	want := []string{
+		"functions/hello/index.js",
+		"functions/hello/lib/greeting.js",
+		"migrations/001_seed.yaml",
+		"schema.yaml",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("files = %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("files = %v, want %v", paths, want)
+		}
+	}
+
+	if archive.Manifest.SchemaHash != manifest.SchemaHash || archive.Manifest.FunctionsHash != manifest.FunctionsHash {
+		t.Error("read manifest differs from the packed one")
+	}
+	if len(manifest.Functions) != 1 || manifest.Functions[0].Name != "hello" || manifest.Functions[0].Runtime != "node" {
+		t.Errorf("functions = %+v, want hello (node)", manifest.Functions)
+	}
+
+	// The schema hash is the same one a plain deploy computes, so bundles and
+	// plain deploys of the same project prepare identically.
+	bundle, err := NewBundler(filepath.Join(dir, "schema.yaml"), "").CreateBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.SchemaHash != manifest.SchemaHash {
+		t.Errorf("schema hash = %s, want %s", manifest.SchemaHash, bundle.SchemaHash)
+	}
+
+	out := filepath.Join(t.TempDir(), "release")
+	if err := archive.Extract(out); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(out, "functions", "hello", "index.js"))
+	if err != nil || string(got) != "module.exports = () => 'v1';" {
+		t.Errorf("extracted index.js = %q, %v", got, err)
+	}
+}
+
+func TestBundlePackRejectsFunctionPath(t *testing.T) {
+	dir := writeProject(t, bundleTestSchema+"    path: ./elsewhere\n", "", nil)
+
+	bundler := NewBundler(filepath.Join(dir, "schema.yaml"), filepath.Join(dir, "functions"))
+	if _, err := bundler.Pack(&bytes.Buffer{}); err == nil {
+		t.Fatal("expected Pack to reject a function with a path")
+	}
+}
+
+func TestReadBundleRejectsTampering(t *testing.T) {
+	dir := writeProject(t, bundleTestSchema, "module.exports = () => 'v1';", nil)
+	data, manifest := packProject(t, dir)
+	archive, err := ReadBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaData := string(archive.Schema())
+	handler := string(archive.files["functions/hello/index.js"])
+	helper := string(archive.files["functions/hello/lib/greeting.js"])
+
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{"not gzip", []byte("plain text")},
+		{"missing manifest", writeArchive(t, [2]string{"schema.yaml", schemaData})},
+		{"modified file", writeArchive(t,
+			[2]string{"manifest.json", string(manifestData)},
+			[2]string{"functions/hello/index.js", "module.exports = () => 'evil';"},
+			[2]string{"functions/hello/lib/greeting.js", helper},
+			[2]string{"schema.yaml", schemaData},
+		)},
+		{"missing file", writeArchive(t,
+			[2]string{"manifest.json", string(manifestData)},
+			[2]string{"functions/hello/index.js", handler},
+			[2]string{"schema.yaml", schemaData},
+		)},
+		{"unlisted file", writeArchive(t,
+			[2]string{"manifest.json", string(manifestData)},
+			[2]string{"functions/hello/index.js", handler},
+			[2]string{"functions/hello/lib/greeting.js", helper},
+			[2]string{"functions/hello/extra.js", "x"},
+			[2]string{"schema.yaml", schemaData},
+		)},
+		{"path traversal", writeArchive(t,
+			[2]string{"manifest.json", string(manifestData)},
+			[2]string{"functions/../../etc/passwd", "x"},
+		)},
+		{"unexpected top-level path", writeArchive(t,
+			[2]string{"manifest.json", string(manifestData)},
+			[2]string{"run.sh", "x"},
+		)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadBundle(tt.archive)
+			if !errors.Is(err, ErrInvalidBundle) {
+				t.Errorf("ReadBundle error = %v, want ErrInvalidBundle", err)
+			}
+		})
+	}
+}
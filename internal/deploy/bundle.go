@@ -16,8 +16,10 @@ import (
 
 // Bundler creates deployment bundles from local project files.
 type Bundler struct {
-	schemaPath    string
-	functionsPath string
+	schemaPath     string
+	functionsPath  string
+	migrationsPath string
+	env            string
 }
 
 // NewBundler creates a new bundler.
@@ -28,13 +30,18 @@ func NewBundler(schemaPath, functionsPath string) *Bundler {
 	}
 }
 
+// SetEnv selects the schema environment overlay to merge into the bundle.
+func (b *Bundler) SetEnv(env string) {
+	b.env = env
+}
+
 // CreateBundle creates a deployment bundle from local files.
 func (b *Bundler) CreateBundle() (*Bundle, error) {
 	bundle := &Bundle{}
 
 	// Load and hash schema
 	if b.schemaPath != "" {
-		schemaData, err := os.ReadFile(b.schemaPath)
+		schemaData, err := schema.ReadSourceWithEnv(b.schemaPath, b.env)
 		if err != nil {
 			if os.IsNotExist(err) {
 				return nil, fmt.Errorf("schema file not found: %s", b.schemaPath)
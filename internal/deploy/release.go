@@ -0,0 +1,291 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/functions"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// ErrBundleMismatch is returned when a bundle is not the deployment that was
+// prepared: its hashes differ from the prepared ones, or another deployment
+// became active in between.
+var ErrBundleMismatch = errors.New("bundle does not match the prepared deployment")
+
+// releasesDir is where bundle releases are extracted, next to the functions
+// directory. The functions directory becomes a symlink to the active
+// release's functions.
+const releasesDir = ".alyx/releases"
+
+// SetActivate sets the function that installs a deployed schema in the
+// running server and reloads its functions. Bundle deployments call it
+// before they are recorded and roll back if it fails.
+func (s *Service) SetActivate(fn func(s *schema.Schema, collections []string) error) {
+	s.activate = fn
+}
+
+// ExecuteBundle deploys a bundle archive. It verifies the archive against its
+// manifest and the manifest against the prepared hashes, then applies the
+// schema, applies the bundle's pending migrations in version order, swaps
+// the functions directory to the extracted release and activates the result.
+// If any step fails, the steps before it are undone: migrations are reverted
+// with their down SQL, schema changes that can be reversed safely are, and
+// the previous functions directory is swapped back.
+func (s *Service) ExecuteBundle(req *BundleExecuteRequest, deployedBy string) (*ExecuteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	archive, err := ReadBundle(req.Bundle)
+	if err != nil {
+		return nil, err
+	}
+	manifest := archive.Manifest
+	if manifest.SchemaHash != req.SchemaHash || manifest.FunctionsHash != req.FunctionsHash {
+		return nil, fmt.Errorf("%w: manifest hashes differ from the prepared ones", ErrBundleMismatch)
+	}
+
+	current, err := s.store.GetCurrentDeployment()
+	if err != nil {
+		return nil, fmt.Errorf("getting current deployment: %w", err)
+	}
+	var currentVersion string
+	if current != nil {
+		currentVersion = current.Version
+	}
+	if currentVersion != req.CurrentVersion {
+		return nil, fmt.Errorf("%w: prepared against %q, but %q is active", ErrBundleMismatch, req.CurrentVersion, currentVersion)
+	}
+
+	newSchema, err := schema.Parse(archive.Schema())
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema: %w", err)
+	}
+	if err := rules.Check(newSchema); err != nil {
+		return nil, fmt.Errorf("checking schema rules: %w", err)
+	}
+
+	nextVersion, err := s.store.NextVersion()
+	if err != nil {
+		return nil, fmt.Errorf("getting next version: %w", err)
+	}
+
+	release := s.releasePath(nextVersion)
+	if err := os.RemoveAll(release); err != nil {
+		return nil, fmt.Errorf("clearing release directory: %w", err)
+	}
+	if err := archive.Extract(release); err != nil {
+		_ = os.RemoveAll(release)
+		return nil, fmt.Errorf("extracting bundle: %w", err)
+	}
+	releaseFunctions := filepath.Join(release, bundleFunctions)
+	if _, err := functions.SchemaToFunctionDefs(newSchema, releaseFunctions); err != nil {
+		_ = os.RemoveAll(release)
+		return nil, fmt.Errorf("checking bundle functions: %w", err)
+	}
+
+	// From here on every step registers how to undo itself.
+	var undo []func() error
+	fail := func(err error) (*ExecuteResponse, error) {
+		for i := len(undo) - 1; i >= 0; i-- {
+			if undoErr := undo[i](); undoErr != nil {
+				log.Error().Err(undoErr).Str("version", nextVersion).Msg("Failed to undo bundle deployment step")
+			}
+		}
+		_ = os.RemoveAll(release)
+		return nil, err
+	}
+
+	currentSchema := s.getCurrentSchema(current)
+	changed, err := s.applySchemaChanges(currentSchema, newSchema)
+	undo = append(undo, func() error {
+		if currentSchema == nil {
+			return nil
+		}
+		_, err := s.applySchemaChanges(newSchema, currentSchema)
+		return err
+	})
+	if err != nil {
+		return fail(fmt.Errorf("applying schema changes: %w", err))
+	}
+
+	migrator := schema.NewMigrator(s.db, "", filepath.Join(release, bundleMigrations))
+	pending, err := migrator.PendingMigrations()
+	if err != nil {
+		return fail(fmt.Errorf("reading bundle migrations: %w", err))
+	}
+	for _, mig := range pending {
+		if err := migrator.Apply(mig); err != nil {
+			return fail(fmt.Errorf("applying migration %d (%s): %w", mig.Version, mig.Name, err))
+		}
+		undo = append(undo, func() error { return migrator.Revert(mig) })
+	}
+
+	// Registered before the functions switch so that, when undoing, the
+	// previous schema is reactivated after its functions directory is back.
+	activated := false
+	undo = append(undo, func() error {
+		if !activated || currentSchema == nil {
+			return nil
+		}
+		return s.activate(currentSchema, changed)
+	})
+
+	if s.functionsPath != "" {
+		restore, err := s.switchFunctions(releaseFunctions)
+		if err != nil {
+			return fail(fmt.Errorf("switching functions: %w", err))
+		}
+		undo = append(undo, restore)
+	}
+
+	if s.activate != nil {
+		activated = true
+		if err := s.activate(newSchema, changed); err != nil {
+			return fail(fmt.Errorf("activating deployment: %w", err))
+		}
+	}
+
+	funcsSnapshot, err := SerializeFunctions(manifest.Functions)
+	if err != nil {
+		return fail(fmt.Errorf("serializing functions: %w", err))
+	}
+	if current != nil {
+		if err := s.store.UpdateDeploymentStatus(current.Version, StatusRolledBack, nextVersion); err != nil {
+			return fail(fmt.Errorf("deactivating current deployment: %w", err))
+		}
+		undo = append(undo, func() error {
+			return s.store.UpdateDeploymentStatus(current.Version, StatusActive, "")
+		})
+	}
+	deployment := &Deployment{
+		Version:           nextVersion,
+		SchemaHash:        manifest.SchemaHash,
+		FunctionsHash:     manifest.FunctionsHash,
+		SchemaSnapshot:    string(archive.Schema()),
+		FunctionsSnapshot: funcsSnapshot,
+		DeployedBy:        deployedBy,
+		Status:            StatusActive,
+		Description:       req.Description,
+		BundleHash:        archive.Hash,
+		BundleSize:        archive.Size,
+	}
+	if err := s.store.CreateDeployment(deployment); err != nil {
+		return fail(fmt.Errorf("creating deployment record: %w", err))
+	}
+
+	log.Info().
+		Str("version", nextVersion).
+		Str("deployed_by", deployedBy).
+		Str("bundle_hash", archive.Hash).
+		Int64("bundle_size", archive.Size).
+		Int("migrations", len(pending)).
+		Msg("Bundle deployment completed successfully")
+
+	return &ExecuteResponse{
+		Success:     true,
+		Version:     nextVersion,
+		Message:     fmt.Sprintf("Deployed version %s successfully", nextVersion),
+		RollbackCmd: fmt.Sprintf("alyx deploy --rollback %s", nextVersion),
+		Collections: changed,
+		Schema:      newSchema,
+		Activated:   activated,
+	}, nil
+}
+
+// releasePath returns the directory a version's bundle is extracted to.
+func (s *Service) releasePath(version string) string {
+	return filepath.Join(filepath.Dir(filepath.Clean(s.functionsPath)), releasesDir, version)
+}
+
+// switchFunctions points the functions directory at target and returns how
+// to point it back. The functions directory is a symlink replaced with a
+// rename, so it is never missing or half written. A plain directory, from
+// before the first bundle deployment, is moved into the releases directory
+// first.
+func (s *Service) switchFunctions(target string) (func() error, error) {
+	link := filepath.Clean(s.functionsPath)
+	info, err := os.Lstat(link)
+	switch {
+	case os.IsNotExist(err):
+		if err := swapSymlink(link, target); err != nil {
+			return nil, err
+		}
+		return func() error { return os.Remove(link) }, nil
+	case err != nil:
+		return nil, err
+	case info.Mode()&os.ModeSymlink != 0:
+		previous, err := os.Readlink(link)
+		if err != nil {
+			return nil, err
+		}
+		if !filepath.IsAbs(previous) {
+			previous = filepath.Join(filepath.Dir(link), previous)
+		}
+		if err := swapSymlink(link, target); err != nil {
+			return nil, err
+		}
+		return func() error { return swapSymlink(link, previous) }, nil
+	case info.IsDir():
+		moved := filepath.Join(filepath.Dir(link), releasesDir, "initial")
+		if _, err := os.Stat(moved); err == nil {
+			return nil, fmt.Errorf("%s already exists", moved)
+		}
+		if err := os.Rename(link, moved); err != nil {
+			return nil, fmt.Errorf("moving functions directory: %w", err)
+		}
+		if err := swapSymlink(link, target); err != nil {
+			_ = os.Rename(moved, link)
+			return nil, err
+		}
+		return func() error {
+			if err := os.Remove(link); err != nil {
+				return err
+			}
+			return os.Rename(moved, link)
+		}, nil
+	default:
+		return nil, fmt.Errorf("%s is not a directory", link)
+	}
+}
+
+// swapSymlink atomically makes link a symlink to target, relative to link's
+// directory when possible.
+func swapSymlink(link, target string) error {
+	if rel, err := filepath.Rel(filepath.Dir(link), target); err == nil {
+		target = rel
+	}
+	tmp := link + ".next"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("creating functions symlink: %w", err)
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("replacing functions symlink: %w", err)
+	}
+	return nil
+}
+
+// restoreRelease points the functions directory back at the release of a
+// bundle deployment being rolled back to. It reports false, doing nothing,
+// for deployments that were not bundles or whose release is gone.
+func (s *Service) restoreRelease(target *Deployment) (bool, error) {
+	if target.BundleHash == "" || s.functionsPath == "" {
+		return false, nil
+	}
+	releaseFunctions := filepath.Join(s.releasePath(target.Version), bundleFunctions)
+	if _, err := os.Stat(releaseFunctions); err != nil {
+		log.Warn().Str("version", target.Version).Msg("Release directory not found; functions were not rolled back")
+		return false, nil
+	}
+	if _, err := s.switchFunctions(releaseFunctions); err != nil {
+		return false, err
+	}
+	return true, nil
+}
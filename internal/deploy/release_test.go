@@ -0,0 +1,195 @@
+package deploy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func testService(t *testing.T, functionsPath string) *Service {
+	t.Helper()
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	svc := NewService(db.DB, "", functionsPath, "")
+	if err := svc.Init(); err != nil {
+		t.Fatalf("failed to init service: %v", err)
+	}
+	return svc
+}
+
+func bundleRequest(data []byte, manifest *BundleManifest, currentVersion string) *BundleExecuteRequest {
+	return &BundleExecuteRequest{
+		Bundle:         data,
+		SchemaHash:     manifest.SchemaHash,
+		FunctionsHash:  manifest.FunctionsHash,
+		CurrentVersion: currentVersion,
+	}
+}
+
+func readHandler(t *testing.T, functionsPath string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(functionsPath, "hello", "index.js"))
+	if err != nil {
+		t.Fatalf("reading deployed handler: %v", err)
+	}
+	return string(data)
+}
+
+func tableExists(t *testing.T, svc *Service, name string) bool {
+	t.Helper()
+	var count int
+	if err := svc.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func TestExecuteBundle(t *testing.T) {
+	root := t.TempDir()
+	functionsPath := filepath.Join(root, "functions")
+	if err := os.MkdirAll(filepath.Join(functionsPath, "hello"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(functionsPath, "hello", "index.js"), []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	svc := testService(t, functionsPath)
+
+	var activated []*schema.Schema
+	svc.SetActivate(func(s *schema.Schema, _ []string) error {
+		activated = append(activated, s)
+		return nil
+	})
+
+	v1, v1Manifest := packProject(t, writeProject(t, bundleTestSchema, "v1", map[string]string{
+		"001_extra.yaml": `version: 1
+name: extra
+operations:
+  - type: sql
+    up: CREATE TABLE extra (id INTEGER PRIMARY KEY)
+    down: DROP TABLE extra
+`,
+	}))
+	resp, err := svc.ExecuteBundle(bundleRequest(v1, v1Manifest, ""), "test")
+	if err != nil {
+		t.Fatalf("ExecuteBundle failed: %v", err)
+	}
+	if resp.Version != "v1" || !resp.Activated || len(activated) != 1 {
+		t.Fatalf("response = %+v, activations = %d", resp, len(activated))
+	}
+	if got := readHandler(t, functionsPath); got != "v1" {
+		t.Errorf("deployed handler = %q, want v1", got)
+	}
+	if !tableExists(t, svc, "posts") || !tableExists(t, svc, "extra") {
+		t.Error("expected the schema and migration tables to exist")
+	}
+	// The original directory is kept for rolling back past the first bundle.
+	if data, err := os.ReadFile(filepath.Join(root, releasesDir, "initial", "hello", "index.js")); err != nil || string(data) != "original" {
+		t.Errorf("initial functions = %q, %v", data, err)
+	}
+
+	current, err := svc.store.GetCurrentDeployment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.BundleHash != hashBytes(v1) || current.BundleSize != int64(len(v1)) {
+		t.Errorf("deployment bundle = %s/%d, want the v1 archive", current.BundleHash, current.BundleSize)
+	}
+
+	v2, v2Manifest := packProject(t, writeProject(t, bundleTestSchema, "v2", nil))
+
+	t.Run("rejects a stale prepare", func(t *testing.T) {
+		_, err := svc.ExecuteBundle(bundleRequest(v2, v2Manifest, ""), "test")
+		if !errors.Is(err, ErrBundleMismatch) {
+			t.Errorf("error = %v, want ErrBundleMismatch", err)
+		}
+	})
+
+	t.Run("rejects hashes that were not prepared", func(t *testing.T) {
+		req := bundleRequest(v2, v2Manifest, "v1")
+		req.FunctionsHash = v1Manifest.FunctionsHash
+		_, err := svc.ExecuteBundle(req, "test")
+		if !errors.Is(err, ErrBundleMismatch) {
+			t.Errorf("error = %v, want ErrBundleMismatch", err)
+		}
+	})
+
+	t.Run("undoes everything when a step fails", func(t *testing.T) {
+		broken, brokenManifest := packProject(t, writeProject(t, bundleTestSchema, "broken", map[string]string{
+			"002_more.yaml": `version: 2
+name: more
+operations:
+  - type: sql
+    up: CREATE TABLE more (id INTEGER PRIMARY KEY)
+    down: DROP TABLE more
+`,
+			"003_bad.yaml": `version: 3
+name: bad
+operations:
+  - type: sql
+    up: NOT VALID SQL
+    down: SELECT 1
+`,
+		}))
+		_, err := svc.ExecuteBundle(bundleRequest(broken, brokenManifest, "v1"), "test")
+		if err == nil || !strings.Contains(err.Error(), "migration 3") {
+			t.Fatalf("error = %v, want a migration 3 failure", err)
+		}
+		if tableExists(t, svc, "more") {
+			t.Error("migration 2 was not reverted")
+		}
+		if got := readHandler(t, functionsPath); got != "v1" {
+			t.Errorf("deployed handler = %q, want v1", got)
+		}
+		if _, err := os.Stat(svc.releasePath("v2")); !os.IsNotExist(err) {
+			t.Errorf("failed release was not removed: %v", err)
+		}
+		current, err := svc.store.GetCurrentDeployment()
+		if err != nil || current.Version != "v1" {
+			t.Errorf("current deployment = %+v, %v; want v1", current, err)
+		}
+	})
+
+	t.Run("rolls back failed activation", func(t *testing.T) {
+		svc.SetActivate(func(s *schema.Schema, _ []string) error {
+			return errors.New("reload failed")
+		})
+		defer svc.SetActivate(func(s *schema.Schema, _ []string) error { return nil })
+
+		if _, err := svc.ExecuteBundle(bundleRequest(v2, v2Manifest, "v1"), "test"); err == nil {
+			t.Fatal("expected activation failure")
+		}
+		if got := readHandler(t, functionsPath); got != "v1" {
+			t.Errorf("deployed handler = %q, want v1", got)
+		}
+	})
+
+	resp, err = svc.ExecuteBundle(bundleRequest(v2, v2Manifest, "v1"), "test")
+	if err != nil {
+		t.Fatalf("ExecuteBundle v2 failed: %v", err)
+	}
+	if got := readHandler(t, functionsPath); got != "v2" {
+		t.Errorf("deployed handler = %q, want v2", got)
+	}
+
+	rollback, err := svc.Rollback(&RollbackRequest{ToVersion: "v1"}, "test")
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if !rollback.FunctionsRestored {
+		t.Error("expected the rollback to restore functions")
+	}
+	if got := readHandler(t, functionsPath); got != "v1" {
+		t.Errorf("handler after rollback = %q, want v1", got)
+	}
+}
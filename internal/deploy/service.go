@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/watzon/alyx/internal/rules"
 	"github.com/watzon/alyx/internal/schema"
 )
 
@@ -18,6 +21,12 @@ type Service struct {
 	schemaPath    string
 	functionsPath string
 	migrator      *schema.Migrator
+	replays       replayCache
+
+	// mu serializes bundle deployments and rollbacks, which swap the
+	// functions directory.
+	mu       sync.Mutex
+	activate func(s *schema.Schema, collections []string) error
 }
 
 // NewService creates a new deployment service.
@@ -45,6 +54,12 @@ func (s *Service) Init() error {
 	return nil
 }
 
+// SetKeyCipher sets the cipher the signing keys of signing tokens are sealed
+// with. Without one they are stored unencrypted.
+func (s *Service) SetKeyCipher(c KeyCipher) {
+	s.store.cipher = c
+}
+
 // Store returns the deployment store.
 func (s *Service) Store() *Store {
 	return s.store
@@ -52,7 +67,10 @@ func (s *Service) Store() *Store {
 
 // Prepare analyzes incoming deployment and returns required changes.
 func (s *Service) Prepare(req *PrepareRequest) (*PrepareResponse, error) {
-	resp := &PrepareResponse{}
+	resp := &PrepareResponse{
+		SchemaHash:    req.SchemaHash,
+		FunctionsHash: req.FunctionsHash,
+	}
 
 	current, err := s.store.GetCurrentDeployment()
 	if err != nil {
@@ -93,7 +111,7 @@ func (s *Service) analyzeSchemaChanges(current *Deployment, resp *PrepareRespons
 		return
 	}
 
-	newSchemaData, readErr := os.ReadFile(s.schemaPath)
+	newSchemaData, readErr := schema.ReadSource(s.schemaPath)
 	if readErr != nil {
 		return
 	}
@@ -143,12 +161,6 @@ func (s *Service) analyzeFunctionChanges(current *Deployment, functions []*Funct
 
 // Execute performs the deployment.
 func (s *Service) Execute(req *ExecuteRequest, deployedBy string) (*ExecuteResponse, error) {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return nil, fmt.Errorf("beginning transaction: %w", err)
-	}
-	defer func() { _ = tx.Rollback() }()
-
 	current, err := s.store.GetCurrentDeployment()
 	if err != nil {
 		return nil, fmt.Errorf("getting current deployment: %w", err)
@@ -163,8 +175,12 @@ func (s *Service) Execute(req *ExecuteRequest, deployedBy string) (*ExecuteRespo
 	if err != nil {
 		return nil, fmt.Errorf("parsing schema: %w", err)
 	}
+	if err := rules.Check(newSchema); err != nil {
+		return nil, fmt.Errorf("checking schema rules: %w", err)
+	}
 
-	if applyErr := s.applySchemaChanges(current, newSchema); applyErr != nil {
+	changed, applyErr := s.applySchemaChanges(s.getCurrentSchema(current), newSchema)
+	if applyErr != nil {
 		return nil, fmt.Errorf("applying schema changes: %w", applyErr)
 	}
 
@@ -201,11 +217,6 @@ func (s *Service) Execute(req *ExecuteRequest, deployedBy string) (*ExecuteRespo
 		return nil, fmt.Errorf("creating deployment record: %w", err)
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("committing deployment: %w", err)
-	}
-
 	log.Info().
 		Str("version", nextVersion).
 		Str("deployed_by", deployedBy).
@@ -216,11 +227,17 @@ func (s *Service) Execute(req *ExecuteRequest, deployedBy string) (*ExecuteRespo
 		Version:     nextVersion,
 		Message:     fmt.Sprintf("Deployed version %s successfully", nextVersion),
 		RollbackCmd: fmt.Sprintf("alyx deploy --rollback %s", nextVersion),
+		Collections: changed,
+		Schema:      newSchema,
 	}, nil
 }
 
-// Rollback reverts to a previous deployment.
+// Rollback reverts to a previous deployment. Rolling back to a bundle
+// deployment also switches the functions directory back to its release.
 func (s *Service) Rollback(req *RollbackRequest, rolledBackBy string) (*RollbackResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Get current deployment
 	current, err := s.store.GetCurrentDeployment()
 	if err != nil {
@@ -251,23 +268,32 @@ func (s *Service) Rollback(req *RollbackRequest, rolledBackBy string) (*Rollback
 		return nil, fmt.Errorf("target deployment %s not found", targetVersion)
 	}
 
-	// Start transaction
-	tx, err := s.db.Begin()
-	if err != nil {
-		return nil, fmt.Errorf("beginning transaction: %w", err)
-	}
-	defer func() { _ = tx.Rollback() }()
-
 	// Parse target schema
 	targetSchema, err := schema.Parse([]byte(target.SchemaSnapshot))
 	if err != nil {
 		return nil, fmt.Errorf("parsing target schema: %w", err)
 	}
+	if err := rules.Check(targetSchema); err != nil {
+		return nil, fmt.Errorf("checking target schema rules: %w", err)
+	}
 
-	if applyErr := s.applySchemaChanges(current, targetSchema); applyErr != nil {
+	changed, applyErr := s.applySchemaChanges(s.getCurrentSchema(current), targetSchema)
+	if applyErr != nil {
 		return nil, fmt.Errorf("applying schema rollback: %w", applyErr)
 	}
 
+	restored, err := s.restoreRelease(target)
+	if err != nil {
+		return nil, fmt.Errorf("restoring functions: %w", err)
+	}
+	activated := false
+	if restored && s.activate != nil {
+		activated = true
+		if err := s.activate(targetSchema, changed); err != nil {
+			return nil, fmt.Errorf("activating rolled back deployment: %w", err)
+		}
+	}
+
 	if statusErr := s.store.UpdateDeploymentStatus(current.Version, StatusRolledBack, targetVersion); statusErr != nil {
 		return nil, fmt.Errorf("marking current as rolled back: %w", statusErr)
 	}
@@ -288,16 +314,14 @@ func (s *Service) Rollback(req *RollbackRequest, rolledBackBy string) (*Rollback
 		Status:            StatusActive,
 		RollbackTo:        targetVersion,
 		Description:       fmt.Sprintf("Rollback to %s: %s", targetVersion, req.Reason),
+		BundleHash:        target.BundleHash,
+		BundleSize:        target.BundleSize,
 	}
 
 	if err := s.store.CreateDeployment(rollbackDeployment); err != nil {
 		return nil, fmt.Errorf("creating rollback deployment: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("committing rollback: %w", err)
-	}
-
 	log.Info().
 		Str("from_version", current.Version).
 		Str("to_version", targetVersion).
@@ -306,10 +330,14 @@ func (s *Service) Rollback(req *RollbackRequest, rolledBackBy string) (*Rollback
 		Msg("Rollback completed successfully")
 
 	return &RollbackResponse{
-		Success:        true,
-		RolledBackFrom: current.Version,
-		RolledBackTo:   targetVersion,
-		Message:        fmt.Sprintf("Rolled back from %s to %s (new version: %s)", current.Version, targetVersion, nextVersion),
+		Success:           true,
+		RolledBackFrom:    current.Version,
+		RolledBackTo:      targetVersion,
+		Message:           fmt.Sprintf("Rolled back from %s to %s (new version: %s)", current.Version, targetVersion, nextVersion),
+		Collections:       changed,
+		FunctionsRestored: restored,
+		Schema:            targetSchema,
+		Activated:         activated,
 	}, nil
 }
 
@@ -344,14 +372,19 @@ func (s *Service) getCurrentSchema(current *Deployment) *schema.Schema {
 	return parsed
 }
 
-func (s *Service) applySchemaChanges(current *Deployment, newSchema *schema.Schema) error {
-	if current == nil {
-		return s.migrator.ApplySchema(newSchema)
-	}
-
-	currentSchema := s.getCurrentSchema(current)
+// applySchemaChanges migrates the database from currentSchema, nil if there
+// is none, to newSchema and returns the names of the collections that changed.
+func (s *Service) applySchemaChanges(currentSchema, newSchema *schema.Schema) ([]string, error) {
 	if currentSchema == nil {
-		return s.migrator.ApplySchema(newSchema)
+		if err := s.migrator.ApplySchema(newSchema); err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(newSchema.Collections))
+		for name := range newSchema.Collections {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names, nil
 	}
 
 	differ := schema.NewDiffer()
@@ -360,11 +393,11 @@ func (s *Service) applySchemaChanges(current *Deployment, newSchema *schema.Sche
 	safeChanges := differ.SafeChanges(changes)
 	if len(safeChanges) > 0 {
 		if err := s.migrator.ApplySafeChanges(safeChanges, newSchema); err != nil {
-			return fmt.Errorf("applying safe changes: %w", err)
+			return nil, fmt.Errorf("applying safe changes: %w", err)
 		}
 	}
 
-	return nil
+	return schema.ChangedCollections(changes), nil
 }
 
 // applyFunctionChanges deploys function files.
@@ -417,17 +450,40 @@ func (s *Service) ValidateToken(token string) (*AdminToken, error) {
 
 // CreateToken creates a new admin token.
 func (s *Service) CreateToken(req *CreateTokenRequest, createdBy string) (*CreateTokenResponse, error) {
-	token, err := s.store.CreateToken(req.Name, req.Permissions, req.ExpiresAt, createdBy)
+	create := s.store.CreateToken
+	if req.RequireSigning {
+		create = s.store.CreateSigningToken
+	}
+	token, err := create(req.Name, req.Permissions, req.ExpiresAt, createdBy)
 	if err != nil {
 		return nil, fmt.Errorf("creating token: %w", err)
 	}
 
 	return &CreateTokenResponse{
-		Token:       token,
-		Name:        req.Name,
-		Permissions: req.Permissions,
-		ExpiresAt:   req.ExpiresAt,
-		Message:     "Token created successfully. Store it securely - it cannot be retrieved again.",
+		Token:          token,
+		Name:           req.Name,
+		Permissions:    req.Permissions,
+		ExpiresAt:      req.ExpiresAt,
+		RequireSigning: req.RequireSigning,
+		Message:        "Token created successfully. Store it securely - it cannot be retrieved again.",
+	}, nil
+}
+
+// RotateToken issues a new secret for an existing token, keeping its
+// permissions and expiry.
+func (s *Service) RotateToken(name string) (*CreateTokenResponse, error) {
+	token, t, err := s.store.RotateToken(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateTokenResponse{
+		Token:          token,
+		Name:           t.Name,
+		Permissions:    t.Permissions,
+		ExpiresAt:      t.ExpiresAt,
+		RequireSigning: t.RequireSigning,
+		Message:        "Token rotated successfully. The previous secret no longer works. Store the new one securely - it cannot be retrieved again.",
 	}, nil
 }
 
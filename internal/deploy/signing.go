@@ -0,0 +1,190 @@
+package deploy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signed request headers.
+const (
+	TimestampHeader = "X-Alyx-Timestamp"
+	SignatureHeader = "X-Alyx-Signature"
+)
+
+// SigningTokenPrefix starts the secret of every token created with
+// require_signing. The secret has the form alyxs_<key ID>_<secret>; signed
+// requests send only alyxs_<key ID> as their bearer token.
+const SigningTokenPrefix = "alyxs_"
+
+// signingKeyLabel is the message a signing token's secret is keyed with to
+// derive its signing key.
+const signingKeyLabel = "alyx request signing"
+
+// SignatureTolerance is how far a signed request's timestamp may be from the
+// server's clock, in either direction.
+const SignatureTolerance = 5 * time.Minute
+
+// Signed request errors, returned by ValidateSignedRequest and, for a signing
+// token presented without a signature, ValidateToken.
+var (
+	ErrSignatureRequired = errors.New("token requires signed requests")
+	ErrSignatureExpired  = errors.New("request timestamp is outside the allowed clock skew")
+	ErrInvalidSignature  = errors.New("invalid request signature")
+	ErrSignatureReplayed = errors.New("request signature was already used")
+)
+
+// IsSigningToken reports whether token is the secret of a token that
+// requires signed requests.
+func IsSigningToken(token string) bool {
+	keyID, secret, ok := splitSigningToken(token)
+	return ok && keyID != "" && secret != ""
+}
+
+// SigningKeyID returns the key ID a signed request names its token by, and
+// whether bearer is one rather than a token secret.
+func SigningKeyID(bearer string) (string, bool) {
+	keyID, secret, ok := splitSigningToken(bearer)
+	if !ok || keyID == "" || secret != "" {
+		return "", false
+	}
+	return keyID, true
+}
+
+// SignRequest signs req with a signing token, replacing its Authorization
+// header with the token's key ID. body must be the request body as sent.
+//
+// The signature is the hex HMAC-SHA256, keyed with the token's signing key,
+// of the method, request URI, timestamp and hex SHA-256 of the body, joined
+// by newlines. The signing key is the hex HMAC-SHA256 of "alyx request
+// signing" keyed with the token.
+func SignRequest(req *http.Request, token string, body []byte, now time.Time) error {
+	if !IsSigningToken(token) {
+		return errors.New("not a signing token")
+	}
+	keyID, _, _ := splitSigningToken(token)
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Authorization", "Bearer "+SigningTokenPrefix+keyID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, requestSignature(signingKey(token), req.Method, req.URL.RequestURI(), timestamp, body))
+	return nil
+}
+
+// ValidateSignedRequest authenticates a signed request naming its token by
+// keyID. The signature is checked before the timestamp, so a stale request
+// with a forged signature reports ErrInvalidSignature, and each signature is
+// accepted once.
+func (s *Service) ValidateSignedRequest(keyID string, r *http.Request, body []byte) (*AdminToken, error) {
+	timestamp := r.Header.Get(TimestampHeader)
+	signature := r.Header.Get(SignatureHeader)
+	if timestamp == "" || signature == "" {
+		return nil, fmt.Errorf("%w: missing %s or %s header", ErrSignatureRequired, TimestampHeader, SignatureHeader)
+	}
+
+	t, err := s.store.TokenByKeyID(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.store.storedSigningKey(t)
+	if err != nil {
+		return nil, err
+	}
+	expected := requestSignature(key, r.Method, r.URL.RequestURI(), timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return nil, ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	now := time.Now()
+	signedAt := time.Unix(unix, 0)
+	if signedAt.Before(now.Add(-SignatureTolerance)) || signedAt.After(now.Add(SignatureTolerance)) {
+		return nil, ErrSignatureExpired
+	}
+
+	if !s.replays.add(expected, signedAt.Add(SignatureTolerance), now) {
+		return nil, ErrSignatureReplayed
+	}
+
+	s.store.touchToken(t)
+	return t, nil
+}
+
+// signingKey derives the key requests are signed with from a signing
+// token's secret. It differs from the token's stored digest, so reading
+// token_hash is not enough to sign requests.
+func signingKey(token string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(signingKeyLabel))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func requestSignature(key, method, requestURI, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, requestURI, timestamp, hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// splitSigningToken splits alyxs_<key ID>[_<secret>] into its parts.
+func splitSigningToken(token string) (keyID, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(token, SigningTokenPrefix)
+	if !ok {
+		return "", "", false
+	}
+	keyID, secret, _ = strings.Cut(rest, "_")
+	return keyID, secret, true
+}
+
+func signingToken(keyID, secret string) string {
+	return SigningTokenPrefix + keyID + "_" + secret
+}
+
+// generateKeyID returns a new random signing token key ID.
+func generateKeyID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating key ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// replayCache remembers signatures until their timestamp falls outside the
+// clock skew tolerance, after which ValidateSignedRequest rejects them
+// anyway.
+type replayCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// add records signature until expires and reports whether it was new.
+func (c *replayCache) add(signature string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.seen == nil {
+		c.seen = make(map[string]time.Time)
+	}
+	for sig, exp := range c.seen {
+		if !exp.After(now) {
+			delete(c.seen, sig)
+		}
+	}
+	if _, ok := c.seen[signature]; ok {
+		return false
+	}
+	c.seen[signature] = expires
+	return true
+}
@@ -0,0 +1,178 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/database"
+)
+
+func TestSignedRequests(t *testing.T) {
+	store := testStore(t)
+	svc := &Service{store: store}
+
+	secret, err := store.CreateSigningToken("ci", []string{"deploy"}, nil, "test")
+	if err != nil {
+		t.Fatalf("CreateSigningToken: %v", err)
+	}
+	if !IsSigningToken(secret) {
+		t.Fatalf("%q is not a signing token", secret)
+	}
+
+	if _, err := store.ValidateToken(secret); !errors.Is(err, ErrSignatureRequired) {
+		t.Errorf("ValidateToken of signing token: got %v, want ErrSignatureRequired", err)
+	}
+
+	body := []byte(`{"schema_hash":"abc"}`)
+	signed := func(t *testing.T, token string, at time.Time) *http.Request {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/deploy/prepare?dry_run=1", nil)
+		if err := SignRequest(req, token, body, at); err != nil {
+			t.Fatalf("SignRequest: %v", err)
+		}
+		return req
+	}
+	validate := func(req *http.Request, body []byte) (*AdminToken, error) {
+		keyID, ok := SigningKeyID(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+		if !ok {
+			t.Fatalf("Authorization header %q does not carry a key ID", req.Header.Get("Authorization"))
+		}
+		return svc.ValidateSignedRequest(keyID, req, body)
+	}
+
+	req := signed(t, secret, time.Now())
+	if strings.Contains(req.Header.Get("Authorization"), secret) {
+		t.Error("signed request sent the token secret")
+	}
+	token, err := validate(req, body)
+	if err != nil {
+		t.Fatalf("valid signed request: %v", err)
+	}
+	if token.Name != "ci" || !token.RequireSigning || token.LastUsedAt == nil {
+		t.Errorf("token = %+v", token)
+	}
+
+	if _, err := validate(req, body); !errors.Is(err, ErrSignatureReplayed) {
+		t.Errorf("replayed request: got %v, want ErrSignatureReplayed", err)
+	}
+
+	if _, err := validate(signed(t, secret, time.Now()), []byte(`{"schema_hash":"evil"}`)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered body: got %v, want ErrInvalidSignature", err)
+	}
+
+	stale := signed(t, secret, time.Now().Add(-SignatureTolerance-time.Minute))
+	if _, err := validate(stale, body); !errors.Is(err, ErrSignatureExpired) {
+		t.Errorf("stale timestamp: got %v, want ErrSignatureExpired", err)
+	}
+
+	// A stale request with a forged signature is reported as forged.
+	stale.Header.Set(SignatureHeader, strings.Repeat("0", 64))
+	if _, err := validate(stale, body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("stale forged request: got %v, want ErrInvalidSignature", err)
+	}
+
+	unsigned := signed(t, secret, time.Now())
+	unsigned.Header.Del(SignatureHeader)
+	if _, err := validate(unsigned, body); !errors.Is(err, ErrSignatureRequired) {
+		t.Errorf("missing signature: got %v, want ErrSignatureRequired", err)
+	}
+
+	// Rotation keeps the key ID and invalidates signatures made with the old secret.
+	rotated, _, err := store.RotateToken("ci")
+	if err != nil {
+		t.Fatalf("RotateToken: %v", err)
+	}
+	oldKeyID, _, _ := splitSigningToken(secret)
+	newKeyID, _, _ := splitSigningToken(rotated)
+	if !IsSigningToken(rotated) || newKeyID != oldKeyID {
+		t.Errorf("rotated token %q lost its key ID %q", rotated, oldKeyID)
+	}
+	if _, err := validate(signed(t, secret, time.Now()), body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("old secret after rotation: got %v, want ErrInvalidSignature", err)
+	}
+	if _, err := validate(signed(t, rotated, time.Now()), body); err != nil {
+		t.Errorf("new secret after rotation: %v", err)
+	}
+}
+
+func TestSigningKeyID(t *testing.T) {
+	tests := []struct {
+		bearer string
+		keyID  string
+		ok     bool
+	}{
+		{"alyxs_0123abcd", "0123abcd", true},
+		{"alyxs_0123abcd_secret", "", false},
+		{"alyxs_", "", false},
+		{"0123abcd", "", false},
+	}
+	for _, tt := range tests {
+		keyID, ok := SigningKeyID(tt.bearer)
+		if keyID != tt.keyID || ok != tt.ok {
+			t.Errorf("SigningKeyID(%q) = %q, %v; want %q, %v", tt.bearer, keyID, ok, tt.keyID, tt.ok)
+		}
+	}
+}
+
+func TestSigningKeyStorage(t *testing.T) {
+	store := testStore(t)
+	fc, err := database.NewFieldCipher(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("NewFieldCipher: %v", err)
+	}
+	svc := &Service{store: store}
+	svc.SetKeyCipher(fc)
+
+	secret, err := store.CreateSigningToken("ci", []string{"deploy"}, nil, "test")
+	if err != nil {
+		t.Fatalf("CreateSigningToken: %v", err)
+	}
+	keyID, _, _ := splitSigningToken(secret)
+	body := []byte(`{}`)
+	validate := func(req *http.Request) error {
+		_, err := svc.ValidateSignedRequest(keyID, req, body)
+		return err
+	}
+
+	var tokenHash, stored string
+	if err := store.db.QueryRow(`SELECT token_hash, signing_key FROM _alyx_admin_tokens WHERE name = 'ci'`).Scan(&tokenHash, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored, signingKey(secret)) || !strings.HasPrefix(stored, "enc:v1:") {
+		t.Errorf("signing key stored unsealed: %q", stored)
+	}
+
+	// The stored digest does not sign requests.
+	forged := httptest.NewRequest(http.MethodPost, "/api/admin/deploy/execute", nil)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	forged.Header.Set(TimestampHeader, timestamp)
+	forged.Header.Set(SignatureHeader, requestSignature(strings.TrimPrefix(tokenHash, "sha256:"), forged.Method, forged.URL.RequestURI(), timestamp, body))
+	if err := validate(forged); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("request signed with token_hash: got %v, want ErrInvalidSignature", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/deploy/execute", nil)
+	if err := SignRequest(req, secret, body, time.Now()); err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+	if err := validate(req); err != nil {
+		t.Errorf("valid signed request: %v", err)
+	}
+
+	// Without the cipher the sealed key is unusable.
+	store.cipher = nil
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/deploy/execute", nil)
+	if err := SignRequest(req, secret, body, time.Now()); err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+	if err := validate(req); err == nil {
+		t.Error("sealed signing key accepted without the cipher")
+	}
+}
@@ -3,6 +3,7 @@ package deploy
 import (
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/hex"
 	"errors"
@@ -16,7 +17,15 @@ import (
 
 // Store manages deployment state in the database.
 type Store struct {
-	db *sql.DB
+	db     *sql.DB
+	cipher KeyCipher
+}
+
+// KeyCipher seals the signing keys of signing tokens at rest.
+// *database.FieldCipher implements it.
+type KeyCipher interface {
+	Encrypt(plaintext, aad string) (string, error)
+	Decrypt(value, aad string) (string, error)
 }
 
 // NewStore creates a new deployment store.
@@ -34,7 +43,8 @@ func (s *Store) Init() error {
 func (s *Store) GetCurrentDeployment() (*Deployment, error) {
 	row := s.db.QueryRow(`
 		SELECT id, version, schema_hash, functions_hash, schema_snapshot, 
-		       functions_snapshot, deployed_at, deployed_by, status, rollback_to, description
+		       functions_snapshot, deployed_at, deployed_by, status, rollback_to, description,
+		       bundle_hash, bundle_size
 		FROM _alyx_deployments
 		WHERE status = ?
 		ORDER BY deployed_at DESC
@@ -52,7 +62,8 @@ func (s *Store) GetCurrentDeployment() (*Deployment, error) {
 func (s *Store) GetDeployment(version string) (*Deployment, error) {
 	row := s.db.QueryRow(`
 		SELECT id, version, schema_hash, functions_hash, schema_snapshot, 
-		       functions_snapshot, deployed_at, deployed_by, status, rollback_to, description
+		       functions_snapshot, deployed_at, deployed_by, status, rollback_to, description,
+		       bundle_hash, bundle_size
 		FROM _alyx_deployments
 		WHERE version = ?
 	`, version)
@@ -68,7 +79,8 @@ func (s *Store) GetDeployment(version string) (*Deployment, error) {
 func (s *Store) ListDeployments(limit int, status string) ([]*Deployment, error) {
 	query := `
 		SELECT id, version, schema_hash, functions_hash, schema_snapshot, 
-		       functions_snapshot, deployed_at, deployed_by, status, rollback_to, description
+		       functions_snapshot, deployed_at, deployed_by, status, rollback_to, description,
+		       bundle_hash, bundle_size
 		FROM _alyx_deployments
 	`
 	var args []any
@@ -132,10 +144,12 @@ func (s *Store) CreateDeployment(d *Deployment) error {
 	_, err := s.db.Exec(`
 		INSERT INTO _alyx_deployments (
 			version, schema_hash, functions_hash, schema_snapshot, 
-			functions_snapshot, deployed_by, status, description
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			functions_snapshot, deployed_by, status, description,
+			bundle_hash, bundle_size
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, d.Version, d.SchemaHash, d.FunctionsHash, d.SchemaSnapshot,
-		d.FunctionsSnapshot, d.DeployedBy, d.Status, d.Description)
+		d.FunctionsSnapshot, d.DeployedBy, d.Status, d.Description,
+		nullString(d.BundleHash), nullInt64(d.BundleSize))
 
 	if err != nil {
 		return fmt.Errorf("creating deployment: %w", err)
@@ -169,12 +183,14 @@ func (s *Store) DeactivateAllDeployments() error {
 
 func (s *Store) scanDeployment(row *sql.Row) (*Deployment, error) {
 	var d Deployment
-	var deployedAt, expiresAt, rollbackTo, description sql.NullString
+	var deployedAt, expiresAt, rollbackTo, description, bundleHash sql.NullString
+	var bundleSize sql.NullInt64
 
 	err := row.Scan(
 		&d.ID, &d.Version, &d.SchemaHash, &d.FunctionsHash,
 		&d.SchemaSnapshot, &d.FunctionsSnapshot, &deployedAt,
 		&d.DeployedBy, &d.Status, &rollbackTo, &description,
+		&bundleHash, &bundleSize,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, sql.ErrNoRows
@@ -196,6 +212,8 @@ func (s *Store) scanDeployment(row *sql.Row) (*Deployment, error) {
 	if description.Valid {
 		d.Description = description.String
 	}
+	d.BundleHash = bundleHash.String
+	d.BundleSize = bundleSize.Int64
 	_ = expiresAt // unused but needed for future
 
 	return &d, nil
@@ -203,12 +221,14 @@ func (s *Store) scanDeployment(row *sql.Row) (*Deployment, error) {
 
 func (s *Store) scanDeploymentFromRows(rows *sql.Rows) (*Deployment, error) {
 	var d Deployment
-	var deployedAt, rollbackTo, description sql.NullString
+	var deployedAt, rollbackTo, description, bundleHash sql.NullString
+	var bundleSize sql.NullInt64
 
 	err := rows.Scan(
 		&d.ID, &d.Version, &d.SchemaHash, &d.FunctionsHash,
 		&d.SchemaSnapshot, &d.FunctionsSnapshot, &deployedAt,
 		&d.DeployedBy, &d.Status, &rollbackTo, &description,
+		&bundleHash, &bundleSize,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("scanning deployment row: %w", err)
@@ -227,39 +247,66 @@ func (s *Store) scanDeploymentFromRows(rows *sql.Rows) (*Deployment, error) {
 	if description.Valid {
 		d.Description = description.String
 	}
+	d.BundleHash = bundleHash.String
+	d.BundleSize = bundleSize.Int64
 
 	return &d, nil
 }
 
 // Token management methods.
 
+// Token errors returned by ValidateToken, RotateToken and DeleteToken.
+var (
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrTokenExpired  = errors.New("token expired")
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// adminTokenColumns are the columns scanned by scanToken.
+const adminTokenColumns = "id, name, token_hash, permissions, created_at, expires_at, last_used_at, created_by, key_id"
+
 // CreateToken creates a new admin token.
 func (s *Store) CreateToken(name string, permissions []string, expiresAt *time.Time, createdBy string) (string, error) {
-	// Generate random token
-	tokenBytes := make([]byte, 32)
-	if _, err := rand.Read(tokenBytes); err != nil {
-		return "", fmt.Errorf("generating token: %w", err)
+	return s.createToken(name, permissions, expiresAt, createdBy, "")
+}
+
+// CreateSigningToken creates an admin token that only authenticates signed
+// requests. Its secret embeds a key ID that identifies it in place of the
+// secret; see SignRequest.
+func (s *Store) CreateSigningToken(name string, permissions []string, expiresAt *time.Time, createdBy string) (string, error) {
+	keyID, err := generateKeyID()
+	if err != nil {
+		return "", err
 	}
-	token := hex.EncodeToString(tokenBytes)
+	return s.createToken(name, permissions, expiresAt, createdBy, keyID)
+}
 
-	// Hash the token for storage
-	hash, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.DefaultCost)
+func (s *Store) createToken(name string, permissions []string, expiresAt *time.Time, createdBy, keyID string) (string, error) {
+	token, err := generateToken()
 	if err != nil {
-		return "", fmt.Errorf("hashing token: %w", err)
+		return "", err
+	}
+	if keyID != "" {
+		token = signingToken(keyID, token)
 	}
 
 	permsStr := strings.Join(permissions, ",")
 
 	var expiresAtStr *string
 	if expiresAt != nil {
-		s := expiresAt.Format(time.RFC3339)
+		s := expiresAt.UTC().Format(time.RFC3339)
 		expiresAtStr = &s
 	}
 
+	key, err := s.sealSigningKey(keyID, token)
+	if err != nil {
+		return "", err
+	}
+
 	_, err = s.db.Exec(`
-		INSERT INTO _alyx_admin_tokens (name, token_hash, permissions, expires_at, created_by)
-		VALUES (?, ?, ?, ?, ?)
-	`, name, string(hash), permsStr, expiresAtStr, createdBy)
+		INSERT INTO _alyx_admin_tokens (name, token_hash, permissions, expires_at, created_by, key_id, signing_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, name, tokenDigest(token), permsStr, expiresAtStr, createdBy, nullString(keyID), key)
 
 	if err != nil {
 		return "", fmt.Errorf("creating token: %w", err)
@@ -269,80 +316,189 @@ func (s *Store) CreateToken(name string, permissions []string, expiresAt *time.T
 }
 
 // ValidateToken validates an admin token and returns its permissions.
+//
+// Tokens are looked up by the SHA-256 digest of the presented value, so the
+// secret itself is never compared. Tokens created before digests were used
+// are stored as bcrypt hashes; one that matches is switched to its digest.
 func (s *Store) ValidateToken(token string) (*AdminToken, error) {
-	rows, err := s.db.Query(`
-		SELECT id, name, token_hash, permissions, created_at, expires_at, last_used_at, created_by
-		FROM _alyx_admin_tokens
-	`)
+	digest := tokenDigest(token)
+
+	t, err := s.scanToken(s.db.QueryRow(`SELECT `+adminTokenColumns+` FROM _alyx_admin_tokens WHERE token_hash = ?`, digest))
+	if errors.Is(err, sql.ErrNoRows) {
+		t, err = s.validateLegacyToken(token, digest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(digest)) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	if t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now()) {
+		return nil, ErrTokenExpired
+	}
+	if t.RequireSigning {
+		return nil, ErrSignatureRequired
+	}
+
+	s.touchToken(t)
+	return t, nil
+}
+
+// TokenByKeyID returns the signing token with the given key ID.
+func (s *Store) TokenByKeyID(keyID string) (*AdminToken, error) {
+	t, err := s.scanToken(s.db.QueryRow(`SELECT `+adminTokenColumns+` FROM _alyx_admin_tokens WHERE key_id = ?`, keyID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying token: %w", err)
+	}
+
+	if t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now()) {
+		return nil, ErrTokenExpired
+	}
+
+	return t, nil
+}
+
+// sealSigningKey returns the stored form of the signing key of token, or nil
+// for a token without a key ID. The key is encrypted when a key cipher is
+// set, so the database alone cannot sign requests.
+func (s *Store) sealSigningKey(keyID, token string) (any, error) {
+	if keyID == "" {
+		return nil, nil
+	}
+	key := signingKey(token)
+	if s.cipher == nil {
+		return key, nil
+	}
+	sealed, err := s.cipher.Encrypt(key, signingKeyAAD(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("sealing signing key: %w", err)
+	}
+	return sealed, nil
+}
+
+// storedSigningKey returns the key the requests of signing token t are
+// verified with.
+func (s *Store) storedSigningKey(t *AdminToken) (string, error) {
+	var stored sql.NullString
+	if err := s.db.QueryRow(`SELECT signing_key FROM _alyx_admin_tokens WHERE id = ?`, t.ID).Scan(&stored); err != nil {
+		return "", fmt.Errorf("querying signing key: %w", err)
+	}
+	if !stored.Valid {
+		// Signing tokens created before signing keys were stored have none
+		// until they are rotated.
+		return "", fmt.Errorf("%w: rotate the token to issue a signing key", ErrInvalidSignature)
+	}
+	if s.cipher == nil {
+		if strings.HasPrefix(stored.String, "enc:") {
+			return "", errors.New("signing key is encrypted but security.field_encryption_key is not configured")
+		}
+		return stored.String, nil
+	}
+	key, err := s.cipher.Decrypt(stored.String, signingKeyAAD(t.KeyID))
+	if err != nil {
+		return "", fmt.Errorf("opening signing key: %w", err)
+	}
+	return key, nil
+}
+
+// signingKeyAAD binds a sealed signing key to its token's key ID.
+func signingKeyAAD(keyID string) string {
+	return "_alyx_admin_tokens.signing_key:" + keyID
+}
+
+// touchToken records that t was just used.
+func (s *Store) touchToken(t *AdminToken) {
+	now := time.Now().UTC()
+	_, _ = s.db.Exec(`UPDATE _alyx_admin_tokens SET last_used_at = ? WHERE id = ?`, now.Format(time.RFC3339), t.ID)
+	t.LastUsedAt = &now
+}
+
+// validateLegacyToken finds the bcrypt-hashed token matching token and
+// replaces its hash with digest.
+func (s *Store) validateLegacyToken(token, digest string) (*AdminToken, error) {
+	match, err := s.findLegacyToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.Exec(`UPDATE _alyx_admin_tokens SET token_hash = ? WHERE id = ?`, digest, match.ID); err != nil {
+		return nil, fmt.Errorf("upgrading token hash: %w", err)
+	}
+	match.TokenHash = digest
+	return match, nil
+}
+
+func (s *Store) findLegacyToken(token string) (*AdminToken, error) {
+	rows, err := s.db.Query(`SELECT ` + adminTokenColumns + ` FROM _alyx_admin_tokens WHERE token_hash LIKE '$2%'`)
 	if err != nil {
 		return nil, fmt.Errorf("querying tokens: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var t AdminToken
-		var permsStr string
-		var createdAt, expiresAt, lastUsedAt sql.NullString
-
-		scanErr := rows.Scan(
-			&t.ID, &t.Name, &t.TokenHash, &permsStr,
-			&createdAt, &expiresAt, &lastUsedAt, &t.CreatedBy,
-		)
+		t, scanErr := s.scanToken(rows)
 		if scanErr != nil {
 			continue
 		}
-
-		// Check if token matches
-		if bcrypt.CompareHashAndPassword([]byte(t.TokenHash), []byte(token)) != nil {
-			continue
-		}
-
-		// Parse permissions
-		if permsStr != "" {
-			t.Permissions = strings.Split(permsStr, ",")
+		if bcrypt.CompareHashAndPassword([]byte(t.TokenHash), []byte(token)) == nil {
+			return t, nil
 		}
+	}
 
-		// Parse timestamps
-		if createdAt.Valid {
-			if parsed, parseErr := time.Parse(time.RFC3339, createdAt.String); parseErr == nil {
-				t.CreatedAt = parsed
-			}
-		}
-		if expiresAt.Valid {
-			if parsed, parseErr := time.Parse(time.RFC3339, expiresAt.String); parseErr == nil {
-				t.ExpiresAt = &parsed
-			}
-		}
-		if lastUsedAt.Valid {
-			if parsed, parseErr := time.Parse(time.RFC3339, lastUsedAt.String); parseErr == nil {
-				t.LastUsedAt = &parsed
-			}
-		}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating tokens: %w", err)
+	}
 
-		// Check expiration
-		if t.ExpiresAt != nil && t.ExpiresAt.Before(time.Now()) {
-			return nil, fmt.Errorf("token expired")
-		}
+	return nil, ErrInvalidToken
+}
 
-		// Update last used
-		_, _ = s.db.Exec(`
-			UPDATE _alyx_admin_tokens SET last_used_at = datetime('now') WHERE id = ?
-		`, t.ID)
+// RotateToken replaces the secret of the named token, keeping its
+// permissions, expiry and key ID, and returns the new secret. The old secret
+// stops working immediately.
+func (s *Store) RotateToken(name string) (string, *AdminToken, error) {
+	var keyID sql.NullString
+	err := s.db.QueryRow(`SELECT key_id FROM _alyx_admin_tokens WHERE name = ?`, name).Scan(&keyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("rotating token: %w", err)
+	}
 
-		return &t, nil
+	token, err := generateToken()
+	if err != nil {
+		return "", nil, err
+	}
+	if keyID.Valid {
+		token = signingToken(keyID.String, token)
+	}
+	key, err := s.sealSigningKey(keyID.String, token)
+	if err != nil {
+		return "", nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterating tokens: %w", err)
+	t, err := s.scanToken(s.db.QueryRow(`
+		UPDATE _alyx_admin_tokens SET token_hash = ?, signing_key = ?, last_used_at = NULL
+		WHERE name = ?
+		RETURNING `+adminTokenColumns, tokenDigest(token), key, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("rotating token: %w", err)
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return token, t, nil
 }
 
 // ListTokens returns all admin tokens (without the actual token values).
 func (s *Store) ListTokens() ([]*AdminToken, error) {
 	rows, err := s.db.Query(`
-		SELECT id, name, permissions, created_at, expires_at, last_used_at, created_by
+		SELECT ` + adminTokenColumns + `
 		FROM _alyx_admin_tokens
 		ORDER BY created_at DESC
 	`)
@@ -353,38 +509,12 @@ func (s *Store) ListTokens() ([]*AdminToken, error) {
 
 	var tokens []*AdminToken
 	for rows.Next() {
-		var t AdminToken
-		var permsStr string
-		var createdAt, expiresAt, lastUsedAt sql.NullString
-
-		err := rows.Scan(
-			&t.ID, &t.Name, &permsStr,
-			&createdAt, &expiresAt, &lastUsedAt, &t.CreatedBy,
-		)
+		t, err := s.scanToken(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scanning token: %w", err)
 		}
-
-		if permsStr != "" {
-			t.Permissions = strings.Split(permsStr, ",")
-		}
-		if createdAt.Valid {
-			if parsed, err := time.Parse(time.RFC3339, createdAt.String); err == nil {
-				t.CreatedAt = parsed
-			}
-		}
-		if expiresAt.Valid {
-			if parsed, err := time.Parse(time.RFC3339, expiresAt.String); err == nil {
-				t.ExpiresAt = &parsed
-			}
-		}
-		if lastUsedAt.Valid {
-			if parsed, err := time.Parse(time.RFC3339, lastUsedAt.String); err == nil {
-				t.LastUsedAt = &parsed
-			}
-		}
-
-		tokens = append(tokens, &t)
+		t.TokenHash = ""
+		tokens = append(tokens, t)
 	}
 
 	return tokens, rows.Err()
@@ -402,18 +532,104 @@ func (s *Store) DeleteToken(name string) error {
 		return err
 	}
 	if rows == 0 {
-		return fmt.Errorf("token not found")
+		return ErrTokenNotFound
 	}
 
 	return nil
 }
 
-// HasPermission checks if a token has a specific permission.
+// scanner is satisfied by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (s *Store) scanToken(row scanner) (*AdminToken, error) {
+	var t AdminToken
+	var permsStr string
+	var createdAt, expiresAt, lastUsedAt, createdBy, keyID sql.NullString
+
+	if err := row.Scan(
+		&t.ID, &t.Name, &t.TokenHash, &permsStr,
+		&createdAt, &expiresAt, &lastUsedAt, &createdBy, &keyID,
+	); err != nil {
+		return nil, err
+	}
+
+	if permsStr != "" {
+		t.Permissions = strings.Split(permsStr, ",")
+	}
+	if parsed, ok := parseTimestamp(createdAt); ok {
+		t.CreatedAt = parsed
+	}
+	if parsed, ok := parseTimestamp(expiresAt); ok {
+		t.ExpiresAt = &parsed
+	}
+	if parsed, ok := parseTimestamp(lastUsedAt); ok {
+		t.LastUsedAt = &parsed
+	}
+	t.CreatedBy = createdBy.String
+	t.KeyID = keyID.String
+	t.RequireSigning = keyID.Valid
+
+	return &t, nil
+}
+
+// parseTimestamp parses an RFC 3339 timestamp or one written by SQLite's
+// datetime('now') column defaults.
+func parseTimestamp(s sql.NullString) (time.Time, bool) {
+	if !s.Valid {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, s.String); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02 15:04:05", s.String); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// nullString returns nil for an empty string, so it is stored as NULL.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullInt64 returns nil for zero, so it is stored as NULL.
+func nullInt64(n int64) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// generateToken returns a new random token secret.
+func generateToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	return hex.EncodeToString(tokenBytes), nil
+}
+
+// tokenDigest returns the stored form of a token secret. Secrets are 256
+// random bits, so a fast hash is enough.
+func tokenDigest(token string) string {
+	return "sha256:" + hashString(token)
+}
+
+// HasPermission checks if a token has a specific permission. Admin implies
+// every permission, and deploy and rollback imply read.
 func (t *AdminToken) HasPermission(perm TokenPermission) bool {
 	for _, p := range t.Permissions {
 		if p == string(PermissionAdmin) || p == string(perm) {
 			return true
 		}
+		if perm == PermissionRead && (p == string(PermissionDeploy) || p == string(PermissionRollback)) {
+			return true
+		}
 	}
 	return false
 }
@@ -0,0 +1,146 @@
+package deploy
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+)
+
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewStore(db.DB)
+}
+
+func TestValidateToken(t *testing.T) {
+	store := testStore(t)
+
+	secret, err := store.CreateToken("ci", []string{"deploy"}, nil, "test")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	var stored string
+	if err := store.db.QueryRow(`SELECT token_hash FROM _alyx_admin_tokens WHERE name = 'ci'`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored, secret) || stored != tokenDigest(secret) {
+		t.Errorf("expected the token digest to be stored, got %q", stored)
+	}
+
+	token, err := store.ValidateToken(secret)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if token.Name != "ci" || !token.HasPermission(PermissionDeploy) || !token.HasPermission(PermissionRead) || token.HasPermission(PermissionRollback) {
+		t.Errorf("unexpected token %+v", token)
+	}
+
+	if _, err := store.ValidateToken(secret + "0"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for a wrong secret, got %v", err)
+	}
+
+	tokens, err := store.ListTokens()
+	if err != nil {
+		t.Fatalf("ListTokens: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].LastUsedAt == nil || tokens[0].CreatedAt.IsZero() {
+		t.Errorf("expected listed token with created_at and last_used_at, got %+v", tokens[0])
+	}
+}
+
+func TestValidateTokenExpired(t *testing.T) {
+	store := testStore(t)
+
+	past := time.Now().Add(-time.Minute)
+	secret, err := store.CreateToken("old", []string{"deploy"}, &past, "test")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if _, err := store.ValidateToken(secret); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired, got %v", err)
+	}
+
+	future := time.Now().Add(time.Hour)
+	secret, err = store.CreateToken("new", []string{"deploy"}, &future, "test")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	token, err := store.ValidateToken(secret)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if token.ExpiresAt == nil || !token.ExpiresAt.Equal(future.Truncate(time.Second)) {
+		t.Errorf("expires_at = %v, want %v", token.ExpiresAt, future.Truncate(time.Second))
+	}
+}
+
+func TestValidateLegacyBcryptToken(t *testing.T) {
+	store := testStore(t)
+
+	secret := strings.Repeat("ab", 32)
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.db.Exec(`INSERT INTO _alyx_admin_tokens (name, token_hash, permissions) VALUES ('legacy', ?, 'admin')`, string(hash)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.ValidateToken(secret); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	var stored string
+	if err := store.db.QueryRow(`SELECT token_hash FROM _alyx_admin_tokens WHERE name = 'legacy'`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != tokenDigest(secret) {
+		t.Errorf("expected legacy hash to be replaced by the digest, got %q", stored)
+	}
+	if _, err := store.ValidateToken(secret); err != nil {
+		t.Errorf("ValidateToken after upgrade: %v", err)
+	}
+}
+
+func TestRotateToken(t *testing.T) {
+	store := testStore(t)
+
+	expiry := time.Now().Add(time.Hour)
+	old, err := store.CreateToken("ci", []string{"deploy", "rollback"}, &expiry, "test")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	secret, token, err := store.RotateToken("ci")
+	if err != nil {
+		t.Fatalf("RotateToken: %v", err)
+	}
+	if secret == old {
+		t.Fatal("expected a new secret")
+	}
+	if strings.Join(token.Permissions, ",") != "deploy,rollback" || token.ExpiresAt == nil {
+		t.Errorf("expected permissions and expiry to be kept, got %+v", token)
+	}
+
+	if _, err := store.ValidateToken(old); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected the old secret to stop working, got %v", err)
+	}
+	if _, err := store.ValidateToken(secret); err != nil {
+		t.Errorf("ValidateToken with the new secret: %v", err)
+	}
+
+	if _, _, err := store.RotateToken("missing"); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("expected ErrTokenNotFound, got %v", err)
+	}
+}
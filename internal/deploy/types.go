@@ -32,6 +32,10 @@ type Deployment struct {
 	Status            DeploymentStatus `json:"status"`
 	RollbackTo        string           `json:"rollback_to,omitempty"`
 	Description       string           `json:"description,omitempty"`
+	// BundleHash and BundleSize describe the uploaded bundle archive, for
+	// deployments made from one.
+	BundleHash string `json:"bundle_hash,omitempty"`
+	BundleSize int64  `json:"bundle_size,omitempty"`
 }
 
 // FunctionInfo represents function metadata for deployment.
@@ -69,6 +73,10 @@ type PrepareResponse struct {
 	NextVersion     string            `json:"next_version"`
 	HasUnsafe       bool              `json:"has_unsafe"`
 	UnsafeWarnings  []string          `json:"unsafe_warnings,omitempty"`
+	// SchemaHash and FunctionsHash are the hashes the changes were prepared
+	// for. A bundle executed afterwards must match them.
+	SchemaHash    string `json:"schema_hash"`
+	FunctionsHash string `json:"functions_hash"`
 }
 
 // FunctionChange represents a change to a function.
@@ -105,12 +113,32 @@ type ExecuteRequest struct {
 	Force         bool              `json:"force,omitempty"`
 }
 
+// BundleExecuteRequest is a deployment from an uploaded bundle archive.
+// SchemaHash, FunctionsHash and CurrentVersion come from the PrepareResponse
+// the deployment was reviewed with; the bundle's manifest must match the
+// hashes, and CurrentVersion must still be the active deployment.
+type BundleExecuteRequest struct {
+	Bundle         []byte
+	SchemaHash     string
+	FunctionsHash  string
+	CurrentVersion string
+	Description    string
+}
+
 // ExecuteResponse is the response from deployment execution.
 type ExecuteResponse struct {
 	Success     bool   `json:"success"`
 	Version     string `json:"version"`
 	Message     string `json:"message,omitempty"`
 	RollbackCmd string `json:"rollback_cmd,omitempty"`
+	// Collections names the collections whose schema the deployment changed.
+	Collections []string `json:"collections,omitempty"`
+
+	// Schema is the deployed schema.
+	Schema *schema.Schema `json:"-"`
+	// Activated is set when the service already installed Schema in the
+	// running server; see Service.SetActivate.
+	Activated bool `json:"-"`
 }
 
 // RollbackRequest is the request payload for rollback.
@@ -125,6 +153,18 @@ type RollbackResponse struct {
 	RolledBackFrom string `json:"rolled_back_from"`
 	RolledBackTo   string `json:"rolled_back_to"`
 	Message        string `json:"message,omitempty"`
+	// Collections names the collections whose schema the rollback changed.
+	Collections []string `json:"collections,omitempty"`
+
+	// FunctionsRestored is set when the functions directory was switched
+	// back to the release of the bundle deployment rolled back to.
+	FunctionsRestored bool `json:"functions_restored,omitempty"`
+
+	// Schema is the schema rolled back to.
+	Schema *schema.Schema `json:"-"`
+	// Activated is set when the service already installed Schema in the
+	// running server; see Service.SetActivate.
+	Activated bool `json:"-"`
 }
 
 // HistoryRequest is the request for deployment history.
@@ -149,16 +189,26 @@ type AdminToken struct {
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
 	CreatedBy   string     `json:"created_by,omitempty"`
+	// RequireSigning is set for tokens that only authenticate signed
+	// requests, which name the token by KeyID instead of sending its secret.
+	RequireSigning bool   `json:"require_signing"`
+	KeyID          string `json:"-"`
 }
 
 // TokenPermission represents permissions for admin tokens.
 type TokenPermission string
 
 const (
+	// PermissionRead allows the read-only admin endpoints: schema, config
+	// metadata, deploy history, pending changes, logs and deploy prepare.
+	// Deploy, rollback and admin tokens have it too.
+	PermissionRead TokenPermission = "read"
 	// PermissionDeploy allows deployment operations.
 	PermissionDeploy TokenPermission = "deploy"
 	// PermissionRollback allows rollback operations.
 	PermissionRollback TokenPermission = "rollback"
+	// PermissionIntrospect allows checking user access tokens.
+	PermissionIntrospect TokenPermission = "introspect"
 	// PermissionAdmin allows full admin access.
 	PermissionAdmin TokenPermission = "admin"
 )
@@ -168,13 +218,17 @@ type CreateTokenRequest struct {
 	Name        string     `json:"name"`
 	Permissions []string   `json:"permissions"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	// RequireSigning creates a token that only authenticates signed
+	// requests.
+	RequireSigning bool `json:"require_signing,omitempty"`
 }
 
 // CreateTokenResponse is the response after creating an admin token.
 type CreateTokenResponse struct {
-	Token       string     `json:"token"`
-	Name        string     `json:"name"`
-	Permissions []string   `json:"permissions"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
-	Message     string     `json:"message"`
+	Token          string     `json:"token"`
+	Name           string     `json:"name"`
+	Permissions    []string   `json:"permissions"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	RequireSigning bool       `json:"require_signing,omitempty"`
+	Message        string     `json:"message"`
 }
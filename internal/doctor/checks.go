@@ -0,0 +1,439 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/functions"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/storage"
+)
+
+// maxDetails caps how many offending rows or items a check lists.
+const maxDetails = 20
+
+// storageProbeTimeout bounds how long a remote storage backend may take to answer.
+const storageProbeTimeout = 5 * time.Second
+
+// configCheck verifies the config file loads, validates and has a usable JWT secret.
+type configCheck struct{}
+
+func (configCheck) Name() string { return "config" }
+
+func (configCheck) Run(_ context.Context, env *Env) Result {
+	if env.ConfigErr != nil {
+		return fail("could not load config: %v", env.ConfigErr)
+	}
+
+	var details []string
+	if err := config.Validate(env.Config); err != nil {
+		var verrs config.ValidationErrors
+		if errors.As(err, &verrs) {
+			for _, e := range verrs {
+				details = append(details, e.Error())
+			}
+		} else {
+			details = append(details, err.Error())
+		}
+	}
+	if err := config.ValidateJWTSecret(env.Config.Auth.JWT.SigningSecret()); err != nil {
+		details = append(details, err.Error())
+	}
+
+	if len(details) > 0 {
+		return fail("%d configuration problem(s)", len(details)).with(details)
+	}
+	return pass("config is valid")
+}
+
+// schemaCheck verifies the schema parses and flags operations left without
+// rules, which are open to every caller.
+type schemaCheck struct{}
+
+func (schemaCheck) Name() string { return "schema" }
+
+func (schemaCheck) Run(_ context.Context, env *Env) Result {
+	if env.SchemaErr != nil {
+		return fail("could not parse schema: %v", env.SchemaErr)
+	}
+	if env.Schema == nil {
+		return warn("no schema file found")
+	}
+
+	var details []string
+	for _, name := range sortedKeys(env.Schema.Collections) {
+		rules := env.Schema.Collections[name].Rules
+		if rules == nil {
+			rules = &schema.Rules{}
+		}
+
+		var open []string
+		for _, op := range []struct{ name, rule string }{
+			{"create", rules.Create},
+			{"read", rules.Read},
+			{"update", rules.Update},
+			{"delete", rules.Delete},
+		} {
+			if op.rule == "" {
+				open = append(open, op.name)
+			}
+		}
+		if len(open) > 0 {
+			details = append(details, fmt.Sprintf("collection %q has no %s rule; anyone can perform it", name, strings.Join(open, "/")))
+		}
+	}
+
+	if len(details) > 0 {
+		return warn("%d collection(s) without access rules", len(details)).with(details)
+	}
+	return pass("%d collection(s), all with access rules", len(env.Schema.Collections))
+}
+
+// databaseCheck verifies the database file is writable and in WAL mode.
+type databaseCheck struct{}
+
+func (databaseCheck) Name() string { return "database" }
+
+func (databaseCheck) Run(_ context.Context, env *Env) Result {
+	if turso := env.Config.Database.Turso; turso != nil && turso.Enabled {
+		return skip("using Turso; local database checks do not apply")
+	}
+
+	path := env.Config.Database.Path
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := dirWritable(filepath.Dir(path)); err != nil {
+			return fail("database %s does not exist and cannot be created: %v", path, err)
+		}
+		return warn("database %s does not exist yet; it will be created on first start", path)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fail("database %s is not writable: %v", path, err)
+	}
+	f.Close()
+
+	db, err := env.DB()
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		return fail("reading journal mode: %v", err)
+	}
+	if !strings.EqualFold(mode, "wal") {
+		return warn("database %s is writable but uses journal mode %q instead of wal", path, mode)
+	}
+	return pass("database %s is writable and in WAL mode", path)
+}
+
+// foreignKeysCheck reports rows whose foreign keys point at missing parents.
+type foreignKeysCheck struct{}
+
+func (foreignKeysCheck) Name() string { return "foreign_keys" }
+
+func (foreignKeysCheck) Run(_ context.Context, env *Env) Result {
+	db, err := env.DB()
+	if err != nil {
+		return fail("%v", err)
+	}
+	if db == nil {
+		return skip("database does not exist yet")
+	}
+
+	rows, err := db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return fail("running foreign_key_check: %v", err)
+	}
+	defer rows.Close()
+
+	var details []string
+	violations := 0
+	for rows.Next() {
+		var table, parent string
+		var rowid, fkid any
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return fail("reading foreign_key_check: %v", err)
+		}
+		violations++
+		if len(details) < maxDetails {
+			details = append(details, fmt.Sprintf("%s row %v references a missing %s row", table, rowid, parent))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fail("reading foreign_key_check: %v", err)
+	}
+
+	if violations > 0 {
+		return fail("%d foreign key violation(s)", violations).with(details)
+	}
+	return pass("no foreign key violations")
+}
+
+// migrationsCheck verifies applied migrations still match their files.
+type migrationsCheck struct{}
+
+func (migrationsCheck) Name() string { return "migrations" }
+
+func (migrationsCheck) Run(_ context.Context, env *Env) Result {
+	db, err := env.DB()
+	if err != nil {
+		return fail("%v", err)
+	}
+	if db == nil {
+		return skip("database does not exist yet")
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = '_alyx_migrations'`).Scan(&count); err != nil {
+		return fail("reading migrations table: %v", err)
+	}
+	if count == 0 {
+		return pass("no migrations applied")
+	}
+
+	migrator := schema.NewMigrator(db, env.SchemaPath, env.MigrationsPath)
+	mismatches, err := migrator.VerifyMigrations()
+	if err != nil {
+		return fail("verifying migrations: %v", err)
+	}
+	if len(mismatches) > 0 {
+		details := make([]string, len(mismatches))
+		for i, m := range mismatches {
+			details[i] = fmt.Sprintf("%s_%s: %s", m.Version, m.Name, m.Reason)
+		}
+		return fail("%d applied migration(s) do not match their files", len(mismatches)).with(details)
+	}
+
+	pending, err := migrator.PendingMigrations()
+	if err != nil {
+		return fail("listing pending migrations: %v", err)
+	}
+	if len(pending) > 0 {
+		return warn("%d migration(s) pending; run 'alyx migrate apply'", len(pending))
+	}
+	return pass("applied migrations match their files")
+}
+
+// functionRuntimesCheck verifies the interpreter for every runtime used by the
+// schema's functions is installed. Functions run as local subprocesses, so
+// this is the runtime dependency doctor can check.
+type functionRuntimesCheck struct{}
+
+func (functionRuntimesCheck) Name() string { return "function_runtimes" }
+
+func (functionRuntimesCheck) Run(_ context.Context, env *Env) Result {
+	if r, ok := skipFunctions(env); !ok {
+		return r
+	}
+
+	used := make(map[string][]string)
+	for _, name := range sortedKeys(env.Schema.Functions) {
+		rt := env.Schema.Functions[name].Runtime
+		used[rt] = append(used[rt], name)
+	}
+
+	var details, found []string
+	for _, rt := range sortedKeys(used) {
+		if functions.Runtime(rt) == functions.RuntimeBinary {
+			continue
+		}
+		command, ok := functions.RuntimeCommand(functions.Runtime(rt))
+		if !ok {
+			details = append(details, fmt.Sprintf("runtime %q (used by %s) is not supported", rt, strings.Join(used[rt], ", ")))
+			continue
+		}
+		if _, err := exec.LookPath(command); err != nil {
+			details = append(details, fmt.Sprintf("%s not found on PATH (needed by %s)", command, strings.Join(used[rt], ", ")))
+			continue
+		}
+		found = append(found, command)
+	}
+
+	if len(details) > 0 {
+		return fail("%d function runtime(s) unavailable", len(details)).with(details)
+	}
+	if len(found) == 0 {
+		return pass("no interpreters required")
+	}
+	return pass("found %s", strings.Join(found, ", "))
+}
+
+// functionEntrypointsCheck verifies every function's directory and entrypoint exist.
+type functionEntrypointsCheck struct{}
+
+func (functionEntrypointsCheck) Name() string { return "function_entrypoints" }
+
+func (functionEntrypointsCheck) Run(_ context.Context, env *Env) Result {
+	if r, ok := skipFunctions(env); !ok {
+		return r
+	}
+
+	var details []string
+	for _, name := range sortedKeys(env.Schema.Functions) {
+		fn := env.Schema.Functions[name]
+		dir := fn.Path
+		if dir == "" {
+			dir = filepath.Join(env.Config.Functions.Path, name)
+		}
+		entrypoint := filepath.Join(dir, fn.Entrypoint)
+		if _, err := os.Stat(entrypoint); err != nil {
+			details = append(details, fmt.Sprintf("%s: entrypoint %s does not exist", name, entrypoint))
+		}
+	}
+
+	if len(details) > 0 {
+		return fail("%d function(s) missing an entrypoint", len(details)).with(details)
+	}
+	return pass("%d function entrypoint(s) found", len(env.Schema.Functions))
+}
+
+func skipFunctions(env *Env) (Result, bool) {
+	if !env.Config.Functions.Enabled {
+		return skip("functions are disabled"), false
+	}
+	if env.Schema == nil || len(env.Schema.Functions) == 0 {
+		return skip("no functions defined"), false
+	}
+	return Result{}, true
+}
+
+// storageCheck verifies each configured storage backend is reachable.
+type storageCheck struct{}
+
+func (storageCheck) Name() string { return "storage" }
+
+func (storageCheck) Run(ctx context.Context, env *Env) Result {
+	backends := env.Config.Storage.Backends
+	if len(backends) == 0 {
+		return skip("no storage backends configured")
+	}
+
+	var failures, warnings []string
+	for _, name := range sortedKeys(backends) {
+		cfg := backends[name]
+		switch cfg.Type {
+		case "filesystem":
+			if cfg.Filesystem == nil || cfg.Filesystem.Path == "" {
+				failures = append(failures, fmt.Sprintf("%s: filesystem path is not set", name))
+				continue
+			}
+			path := cfg.Filesystem.Path
+			info, err := os.Stat(path)
+			if os.IsNotExist(err) {
+				warnings = append(warnings, fmt.Sprintf("%s: %s does not exist yet; it will be created on first upload", name, path))
+				continue
+			}
+			if err == nil && !info.IsDir() {
+				err = errors.New("not a directory")
+			}
+			if err == nil {
+				err = dirWritable(path)
+			}
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %s is not writable: %v", name, path, err))
+			}
+		case "s3":
+			if err := probeS3(ctx, name, cfg, env.Schema); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			}
+		default:
+			failures = append(failures, fmt.Sprintf("%s: unknown backend type %q", name, cfg.Type))
+		}
+	}
+
+	switch {
+	case len(failures) > 0:
+		return fail("%d storage backend(s) unreachable", len(failures)).with(append(failures, warnings...))
+	case len(warnings) > 0:
+		return warn("storage backends reachable with warnings").with(warnings)
+	}
+	return pass("%d storage backend(s) reachable", len(backends))
+}
+
+// probeS3 asks the backend about an object in every bucket that uses it, which
+// exercises credentials, endpoint and bucket access without writing anything.
+func probeS3(ctx context.Context, name string, cfg config.StorageBackendConfig, s *schema.Schema) error {
+	if cfg.S3 == nil {
+		return fmt.Errorf("s3 settings are not set")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storageProbeTimeout)
+	defer cancel()
+
+	backend, err := storage.NewS3Backend(ctx, *cfg.S3)
+	if err != nil {
+		return err
+	}
+
+	if s == nil {
+		return nil
+	}
+	for _, bucketName := range sortedKeys(s.Buckets) {
+		if s.Buckets[bucketName].Backend != name {
+			continue
+		}
+		if _, err := backend.Exists(ctx, bucketName, ".alyx-doctor"); err != nil {
+			return fmt.Errorf("bucket %q: %w", bucketName, err)
+		}
+	}
+	return nil
+}
+
+// portCheck verifies the server's listen address is free.
+type portCheck struct{}
+
+func (portCheck) Name() string { return "port" }
+
+func (portCheck) Run(_ context.Context, env *Env) Result {
+	addr := env.Config.Server.Address()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fail("cannot listen on %s: %v", addr, err)
+	}
+	ln.Close()
+	return pass("%s is available", addr)
+}
+
+func dirWritable(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".alyx-doctor-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
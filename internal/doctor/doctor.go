@@ -0,0 +1,166 @@
+// Package doctor runs diagnostic checks against an Alyx project: its config,
+// schema, database, migrations, functions, storage and listen address.
+package doctor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Result is the outcome of running a check.
+type Result struct {
+	Name    string   `json:"name"`
+	Status  Status   `json:"status"`
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"`
+}
+
+// Check is a single diagnostic.
+type Check interface {
+	// Name returns a short, stable identifier for the check.
+	Name() string
+	// Run performs the check. The returned Result's Name is filled in by Run.
+	Run(ctx context.Context, env *Env) Result
+}
+
+// Env is the project state shared by all checks. Config is never nil; when
+// loading fails, ConfigErr is set and defaults are used for the other checks.
+type Env struct {
+	Config         *config.Config
+	ConfigErr      error
+	Schema         *schema.Schema
+	SchemaErr      error
+	SchemaPath     string
+	MigrationsPath string
+
+	db     *sql.DB
+	dbErr  error
+	opened bool
+}
+
+// DB opens the database read-only, so running doctor never creates or changes
+// it. It returns nil without error when the database file does not exist yet.
+func (e *Env) DB() (*sql.DB, error) {
+	if e.opened {
+		return e.db, e.dbErr
+	}
+	e.opened = true
+
+	path := e.Config.Database.Path
+	if _, err := os.Stat(path); err != nil {
+		if !os.IsNotExist(err) {
+			e.dbErr = err
+		}
+		return nil, e.dbErr
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err == nil {
+		err = db.Ping()
+	}
+	if err != nil {
+		e.dbErr = fmt.Errorf("opening database: %w", err)
+		return nil, e.dbErr
+	}
+
+	e.db = db
+	return db, nil
+}
+
+// Close releases the database handle, if one was opened.
+func (e *Env) Close() error {
+	if e.db != nil {
+		return e.db.Close()
+	}
+	return nil
+}
+
+// DefaultChecks returns every built-in check in the order they are reported.
+func DefaultChecks() []Check {
+	return []Check{
+		configCheck{},
+		schemaCheck{},
+		databaseCheck{},
+		foreignKeysCheck{},
+		migrationsCheck{},
+		functionRuntimesCheck{},
+		functionEntrypointsCheck{},
+		storageCheck{},
+		portCheck{},
+	}
+}
+
+// Run executes checks in order.
+func Run(ctx context.Context, env *Env, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		result := check.Run(ctx, env)
+		result.Name = check.Name()
+		results = append(results, result)
+	}
+	return results
+}
+
+// Summary counts results by status.
+type Summary struct {
+	Pass int `json:"pass"`
+	Warn int `json:"warn"`
+	Fail int `json:"fail"`
+	Skip int `json:"skip"`
+}
+
+// Summarize counts results by status.
+func Summarize(results []Result) Summary {
+	var s Summary
+	for _, r := range results {
+		switch r.Status {
+		case StatusPass:
+			s.Pass++
+		case StatusWarn:
+			s.Warn++
+		case StatusFail:
+			s.Fail++
+		case StatusSkip:
+			s.Skip++
+		}
+	}
+	return s
+}
+
+func pass(format string, args ...any) Result {
+	return Result{Status: StatusPass, Message: fmt.Sprintf(format, args...)}
+}
+
+func warn(format string, args ...any) Result {
+	return Result{Status: StatusWarn, Message: fmt.Sprintf(format, args...)}
+}
+
+func fail(format string, args ...any) Result {
+	return Result{Status: StatusFail, Message: fmt.Sprintf(format, args...)}
+}
+
+func skip(format string, args ...any) Result {
+	return Result{Status: StatusSkip, Message: fmt.Sprintf(format, args...)}
+}
+
+func (r Result) with(details []string) Result {
+	r.Details = details
+	return r
+}
@@ -0,0 +1,266 @@
+package doctor
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const testSecret = "a-test-secret-that-is-long-enough-for-jwt"
+
+func testEnv(t *testing.T) *Env {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "data", "alyx.db")
+	cfg.Auth.JWT.Secret = testSecret
+
+	env := &Env{Config: cfg}
+	t.Cleanup(func() { env.Close() })
+	return env
+}
+
+// createDB creates the database the way the server does, so it is in WAL mode
+// with internal tables present.
+func createDB(t *testing.T, env *Env) *database.DB {
+	t.Helper()
+
+	db, err := database.Open(&env.Config.Database)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func runCheck(t *testing.T, check Check, env *Env) Result {
+	t.Helper()
+	return Run(context.Background(), env, []Check{check})[0]
+}
+
+func expectStatus(t *testing.T, r Result, want Status) {
+	t.Helper()
+	if r.Status != want {
+		t.Fatalf("%s: expected %s, got %s: %s %v", r.Name, want, r.Status, r.Message, r.Details)
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	env := testEnv(t)
+	expectStatus(t, runCheck(t, configCheck{}, env), StatusPass)
+
+	env.Config.Auth.JWT.Secret = "short"
+	r := runCheck(t, configCheck{}, env)
+	expectStatus(t, r, StatusFail)
+	if len(r.Details) == 0 || !strings.Contains(r.Details[0], "32 characters") {
+		t.Errorf("expected JWT secret length detail, got %v", r.Details)
+	}
+
+	env.ConfigErr = os.ErrNotExist
+	expectStatus(t, runCheck(t, configCheck{}, env), StatusFail)
+}
+
+func TestSchemaCheck(t *testing.T) {
+	env := testEnv(t)
+	env.Schema = &schema.Schema{Collections: map[string]*schema.Collection{
+		"posts": {Rules: &schema.Rules{Create: "true", Read: "true", Update: "true", Delete: "true"}},
+		"notes": {Rules: &schema.Rules{Read: "true"}},
+	}}
+
+	r := runCheck(t, schemaCheck{}, env)
+	expectStatus(t, r, StatusWarn)
+	if len(r.Details) != 1 || !strings.Contains(r.Details[0], `"notes"`) || !strings.Contains(r.Details[0], "create/update/delete") {
+		t.Errorf("expected a warning for notes only, got %v", r.Details)
+	}
+
+	env.SchemaErr = os.ErrInvalid
+	expectStatus(t, runCheck(t, schemaCheck{}, env), StatusFail)
+}
+
+func TestDatabaseCheck(t *testing.T) {
+	env := testEnv(t)
+	expectStatus(t, runCheck(t, databaseCheck{}, env), StatusWarn)
+	if _, err := os.Stat(env.Config.Database.Path); !os.IsNotExist(err) {
+		t.Fatal("expected doctor not to create the database")
+	}
+
+	createDB(t, env)
+	expectStatus(t, runCheck(t, databaseCheck{}, env), StatusPass)
+}
+
+func TestForeignKeysCheck(t *testing.T) {
+	env := testEnv(t)
+	db := createDB(t, env)
+
+	if _, err := db.Exec(`CREATE TABLE authors (id TEXT PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE books (id TEXT PRIMARY KEY, author TEXT REFERENCES authors(id))`); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, runCheck(t, foreignKeysCheck{}, env), StatusPass)
+
+	// Insert an orphan on a connection without foreign key enforcement.
+	raw, err := sql.Open("sqlite", env.Config.Database.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err := raw.Exec(`INSERT INTO books (id, author) VALUES ('b1', 'missing')`); err != nil {
+		t.Fatal(err)
+	}
+
+	r := Run(context.Background(), &Env{Config: env.Config}, []Check{foreignKeysCheck{}})[0]
+	expectStatus(t, r, StatusFail)
+	if len(r.Details) != 1 || !strings.Contains(r.Details[0], "books") {
+		t.Errorf("expected violation detail for books, got %v", r.Details)
+	}
+}
+
+func TestMigrationsCheck(t *testing.T) {
+	env := testEnv(t)
+	env.MigrationsPath = t.TempDir()
+	db := createDB(t, env)
+
+	migrator := schema.NewMigrator(db.DB, "", env.MigrationsPath)
+	if err := migrator.Init(); err != nil {
+		t.Fatal(err)
+	}
+	path, err := migrator.CreateMigrationFile("add_tags", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := migrator.PendingMigrations()
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("expected one pending migration, got %v (%v)", pending, err)
+	}
+	pending[0].Operations = nil
+	if err := migrator.Apply(pending[0]); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, runCheck(t, migrationsCheck{}, env), StatusPass)
+
+	if err := os.WriteFile(path, []byte("version: 1\nname: add_tags\ndescription: edited\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := Run(context.Background(), &Env{Config: env.Config, MigrationsPath: env.MigrationsPath}, []Check{migrationsCheck{}})[0]
+	expectStatus(t, r, StatusFail)
+	if len(r.Details) != 1 || !strings.Contains(r.Details[0], "checksum changed") {
+		t.Errorf("expected checksum mismatch detail, got %v", r.Details)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	r = Run(context.Background(), &Env{Config: env.Config, MigrationsPath: env.MigrationsPath}, []Check{migrationsCheck{}})[0]
+	expectStatus(t, r, StatusFail)
+	if len(r.Details) != 1 || !strings.Contains(r.Details[0], "missing") {
+		t.Errorf("expected missing file detail, got %v", r.Details)
+	}
+}
+
+func TestFunctionEntrypointsCheck(t *testing.T) {
+	env := testEnv(t)
+	expectStatus(t, runCheck(t, functionEntrypointsCheck{}, env), StatusSkip)
+
+	dir := t.TempDir()
+	env.Config.Functions.Enabled = true
+	env.Config.Functions.Path = dir
+	env.Schema = &schema.Schema{Functions: map[string]*schema.Function{
+		"hello": {Runtime: "node", Entrypoint: "index.js"},
+		"bye":   {Runtime: "node", Entrypoint: "index.js"},
+	}}
+	if err := os.MkdirAll(filepath.Join(dir, "hello"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hello", "index.js"), []byte(""), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := runCheck(t, functionEntrypointsCheck{}, env)
+	expectStatus(t, r, StatusFail)
+	if len(r.Details) != 1 || !strings.HasPrefix(r.Details[0], "bye:") {
+		t.Errorf("expected only bye to be reported, got %v", r.Details)
+	}
+}
+
+func TestFunctionRuntimesCheck(t *testing.T) {
+	env := testEnv(t)
+	env.Config.Functions.Enabled = true
+	env.Schema = &schema.Schema{Functions: map[string]*schema.Function{
+		"legacy": {Runtime: "cobol", Entrypoint: "main.cob"},
+	}}
+
+	r := runCheck(t, functionRuntimesCheck{}, env)
+	expectStatus(t, r, StatusFail)
+	if len(r.Details) != 1 || !strings.Contains(r.Details[0], "not supported") {
+		t.Errorf("expected unsupported runtime detail, got %v", r.Details)
+	}
+}
+
+func TestStorageCheck(t *testing.T) {
+	env := testEnv(t)
+	expectStatus(t, runCheck(t, storageCheck{}, env), StatusSkip)
+
+	dir := t.TempDir()
+	env.Config.Storage.Backends = map[string]config.StorageBackendConfig{
+		"local": {Type: "filesystem", Filesystem: &config.FilesystemBackendConfig{Path: dir}},
+	}
+	expectStatus(t, runCheck(t, storageCheck{}, env), StatusPass)
+
+	env.Config.Storage.Backends["later"] = config.StorageBackendConfig{
+		Type:       "filesystem",
+		Filesystem: &config.FilesystemBackendConfig{Path: filepath.Join(dir, "not-yet")},
+	}
+	expectStatus(t, runCheck(t, storageCheck{}, env), StatusWarn)
+
+	env.Config.Storage.Backends["broken"] = config.StorageBackendConfig{Type: "ftp"}
+	expectStatus(t, runCheck(t, storageCheck{}, env), StatusFail)
+}
+
+func TestPortCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	env := testEnv(t)
+	env.Config.Server.Host = "127.0.0.1"
+	env.Config.Server.Port = port
+	r := runCheck(t, portCheck{}, env)
+	expectStatus(t, r, StatusFail)
+	if !strings.Contains(r.Message, strconv.Itoa(port)) {
+		t.Errorf("expected message to name the port, got %q", r.Message)
+	}
+
+	ln.Close()
+	expectStatus(t, runCheck(t, portCheck{}, env), StatusPass)
+}
+
+func TestRunAndSummarize(t *testing.T) {
+	env := testEnv(t)
+	results := Run(context.Background(), env, DefaultChecks())
+	if len(results) != len(DefaultChecks()) {
+		t.Fatalf("expected a result per check, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Name == "" || r.Status == "" {
+			t.Errorf("result missing name or status: %+v", r)
+		}
+	}
+
+	s := Summarize([]Result{{Status: StatusPass}, {Status: StatusFail}, {Status: StatusFail}, {Status: StatusSkip}})
+	if s.Pass != 1 || s.Fail != 2 || s.Skip != 1 || s.Warn != 0 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
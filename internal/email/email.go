@@ -0,0 +1,251 @@
+// Package email sends verification, password reset and other transactional
+// emails over SMTP, or logs them when email is not configured.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+// Message is an email to send.
+type Message struct {
+	To      []string
+	Subject string
+	// Text is the plain-text body.
+	Text string
+	// HTML is an optional HTML alternative to Text.
+	HTML string
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Mailer renders templates and sends them through a Sender.
+type Mailer struct {
+	sender     Sender
+	templates  *Templates
+	appName    string
+	verifyURL  string
+	resetURL   string
+	restoreURL string
+}
+
+// New creates a Mailer from cfg. When email is disabled, messages are written
+// to the log instead of sent. baseURL is the server URL used for the default
+// verification and reset links.
+func New(cfg *config.EmailConfig, baseURL string) (*Mailer, error) {
+	var sender Sender = NewLogSender()
+	if cfg.Enabled {
+		sender = NewSMTPSender(&cfg.SMTP, cfg.From)
+	}
+	return NewWithSender(cfg, baseURL, sender)
+}
+
+// NewWithSender creates a Mailer that delivers through sender.
+func NewWithSender(cfg *config.EmailConfig, baseURL string, sender Sender) (*Mailer, error) {
+	templates, err := LoadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading email templates: %w", err)
+	}
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	m := &Mailer{
+		sender:     sender,
+		templates:  templates,
+		appName:    cfg.AppName,
+		verifyURL:  cfg.VerifyURL,
+		resetURL:   cfg.ResetURL,
+		restoreURL: cfg.RestoreURL,
+	}
+	if m.verifyURL == "" {
+		m.verifyURL = baseURL + "/api/auth/verify?token={token}"
+	}
+	if m.resetURL == "" {
+		m.resetURL = baseURL + "/api/auth/password/reset?token={token}"
+	}
+	if m.restoreURL == "" {
+		m.restoreURL = baseURL + "/api/auth/me/restore?token={token}"
+	}
+	return m, nil
+}
+
+// Sender returns the sender messages are delivered through.
+func (m *Mailer) Sender() Sender {
+	return m.sender
+}
+
+// SendVerification sends an email verification link.
+func (m *Mailer) SendVerification(ctx context.Context, to, token string, ttl time.Duration) error {
+	return m.send(ctx, TemplateVerification, TemplateData{
+		Email:     to,
+		Link:      link(m.verifyURL, token),
+		ExpiresIn: humanDuration(ttl),
+	})
+}
+
+// SendPasswordReset sends a password reset link.
+func (m *Mailer) SendPasswordReset(ctx context.Context, to, token string, ttl time.Duration) error {
+	return m.send(ctx, TemplatePasswordReset, TemplateData{
+		Email:     to,
+		Link:      link(m.resetURL, token),
+		ExpiresIn: humanDuration(ttl),
+	})
+}
+
+// SendAccountDeletion confirms a deletion request and sends the link that
+// restores the account during the grace period.
+func (m *Mailer) SendAccountDeletion(ctx context.Context, to, token string, ttl time.Duration) error {
+	return m.send(ctx, TemplateAccountDeletion, TemplateData{
+		Email:     to,
+		Link:      link(m.restoreURL, token),
+		ExpiresIn: humanDuration(ttl),
+	})
+}
+
+// SendWelcome sends the welcome email.
+func (m *Mailer) SendWelcome(ctx context.Context, to string) error {
+	return m.send(ctx, TemplateWelcome, TemplateData{Email: to})
+}
+
+// SendTest sends a test message, for checking the SMTP settings.
+func (m *Mailer) SendTest(ctx context.Context, to string) error {
+	return m.send(ctx, TemplateTest, TemplateData{Email: to})
+}
+
+func (m *Mailer) send(ctx context.Context, name string, data TemplateData) error {
+	if _, err := mail.ParseAddress(data.Email); err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", data.Email, err)
+	}
+	data.AppName = m.appName
+
+	msg, err := m.templates.Render(name, data)
+	if err != nil {
+		return err
+	}
+	return m.sender.Send(ctx, msg)
+}
+
+func link(tmpl, token string) string {
+	return strings.ReplaceAll(tmpl, "{token}", url.QueryEscape(token))
+}
+
+// humanDuration formats d for email copy, e.g. "7 days", "24 hours" or
+// "30 minutes".
+func humanDuration(d time.Duration) string {
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	const day = 24 * time.Hour
+
+	switch {
+	case d > day && d%day == 0:
+		return plural(int64(d/day), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return plural(int64(d/time.Hour), "hour")
+	case d >= time.Minute && d%time.Minute == 0:
+		return plural(int64(d/time.Minute), "minute")
+	default:
+		return d.String()
+	}
+}
+
+// build encodes msg as an RFC 5322 message from the given sender.
+func build(from string, msg *Message, now time.Time) ([]byte, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", from, err)
+	}
+
+	to := make([]string, 0, len(msg.To))
+	for _, addr := range msg.To {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", addr, err)
+		}
+		to = append(to, parsed.String())
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", sender.String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(sender.Address))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -0,0 +1,232 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+type recordingSender struct {
+	messages []*Message
+}
+
+func (s *recordingSender) Send(_ context.Context, msg *Message) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func TestMailer_SendVerification(t *testing.T) {
+	sender := &recordingSender{}
+	m, err := NewWithSender(&config.EmailConfig{AppName: "Acme"}, "http://localhost:8090/", sender)
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+
+	if err := m.SendVerification(context.Background(), "user@example.com", "tok/en", 24*time.Hour); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(sender.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(sender.messages))
+	}
+
+	msg := sender.messages[0]
+	if msg.Subject != "Verify your email for Acme" {
+		t.Errorf("unexpected subject %q", msg.Subject)
+	}
+	wantLink := "http://localhost:8090/api/auth/verify?token=tok%2Fen"
+	if !strings.Contains(msg.Text, wantLink) || !strings.Contains(msg.HTML, wantLink) {
+		t.Errorf("expected link %s in both parts, got text %q", wantLink, msg.Text)
+	}
+	if !strings.Contains(msg.Text, "24 hours") {
+		t.Errorf("expected expiry in text, got %q", msg.Text)
+	}
+
+	if err := m.SendWelcome(context.Background(), "not an address"); err == nil {
+		t.Error("expected error for invalid recipient")
+	}
+}
+
+func TestLoadTemplates_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	override := `{{define "subject"}}Reset for {{.Email}}{{end}}Go to {{.Link}}`
+	if err := os.WriteFile(filepath.Join(dir, "password_reset.txt"), []byte(override), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sender := &recordingSender{}
+	m, err := NewWithSender(&config.EmailConfig{
+		TemplatesDir: dir,
+		ResetURL:     "https://app.example.com/reset/{token}",
+	}, "http://localhost:8090", sender)
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+	if err := m.SendPasswordReset(context.Background(), "user@example.com", "abc", time.Hour); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	msg := sender.messages[0]
+	if msg.Subject != "Reset for user@example.com" {
+		t.Errorf("unexpected subject %q", msg.Subject)
+	}
+	if msg.Text != "Go to https://app.example.com/reset/abc" {
+		t.Errorf("unexpected text %q", msg.Text)
+	}
+	if !strings.Contains(msg.HTML, "https://app.example.com/reset/abc") {
+		t.Error("expected the built-in HTML part to be kept")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "welcome.txt"), []byte("no subject"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTemplates(dir); err == nil || !strings.Contains(err.Error(), "subject") {
+		t.Errorf("expected missing subject error, got %v", err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	msg := &Message{
+		To:      []string{"user@example.com"},
+		Subject: "Héllo\r\nBcc: evil@example.com",
+		Text:    "plain",
+		HTML:    "<p>html</p>",
+	}
+	data, err := build("Alyx <no-reply@example.com>", msg, time.Now())
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	out := string(data)
+	if strings.Contains(out, "\r\nBcc:") {
+		t.Error("subject must not inject headers")
+	}
+	for _, want := range []string{
+		"From: \"Alyx\" <no-reply@example.com>\r\n",
+		"To: <user@example.com>\r\n",
+		"Content-Type: multipart/alternative;",
+		"text/plain; charset=utf-8",
+		"text/html; charset=utf-8",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in message:\n%s", want, out)
+		}
+	}
+}
+
+// fakeSMTP serves a single SMTP conversation without TLS. rcptReply is sent
+// in response to RCPT TO.
+func fakeSMTP(t *testing.T, rcptReply string) (*config.SMTPConfig, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake ESMTP")
+
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 queued")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-fake")
+				reply("250 8BITMIME")
+			case strings.HasPrefix(cmd, "MAIL"):
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "RCPT"):
+				reply(rcptReply)
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return &config.SMTPConfig{
+		Host:    "127.0.0.1",
+		Port:    addr.Port,
+		TLS:     config.SMTPTLSNone,
+		Timeout: 5 * time.Second,
+	}, received
+}
+
+func TestSMTPSender_Send(t *testing.T) {
+	cfg, received := fakeSMTP(t, "250 ok")
+	sender := NewSMTPSender(cfg, "no-reply@example.com")
+
+	err := sender.Send(context.Background(), &Message{
+		To:      []string{"user@example.com"},
+		Subject: "Hi",
+		Text:    "Hello there",
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	select {
+	case data := <-received:
+		if !strings.Contains(data, "Subject: Hi") || !strings.Contains(data, "Hello there") {
+			t.Errorf("unexpected message data:\n%s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server did not receive the message")
+	}
+}
+
+func TestSMTPSender_Errors(t *testing.T) {
+	cfg, _ := fakeSMTP(t, "550 5.1.1 mailbox unavailable")
+	sender := NewSMTPSender(cfg, "no-reply@example.com")
+
+	msg := &Message{To: []string{"nobody@example.com"}, Subject: "Hi", Text: "x"}
+	err := sender.Send(context.Background(), msg)
+	if err == nil || !strings.Contains(err.Error(), "RCPT TO <nobody@example.com>") || !strings.Contains(err.Error(), "mailbox unavailable") {
+		t.Errorf("expected verbose RCPT error, got %v", err)
+	}
+
+	cfg, _ = fakeSMTP(t, "250 ok")
+	cfg.TLS = config.SMTPTLSStartTLS
+	err = NewSMTPSender(cfg, "no-reply@example.com").Send(context.Background(), msg)
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("expected STARTTLS error, got %v", err)
+	}
+}
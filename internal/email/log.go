@@ -0,0 +1,27 @@
+package email
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+// LogSender writes messages to the log instead of sending them. It is used
+// when email is disabled so verification and reset links are still reachable
+// during development.
+type LogSender struct{}
+
+// NewLogSender creates a LogSender.
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+// Send logs msg's recipients, subject and plain-text body.
+func (s *LogSender) Send(_ context.Context, msg *Message) error {
+	log.Info().
+		Strs("to", msg.To).
+		Str("subject", msg.Subject).
+		Str("body", msg.Text).
+		Msg("Email not sent (email.enabled is false)")
+	return nil
+}
@@ -0,0 +1,129 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+// SMTPSender sends messages through an SMTP server. Errors name the stage of
+// the SMTP conversation that failed and include the server's reply.
+type SMTPSender struct {
+	cfg  *config.SMTPConfig
+	from string
+}
+
+// NewSMTPSender creates a sender for the given server and sender address.
+func NewSMTPSender(cfg *config.SMTPConfig, from string) *SMTPSender {
+	return &SMTPSender{cfg: cfg, from: from}
+}
+
+// Send delivers msg over a new SMTP connection.
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	body, err := build(s.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	sender, _ := mail.ParseAddress(s.from)
+
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.cfg.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("server %s does not advertise AUTH; remove email.smtp.username or check the TLS mode", s.cfg.Host)
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fmt.Errorf("authenticating as %s: %w", s.cfg.Username, err)
+		}
+	}
+
+	if err := client.Mail(sender.Address); err != nil {
+		return fmt.Errorf("MAIL FROM <%s>: %w", sender.Address, err)
+	}
+	for _, to := range msg.To {
+		addr, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("RCPT TO <%s>: %w", addr.Address, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+
+	// The message has been accepted; a failed QUIT does not affect delivery.
+	_ = client.Quit()
+	return nil
+}
+
+// dial connects to the server and negotiates TLS according to the TLS mode.
+func (s *SMTPSender) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	tlsConfig := &tls.Config{
+		ServerName:         s.cfg.Host,
+		InsecureSkipVerify: s.cfg.InsecureSkipVerify, //nolint:gosec // Opt-in for test servers.
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if s.cfg.TLS == config.SMTPTLSImplicit {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake with %s: %w", addr, err)
+		}
+		conn = tlsConn
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading greeting from %s: %w", addr, err)
+	}
+
+	if s.cfg.TLS == config.SMTPTLSStartTLS || s.cfg.TLS == "" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("server " + addr + " does not support STARTTLS; set email.smtp.tls to \"tls\" for implicit TLS or \"none\" to send unencrypted")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS with %s: %w", addr, err)
+		}
+	}
+
+	return client, nil
+}
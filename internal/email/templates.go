@@ -0,0 +1,143 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Template names. Each template is a <name>.txt file, which must define a
+// "subject" block, and an optional <name>.html file for the HTML part.
+const (
+	TemplateVerification    = "verification"
+	TemplatePasswordReset   = "password_reset"
+	TemplateWelcome         = "welcome"
+	TemplateAccountDeletion = "account_deletion"
+	TemplateTest            = "test"
+)
+
+var templateNames = []string{TemplateVerification, TemplatePasswordReset, TemplateWelcome, TemplateAccountDeletion, TemplateTest}
+
+//go:embed templates/*
+var defaultTemplates embed.FS
+
+// TemplateData is the data available to email templates.
+type TemplateData struct {
+	// AppName is email.app_name.
+	AppName string
+	// Email is the recipient's address.
+	Email string
+	// Link is the verification, reset or restore link, if any.
+	Link string
+	// ExpiresIn is how long Link stays valid, e.g. "24 hours".
+	ExpiresIn string
+}
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates renders the built-in email templates and any overrides.
+type Templates struct {
+	templates map[string]*emailTemplate
+}
+
+// LoadTemplates parses the built-in templates. Files in dir with the same
+// name as a built-in template replace it; dir may be empty.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{templates: make(map[string]*emailTemplate, len(templateNames))}
+
+	for _, name := range templateNames {
+		tmpl := &emailTemplate{}
+
+		text, source, err := readTemplate(dir, name+".txt")
+		if err != nil {
+			return nil, err
+		}
+		tmpl.text, err = texttemplate.New(name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", source, err)
+		}
+		if tmpl.text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("parsing %s: missing {{define \"subject\"}} block", source)
+		}
+
+		html, source, err := readTemplate(dir, name+".html")
+		if errors.Is(err, fs.ErrNotExist) {
+			t.templates[name] = tmpl
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tmpl.html, err = htmltemplate.New(name).Parse(html)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", source, err)
+		}
+
+		t.templates[name] = tmpl
+	}
+
+	return t, nil
+}
+
+// readTemplate returns the contents of file from dir, falling back to the
+// built-in template, along with where it was read from.
+func readTemplate(dir, file string) (string, string, error) {
+	if dir != "" {
+		path := filepath.Join(dir, file)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return string(data), path, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", path, fmt.Errorf("reading template: %w", err)
+		}
+	}
+
+	data, err := defaultTemplates.ReadFile("templates/" + file)
+	if err != nil {
+		return "", file, err
+	}
+	return string(data), file, nil
+}
+
+// Render executes the named template for a recipient.
+func (t *Templates) Render(name string, data TemplateData) (*Message, error) {
+	tmpl, ok := t.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, text bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("rendering %s subject: %w", name, err)
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("rendering %s: %w", name, err)
+	}
+
+	msg := &Message{
+		To:      []string{data.Email},
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+	}
+
+	if tmpl.html != nil {
+		var html bytes.Buffer
+		if err := tmpl.html.Execute(&html, data); err != nil {
+			return nil, fmt.Errorf("rendering %s HTML: %w", name, err)
+		}
+		msg.HTML = html.String()
+	}
+
+	return msg, nil
+}
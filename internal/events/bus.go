@@ -9,6 +9,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/requestctx"
 )
 
 // EventHandler is a function that handles an event.
@@ -87,7 +88,12 @@ func (bus *EventBus) Stop() {
 }
 
 // Publish publishes an event to the queue.
+// If the event has no request ID, the one from ctx (if any) is recorded.
 func (bus *EventBus) Publish(ctx context.Context, event *Event) error {
+	if event.Metadata.RequestID == "" {
+		event.Metadata.RequestID = requestctx.RequestID(ctx)
+	}
+
 	if err := bus.store.Create(ctx, event); err != nil {
 		return fmt.Errorf("creating event: %w", err)
 	}
@@ -102,6 +108,16 @@ func (bus *EventBus) Publish(ctx context.Context, event *Event) error {
 	return nil
 }
 
+// Done reports whether the event with the given ID has been processed, or no
+// longer exists.
+func (bus *EventBus) Done(ctx context.Context, id string) (bool, error) {
+	status, err := bus.store.Status(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return status != "pending" && status != "processing", nil
+}
+
 // Subscribe registers a handler for events matching the pattern.
 // Use "*" for source or action to match all.
 func (bus *EventBus) Subscribe(eventType EventType, source, action string, handler EventHandler) {
@@ -182,10 +198,15 @@ func (bus *EventBus) processEvent(ctx context.Context, event *Event) error {
 		return nil
 	}
 
-	// Execute handlers
+	// Execute handlers with the originating request ID so downstream work can be correlated
+	handlerCtx := ctx
+	if event.Metadata.RequestID != "" {
+		handlerCtx = requestctx.WithRequestID(ctx, event.Metadata.RequestID)
+	}
+
 	var handlerErr error
 	for _, handler := range handlers {
-		if err := handler(ctx, event); err != nil {
+		if err := handler(handlerCtx, event); err != nil {
 			log.Error().
 				Err(err).
 				Str("event_id", event.ID).
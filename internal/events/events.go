@@ -0,0 +1,53 @@
+// Package events names the server events sent on the privileged realtime
+// channels, and the interface services publish them through. It has no
+// dependencies, so the auth service and the admin handlers can publish
+// without importing the realtime hub.
+package events
+
+// Privileged realtime channels. Only connections authenticated as an admin
+// user may subscribe to them.
+const (
+	// ChannelAuth carries user and session events.
+	ChannelAuth = "_auth"
+	// ChannelAdmin carries deploy, schema and config events.
+	ChannelAdmin = "_admin"
+)
+
+// Type identifies an event within its channel.
+type Type string
+
+// Events on ChannelAuth.
+const (
+	// UserRegistered carries the new user, as returned by
+	// GET /api/admin/users/{id}.
+	UserRegistered Type = "user.registered"
+	// UserUpdated carries the user after the change.
+	UserUpdated Type = "user.updated"
+	// SessionRevoked carries the user ID and, when a single session ended,
+	// its ID.
+	SessionRevoked Type = "session.revoked"
+)
+
+// Events on ChannelAdmin.
+const (
+	// DeployExecuted carries the deployment, as listed by
+	// GET /api/admin/deploy/history.
+	DeployExecuted Type = "deploy.executed"
+	// SchemaChanged carries the collections whose shape changed.
+	SchemaChanged Type = "schema.changed"
+	// ConfigUpdated carries the config file path and the fields changed.
+	ConfigUpdated Type = "config.updated"
+)
+
+// Publisher sends an event to the clients subscribed to a channel. Publish
+// must not block, and data must encode to JSON.
+type Publisher interface {
+	Publish(channel string, event Type, data any)
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(string, Type, any) {}
+
+// Nop discards every event. Services use it until a publisher is set.
+var Nop Publisher = nopPublisher{}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -136,6 +137,20 @@ func (s *Store) UpdateStatus(ctx context.Context, id string, status string) erro
 	return nil
 }
 
+// Status returns the status of an event, or "" if it does not exist, such as
+// after cleanup removed it.
+func (s *Store) Status(ctx context.Context, id string) (string, error) {
+	var status string
+	err := s.db.QueryRowContext(ctx, `SELECT status FROM events WHERE id = ?`, id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("getting event status: %w", err)
+	}
+	return status, nil
+}
+
 // DeleteOlderThan deletes events older than the given duration.
 func (s *Store) DeleteOlderThan(ctx context.Context, duration time.Duration) error {
 	cutoff := time.Now().UTC().Add(-duration).Format(time.RFC3339)
@@ -0,0 +1,339 @@
+// Package fake fills collections with plausible generated documents for
+// development and demos.
+//
+// Values follow the schema: select values and enums are picked from the
+// declared options, patterns are satisfied by walking the regular
+// expression, and length and numeric limits are respected. String fields
+// get realistic values chosen by their names (email, name, url, phone, ...),
+// and timestamps are spread over a time window. Fields referencing other
+// collections are filled with keys sampled from existing documents; when a
+// referenced collection is empty, documents are generated for it first.
+//
+// Every document passes the same validation and checks as a create through
+// the REST API before it is inserted. The same seed, window and schema
+// produce the same documents.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const (
+	// DefaultWindow is how far back generated timestamps reach.
+	DefaultWindow = 90 * 24 * time.Hour
+	// DefaultParents is the number of documents generated for an empty
+	// referenced collection.
+	DefaultParents = 10
+	// MaxCount is the most documents one call generates for a collection.
+	MaxCount = 10000
+
+	// maxAttempts is how many documents are generated for each insert
+	// before giving up on validation, check or unique failures.
+	maxAttempts = 25
+	// poolLimit is the most existing keys sampled for a reference.
+	poolLimit = 1000
+)
+
+// ErrCollectionNotFound is returned for a collection the schema does not
+// declare.
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// Options configures a generation.
+type Options struct {
+	// Count is the number of documents to generate.
+	Count int
+	// Seed seeds every random choice.
+	Seed int64
+	// Window is how far back from Until timestamps reach. Zero means
+	// DefaultWindow.
+	Window time.Duration
+	// Until is the latest generated timestamp. Zero means now.
+	Until time.Time
+	// Parents is the number of documents generated for each empty
+	// referenced collection. Zero means DefaultParents.
+	Parents int
+	// Tenant, if set, is the tenant field value of tenant-scoped
+	// collections.
+	Tenant string
+}
+
+// Report lists the documents a generation inserted.
+type Report struct {
+	Seed int64 `json:"seed"`
+	// Collections are in the order they were filled, referenced
+	// collections first.
+	Collections []*CollectionReport `json:"collections"`
+	Total       int                 `json:"total"`
+}
+
+// CollectionReport is the number of documents inserted into a collection.
+type CollectionReport struct {
+	Collection string `json:"collection"`
+	Created    int    `json:"created"`
+}
+
+// Generate inserts opts.Count generated documents into collection,
+// generating documents for the collections it references first where they
+// are empty.
+func Generate(ctx context.Context, db *database.DB, s *schema.Schema, collection string, opts Options) (*Report, error) {
+	if _, ok := s.Collections[collection]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, collection)
+	}
+	if opts.Count <= 0 || opts.Count > MaxCount {
+		return nil, fmt.Errorf("count must be between 1 and %d", MaxCount)
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultWindow
+	}
+	if opts.Until.IsZero() {
+		opts.Until = time.Now()
+	}
+	if opts.Parents <= 0 {
+		opts.Parents = DefaultParents
+	}
+
+	g := &generator{
+		db:        db,
+		schema:    s,
+		opts:      opts,
+		rng:       rand.New(rand.NewPCG(uint64(opts.Seed), 0)),
+		until:     opts.Until.UTC().Truncate(time.Second),
+		pools:     make(map[string][]any),
+		used:      make(map[string]map[string]bool),
+		suffixes:  make(map[string]int),
+		sequences: make(map[string]int64),
+		patterns:  make(map[string]*patternGenerator),
+		counters:  counterFields(s),
+		filling:   make(map[string]bool),
+		report:    &Report{Seed: opts.Seed},
+	}
+	g.from = g.until.Add(-opts.Window)
+
+	if err := g.fill(ctx, collection, opts.Count); err != nil {
+		return g.report, err
+	}
+	return g.report, nil
+}
+
+type generator struct {
+	db     *database.DB
+	schema *schema.Schema
+	opts   Options
+	rng    *rand.Rand
+
+	from, until time.Time
+
+	// pools holds the values a reference may take, by collection.field.
+	pools map[string][]any
+	// users holds the user IDs a user reference may take.
+	users []any
+	// used holds the values generated for unique fields, by
+	// collection.field.
+	used     map[string]map[string]bool
+	suffixes map[string]int
+	// sequences holds the last integer primary key, by collection.
+	sequences map[string]int64
+	patterns  map[string]*patternGenerator
+	// counters holds the fields maintained by counters, by
+	// collection.field.
+	counters map[string]bool
+	// filling holds the collections being filled, to detect reference
+	// cycles.
+	filling map[string]bool
+	report  *Report
+}
+
+// fill inserts count documents into the named collection, after filling
+// the empty collections it references.
+func (g *generator) fill(ctx context.Context, name string, count int) error {
+	col := g.schema.Collections[name]
+	if col.PrimaryKeyField() == nil {
+		return fmt.Errorf("%s: collection has no primary key", name)
+	}
+
+	g.filling[name] = true
+	defer delete(g.filling, name)
+
+	for _, f := range col.OrderedFields() {
+		target, field, ok := reference(f)
+		if !ok || target == name || g.omitted(col, f) {
+			continue
+		}
+		pool, err := g.pool(ctx, target, field)
+		if err != nil {
+			return err
+		}
+		if len(pool) > 0 {
+			continue
+		}
+		if g.filling[target] {
+			if f.Nullable {
+				continue
+			}
+			return fmt.Errorf("%s.%s: %s is empty and references %s in a cycle", name, f.Name, target, name)
+		}
+		if err := g.fill(ctx, target, g.opts.Parents); err != nil {
+			return err
+		}
+	}
+
+	c := database.NewCollection(g.db, col)
+	for range count {
+		if err := g.create(ctx, c); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		g.record(name)
+	}
+	return nil
+}
+
+// create inserts one generated document, generating another when the
+// previous one fails validation, a check or a unique constraint.
+func (g *generator) create(ctx context.Context, c *database.Collection) error {
+	col := c.Schema()
+	for attempt := 1; ; attempt++ {
+		doc, err := g.document(ctx, col)
+		if err != nil {
+			return err
+		}
+
+		err = validate(col, doc)
+		if err == nil {
+			var created database.Row
+			if created, err = c.Create(ctx, doc); err == nil {
+				g.remember(col, created)
+				return nil
+			}
+			if !errors.Is(err, database.ErrUniqueViolation) {
+				return err
+			}
+		}
+		if attempt == maxAttempts {
+			return fmt.Errorf("no valid document after %d attempts: %w", maxAttempts, err)
+		}
+	}
+}
+
+// validate runs the checks a create through the REST API runs. The document
+// is passed through JSON first, so the values have the types of a decoded
+// request body.
+func validate(col *schema.Collection, doc database.Row) error {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var input database.Row
+	if err := json.Unmarshal(body, &input); err != nil {
+		return err
+	}
+
+	if verrs := database.ValidateInput(col, input, true); verrs.HasErrors() {
+		return verrs
+	}
+	return col.RunChecks(input, nil)
+}
+
+// remember adds the values of a created document to the reference pools of
+// its collection, so later documents, including those of the same
+// collection, may reference it.
+func (g *generator) remember(col *schema.Collection, doc database.Row) {
+	for name, value := range doc {
+		key := col.Name + "." + name
+		if pool, ok := g.pools[key]; ok && value != nil {
+			g.pools[key] = append(pool, value)
+		}
+	}
+}
+
+func (g *generator) record(name string) {
+	g.report.Total++
+	for _, c := range g.report.Collections {
+		if c.Collection == name {
+			c.Created++
+			return
+		}
+	}
+	g.report.Collections = append(g.report.Collections, &CollectionReport{Collection: name, Created: 1})
+}
+
+// pool returns the existing values of collection.field, loading them on
+// first use.
+func (g *generator) pool(ctx context.Context, collection, field string) ([]any, error) {
+	key := collection + "." + field
+	if pool, ok := g.pools[key]; ok {
+		return pool, nil
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IS NOT NULL ORDER BY %s LIMIT %d", field, collection, field, field, poolLimit)
+	pool, err := g.column(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("sampling %s: %w", key, err)
+	}
+	g.pools[key] = pool
+	return pool, nil
+}
+
+// userPool returns the IDs of existing users, loading them on first use.
+func (g *generator) userPool(ctx context.Context) ([]any, error) {
+	if g.users != nil {
+		return g.users, nil
+	}
+	users, err := g.column(ctx, fmt.Sprintf("SELECT id FROM _alyx_users ORDER BY id LIMIT %d", poolLimit))
+	if err != nil {
+		return nil, fmt.Errorf("sampling users: %w", err)
+	}
+	g.users = append([]any{}, users...)
+	return g.users, nil
+}
+
+func (g *generator) column(ctx context.Context, query string) ([]any, error) {
+	rows, err := g.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []any
+	for rows.Next() {
+		var v any
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// reference returns the collection and field f points at.
+func reference(f *schema.Field) (collection, field string, ok bool) {
+	if f.Relation != nil {
+		field = f.Relation.Field
+		if field == "" {
+			field = "id"
+		}
+		return f.Relation.Collection, field, f.Relation.Collection != ""
+	}
+	return f.ParseReference()
+}
+
+// counterFields returns the fields counters maintain, by collection.field.
+func counterFields(s *schema.Schema) map[string]bool {
+	fields := make(map[string]bool)
+	for _, col := range s.Collections {
+		for _, c := range col.Counters {
+			fields[c.Target+"."+c.TargetField] = true
+		}
+	}
+	return fields
+}
@@ -0,0 +1,296 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const testSchemaYAML = `
+version: 1
+collections:
+  authors:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      name:
+        type: string
+      email:
+        type: email
+        unique: true
+      website:
+        type: url
+        nullable: true
+      post_count:
+        type: int
+        default: "0"
+  posts:
+    fields:
+      id:
+        type: id
+        primary: true
+        default: auto
+      author_id:
+        type: uuid
+        references: authors.id
+      parent_id:
+        type: id
+        nullable: true
+        references: posts.id
+      title:
+        type: string
+        validate:
+          minLength: 5
+          maxLength: 40
+      slug:
+        type: string
+        unique: true
+        slug:
+          from: title
+      status:
+        type: string
+        validate:
+          enum: [draft, published]
+      tags:
+        type: select
+        select:
+          values: [go, sql, web, ops]
+          maxSelect: 2
+      code:
+        type: string
+        unique: true
+        validate:
+          pattern: "^[A-Z]{3}-\\d{4}$"
+      rating:
+        type: int
+        validate:
+          min: 1
+          max: 5
+      price:
+        type: float
+        validate:
+          min: 10
+          max: 20
+      starts_at:
+        type: timestamp
+      ends_at:
+        type: timestamp
+      created_at:
+        type: timestamp
+        default: now
+      updated_at:
+        type: timestamp
+        default: now
+        onUpdate: now
+    checks:
+      - name: ends_after_start
+        expr: "doc.ends_at > doc.starts_at"
+    counters:
+      - target: authors
+        targetField: post_count
+        foreignKey: author_id
+`
+
+var testUntil = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func setup(t *testing.T) (*database.DB, *schema.Schema) {
+	t.Helper()
+	db, err := database.Open(&config.DatabaseConfig{Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(testSchemaYAML))
+	if err != nil {
+		t.Fatalf("Failed to parse test schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to execute SQL: %v\nSQL: %s", err, stmt)
+		}
+	}
+	return db, s
+}
+
+func rows(t *testing.T, db *database.DB, query string) []database.Row {
+	t.Helper()
+	r, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	docs, err := database.ScanRows(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
+
+func TestGenerate(t *testing.T) {
+	db, s := setup(t)
+	ctx := context.Background()
+
+	report, err := Generate(ctx, db, s, "posts", Options{Count: 50, Seed: 1, Until: testUntil, Window: 30 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if report.Total != 60 || len(report.Collections) != 2 ||
+		report.Collections[0].Collection != "authors" || report.Collections[0].Created != DefaultParents ||
+		report.Collections[1].Collection != "posts" || report.Collections[1].Created != 50 {
+		t.Fatalf("Expected the authors to be created before the posts, got %+v", report.Collections)
+	}
+
+	authors := map[string]bool{}
+	for _, a := range rows(t, db, "SELECT * FROM authors") {
+		authors[a["id"].(string)] = true
+		if !regexp.MustCompile(`^[a-z.0-9]+@example\.(com|org|net)$`).MatchString(a["email"].(string)) {
+			t.Errorf("Expected a documentation email address, got %v", a["email"])
+		}
+	}
+
+	code := regexp.MustCompile(`^[A-Z]{3}-\d{4}$`)
+	from := testUntil.Add(-30 * 24 * time.Hour).Format(time.RFC3339)
+	until := testUntil.Format(time.RFC3339)
+	var children int
+	for _, p := range rows(t, db, "SELECT * FROM posts") {
+		if !authors[p["author_id"].(string)] {
+			t.Errorf("Expected author_id to reference an author, got %v", p["author_id"])
+		}
+		if p["parent_id"] != nil {
+			children++
+		}
+		if title := p["title"].(string); len(title) < 5 || len(title) > 40 {
+			t.Errorf("Expected title within its length limits, got %q", title)
+		}
+		if p["slug"] == nil || p["slug"] == "" {
+			t.Errorf("Expected the server to generate a slug for %v", p["title"])
+		}
+		if p["status"] != "draft" && p["status"] != "published" {
+			t.Errorf("Expected status from the enum, got %v", p["status"])
+		}
+		var tags []string
+		if err := json.Unmarshal([]byte(p["tags"].(string)), &tags); err != nil || len(tags) == 0 || len(tags) > 2 {
+			t.Errorf("Expected one or two tags, got %v", p["tags"])
+		}
+		if !code.MatchString(p["code"].(string)) {
+			t.Errorf("Expected code to match its pattern, got %v", p["code"])
+		}
+		if r := p["rating"].(int64); r < 1 || r > 5 {
+			t.Errorf("Expected rating within 1..5, got %d", r)
+		}
+		if price := p["price"].(float64); price < 10 || price > 20 {
+			t.Errorf("Expected price within 10..20, got %v", price)
+		}
+		created, updated := p["created_at"].(string), p["updated_at"].(string)
+		if created < from || created > until || updated < created || updated > until {
+			t.Errorf("Expected created_at <= updated_at within the window, got %s and %s", created, updated)
+		}
+		if p["ends_at"].(string) <= p["starts_at"].(string) {
+			t.Errorf("Expected the check to hold, got %v and %v", p["starts_at"], p["ends_at"])
+		}
+	}
+	if children == 0 {
+		t.Error("Expected some posts to reference earlier posts")
+	}
+
+	var counted int64
+	if err := db.QueryRow("SELECT SUM(post_count) FROM authors").Scan(&counted); err != nil || counted != 50 {
+		t.Errorf("Expected the counter to count the posts, got %d (%v)", counted, err)
+	}
+
+	// Existing authors are referenced rather than created again.
+	report, err = Generate(ctx, db, s, "posts", Options{Count: 5, Seed: 2, Until: testUntil})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if report.Total != 5 || len(report.Collections) != 1 {
+		t.Errorf("Expected only posts to be created, got %+v", report.Collections)
+	}
+}
+
+func TestGenerateSeed(t *testing.T) {
+	dump := func(seed int64) string {
+		db, s := setup(t)
+		if _, err := Generate(context.Background(), db, s, "posts", Options{Count: 20, Seed: seed, Until: testUntil}); err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		b, _ := json.Marshal([][]database.Row{
+			rows(t, db, "SELECT * FROM authors ORDER BY id"),
+			rows(t, db, "SELECT * FROM posts ORDER BY id"),
+		})
+		return string(b)
+	}
+
+	if dump(7) != dump(7) {
+		t.Error("Expected the same seed to generate the same documents")
+	}
+	if dump(7) == dump(8) {
+		t.Error("Expected different seeds to generate different documents")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	db, s := setup(t)
+	ctx := context.Background()
+
+	if _, err := Generate(ctx, db, s, "missing", Options{Count: 1}); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+	if _, err := Generate(ctx, db, s, "posts", Options{Count: MaxCount + 1}); err == nil {
+		t.Error("Expected an error for a count over the limit")
+	}
+}
+
+func TestPatternGenerator(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 0))
+	for _, pattern := range []string{
+		`^[A-Z]{2}\d{3}$`,
+		`^(foo|bar)-[a-f0-9]+$`,
+		`^\w+@\w+\.com$`,
+		`^[^,\s]{4,8}$`,
+		`^(?i)abc?x*$`,
+		`^\+\d{1,3} \d{3}-\d{4}$`,
+	} {
+		p, err := newPatternGenerator(pattern)
+		if err != nil {
+			t.Fatalf("%s: %v", pattern, err)
+		}
+		for range 50 {
+			s, err := p.generate(rng)
+			if err != nil {
+				t.Fatalf("%s: %v", pattern, err)
+			}
+			if !p.re.MatchString(s) {
+				t.Fatalf("%s: generated %q", pattern, s)
+			}
+		}
+	}
+}
+
+func TestNameTokens(t *testing.T) {
+	tests := map[string][]string{
+		"avatar_url":  {"avatar", "url"},
+		"avatarURL":   {"avatar", "url"},
+		"HTTPStatus":  {"http", "status"},
+		"first-name":  {"first", "name"},
+		"createdAt":   {"created", "at"},
+		"phonenumber": {"phonenumber"},
+	}
+	for name, want := range tests {
+		if got := nameTokens(name); !slices.Equal(got, want) {
+			t.Errorf("nameTokens(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
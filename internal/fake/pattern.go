@@ -0,0 +1,124 @@
+package fake
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// maxRepeat bounds the repetitions generated for *, + and open-ended
+// {n,} quantifiers.
+const maxRepeat = 4
+
+// patternGenerator produces strings matching a validation pattern by walking
+// its parsed syntax tree.
+type patternGenerator struct {
+	re   *regexp.Regexp
+	tree *syntax.Regexp
+}
+
+func newPatternGenerator(pattern string) (*patternGenerator, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	return &patternGenerator{re: re, tree: tree.Simplify()}, nil
+}
+
+// generate returns a string matching the pattern. Patterns the walk cannot
+// satisfy, such as those relying on anchors in the middle of the
+// expression, are reported as errors.
+func (p *patternGenerator) generate(rng *rand.Rand) (string, error) {
+	for range 10 {
+		var b strings.Builder
+		writePattern(&b, p.tree, rng)
+		if s := b.String(); p.re.MatchString(s) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("cannot generate a value matching pattern %q", p.re.String())
+}
+
+func writePattern(b *strings.Builder, re *syntax.Regexp, rng *rand.Rand) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && rng.IntN(2) == 0 {
+				r = unicode.SimpleFold(r)
+			}
+			b.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		b.WriteRune(classRune(re.Rune, rng))
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		b.WriteByte(alphanumeric[rng.IntN(len(alphanumeric))])
+	case syntax.OpCapture:
+		writePattern(b, re.Sub[0], rng)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writePattern(b, sub, rng)
+		}
+	case syntax.OpAlternate:
+		writePattern(b, re.Sub[rng.IntN(len(re.Sub))], rng)
+	case syntax.OpStar:
+		writeRepeat(b, re.Sub[0], 0, maxRepeat, rng)
+	case syntax.OpPlus:
+		writeRepeat(b, re.Sub[0], 1, maxRepeat, rng)
+	case syntax.OpQuest:
+		writeRepeat(b, re.Sub[0], 0, 1, rng)
+	case syntax.OpRepeat:
+		hi := re.Max
+		if hi < 0 {
+			hi = re.Min + maxRepeat
+		}
+		writeRepeat(b, re.Sub[0], re.Min, hi, rng)
+	default:
+		// Empty matches, anchors and word boundaries produce no text.
+	}
+}
+
+func writeRepeat(b *strings.Builder, re *syntax.Regexp, lo, hi int, rng *rand.Rand) {
+	for range lo + rng.IntN(hi-lo+1) {
+		writePattern(b, re, rng)
+	}
+}
+
+// classRune picks a rune uniformly from a character class given as inclusive
+// ranges, preferring printable ASCII so that negated classes such as [^,]
+// yield readable text.
+func classRune(ranges []rune, rng *rand.Rand) rune {
+	var printable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := max(ranges[i], '!'), min(ranges[i+1], '~')
+		if lo <= hi {
+			printable = append(printable, lo, hi)
+		}
+	}
+	if len(printable) > 0 {
+		ranges = printable
+	}
+	if len(ranges) == 0 {
+		return 'x'
+	}
+
+	size := 0
+	for i := 0; i+1 < len(ranges); i += 2 {
+		size += int(ranges[i+1]-ranges[i]) + 1
+	}
+	n := rng.IntN(size)
+	for i := 0; i+1 < len(ranges); i += 2 {
+		width := int(ranges[i+1]-ranges[i]) + 1
+		if n < width {
+			return ranges[i] + rune(n)
+		}
+		n -= width
+	}
+	return ranges[0]
+}
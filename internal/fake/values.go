@@ -0,0 +1,583 @@
+package fake
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const (
+	// nullOdds is one in how many values of a nullable field are left null.
+	nullOdds = 10
+	// uniqueTries is how many values are generated for a unique field
+	// before giving up; from the third on, a counter suffix is added to
+	// strings.
+	uniqueTries = 10
+
+	alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// personCollections are collection names whose name field holds a person's
+// name rather than a title.
+var personCollections = []string{
+	"users", "authors", "people", "persons", "members", "customers", "contacts", "employees",
+	"profiles", "students", "teachers", "staff", "accounts", "players", "patients", "clients",
+}
+
+// document generates a document for col. The values of one document are
+// related where their names suggest it, e.g. updated_at follows created_at.
+func (g *generator) document(ctx context.Context, col *schema.Collection) (database.Row, error) {
+	doc := database.Row{}
+	created := g.instant(g.from, g.until)
+	for _, f := range col.OrderedFields() {
+		if g.omitted(col, f) {
+			continue
+		}
+		v, err := g.value(ctx, col, f, created)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if v != nil {
+			doc[f.Name] = v
+		}
+	}
+	return doc, nil
+}
+
+// omitted reports whether f is left to the server: slugs, counters, and
+// file and bucket-stored blob fields that may be empty.
+func (g *generator) omitted(col *schema.Collection, f *schema.Field) bool {
+	switch {
+	case f.IsSlug(), g.counters[col.Name+"."+f.Name]:
+		return true
+	case f.Type == schema.FieldTypeFile:
+		return f.Nullable || f.HasDefault()
+	case f.Type == schema.FieldTypeBlob && f.Storage != "":
+		return true
+	}
+	return false
+}
+
+func (g *generator) value(ctx context.Context, col *schema.Collection, f *schema.Field, created time.Time) (any, error) {
+	if col.Tenant != nil && f.Name == col.Tenant.Field && g.opts.Tenant != "" {
+		return g.opts.Tenant, nil
+	}
+	if f.Primary && f.Type == schema.FieldTypeInt {
+		return g.sequence(ctx, col, f)
+	}
+	if f.Nullable && !f.Primary && g.rng.IntN(nullOdds) == 0 {
+		return nil, nil
+	}
+
+	if f.OnUserDelete != "" {
+		users, err := g.userPool(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) == 0 {
+			if f.Nullable {
+				return nil, nil
+			}
+			return nil, errors.New("no users to reference; create one with alyx users create")
+		}
+		return users[g.rng.IntN(len(users))], nil
+	}
+
+	if target, field, ok := reference(f); ok {
+		pool, err := g.pool(ctx, target, field)
+		if err != nil {
+			return nil, err
+		}
+		if len(pool) == 0 {
+			if f.Nullable {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("no %s documents to reference", target)
+		}
+		return pool[g.rng.IntN(len(pool))], nil
+	}
+
+	if !f.Unique && !f.Primary {
+		return g.generate(col, f, created)
+	}
+
+	key := col.Name + "." + f.Name
+	used := g.used[key]
+	if used == nil {
+		used = make(map[string]bool)
+		g.used[key] = used
+	}
+	for try := range uniqueTries {
+		v, err := g.generate(col, f, created)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := v.(string); ok && try >= 2 {
+			g.suffixes[key]++
+			v = withSuffix(s, g.suffixes[key], maxLength(f))
+		}
+		if s := fmt.Sprint(v); !used[s] {
+			used[s] = true
+			return v, nil
+		}
+	}
+	return nil, errors.New("no unused value left for a unique field")
+}
+
+// sequence returns the next integer primary key of col.
+func (g *generator) sequence(ctx context.Context, col *schema.Collection, f *schema.Field) (int64, error) {
+	last, ok := g.sequences[col.Name]
+	if !ok {
+		query := fmt.Sprintf("SELECT COALESCE(MAX(%s), 0) FROM %s", f.Name, col.Name)
+		if err := g.db.QueryRowContext(ctx, query).Scan(&last); err != nil {
+			return 0, fmt.Errorf("reading the last %s: %w", f.Name, err)
+		}
+	}
+	last++
+	g.sequences[col.Name] = last
+	return last, nil
+}
+
+// generate returns a value for f honoring its type and validation.
+func (g *generator) generate(col *schema.Collection, f *schema.Field, created time.Time) (any, error) {
+	v := f.Validate
+	if v != nil && len(v.Enum) > 0 {
+		return pick(g, v.Enum), nil
+	}
+
+	switch f.Type {
+	case schema.FieldTypeSelect:
+		return g.selectValue(f), nil
+	case schema.FieldTypeBool:
+		return g.rng.IntN(2) == 0, nil
+	case schema.FieldTypeInt, schema.FieldTypeFloat:
+		return g.number(f), nil
+	case schema.FieldTypeTimestamp:
+		return g.timestamp(f, created).Format(time.RFC3339), nil
+	case schema.FieldTypeDate:
+		return g.date(f).Format(time.DateOnly), nil
+	case schema.FieldTypeJSON:
+		return g.jsonValue(f), nil
+	case schema.FieldTypeBlob:
+		b := make([]byte, 16+g.rng.IntN(48))
+		for i := range b {
+			b[i] = byte(g.rng.UintN(256))
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	case schema.FieldTypeID:
+		return g.shortID(), nil
+	case schema.FieldTypeUUID:
+		return g.uuid(), nil
+	case schema.FieldTypeFile:
+		return nil, errors.New("file fields cannot be generated; make the field nullable")
+	}
+
+	if v != nil && v.Pattern != "" {
+		p, ok := g.patterns[v.Pattern]
+		if !ok {
+			var err error
+			if p, err = newPatternGenerator(v.Pattern); err != nil {
+				return nil, err
+			}
+			g.patterns[v.Pattern] = p
+		}
+		return p.generate(g.rng)
+	}
+
+	format := ""
+	if v != nil {
+		format = v.Format
+	}
+	switch {
+	case f.Type == schema.FieldTypeEmail || format == "email":
+		return g.email(), nil
+	case f.Type == schema.FieldTypeURL || format == "url" || format == "uri":
+		return g.url(f.Name), nil
+	case format == "uuid":
+		return g.uuid(), nil
+	}
+
+	var s string
+	switch f.Type {
+	case schema.FieldTypeText:
+		s = g.paragraph()
+	case schema.FieldTypeRichText:
+		paragraphs := make([]string, 1+g.rng.IntN(3))
+		for i := range paragraphs {
+			paragraphs[i] = "<p>" + g.paragraph() + "</p>"
+		}
+		s = strings.Join(paragraphs, "")
+	default:
+		s = g.text(col, f)
+	}
+	return g.fitLength(s, minLength(f), maxLength(f)), nil
+}
+
+// text returns a string for f, chosen by the field's name.
+func (g *generator) text(col *schema.Collection, f *schema.Field) string {
+	name := strings.ToLower(f.Name)
+	tokens := nameTokens(f.Name)
+	has := func(names ...string) bool {
+		return slices.ContainsFunc(names, func(w string) bool { return slices.Contains(tokens, w) })
+	}
+
+	switch {
+	case strings.Contains(name, "email"):
+		return g.email()
+	case strings.Contains(name, "firstname") || has("first", "given"):
+		return pick(g, firstNames)
+	case strings.Contains(name, "lastname") || has("last", "surname", "family"):
+		return pick(g, lastNames)
+	case has("username", "handle", "login", "nickname", "nick"):
+		return strings.ToLower(pick(g, firstNames) + pick(g, []string{".", "_", ""}) + pick(g, words))
+	case has("company", "organization", "organisation", "org", "business", "brand", "employer"):
+		return pick(g, companyWords) + " " + pick(g, companySuffixes)
+	case has("avatar", "image", "photo", "picture", "thumbnail", "cover", "logo", "icon", "banner"):
+		return fmt.Sprintf("https://picsum.photos/seed/%s%d/640/480", pick(g, words), g.rng.IntN(1000))
+	case has("url", "website", "link", "homepage", "site", "uri", "href"):
+		return g.url(f.Name)
+	case has("phone", "mobile", "tel", "telephone", "fax", "cell"):
+		return fmt.Sprintf("+1-%03d-555-%04d", 201+g.rng.IntN(789), 100+g.rng.IntN(100))
+	case has("city", "town"):
+		return pick(g, cities)
+	case has("country"):
+		return pick(g, countries)
+	case has("address", "street"):
+		return fmt.Sprintf("%d %s %s", 1+g.rng.IntN(9999), pick(g, streetNames), pick(g, streetSuffixes))
+	case has("zip", "postal", "postcode", "zipcode"):
+		return fmt.Sprintf("%05d", g.rng.IntN(100000))
+	case has("color", "colour"):
+		return fmt.Sprintf("#%06x", g.rng.IntN(1<<24))
+	case has("currency"):
+		return pick(g, currencies)
+	case has("locale", "language", "lang"):
+		return pick(g, locales)
+	case has("ip"):
+		return fmt.Sprintf("%s.%d", pick(g, []string{"192.0.2", "198.51.100", "203.0.113"}), 1+g.rng.IntN(254))
+	case has("password", "hash", "token", "secret", "key"):
+		return g.alphanumeric(32)
+	case has("code", "sku", "ref", "reference"):
+		return strings.ToUpper(g.alphanumeric(8))
+	case has("slug"):
+		return strings.Join(g.words(words, 2+g.rng.IntN(3)), "-")
+	case has("title", "headline", "subject", "heading", "caption"):
+		return titleCase(g.words(words, 2+g.rng.IntN(5)))
+	case has("description", "summary", "bio", "about", "content", "body", "excerpt", "comment",
+		"message", "note", "notes", "text", "details", "review"):
+		return g.sentence()
+	case has("tag", "category", "status", "type", "kind", "label", "group", "role"):
+		return pick(g, words)
+	case has("name"):
+		if has("full", "display", "author", "contact", "customer", "person", "owner") ||
+			slices.Contains(personCollections, strings.ToLower(col.Name)) {
+			return pick(g, firstNames) + " " + pick(g, lastNames)
+		}
+		return titleCase(g.words(words, 1+g.rng.IntN(2)))
+	}
+	return strings.Join(g.words(words, 1+g.rng.IntN(3)), " ")
+}
+
+func (g *generator) email() string {
+	local := strings.ToLower(pick(g, firstNames) + "." + pick(g, lastNames))
+	if g.rng.IntN(3) == 0 {
+		local += fmt.Sprint(g.rng.IntN(100))
+	}
+	return local + "@" + pick(g, emailDomains)
+}
+
+func (g *generator) url(field string) string {
+	tokens := nameTokens(field)
+	if slices.Contains(tokens, "website") || slices.Contains(tokens, "homepage") {
+		return "https://" + pick(g, words) + "." + pick(g, emailDomains)
+	}
+	return "https://" + pick(g, emailDomains) + "/" + strings.Join(g.words(words, 1+g.rng.IntN(2)), "-")
+}
+
+func (g *generator) sentence() string {
+	s := strings.Join(g.words(loremWords, 6+g.rng.IntN(10)), " ")
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
+
+func (g *generator) paragraph() string {
+	sentences := make([]string, 2+g.rng.IntN(3))
+	for i := range sentences {
+		sentences[i] = g.sentence()
+	}
+	return strings.Join(sentences, " ")
+}
+
+func (g *generator) words(list []string, n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = pick(g, list)
+	}
+	return out
+}
+
+func (g *generator) alphanumeric(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphanumeric[g.rng.IntN(len(alphanumeric))]
+	}
+	return string(b)
+}
+
+func (g *generator) shortID() string {
+	return g.alphanumeric(15)
+}
+
+// uuid returns a version 4 UUID drawn from the generator's random source.
+func (g *generator) uuid() string {
+	var u uuid.UUID
+	for i := range u {
+		u[i] = byte(g.rng.UintN(256))
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u.String()
+}
+
+// fitLength pads s with words to at least lo bytes, and cuts it to at most
+// hi bytes when hi is positive.
+func (g *generator) fitLength(s string, lo, hi int) string {
+	for len(s) < lo {
+		s += " " + pick(g, loremWords)
+	}
+	if hi > 0 && len(s) > hi {
+		s = strings.TrimRight(s[:hi], " ")
+		for len(s) < lo {
+			s += "x"
+		}
+	}
+	return s
+}
+
+func (g *generator) selectValue(f *schema.Field) any {
+	values := f.Select.Values
+	if len(values) == 0 {
+		return nil
+	}
+	if !f.Select.IsMultiple() {
+		return pick(g, values)
+	}
+
+	limit := len(values)
+	if f.Select.MaxSelect > 0 {
+		limit = min(limit, f.Select.MaxSelect)
+	}
+	order := g.rng.Perm(len(values))[:1+g.rng.IntN(limit)]
+	slices.Sort(order)
+	selected := make([]string, len(order))
+	for i, j := range order {
+		selected[i] = values[j]
+	}
+	return selected
+}
+
+// number returns an int64 or float64 for f within the range its name
+// suggests, narrowed to its validation limits.
+func (g *generator) number(f *schema.Field) any {
+	lo, hi := g.numberRange(f.Name)
+	if v := f.Validate; v != nil {
+		switch {
+		case v.Min != nil && v.Max != nil:
+			if *v.Min > hi || *v.Max < lo {
+				lo, hi = *v.Min, *v.Max
+			} else {
+				lo, hi = max(lo, *v.Min), min(hi, *v.Max)
+			}
+		case v.Min != nil:
+			lo = max(lo, *v.Min)
+			if hi < lo {
+				hi = lo + 1000
+			}
+		case v.Max != nil:
+			hi = min(hi, *v.Max)
+			if lo > hi {
+				lo = hi - 1000
+			}
+		}
+	}
+
+	if f.Type == schema.FieldTypeInt {
+		ilo, ihi := int64(math.Ceil(lo)), int64(math.Floor(hi))
+		if ihi < ilo {
+			return ilo
+		}
+		return ilo + g.rng.Int64N(ihi-ilo+1)
+	}
+	n := math.Round((lo+g.rng.Float64()*(hi-lo))*100) / 100
+	return math.Min(math.Max(n, lo), hi)
+}
+
+func (g *generator) numberRange(name string) (lo, hi float64) {
+	tokens := nameTokens(name)
+	has := func(names ...string) bool {
+		return slices.ContainsFunc(names, func(w string) bool { return slices.Contains(tokens, w) })
+	}
+
+	switch {
+	case has("age"):
+		return 18, 80
+	case has("year"):
+		return 1990, float64(g.until.Year())
+	case has("rating", "stars"):
+		return 1, 5
+	case has("score", "percent", "percentage", "progress"):
+		return 0, 100
+	case has("price", "amount", "cost", "total", "fee", "balance", "subtotal"):
+		return 1, 500
+	case has("quantity", "qty", "stock", "count", "inventory"):
+		return 0, 250
+	case has("views", "likes", "followers", "visits", "clicks", "downloads", "votes"):
+		return 0, 10000
+	case has("position", "order", "rank", "priority", "sort", "index"):
+		return 1, 100
+	case has("lat", "latitude"):
+		return -90, 90
+	case has("lng", "lon", "longitude"):
+		return -180, 180
+	case has("minutes", "duration"):
+		return 1, 240
+	case has("seconds"):
+		return 1, 3600
+	}
+	return 0, 1000
+}
+
+// timestamp returns a time within the window. Creation times are the
+// document's creation time, and update times follow it.
+func (g *generator) timestamp(f *schema.Field, created time.Time) time.Time {
+	tokens := nameTokens(f.Name)
+	has := func(names ...string) bool {
+		return slices.ContainsFunc(names, func(w string) bool { return slices.Contains(tokens, w) })
+	}
+
+	switch {
+	case f.IsAutoUpdateTimestamp() || has("updated", "modified", "edited", "changed"):
+		return g.instant(created, g.until)
+	case f.IsTimestampNow() || has("created", "inserted", "joined", "registered"):
+		return created
+	}
+	return g.instant(g.from, g.until)
+}
+
+func (g *generator) date(f *schema.Field) time.Time {
+	tokens := nameTokens(f.Name)
+	if slices.Contains(tokens, "birth") || slices.Contains(tokens, "birthday") || slices.Contains(tokens, "dob") {
+		return g.until.AddDate(-18-g.rng.IntN(62), 0, -g.rng.IntN(365))
+	}
+	return g.instant(g.from, g.until)
+}
+
+// instant returns a time between lo and hi, to the second.
+func (g *generator) instant(lo, hi time.Time) time.Time {
+	span := int64(hi.Sub(lo) / time.Second)
+	if span <= 0 {
+		return lo
+	}
+	return lo.Add(time.Duration(g.rng.Int64N(span+1)) * time.Second)
+}
+
+func (g *generator) jsonValue(f *schema.Field) any {
+	tokens := nameTokens(f.Name)
+	if slices.ContainsFunc([]string{"tags", "labels", "keywords", "categories"}, func(w string) bool { return slices.Contains(tokens, w) }) {
+		return g.words(words, 1+g.rng.IntN(4))
+	}
+	return map[string]any{
+		"source":  pick(g, words),
+		"score":   g.rng.IntN(100),
+		"enabled": g.rng.IntN(2) == 0,
+	}
+}
+
+func pick(g *generator, list []string) string {
+	return list[g.rng.IntN(len(list))]
+}
+
+// withSuffix adds a counter to s to make it unique, before the @ of an
+// email address, keeping the result within maxLen bytes when it is
+// positive.
+func withSuffix(s string, n, maxLen int) string {
+	suffix := fmt.Sprint(n)
+	if at := strings.LastIndex(s, "@"); at > 0 {
+		return s[:at] + suffix + s[at:]
+	}
+	suffix = "-" + suffix
+	if maxLen > 0 && len(s)+len(suffix) > maxLen {
+		s = s[:max(0, maxLen-len(suffix))]
+	}
+	return s + suffix
+}
+
+func minLength(f *schema.Field) int {
+	n := 0
+	if f.MinLength != nil {
+		n = *f.MinLength
+	}
+	if f.Validate != nil && f.Validate.MinLength != nil {
+		n = max(n, *f.Validate.MinLength)
+	}
+	return n
+}
+
+// maxLength returns the tighter of f's length limits, or zero for none.
+func maxLength(f *schema.Field) int {
+	limits := []*int{f.MaxLength}
+	if f.Validate != nil {
+		limits = append(limits, f.Validate.MaxLength)
+	}
+	n := 0
+	for _, limit := range limits {
+		if limit != nil && (n == 0 || *limit < n) {
+			n = *limit
+		}
+	}
+	return n
+}
+
+// nameTokens splits a field name into lowercase words at underscores,
+// hyphens and camelCase boundaries: "avatarURL" gives avatar and url.
+func nameTokens(name string) []string {
+	var tokens []string
+	var current []rune
+	runes := []rune(name)
+	flush := func() {
+		if len(current) > 0 {
+			tokens = append(tokens, strings.ToLower(string(current)))
+			current = current[:0]
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || r == '.' || unicode.IsSpace(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))):
+			flush()
+		}
+		current = append(current, r)
+	}
+	flush()
+	return tokens
+}
+
+func titleCase(words []string) string {
+	out := make([]string, len(words))
+	for i, w := range words {
+		out[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(out, " ")
+}
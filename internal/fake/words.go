@@ -0,0 +1,73 @@
+package fake
+
+// Curated values for the field name heuristics. Emails, phone numbers, IP
+// addresses and domains use ranges reserved for documentation, so generated
+// data never reaches a real person or host.
+
+var firstNames = []string{
+	"Ada", "Alan", "Alice", "Amara", "Ben", "Carla", "Chen", "Daniel", "Diego", "Elena",
+	"Emma", "Farah", "Felix", "Grace", "Hana", "Hugo", "Ines", "Isaac", "Jamal", "Julia",
+	"Kai", "Keiko", "Leo", "Lina", "Marco", "Maya", "Nadia", "Noah", "Olga", "Omar",
+	"Priya", "Quinn", "Rosa", "Sam", "Sofia", "Tariq", "Uma", "Victor", "Wen", "Yusuf",
+	"Zara", "Zoe",
+}
+
+var lastNames = []string{
+	"Adams", "Baker", "Chen", "Costa", "Dubois", "Evans", "Fischer", "Garcia", "Hansen", "Ito",
+	"Jensen", "Kim", "Kowalski", "Lopez", "Martin", "Mensah", "Nakamura", "Novak", "Okafor", "Patel",
+	"Quinn", "Rossi", "Santos", "Schmidt", "Silva", "Singh", "Tanaka", "Turner", "Usman", "Virtanen",
+	"Walker", "Weber", "Xu", "Yilmaz", "Young", "Zimmerman",
+}
+
+var companyWords = []string{
+	"Acme", "Apex", "Blue Harbor", "Brightline", "Cedar", "Copperleaf", "Evergreen", "Foxglove",
+	"Granite", "Helix", "Juniper", "Kestrel", "Lumen", "Meridian", "Northwind", "Orbit",
+	"Pinecrest", "Quartz", "Redwood", "Silverline", "Summit", "Tidewater", "Vertex", "Willow",
+}
+
+var companySuffixes = []string{"Labs", "Systems", "Group", "Studio", "Co.", "Works", "Partners", "Inc."}
+
+var cities = []string{
+	"Amsterdam", "Austin", "Barcelona", "Berlin", "Buenos Aires", "Cape Town", "Chicago", "Copenhagen",
+	"Dublin", "Helsinki", "Lagos", "Lisbon", "London", "Melbourne", "Mexico City", "Montreal",
+	"Mumbai", "Nairobi", "Osaka", "Paris", "Portland", "Seoul", "Singapore", "Toronto", "Vienna",
+}
+
+var countries = []string{
+	"Argentina", "Australia", "Brazil", "Canada", "Denmark", "Finland", "France", "Germany",
+	"India", "Ireland", "Japan", "Kenya", "Mexico", "Netherlands", "Nigeria", "Portugal",
+	"Singapore", "South Africa", "South Korea", "Spain", "United Kingdom", "United States",
+}
+
+var streetNames = []string{
+	"Maple", "Oak", "Pine", "Cedar", "Elm", "Hillside", "Lakeview", "Main", "Park", "River",
+	"Sunset", "Washington", "Highland", "Mill", "Church", "Station",
+}
+
+var streetSuffixes = []string{"St", "Ave", "Rd", "Ln", "Blvd", "Way", "Ct"}
+
+var emailDomains = []string{"example.com", "example.org", "example.net"}
+
+var currencies = []string{"USD", "EUR", "GBP", "JPY", "CAD", "AUD", "CHF", "SEK"}
+
+var locales = []string{"en-US", "en-GB", "de-DE", "fr-FR", "es-ES", "pt-BR", "ja-JP", "nl-NL"}
+
+var words = []string{
+	"alpha", "amber", "anchor", "arrow", "atlas", "autumn", "beacon", "birch", "bloom", "breeze",
+	"bridge", "canvas", "canyon", "cascade", "cloud", "comet", "coral", "crystal", "delta", "dune",
+	"echo", "ember", "falcon", "fern", "field", "flint", "forest", "galaxy", "garden", "glacier",
+	"harbor", "horizon", "island", "jade", "lagoon", "lantern", "maple", "meadow", "mesa", "mist",
+	"nebula", "oasis", "orchid", "pebble", "prairie", "quartz", "rain", "reef", "ridge", "river",
+	"sage", "shadow", "sierra", "spark", "spring", "stone", "storm", "summit", "thunder", "tide",
+	"timber", "valley", "velvet", "willow", "winter", "zephyr",
+}
+
+var loremWords = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do",
+	"eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore", "magna", "aliqua", "enim",
+	"ad", "minim", "veniam", "quis", "nostrud", "exercitation", "ullamco", "laboris", "nisi",
+	"aliquip", "ex", "ea", "commodo", "consequat", "duis", "aute", "irure", "in", "reprehenderit",
+	"voluptate", "velit", "esse", "cillum", "fugiat", "nulla", "pariatur", "excepteur", "sint",
+	"occaecat", "cupidatat", "non", "proident", "sunt", "culpa", "qui", "officia", "deserunt",
+	"mollit", "anim", "id", "est", "laborum",
+}
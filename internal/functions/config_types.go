@@ -21,6 +21,7 @@ type HookConfig struct {
 	Source       string              `yaml:"source" json:"source"`
 	Action       string              `yaml:"action" json:"action"`
 	Mode         string              `yaml:"mode" json:"mode"`
+	Fields       []string            `yaml:"fields" json:"fields,omitempty"`
 	Config       map[string]any      `yaml:"config" json:"config"`
 	Verification *VerificationConfig `yaml:"verification" json:"verification,omitempty"`
 }
@@ -31,13 +32,17 @@ type ScheduleConfig struct {
 	Type       string         `yaml:"type" json:"type"`
 	Expression string         `yaml:"expression" json:"expression"`
 	Timezone   string         `yaml:"timezone" json:"timezone"`
+	Overlap    string         `yaml:"overlap" json:"overlap,omitempty"`
+	Jitter     string         `yaml:"jitter" json:"jitter,omitempty"`
+	CatchUp    string         `yaml:"catch_up" json:"catch_up,omitempty"`
 	Config     map[string]any `yaml:"config" json:"config"`
 	Input      map[string]any `yaml:"input" json:"input"`
 }
 
 // VerificationConfig represents webhook verification configuration.
 type VerificationConfig struct {
-	Type   string `yaml:"type" json:"type"`
-	Header string `yaml:"header" json:"header"`
-	Secret string `yaml:"secret" json:"secret"`
+	Type      string `yaml:"type" json:"type"`
+	Header    string `yaml:"header" json:"header"`
+	Secret    string `yaml:"secret" json:"secret"`
+	Tolerance string `yaml:"tolerance" json:"tolerance,omitempty"`
 }
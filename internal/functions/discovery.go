@@ -12,6 +12,7 @@ import (
 type FunctionDef struct {
 	Name        string            `json:"name"`
 	Runtime     Runtime           `json:"runtime"`
+	Mode        string            `json:"mode"`
 	Path        string            `json:"path"`
 	OutputPath  string            `json:"output_path,omitempty"`
 	Description string            `json:"description,omitempty"`
@@ -24,10 +25,23 @@ type FunctionDef struct {
 	Routes      []RouteConfig     `json:"routes,omitempty"`
 	Hooks       []HookConfig      `json:"hooks,omitempty"`
 	Schedules   []ScheduleConfig  `json:"schedules,omitempty"`
+	Status      FunctionStatus    `json:"status"`
+	Error       string            `json:"error,omitempty"`
+	LastBuild   *BuildResult      `json:"last_build,omitempty"`
+	// NativeBinary is the current native build of a native mode function.
+	NativeBinary string `json:"-"`
+	// NativeFallback is why a native mode function runs as a subprocess.
+	NativeFallback string `json:"native_fallback,omitempty"`
+	// Input and Output are the declared payload types, if any.
+	Input  *schema.MetadataSchema `json:"input,omitempty"`
+	Output *schema.MetadataSchema `json:"output,omitempty"`
 }
 
 // GetEntrypoint returns the appropriate entrypoint path based on dev mode.
 func (f *FunctionDef) GetEntrypoint(devMode bool) string {
+	if f.NativeBinary != "" {
+		return f.NativeBinary
+	}
 	if devMode {
 		return f.Path
 	}
@@ -124,6 +138,48 @@ func (r *Registry) autoRegister(ctx context.Context, funcDef *FunctionDef) error
 	return nil
 }
 
+// setStatus records the result of validating a function.
+func (r *Registry) setStatus(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn, ok := r.functions[name]
+	if !ok {
+		return
+	}
+	if err != nil {
+		fn.Status = FunctionStatusError
+		fn.Error = err.Error()
+		return
+	}
+	fn.Status = FunctionStatusReady
+	fn.Error = ""
+}
+
+// setBuildResult records the outcome of a function's latest build.
+func (r *Registry) setBuildResult(name string, result *BuildResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fn, ok := r.functions[name]; ok {
+		fn.LastBuild = result
+	}
+}
+
+// setNative records a native mode function's build, or why it falls back
+// to the subprocess runtime.
+func (r *Registry) setNative(name, binary string, fallback error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn, ok := r.functions[name]
+	if !ok {
+		return
+	}
+	fn.NativeBinary = binary
+	fn.NativeFallback = ""
+	if fallback != nil {
+		fn.NativeFallback = fallback.Error()
+	}
+}
+
 // Get returns a function definition by name.
 func (r *Registry) Get(name string) (*FunctionDef, bool) {
 	r.mu.RLock()
@@ -3,12 +3,20 @@ package functions
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/requestctx"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/tracing"
 )
 
 // ServiceConfig contains configuration for the function service.
@@ -39,6 +47,9 @@ type Service struct {
 	devMode       bool
 	schema        interface{} // *schema.Schema, but avoiding import cycle
 	registrar     Registrar
+	warm          sync.Map // function name -> struct{}, set after the first invocation
+	native        nativeWorkers
+	prober        RuntimeProber
 }
 
 // NewService creates a new function service with subprocess runtime.
@@ -87,7 +98,7 @@ func NewService(cfg *ServiceConfig) (*Service, error) {
 		}
 	}
 
-	return &Service{
+	s := &Service{
 		runtimes:      runtimes,
 		registry:      registry,
 		sourceWatcher: sourceWatcher,
@@ -98,7 +109,13 @@ func NewService(cfg *ServiceConfig) (*Service, error) {
 		devMode:       cfg.DevMode,
 		schema:        cfg.Schema,
 		registrar:     cfg.Registrar,
-	}, nil
+	}
+	s.validateFunctions(context.Background(), registry)
+	if sourceWatcher != nil {
+		sourceWatcher.OnBuild(s.handleBuild)
+	}
+
+	return s, nil
 }
 
 // Start starts the function service and watchers.
@@ -117,18 +134,30 @@ func (s *Service) Start(ctx context.Context) error {
 }
 
 // Invoke invokes a function with the given input and auth context.
-func (s *Service) Invoke(ctx context.Context, functionName string, input map[string]any, authCtx *AuthContext) (*FunctionResponse, error) {
+func (s *Service) Invoke(ctx context.Context, functionName string, input map[string]any, authCtx *AuthContext) (resp *FunctionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "function "+functionName, trace.WithAttributes(
+		attribute.String("faas.name", functionName),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	startTime := time.Now()
-	requestID := uuid.New().String()
+	requestID := requestctx.RequestID(ctx)
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
 
 	// Get function definition
 	fn, ok := s.registry.Get(functionName)
 	if !ok {
 		return nil, fmt.Errorf("function %s not found", functionName)
 	}
+	if fn.Status == FunctionStatusError {
+		return nil, &UnavailableError{Function: functionName, Reason: fn.Error}
+	}
 
-	// Generate internal token for API access
-	token := s.tokenStore.Generate()
+	// Generate internal token for API access. Callbacks use the database the
+	// invocation was routed to, such as the dev sandbox.
+	token := s.tokenStore.GenerateFor(database.DBFromContext(ctx, nil))
 
 	// Build function context
 	funcCtx := &FunctionContext{
@@ -146,24 +175,15 @@ func (s *Service) Invoke(ctx context.Context, functionName string, input map[str
 		Context:   funcCtx,
 	}
 
+	// In dev mode native builds follow source changes.
+	if s.devMode && fn.Mode == schema.FunctionModeNative {
+		s.prepareNative(ctx, s.registry, fn)
+	}
+
 	// Get entrypoint based on dev mode
 	entrypoint := fn.GetEntrypoint(s.devMode)
 
-	// Select runtime based on mode and build config
-	var runtime *SubprocessRuntime
-	var runtimeOk bool
-
-	if !s.devMode && fn.HasBuild {
-		// Production mode with build config - use binary runtime
-		runtime, runtimeOk = s.runtimes[RuntimeBinary]
-		if !runtimeOk {
-			// Fallback to source runtime if binary runtime not available
-			runtime, runtimeOk = s.runtimes[fn.Runtime]
-		}
-	} else {
-		// Dev mode or no build config - use source runtime
-		runtime, runtimeOk = s.runtimes[fn.Runtime]
-	}
+	runtime, runtimeOk := s.selectRuntime(fn)
 
 	if !runtimeOk {
 		duration := time.Since(startTime)
@@ -175,8 +195,20 @@ func (s *Service) Invoke(ctx context.Context, functionName string, input map[str
 		}, fmt.Errorf("runtime %s not available", fn.Runtime)
 	}
 
-	// Call subprocess function with selected entrypoint
-	resp, err := runtime.Call(ctx, functionName, entrypoint, req)
+	_, warm := s.warm.LoadOrStore(functionName, struct{}{})
+	span.SetAttributes(
+		attribute.String("faas.runtime", string(runtime.Runtime())),
+		attribute.Bool("faas.coldstart", !warm),
+	)
+	// Call subprocess function with selected entrypoint. Native builds run
+	// in the function directory, as the source would.
+	if runtime == nativeRuntime {
+		release := s.native.acquire()
+		resp, err = runtime.call(ctx, functionName, entrypoint, filepath.Dir(fn.Path), req)
+		release()
+	} else {
+		resp, err = runtime.Call(ctx, functionName, entrypoint, req)
+	}
 	if err != nil {
 		duration := time.Since(startTime)
 		return &FunctionResponse{
@@ -188,10 +220,76 @@ func (s *Service) Invoke(ctx context.Context, functionName string, input map[str
 	}
 
 	resp.DurationMs = time.Since(startTime).Milliseconds()
+	resp.RequestID = requestID
+	for i := range resp.Logs {
+		if resp.Logs[i].RequestID == "" {
+			resp.Logs[i].RequestID = requestID
+		}
+	}
 
 	return resp, nil
 }
 
+// handleBuild records a watch-mode build. A successful build replaces the
+// function: warm state is dropped and its status is checked again. After a
+// failed build the function keeps running the previous build.
+func (s *Service) handleBuild(fn *FunctionDef, result *BuildResult) {
+	registry := s.registry
+	registry.setBuildResult(fn.Name, result)
+	if result.Status != BuildStatusSuccess {
+		return
+	}
+
+	s.warm.Delete(fn.Name)
+	if current, ok := registry.Get(fn.Name); ok {
+		registry.setStatus(fn.Name, s.checkFunction(current))
+	}
+	log.Info().Str("function", fn.Name).Msg("Function reloaded")
+}
+
+// BuildStats returns the latest build of each function with a build config.
+func (s *Service) BuildStats() map[string]*BuildResult {
+	stats := make(map[string]*BuildResult)
+	for _, fn := range s.registry.List() {
+		if fn.HasBuild {
+			stats[fn.Name] = fn.LastBuild
+		}
+	}
+	return stats
+}
+
+// ValidateInput checks input against fn's declared input. It returns nil when
+// fn declares no input or input validation is disabled.
+func (s *Service) ValidateInput(fn *FunctionDef, input map[string]any) schema.ValidationErrors {
+	if fn.Input == nil || (s.config != nil && !s.config.ValidateInput) {
+		return nil
+	}
+	payload := make(map[string]any, len(input))
+	for key, value := range input {
+		// Uploaded files are passed alongside the declared input.
+		if key != "_files" {
+			payload[key] = value
+		}
+	}
+	return fn.Input.ValidateInput(payload)
+}
+
+// selectRuntime returns the runtime fn runs on: its native build in native
+// mode, the binary runtime for built functions in production, falling back
+// to the function's source runtime.
+func (s *Service) selectRuntime(fn *FunctionDef) (*SubprocessRuntime, bool) {
+	if fn.NativeBinary != "" {
+		return nativeRuntime, true
+	}
+	if !s.devMode && fn.HasBuild {
+		if runtime, ok := s.runtimes[RuntimeBinary]; ok {
+			return runtime, true
+		}
+	}
+	runtime, ok := s.runtimes[fn.Runtime]
+	return runtime, ok
+}
+
 // GetFunction returns a function definition by name.
 func (s *Service) GetFunction(name string) (*FunctionDef, bool) {
 	return s.registry.Get(name)
@@ -202,6 +300,12 @@ func (s *Service) ListFunctions() []*FunctionDef {
 	return s.registry.List()
 }
 
+// UpdateSchema replaces the schema functions are loaded from. It takes effect
+// on the next ReloadFunctions.
+func (s *Service) UpdateSchema(sch *schema.Schema) {
+	s.schema = sch
+}
+
 // ReloadFunctions reloads functions from the schema.
 func (s *Service) ReloadFunctions() error {
 	registry, err := newRegistryFromSchemaInterface(s.schema, s.functionsDir, s.registrar)
@@ -209,7 +313,9 @@ func (s *Service) ReloadFunctions() error {
 		return fmt.Errorf("reloading functions from schema: %w", err)
 	}
 
+	s.validateFunctions(context.Background(), registry)
 	s.registry = registry
+	s.warm.Clear()
 
 	functions := s.registry.List()
 	log.Info().Int("count", len(functions)).Msg("Functions reloaded")
@@ -217,12 +323,17 @@ func (s *Service) ReloadFunctions() error {
 	return nil
 }
 
-// Stats returns runtime statistics (placeholder for compatibility).
+// Stats returns runtime statistics. Native mode go functions are reported
+// under NativePool.
 func (s *Service) Stats() map[Runtime]PoolStats {
-	return make(map[Runtime]PoolStats)
+	stats := make(map[Runtime]PoolStats)
+	if native, ok := s.nativeStats(); ok {
+		stats[NativePool] = native
+	}
+	return stats
 }
 
-// PoolStats represents pool statistics (placeholder for compatibility).
+// PoolStats represents pool statistics.
 type PoolStats struct {
 	Ready int `json:"ready"`
 	Busy  int `json:"busy"`
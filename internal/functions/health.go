@@ -0,0 +1,96 @@
+package functions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// RuntimeProber checks that a runtime's command can be run.
+type RuntimeProber interface {
+	// Version runs the runtime's version command and returns what it
+	// printed.
+	Version(ctx context.Context, runtime Runtime) (string, error)
+}
+
+// ExecProber probes runtimes by running their version command.
+type ExecProber struct{}
+
+// Version implements RuntimeProber.
+func (ExecProber) Version(ctx context.Context, runtime Runtime) (string, error) {
+	cfg, ok := defaultRuntimes[runtime]
+	if !ok {
+		return "", fmt.Errorf("unsupported runtime: %s", runtime)
+	}
+
+	var stdout, stderr bytes.Buffer
+	// #nosec G204 - the command comes from the built-in runtime table
+	cmd := exec.CommandContext(ctx, cfg.Command, cfg.VersionArgs...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", cfg.Command, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", cfg.Command, err)
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(stdout.String()), "\n")
+	return version, nil
+}
+
+// RuntimeHealth is the state of a runtime that registered functions run on.
+type RuntimeHealth struct {
+	Runtime Runtime `json:"runtime"`
+	Command string  `json:"command"`
+	// Version is the first line the runtime's version command printed.
+	Version string `json:"version,omitempty"`
+	// Functions is how many functions run on the runtime.
+	Functions int    `json:"functions"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Available reports whether the runtime responded.
+func (h RuntimeHealth) Available() bool {
+	return h.Error == ""
+}
+
+// SetProber replaces how CheckRuntimes probes runtimes.
+func (s *Service) SetProber(p RuntimeProber) {
+	s.prober = p
+}
+
+// CheckRuntimes probes every runtime that at least one registered function
+// runs on, in runtime order. Functions that run as native builds need no
+// runtime.
+func (s *Service) CheckRuntimes(ctx context.Context) []RuntimeHealth {
+	counts := make(map[Runtime]int)
+	for _, fn := range s.ListFunctions() {
+		if fn.NativeBinary != "" {
+			continue
+		}
+		counts[fn.Runtime]++
+	}
+
+	prober := s.prober
+	if prober == nil {
+		prober = ExecProber{}
+	}
+
+	results := make([]RuntimeHealth, 0, len(counts))
+	for runtime, n := range counts {
+		command, _ := RuntimeCommand(runtime)
+		health := RuntimeHealth{Runtime: runtime, Command: command, Functions: n}
+		version, err := prober.Version(ctx, runtime)
+		if err != nil {
+			health.Error = err.Error()
+		} else {
+			health.Version = version
+		}
+		results = append(results, health)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Runtime < results[j].Runtime })
+	return results
+}
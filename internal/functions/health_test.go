@@ -0,0 +1,52 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+type stubProber map[Runtime]error
+
+func (p stubProber) Version(_ context.Context, runtime Runtime) (string, error) {
+	if err := p[runtime]; err != nil {
+		return "", err
+	}
+	return string(runtime) + " 1.0.0", nil
+}
+
+func TestCheckRuntimes(t *testing.T) {
+	dir := t.TempDir()
+	createFunctionDir(t, dir, "hello", "index.js", "module.exports = {}")
+	createFunctionDir(t, dir, "bye", "index.js", "module.exports = {}")
+	createFunctionDir(t, dir, "greet", "main.py", "def handler(): pass")
+
+	registry, err := NewRegistryFromSchema(&schema.Schema{
+		Functions: map[string]*schema.Function{
+			"hello": {Runtime: "node", Entrypoint: "index.js"},
+			"bye":   {Runtime: "node", Entrypoint: "index.js"},
+			"greet": {Runtime: "python", Entrypoint: "main.py"},
+		},
+	}, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{registry: registry}
+	s.SetProber(stubProber{RuntimePython: errors.New("python3: executable file not found")})
+
+	got := s.CheckRuntimes(context.Background())
+	if len(got) != 2 {
+		t.Fatalf("expected 2 runtimes, got %+v", got)
+	}
+
+	node, python := got[0], got[1]
+	if node.Runtime != RuntimeNode || !node.Available() || node.Functions != 2 || node.Version != "node 1.0.0" {
+		t.Errorf("unexpected node health: %+v", node)
+	}
+	if python.Runtime != RuntimePython || python.Available() || python.Functions != 1 || python.Command != "python3" {
+		t.Errorf("unexpected python health: %+v", python)
+	}
+}
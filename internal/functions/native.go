@@ -0,0 +1,186 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// NativePool is the Stats key for go functions in native mode. It is not a
+// runtime a function can declare.
+const NativePool Runtime = "go:native"
+
+// nativeDir is where native builds are written, inside the function
+// directory. Dot directories are skipped by the staleness check.
+const nativeDir = ".alyx/native"
+
+// nativeRuntime runs native builds. They speak the same JSON protocol over
+// stdin and stdout as every other runtime.
+var nativeRuntime = &SubprocessRuntime{runtime: RuntimeBinary}
+
+// nativeWorkers tracks native go functions for Stats.
+type nativeWorkers struct {
+	mu    sync.Mutex
+	busy  int
+	locks map[string]*sync.Mutex // function name -> build lock
+}
+
+// acquire marks a native invocation as running and returns its release.
+func (n *nativeWorkers) acquire() func() {
+	n.mu.Lock()
+	n.busy++
+	n.mu.Unlock()
+	return func() {
+		n.mu.Lock()
+		n.busy--
+		n.mu.Unlock()
+	}
+}
+
+// buildLock returns the lock serializing native builds of a function.
+func (n *nativeWorkers) buildLock(name string) *sync.Mutex {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.locks == nil {
+		n.locks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := n.locks[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		n.locks[name] = lock
+	}
+	return lock
+}
+
+// nativeBinaryPath returns where fn's native build is written.
+func nativeBinaryPath(fn *FunctionDef) string {
+	name := fn.Name
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(filepath.Dir(fn.Path), nativeDir, name)
+}
+
+// nativeSupported reports whether native builds are possible here: the go
+// toolchain must be installed.
+func nativeSupported() error {
+	if _, err := exec.LookPath("go"); err != nil {
+		return errors.New("go was not found in PATH")
+	}
+	return nil
+}
+
+// buildNative compiles fn with go build. A function directory with its own
+// go.mod is built as a package; otherwise the entrypoint file is built alone.
+func buildNative(ctx context.Context, fn *FunctionDef, output string) (*BuildResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, buildTimeout)
+	defer cancel()
+
+	funcDir := filepath.Dir(fn.Path)
+	target := filepath.Base(fn.Path)
+	if _, err := os.Stat(filepath.Join(funcDir, "go.mod")); err == nil {
+		target = "."
+	}
+
+	absOutput, err := filepath.Abs(output)
+	if err != nil {
+		return nil, fmt.Errorf("resolving native build path: %w", err)
+	}
+
+	log.Info().Str("function", fn.Name).Msg("Building native function")
+
+	//nolint:gosec // The target is the function's entrypoint from trusted schema configuration
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", absOutput, target)
+	cmd.Dir = funcDir
+
+	started := time.Now()
+	out, err := cmd.CombinedOutput()
+	result := &BuildResult{
+		Status:     BuildStatusSuccess,
+		StartedAt:  started.UTC(),
+		DurationMs: time.Since(started).Milliseconds(),
+		Output:     trimBuildOutput(string(out)),
+	}
+	if err == nil {
+		return result, nil
+	}
+
+	result.Status = BuildStatusFailed
+	result.Error = err.Error()
+	if result.Output == "" {
+		return result, fmt.Errorf("go build failed: %w", err)
+	}
+	return result, fmt.Errorf("go build failed: %w: %s", err, result.Output)
+}
+
+// prepareNative builds fn if it runs in native mode and its native build is
+// missing or stale. When the build fails, or native builds are not possible,
+// fn falls back to the subprocess runtime and the reason is recorded.
+func (s *Service) prepareNative(ctx context.Context, registry *Registry, fn *FunctionDef) {
+	if fn.Mode != schema.FunctionModeNative {
+		return
+	}
+
+	lock := s.native.buildLock(fn.Name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	output := nativeBinaryPath(fn)
+	if fn.NativeBinary != "" && !outputStale(fn.Path, output) {
+		return
+	}
+
+	if err := nativeSupported(); err != nil {
+		registry.setNative(fn.Name, "", err)
+		log.Warn().Str("function", fn.Name).Str("reason", err.Error()).Msg("Native mode unavailable, falling back to subprocess")
+		return
+	}
+
+	if !outputStale(fn.Path, output) {
+		registry.setNative(fn.Name, output, nil)
+		return
+	}
+
+	result, err := buildNative(ctx, fn, output)
+	if result != nil {
+		registry.setBuildResult(fn.Name, result)
+	}
+	if err != nil {
+		// A failed build leaves the previous one, if any, in place, but it
+		// no longer matches the sources.
+		registry.setNative(fn.Name, "", err)
+		log.Warn().Str("function", fn.Name).Str("reason", err.Error()).Msg("Native build failed, falling back to subprocess")
+		return
+	}
+	registry.setNative(fn.Name, output, nil)
+}
+
+// nativeStats returns the native pool: Total native functions, Ready those
+// with a current native build, and Busy the native invocations running.
+func (s *Service) nativeStats() (PoolStats, bool) {
+	var stats PoolStats
+	for _, fn := range s.registry.List() {
+		if fn.Mode != schema.FunctionModeNative {
+			continue
+		}
+		stats.Total++
+		if fn.NativeBinary != "" {
+			stats.Ready++
+		}
+	}
+
+	s.native.mu.Lock()
+	stats.Busy = s.native.busy
+	s.native.mu.Unlock()
+	return stats, stats.Total > 0
+}
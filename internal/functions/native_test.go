@@ -0,0 +1,110 @@
+package functions
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// nativeTestFunction echoes its request back, along with a file read from
+// its working directory.
+const nativeTestFunction = `package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+func main() {
+	var req struct {
+		Input   map[string]any ` + "`json:\"input\"`" + `
+		Context struct {
+			Auth map[string]any    ` + "`json:\"auth\"`" + `
+			Env  map[string]string ` + "`json:\"env\"`" + `
+		} ` + "`json:\"context\"`" + `
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		panic(err)
+	}
+	greeting, _ := os.ReadFile("greeting.txt")
+	json.NewEncoder(os.Stdout).Encode(map[string]any{
+		"success": true,
+		"output": map[string]any{
+			"input":    req.Input,
+			"auth":     req.Context.Auth,
+			"env":      req.Context.Env,
+			"greeting": string(greeting),
+		},
+	})
+}
+`
+
+func TestNativeMode(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	registry, tmpDir := testSchemaRegistry(t, map[string]*schema.Function{
+		"native":     {Runtime: "go", Mode: schema.FunctionModeNative, Entrypoint: "main.go", Env: map[string]string{"PLAN": "pro"}},
+		"subprocess": {Runtime: "go", Entrypoint: "main.go", Env: map[string]string{"PLAN": "pro"}},
+		"broken":     {Runtime: "go", Mode: schema.FunctionModeNative, Entrypoint: "main.go"},
+	})
+	for _, name := range []string{"native", "subprocess"} {
+		dir := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(nativeTestFunction), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "greeting.txt"), []byte("hello"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "broken", "main.go"), []byte("package main\n\nfunc main() { undefined() }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{
+		runtimes:   map[Runtime]*SubprocessRuntime{RuntimeGo: {runtime: RuntimeGo, config: defaultRuntimes[RuntimeGo]}},
+		registry:   registry,
+		tokenStore: NewInternalTokenStore(time.Minute),
+	}
+	t.Cleanup(s.tokenStore.Stop)
+	s.validateFunctions(context.Background(), registry)
+
+	native, _ := registry.Get("native")
+	if native.NativeBinary == "" || native.NativeFallback != "" {
+		t.Fatalf("expected a native build, got fallback %q", native.NativeFallback)
+	}
+	broken, _ := registry.Get("broken")
+	if broken.NativeBinary != "" || !strings.Contains(broken.NativeFallback, "go build failed") {
+		t.Errorf("expected a failed build to fall back, got %q", broken.NativeFallback)
+	}
+
+	authCtx := &AuthContext{ID: "u1", Email: "a@example.com"}
+	input := map[string]any{"n": 1.0}
+	nativeResp, err := s.Invoke(context.Background(), "native", input, authCtx)
+	if err != nil {
+		t.Fatalf("invoking native: %v", err)
+	}
+	subprocessResp, err := s.Invoke(context.Background(), "subprocess", input, authCtx)
+	if err != nil {
+		t.Fatalf("invoking subprocess: %v", err)
+	}
+	if !nativeResp.Success || !reflect.DeepEqual(nativeResp.Output, subprocessResp.Output) {
+		t.Errorf("expected identical output in both modes:\nnative:     %v\nsubprocess: %v", nativeResp.Output, subprocessResp.Output)
+	}
+	if out, _ := nativeResp.Output.(map[string]any); out["greeting"] != "hello" {
+		t.Errorf("expected the native build to run in the function directory, got %v", nativeResp.Output)
+	}
+
+	stats := s.Stats()[NativePool]
+	if stats.Total != 2 || stats.Ready != 1 || stats.Busy != 0 {
+		t.Errorf("unexpected native pool stats %+v", stats)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/watzon/alyx/internal/tracing"
 )
 
 // SubprocessRuntime executes functions by spawning subprocesses.
@@ -40,14 +42,28 @@ func NewSubprocessRuntime(runtime Runtime) (*SubprocessRuntime, error) {
 	}, nil
 }
 
+// RuntimeCommand returns the binary used to run functions written for runtime.
+func RuntimeCommand(runtime Runtime) (string, bool) {
+	config, ok := defaultRuntimes[runtime]
+	return config.Command, ok
+}
+
 // Call executes a function by spawning a subprocess and communicating via JSON.
 // The function receives a FunctionRequest on stdin and returns a FunctionResponse on stdout.
 func (r *SubprocessRuntime) Call(ctx context.Context, name, entrypoint string, req *FunctionRequest) (*FunctionResponse, error) {
+	return r.call(ctx, name, entrypoint, "", req)
+}
+
+// call is Call with the working directory set to funcDir, or to the
+// entrypoint's directory when funcDir is empty.
+func (r *SubprocessRuntime) call(ctx context.Context, name, entrypoint, funcDir string, req *FunctionRequest) (*FunctionResponse, error) {
 	absPath, err := filepath.Abs(entrypoint)
 	if err != nil {
 		return nil, fmt.Errorf("resolving entrypoint path: %w", err)
 	}
-	funcDir := filepath.Dir(absPath)
+	if funcDir == "" {
+		funcDir = filepath.Dir(absPath)
+	}
 	funcFile := filepath.Base(absPath)
 
 	var cmd *exec.Cmd
@@ -84,6 +100,8 @@ func (r *SubprocessRuntime) Call(ctx context.Context, name, entrypoint string, r
 	}
 
 	cmd.Stdin = bytes.NewReader(inputJSON)
+	cmd.Env = append(os.Environ(), "ALYX_REQUEST_ID="+req.RequestID)
+	cmd.Env = append(cmd.Env, tracing.Env(ctx)...)
 
 	// Capture stdout and stderr
 	var stdout, stderr bytes.Buffer
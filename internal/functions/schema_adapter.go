@@ -59,6 +59,10 @@ func convertFunction(name string, fn *schema.Function, functionsDir string) (*Fu
 	}
 
 	runtime := Runtime(fn.Runtime)
+	mode := fn.Mode
+	if mode == "" {
+		mode = schema.FunctionModeSubprocess
+	}
 
 	hooks := make([]HookConfig, len(fn.Hooks))
 	for i, h := range fn.Hooks {
@@ -67,12 +71,14 @@ func convertFunction(name string, fn *schema.Function, functionsDir string) (*Fu
 			Source: h.Source,
 			Action: h.Action,
 			Mode:   h.Mode,
+			Fields: h.Fields,
 		}
 		if h.Verification != nil {
 			hooks[i].Verification = &VerificationConfig{
-				Type:   h.Verification.Type,
-				Header: h.Verification.Header,
-				Secret: h.Verification.Secret,
+				Type:      h.Verification.Type,
+				Header:    h.Verification.Header,
+				Secret:    h.Verification.Secret,
+				Tolerance: h.Verification.Tolerance,
 			}
 		}
 	}
@@ -84,6 +90,9 @@ func convertFunction(name string, fn *schema.Function, functionsDir string) (*Fu
 			Type:       s.Type,
 			Expression: s.Expression,
 			Timezone:   s.Timezone,
+			Overlap:    s.Overlap,
+			Jitter:     s.Jitter,
+			CatchUp:    s.CatchUp,
 			Config:     s.Config,
 			Input:      s.Input,
 		}
@@ -114,6 +123,7 @@ func convertFunction(name string, fn *schema.Function, functionsDir string) (*Fu
 	return &FunctionDef{
 		Name:        name,
 		Runtime:     runtime,
+		Mode:        mode,
 		Path:        entrypointPath,
 		OutputPath:  outputPath,
 		Description: fn.Description,
@@ -126,6 +136,8 @@ func convertFunction(name string, fn *schema.Function, functionsDir string) (*Fu
 		Routes:      routes,
 		Hooks:       hooks,
 		Schedules:   schedules,
+		Input:       fn.Input,
+		Output:      fn.Output,
 	}, nil
 }
 
@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"sync"
 	"time"
+
+	"github.com/watzon/alyx/internal/database"
 )
 
 // InternalTokenStore manages short-lived tokens for container->host communication.
@@ -18,6 +20,7 @@ type InternalTokenStore struct {
 
 type tokenEntry struct {
 	createdAt time.Time
+	db        *database.DB
 }
 
 // NewInternalTokenStore creates a new token store.
@@ -40,6 +43,12 @@ func NewInternalTokenStore(ttl time.Duration) *InternalTokenStore {
 
 // Generate creates a new internal token.
 func (s *InternalTokenStore) Generate() string {
+	return s.GenerateFor(nil)
+}
+
+// GenerateFor creates a new internal token whose callbacks use db. A nil db
+// means the server's primary database.
+func (s *InternalTokenStore) GenerateFor(db *database.DB) string {
 	// Generate random token
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
@@ -51,6 +60,7 @@ func (s *InternalTokenStore) Generate() string {
 	s.mu.Lock()
 	s.tokens[token] = tokenEntry{
 		createdAt: time.Now(),
+		db:        db,
 	}
 	s.mu.Unlock()
 
@@ -78,6 +88,14 @@ func (s *InternalTokenStore) Validate(token string) bool {
 	return true
 }
 
+// DB returns the database the token was generated for, or nil for the
+// primary database.
+func (s *InternalTokenStore) DB(token string) *database.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tokens[token].db
+}
+
 // Revoke removes a token.
 func (s *InternalTokenStore) Revoke(token string) {
 	s.mu.Lock()
@@ -3,6 +3,8 @@ package functions
 import (
 	"testing"
 	"time"
+
+	"github.com/watzon/alyx/internal/database"
 )
 
 func TestInternalTokenStore_Generate(t *testing.T) {
@@ -32,6 +34,22 @@ func TestInternalTokenStore_Validate(t *testing.T) {
 	}
 }
 
+func TestInternalTokenStore_GenerateFor(t *testing.T) {
+	store := NewInternalTokenStore(5 * time.Minute)
+	sandbox := &database.DB{}
+
+	token := store.GenerateFor(sandbox)
+	if !store.Validate(token) {
+		t.Error("expected token to be valid")
+	}
+	if store.DB(token) != sandbox {
+		t.Error("expected the token to carry its database")
+	}
+	if store.DB(store.Generate()) != nil {
+		t.Error("expected a plain token to use the primary database")
+	}
+}
+
 func TestInternalTokenStore_Revoke(t *testing.T) {
 	store := NewInternalTokenStore(5 * time.Minute)
 
@@ -29,39 +29,47 @@ type RuntimeConfig struct {
 	Command    string
 	Args       []string
 	Extensions []string
+	// VersionArgs make Command print its version, for health checks.
+	VersionArgs []string
 }
 
 var defaultRuntimes = map[Runtime]RuntimeConfig{
 	RuntimeDeno: {
-		Command:    "deno",
-		Args:       []string{"run", "--allow-all"},
-		Extensions: []string{".ts", ".tsx"},
+		Command:     "deno",
+		Args:        []string{"run", "--allow-all"},
+		Extensions:  []string{".ts", ".tsx"},
+		VersionArgs: []string{"--version"},
 	},
 	RuntimeNode: {
-		Command:    "node",
-		Args:       []string{},
-		Extensions: []string{".js", ".mjs"},
+		Command:     "node",
+		Args:        []string{},
+		Extensions:  []string{".js", ".mjs"},
+		VersionArgs: []string{"--version"},
 	},
 	RuntimeBun: {
-		Command:    "bun",
-		Args:       []string{"run"},
-		Extensions: []string{".ts", ".tsx", ".js"},
+		Command:     "bun",
+		Args:        []string{"run"},
+		Extensions:  []string{".ts", ".tsx", ".js"},
+		VersionArgs: []string{"--version"},
 	},
 	RuntimePython: {
-		Command:    "python3",
-		Args:       []string{},
-		Extensions: []string{".py"},
+		Command:     "python3",
+		Args:        []string{},
+		Extensions:  []string{".py"},
+		VersionArgs: []string{"--version"},
 	},
 	RuntimeGo: {
-		Command:    "go",
-		Args:       []string{"run"},
-		Extensions: []string{".go"},
+		Command:     "go",
+		Args:        []string{"run"},
+		Extensions:  []string{".go"},
+		VersionArgs: []string{"version"},
 	},
 }
 
 // FunctionRequest represents a function invocation request.
 type FunctionRequest struct {
-	// RequestID is a unique identifier for this request.
+	// RequestID identifies the originating HTTP request, or a fresh ID when the
+	// invocation was not triggered by one.
 	RequestID string `json:"request_id"`
 	// Function is the name of the function to invoke.
 	Function string `json:"function"`
@@ -124,6 +132,7 @@ type LogEntry struct {
 	Message   string         `json:"message"`
 	Data      map[string]any `json:"data,omitempty"`
 	Timestamp time.Time      `json:"timestamp"`
+	RequestID string         `json:"request_id,omitempty"`
 }
 
 // FileUpload represents an uploaded file passed to a function.
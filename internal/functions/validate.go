@@ -0,0 +1,198 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FunctionStatus reports whether a function can be invoked.
+type FunctionStatus string
+
+const (
+	// FunctionStatusReady means the function passed validation.
+	FunctionStatusReady FunctionStatus = "ready"
+	// FunctionStatusError means the function cannot be invoked; Error says why.
+	FunctionStatusError FunctionStatus = "error"
+)
+
+// buildTimeout bounds a single function build.
+const buildTimeout = 5 * time.Minute
+
+// maxBuildOutput is how much trailing build output is kept in a diagnosis.
+const maxBuildOutput = 2000
+
+// BuildStatus is the outcome of a function build.
+type BuildStatus string
+
+const (
+	// BuildStatusSuccess means the build command exited zero.
+	BuildStatusSuccess BuildStatus = "success"
+	// BuildStatusFailed means the build command failed; the previous build
+	// output is left in place.
+	BuildStatusFailed BuildStatus = "failed"
+)
+
+// BuildResult describes the most recent build of a function.
+type BuildResult struct {
+	Status     BuildStatus `json:"status"`
+	StartedAt  time.Time   `json:"started_at"`
+	DurationMs int64       `json:"duration_ms"`
+	Output     string      `json:"output,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// UnavailableError is returned when invoking a function that failed
+// validation.
+type UnavailableError struct {
+	Function string
+	Reason   string
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("function %s is unavailable: %s", e.Function, e.Reason)
+}
+
+// validateFunctions checks every function in registry and records its
+// status, building functions whose build output is missing or stale.
+func (s *Service) validateFunctions(ctx context.Context, registry *Registry) {
+	for _, fn := range registry.List() {
+		err := s.validateFunction(ctx, registry, fn)
+		registry.setStatus(fn.Name, err)
+		if err != nil {
+			log.Warn().Str("function", fn.Name).Str("error", err.Error()).Msg("Function failed validation")
+		}
+	}
+}
+
+// validateFunction builds fn if its output, or its native build, is missing
+// or older than its sources, then checks that it can be invoked.
+func (s *Service) validateFunction(ctx context.Context, registry *Registry, fn *FunctionDef) error {
+	if fn.Build != nil && fn.Build.Command != "" && fn.OutputPath != "" && buildStale(fn) {
+		result, err := runBuild(ctx, fn)
+		registry.setBuildResult(fn.Name, result)
+		if err != nil {
+			return err
+		}
+	}
+	s.prepareNative(ctx, registry, fn)
+	return s.checkFunction(fn)
+}
+
+// checkFunction checks that fn's runtime is installed and that its
+// entrypoint exists.
+func (s *Service) checkFunction(fn *FunctionDef) error {
+	if _, ok := s.selectRuntime(fn); !ok {
+		command, _ := RuntimeCommand(fn.Runtime)
+		if command == "" {
+			return fmt.Errorf("unsupported runtime %s", fn.Runtime)
+		}
+		return fmt.Errorf("runtime %s is not available: %s was not found in PATH", fn.Runtime, command)
+	}
+
+	entrypoint := fn.GetEntrypoint(s.devMode)
+	info, err := os.Stat(entrypoint)
+	if errors.Is(err, fs.ErrNotExist) {
+		if fn.HasBuild && entrypoint == fn.OutputPath {
+			return fmt.Errorf("build output %s does not exist", entrypoint)
+		}
+		return fmt.Errorf("entrypoint %s does not exist", entrypoint)
+	}
+	if err != nil {
+		return fmt.Errorf("checking entrypoint: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("entrypoint %s is a directory", entrypoint)
+	}
+	return nil
+}
+
+// buildStale reports whether fn's build output is missing or older than any
+// other file in the function directory.
+func buildStale(fn *FunctionDef) bool {
+	return outputStale(fn.Path, fn.OutputPath)
+}
+
+// outputStale reports whether output is missing or older than any other
+// file in the directory of entrypoint.
+func outputStale(entrypoint, output string) bool {
+	out, err := os.Stat(output)
+	if err != nil {
+		return true
+	}
+
+	stale := false
+	funcDir := filepath.Dir(entrypoint)
+	outDir := filepath.Dir(output)
+	_ = filepath.WalkDir(funcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != funcDir && (path == outDir || name == "node_modules" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == output {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().After(out.ModTime()) {
+			stale = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return stale
+}
+
+// runBuild runs fn's build command in its directory. The returned error
+// includes the tail of the build output.
+func runBuild(ctx context.Context, fn *FunctionDef) (*BuildResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, buildTimeout)
+	defer cancel()
+
+	log.Info().Str("function", fn.Name).Str("command", fn.Build.Command).Msg("Building function")
+
+	//nolint:gosec // Build command is from trusted schema configuration
+	cmd := exec.CommandContext(ctx, fn.Build.Command, fn.Build.Args...)
+	cmd.Dir = filepath.Dir(fn.Path)
+
+	started := time.Now()
+	output, err := cmd.CombinedOutput()
+	result := &BuildResult{
+		Status:     BuildStatusSuccess,
+		StartedAt:  started.UTC(),
+		DurationMs: time.Since(started).Milliseconds(),
+		Output:     trimBuildOutput(string(output)),
+	}
+	if err == nil {
+		return result, nil
+	}
+
+	result.Status = BuildStatusFailed
+	result.Error = err.Error()
+	if result.Output == "" {
+		return result, fmt.Errorf("build command %s failed: %w", fn.Build.Command, err)
+	}
+	return result, fmt.Errorf("build command %s failed: %w: %s", fn.Build.Command, err, result.Output)
+}
+
+// trimBuildOutput keeps the last maxBuildOutput bytes of build output, where
+// compiler errors usually end up.
+func trimBuildOutput(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxBuildOutput {
+		output = "..." + output[len(output)-maxBuildOutput:]
+	}
+	return output
+}
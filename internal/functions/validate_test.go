@@ -0,0 +1,185 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func testValidationService() *Service {
+	return &Service{
+		runtimes: map[Runtime]*SubprocessRuntime{
+			RuntimeNode: {runtime: RuntimeNode},
+		},
+	}
+}
+
+func TestValidateFunctions(t *testing.T) {
+	registry, tmpDir := testSchemaRegistry(t, map[string]*schema.Function{
+		"ready":   {Runtime: "node"},
+		"missing": {Runtime: "node"},
+		"broken": {
+			Runtime: "node",
+			Build: &schema.FunctionBuild{
+				Command: "sh",
+				Args:    []string{"-c", "echo 'syntax error on line 3' >&2; exit 1"},
+				Output:  "dist/index.js",
+			},
+		},
+		"built": {
+			Runtime: "node",
+			Build: &schema.FunctionBuild{
+				Command: "sh",
+				Args:    []string{"-c", "mkdir -p dist && touch dist/index.js"},
+				Output:  "dist/index.js",
+			},
+		},
+		"python": {Runtime: "python", Entrypoint: "main.py"},
+	})
+	if err := os.Remove(filepath.Join(tmpDir, "missing", "index.js")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := testValidationService()
+	s.validateFunctions(context.Background(), registry)
+
+	tests := []struct {
+		name   string
+		status FunctionStatus
+		error  string
+	}{
+		{"ready", FunctionStatusReady, ""},
+		{"missing", FunctionStatusError, "does not exist"},
+		{"broken", FunctionStatusError, "syntax error on line 3"},
+		{"built", FunctionStatusReady, ""},
+		{"python", FunctionStatusError, "runtime python is not available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, _ := registry.Get(tt.name)
+			if fn.Status != tt.status {
+				t.Errorf("expected status %s, got %s (%s)", tt.status, fn.Status, fn.Error)
+			}
+			if !strings.Contains(fn.Error, tt.error) {
+				t.Errorf("expected error to contain %q, got %q", tt.error, fn.Error)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "built", "dist", "index.js")); err != nil {
+		t.Errorf("expected build to produce output: %v", err)
+	}
+}
+
+func TestInvoke_UnavailableFunction(t *testing.T) {
+	registry, tmpDir := testSchemaRegistry(t, map[string]*schema.Function{
+		"missing": {Runtime: "node"},
+	})
+	if err := os.Remove(filepath.Join(tmpDir, "missing", "index.js")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := testValidationService()
+	s.registry = registry
+	s.validateFunctions(context.Background(), registry)
+
+	_, err := s.Invoke(context.Background(), "missing", nil, nil)
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("expected UnavailableError, got %v", err)
+	}
+	if !strings.Contains(unavailable.Reason, "does not exist") {
+		t.Errorf("expected stored diagnosis, got %q", unavailable.Reason)
+	}
+}
+
+func TestBuildStale(t *testing.T) {
+	registry, tmpDir := testSchemaRegistry(t, map[string]*schema.Function{
+		"fn": {
+			Runtime: "node",
+			Build:   &schema.FunctionBuild{Command: "true", Output: "dist/index.js"},
+		},
+	})
+	fn, _ := registry.Get("fn")
+
+	if !buildStale(fn) {
+		t.Error("expected missing output to be stale")
+	}
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "fn", "dist"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fn.OutputPath, []byte("built"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if buildStale(fn) {
+		t.Error("expected fresh output not to be stale")
+	}
+}
+
+func TestHandleBuild(t *testing.T) {
+	registry, _ := testSchemaRegistry(t, map[string]*schema.Function{
+		"fn": {
+			Runtime: "node",
+			Build:   &schema.FunctionBuild{Command: "true", Output: "index.js"},
+		},
+	})
+	s := testValidationService()
+	s.registry = registry
+	s.validateFunctions(context.Background(), registry)
+	s.warm.Store("fn", struct{}{})
+	fn, _ := registry.Get("fn")
+
+	s.handleBuild(fn, &BuildResult{Status: BuildStatusFailed, Output: "error TS2304"})
+	if fn.Status != FunctionStatusReady {
+		t.Errorf("expected failed rebuild to keep the previous build, got %s", fn.Status)
+	}
+	if _, warm := s.warm.Load("fn"); !warm {
+		t.Error("expected warm state to be kept after a failed build")
+	}
+	if stats := s.BuildStats(); stats["fn"] == nil || stats["fn"].Output != "error TS2304" {
+		t.Errorf("expected build output in stats, got %+v", stats["fn"])
+	}
+
+	s.handleBuild(fn, &BuildResult{Status: BuildStatusSuccess})
+	if _, warm := s.warm.Load("fn"); warm {
+		t.Error("expected successful build to drop warm state")
+	}
+	if s.BuildStats()["fn"].Status != BuildStatusSuccess {
+		t.Error("expected stats to report the latest build")
+	}
+}
+
+func TestService_ValidateInput(t *testing.T) {
+	no := false
+	fn := &FunctionDef{
+		Name: "fn",
+		Input: &schema.MetadataSchema{
+			AdditionalProperties: &no,
+			Required:             []string{"name"},
+			Properties:           map[string]*schema.MetadataSchema{"name": {Type: "string"}},
+		},
+	}
+	s := &Service{config: &config.FunctionsConfig{ValidateInput: true}}
+
+	if errs := s.ValidateInput(fn, map[string]any{"name": "Ada", "_files": []FileUpload{}}); len(errs) != 0 {
+		t.Errorf("expected uploaded files to be ignored, got %v", errs)
+	}
+	if errs := s.ValidateInput(fn, nil); len(errs) != 1 || errs[0].Path != "input.name" {
+		t.Errorf("expected missing name, got %v", errs)
+	}
+	if errs := s.ValidateInput(&FunctionDef{Name: "untyped"}, map[string]any{"x": 1.0}); errs != nil {
+		t.Errorf("expected untyped function to accept any input, got %v", errs)
+	}
+
+	s.config.ValidateInput = false
+	if errs := s.ValidateInput(fn, nil); errs != nil {
+		t.Errorf("expected validation to be disabled, got %v", errs)
+	}
+}
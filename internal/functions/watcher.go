@@ -4,7 +4,6 @@ package functions
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"path/filepath"
 	"sync"
 	"time"
@@ -18,12 +17,18 @@ const (
 	defaultDebounceDuration = 100 * time.Millisecond
 )
 
+// BuildHandler is called after a watched function has been rebuilt.
+type BuildHandler func(fn *FunctionDef, result *BuildResult)
+
 // SourceWatcher watches source files and triggers builds when changes are detected.
+// Builds are debounced and never run concurrently for the same function.
 type SourceWatcher struct {
 	registry         *Registry
 	watcher          *fsnotify.Watcher
 	debounceDuration time.Duration
 	debounceTimers   map[string]*time.Timer
+	buildLocks       map[string]*sync.Mutex
+	onBuild          BuildHandler
 	mu               sync.Mutex
 	ctx              context.Context
 	cancel           context.CancelFunc
@@ -44,6 +49,7 @@ func NewSourceWatcher(registry *Registry) (*SourceWatcher, error) {
 		watcher:          watcher,
 		debounceDuration: defaultDebounceDuration,
 		debounceTimers:   make(map[string]*time.Timer),
+		buildLocks:       make(map[string]*sync.Mutex),
 		ctx:              ctx,
 		cancel:           cancel,
 	}, nil
@@ -56,6 +62,13 @@ func (sw *SourceWatcher) SetDebounceDuration(d time.Duration) {
 	sw.debounceDuration = d
 }
 
+// OnBuild sets the handler called after each build.
+func (sw *SourceWatcher) OnBuild(handler BuildHandler) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.onBuild = handler
+}
+
 // Start begins watching source files for all functions with build configurations.
 func (sw *SourceWatcher) Start() error {
 	functions := sw.registry.List()
@@ -254,38 +267,43 @@ func (sw *SourceWatcher) debounceBuild(fn *FunctionDef) {
 }
 
 func (sw *SourceWatcher) executeBuild(fn *FunctionDef) {
-	funcDir := filepath.Dir(fn.Path)
-
 	if fn.Build == nil {
 		return
 	}
 
-	log.Info().
-		Str("function", fn.Name).
-		Str("command", fn.Build.Command).
-		Msg("Building function")
-
-	const buildTimeout = 5 * time.Minute
-	ctx, cancel := context.WithTimeout(context.Background(), buildTimeout)
-	defer cancel()
+	sw.mu.Lock()
+	lock, ok := sw.buildLocks[fn.Name]
+	if !ok {
+		lock = &sync.Mutex{}
+		sw.buildLocks[fn.Name] = lock
+	}
+	sw.mu.Unlock()
 
-	//nolint:gosec // Build command is from trusted schema configuration
-	cmd := exec.CommandContext(ctx, fn.Build.Command, fn.Build.Args...)
-	cmd.Dir = funcDir
+	lock.Lock()
+	defer lock.Unlock()
 
-	output, err := cmd.CombinedOutput()
+	if sw.ctx.Err() != nil {
+		return
+	}
 
+	result, err := runBuild(sw.ctx, fn)
 	if err != nil {
 		log.Error().
-			Err(err).
 			Str("function", fn.Name).
-			Str("output", string(output)).
-			Msg("Build failed")
-		return
+			Int64("duration_ms", result.DurationMs).
+			Str("output", result.Output).
+			Msg("Build failed, keeping previous build")
+	} else {
+		log.Info().
+			Str("function", fn.Name).
+			Int64("duration_ms", result.DurationMs).
+			Msg("Build succeeded")
 	}
 
-	log.Info().
-		Str("function", fn.Name).
-		Str("output", string(output)).
-		Msg("Build succeeded")
+	sw.mu.Lock()
+	handler := sw.onBuild
+	sw.mu.Unlock()
+	if handler != nil {
+		handler(fn, result)
+	}
 }
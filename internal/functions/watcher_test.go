@@ -3,6 +3,7 @@ package functions
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -418,3 +419,65 @@ func TestSourceWatcher_StopCleansUp(t *testing.T) {
 		t.Errorf("expected debounce timers to be cleaned up, got %d", len(watcher.debounceTimers))
 	}
 }
+
+func TestSourceWatcher_OnBuild(t *testing.T) {
+	functions := map[string]*schema.Function{
+		"test-func": {
+			Runtime:    "node",
+			Entrypoint: "index.js",
+			Build: &schema.FunctionBuild{
+				Command: "sh",
+				Args:    []string{"-c", "if grep -q broken test.js; then echo 'test.js: unexpected token' >&2; exit 1; fi"},
+				Watch:   []string{"*.js"},
+				Output:  "index.js",
+			},
+		},
+	}
+
+	registry, tmpDir := testSchemaRegistry(t, functions)
+	testFile := filepath.Join(tmpDir, "test-func", "test.js")
+
+	watcher, err := NewSourceWatcher(registry)
+	if err != nil {
+		t.Fatalf("creating watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	results := make(chan *BuildResult, 4)
+	watcher.OnBuild(func(fn *FunctionDef, result *BuildResult) {
+		results <- result
+	})
+
+	if err := watcher.Start(); err != nil {
+		t.Fatalf("starting watcher: %v", err)
+	}
+
+	next := func() *BuildResult {
+		t.Helper()
+		select {
+		case result := <-results:
+			return result
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for build")
+			return nil
+		}
+	}
+
+	if err := os.WriteFile(testFile, []byte("broken"), 0o644); err != nil {
+		t.Fatalf("writing test file: %v", err)
+	}
+	failed := next()
+	if failed.Status != BuildStatusFailed {
+		t.Errorf("expected failed build, got %s", failed.Status)
+	}
+	if !strings.Contains(failed.Output, "unexpected token") {
+		t.Errorf("expected compiler output to be captured, got %q", failed.Output)
+	}
+
+	if err := os.WriteFile(testFile, []byte("console.log('fixed')"), 0o644); err != nil {
+		t.Fatalf("writing test file: %v", err)
+	}
+	if result := next(); result.Status != BuildStatusSuccess {
+		t.Errorf("expected successful build, got %s: %s", result.Status, result.Output)
+	}
+}
@@ -35,6 +35,14 @@ var (
 		},
 	)
 
+	httpJSONDecodeErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alyx_http_json_decode_errors_total",
+			Help: "Total number of JSON request bodies rejected, by reason",
+		},
+		[]string{"reason"},
+	)
+
 	httpResponseSize = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "alyx_http_response_size_bytes",
@@ -65,6 +73,14 @@ var (
 		},
 	)
 
+	dbRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alyx_db_retries_total",
+			Help: "Total number of writes retried because the database was busy or locked",
+		},
+		[]string{"op"},
+	)
+
 	realtimeConnections = promauto.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "alyx_realtime_connections",
@@ -79,6 +95,14 @@ var (
 		},
 	)
 
+	realtimeEvictions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alyx_realtime_evictions_total",
+			Help: "Total number of WebSocket clients disconnected for missing pongs or not keeping up",
+		},
+		[]string{"reason"},
+	)
+
 	functionInvocations = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "alyx_function_invocations_total",
@@ -103,6 +127,59 @@ var (
 		},
 		[]string{"runtime", "state"},
 	)
+
+	retentionRowsPruned = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alyx_retention_rows_pruned_total",
+			Help: "Total number of rows deleted by retention policies",
+		},
+		[]string{"collection"},
+	)
+
+	authLogins = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alyx_auth_logins_total",
+			Help: "Total number of password login attempts",
+		},
+		[]string{"result"},
+	)
+
+	authRegistrations = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "alyx_auth_registrations_total",
+			Help: "Total number of user registrations",
+		},
+	)
+
+	authPasswordRehashes = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "alyx_auth_password_rehashes_total",
+			Help: "Total number of password hashes upgraded to the current algorithm or parameters at login",
+		},
+	)
+
+	authTokenRefreshes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alyx_auth_token_refreshes_total",
+			Help: "Total number of refresh token exchanges",
+		},
+		[]string{"result"},
+	)
+
+	authRateLimited = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alyx_auth_rate_limited_total",
+			Help: "Total number of auth requests rejected by rate limiting",
+		},
+		[]string{"endpoint"},
+	)
+
+	authActiveSessions = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "alyx_auth_active_sessions",
+			Help: "Number of sessions that have not expired",
+		},
+	)
 )
 
 func Handler() http.Handler {
@@ -116,6 +193,11 @@ func RecordHTTPRequest(method, path string, status int, duration time.Duration,
 	httpResponseSize.WithLabelValues(method, path).Observe(float64(responseSize))
 }
 
+// RecordJSONDecodeError counts a request body rejected by the JSON decoder.
+func RecordJSONDecodeError(reason string) {
+	httpJSONDecodeErrors.WithLabelValues(reason).Inc()
+}
+
 func IncrementInFlight() {
 	httpRequestsInFlight.Inc()
 }
@@ -130,11 +212,19 @@ func UpdateDBStats(open, inUse, idle int) {
 	dbConnectionsIdle.Set(float64(idle))
 }
 
+func RecordDBRetry(op string) {
+	dbRetries.WithLabelValues(op).Inc()
+}
+
 func UpdateRealtimeStats(connections, subscriptions int) {
 	realtimeConnections.Set(float64(connections))
 	realtimeSubscriptions.Set(float64(subscriptions))
 }
 
+func RecordRealtimeEviction(reason string) {
+	realtimeEvictions.WithLabelValues(reason).Inc()
+}
+
 func RecordFunctionInvocation(name, runtime, status string, duration time.Duration) {
 	functionInvocations.WithLabelValues(name, runtime, status).Inc()
 	functionDuration.WithLabelValues(name, runtime).Observe(duration.Seconds())
@@ -145,6 +235,44 @@ func UpdateFunctionPoolStats(runtime string, ready, busy int) {
 	functionPoolSize.WithLabelValues(runtime, "busy").Set(float64(busy))
 }
 
+func RecordRetentionPruned(collection string, rows int64) {
+	retentionRowsPruned.WithLabelValues(collection).Add(float64(rows))
+}
+
+// AuthMetrics records auth activity for the auth service.
+type AuthMetrics struct{}
+
+func (AuthMetrics) Login(success bool) {
+	authLogins.WithLabelValues(authResult(success)).Inc()
+}
+
+func (AuthMetrics) Registration() {
+	authRegistrations.Inc()
+}
+
+func (AuthMetrics) TokenRefresh(success bool) {
+	authTokenRefreshes.WithLabelValues(authResult(success)).Inc()
+}
+
+func (AuthMetrics) ActiveSessions(n int) {
+	authActiveSessions.Set(float64(n))
+}
+
+func (AuthMetrics) PasswordRehash() {
+	authPasswordRehashes.Inc()
+}
+
+func authResult(success bool) string {
+	if success {
+		return "success"
+	}
+	return "failure"
+}
+
+func RecordAuthRateLimited(endpoint string) {
+	authRateLimited.WithLabelValues(endpoint).Inc()
+}
+
 func NormalizePath(path string) string {
 	if len(path) > 100 {
 		path = path[:100]
@@ -4,7 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 
+	"gopkg.in/yaml.v3"
+
+	"github.com/watzon/alyx/internal/buildinfo"
+	"github.com/watzon/alyx/internal/changefeed"
+	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/schema"
 )
 
@@ -25,6 +31,7 @@ type Spec struct {
 	Info       Info                  `json:"info"`
 	Servers    []Server              `json:"servers,omitempty"`
 	Paths      map[string]*PathItem  `json:"paths"`
+	Webhooks   map[string]*PathItem  `json:"webhooks,omitempty"`
 	Components *Components           `json:"components,omitempty"`
 	Tags       []Tag                 `json:"tags,omitempty"`
 	Security   []SecurityRequirement `json:"security,omitempty"`
@@ -71,6 +78,20 @@ type Operation struct {
 	RequestBody *RequestBody          `json:"requestBody,omitempty"`
 	Responses   map[string]Response   `json:"responses"`
 	Security    []SecurityRequirement `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// MarshalJSON encodes the operation, writing security: [] for a non-nil empty
+// Security, which marks the operation as public.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	type plain Operation
+	if o.Security == nil || len(o.Security) > 0 {
+		return json.Marshal(plain(o))
+	}
+	return json.Marshal(struct {
+		plain
+		Security []SecurityRequirement `json:"security"`
+	}{plain: plain(o), Security: o.Security})
 }
 
 type Parameter struct {
@@ -88,16 +109,31 @@ type RequestBody struct {
 }
 
 type MediaType struct {
-	Schema *Schema `json:"schema,omitempty"`
+	Schema   *Schema            `json:"schema,omitempty"`
+	Example  any                `json:"example,omitempty"`
+	Examples map[string]Example `json:"examples,omitempty"`
+}
+
+type Example struct {
+	Summary string `json:"summary,omitempty"`
+	Value   any    `json:"value"`
 }
 
 type Response struct {
 	Description string               `json:"description"`
+	Headers     map[string]*Header   `json:"headers,omitempty"`
 	Content     map[string]MediaType `json:"content,omitempty"`
 }
 
+type Header struct {
+	Ref         string  `json:"$ref,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
 type Components struct {
 	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	Headers         map[string]*Header         `json:"headers,omitempty"`
 	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
 }
 
@@ -117,6 +153,24 @@ type Schema struct {
 	Maximum              *float64           `json:"maximum,omitempty"`
 	Pattern              string             `json:"pattern,omitempty"`
 	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Example              any                `json:"example,omitempty"`
+	Examples             []any              `json:"examples,omitempty"`
+	// NoAdditionalProperties emits additionalProperties: false.
+	NoAdditionalProperties bool `json:"-"`
+}
+
+// MarshalJSON encodes the schema, writing additionalProperties: false when
+// NoAdditionalProperties is set.
+func (s Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if !s.NoAdditionalProperties {
+		return json.Marshal(plain(s))
+	}
+	return json.Marshal(struct {
+		plain
+		AdditionalProperties bool `json:"additionalProperties"`
+	}{plain: plain(s)})
 }
 
 type Tag struct {
@@ -136,11 +190,18 @@ type SecurityRequirement map[string][]string
 type GeneratorConfig struct {
 	Title       string
 	Description string
-	Version     string
-	ServerURL   string
+	// Version is the API version. Empty, the Alyx version is used.
+	Version   string
+	ServerURL string
+	// ErrorFormat is the server's error_format; "problem+json" documents
+	// RFC 7807 error bodies instead of the default Alyx shape.
+	ErrorFormat string
 }
 
 func Generate(s *schema.Schema, cfg GeneratorConfig) *Spec {
+	if cfg.Version == "" {
+		cfg.Version = buildinfo.Version
+	}
 	spec := &Spec{
 		OpenAPI: "3.1.0",
 		Info: Info{
@@ -171,8 +232,10 @@ func Generate(s *schema.Schema, cfg GeneratorConfig) *Spec {
 	}
 
 	collectionNames := make([]string, 0, len(s.Collections))
-	for name := range s.Collections {
-		collectionNames = append(collectionNames, name)
+	for name, col := range s.Collections {
+		if col.Exposed() {
+			collectionNames = append(collectionNames, name)
+		}
 	}
 	sort.Strings(collectionNames)
 
@@ -185,6 +248,7 @@ func Generate(s *schema.Schema, cfg GeneratorConfig) *Spec {
 
 		spec.Components.Schemas[name] = generateSchema(col)
 		spec.Components.Schemas[name+"Input"] = generateInputSchema(col)
+		spec.Components.Schemas[name+"Patch"] = generatePatchSchema(col)
 
 		listPath := fmt.Sprintf("/api/collections/%s", name)
 		itemPath := fmt.Sprintf("/api/collections/%s/{id}", name)
@@ -199,6 +263,47 @@ func Generate(s *schema.Schema, cfg GeneratorConfig) *Spec {
 			Patch:  generateUpdateOperation(name),
 			Delete: generateDeleteOperation(name),
 		}
+
+		spec.Paths[listPath+"/upsert"] = &PathItem{
+			Put: generateUpsertOperation(name, upsertKeyNames(col)),
+		}
+
+		if fields := blobFieldNames(col); len(fields) > 0 {
+			spec.Paths[itemPath+"/blob/{field}"] = &PathItem{
+				Get: generateGetBlobOperation(name, fields),
+				Put: generatePutBlobOperation(name, fields),
+			}
+			spec.Components.Schemas["BlobInfo"] = blobInfoSchema()
+		}
+
+		if len(col.Checks) > 0 {
+			checkFailed := Response{
+				Description: "A collection check failed",
+				Content:     map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}},
+			}
+			spec.Paths[listPath].Post.Responses["422"] = checkFailed
+			spec.Paths[itemPath].Patch.Responses["422"] = Response{
+				Description: "A collection check failed, or null was given for a field that is not nullable",
+				Content:     checkFailed.Content,
+			}
+			spec.Paths[listPath+"/upsert"].Put.Responses["422"] = checkFailed
+		}
+
+		spec.Paths["/api/sync/"+name] = &PathItem{
+			Get:  generateSyncPullOperation(name),
+			Post: generateSyncPushOperation(name),
+		}
+
+		if col.History != nil {
+			spec.Paths[itemPath+"/history"] = &PathItem{
+				Get: generateHistoryOperation(name),
+			}
+			spec.Components.Schemas["HistoryEntry"] = historyEntrySchema()
+		}
+
+		if col.Docs != nil {
+			applyCollectionDocs(spec, name, col.Docs)
+		}
 	}
 
 	spec.Components.Schemas["Error"] = &Schema{
@@ -212,26 +317,74 @@ func Generate(s *schema.Schema, cfg GeneratorConfig) *Spec {
 		},
 		Required: []string{"error"},
 	}
+	if cfg.ErrorFormat == config.ErrorFormatProblem {
+		spec.Components.Schemas["Error"] = &Schema{
+			Type:        "object",
+			Description: "RFC 7807 problem details",
+			Properties: map[string]*Schema{
+				"type":       {Type: "string", Format: "uri", Description: "Stable problem type URI derived from the error code"},
+				"title":      {Type: "string", Description: "HTTP status text"},
+				"status":     {Type: "integer", Description: "HTTP status code"},
+				"detail":     {Type: "string", Description: "Error message"},
+				"instance":   {Type: "string", Description: "Request path"},
+				"code":       {Type: "string", Description: "Error code"},
+				"details":    {Type: "object", Description: "Additional error details"},
+				"request_id": {Type: "string", Description: "Request ID for tracing"},
+			},
+			Required: []string{"type", "title", "status"},
+		}
+	}
 
 	spec.Components.Schemas["ListResponse"] = &Schema{
 		Type: "object",
 		Properties: map[string]*Schema{
-			"docs":   {Type: "array", Items: &Schema{Type: "object"}},
-			"total":  {Type: "integer", Description: "Total number of documents"},
-			"limit":  {Type: "integer", Description: "Limit used in query"},
-			"offset": {Type: "integer", Description: "Offset used in query"},
+			"docs":            {Type: "array", Items: &Schema{Type: "object"}},
+			"total":           {Type: "integer", Description: "Total number of documents; omitted when total=none"},
+			"total_estimated": {Type: "boolean", Description: "Present and true when total is an estimate"},
+			"limit":           {Type: "integer", Description: "Limit used in query"},
+			"offset":          {Type: "integer", Description: "Offset used in query"},
 		},
-		Required: []string{"docs", "total"},
+		Required: []string{"docs"},
 	}
 
+	spec.Components.Schemas["UserMetadata"] = userMetadataSchema(s.UserMetadata)
+
 	addHealthEndpoints(spec)
-	addAuthEndpoints(spec)
+	roles := s.AllRoles()
+	addAuthEndpoints(spec, roles)
 	addFunctionEndpoints(spec)
-	addAdminEndpoints(spec)
+	addTypedFunctionEndpoints(spec, s.Functions)
+	addViewEndpoints(spec, s.Views)
+	addStorageEndpoints(spec, s.Buckets)
+	addAdminEndpoints(spec, roles)
+	addEventWebhooks(spec, collectionNames)
+
+	if cfg.ErrorFormat == config.ErrorFormatProblem {
+		useProblemMediaType(spec)
+	}
 
 	return spec
 }
 
+// useProblemMediaType moves every Error response to application/problem+json.
+func useProblemMediaType(spec *Spec) {
+	for _, item := range spec.Paths {
+		for _, op := range []*Operation{item.Get, item.Post, item.Put, item.Patch, item.Delete} {
+			if op == nil {
+				continue
+			}
+			for status, resp := range op.Responses {
+				media, ok := resp.Content["application/json"]
+				if !ok || media.Schema == nil || media.Schema.Ref != "#/components/schemas/Error" {
+					continue
+				}
+				resp.Content = map[string]MediaType{"application/problem+json": media}
+				op.Responses[status] = resp
+			}
+		}
+	}
+}
+
 func addHealthEndpoints(spec *Spec) {
 	spec.Tags = append(spec.Tags, Tag{
 		Name:        "health",
@@ -253,11 +406,32 @@ func addHealthEndpoints(spec *Spec) {
 						"status":  {Type: "string", Enum: []string{"healthy", "degraded", "unhealthy"}},
 						"latency": {Type: "string"},
 						"message": {Type: "string"},
+						"details": {Type: "object", Description: "Component-specific details"},
 					},
 				},
 			},
 		},
 		Required: []string{"status", "version", "timestamp"},
+		Example: map[string]any{
+			"status":    "healthy",
+			"version":   "0.1.0",
+			"uptime":    "1h2m3s",
+			"timestamp": "2024-01-01T00:00:00Z",
+			"components": map[string]any{
+				"database": map[string]any{"status": "healthy", "latency": "120µs"},
+				"functions": map[string]any{
+					"status":  "healthy",
+					"latency": "45ms",
+					"details": map[string]any{
+						"runtime":   "subprocess",
+						"functions": 3,
+						"runtimes": []any{
+							map[string]any{"runtime": "node", "command": "node", "version": "v20.11.0", "functions": 3},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	spec.Paths["/health"] = &PathItem{
@@ -291,7 +465,7 @@ func addHealthEndpoints(spec *Spec) {
 		Get: &Operation{
 			Tags:        []string{"health"},
 			Summary:     "Readiness probe",
-			Description: "Readiness check for Kubernetes. Returns 200 if the server can handle requests (database connected).",
+			Description: "Readiness check for Kubernetes. Returns 200 if the server can handle requests (database connected, and function runtimes available when health.require_functions is set).",
 			OperationID: "readiness",
 			Security:    []SecurityRequirement{},
 			Responses: map[string]Response{
@@ -314,6 +488,29 @@ func addHealthEndpoints(spec *Spec) {
 		},
 	}
 
+	spec.Paths["/api/version"] = &PathItem{
+		Get: &Operation{
+			Tags:        []string{"health"},
+			Summary:     "Server version",
+			Description: "Returns the build information of the running server and the optional subsystems it has enabled.",
+			OperationID: "version",
+			Security:    []SecurityRequirement{},
+			Responses: map[string]Response{
+				"200": {Description: "Build information", Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"version":    {Type: "string"},
+						"commit":     {Type: "string"},
+						"build_date": {Type: "string"},
+						"go_version": {Type: "string"},
+						"features":   {Type: "array", Items: &Schema{Type: "string"}},
+					},
+					Required: []string{"version", "go_version", "features"},
+				}}}},
+			},
+		},
+	}
+
 	spec.Paths["/metrics"] = &PathItem{
 		Get: &Operation{
 			Tags:        []string{"health"},
@@ -328,7 +525,7 @@ func addHealthEndpoints(spec *Spec) {
 	}
 }
 
-func addAuthEndpoints(spec *Spec) {
+func addAuthEndpoints(spec *Spec, roles []string) {
 	spec.Tags = append(spec.Tags, Tag{
 		Name:        "auth",
 		Description: "Authentication endpoints",
@@ -340,10 +537,12 @@ func addAuthEndpoints(spec *Spec) {
 			"id":         {Type: "string", Format: "uuid"},
 			"email":      {Type: "string", Format: "email"},
 			"verified":   {Type: "boolean"},
-			"role":       {Type: "string", Enum: []string{"user", "admin"}},
+			"role":       {Type: "string", Enum: roles},
 			"created_at": {Type: "string", Format: "date-time"},
 			"updated_at": {Type: "string", Format: "date-time"},
-			"metadata":   {Type: "object", AdditionalProperties: &Schema{}},
+			"metadata":   {Ref: "#/components/schemas/UserMetadata"},
+
+			"deletion_requested_at": {Type: "string", Format: "date-time", Description: "Set while the account is scheduled for deletion"},
 		},
 		Required: []string{"id", "email", "verified", "role", "created_at", "updated_at"},
 	}
@@ -373,7 +572,7 @@ func addAuthEndpoints(spec *Spec) {
 		Properties: map[string]*Schema{
 			"email":    {Type: "string", Format: "email"},
 			"password": {Type: "string", MinLength: intPtr(defaultPasswordMinLength)},
-			"metadata": {Type: "object", AdditionalProperties: &Schema{}},
+			"metadata": {Ref: "#/components/schemas/UserMetadata"},
 		},
 		Required: []string{"email", "password"},
 	}
@@ -491,6 +690,131 @@ func addAuthEndpoints(spec *Spec) {
 				"401": {Description: "Not authenticated", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 			},
 		},
+		Patch: &Operation{
+			Tags:        []string{"auth"},
+			Summary:     "Update current user",
+			Description: "Update the current user's metadata with a JSON merge patch: keys set to null are removed, other keys are merged into the stored metadata, and a null metadata value clears it",
+			OperationID: "updateCurrentUser",
+			RequestBody: &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"application/json": {Schema: &Schema{Ref: "#/components/schemas/UpdateMeInput"}},
+				},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Updated user", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/User"}}}},
+				"400": {Description: "Invalid patch or metadata does not match the declared shape", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"401": {Description: "Not authenticated", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+		Delete: &Operation{
+			Tags:        []string{"auth"},
+			Summary:     "Delete current user",
+			Description: "Schedule the current user's account for deletion and revoke all sessions. The password is required unless the access token was issued within the last five minutes. The account and the documents that reference it are removed after the grace period unless it is restored.",
+			OperationID: "deleteCurrentUser",
+			RequestBody: &RequestBody{
+				Content: map[string]MediaType{
+					"application/json": {Schema: &Schema{Ref: "#/components/schemas/DeleteMeInput"}},
+				},
+			},
+			Responses: map[string]Response{
+				"202": {Description: "Deletion scheduled", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/DeletionScheduled"}}}},
+				"401": {Description: "Not authenticated, wrong password or re-authentication required", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Paths["/api/auth/me/restore"] = &PathItem{
+		Post: &Operation{
+			Tags:        []string{"auth"},
+			Summary:     "Restore account",
+			Description: "Cancel a pending account deletion using the token from the deletion email. GET with a token query parameter is also accepted.",
+			OperationID: "restoreAccount",
+			Security:    []SecurityRequirement{},
+			RequestBody: &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"application/json": {Schema: &Schema{Ref: "#/components/schemas/TokenInput"}},
+				},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Account restored", Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type:       "object",
+					Properties: map[string]*Schema{"user": {Ref: "#/components/schemas/User"}},
+				}}}},
+				"400": {Description: "Invalid or expired token", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Components.Schemas["RateLimitStatus"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name":           {Type: "string", Description: "Limit name from auth.rate_limit, such as login"},
+			"limit":          {Type: "integer", Description: "Requests allowed per window"},
+			"remaining":      {Type: "integer", Description: "Requests left in the current window"},
+			"window_seconds": {Type: "number", Description: "Window length in seconds"},
+			"reset":          {Type: "string", Format: "date-time", Description: "When the window resets"},
+			"reset_seconds":  {Type: "integer", Description: "Seconds until the window resets"},
+		},
+		Required: []string{"name", "limit", "remaining", "window_seconds", "reset", "reset_seconds"},
+	}
+
+	spec.Paths["/api/auth/me/limits"] = &PathItem{
+		Get: &Operation{
+			Tags:        []string{"auth"},
+			Summary:     "Get rate limit quotas",
+			Description: "Get the caller's remaining quota under each configured rate limit. Limits apply per client address, and checking them does not count against any.",
+			OperationID: "getRateLimits",
+			Responses: map[string]Response{
+				"200": {Description: "Rate limit quotas", Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"limits": {Type: "array", Items: &Schema{Ref: "#/components/schemas/RateLimitStatus"}},
+					},
+					Required: []string{"limits"},
+				}}}},
+				"401": {Description: "Not authenticated", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	addRateLimits(spec,
+		spec.Paths["/api/auth/register"].Post,
+		spec.Paths["/api/auth/login"].Post,
+		spec.Paths["/api/auth/me/restore"].Post,
+	)
+
+	spec.Components.Schemas["DeleteMeInput"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"password": {Type: "string", Format: "password", Description: "Current password"},
+		},
+	}
+
+	spec.Components.Schemas["DeletionScheduled"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"deletion_requested_at": {Type: "string", Format: "date-time"},
+			"purge_at":              {Type: "string", Format: "date-time", Description: "When the account will be removed"},
+		},
+		Required: []string{"deletion_requested_at", "purge_at"},
+	}
+
+	spec.Components.Schemas["TokenInput"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"token": {Type: "string"},
+		},
+		Required: []string{"token"},
+	}
+
+	spec.Components.Schemas["UpdateMeInput"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"metadata": {Type: "object", Nullable: true, AdditionalProperties: &Schema{}, Description: "JSON merge patch applied to the user's metadata"},
+		},
+		Required: []string{"metadata"},
 	}
 
 	spec.Components.Schemas["ProvidersResponse"] = &Schema{
@@ -523,10 +847,11 @@ func addAuthEndpoints(spec *Spec) {
 			Security:    []SecurityRequirement{},
 			Parameters: []Parameter{
 				{Name: "provider", In: "path", Required: true, Description: "OAuth provider name (e.g., github, google)", Schema: &Schema{Type: "string"}},
+				{Name: "redirect_uri", In: "query", Description: "Where to send the user with a one-time code once the login completes. Must be in auth.oauth.redirect_uri_allowlist. Without it the callback responds with the tokens as JSON.", Schema: &Schema{Type: "string", Format: "uri"}},
 			},
 			Responses: map[string]Response{
 				"307": {Description: "Redirect to OAuth provider"},
-				"400": {Description: "Provider name is required", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"400": {Description: "Provider name is required or redirect_uri is not allowed", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 				"404": {Description: "OAuth provider not found", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 			},
 		},
@@ -536,7 +861,7 @@ func addAuthEndpoints(spec *Spec) {
 		Get: &Operation{
 			Tags:        []string{"auth"},
 			Summary:     "OAuth callback",
-			Description: "Handles the OAuth callback from the provider and completes authentication",
+			Description: "Handles the OAuth callback from the provider and completes authentication. If the login was started with a redirect_uri, the user is redirected there with a one-time code for /api/auth/oauth/exchange, or with error and error_description on failure.",
 			OperationID: "oauthCallback",
 			Security:    []SecurityRequirement{},
 			Parameters: []Parameter{
@@ -546,18 +871,142 @@ func addAuthEndpoints(spec *Spec) {
 			},
 			Responses: map[string]Response{
 				"200": {Description: "OAuth login successful", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/AuthResponse"}}}},
+				"302": {Description: "Redirect to the login's redirect_uri with a code, or an error"},
 				"400": {Description: "Invalid callback parameters or OAuth error", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 				"404": {Description: "OAuth provider not found", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 				"409": {Description: "OAuth account already linked to another user", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 			},
 		},
 	}
+
+	spec.Components.Schemas["OAuthExchangeInput"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code": {Type: "string", Description: "One-time code from the redirect_uri query"},
+		},
+		Required: []string{"code"},
+	}
+
+	spec.Paths["/api/auth/oauth/exchange"] = &PathItem{
+		Post: &Operation{
+			Tags:        []string{"auth"},
+			Summary:     "Exchange OAuth login code",
+			Description: "Exchange the one-time code an OAuth login sent to its redirect_uri for the token pair. Codes expire after a minute and can be used once.",
+			OperationID: "oauthExchange",
+			Security:    []SecurityRequirement{},
+			RequestBody: &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"application/json": {Schema: &Schema{Ref: "#/components/schemas/OAuthExchangeInput"}},
+				},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "OAuth login successful", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/AuthResponse"}}}},
+				"400": {Description: "Invalid, expired or already used code", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+	addRateLimits(spec, spec.Paths["/api/auth/oauth/exchange"].Post)
+}
+
+// rateLimitHeaders are the headers sent by rate limited endpoints, per the
+// IETF RateLimit header fields draft.
+var rateLimitHeaders = map[string]*Header{
+	"RateLimit-Limit":     {Description: "Requests allowed in the current window", Schema: &Schema{Type: "integer"}},
+	"RateLimit-Remaining": {Description: "Requests left in the current window", Schema: &Schema{Type: "integer"}},
+	"RateLimit-Reset":     {Description: "Seconds until the window resets", Schema: &Schema{Type: "integer"}},
+}
+
+// addRateLimits documents the RateLimit headers on every response of ops and
+// adds their 429 response.
+func addRateLimits(spec *Spec, ops ...*Operation) {
+	if spec.Components.Headers == nil {
+		spec.Components.Headers = make(map[string]*Header)
+	}
+	for name, header := range rateLimitHeaders {
+		spec.Components.Headers[name] = header
+	}
+	spec.Components.Headers["Retry-After"] = &Header{Description: "Seconds to wait before retrying", Schema: &Schema{Type: "integer"}}
+
+	refs := func(names ...string) map[string]*Header {
+		headers := make(map[string]*Header, len(names))
+		for _, name := range names {
+			headers[name] = &Header{Ref: "#/components/headers/" + name}
+		}
+		return headers
+	}
+
+	for _, op := range ops {
+		for status, resp := range op.Responses {
+			resp.Headers = refs("RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset")
+			op.Responses[status] = resp
+		}
+		op.Responses["429"] = Response{
+			Description: "Rate limit exceeded",
+			Headers:     refs("RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"),
+			Content:     map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}},
+		}
+	}
 }
 
 func intPtr(i int) *int {
 	return &i
 }
 
+// userMetadataSchema converts the schema's userMetadata declaration. Without
+// one, metadata is any JSON object.
+func userMetadataSchema(m *schema.MetadataSchema) *Schema {
+	if m == nil {
+		return &Schema{Type: typeObject, AdditionalProperties: &Schema{}}
+	}
+	out := metadataToSchema(m)
+	out.Type = typeObject
+	return out
+}
+
+func metadataToSchema(m *schema.MetadataSchema) *Schema {
+	out := &Schema{
+		Type:        m.ValueType(),
+		Description: m.Description,
+		MinLength:   m.MinLength,
+		MaxLength:   m.MaxLength,
+		Minimum:     m.Minimum,
+		Maximum:     m.Maximum,
+	}
+
+	for _, e := range m.Enum {
+		str, ok := e.(string)
+		if !ok {
+			// Schema.Enum only holds strings; numeric enums are still
+			// enforced by the server.
+			out.Enum = nil
+			break
+		}
+		out.Enum = append(out.Enum, str)
+	}
+
+	if out.Type == typeObject {
+		if len(m.Properties) > 0 {
+			out.Properties = make(map[string]*Schema, len(m.Properties))
+			for _, name := range m.SortedProperties() {
+				out.Properties[name] = metadataToSchema(m.Properties[name])
+			}
+		}
+		out.Required = m.Required
+		if m.AllowsAdditional() {
+			out.AdditionalProperties = &Schema{}
+		} else {
+			out.NoAdditionalProperties = true
+		}
+	}
+
+	if m.Items != nil {
+		out.Items = metadataToSchema(m.Items)
+	}
+
+	return out
+}
+
 func generateSchema(col *schema.Collection) *Schema {
 	s := &Schema{
 		Type:       "object",
@@ -570,8 +1019,17 @@ func generateSchema(col *schema.Collection) *Schema {
 		}
 
 		prop := fieldToSchema(field)
+		if field.Type == schema.FieldTypeBlob {
+			// Documents carry the blob's metadata, not its content.
+			prop = &Schema{Ref: "#/components/schemas/BlobInfo", Nullable: field.Nullable}
+		}
 		s.Properties[field.Name] = prop
 
+		if field.ReadRule != "" {
+			// A field the caller may not see is left out of the document.
+			prop.Description = strings.TrimSpace(prop.Description + fmt.Sprintf(" Only included when its read rule allows the caller: `%s`.", field.ReadRule))
+			continue
+		}
 		if !field.Nullable && !field.HasDefault() && !field.Primary {
 			s.Required = append(s.Required, field.Name)
 		}
@@ -585,6 +1043,7 @@ func generateInputSchema(col *schema.Collection) *Schema {
 		Type:       "object",
 		Properties: make(map[string]*Schema),
 	}
+	hasSlug := false
 
 	for _, field := range col.OrderedFields() {
 		if field.Internal || field.Primary || field.IsTimestampNow() || field.IsAutoUpdateTimestamp() {
@@ -594,14 +1053,79 @@ func generateInputSchema(col *schema.Collection) *Schema {
 		prop := fieldToSchema(field)
 		s.Properties[field.Name] = prop
 
-		if !field.Nullable && !field.HasDefault() {
+		if field.Slug != nil {
+			applySlugInput(field, prop)
+			hasSlug = true
+		}
+		if field.Type == schema.FieldTypeBlob {
+			prop.Description = blobInputDescription(field)
+		}
+		if col.Tenant != nil && col.Tenant.Field == field.Name {
+			prop.Description = fmt.Sprintf("Tenant. Set by the server from %s; a value for another tenant is rejected.", col.Tenant.Source)
+		}
+
+		if !col.OptionalOnCreate(field) {
 			s.Required = append(s.Required, field.Name)
 		}
 	}
 
+	if hasSlug {
+		s.Properties[schema.RegenerateSlugKey] = &Schema{
+			Type:        "boolean",
+			Description: "Update only: regenerate slug fields from their source fields. Slugs are otherwise kept when the source changes.",
+		}
+	}
+
+	if len(col.Checks) > 0 {
+		s.Description = checksDescription(col.Checks)
+	}
+
+	return s
+}
+
+// generatePatchSchema returns the body of a partial update. Every field is
+// optional: an absent field is left unchanged, and null clears a nullable
+// field.
+func generatePatchSchema(col *schema.Collection) *Schema {
+	s := generateInputSchema(col)
+	s.Required = nil
 	return s
 }
 
+// checksDescription lists a collection's checks, which a write must pass or
+// fail with 422 CHECK_FAILED.
+func checksDescription(checks []*schema.Check) string {
+	var b strings.Builder
+	b.WriteString("Writes must pass these checks, evaluated against the document after the write, or fail with 422 CHECK_FAILED:\n")
+	for _, check := range checks {
+		fmt.Fprintf(&b, "\n- %s: `%s`", check.Name, check.Expr)
+		if check.Message != "" {
+			fmt.Fprintf(&b, " (%s)", check.Message)
+		}
+	}
+	return b.String()
+}
+
+// blobInputDescription documents how a blob field is written through the
+// document endpoints.
+func blobInputDescription(f *schema.Field) string {
+	if f.Storage != "" {
+		return fmt.Sprintf("Stored in the %s bucket. Only null, which clears the content, is accepted here; "+
+			"upload content with PUT /blob/%s.", f.Storage, f.Name)
+	}
+	return fmt.Sprintf("Base64 encoded content. Larger content is better uploaded with PUT /blob/%s.", f.Name)
+}
+
+// applySlugInput documents how the server derives an omitted slug field.
+func applySlugInput(f *schema.Field, s *Schema) {
+	maxLen := f.Slug.Max()
+	s.Pattern = schema.SlugPattern
+	s.MaxLength = &maxLen
+	s.Description = fmt.Sprintf("URL-safe slug. When omitted on create, it is generated from %s: transliterated to ASCII, "+
+		"lowercased, with other characters replaced by hyphens, and suffixed -2, -3, ... if it is already taken. "+
+		"On update it changes only when given explicitly or when %s is true.", f.Slug.From, schema.RegenerateSlugKey)
+}
+
 func fieldToSchema(f *schema.Field) *Schema {
 	s := &Schema{
 		Nullable: f.Nullable,
@@ -609,6 +1133,12 @@ func fieldToSchema(f *schema.Field) *Schema {
 
 	setSchemaTypeAndFormat(f, s)
 	applyFieldValidation(f, s)
+	if f.Encrypted {
+		s.Description = "Encrypted at rest. Cannot be used in filter, sort or search."
+	}
+	if f.CaseInsensitive() {
+		s.Description = "Case-insensitive: equality filters, lookups and uniqueness ignore the case of ASCII letters, so Foo and foo are equal."
+	}
 
 	return s
 }
@@ -677,31 +1207,58 @@ func applyFieldValidation(f *schema.Field, s *Schema) {
 	}
 }
 
+// limitClampedHeader is set on list responses whose requested limit was
+// clamped to the collection's maximum.
+const limitClampedHeader = "X-Alyx-Limit-Clamped"
+
+// locationHeader describes the Location header of a 201 response.
+func locationHeader(name string) map[string]*Header {
+	return map[string]*Header{
+		"Location": {Description: fmt.Sprintf("Path of the created document, /api/collections/%s/{id}", name), Schema: &Schema{Type: "string"}},
+	}
+}
+
 func generateListOperation(name string, col *schema.Collection) *Operation {
+	defaultLimit, maxLimit := col.List.Limits()
+	limitDescription := fmt.Sprintf("Maximum number of documents to return (default: %d, max: %d; larger limits are clamped)", defaultLimit, maxLimit)
+	sortSchema := &Schema{Type: "string"}
+	sortDescription := "Comma separated fields to sort by, each prefixed with '-' for descending and optionally suffixed with ':nullsfirst' or ':nullslast' (e.g., '-published,created_at'). JSON and blob fields cannot be sorted"
+	if col.List != nil && col.List.DefaultSort != "" {
+		sortSchema.Default = col.List.DefaultSort
+		sortDescription += fmt.Sprintf("; defaults to '%s'", col.List.DefaultSort)
+	}
+
 	return &Operation{
 		Tags:        []string{name},
 		Summary:     fmt.Sprintf("List %s", name),
-		Description: fmt.Sprintf("Retrieve a paginated list of %s documents", name),
+		Description: fmt.Sprintf("Retrieve a paginated list of %s documents. HEAD returns the same status and headers without a body.", name),
 		OperationID: fmt.Sprintf("list%s", capitalize(name)),
 		Parameters: []Parameter{
-			{Name: "limit", In: "query", Description: "Maximum number of documents to return (default: 100, max: 1000)", Schema: &Schema{Type: "integer"}},
+			{Name: "limit", In: "query", Description: limitDescription, Schema: &Schema{Type: "integer", Default: defaultLimit}},
 			{Name: "offset", In: "query", Description: "Number of documents to skip", Schema: &Schema{Type: "integer"}},
-			{Name: "sort", In: "query", Description: "Sort order (e.g., '-created_at' for descending)", Schema: &Schema{Type: "string"}},
+			{Name: "sort", In: "query", Description: sortDescription, Schema: sortSchema},
 			{Name: "filter", In: "query", Description: "Filter expression (e.g., 'field:eq:value')", Schema: &Schema{Type: "array", Items: &Schema{Type: "string"}}},
 			{Name: "expand", In: "query", Description: "Relations to expand", Schema: &Schema{Type: "string"}},
+			{Name: "total", In: "query", Description: "How to compute total: exact (default), none to skip counting, or estimate to use table statistics when no filter is given", Schema: &Schema{Type: "string", Enum: []string{"exact", "none", "estimate"}}},
 		},
 		Responses: map[string]Response{
 			"200": {
 				Description: "Successful response",
+				Headers: map[string]*Header{
+					limitClampedHeader: {Description: "The requested limit, when it exceeded the maximum and was clamped", Schema: &Schema{Type: "integer"}},
+					"X-Total-Count":    {Description: "The total in the body; omitted when total=none", Schema: &Schema{Type: "integer"}},
+				},
 				Content: map[string]MediaType{
 					"application/json": {Schema: &Schema{
 						Type: "object",
 						Properties: map[string]*Schema{
-							"docs":   {Type: "array", Items: &Schema{Ref: "#/components/schemas/" + name}},
-							"total":  {Type: "integer"},
-							"limit":  {Type: "integer"},
-							"offset": {Type: "integer"},
+							"docs":            {Type: "array", Items: &Schema{Ref: "#/components/schemas/" + name}},
+							"total":           {Type: "integer", Description: "Omitted when total=none"},
+							"total_estimated": {Type: "boolean"},
+							"limit":           {Type: "integer"},
+							"offset":          {Type: "integer"},
 						},
+						Required: []string{"docs"},
 					}},
 				},
 			},
@@ -715,7 +1272,7 @@ func generateGetOperation(name string) *Operation {
 	return &Operation{
 		Tags:        []string{name},
 		Summary:     fmt.Sprintf("Get %s by ID", name),
-		Description: fmt.Sprintf("Retrieve a single %s document by its ID", name),
+		Description: fmt.Sprintf("Retrieve a single %s document by its ID. HEAD returns the same status and headers without a body, for existence checks.", name),
 		OperationID: fmt.Sprintf("get%s", capitalize(name)),
 		Parameters: []Parameter{
 			{Name: "id", In: "path", Required: true, Description: "Document ID", Schema: &Schema{Type: "string"}},
@@ -728,6 +1285,61 @@ func generateGetOperation(name string) *Operation {
 	}
 }
 
+func generateHistoryOperation(name string) *Operation {
+	errorResponse := &Schema{Ref: "#/components/schemas/Error"}
+	defaultLimit, maxLimit := (*schema.ListConfig)(nil).Limits()
+	return &Operation{
+		Tags:        []string{name},
+		Summary:     fmt.Sprintf("Get %s history", name),
+		Description: fmt.Sprintf("List the recorded changes to a %s document, newest first. Available after the document is deleted.", name),
+		OperationID: fmt.Sprintf("get%sHistory", capitalize(name)),
+		Parameters: []Parameter{
+			{Name: "id", In: "path", Required: true, Description: "Document ID", Schema: &Schema{Type: "string"}},
+			{Name: "limit", In: "query", Description: fmt.Sprintf("Maximum number of entries to return (default: %d, max: %d; larger limits are clamped)", defaultLimit, maxLimit), Schema: &Schema{Type: "integer", Default: defaultLimit}},
+			{Name: "offset", In: "query", Description: "Number of entries to skip", Schema: &Schema{Type: "integer"}},
+		},
+		Responses: map[string]Response{
+			"200": {
+				Description: "Successful response",
+				Headers: map[string]*Header{
+					limitClampedHeader: {Description: "The requested limit, when it exceeded the maximum and was clamped", Schema: &Schema{Type: "integer"}},
+				},
+				Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"docs":   {Type: "array", Items: &Schema{Ref: "#/components/schemas/HistoryEntry"}},
+						"total":  {Type: "integer"},
+						"limit":  {Type: "integer"},
+						"offset": {Type: "integer"},
+					},
+					Required: []string{"docs", "total"},
+				}}},
+			},
+			"400": {Description: "Invalid query parameters", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+			"403": {Description: "Access denied by the collection's history rule", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+			"404": {Description: "Document not found", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+			"500": {Description: "Internal server error", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+		},
+	}
+}
+
+// historyEntrySchema describes a row of a collection's history.
+func historyEntrySchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":         {Type: "integer"},
+			"doc_id":     {Type: "string", Description: "ID of the changed document"},
+			"action":     {Type: "string", Enum: []string{schema.HistoryActionCreate, schema.HistoryActionUpdate, schema.HistoryActionDelete}},
+			"actor":      {Type: "string", Description: "ID of the user who made the change, or null"},
+			"changes":    {Type: typeObject, Description: "Changed fields, each as {from, to}; encrypted fields as {redacted: true}", AdditionalProperties: &Schema{}},
+			"snapshot":   {Type: typeObject, Description: "The document after the change, or before a delete, when snapshots are enabled", AdditionalProperties: &Schema{}},
+			"created_at": {Type: "string", Format: "date-time"},
+		},
+		Required: []string{"id", "doc_id", "action", "created_at"},
+	}
+}
+
 func generateCreateOperation(name string) *Operation {
 	return &Operation{
 		Tags:        []string{name},
@@ -742,7 +1354,7 @@ func generateCreateOperation(name string) *Operation {
 			},
 		},
 		Responses: map[string]Response{
-			"201": {Description: "Document created", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/" + name}}}},
+			"201": {Description: "Document created", Headers: locationHeader(name), Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/" + name}}}},
 			"400": {Description: "Invalid request body", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 			"500": {Description: "Internal server error", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 		},
@@ -760,15 +1372,63 @@ func generateUpdateOperation(name string) *Operation {
 		},
 		RequestBody: &RequestBody{
 			Required:    true,
-			Description: "Fields to update",
+			Description: "Fields to update. Absent fields are left unchanged; null clears a nullable field",
 			Content: map[string]MediaType{
-				"application/json": {Schema: &Schema{Ref: "#/components/schemas/" + name + "Input"}},
+				"application/json": {Schema: &Schema{Ref: "#/components/schemas/" + name + "Patch"}},
 			},
 		},
 		Responses: map[string]Response{
 			"200": {Description: "Document updated", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/" + name}}}},
 			"400": {Description: "Invalid request body", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 			"404": {Description: "Document not found", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			"422": {Description: "Null for a field that is not nullable", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			"500": {Description: "Internal server error", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+		},
+	}
+}
+
+// upsertKeyNames returns the fields documents can be upserted by: the
+// primary key and the unique fields that are not encrypted.
+func upsertKeyNames(col *schema.Collection) []string {
+	var names []string
+	for _, field := range col.OrderedFields() {
+		if (field.Primary || field.Unique) && !field.Encrypted {
+			names = append(names, field.Name)
+		}
+	}
+	return names
+}
+
+func generateUpsertOperation(name string, keys []string) *Operation {
+	document := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"created":  {Type: "boolean", Description: "True if the document was created, false if it was updated"},
+			"document": {Ref: "#/components/schemas/" + name},
+		},
+		Required: []string{"created", "document"},
+	}
+	return &Operation{
+		Tags:    []string{name},
+		Summary: fmt.Sprintf("Create or update %s by key", name),
+		Description: fmt.Sprintf("Update the %s document whose key field has the body's value, checked against the update rule, "+
+			"or create the body when there is none, checked against the create rule", name),
+		OperationID: fmt.Sprintf("upsert%s", capitalize(name)),
+		Parameters: []Parameter{
+			{Name: "key", In: "query", Required: true, Description: "Primary key or unique field to match on", Schema: &Schema{Type: "string", Enum: keys}},
+		},
+		RequestBody: &RequestBody{
+			Required:    true,
+			Description: "Document to create or fields to update; must include the key field",
+			Content: map[string]MediaType{
+				"application/json": {Schema: &Schema{Ref: "#/components/schemas/" + name + "Input"}},
+			},
+		},
+		Responses: map[string]Response{
+			"200": {Description: "Document updated", Content: map[string]MediaType{"application/json": {Schema: document}}},
+			"201": {Description: "Document created", Headers: locationHeader(name), Content: map[string]MediaType{"application/json": {Schema: document}}},
+			"400": {Description: "Invalid key or request body", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			"409": {Description: "Document changed during the upsert", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 			"500": {Description: "Internal server error", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 		},
 	}
@@ -791,15 +1451,265 @@ func generateDeleteOperation(name string) *Operation {
 	}
 }
 
-func capitalize(s string) string {
-	if s == "" {
-		return s
+// syncChangeSchema describes a document in a sync pull or push result.
+func syncChangeSchema(name string) map[string]*Schema {
+	return map[string]*Schema{
+		"id":       {Type: "string", Description: "Document ID"},
+		"deleted":  {Type: "boolean", Description: "True for the tombstone of a deleted document"},
+		"rev":      {Type: "string", Description: "Revision of the document, to send as base_rev when changing it"},
+		"document": {Ref: "#/components/schemas/" + name},
 	}
-	return string(s[0]-32) + s[1:]
 }
 
-func addFunctionEndpoints(spec *Spec) {
-	spec.Tags = append(spec.Tags, Tag{
+func generateSyncPullOperation(name string) *Operation {
+	errorResponse := &Schema{Ref: "#/components/schemas/Error"}
+	return &Operation{
+		Tags:    []string{name},
+		Summary: fmt.Sprintf("Pull %s changes", name),
+		Description: fmt.Sprintf("Return the %s documents changed since the cursor, each once, and tombstones for those deleted. "+
+			"Without since, page through a snapshot of every readable document; the cursors it returns then continue with the changes made since. "+
+			"Documents the read rule hides are left out. Requires the change feed.", name),
+		OperationID: fmt.Sprintf("pull%s", capitalize(name)),
+		Parameters: []Parameter{
+			{Name: "since", In: "query", Description: "Cursor returned by the previous pull; omit to start a snapshot", Schema: &Schema{Type: "string"}},
+			{Name: "limit", In: "query", Description: fmt.Sprintf("Maximum number of changes to read (default: %d, max: %d)", changefeed.DefaultLimit, changefeed.MaxLimit), Schema: &Schema{Type: "integer", Default: changefeed.DefaultLimit}},
+		},
+		Responses: map[string]Response{
+			"200": {
+				Description: "Changes since the cursor",
+				Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"changes": {Type: "array", Items: &Schema{
+							Type:       "object",
+							Properties: syncChangeSchema(name),
+							Required:   []string{"id"},
+						}},
+						"cursor":   {Type: "string", Description: "The since of the next pull"},
+						"has_more": {Type: "boolean", Description: "Whether the next pull already has changes"},
+					},
+					Required: []string{"changes", "cursor", "has_more"},
+				}}},
+			},
+			"400": {Description: "Invalid cursor or limit", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+			"410": {Description: "Changes after the cursor are no longer retained (RESYNC_REQUIRED); discard local data and pull without since", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+			"503": {Description: "The change feed is not enabled", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+		},
+	}
+}
+
+func generateSyncPushOperation(name string) *Operation {
+	errorResponse := &Schema{Ref: "#/components/schemas/Error"}
+	result := syncChangeSchema(name)
+	result["conflict"] = &Schema{Type: "boolean", Description: "True if the mutation was not applied because the document changed; document is the server's"}
+	return &Operation{
+		Tags:    []string{name},
+		Summary: fmt.Sprintf("Push %s mutations", name),
+		Description: fmt.Sprintf("Apply a batch of offline mutations to %s in a single transaction, each checked against the create, update or delete rule. "+
+			"A mutation whose base_rev is no longer the document's revision, or that creates an existing document, is not applied and its result is marked conflict. "+
+			"Any other failure rejects the whole batch, with the index of the mutation in details.mutation.", name),
+		OperationID: fmt.Sprintf("push%s", capitalize(name)),
+		RequestBody: &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{"application/json": {Schema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"mutations": {Type: "array", Items: &Schema{
+						Type: "object",
+						Properties: map[string]*Schema{
+							"op":       {Type: "string", Enum: []string{"create", "update", "delete"}},
+							"id":       {Type: "string", Description: "Document ID, generated by the client for creates"},
+							"base_rev": {Type: "string", Description: "Revision the change was made to; required for update and delete"},
+							"data":     {Ref: "#/components/schemas/" + name + "Patch"},
+						},
+						Required: []string{"op", "id"},
+					}},
+				},
+				Required: []string{"mutations"},
+			}}},
+		},
+		Responses: map[string]Response{
+			"200": {
+				Description: "Mutations applied, with a result per mutation in request order",
+				Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"results": {Type: "array", Items: &Schema{Type: "object", Properties: result, Required: []string{"id"}}},
+					},
+					Required: []string{"results"},
+				}}},
+			},
+			"400": {Description: "Invalid mutation", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+			"403": {Description: "A mutation was denied by the collection's rules", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+			"409": {Description: "Documents changed while the batch was applied; retry", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+			"422": {Description: "A mutation failed a collection check or set a required field to null", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+			"503": {Description: "The change feed is not enabled", Content: map[string]MediaType{"application/json": {Schema: errorResponse}}},
+		},
+	}
+}
+
+// blobFieldNames returns the names of the collection's public blob fields.
+func blobFieldNames(col *schema.Collection) []string {
+	var names []string
+	for _, field := range col.OrderedFields() {
+		if field.Type == schema.FieldTypeBlob && !field.Internal {
+			names = append(names, field.Name)
+		}
+	}
+	return names
+}
+
+func blobInfoSchema() *Schema {
+	return &Schema{
+		Type:        "object",
+		Description: "Metadata of a blob field's content, which is read and written through the blob endpoints",
+		Properties: map[string]*Schema{
+			"size": {Type: "integer", Format: "int64", Description: "Content length in bytes"},
+			"mime": {Type: "string", Description: "Content-Type stored with the content"},
+			"etag": {Type: "string", Description: "Hex SHA-256 of the content; empty for content written before blob metadata was recorded"},
+		},
+		Required: []string{"size", "mime", "etag"},
+	}
+}
+
+func blobParameters(fields []string) []Parameter {
+	return []Parameter{
+		{Name: "id", In: "path", Required: true, Description: "Document ID", Schema: &Schema{Type: "string"}},
+		{Name: "field", In: "path", Required: true, Description: "Blob field", Schema: &Schema{Type: "string", Enum: fields}},
+	}
+}
+
+func generateGetBlobOperation(name string, fields []string) *Operation {
+	return &Operation{
+		Tags:        []string{name},
+		Summary:     fmt.Sprintf("Download %s blob", name),
+		Description: "Stream the content of a blob field with its stored Content-Type, under the collection's read rule. Supports Range and conditional requests.",
+		OperationID: fmt.Sprintf("get%sBlob", capitalize(name)),
+		Parameters: append(blobParameters(fields),
+			Parameter{Name: "Range", In: "header", Description: "Byte range to return, e.g. bytes=0-1023", Schema: &Schema{Type: "string"}},
+		),
+		Responses: map[string]Response{
+			"200": {Description: "Blob content", Content: map[string]MediaType{"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}}}},
+			"206": {Description: "Requested range of the blob content", Content: map[string]MediaType{"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}}}},
+			"304": {Description: "Not modified"},
+			"403": {Description: "Access denied", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			"404": {Description: "Document not found or blob has no content", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			"416": {Description: "Range not satisfiable"},
+			"500": {Description: "Internal server error", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+		},
+	}
+}
+
+func generatePutBlobOperation(name string, fields []string) *Operation {
+	return &Operation{
+		Tags:        []string{name},
+		Summary:     fmt.Sprintf("Upload %s blob", name),
+		Description: "Replace the content of a blob field with the raw request body, under the collection's update rule. The request's Content-Type is stored with the content.",
+		OperationID: fmt.Sprintf("put%sBlob", capitalize(name)),
+		Parameters:  blobParameters(fields),
+		RequestBody: &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				"*/*": {Schema: &Schema{Type: "string", Format: "binary"}},
+			},
+		},
+		Responses: map[string]Response{
+			"200": {Description: "Blob stored", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/BlobInfo"}}}},
+			"400": {Description: "Invalid Content-Type or content type not allowed by the bucket", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			"403": {Description: "Access denied", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			"404": {Description: "Document not found", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			"413": {Description: "Content too large", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			"500": {Description: "Internal server error", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+		},
+	}
+}
+
+// applyCollectionDocs merges a collection's docs block from schema.yaml into
+// its tag, component schemas and operations.
+func applyCollectionDocs(spec *Spec, name string, docs *schema.CollectionDocs) {
+	if docs.Description != "" {
+		for i := range spec.Tags {
+			if spec.Tags[i].Name == name {
+				spec.Tags[i].Description = docs.Description
+			}
+		}
+		spec.Components.Schemas[name].Description = docs.Description
+	}
+
+	listItem := spec.Paths[fmt.Sprintf("/api/collections/%s", name)]
+	item := spec.Paths[fmt.Sprintf("/api/collections/%s/{id}", name)]
+	ops := map[string]*Operation{
+		schema.OperationList:   listItem.Get,
+		schema.OperationCreate: listItem.Post,
+		schema.OperationGet:    item.Get,
+		schema.OperationUpdate: item.Patch,
+		schema.OperationDelete: item.Delete,
+	}
+	for opName, op := range ops {
+		override := docs.Operation(opName)
+		if override == nil {
+			continue
+		}
+		if override.Summary != "" {
+			op.Summary = override.Summary
+		}
+		if override.Description != "" {
+			op.Description = override.Description
+		}
+		op.Deprecated = override.Deprecated
+	}
+
+	if len(docs.Examples) == 0 {
+		return
+	}
+
+	exampleNames := make([]string, 0, len(docs.Examples))
+	for exampleName := range docs.Examples {
+		exampleNames = append(exampleNames, exampleName)
+	}
+	sort.Strings(exampleNames)
+
+	docExamples := make(map[string]Example, len(exampleNames))
+	inputExamples := make(map[string]Example, len(exampleNames))
+	docSchema := spec.Components.Schemas[name]
+	inputSchema := spec.Components.Schemas[name+"Input"]
+	for _, exampleName := range exampleNames {
+		value := docs.Examples[exampleName]
+		input := make(map[string]any, len(value))
+		for field, v := range value {
+			if _, ok := inputSchema.Properties[field]; ok {
+				input[field] = v
+			}
+		}
+
+		docExamples[exampleName] = Example{Value: value}
+		inputExamples[exampleName] = Example{Value: input}
+		docSchema.Examples = append(docSchema.Examples, value)
+		inputSchema.Examples = append(inputSchema.Examples, input)
+	}
+
+	setExamples(listItem.Post.RequestBody.Content, inputExamples)
+	setExamples(item.Patch.RequestBody.Content, inputExamples)
+	setExamples(listItem.Post.Responses["201"].Content, docExamples)
+	setExamples(item.Get.Responses["200"].Content, docExamples)
+	setExamples(item.Patch.Responses["200"].Content, docExamples)
+}
+
+func setExamples(content map[string]MediaType, examples map[string]Example) {
+	media := content["application/json"]
+	media.Examples = examples
+	content["application/json"] = media
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]-32) + s[1:]
+}
+
+func addFunctionEndpoints(spec *Spec) {
+	spec.Tags = append(spec.Tags, Tag{
 		Name:        "functions",
 		Description: "Serverless function endpoints",
 	})
@@ -809,8 +1719,19 @@ func addFunctionEndpoints(spec *Spec) {
 		Properties: map[string]*Schema{
 			"name":    {Type: "string"},
 			"runtime": {Type: "string", Enum: []string{"node", "python", "go"}},
+			"status":  {Type: "string", Enum: []string{"ready", "error"}, Description: "Whether the function passed validation and can be invoked"},
+			"error":   {Type: "string", Description: "Why validation failed, when status is error"},
 		},
-		Required: []string{"name", "runtime"},
+		Required: []string{"name", "runtime", "status"},
+	}
+
+	spec.Components.Schemas["FunctionFailure"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name":  {Type: "string"},
+			"error": {Type: "string"},
+		},
+		Required: []string{"name", "error"},
 	}
 
 	spec.Components.Schemas["FunctionInput"] = &Schema{
@@ -833,10 +1754,11 @@ func addFunctionEndpoints(spec *Spec) {
 	spec.Components.Schemas["LogEntry"] = &Schema{
 		Type: "object",
 		Properties: map[string]*Schema{
-			"level":     {Type: "string", Enum: []string{"debug", "info", "warn", "error"}},
-			"message":   {Type: "string"},
-			"data":      {Type: "object", AdditionalProperties: &Schema{}},
-			"timestamp": {Type: "string", Format: "date-time"},
+			"level":      {Type: "string", Enum: []string{"debug", "info", "warn", "error"}},
+			"message":    {Type: "string"},
+			"data":       {Type: "object", AdditionalProperties: &Schema{}},
+			"timestamp":  {Type: "string", Format: "date-time"},
+			"request_id": {Type: "string", Description: "ID of the request that triggered the invocation"},
 		},
 		Required: []string{"level", "message"},
 	}
@@ -863,6 +1785,18 @@ func addFunctionEndpoints(spec *Spec) {
 		Required: []string{"ready", "busy", "total"},
 	}
 
+	spec.Components.Schemas["BuildResult"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status":      {Type: "string", Enum: []string{"success", "failed"}},
+			"started_at":  {Type: "string", Format: "date-time"},
+			"duration_ms": {Type: "integer"},
+			"output":      {Type: "string", Description: "Trailing output of the build command"},
+			"error":       {Type: "string"},
+		},
+		Required: []string{"status", "started_at", "duration_ms"},
+	}
+
 	spec.Paths["/api/functions"] = &PathItem{
 		Get: &Operation{
 			Tags:        []string{"functions"},
@@ -890,7 +1824,7 @@ func addFunctionEndpoints(spec *Spec) {
 		Get: &Operation{
 			Tags:        []string{"functions"},
 			Summary:     "Get pool statistics",
-			Description: "Get container pool statistics for all runtimes",
+			Description: "Get container pool statistics for all runtimes and the latest build of each function with a build config",
 			OperationID: "getFunctionStats",
 			Responses: map[string]Response{
 				"200": {
@@ -899,7 +1833,8 @@ func addFunctionEndpoints(spec *Spec) {
 						"application/json": {Schema: &Schema{
 							Type: "object",
 							Properties: map[string]*Schema{
-								"pools": {Type: "object", AdditionalProperties: &Schema{Ref: "#/components/schemas/PoolStats"}},
+								"pools":  {Type: "object", AdditionalProperties: &Schema{Ref: "#/components/schemas/PoolStats"}},
+								"builds": {Type: "object", AdditionalProperties: &Schema{Ref: "#/components/schemas/BuildResult"}},
 							},
 						}},
 					},
@@ -912,7 +1847,7 @@ func addFunctionEndpoints(spec *Spec) {
 		Post: &Operation{
 			Tags:        []string{"functions"},
 			Summary:     "Reload functions",
-			Description: "Rediscover and reload all functions",
+			Description: "Rediscover, rebuild and validate all functions, reporting any that failed",
 			OperationID: "reloadFunctions",
 			Responses: map[string]Response{
 				"200": {
@@ -923,6 +1858,8 @@ func addFunctionEndpoints(spec *Spec) {
 							Properties: map[string]*Schema{
 								"success": {Type: "boolean"},
 								"count":   {Type: "integer"},
+								"ready":   {Type: "integer", Description: "Functions that passed validation"},
+								"failed":  {Type: "array", Items: &Schema{Ref: "#/components/schemas/FunctionFailure"}},
 								"message": {Type: "string"},
 							},
 						}},
@@ -953,16 +1890,463 @@ func addFunctionEndpoints(spec *Spec) {
 				"400": {Description: "Invalid input", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 				"404": {Description: "Function not found", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 				"500": {Description: "Invocation error", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"503": {Description: "Function failed validation and is unavailable", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+}
+
+// addTypedFunctionEndpoints adds a path and input, output and response
+// schemas for each function that declares its input or output. Other
+// functions are only described by the generic invoke endpoint.
+func addTypedFunctionEndpoints(spec *Spec, fns map[string]*schema.Function) {
+	names := make([]string, 0, len(fns))
+	for name, fn := range fns {
+		if fn.HasTypes() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	errorResponse := func(description string) Response {
+		return Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}}
+	}
+
+	for _, name := range names {
+		fn := fns[name]
+		typeName := fn.TypeName()
+
+		input := &Schema{Type: typeObject, AdditionalProperties: &Schema{}}
+		if fn.Input != nil {
+			input = userMetadataSchema(fn.Input)
+		}
+		spec.Components.Schemas[typeName+"Input"] = input
+
+		output := &Schema{Type: typeObject, AdditionalProperties: &Schema{}}
+		if fn.Output != nil {
+			output = metadataToSchema(fn.Output)
+		}
+		spec.Components.Schemas[typeName+"Output"] = output
+
+		spec.Components.Schemas[typeName+"Response"] = &Schema{
+			Type: typeObject,
+			Properties: map[string]*Schema{
+				"success":     {Type: "boolean"},
+				"output":      {Ref: "#/components/schemas/" + typeName + "Output"},
+				"error":       {Ref: "#/components/schemas/FunctionError"},
+				"logs":        {Type: "array", Items: &Schema{Ref: "#/components/schemas/LogEntry"}},
+				"duration_ms": {Type: "integer"},
+			},
+			Required: []string{"success", "duration_ms"},
+		}
+
+		responses := map[string]Response{
+			"200": {Description: "Function executed", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/" + typeName + "Response"}}}},
+			"400": errorResponse("Invalid input"),
+			"500": errorResponse("Invocation error"),
+			"503": errorResponse("Function failed validation and is unavailable"),
+		}
+		if fn.Input != nil {
+			responses["422"] = errorResponse("Input does not match the declared input")
+		}
+
+		spec.Paths["/api/functions/"+name] = &PathItem{
+			Post: &Operation{
+				Tags:        []string{"functions"},
+				Summary:     "Invoke " + name,
+				Description: fn.Description,
+				OperationID: "invoke" + typeName,
+				RequestBody: &RequestBody{
+					Content: map[string]MediaType{
+						"application/json": {Schema: &Schema{Ref: "#/components/schemas/" + typeName + "Input"}},
+					},
+				},
+				Responses: responses,
+			},
+		}
+	}
+}
+
+// View staleness headers, set once a view has been refreshed.
+const (
+	viewRefreshedAtHeader = "X-Alyx-View-Refreshed-At"
+	viewAgeHeader         = "X-Alyx-View-Age"
+)
+
+// addViewEndpoints documents GET /api/views/{name} for each materialized
+// view. Rows are untyped objects, since their columns come from the view's
+// query.
+func addViewEndpoints(spec *Spec, views map[string]*schema.View) {
+	if len(views) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(views))
+	for name := range views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	spec.Tags = append(spec.Tags, Tag{
+		Name:        "views",
+		Description: "Read-only materialized views",
+	})
+
+	errorResponse := func(description string) Response {
+		return Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}}
+	}
+	defaultLimit, maxLimit := (*schema.ListConfig)(nil).Limits()
+
+	for _, name := range names {
+		v := views[name]
+
+		description := v.Description
+		refresh := "Refreshed shortly after writes to " + strings.Join(v.Collections(), ", ") + "."
+		if v.RefreshPolicy() == schema.ViewRefreshInterval {
+			refresh = "Refreshed every " + v.Interval + "."
+		}
+		if description != "" {
+			description += "\n\n"
+		}
+		description += refresh
+
+		spec.Paths["/api/views/"+name] = &PathItem{
+			Get: &Operation{
+				Tags:        []string{"views"},
+				Summary:     "Read " + name,
+				Description: description,
+				OperationID: "getView" + v.TypeName(),
+				Parameters: []Parameter{
+					{Name: "limit", In: "query", Description: fmt.Sprintf("Maximum number of rows to return (default: %d, max: %d; larger limits are clamped)", defaultLimit, maxLimit), Schema: &Schema{Type: "integer", Default: defaultLimit}},
+					{Name: "offset", In: "query", Description: "Number of rows to skip", Schema: &Schema{Type: "integer"}},
+				},
+				Responses: map[string]Response{
+					"200": {
+						Description: "Successful response",
+						Headers: map[string]*Header{
+							limitClampedHeader:    {Description: "The requested limit, when it exceeded the maximum and was clamped", Schema: &Schema{Type: "integer"}},
+							viewRefreshedAtHeader: {Description: "When the view was last refreshed; absent until its first refresh", Schema: &Schema{Type: "string", Format: "date-time"}},
+							viewAgeHeader:         {Description: "Seconds since the view was last refreshed", Schema: &Schema{Type: "integer"}},
+						},
+						Content: map[string]MediaType{
+							"application/json": {Schema: &Schema{
+								Type: "object",
+								Properties: map[string]*Schema{
+									"docs":         {Type: "array", Items: &Schema{Type: typeObject, AdditionalProperties: &Schema{}}},
+									"total":        {Type: "integer"},
+									"limit":        {Type: "integer"},
+									"offset":       {Type: "integer"},
+									"refreshed_at": {Type: "string", Format: "date-time", Description: "When the view was last refreshed, or null"},
+								},
+								Required: []string{"docs", "total"},
+							}},
+						},
+					},
+					"400": errorResponse("Invalid query parameters"),
+					"403": errorResponse("Access denied by the view's read rule"),
+					"500": errorResponse("Internal server error"),
+				},
+			},
+		}
+	}
+}
+
+// addStorageEndpoints describes the file endpoints of each bucket. An
+// operation whose bucket rule is "true" is public and has no security
+// requirement.
+func addStorageEndpoints(spec *Spec, buckets map[string]*schema.Bucket) {
+	if len(buckets) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(buckets))
+	for name := range buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	spec.Tags = append(spec.Tags, Tag{
+		Name:        "storage",
+		Description: "File uploads and downloads",
+	})
+
+	spec.Components.Schemas["File"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":         {Type: "string", Format: "uuid"},
+			"bucket":     {Type: "string"},
+			"name":       {Type: "string"},
+			"mime_type":  {Type: "string"},
+			"size":       {Type: "integer"},
+			"checksum":   {Type: "string", Description: "SHA-256 of the content"},
+			"owner_id":   {Type: "string", Description: "ID of the user who uploaded the file"},
+			"created_at": {Type: "string", Format: "date-time"},
+			"updated_at": {Type: "string", Format: "date-time"},
+		},
+		Required: []string{"id", "bucket", "name", "mime_type", "size"},
+	}
+
+	errorResponse := func(description string) Response {
+		return Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}}
+	}
+	fileResponse := Response{Description: "File metadata", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/File"}}}}
+	idParam := Parameter{Name: "id", In: "path", Required: true, Description: "File ID", Schema: &Schema{Type: "string"}}
+	tokenParam := Parameter{Name: "token", In: "query", Description: "Signed URL token, which stands in for the download rule", Schema: &Schema{Type: "string"}}
+
+	for _, name := range names {
+		r := buckets[name].Rules
+		security := func(expr string) []SecurityRequirement {
+			if strings.TrimSpace(expr) == "true" {
+				return []SecurityRequirement{}
+			}
+			return nil
+		}
+		var uploadRule, readRule, downloadRule, deleteRule string
+		if r != nil {
+			uploadRule, readRule, downloadRule, deleteRule = r.UploadRule(), r.Read, r.Download, r.Delete
+		}
+
+		listPath := "/api/files/" + name
+		itemPath := listPath + "/{id}"
+		id := capitalize(name)
+
+		spec.Paths[listPath] = &PathItem{
+			Post: &Operation{
+				Tags:        []string{"storage"},
+				Summary:     "Upload a file to " + name,
+				OperationID: "upload" + id + "File",
+				RequestBody: &RequestBody{
+					Required: true,
+					Content: map[string]MediaType{"multipart/form-data": {Schema: &Schema{
+						Type:       "object",
+						Properties: map[string]*Schema{"file": {Type: "string", Format: "binary"}},
+						Required:   []string{"file"},
+					}}},
+				},
+				Responses: map[string]Response{
+					"201": {Description: "File uploaded", Content: fileResponse.Content},
+					"400": errorResponse("Missing file or type not allowed"),
+					"403": errorResponse("Access denied by the bucket's upload rule"),
+					"413": errorResponse("File too large"),
+				},
+				Security: security(uploadRule),
+			},
+			Get: &Operation{
+				Tags:        []string{"storage"},
+				Summary:     "List files in " + name,
+				OperationID: "list" + id + "Files",
+				Parameters: []Parameter{
+					{Name: "search", In: "query", Description: "Filter by file name", Schema: &Schema{Type: "string"}},
+					{Name: "mime_type", In: "query", Description: "Filter by MIME type", Schema: &Schema{Type: "string"}},
+					{Name: "limit", In: "query", Description: "Maximum number of files to return (default: 100, max: 1000)", Schema: &Schema{Type: "integer"}},
+					{Name: "offset", In: "query", Description: "Number of files to skip", Schema: &Schema{Type: "integer"}},
+				},
+				Responses: map[string]Response{
+					"200": {Description: "Files", Content: map[string]MediaType{"application/json": {Schema: &Schema{
+						Type: "object",
+						Properties: map[string]*Schema{
+							"files":  {Type: "array", Items: &Schema{Ref: "#/components/schemas/File"}},
+							"total":  {Type: "integer"},
+							"limit":  {Type: "integer"},
+							"offset": {Type: "integer"},
+						},
+						Required: []string{"files", "total"},
+					}}}},
+					"403": errorResponse("Access denied by the bucket's read rule"),
+				},
+				Security: security(readRule),
+			},
+		}
+
+		spec.Paths[itemPath] = &PathItem{
+			Get: &Operation{
+				Tags:        []string{"storage"},
+				Summary:     "Get the metadata of a file in " + name,
+				OperationID: "get" + id + "File",
+				Parameters:  []Parameter{idParam},
+				Responses: map[string]Response{
+					"200": fileResponse,
+					"403": errorResponse("Access denied by the bucket's read rule"),
+					"404": errorResponse("File not found"),
+				},
+				Security: security(readRule),
+			},
+			Delete: &Operation{
+				Tags:        []string{"storage"},
+				Summary:     "Delete a file from " + name,
+				OperationID: "delete" + id + "File",
+				Parameters:  []Parameter{idParam},
+				Responses: map[string]Response{
+					"204": {Description: "File deleted"},
+					"403": errorResponse("Access denied by the bucket's delete rule"),
+					"404": errorResponse("File not found"),
+				},
+				Security: security(deleteRule),
 			},
+		}
+
+		for _, op := range []struct{ path, verb, summary string }{
+			{"download", "download", "Download a file from " + name + " as an attachment"},
+			{"view", "view", "View a file from " + name + " inline"},
+		} {
+			spec.Paths[itemPath+"/"+op.path] = &PathItem{
+				Get: &Operation{
+					Tags:        []string{"storage"},
+					Summary:     op.summary,
+					Description: "The download rule sees the file and, when a file field references it, the referencing document as doc.",
+					OperationID: op.verb + id + "File",
+					Parameters:  []Parameter{idParam, tokenParam},
+					Responses: map[string]Response{
+						"200": {Description: "File content", Content: map[string]MediaType{"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}}}},
+						"401": errorResponse("Invalid or expired token"),
+						"403": errorResponse("Access denied by the bucket's download rule"),
+						"404": errorResponse("File not found"),
+					},
+					Security: security(downloadRule),
+				},
+			}
+		}
+	}
+}
+
+// databaseEventActions are the document changes delivered to database hooks.
+var databaseEventActions = []struct {
+	action string
+	past   string
+}{
+	{"insert", "inserted"},
+	{"update", "updated"},
+	{"delete", "deleted"},
+}
+
+// authEventActions are the account events delivered to auth hooks.
+var authEventActions = []struct {
+	action      string
+	operationID string
+	summary     string
+}{
+	{"signup", "onAuthSignup", "User signed up"},
+	{"login", "onAuthLogin", "User logged in"},
+	{"logout", "onAuthLogout", "User logged out"},
+	{"password_reset", "onAuthPasswordReset", "User reset their password"},
+	{"email_verify", "onAuthEmailVerify", "User verified their email"},
+}
+
+// addEventWebhooks describes the database change events for each
+// collection and the auth events in the spec's webhooks section. Each event
+// is a POST whose request body is the input a hook function receives.
+func addEventWebhooks(spec *Spec, collectionNames []string) {
+	spec.Webhooks = make(map[string]*PathItem)
+
+	spec.Components.Schemas["EventMetadata"] = &Schema{
+		Type: typeObject,
+		Properties: map[string]*Schema{
+			"request_id": {Type: typeString, Description: "ID of the request that caused the event"},
 		},
 	}
+
+	handled := map[string]Response{
+		"200": {Description: "Event handled"},
+	}
+
+	for _, name := range collectionNames {
+		docRef := &Schema{Ref: "#/components/schemas/" + name}
+		for _, event := range databaseEventActions {
+			action := event.action
+			payload := &Schema{
+				Type: typeObject,
+				Properties: map[string]*Schema{
+					"collection": {Type: typeString, Enum: []string{name}},
+					"action":     {Type: typeString, Enum: []string{action}},
+					"document":   docRef,
+					"metadata":   {Ref: "#/components/schemas/EventMetadata"},
+				},
+				Required: []string{"collection", "action", "document", "metadata"},
+			}
+			description := fmt.Sprintf("Sent to database hooks on %s when a document is %s.", name, event.past)
+			if action == "update" {
+				payload.Properties["previous"] = docRef
+				payload.Properties["changed_fields"] = &Schema{
+					Type:        "array",
+					Items:       &Schema{Type: typeString},
+					Description: "Fields whose values the update changed",
+				}
+				payload.Required = append(payload.Required, "previous", "changed_fields")
+				description += " previous holds the document before the update."
+			}
+			if action == "delete" {
+				description += " document holds the deleted document."
+			}
+
+			spec.Webhooks[name+"."+action] = &PathItem{
+				Post: &Operation{
+					Tags:        []string{name},
+					Summary:     fmt.Sprintf("Document %s in %s", event.past, name),
+					Description: description,
+					OperationID: "on" + capitalize(name) + capitalize(action),
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{"application/json": {Schema: payload}},
+					},
+					Responses: handled,
+				},
+			}
+		}
+	}
+
+	for _, event := range authEventActions {
+		spec.Webhooks["auth."+event.action] = &PathItem{
+			Post: &Operation{
+				Tags:        []string{"auth"},
+				Summary:     event.summary,
+				Description: "Sent to auth hooks.",
+				OperationID: event.operationID,
+				RequestBody: &RequestBody{
+					Required: true,
+					Content: map[string]MediaType{"application/json": {Schema: &Schema{
+						Type: typeObject,
+						Properties: map[string]*Schema{
+							"action":   {Type: typeString, Enum: []string{event.action}},
+							"user":     {Ref: "#/components/schemas/User"},
+							"metadata": {Type: typeObject, AdditionalProperties: &Schema{}, Description: "Event details, such as the request ID"},
+						},
+						Required: []string{"action", "user"},
+					}}},
+				},
+				Responses: handled,
+			},
+		}
+	}
 }
 
 func (s *Spec) JSON() ([]byte, error) {
 	return json.MarshalIndent(s, "", "  ")
 }
 
-func addAdminEndpoints(spec *Spec) {
+// YAML returns the spec as YAML, with keys in the same order as JSON.
+func (s *Spec) YAML() ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is YAML, so decoding it into a node keeps the key order; clearing
+	// the flow and quoting styles it was parsed with gives block YAML.
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+func addAdminEndpoints(spec *Spec, roles []string) {
 	spec.Tags = append(spec.Tags, Tag{
 		Name:        "admin",
 		Description: "Admin API endpoints (requires admin authentication)",
@@ -974,10 +2358,12 @@ func addAdminEndpoints(spec *Spec) {
 			"id":         {Type: "string", Format: "uuid"},
 			"email":      {Type: "string", Format: "email"},
 			"verified":   {Type: "boolean"},
-			"role":       {Type: "string", Enum: []string{"user", "admin"}},
+			"role":       {Type: "string", Enum: roles},
 			"created_at": {Type: "string", Format: "date-time"},
 			"updated_at": {Type: "string", Format: "date-time"},
-			"metadata":   {Type: "object", AdditionalProperties: &Schema{}},
+			"metadata":   {Ref: "#/components/schemas/UserMetadata"},
+
+			"deletion_requested_at": {Type: "string", Format: "date-time", Description: "Set while the account is scheduled for deletion"},
 		},
 		Required: []string{"id", "email", "verified", "role", "created_at", "updated_at"},
 	}
@@ -988,8 +2374,8 @@ func addAdminEndpoints(spec *Spec) {
 			"email":    {Type: "string", Format: "email"},
 			"password": {Type: "string", MinLength: intPtr(defaultPasswordMinLength)},
 			"verified": {Type: "boolean"},
-			"role":     {Type: "string", Enum: []string{"user", "admin"}},
-			"metadata": {Type: "object", AdditionalProperties: &Schema{}},
+			"role":     {Type: "string", Enum: roles},
+			"metadata": {Ref: "#/components/schemas/UserMetadata"},
 		},
 		Required: []string{"email", "password"},
 	}
@@ -999,8 +2385,8 @@ func addAdminEndpoints(spec *Spec) {
 		Properties: map[string]*Schema{
 			"email":    {Type: "string", Format: "email"},
 			"verified": {Type: "boolean"},
-			"role":     {Type: "string", Enum: []string{"user", "admin"}},
-			"metadata": {Type: "object", AdditionalProperties: &Schema{}},
+			"role":     {Type: "string", Enum: roles},
+			"metadata": {Ref: "#/components/schemas/UserMetadata"},
 		},
 	}
 
@@ -1021,22 +2407,59 @@ func addAdminEndpoints(spec *Spec) {
 		Required: []string{"users", "total"},
 	}
 
+	userFilters := []Parameter{
+		{Name: "sort_by", In: "query", Description: "Field to sort by (id, email, verified, role, created_at, updated_at, deletion_requested_at)", Schema: &Schema{Type: "string"}},
+		{Name: "sort_dir", In: "query", Description: "Sort direction (asc, desc)", Schema: &Schema{Type: "string"}},
+		{Name: "search", In: "query", Description: "Search in email", Schema: &Schema{Type: "string"}},
+		{Name: "role", In: "query", Description: "Filter by role", Schema: &Schema{Type: "string"}},
+		{Name: "verified", In: "query", Description: "Filter by verification status", Schema: &Schema{Type: "boolean"}},
+		{Name: "created_after", In: "query", Description: "Only users created at or after this time", Schema: &Schema{Type: "string", Format: "date-time"}},
+		{Name: "created_before", In: "query", Description: "Only users created before this time", Schema: &Schema{Type: "string", Format: "date-time"}},
+		{Name: "pending_deletion", In: "query", Description: "Only list accounts scheduled for deletion", Schema: &Schema{Type: "boolean"}},
+	}
+
+	spec.Components.Schemas["BulkUsersInput"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"ids":    {Type: "array", Items: &Schema{Type: "string"}, Description: "User IDs, at most 1000"},
+			"action": {Type: "string", Enum: []string{"set_role", "verify", "delete"}},
+			"role":   {Type: "string", Enum: roles, Description: "New role for set_role"},
+		},
+		Required: []string{"ids", "action"},
+	}
+
+	spec.Components.Schemas["BulkUsersResponse"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"action": {Type: "string"},
+			"results": {Type: "array", Items: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"id":      {Type: "string"},
+					"success": {Type: "boolean"},
+					"error":   {Type: "string", Description: "Why the user was skipped: not found, or the last admin"},
+				},
+				Required: []string{"id", "success"},
+			}},
+			"succeeded": {Type: "integer"},
+			"failed":    {Type: "integer"},
+		},
+		Required: []string{"action", "results", "succeeded", "failed"},
+	}
+
 	spec.Paths["/api/admin/users"] = &PathItem{
 		Get: &Operation{
 			Tags:        []string{"admin"},
 			Summary:     "List users",
 			Description: "Get a paginated list of all users",
 			OperationID: "listUsers",
-			Parameters: []Parameter{
+			Parameters: append([]Parameter{
 				{Name: "limit", In: "query", Description: "Maximum users to return (default: 20, max: 100)", Schema: &Schema{Type: "integer"}},
 				{Name: "offset", In: "query", Description: "Number of users to skip", Schema: &Schema{Type: "integer"}},
-				{Name: "sort_by", In: "query", Description: "Field to sort by (id, email, verified, role, created_at, updated_at)", Schema: &Schema{Type: "string"}},
-				{Name: "sort_dir", In: "query", Description: "Sort direction (asc, desc)", Schema: &Schema{Type: "string"}},
-				{Name: "search", In: "query", Description: "Search in email", Schema: &Schema{Type: "string"}},
-				{Name: "role", In: "query", Description: "Filter by role", Schema: &Schema{Type: "string"}},
-			},
+			}, userFilters...),
 			Responses: map[string]Response{
 				"200": {Description: "List of users", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/UserListResponse"}}}},
+				"400": {Description: "Invalid filter", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
 			},
 		},
@@ -1061,6 +2484,44 @@ func addAdminEndpoints(spec *Spec) {
 		},
 	}
 
+	spec.Paths["/api/admin/users/export"] = &PathItem{
+		Get: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "Export users",
+			Description: "Stream every user matching the filters as CSV. Password hashes are never exported.",
+			OperationID: "exportUsers",
+			Parameters: append([]Parameter{
+				{Name: "format", In: "query", Description: "Export format (csv)", Schema: &Schema{Type: "string", Enum: []string{"csv"}}},
+			}, userFilters...),
+			Responses: map[string]Response{
+				"200": {Description: "CSV with the columns id, email, verified, role, created_at, updated_at and deletion_requested_at", Content: map[string]MediaType{"text/csv": {Schema: &Schema{Type: "string"}}}},
+				"400": {Description: "Invalid format or filter", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Paths["/api/admin/users/bulk"] = &PathItem{
+		Post: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "Bulk update users",
+			Description: "Set the role of, verify, or delete many users in one transaction. Users that do not exist, or whose change would demote or delete the last admin, are skipped and reported in their result.",
+			OperationID: "bulkUpdateUsers",
+			RequestBody: &RequestBody{
+				Required:    true,
+				Description: "Users and the action to apply",
+				Content: map[string]MediaType{
+					"application/json": {Schema: &Schema{Ref: "#/components/schemas/BulkUsersInput"}},
+				},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Per-user results", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/BulkUsersResponse"}}}},
+				"400": {Description: "Invalid action, role or ids", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
 	spec.Paths["/api/admin/users/{id}"] = &PathItem{
 		Get: &Operation{
 			Tags:        []string{"admin"},
@@ -1140,24 +2601,351 @@ func addAdminEndpoints(spec *Spec) {
 		},
 	}
 
+	spec.Paths["/api/admin/users/{id}/restore"] = &PathItem{
+		Post: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "Restore user",
+			Description: "Cancel a pending account deletion",
+			OperationID: "restoreUser",
+			Parameters: []Parameter{
+				{Name: "id", In: "path", Required: true, Description: "User ID", Schema: &Schema{Type: "string", Format: "uuid"}},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Restored user", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/AdminUser"}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"404": {Description: "User not found", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Components.Schemas["AdminToken"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":              {Type: "integer"},
+			"name":            {Type: "string"},
+			"permissions":     {Type: "array", Items: &Schema{Type: "string", Enum: []string{"read", "deploy", "rollback", "introspect", "admin"}}},
+			"created_at":      {Type: "string", Format: "date-time"},
+			"expires_at":      {Type: "string", Format: "date-time", Description: "When the token stops working; absent if it never expires"},
+			"last_used_at":    {Type: "string", Format: "date-time", Description: "When the token last authenticated a request; absent if never used"},
+			"created_by":      {Type: "string"},
+			"require_signing": {Type: "boolean", Description: "Whether the token only authenticates signed requests"},
+		},
+		Required: []string{"id", "name", "permissions", "created_at", "require_signing"},
+	}
+
+	spec.Components.Schemas["CreateTokenInput"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name": {Type: "string"},
+			"permissions": {
+				Type:  "array",
+				Items: &Schema{Type: "string", Enum: []string{"read", "deploy", "rollback", "introspect", "admin"}},
+				Description: "Defaults to [deploy]. read allows the read-only admin endpoints (schema, config metadata, deploy history, pending changes, logs) and POST /api/admin/deploy/prepare, " +
+					"which suits CI spec checks. deploy adds deploy execute, rollback adds deploy rollback, and both imply read. introspect only allows POST /api/auth/introspect. " +
+					"admin allows every endpoint, including users, tokens, the change feed and raw config. " +
+					"A valid token without the required permission gets 403 INSUFFICIENT_PERMISSION naming it in details.required_permission.",
+			},
+			"expires_at": {Type: "string", Format: "date-time", Description: "Optional expiry, which must be in the future"},
+			"require_signing": {
+				Type:        "boolean",
+				Description: "Only accept signed requests, which send the token's key ID with X-Alyx-Timestamp and X-Alyx-Signature headers instead of its secret",
+			},
+		},
+		Required: []string{"name"},
+	}
+
+	spec.Components.Schemas["TokenSecretResponse"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"token":           {Type: "string", Description: "The token secret. It is only returned here and cannot be retrieved again."},
+			"name":            {Type: "string"},
+			"permissions":     {Type: "array", Items: &Schema{Type: "string"}},
+			"expires_at":      {Type: "string", Format: "date-time"},
+			"require_signing": {Type: "boolean"},
+			"message":         {Type: "string"},
+		},
+		Required: []string{"token", "name", "permissions", "message"},
+	}
+
+	spec.Paths["/api/admin/tokens"] = &PathItem{
+		Get: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "List admin tokens",
+			Description: "List deploy and admin tokens, without their secrets",
+			OperationID: "listAdminTokens",
+			Responses: map[string]Response{
+				"200": {Description: "Admin tokens", Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"tokens": {Type: "array", Items: &Schema{Ref: "#/components/schemas/AdminToken"}},
+						"total":  {Type: "integer"},
+					},
+					Required: []string{"tokens", "total"},
+				}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+		Post: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "Create admin token",
+			Description: "Create a deploy or admin token. The secret is returned once.",
+			OperationID: "createAdminToken",
+			RequestBody: &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"application/json": {Schema: &Schema{Ref: "#/components/schemas/CreateTokenInput"}},
+				},
+			},
+			Responses: map[string]Response{
+				"201": {Description: "Token created", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/TokenSecretResponse"}}}},
+				"400": {Description: "Invalid input", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Paths["/api/admin/tokens/{name}"] = &PathItem{
+		Delete: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "Revoke admin token",
+			Description: "Delete a token by name",
+			OperationID: "deleteAdminToken",
+			Parameters: []Parameter{
+				{Name: "name", In: "path", Required: true, Description: "Token name", Schema: &Schema{Type: "string"}},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Token revoked"},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"404": {Description: "Token not found", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Paths["/api/admin/tokens/{name}/rotate"] = &PathItem{
+		Post: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "Rotate admin token",
+			Description: "Issue a new secret for a token, keeping its permissions and expiry. The previous secret stops working immediately; the new one is returned once.",
+			OperationID: "rotateAdminToken",
+			Parameters: []Parameter{
+				{Name: "name", In: "path", Required: true, Description: "Token name", Schema: &Schema{Type: "string"}},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Token rotated", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/TokenSecretResponse"}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"404": {Description: "Token not found", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Components.Schemas["TokenIntrospection"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"active":     {Type: "boolean", Description: "Whether the token would authenticate a request now"},
+			"user_id":    {Type: "string", Description: "Present for active tokens"},
+			"role":       {Type: "string", Description: "The user's current role; present for active tokens"},
+			"expires_at": {Type: "string", Format: "date-time", Description: "Present for active tokens"},
+		},
+		Required: []string{"active"},
+	}
+
+	spec.Paths["/api/auth/introspect"] = &PathItem{
+		Post: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "Introspect access tokens",
+			Description: "Check up to 20 access tokens, modeled on RFC 7662. Results are in request order. Requires admin auth or an admin token with the introspect permission.",
+			OperationID: "introspectTokens",
+			RequestBody: &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"application/json": {Schema: &Schema{
+						Type: "object",
+						Properties: map[string]*Schema{
+							"tokens": {Type: "array", Items: &Schema{Type: "string"}, Description: "Access tokens, at most 20"},
+						},
+						Required: []string{"tokens"},
+					}},
+				},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "One result per token", Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"tokens": {Type: "array", Items: &Schema{Ref: "#/components/schemas/TokenIntrospection"}},
+					},
+					Required: []string{"tokens"},
+				}}}},
+				"400": {Description: "Missing tokens or more than 20", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Components.Schemas["RealtimeConnection"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":            {Type: "string", Description: "Connection ID"},
+			"user_id":       {Type: "string", Description: "Authenticated user ID, if any"},
+			"remote_addr":   {Type: "string", Description: "Remote address of the client"},
+			"connected_at":  {Type: "string", Format: "date-time"},
+			"subscriptions": {Type: "integer", Description: "Number of active subscriptions"},
+			"delivered":     {Type: "integer", Description: "Messages queued for the client"},
+			"dropped":       {Type: "integer", Description: "Messages dropped because the client's send buffer was full"},
+		},
+		Required: []string{"id", "remote_addr", "connected_at", "subscriptions", "delivered", "dropped"},
+	}
+
+	spec.Components.Schemas["RealtimeSubscription"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":         {Type: "string", Description: "Subscription ID"},
+			"client_id":  {Type: "string", Description: "Connection ID"},
+			"user_id":    {Type: "string", Description: "Authenticated user ID, if any"},
+			"collection": {Type: "string"},
+			"filter":     {Type: "object", Description: "Subscription filter by field", AdditionalProperties: &Schema{Type: "object"}},
+			"sort":       {Type: "array", Items: &Schema{Type: "string"}},
+			"limit":      {Type: "integer"},
+			"state":      {Type: "string", Enum: []string{"active", "paused", "canceled"}},
+			"created_at": {Type: "string", Format: "date-time"},
+		},
+		Required: []string{"id", "client_id", "collection", "state", "created_at"},
+	}
+
+	spec.Paths["/api/admin/realtime/connections"] = &PathItem{
+		Get: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "List realtime connections",
+			Description: "List connected WebSocket clients with their delivery counters",
+			OperationID: "listRealtimeConnections",
+			Responses: map[string]Response{
+				"200": {Description: "Connected clients", Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"connections": {Type: "array", Items: &Schema{Ref: "#/components/schemas/RealtimeConnection"}},
+						"total":       {Type: "integer"},
+					},
+					Required: []string{"connections", "total"},
+				}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"503": {Description: "Realtime is not enabled", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Paths["/api/admin/realtime/connections/{id}"] = &PathItem{
+		Delete: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "Disconnect realtime client",
+			Description: "Force-disconnect a client. The client receives a policy violation (1008) close frame with the given reason.",
+			OperationID: "disconnectRealtimeClient",
+			Parameters: []Parameter{
+				{Name: "id", In: "path", Required: true, Description: "Connection ID", Schema: &Schema{Type: "string"}},
+				{Name: "reason", In: "query", Description: "Close reason sent to the client (default: disconnected by admin)", Schema: &Schema{Type: "string", MaxLength: intPtr(123)}},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Client disconnected", Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"disconnected": {Type: "boolean"},
+						"id":           {Type: "string"},
+						"reason":       {Type: "string"},
+					},
+				}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"404": {Description: "Connection not found", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"503": {Description: "Realtime is not enabled", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Components.Schemas["Change"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"seq":            {Type: "integer", Description: "Sequence number, increasing in commit order"},
+			"collection":     {Type: "string"},
+			"operation":      {Type: "string", Enum: []string{"INSERT", "UPDATE", "DELETE"}},
+			"doc_id":         {Type: "string"},
+			"changed_fields": {Type: "array", Items: &Schema{Type: "string"}, Description: "Fields modified by an update"},
+			"timestamp":      {Type: "string", Format: "date-time"},
+			"document":       {Type: "object", Description: "Current state of the document, when changes.include is document and it still exists"},
+		},
+		Required: []string{"seq", "collection", "operation", "doc_id", "timestamp"},
+	}
+
+	spec.Paths["/api/admin/changes"] = &PathItem{
+		Get: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "Read the change feed",
+			Description: "Read committed inserts, updates and deletes after a sequence number, oldest first. Pass next_seq as since_seq to read the next batch.",
+			OperationID: "readChanges",
+			Parameters: []Parameter{
+				{Name: "since_seq", In: "query", Description: "Return changes after this sequence number (default: the oldest retained change)", Schema: &Schema{Type: "integer"}},
+				{Name: "limit", In: "query", Description: "Maximum changes to return, 1 to 1000 (default 100)", Schema: &Schema{Type: "integer"}},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "A batch of changes", Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"changes":        {Type: "array", Items: &Schema{Ref: "#/components/schemas/Change"}},
+						"next_seq":       {Type: "integer", Description: "since_seq for the next read"},
+						"has_more":       {Type: "boolean", Description: "Whether more changes are already available"},
+						"schema_version": {Type: "string", Description: "Identifies the server's current schema; changes when the schema does"},
+					},
+					Required: []string{"changes", "next_seq", "has_more", "schema_version"},
+				}}}},
+				"400": {Description: "Invalid since_seq or limit", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"410": {Description: "Changes after since_seq have been pruned", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"503": {Description: "The change feed is not enabled", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
+	spec.Paths["/api/admin/realtime/subscriptions"] = &PathItem{
+		Get: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "List realtime subscriptions",
+			Description: "List active subscriptions with their filters",
+			OperationID: "listRealtimeSubscriptions",
+			Parameters: []Parameter{
+				{Name: "collection", In: "query", Description: "Only list subscriptions to this collection", Schema: &Schema{Type: "string"}},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Active subscriptions", Content: map[string]MediaType{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"subscriptions": {Type: "array", Items: &Schema{Ref: "#/components/schemas/RealtimeSubscription"}},
+						"total":         {Type: "integer"},
+					},
+					Required: []string{"subscriptions", "total"},
+				}}}},
+				"401": {Description: "Unauthorized", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+				"503": {Description: "Realtime is not enabled", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
 	spec.Components.Schemas["RequestLogEntry"] = &Schema{
 		Type: "object",
 		Properties: map[string]*Schema{
-			"id":          {Type: "string", Description: "Request ID"},
-			"timestamp":   {Type: "string", Format: "date-time", Description: "Request timestamp"},
-			"method":      {Type: "string", Description: "HTTP method"},
-			"path":        {Type: "string", Description: "Request path"},
-			"query":       {Type: "string", Description: "Query string"},
-			"status":      {Type: "integer", Description: "HTTP status code"},
-			"duration":    {Type: "integer", Description: "Duration in nanoseconds"},
-			"duration_ms": {Type: "number", Description: "Duration in milliseconds"},
-			"bytes_in":    {Type: "integer", Description: "Request body size"},
-			"bytes_out":   {Type: "integer", Description: "Response body size"},
-			"client_ip":   {Type: "string", Description: "Client IP address"},
-			"user_agent":  {Type: "string", Description: "User agent string"},
-			"user_id":     {Type: "string", Description: "Authenticated user ID"},
-			"error":       {Type: "string", Description: "Error message if any"},
-			"error_code":  {Type: "string", Description: "Error code if any"},
+			"id":            {Type: "string", Description: "Request ID"},
+			"parent_id":     {Type: "string", Description: "ID of the request that triggered this one (e.g. via a database hook)"},
+			"timestamp":     {Type: "string", Format: "date-time", Description: "Request timestamp"},
+			"method":        {Type: "string", Description: "HTTP method"},
+			"path":          {Type: "string", Description: "Request path"},
+			"route":         {Type: "string", Description: "Pattern of the route that served the request"},
+			"query":         {Type: "string", Description: "Query string"},
+			"status":        {Type: "integer", Description: "HTTP status code"},
+			"duration":      {Type: "integer", Description: "Duration in nanoseconds"},
+			"duration_ms":   {Type: "number", Description: "Duration in milliseconds"},
+			"bytes_in":      {Type: "integer", Description: "Request body size"},
+			"bytes_out":     {Type: "integer", Description: "Response body size"},
+			"client_ip":     {Type: "string", Description: "Client IP address"},
+			"user_agent":    {Type: "string", Description: "User agent string"},
+			"user_id":       {Type: "string", Description: "Authenticated user ID"},
+			"error":         {Type: "string", Description: "Error message if any"},
+			"error_code":    {Type: "string", Description: "Error code if any"},
+			"error_details": {Type: "object", Description: "Structured error context, such as the rule_denied block for RULE_DENIED"},
 		},
 	}
 
@@ -1196,6 +2984,7 @@ func addAdminEndpoints(spec *Spec) {
 				{Name: "min_status", In: "query", Description: "Filter by minimum status code", Schema: &Schema{Type: "integer"}},
 				{Name: "max_status", In: "query", Description: "Filter by maximum status code", Schema: &Schema{Type: "integer"}},
 				{Name: "user_id", In: "query", Description: "Filter by user ID", Schema: &Schema{Type: "string"}},
+				{Name: "error_code", In: "query", Description: "Filter by error code, e.g. RULE_DENIED", Schema: &Schema{Type: "string"}},
 				{Name: "since", In: "query", Description: "Filter by start time (RFC3339)", Schema: &Schema{Type: "string", Format: "date-time"}},
 				{Name: "until", In: "query", Description: "Filter by end time (RFC3339)", Schema: &Schema{Type: "string", Format: "date-time"}},
 			},
@@ -1217,6 +3006,61 @@ func addAdminEndpoints(spec *Spec) {
 		},
 	}
 
+	spec.Components.Schemas["RequestLogMetricsBucket"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"start":      {Type: "string", Format: "date-time", Description: "Bucket start"},
+			"count":      {Type: "integer", Description: "Requests in the bucket"},
+			"errors_4xx": {Type: "integer", Description: "Requests with a 4xx status"},
+			"errors_5xx": {Type: "integer", Description: "Requests with a 5xx status"},
+			"error_rate": {Type: "number", Description: "Share of requests with a 4xx or 5xx status; 0 for empty buckets"},
+			"p50_ms":     {Type: "number", Description: "Median latency in milliseconds, estimated within 10%; 0 for empty buckets"},
+			"p95_ms":     {Type: "number", Description: "95th percentile latency in milliseconds"},
+			"p99_ms":     {Type: "number", Description: "99th percentile latency in milliseconds"},
+		},
+		Required: []string{"start", "count", "errors_4xx", "errors_5xx", "error_rate", "p50_ms", "p95_ms", "p99_ms"},
+	}
+
+	spec.Components.Schemas["RequestLogMetrics"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"start":          {Type: "string", Format: "date-time", Description: "Start of the first bucket"},
+			"end":            {Type: "string", Format: "date-time", Description: "End of the last bucket"},
+			"bucket_seconds": {Type: "number", Description: "Bucket width in seconds"},
+			"group_by":       {Type: "string", Enum: []string{"path"}, Description: "Grouping of the series, if any"},
+			"truncated":      {Type: "boolean", Description: "Whether groups past max_groups were merged into the (other) series"},
+			"series": {Type: "array", Description: "One series when not grouped, otherwise one per route pattern, busiest first", Items: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"route":   {Type: "string", Description: "Route pattern, (unmatched) for requests no route matched, or (other) for merged groups"},
+					"total":   {Ref: "#/components/schemas/RequestLogMetricsBucket"},
+					"buckets": {Type: "array", Description: "Every bucket of the window in order, including empty ones", Items: &Schema{Ref: "#/components/schemas/RequestLogMetricsBucket"}},
+				},
+				Required: []string{"total", "buckets"},
+			}},
+		},
+		Required: []string{"start", "end", "bucket_seconds", "truncated", "series"},
+	}
+
+	spec.Paths["/api/admin/logs/metrics"] = &PathItem{
+		Get: &Operation{
+			Tags:        []string{"admin"},
+			Summary:     "Get request log metrics",
+			Description: "Aggregate the request log into per-bucket request counts, error rates and latency percentiles",
+			OperationID: "getRequestLogMetrics",
+			Parameters: []Parameter{
+				{Name: "window", In: "query", Description: "How far back to aggregate (default: 1h)", Schema: &Schema{Type: "string"}},
+				{Name: "bucket", In: "query", Description: "Bucket width, at least 1s and at most 1440 buckets per window (default: 1m)", Schema: &Schema{Type: "string"}},
+				{Name: "group_by", In: "query", Description: "Group by route pattern rather than raw path", Schema: &Schema{Type: "string", Enum: []string{"path"}}},
+				{Name: "max_groups", In: "query", Description: "Maximum series when grouped (default: 20, max: 100)", Schema: &Schema{Type: "integer"}},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Request log metrics", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/RequestLogMetrics"}}}},
+				"400": {Description: "Invalid window, bucket or grouping", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}}},
+			},
+		},
+	}
+
 	spec.Paths["/api/admin/logs/clear"] = &PathItem{
 		Post: &Operation{
 			Tags:        []string{"admin"},
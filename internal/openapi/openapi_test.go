@@ -1,10 +1,16 @@
 package openapi
 
 import (
+	"bytes"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/schema"
 )
 
@@ -308,3 +314,750 @@ collections:
 		t.Error("expected error to be required")
 	}
 }
+
+func TestErrorSchemaProblemJSON(t *testing.T) {
+	schemaYAML := `
+version: 1
+collections:
+  items:
+    fields:
+      id:
+        type: uuid
+        primary: true
+`
+	s, _ := schema.Parse([]byte(schemaYAML))
+	spec := Generate(s, GeneratorConfig{Title: "Test", ErrorFormat: config.ErrorFormatProblem})
+
+	errSchema := spec.Components.Schemas["Error"]
+	for _, prop := range []string{"type", "title", "status", "detail", "code", "request_id"} {
+		if errSchema.Properties[prop] == nil {
+			t.Errorf("expected %s property", prop)
+		}
+	}
+
+	resp := spec.Paths["/api/collections/items/{id}"].Get.Responses["404"]
+	if _, ok := resp.Content["application/problem+json"]; !ok {
+		t.Errorf("expected problem+json media type, got %v", resp.Content)
+	}
+}
+
+func TestBlobEndpoints(t *testing.T) {
+	schemaYAML := `
+version: 1
+buckets:
+  media:
+    backend: local
+collections:
+  items:
+    fields:
+      id:
+        type: uuid
+        primary: true
+      thumb:
+        type: blob
+      video:
+        type: blob
+        nullable: true
+        storage: media
+  plain:
+    fields:
+      id:
+        type: uuid
+        primary: true
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	item := spec.Paths["/api/collections/items/{id}/blob/{field}"]
+	if item == nil || item.Get == nil || item.Put == nil {
+		t.Fatalf("expected blob GET and PUT operations, got %+v", item)
+	}
+	if got := item.Get.Parameters[1].Schema.Enum; strings.Join(got, ",") != "thumb,video" {
+		t.Errorf("expected field enum [thumb video], got %v", got)
+	}
+	if _, ok := item.Get.Responses["206"]; !ok {
+		t.Error("expected 206 response for range requests")
+	}
+	if _, ok := spec.Paths["/api/collections/plain/{id}/blob/{field}"]; ok {
+		t.Error("expected no blob path for a collection without blob fields")
+	}
+
+	if ref := spec.Components.Schemas["items"].Properties["thumb"].Ref; ref != "#/components/schemas/BlobInfo" {
+		t.Errorf("expected document blob field to reference BlobInfo, got %q", ref)
+	}
+	if spec.Components.Schemas["BlobInfo"] == nil {
+		t.Fatal("expected BlobInfo schema")
+	}
+
+	input := spec.Components.Schemas["itemsInput"]
+	if input.Properties["thumb"].Format != "byte" {
+		t.Errorf("expected base64 input for blob field, got %q", input.Properties["thumb"].Format)
+	}
+	if !strings.Contains(input.Properties["video"].Description, "media bucket") {
+		t.Errorf("expected bucket blob input description, got %q", input.Properties["video"].Description)
+	}
+}
+
+func TestStorageEndpoints(t *testing.T) {
+	schemaYAML := `
+version: 1
+buckets:
+  avatars:
+    backend: local
+    rules:
+      upload: "has(auth.id)"
+      download: "true"
+  private:
+    backend: local
+collections:
+  items:
+    fields:
+      id:
+        type: uuid
+        primary: true
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	avatars := spec.Paths["/api/files/avatars"]
+	if avatars == nil || avatars.Post == nil || avatars.Get == nil {
+		t.Fatalf("expected upload and list operations, got %+v", avatars)
+	}
+	if avatars.Post.Security != nil {
+		t.Errorf("expected upload to need auth, got %+v", avatars.Post.Security)
+	}
+	download := spec.Paths["/api/files/avatars/{id}/download"]
+	if download == nil || download.Get == nil {
+		t.Fatal("expected a download operation")
+	}
+	data, err := json.Marshal(download.Get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"security":[]`) {
+		t.Errorf("expected a public download to have empty security, got %s", data)
+	}
+
+	data, err = json.Marshal(spec.Paths["/api/files/private/{id}/download"].Get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"security"`) {
+		t.Errorf("expected a private download to inherit the global security, got %s", data)
+	}
+}
+
+func TestCustomRolesEnum(t *testing.T) {
+	schemaYAML := `
+version: 1
+roles: [editor]
+collections:
+  items:
+    fields:
+      id:
+        type: uuid
+        primary: true
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	for _, name := range []string{"User", "AdminUser"} {
+		component, ok := spec.Components.Schemas[name]
+		if !ok {
+			t.Fatalf("expected %s schema", name)
+		}
+		roles := component.Properties["role"].Enum
+		if strings.Join(roles, ",") != "user,admin,editor" {
+			t.Errorf("%s.role enum = %v, want user,admin,editor", name, roles)
+		}
+	}
+}
+
+func TestUserMetadataSchema(t *testing.T) {
+	schemaYAML := `
+version: 1
+userMetadata:
+  additionalProperties: false
+  required: [display_name]
+  properties:
+    display_name: { type: string, maxLength: 64 }
+    theme: { type: string, enum: [light, dark] }
+collections:
+  items:
+    fields:
+      id:
+        type: uuid
+        primary: true
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	for _, name := range []string{"User", "RegisterInput", "AdminUser", "CreateUserInput", "UpdateUserInput"} {
+		if ref := spec.Components.Schemas[name].Properties["metadata"].Ref; ref != "#/components/schemas/UserMetadata" {
+			t.Errorf("%s.metadata ref = %q", name, ref)
+		}
+	}
+
+	data, err := json.Marshal(spec.Components.Schemas["UserMetadata"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"additionalProperties":false`, `"required":["display_name"]`, `"enum":["light","dark"]`, `"maxLength":64`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("UserMetadata missing %s: %s", want, data)
+		}
+	}
+
+	if spec.Paths["/api/auth/me"].Patch == nil {
+		t.Error("expected PATCH /api/auth/me")
+	}
+}
+
+func TestTypedFunctionEndpoints(t *testing.T) {
+	schemaYAML := `
+version: 1
+collections:
+  items:
+    fields:
+      id:
+        type: uuid
+        primary: true
+functions:
+  process_user:
+    runtime: node
+    entrypoint: index.js
+    input:
+      required: [user_id]
+      properties:
+        user_id: { type: string }
+    output:
+      properties:
+        ok: { type: boolean }
+  untyped:
+    runtime: node
+    entrypoint: index.js
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	path, ok := spec.Paths["/api/functions/process_user"]
+	if !ok || path.Post == nil {
+		t.Fatal("expected POST /api/functions/process_user")
+	}
+	if path.Post.OperationID != "invokeProcessUser" {
+		t.Errorf("operationId = %q", path.Post.OperationID)
+	}
+	if ref := path.Post.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/ProcessUserInput" {
+		t.Errorf("request body ref = %q", ref)
+	}
+	if _, ok := path.Post.Responses["422"]; !ok {
+		t.Error("expected 422 response for declared input")
+	}
+
+	input := spec.Components.Schemas["ProcessUserInput"]
+	if input == nil || input.Properties["user_id"] == nil || len(input.Required) != 1 {
+		t.Errorf("unexpected input schema %+v", input)
+	}
+	response := spec.Components.Schemas["ProcessUserResponse"]
+	if response == nil || response.Properties["output"].Ref != "#/components/schemas/ProcessUserOutput" {
+		t.Errorf("unexpected response schema %+v", response)
+	}
+
+	if _, ok := spec.Paths["/api/functions/untyped"]; ok {
+		t.Error("expected untyped function to use the generic invoke endpoint")
+	}
+}
+
+func TestCollectionDocs(t *testing.T) {
+	schemaYAML := `
+version: 1
+collections:
+  posts:
+    docs:
+      description: Blog posts written by users
+      operations:
+        list:
+          summary: Browse posts
+        delete:
+          description: Posts are archived instead; deleting is kept for old clients
+          deprecated: true
+      examples:
+        hello:
+          id: 5f0c1d7e-0000-4000-8000-000000000001
+          title: Hello world
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	if d := spec.Components.Schemas["posts"].Description; d != "Blog posts written by users" {
+		t.Errorf("posts description = %q", d)
+	}
+	list := spec.Paths["/api/collections/posts"].Get
+	if list.Summary != "Browse posts" || list.Description == "" {
+		t.Errorf("expected list summary override and generated description, got %q / %q", list.Summary, list.Description)
+	}
+	del := spec.Paths["/api/collections/posts/{id}"].Delete
+	if !del.Deprecated || !strings.HasPrefix(del.Description, "Posts are archived") {
+		t.Errorf("expected deprecated delete with custom description, got %+v", del)
+	}
+	if spec.Paths["/api/collections/posts/{id}"].Get.Deprecated {
+		t.Error("get should not be deprecated")
+	}
+
+	create := spec.Paths["/api/collections/posts"].Post
+	input := create.RequestBody.Content["application/json"].Examples["hello"].Value.(map[string]any)
+	if _, ok := input["id"]; ok || input["title"] != "Hello world" {
+		t.Errorf("expected input example without generated id, got %v", input)
+	}
+	created := create.Responses["201"].Content["application/json"].Examples["hello"].Value.(map[string]any)
+	if created["id"] == nil {
+		t.Errorf("expected response example with id, got %v", created)
+	}
+
+	data, err := json.Marshal(spec.Components.Schemas["posts"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"examples":[{`) {
+		t.Errorf("expected schema examples: %s", data)
+	}
+
+	invalid := strings.Replace(schemaYAML, "        list:", "        search:", 1)
+	if _, err := schema.Parse([]byte(invalid)); err == nil || !strings.Contains(err.Error(), `unknown operation "search"`) {
+		t.Errorf("expected unknown operation error, got %v", err)
+	}
+}
+
+func TestEventWebhooks(t *testing.T) {
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	for _, name := range []string{"posts.insert", "posts.update", "posts.delete"} {
+		item, ok := spec.Webhooks[name]
+		if !ok || item.Post == nil {
+			t.Fatalf("expected webhook %s", name)
+		}
+		payload := item.Post.RequestBody.Content["application/json"].Schema
+		if ref := payload.Properties["document"].Ref; ref != "#/components/schemas/posts" {
+			t.Errorf("%s: document ref = %q", name, ref)
+		}
+		_, hasPrevious := payload.Properties["previous"]
+		if hasPrevious != (name == "posts.update") {
+			t.Errorf("%s: previous present = %v", name, hasPrevious)
+		}
+	}
+	if op := spec.Webhooks["posts.update"].Post; op.OperationID != "onPostsUpdate" || op.Tags[0] != "posts" {
+		t.Errorf("unexpected update operation %+v", op)
+	}
+
+	login, ok := spec.Webhooks["auth.login"]
+	if !ok {
+		t.Fatal("expected auth.login webhook")
+	}
+	if ref := login.Post.RequestBody.Content["application/json"].Schema.Properties["user"].Ref; ref != "#/components/schemas/User" {
+		t.Errorf("auth.login user ref = %q", ref)
+	}
+	if _, ok := spec.Webhooks["auth.password_reset"]; !ok {
+		t.Error("expected auth.password_reset webhook")
+	}
+
+	data, err := spec.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"webhooks"`) {
+		t.Error("expected webhooks in JSON output")
+	}
+}
+
+// TestGenerateValidatesAgainstMetaSchema checks generated specs against the
+// official OpenAPI 3.1 schema in testdata.
+func TestGenerateValidatesAgainstMetaSchema(t *testing.T) {
+	const metaSchemaURL = "https://spec.openapis.org/oas/3.1/schema/2022-10-07"
+
+	f, err := os.Open(filepath.Join("testdata", "oas-3.1-schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	metaSchema, err := jsonschema.UnmarshalJSON(f)
+	if err != nil {
+		t.Fatalf("parse meta-schema: %v", err)
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(metaSchemaURL, metaSchema); err != nil {
+		t.Fatal(err)
+	}
+	validator, err := compiler.Compile(metaSchemaURL)
+	if err != nil {
+		t.Fatalf("compile meta-schema: %v", err)
+	}
+	if err := validator.Validate(map[string]any{"openapi": "3.1.0"}); err == nil {
+		t.Fatal("expected a spec without info to fail validation")
+	}
+
+	s, err := schema.Parse([]byte(`
+version: 1
+roles: [editor]
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+        maxLength: 200
+      status:
+        type: string
+        validate:
+          enum: [draft, published]
+      body:
+        type: text
+        nullable: true
+      cover:
+        type: blob
+        nullable: true
+      updated_at:
+        type: timestamp
+        default: now
+        onUpdate: now
+    docs:
+      description: Blog posts
+      examples:
+        first:
+          title: Hello
+functions:
+  summarize:
+    runtime: node
+    entrypoint: index.js
+    input:
+      required: [text]
+      properties:
+        text: { type: string }
+    output:
+      properties:
+        summary: { type: string }
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	for _, format := range []string{"", config.ErrorFormatProblem} {
+		spec := Generate(s, GeneratorConfig{Title: "Test", Version: "1.0.0", ServerURL: "http://localhost:8090", ErrorFormat: format})
+		data, err := spec.JSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validator.Validate(doc); err != nil {
+			t.Errorf("spec with error format %q does not validate: %v", format, err)
+		}
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	schemaYAML := `
+version: 1
+collections:
+  items:
+    use: [id]
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	login := spec.Paths["/api/auth/login"].Post
+	limited, ok := login.Responses["429"]
+	if !ok {
+		t.Fatal("expected 429 response on login")
+	}
+	if limited.Headers["Retry-After"] == nil || limited.Headers["RateLimit-Reset"] == nil {
+		t.Errorf("expected Retry-After and RateLimit headers on 429, got %v", limited.Headers)
+	}
+	if h := login.Responses["200"].Headers["RateLimit-Remaining"]; h == nil || h.Ref != "#/components/headers/RateLimit-Remaining" {
+		t.Errorf("expected RateLimit-Remaining header on 200, got %v", h)
+	}
+	if spec.Components.Headers["RateLimit-Limit"] == nil {
+		t.Error("expected RateLimit-Limit header component")
+	}
+
+	if _, ok := spec.Paths["/api/auth/me"].Get.Responses["429"]; ok {
+		t.Error("expected no 429 response on an operation that is not rate limited")
+	}
+
+	limits := spec.Paths["/api/auth/me/limits"]
+	if limits == nil || limits.Get == nil || limits.Get.OperationID != "getRateLimits" {
+		t.Fatal("expected GET /api/auth/me/limits")
+	}
+	if spec.Components.Schemas["RateLimitStatus"] == nil {
+		t.Error("expected RateLimitStatus schema")
+	}
+}
+
+func TestListConfigDefaults(t *testing.T) {
+	schemaYAML := `
+version: 1
+collections:
+  posts:
+    use: [id, timestamps]
+    list:
+      defaultSort: "-created_at"
+      defaultLimit: 20
+      maxLimit: 50
+  tags:
+    use: [id]
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	params := func(path string) map[string]Parameter {
+		byName := make(map[string]Parameter)
+		for _, p := range spec.Paths[path].Get.Parameters {
+			byName[p.Name] = p
+		}
+		return byName
+	}
+
+	posts := params("/api/collections/posts")
+	if posts["limit"].Schema.Default != 20 || !strings.Contains(posts["limit"].Description, "max: 50") {
+		t.Errorf("unexpected posts limit parameter: %+v %+v", posts["limit"], posts["limit"].Schema)
+	}
+	if posts["sort"].Schema.Default != "-created_at" || !strings.Contains(posts["sort"].Description, "defaults to '-created_at'") {
+		t.Errorf("unexpected posts sort parameter: %+v %+v", posts["sort"], posts["sort"].Schema)
+	}
+
+	tags := params("/api/collections/tags")
+	if tags["limit"].Schema.Default != schema.DefaultListLimit || !strings.Contains(tags["limit"].Description, "max: 1000") {
+		t.Errorf("unexpected tags limit parameter: %+v %+v", tags["limit"], tags["limit"].Schema)
+	}
+	if tags["sort"].Schema.Default != nil {
+		t.Errorf("expected no default sort for tags, got %v", tags["sort"].Schema.Default)
+	}
+
+	if spec.Paths["/api/collections/posts"].Get.Responses["200"].Headers[limitClampedHeader] == nil {
+		t.Error("expected clamp header on list response")
+	}
+}
+
+func TestEncryptedFieldDescription(t *testing.T) {
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  patients:
+    use: [id]
+    fields:
+      ssn: { type: string, encrypted: true, nullable: true }
+      name: { type: string }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	for _, name := range []string{"patients", "patientsInput"} {
+		props := spec.Components.Schemas[name].Properties
+		if !strings.Contains(props["ssn"].Description, "Encrypted at rest") {
+			t.Errorf("%s.ssn description = %q", name, props["ssn"].Description)
+		}
+		if props["name"].Description != "" {
+			t.Errorf("%s.name description = %q, want none", name, props["name"].Description)
+		}
+	}
+}
+
+func TestChecksDescription(t *testing.T) {
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  events:
+    use: [id]
+    fields:
+      start_date: { type: timestamp }
+      end_date: { type: timestamp }
+    checks:
+      - name: ends_after_start
+        expr: doc.end_date >= doc.start_date
+        message: End date must not be before the start date
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	desc := spec.Components.Schemas["eventsInput"].Description
+	for _, want := range []string{"ends_after_start", "`doc.end_date >= doc.start_date`", "End date must not be before the start date"} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("input description is missing %q: %q", want, desc)
+		}
+	}
+	if _, ok := spec.Paths["/api/collections/events"].Post.Responses["422"]; !ok {
+		t.Error("expected a 422 response on create")
+	}
+	if _, ok := spec.Paths["/api/collections/events/{id}"].Patch.Responses["422"]; !ok {
+		t.Error("expected a 422 response on update")
+	}
+}
+
+func TestFieldReadRuleOptional(t *testing.T) {
+	schemaYAML := `
+version: 1
+collections:
+  profiles:
+    fields:
+      id:
+        type: uuid
+        primary: true
+      owner:
+        type: string
+      email:
+        type: string
+        readRule: "auth.id == doc.owner"
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	profiles := spec.Components.Schemas["profiles"]
+	for _, name := range profiles.Required {
+		if name == "email" {
+			t.Errorf("email should not be required: %v", profiles.Required)
+		}
+	}
+	if !strings.Contains(strings.Join(profiles.Required, ","), "owner") {
+		t.Errorf("owner should stay required: %v", profiles.Required)
+	}
+	if desc := profiles.Properties["email"].Description; !strings.Contains(desc, "auth.id == doc.owner") {
+		t.Errorf("email description %q does not name the read rule", desc)
+	}
+}
+
+func TestPatchSchema(t *testing.T) {
+	schemaYAML := `
+version: 1
+collections:
+  notes:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+      body:
+        type: text
+        nullable: true
+`
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	if input := spec.Components.Schemas["notesInput"]; strings.Join(input.Required, ",") != "title" {
+		t.Errorf("notesInput required = %v, want [title]", input.Required)
+	}
+	patch, ok := spec.Components.Schemas["notesPatch"]
+	if !ok {
+		t.Fatal("expected notesPatch schema")
+	}
+	if len(patch.Required) != 0 {
+		t.Errorf("notesPatch should have no required fields, got %v", patch.Required)
+	}
+	if !patch.Properties["body"].Nullable || patch.Properties["title"].Nullable {
+		t.Errorf("expected only body to be nullable: %+v %+v", patch.Properties["body"], patch.Properties["title"])
+	}
+
+	update := spec.Paths["/api/collections/notes/{id}"].Patch
+	if ref := update.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/notesPatch" {
+		t.Errorf("PATCH body ref = %q", ref)
+	}
+	if _, ok := update.Responses["422"]; !ok {
+		t.Error("expected a 422 response on PATCH")
+	}
+}
+
+func TestUnexposedCollectionOmitted(t *testing.T) {
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  posts:
+    use: [id]
+  jobs:
+    use: [id]
+    expose: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := Generate(s, GeneratorConfig{Title: "Test"})
+
+	if spec.Paths["/api/collections/posts"] == nil || spec.Components.Schemas["posts"] == nil {
+		t.Error("expected posts in the spec")
+	}
+	for path := range spec.Paths {
+		if strings.Contains(path, "jobs") {
+			t.Errorf("unexpected path for unexposed collection: %s", path)
+		}
+	}
+	for _, name := range []string{"jobs", "jobsInput", "jobsPatch"} {
+		if spec.Components.Schemas[name] != nil {
+			t.Errorf("unexpected component schema %s", name)
+		}
+	}
+	for _, tag := range spec.Tags {
+		if tag.Name == "jobs" {
+			t.Error("unexpected jobs tag")
+		}
+	}
+}
@@ -2,13 +2,20 @@ package realtime
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/events"
+	"github.com/watzon/alyx/internal/metrics"
 	"github.com/watzon/alyx/internal/rules"
 	"github.com/watzon/alyx/internal/schema"
 )
@@ -24,6 +31,15 @@ type Broker struct {
 	index         *SubscriptionIndex
 	detector      *ChangeDetector
 
+	cleanupInterval time.Duration
+	cleanupAge      time.Duration
+
+	pingInterval      time.Duration
+	maxMissedPongs    int
+	writeTimeout      time.Duration
+	slowClientTimeout time.Duration
+	evictions         atomic.Int64
+
 	mu       sync.RWMutex
 	wg       sync.WaitGroup
 	done     chan struct{}
@@ -33,10 +49,49 @@ type Broker struct {
 // BrokerConfig holds configuration for the broker.
 type BrokerConfig struct {
 	PollInterval   int64
+	Mode           DetectionMode
 	MaxConnections int
 	BufferSize     int
+
+	// CleanupInterval and CleanupAge control pruning of processed changes.
+	// Changes are kept for CleanupAge so subscriptions can resume from a
+	// cursor; zero disables pruning.
+	CleanupInterval time.Duration
+	CleanupAge      time.Duration
+
+	// PingInterval is how often clients are pinged; a client missing
+	// MaxMissedPongs pongs in a row is evicted. WriteTimeout bounds each
+	// write, and a client whose send buffer stays full for
+	// SlowClientTimeout is evicted. Zero values use the defaults.
+	PingInterval      time.Duration
+	MaxMissedPongs    int
+	WriteTimeout      time.Duration
+	SlowClientTimeout time.Duration
 }
 
+// Keepalive and slow client defaults, used when BrokerConfig leaves them
+// unset.
+const (
+	defaultPingInterval      = 30 * time.Second
+	defaultMaxMissedPongs    = 2
+	defaultWriteTimeout      = 10 * time.Second
+	defaultSlowClientTimeout = 5 * time.Second
+)
+
+// EvictionReason says why the broker disconnected a client.
+type EvictionReason string
+
+// Eviction reasons, used as the reason label of the eviction metric.
+const (
+	EvictionMissedPongs  EvictionReason = "missed_pongs"
+	EvictionWriteTimeout EvictionReason = "write_timeout"
+	EvictionSlowConsumer EvictionReason = "slow_consumer"
+)
+
+// maxReplayChanges bounds how far behind a since cursor may be. Clients
+// further behind get ErrCursorExpired and should resync from a snapshot.
+const maxReplayChanges = 10000
+
 // NewBroker creates a new subscription broker.
 func NewBroker(db *database.DB, s *schema.Schema, rulesEngine *rules.Engine, cfg *BrokerConfig) *Broker {
 	if cfg == nil {
@@ -48,17 +103,37 @@ func NewBroker(db *database.DB, s *schema.Schema, rulesEngine *rules.Engine, cfg
 	}
 
 	b := &Broker{
-		db:            db,
-		schema:        s,
-		rules:         rulesEngine,
-		clients:       make(map[string]*Client),
-		subscriptions: make(map[string]*Subscription),
-		index:         NewSubscriptionIndex(),
-		done:          make(chan struct{}),
-		changeCh:      make(chan *Change, cfg.BufferSize),
+		db:              db,
+		schema:          s,
+		rules:           rulesEngine,
+		clients:         make(map[string]*Client),
+		subscriptions:   make(map[string]*Subscription),
+		index:           NewSubscriptionIndex(),
+		done:            make(chan struct{}),
+		changeCh:        make(chan *Change, cfg.BufferSize),
+		cleanupInterval: cfg.CleanupInterval,
+		cleanupAge:      cfg.CleanupAge,
+
+		pingInterval:      cfg.PingInterval,
+		maxMissedPongs:    cfg.MaxMissedPongs,
+		writeTimeout:      cfg.WriteTimeout,
+		slowClientTimeout: cfg.SlowClientTimeout,
+	}
+	if b.pingInterval <= 0 {
+		b.pingInterval = defaultPingInterval
+	}
+	if b.maxMissedPongs <= 0 {
+		b.maxMissedPongs = defaultMaxMissedPongs
+	}
+	if b.writeTimeout <= 0 {
+		b.writeTimeout = defaultWriteTimeout
+	}
+	if b.slowClientTimeout <= 0 {
+		b.slowClientTimeout = defaultSlowClientTimeout
 	}
 
 	b.detector = NewChangeDetector(db, cfg.PollInterval, b.changeCh)
+	b.detector.SetMode(cfg.Mode)
 	return b
 }
 
@@ -76,9 +151,35 @@ func (b *Broker) Start(ctx context.Context) error {
 		b.processChanges(ctx)
 	}()
 
+	if b.cleanupInterval > 0 && b.cleanupAge > 0 {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.cleanupLoop(ctx)
+		}()
+	}
+
 	return nil
 }
 
+func (b *Broker) cleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(b.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.detector.CleanupOldChanges(ctx, b.cleanupAge); err != nil {
+				log.Error().Err(err).Msg("Failed to clean up old changes")
+			}
+		case <-b.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // Stop gracefully shuts down the broker.
 func (b *Broker) Stop() {
 	close(b.done)
@@ -127,10 +228,15 @@ func (b *Broker) UnregisterClient(clientID string) {
 	log.Debug().Str("client_id", clientID).Int("total_clients", len(b.clients)).Msg("Client disconnected")
 }
 
-// Subscribe creates a new subscription for a client.
+// Subscribe creates a new subscription for a client and returns its initial
+// data. Live changes for the subscription are queued until Activate is
+// called, so the caller can send the snapshot or replay first without racing
+// the change feed.
 func (b *Broker) Subscribe(client *Client, sub *Subscription) (*SubscriptionSnapshot, error) {
+	b.mu.RLock()
 	col, ok := b.schema.Collections[sub.Collection]
-	if !ok {
+	b.mu.RUnlock()
+	if !ok || (!col.Exposed() && sub.AuthContext["role"] != schema.RoleAdmin) {
 		return nil, ErrCollectionNotFound
 	}
 
@@ -138,12 +244,16 @@ func (b *Broker) Subscribe(client *Client, sub *Subscription) (*SubscriptionSnap
 		return nil, err
 	}
 
+	sub.syncMu.Lock()
+	sub.syncing = true
+	sub.syncMu.Unlock()
+
 	b.mu.Lock()
 	b.subscriptions[sub.ID] = sub
 	b.index.Add(sub)
 	b.mu.Unlock()
 
-	snapshot, err := b.executeSubscriptionQuery(sub, col)
+	snapshot, err := b.prepareSubscription(sub, col)
 	if err != nil {
 		b.mu.Lock()
 		delete(b.subscriptions, sub.ID)
@@ -156,6 +266,189 @@ func (b *Broker) Subscribe(client *Client, sub *Subscription) (*SubscriptionSnap
 	return snapshot, nil
 }
 
+// prepareSubscription reads the change feed head, then the snapshot, then any
+// replay. Every change after the head is either already queued on the
+// subscription or still to be broadcast, so nothing falls between the
+// snapshot and live delivery.
+func (b *Broker) prepareSubscription(sub *Subscription, col *schema.Collection) (*SubscriptionSnapshot, error) {
+	ctx := context.Background()
+
+	head, err := b.changeHead(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading change cursor: %w", err)
+	}
+
+	var since int64
+	if sub.Since != nil {
+		since, err = b.resolveCursor(ctx, sub.Since, head)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// While the subscription is syncing, broadcastChange only queues changes,
+	// so DocIDs is not touched concurrently and the feed is not held up by
+	// the snapshot query.
+	snapshot, err := b.executeSubscriptionQuery(sub, col)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Cursor = head
+
+	sub.syncMu.Lock()
+	sub.cursor = head
+	sub.syncMu.Unlock()
+
+	if sub.Since != nil {
+		snapshot.Replay, err = b.replayChanges(ctx, sub, col, since, head)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return snapshot, nil
+}
+
+// Activate delivers the changes queued while the subscription was being
+// prepared and switches it to live delivery.
+func (b *Broker) Activate(client *Client, sub *Subscription) {
+	b.mu.RLock()
+	col, ok := b.schema.Collections[sub.Collection]
+	b.mu.RUnlock()
+
+	sub.syncMu.Lock()
+	defer sub.syncMu.Unlock()
+
+	pending := sub.pending
+	sub.pending = nil
+	sub.syncing = false
+
+	if !ok {
+		return
+	}
+	for _, change := range pending {
+		// Changes up to the head are already reflected in the snapshot.
+		if change.ID <= sub.cursor {
+			continue
+		}
+		b.deliverChange(client, sub, col, change)
+	}
+}
+
+// changeHead returns the ID of the newest recorded change.
+func (b *Broker) changeHead(ctx context.Context) (int64, error) {
+	var head int64
+	err := b.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM _alyx_changes").Scan(&head)
+	return head, err
+}
+
+// resolveCursor converts a since cursor to a change ID, or returns
+// ErrCursorExpired when changes after it may already have been pruned.
+func (b *Broker) resolveCursor(ctx context.Context, cursor *Cursor, head int64) (int64, error) {
+	var oldest int64
+	if err := b.db.QueryRowContext(ctx, "SELECT COALESCE(MIN(id), 0) FROM _alyx_changes").Scan(&oldest); err != nil {
+		return 0, fmt.Errorf("reading change history: %w", err)
+	}
+
+	id := cursor.ID
+	if !cursor.Time.IsZero() {
+		ts := cursor.Time.UTC().Format("2006-01-02 15:04:05")
+		var found sql.NullInt64
+		if err := b.db.QueryRowContext(ctx,
+			"SELECT MAX(id) FROM _alyx_changes WHERE timestamp <= ?", ts,
+		).Scan(&found); err != nil {
+			return 0, fmt.Errorf("resolving cursor: %w", err)
+		}
+		switch {
+		case found.Valid:
+			id = found.Int64
+		case oldest <= 1:
+			// Nothing has been pruned, so every change is after the cursor.
+			id = 0
+		default:
+			return 0, ErrCursorExpired
+		}
+	}
+
+	switch {
+	case id < 0 || id > head:
+		// A cursor past the head comes from a different or reset change feed.
+		return 0, ErrCursorExpired
+	case head > 0 && id < oldest-1:
+		return 0, ErrCursorExpired
+	case head-id > maxReplayChanges:
+		return 0, ErrCursorExpired
+	}
+	return id, nil
+}
+
+// replayChanges builds the catch-up delta for changes in (since, head]. Each
+// document touched since the cursor is sent once with its current state: as
+// an insert if it was created after the cursor, an update if it still
+// matches, or a delete if it no longer exists or no longer matches.
+func (b *Broker) replayChanges(ctx context.Context, sub *Subscription, col *schema.Collection, since, head int64) (*Changes, error) {
+	delta := &Changes{}
+	if since >= head || col.PrimaryKeyField() == nil {
+		return delta, nil
+	}
+
+	// SQLite returns the operation from the row holding MIN(id), i.e. the
+	// first change to each document after the cursor.
+	rows, err := b.db.QueryContext(ctx, `
+		SELECT doc_id, operation, MIN(id) AS first_id
+		FROM _alyx_changes
+		WHERE collection = ? AND id > ? AND id <= ?
+		GROUP BY doc_id
+		ORDER BY first_id ASC
+	`, col.Name, since, head)
+	if err != nil {
+		return nil, fmt.Errorf("reading changes: %w", err)
+	}
+
+	type touched struct {
+		docID string
+		op    Operation
+	}
+	var docs []touched
+	for rows.Next() {
+		var t touched
+		var firstID int64
+		if err := rows.Scan(&t.docID, &t.op, &firstID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning change: %w", err)
+		}
+		docs = append(docs, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading changes: %w", err)
+	}
+
+	collection := database.NewCollection(b.db, col)
+	for _, t := range docs {
+		doc, err := collection.FindOne(ctx, t.docID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return nil, err
+		}
+
+		if err == nil && b.matchesFilter(col, doc, sub.Filter) && b.canReadDocument(sub, col, doc) {
+			b.redactFields(sub, col, doc)
+			sub.DocIDs[t.docID] = struct{}{}
+			if t.op == OperationInsert {
+				delta.Inserts = append(delta.Inserts, doc)
+			} else {
+				delta.Updates = append(delta.Updates, doc)
+			}
+			continue
+		}
+
+		delete(sub.DocIDs, t.docID)
+		delta.Deletes = append(delta.Deletes, t.docID)
+	}
+
+	return delta, nil
+}
+
 // Unsubscribe removes a subscription.
 func (b *Broker) Unsubscribe(subID string) {
 	b.mu.Lock()
@@ -174,6 +467,10 @@ func (b *Broker) Unsubscribe(subID string) {
 type SubscriptionSnapshot struct {
 	Docs  []any
 	Total int64
+	// Cursor is the newest change reflected in Docs.
+	Cursor int64
+	// Replay is the catch-up delta for a subscription with a since cursor.
+	Replay *Changes
 }
 
 func (b *Broker) executeSubscriptionQuery(sub *Subscription, col *schema.Collection) (*SubscriptionSnapshot, error) {
@@ -190,8 +487,11 @@ func (b *Broker) executeSubscriptionQuery(sub *Subscription, col *schema.Collect
 	}
 
 	for _, s := range sub.Sort {
-		field, order := database.ParseSortString(s)
-		opts.Sorts = append(opts.Sorts, &database.Sort{Field: field, Order: order})
+		sort, err := database.ParseSort(s)
+		if err != nil {
+			return nil, err
+		}
+		opts.Sorts = append(opts.Sorts, sort)
 	}
 
 	result, err := collection.Find(context.Background(), opts)
@@ -204,7 +504,7 @@ func (b *Broker) executeSubscriptionQuery(sub *Subscription, col *schema.Collect
 	pk := col.PrimaryKeyField()
 
 	for _, doc := range result.Docs {
-		if !b.canReadDocument(sub, col.Name, doc) {
+		if !b.canReadDocument(sub, col, doc) {
 			continue
 		}
 		if pk != nil {
@@ -212,6 +512,7 @@ func (b *Broker) executeSubscriptionQuery(sub *Subscription, col *schema.Collect
 				sub.DocIDs[toString(id)] = struct{}{}
 			}
 		}
+		b.redactFields(sub, col, doc)
 		docs = append(docs, doc)
 	}
 
@@ -235,11 +536,19 @@ func (b *Broker) processChanges(ctx context.Context) {
 }
 
 func (b *Broker) broadcastChange(change *Change) {
+	// The change feed also sees writes made outside Collection, such as from
+	// functions or raw SQL, so it keeps cached list counts honest.
+	if b.db != nil {
+		if cache := b.db.CountCache(); cache != nil {
+			cache.Invalidate(change.Collection)
+		}
+	}
+
 	b.mu.RLock()
 	candidates := b.index.GetCandidates(change.Collection)
+	col, ok := b.schema.Collections[change.Collection]
 	b.mu.RUnlock()
 
-	col, ok := b.schema.Collections[change.Collection]
 	if !ok {
 		return
 	}
@@ -254,21 +563,33 @@ func (b *Broker) broadcastChange(change *Change) {
 			continue
 		}
 
-		delta, err := b.calculateDelta(sub, col, change)
-		if err != nil {
-			log.Error().Err(err).
-				Str("subscription_id", sub.ID).
-				Str("collection", change.Collection).
-				Msg("Failed to calculate delta")
-			continue
+		sub.syncMu.Lock()
+		if sub.syncing {
+			sub.pending = append(sub.pending, change)
+		} else {
+			b.deliverChange(client, sub, col, change)
 		}
+		sub.syncMu.Unlock()
+	}
+}
 
-		if delta == nil || delta.IsEmpty() {
-			continue
-		}
+// deliverChange sends the delta for one change; the caller holds sub.syncMu.
+func (b *Broker) deliverChange(client *Client, sub *Subscription, col *schema.Collection, change *Change) {
+	delta, err := b.calculateDelta(sub, col, change)
+	if err != nil {
+		log.Error().Err(err).
+			Str("subscription_id", sub.ID).
+			Str("collection", change.Collection).
+			Msg("Failed to calculate delta")
+		return
+	}
 
-		b.sendDelta(client, sub, delta)
+	sub.cursor = change.ID
+	if delta == nil || delta.IsEmpty() {
+		return
 	}
+
+	b.sendDelta(client, sub, delta)
 }
 
 func (b *Broker) calculateDelta(sub *Subscription, col *schema.Collection, change *Change) (*Changes, error) {
@@ -299,7 +620,8 @@ func (b *Broker) handleInsert(sub *Subscription, col *schema.Collection, docID s
 	}
 
 	delta := &Changes{}
-	if b.matchesFilter(doc, sub.Filter) && b.canReadDocument(sub, col.Name, doc) {
+	if b.matchesFilter(col, doc, sub.Filter) && b.canReadDocument(sub, col, doc) {
+		b.redactFields(sub, col, doc)
 		delta.Inserts = append(delta.Inserts, doc)
 		sub.DocIDs[docID] = struct{}{}
 	}
@@ -318,7 +640,10 @@ func (b *Broker) handleUpdate(sub *Subscription, col *schema.Collection, docID s
 		return nil, err
 	}
 
-	matchesNow := b.matchesFilter(doc, sub.Filter) && b.canReadDocument(sub, col.Name, doc)
+	matchesNow := b.matchesFilter(col, doc, sub.Filter) && b.canReadDocument(sub, col, doc)
+	if matchesNow {
+		b.redactFields(sub, col, doc)
+	}
 	return b.computeUpdateDelta(sub, docID, doc, wasInSet, matchesNow), nil
 }
 
@@ -355,7 +680,7 @@ func (b *Broker) handleDelete(sub *Subscription, docID string) *Changes {
 	return delta
 }
 
-func (b *Broker) matchesFilter(doc database.Row, filters map[string]Filter) bool {
+func (b *Broker) matchesFilter(col *schema.Collection, doc database.Row, filters map[string]Filter) bool {
 	if len(filters) == 0 {
 		return true
 	}
@@ -366,7 +691,9 @@ func (b *Broker) matchesFilter(doc database.Row, filters map[string]Filter) bool
 			return false
 		}
 
-		if !matchValue(val, filter) {
+		// Match the database, where nocase fields compare ignoring case.
+		f := col.Fields[field]
+		if !matchValue(val, filter, f != nil && f.CaseInsensitive()) {
 			return false
 		}
 	}
@@ -374,33 +701,44 @@ func (b *Broker) matchesFilter(doc database.Row, filters map[string]Filter) bool
 	return true
 }
 
-func matchValue(val any, filter Filter) bool {
-	if filter.Eq != nil && !equals(val, filter.Eq) {
+func matchValue(val any, filter Filter, nocase bool) bool {
+	if filter.Eq != nil && !equals(val, filter.Eq, nocase) {
 		return false
 	}
-	if filter.Ne != nil && equals(val, filter.Ne) {
+	if filter.Ne != nil && equals(val, filter.Ne, nocase) {
 		return false
 	}
-	if filter.In != nil && !inArray(val, filter.In) {
+	if filter.In != nil && !inArray(val, filter.In, nocase) {
 		return false
 	}
 	return true
 }
 
-func (b *Broker) canReadDocument(sub *Subscription, collection string, doc database.Row) bool {
+func (b *Broker) canReadDocument(sub *Subscription, col *schema.Collection, doc database.Row) bool {
+	// Tenant-scoped collections only deliver the subscriber's own tenant.
+	var tenant any
+	if col.Tenant != nil {
+		value, ok := col.Tenant.Resolve(sub.AuthContext)
+		if !ok || !col.Tenant.Owns(doc, value) {
+			return false
+		}
+		tenant = value
+	}
+
 	if b.rules == nil {
 		return true
 	}
 
 	evalCtx := &rules.EvalContext{
-		Auth: sub.AuthContext,
-		Doc:  doc,
+		Auth:   sub.AuthContext,
+		Doc:    doc,
+		Tenant: tenant,
 	}
 
-	allowed, err := b.rules.Evaluate(collection, rules.OpRead, evalCtx)
+	allowed, err := b.rules.Evaluate(col.Name, rules.OpRead, evalCtx)
 	if err != nil {
 		log.Debug().Err(err).
-			Str("collection", collection).
+			Str("collection", col.Name).
 			Str("subscription_id", sub.ID).
 			Msg("Rule evaluation failed, denying access")
 		return false
@@ -409,13 +747,52 @@ func (b *Broker) canReadDocument(sub *Subscription, collection string, doc datab
 	return allowed
 }
 
-func equals(a, b any) bool {
+// redactFields removes from a document the subscriber may read the fields
+// whose read rule hides them from it. Each subscription reads its own copy
+// of a changed document, so it is modified in place.
+func (b *Broker) redactFields(sub *Subscription, col *schema.Collection, doc database.Row) {
+	if b.rules == nil || !b.rules.HasFieldRules(col.Name) {
+		return
+	}
+
+	var tenant any
+	if col.Tenant != nil {
+		tenant, _ = col.Tenant.Resolve(sub.AuthContext)
+	}
+	b.rules.RedactFields(col.Name, &rules.EvalContext{Auth: sub.AuthContext, Tenant: tenant}, doc)
+}
+
+func equals(a, b any, nocase bool) bool {
+	if nocase {
+		return asciiEqualFold(toString(a), toString(b))
+	}
 	return toString(a) == toString(b)
 }
 
-func inArray(val any, arr []any) bool {
+// asciiEqualFold compares like SQLite's NOCASE collation, which only folds
+// ASCII letters.
+func asciiEqualFold(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		ca, cb := a[i], b[i]
+		if 'A' <= ca && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if 'A' <= cb && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
+
+func inArray(val any, arr []any, nocase bool) bool {
 	for _, v := range arr {
-		if equals(val, v) {
+		if equals(val, v, nocase) {
 			return true
 		}
 	}
@@ -439,6 +816,7 @@ func (b *Broker) sendDelta(client *Client, sub *Subscription, delta *Changes) {
 	payload, _ := json.Marshal(&DeltaPayload{
 		SubscriptionID: sub.ID,
 		Changes:        *delta,
+		Cursor:         sub.cursor,
 	})
 
 	_ = client.Send(&Message{
@@ -466,8 +844,10 @@ func (b *Broker) SubscriptionCount() int {
 }
 
 type BrokerStats struct {
-	Connections   int `json:"connections"`
-	Subscriptions int `json:"subscriptions"`
+	Connections   int           `json:"connections"`
+	Subscriptions int           `json:"subscriptions"`
+	Mode          DetectionMode `json:"mode"`
+	Evictions     int64         `json:"evictions"`
 }
 
 func (b *Broker) Stats() BrokerStats {
@@ -476,6 +856,167 @@ func (b *Broker) Stats() BrokerStats {
 	return BrokerStats{
 		Connections:   len(b.clients),
 		Subscriptions: len(b.subscriptions),
+		Mode:          b.detector.Mode(),
+		Evictions:     b.evictions.Load(),
+	}
+}
+
+// ConnectionInfo describes a connected client for the admin API.
+type ConnectionInfo struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id,omitempty"`
+	RemoteAddr    string    `json:"remote_addr"`
+	ConnectedAt   time.Time `json:"connected_at"`
+	Subscriptions int       `json:"subscriptions"`
+	Delivered     int64     `json:"delivered"`
+	Dropped       int64     `json:"dropped"`
+}
+
+// SubscriptionInfo describes an active subscription for the admin API.
+type SubscriptionInfo struct {
+	ID         string            `json:"id"`
+	ClientID   string            `json:"client_id"`
+	UserID     string            `json:"user_id,omitempty"`
+	Collection string            `json:"collection"`
+	Filter     map[string]Filter `json:"filter,omitempty"`
+	Sort       []string          `json:"sort,omitempty"`
+	Limit      int               `json:"limit,omitempty"`
+	State      SubscriptionState `json:"state"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// Connections returns a snapshot of connected clients, oldest first.
+func (b *Broker) Connections() []ConnectionInfo {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	conns := make([]ConnectionInfo, 0, len(b.clients))
+	for _, client := range b.clients {
+		client.mu.RLock()
+		subs := len(client.subscriptions)
+		client.mu.RUnlock()
+
+		conns = append(conns, ConnectionInfo{
+			ID:            client.ID,
+			UserID:        client.UserID,
+			RemoteAddr:    client.RemoteAddr,
+			ConnectedAt:   client.ConnectedAt,
+			Subscriptions: subs,
+			Delivered:     client.delivered.Load(),
+			Dropped:       client.dropped.Load(),
+		})
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		if conns[i].ConnectedAt.Equal(conns[j].ConnectedAt) {
+			return conns[i].ID < conns[j].ID
+		}
+		return conns[i].ConnectedAt.Before(conns[j].ConnectedAt)
+	})
+	return conns
+}
+
+// Subscriptions returns a snapshot of active subscriptions, oldest first.
+// If collection is non-empty, only subscriptions to it are returned.
+func (b *Broker) Subscriptions(collection string) []SubscriptionInfo {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	subs := make([]SubscriptionInfo, 0, len(b.subscriptions))
+	for _, sub := range b.subscriptions {
+		if collection != "" && sub.Collection != collection {
+			continue
+		}
+		info := SubscriptionInfo{
+			ID:         sub.ID,
+			ClientID:   sub.ClientID,
+			Collection: sub.Collection,
+			Filter:     sub.Filter,
+			Sort:       sub.Sort,
+			Limit:      sub.Limit,
+			State:      sub.State,
+			CreatedAt:  sub.CreatedAt,
+		}
+		if client, ok := b.clients[sub.ClientID]; ok {
+			info.UserID = client.UserID
+		}
+		subs = append(subs, info)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].CreatedAt.Equal(subs[j].CreatedAt) {
+			return subs[i].ID < subs[j].ID
+		}
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs
+}
+
+// Disconnect force-closes a client with a policy violation close frame
+// carrying reason. It reports false if no such client is connected.
+func (b *Broker) Disconnect(clientID, reason string) bool {
+	client := b.getClient(clientID)
+	if client == nil {
+		return false
+	}
+
+	log.Info().Str("client_id", clientID).Str("reason", reason).Msg("Disconnecting realtime client")
+	client.Kick(reason)
+	b.UnregisterClient(clientID)
+	return true
+}
+
+// evict disconnects a client that stopped keeping up. Callers are the
+// client's own pumps and the broadcast loop, so the disconnect runs on its
+// own goroutine: Kick waits for the pumps and unsubscribes through the
+// broker.
+func (b *Broker) evict(client *Client, reason EvictionReason) {
+	if !client.evicting.CompareAndSwap(false, true) {
+		return
+	}
+
+	b.evictions.Add(1)
+	metrics.RecordRealtimeEviction(string(reason))
+	log.Warn().Str("client_id", client.ID).Str("reason", string(reason)).Msg("Evicting realtime client")
+
+	go func() {
+		client.Kick(string(reason))
+		b.UnregisterClient(client.ID)
+	}()
+}
+
+// BroadcastSystem sends a system message to every client subscribed to
+// ChannelSystem.
+func (b *Broker) BroadcastSystem(payload *SystemPayload) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode system message")
+		return
+	}
+	b.sendToChannel(ChannelSystem, &Message{Type: MessageTypeSystem, Payload: data})
+}
+
+// Publish sends an event message to every client subscribed to channel. It
+// implements events.Publisher.
+func (b *Broker) Publish(channel string, event events.Type, data any) {
+	payload, err := json.Marshal(&EventPayload{Channel: channel, Event: event, Data: data})
+	if err != nil {
+		log.Error().Err(err).Str("event", string(event)).Msg("Failed to encode event message")
+		return
+	}
+	b.sendToChannel(channel, &Message{Type: MessageTypeEvent, Payload: payload})
+}
+
+func (b *Broker) sendToChannel(channel string, msg *Message) {
+	b.mu.RLock()
+	clients := make([]*Client, 0, len(b.clients))
+	for _, client := range b.clients {
+		if client.subscribedTo(channel) {
+			clients = append(clients, client)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, client := range clients {
+		_ = client.Send(msg)
 	}
 }
 
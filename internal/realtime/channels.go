@@ -0,0 +1,25 @@
+package realtime
+
+import (
+	"github.com/watzon/alyx/internal/events"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// ChannelAuthorizer reports whether a connection with the given auth context
+// may subscribe to a channel. Channels carry server events rather than
+// documents, so collection rules do not apply to them.
+type ChannelAuthorizer func(authContext map[string]any) bool
+
+// channelAuthorizers lists the channels clients may subscribe to.
+var channelAuthorizers = map[string]ChannelAuthorizer{
+	ChannelSystem:       anyConnection,
+	events.ChannelAuth:  adminConnection,
+	events.ChannelAdmin: adminConnection,
+}
+
+func anyConnection(map[string]any) bool { return true }
+
+// adminConnection allows connections authenticated as an admin user.
+func adminConnection(authContext map[string]any) bool {
+	return authContext["role"] == schema.RoleAdmin
+}
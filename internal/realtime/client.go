@@ -3,7 +3,9 @@ package realtime
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coder/websocket"
@@ -12,18 +14,36 @@ import (
 )
 
 const (
-	writeTimeout     = 10 * time.Second
-	pingInterval     = 30 * time.Second
-	pongTimeout      = 60 * time.Second
 	maxMessageSize   = 512 * 1024
 	maxSubscriptions = 100
 	sendBufferSize   = 256
+
+	// snapshotChunkSize is the number of documents per synthetic insert
+	// delta when streaming an initial snapshot.
+	snapshotChunkSize = 100
 )
 
 // Client represents a connected WebSocket client.
 type Client struct {
-	ID            string
-	AuthContext   map[string]any
+	ID          string
+	AuthContext map[string]any
+	// UserID, RemoteAddr and ConnectedAt describe the connection for the
+	// admin API.
+	UserID      string
+	RemoteAddr  string
+	ConnectedAt time.Time
+
+	// delivered and dropped count messages queued for and discarded from
+	// the send buffer.
+	delivered atomic.Int64
+	dropped   atomic.Int64
+
+	// fullSince is when the send buffer was first found full, in Unix
+	// nanoseconds, or zero while it has room. evicting is set once the
+	// broker decides to drop the client.
+	fullSince atomic.Int64
+	evicting  atomic.Bool
+
 	conn          *websocket.Conn
 	broker        *Broker
 	subscriptions map[string]*Subscription
@@ -33,6 +53,9 @@ type Client struct {
 	done          chan struct{}
 	ctx           context.Context
 	cancel        context.CancelFunc
+
+	// channels holds the channels the client is subscribed to.
+	channels map[string]bool
 }
 
 // NewClient creates a new WebSocket client.
@@ -40,9 +63,11 @@ func NewClient(conn *websocket.Conn, broker *Broker) *Client {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Client{
 		ID:            uuid.New().String(),
+		ConnectedAt:   time.Now().UTC(),
 		conn:          conn,
 		broker:        broker,
 		subscriptions: make(map[string]*Subscription),
+		channels:      make(map[string]bool),
 		sendCh:        make(chan []byte, sendBufferSize),
 		done:          make(chan struct{}),
 		ctx:           ctx,
@@ -69,40 +94,39 @@ func (c *Client) Run() {
 
 // Close terminates the client connection and cleans up resources.
 func (c *Client) Close() {
-	c.mu.Lock()
-	select {
-	case <-c.done:
-		c.mu.Unlock()
+	if !c.markClosed() {
 		return
-	default:
-		close(c.done)
 	}
-	c.mu.Unlock()
 
 	c.cancel()
 	c.wg.Wait()
+	c.unsubscribeAll()
 
-	c.mu.Lock()
-	for _, sub := range c.subscriptions {
-		c.broker.Unsubscribe(sub.ID)
+	c.conn.Close(websocket.StatusNormalClosure, "closing")
+}
+
+// Kick force-disconnects the client. The close frame carrying reason is
+// sent before the read loop is cancelled so the client receives it.
+func (c *Client) Kick(reason string) {
+	if !c.markClosed() {
+		return
 	}
-	c.subscriptions = make(map[string]*Subscription)
-	c.mu.Unlock()
 
-	c.conn.Close(websocket.StatusNormalClosure, "closing")
+	if c.conn != nil {
+		_ = c.conn.Close(websocket.StatusPolicyViolation, reason)
+	}
+	c.cancel()
+	c.wg.Wait()
+	c.unsubscribeAll()
 }
 
 // CloseWithoutUnsubscribe terminates the connection without broker cleanup.
 // Used during broker shutdown to avoid deadlock.
 func (c *Client) CloseWithoutUnsubscribe() {
-	c.mu.Lock()
-	select {
-	case <-c.done:
-		c.mu.Unlock()
+	if !c.markClosed() {
 		return
-	default:
-		close(c.done)
 	}
+	c.mu.Lock()
 	c.subscriptions = make(map[string]*Subscription)
 	c.mu.Unlock()
 
@@ -110,6 +134,28 @@ func (c *Client) CloseWithoutUnsubscribe() {
 	c.conn.Close(websocket.StatusGoingAway, "server shutting down")
 }
 
+// markClosed closes done, reporting false if the client was already closed.
+func (c *Client) markClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.done:
+		return false
+	default:
+		close(c.done)
+		return true
+	}
+}
+
+func (c *Client) unsubscribeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, sub := range c.subscriptions {
+		c.broker.Unsubscribe(sub.ID)
+	}
+	c.subscriptions = make(map[string]*Subscription)
+}
+
 // Send queues a message to be sent to the client.
 func (c *Client) Send(msg *Message) error {
 	data, err := json.Marshal(msg)
@@ -119,13 +165,26 @@ func (c *Client) Send(msg *Message) error {
 
 	select {
 	case c.sendCh <- data:
+		c.delivered.Add(1)
+		c.fullSince.Store(0)
 		return nil
 	case <-c.done:
 		return context.Canceled
 	default:
-		log.Warn().Str("client_id", c.ID).Msg("Client send buffer full, dropping message")
-		return nil
 	}
+
+	// Send never blocks, so one slow client cannot stall the broadcast
+	// loop. A client whose buffer stays full is evicted instead.
+	c.dropped.Add(1)
+	now := time.Now().UnixNano()
+	if !c.fullSince.CompareAndSwap(0, now) {
+		if time.Duration(now-c.fullSince.Load()) >= c.broker.slowClientTimeout {
+			c.broker.evict(c, EvictionSlowConsumer)
+			return nil
+		}
+	}
+	log.Warn().Str("client_id", c.ID).Msg("Client send buffer full, dropping message")
+	return nil
 }
 
 // SendError sends an error message to the client.
@@ -209,11 +268,17 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case data := <-c.sendCh:
-			ctx, cancel := context.WithTimeout(c.ctx, writeTimeout)
+			ctx, cancel := context.WithTimeout(c.ctx, c.broker.writeTimeout)
 			err := c.conn.Write(ctx, websocket.MessageText, data)
+			timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
 			cancel()
 			if err != nil {
 				log.Debug().Err(err).Str("client_id", c.ID).Msg("WebSocket write error")
+				if timedOut {
+					c.broker.evict(c, EvictionWriteTimeout)
+				} else {
+					c.cancel()
+				}
 				return
 			}
 		case <-c.done:
@@ -224,19 +289,36 @@ func (c *Client) writePump() {
 	}
 }
 
+// pingPump pings the client every ping interval, waiting up to the next
+// ping for the pong. The client is evicted after maxMissedPongs pings in a
+// row go unanswered.
 func (c *Client) pingPump() {
-	ticker := time.NewTicker(pingInterval)
+	interval := c.broker.pingInterval
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	missed := 0
 	for {
 		select {
 		case <-ticker.C:
-			ctx, cancel := context.WithTimeout(c.ctx, pongTimeout)
+			ctx, cancel := context.WithTimeout(c.ctx, interval)
 			err := c.conn.Ping(ctx)
+			timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
 			cancel()
-			if err != nil {
+			if err == nil {
+				missed = 0
+				continue
+			}
+			if !timedOut {
+				// The connection is closed or closing; readPump cleans up.
 				log.Debug().Err(err).Str("client_id", c.ID).Msg("Ping failed")
-				c.Close()
+				c.cancel()
+				return
+			}
+			missed++
+			log.Debug().Str("client_id", c.ID).Int("missed", missed).Msg("Pong not received")
+			if missed >= c.broker.maxMissedPongs {
+				c.broker.evict(c, EvictionMissedPongs)
 				return
 			}
 		case <-c.done:
@@ -267,15 +349,33 @@ func (c *Client) handleSubscribe(msg *Message) {
 		return
 	}
 
+	if payload.Channel != "" {
+		c.handleChannelSubscribe(msg.ID, payload.Channel)
+		return
+	}
+
 	if payload.Collection == "" {
 		_ = c.SendError(msg.ID, ErrorCodeInvalidPayload, "Collection is required")
 		return
 	}
 
+	if payload.IncludeSnapshot && payload.Since != nil {
+		_ = c.SendError(msg.ID, ErrorCodeInvalidPayload, "include_snapshot and since are mutually exclusive")
+		return
+	}
+
 	sub := NewSubscription(c.ID, &payload, c.AuthContext)
 	sub.ID = uuid.New().String()
 
 	snapshot, err := c.broker.Subscribe(c, sub)
+	if errors.Is(err, ErrCursorExpired) {
+		_ = c.SendError(msg.ID, ErrorCodeCursorExpired, "Cursor is too old; resubscribe with include_snapshot")
+		return
+	}
+	if errors.Is(err, ErrCollectionNotFound) {
+		_ = c.SendError(msg.ID, ErrorCodeCollectionNotFound, "Collection not found")
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).
 			Str("client_id", c.ID).
@@ -284,18 +384,78 @@ func (c *Client) handleSubscribe(msg *Message) {
 		_ = c.SendError(msg.ID, ErrorCodeInternalError, err.Error())
 		return
 	}
+	defer c.broker.Activate(c, sub)
+
+	if !payload.IncludeSnapshot && payload.Since == nil {
+		snapshotPayload, _ := json.Marshal(&SnapshotPayload{
+			SubscriptionID: sub.ID,
+			Docs:           snapshot.Docs,
+			Total:          snapshot.Total,
+			Cursor:         snapshot.Cursor,
+		})
+
+		_ = c.Send(&Message{
+			ID:      msg.ID,
+			Type:    MessageTypeSnapshot,
+			Payload: snapshotPayload,
+		})
+		return
+	}
 
-	snapshotPayload, _ := json.Marshal(&SnapshotPayload{
+	// Streamed mode: acknowledge, send the current documents as inserts or
+	// the catch-up delta, then mark the subscription synced. Live deltas
+	// queued meanwhile follow once the subscription is activated.
+	cursorPayload, _ := json.Marshal(&SubscriptionCursorPayload{
 		SubscriptionID: sub.ID,
-		Docs:           snapshot.Docs,
-		Total:          snapshot.Total,
+		Cursor:         snapshot.Cursor,
 	})
+	_ = c.Send(&Message{ID: msg.ID, Type: MessageTypeSubscribed, Payload: cursorPayload})
 
-	_ = c.Send(&Message{
-		ID:      msg.ID,
-		Type:    MessageTypeSnapshot,
-		Payload: snapshotPayload,
+	if payload.IncludeSnapshot {
+		for start := 0; start < len(snapshot.Docs); start += snapshotChunkSize {
+			end := min(start+snapshotChunkSize, len(snapshot.Docs))
+			c.sendSyncDelta(sub.ID, &Changes{Inserts: snapshot.Docs[start:end]}, snapshot.Cursor)
+		}
+	} else if snapshot.Replay != nil && !snapshot.Replay.IsEmpty() {
+		c.sendSyncDelta(sub.ID, snapshot.Replay, snapshot.Cursor)
+	}
+
+	_ = c.Send(&Message{ID: msg.ID, Type: MessageTypeSynced, Payload: cursorPayload})
+}
+
+func (c *Client) handleChannelSubscribe(msgID, channel string) {
+	authorize, ok := channelAuthorizers[channel]
+	if !ok {
+		_ = c.SendError(msgID, ErrorCodeInvalidPayload, "Unknown channel")
+		return
+	}
+	if !authorize(c.AuthContext) {
+		_ = c.SendError(msgID, ErrorCodeForbidden, "Not allowed to subscribe to this channel")
+		return
+	}
+
+	c.mu.Lock()
+	c.channels[channel] = true
+	c.mu.Unlock()
+
+	payload, _ := json.Marshal(&ChannelPayload{Channel: channel})
+	_ = c.Send(&Message{ID: msgID, Type: MessageTypeSubscribed, Payload: payload})
+}
+
+// subscribedTo reports whether the client receives messages on channel.
+func (c *Client) subscribedTo(channel string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.channels[channel]
+}
+
+func (c *Client) sendSyncDelta(subID string, changes *Changes, cursor int64) {
+	payload, _ := json.Marshal(&DeltaPayload{
+		SubscriptionID: subID,
+		Changes:        *changes,
+		Cursor:         cursor,
 	})
+	_ = c.Send(&Message{Type: MessageTypeDelta, Payload: payload})
 }
 
 func (c *Client) handleUnsubscribe(msg *Message) {
@@ -305,6 +465,13 @@ func (c *Client) handleUnsubscribe(msg *Message) {
 		return
 	}
 
+	if payload.Channel != "" {
+		c.mu.Lock()
+		delete(c.channels, payload.Channel)
+		c.mu.Unlock()
+		return
+	}
+
 	if payload.SubscriptionID == "" {
 		_ = c.SendError(msg.ID, ErrorCodeInvalidPayload, "Subscription ID is required")
 		return
@@ -8,14 +8,37 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/tracing"
 )
 
-// ChangeDetector polls the _alyx_changes table for new changes.
+// DetectionMode selects how the detector learns about new changes.
+type DetectionMode string
+
+const (
+	// DetectionAuto uses commit hooks when the database supports them and
+	// polling otherwise.
+	DetectionAuto DetectionMode = "auto"
+	// DetectionPoll always polls at the configured interval.
+	DetectionPoll DetectionMode = "poll"
+	// DetectionHook reads changes as soon as a commit records them.
+	DetectionHook DetectionMode = "hook"
+)
+
+// hookSafetyInterval is how often the detector polls in hook mode, to pick
+// up changes written by other processes sharing the database file.
+const hookSafetyInterval = time.Second
+
+// ChangeDetector reads new rows from the _alyx_changes table, either when a
+// commit hook reports them or by polling.
 type ChangeDetector struct {
 	db           *database.DB
 	pollInterval time.Duration
+	mode         DetectionMode
+	activeMode   DetectionMode
 	changeCh     chan<- *Change
 	lastID       int64
 	done         chan struct{}
@@ -28,21 +51,62 @@ func NewChangeDetector(db *database.DB, pollIntervalMs int64, changeCh chan<- *C
 	return &ChangeDetector{
 		db:           db,
 		pollInterval: time.Duration(pollIntervalMs) * time.Millisecond,
+		mode:         DetectionAuto,
 		changeCh:     changeCh,
 		done:         make(chan struct{}),
 	}
 }
 
-// Start begins polling for changes.
+// SetMode sets the detection mode. It must be called before Start.
+func (d *ChangeDetector) SetMode(mode DetectionMode) {
+	if mode == "" {
+		mode = DetectionAuto
+	}
+	d.mode = mode
+}
+
+// Mode returns the mode in effect once the detector has started: poll or hook.
+func (d *ChangeDetector) Mode() DetectionMode {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.activeMode
+}
+
+// Start begins watching for changes and blocks until Stop is called or ctx
+// is cancelled.
 func (d *ChangeDetector) Start(ctx context.Context) {
 	d.wg.Add(1)
 	defer d.wg.Done()
 
-	ticker := time.NewTicker(d.pollInterval)
+	var notify <-chan struct{}
+	interval := d.pollInterval
+	active := DetectionPoll
+
+	if d.mode != DetectionPoll {
+		ch, cancel, ok := d.db.SubscribeChanges()
+		defer cancel()
+		switch {
+		case ok:
+			notify = ch
+			active = DetectionHook
+			interval = max(interval, hookSafetyInterval)
+		case d.mode == DetectionHook:
+			log.Warn().Msg("Realtime commit hooks are not available for this database, falling back to polling")
+		}
+	}
+
+	d.mu.Lock()
+	d.activeMode = active
+	d.mu.Unlock()
+	log.Debug().Str("mode", string(active)).Dur("poll_interval", interval).Msg("Realtime change detector started")
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-notify:
+			d.poll(ctx)
 		case <-ticker.C:
 			d.poll(ctx)
 		case <-d.done:
@@ -64,12 +128,24 @@ func (d *ChangeDetector) poll(ctx context.Context) {
 	lastID := d.lastID
 	d.mu.Unlock()
 
+	start := time.Now()
 	changes, maxID, err := d.fetchChanges(ctx, lastID)
 	if err != nil {
 		return
 	}
 
 	if len(changes) > 0 {
+		// Empty polls run every few milliseconds, so only polls that found
+		// changes are traced; the span is backdated to cover the fetch.
+		_, span := tracing.Start(ctx, "realtime.poll",
+			trace.WithTimestamp(start),
+			trace.WithAttributes(
+				attribute.Int("realtime.changes", len(changes)),
+				attribute.Int64("realtime.last_id", maxID),
+			),
+		)
+		defer span.End()
+
 		d.updateLastID(maxID)
 		d.broadcastChanges(ctx, changes)
 		d.markProcessed(ctx, maxID)
@@ -187,10 +263,15 @@ func (d *ChangeDetector) markProcessed(ctx context.Context, maxID int64) {
 	}
 }
 
-// CleanupOldChanges removes old processed changes.
+// CleanupOldChanges removes old processed changes. The newest change is
+// always kept so change IDs, which subscription cursors refer to, keep
+// increasing instead of restarting from an empty table.
 func (d *ChangeDetector) CleanupOldChanges(ctx context.Context, olderThan time.Duration) error {
-	cutoff := time.Now().Add(-olderThan).Format(time.RFC3339)
-	query := `DELETE FROM _alyx_changes WHERE processed = 1 AND timestamp < ?`
+	// Match the format of datetime('now') used by the change triggers.
+	cutoff := time.Now().UTC().Add(-olderThan).Format("2006-01-02 15:04:05")
+	query := `DELETE FROM _alyx_changes
+		WHERE processed = 1 AND timestamp < ?
+		AND id < (SELECT MAX(id) FROM _alyx_changes)`
 	_, err := d.db.ExecContext(ctx, query, cutoff)
 	return err
 }
@@ -8,4 +8,5 @@ var (
 	ErrInvalidFilter       = errors.New("invalid filter")
 	ErrSubscriptionExists  = errors.New("subscription already exists")
 	ErrSubscriptionMissing = errors.New("subscription not found")
+	ErrCursorExpired       = errors.New("cursor is older than the retained change history; resync and subscribe again")
 )
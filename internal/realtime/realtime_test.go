@@ -3,6 +3,8 @@ package realtime
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -10,9 +12,12 @@ import (
 	"time"
 
 	"github.com/coder/websocket"
+	"github.com/google/uuid"
 
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/events"
+	"github.com/watzon/alyx/internal/rules"
 	"github.com/watzon/alyx/internal/schema"
 )
 
@@ -115,6 +120,25 @@ func TestSubscriptionMatching(t *testing.T) {
 	}
 }
 
+func TestFilterMatchingNocase(t *testing.T) {
+	col := &schema.Collection{Fields: map[string]*schema.Field{
+		"email": {Name: "email", Type: schema.FieldTypeEmail, Collate: schema.CollationNocase},
+		"name":  {Name: "name", Type: schema.FieldTypeString},
+	}}
+	b := &Broker{}
+	doc := database.Row{"email": "Ada@Example.com", "name": "Ada"}
+
+	if !b.matchesFilter(col, doc, map[string]Filter{"email": {Eq: "ada@example.com"}}) {
+		t.Error("expected $eq on a nocase field to ignore case")
+	}
+	if !b.matchesFilter(col, doc, map[string]Filter{"email": {In: []any{"x@example.com", "ADA@EXAMPLE.COM"}}}) {
+		t.Error("expected $in on a nocase field to ignore case")
+	}
+	if b.matchesFilter(col, doc, map[string]Filter{"name": {Eq: "ada"}}) {
+		t.Error("expected $eq on a binary field to respect case")
+	}
+}
+
 func TestNewSubscription(t *testing.T) {
 	payload := &SubscribePayload{
 		Collection: "posts",
@@ -356,3 +380,789 @@ func TestWebSocketHandshake(t *testing.T) {
 		t.Errorf("Expected connected message, got %s", msg.Type)
 	}
 }
+
+func TestBrokerHookDelivery(t *testing.T) {
+	db := testDB(t)
+	s := testSchema(t)
+	setupTestDB(t, db, s)
+
+	// A poll interval far longer than the deadline below means only the
+	// commit hook can deliver the change in time.
+	pollInterval := 5 * time.Second
+	broker := NewBroker(db, s, nil, &BrokerConfig{
+		PollInterval:   pollInterval.Milliseconds(),
+		Mode:           DetectionHook,
+		MaxConnections: 100,
+		BufferSize:     100,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker.Start(ctx)
+	defer broker.Stop()
+
+	client := NewClient(nil, broker)
+	broker.RegisterClient(client)
+	defer broker.UnregisterClient(client.ID)
+	sub := NewSubscription(client.ID, &SubscribePayload{Collection: "posts"}, nil)
+	if _, err := broker.Subscribe(client, sub); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	broker.Activate(client, sub)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for broker.detector.Mode() == "" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if mode := broker.detector.Mode(); mode != DetectionHook {
+		t.Fatalf("Expected hook mode, got %q", mode)
+	}
+
+	start := time.Now()
+	if _, err := db.Exec(`INSERT INTO posts (id, title, author_id) VALUES ('p1', 'Hello', 'u1')`); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	select {
+	case data := <-client.sendCh:
+		elapsed := time.Since(start)
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to unmarshal message: %v", err)
+		}
+		if msg.Type != MessageTypeDelta {
+			t.Fatalf("Expected delta message, got %s", msg.Type)
+		}
+		if elapsed > pollInterval/10 {
+			t.Errorf("Expected delivery well under the poll interval, took %s", elapsed)
+		}
+	case <-time.After(pollInterval / 2):
+		t.Fatal("Insert was not delivered before the poll interval")
+	}
+}
+
+func TestChangeDetectorPollMode(t *testing.T) {
+	db := testDB(t)
+
+	detector := NewChangeDetector(db, 20, make(chan *Change, 1))
+	detector.SetMode(DetectionPoll)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go detector.Start(ctx)
+	defer detector.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for detector.Mode() == "" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if mode := detector.Mode(); mode != DetectionPoll {
+		t.Errorf("Expected poll mode, got %q", mode)
+	}
+}
+
+func TestCursorUnmarshal(t *testing.T) {
+	var c Cursor
+	if err := json.Unmarshal([]byte(`42`), &c); err != nil || c.ID != 42 {
+		t.Errorf("Expected ID 42 from number, got %+v (%v)", c, err)
+	}
+	if err := json.Unmarshal([]byte(`"17"`), &c); err != nil || c.ID != 17 {
+		t.Errorf("Expected ID 17 from string, got %+v (%v)", c, err)
+	}
+	if err := json.Unmarshal([]byte(`"2024-05-01T10:00:00Z"`), &c); err != nil || c.Time.IsZero() || c.ID != 0 {
+		t.Errorf("Expected timestamp cursor, got %+v (%v)", c, err)
+	}
+	if err := json.Unmarshal([]byte(`"yesterday"`), &c); err == nil {
+		t.Error("Expected error for invalid cursor")
+	}
+}
+
+func subscribeBroker(t *testing.T) (*Broker, *Client, *database.DB) {
+	t.Helper()
+	db := testDB(t)
+	s := testSchema(t)
+	setupTestDB(t, db, s)
+
+	broker := NewBroker(db, s, nil, &BrokerConfig{MaxConnections: 100, BufferSize: 100})
+	client := NewClient(nil, broker)
+	broker.RegisterClient(client)
+	t.Cleanup(func() { broker.UnregisterClient(client.ID) })
+	return broker, client, db
+}
+
+func mustExec(t *testing.T, db *database.DB, query string) {
+	t.Helper()
+	if _, err := db.Exec(query); err != nil {
+		t.Fatalf("Exec %q failed: %v", query, err)
+	}
+}
+
+func readMessage(t *testing.T, client *Client) *Message {
+	t.Helper()
+	select {
+	case data := <-client.sendCh:
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to unmarshal message: %v", err)
+		}
+		return &msg
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message")
+		return nil
+	}
+}
+
+func TestSubscribeSinceReplay(t *testing.T) {
+	broker, client, db := subscribeBroker(t)
+
+	mustExec(t, db, `INSERT INTO posts (id, title, author_id) VALUES ('p1', 'One', 'u1')`)
+	mustExec(t, db, `INSERT INTO posts (id, title, author_id) VALUES ('p2', 'Two', 'u1')`)
+
+	first := NewSubscription(client.ID, &SubscribePayload{Collection: "posts"}, nil)
+	first.ID = "first"
+	snapshot, err := broker.Subscribe(client, first)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if snapshot.Cursor == 0 || len(snapshot.Docs) != 2 {
+		t.Fatalf("Expected 2 docs and a cursor, got %d docs at cursor %d", len(snapshot.Docs), snapshot.Cursor)
+	}
+
+	mustExec(t, db, `INSERT INTO posts (id, title, author_id) VALUES ('p3', 'Three', 'u2')`)
+	mustExec(t, db, `UPDATE posts SET title = 'Three again' WHERE id = 'p3'`)
+	mustExec(t, db, `UPDATE posts SET title = 'One again' WHERE id = 'p1'`)
+	mustExec(t, db, `DELETE FROM posts WHERE id = 'p2'`)
+
+	sub := NewSubscription(client.ID, &SubscribePayload{
+		Collection: "posts",
+		Since:      &Cursor{ID: snapshot.Cursor},
+	}, nil)
+	sub.ID = "resumed"
+	resumed, err := broker.Subscribe(client, sub)
+	if err != nil {
+		t.Fatalf("Subscribe with since failed: %v", err)
+	}
+
+	replay := resumed.Replay
+	if replay == nil {
+		t.Fatal("Expected a replay delta")
+	}
+	if len(replay.Inserts) != 1 || replay.Inserts[0].(database.Row)["id"] != "p3" {
+		t.Errorf("Expected p3 inserted, got %v", replay.Inserts)
+	}
+	if len(replay.Updates) != 1 || replay.Updates[0].(database.Row)["title"] != "One again" {
+		t.Errorf("Expected p1 updated, got %v", replay.Updates)
+	}
+	if len(replay.Deletes) != 1 || replay.Deletes[0] != "p2" {
+		t.Errorf("Expected p2 deleted, got %v", replay.Deletes)
+	}
+	if resumed.Cursor <= snapshot.Cursor {
+		t.Errorf("Expected cursor to advance past %d, got %d", snapshot.Cursor, resumed.Cursor)
+	}
+	if _, ok := sub.DocIDs["p3"]; !ok {
+		t.Error("Expected replayed insert to be tracked")
+	}
+}
+
+func TestSubscribeCursorExpired(t *testing.T) {
+	broker, client, db := subscribeBroker(t)
+
+	for _, id := range []string{"p1", "p2", "p3"} {
+		mustExec(t, db, `INSERT INTO posts (id, title, author_id) VALUES ('`+id+`', 'Post', 'u1')`)
+	}
+
+	sub := NewSubscription(client.ID, &SubscribePayload{Collection: "posts", Since: &Cursor{ID: 1000}}, nil)
+	sub.ID = uuid.New().String()
+	if _, err := broker.Subscribe(client, sub); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("Expected ErrCursorExpired for a cursor past the head, got %v", err)
+	}
+
+	mustExec(t, db, `UPDATE _alyx_changes SET processed = 1, timestamp = '2000-01-01 00:00:00'`)
+	if err := broker.detector.CleanupOldChanges(context.Background(), time.Hour); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+
+	sub = NewSubscription(client.ID, &SubscribePayload{Collection: "posts", Since: &Cursor{ID: 1}}, nil)
+	sub.ID = uuid.New().String()
+	if _, err := broker.Subscribe(client, sub); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("Expected ErrCursorExpired for a pruned cursor, got %v", err)
+	}
+	if len(client.Subscriptions()) != 0 {
+		t.Errorf("Expected failed subscriptions to be removed, got %d", len(client.Subscriptions()))
+	}
+
+	// The newest change survives cleanup, so a cursor at the head still works.
+	var head int64
+	if err := db.QueryRow(`SELECT MAX(id) FROM _alyx_changes`).Scan(&head); err != nil || head != 3 {
+		t.Fatalf("Expected newest change to be kept, got %d (%v)", head, err)
+	}
+	sub = NewSubscription(client.ID, &SubscribePayload{Collection: "posts", Since: &Cursor{ID: head}}, nil)
+	sub.ID = uuid.New().String()
+	if _, err := broker.Subscribe(client, sub); err != nil {
+		t.Errorf("Expected cursor at head to resume, got %v", err)
+	}
+}
+
+func TestHandleSubscribeIncludeSnapshot(t *testing.T) {
+	broker, client, db := subscribeBroker(t)
+
+	mustExec(t, db, `INSERT INTO posts (id, title, author_id) VALUES ('p1', 'One', 'u1')`)
+	mustExec(t, db, `INSERT INTO posts (id, title, author_id) VALUES ('p2', 'Two', 'u1')`)
+
+	payload, _ := json.Marshal(&SubscribePayload{Collection: "posts", IncludeSnapshot: true})
+	client.handleSubscribe(&Message{ID: "1", Type: MessageTypeSubscribe, Payload: payload})
+
+	msg := readMessage(t, client)
+	if msg.Type != MessageTypeSubscribed {
+		t.Fatalf("Expected subscribed, got %s", msg.Type)
+	}
+	var ack SubscriptionCursorPayload
+	_ = json.Unmarshal(msg.Payload, &ack)
+
+	msg = readMessage(t, client)
+	var delta DeltaPayload
+	_ = json.Unmarshal(msg.Payload, &delta)
+	if msg.Type != MessageTypeDelta || len(delta.Changes.Inserts) != 2 {
+		t.Fatalf("Expected snapshot as 2 inserts, got %s with %d inserts", msg.Type, len(delta.Changes.Inserts))
+	}
+
+	if msg = readMessage(t, client); msg.Type != MessageTypeSynced {
+		t.Fatalf("Expected synced, got %s", msg.Type)
+	}
+
+	// Changes broadcast before activation are delivered after the snapshot.
+	sub := client.GetSubscription(ack.SubscriptionID)
+	if sub == nil {
+		t.Fatal("Expected subscription to be registered")
+	}
+	sub.syncMu.Lock()
+	sub.syncing = true
+	sub.syncMu.Unlock()
+
+	mustExec(t, db, `INSERT INTO posts (id, title, author_id) VALUES ('p3', 'Three', 'u1')`)
+	broker.broadcastChange(&Change{ID: ack.Cursor + 1, Collection: "posts", Operation: OperationInsert, DocID: "p3"})
+	select {
+	case <-client.sendCh:
+		t.Fatal("Expected change to be queued while syncing")
+	default:
+	}
+
+	broker.Activate(client, sub)
+	msg = readMessage(t, client)
+	delta = DeltaPayload{}
+	_ = json.Unmarshal(msg.Payload, &delta)
+	if len(delta.Changes.Inserts) != 1 || delta.Cursor != ack.Cursor+1 {
+		t.Errorf("Expected queued insert at cursor %d, got %+v", ack.Cursor+1, delta)
+	}
+
+	payload, _ = json.Marshal(map[string]any{"collection": "posts", "include_snapshot": true, "since": 1})
+	client.handleSubscribe(&Message{ID: "2", Type: MessageTypeSubscribe, Payload: payload})
+	if msg = readMessage(t, client); msg.Type != MessageTypeError {
+		t.Errorf("Expected error for include_snapshot with since, got %s", msg.Type)
+	}
+}
+
+func TestSubscribeUnexposedCollection(t *testing.T) {
+	broker, client, _ := subscribeBroker(t)
+	expose := false
+	broker.schema.Collections["posts"].Expose = &expose
+
+	payload, _ := json.Marshal(&SubscribePayload{Collection: "posts"})
+	client.handleSubscribe(&Message{ID: "1", Type: MessageTypeSubscribe, Payload: payload})
+	msg := readMessage(t, client)
+	var errPayload ErrorPayload
+	_ = json.Unmarshal(msg.Payload, &errPayload)
+	if msg.Type != MessageTypeError || errPayload.Code != string(ErrorCodeCollectionNotFound) {
+		t.Fatalf("Expected COLLECTION_NOT_FOUND, got %s %+v", msg.Type, errPayload)
+	}
+	if len(client.Subscriptions()) != 0 {
+		t.Errorf("Expected no subscription, got %d", len(client.Subscriptions()))
+	}
+
+	sub := NewSubscription(client.ID, &SubscribePayload{Collection: "posts"}, map[string]any{"id": "u1", "role": schema.RoleAdmin})
+	sub.ID = uuid.New().String()
+	if _, err := broker.Subscribe(client, sub); err != nil {
+		t.Errorf("Expected admins to subscribe to an unexposed collection, got %v", err)
+	}
+}
+
+func TestSystemChannel(t *testing.T) {
+	broker, client, _ := subscribeBroker(t)
+	other := NewClient(nil, broker)
+	broker.RegisterClient(other)
+	t.Cleanup(func() { broker.UnregisterClient(other.ID) })
+
+	payload, _ := json.Marshal(&SubscribePayload{Channel: ChannelSystem})
+	client.handleSubscribe(&Message{ID: "1", Type: MessageTypeSubscribe, Payload: payload})
+	msg := readMessage(t, client)
+	var ack ChannelPayload
+	_ = json.Unmarshal(msg.Payload, &ack)
+	if msg.Type != MessageTypeSubscribed || msg.ID != "1" || ack.Channel != ChannelSystem {
+		t.Fatalf("Expected subscribed to the system channel, got %s %s", msg.Type, msg.Payload)
+	}
+
+	broker.BroadcastSystem(&SystemPayload{Action: SystemActionSchemaChanged, Collections: []string{"posts"}})
+	msg = readMessage(t, client)
+	var event SystemPayload
+	_ = json.Unmarshal(msg.Payload, &event)
+	if msg.Type != MessageTypeSystem || event.Action != SystemActionSchemaChanged || len(event.Collections) != 1 || event.Collections[0] != "posts" {
+		t.Errorf("Expected schema_changed for posts, got %s %s", msg.Type, msg.Payload)
+	}
+	select {
+	case <-other.sendCh:
+		t.Error("Expected client without a system subscription to get nothing")
+	default:
+	}
+
+	payload, _ = json.Marshal(&UnsubscribePayload{Channel: ChannelSystem})
+	client.handleUnsubscribe(&Message{Type: MessageTypeUnsubscribe, Payload: payload})
+	broker.BroadcastSystem(&SystemPayload{Action: SystemActionSchemaChanged, Collections: []string{}})
+	select {
+	case <-client.sendCh:
+		t.Error("Expected nothing after unsubscribing")
+	default:
+	}
+
+	payload, _ = json.Marshal(&SubscribePayload{Channel: "metrics"})
+	client.handleSubscribe(&Message{ID: "2", Type: MessageTypeSubscribe, Payload: payload})
+	if msg = readMessage(t, client); msg.Type != MessageTypeError {
+		t.Errorf("Expected error for an unknown channel, got %s", msg.Type)
+	}
+}
+
+func TestPrivilegedChannels(t *testing.T) {
+	broker, client, _ := subscribeBroker(t)
+	admin := NewClient(nil, broker)
+	admin.AuthContext = map[string]any{"id": "a1", "role": schema.RoleAdmin}
+	broker.RegisterClient(admin)
+	t.Cleanup(func() { broker.UnregisterClient(admin.ID) })
+
+	client.AuthContext = map[string]any{"id": "u1", "role": schema.RoleUser}
+	payload, _ := json.Marshal(&SubscribePayload{Channel: events.ChannelAuth})
+	client.handleSubscribe(&Message{ID: "1", Type: MessageTypeSubscribe, Payload: payload})
+	msg := readMessage(t, client)
+	var errPayload ErrorPayload
+	_ = json.Unmarshal(msg.Payload, &errPayload)
+	if msg.Type != MessageTypeError || errPayload.Code != string(ErrorCodeForbidden) {
+		t.Fatalf("Expected FORBIDDEN for a non-admin, got %s %s", msg.Type, msg.Payload)
+	}
+
+	for _, channel := range []string{events.ChannelAuth, events.ChannelAdmin} {
+		payload, _ = json.Marshal(&SubscribePayload{Channel: channel})
+		admin.handleSubscribe(&Message{ID: channel, Type: MessageTypeSubscribe, Payload: payload})
+		if msg = readMessage(t, admin); msg.Type != MessageTypeSubscribed {
+			t.Fatalf("Expected an admin to subscribe to %s, got %s %s", channel, msg.Type, msg.Payload)
+		}
+	}
+
+	broker.Publish(events.ChannelAuth, events.UserRegistered, map[string]any{"id": "u2"})
+	msg = readMessage(t, admin)
+	var event struct {
+		Channel string         `json:"channel"`
+		Event   events.Type    `json:"event"`
+		Data    map[string]any `json:"data"`
+	}
+	_ = json.Unmarshal(msg.Payload, &event)
+	if msg.Type != MessageTypeEvent || event.Channel != events.ChannelAuth || event.Event != events.UserRegistered || event.Data["id"] != "u2" {
+		t.Errorf("Expected user.registered, got %s %s", msg.Type, msg.Payload)
+	}
+	select {
+	case <-client.sendCh:
+		t.Error("Expected the non-admin client to get nothing")
+	default:
+	}
+
+	payload, _ = json.Marshal(&UnsubscribePayload{Channel: events.ChannelAdmin})
+	admin.handleUnsubscribe(&Message{Type: MessageTypeUnsubscribe, Payload: payload})
+	broker.Publish(events.ChannelAdmin, events.ConfigUpdated, map[string]any{})
+	select {
+	case <-admin.sendCh:
+		t.Error("Expected nothing on _admin after unsubscribing")
+	default:
+	}
+}
+
+func TestBrokerConnectionsAndSubscriptions(t *testing.T) {
+	broker, client, _ := subscribeBroker(t)
+	client.UserID = "u1"
+	client.RemoteAddr = "127.0.0.1:5000"
+
+	for _, collection := range []string{"posts", "comments"} {
+		sub := NewSubscription(client.ID, &SubscribePayload{
+			Collection: collection,
+			Filter:     map[string]Filter{"author_id": {Eq: "u1"}},
+		}, nil)
+		sub.ID = collection
+		broker.mu.Lock()
+		broker.subscriptions[sub.ID] = sub
+		broker.mu.Unlock()
+		if err := client.AddSubscription(sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_ = client.Send(&Message{Type: MessageTypePong})
+	for range sendBufferSize {
+		_ = client.Send(&Message{Type: MessageTypePong})
+	}
+
+	conns := broker.Connections()
+	if len(conns) != 1 {
+		t.Fatalf("Expected 1 connection, got %d", len(conns))
+	}
+	conn := conns[0]
+	if conn.UserID != "u1" || conn.RemoteAddr != "127.0.0.1:5000" || conn.Subscriptions != 2 {
+		t.Errorf("Unexpected connection info: %+v", conn)
+	}
+	if conn.Delivered != sendBufferSize || conn.Dropped != 1 {
+		t.Errorf("Expected %d delivered and 1 dropped, got %d and %d", sendBufferSize, conn.Delivered, conn.Dropped)
+	}
+
+	subs := broker.Subscriptions("posts")
+	if len(subs) != 1 || subs[0].ID != "posts" || subs[0].UserID != "u1" || subs[0].Filter["author_id"].Eq != "u1" {
+		t.Errorf("Expected filtered posts subscription, got %+v", subs)
+	}
+	if all := broker.Subscriptions(""); len(all) != 2 {
+		t.Errorf("Expected 2 subscriptions, got %d", len(all))
+	}
+
+	if broker.Disconnect("missing", "bye") {
+		t.Error("Expected unknown connection to report false")
+	}
+	if !broker.Disconnect(client.ID, "bye") {
+		t.Fatal("Expected connection to be disconnected")
+	}
+	if broker.ClientCount() != 0 || broker.SubscriptionCount() != 0 {
+		t.Errorf("Expected client and subscriptions to be removed, got %d and %d", broker.ClientCount(), broker.SubscriptionCount())
+	}
+}
+
+func TestBrokerDisconnectSendsCloseReason(t *testing.T) {
+	db := testDB(t)
+	s := testSchema(t)
+	setupTestDB(t, db, s)
+	broker := NewBroker(db, s, nil, &BrokerConfig{MaxConnections: 100, BufferSize: 100})
+
+	registered := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(conn, broker)
+		broker.RegisterClient(client)
+		defer broker.UnregisterClient(client.ID)
+		registered <- client.ID
+		client.Run()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.CloseNow()
+
+	id := <-registered
+	go broker.Disconnect(id, "too many subscriptions")
+
+	_, _, err = conn.Read(ctx)
+	var closeErr websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("Expected close frame, got %v", err)
+	}
+	if closeErr.Code != websocket.StatusPolicyViolation || closeErr.Reason != "too many subscriptions" {
+		t.Errorf("Expected policy violation with reason, got %d %q", closeErr.Code, closeErr.Reason)
+	}
+}
+
+// serveClients runs broker clients behind a test WebSocket server, sending
+// each server-side client on the returned channel once it is registered.
+func serveClients(t *testing.T, broker *Broker) (string, <-chan *Client) {
+	t.Helper()
+	clients := make(chan *Client, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(conn, broker)
+		broker.RegisterClient(client)
+		defer broker.UnregisterClient(client.ID)
+		clients <- client
+		client.Run()
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http"), clients
+}
+
+func dialClient(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	return dialClientWith(t, url, nil)
+}
+
+// dialSlowReader dials a client with a small socket receive buffer, so the
+// server's writes back up as soon as it stops reading.
+func dialSlowReader(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			if err := conn.(*net.TCPConn).SetReadBuffer(4096); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		},
+	}
+	return dialClientWith(t, url, &websocket.DialOptions{HTTPClient: &http.Client{Transport: transport}})
+}
+
+func dialClientWith(t *testing.T, url string, opts *websocket.DialOptions) *websocket.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, url, opts)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	conn.SetReadLimit(-1)
+	t.Cleanup(func() { conn.CloseNow() })
+	return conn
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSendEvictsSlowConsumer(t *testing.T) {
+	broker := NewBroker(nil, testSchema(t), nil, &BrokerConfig{SlowClientTimeout: 50 * time.Millisecond})
+	slow := NewClient(nil, broker)
+	fast := NewClient(nil, broker)
+	broker.RegisterClient(slow)
+	broker.RegisterClient(fast)
+	t.Cleanup(func() { broker.UnregisterClient(fast.ID) })
+
+	msg := &Message{Type: MessageTypePong}
+	for range sendBufferSize + 1 {
+		_ = slow.Send(msg)
+	}
+	if slow.dropped.Load() != 1 || slow.evicting.Load() {
+		t.Fatalf("Full buffer: dropped = %d, evicting = %v; want 1, false", slow.dropped.Load(), slow.evicting.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	_ = slow.Send(msg)
+	if !slow.evicting.Load() {
+		t.Fatal("Client whose buffer stayed full was not evicted")
+	}
+	waitFor(t, "slow client to be unregistered", func() bool { return broker.getClient(slow.ID) == nil })
+
+	if err := fast.Send(msg); err != nil {
+		t.Fatalf("Send to fast client: %v", err)
+	}
+	readMessage(t, fast)
+	if fast.evicting.Load() || broker.getClient(fast.ID) == nil {
+		t.Error("Fast client was evicted")
+	}
+	if got := broker.Stats().Evictions; got != 1 {
+		t.Errorf("Evictions = %d, want 1", got)
+	}
+}
+
+func TestMissedPongsEvictClient(t *testing.T) {
+	broker := NewBroker(nil, testSchema(t), nil, &BrokerConfig{
+		PingInterval:   50 * time.Millisecond,
+		MaxMissedPongs: 2,
+	})
+	url, clients := serveClients(t, broker)
+
+	// Pongs are sent while reading, so a client that never reads misses
+	// every ping.
+	dialClient(t, url)
+	silent := <-clients
+
+	responsive := dialClient(t, url)
+	alive := <-clients
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		for {
+			if _, _, err := responsive.Read(ctx); err != nil {
+				return
+			}
+		}
+	}()
+
+	waitFor(t, "silent client to be evicted", silent.evicting.Load)
+	if alive.evicting.Load() {
+		t.Error("Client answering pings was evicted")
+	}
+	if got := broker.Stats().Evictions; got != 1 {
+		t.Errorf("Evictions = %d, want 1", got)
+	}
+}
+
+// TestSlowReaderIsolation broadcasts to several reading clients and one that
+// never reads. The slow reader must be evicted without delaying the others.
+func TestSlowReaderIsolation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("load test")
+	}
+
+	const (
+		fastReaders = 5
+		messages    = 1000
+	)
+
+	broker := NewBroker(nil, testSchema(t), nil, &BrokerConfig{
+		PingInterval:      time.Hour,
+		WriteTimeout:      200 * time.Millisecond,
+		SlowClientTimeout: 200 * time.Millisecond,
+	})
+	url, clients := serveClients(t, broker)
+
+	dialSlowReader(t, url)
+	slow := <-clients
+
+	received := make([]chan int, fastReaders)
+	fast := make([]*Client, fastReaders)
+	for i := range fastReaders {
+		conn := dialClient(t, url)
+		fast[i] = <-clients
+		received[i] = make(chan int, 1)
+		go func(done chan<- int) {
+			n := 0
+			defer func() { done <- n }()
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			for n < messages {
+				if _, _, err := conn.Read(ctx); err != nil {
+					return
+				}
+				n++
+			}
+		}(received[i])
+	}
+
+	payload, _ := json.Marshal(strings.Repeat("x", 16*1024))
+	msg := &Message{Type: MessageTypeSystem, Payload: payload}
+
+	var slowest time.Duration
+	for range messages {
+		start := time.Now()
+		_ = slow.Send(msg)
+		for _, c := range fast {
+			if err := c.Send(msg); err != nil {
+				t.Fatalf("Send to fast client: %v", err)
+			}
+		}
+		slowest = max(slowest, time.Since(start))
+		time.Sleep(time.Millisecond)
+	}
+
+	if slowest > 100*time.Millisecond {
+		t.Errorf("A broadcast round took %v; the slow reader blocked the loop", slowest)
+	}
+	waitFor(t, "slow reader to be evicted", slow.evicting.Load)
+
+	for i, done := range received {
+		select {
+		case n := <-done:
+			if n != messages {
+				t.Errorf("Fast reader %d received %d of %d messages", i, n, messages)
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatalf("Fast reader %d timed out", i)
+		}
+		if fast[i].evicting.Load() || fast[i].dropped.Load() != 0 {
+			t.Errorf("Fast reader %d: evicting = %v, dropped = %d", i, fast[i].evicting.Load(), fast[i].dropped.Load())
+		}
+	}
+}
+
+func TestFieldReadRuleDelivery(t *testing.T) {
+	db := testDB(t)
+	s := testSchema(t)
+	s.Collections["posts"].Fields["author_id"].ReadRule = "auth.id == doc.author_id"
+	setupTestDB(t, db, s)
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatalf("LoadSchema failed: %v", err)
+	}
+	broker := NewBroker(db, s, engine, &BrokerConfig{MaxConnections: 100, BufferSize: 100})
+
+	mustExec(t, db, `INSERT INTO posts (id, title, author_id) VALUES ('p1', 'One', 'u1')`)
+
+	subscribe := func(userID string) (*Client, *SubscriptionSnapshot) {
+		t.Helper()
+		client := NewClient(nil, broker)
+		broker.RegisterClient(client)
+		t.Cleanup(func() { broker.UnregisterClient(client.ID) })
+		sub := NewSubscription(client.ID, &SubscribePayload{Collection: "posts"}, map[string]any{"id": userID})
+		sub.ID = "sub-" + userID
+		snapshot, err := broker.Subscribe(client, sub)
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		broker.Activate(client, sub)
+		return client, snapshot
+	}
+	owner, ownerSnapshot := subscribe("u1")
+	other, otherSnapshot := subscribe("u2")
+
+	if _, ok := ownerSnapshot.Docs[0].(database.Row)["author_id"]; !ok {
+		t.Error("Expected the author to see author_id in the snapshot")
+	}
+	if _, ok := otherSnapshot.Docs[0].(database.Row)["author_id"]; ok {
+		t.Error("Expected author_id to be omitted from another user's snapshot")
+	}
+
+	mustExec(t, db, `INSERT INTO posts (id, title, author_id) VALUES ('p2', 'Two', 'u1')`)
+	broker.broadcastChange(&Change{ID: ownerSnapshot.Cursor + 1, Collection: "posts", Operation: OperationInsert, DocID: "p2"})
+
+	for _, tt := range []struct {
+		name   string
+		client *Client
+		want   bool
+	}{
+		{"author", owner, true},
+		{"other user", other, false},
+	} {
+		msg := readMessage(t, tt.client)
+		var delta struct {
+			Changes struct {
+				Inserts []map[string]any `json:"inserts"`
+			} `json:"changes"`
+		}
+		if err := json.Unmarshal(msg.Payload, &delta); err != nil {
+			t.Fatalf("%s: unmarshal delta: %v", tt.name, err)
+		}
+		if len(delta.Changes.Inserts) != 1 {
+			t.Fatalf("%s: expected one insert, got %s", tt.name, msg.Payload)
+		}
+		doc := delta.Changes.Inserts[0]
+		if _, ok := doc["author_id"]; ok != tt.want {
+			t.Errorf("%s: author_id included = %v, want %v", tt.name, ok, tt.want)
+		}
+		if doc["title"] != "Two" {
+			t.Errorf("%s: expected the rest of the document, got %v", tt.name, doc)
+		}
+	}
+}
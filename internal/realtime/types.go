@@ -3,7 +3,12 @@ package realtime
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/watzon/alyx/internal/events"
 )
 
 // MessageType represents the type of WebSocket message.
@@ -14,11 +19,28 @@ const (
 	MessageTypeUnsubscribe MessageType = "unsubscribe"
 	MessageTypePing        MessageType = "ping"
 
-	MessageTypeConnected MessageType = "connected"
-	MessageTypeSnapshot  MessageType = "snapshot"
-	MessageTypeDelta     MessageType = "delta"
-	MessageTypeError     MessageType = "error"
-	MessageTypePong      MessageType = "pong"
+	MessageTypeConnected  MessageType = "connected"
+	MessageTypeSnapshot   MessageType = "snapshot"
+	MessageTypeSubscribed MessageType = "subscribed"
+	MessageTypeSynced     MessageType = "synced"
+	MessageTypeDelta      MessageType = "delta"
+	MessageTypeError      MessageType = "error"
+	MessageTypePong       MessageType = "pong"
+	MessageTypeSystem     MessageType = "system"
+	MessageTypeEvent      MessageType = "event"
+)
+
+// ChannelSystem is the channel a client subscribes to, instead of a
+// collection, to receive system messages.
+const ChannelSystem = "system"
+
+// SystemAction identifies the server event a system message reports.
+type SystemAction string
+
+const (
+	// SystemActionSchemaChanged is sent after a schema change has been
+	// applied, from the admin API or a deploy.
+	SystemActionSchemaChanged SystemAction = "schema_changed"
 )
 
 // Operation represents a database change operation.
@@ -39,11 +61,63 @@ type Message struct {
 
 // SubscribePayload is the payload for subscribe messages.
 type SubscribePayload struct {
+	// Channel is set to ChannelSystem or a privileged channel, without a
+	// collection, to receive its messages. The other fields do not apply
+	// to it.
+	Channel string `json:"channel,omitempty"`
+
 	Collection string            `json:"collection"`
 	Filter     map[string]Filter `json:"filter,omitempty"`
 	Sort       []string          `json:"sort,omitempty"`
 	Limit      int               `json:"limit,omitempty"`
 	Expand     []string          `json:"expand,omitempty"`
+
+	// IncludeSnapshot streams the current matching documents as insert
+	// deltas, instead of a single snapshot message, before live changes.
+	IncludeSnapshot bool `json:"include_snapshot,omitempty"`
+
+	// Since replays changes made after this cursor before live changes.
+	Since *Cursor `json:"since,omitempty"`
+}
+
+// Cursor is a position in the change feed: either a change ID taken from a
+// previous message's cursor, or a timestamp meaning "changes after this time".
+type Cursor struct {
+	ID   int64
+	Time time.Time
+}
+
+// UnmarshalJSON accepts a change ID as a number or numeric string, or an
+// RFC 3339 timestamp.
+func (c *Cursor) UnmarshalJSON(data []byte) error {
+	var id int64
+	if err := json.Unmarshal(data, &id); err == nil {
+		*c = Cursor{ID: id}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("cursor must be a change ID or timestamp")
+	}
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*c = Cursor{ID: id}
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return fmt.Errorf("cursor must be a change ID or RFC 3339 timestamp")
+	}
+	*c = Cursor{Time: t}
+	return nil
+}
+
+// MarshalJSON encodes the cursor the way it was given.
+func (c Cursor) MarshalJSON() ([]byte, error) {
+	if !c.Time.IsZero() {
+		return json.Marshal(c.Time.Format(time.RFC3339))
+	}
+	return json.Marshal(c.ID)
 }
 
 // Filter represents a filter condition.
@@ -62,6 +136,8 @@ type Filter struct {
 // UnsubscribePayload is the payload for unsubscribe messages.
 type UnsubscribePayload struct {
 	SubscriptionID string `json:"subscription_id"`
+	// Channel unsubscribes from a channel instead of a subscription.
+	Channel string `json:"channel,omitempty"`
 }
 
 // ConnectedPayload is the payload for connected messages.
@@ -74,12 +150,46 @@ type SnapshotPayload struct {
 	SubscriptionID string `json:"subscription_id"`
 	Docs           []any  `json:"docs"`
 	Total          int64  `json:"total"`
+	// Cursor is the last change reflected in Docs.
+	Cursor int64 `json:"cursor"`
+}
+
+// SubscriptionCursorPayload is the payload for subscribed and synced
+// messages, which bracket the catch-up deltas of a subscription created with
+// include_snapshot or since.
+type SubscriptionCursorPayload struct {
+	SubscriptionID string `json:"subscription_id"`
+	Cursor         int64  `json:"cursor"`
+}
+
+// ChannelPayload is the payload of the subscribed message acknowledging a
+// channel subscription.
+type ChannelPayload struct {
+	Channel string `json:"channel"`
+}
+
+// SystemPayload is the payload for system messages, which report server
+// events that are not tied to a subscription.
+type SystemPayload struct {
+	Action SystemAction `json:"action"`
+	// Collections names the collections the event affects.
+	Collections []string `json:"collections"`
+}
+
+// EventPayload is the payload for event messages, sent on the privileged
+// channels. Data depends on the event; see the events package.
+type EventPayload struct {
+	Channel string      `json:"channel"`
+	Event   events.Type `json:"event"`
+	Data    any         `json:"data"`
 }
 
 // DeltaPayload is the payload for delta messages.
 type DeltaPayload struct {
 	SubscriptionID string  `json:"subscription_id"`
 	Changes        Changes `json:"changes"`
+	// Cursor is the last change included; pass it as since to resume.
+	Cursor int64 `json:"cursor,omitempty"`
 }
 
 // Changes represents the set of changes in a delta.
@@ -130,7 +240,20 @@ type Subscription struct {
 	// AuthContext stores the authenticated user context for rule evaluation.
 	AuthContext map[string]any `json:"-"`
 
+	// IncludeSnapshot and Since are the catch-up options from the subscribe
+	// message.
+	IncludeSnapshot bool    `json:"-"`
+	Since           *Cursor `json:"-"`
+
 	DocIDs map[string]struct{} `json:"-"`
+
+	// syncMu guards DocIDs and the catch-up state. Until the subscription is
+	// activated, live changes are queued in pending so they are delivered
+	// after the snapshot or replay.
+	syncMu  sync.Mutex
+	syncing bool
+	cursor  int64
+	pending []*Change
 }
 
 // NewSubscription creates a new subscription from a subscribe payload.
@@ -144,16 +267,18 @@ func NewSubscription(clientID string, payload *SubscribePayload, authContext map
 	}
 
 	return &Subscription{
-		ClientID:    clientID,
-		Collection:  payload.Collection,
-		Filter:      payload.Filter,
-		Sort:        payload.Sort,
-		Limit:       limit,
-		Expand:      payload.Expand,
-		State:       SubscriptionStateActive,
-		CreatedAt:   time.Now(),
-		AuthContext: authContext,
-		DocIDs:      make(map[string]struct{}),
+		ClientID:        clientID,
+		Collection:      payload.Collection,
+		Filter:          payload.Filter,
+		Sort:            payload.Sort,
+		Limit:           limit,
+		Expand:          payload.Expand,
+		State:           SubscriptionStateActive,
+		CreatedAt:       time.Now(),
+		AuthContext:     authContext,
+		IncludeSnapshot: payload.IncludeSnapshot,
+		Since:           payload.Since,
+		DocIDs:          make(map[string]struct{}),
 	}
 }
 
@@ -176,8 +301,10 @@ type ErrorCode string
 const (
 	ErrorCodeInvalidMessage     ErrorCode = "INVALID_MESSAGE"
 	ErrorCodeInvalidPayload     ErrorCode = "INVALID_PAYLOAD"
+	ErrorCodeForbidden          ErrorCode = "FORBIDDEN"
 	ErrorCodeCollectionNotFound ErrorCode = "COLLECTION_NOT_FOUND"
 	ErrorCodeInvalidFilter      ErrorCode = "INVALID_FILTER"
 	ErrorCodeSubscriptionLimit  ErrorCode = "SUBSCRIPTION_LIMIT_REACHED"
+	ErrorCodeCursorExpired      ErrorCode = "CURSOR_EXPIRED"
 	ErrorCodeInternalError      ErrorCode = "INTERNAL_ERROR"
 )
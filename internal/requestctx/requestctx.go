@@ -2,20 +2,36 @@ package requestctx
 
 import (
 	"context"
+	"regexp"
 	"time"
 )
 
 type contextKey string
 
 const (
-	requestIDKey   contextKey = "request_id"
-	requestTimeKey contextKey = "request_time"
+	requestIDKey       contextKey = "request_id"
+	parentRequestIDKey contextKey = "parent_request_id"
+	requestTimeKey     contextKey = "request_time"
 )
 
+// requestIDPattern limits client-supplied request IDs to safe, log-friendly values.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// ValidRequestID reports whether id is acceptable as an incoming request ID.
+func ValidRequestID(id string) bool {
+	return requestIDPattern.MatchString(id)
+}
+
 func WithRequestID(ctx context.Context, id string) context.Context {
 	return context.WithValue(ctx, requestIDKey, id)
 }
 
+// WithParentRequestID records the request that caused this one, e.g. the HTTP
+// request whose database hook invoked a function that is now calling back.
+func WithParentRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, parentRequestIDKey, id)
+}
+
 func WithRequestTime(ctx context.Context, t time.Time) context.Context {
 	return context.WithValue(ctx, requestTimeKey, t)
 }
@@ -27,6 +43,13 @@ func RequestID(ctx context.Context) string {
 	return ""
 }
 
+func ParentRequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(parentRequestIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
 func RequestTime(ctx context.Context) time.Time {
 	if t, ok := ctx.Value(requestTimeKey).(time.Time); ok {
 		return t
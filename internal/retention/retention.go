@@ -0,0 +1,333 @@
+// Package retention prunes collection rows according to schema retention policies.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/metrics"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// Service runs retention policies in the background.
+//
+// Rows are deleted directly with SQL in batched transactions, so database
+// hooks and file cascades are not triggered for pruned rows. Realtime
+// subscribers still receive delete events unless SuppressRealtime is set.
+type Service struct {
+	db     *database.DB
+	cfg    *config.RetentionConfig
+	schema *schema.Schema
+
+	mu   sync.RWMutex
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Preview describes what a retention run would delete for one collection.
+type Preview struct {
+	Collection   string     `json:"collection"`
+	Field        string     `json:"field"`
+	MaxAge       string     `json:"max_age,omitempty"`
+	MaxRows      int64      `json:"max_rows,omitempty"`
+	Cutoff       *time.Time `json:"cutoff,omitempty"`
+	Total        int64      `json:"total"`
+	ExpiredByAge int64      `json:"expired_by_age"`
+	ExcessRows   int64      `json:"excess_rows"`
+	WouldDelete  int64      `json:"would_delete"`
+}
+
+// NewService creates a new retention service.
+func NewService(db *database.DB, s *schema.Schema, cfg *config.RetentionConfig) *Service {
+	if cfg == nil {
+		cfg = &config.RetentionConfig{
+			Enabled:    true,
+			Interval:   config.DefaultRetentionInterval,
+			BatchSize:  config.DefaultRetentionBatchSize,
+			BatchSleep: config.DefaultRetentionBatchSleep,
+		}
+	}
+
+	return &Service{
+		db:     db,
+		cfg:    cfg,
+		schema: s,
+		done:   make(chan struct{}),
+	}
+}
+
+// UpdateSchema replaces the schema used to look up retention policies.
+func (s *Service) UpdateSchema(sch *schema.Schema) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schema = sch
+}
+
+// Start begins the background retention loop.
+func (s *Service) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go s.loop(ctx)
+
+	log.Info().
+		Dur("interval", s.cfg.Interval).
+		Int("batch_size", s.cfg.BatchSize).
+		Msg("Retention service started")
+}
+
+// Stop halts the background retention loop and waits for it to finish.
+func (s *Service) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+func (s *Service) loop(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := s.RunOnce(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("Retention run failed")
+			}
+			for collection, count := range pruned {
+				if count > 0 {
+					log.Info().
+						Str("collection", collection).
+						Int64("deleted", count).
+						Msg("Pruned expired rows")
+				}
+			}
+		}
+	}
+}
+
+// RunOnce applies every retention policy once and returns rows deleted per collection.
+func (s *Service) RunOnce(ctx context.Context) (map[string]int64, error) {
+	pruned := make(map[string]int64)
+
+	for _, col := range s.collections() {
+		count, err := s.pruneCollection(ctx, col)
+		if count > 0 {
+			pruned[col.Name] = count
+			metrics.RecordRetentionPruned(col.Name, count)
+		}
+		if err != nil {
+			return pruned, fmt.Errorf("pruning %s: %w", col.Name, err)
+		}
+	}
+
+	return pruned, nil
+}
+
+// Preview reports what RunOnce would delete without modifying any data.
+func (s *Service) Preview(ctx context.Context) ([]*Preview, error) {
+	cols := s.collections()
+	previews := make([]*Preview, 0, len(cols))
+
+	for _, col := range cols {
+		p, err := s.previewCollection(ctx, col)
+		if err != nil {
+			return nil, fmt.Errorf("previewing %s: %w", col.Name, err)
+		}
+		previews = append(previews, p)
+	}
+
+	return previews, nil
+}
+
+// collections returns the collections with a retention policy, including
+// the history tables of collections whose history has one.
+func (s *Service) collections() []*schema.Collection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.schema == nil {
+		return nil
+	}
+
+	var cols []*schema.Collection
+	for _, col := range s.schema.Collections {
+		if col.Retention != nil && col.PrimaryKeyField() != nil {
+			cols = append(cols, col)
+		}
+		if col.History != nil && col.History.Retention != nil {
+			cols = append(cols, col.HistoryCollection())
+		}
+	}
+	sort.Slice(cols, func(i, j int) bool { return cols[i].Name < cols[j].Name })
+	return cols
+}
+
+func (s *Service) previewCollection(ctx context.Context, col *schema.Collection) (*Preview, error) {
+	policy := col.Retention
+	p := &Preview{
+		Collection: col.Name,
+		Field:      policy.Field,
+		MaxAge:     policy.MaxAge,
+		MaxRows:    policy.MaxRows,
+	}
+
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+col.Name).Scan(&p.Total); err != nil {
+		return nil, fmt.Errorf("counting rows: %w", err)
+	}
+
+	if maxAge := policy.MaxAgeDuration(); maxAge > 0 {
+		cutoff := time.Now().UTC().Add(-maxAge)
+		p.Cutoff = &cutoff
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", col.Name, expiredCondition(policy.Field))
+		if err := s.db.QueryRowContext(ctx, query, formatCutoff(cutoff)).Scan(&p.ExpiredByAge); err != nil {
+			return nil, fmt.Errorf("counting expired rows: %w", err)
+		}
+	}
+
+	if policy.MaxRows > 0 {
+		if remaining := p.Total - p.ExpiredByAge; remaining > policy.MaxRows {
+			p.ExcessRows = remaining - policy.MaxRows
+		}
+	}
+
+	p.WouldDelete = p.ExpiredByAge + p.ExcessRows
+	return p, nil
+}
+
+func (s *Service) pruneCollection(ctx context.Context, col *schema.Collection) (int64, error) {
+	policy := col.Retention
+	pk := col.PrimaryKeyField()
+	var total int64
+
+	if maxAge := policy.MaxAgeDuration(); maxAge > 0 {
+		cutoff := formatCutoff(time.Now().UTC().Add(-maxAge))
+		query := fmt.Sprintf(
+			"DELETE FROM %[1]s WHERE %[2]s IN (SELECT %[2]s FROM %[1]s WHERE %[3]s ORDER BY %[4]s LIMIT ?)",
+			col.Name, pk.Name, expiredCondition(policy.Field), policy.Field,
+		)
+
+		for {
+			n, err := s.deleteBatch(ctx, col.Name, query, cutoff, s.cfg.BatchSize)
+			total += n
+			if err != nil {
+				return total, err
+			}
+			if n < int64(s.cfg.BatchSize) {
+				break
+			}
+			if !s.pause(ctx) {
+				return total, ctx.Err()
+			}
+		}
+	}
+
+	if policy.MaxRows > 0 {
+		query := fmt.Sprintf(
+			"DELETE FROM %[1]s WHERE %[2]s IN (SELECT %[2]s FROM %[1]s ORDER BY %[3]s ASC, %[2]s ASC LIMIT ?)",
+			col.Name, pk.Name, policy.Field,
+		)
+
+		for {
+			var count int64
+			if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+col.Name).Scan(&count); err != nil {
+				return total, fmt.Errorf("counting rows: %w", err)
+			}
+
+			excess := count - policy.MaxRows
+			if excess <= 0 {
+				break
+			}
+
+			n, err := s.deleteBatch(ctx, col.Name, query, min(excess, int64(s.cfg.BatchSize)))
+			total += n
+			if err != nil {
+				return total, err
+			}
+			if n == 0 || excess <= int64(s.cfg.BatchSize) {
+				break
+			}
+			if !s.pause(ctx) {
+				return total, ctx.Err()
+			}
+		}
+	}
+
+	return total, nil
+}
+
+// deleteBatch runs a single delete statement in its own transaction. When
+// realtime suppression is enabled, the change records written by the delete
+// triggers are removed in the same transaction so subscribers never see them.
+func (s *Service) deleteBatch(ctx context.Context, collection, query string, args ...any) (int64, error) {
+	var affected int64
+
+	err := s.db.Transaction(ctx, func(tx *database.Tx) error {
+		var lastChangeID int64
+		if s.cfg.SuppressRealtime {
+			if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM _alyx_changes").Scan(&lastChangeID); err != nil {
+				return fmt.Errorf("reading change cursor: %w", err)
+			}
+		}
+
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("deleting rows: %w", err)
+		}
+		affected, _ = result.RowsAffected()
+
+		if s.cfg.SuppressRealtime && affected > 0 {
+			if _, err := tx.ExecContext(ctx,
+				"DELETE FROM _alyx_changes WHERE id > ? AND collection = ? AND operation = 'DELETE'",
+				lastChangeID, collection,
+			); err != nil {
+				return fmt.Errorf("suppressing change events: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return affected, nil
+}
+
+func (s *Service) pause(ctx context.Context) bool {
+	if s.cfg.BatchSleep <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(s.cfg.BatchSleep)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.done:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// expiredCondition compares through datetime() because timestamps may be stored
+// either as RFC3339 (application writes) or SQLite's "YYYY-MM-DD HH:MM:SS" (column defaults).
+func expiredCondition(field string) string {
+	return fmt.Sprintf("%s IS NOT NULL AND datetime(%s) < datetime(?)", field, field)
+}
+
+func formatCutoff(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
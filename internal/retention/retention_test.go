@@ -0,0 +1,175 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func testDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.Open(&config.DatabaseConfig{Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testSchema(t *testing.T, retention string) *schema.Schema {
+	t.Helper()
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  audit_logs:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      name:
+        type: string
+      created_at:
+        type: timestamp
+        default: now
+    retention:
+` + retention))
+	if err != nil {
+		t.Fatalf("Failed to parse test schema: %v", err)
+	}
+	return s
+}
+
+func setup(t *testing.T, s *schema.Schema, cfg *config.RetentionConfig) (*Service, *database.DB) {
+	t.Helper()
+	db := testDB(t)
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to execute SQL: %v\nSQL: %s", err, stmt)
+		}
+	}
+	return NewService(db, s, cfg), db
+}
+
+func insertLog(t *testing.T, db *database.DB, id string, age time.Duration) {
+	t.Helper()
+	createdAt := time.Now().UTC().Add(-age).Format(time.RFC3339)
+	if _, err := db.Exec("INSERT INTO audit_logs (id, name, created_at) VALUES (?, ?, ?)", id, id, createdAt); err != nil {
+		t.Fatalf("Failed to insert log: %v", err)
+	}
+}
+
+func countRows(t *testing.T, db *database.DB, query string, args ...any) int64 {
+	t.Helper()
+	var n int64
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	return n
+}
+
+func TestRunOnce_MaxAge(t *testing.T) {
+	s := testSchema(t, "      field: created_at\n      max_age: 7d\n")
+	svc, db := setup(t, s, &config.RetentionConfig{Enabled: true, Interval: time.Hour, BatchSize: 2})
+
+	for i := 0; i < 5; i++ {
+		insertLog(t, db, fmt.Sprintf("old-%d", i), 10*24*time.Hour)
+	}
+	insertLog(t, db, "new-1", time.Hour)
+	insertLog(t, db, "new-2", 6*24*time.Hour)
+
+	pruned, err := svc.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if pruned["audit_logs"] != 5 {
+		t.Errorf("Expected 5 pruned rows, got %d", pruned["audit_logs"])
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM audit_logs"); n != 2 {
+		t.Errorf("Expected 2 remaining rows, got %d", n)
+	}
+}
+
+func TestRunOnce_MaxRows(t *testing.T) {
+	s := testSchema(t, "      field: created_at\n      max_rows: 3\n")
+	svc, db := setup(t, s, &config.RetentionConfig{Enabled: true, Interval: time.Hour, BatchSize: 2})
+
+	for i := 0; i < 7; i++ {
+		insertLog(t, db, fmt.Sprintf("e-%d", i), time.Duration(i)*time.Hour)
+	}
+
+	pruned, err := svc.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if pruned["audit_logs"] != 4 {
+		t.Errorf("Expected 4 pruned rows, got %d", pruned["audit_logs"])
+	}
+
+	// The three newest rows are kept.
+	for _, id := range []string{"e-0", "e-1", "e-2"} {
+		if n := countRows(t, db, "SELECT COUNT(*) FROM audit_logs WHERE id = ?", id); n != 1 {
+			t.Errorf("Expected %s to be kept", id)
+		}
+	}
+}
+
+func TestRunOnce_SuppressRealtime(t *testing.T) {
+	s := testSchema(t, "      field: created_at\n      max_age: 1d\n")
+
+	for _, suppress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("suppress=%v", suppress), func(t *testing.T) {
+			svc, db := setup(t, s, &config.RetentionConfig{
+				Enabled:          true,
+				Interval:         time.Hour,
+				BatchSize:        10,
+				SuppressRealtime: suppress,
+			})
+			insertLog(t, db, "old", 48*time.Hour)
+
+			if _, err := svc.RunOnce(context.Background()); err != nil {
+				t.Fatalf("RunOnce failed: %v", err)
+			}
+
+			deletes := countRows(t, db, "SELECT COUNT(*) FROM _alyx_changes WHERE collection = 'audit_logs' AND operation = 'DELETE'")
+			want := int64(1)
+			if suppress {
+				want = 0
+			}
+			if deletes != want {
+				t.Errorf("Expected %d delete change records, got %d", want, deletes)
+			}
+		})
+	}
+}
+
+func TestPreview(t *testing.T) {
+	s := testSchema(t, "      field: created_at\n      max_age: 7d\n      max_rows: 2\n")
+	svc, db := setup(t, s, nil)
+
+	insertLog(t, db, "old", 30*24*time.Hour)
+	insertLog(t, db, "a", time.Hour)
+	insertLog(t, db, "b", 2*time.Hour)
+	insertLog(t, db, "c", 3*time.Hour)
+
+	previews, err := svc.Preview(context.Background())
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if len(previews) != 1 {
+		t.Fatalf("Expected 1 preview, got %d", len(previews))
+	}
+
+	p := previews[0]
+	if p.Total != 4 || p.ExpiredByAge != 1 || p.ExcessRows != 1 || p.WouldDelete != 2 {
+		t.Errorf("Unexpected preview: %+v", p)
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM audit_logs"); n != 4 {
+		t.Errorf("Preview should not delete rows, got %d remaining", n)
+	}
+}
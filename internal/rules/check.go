@@ -0,0 +1,87 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// Check compiles every rule expression in s with the runtime CEL
+// environment: collection, field, bucket and view rules and function invoke
+// rules. It returns every expression that fails to compile at once, as
+// schema.ValidationErrors, or nil.
+func Check(s *schema.Schema) error {
+	e, err := NewEngine()
+	if err != nil {
+		return err
+	}
+	return e.check(s)
+}
+
+func (e *Engine) check(s *schema.Schema) error {
+	var errs schema.ValidationErrors
+	compile := func(path, expr string) {
+		if expr == "" {
+			return
+		}
+		if _, _, err := e.compile(expr); err != nil {
+			errs = append(errs, &schema.ValidationError{Path: path, Message: err.Error(), Err: err})
+		}
+	}
+
+	for _, name := range sortedNames(s.Collections) {
+		col := s.Collections[name]
+		path := "collections." + name
+		for _, field := range col.ReadRuleFields() {
+			compile(fmt.Sprintf("%s.fields.%s.readRule", path, field.Name), field.ReadRule)
+		}
+		if col.Rules != nil {
+			compile(path+".rules.create", col.Rules.Create)
+			compile(path+".rules.read", col.Rules.Read)
+			compile(path+".rules.update", col.Rules.Update)
+			compile(path+".rules.delete", col.Rules.Delete)
+			compile(path+".rules.history", col.Rules.History)
+		}
+	}
+
+	for _, name := range sortedNames(s.Buckets) {
+		bucket := s.Buckets[name]
+		if bucket.Rules == nil {
+			continue
+		}
+		path := "buckets." + name + ".rules"
+		compile(path+".create", bucket.Rules.Create)
+		compile(path+".read", bucket.Rules.Read)
+		compile(path+".update", bucket.Rules.Update)
+		compile(path+".delete", bucket.Rules.Delete)
+		compile(path+".download", bucket.Rules.Download)
+		compile(path+".upload", bucket.Rules.Upload)
+	}
+
+	for _, name := range sortedNames(s.Views) {
+		if view := s.Views[name]; view.Rules != nil {
+			compile("views."+name+".rules.read", view.Rules.Read)
+		}
+	}
+
+	for _, name := range sortedNames(s.Functions) {
+		if fn := s.Functions[name]; fn.Rules != nil {
+			compile("functions."+name+".rules.invoke", fn.Rules.Invoke)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
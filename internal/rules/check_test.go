@@ -0,0 +1,66 @@
+package rules
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func TestCheck(t *testing.T) {
+	s := &schema.Schema{
+		Collections: map[string]*schema.Collection{
+			"posts": {
+				Name: "posts",
+				Fields: map[string]*schema.Field{
+					"secret": {Name: "secret", Type: schema.FieldTypeString, ReadRule: "user.role == 'admin'"},
+				},
+				Rules: &schema.Rules{Read: "true", Update: "doc.author_id == auth.id &&"},
+			},
+		},
+		Buckets: map[string]*schema.Bucket{
+			"uploads": {Name: "uploads", Rules: &schema.Rules{Download: "nope"}},
+		},
+		Functions: map[string]*schema.Function{
+			"publish": {Name: "publish", Rules: &schema.FunctionRules{Invoke: "auth.role = 'admin'"}},
+		},
+	}
+
+	err := Check(s)
+	errs := schema.AsValidationErrors(err)
+	want := []string{
+		"collections.posts.fields.secret.readRule",
+		"collections.posts.rules.update",
+		"buckets.uploads.rules.download",
+		"functions.publish.rules.invoke",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), err)
+	}
+	for i, path := range want {
+		if errs[i].Path != path {
+			t.Errorf("error %d: expected path %s, got %s", i, path, errs[i].Path)
+		}
+	}
+	if !errors.Is(err, ErrInvalidRuleExpr) {
+		t.Errorf("expected ErrInvalidRuleExpr, got %v", err)
+	}
+
+	// A failed load keeps the rules already loaded.
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	valid := &schema.Schema{Collections: map[string]*schema.Collection{
+		"posts": {Name: "posts", Rules: &schema.Rules{Read: "false"}},
+	}}
+	if err := engine.LoadSchema(valid); err != nil {
+		t.Fatalf("LoadSchema failed: %v", err)
+	}
+	if err := engine.LoadSchema(s); err == nil {
+		t.Fatal("expected LoadSchema to fail")
+	}
+	if allowed, _ := engine.Evaluate("posts", OpRead, &EvalContext{}); allowed {
+		t.Error("expected the previous read rule to stay in place")
+	}
+}
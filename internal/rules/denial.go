@@ -0,0 +1,156 @@
+package rules
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+)
+
+// RedactedValue replaces sensitive values in a Denial.
+const RedactedValue = "[redacted]"
+
+// ruleVars are the variables a rule can reference.
+var ruleVars = map[string]bool{"auth": true, "doc": true, "file": true, "request": true, "tenant": true}
+
+// sensitiveNames are substrings of field names whose values are never
+// included in a Denial.
+var sensitiveNames = []string{"password", "secret", "token", "hash", "api_key", "apikey", "private_key"}
+
+// Denial describes a rule that denied access. It wraps ErrAccessDenied, so
+// callers can keep testing with errors.Is.
+type Denial struct {
+	Collection string    `json:"collection"`
+	Operation  Operation `json:"operation"`
+	Rule       string    `json:"rule"`
+	// References lists the variables the rule reads, e.g. auth.id.
+	References []string `json:"references,omitempty"`
+	// Values holds what each reference evaluated to for the denied request.
+	// Sensitive and internal fields are redacted.
+	Values map[string]any `json:"values,omitempty"`
+}
+
+func (d *Denial) Error() string {
+	return ErrAccessDenied.Error()
+}
+
+func (d *Denial) Unwrap() error {
+	return ErrAccessDenied
+}
+
+// References returns the variable paths the rule for collection and op
+// reads, such as auth.id or doc.owner_id, sorted.
+func (e *Engine) References(collection string, op Operation) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.refs[ruleKey(collection, op)]
+}
+
+// Deny returns the Denial for the rule on collection and op, with the
+// referenced values taken from ctx.
+func (e *Engine) Deny(collection string, op Operation, ctx *EvalContext) *Denial {
+	e.mu.RLock()
+	key := ruleKey(collection, op)
+	denial := &Denial{
+		Collection: collection,
+		Operation:  op,
+		Rule:       e.sources[key],
+		References: e.refs[key],
+	}
+	col := e.collections[collection]
+	e.mu.RUnlock()
+
+	if ctx == nil || len(denial.References) == 0 {
+		return denial
+	}
+
+	vars := map[string]any{
+		"auth":    ctx.Auth,
+		"doc":     ctx.Doc,
+		"file":    ctx.File,
+		"request": ctx.Request,
+		"tenant":  ctx.Tenant,
+	}
+	denial.Values = make(map[string]any, len(denial.References))
+	for _, path := range denial.References {
+		parts := strings.Split(path, ".")
+		if isSensitive(parts) || (parts[0] == "doc" && len(parts) > 1 && col != nil &&
+			col.Fields[parts[1]] != nil && col.Fields[parts[1]].Internal) {
+			denial.Values[path] = RedactedValue
+			continue
+		}
+		denial.Values[path] = lookupPath(vars, parts)
+	}
+	return denial
+}
+
+// referencedPaths returns the outermost select chains rooted at a rule
+// variable, so auth.metadata.plan is reported rather than auth.metadata.
+func referencedPaths(root ast.Expr) []string {
+	inner := make(map[int64]bool)
+	ast.PreOrderVisit(root, ast.NewExprVisitor(func(expr ast.Expr) {
+		if expr.Kind() == ast.SelectKind {
+			inner[expr.AsSelect().Operand().ID()] = true
+		}
+	}))
+
+	seen := make(map[string]bool)
+	ast.PreOrderVisit(root, ast.NewExprVisitor(func(expr ast.Expr) {
+		if inner[expr.ID()] {
+			return
+		}
+		if path, ok := selectPath(expr); ok {
+			seen[path] = true
+		}
+	}))
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// selectPath renders expr as a dotted path if it is a rule variable or a
+// chain of field selections on one.
+func selectPath(expr ast.Expr) (string, bool) {
+	switch expr.Kind() {
+	case ast.IdentKind:
+		name := expr.AsIdent()
+		return name, ruleVars[name]
+	case ast.SelectKind:
+		sel := expr.AsSelect()
+		operand, ok := selectPath(sel.Operand())
+		if !ok {
+			return "", false
+		}
+		return operand + "." + sel.FieldName(), true
+	default:
+		return "", false
+	}
+}
+
+func lookupPath(vars map[string]any, parts []string) any {
+	var value any = vars
+	for _, part := range parts {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+	return value
+}
+
+func isSensitive(parts []string) bool {
+	for _, part := range parts[1:] {
+		lower := strings.ToLower(part)
+		for _, name := range sensitiveNames {
+			if strings.Contains(lower, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@
 package rules
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/watzon/alyx/internal/auth"
 	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/store"
 )
 
 var (
@@ -27,12 +29,27 @@ const (
 	OpUpdate   Operation = "update"
 	OpDelete   Operation = "delete"
 	OpDownload Operation = "download"
+	OpUpload   Operation = "upload"
+	OpHistory  Operation = "history"
 )
 
 type Engine struct {
-	env      *cel.Env
-	programs map[string]cel.Program
-	mu       sync.RWMutex
+	env         *cel.Env
+	programs    map[string]cel.Program
+	asts        map[string]*cel.Ast
+	sources     map[string]string
+	refs        map[string][]string
+	collections map[string]*schema.Collection
+	// fieldRules holds the compiled read rules of fields, by collection.
+	fieldRules map[string][]fieldRule
+	docs       store.DocumentStore
+	mu         sync.RWMutex
+}
+
+// fieldRule is a field's compiled read rule.
+type fieldRule struct {
+	field   string
+	program cel.Program
 }
 
 type EvalContext struct {
@@ -40,30 +57,63 @@ type EvalContext struct {
 	Doc     map[string]any
 	File    map[string]any
 	Request map[string]any
+	// Tenant is the tenant a request to a tenant-scoped collection is
+	// scoped to. It is nil for other collections.
+	Tenant any
+	// Context is the context of the request being checked. The queries
+	// exists() runs use it, so they stop when the request is canceled. It
+	// defaults to context.Background().
+	Context context.Context
 }
 
 func NewEngine() (*Engine, error) {
-	env, err := cel.NewEnv(
-		cel.Variable("auth", cel.MapType(cel.StringType, cel.DynType)),
-		cel.Variable("doc", cel.MapType(cel.StringType, cel.DynType)),
-		cel.Variable("file", cel.MapType(cel.StringType, cel.DynType)),
-		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
-	)
+	e := &Engine{
+		programs: make(map[string]cel.Program),
+		asts:     make(map[string]*cel.Ast),
+		sources:  make(map[string]string),
+		refs:     make(map[string][]string),
+	}
+
+	env, err := newEnv(e)
 	if err != nil {
 		return nil, fmt.Errorf("creating CEL environment: %w", err)
 	}
+	e.env = env
 
-	return &Engine{
-		env:      env,
-		programs: make(map[string]cel.Program),
-	}, nil
+	return e, nil
 }
 
+// SetStore sets the store queried by exists(). Without one, rules that call
+// exists() fail to evaluate.
+func (e *Engine) SetStore(docs store.DocumentStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.docs = docs
+}
+
+// LoadSchema compiles the rules of s and makes them the rules the engine
+// evaluates. If any rule fails to compile, it returns every failure, as
+// Check does, and keeps the previous rules.
 func (e *Engine) LoadSchema(s *schema.Schema) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if err := e.check(s); err != nil {
+		return err
+	}
+
+	e.collections = s.Collections
+	e.fieldRules = make(map[string][]fieldRule)
+
 	for name, col := range s.Collections {
+		for _, field := range col.ReadRuleFields() {
+			program, _, err := e.compile(field.ReadRule)
+			if err != nil {
+				return fmt.Errorf("compiling read rule for %s.%s: %w", name, field.Name, err)
+			}
+			e.fieldRules[name] = append(e.fieldRules[name], fieldRule{field: field.Name, program: program})
+		}
+
 		if col.Rules == nil {
 			continue
 		}
@@ -88,6 +138,11 @@ func (e *Engine) LoadSchema(s *schema.Schema) error {
 				return fmt.Errorf("compiling delete rule for %s: %w", name, err)
 			}
 		}
+		if expr := col.Rules.HistoryRule(); expr != "" {
+			if err := e.compileRule(name, OpHistory, expr); err != nil {
+				return fmt.Errorf("compiling history rule for %s: %w", name, err)
+			}
+		}
 	}
 
 	for name, bucket := range s.Buckets {
@@ -120,25 +175,59 @@ func (e *Engine) LoadSchema(s *schema.Schema) error {
 				return fmt.Errorf("compiling download rule for bucket %s: %w", name, err)
 			}
 		}
+		if expr := bucket.Rules.UploadRule(); expr != "" {
+			if err := e.compileRule(name, OpUpload, expr); err != nil {
+				return fmt.Errorf("compiling upload rule for bucket %s: %w", name, err)
+			}
+		}
+	}
+
+	for name, view := range s.Views {
+		if view.Rules == nil || view.Rules.Read == "" {
+			continue
+		}
+		if err := e.compileRule(ViewTarget(name), OpRead, view.Rules.Read); err != nil {
+			return fmt.Errorf("compiling read rule for view %s: %w", name, err)
+		}
 	}
 
 	return nil
 }
 
+// ViewTarget is the name a view's rules are compiled and evaluated under, so
+// they cannot collide with those of a collection or bucket of the same name.
+func ViewTarget(view string) string {
+	return "view:" + view
+}
+
 func (e *Engine) compileRule(collection string, op Operation, expr string) error {
+	program, ast, err := e.compile(expr)
+	if err != nil {
+		return err
+	}
+
+	key := ruleKey(collection, op)
+	e.programs[key] = program
+	e.asts[key] = ast
+	e.sources[key] = expr
+	e.refs[key] = referencedPaths(ast.NativeRep().Expr())
+	return nil
+}
+
+func (e *Engine) compile(expr string) (cel.Program, *cel.Ast, error) {
 	ast, issues := e.env.Compile(expr)
 	if issues != nil && issues.Err() != nil {
-		return fmt.Errorf("%w: %w", ErrInvalidRuleExpr, issues.Err())
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidRuleExpr, issues.Err())
+	}
+	if err := checkExistsCalls(ast); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidRuleExpr, err)
 	}
 
 	program, err := e.env.Program(ast)
 	if err != nil {
-		return fmt.Errorf("creating program: %w", err)
+		return nil, nil, fmt.Errorf("creating program: %w", err)
 	}
-
-	key := ruleKey(collection, op)
-	e.programs[key] = program
-	return nil
+	return program, ast, nil
 }
 
 func (e *Engine) Evaluate(collection string, op Operation, ctx *EvalContext) (bool, error) {
@@ -151,11 +240,73 @@ func (e *Engine) Evaluate(collection string, op Operation, ctx *EvalContext) (bo
 		return true, nil
 	}
 
+	return run(program, evalVars(ctx))
+}
+
+// HasFieldRules reports whether any field of collection has a read rule.
+func (e *Engine) HasFieldRules(collection string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.fieldRules[collection]) > 0
+}
+
+// RedactFields removes from doc the fields of collection whose read rule
+// does not allow the caller in ctx to see them. The rules see the whole
+// document as doc. A rule that fails to evaluate hides its field.
+func (e *Engine) RedactFields(collection string, ctx *EvalContext, doc map[string]any) {
+	if doc == nil {
+		return
+	}
+	withDoc := *ctx
+	withDoc.Doc = doc
+	for _, field := range e.hiddenFields(collection, &withDoc, doc) {
+		delete(doc, field)
+	}
+}
+
+// HiddenFields returns the fields of collection whose read rule does not
+// allow the caller in ctx, with ctx.Doc as doc, to see them, whether or not
+// ctx.Doc holds them. A rule that fails to evaluate hides its field.
+func (e *Engine) HiddenFields(collection string, ctx *EvalContext) []string {
+	return e.hiddenFields(collection, ctx, nil)
+}
+
+// hiddenFields evaluates the field read rules of collection. With present
+// set, only the fields it holds are checked.
+func (e *Engine) hiddenFields(collection string, ctx *EvalContext, present map[string]any) []string {
+	e.mu.RLock()
+	fieldRules := e.fieldRules[collection]
+	e.mu.RUnlock()
+
+	if len(fieldRules) == 0 {
+		return nil
+	}
+
+	vars := evalVars(ctx)
+	hidden := make([]string, 0, len(fieldRules))
+	for _, rule := range fieldRules {
+		if present != nil {
+			if _, ok := present[rule.field]; !ok {
+				continue
+			}
+		}
+		if allowed, err := run(rule.program, vars); err != nil || !allowed {
+			hidden = append(hidden, rule.field)
+		}
+	}
+	return hidden
+}
+
+// evalVars returns the variables a rule is evaluated with, with an empty
+// map for each one ctx leaves unset.
+func evalVars(ctx *EvalContext) map[string]any {
 	vars := map[string]any{
 		"auth":    ctx.Auth,
 		"doc":     ctx.Doc,
 		"file":    ctx.File,
 		"request": ctx.Request,
+		"tenant":  ctx.Tenant,
+		scopeVar:  &evalScope{ctx: ctx.Context},
 	}
 
 	if vars["auth"] == nil {
@@ -170,7 +321,11 @@ func (e *Engine) Evaluate(collection string, op Operation, ctx *EvalContext) (bo
 	if vars["request"] == nil {
 		vars["request"] = map[string]any{}
 	}
+	return vars
+}
 
+// run evaluates a compiled rule, which must return a boolean.
+func run(program cel.Program, vars map[string]any) (bool, error) {
 	result, _, err := program.Eval(vars)
 	if err != nil {
 		return false, fmt.Errorf("%w: %w", ErrRuleEvaluation, err)
@@ -184,13 +339,15 @@ func (e *Engine) Evaluate(collection string, op Operation, ctx *EvalContext) (bo
 	return allowed, nil
 }
 
+// CheckAccess evaluates the rule for collection and op, returning a *Denial
+// if it does not allow access.
 func (e *Engine) CheckAccess(collection string, op Operation, ctx *EvalContext) error {
 	allowed, err := e.Evaluate(collection, op, ctx)
 	if err != nil {
 		return err
 	}
 	if !allowed {
-		return ErrAccessDenied
+		return e.Deny(collection, op, ctx)
 	}
 	return nil
 }
@@ -0,0 +1,111 @@
+package rules
+
+import (
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func fieldRulesSchema(readRule string) *schema.Schema {
+	profiles := &schema.Collection{
+		Name: "profiles",
+		Fields: map[string]*schema.Field{
+			"id":    {Name: "id", Type: schema.FieldTypeString, Primary: true},
+			"name":  {Name: "name", Type: schema.FieldTypeString},
+			"email": {Name: "email", Type: schema.FieldTypeString, ReadRule: readRule},
+		},
+		Rules: &schema.Rules{Read: "true"},
+	}
+	profiles.SetFieldOrder([]string{"id", "name", "email"})
+	posts := &schema.Collection{
+		Name:   "posts",
+		Fields: map[string]*schema.Field{"id": {Name: "id", Type: schema.FieldTypeString, Primary: true}},
+	}
+	posts.SetFieldOrder([]string{"id"})
+	return &schema.Schema{Collections: map[string]*schema.Collection{"profiles": profiles, "posts": posts}}
+}
+
+func TestEngine_RedactFields(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	if err := engine.LoadSchema(fieldRulesSchema("auth.id == doc.id || auth.role == 'admin'")); err != nil {
+		t.Fatalf("LoadSchema failed: %v", err)
+	}
+
+	if !engine.HasFieldRules("profiles") {
+		t.Error("expected profiles to have field rules")
+	}
+	if engine.HasFieldRules("posts") {
+		t.Error("expected posts to have no field rules")
+	}
+
+	doc := map[string]any{"id": "u1", "name": "Ada", "email": "ada@example.com"}
+	tests := []struct {
+		name      string
+		auth      map[string]any
+		wantEmail bool
+	}{
+		{"owner", map[string]any{"id": "u1", "role": "user"}, true},
+		{"admin", map[string]any{"id": "u2", "role": "admin"}, true},
+		{"other user", map[string]any{"id": "u2", "role": "user"}, false},
+		// auth.id fails to evaluate without a user, which hides the field.
+		{"anonymous", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := maps.Clone(doc)
+			engine.RedactFields("profiles", &EvalContext{Auth: tt.auth}, got)
+			if _, ok := got["email"]; ok != tt.wantEmail {
+				t.Errorf("email included = %v, want %v", ok, tt.wantEmail)
+			}
+			if got["name"] != "Ada" || got["id"] != "u1" {
+				t.Errorf("other fields changed: %v", got)
+			}
+		})
+	}
+}
+
+func TestEngine_FieldReadRuleInvalid(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	if err := engine.LoadSchema(fieldRulesSchema("auth.id ==")); err == nil {
+		t.Fatal("expected an invalid field read rule to fail to load")
+	}
+}
+
+// BenchmarkRedactFields measures redacting a page of documents, with and
+// without field rules on the collection.
+func BenchmarkRedactFields(b *testing.B) {
+	engine, err := NewEngine()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := engine.LoadSchema(fieldRulesSchema("auth.id == doc.id || auth.role == 'admin'")); err != nil {
+		b.Fatal(err)
+	}
+	evalCtx := &EvalContext{Auth: map[string]any{"id": "u7", "role": "user"}}
+
+	page := make([]map[string]any, 20)
+	for i := range page {
+		page[i] = map[string]any{"id": fmt.Sprintf("u%d", i), "name": "name", "email": "user@example.com"}
+	}
+
+	for _, collection := range []string{"profiles", "posts"} {
+		b.Run(collection, func(b *testing.B) {
+			for b.Loop() {
+				if !engine.HasFieldRules(collection) {
+					continue
+				}
+				for _, doc := range page {
+					engine.RedactFields(collection, evalCtx, maps.Clone(doc))
+				}
+			}
+		})
+	}
+}
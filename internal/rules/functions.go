@@ -0,0 +1,274 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/env"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+
+	"github.com/watzon/alyx/internal/database"
+)
+
+// MaxExistsCalls is the maximum number of exists() calls a single rule may
+// contain. Calls inside comprehensions are rejected, so this also bounds the
+// number of queries one evaluation can run.
+const MaxExistsCalls = 5
+
+// existsTimeout bounds how long a single exists() query may run.
+const existsTimeout = 2 * time.Second
+
+// scopeVar is the hidden variable that carries an evaluation's context to
+// exists(). The exists macro passes it as a third argument. It is not a valid
+// identifier, so rules cannot name it.
+const scopeVar = "@scope"
+
+// newEnv creates the CEL environment for rules. The exists() binding queries
+// through e, which may be nil when the environment is only used for validation.
+func newEnv(e *Engine) (*cel.Env, error) {
+	// The standard size() cannot be extended with new overloads, so it is
+	// replaced by one that also accepts null.
+	stdlib := cel.StdLib(cel.StdLibSubset(&env.LibrarySubset{
+		ExcludeFunctions: []*env.Function{env.NewFunction("size")},
+	}))
+
+	return cel.NewCustomEnv(
+		stdlib,
+		cel.Variable("auth", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("doc", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("file", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		// tenant is the request's tenant in a tenant-scoped collection, or null.
+		cel.Variable("tenant", cel.DynType),
+		cel.Variable(scopeVar, cel.DynType),
+
+		// exists('members', {'org_id': doc.org_id, 'user_id': auth.id})
+		cel.Macros(cel.GlobalMacro("exists", 2, expandExists)),
+		cel.Function("exists",
+			cel.Overload("exists_string_map_scope",
+				[]*cel.Type{cel.StringType, cel.MapType(cel.StringType, cel.DynType), cel.DynType},
+				cel.BoolType,
+				cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+					return e.exists(args[0], args[1], args[2])
+				}),
+			),
+		),
+
+		// has(doc.metadata, 'billing.plan') checks a dotted path in a JSON value.
+		cel.Function("has",
+			cel.Overload("has_dyn_string",
+				[]*cel.Type{cel.DynType, cel.StringType},
+				cel.BoolType,
+				cel.BinaryBinding(hasPath),
+			),
+		),
+
+		// size(null) is 0, so size(doc.tags) works for unset JSON fields.
+		cel.Function("size",
+			cel.Overload("size_string", []*cel.Type{cel.StringType}, cel.IntType, cel.UnaryBinding(sizeOf)),
+			cel.Overload("size_bytes", []*cel.Type{cel.BytesType}, cel.IntType, cel.UnaryBinding(sizeOf)),
+			cel.Overload("size_list", []*cel.Type{cel.ListType(cel.TypeParamType("V"))}, cel.IntType, cel.UnaryBinding(sizeOf)),
+			cel.Overload("size_map", []*cel.Type{cel.MapType(cel.TypeParamType("K"), cel.TypeParamType("V"))}, cel.IntType, cel.UnaryBinding(sizeOf)),
+			cel.Overload("size_null", []*cel.Type{cel.NullType}, cel.IntType, cel.UnaryBinding(sizeOf)),
+			cel.MemberOverload("string_size", []*cel.Type{cel.StringType}, cel.IntType, cel.UnaryBinding(sizeOf)),
+			cel.MemberOverload("bytes_size", []*cel.Type{cel.BytesType}, cel.IntType, cel.UnaryBinding(sizeOf)),
+			cel.MemberOverload("list_size", []*cel.Type{cel.ListType(cel.TypeParamType("V"))}, cel.IntType, cel.UnaryBinding(sizeOf)),
+			cel.MemberOverload("map_size", []*cel.Type{cel.MapType(cel.TypeParamType("K"), cel.TypeParamType("V"))}, cel.IntType, cel.UnaryBinding(sizeOf)),
+		),
+	)
+}
+
+func sizeOf(value ref.Val) ref.Val {
+	switch v := value.(type) {
+	case types.Null:
+		return types.IntZero
+	case traits.Sizer:
+		return v.Size()
+	}
+	return types.MaybeNoSuchOverloadErr(value)
+}
+
+// ValidateExpression reports whether expr compiles against the rule
+// environment, including the exists() limits enforced at load time.
+func ValidateExpression(expr string) error {
+	celEnv, err := newEnv(nil)
+	if err != nil {
+		return fmt.Errorf("creating CEL environment: %w", err)
+	}
+
+	checked, issues := celEnv.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return issues.Err()
+	}
+
+	return checkExistsCalls(checked)
+}
+
+// checkExistsCalls bounds query amplification: a rule may call exists() at
+// most MaxExistsCalls times, and never from inside a comprehension where the
+// call count would depend on the data.
+func checkExistsCalls(checked *cel.Ast) error {
+	root := checked.NativeRep().Expr()
+
+	count := 0
+	var inComprehension bool
+	ast.PreOrderVisit(root, ast.NewExprVisitor(func(expr ast.Expr) {
+		switch expr.Kind() {
+		case ast.CallKind:
+			if expr.AsCall().FunctionName() == "exists" {
+				count++
+			}
+		case ast.ComprehensionKind:
+			comp := expr.AsComprehension()
+			for _, part := range []ast.Expr{comp.LoopCondition(), comp.LoopStep(), comp.Result()} {
+				if countExistsCalls(part) > 0 {
+					inComprehension = true
+				}
+			}
+		}
+	}))
+
+	if inComprehension {
+		return fmt.Errorf("exists() cannot be called inside a comprehension such as all(), exists() or map()")
+	}
+	if count > MaxExistsCalls {
+		return fmt.Errorf("rule calls exists() %d times; the maximum is %d", count, MaxExistsCalls)
+	}
+	return nil
+}
+
+func countExistsCalls(root ast.Expr) int {
+	count := 0
+	ast.PreOrderVisit(root, ast.NewExprVisitor(func(expr ast.Expr) {
+		if expr.Kind() == ast.CallKind && expr.AsCall().FunctionName() == "exists" {
+			count++
+		}
+	}))
+	return count
+}
+
+// expandExists rewrites exists(collection, filter) to pass the evaluation's
+// scope along.
+func expandExists(eh cel.MacroExprFactory, _ ast.Expr, args []ast.Expr) (ast.Expr, *common.Error) {
+	return eh.NewCall("exists", args[0], args[1], eh.NewIdent(scopeVar)), nil
+}
+
+// evalScope is the value of scopeVar: what exists() needs to know about the
+// evaluation it runs in.
+type evalScope struct {
+	ctx context.Context
+}
+
+var evalScopeType = types.NewOpaqueType("alyx.scope")
+
+func (s *evalScope) ConvertToNative(reflect.Type) (any, error) {
+	return nil, errors.New("rule scope cannot be converted")
+}
+
+func (s *evalScope) ConvertToType(ref.Type) ref.Val {
+	return types.NewErr("rule scope cannot be converted")
+}
+
+func (s *evalScope) Equal(other ref.Val) ref.Val {
+	return types.Bool(s == other)
+}
+
+func (s *evalScope) Type() ref.Type {
+	return evalScopeType
+}
+
+func (s *evalScope) Value() any {
+	return s.ctx
+}
+
+// exists checks for a matching document in a collection. Filter keys must be
+// fields of the collection and are combined with AND. The query runs with
+// the evaluation's context and ignores the collection's read rule.
+func (e *Engine) exists(collectionVal, filterVal, scopeVal ref.Val) ref.Val {
+	if e == nil {
+		return types.NewErr("exists() is not available")
+	}
+
+	e.mu.RLock()
+	docs := e.docs
+	col := e.collections[fmt.Sprint(collectionVal.Value())]
+	e.mu.RUnlock()
+
+	if docs == nil {
+		return types.NewErr("exists() is not available: no database configured")
+	}
+	if col == nil {
+		return types.NewErr("exists(): unknown collection %q", collectionVal.Value())
+	}
+
+	filter, ok := filterVal.(traits.Mapper)
+	if !ok {
+		return types.NewErr("exists(): filter must be a map")
+	}
+
+	var filters []*database.Filter
+	it := filter.Iterator()
+	for it.HasNext() == types.True {
+		key := it.Next()
+		field, ok := key.Value().(string)
+		if !ok {
+			return types.NewErr("exists(): filter keys must be strings")
+		}
+		if _, ok := col.Fields[field]; !ok {
+			return types.NewErr("exists(): collection %q has no field %q", col.Name, field)
+		}
+
+		switch v := filter.Get(key).(type) {
+		case types.Null:
+			filters = append(filters, &database.Filter{Field: field, Op: database.OpIsNull})
+		case types.Bool, types.String, types.Int, types.Uint, types.Double:
+			filters = append(filters, &database.Filter{Field: field, Op: database.OpEq, Value: v.Value()})
+		default:
+			return types.NewErr("exists(): unsupported value for field %q", field)
+		}
+	}
+
+	ctx := context.Background()
+	if scope, ok := scopeVal.(*evalScope); ok && scope.ctx != nil {
+		ctx = scope.ctx
+	}
+	ctx, cancel := context.WithTimeout(ctx, existsTimeout)
+	defer cancel()
+
+	found, err := docs.Exists(ctx, col, filters)
+	if err != nil {
+		return types.NewErr("exists(): %v", err)
+	}
+	return types.Bool(found)
+}
+
+// hasPath reports whether a dotted path resolves to a value in a map.
+func hasPath(value, pathVal ref.Val) ref.Val {
+	path, ok := pathVal.Value().(string)
+	if !ok || path == "" {
+		return types.False
+	}
+
+	current := value
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(traits.Mapper)
+		if !ok {
+			return types.False
+		}
+		next, found := m.Find(types.String(part))
+		if !found {
+			return types.False
+		}
+		current = next
+	}
+	return types.True
+}
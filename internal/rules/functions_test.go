@@ -0,0 +1,193 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/store"
+)
+
+func membershipEngine(t *testing.T) *Engine {
+	t.Helper()
+
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "rules.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE members (id TEXT PRIMARY KEY, org_id TEXT, user_id TEXT, active INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO members VALUES ('m1', 'org1', 'user1', 1), ('m2', 'org1', 'user2', 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	engine.SetStore(store.NewSQLiteDocuments(db, nil))
+
+	s := &schema.Schema{
+		Collections: map[string]*schema.Collection{
+			"members": {
+				Name: "members",
+				Fields: map[string]*schema.Field{
+					"id":      {Name: "id", Type: schema.FieldTypeString},
+					"org_id":  {Name: "org_id", Type: schema.FieldTypeString},
+					"user_id": {Name: "user_id", Type: schema.FieldTypeString},
+					"active":  {Name: "active", Type: schema.FieldTypeBool},
+				},
+			},
+			"docs": {
+				Name: "docs",
+				Rules: &schema.Rules{
+					Read:   "exists('members', {'org_id': doc.org_id, 'user_id': auth.id, 'active': true})",
+					Update: "exists('members', {'nope': auth.id})",
+				},
+			},
+		},
+	}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatalf("LoadSchema failed: %v", err)
+	}
+	return engine
+}
+
+func TestEngine_Exists(t *testing.T) {
+	engine := membershipEngine(t)
+
+	tests := []struct {
+		name   string
+		userID string
+		orgID  string
+		want   bool
+	}{
+		{"active member", "user1", "org1", true},
+		{"inactive member", "user2", "org1", false},
+		{"other org", "user1", "org2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := engine.Evaluate("docs", OpRead, &EvalContext{
+				Auth: map[string]any{"id": tt.userID},
+				Doc:  map[string]any{"org_id": tt.orgID},
+			})
+			if err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+			if allowed != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, allowed)
+			}
+		})
+	}
+
+	_, err := engine.Evaluate("docs", OpUpdate, &EvalContext{Auth: map[string]any{"id": "user1"}})
+	if !errors.Is(err, ErrRuleEvaluation) || !strings.Contains(err.Error(), `no field "nope"`) {
+		t.Errorf("Expected unknown field error, got %v", err)
+	}
+}
+
+func TestEngine_ExistsContext(t *testing.T) {
+	engine := membershipEngine(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := engine.Evaluate("docs", OpRead, &EvalContext{
+		Auth:    map[string]any{"id": "user1"},
+		Doc:     map[string]any{"org_id": "org1"},
+		Context: ctx,
+	})
+	if !errors.Is(err, ErrRuleEvaluation) || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("Expected canceled query to fail the rule, got %v", err)
+	}
+}
+
+func TestEngine_ExistsLimits(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	call := "exists('members', {'user_id': auth.id})"
+	tooMany := strings.TrimSuffix(strings.Repeat(call+" || ", MaxExistsCalls+1), " || ")
+
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"too many calls", tooMany, "maximum"},
+		{"inside comprehension", "doc.orgs.all(o, exists('members', {'org_id': o}))", "comprehension"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &schema.Schema{Collections: map[string]*schema.Collection{
+				"docs": {Name: "docs", Rules: &schema.Rules{Read: tt.expr}},
+			}}
+			err := engine.LoadSchema(s)
+			if !errors.Is(err, ErrInvalidRuleExpr) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected LoadSchema error containing %q, got %v", tt.want, err)
+			}
+			if err := ValidateExpression(tt.expr); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected ValidateExpression error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if err := ValidateExpression(strings.Repeat(call+" || ", MaxExistsCalls-1) + call); err != nil {
+		t.Errorf("Expected %d calls to be allowed, got %v", MaxExistsCalls, err)
+	}
+}
+
+func TestEngine_JSONHelpers(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	s := &schema.Schema{Collections: map[string]*schema.Collection{
+		"docs": {Name: "docs", Rules: &schema.Rules{
+			Read:   "has(doc.metadata, 'billing.plan')",
+			Update: "size(doc.tags) < 3",
+		}},
+	}}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatalf("LoadSchema failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		op   Operation
+		doc  map[string]any
+		want bool
+	}{
+		{"nested path set", OpRead, map[string]any{"metadata": map[string]any{"billing": map[string]any{"plan": "pro"}}}, true},
+		{"nested path missing", OpRead, map[string]any{"metadata": map[string]any{"billing": map[string]any{}}}, false},
+		{"not a map", OpRead, map[string]any{"metadata": "text"}, false},
+		{"null JSON field", OpUpdate, map[string]any{"tags": nil}, true},
+		{"list JSON field", OpUpdate, map[string]any{"tags": []any{"a", "b", "c"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := engine.Evaluate("docs", tt.op, &EvalContext{Doc: tt.doc})
+			if err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+			if allowed != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package rules
+
+import (
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// ListStrategy describes how a rule is applied to a list query.
+type ListStrategy string
+
+const (
+	// ListStrategyUnrestricted means the collection has no rule for the operation.
+	ListStrategyUnrestricted ListStrategy = "unrestricted"
+	// ListStrategyConstant means the rule does not reference doc, so it was
+	// evaluated once for the whole request.
+	ListStrategyConstant ListStrategy = "constant"
+	// ListStrategySQL means the rule was translated into query filters.
+	ListStrategySQL ListStrategy = "sql"
+	// ListStrategyPerRow means the rule must be evaluated against every row.
+	ListStrategyPerRow ListStrategy = "per_row"
+)
+
+// ListPlan is the result of planning a rule for a list query.
+type ListPlan struct {
+	Strategy ListStrategy
+	// Allowed is the rule result when Strategy is ListStrategyConstant.
+	Allowed bool
+	// Filters restrict the query to permitted rows when Strategy is ListStrategySQL.
+	Filters []*database.Filter
+}
+
+// PlanList decides how to apply a collection's rule to a list query. Rules
+// that do not reference doc are evaluated once; conjunctions of comparisons
+// between doc fields and constants or auth/request values become SQL filters;
+// anything else has to be evaluated per row.
+func (e *Engine) PlanList(collection string, op Operation, ctx *EvalContext) (*ListPlan, error) {
+	e.mu.RLock()
+	checked, ok := e.asts[ruleKey(collection, op)]
+	col := e.collections[collection]
+	e.mu.RUnlock()
+
+	if !ok {
+		return &ListPlan{Strategy: ListStrategyUnrestricted, Allowed: true}, nil
+	}
+
+	root := checked.NativeRep().Expr()
+	if !referencesDoc(root) {
+		allowed, err := e.Evaluate(collection, op, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &ListPlan{Strategy: ListStrategyConstant, Allowed: allowed}, nil
+	}
+
+	if col != nil {
+		vars := map[string]any{"auth": ctx.Auth, "request": ctx.Request, "tenant": ctx.Tenant}
+		if filters, ok := translateFilters(root, col, vars); ok {
+			return &ListPlan{Strategy: ListStrategySQL, Filters: filters}, nil
+		}
+	}
+
+	return &ListPlan{Strategy: ListStrategyPerRow}, nil
+}
+
+func referencesDoc(root ast.Expr) bool {
+	found := false
+	ast.PreOrderVisit(root, ast.NewExprVisitor(func(expr ast.Expr) {
+		if expr.Kind() == ast.IdentKind && expr.AsIdent() == "doc" {
+			found = true
+		}
+	}))
+	return found
+}
+
+// flippedOps maps a comparison to its equivalent with the operands swapped.
+var flippedOps = map[string]string{
+	"_==_": "_==_",
+	"_!=_": "_!=_",
+	"_<_":  "_>_",
+	"_<=_": "_>=_",
+	"_>_":  "_<_",
+	"_>=_": "_<=_",
+}
+
+var filterOps = map[string]database.FilterOp{
+	"_==_": database.OpEq,
+	"_!=_": database.OpNe,
+	"_<_":  database.OpLt,
+	"_<=_": database.OpLte,
+	"_>_":  database.OpGt,
+	"_>=_": database.OpGte,
+}
+
+// translateFilters converts expr into filters that select exactly the rows
+// the rule would allow, or reports false if it cannot do so faithfully.
+func translateFilters(expr ast.Expr, col *schema.Collection, vars map[string]any) ([]*database.Filter, bool) {
+	switch expr.Kind() {
+	case ast.LiteralKind:
+		if expr.AsLiteral() == types.True {
+			return nil, true
+		}
+		return nil, false
+	case ast.CallKind:
+	default:
+		return nil, false
+	}
+
+	call := expr.AsCall()
+	args := call.Args()
+	if call.IsMemberFunction() || len(args) != 2 {
+		return nil, false
+	}
+
+	if call.FunctionName() == "_&&_" {
+		left, ok := translateFilters(args[0], col, vars)
+		if !ok {
+			return nil, false
+		}
+		right, ok := translateFilters(args[1], col, vars)
+		if !ok {
+			return nil, false
+		}
+		return append(left, right...), true
+	}
+
+	fn := call.FunctionName()
+	if _, ok := filterOps[fn]; !ok {
+		return nil, false
+	}
+
+	fieldExpr, valueExpr := args[0], args[1]
+	if _, isDoc := docField(fieldExpr); !isDoc {
+		fieldExpr, valueExpr = valueExpr, fieldExpr
+		fn = flippedOps[fn]
+	}
+
+	fieldName, ok := docField(fieldExpr)
+	if !ok {
+		return nil, false
+	}
+	field, ok := col.Fields[fieldName]
+	if !ok || field.Internal {
+		return nil, false
+	}
+
+	value, ok := operandValue(valueExpr, vars)
+	if !ok {
+		return nil, false
+	}
+
+	filter, ok := comparisonFilter(field, fn, value)
+	if !ok {
+		return nil, false
+	}
+	return []*database.Filter{filter}, true
+}
+
+// docField returns the field name for a doc.<field> selection.
+func docField(expr ast.Expr) (string, bool) {
+	if expr.Kind() != ast.SelectKind {
+		return "", false
+	}
+	sel := expr.AsSelect()
+	if sel.IsTestOnly() || sel.Operand().Kind() != ast.IdentKind || sel.Operand().AsIdent() != "doc" {
+		return "", false
+	}
+	return sel.FieldName(), true
+}
+
+// operandValue resolves a literal, the tenant, or an auth/request selection
+// to a Go value.
+func operandValue(expr ast.Expr, vars map[string]any) (any, bool) {
+	switch expr.Kind() {
+	case ast.LiteralKind:
+		return literalValue(expr.AsLiteral())
+	case ast.IdentKind:
+		if expr.AsIdent() != "tenant" {
+			return nil, false
+		}
+		return vars["tenant"], true
+	case ast.SelectKind:
+		sel := expr.AsSelect()
+		if sel.IsTestOnly() || sel.Operand().Kind() != ast.IdentKind {
+			return nil, false
+		}
+		values, ok := vars[sel.Operand().AsIdent()].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		// A missing key is an evaluation error, which only per-row
+		// evaluation reproduces.
+		value, ok := values[sel.FieldName()]
+		if !ok {
+			return nil, false
+		}
+		return value, true
+	}
+	return nil, false
+}
+
+func literalValue(val ref.Val) (any, bool) {
+	switch v := val.(type) {
+	case types.Null:
+		return nil, true
+	case types.String, types.Int, types.Double, types.Bool:
+		return v.Value(), true
+	}
+	return nil, false
+}
+
+// comparisonFilter builds the filter for doc.<field> <fn> value. Comparisons
+// whose SQL result would differ from CEL's, such as != against a nullable
+// column (NULL != 'x' is true in CEL but not in SQL) or any comparison on a
+// nocase column, are rejected, as are encrypted fields, which cannot be
+// filtered on.
+func comparisonFilter(field *schema.Field, fn string, value any) (*database.Filter, bool) {
+	if field.Encrypted || field.CaseInsensitive() {
+		return nil, false
+	}
+
+	if value == nil {
+		switch fn {
+		case "_==_":
+			return &database.Filter{Field: field.Name, Op: database.OpIsNull}, true
+		case "_!=_":
+			return &database.Filter{Field: field.Name, Op: database.OpNotNull}, true
+		}
+		return nil, false
+	}
+
+	if fn == "_!=_" && field.Nullable {
+		return nil, false
+	}
+
+	switch field.Type {
+	case schema.FieldTypeID, schema.FieldTypeUUID, schema.FieldTypeString, schema.FieldTypeText,
+		schema.FieldTypeRichText, schema.FieldTypeEmail, schema.FieldTypeURL, schema.FieldTypeDate,
+		schema.FieldTypeRelation, schema.FieldTypeFile:
+		if _, ok := value.(string); !ok {
+			return nil, false
+		}
+	case schema.FieldTypeInt, schema.FieldTypeFloat:
+		switch value.(type) {
+		case int, int32, int64, float32, float64:
+		default:
+			return nil, false
+		}
+	case schema.FieldTypeBool:
+		b, ok := value.(bool)
+		if !ok || (fn != "_==_" && fn != "_!=_") {
+			return nil, false
+		}
+		value = 0
+		if b {
+			value = 1
+		}
+	case schema.FieldTypeTimestamp, schema.FieldTypeJSON, schema.FieldTypeBlob, schema.FieldTypeSelect:
+		return nil, false
+	default:
+		return nil, false
+	}
+
+	return &database.Filter{Field: field.Name, Op: filterOps[fn], Value: value}, true
+}
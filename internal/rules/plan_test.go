@@ -0,0 +1,247 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func postsSchema(read string) *schema.Schema {
+	return &schema.Schema{
+		Collections: map[string]*schema.Collection{
+			"posts": {
+				Name: "posts",
+				Fields: map[string]*schema.Field{
+					"id":        {Name: "id", Type: schema.FieldTypeString},
+					"author_id": {Name: "author_id", Type: schema.FieldTypeString},
+					"published": {Name: "published", Type: schema.FieldTypeBool},
+					"views":     {Name: "views", Type: schema.FieldTypeInt},
+					"editor_id": {Name: "editor_id", Type: schema.FieldTypeString, Nullable: true},
+					"meta":      {Name: "meta", Type: schema.FieldTypeJSON},
+					"handle":    {Name: "handle", Type: schema.FieldTypeString, Collate: schema.CollationNocase},
+					"secret":    {Name: "secret", Type: schema.FieldTypeString, Encrypted: true},
+				},
+				Rules: &schema.Rules{Read: read},
+			},
+		},
+	}
+}
+
+func TestEngine_PlanList(t *testing.T) {
+	evalCtx := &EvalContext{
+		Auth:    map[string]any{"id": "user1", "role": "user"},
+		Request: map[string]any{"method": "GET", "ip": "127.0.0.1"},
+	}
+
+	tests := []struct {
+		name     string
+		rule     string
+		strategy ListStrategy
+		allowed  bool
+		filters  []database.Filter
+	}{
+		{name: "no rule", rule: "", strategy: ListStrategyUnrestricted, allowed: true},
+		{name: "auth only allowed", rule: "auth.role == 'user'", strategy: ListStrategyConstant, allowed: true},
+		{name: "auth only denied", rule: "auth.role == 'admin'", strategy: ListStrategyConstant, allowed: false},
+		{
+			name:     "owner",
+			rule:     "doc.author_id == auth.id",
+			strategy: ListStrategySQL,
+			filters:  []database.Filter{{Field: "author_id", Op: database.OpEq, Value: "user1"}},
+		},
+		{
+			name:     "conjunction with flipped comparison",
+			rule:     "doc.published == true && 10 < doc.views",
+			strategy: ListStrategySQL,
+			filters: []database.Filter{
+				{Field: "published", Op: database.OpEq, Value: 1},
+				{Field: "views", Op: database.OpGt, Value: int64(10)},
+			},
+		},
+		{
+			name:     "null check",
+			rule:     "doc.editor_id == null",
+			strategy: ListStrategySQL,
+			filters:  []database.Filter{{Field: "editor_id", Op: database.OpIsNull}},
+		},
+		{name: "disjunction", rule: "doc.author_id == auth.id || doc.published == true", strategy: ListStrategyPerRow},
+		{name: "not equal on nullable field", rule: "doc.editor_id != auth.id", strategy: ListStrategyPerRow},
+		{name: "json field", rule: "doc.meta == 'x'", strategy: ListStrategyPerRow},
+		{name: "type mismatch", rule: "doc.views == 'ten'", strategy: ListStrategyPerRow},
+		{name: "missing auth key", rule: "doc.author_id == auth.team", strategy: ListStrategyPerRow},
+		{name: "unknown field", rule: "doc.nope == 'x'", strategy: ListStrategyPerRow},
+		{name: "nocase field", rule: "doc.handle == auth.id", strategy: ListStrategyPerRow},
+		{name: "nocase field null check", rule: "doc.handle == null", strategy: ListStrategyPerRow},
+		{name: "encrypted field", rule: "doc.secret == auth.id", strategy: ListStrategyPerRow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := NewEngine()
+			if err != nil {
+				t.Fatalf("NewEngine failed: %v", err)
+			}
+			if err := engine.LoadSchema(postsSchema(tt.rule)); err != nil {
+				t.Fatalf("LoadSchema failed: %v", err)
+			}
+
+			plan, err := engine.PlanList("posts", OpRead, evalCtx)
+			if err != nil {
+				t.Fatalf("PlanList failed: %v", err)
+			}
+			if plan.Strategy != tt.strategy {
+				t.Fatalf("Expected strategy %s, got %s", tt.strategy, plan.Strategy)
+			}
+			if plan.Strategy == ListStrategyConstant && plan.Allowed != tt.allowed {
+				t.Errorf("Expected allowed=%v, got %v", tt.allowed, plan.Allowed)
+			}
+			if len(plan.Filters) != len(tt.filters) {
+				t.Fatalf("Expected %d filters, got %d", len(tt.filters), len(plan.Filters))
+			}
+			for i, want := range tt.filters {
+				if *plan.Filters[i] != want {
+					t.Errorf("Filter %d: expected %+v, got %+v", i, want, *plan.Filters[i])
+				}
+			}
+		})
+	}
+}
+
+// benchmarkRows is the size of the collection used by the list benchmarks.
+const benchmarkRows = 100_000
+
+func benchmarkCollection(b *testing.B) (*database.Collection, *schema.Schema) {
+	b.Helper()
+
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(b.TempDir(), "bench.db")})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+
+	s := postsSchema("")
+	if _, err := db.Exec(`CREATE TABLE posts (id TEXT PRIMARY KEY, author_id TEXT NOT NULL, published INTEGER NOT NULL, views INTEGER NOT NULL, editor_id TEXT, meta TEXT, handle TEXT, secret TEXT)`); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE INDEX idx_posts_author_id ON posts (author_id)`); err != nil {
+		b.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO posts (id, author_id, published, views) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := range benchmarkRows {
+		if _, err := stmt.Exec(fmt.Sprintf("post%d", i), fmt.Sprintf("user%d", i%1000), i%2, i); err != nil {
+			b.Fatal(err)
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+
+	return database.NewCollection(db, s.Collections["posts"]), s
+}
+
+// BenchmarkListReadRule compares listing the first page of an owner-only
+// collection by evaluating the rule per row against translating it to SQL.
+func BenchmarkListReadRule(b *testing.B) {
+	col, s := benchmarkCollection(b)
+	ctx := context.Background()
+	evalCtx := &EvalContext{Auth: map[string]any{"id": "user42"}}
+	opts := &database.QueryOptions{Limit: 20}
+
+	engine, err := NewEngine()
+	if err != nil {
+		b.Fatal(err)
+	}
+	s.Collections["posts"].Rules = &schema.Rules{Read: "doc.author_id == auth.id"}
+	if err := engine.LoadSchema(s); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("per_row", func(b *testing.B) {
+		for b.Loop() {
+			result, err := col.Find(ctx, &database.QueryOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			var visible []database.Row
+			for _, doc := range result.Docs {
+				evalCtx.Doc = doc
+				allowed, err := engine.Evaluate("posts", OpRead, evalCtx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if allowed && len(visible) < opts.Limit {
+					visible = append(visible, doc)
+				}
+			}
+		}
+	})
+
+	b.Run("sql", func(b *testing.B) {
+		for b.Loop() {
+			plan, err := engine.PlanList("posts", OpRead, evalCtx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := col.Find(ctx, &database.QueryOptions{Filters: plan.Filters, Limit: opts.Limit}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkListReadRuleConstant compares evaluating a doc-independent rule per
+// row against evaluating it once per request.
+func BenchmarkListReadRuleConstant(b *testing.B) {
+	col, s := benchmarkCollection(b)
+	ctx := context.Background()
+	evalCtx := &EvalContext{Auth: map[string]any{"role": "admin"}}
+
+	engine, err := NewEngine()
+	if err != nil {
+		b.Fatal(err)
+	}
+	s.Collections["posts"].Rules = &schema.Rules{Read: "auth.role == 'admin'"}
+	if err := engine.LoadSchema(s); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("per_row", func(b *testing.B) {
+		for b.Loop() {
+			result, err := col.Find(ctx, &database.QueryOptions{Limit: 1000})
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, doc := range result.Docs {
+				evalCtx.Doc = doc
+				if _, err := engine.Evaluate("posts", OpRead, evalCtx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("constant", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := engine.PlanList("posts", OpRead, evalCtx); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := col.Find(ctx, &database.QueryOptions{Limit: 1000}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -2,6 +2,7 @@ package rules
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/watzon/alyx/internal/auth"
@@ -359,3 +360,61 @@ func TestBuildRequestContext(t *testing.T) {
 		t.Errorf("ip mismatch: got %v, want 192.168.1.1", ctx["ip"])
 	}
 }
+
+func TestEngine_CheckAccessDenial(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	s := &schema.Schema{
+		Collections: map[string]*schema.Collection{
+			"posts": {
+				Name: "posts",
+				Fields: map[string]*schema.Field{
+					"owner_id": {Name: "owner_id", Type: schema.FieldTypeString},
+					"notes":    {Name: "notes", Type: schema.FieldTypeString, Internal: true},
+				},
+				Rules: &schema.Rules{
+					Update: "auth.id == doc.owner_id && auth.metadata.plan == 'pro' && doc.notes != '' && auth.api_token != ''",
+				},
+			},
+		},
+	}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatalf("LoadSchema failed: %v", err)
+	}
+
+	want := []string{"auth.api_token", "auth.id", "auth.metadata.plan", "doc.notes", "doc.owner_id"}
+	if got := engine.References("posts", OpUpdate); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("References = %v, want %v", got, want)
+	}
+
+	err = engine.CheckAccess("posts", OpUpdate, &EvalContext{
+		Auth: map[string]any{"id": "user-1", "metadata": map[string]any{"plan": "free"}, "api_token": "t0k3n"},
+		Doc:  map[string]any{"owner_id": "user-1", "notes": "internal"},
+	})
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+	var denial *Denial
+	if !errors.As(err, &denial) {
+		t.Fatalf("expected *Denial, got %T", err)
+	}
+	if denial.Operation != OpUpdate || denial.Rule != s.Collections["posts"].Rules.Update {
+		t.Errorf("unexpected denial %+v", denial)
+	}
+
+	wantValues := map[string]any{
+		"auth.api_token":     RedactedValue,
+		"auth.id":            "user-1",
+		"auth.metadata.plan": "free",
+		"doc.notes":          RedactedValue,
+		"doc.owner_id":       "user-1",
+	}
+	for path, value := range wantValues {
+		if denial.Values[path] != value {
+			t.Errorf("Values[%s] = %v, want %v", path, denial.Values[path], value)
+		}
+	}
+}
@@ -21,35 +21,46 @@ type ScheduleState struct {
 	ScheduleID      string
 	LastExecutionAt *time.Time
 	NextExecutionAt *time.Time
-	ExecutionCount  int
-	UpdatedAt       time.Time
+	// NextSlotAt is the fire time NextExecutionAt was derived from, before
+	// jitter. Nil for state saved before jitter existed.
+	NextSlotAt *time.Time
+	// LockEventID is the event of the latest run. Until the event bus has
+	// processed it, the run counts as in progress for the overlap policy.
+	LockEventID    string
+	ExecutionCount int
+	UpdatedAt      time.Time
 }
 
-func (s *StateStore) Save(ctx context.Context, state *ScheduleState) error {
-	state.UpdatedAt = time.Now().UTC()
+const stateColumns = "schedule_id, last_execution_at, next_execution_at, next_slot_at, lock_event_id, execution_count, updated_at"
 
-	var lastExecStr, nextExecStr sql.NullString
-	if state.LastExecutionAt != nil {
-		lastExecStr = sql.NullString{String: state.LastExecutionAt.UTC().Format(time.RFC3339), Valid: true}
-	}
-	if state.NextExecutionAt != nil {
-		nextExecStr = sql.NullString{String: state.NextExecutionAt.UTC().Format(time.RFC3339), Valid: true}
+func formatStateTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
 	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339), Valid: true}
+}
+
+func (s *StateStore) Save(ctx context.Context, state *ScheduleState) error {
+	state.UpdatedAt = time.Now().UTC()
 
 	query := `
-		INSERT INTO _alyx_scheduler_state (schedule_id, last_execution_at, next_execution_at, execution_count, updated_at)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO _alyx_scheduler_state (` + stateColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(schedule_id) DO UPDATE SET
 			last_execution_at = excluded.last_execution_at,
 			next_execution_at = excluded.next_execution_at,
+			next_slot_at = excluded.next_slot_at,
+			lock_event_id = excluded.lock_event_id,
 			execution_count = excluded.execution_count,
 			updated_at = excluded.updated_at
 	`
 
 	_, err := s.db.ExecContext(ctx, query,
 		state.ScheduleID,
-		lastExecStr,
-		nextExecStr,
+		formatStateTime(state.LastExecutionAt),
+		formatStateTime(state.NextExecutionAt),
+		formatStateTime(state.NextSlotAt),
+		sql.NullString{String: state.LockEventID, Valid: state.LockEventID != ""},
 		state.ExecutionCount,
 		state.UpdatedAt.Format(time.RFC3339),
 	)
@@ -61,24 +72,9 @@ func (s *StateStore) Save(ctx context.Context, state *ScheduleState) error {
 }
 
 func (s *StateStore) Get(ctx context.Context, scheduleID string) (*ScheduleState, error) {
-	query := `
-		SELECT schedule_id, last_execution_at, next_execution_at, execution_count, updated_at
-		FROM _alyx_scheduler_state
-		WHERE schedule_id = ?
-	`
-
-	var state ScheduleState
-	var lastExec, nextExec sql.NullString
-	var updatedAt string
-
-	err := s.db.QueryRowContext(ctx, query, scheduleID).Scan(
-		&state.ScheduleID,
-		&lastExec,
-		&nextExec,
-		&state.ExecutionCount,
-		&updatedAt,
-	)
+	query := `SELECT ` + stateColumns + ` FROM _alyx_scheduler_state WHERE schedule_id = ?`
 
+	state, err := scanState(s.db.QueryRowContext(ctx, query, scheduleID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -86,29 +82,7 @@ func (s *StateStore) Get(ctx context.Context, scheduleID string) (*ScheduleState
 		return nil, fmt.Errorf("getting scheduler state: %w", err)
 	}
 
-	if lastExec.Valid {
-		t, parseErr := time.Parse(time.RFC3339, lastExec.String)
-		if parseErr != nil {
-			return nil, fmt.Errorf("parsing last_execution_at: %w", parseErr)
-		}
-		state.LastExecutionAt = &t
-	}
-
-	if nextExec.Valid {
-		t, parseErr := time.Parse(time.RFC3339, nextExec.String)
-		if parseErr != nil {
-			return nil, fmt.Errorf("parsing next_execution_at: %w", parseErr)
-		}
-		state.NextExecutionAt = &t
-	}
-
-	t, parseErr := time.Parse(time.RFC3339, updatedAt)
-	if parseErr != nil {
-		return nil, fmt.Errorf("parsing updated_at: %w", parseErr)
-	}
-	state.UpdatedAt = t
-
-	return &state, nil
+	return state, nil
 }
 
 func (s *StateStore) Delete(ctx context.Context, scheduleID string) error {
@@ -123,11 +97,7 @@ func (s *StateStore) Delete(ctx context.Context, scheduleID string) error {
 }
 
 func (s *StateStore) List(ctx context.Context) ([]*ScheduleState, error) {
-	query := `
-		SELECT schedule_id, last_execution_at, next_execution_at, execution_count, updated_at
-		FROM _alyx_scheduler_state
-		ORDER BY schedule_id
-	`
+	query := `SELECT ` + stateColumns + ` FROM _alyx_scheduler_state ORDER BY schedule_id`
 
 	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
@@ -137,51 +107,68 @@ func (s *StateStore) List(ctx context.Context) ([]*ScheduleState, error) {
 
 	var states []*ScheduleState
 	for rows.Next() {
-		var state ScheduleState
-		var lastExec, nextExec sql.NullString
-		var updatedAt string
-
-		err := rows.Scan(
-			&state.ScheduleID,
-			&lastExec,
-			&nextExec,
-			&state.ExecutionCount,
-			&updatedAt,
-		)
+		state, err := scanState(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scanning scheduler state: %w", err)
 		}
+		states = append(states, state)
+	}
 
-		if lastExec.Valid {
-			t, parseErr := time.Parse(time.RFC3339, lastExec.String)
-			if parseErr != nil {
-				return nil, fmt.Errorf("parsing last_execution_at: %w", parseErr)
-			}
-			state.LastExecutionAt = &t
-		}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating scheduler states: %w", err)
+	}
 
-		if nextExec.Valid {
-			t, parseErr := time.Parse(time.RFC3339, nextExec.String)
-			if parseErr != nil {
-				return nil, fmt.Errorf("parsing next_execution_at: %w", parseErr)
-			}
-			state.NextExecutionAt = &t
-		}
+	return states, nil
+}
 
-		t, parseErr := time.Parse(time.RFC3339, updatedAt)
-		if parseErr != nil {
-			return nil, fmt.Errorf("parsing updated_at: %w", parseErr)
-		}
-		state.UpdatedAt = t
+type stateScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanState(row stateScanner) (*ScheduleState, error) {
+	var state ScheduleState
+	var lastExec, nextExec, nextSlot, lockEvent sql.NullString
+	var updatedAt string
 
-		states = append(states, &state)
+	if err := row.Scan(
+		&state.ScheduleID,
+		&lastExec,
+		&nextExec,
+		&nextSlot,
+		&lockEvent,
+		&state.ExecutionCount,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	state.LockEventID = lockEvent.String
+
+	for _, field := range []struct {
+		name  string
+		value sql.NullString
+		dest  **time.Time
+	}{
+		{"last_execution_at", lastExec, &state.LastExecutionAt},
+		{"next_execution_at", nextExec, &state.NextExecutionAt},
+		{"next_slot_at", nextSlot, &state.NextSlotAt},
+	} {
+		if !field.value.Valid {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, field.value.String)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", field.name, err)
+		}
+		*field.dest = &t
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterating scheduler states: %w", err)
+	t, err := time.Parse(time.RFC3339, updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parsing updated_at: %w", err)
 	}
+	state.UpdatedAt = t
 
-	return states, nil
+	return &state, nil
 }
 
 func (s *StateStore) UpdateAfterExecution(ctx context.Context, scheduleID string, nextRun time.Time) error {
@@ -208,3 +195,55 @@ func (s *StateStore) UpdateAfterExecution(ctx context.Context, scheduleID string
 
 	return nil
 }
+
+// RecordFire records a run of the schedule started at firedAt under the
+// event eventID, which takes the schedule's overlap lock. It creates the
+// state if the schedule has none yet.
+func (s *StateStore) RecordFire(ctx context.Context, scheduleID, eventID string, firedAt time.Time) error {
+	query := `
+		INSERT INTO _alyx_scheduler_state (schedule_id, last_execution_at, lock_event_id, execution_count, updated_at)
+		VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT(schedule_id) DO UPDATE SET
+			last_execution_at = excluded.last_execution_at,
+			lock_event_id = excluded.lock_event_id,
+			execution_count = execution_count + 1,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		scheduleID,
+		firedAt.UTC().Format(time.RFC3339),
+		eventID,
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("recording scheduled run: %w", err)
+	}
+
+	return nil
+}
+
+// SetNext records the schedule's next fire time slot and the jittered time
+// it fires at. It creates the state if the schedule has none yet.
+func (s *StateStore) SetNext(ctx context.Context, scheduleID string, slot, fireAt time.Time) error {
+	query := `
+		INSERT INTO _alyx_scheduler_state (schedule_id, next_execution_at, next_slot_at, execution_count, updated_at)
+		VALUES (?, ?, ?, 0, ?)
+		ON CONFLICT(schedule_id) DO UPDATE SET
+			next_execution_at = excluded.next_execution_at,
+			next_slot_at = excluded.next_slot_at,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		scheduleID,
+		fireAt.UTC().Format(time.RFC3339),
+		slot.UTC().Format(time.RFC3339),
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("saving next scheduled run: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// OverlapPolicy decides what happens when a schedule comes due while its
+// previous run is still in progress.
+type OverlapPolicy string
+
+const (
+	// OverlapSkip drops the fire time and waits for the next one.
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapQueue holds the schedule until the previous run finishes, then
+	// runs it once. Fire times that pass while waiting are merged into that run.
+	OverlapQueue OverlapPolicy = "queue"
+	// OverlapAllow runs regardless, up to MaxOverlap concurrent runs.
+	OverlapAllow OverlapPolicy = "allow"
+)
+
+// CatchUpPolicy decides which fire times missed while the server was down
+// run when it starts again.
+type CatchUpPolicy string
+
+const (
+	// CatchUpNone skips missed fire times.
+	CatchUpNone CatchUpPolicy = "none"
+	// CatchUpOne runs once for all missed fire times.
+	CatchUpOne CatchUpPolicy = "one"
+	// CatchUpAll runs once for each missed fire time, up to maxCatchUp.
+	CatchUpAll CatchUpPolicy = "all"
+)
+
+// maxCatchUp bounds the runs CatchUpAll starts for one schedule.
+const maxCatchUp = 100
+
+// ParseOverlapPolicy parses an overlap policy. The empty string is returned
+// as is and behaves as OverlapSkip.
+func ParseOverlapPolicy(s string) (OverlapPolicy, error) {
+	switch p := OverlapPolicy(s); p {
+	case "", OverlapSkip, OverlapQueue, OverlapAllow:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown overlap policy %q (want skip, queue, or allow)", s)
+}
+
+// ParseCatchUpPolicy parses a catch-up policy; the empty string is returned
+// as is, leaving the choice to the recovery defaults.
+func ParseCatchUpPolicy(s string) (CatchUpPolicy, error) {
+	switch p := CatchUpPolicy(s); p {
+	case "", CatchUpNone, CatchUpOne, CatchUpAll:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown catch-up policy %q (want none, one, or all)", s)
+}
+
+// Validate checks the config's overlap and catch-up policies and jitter.
+func (c ScheduleConfig) Validate() error {
+	if _, err := ParseOverlapPolicy(string(c.Overlap)); err != nil {
+		return err
+	}
+	if _, err := ParseCatchUpPolicy(string(c.CatchUp)); err != nil {
+		return err
+	}
+	if c.Jitter < 0 {
+		return fmt.Errorf("jitter must not be negative")
+	}
+	return nil
+}
+
+// overlap returns the schedule's effective overlap policy. Configs written
+// before Overlap existed keep their meaning: SkipIfRunning is skip, and a
+// MaxOverlap limit alone is allow.
+func (c ScheduleConfig) overlap() OverlapPolicy {
+	switch {
+	case c.Overlap != "":
+		return c.Overlap
+	case !c.SkipIfRunning && c.MaxOverlap > 0:
+		return OverlapAllow
+	}
+	return OverlapSkip
+}
+
+// catchUp returns the schedule's effective catch-up policy. Unset, it follows
+// the recovery config's EnableCatchup, except that a one-time schedule, which
+// has no later fire time to wait for, runs once.
+func (s *Schedule) catchUp(config *RecoveryConfig) CatchUpPolicy {
+	switch {
+	case s.Config.CatchUp != "":
+		return s.Config.CatchUp
+	case config.EnableCatchup:
+		return CatchUpAll
+	case s.Type == ScheduleTypeOneTime:
+		return CatchUpOne
+	}
+	return CatchUpNone
+}
+
+// jitterFor returns the delay added to the schedule's fire time slot: a
+// value in [0, maxJitter) derived from the schedule ID and the slot, so that
+// every replica and every restart agrees on it. It is whole seconds, the
+// precision fire times are stored with.
+func jitterFor(scheduleID string, slot time.Time, maxJitter time.Duration) time.Duration {
+	if maxJitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s@%d", scheduleID, slot.Unix())
+	return time.Duration(h.Sum64() % uint64(maxJitter)).Truncate(time.Second)
+}
+
+// fireTime returns when the schedule actually fires for slot.
+func (s *Schedule) fireTime(slot time.Time) time.Time {
+	return slot.Add(jitterFor(s.ID, slot, s.Config.Jitter))
+}
+
+// NextFire returns the schedule's first fire time slot after after, and the
+// time it fires at once its jitter is added.
+func NextFire(schedule *Schedule, after time.Time) (slot, fireAt time.Time, err error) {
+	slot, err = CalculateNextRun(schedule, after)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return slot, schedule.fireTime(slot), nil
+}
+
+// nextSlot returns the first fire time slot after both slot and now. Interval
+// schedules stay aligned to their previous slot instead of drifting by the
+// time each run was late.
+func nextSlot(schedule *Schedule, slot, now time.Time) (time.Time, error) {
+	if schedule.Type != ScheduleTypeInterval {
+		after := now
+		if slot.After(now) {
+			after = slot
+		}
+		return CalculateNextRun(schedule, after)
+	}
+
+	interval, err := ParseInterval(schedule.Expression)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := slot.Add(interval)
+	if !next.After(now) {
+		next = next.Add(interval * (now.Sub(next)/interval + 1))
+	}
+	return next, nil
+}
+
+// missedSlots returns the fire time slots from first through now, at most
+// limit of them.
+func missedSlots(schedule *Schedule, first, now time.Time, limit int) ([]time.Time, error) {
+	var slots []time.Time
+	for slot := first; !slot.After(now) && len(slots) < limit; {
+		slots = append(slots, slot)
+		if schedule.Type == ScheduleTypeOneTime {
+			break
+		}
+
+		var err error
+		if schedule.Type == ScheduleTypeInterval {
+			interval, parseErr := ParseInterval(schedule.Expression)
+			if parseErr != nil {
+				return nil, parseErr
+			}
+			slot = slot.Add(interval)
+		} else if slot, err = CalculateNextRun(schedule, slot); err != nil {
+			return nil, err
+		}
+	}
+	return slots, nil
+}
@@ -0,0 +1,388 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/events"
+)
+
+// fakeClock is a settable clock for the scheduler.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestScheduler(t *testing.T, db *database.DB, clock *fakeClock) (*Scheduler, *events.EventBus) {
+	t.Helper()
+	eventBus := events.NewEventBus(db, nil)
+	s := NewScheduler(db, eventBus)
+	s.now = clock.Now
+	return s, eventBus
+}
+
+// scheduledEvents returns the scheduled_at slot of each schedule event, in
+// publishing order.
+func scheduledEvents(t *testing.T, db *database.DB) []string {
+	t.Helper()
+	rows, err := db.QueryContext(context.Background(),
+		`SELECT json_extract(payload, '$.scheduled_at') FROM events WHERE type = 'schedule' ORDER BY rowid`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var slots []string
+	for rows.Next() {
+		var slot string
+		if err := rows.Scan(&slot); err != nil {
+			t.Fatal(err)
+		}
+		slots = append(slots, slot)
+	}
+	return slots
+}
+
+func TestJitterFor(t *testing.T) {
+	slot := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if got := jitterFor("a", slot, 0); got != 0 {
+		t.Errorf("jitter without a maximum = %v", got)
+	}
+
+	seen := map[time.Duration]bool{}
+	for i := range 50 {
+		s := slot.Add(time.Duration(i) * time.Minute)
+		j := jitterFor("sched", s, 30*time.Second)
+		if j < 0 || j >= 30*time.Second {
+			t.Fatalf("jitter %v out of range", j)
+		}
+		if again := jitterFor("sched", s, 30*time.Second); again != j {
+			t.Fatalf("jitter not deterministic: %v then %v", j, again)
+		}
+		seen[j] = true
+	}
+	if len(seen) < 10 {
+		t.Errorf("expected jitter to vary across slots, got %d distinct values", len(seen))
+	}
+}
+
+func TestNextFire(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC)
+	schedule := &Schedule{
+		ID:         "hourly",
+		Type:       ScheduleTypeCron,
+		Expression: "0 * * * *",
+		Timezone:   "UTC",
+		Config:     ScheduleConfig{Jitter: 5 * time.Minute},
+	}
+
+	slot, fireAt, err := NextFire(schedule, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC); !slot.Equal(want) {
+		t.Errorf("slot = %v, want %v", slot, want)
+	}
+	if fireAt.Sub(slot) != jitterFor("hourly", slot, 5*time.Minute) {
+		t.Errorf("fire time %v does not add the slot's jitter", fireAt)
+	}
+}
+
+func TestNextSlot(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	interval := &Schedule{Type: ScheduleTypeInterval, Expression: "10m", Timezone: "UTC"}
+	cron := &Schedule{Type: ScheduleTypeCron, Expression: "*/15 * * * *", Timezone: "UTC"}
+
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		slot     time.Time
+		now      time.Time
+		want     time.Time
+	}{
+		{"interval on time", interval, base, base.Add(3 * time.Second), base.Add(10 * time.Minute)},
+		{"interval run late stays aligned", interval, base, base.Add(4 * time.Minute), base.Add(10 * time.Minute)},
+		{"interval behind skips past now", interval, base, base.Add(25 * time.Minute), base.Add(30 * time.Minute)},
+		{"interval exactly on a slot", interval, base, base.Add(20 * time.Minute), base.Add(30 * time.Minute)},
+		{"cron", cron, base, base.Add(time.Second), base.Add(15 * time.Minute)},
+		{"cron behind", cron, base, base.Add(50 * time.Minute), base.Add(60 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextSlot(tt.schedule, tt.slot, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("nextSlot = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissedSlots(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cron := &Schedule{Type: ScheduleTypeCron, Expression: "*/15 * * * *", Timezone: "UTC"}
+
+	slots, err := missedSlots(cron, base, base.Add(50*time.Minute), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 4 || !slots[3].Equal(base.Add(45*time.Minute)) {
+		t.Errorf("missed cron slots = %v", slots)
+	}
+
+	interval := &Schedule{Type: ScheduleTypeInterval, Expression: "1m", Timezone: "UTC"}
+	slots, _ = missedSlots(interval, base, base.Add(time.Hour), 10)
+	if len(slots) != 10 {
+		t.Errorf("expected limit of 10 slots, got %d", len(slots))
+	}
+
+	oneTime := &Schedule{Type: ScheduleTypeOneTime, Expression: base.Format(time.RFC3339)}
+	slots, _ = missedSlots(oneTime, base, base.Add(time.Hour), 10)
+	if len(slots) != 1 {
+		t.Errorf("expected one missed one-time slot, got %d", len(slots))
+	}
+}
+
+func TestScheduler_ProcessDueWithJitter(t *testing.T) {
+	db := testDB(t)
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 11, 59, 0, 0, time.UTC)}
+	s, _ := newTestScheduler(t, db, clock)
+	ctx := context.Background()
+
+	schedule := &Schedule{
+		Name:       "jittered",
+		FunctionID: "fn",
+		Type:       ScheduleTypeCron,
+		Expression: "0 * * * *",
+		Timezone:   "UTC",
+		Enabled:    true,
+		Config:     ScheduleConfig{Jitter: 10 * time.Minute},
+	}
+	if err := s.Create(ctx, schedule); err != nil {
+		t.Fatal(err)
+	}
+
+	slot := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	fireAt := slot.Add(jitterFor(schedule.ID, slot, 10*time.Minute))
+	if !schedule.NextRun.Equal(fireAt) {
+		t.Fatalf("NextRun = %v, want slot plus jitter %v", schedule.NextRun, fireAt)
+	}
+
+	// Due at the slot but not yet at its jittered fire time.
+	clock.now = fireAt.Add(-time.Second)
+	if err := s.ProcessDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := scheduledEvents(t, db); len(got) != 0 {
+		t.Fatalf("fired before the jittered time: %v", got)
+	}
+
+	clock.now = fireAt
+	if err := s.ProcessDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := scheduledEvents(t, db); len(got) != 1 || got[0] != "2026-01-01T12:00:00Z" {
+		t.Fatalf("scheduled events = %v", got)
+	}
+
+	// The next fire time is derived from the next slot, not the jittered time.
+	updated, _ := s.Get(ctx, schedule.ID)
+	nextSlot := slot.Add(time.Hour)
+	if want := nextSlot.Add(jitterFor(schedule.ID, nextSlot, 10*time.Minute)); !updated.NextRun.Equal(want) {
+		t.Errorf("NextRun = %v, want %v", updated.NextRun, want)
+	}
+	state, _ := s.stateStore.Get(ctx, schedule.ID)
+	if state.NextSlotAt == nil || !state.NextSlotAt.Equal(nextSlot) {
+		t.Errorf("NextSlotAt = %v, want %v", state.NextSlotAt, nextSlot)
+	}
+	if state.LastExecutionAt == nil || !state.LastExecutionAt.Equal(fireAt) {
+		t.Errorf("LastExecutionAt = %v, want %v", state.LastExecutionAt, fireAt)
+	}
+}
+
+func TestScheduler_OverlapPolicies(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		policy OverlapPolicy
+		// Slots fired while the first run is still in progress, then after
+		// it has been processed.
+		whileBusy []string
+		afterDone []string
+	}{
+		{OverlapSkip, []string{"12:00"}, []string{"12:00", "12:03"}},
+		{OverlapQueue, []string{"12:00"}, []string{"12:00", "12:01"}},
+		{OverlapAllow, []string{"12:00", "12:01", "12:02"}, []string{"12:00", "12:01", "12:02", "12:03"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			db := testDB(t)
+			clock := &fakeClock{now: start.Add(-time.Minute)}
+			s, eventBus := newTestScheduler(t, db, clock)
+			ctx := context.Background()
+
+			schedule := &Schedule{
+				Name:       "every-minute",
+				FunctionID: "fn",
+				Type:       ScheduleTypeInterval,
+				Expression: "1m",
+				Timezone:   "UTC",
+				Enabled:    true,
+				Config:     ScheduleConfig{Overlap: tt.policy},
+			}
+			if err := s.Create(ctx, schedule); err != nil {
+				t.Fatal(err)
+			}
+
+			// Three slots pass without the event bus processing the first run.
+			for range 3 {
+				clock.Advance(time.Minute)
+				if err := s.ProcessDue(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			assertSlots(t, scheduledEvents(t, db), tt.whileBusy)
+
+			if err := eventBus.ProcessPending(ctx); err != nil {
+				t.Fatal(err)
+			}
+			clock.Advance(time.Minute)
+			if err := s.ProcessDue(ctx); err != nil {
+				t.Fatal(err)
+			}
+			assertSlots(t, scheduledEvents(t, db), tt.afterDone)
+		})
+	}
+}
+
+func assertSlots(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("fired slots = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != "2026-01-01T"+want[i]+":00Z" {
+			t.Fatalf("fired slots = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestScheduler_OverlapLockSurvivesRestart(t *testing.T) {
+	db := testDB(t)
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 11, 59, 0, 0, time.UTC)}
+	s, _ := newTestScheduler(t, db, clock)
+	ctx := context.Background()
+
+	schedule := &Schedule{
+		Name: "locked", FunctionID: "fn", Type: ScheduleTypeInterval, Expression: "1m",
+		Timezone: "UTC", Enabled: true,
+	}
+	if err := s.Create(ctx, schedule); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if err := s.ProcessDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new scheduler on the same database still sees the unprocessed run.
+	clock.Advance(time.Minute)
+	restarted, eventBus := newTestScheduler(t, db, clock)
+	if err := restarted.RecoverSchedules(ctx, &RecoveryConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.ProcessDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertSlots(t, scheduledEvents(t, db), []string{"12:00"})
+
+	if err := eventBus.ProcessPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if err := restarted.ProcessDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertSlots(t, scheduledEvents(t, db), []string{"12:00", "12:02"})
+}
+
+func TestScheduler_CatchUpPolicies(t *testing.T) {
+	tests := []struct {
+		policy CatchUpPolicy
+		want   []string
+	}{
+		{CatchUpNone, nil},
+		{CatchUpOne, []string{"12:00"}},
+		{CatchUpAll, []string{"12:00", "12:15", "12:30", "12:45"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			db := testDB(t)
+			clock := &fakeClock{now: time.Date(2026, 1, 1, 11, 50, 0, 0, time.UTC)}
+			s, _ := newTestScheduler(t, db, clock)
+			ctx := context.Background()
+
+			schedule := &Schedule{
+				Name:       "quarter-hourly",
+				FunctionID: "fn",
+				Type:       ScheduleTypeCron,
+				Expression: "*/15 * * * *",
+				Timezone:   "UTC",
+				Enabled:    true,
+				Config:     ScheduleConfig{CatchUp: tt.policy},
+			}
+			if err := s.Create(ctx, schedule); err != nil {
+				t.Fatal(err)
+			}
+
+			// The server is down from 11:50 to 12:50 and restarts.
+			clock.now = time.Date(2026, 1, 1, 12, 50, 0, 0, time.UTC)
+			restarted, _ := newTestScheduler(t, db, clock)
+			if err := restarted.RecoverSchedules(ctx, &RecoveryConfig{}); err != nil {
+				t.Fatal(err)
+			}
+			assertSlots(t, scheduledEvents(t, db), tt.want)
+
+			updated, _ := restarted.Get(ctx, schedule.ID)
+			if want := time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC); !updated.NextRun.Equal(want) {
+				t.Errorf("NextRun = %v, want %v", updated.NextRun, want)
+			}
+		})
+	}
+}
+
+func TestScheduleCatchUpDefaults(t *testing.T) {
+	cron := &Schedule{Type: ScheduleTypeCron}
+	if got := cron.catchUp(&RecoveryConfig{}); got != CatchUpNone {
+		t.Errorf("default = %s, want none", got)
+	}
+	if got := cron.catchUp(&RecoveryConfig{EnableCatchup: true}); got != CatchUpAll {
+		t.Errorf("with EnableCatchup = %s, want all", got)
+	}
+	oneTime := &Schedule{Type: ScheduleTypeOneTime}
+	if got := oneTime.catchUp(&RecoveryConfig{}); got != CatchUpOne {
+		t.Errorf("one-time default = %s, want one", got)
+	}
+	cron.Config.CatchUp = CatchUpOne
+	if got := cron.catchUp(&RecoveryConfig{EnableCatchup: true}); got != CatchUpOne {
+		t.Errorf("explicit policy = %s, want one", got)
+	}
+
+	if got := (ScheduleConfig{}).overlap(); got != OverlapSkip {
+		t.Errorf("default overlap = %s, want skip", got)
+	}
+	if got := (ScheduleConfig{MaxOverlap: 3}).overlap(); got != OverlapAllow {
+		t.Errorf("legacy max_overlap overlap = %s, want allow", got)
+	}
+	if err := (ScheduleConfig{Overlap: "sometimes"}).Validate(); err == nil {
+		t.Error("expected unknown overlap policy to be rejected")
+	}
+}
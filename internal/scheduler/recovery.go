@@ -4,12 +4,13 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 type RecoveryConfig struct {
+	// EnableCatchup runs every missed fire time of schedules that do not set
+	// their own catch-up policy.
 	EnableCatchup bool
 }
 
@@ -49,6 +50,8 @@ func (s *Scheduler) RecoverSchedules(ctx context.Context, config *RecoveryConfig
 	return nil
 }
 
+// recoverSchedule restores a schedule's overlap lock and applies its
+// catch-up policy to the fire times missed while the server was down.
 func (s *Scheduler) recoverSchedule(ctx context.Context, schedule *Schedule, config *RecoveryConfig) error {
 	if !schedule.Enabled {
 		log.Debug().
@@ -62,162 +65,66 @@ func (s *Scheduler) recoverSchedule(ctx context.Context, schedule *Schedule, con
 	if err != nil {
 		return fmt.Errorf("loading schedule state: %w", err)
 	}
-
-	if state == nil {
-		state = &ScheduleState{
-			ScheduleID:     schedule.ID,
-			ExecutionCount: 0,
-		}
+	if state != nil && state.LockEventID != "" {
+		s.trackRun(schedule.ID, state.LockEventID)
+	}
+	if schedule.NextRun == nil {
+		return nil
 	}
 
-	now := time.Now().UTC()
+	now := s.now().UTC()
+	slot := currentSlot(schedule, state)
 
-	if schedule.NextRun != nil && schedule.NextRun.Before(now) {
-		missedExecutions, err := s.calculateMissedExecutions(schedule, state, now)
-		if err != nil {
-			log.Warn().
-				Err(err).
-				Str("schedule_id", schedule.ID).
-				Str("schedule_name", schedule.Name).
-				Msg("Failed to calculate missed executions")
-		} else if missedExecutions > 0 {
-			log.Info().
-				Str("schedule_id", schedule.ID).
-				Str("schedule_name", schedule.Name).
-				Int("missed_count", missedExecutions).
-				Msg("Detected missed executions during downtime")
-
-			if config.EnableCatchup {
-				if err := s.executeCatchup(ctx, schedule, missedExecutions); err != nil {
-					log.Error().
-						Err(err).
-						Str("schedule_id", schedule.ID).
-						Str("schedule_name", schedule.Name).
-						Msg("Failed to execute catch-up")
-				}
-			} else {
-				log.Info().
-					Str("schedule_id", schedule.ID).
-					Str("schedule_name", schedule.Name).
-					Msg("Catch-up disabled, skipping missed executions")
-
-				nextRun, calcErr := CalculateNextRun(schedule, now)
-				if calcErr == nil {
-					schedule.NextRun = &nextRun
-					if updateErr := s.store.UpdateNextRun(ctx, schedule.ID, nextRun, now); updateErr != nil {
-						log.Error().
-							Err(updateErr).
-							Str("schedule_id", schedule.ID).
-							Msg("Failed to update next_run during recovery")
-					}
-
-					state.NextExecutionAt = &nextRun
-					state.LastExecutionAt = &now
-					if saveErr := s.stateStore.Save(ctx, state); saveErr != nil {
-						log.Error().
-							Err(saveErr).
-							Str("schedule_id", schedule.ID).
-							Msg("Failed to save state during recovery")
-					}
-				}
+	if !schedule.NextRun.Before(now) {
+		if state == nil || state.NextExecutionAt == nil {
+			if err := s.stateStore.SetNext(ctx, schedule.ID, slot, *schedule.NextRun); err != nil {
+				return fmt.Errorf("saving initial state: %w", err)
 			}
 		}
+		log.Debug().
+			Str("schedule_id", schedule.ID).
+			Str("schedule_name", schedule.Name).
+			Time("next_run", *schedule.NextRun).
+			Msg("Schedule recovered")
+		return nil
 	}
 
-	if state.NextExecutionAt == nil && schedule.NextRun != nil {
-		state.NextExecutionAt = schedule.NextRun
-		if err := s.stateStore.Save(ctx, state); err != nil {
-			return fmt.Errorf("saving initial state: %w", err)
-		}
+	missed, err := missedSlots(schedule, slot, now, 1000)
+	if err != nil {
+		return fmt.Errorf("calculating missed executions: %w", err)
 	}
+	policy := schedule.catchUp(config)
 
-	log.Debug().
+	log.Info().
 		Str("schedule_id", schedule.ID).
 		Str("schedule_name", schedule.Name).
-		Time("next_run", *schedule.NextRun).
-		Msg("Schedule recovered")
+		Int("missed_count", len(missed)).
+		Str("catch_up", string(policy)).
+		Msg("Detected missed executions during downtime")
 
-	return nil
-}
-
-func (s *Scheduler) calculateMissedExecutions(schedule *Schedule, state *ScheduleState, now time.Time) (int, error) {
-	if schedule.NextRun == nil || !schedule.NextRun.Before(now) {
-		return 0, nil
-	}
-
-	switch schedule.Type {
-	case ScheduleTypeOneTime:
-		if state.LastExecutionAt != nil {
-			return 0, nil
-		}
-		return 1, nil
+	switch policy {
+	case CatchUpOne:
+		// Runs like any due schedule, so the overlap policy still applies.
+		return s.processSchedule(ctx, schedule, now)
 
-	case ScheduleTypeInterval:
-		return 1, nil
-
-	case ScheduleTypeCron:
-		missedCount := 0
-		currentTime := *schedule.NextRun
-		parser := NewCronParser()
-
-		for currentTime.Before(now) && missedCount < 1000 {
-			missedCount++
-			nextTime, err := parser.NextRun(schedule.Expression, schedule.Timezone, currentTime)
-			if err != nil {
-				return missedCount, fmt.Errorf("calculating next cron run: %w", err)
-			}
-			currentTime = nextTime
-		}
-
-		return missedCount, nil
-
-	default:
-		return 0, fmt.Errorf("unknown schedule type: %s", schedule.Type)
-	}
-}
-
-func (s *Scheduler) executeCatchup(ctx context.Context, schedule *Schedule, missedCount int) error {
-	switch schedule.Type {
-	case ScheduleTypeOneTime:
-		if missedCount > 0 {
-			if err := s.processSchedule(ctx, schedule); err != nil {
-				return fmt.Errorf("executing one-time catch-up: %w", err)
-			}
-		}
-
-	case ScheduleTypeInterval:
-		if missedCount > 0 {
-			if err := s.processSchedule(ctx, schedule); err != nil {
-				return fmt.Errorf("executing interval catch-up: %w", err)
-			}
-		}
-
-	case ScheduleTypeCron:
-		execLimit := missedCount
-		if execLimit > 100 {
-			execLimit = 100
+	case CatchUpAll:
+		if len(missed) > maxCatchUp {
 			log.Warn().
 				Str("schedule_id", schedule.ID).
 				Str("schedule_name", schedule.Name).
-				Int("missed_count", missedCount).
-				Int("exec_limit", execLimit).
+				Int("missed_count", len(missed)).
+				Int("exec_limit", maxCatchUp).
 				Msg("Limiting catch-up executions to prevent overload")
+			missed = missed[len(missed)-maxCatchUp:]
 		}
-
-		for i := 0; i < execLimit; i++ {
-			if err := s.processSchedule(ctx, schedule); err != nil {
-				log.Error().
-					Err(err).
-					Str("schedule_id", schedule.ID).
-					Str("schedule_name", schedule.Name).
-					Int("execution_number", i+1).
-					Msg("Failed to execute catch-up iteration")
-				continue
+		for _, missedSlot := range missed {
+			if err := s.fire(ctx, schedule, missedSlot, now); err != nil {
+				return fmt.Errorf("executing catch-up: %w", err)
 			}
-
-			time.Sleep(100 * time.Millisecond)
 		}
-	}
+		return s.advance(ctx, schedule, slot, now, len(missed) > 0)
 
-	return nil
+	default:
+		return s.advance(ctx, schedule, slot, now, false)
+	}
 }
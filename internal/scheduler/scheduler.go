@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
 	"github.com/watzon/alyx/internal/database"
@@ -21,8 +22,10 @@ type Scheduler struct {
 	ctx        context.Context
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
-	running    map[string]int // scheduleID -> count of running executions
+	running    map[string]int      // scheduleID -> count of running executions
+	inflight   map[string][]string // scheduleID -> events of runs not yet processed
 	runningMu  sync.RWMutex
+	now        func() time.Time
 }
 
 // Config holds configuration for Scheduler.
@@ -43,6 +46,8 @@ func NewScheduler(db *database.DB, eventBus *events.EventBus) *Scheduler {
 		ctx:        ctx,
 		cancel:     cancel,
 		running:    make(map[string]int),
+		inflight:   make(map[string][]string),
+		now:        time.Now,
 	}
 }
 
@@ -91,13 +96,14 @@ func (s *Scheduler) pollLoop(ctx context.Context, interval time.Duration) {
 
 // ProcessDue processes schedules that are due to run.
 func (s *Scheduler) ProcessDue(ctx context.Context) error {
-	schedules, err := s.store.GetDue(ctx, 100)
+	now := s.now().UTC()
+	schedules, err := s.store.GetDueAt(ctx, now, 100)
 	if err != nil {
 		return fmt.Errorf("getting due schedules: %w", err)
 	}
 
 	for _, schedule := range schedules {
-		if err := s.processSchedule(ctx, schedule); err != nil {
+		if err := s.processSchedule(ctx, schedule, now); err != nil {
 			log.Error().
 				Err(err).
 				Str("schedule_id", schedule.ID).
@@ -109,22 +115,60 @@ func (s *Scheduler) ProcessDue(ctx context.Context) error {
 	return nil
 }
 
-// processSchedule processes a single schedule.
-func (s *Scheduler) processSchedule(ctx context.Context, schedule *Schedule) error {
-	// Check concurrency limits
-	if !s.canRun(schedule) {
+// processSchedule runs a due schedule, subject to its overlap policy, and
+// moves it on to its next fire time.
+func (s *Scheduler) processSchedule(ctx context.Context, schedule *Schedule, now time.Time) error {
+	state, err := s.stateStore.Get(ctx, schedule.ID)
+	if err != nil {
+		return fmt.Errorf("loading schedule state: %w", err)
+	}
+	slot := currentSlot(schedule, state)
+
+	if err := s.refreshRunning(ctx, schedule.ID); err != nil {
+		return fmt.Errorf("checking previous run: %w", err)
+	}
+
+	policy := schedule.Config.overlap()
+	busy := s.runningCount(schedule.ID) > 0
+	switch {
+	case policy == OverlapQueue && busy:
 		log.Debug().
 			Str("schedule_id", schedule.ID).
 			Str("schedule_name", schedule.Name).
-			Msg("Skipping schedule due to concurrency limits")
+			Msg("Holding schedule until its previous run finishes")
 		return nil
+
+	case policy == OverlapSkip && busy, !s.canRun(schedule):
+		log.Debug().
+			Str("schedule_id", schedule.ID).
+			Str("schedule_name", schedule.Name).
+			Time("slot", slot).
+			Msg("Skipping schedule due to concurrency limits")
+		return s.advance(ctx, schedule, slot, now, false)
 	}
 
-	// Increment running count
-	s.incrementRunning(schedule.ID)
-	defer s.decrementRunning(schedule.ID)
+	if err := s.fire(ctx, schedule, slot, now); err != nil {
+		return err
+	}
+	return s.advance(ctx, schedule, slot, now, true)
+}
 
-	// Publish schedule event to event bus
+// currentSlot returns the fire time slot the schedule is due for. The
+// persisted slot is only used while it still belongs to the schedule's
+// next_run, which an update through the API may have replaced.
+func currentSlot(schedule *Schedule, state *ScheduleState) time.Time {
+	if state != nil && state.NextSlotAt != nil && state.NextExecutionAt != nil &&
+		schedule.NextRun != nil && state.NextExecutionAt.Equal(*schedule.NextRun) {
+		return *state.NextSlotAt
+	}
+	if schedule.NextRun != nil {
+		return *schedule.NextRun
+	}
+	return time.Time{}
+}
+
+// fire publishes the event that runs the schedule for slot.
+func (s *Scheduler) fire(ctx context.Context, schedule *Schedule, slot, now time.Time) error {
 	event := &events.Event{
 		Type:   events.EventTypeSchedule,
 		Source: "scheduler",
@@ -133,6 +177,7 @@ func (s *Scheduler) processSchedule(ctx context.Context, schedule *Schedule) err
 			"schedule_id":   schedule.ID,
 			"schedule_name": schedule.Name,
 			"function_id":   schedule.FunctionID,
+			"scheduled_at":  slot.UTC().Format(time.RFC3339),
 			"input":         schedule.Config.Input,
 		},
 		Metadata: events.EventMetadata{
@@ -147,46 +192,63 @@ func (s *Scheduler) processSchedule(ctx context.Context, schedule *Schedule) err
 	if err := s.eventBus.Publish(ctx, event); err != nil {
 		return fmt.Errorf("publishing schedule event: %w", err)
 	}
+	s.trackRun(schedule.ID, event.ID)
+	schedule.LastRun = &now
+
+	if err := s.stateStore.RecordFire(ctx, schedule.ID, event.ID, now); err != nil {
+		log.Error().
+			Err(err).
+			Str("schedule_id", schedule.ID).
+			Msg("Failed to persist scheduler state")
+	}
 
 	log.Debug().
 		Str("schedule_id", schedule.ID).
 		Str("schedule_name", schedule.Name).
 		Str("function_id", schedule.FunctionID).
+		Time("slot", slot).
 		Msg("Schedule event published")
 
-	// Update last_run
-	now := time.Now().UTC()
-	schedule.LastRun = &now
+	return nil
+}
 
-	// Calculate next_run
-	nextRun, err := CalculateNextRun(schedule, now)
-	if err != nil {
-		// For one-time schedules, this is expected after first run
-		if schedule.Type == ScheduleTypeOneTime {
-			log.Debug().
-				Str("schedule_id", schedule.ID).
-				Str("schedule_name", schedule.Name).
-				Msg("One-time schedule completed, disabling")
+// advance moves the schedule past slot and now to its next fire time, or
+// disables a one-time schedule. fired reports whether it ran for slot.
+func (s *Scheduler) advance(ctx context.Context, schedule *Schedule, slot, now time.Time, fired bool) error {
+	if schedule.Type == ScheduleTypeOneTime {
+		log.Debug().
+			Str("schedule_id", schedule.ID).
+			Str("schedule_name", schedule.Name).
+			Msg("One-time schedule completed, disabling")
 
-			// Disable the schedule
-			schedule.Enabled = false
+		schedule.Enabled = false
+		schedule.NextRun = nil
+		if fired {
 			schedule.LastRun = &now
-			schedule.NextRun = nil
-			if updateErr := s.store.Update(ctx, schedule); updateErr != nil {
-				return fmt.Errorf("disabling one-time schedule: %w", updateErr)
-			}
-			return nil
 		}
+		if err := s.store.Update(ctx, schedule); err != nil {
+			return fmt.Errorf("disabling one-time schedule: %w", err)
+		}
+		return nil
+	}
 
+	next, err := nextSlot(schedule, slot, now)
+	if err != nil {
 		return fmt.Errorf("calculating next run: %w", err)
 	}
+	fireAt := schedule.fireTime(next)
+	schedule.NextRun = &fireAt
 
-	// Update schedule
-	if err := s.store.UpdateNextRun(ctx, schedule.ID, nextRun, now); err != nil {
+	if fired {
+		err = s.store.UpdateNextRun(ctx, schedule.ID, fireAt, now)
+	} else {
+		err = s.store.SetNextRun(ctx, schedule.ID, fireAt)
+	}
+	if err != nil {
 		return fmt.Errorf("updating next_run: %w", err)
 	}
 
-	if err := s.stateStore.UpdateAfterExecution(ctx, schedule.ID, nextRun); err != nil {
+	if err := s.stateStore.SetNext(ctx, schedule.ID, next, fireAt); err != nil {
 		log.Error().
 			Err(err).
 			Str("schedule_id", schedule.ID).
@@ -196,7 +258,8 @@ func (s *Scheduler) processSchedule(ctx context.Context, schedule *Schedule) err
 	log.Debug().
 		Str("schedule_id", schedule.ID).
 		Str("schedule_name", schedule.Name).
-		Time("next_run", nextRun).
+		Time("next_slot", next).
+		Time("next_run", fireAt).
 		Msg("Schedule next_run updated")
 
 	return nil
@@ -222,26 +285,74 @@ func (s *Scheduler) canRun(schedule *Schedule) bool {
 	return true
 }
 
-// incrementRunning increments the running count for a schedule.
-func (s *Scheduler) incrementRunning(scheduleID string) {
+// runningCount returns the number of the schedule's runs in progress.
+func (s *Scheduler) runningCount(scheduleID string) int {
+	s.runningMu.RLock()
+	defer s.runningMu.RUnlock()
+	return s.running[scheduleID]
+}
+
+// trackRun counts the run published as eventID as in progress until the
+// event bus has processed it.
+func (s *Scheduler) trackRun(scheduleID, eventID string) {
 	s.runningMu.Lock()
 	defer s.runningMu.Unlock()
-	s.running[scheduleID]++
+	s.inflight[scheduleID] = append(s.inflight[scheduleID], eventID)
+	s.running[scheduleID] = len(s.inflight[scheduleID])
 }
 
-// decrementRunning decrements the running count for a schedule.
-func (s *Scheduler) decrementRunning(scheduleID string) {
+// refreshRunning stops counting the schedule's runs whose events have been
+// processed.
+func (s *Scheduler) refreshRunning(ctx context.Context, scheduleID string) error {
+	s.runningMu.RLock()
+	tracked := append([]string(nil), s.inflight[scheduleID]...)
+	s.runningMu.RUnlock()
+	if len(tracked) == 0 {
+		return nil
+	}
+
+	finished := make(map[string]bool)
+	for _, eventID := range tracked {
+		done, err := s.eventBus.Done(ctx, eventID)
+		if err != nil {
+			return err
+		}
+		finished[eventID] = done
+	}
+
 	s.runningMu.Lock()
 	defer s.runningMu.Unlock()
-
-	s.running[scheduleID]--
-	if s.running[scheduleID] <= 0 {
+	var still []string
+	for _, eventID := range s.inflight[scheduleID] {
+		if !finished[eventID] {
+			still = append(still, eventID)
+		}
+	}
+	if len(still) == 0 {
+		delete(s.inflight, scheduleID)
 		delete(s.running, scheduleID)
+	} else {
+		s.inflight[scheduleID] = still
+		s.running[scheduleID] = len(still)
 	}
+	return nil
 }
 
-// Create creates a new schedule.
+// Create creates a new schedule. Without a NextRun, it is first due at the
+// schedule's next fire time after now, plus its jitter.
 func (s *Scheduler) Create(ctx context.Context, schedule *Schedule) error {
+	if schedule.ID == "" {
+		schedule.ID = uuid.New().String()
+	}
+	slot := schedule.NextRun
+	if schedule.NextRun == nil {
+		next, fireAt, err := NextFire(schedule, s.now().UTC())
+		if err != nil {
+			return fmt.Errorf("calculating initial next_run: %w", err)
+		}
+		slot, schedule.NextRun = &next, &fireAt
+	}
+
 	if err := s.store.Create(ctx, schedule); err != nil {
 		return err
 	}
@@ -249,6 +360,7 @@ func (s *Scheduler) Create(ctx context.Context, schedule *Schedule) error {
 	state := &ScheduleState{
 		ScheduleID:      schedule.ID,
 		NextExecutionAt: schedule.NextRun,
+		NextSlotAt:      slot,
 		ExecutionCount:  0,
 	}
 	if err := s.stateStore.Save(ctx, state); err != nil {
@@ -266,6 +378,27 @@ func (s *Scheduler) Update(ctx context.Context, schedule *Schedule) error {
 	return s.store.Update(ctx, schedule)
 }
 
+// Reschedule updates a schedule whose timing changed, moving it to its next
+// fire time after now.
+func (s *Scheduler) Reschedule(ctx context.Context, schedule *Schedule) error {
+	next, fireAt, err := NextFire(schedule, s.now().UTC())
+	if err != nil {
+		return err
+	}
+	schedule.NextRun = &fireAt
+
+	if err := s.store.Update(ctx, schedule); err != nil {
+		return err
+	}
+	if err := s.stateStore.SetNext(ctx, schedule.ID, next, fireAt); err != nil {
+		log.Error().
+			Err(err).
+			Str("schedule_id", schedule.ID).
+			Msg("Failed to persist scheduler state")
+	}
+	return nil
+}
+
 // Delete removes a schedule.
 func (s *Scheduler) Delete(ctx context.Context, scheduleID string) error {
 	if err := s.store.Delete(ctx, scheduleID); err != nil {
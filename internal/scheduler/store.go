@@ -154,6 +154,26 @@ func (s *Store) UpdateNextRun(ctx context.Context, scheduleID string, nextRun, l
 	return nil
 }
 
+// SetNextRun updates the next_run field of a schedule that was skipped.
+func (s *Store) SetNextRun(ctx context.Context, scheduleID string, nextRun time.Time) error {
+	query := `
+		UPDATE schedules
+		SET next_run = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		nextRun.UTC().Format(time.RFC3339),
+		time.Now().UTC().Format(time.RFC3339),
+		scheduleID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating next_run: %w", err)
+	}
+
+	return nil
+}
+
 // UpdateStatus updates the last_status field.
 func (s *Store) UpdateStatus(ctx context.Context, scheduleID, status string) error {
 	query := `
@@ -226,6 +246,11 @@ func (s *Store) List(ctx context.Context) ([]*Schedule, error) {
 
 // GetDue retrieves schedules that are due to run.
 func (s *Store) GetDue(ctx context.Context, limit int) ([]*Schedule, error) {
+	return s.GetDueAt(ctx, time.Now(), limit)
+}
+
+// GetDueAt retrieves schedules that are due to run at now.
+func (s *Store) GetDueAt(ctx context.Context, now time.Time, limit int) ([]*Schedule, error) {
 	query := `
 		SELECT id, name, function_id, type, expression, timezone, next_run, last_run, last_status, enabled, config, created_at, updated_at
 		FROM schedules
@@ -236,9 +261,7 @@ func (s *Store) GetDue(ctx context.Context, limit int) ([]*Schedule, error) {
 		LIMIT ?
 	`
 
-	now := time.Now().UTC().Format(time.RFC3339)
-
-	rows, err := s.db.QueryContext(ctx, query, now, limit)
+	rows, err := s.db.QueryContext(ctx, query, now.UTC().Format(time.RFC3339), limit)
 	if err != nil {
 		return nil, fmt.Errorf("querying due schedules: %w", err)
 	}
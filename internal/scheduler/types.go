@@ -33,9 +33,12 @@ type Schedule struct {
 
 // ScheduleConfig contains configuration for a schedule.
 type ScheduleConfig struct {
-	SkipIfRunning  bool // Skip execution if previous run still active
-	MaxOverlap     int  // Maximum concurrent executions (0 = unlimited)
-	RetryOnFailure bool // Retry if function fails
-	MaxRetries     int  // Maximum retry attempts
-	Input          any  // Static input for scheduled runs
+	SkipIfRunning  bool          // Skip execution if previous run still active
+	MaxOverlap     int           // Maximum concurrent executions (0 = unlimited)
+	Overlap        OverlapPolicy // What to do when due while the previous run is active (default skip)
+	Jitter         time.Duration // Run up to this much after each fire time, to spread load
+	CatchUp        CatchUpPolicy // Fire times missed during downtime to run on recovery
+	RetryOnFailure bool          // Retry if function fails
+	MaxRetries     int           // Maximum retry attempts
+	Input          any           // Static input for scheduled runs
 }
@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+)
+
+var (
+	bucketRuleEnvOnce sync.Once
+	bucketRuleEnv     *cel.Env
+	bucketRuleEnvErr  error
+)
+
+// bucketRuleEnvironment returns the CEL environment bucket rules are checked
+// against: the variables and functions of the rules engine. file holds the
+// file's name, size, mime and owner_id, and doc the document referencing a
+// downloaded file.
+func bucketRuleEnvironment() (*cel.Env, error) {
+	bucketRuleEnvOnce.Do(func() {
+		bucketRuleEnv, bucketRuleEnvErr = cel.NewEnv(
+			cel.Variable("auth", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("file", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("doc", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Function("exists",
+				cel.Overload("exists_string_map", []*cel.Type{cel.StringType, cel.MapType(cel.StringType, cel.DynType)}, cel.BoolType),
+			),
+			cel.Function("has",
+				cel.Overload("has_dyn_string", []*cel.Type{cel.DynType, cel.StringType}, cel.BoolType),
+			),
+		)
+	})
+	return bucketRuleEnv, bucketRuleEnvErr
+}
+
+// validateBucketRules reports the rules of a bucket that do not compile or
+// do not return a bool.
+func validateBucketRules(path string, r *Rules) ValidationErrors {
+	if r == nil {
+		return nil
+	}
+	env, err := bucketRuleEnvironment()
+	if err != nil {
+		return ValidationErrors{{Path: path, Message: fmt.Sprintf("creating CEL environment: %v", err)}}
+	}
+
+	var errs ValidationErrors
+	for _, rule := range []struct{ op, expr string }{
+		{"create", r.Create},
+		{"read", r.Read},
+		{"update", r.Update},
+		{"delete", r.Delete},
+		{"download", r.Download},
+		{"upload", r.Upload},
+	} {
+		if rule.expr == "" {
+			continue
+		}
+		ast, issues := env.Compile(rule.expr)
+		if issues != nil && issues.Err() != nil {
+			errs = append(errs, &ValidationError{Path: path + "." + rule.op, Message: fmt.Sprintf("invalid rule: %v", issues.Err())})
+			continue
+		}
+		if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+			errs = append(errs, &ValidationError{Path: path + "." + rule.op, Message: fmt.Sprintf("rule must return a bool, not %s", t)})
+		}
+	}
+	if r.History != "" {
+		errs = append(errs, &ValidationError{Path: path + ".history", Message: "buckets have no history rule"})
+	}
+	return errs
+}
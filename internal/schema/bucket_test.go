@@ -3,6 +3,7 @@ package schema
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseBucket_Valid(t *testing.T) {
@@ -338,3 +339,71 @@ buckets:
 		t.Errorf("expected documents backend 's3', got %q", schema.Buckets["documents"].Backend)
 	}
 }
+
+func TestParseBucket_GC(t *testing.T) {
+	base := `
+version: 1
+
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+
+buckets:
+  uploads:
+    backend: local
+    gc:
+`
+	schema, err := Parse([]byte(base + "      interval: 1d\n      grace_period: 12h\n"))
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	gc := schema.Buckets["uploads"].GC
+	if gc.IntervalDuration() != 24*time.Hour || gc.GracePeriodDuration() != 12*time.Hour {
+		t.Errorf("unexpected gc durations: %+v", gc)
+	}
+
+	for _, invalid := range []string{"      grace_period: 12h\n", "      interval: soon\n", "      interval: 1d\n      grace_period: -1h\n"} {
+		if _, err := Parse([]byte(base + invalid)); err == nil {
+			t.Errorf("expected a validation error for gc:\n%s", invalid)
+		}
+	}
+}
+
+func TestParseBucket_Rules(t *testing.T) {
+	base := `
+version: 1
+
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+
+buckets:
+  uploads:
+    backend: local
+    rules:
+`
+	schema, err := Parse([]byte(base + "      upload: \"has(auth.id) && file.size <= 1024\"\n      download: \"true\"\n"))
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	if got := schema.Buckets["uploads"].Rules.UploadRule(); got != "has(auth.id) && file.size <= 1024" {
+		t.Errorf("unexpected upload rule %q", got)
+	}
+
+	for _, invalid := range []string{"      upload: \"auth.id ==\"\n", "      download: \"'public'\"\n"} {
+		_, err := Parse([]byte(base + invalid))
+		if err == nil {
+			t.Errorf("expected a validation error for rules:\n%s", invalid)
+			continue
+		}
+		if !strings.Contains(err.Error(), "buckets.uploads.rules.") {
+			t.Errorf("expected the error to name the rule, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Check is a collection-level constraint across fields, written as a CEL
+// expression over doc, the document as it would be after the write, and
+// old, the document before it on updates (empty on creates). A write fails
+// when a check does not evaluate to true.
+type Check struct {
+	Name string `yaml:"name" json:"name"`
+	Expr string `yaml:"expr" json:"expr"`
+	// Message is returned when the check fails. Unset, a message naming
+	// the check is returned.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+
+	once    sync.Once
+	program cel.Program
+	err     error
+}
+
+// FailureMessage returns the message reported when the check fails.
+func (c *Check) FailureMessage() string {
+	if c.Message != "" {
+		return c.Message
+	}
+	return fmt.Sprintf("Check %q failed", c.Name)
+}
+
+var (
+	checkEnvOnce sync.Once
+	checkEnv     *cel.Env
+	checkEnvErr  error
+)
+
+// checkEnvironment returns the CEL environment checks compile against.
+func checkEnvironment() (*cel.Env, error) {
+	checkEnvOnce.Do(func() {
+		checkEnv, checkEnvErr = cel.NewEnv(
+			cel.Variable("doc", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("old", cel.MapType(cel.StringType, cel.DynType)),
+		)
+	})
+	return checkEnv, checkEnvErr
+}
+
+// ValidateCheckExpression reports whether expr compiles as a check: against
+// doc and old, returning a bool.
+func ValidateCheckExpression(expr string) error {
+	_, err := compileCheck(expr)
+	return err
+}
+
+func compileCheck(expr string) (cel.Program, error) {
+	env, err := checkEnvironment()
+	if err != nil {
+		return nil, fmt.Errorf("creating CEL environment: %w", err)
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return nil, fmt.Errorf("check must return a bool, not %s", t)
+	}
+	return env.Program(ast)
+}
+
+func (c *Check) compiled() (cel.Program, error) {
+	c.once.Do(func() {
+		c.program, c.err = compileCheck(c.Expr)
+	})
+	return c.program, c.err
+}
+
+// ErrCheckFailed is wrapped by the error RunChecks returns for a failed
+// check.
+var ErrCheckFailed = errors.New("check failed")
+
+// CheckError reports the check a write failed.
+type CheckError struct {
+	Check *Check
+	// Err is the evaluation error, if the check could not be evaluated.
+	Err error
+}
+
+func (e *CheckError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("check %q could not be evaluated: %v", e.Check.Name, e.Err)
+	}
+	return e.Check.FailureMessage()
+}
+
+func (e *CheckError) Unwrap() error { return ErrCheckFailed }
+
+// RunChecks evaluates the collection's checks in order against doc, the
+// document after a write, and old, the document before it or nil on create.
+// It returns a *CheckError for the first check that is not true.
+//
+// Every field is visible in doc and old: an unset field has its static
+// default, or is null. Values are normalized to their field types, so stored
+// documents and request bodies compare alike.
+func (c *Collection) RunChecks(doc, old map[string]any) error {
+	if len(c.Checks) == 0 {
+		return nil
+	}
+	vars := map[string]any{
+		"doc": c.checkDocument(doc),
+		"old": map[string]any{},
+	}
+	if old != nil {
+		vars["old"] = c.checkDocument(old)
+	}
+
+	for _, check := range c.Checks {
+		program, err := check.compiled()
+		if err != nil {
+			return &CheckError{Check: check, Err: err}
+		}
+		result, _, err := program.Eval(vars)
+		if err != nil {
+			return &CheckError{Check: check, Err: err}
+		}
+		if ok, isBool := result.Value().(bool); !isBool || !ok {
+			return &CheckError{Check: check}
+		}
+	}
+	return nil
+}
+
+// checkDocument returns the fields of doc as checks see them.
+func (c *Collection) checkDocument(doc map[string]any) map[string]any {
+	out := make(map[string]any, len(c.Fields))
+	for name, field := range c.Fields {
+		value, ok := doc[name]
+		if !ok {
+			value = field.StaticDefault()
+		}
+		out[name] = checkValue(field, value)
+	}
+	return out
+}
+
+func checkValue(field *Field, value any) any {
+	switch field.Type {
+	case FieldTypeInt:
+		if f, ok := value.(float64); ok && f == float64(int64(f)) {
+			return int64(f)
+		}
+	case FieldTypeFloat:
+		switch v := value.(type) {
+		case int:
+			return float64(v)
+		case int64:
+			return float64(v)
+		}
+	case FieldTypeBool:
+		if i, ok := value.(int64); ok {
+			return i != 0
+		}
+	case FieldTypeTimestamp:
+		if s, ok := value.(string); ok {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				return t
+			}
+		}
+	}
+	return value
+}
+
+func validateChecks(path string, col *Collection) ValidationErrors {
+	var errs ValidationErrors
+	seen := make(map[string]bool, len(col.Checks))
+
+	for i, check := range col.Checks {
+		checkPath := fmt.Sprintf("%s[%d]", path, i)
+		if check.Name == "" {
+			errs = append(errs, &ValidationError{
+				Path:    checkPath + ".name",
+				Message: "required field",
+			})
+		} else if seen[check.Name] {
+			errs = append(errs, &ValidationError{
+				Path:    checkPath + ".name",
+				Message: fmt.Sprintf("duplicate check name %q", check.Name),
+			})
+		}
+		seen[check.Name] = true
+
+		if check.Expr == "" {
+			errs = append(errs, &ValidationError{
+				Path:    checkPath + ".expr",
+				Message: "required field",
+			})
+		} else if _, err := check.compiled(); err != nil {
+			errs = append(errs, &ValidationError{
+				Path:    checkPath + ".expr",
+				Message: fmt.Sprintf("invalid expression: %v", err),
+			})
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const checksTestSchema = `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      published:
+        type: bool
+        default: false
+      published_at:
+        type: timestamp
+        nullable: true
+      views:
+        type: int
+        default: 0
+`
+
+func TestParseChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		checks  string
+		wantErr string
+	}{
+		{"valid", "    checks:\n      - name: published_at_set\n        expr: \"!doc.published || doc.published_at != null\"\n", ""},
+		{"missing name", "    checks:\n      - expr: \"true\"\n", "checks[0].name"},
+		{"duplicate name", "    checks:\n      - name: a\n        expr: \"true\"\n      - name: a\n        expr: \"true\"\n", "duplicate check name"},
+		{"missing expr", "    checks:\n      - name: a\n", "checks[0].expr"},
+		{"syntax error", "    checks:\n      - name: a\n        expr: \"doc.views >\"\n", "invalid expression"},
+		{"undeclared variable", "    checks:\n      - name: a\n        expr: \"auth.id != ''\"\n", "undeclared reference"},
+		{"not a bool", "    checks:\n      - name: a\n        expr: \"1 + 1\"\n", "must return a bool"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(checksTestSchema + tt.checks))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRunChecks(t *testing.T) {
+	s, err := Parse([]byte(checksTestSchema + `    checks:
+      - name: published_at_set
+        expr: "!doc.published || doc.published_at != null"
+        message: Published posts need a publish date
+      - name: views_only_grow
+        expr: "!has(old.views) || old.views == null || doc.views >= old.views"
+      - name: published_after_epoch
+        expr: "doc.published_at == null || doc.published_at > timestamp('1970-01-01T00:00:00Z')"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	col := s.Collections["posts"]
+
+	tests := []struct {
+		name  string
+		doc   map[string]any
+		old   map[string]any
+		check string
+	}{
+		{"draft", map[string]any{"published": false}, nil, ""},
+		{"published without date", map[string]any{"published": true}, nil, "published_at_set"},
+		{"published with date", map[string]any{"published": true, "published_at": "2024-05-01T10:00:00Z"}, nil, ""},
+		{"stored bool", map[string]any{"published": int64(1)}, nil, "published_at_set"},
+		{"views grow", map[string]any{"views": float64(5)}, map[string]any{"views": int64(3)}, ""},
+		{"views shrink", map[string]any{"views": float64(2)}, map[string]any{"views": int64(3)}, "views_only_grow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := col.RunChecks(tt.doc, tt.old)
+			if tt.check == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var ce *CheckError
+			if !errors.As(err, &ce) || !errors.Is(err, ErrCheckFailed) {
+				t.Fatalf("expected a check error, got %v", err)
+			}
+			if ce.Check.Name != tt.check {
+				t.Errorf("expected %s to fail, got %s (%v)", tt.check, ce.Check.Name, ce.Err)
+			}
+		})
+	}
+
+	err = col.RunChecks(map[string]any{"published": true}, nil)
+	if err == nil || err.Error() != "Published posts need a publish date" {
+		t.Errorf("expected the custom message, got %v", err)
+	}
+}
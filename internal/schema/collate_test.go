@@ -0,0 +1,123 @@
+package schema
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+const collateTestSchema = `
+version: 1
+collections:
+  accounts:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      email: { type: email, unique: true%s }
+      name: { type: string, index: true }
+`
+
+func collateSchema(t *testing.T, extra string) *Schema {
+	t.Helper()
+	s, err := Parse([]byte(strings.Replace(collateTestSchema, "%s", extra, 1)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return s
+}
+
+func TestParseCollate(t *testing.T) {
+	s := collateSchema(t, ", collate: nocase")
+	email := s.Collections["accounts"].Fields["email"]
+	if !email.CaseInsensitive() {
+		t.Fatal("expected email to be case-insensitive")
+	}
+	if got := NewSQLGenerator(s).GenerateCreateTable(s.Collections["accounts"]); !strings.Contains(got, "email TEXT COLLATE NOCASE NOT NULL UNIQUE") {
+		t.Errorf("expected a NOCASE column, got:\n%s", got)
+	}
+
+	for _, tc := range []struct{ field, want string }{
+		{"{ type: string, collate: upper }", "invalid collate"},
+		{"{ type: int, collate: nocase }", "collate can only be used with"},
+		{"{ type: string, nullable: true, encrypted: true, collate: nocase }", "collate cannot be used with encrypted fields"},
+	} {
+		_, err := Parse([]byte("version: 1\ncollections:\n  c:\n    fields:\n      id: { type: id, primary: true, default: auto }\n      f: " + tc.field + "\n"))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.field, tc.want, err)
+		}
+	}
+}
+
+func TestCollateMigration(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "collate.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	binary := collateSchema(t, "")
+	nocase := collateSchema(t, ", collate: nocase")
+
+	migrator := NewMigrator(db, "", "")
+	if err := migrator.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.ApplySchema(binary); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		// The change triggers record writes here.
+		"CREATE TABLE _alyx_changes (collection TEXT, operation TEXT, doc_id TEXT, changed_fields TEXT)",
+		"INSERT INTO accounts (id, email, name) VALUES ('a', 'ada@example.com', 'Ada')",
+		"INSERT INTO accounts (id, email, name) VALUES ('b', 'ADA@example.com', 'Ada')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current, err := InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := NewDiffer().Diff(current, nocase)
+	if len(changes) != 1 || changes[0].Type != ChangeModifyField || changes[0].Safe {
+		t.Fatalf("expected one unsafe modify field change, got %v", changes)
+	}
+
+	errs := migrator.ValidateUnsafeChanges(changes)
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "duplicated ignoring case") {
+		t.Fatalf("expected a case-insensitive duplicate error, got %v", errs)
+	}
+
+	if _, err := db.Exec("DELETE FROM accounts WHERE id = 'b'"); err != nil {
+		t.Fatal(err)
+	}
+	if errs := migrator.ValidateUnsafeChanges(changes); len(errs) != 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+	if err := migrator.ApplyUnsafeChanges(changes, nocase); err != nil {
+		t.Fatal(err)
+	}
+
+	var id string
+	if err := db.QueryRow("SELECT id FROM accounts WHERE email = ?", "Ada@Example.com").Scan(&id); err != nil || id != "a" {
+		t.Errorf("case-insensitive lookup = %q, %v; want a", id, err)
+	}
+	if _, err := db.Exec("INSERT INTO accounts (id, email, name) VALUES ('c', 'ADA@EXAMPLE.COM', 'Ada')"); err == nil || !strings.Contains(err.Error(), "UNIQUE") {
+		t.Errorf("expected a unique violation ignoring case, got %v", err)
+	}
+
+	current, err = InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current.Collections["accounts"].Fields["name"].Index {
+		t.Error("expected the rebuilt table to keep its indexes")
+	}
+	if changes := NewDiffer().Diff(current, nocase); len(changes) != 0 {
+		t.Errorf("expected no changes after the rebuild, got %v", changes)
+	}
+}
@@ -0,0 +1,213 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Counter events, as listed in a counter's on.
+const (
+	CounterOnInsert = "insert"
+	CounterOnUpdate = "update"
+	CounterOnDelete = "delete"
+)
+
+// CounterEvents lists the events a counter may be kept up to date on.
+var CounterEvents = []string{CounterOnInsert, CounterOnUpdate, CounterOnDelete}
+
+// counterTriggerPrefix starts the name of every counter trigger.
+const counterTriggerPrefix = "_alyx_counter_"
+
+// Counter keeps an int field of another collection equal to the number of
+// documents of its collection that reference each target document through
+// ForeignKey. Triggers adjust the target field in the same transaction as
+// the write, so counts never need a function.
+type Counter struct {
+	// Target is the collection holding the count.
+	Target string `yaml:"target" json:"target"`
+	// TargetField is the int field of Target that holds the count.
+	TargetField string `yaml:"targetField" json:"targetField"`
+	// ForeignKey is the field of this collection naming the target
+	// document. If it references Target, the referenced field is matched;
+	// otherwise Target's primary key is.
+	ForeignKey string `yaml:"foreignKey" json:"foreignKey"`
+	// On lists the events that change the count. Unset, inserts, deletes
+	// and updates that move a document to another target all do.
+	On []string `yaml:"on,omitempty" json:"on,omitempty"`
+}
+
+// Events returns the events that change the count.
+func (c *Counter) Events() []string {
+	if len(c.On) == 0 {
+		return CounterEvents
+	}
+	return c.On
+}
+
+// triggerName returns the name of the trigger that updates the count on
+// event for documents of collection.
+func (c *Counter) triggerName(collection, event string) string {
+	return fmt.Sprintf("%s%s_%s_%s_%s", counterTriggerPrefix, collection, c.Target, c.TargetField, event)
+}
+
+// DropTriggersSQL returns the statements that drop the counter's triggers
+// on collection.
+func (c *Counter) DropTriggersSQL(collection string) []string {
+	stmts := make([]string, 0, len(CounterEvents))
+	for _, event := range CounterEvents {
+		stmts = append(stmts, fmt.Sprintf("DROP TRIGGER IF EXISTS %s", c.triggerName(collection, event)))
+	}
+	return stmts
+}
+
+// counterKey returns the field of the target collection that the counter's
+// foreign key holds.
+func (g *SQLGenerator) counterKey(col *Collection, c *Counter) string {
+	if fk, ok := col.Fields[c.ForeignKey]; ok {
+		if table, field, ok := fk.ParseReference(); ok && table == c.Target {
+			return field
+		}
+	}
+	if g.schema != nil {
+		if target, ok := g.schema.Collections[c.Target]; ok {
+			if pk := target.PrimaryKeyField(); pk != nil {
+				return pk.Name
+			}
+		}
+	}
+	return "id"
+}
+
+// GenerateCounterTriggers returns the triggers that keep the counter on col
+// up to date.
+func (g *SQLGenerator) GenerateCounterTriggers(col *Collection, c *Counter) []string {
+	key := g.counterKey(col, c)
+	adjust := func(ref string, delta string) string {
+		return fmt.Sprintf("UPDATE %s SET %s = COALESCE(%s, 0) %s 1 WHERE %s = %s.%s;",
+			c.Target, c.TargetField, c.TargetField, delta, key, ref, c.ForeignKey)
+	}
+
+	var triggers []string
+	for _, event := range c.Events() {
+		name := c.triggerName(col.Name, event)
+		switch event {
+		case CounterOnInsert:
+			triggers = append(triggers, fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s
+AFTER INSERT ON %s
+WHEN NEW.%s IS NOT NULL
+BEGIN
+	%s
+END`, name, col.Name, c.ForeignKey, adjust("NEW", "+")))
+		case CounterOnDelete:
+			triggers = append(triggers, fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s
+AFTER DELETE ON %s
+WHEN OLD.%s IS NOT NULL
+BEGIN
+	%s
+END`, name, col.Name, c.ForeignKey, adjust("OLD", "-")))
+		case CounterOnUpdate:
+			triggers = append(triggers, fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s
+AFTER UPDATE OF %s ON %s
+WHEN OLD.%s IS NOT NEW.%s
+BEGIN
+	%s
+	%s
+END`, name, c.ForeignKey, col.Name, c.ForeignKey, c.ForeignKey, adjust("OLD", "-"), adjust("NEW", "+")))
+		}
+	}
+	return triggers
+}
+
+// GenerateCounterRecount returns the statement that sets the counter's
+// target field to the current count for every target document.
+func (g *SQLGenerator) GenerateCounterRecount(col *Collection, c *Counter) string {
+	return fmt.Sprintf("UPDATE %s SET %s = (SELECT COUNT(*) FROM %s WHERE %s.%s = %s.%s)",
+		c.Target, c.TargetField, col.Name, col.Name, c.ForeignKey, c.Target, g.counterKey(col, c))
+}
+
+// IsCounterTrigger reports whether name is the name of a counter trigger.
+func IsCounterTrigger(name string) bool {
+	return strings.HasPrefix(name, counterTriggerPrefix)
+}
+
+func validateCounters(path string, col *Collection, s *Schema) ValidationErrors {
+	var errs ValidationErrors
+	seen := make(map[string]bool, len(col.Counters))
+
+	for i, c := range col.Counters {
+		cPath := fmt.Sprintf("%s[%d]", path, i)
+
+		target, ok := s.Collections[c.Target]
+		switch {
+		case c.Target == "":
+			errs = append(errs, &ValidationError{Path: cPath + ".target", Message: "target is required"})
+		case !ok:
+			errs = append(errs, &ValidationError{
+				Path:    cPath + ".target",
+				Message: fmt.Sprintf("target collection %q does not exist", c.Target),
+			})
+		}
+
+		if c.TargetField == "" {
+			errs = append(errs, &ValidationError{Path: cPath + ".targetField", Message: "targetField is required"})
+		} else if target != nil {
+			switch f, ok := target.Fields[c.TargetField]; {
+			case !ok:
+				errs = append(errs, &ValidationError{
+					Path:    cPath + ".targetField",
+					Message: fmt.Sprintf("field %q does not exist in collection %q", c.TargetField, c.Target),
+				})
+			case f.Type != FieldTypeInt:
+				errs = append(errs, &ValidationError{
+					Path:    cPath + ".targetField",
+					Message: fmt.Sprintf("field %q must be of type int, not %s", c.TargetField, f.Type),
+				})
+			case f.Primary:
+				errs = append(errs, &ValidationError{
+					Path:    cPath + ".targetField",
+					Message: "targetField cannot be the primary key",
+				})
+			}
+		}
+
+		key := c.Target + "." + c.TargetField
+		if seen[key] {
+			errs = append(errs, &ValidationError{
+				Path:    cPath,
+				Message: fmt.Sprintf("duplicate counter for %s", key),
+			})
+		}
+		seen[key] = true
+
+		if c.ForeignKey == "" {
+			errs = append(errs, &ValidationError{Path: cPath + ".foreignKey", Message: "foreignKey is required"})
+		} else if fk, ok := col.Fields[c.ForeignKey]; !ok {
+			errs = append(errs, &ValidationError{
+				Path:    cPath + ".foreignKey",
+				Message: fmt.Sprintf("field %q does not exist in collection", c.ForeignKey),
+			})
+		} else if table, _, ok := fk.ParseReference(); ok && table != c.Target {
+			errs = append(errs, &ValidationError{
+				Path:    cPath + ".foreignKey",
+				Message: fmt.Sprintf("field %q references %q, not the target %q", c.ForeignKey, table, c.Target),
+			})
+		}
+
+		for j, event := range c.On {
+			if !slices.Contains(CounterEvents, event) {
+				errs = append(errs, &ValidationError{
+					Path:    fmt.Sprintf("%s.on[%d]", cPath, j),
+					Message: fmt.Sprintf("must be one of: %s", strings.Join(CounterEvents, ", ")),
+				})
+			} else if slices.Index(c.On, event) != j {
+				errs = append(errs, &ValidationError{
+					Path:    fmt.Sprintf("%s.on[%d]", cPath, j),
+					Message: fmt.Sprintf("duplicate event %q", event),
+				})
+			}
+		}
+	}
+
+	return errs
+}
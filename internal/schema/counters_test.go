@@ -0,0 +1,199 @@
+package schema
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+const counterTestSchema = `
+version: 1
+collections:
+  posts:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      title: { type: string%s }
+      comment_count: { type: int, default: "0" }
+  comments:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      post_id: { type: string, references: posts.id, onDelete: cascade }
+      body: { type: string }
+%s`
+
+const commentCounter = `    counters:
+      - { target: posts, targetField: comment_count, foreignKey: post_id }
+`
+
+func counterSchema(t *testing.T, titleExtra, counters string) *Schema {
+	t.Helper()
+	yaml := strings.Replace(counterTestSchema, "%s", titleExtra, 1)
+	s, err := Parse([]byte(strings.Replace(yaml, "%s", counters, 1)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return s
+}
+
+func TestParseCounters(t *testing.T) {
+	s := counterSchema(t, "", commentCounter)
+	counters := s.Collections["comments"].Counters
+	if len(counters) != 1 || counters[0].Target != "posts" || counters[0].ForeignKey != "post_id" {
+		t.Fatalf("unexpected counters: %+v", counters)
+	}
+	if got := counters[0].Events(); len(got) != 3 {
+		t.Errorf("expected every event by default, got %v", got)
+	}
+
+	for _, tc := range []struct{ counter, want string }{
+		{"{ target: authors, targetField: n, foreignKey: post_id }", `target collection "authors" does not exist`},
+		{"{ target: posts, targetField: views, foreignKey: post_id }", `field "views" does not exist in collection "posts"`},
+		{"{ target: posts, targetField: title, foreignKey: post_id }", "must be of type int"},
+		{"{ target: posts, targetField: comment_count, foreignKey: author }", `field "author" does not exist in collection`},
+		{"{ target: posts, targetField: comment_count, foreignKey: post_id, on: [insert, upsert] }", "must be one of: insert, update, delete"},
+		{"{ target: posts, targetField: comment_count, foreignKey: post_id, on: [insert, insert] }", `duplicate event "insert"`},
+	} {
+		_, err := Parse([]byte(strings.Replace(strings.Replace(counterTestSchema, "%s", "", 1), "%s", "    counters:\n      - "+tc.counter+"\n", 1)))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.counter, tc.want, err)
+		}
+	}
+}
+
+func counterCount(t *testing.T, db *sql.DB, post string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT comment_count FROM posts WHERE id = ?", post).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCounterMigration(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "counters.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator := NewMigrator(db, "", "")
+	if err := migrator.Init(); err != nil {
+		t.Fatal(err)
+	}
+	plain := counterSchema(t, "", "")
+	if err := migrator.ApplySchema(plain); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		// The change triggers record writes here.
+		"CREATE TABLE _alyx_changes (collection TEXT, operation TEXT, doc_id TEXT, changed_fields TEXT)",
+		"INSERT INTO posts (id, title) VALUES ('a', 'A'), ('b', 'B')",
+		"INSERT INTO comments (id, post_id, body) VALUES ('c1', 'a', 'x'), ('c2', 'a', 'y')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counted := counterSchema(t, "", commentCounter)
+	current, err := InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := NewDiffer().Diff(current, counted)
+	if len(changes) != 1 || changes[0].Type != ChangeAddCounter || !changes[0].Safe {
+		t.Fatalf("expected one safe add counter change, got %v", changes)
+	}
+	changes[0].Backfill = true
+	if err := migrator.ApplySafeChanges(changes, counted); err != nil {
+		t.Fatal(err)
+	}
+	if n := counterCount(t, db, "a"); n != 2 {
+		t.Errorf("recounted comment_count = %d, want 2", n)
+	}
+
+	if _, err := db.Exec("DELETE FROM _alyx_changes"); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO comments (id, post_id, body) VALUES ('c3', 'b', 'z')",
+		"UPDATE comments SET post_id = 'b' WHERE id = 'c1'",
+		"DELETE FROM comments WHERE id = 'c2'",
+		"UPDATE comments SET body = 'edited' WHERE id = 'c3'",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if a, b := counterCount(t, db, "a"), counterCount(t, db, "b"); a != 0 || b != 2 {
+		t.Errorf("counts = %d, %d; want 0, 2", a, b)
+	}
+
+	// Counter updates go through the target's change trigger, so realtime
+	// sees them like any other update.
+	var changed string
+	if err := db.QueryRow("SELECT changed_fields FROM _alyx_changes WHERE collection = 'posts' AND operation = 'UPDATE' AND doc_id = 'b' LIMIT 1").Scan(&changed); err != nil {
+		t.Fatalf("expected a change row for the target: %v", err)
+	}
+	if !strings.Contains(changed, "comment_count") {
+		t.Errorf("changed_fields = %s, want comment_count", changed)
+	}
+
+	current, err = InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := NewDiffer().Diff(current, counted); len(changes) != 0 {
+		t.Fatalf("expected no changes after applying, got %v", changes)
+	}
+
+	// Rebuilding the target table keeps the counter working.
+	nocase := counterSchema(t, ", collate: nocase", commentCounter)
+	changes = NewDiffer().Diff(current, nocase)
+	if len(changes) != 1 || changes[0].Type != ChangeModifyField {
+		t.Fatalf("expected one modify field change, got %v", changes)
+	}
+	if err := migrator.ApplyUnsafeChanges(changes, nocase); err != nil {
+		t.Fatalf("rebuilding the target: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO comments (id, post_id, body) VALUES ('c4', 'b', 'w')"); err != nil {
+		t.Fatal(err)
+	}
+	if n := counterCount(t, db, "b"); n != 3 {
+		t.Errorf("comment_count after rebuild = %d, want 3", n)
+	}
+
+	current, err = InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncounted := withoutCounters(nocase)
+	changes = NewDiffer().Diff(current, uncounted)
+	if len(changes) != 1 || changes[0].Type != ChangeDropCounter {
+		t.Fatalf("expected one drop counter change, got %v", changes)
+	}
+	if err := migrator.ApplySafeChanges(changes, uncounted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO comments (id, post_id, body) VALUES ('c5', 'b', 'v')"); err != nil {
+		t.Fatal(err)
+	}
+	if n := counterCount(t, db, "b"); n != 3 {
+		t.Errorf("comment_count after dropping the counter = %d, want 3", n)
+	}
+}
+
+// withoutCounters returns a copy of s with no counters.
+func withoutCounters(s *Schema) *Schema {
+	out := *s
+	out.Collections = make(map[string]*Collection, len(s.Collections))
+	for name, col := range s.Collections {
+		c := *col
+		c.Counters = nil
+		out.Collections[name] = &c
+	}
+	return &out
+}
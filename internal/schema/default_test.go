@@ -0,0 +1,144 @@
+package schema
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+const defaultTestSchema = `
+version: 1
+collections:
+  posts:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      status: { type: string, nullable: true%s }
+      edited: { type: timestamp, nullable: true%s }
+`
+
+func defaultSchema(t *testing.T, status, edited string) *Schema {
+	t.Helper()
+	s, err := Parse([]byte(strings.Replace(strings.Replace(defaultTestSchema, "%s", status, 1), "%s", edited, 1)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return s
+}
+
+func triggerExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?", name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestDefaultAndOnUpdateMigration(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "default.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator := NewMigrator(db, "", "")
+	if err := migrator.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.ApplySchema(defaultSchema(t, "", "")); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		// The change triggers record writes here.
+		"CREATE TABLE _alyx_changes (collection TEXT, operation TEXT, doc_id TEXT, changed_fields TEXT)",
+		"INSERT INTO posts (id, status) VALUES ('a', NULL)",
+		"INSERT INTO posts (id, status) VALUES ('b', 'published')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	next := defaultSchema(t, ", default: draft", ", onUpdate: now")
+	current, err := InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := NewDiffer().Diff(current, next)
+	types := make(map[ChangeType]*Change)
+	for _, c := range changes {
+		if !c.Safe {
+			t.Errorf("expected only safe changes, got %s", c)
+		}
+		types[c.Type] = c
+	}
+	if len(changes) != 2 || types[ChangeModifyDefault] == nil || types[ChangeModifyOnUpdate] == nil {
+		t.Fatalf("expected a default and an onUpdate change, got %v", changes)
+	}
+
+	types[ChangeModifyDefault].Backfill = true
+	if err := migrator.ApplySafeChanges(changes, next); err != nil {
+		t.Fatal(err)
+	}
+
+	var status string
+	if err := db.QueryRow("SELECT status FROM posts WHERE id = 'a'").Scan(&status); err != nil || status != "draft" {
+		t.Errorf("backfilled status = %q, %v; want draft", status, err)
+	}
+	if err := db.QueryRow("SELECT status FROM posts WHERE id = 'b'").Scan(&status); err != nil || status != "published" {
+		t.Errorf("status = %q, %v; want published", status, err)
+	}
+	if !triggerExists(t, db, "posts_auto_update_timestamp") {
+		t.Fatal("expected the auto update trigger to be created")
+	}
+	if _, err := db.Exec("UPDATE posts SET status = 'archived' WHERE id = 'b'"); err != nil {
+		t.Fatal(err)
+	}
+	var edited sql.NullString
+	if err := db.QueryRow("SELECT edited FROM posts WHERE id = 'b'").Scan(&edited); err != nil || !edited.Valid {
+		t.Errorf("expected edited to be set on update, got %v, %v", edited, err)
+	}
+
+	current, err = InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := NewDiffer().Diff(current, next); len(changes) != 0 {
+		t.Errorf("expected no changes after applying, got %v", changes)
+	}
+
+	// Removing onUpdate drops the trigger, and a default change without
+	// backfill leaves existing rows alone.
+	final := defaultSchema(t, ", default: pending", "")
+	changes = NewDiffer().Diff(current, final)
+	if err := migrator.ApplySafeChanges(changes, final); err != nil {
+		t.Fatal(err)
+	}
+	if triggerExists(t, db, "posts_auto_update_timestamp") {
+		t.Error("expected the auto update trigger to be dropped")
+	}
+	if !triggerExists(t, db, "posts_after_update") {
+		t.Error("expected the change triggers to be kept")
+	}
+	if err := db.QueryRow("SELECT status FROM posts WHERE id = 'a'").Scan(&status); err != nil || status != "draft" {
+		t.Errorf("status = %q, %v; want draft", status, err)
+	}
+}
+
+func TestBackfillRequiresSQLDefault(t *testing.T) {
+	m := &Migrator{}
+	change := &Change{
+		Type:       ChangeModifyDefault,
+		Collection: "posts",
+		Field:      "id",
+		NewField:   &Field{Name: "id", Type: FieldTypeUUID, Default: string(DefaultAuto)},
+		Safe:       true,
+		Backfill:   true,
+	}
+	if _, err := m.changeToSQL(change, nil); err == nil {
+		t.Error("expected backfilling an auto default to fail")
+	}
+}
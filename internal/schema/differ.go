@@ -2,6 +2,9 @@ package schema
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 )
 
 type ChangeType string
@@ -16,6 +19,22 @@ const (
 	ChangeAddIndex       ChangeType = "add_index"
 	ChangeDropIndex      ChangeType = "drop_index"
 	ChangeModifyRules    ChangeType = "modify_rules"
+	ChangeModifyRoles    ChangeType = "modify_roles"
+	ChangeAddHistory     ChangeType = "add_history"
+	ChangeDropHistory    ChangeType = "drop_history"
+
+	ChangeModifyUserMetadata ChangeType = "modify_user_metadata"
+
+	ChangeAddView    ChangeType = "add_view"
+	ChangeDropView   ChangeType = "drop_view"
+	ChangeModifyView ChangeType = "modify_view"
+
+	ChangeModifyDefault  ChangeType = "modify_default"
+	ChangeModifyOnUpdate ChangeType = "modify_on_update"
+
+	ChangeAddCounter    ChangeType = "add_counter"
+	ChangeDropCounter   ChangeType = "drop_counter"
+	ChangeModifyCounter ChangeType = "modify_counter"
 )
 
 type Change struct {
@@ -25,9 +44,18 @@ type Change struct {
 	OldField       *Field
 	NewField       *Field
 	Index          *Index
+	OldView        *View
+	NewView        *View
+	OldCounter     *Counter
+	NewCounter     *Counter
+	RemovedRoles   []string
 	Safe           bool
 	RequiresManual bool
 	Description    string
+	// Backfill, on a ChangeModifyDefault, also sets existing NULL values to
+	// the new default when the change is applied. On a ChangeAddCounter or
+	// ChangeModifyCounter, it recounts the target field.
+	Backfill bool
 }
 
 func (c *Change) String() string {
@@ -50,6 +78,26 @@ func (c *Change) String() string {
 		return fmt.Sprintf("Drop index %q", c.Index.Name)
 	case ChangeModifyRules:
 		return fmt.Sprintf("Modify rules for collection %q", c.Collection)
+	case ChangeAddHistory:
+		return fmt.Sprintf("Enable history for collection %q", c.Collection)
+	case ChangeDropHistory:
+		return fmt.Sprintf("Disable history for collection %q (DESTRUCTIVE)", c.Collection)
+	case ChangeAddView:
+		return fmt.Sprintf("Add view %q", c.NewView.Name)
+	case ChangeDropView:
+		return fmt.Sprintf("Drop view %q", c.OldView.Name)
+	case ChangeModifyView:
+		return fmt.Sprintf("Modify view %q", c.NewView.Name)
+	case ChangeModifyDefault:
+		return fmt.Sprintf("Change default of field %q in collection %q", c.Field, c.Collection)
+	case ChangeModifyOnUpdate:
+		return fmt.Sprintf("Change onUpdate of field %q in collection %q", c.Field, c.Collection)
+	case ChangeAddCounter:
+		return fmt.Sprintf("Add counter %s.%s on collection %q", c.NewCounter.Target, c.NewCounter.TargetField, c.Collection)
+	case ChangeDropCounter:
+		return fmt.Sprintf("Drop counter %s.%s on collection %q", c.OldCounter.Target, c.OldCounter.TargetField, c.Collection)
+	case ChangeModifyCounter:
+		return fmt.Sprintf("Modify counter %s.%s on collection %q", c.NewCounter.Target, c.NewCounter.TargetField, c.Collection)
 	default:
 		return c.Description
 	}
@@ -65,6 +113,19 @@ func (d *Differ) Diff(old, newSchema *Schema) []*Change {
 	var changes []*Change
 	droppedCollections := make(map[string]bool)
 
+	if change := d.diffRoles(old, newSchema); change != nil {
+		changes = append(changes, change)
+	}
+	if !reflect.DeepEqual(old.UserMetadata, newSchema.UserMetadata) {
+		// Existing metadata is not rewritten; the new shape is enforced on
+		// the next write to each user.
+		changes = append(changes, &Change{
+			Type:        ChangeModifyUserMetadata,
+			Safe:        true,
+			Description: "User metadata schema will change",
+		})
+	}
+
 	for name := range old.Collections {
 		if _, exists := newSchema.Collections[name]; !exists {
 			droppedCollections[name] = true
@@ -95,9 +156,106 @@ func (d *Differ) Diff(old, newSchema *Schema) []*Change {
 		}
 	}
 
+	changes = append(changes, d.diffViews(old, newSchema)...)
+
+	return changes
+}
+
+// diffViews reports added, dropped and modified views. A view's backing table
+// only holds data derived from collections, so every view change is safe: a
+// changed query recreates the table, which is filled again on its next
+// refresh.
+func (d *Differ) diffViews(old, newSchema *Schema) []*Change {
+	var changes []*Change
+
+	for _, name := range sortedNames(old.Views) {
+		if _, exists := newSchema.Views[name]; !exists {
+			changes = append(changes, &Change{
+				Type:        ChangeDropView,
+				OldView:     old.Views[name],
+				Safe:        true,
+				Description: fmt.Sprintf("View %q will be dropped", name),
+			})
+		}
+	}
+
+	for _, name := range sortedNames(newSchema.Views) {
+		newView := newSchema.Views[name]
+		oldView, exists := old.Views[name]
+		switch {
+		case !exists:
+			changes = append(changes, &Change{
+				Type:        ChangeAddView,
+				NewView:     newView,
+				Safe:        true,
+				Description: fmt.Sprintf("View %q will be created", name),
+			})
+		case oldView.Query != newView.Query:
+			changes = append(changes, &Change{
+				Type:        ChangeModifyView,
+				OldView:     oldView,
+				NewView:     newView,
+				Safe:        true,
+				Description: fmt.Sprintf("View %q will be recreated with its new query", name),
+			})
+		case !reflect.DeepEqual(oldView, newView):
+			changes = append(changes, &Change{
+				Type:        ChangeModifyView,
+				OldView:     oldView,
+				NewView:     newView,
+				Safe:        true,
+				Description: fmt.Sprintf("View %q settings will change", name),
+			})
+		}
+	}
+
 	return changes
 }
 
+// diffRoles reports added and removed custom roles. Role changes need no SQL,
+// so they are safe; removing a role still held by users is rejected when the
+// change is applied.
+func (d *Differ) diffRoles(old, newSchema *Schema) *Change {
+	oldRoles := make(map[string]bool, len(old.Roles))
+	for _, r := range old.Roles {
+		oldRoles[r] = true
+	}
+	newRoles := make(map[string]bool, len(newSchema.Roles))
+	for _, r := range newSchema.Roles {
+		newRoles[r] = true
+	}
+
+	var added, removed []string
+	for _, r := range newSchema.Roles {
+		if !oldRoles[r] {
+			added = append(added, r)
+		}
+	}
+	for _, r := range old.Roles {
+		if !newRoles[r] {
+			removed = append(removed, r)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	var parts []string
+	if len(added) > 0 {
+		parts = append(parts, "add "+strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		parts = append(parts, "remove "+strings.Join(removed, ", "))
+	}
+
+	return &Change{
+		Type:         ChangeModifyRoles,
+		RemovedRoles: removed,
+		Safe:         true,
+		Description:  fmt.Sprintf("Roles will change: %s", strings.Join(parts, "; ")),
+	}
+}
+
 func (d *Differ) diffCollection(name string, old, newCol *Collection) []*Change {
 	var changes []*Change
 
@@ -147,6 +305,80 @@ func (d *Differ) diffCollection(name string, old, newCol *Collection) []*Change
 		})
 	}
 
+	switch {
+	case old.History == nil && newCol.History != nil:
+		changes = append(changes, &Change{
+			Type:        ChangeAddHistory,
+			Collection:  name,
+			Safe:        true,
+			Description: fmt.Sprintf("History table %q will be created", newCol.HistoryTable()),
+		})
+	case old.History != nil && newCol.History == nil:
+		changes = append(changes, &Change{
+			Type:           ChangeDropHistory,
+			Collection:     name,
+			Safe:           false,
+			RequiresManual: true,
+			Description:    fmt.Sprintf("History table %q will be dropped", old.HistoryTable()),
+		})
+	}
+
+	changes = append(changes, d.diffCounters(name, old, newCol)...)
+
+	return changes
+}
+
+// diffCounters reports added, dropped and modified counters. Counters are
+// only triggers, so every counter change is safe; existing counts are left
+// as they are unless the change is applied with a backfill.
+func (d *Differ) diffCounters(name string, old, newCol *Collection) []*Change {
+	var changes []*Change
+
+	key := func(c *Counter) string { return c.Target + "." + c.TargetField }
+	oldCounters := make(map[string]*Counter, len(old.Counters))
+	for _, c := range old.Counters {
+		oldCounters[key(c)] = c
+	}
+	newCounters := make(map[string]*Counter, len(newCol.Counters))
+	for _, c := range newCol.Counters {
+		newCounters[key(c)] = c
+	}
+
+	for _, c := range old.Counters {
+		if _, exists := newCounters[key(c)]; !exists {
+			changes = append(changes, &Change{
+				Type:        ChangeDropCounter,
+				Collection:  name,
+				OldCounter:  c,
+				Safe:        true,
+				Description: fmt.Sprintf("Counter %s will no longer be kept up to date", key(c)),
+			})
+		}
+	}
+
+	for _, c := range newCol.Counters {
+		oldCounter, exists := oldCounters[key(c)]
+		switch {
+		case !exists:
+			changes = append(changes, &Change{
+				Type:        ChangeAddCounter,
+				Collection:  name,
+				NewCounter:  c,
+				Safe:        true,
+				Description: fmt.Sprintf("Counter %s will be kept up to date", key(c)),
+			})
+		case oldCounter.ForeignKey != c.ForeignKey || !reflect.DeepEqual(oldCounter.Events(), c.Events()):
+			changes = append(changes, &Change{
+				Type:        ChangeModifyCounter,
+				Collection:  name,
+				OldCounter:  oldCounter,
+				NewCounter:  c,
+				Safe:        true,
+				Description: fmt.Sprintf("Counter %s triggers will be recreated", key(c)),
+			})
+		}
+	}
+
 	return changes
 }
 
@@ -216,6 +448,18 @@ func (d *Differ) diffField(collection, fieldName string, old, newField *Field) [
 		}
 	}
 
+	if old.CaseInsensitive() != newField.CaseInsensitive() {
+		changes = append(changes, &Change{
+			Type:        ChangeModifyField,
+			Collection:  collection,
+			Field:       fieldName,
+			OldField:    old,
+			NewField:    newField,
+			Safe:        false,
+			Description: "Changing collation rebuilds the table",
+		})
+	}
+
 	if old.References != newField.References {
 		if old.References != "" && newField.References != "" {
 			changes = append(changes, &Change{
@@ -231,9 +475,42 @@ func (d *Differ) diffField(collection, fieldName string, old, newField *Field) [
 		}
 	}
 
+	// Defaults are applied when records are inserted, so changing one leaves
+	// existing rows alone.
+	if !old.unconfigured && old.Default != newField.Default {
+		changes = append(changes, &Change{
+			Type:        ChangeModifyDefault,
+			Collection:  collection,
+			Field:       fieldName,
+			OldField:    old,
+			NewField:    newField,
+			Safe:        true,
+			Description: fmt.Sprintf("Default will change from %s to %s", describeSetting(old.Default), describeSetting(newField.Default)),
+		})
+	}
+
+	if !old.unconfigured && old.OnUpdate != newField.OnUpdate {
+		changes = append(changes, &Change{
+			Type:        ChangeModifyOnUpdate,
+			Collection:  collection,
+			Field:       fieldName,
+			OldField:    old,
+			NewField:    newField,
+			Safe:        true,
+			Description: fmt.Sprintf("onUpdate will change from %s to %s", describeSetting(old.OnUpdate), describeSetting(newField.OnUpdate)),
+		})
+	}
+
 	return changes
 }
 
+func describeSetting(v string) string {
+	if v == "" {
+		return "none"
+	}
+	return fmt.Sprintf("%q", v)
+}
+
 func (d *Differ) diffIndexes(collectionName string, old, newCol *Collection) []*Change {
 	var changes []*Change
 
@@ -300,7 +577,10 @@ func (d *Differ) rulesChanged(old, newRules *Rules) bool {
 	return old.Create != newRules.Create ||
 		old.Read != newRules.Read ||
 		old.Update != newRules.Update ||
-		old.Delete != newRules.Delete
+		old.Delete != newRules.Delete ||
+		old.Download != newRules.Download ||
+		old.Upload != newRules.Upload ||
+		old.History != newRules.History
 }
 
 func (d *Differ) areTypesCompatible(oldType, newType FieldType) bool {
@@ -336,6 +616,22 @@ func (d *Differ) areTypesCompatible(oldType, newType FieldType) bool {
 	return false
 }
 
+// ChangedCollections returns the sorted names of the collections changes
+// touch. Changes that are not about a collection, such as to roles, are
+// skipped.
+func ChangedCollections(changes []*Change) []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, c := range changes {
+		if c.Collection != "" && !seen[c.Collection] {
+			seen[c.Collection] = true
+			names = append(names, c.Collection)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (d *Differ) SafeChanges(changes []*Change) []*Change {
 	var safe []*Change
 	for _, c := range changes {
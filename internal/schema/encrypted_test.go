@@ -0,0 +1,98 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEncrypted(t *testing.T) {
+	s, err := Parse([]byte(`
+version: 1
+collections:
+  patients:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      name: { type: string }
+      ssn: { type: string, encrypted: true, nullable: true }
+      notes: { type: text, encrypted: true, nullable: true }
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !s.HasEncryptedFields() {
+		t.Error("HasEncryptedFields() = false")
+	}
+	var names []string
+	for _, f := range s.Collections["patients"].EncryptedFields() {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "ssn,notes" {
+		t.Errorf("EncryptedFields() = %s", got)
+	}
+
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("parse marshaled schema: %v\n%s", err, data)
+	}
+	if !reparsed.Collections["patients"].Fields["ssn"].Encrypted {
+		t.Errorf("encrypted lost across round trip:\n%s", data)
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		extra string
+		want  string
+	}{
+		{"int field", "{ type: int, encrypted: true }", "", "only be used with string or text"},
+		{"indexed", "{ type: string, encrypted: true, index: true }", "", "cannot be combined with index"},
+		{"unique", "{ type: string, encrypted: true, unique: true }", "", "cannot be combined with unique"},
+		{"default", "{ type: string, encrypted: true, default: x }", "", "cannot be combined with default"},
+		{"composite index", "{ type: string, encrypted: true }", `
+    indexes:
+      - { name: idx_secret, fields: [name, secret] }`, `field "secret" is encrypted and cannot be indexed`},
+		{"default sort", "{ type: string, encrypted: true }", `
+    list: { defaultSort: "-secret" }`, `field "secret" is encrypted and cannot be sorted`},
+		{"list without sort", "{ type: string, encrypted: true }", `
+    list: { defaultLimit: 10 }`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(`
+version: 1
+collections:
+  items:` + tt.extra + `
+    fields:
+      id: { type: id, primary: true, default: auto }
+      name: { type: string }
+      secret: ` + tt.field + `
+`))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	_, err = Parse([]byte(`
+version: 1
+collections:
+  items:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      secret: { type: string, encrypted: true }
+      slug: { type: string, unique: true, slug: { from: secret } }
+`))
+	if err == nil || !strings.Contains(err.Error(), "a slug would expose its value") {
+		t.Errorf("slug from encrypted field: error = %v", err)
+	}
+}
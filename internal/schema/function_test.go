@@ -3,6 +3,7 @@ package schema
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseFunctions_ValidMinimal(t *testing.T) {
@@ -697,6 +698,60 @@ functions:
 	}
 }
 
+func TestValidation_SchedulePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		wantErr string
+	}{
+		{"all options", "overlap: queue\n        jitter: 30s\n        catch_up: all", ""},
+		{"invalid overlap", "overlap: sometimes", "overlap"},
+		{"invalid catch_up", "catch_up: every", "catch_up"},
+		{"invalid jitter", "jitter: soon", "jitter"},
+		{"negative jitter", "jitter: -5s", "jitter"},
+		{"jitter as long as interval", "jitter: 5m", "jitter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml := `
+version: 1
+
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+
+functions:
+  health_check:
+    runtime: node
+    entrypoint: health.js
+    schedules:
+      - name: check
+        type: interval
+        expression: "5m"
+        ` + tt.options + `
+`
+			schema, err := Parse([]byte(yaml))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("failed to parse schema: %v", err)
+				}
+				sched := schema.Functions["health_check"].Schedules[0]
+				if sched.Overlap != "queue" || sched.Jitter != "30s" || sched.CatchUp != "all" {
+					t.Errorf("unexpected schedule options: %+v", sched)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error about %s, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestValidation_HookReferencesNonExistentCollection(t *testing.T) {
 	yaml := `
 version: 1
@@ -878,6 +933,54 @@ functions:
 	}
 }
 
+func TestValidation_WebhookVerificationPresets(t *testing.T) {
+	base := `
+version: 1
+
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+
+functions:
+  webhook:
+    runtime: node
+    entrypoint: index.js
+    hooks:
+      - type: webhook
+        verification:
+`
+	tests := []struct {
+		name         string
+		verification string
+		wantErr      string
+	}{
+		{"stripe with default header", "          type: stripe\n          secret: whsec_x\n          tolerance: 10m\n", ""},
+		{"github with default header", "          type: github\n          secret: x\n", ""},
+		{"slack without secret", "          type: slack\n", "verification.secret: required field"},
+		{"token without header", "          type: token\n          secret: x\n", "verification.header: required for token verification"},
+		{"unknown type", "          type: md5\n          secret: x\n", "verification.type: must be one of"},
+		{"tolerance on github", "          type: github\n          secret: x\n          tolerance: 5m\n", "only applies to stripe and slack"},
+		{"invalid tolerance", "          type: stripe\n          secret: x\n          tolerance: soon\n", "must be a positive duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(base + tt.verification))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestValidation_AllRuntimes(t *testing.T) {
 	runtimes := []string{"node", "python", "go", "deno", "bun"}
 
@@ -1073,3 +1176,203 @@ functions:
 		})
 	}
 }
+
+func TestParseFunctions_Mode(t *testing.T) {
+	tests := []struct {
+		name    string
+		runtime string
+		mode    string
+		wantErr string
+	}{
+		{"default", "go", "", ""},
+		{"native go", "go", "native", ""},
+		{"subprocess", "node", "subprocess", ""},
+		{"native node", "node", "native", "only supported for the go runtime"},
+		{"unknown", "go", "plugin", "must be one of: subprocess, native"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml := `
+version: 1
+
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+
+functions:
+  hello:
+    runtime: ` + tt.runtime + `
+    entrypoint: main.go
+`
+			if tt.mode != "" {
+				yaml += "    mode: " + tt.mode + "\n"
+			}
+			s, err := Parse([]byte(yaml))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse schema: %v", err)
+			}
+			if s.Functions["hello"].Mode != tt.mode {
+				t.Errorf("expected mode %q, got %q", tt.mode, s.Functions["hello"].Mode)
+			}
+		})
+	}
+}
+
+func TestValidation_ScheduleExpressions(t *testing.T) {
+	yaml := `
+version: 1
+
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+
+functions:
+  jobs:
+    runtime: node
+    entrypoint: index.js
+    schedules:
+      - name: bad_cron
+        type: cron
+        expression: "0 25 * * *"
+      - name: too_fast
+        type: interval
+        expression: "500ms"
+      - name: bad_time
+        type: one_time
+        expression: "tomorrow"
+      - name: bad_zone
+        type: cron
+        expression: "@daily"
+        timezone: Mars/Olympus
+      - name: missing
+        type: interval
+    routes:
+      - path: /hooks/a b
+        methods: [GET, FETCH, get]
+`
+	_, err := Parse([]byte(yaml))
+	errs := AsValidationErrors(err)
+	if errs == nil {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	want := []string{
+		"functions.jobs.schedules[0].expression",
+		"functions.jobs.schedules[1].expression",
+		"functions.jobs.schedules[2].expression",
+		"functions.jobs.schedules[3].timezone",
+		"functions.jobs.schedules[4].expression",
+		"functions.jobs.routes[0].path",
+		"functions.jobs.routes[0].methods[1]",
+		"functions.jobs.routes[0].methods[2]",
+	}
+	paths := make(map[string]bool, len(errs))
+	for _, e := range errs {
+		paths[e.Path] = true
+	}
+	for _, path := range want {
+		if !paths[path] {
+			t.Errorf("expected an error at %s, got:\n%v", path, err)
+		}
+	}
+}
+
+func TestStaleSchedules(t *testing.T) {
+	yaml := `
+version: 1
+
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+
+functions:
+  jobs:
+    runtime: node
+    entrypoint: index.js
+    schedules:
+      - name: launch
+        type: one_time
+        expression: "2025-01-01T00:00:00Z"
+      - name: later
+        type: one_time
+        expression: "2030-01-01T00:00:00Z"
+      - name: nightly
+        type: cron
+        expression: "0 2 * * *"
+`
+	s, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	stale := s.StaleSchedules(now)
+	if len(stale) != 1 || stale[0] != "functions.jobs.schedules[0]" {
+		t.Errorf("expected only the past one-time schedule, got %v", stale)
+	}
+}
+
+func TestValidation_DatabaseHookFields(t *testing.T) {
+	yaml := `
+version: 1
+
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+      status:
+        type: string
+
+functions:
+  notify:
+    runtime: node
+    entrypoint: index.js
+    hooks:
+      - type: database
+        source: posts
+        action: update
+        fields: [status, published]
+      - type: database
+        action: update
+        fields: [status]
+`
+	_, err := Parse([]byte(yaml))
+	errs := AsValidationErrors(err)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 validation errors, got %v", err)
+	}
+	if errs[0].Path != "functions.notify.hooks[0].fields[1]" || !strings.Contains(errs[0].Message, `"published"`) {
+		t.Errorf("unexpected error for the missing field: %v", errs[0])
+	}
+	if errs[1].Path != "functions.notify.hooks[1].fields" {
+		t.Errorf("unexpected error for the hook without a source: %v", errs[1])
+	}
+
+	valid := strings.Replace(yaml, "fields: [status, published]", "fields: [status]", 1)
+	valid = strings.Replace(valid, "      - type: database\n        action: update\n        fields: [status]\n", "", 1)
+	s, err := Parse([]byte(valid))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if hook := s.Functions["notify"].Hooks[0]; len(hook.Fields) != 1 || hook.Fields[0] != "status" {
+		t.Errorf("expected the fields filter to be parsed, got %v", hook.Fields)
+	}
+}
@@ -0,0 +1,314 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Graph node kinds.
+const (
+	GraphNodeCollection = "collection"
+	GraphNodeBucket     = "bucket"
+)
+
+// Graph edge kinds.
+const (
+	// GraphEdgeReference is a field with references: table.field.
+	GraphEdgeReference = "reference"
+	// GraphEdgeRelation is a relation field.
+	GraphEdgeRelation = "relation"
+	// GraphEdgeFile is a file field storing into a bucket.
+	GraphEdgeFile = "file"
+)
+
+// Graph is the entity-relationship graph of a schema: collections and
+// buckets as nodes, and the fields linking them as edges. Nodes and edges
+// are sorted so the same schema always produces the same graph.
+type Graph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// GraphNode is a collection or bucket.
+type GraphNode struct {
+	// ID is unique across kinds, e.g. collection:posts or bucket:uploads.
+	ID     string            `json:"id"`
+	Kind   string            `json:"kind"`
+	Name   string            `json:"name"`
+	Fields []*GraphNodeField `json:"fields,omitempty"`
+	// RowCount is set by callers with database access; it is nil when the
+	// table does not exist yet.
+	RowCount *int64 `json:"rowCount,omitempty"`
+	Backend  string `json:"backend,omitempty"`
+}
+
+// GraphNodeField summarizes a collection field.
+type GraphNodeField struct {
+	Name     string    `json:"name"`
+	Type     FieldType `json:"type"`
+	Primary  bool      `json:"primary,omitempty"`
+	Unique   bool      `json:"unique,omitempty"`
+	Nullable bool      `json:"nullable,omitempty"`
+}
+
+// GraphEdge links a collection field to the node it points at.
+type GraphEdge struct {
+	// ID is the source field path, e.g. posts.author_id.
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Field  string `json:"field"`
+	// TargetField is the referenced field; empty for file edges.
+	TargetField string         `json:"targetField,omitempty"`
+	OnDelete    OnDeleteAction `json:"onDelete,omitempty"`
+	// SelfReference is set when a collection points at itself.
+	SelfReference bool `json:"selfReference,omitempty"`
+	// Cyclic is set when the edge is part of a reference cycle, including
+	// self-references. Layered layouts need to reverse one such edge.
+	Cyclic bool `json:"cyclic,omitempty"`
+}
+
+// CollectionNodeID returns the graph node ID of a collection.
+func CollectionNodeID(name string) string {
+	return GraphNodeCollection + ":" + name
+}
+
+// BucketNodeID returns the graph node ID of a bucket.
+func BucketNodeID(name string) string {
+	return GraphNodeBucket + ":" + name
+}
+
+// BuildGraph extracts the entity-relationship graph of s. It reads only the
+// parsed schema, so it works before the schema has been migrated. Edges to
+// collections or buckets missing from s are left out.
+func BuildGraph(s *Schema) *Graph {
+	g := &Graph{Nodes: []*GraphNode{}, Edges: []*GraphEdge{}}
+	if s == nil {
+		return g
+	}
+
+	for _, name := range sortedNames(s.Collections) {
+		col := s.Collections[name]
+		node := &GraphNode{ID: CollectionNodeID(name), Kind: GraphNodeCollection, Name: name}
+		for _, f := range col.OrderedFields() {
+			node.Fields = append(node.Fields, &GraphNodeField{
+				Name:     f.Name,
+				Type:     f.Type,
+				Primary:  f.Primary,
+				Unique:   f.Unique,
+				Nullable: f.Nullable,
+			})
+			if edge := fieldEdge(s, name, f); edge != nil {
+				g.Edges = append(g.Edges, edge)
+			}
+		}
+		g.Nodes = append(g.Nodes, node)
+	}
+
+	for _, name := range sortedNames(s.Buckets) {
+		g.Nodes = append(g.Nodes, &GraphNode{
+			ID:      BucketNodeID(name),
+			Kind:    GraphNodeBucket,
+			Name:    name,
+			Backend: s.Buckets[name].Backend,
+		})
+	}
+
+	markCycles(g.Edges)
+	return g
+}
+
+func fieldEdge(s *Schema, collection string, f *Field) *GraphEdge {
+	edge := &GraphEdge{
+		ID:     collection + "." + f.Name,
+		Source: CollectionNodeID(collection),
+		Field:  f.Name,
+	}
+
+	var target string
+	switch {
+	case f.Type == FieldTypeRelation && f.Relation != nil:
+		edge.Kind = GraphEdgeRelation
+		target = f.Relation.Collection
+		edge.TargetField = f.Relation.Field
+		if edge.TargetField == "" {
+			edge.TargetField = "id"
+		}
+		edge.OnDelete = f.Relation.OnDelete
+	case f.References != "":
+		table, field, ok := f.ParseReference()
+		if !ok {
+			return nil
+		}
+		edge.Kind = GraphEdgeReference
+		target = table
+		edge.TargetField = field
+		edge.OnDelete = f.OnDelete
+	case f.Type == FieldTypeFile && f.File != nil && f.File.Bucket != "":
+		if _, ok := s.Buckets[f.File.Bucket]; !ok {
+			return nil
+		}
+		edge.Kind = GraphEdgeFile
+		edge.Target = BucketNodeID(f.File.Bucket)
+		edge.OnDelete = f.File.OnDelete
+		return edge
+	default:
+		return nil
+	}
+
+	if _, ok := s.Collections[target]; !ok {
+		return nil
+	}
+	if edge.OnDelete == "" {
+		edge.OnDelete = OnDeleteRestrict
+	}
+	edge.Target = CollectionNodeID(target)
+	edge.SelfReference = target == collection
+	return edge
+}
+
+// markCycles flags the edges whose source and target lie in the same
+// strongly connected component.
+func markCycles(edges []*GraphEdge) {
+	adjacent := make(map[string][]string)
+	for _, e := range edges {
+		adjacent[e.Source] = append(adjacent[e.Source], e.Target)
+	}
+
+	// Tarjan's algorithm, visiting nodes in sorted order for stability.
+	nodes := make([]string, 0, len(adjacent))
+	for n := range adjacent {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	component := make(map[string]int)
+	var stack []string
+	next, components := 0, 0
+
+	var visit func(string)
+	visit = func(n string) {
+		index[n], low[n] = next, next
+		next++
+		stack = append(stack, n)
+		onStack[n] = true
+
+		for _, m := range adjacent[n] {
+			if _, seen := index[m]; !seen {
+				visit(m)
+				low[n] = min(low[n], low[m])
+			} else if onStack[m] {
+				low[n] = min(low[n], index[m])
+			}
+		}
+
+		if low[n] == index[n] {
+			for {
+				m := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[m] = false
+				component[m] = components
+				if m == n {
+					break
+				}
+			}
+			components++
+		}
+	}
+	for _, n := range nodes {
+		if _, seen := index[n]; !seen {
+			visit(n)
+		}
+	}
+
+	for _, e := range edges {
+		// Targets without outgoing edges were never visited and cannot be
+		// part of a cycle.
+		_, visited := index[e.Target]
+		e.Cyclic = e.SelfReference || visited && component[e.Source] == component[e.Target]
+	}
+}
+
+// DOT renders the graph in Graphviz DOT, one record-shaped node per
+// collection listing its fields.
+func (g *Graph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph schema {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=record, fontname=\"Helvetica\", fontsize=10];\n")
+	sb.WriteString("  edge [fontname=\"Helvetica\", fontsize=9];\n")
+
+	for _, n := range g.Nodes {
+		switch n.Kind {
+		case GraphNodeCollection:
+			rows := make([]string, 0, len(n.Fields))
+			for _, f := range n.Fields {
+				row := fmt.Sprintf("%s: %s", f.Name, f.Type)
+				switch {
+				case f.Primary:
+					row += " (PK)"
+				case f.Unique:
+					row += " (unique)"
+				}
+				if f.Nullable {
+					row += "?"
+				}
+				rows = append(rows, "<"+f.Name+"> "+dotEscapeRecord(row)+`\l`)
+			}
+			title := n.Name
+			if n.RowCount != nil {
+				title = fmt.Sprintf("%s (%d rows)", n.Name, *n.RowCount)
+			}
+			fmt.Fprintf(&sb, "  %q [label=\"{%s}\"];\n", n.ID, strings.Join(append([]string{dotEscapeRecord(title)}, rows...), "|"))
+		case GraphNodeBucket:
+			label := n.Name
+			if n.Backend != "" {
+				label += " (" + n.Backend + ")"
+			}
+			fmt.Fprintf(&sb, "  %q [shape=cylinder, label=%q];\n", n.ID, label)
+		}
+	}
+
+	for _, e := range g.Edges {
+		attrs := []string{fmt.Sprintf("label=%q", string(e.OnDelete))}
+		if e.Kind == GraphEdgeFile {
+			attrs = append(attrs, "style=dashed")
+		}
+		if e.Cyclic && !e.SelfReference {
+			// Keep cycles from distorting the left-to-right ranking.
+			attrs = append(attrs, "constraint=false")
+		}
+		target := fmt.Sprintf("%q", e.Target)
+		if e.TargetField != "" {
+			target += fmt.Sprintf(":%q", e.TargetField)
+		}
+		fmt.Fprintf(&sb, "  %q:%q -> %s [%s];\n", e.Source, e.Field, target, strings.Join(attrs, ", "))
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// dotEscapeRecord escapes the characters that structure record labels.
+func dotEscapeRecord(s string) string {
+	escaped := dotEscape(s)
+	return strings.NewReplacer("{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`).Replace(escaped)
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,147 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const graphTestSchema = `
+version: 1
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      best_friend:
+        type: uuid
+        nullable: true
+        references: users.id
+        onDelete: set null
+      team_id:
+        type: uuid
+        nullable: true
+        references: teams.id
+        onDelete: set null
+  teams:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      owner_id:
+        type: uuid
+        references: users.id
+      logo:
+        type: file
+        nullable: true
+        file:
+          bucket: logos
+          on_delete: cascade
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      author:
+        type: relation
+        relation:
+          collection: users
+          onDelete: cascade
+      title:
+        type: string
+        unique: true
+buckets:
+  logos:
+    backend: local
+`
+
+func TestBuildGraph(t *testing.T) {
+	s, err := Parse([]byte(graphTestSchema))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	g := BuildGraph(s)
+
+	var ids []string
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	if got := strings.Join(ids, ","); got != "collection:posts,collection:teams,collection:users,bucket:logos" {
+		t.Errorf("nodes = %s", got)
+	}
+	if g.Nodes[0].Fields[2].Name != "title" || !g.Nodes[0].Fields[2].Unique {
+		t.Errorf("posts fields = %+v", g.Nodes[0].Fields)
+	}
+
+	tests := []struct {
+		id       string
+		kind     string
+		target   string
+		onDelete OnDeleteAction
+		self     bool
+		cyclic   bool
+	}{
+		{"posts.author", GraphEdgeRelation, "collection:users", OnDeleteCascade, false, false},
+		{"teams.owner_id", GraphEdgeReference, "collection:users", OnDeleteRestrict, false, true},
+		{"teams.logo", GraphEdgeFile, "bucket:logos", OnDeleteCascade, false, false},
+		{"users.best_friend", GraphEdgeReference, "collection:users", OnDeleteSetNull, true, true},
+		{"users.team_id", GraphEdgeReference, "collection:teams", OnDeleteSetNull, false, true},
+	}
+	if len(g.Edges) != len(tests) {
+		t.Fatalf("got %d edges, want %d", len(g.Edges), len(tests))
+	}
+	for i, tt := range tests {
+		e := g.Edges[i]
+		if e.ID != tt.id || e.Kind != tt.kind || e.Target != tt.target || e.OnDelete != tt.onDelete ||
+			e.SelfReference != tt.self || e.Cyclic != tt.cyclic {
+			t.Errorf("edge %d = %+v, want %+v", i, e, tt)
+		}
+	}
+
+	// The graph is stable across builds.
+	first, _ := json.Marshal(g)
+	for i := 0; i < 5; i++ {
+		again, _ := json.Marshal(BuildGraph(s))
+		if string(again) != string(first) {
+			t.Fatal("graph differs between builds")
+		}
+	}
+}
+
+func TestBuildGraph_Empty(t *testing.T) {
+	data, err := json.Marshal(BuildGraph(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"nodes":[],"edges":[]}` {
+		t.Errorf("got %s", data)
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	s, err := Parse([]byte(graphTestSchema))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	g := BuildGraph(s)
+	count := int64(3)
+	g.Nodes[2].RowCount = &count
+
+	dot := g.DOT()
+	for _, want := range []string{
+		"digraph schema {",
+		`"collection:users" [label="{users (3 rows)|<id> id: uuid (PK)\l|<best_friend> best_friend: uuid?\l|<team_id> team_id: uuid?\l}"];`,
+		`"bucket:logos" [shape=cylinder, label="logos (local)"];`,
+		`"collection:users":"best_friend" -> "collection:users":"id" [label="set null"];`,
+		`"collection:users":"team_id" -> "collection:teams":"id" [label="set null", constraint=false];`,
+		`"collection:teams":"logo" -> "bucket:logos" [label="cascade", style=dashed];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %s\n%s", want, dot)
+		}
+	}
+}
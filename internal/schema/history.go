@@ -0,0 +1,122 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HistoryTableSuffix is appended to a collection's name to form the name of
+// its history table.
+const HistoryTableSuffix = "__history"
+
+// History actions, as recorded in a history table's action column.
+const (
+	HistoryActionCreate = "create"
+	HistoryActionUpdate = "update"
+	HistoryActionDelete = "delete"
+)
+
+// HistoryConfig enables the change history of a collection. In schema.yaml
+// it is written either as `history: true` or as a mapping of its options.
+type HistoryConfig struct {
+	// Snapshot stores the full document after each change alongside the
+	// changed fields.
+	Snapshot bool `yaml:"snapshot,omitempty" json:"snapshot,omitempty"`
+	// Retention prunes old history rows. Its field is always created_at.
+	Retention *RetentionPolicy `yaml:"retention,omitempty" json:"retention,omitempty"`
+}
+
+// MarshalYAML writes a config without options as `true`.
+func (h *HistoryConfig) MarshalYAML() (any, error) {
+	if !h.Snapshot && h.Retention == nil {
+		return true, nil
+	}
+	type plain HistoryConfig
+	return (*plain)(h), nil
+}
+
+// parseHistory parses a collection's history block, which is a bool or a
+// mapping. An absent block or `history: false` returns nil.
+func parseHistory(node *yaml.Node) (*HistoryConfig, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.ScalarNode:
+		var enabled bool
+		if err := node.Decode(&enabled); err != nil {
+			return nil, fmt.Errorf("history: must be a boolean or a mapping")
+		}
+		if !enabled {
+			return nil, nil
+		}
+		return &HistoryConfig{}, nil
+	case yaml.MappingNode:
+		h := &HistoryConfig{}
+		if err := node.Decode(h); err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+		return h, nil
+	default:
+		return nil, fmt.Errorf("history: must be a boolean or a mapping")
+	}
+}
+
+// HistoryTable returns the name of the collection's history table.
+func (c *Collection) HistoryTable() string {
+	return c.Name + HistoryTableSuffix
+}
+
+// IsHistoryTable reports whether table is named like a history table.
+func IsHistoryTable(table string) bool {
+	return strings.HasSuffix(table, HistoryTableSuffix)
+}
+
+// HistoryCollection returns the collection stored in the collection's
+// history table. Its retention is the history's retention, if any.
+func (c *Collection) HistoryCollection() *Collection {
+	fields := []*Field{
+		{Name: "id", Type: FieldTypeInt, Primary: true},
+		{Name: "doc_id", Type: FieldTypeString, Index: true},
+		{Name: "action", Type: FieldTypeString},
+		{Name: "actor", Type: FieldTypeString, Nullable: true},
+		{Name: "changes", Type: FieldTypeJSON, Nullable: true},
+		{Name: "snapshot", Type: FieldTypeJSON, Nullable: true},
+		{Name: "created_at", Type: FieldTypeTimestamp, Index: true},
+	}
+	hist := &Collection{
+		Name:   c.HistoryTable(),
+		Fields: make(map[string]*Field, len(fields)),
+	}
+	order := make([]string, 0, len(fields))
+	for _, f := range fields {
+		hist.Fields[f.Name] = f
+		order = append(order, f.Name)
+	}
+	hist.SetFieldOrder(order)
+
+	if c.History != nil && c.History.Retention != nil {
+		r := *c.History.Retention
+		r.Field = "created_at"
+		hist.Retention = &r
+	}
+	return hist
+}
+
+func validateHistory(path string, col *Collection) ValidationErrors {
+	var errs ValidationErrors
+
+	if r := col.History.Retention; r != nil {
+		if r.Field != "" {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".retention.field",
+				Message: "history retention is always ordered by created_at; remove field",
+			})
+		} else {
+			errs = append(errs, validateRetention(path+".retention", col.HistoryCollection())...)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,169 @@
+package schema
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+const historyTestSchema = `
+version: 1
+collections:
+  invoices:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      amount:
+        type: int
+`
+
+func TestParseHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		history  string
+		enabled  bool
+		snapshot bool
+		wantErr  bool
+	}{
+		{"enabled", "    history: true\n", true, false, false},
+		{"disabled", "    history: false\n", false, false, false},
+		{"snapshot", "    history:\n      snapshot: true\n", true, true, false},
+		{"retention", "    history:\n      retention:\n        max_age: 365d\n", true, false, false},
+		{"retention field", "    history:\n      retention:\n        field: created_at\n        max_age: 365d\n", false, false, true},
+		{"retention without limits", "    history:\n      retention: {}\n", false, false, true},
+		{"invalid", "    history: sometimes\n", false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse([]byte(historyTestSchema + tt.history))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			h := s.Collections["invoices"].History
+			if (h != nil) != tt.enabled {
+				t.Fatalf("expected history enabled %v, got %+v", tt.enabled, h)
+			}
+			if h != nil && h.Snapshot != tt.snapshot {
+				t.Errorf("expected snapshot %v, got %v", tt.snapshot, h.Snapshot)
+			}
+		})
+	}
+
+	_, err := Parse([]byte(strings.Replace(historyTestSchema, "invoices:", "invoices__history:", 1)))
+	if err == nil || !strings.Contains(err.Error(), "reserved for history tables") {
+		t.Errorf("expected reserved name error, got %v", err)
+	}
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	for _, history := range []string{
+		"    history: true\n",
+		"    history:\n      snapshot: true\n      retention:\n        max_age: 30d\n",
+	} {
+		s, err := Parse([]byte(historyTestSchema + history))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reparsed, err := Parse(data)
+		if err != nil {
+			t.Fatalf("reparsing marshaled schema: %v\n%s", err, data)
+		}
+		if !reflect.DeepEqual(reparsed.Collections["invoices"].History, s.Collections["invoices"].History) {
+			t.Errorf("history changed in round trip:\n%s", data)
+		}
+	}
+}
+
+func TestHistoryRuleFallback(t *testing.T) {
+	r := &Rules{Read: "true"}
+	if r.HistoryRule() != "true" {
+		t.Errorf("expected history to fall back to read, got %q", r.HistoryRule())
+	}
+	r.History = "auth.role == 'admin'"
+	if r.HistoryRule() != r.History {
+		t.Errorf("expected history rule, got %q", r.HistoryRule())
+	}
+}
+
+func TestHistoryMigration(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	without, err := Parse([]byte(historyTestSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	with, err := Parse([]byte(historyTestSchema + "    history:\n      snapshot: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator := NewMigrator(db, "", "")
+	if err := migrator.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.ApplySchema(without); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := NewDiffer().Diff(current, with)
+	if len(changes) != 1 || changes[0].Type != ChangeAddHistory || !changes[0].Safe {
+		t.Fatalf("expected one safe add history change, got %v", changes)
+	}
+	if err := migrator.ApplySafeChanges(changes, with); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err = InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := current.Collections["invoices__history"]; ok {
+		t.Error("history table inferred as a collection")
+	}
+	if h := current.Collections["invoices"].History; h == nil || !h.Snapshot {
+		t.Errorf("expected cached history config, got %+v", h)
+	}
+	if changes := NewDiffer().Diff(current, with); len(changes) != 0 {
+		t.Errorf("expected no changes after enabling history, got %v", changes)
+	}
+
+	changes = NewDiffer().Diff(current, without)
+	if len(changes) != 1 || changes[0].Type != ChangeDropHistory || changes[0].Safe {
+		t.Fatalf("expected one unsafe drop history change, got %v", changes)
+	}
+	if err := migrator.ApplyUnsafeChanges(changes, without); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'invoices__history'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("expected history table to be dropped")
+	}
+}
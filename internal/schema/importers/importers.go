@@ -0,0 +1,223 @@
+// Package importers converts schema definitions from other systems, such as
+// PocketBase exports and Postgres catalogs, into Alyx schemas.
+//
+// Conversions are best effort: each importer maps what it can to the closest
+// Alyx field config and records everything else as a TODO for the user to
+// resolve by hand.
+package importers
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// Result is a converted schema and the features that could not be converted.
+type Result struct {
+	// Source names the system the schema was imported from.
+	Source string
+	Schema *schema.Schema
+	// TODOs describes unconverted features, one per entry.
+	TODOs []string
+}
+
+// YAML renders the result as a schema.yaml document, with the TODOs as
+// comments above the definitions.
+func (r *Result) YAML() ([]byte, error) {
+	data, err := schema.Marshal(r.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Imported from %s by `alyx schema import`.\n", r.Source)
+	if len(r.TODOs) > 0 {
+		buf.WriteString("#\n# Review these before applying the schema:\n")
+		for _, todo := range r.TODOs {
+			fmt.Fprintf(&buf, "# TODO: %s\n", todo)
+		}
+	}
+	buf.WriteString("\n")
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+func (r *Result) todo(format string, args ...any) {
+	r.TODOs = append(r.TODOs, fmt.Sprintf(format, args...))
+}
+
+// validate checks the converted schema, reporting the first problem as an
+// importer bug rather than leaving the user with an invalid schema.yaml.
+func (r *Result) validate() error {
+	if err := schema.Validate(r.Schema); err != nil {
+		return fmt.Errorf("converted schema is invalid: %w", err)
+	}
+	return nil
+}
+
+func newSchema() *schema.Schema {
+	return &schema.Schema{
+		Version:     1,
+		Collections: make(map[string]*schema.Collection),
+		Buckets:     make(map[string]*schema.Bucket),
+		Functions:   make(map[string]*schema.Function),
+	}
+}
+
+// collectionBuilder adds fields to a collection, keeping their order.
+type collectionBuilder struct {
+	col   *schema.Collection
+	order []string
+}
+
+func newCollection(name string) *collectionBuilder {
+	return &collectionBuilder{col: &schema.Collection{
+		Name:   name,
+		Fields: make(map[string]*schema.Field),
+	}}
+}
+
+func (b *collectionBuilder) add(f *schema.Field) {
+	if _, exists := b.col.Fields[f.Name]; !exists {
+		b.order = append(b.order, f.Name)
+	}
+	b.col.Fields[f.Name] = f
+}
+
+func (b *collectionBuilder) has(name string) bool {
+	_, ok := b.col.Fields[name]
+	return ok
+}
+
+func (b *collectionBuilder) build() *schema.Collection {
+	b.col.SetFieldOrder(b.order)
+	return b.col
+}
+
+// identifier converts a foreign name such as createdAt or Order-Items into
+// an Alyx identifier (created_at, order_items).
+func identifier(name string) string {
+	var sb strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(unicode.ToLower(r))
+		default:
+			sb.WriteByte('_')
+		}
+	}
+
+	id := strings.Trim(sb.String(), "_")
+	for strings.Contains(id, "__") {
+		id = strings.ReplaceAll(id, "__", "_")
+	}
+	if id == "" || !unicode.IsLetter(rune(id[0])) {
+		id = "x_" + id
+	}
+	return id
+}
+
+// indexSQLRegex matches CREATE INDEX statements as PocketBase stores them and
+// as Postgres reports them in pg_indexes.indexdef.
+var indexSQLRegex = regexp.MustCompile(`(?is)^\s*CREATE\s+(UNIQUE\s+)?INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?["` + "`" + `]?([\w.]+?)["` + "`" + `]?\s+ON\s+(?:ONLY\s+)?["` + "`" + `]?([\w.]+?)["` + "`" + `]?\s*(?:USING\s+(\w+)\s*)?\((.*)\)\s*(WHERE\s+.*)?;?\s*$`)
+
+// indexColumnRegex matches one plain column of an index, optionally quoted
+// and followed by a sort direction.
+var indexColumnRegex = regexp.MustCompile(`(?i)^["` + "`" + `]?(\w+)["` + "`" + `]?(?:\s+(ASC|DESC))?$`)
+
+// parsedIndex is a CREATE INDEX statement reduced to what Alyx indexes
+// support.
+type parsedIndex struct {
+	Name    string
+	Table   string
+	Columns []string
+	Unique  bool
+	Desc    bool
+}
+
+// parseIndexSQL converts a CREATE INDEX statement. It returns a reason when
+// the index uses features Alyx indexes cannot express, such as expressions,
+// partial indexes or non-btree methods.
+func parseIndexSQL(stmt string) (*parsedIndex, string) {
+	m := indexSQLRegex.FindStringSubmatch(stmt)
+	if m == nil {
+		return nil, "unrecognized index definition"
+	}
+
+	idx := &parsedIndex{
+		Name:   lastPart(m[2]),
+		Table:  lastPart(m[3]),
+		Unique: m[1] != "",
+	}
+	if m[4] != "" && !strings.EqualFold(m[4], "btree") {
+		return idx, fmt.Sprintf("uses the %s method", m[4])
+	}
+	if m[6] != "" {
+		return idx, "is a partial index (" + strings.TrimSpace(m[6]) + ")"
+	}
+
+	var desc, asc int
+	for _, part := range strings.Split(m[5], ",") {
+		cm := indexColumnRegex.FindStringSubmatch(strings.TrimSpace(part))
+		if cm == nil {
+			return idx, "indexes an expression (" + strings.TrimSpace(m[5]) + ")"
+		}
+		idx.Columns = append(idx.Columns, cm[1])
+		if strings.EqualFold(cm[2], "DESC") {
+			desc++
+		} else {
+			asc++
+		}
+	}
+	if desc > 0 && asc > 0 {
+		return idx, "mixes sort directions"
+	}
+	idx.Desc = desc > 0
+	return idx, ""
+}
+
+func lastPart(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// addIndex converts idx into an index on b, with columns renamed through
+// names. It returns false if a column is unknown.
+func addIndex(b *collectionBuilder, idx *parsedIndex, names map[string]string) bool {
+	fields := make([]string, 0, len(idx.Columns))
+	for _, column := range idx.Columns {
+		name, ok := names[column]
+		if !ok {
+			return false
+		}
+		fields = append(fields, name)
+	}
+
+	index := &schema.Index{
+		Name:   identifier(idx.Name),
+		Fields: fields,
+		Unique: idx.Unique,
+	}
+	if idx.Desc {
+		index.Order = "desc"
+	}
+	b.col.Indexes = append(b.col.Indexes, index)
+	return true
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,360 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func loadPostgresFixture(data []byte) (*Result, error) {
+	var cat PostgresCatalog
+	if err := json.Unmarshal(data, &cat); err != nil {
+		return nil, err
+	}
+	return FromPostgres(&cat)
+}
+
+func TestImportFixtures(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		convert func([]byte) (*Result, error)
+		// collections lists the collections the import must produce.
+		collections []string
+		// todos are substrings each expected in some TODO.
+		todos []string
+	}{
+		{
+			name:        "pocketbase v0.22",
+			fixture:     "pocketbase_v0.22.json",
+			convert:     FromPocketBase,
+			collections: []string{"posts", "comments"},
+			todos: []string{
+				"auth collection users was not converted",
+				"view collection post_stats was not converted",
+				"posts.view_count was renamed from viewCount",
+				"posts.gallery",
+				"idx_posts_lower_title",
+				"comments: create rule",
+			},
+		},
+		{
+			name:        "pocketbase v0.23",
+			fixture:     "pocketbase_v0.23.json",
+			convert:     FromPocketBase,
+			collections: []string{"projects", "tasks"},
+			todos: []string{
+				"auth collection users was not converted",
+				"collection projects was renamed from Projects",
+				"projects.location has PocketBase type geoPoint",
+				"tasks.blockers relates to up to 999 tasks",
+				"projects: delete rule",
+			},
+		},
+		{
+			name:        "postgres",
+			fixture:     "postgres_catalog.json",
+			convert:     loadPostgresFixture,
+			collections: []string{"authors", "articles", "article_tags"},
+			todos: []string{
+				"view published_articles was not converted",
+				"check constraint articles_rating_check",
+				"articles.id is a serial column",
+				"articles.tags is a text array",
+				"articles.search has type tsvector",
+				"articles_search_idx",
+				"articles_lower_title_idx",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := tt.convert(data)
+			if err != nil {
+				t.Fatalf("convert: %v", err)
+			}
+
+			for _, name := range tt.collections {
+				if res.Schema.Collections[name] == nil {
+					t.Errorf("missing collection %q", name)
+				}
+			}
+			if len(res.Schema.Collections) != len(tt.collections) {
+				t.Errorf("got %d collections, want %d", len(res.Schema.Collections), len(tt.collections))
+			}
+			for _, want := range tt.todos {
+				found := false
+				for _, todo := range res.TODOs {
+					if strings.Contains(todo, want) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("no TODO mentions %q; got:\n%s", want, strings.Join(res.TODOs, "\n"))
+				}
+			}
+
+			out, err := res.YAML()
+			if err != nil {
+				t.Fatalf("render YAML: %v", err)
+			}
+			if _, err := schema.Parse(out); err != nil {
+				t.Fatalf("imported schema does not parse: %v\n%s", err, out)
+			}
+
+			golden := filepath.Join("testdata", strings.TrimSuffix(tt.fixture, ".json")+".golden.yaml")
+			if *updateGolden {
+				if err := os.WriteFile(golden, out, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden file (run go test ./internal/schema/importers -update): %v", err)
+			}
+			if !bytes.Equal(out, want) {
+				t.Errorf("output does not match %s (run go test ./internal/schema/importers -update):\n%s", golden, out)
+			}
+		})
+	}
+}
+
+func TestImportPocketBaseFields(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "pocketbase_v0.22.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := FromPocketBase(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	posts := res.Schema.Collections["posts"]
+	tests := []struct {
+		field    string
+		typ      schema.FieldType
+		nullable bool
+		check    func(*schema.Field) bool
+	}{
+		{"id", schema.FieldTypeID, false, func(f *schema.Field) bool { return f.Primary && f.Default == string(schema.DefaultAuto) }},
+		{"title", schema.FieldTypeString, false, func(f *schema.Field) bool {
+			return f.MinLength != nil && *f.MinLength == 1 && f.MaxLength != nil && *f.MaxLength == 200
+		}},
+		{"slug", schema.FieldTypeString, false, func(f *schema.Field) bool { return f.Validate != nil && f.Validate.Pattern == "^[a-z0-9-]+$" }},
+		{"body", schema.FieldTypeRichText, true, nil},
+		{"status", schema.FieldTypeSelect, false, func(f *schema.Field) bool { return f.Select != nil && len(f.Select.Values) == 2 }},
+		{"author", schema.FieldTypeString, false, func(f *schema.Field) bool { return f.OnUserDelete == schema.UserDeleteCascade }},
+		{"view_count", schema.FieldTypeInt, true, nil},
+		{"published_at", schema.FieldTypeTimestamp, true, nil},
+		{"gallery", schema.FieldTypeJSON, true, nil},
+		{"created", schema.FieldTypeTimestamp, false, func(f *schema.Field) bool { return f.Default == string(schema.DefaultNow) }},
+	}
+	for _, tt := range tests {
+		f := posts.Fields[tt.field]
+		if f == nil {
+			t.Errorf("posts.%s: missing", tt.field)
+			continue
+		}
+		if f.Type != tt.typ || f.Nullable != tt.nullable {
+			t.Errorf("posts.%s: got type %s nullable %v, want %s nullable %v", tt.field, f.Type, f.Nullable, tt.typ, tt.nullable)
+		}
+		if tt.check != nil && !tt.check(f) {
+			t.Errorf("posts.%s: unexpected config %+v", tt.field, f)
+		}
+	}
+
+	post := res.Schema.Collections["comments"].Fields["post"]
+	if post.References != "posts.id" || post.OnDelete != schema.OnDeleteCascade {
+		t.Errorf("comments.post: got references %q onDelete %q", post.References, post.OnDelete)
+	}
+	if got := posts.Rules.Read; got != `doc.status == 'published' || doc.author == auth.id` {
+		t.Errorf("posts read rule = %q", got)
+	}
+	if got := posts.Rules.Delete; got != adminOnlyRule {
+		t.Errorf("posts delete rule = %q, want admin-only", got)
+	}
+}
+
+func TestImportPostgresConstraints(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "postgres_catalog.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := loadPostgresFixture(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authors := res.Schema.Collections["authors"]
+	if f := authors.Fields["email"]; !f.Unique || f.MaxLength == nil || *f.MaxLength != 255 {
+		t.Errorf("authors.email: got %+v", f)
+	}
+	if f := authors.Fields["role"]; f.Type != schema.FieldTypeSelect || f.Default != "writer" {
+		t.Errorf("authors.role: got %+v", f)
+	}
+
+	articles := res.Schema.Collections["articles"]
+	if f := articles.Fields["author_id"]; f.References != "authors.id" || f.OnDelete != schema.OnDeleteSetNull {
+		t.Errorf("articles.author_id: got references %q onDelete %q", f.References, f.OnDelete)
+	}
+	var unique, desc bool
+	for _, idx := range articles.Indexes {
+		if idx.Unique && strings.Join(idx.Fields, ",") == "author_id,title" {
+			unique = true
+		}
+		if idx.Order == "desc" && strings.Join(idx.Fields, ",") == "published_on" {
+			desc = true
+		}
+	}
+	if !unique || !desc {
+		t.Errorf("articles indexes missing composite unique or descending index: %+v", articles.Indexes)
+	}
+
+	tags := res.Schema.Collections["article_tags"]
+	if f := tags.Fields["id"]; f == nil || !f.Primary {
+		t.Fatalf("article_tags: expected a synthesized id primary key, got %+v", f)
+	}
+	if f := tags.Fields["article_id"]; f.References != "articles.id" || f.OnDelete != schema.OnDeleteCascade {
+		t.Errorf("article_tags.article_id: got references %q onDelete %q", f.References, f.OnDelete)
+	}
+}
+
+func TestTranslatePBFilter(t *testing.T) {
+	names := map[string]string{"owner": "owner", "status": "status", "viewCount": "view_count"}
+	tests := []struct {
+		filter  string
+		want    string
+		wantErr bool
+	}{
+		{`owner = @request.auth.id`, `doc.owner == auth.id`, false},
+		{`@request.auth.id != ""`, `auth.id != null`, false},
+		{`@request.auth.id != '' && status = 'active'`, `auth.id != null && doc.status == 'active'`, false},
+		{`(viewCount > 10 || status = "hot") && @request.auth.verified = true`, `(doc.view_count > 10 || doc.status == 'hot') && auth.verified == true`, false},
+		{`owner.role ?= 'admin'`, "", true},
+		{`status ~ "draft"`, "", true},
+		{`@request.data.owner = @request.auth.id`, "", true},
+		{`missing = 1`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			got, err := translatePBFilter(tt.filter, names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIndexSQL(t *testing.T) {
+	tests := []struct {
+		stmt    string
+		want    *parsedIndex
+		wantWhy string
+	}{
+		{
+			stmt: "CREATE UNIQUE INDEX `idx_slug` ON `posts` (`slug`)",
+			want: &parsedIndex{Name: "idx_slug", Table: "posts", Columns: []string{"slug"}, Unique: true},
+		},
+		{
+			stmt: "CREATE INDEX IF NOT EXISTS idx_a ON t (a DESC, b DESC)",
+			want: &parsedIndex{Name: "idx_a", Table: "t", Columns: []string{"a", "b"}, Desc: true},
+		},
+		{
+			stmt: "CREATE INDEX x_idx ON public.t USING btree (\"userId\")",
+			want: &parsedIndex{Name: "x_idx", Table: "t", Columns: []string{"userId"}},
+		},
+		{stmt: "CREATE INDEX g ON public.t USING gin (doc)", wantWhy: "uses the gin method"},
+		{stmt: "CREATE INDEX p ON t (a) WHERE a IS NOT NULL", wantWhy: "is a partial index"},
+		{stmt: "CREATE INDEX e ON t (lower(a))", wantWhy: "indexes an expression"},
+		{stmt: "CREATE INDEX m ON t (a ASC, b DESC)", wantWhy: "mixes sort directions"},
+		{stmt: "ALTER TABLE t ADD x int", wantWhy: "unrecognized index definition"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			got, why := parseIndexSQL(tt.stmt)
+			if tt.wantWhy != "" {
+				if !strings.HasPrefix(why, tt.wantWhy) {
+					t.Errorf("reason = %q, want prefix %q", why, tt.wantWhy)
+				}
+				return
+			}
+			if why != "" {
+				t.Fatalf("unexpected reason %q", why)
+			}
+			if got.Name != tt.want.Name || got.Table != tt.want.Table || got.Unique != tt.want.Unique ||
+				got.Desc != tt.want.Desc || strings.Join(got.Columns, ",") != strings.Join(tt.want.Columns, ",") {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"posts":       "posts",
+		"createdAt":   "created_at",
+		"Order-Items": "order_items",
+		"HTTPStatus":  "http_status",
+		"userID":      "user_id",
+		"2fa_codes":   "x_2fa_codes",
+		"__hidden":    "hidden",
+		"a  b":        "a_b",
+	}
+	for in, want := range tests {
+		if got := identifier(in); got != want {
+			t.Errorf("identifier(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestConvertPGDefault(t *testing.T) {
+	tests := []struct {
+		typ      schema.FieldType
+		def      string
+		want     string
+		wantTODO bool
+	}{
+		{schema.FieldTypeUUID, "gen_random_uuid()", "auto", false},
+		{schema.FieldTypeTimestamp, "now()", "now", false},
+		{schema.FieldTypeTimestamp, "CURRENT_TIMESTAMP", "now", false},
+		{schema.FieldTypeBool, "false", "false", false},
+		{schema.FieldTypeInt, "0", "0", false},
+		{schema.FieldTypeFloat, "(1.5)", "1.5", false},
+		{schema.FieldTypeString, "'it''s'::character varying", "it's", false},
+		{schema.FieldTypeInt, "nextval('t_id_seq'::regclass)", "", false},
+		{schema.FieldTypeString, "NULL::character varying", "", false},
+		{schema.FieldTypeString, "upper('x'::text)", "", true},
+		{schema.FieldTypeString, "gen_random_uuid()", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.def, func(t *testing.T) {
+			res := &Result{}
+			field := &schema.Field{Name: "f", Type: tt.typ}
+			convertPGDefault(res, "t.f", field, tt.def)
+			if field.Default != tt.want {
+				t.Errorf("default = %q, want %q", field.Default, tt.want)
+			}
+			if (len(res.TODOs) > 0) != tt.wantTODO {
+				t.Errorf("TODOs = %v, wantTODO %v", res.TODOs, tt.wantTODO)
+			}
+		})
+	}
+}
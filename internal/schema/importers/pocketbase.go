@@ -0,0 +1,474 @@
+package importers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// pocketBaseBucket is the bucket created for converted file fields.
+const pocketBaseBucket = "files"
+
+// adminOnlyRule is what a locked PocketBase rule (null, superusers only)
+// becomes, and the fallback for rules that cannot be translated.
+const adminOnlyRule = "auth.role == 'admin'"
+
+// pbCollection is a collection in a PocketBase schema export. Versions before
+// 0.23 list fields under "schema" with type-specific "options"; later
+// versions list them under "fields" with the options inlined.
+type pbCollection struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	System     bool      `json:"system"`
+	Schema     []pbField `json:"schema"`
+	Fields     []pbField `json:"fields"`
+	Indexes    []string  `json:"indexes"`
+	ListRule   *string   `json:"listRule"`
+	ViewRule   *string   `json:"viewRule"`
+	CreateRule *string   `json:"createRule"`
+	UpdateRule *string   `json:"updateRule"`
+	DeleteRule *string   `json:"deleteRule"`
+	ViewQuery  string    `json:"viewQuery"`
+	Options    struct {
+		Query string `json:"query"`
+	} `json:"options"`
+}
+
+type pbField struct {
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Required   bool            `json:"required"`
+	System     bool            `json:"system"`
+	Unique     bool            `json:"unique"`
+	PrimaryKey bool            `json:"primaryKey"`
+	Options    json.RawMessage `json:"options"`
+
+	Min           pbBound  `json:"min"`
+	Max           pbBound  `json:"max"`
+	Pattern       string   `json:"pattern"`
+	OnlyInt       bool     `json:"onlyInt"`
+	NoDecimal     bool     `json:"noDecimal"`
+	Values        []string `json:"values"`
+	MaxSelect     int      `json:"maxSelect"`
+	CollectionID  string   `json:"collectionId"`
+	CascadeDelete bool     `json:"cascadeDelete"`
+	MaxSize       int64    `json:"maxSize"`
+	MimeTypes     []string `json:"mimeTypes"`
+	OnCreate      bool     `json:"onCreate"`
+	OnUpdate      bool     `json:"onUpdate"`
+}
+
+// pbBound is a min or max option. Date fields store their bounds as date
+// strings, which are ignored.
+type pbBound struct {
+	value *float64
+}
+
+func (b *pbBound) UnmarshalJSON(data []byte) error {
+	var n *float64
+	if err := json.Unmarshal(data, &n); err == nil {
+		b.value = n
+	}
+	return nil
+}
+
+// FromPocketBase converts a PocketBase schema export (the JSON written by
+// Settings > Export collections) into an Alyx schema. Auth, view and system
+// collections are not converted; they are listed as TODOs.
+func FromPocketBase(data []byte) (*Result, error) {
+	var collections []pbCollection
+	if err := json.Unmarshal(data, &collections); err != nil {
+		return nil, fmt.Errorf("parsing PocketBase export: %w", err)
+	}
+
+	res := &Result{Source: "PocketBase", Schema: newSchema()}
+	byID := make(map[string]*pbCollection, len(collections))
+	for i := range collections {
+		c := &collections[i]
+		byID[c.ID] = c
+		for j := range c.Schema {
+			if err := c.Schema[j].decodeOptions(); err != nil {
+				return nil, fmt.Errorf("collection %s: field %s: %w", c.Name, c.Schema[j].Name, err)
+			}
+		}
+	}
+
+	for i := range collections {
+		c := &collections[i]
+		switch {
+		case c.System || strings.HasPrefix(c.Name, "_"):
+			continue
+		case c.Type == "view":
+			query := c.ViewQuery
+			if query == "" {
+				query = c.Options.Query
+			}
+			res.todo("view collection %s was not converted; recreate it as a function or query: %s", c.Name, oneLine(query))
+			continue
+		case c.Type == "auth":
+			res.todo("auth collection %s was not converted; Alyx manages users itself, so migrate accounts separately%s",
+				c.Name, customFieldNote(c))
+			continue
+		}
+
+		col := convertPBCollection(res, c, byID)
+		res.Schema.Collections[col.Name] = col
+	}
+
+	return res, res.validate()
+}
+
+func (f *pbField) decodeOptions() error {
+	if len(f.Options) == 0 || string(f.Options) == "null" {
+		return nil
+	}
+	// Options use the same keys as the inlined fields of newer exports.
+	return json.Unmarshal(f.Options, f)
+}
+
+// fields returns the collection's fields, adding the implicit id, created
+// and updated fields of exports before 0.23.
+func (c *pbCollection) fields() []pbField {
+	if len(c.Fields) > 0 {
+		return c.Fields
+	}
+	fields := []pbField{{Name: "id", Type: "text", System: true, PrimaryKey: true, Required: true}}
+	fields = append(fields, c.Schema...)
+	return append(fields,
+		pbField{Name: "created", Type: "autodate", System: true, OnCreate: true},
+		pbField{Name: "updated", Type: "autodate", System: true, OnCreate: true, OnUpdate: true},
+	)
+}
+
+func customFieldNote(c *pbCollection) string {
+	var custom []string
+	for _, f := range c.fields() {
+		// Alyx users already carry created and updated timestamps.
+		if !f.System && f.Type != "password" && f.Type != "autodate" {
+			custom = append(custom, f.Name)
+		}
+	}
+	if len(custom) == 0 {
+		return ""
+	}
+	return " and declare its custom fields (" + strings.Join(custom, ", ") + ") under userMetadata"
+}
+
+func convertPBCollection(res *Result, c *pbCollection, byID map[string]*pbCollection) *schema.Collection {
+	name := identifier(c.Name)
+	if name != c.Name {
+		res.todo("collection %s was renamed from %s", name, c.Name)
+	}
+
+	b := newCollection(name)
+	names := make(map[string]string)
+	for _, f := range c.fields() {
+		field := convertPBField(res, name, f, byID)
+		if field == nil {
+			continue
+		}
+		if b.has(field.Name) {
+			res.todo("%s.%s: field %s maps to the same name as another field and was dropped", name, field.Name, f.Name)
+			continue
+		}
+		if field.Name != f.Name {
+			res.todo("%s.%s was renamed from %s", name, field.Name, f.Name)
+		}
+		names[f.Name] = field.Name
+		b.add(field)
+	}
+
+	for _, stmt := range c.Indexes {
+		idx, reason := parseIndexSQL(stmt)
+		if reason == "" && !addIndex(b, idx, names) {
+			reason = "references a field that was not converted"
+		}
+		if reason != "" {
+			res.todo("%s: index %s: %s", name, oneLine(stmt), reason)
+		}
+	}
+
+	rules := &schema.Rules{
+		Create: convertPBRule(res, name, "create", c.CreateRule, names),
+		Read:   convertPBRule(res, name, "read", c.ViewRule, names),
+		Update: convertPBRule(res, name, "update", c.UpdateRule, names),
+		Delete: convertPBRule(res, name, "delete", c.DeleteRule, names),
+	}
+	if ruleText(c.ListRule) != ruleText(c.ViewRule) {
+		res.todo("%s: listRule %s differs from viewRule; Alyx applies the read rule to both", name, ruleText(c.ListRule))
+	}
+	b.col.Rules = rules
+
+	return b.build()
+}
+
+func convertPBField(res *Result, collection string, f pbField, byID map[string]*pbCollection) *schema.Field {
+	field := &schema.Field{
+		Name:     identifier(f.Name),
+		Nullable: !f.Required,
+		Unique:   f.Unique,
+	}
+	path := collection + "." + field.Name
+
+	switch f.Type {
+	case "text":
+		if f.PrimaryKey {
+			field.Type = schema.FieldTypeID
+			field.Primary = true
+			field.Nullable = false
+			field.Default = string(schema.DefaultAuto)
+			return field
+		}
+		field.Type = schema.FieldTypeString
+		if f.Min.value != nil && *f.Min.value > 0 {
+			field.MinLength = intPtr(int(*f.Min.value))
+		}
+		if f.Max.value != nil && *f.Max.value > 0 {
+			field.MaxLength = intPtr(int(*f.Max.value))
+		}
+		if f.Pattern != "" {
+			field.Validate = &schema.FieldValidation{Pattern: f.Pattern}
+		}
+	case "editor":
+		field.Type = schema.FieldTypeRichText
+	case "number":
+		field.Type = schema.FieldTypeFloat
+		if f.OnlyInt || f.NoDecimal {
+			field.Type = schema.FieldTypeInt
+		}
+		if f.Min.value != nil || f.Max.value != nil {
+			field.Validate = &schema.FieldValidation{Min: f.Min.value, Max: f.Max.value}
+		}
+	case "bool":
+		field.Type = schema.FieldTypeBool
+	case "email":
+		field.Type = schema.FieldTypeEmail
+	case "url":
+		field.Type = schema.FieldTypeURL
+	case "date":
+		field.Type = schema.FieldTypeTimestamp
+	case "autodate":
+		field.Type = schema.FieldTypeTimestamp
+		field.Nullable = false
+		if f.OnCreate {
+			field.Default = string(schema.DefaultNow)
+		}
+		if f.OnUpdate {
+			field.OnUpdate = string(schema.DefaultNow)
+		}
+	case "select":
+		field.Type = schema.FieldTypeSelect
+		field.Select = &schema.SelectConfig{Values: f.Values, MaxSelect: max(f.MaxSelect, 1)}
+	case "json":
+		field.Type = schema.FieldTypeJSON
+	case "file":
+		if f.MaxSelect > 1 {
+			field.Type = schema.FieldTypeJSON
+			res.todo("%s holds up to %d files; Alyx file fields hold one, so it was converted to json", path, f.MaxSelect)
+			return field
+		}
+		field.Type = schema.FieldTypeFile
+		field.File = &schema.FileConfig{Bucket: pocketBaseBucket, MaxSize: f.MaxSize, AllowedTypes: f.MimeTypes}
+		if _, ok := res.Schema.Buckets[pocketBaseBucket]; !ok {
+			res.Schema.Buckets[pocketBaseBucket] = &schema.Bucket{Name: pocketBaseBucket, Backend: "local"}
+			res.todo("file fields use the %s bucket on the local backend; copy the files from pb_data/storage", pocketBaseBucket)
+		}
+	case "relation":
+		return convertPBRelation(res, path, field, f, byID)
+	case "password":
+		return nil
+	default:
+		field.Type = schema.FieldTypeJSON
+		res.todo("%s has PocketBase type %s, which has no Alyx equivalent; it was converted to json", path, f.Type)
+	}
+	return field
+}
+
+func convertPBRelation(res *Result, path string, field *schema.Field, f pbField, byID map[string]*pbCollection) *schema.Field {
+	target, ok := byID[f.CollectionID]
+	switch {
+	case !ok:
+		field.Type = schema.FieldTypeString
+		res.todo("%s references unknown collection %s; it was converted to a plain string", path, f.CollectionID)
+		return field
+	case f.MaxSelect > 1:
+		field.Type = schema.FieldTypeJSON
+		res.todo("%s relates to up to %d %s; Alyx relations hold one, so it was converted to json", path, f.MaxSelect, target.Name)
+		return field
+	case target.Type == "auth":
+		field.Type = schema.FieldTypeString
+		field.OnUserDelete = schema.UserDeleteKeep
+		if f.CascadeDelete {
+			field.OnUserDelete = schema.UserDeleteCascade
+		} else if field.Nullable {
+			field.OnUserDelete = schema.UserDeleteSetNull
+		}
+		res.todo("%s holds a %s user ID; map it to the new Alyx user IDs when migrating accounts", path, target.Name)
+		return field
+	case target.Type == "view" || target.System || strings.HasPrefix(target.Name, "_"):
+		field.Type = schema.FieldTypeString
+		res.todo("%s references %s, which was not converted; it was converted to a plain string", path, target.Name)
+		return field
+	}
+
+	field.Type = schema.FieldTypeID
+	field.References = identifier(target.Name) + ".id"
+	switch {
+	case f.CascadeDelete:
+		field.OnDelete = schema.OnDeleteCascade
+	case field.Nullable:
+		field.OnDelete = schema.OnDeleteSetNull
+	default:
+		field.OnDelete = schema.OnDeleteRestrict
+	}
+	return field
+}
+
+func ruleText(rule *string) string {
+	if rule == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%q", *rule)
+}
+
+// convertPBRule translates a PocketBase API rule into CEL. null (superusers
+// only) becomes an admin-only rule and "" (anyone) becomes "true". Rules the
+// translator cannot express are locked to admins and left as TODOs.
+func convertPBRule(res *Result, collection, op string, rule *string, names map[string]string) string {
+	if rule == nil {
+		return adminOnlyRule
+	}
+	if strings.TrimSpace(*rule) == "" {
+		return "true"
+	}
+	cel, err := translatePBFilter(*rule, names)
+	if err != nil {
+		res.todo("%s: %s rule %q was not translated (%v); it is admin-only until rewritten in CEL", collection, op, *rule, err)
+		return adminOnlyRule
+	}
+	return cel
+}
+
+// pbAuthFields are the @request.auth fields with an Alyx equivalent.
+var pbAuthFields = map[string]string{
+	"id":       "auth.id",
+	"email":    "auth.email",
+	"verified": "auth.verified",
+}
+
+// translatePBFilter converts a PocketBase filter expression using plain
+// comparisons, && and || into CEL. Collection fields become doc fields.
+func translatePBFilter(filter string, names map[string]string) (string, error) {
+	tokens, err := tokenizePBFilter(filter)
+	if err != nil {
+		return "", err
+	}
+
+	out := make([]string, 0, len(tokens))
+	for i, tok := range tokens {
+		switch {
+		case tok == "(" || tok == ")" || tok == "&&" || tok == "||" ||
+			tok == "!=" || tok == ">" || tok == ">=" || tok == "<" || tok == "<=":
+			out = append(out, tok)
+		case tok == "=":
+			out = append(out, "==")
+		case tok == "true" || tok == "false" || tok == "null":
+			out = append(out, tok)
+		case tok[0] == '\'' || tok[0] == '"':
+			value := tok[1 : len(tok)-1]
+			if value == "" && comparesWithAuth(tokens, i) {
+				// Anonymous requests have no auth.id, where PocketBase has "".
+				out = append(out, "null")
+				continue
+			}
+			out = append(out, "'"+strings.ReplaceAll(value, "'", `\'`)+"'")
+		case tok[0] >= '0' && tok[0] <= '9' || tok[0] == '-':
+			out = append(out, tok)
+		case strings.HasPrefix(tok, "@request.auth."):
+			mapped, ok := pbAuthFields[strings.TrimPrefix(tok, "@request.auth.")]
+			if !ok {
+				return "", fmt.Errorf("%s has no Alyx equivalent", tok)
+			}
+			out = append(out, mapped)
+		case tok[0] == '@' || strings.ContainsAny(tok, ".:"):
+			return "", fmt.Errorf("%s is not supported", tok)
+		default:
+			name, ok := names[tok]
+			if !ok {
+				return "", fmt.Errorf("unknown field %s", tok)
+			}
+			out = append(out, "doc."+name)
+		}
+	}
+	return joinCEL(out), nil
+}
+
+// joinCEL joins tokens with spaces, except just inside parentheses.
+func joinCEL(tokens []string) string {
+	var sb strings.Builder
+	for i, tok := range tokens {
+		if i > 0 && tok != ")" && tokens[i-1] != "(" {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(tok)
+	}
+	return sb.String()
+}
+
+// comparesWithAuth reports whether the operand at i is compared against an
+// @request.auth value.
+func comparesWithAuth(tokens []string, i int) bool {
+	if i >= 2 && strings.HasPrefix(tokens[i-2], "@request.auth.") {
+		return true
+	}
+	return i+2 < len(tokens) && strings.HasPrefix(tokens[i+2], "@request.auth.")
+}
+
+func tokenizePBFilter(filter string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(filter); {
+		c := filter[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(filter[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, filter[i:i+end+2])
+			i += end + 2
+		case strings.ContainsRune("=!<>~?&|", rune(c)):
+			j := i
+			for j < len(filter) && strings.ContainsRune("=!<>~?&|", rune(filter[j])) {
+				j++
+			}
+			op := filter[i:j]
+			switch op {
+			case "=", "!=", ">", ">=", "<", "<=", "&&", "||":
+			default:
+				return nil, fmt.Errorf("operator %s is not supported", op)
+			}
+			tokens = append(tokens, op)
+			i = j
+		default:
+			j := i
+			for j < len(filter) && !strings.ContainsRune(" \t\n\r()'\"=!<>~?&|", rune(filter[j])) {
+				j++
+			}
+			tokens = append(tokens, filter[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,527 @@
+package importers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// PostgresCatalog is the part of a Postgres database's catalog the importer
+// reads. LoadPostgresCatalog fills it from information_schema; tests build
+// it from captured JSON.
+type PostgresCatalog struct {
+	Schema string              `json:"schema"`
+	Tables []PostgresTable     `json:"tables"`
+	Views  []PostgresView      `json:"views,omitempty"`
+	Enums  map[string][]string `json:"enums,omitempty"`
+}
+
+// PostgresTable is a base table with its constraints and indexes.
+type PostgresTable struct {
+	Name        string               `json:"name"`
+	Columns     []PostgresColumn     `json:"columns"`
+	PrimaryKey  []string             `json:"primary_key,omitempty"`
+	Unique      []PostgresConstraint `json:"unique,omitempty"`
+	ForeignKeys []PostgresForeignKey `json:"foreign_keys,omitempty"`
+	Checks      []PostgresCheck      `json:"checks,omitempty"`
+	// Indexes holds CREATE INDEX definitions for indexes that do not back a
+	// primary key or unique constraint.
+	Indexes []string `json:"indexes,omitempty"`
+}
+
+// PostgresColumn is a row of information_schema.columns.
+type PostgresColumn struct {
+	Name      string  `json:"name"`
+	DataType  string  `json:"data_type"`
+	UDTName   string  `json:"udt_name"`
+	Nullable  bool    `json:"nullable"`
+	Default   *string `json:"default,omitempty"`
+	MaxLength *int    `json:"max_length,omitempty"`
+}
+
+// PostgresConstraint is a named constraint over one or more columns.
+type PostgresConstraint struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// PostgresForeignKey is a foreign key constraint.
+type PostgresForeignKey struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns"`
+	// OnDelete is the referential action as information_schema spells it,
+	// such as CASCADE or SET NULL.
+	OnDelete string `json:"on_delete"`
+}
+
+// PostgresCheck is a CHECK constraint.
+type PostgresCheck struct {
+	Name   string `json:"name"`
+	Clause string `json:"clause"`
+}
+
+// PostgresView is a view and its defining query.
+type PostgresView struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// LoadPostgresCatalog reads the tables, views and enum types of the named
+// Postgres schema (usually "public") through information_schema and
+// pg_catalog. db must use a Postgres driver.
+func LoadPostgresCatalog(ctx context.Context, db *sql.DB, schemaName string) (*PostgresCatalog, error) {
+	cat := &PostgresCatalog{Schema: schemaName, Enums: make(map[string][]string)}
+	tables := make(map[string]*PostgresTable)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = $1 AND table_type = 'BASE TABLE'
+		ORDER BY table_name`, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("listing tables: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		cat.Tables = append(cat.Tables, PostgresTable{Name: name})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing tables: %w", err)
+	}
+	for i := range cat.Tables {
+		tables[cat.Tables[i].Name] = &cat.Tables[i]
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT table_name, column_name, data_type, udt_name, is_nullable = 'YES',
+		       column_default, character_maximum_length
+		FROM information_schema.columns
+		WHERE table_schema = $1
+		ORDER BY table_name, ordinal_position`, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("reading columns: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var table string
+		var col PostgresColumn
+		var def sql.NullString
+		var maxLen sql.NullInt64
+		if err := rows.Scan(&table, &col.Name, &col.DataType, &col.UDTName, &col.Nullable, &def, &maxLen); err != nil {
+			return err
+		}
+		if def.Valid {
+			col.Default = &def.String
+		}
+		if maxLen.Valid {
+			col.MaxLength = intPtr(int(maxLen.Int64))
+		}
+		if t, ok := tables[table]; ok {
+			t.Columns = append(t.Columns, col)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading columns: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT tc.table_name, tc.constraint_name, tc.constraint_type, kcu.column_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+		  ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name
+		WHERE tc.table_schema = $1 AND tc.constraint_type IN ('PRIMARY KEY', 'UNIQUE')
+		ORDER BY tc.table_name, tc.constraint_name, kcu.ordinal_position`, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("reading key constraints: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var table, name, kind, column string
+		if err := rows.Scan(&table, &name, &kind, &column); err != nil {
+			return err
+		}
+		t, ok := tables[table]
+		if !ok {
+			return nil
+		}
+		if kind == "PRIMARY KEY" {
+			t.PrimaryKey = append(t.PrimaryKey, column)
+			return nil
+		}
+		if n := len(t.Unique); n > 0 && t.Unique[n-1].Name == name {
+			t.Unique[n-1].Columns = append(t.Unique[n-1].Columns, column)
+		} else {
+			t.Unique = append(t.Unique, PostgresConstraint{Name: name, Columns: []string{column}})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading key constraints: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT kcu.table_name, rc.constraint_name, kcu.column_name,
+		       ref.table_name, ref.column_name, rc.delete_rule
+		FROM information_schema.referential_constraints rc
+		JOIN information_schema.key_column_usage kcu
+		  ON kcu.constraint_schema = rc.constraint_schema AND kcu.constraint_name = rc.constraint_name
+		JOIN information_schema.key_column_usage ref
+		  ON ref.constraint_schema = rc.unique_constraint_schema AND ref.constraint_name = rc.unique_constraint_name
+		 AND ref.ordinal_position = kcu.position_in_unique_constraint
+		WHERE rc.constraint_schema = $1
+		ORDER BY kcu.table_name, rc.constraint_name, kcu.ordinal_position`, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("reading foreign keys: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var table, name, column, refTable, refColumn, onDelete string
+		if err := rows.Scan(&table, &name, &column, &refTable, &refColumn, &onDelete); err != nil {
+			return err
+		}
+		t, ok := tables[table]
+		if !ok {
+			return nil
+		}
+		if n := len(t.ForeignKeys); n > 0 && t.ForeignKeys[n-1].Name == name {
+			fk := &t.ForeignKeys[n-1]
+			fk.Columns = append(fk.Columns, column)
+			fk.RefColumns = append(fk.RefColumns, refColumn)
+		} else {
+			t.ForeignKeys = append(t.ForeignKeys, PostgresForeignKey{
+				Name: name, Columns: []string{column}, RefTable: refTable, RefColumns: []string{refColumn}, OnDelete: onDelete,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading foreign keys: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT tc.table_name, cc.constraint_name, cc.check_clause
+		FROM information_schema.check_constraints cc
+		JOIN information_schema.table_constraints tc
+		  ON tc.constraint_schema = cc.constraint_schema AND tc.constraint_name = cc.constraint_name
+		WHERE tc.table_schema = $1 AND tc.constraint_type = 'CHECK'
+		  AND cc.check_clause NOT LIKE '% IS NOT NULL'
+		ORDER BY tc.table_name, cc.constraint_name`, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("reading check constraints: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var table string
+		var check PostgresCheck
+		if err := rows.Scan(&table, &check.Name, &check.Clause); err != nil {
+			return err
+		}
+		if t, ok := tables[table]; ok {
+			t.Checks = append(t.Checks, check)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading check constraints: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT i.tablename, i.indexdef
+		FROM pg_indexes i
+		LEFT JOIN pg_constraint c ON c.conname = i.indexname AND c.connamespace = i.schemaname::regnamespace
+		WHERE i.schemaname = $1 AND c.oid IS NULL
+		ORDER BY i.tablename, i.indexname`, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("reading indexes: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var table, def string
+		if err := rows.Scan(&table, &def); err != nil {
+			return err
+		}
+		if t, ok := tables[table]; ok {
+			t.Indexes = append(t.Indexes, def)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading indexes: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT table_name, view_definition FROM information_schema.views
+		WHERE table_schema = $1 ORDER BY table_name`, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("reading views: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var view PostgresView
+		var def sql.NullString
+		if err := rows.Scan(&view.Name, &def); err != nil {
+			return err
+		}
+		view.Definition = def.String
+		cat.Views = append(cat.Views, view)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading views: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT t.typname, e.enumlabel
+		FROM pg_type t
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = $1
+		ORDER BY t.typname, e.enumsortorder`, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("reading enum types: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var typ, label string
+		if err := rows.Scan(&typ, &label); err != nil {
+			return err
+		}
+		cat.Enums[typ] = append(cat.Enums[typ], label)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading enum types: %w", err)
+	}
+
+	return cat, nil
+}
+
+func scanRows(rows *sql.Rows, scan func() error) error {
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// FromPostgres converts a Postgres catalog into an Alyx schema. Each table
+// becomes a collection; views and check constraints are listed as TODOs.
+func FromPostgres(cat *PostgresCatalog) (*Result, error) {
+	res := &Result{Source: "Postgres", Schema: newSchema()}
+
+	for _, v := range cat.Views {
+		res.todo("view %s was not converted; recreate it as a function or query: %s", v.Name, oneLine(v.Definition))
+	}
+
+	// Resolve every table's column names first so foreign keys can point at
+	// renamed columns in tables converted later.
+	columnNames := make(map[string]map[string]string, len(cat.Tables))
+	for _, t := range cat.Tables {
+		names := make(map[string]string, len(t.Columns))
+		for _, c := range t.Columns {
+			names[c.Name] = identifier(c.Name)
+		}
+		columnNames[t.Name] = names
+	}
+
+	for i := range cat.Tables {
+		col := convertPGTable(res, cat, &cat.Tables[i], columnNames)
+		res.Schema.Collections[col.Name] = col
+	}
+
+	return res, res.validate()
+}
+
+func convertPGTable(res *Result, cat *PostgresCatalog, t *PostgresTable, columnNames map[string]map[string]string) *schema.Collection {
+	name := identifier(t.Name)
+	if name != t.Name {
+		res.todo("collection %s was renamed from table %s", name, t.Name)
+	}
+	names := columnNames[t.Name]
+
+	b := newCollection(name)
+	primary := ""
+	switch len(t.PrimaryKey) {
+	case 0:
+		res.todo("%s: table has no primary key; an id field was added", name)
+	case 1:
+		primary = t.PrimaryKey[0]
+	default:
+		res.todo("%s: composite primary key (%s) became a unique index; an id field was added", name, strings.Join(t.PrimaryKey, ", "))
+	}
+	if primary == "" && names["id"] != "id" {
+		b.add(&schema.Field{Name: "id", Type: schema.FieldTypeUUID, Primary: true, Default: string(schema.DefaultAuto)})
+	}
+
+	for _, c := range t.Columns {
+		field := convertPGColumn(res, cat, name, c)
+		if c.Name == primary {
+			field.Primary = true
+			field.Nullable = false
+			if c.Default != nil && strings.HasPrefix(strings.ToLower(*c.Default), "nextval(") {
+				res.todo("%s.%s is a serial column; Alyx does not assign integer IDs, so supply them on insert or switch to uuid", name, field.Name)
+			}
+		}
+		if primary == "" && field.Name == "id" && !b.has("id") {
+			res.todo("%s.id was made the primary key", name)
+			field.Primary = true
+			field.Nullable = false
+		}
+		if field.Name != c.Name {
+			res.todo("%s.%s was renamed from column %s", name, field.Name, c.Name)
+		}
+		b.add(field)
+	}
+
+	if len(t.PrimaryKey) > 1 {
+		addIndex(b, &parsedIndex{Name: t.Name + "_pkey", Columns: t.PrimaryKey, Unique: true}, names)
+	}
+	for _, u := range t.Unique {
+		if len(u.Columns) == 1 {
+			if f := b.col.Fields[names[u.Columns[0]]]; f != nil && !f.Primary {
+				f.Unique = true
+			}
+			continue
+		}
+		addIndex(b, &parsedIndex{Name: u.Name, Columns: u.Columns, Unique: true}, names)
+	}
+
+	for _, fk := range t.ForeignKeys {
+		if len(fk.Columns) != 1 {
+			res.todo("%s: composite foreign key %s (%s) was not converted", name, fk.Name, strings.Join(fk.Columns, ", "))
+			continue
+		}
+		f := b.col.Fields[names[fk.Columns[0]]]
+		refName, ok := columnNames[fk.RefTable][fk.RefColumns[0]]
+		if f == nil || !ok {
+			res.todo("%s: foreign key %s references %s outside the imported schema", name, fk.Name, fk.RefTable)
+			continue
+		}
+		f.References = identifier(fk.RefTable) + "." + refName
+		switch strings.ToUpper(fk.OnDelete) {
+		case "CASCADE":
+			f.OnDelete = schema.OnDeleteCascade
+		case "SET NULL":
+			f.OnDelete = schema.OnDeleteSetNull
+		case "RESTRICT":
+			f.OnDelete = schema.OnDeleteRestrict
+		case "NO ACTION", "":
+		default:
+			res.todo("%s.%s: ON DELETE %s was not converted", name, f.Name, fk.OnDelete)
+		}
+	}
+
+	for _, def := range t.Indexes {
+		idx, reason := parseIndexSQL(def)
+		if reason == "" && !addIndex(b, idx, names) {
+			reason = "references an unknown column"
+		}
+		if reason != "" {
+			res.todo("%s: index %s: %s", name, oneLine(def), reason)
+		}
+	}
+
+	for _, check := range t.Checks {
+		res.todo("%s: check constraint %s %s was not converted; enforce it with field validation or a rule", name, check.Name, check.Clause)
+	}
+
+	return b.build()
+}
+
+// pgLiteralRegex matches a quoted literal default with an optional cast,
+// such as 'draft'::character varying.
+var pgLiteralRegex = regexp.MustCompile(`^'((?:[^']|'')*)'(?:::[\w\s"]+)?$`)
+
+func convertPGColumn(res *Result, cat *PostgresCatalog, collection string, c PostgresColumn) *schema.Field {
+	field := &schema.Field{Name: identifier(c.Name), Nullable: c.Nullable}
+	path := collection + "." + field.Name
+
+	switch strings.ToLower(c.DataType) {
+	case "uuid":
+		field.Type = schema.FieldTypeUUID
+	case "character varying", "character":
+		field.Type = schema.FieldTypeString
+		if c.MaxLength != nil {
+			field.MaxLength = intPtr(*c.MaxLength)
+		}
+	case "text", "citext":
+		field.Type = schema.FieldTypeText
+	case "smallint", "integer", "bigint":
+		field.Type = schema.FieldTypeInt
+	case "real", "double precision", "numeric", "decimal", "money":
+		field.Type = schema.FieldTypeFloat
+	case "boolean":
+		field.Type = schema.FieldTypeBool
+	case "timestamp with time zone", "timestamp without time zone":
+		field.Type = schema.FieldTypeTimestamp
+	case "date":
+		field.Type = schema.FieldTypeDate
+	case "json", "jsonb":
+		field.Type = schema.FieldTypeJSON
+	case "bytea":
+		field.Type = schema.FieldTypeBlob
+	case "array":
+		field.Type = schema.FieldTypeJSON
+		res.todo("%s is a %s array; it was converted to json", path, strings.TrimPrefix(c.UDTName, "_"))
+	case "user-defined":
+		if values, ok := cat.Enums[c.UDTName]; ok {
+			field.Type = schema.FieldTypeSelect
+			field.Select = &schema.SelectConfig{Values: values, MaxSelect: 1}
+			break
+		}
+		field.Type = schema.FieldTypeString
+		res.todo("%s has type %s, which has no Alyx equivalent; it was converted to string", path, c.UDTName)
+	default:
+		field.Type = schema.FieldTypeString
+		res.todo("%s has type %s, which has no Alyx equivalent; it was converted to string", path, c.DataType)
+	}
+
+	if c.Default != nil {
+		convertPGDefault(res, path, field, *c.Default)
+	}
+	return field
+}
+
+func convertPGDefault(res *Result, path string, field *schema.Field, def string) {
+	lower := strings.ToLower(strings.TrimSpace(def))
+	switch {
+	case strings.HasPrefix(lower, "nextval("):
+		// Serial columns; SQLite assigns integer primary keys itself.
+		return
+	case lower == "null" || strings.HasPrefix(lower, "null::"):
+		return
+	case lower == "gen_random_uuid()" || lower == "uuid_generate_v4()":
+		if field.Type == schema.FieldTypeUUID {
+			field.Default = string(schema.DefaultAuto)
+			return
+		}
+	case lower == "now()" || lower == "current_timestamp" || lower == "current_date" ||
+		strings.HasPrefix(lower, "timezone(") || strings.HasPrefix(lower, "clock_timestamp("):
+		if field.Type == schema.FieldTypeTimestamp || field.Type == schema.FieldTypeDate {
+			field.Default = string(schema.DefaultNow)
+			return
+		}
+	case lower == "true" || lower == "false":
+		if field.Type == schema.FieldTypeBool {
+			field.Default = lower
+			return
+		}
+	}
+
+	if m := pgLiteralRegex.FindStringSubmatch(def); m != nil {
+		field.Default = strings.ReplaceAll(m[1], "''", "'")
+		return
+	}
+	if _, err := strconv.ParseFloat(strings.Trim(def, "()"), 64); err == nil &&
+		(field.Type == schema.FieldTypeInt || field.Type == schema.FieldTypeFloat) {
+		field.Default = strings.Trim(def, "()")
+		return
+	}
+	res.todo("%s: default %s was not converted", path, def)
+}
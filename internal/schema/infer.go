@@ -15,6 +15,19 @@ type columnInfo struct {
 	HasDefault bool
 }
 
+// InferFromDB reads the current schema from the database. Table structure
+// (columns, nullability, primary and foreign keys, unique constraints and
+// indexes) comes from SQLite; everything SQLite cannot store, such as field
+// types narrower than a column affinity, defaults, validation, select,
+// richtext, relation and file configs, rules, retention, history options,
+// checks, expose and docs, comes from the configuration cached in _alyx_schema_cache when
+// the schema was applied. Views come from _alyx_views. History tables are
+// not collections; a collection has history when its history table exists.
+//
+// Without a cached configuration for a collection, field types fall back to
+// the column affinity and config blocks are empty. The differ treats types
+// sharing an affinity as compatible and does not compare config blocks, so
+// neither shows up as a change.
 func InferFromDB(db *sql.DB) (*Schema, error) {
 	tables, err := getUserTables(db)
 	if err != nil {
@@ -33,7 +46,17 @@ func InferFromDB(db *sql.DB) (*Schema, error) {
 		Collections: make(map[string]*Collection),
 	}
 
+	exists := make(map[string]bool, len(tables))
 	for _, table := range tables {
+		exists[table] = true
+	}
+
+	for _, table := range tables {
+		// History tables belong to their collection.
+		if IsHistoryTable(table) {
+			continue
+		}
+
 		cols, err := getTableColumns(db, table)
 		if err != nil {
 			return nil, fmt.Errorf("getting columns for %s: %w", table, err)
@@ -56,6 +79,25 @@ func InferFromDB(db *sql.DB) (*Schema, error) {
 			return nil, fmt.Errorf("enriching metadata for %s: %w", table, err)
 		}
 
+		cached, err := loadCollectionFromCache(db, table)
+		if err != nil {
+			return nil, fmt.Errorf("loading cached config for %s: %w", table, err)
+		}
+		if cached != nil {
+			mergeCachedCollection(collection, cached, cols)
+		}
+		if err := inferCollations(db, table, collection); err != nil {
+			return nil, fmt.Errorf("reading collations for %s: %w", table, err)
+		}
+
+		// Whether history is enabled follows its table; its options come
+		// from the cache.
+		if !exists[collection.HistoryTable()] {
+			collection.History = nil
+		} else if collection.History == nil {
+			collection.History = &HistoryConfig{}
+		}
+
 		rules, err := loadRulesFromCache(db, table)
 		if err != nil {
 			return nil, fmt.Errorf("loading rules for %s: %w", table, err)
@@ -65,9 +107,42 @@ func InferFromDB(db *sql.DB) (*Schema, error) {
 		schema.Collections[table] = collection
 	}
 
+	schema.Views, err = loadViews(db)
+	if err != nil {
+		return nil, fmt.Errorf("loading views: %w", err)
+	}
+
 	return schema, nil
 }
 
+// loadViews returns the views whose backing tables exist, as recorded in
+// _alyx_views when each table was created.
+func loadViews(db *sql.DB) (map[string]*View, error) {
+	rows, err := db.Query(`SELECT name, definition FROM _alyx_views`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	views := make(map[string]*View)
+	for rows.Next() {
+		var name, definition string
+		if err := rows.Scan(&name, &definition); err != nil {
+			return nil, err
+		}
+		var v View
+		if err := json.Unmarshal([]byte(definition), &v); err != nil {
+			return nil, fmt.Errorf("unmarshaling view %s: %w", name, err)
+		}
+		v.Name = name
+		views[name] = &v
+	}
+	return views, rows.Err()
+}
+
 func loadRulesFromCache(db *sql.DB, collection string) (*Rules, error) {
 	var rulesJSON sql.NullString
 	err := db.QueryRow(`
@@ -89,6 +164,147 @@ func loadRulesFromCache(db *sql.DB, collection string) (*Rules, error) {
 	return &rules, nil
 }
 
+func loadCollectionFromCache(db *sql.DB, collection string) (*Collection, error) {
+	var collectionJSON sql.NullString
+	err := db.QueryRow(`
+		SELECT collection_json FROM _alyx_schema_cache WHERE collection = ?
+	`, collection).Scan(&collectionJSON)
+
+	if err == sql.ErrNoRows || (err == nil && !collectionJSON.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		// Caches from before collection_json existed lack the column.
+		if strings.Contains(err.Error(), "no such column") || strings.Contains(err.Error(), "no such table") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("querying cache: %w", err)
+	}
+
+	var col Collection
+	if err := json.Unmarshal([]byte(collectionJSON.String), &col); err != nil {
+		return nil, fmt.Errorf("unmarshaling collection: %w", err)
+	}
+	return &col, nil
+}
+
+// mergeCachedCollection copies the configuration SQLite does not store from
+// cached into inferred. Structure read from the database wins: a cached field
+// is only used if its column still exists with the same affinity.
+func mergeCachedCollection(inferred, cached *Collection, cols []columnInfo) {
+	declared := make(map[string]string, len(cols))
+	for _, col := range cols {
+		declared[col.Name] = strings.ToUpper(col.Type)
+	}
+
+	for name, field := range inferred.Fields {
+		cachedField, ok := cached.Fields[name]
+		if !ok || cachedField.Type.SQLiteType() != declared[name] {
+			continue
+		}
+		merged := *cachedField
+		merged.Name = name
+		merged.Primary = field.Primary
+		merged.Nullable = field.Nullable
+		merged.Unique = field.Unique
+		merged.References = field.References
+		// Unique and primary columns get no separate index, so the database
+		// cannot say whether index was set on them.
+		merged.Index = field.Index || (cachedField.Index && (field.Unique || field.Primary))
+		inferred.Fields[name] = &merged
+	}
+
+	cachedIndexes := make(map[string]*Index, len(cached.Indexes))
+	for _, idx := range cached.Indexes {
+		cachedIndexes[idx.Name] = idx
+	}
+	for _, idx := range inferred.Indexes {
+		// index_info does not report sort order.
+		if c, ok := cachedIndexes[idx.Name]; ok && c.Unique == idx.Unique && strings.Join(c.Fields, ",") == strings.Join(idx.Fields, ",") {
+			idx.Order = c.Order
+		}
+	}
+
+	inferred.Retention = cached.Retention
+	inferred.Docs = cached.Docs
+	inferred.Tenant = cached.Tenant
+	inferred.History = cached.History
+	inferred.Checks = cached.Checks
+	inferred.Counters = cached.Counters
+	inferred.Expose = cached.Expose
+}
+
+// inferCollations sets each field's collation from the column definitions
+// in the table's CREATE TABLE statement, which SQLite keeps as written and
+// updates on ALTER TABLE.
+func inferCollations(db *sql.DB, table string, collection *Collection) error {
+	var createSQL string
+	if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&createSQL); err != nil {
+		return err
+	}
+
+	nocase := make(map[string]bool)
+	for _, def := range columnDefinitions(createSQL) {
+		name, rest, _ := strings.Cut(strings.TrimSpace(def), " ")
+		name = strings.Trim(name, "\"`[]")
+		nocase[name] = strings.Contains(strings.ToUpper(rest), "COLLATE NOCASE")
+	}
+
+	for name, field := range collection.Fields {
+		if nocase[name] {
+			field.Collate = CollationNocase
+		} else {
+			field.Collate = ""
+		}
+	}
+	return nil
+}
+
+// columnDefinitions splits the body of a CREATE TABLE statement on its
+// top-level commas, skipping table constraints.
+func columnDefinitions(createSQL string) []string {
+	start := strings.Index(createSQL, "(")
+	end := strings.LastIndex(createSQL, ")")
+	if start < 0 || end <= start {
+		return nil
+	}
+	body := createSQL[start+1 : end]
+
+	var defs []string
+	depth, last := 0, 0
+	var quote byte
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			defs = append(defs, body[last:i])
+			last = i + 1
+		}
+	}
+	defs = append(defs, body[last:])
+
+	columns := defs[:0]
+	for _, def := range defs {
+		keyword, _, _ := strings.Cut(strings.ToUpper(strings.TrimSpace(def)), " ")
+		switch keyword {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+		columns = append(columns, def)
+	}
+	return columns
+}
+
 func getUserTables(db *sql.DB) ([]string, error) {
 	systemTables := map[string]bool{
 		"events":            true,
@@ -155,10 +371,11 @@ func getTableColumns(db *sql.DB, table string) ([]columnInfo, error) {
 
 func columnToField(col columnInfo) *Field {
 	field := &Field{
-		Name:     col.Name,
-		Type:     sqliteTypeToFieldType(col.Type),
-		Primary:  col.PK,
-		Nullable: !col.NotNull && !col.PK,
+		Name:         col.Name,
+		Type:         sqliteTypeToFieldType(col.Type),
+		Primary:      col.PK,
+		Nullable:     !col.NotNull && !col.PK,
+		unconfigured: true,
 	}
 	return field
 }
@@ -249,10 +466,11 @@ func enrichFieldMetadata(db *sql.DB, table string, collection *Collection) error
 		}
 
 		// Handle SQLite internal autoindexes (for UNIQUE/PK constraints)
-		// These should mark field.Unique but not be added to collection.Indexes
+		// These should mark field.Unique but not be added to collection.Indexes.
+		// Primary keys are unique implicitly and are not marked.
 		if strings.HasPrefix(idx.name, "sqlite_autoindex_") {
 			if idx.unique && len(fieldNames) == 1 {
-				if field, exists := collection.Fields[fieldNames[0]]; exists {
+				if field, exists := collection.Fields[fieldNames[0]]; exists && !field.Primary {
 					field.Unique = true
 				}
 			}
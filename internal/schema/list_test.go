@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	valid := `
+version: 1
+collections:
+  posts:
+    list: { defaultSort: "status,-created_at", defaultLimit: 20, maxLimit: 50 }
+    fields:
+      id: { type: id, primary: true, default: auto }
+      status: { type: string }
+      created_at: { type: timestamp, default: now }
+`
+	s, err := Parse([]byte(valid))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	list := s.Collections["posts"].List
+	if list == nil || list.DefaultSort != "status,-created_at" || list.DefaultLimit != 20 || list.MaxLimit != 50 {
+		t.Fatalf("list = %+v", list)
+	}
+	if got := strings.Join(list.SortFields(), ","); got != "status,created_at" {
+		t.Errorf("SortFields() = %s", got)
+	}
+
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("parse marshaled schema: %v\n%s", err, data)
+	}
+	if got := reparsed.Collections["posts"].List; got == nil || *got != *list {
+		t.Errorf("list after round trip = %+v, want %+v", got, list)
+	}
+
+	tests := []struct {
+		name string
+		list string
+		want string
+	}{
+		{"missing sort field", `{ defaultSort: "-published_at" }`, `field "published_at" does not exist`},
+		{"empty sort field", `{ defaultSort: "status,,-created_at" }`, "empty sort field"},
+		{"nulls modifier", `{ defaultSort: "status:nullslast,-created_at" }`, ""},
+		{"unknown modifier", `{ defaultSort: "status:nullsmiddle" }`, `unknown sort modifier "nullsmiddle"`},
+		{"unsortable field", `{ defaultSort: "meta" }`, `json field "meta" cannot be sorted`},
+		{"max over global cap", "{ maxLimit: 5000 }", "maxLimit: must be between 1 and 1000"},
+		{"negative default", "{ defaultLimit: -1 }", "defaultLimit: must be positive"},
+		{"default over max", "{ defaultLimit: 100, maxLimit: 50 }", "must not exceed maxLimit (50)"},
+		{"default only", "{ defaultLimit: 10 }", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml := `
+version: 1
+collections:
+  posts:
+    list: ` + tt.list + `
+    fields:
+      id: { type: id, primary: true, default: auto }
+      status: { type: string }
+      meta: { type: json, nullable: true }
+      created_at: { type: timestamp, default: now }
+`
+			_, err := Parse([]byte(yaml))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestListConfigLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		list        *ListConfig
+		wantDefault int
+		wantMax     int
+	}{
+		{"unset", nil, DefaultListLimit, MaxListLimit},
+		{"max below global default", &ListConfig{MaxLimit: 50}, 50, 50},
+		{"default and max", &ListConfig{DefaultLimit: 20, MaxLimit: 50}, 20, 50},
+		{"default only", &ListConfig{DefaultLimit: 10}, 10, MaxListLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, max := tt.list.Limits()
+			if def != tt.wantDefault || max != tt.wantMax {
+				t.Errorf("Limits() = %d, %d, want %d, %d", def, max, tt.wantDefault, tt.wantMax)
+			}
+		})
+	}
+}
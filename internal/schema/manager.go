@@ -14,14 +14,14 @@ type Manager struct {
 	onChange func(*Schema)
 }
 
-// NewManager creates a new schema manager for the given file path.
+// NewManager creates a new schema manager for the given file or directory path.
 func NewManager(path string) *Manager {
 	return &Manager{
 		path: path,
 	}
 }
 
-// Load reads and parses the schema from the configured file path.
+// Load reads and parses the schema from the configured path.
 func (m *Manager) Load() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -76,7 +76,18 @@ func (m *Manager) GetSchema() *Schema {
 			Fields:     make(map[string]*Field, len(col.Fields)),
 			Indexes:    make([]*Index, len(col.Indexes)),
 			Rules:      col.Rules,
+			Retention:  col.Retention,
+			Docs:       col.Docs,
+			Tenant:     col.Tenant,
+			History:    col.History,
+			Checks:     col.Checks,
+			List:       col.List,
+			Counters:   col.Counters,
+			Expose:     col.Expose,
+			Use:        append([]string(nil), col.Use...),
 			fieldOrder: make([]string, len(col.fieldOrder)),
+			// Preset fields are never modified, so they can be shared.
+			presetFields: col.presetFields,
 		}
 		for fname, field := range col.Fields {
 			fieldCopy := *field
@@ -126,6 +137,25 @@ func (m *Manager) GetSchema() *Schema {
 		schemaCopy.Functions[name] = &fnCopy
 	}
 
+	if m.schema.Views != nil {
+		schemaCopy.Views = make(map[string]*View, len(m.schema.Views))
+		for name, v := range m.schema.Views {
+			viewCopy := *v
+			if v.Rules != nil {
+				rules := *v.Rules
+				viewCopy.Rules = &rules
+			}
+			schemaCopy.Views[name] = &viewCopy
+		}
+	}
+
+	if m.schema.Presets != nil {
+		schemaCopy.Presets = make(map[string]*FieldPreset, len(m.schema.Presets))
+		for name, preset := range m.schema.Presets {
+			schemaCopy.Presets[name] = preset
+		}
+	}
+
 	return schemaCopy
 }
 
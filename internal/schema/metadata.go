@@ -0,0 +1,304 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Metadata value types.
+const (
+	MetadataTypeObject  = "object"
+	MetadataTypeString  = "string"
+	MetadataTypeInteger = "integer"
+	MetadataTypeNumber  = "number"
+	MetadataTypeBoolean = "boolean"
+	MetadataTypeArray   = "array"
+)
+
+// MetadataSchema declares the shape of user metadata, using a subset of JSON
+// Schema. It is set with the top-level userMetadata key:
+//
+//	userMetadata:
+//	  additionalProperties: false
+//	  required: [display_name]
+//	  properties:
+//	    display_name: { type: string, maxLength: 64 }
+//	    theme: { type: string, enum: [light, dark] }
+//
+// The same syntax declares function input and output.
+type MetadataSchema struct {
+	Type        string `yaml:"type,omitempty" json:"type,omitempty"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+
+	// Object keywords.
+	Properties map[string]*MetadataSchema `yaml:"properties,omitempty" json:"properties,omitempty"`
+	Required   []string                   `yaml:"required,omitempty" json:"required,omitempty"`
+	// AdditionalProperties set to false rejects keys not in Properties.
+	AdditionalProperties *bool `yaml:"additionalProperties,omitempty" json:"additionalProperties,omitempty"`
+
+	// Array keywords.
+	Items *MetadataSchema `yaml:"items,omitempty" json:"items,omitempty"`
+
+	Enum      []any    `yaml:"enum,omitempty" json:"enum,omitempty"`
+	MinLength *int     `yaml:"minLength,omitempty" json:"minLength,omitempty"`
+	MaxLength *int     `yaml:"maxLength,omitempty" json:"maxLength,omitempty"`
+	Minimum   *float64 `yaml:"minimum,omitempty" json:"minimum,omitempty"`
+	Maximum   *float64 `yaml:"maximum,omitempty" json:"maximum,omitempty"`
+}
+
+// ValueType returns the declared type, treating a schema with properties and
+// no type as an object.
+func (m *MetadataSchema) ValueType() string {
+	if m.Type == "" && (m.Properties != nil || m.AdditionalProperties != nil) {
+		return MetadataTypeObject
+	}
+	return m.Type
+}
+
+// AllowsAdditional reports whether keys not declared in Properties are accepted.
+func (m *MetadataSchema) AllowsAdditional() bool {
+	return m.AdditionalProperties == nil || *m.AdditionalProperties
+}
+
+// SortedProperties returns the property names in alphabetical order.
+func (m *MetadataSchema) SortedProperties() []string {
+	names := make([]string, 0, len(m.Properties))
+	for name := range m.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsRequired reports whether name is listed in Required.
+func (m *MetadataSchema) IsRequired(name string) bool {
+	for _, r := range m.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks a metadata object, decoded from JSON, against the schema.
+// Errors are reported with paths such as "metadata.address.city".
+func (m *MetadataSchema) Validate(metadata map[string]any) ValidationErrors {
+	var errs ValidationErrors
+	m.validateObject("metadata", "metadata", metadata, &errs)
+	return errs
+}
+
+// ValidateInput checks a function input payload, decoded from JSON, against
+// the schema. Errors are reported with paths such as "input.user_id".
+func (m *MetadataSchema) ValidateInput(input map[string]any) ValidationErrors {
+	var errs ValidationErrors
+	m.validateObject("input", "input", input, &errs)
+	return errs
+}
+
+// validateValue checks value at path. kind names what is being validated in
+// errors about undeclared keys.
+func (m *MetadataSchema) validateValue(path, kind string, value any, errs *ValidationErrors) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		fail("must not be null")
+		return
+	}
+
+	switch m.ValueType() {
+	case MetadataTypeObject:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		m.validateObject(path, kind, obj, errs)
+		return
+
+	case MetadataTypeArray:
+		items, ok := value.([]any)
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if m.Items != nil {
+			for i, item := range items {
+				m.Items.validateValue(fmt.Sprintf("%s[%d]", path, i), kind, item, errs)
+			}
+		}
+
+	case MetadataTypeString:
+		s, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		n := utf8.RuneCountInString(s)
+		if m.MinLength != nil && n < *m.MinLength {
+			fail("must be at least %d characters", *m.MinLength)
+		}
+		if m.MaxLength != nil && n > *m.MaxLength {
+			fail("must be at most %d characters", *m.MaxLength)
+		}
+
+	case MetadataTypeInteger, MetadataTypeNumber:
+		n, ok := value.(float64)
+		if !ok {
+			fail("must be a number")
+			return
+		}
+		if m.ValueType() == MetadataTypeInteger && n != math.Trunc(n) {
+			fail("must be an integer")
+		}
+		if m.Minimum != nil && n < *m.Minimum {
+			fail("must be at least %v", *m.Minimum)
+		}
+		if m.Maximum != nil && n > *m.Maximum {
+			fail("must be at most %v", *m.Maximum)
+		}
+
+	case MetadataTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+			return
+		}
+	}
+
+	if len(m.Enum) > 0 && !enumContains(m.Enum, value) {
+		fail("must be one of: %s", formatEnum(m.Enum))
+	}
+}
+
+func (m *MetadataSchema) validateObject(path, kind string, obj map[string]any, errs *ValidationErrors) {
+	for _, name := range m.Required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, &ValidationError{Path: path + "." + name, Message: "is required"})
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		prop, ok := m.Properties[key]
+		if !ok {
+			if !m.AllowsAdditional() {
+				*errs = append(*errs, &ValidationError{Path: path + "." + key, Message: "is not a declared " + kind + " key"})
+			}
+			continue
+		}
+		prop.validateValue(path+"."+key, kind, obj[key], errs)
+	}
+}
+
+func enumContains(enum []any, value any) bool {
+	for _, e := range enum {
+		// YAML decodes integers as int, JSON as float64.
+		if n, ok := toFloat(e); ok {
+			if v, ok := value.(float64); ok && v == n {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func formatEnum(enum []any) string {
+	parts := make([]string, len(enum))
+	for i, e := range enum {
+		parts[i] = fmt.Sprint(e)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// validateMetadataSchema checks a userMetadata or function input/output
+// declaration. A root declaration must be an object.
+func validateMetadataSchema(path string, m *MetadataSchema, root bool) ValidationErrors {
+	var errs ValidationErrors
+	fail := func(p, format string, args ...any) {
+		errs = append(errs, &ValidationError{Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+
+	typ := m.ValueType()
+	switch typ {
+	case MetadataTypeObject, MetadataTypeString, MetadataTypeInteger, MetadataTypeNumber, MetadataTypeBoolean, MetadataTypeArray:
+	case "":
+		if root {
+			typ = MetadataTypeObject
+		} else {
+			fail(path+".type", "is required")
+		}
+	default:
+		fail(path+".type", "unknown type %q: must be object, string, integer, number, boolean or array", m.Type)
+	}
+
+	if root && typ != MetadataTypeObject {
+		fail(path+".type", "must be object")
+	}
+
+	if typ != MetadataTypeObject && (len(m.Properties) > 0 || len(m.Required) > 0 || m.AdditionalProperties != nil) {
+		fail(path, "properties, required and additionalProperties only apply to objects")
+	}
+	for _, name := range m.Required {
+		if _, ok := m.Properties[name]; !ok && !m.AllowsAdditional() {
+			fail(path+".required", "%q is not a declared property", name)
+		}
+	}
+	for _, name := range m.SortedProperties() {
+		prop := m.Properties[name]
+		if prop == nil {
+			fail(path+".properties."+name, "must declare a type")
+			continue
+		}
+		errs = append(errs, validateMetadataSchema(path+".properties."+name, prop, false)...)
+	}
+
+	if m.Items != nil {
+		if typ != MetadataTypeArray {
+			fail(path+".items", "only applies to arrays")
+		} else {
+			errs = append(errs, validateMetadataSchema(path+".items", m.Items, false)...)
+		}
+	}
+
+	if (m.MinLength != nil || m.MaxLength != nil) && typ != MetadataTypeString {
+		fail(path, "minLength and maxLength only apply to strings")
+	}
+	if m.MinLength != nil && m.MaxLength != nil && *m.MinLength > *m.MaxLength {
+		fail(path, "minLength must not exceed maxLength")
+	}
+	if (m.Minimum != nil || m.Maximum != nil) && typ != MetadataTypeInteger && typ != MetadataTypeNumber {
+		fail(path, "minimum and maximum only apply to numbers")
+	}
+	if m.Minimum != nil && m.Maximum != nil && *m.Minimum > *m.Maximum {
+		fail(path, "minimum must not exceed maximum")
+	}
+
+	return errs
+}
@@ -74,10 +74,31 @@ func (m *Migrator) Init() error {
 		CREATE TABLE IF NOT EXISTS _alyx_schema_cache (
 			collection TEXT PRIMARY KEY,
 			rules_json TEXT,
+			collection_json TEXT,
 			updated_at TEXT NOT NULL DEFAULT (datetime('now'))
 		)
 	`)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Caches created before collection_json was added only hold rules, and
+	// those created before schema_yaml have no snapshot of the whole schema.
+	for _, column := range []string{"collection_json", "schema_yaml"} {
+		var exists int
+		if err := m.db.QueryRow(`
+			SELECT COUNT(*) FROM pragma_table_info('_alyx_schema_cache') WHERE name = ?
+		`, column).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			//nolint:gosec // column is one of the names above
+			if _, err := m.db.Exec(`ALTER TABLE _alyx_schema_cache ADD COLUMN ` + column + ` TEXT`); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (m *Migrator) AppliedMigrations() ([]*AppliedMigration, error) {
@@ -204,9 +225,6 @@ func (m *Migrator) Apply(mig *Migration) error {
 		if _, execErr := tx.Exec(sql); execErr != nil {
 			return fmt.Errorf("executing operation %d: %w", i, execErr)
 		}
-		if _, err := tx.Exec(sql); err != nil {
-			return fmt.Errorf("executing operation %d: %w", i, err)
-		}
 	}
 
 	_, insertErr := tx.Exec(`
@@ -220,6 +238,47 @@ func (m *Migrator) Apply(mig *Migration) error {
 	return tx.Commit()
 }
 
+// Revert undoes an applied migration: it runs the down SQL of its operations
+// in reverse order and removes its record, in one transaction.
+func (m *Migrator) Revert(mig *Migration) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for i := len(mig.Operations) - 1; i >= 0; i-- {
+		sql, sqlErr := m.operationDownSQL(mig.Operations[i])
+		if sqlErr != nil {
+			return fmt.Errorf("operation %d: %w", i, sqlErr)
+		}
+		if _, execErr := tx.Exec(sql); execErr != nil {
+			return fmt.Errorf("reverting operation %d: %w", i, execErr)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM _alyx_migrations WHERE version = ?`, strconv.Itoa(mig.Version)); err != nil {
+		return fmt.Errorf("removing migration record: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) operationDownSQL(op *MigrationOp) (string, error) {
+	switch op.Type {
+	case "sql":
+		if op.Down == "" {
+			return "", fmt.Errorf("sql operation has no down SQL")
+		}
+		return op.Down, nil
+	case "rename_field":
+		return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s",
+			op.Collection, op.To, op.From), nil
+	default:
+		return "", fmt.Errorf("cannot revert operation type: %s", op.Type)
+	}
+}
+
 func (m *Migrator) operationToSQL(op *MigrationOp) (string, error) {
 	switch op.Type {
 	case "sql":
@@ -252,10 +311,17 @@ func (m *Migrator) ApplySchema(schema *Schema) error {
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return m.saveSchemaToCache(schema)
 }
 
 func (m *Migrator) ApplySafeChanges(changes []*Change, schema *Schema) error {
+	if errs := m.ValidateRoleChanges(changes); len(errs) > 0 {
+		return fmt.Errorf("%s: %s", errs[0].Path, errs[0].Message)
+	}
+
 	tx, err := m.db.Begin()
 	if err != nil {
 		return fmt.Errorf("beginning transaction: %w", err)
@@ -267,7 +333,7 @@ func (m *Migrator) ApplySafeChanges(changes []*Change, schema *Schema) error {
 			continue
 		}
 
-		stmts, err := m.changeToSQL(change)
+		stmts, err := m.changeToSQL(change, schema)
 		if err != nil {
 			return fmt.Errorf("generating SQL for %s: %w", change, err)
 		}
@@ -283,18 +349,10 @@ func (m *Migrator) ApplySafeChanges(changes []*Change, schema *Schema) error {
 		return err
 	}
 
-	if schema != nil {
-		for name, collection := range schema.Collections {
-			if err := m.SaveRulesToCache(name, collection.Rules); err != nil {
-				return fmt.Errorf("saving rules for %s: %w", name, err)
-			}
-		}
-	}
-
-	return nil
+	return m.saveSchemaToCache(schema)
 }
 
-func (m *Migrator) changeToSQL(change *Change) ([]string, error) {
+func (m *Migrator) changeToSQL(change *Change, schema *Schema) ([]string, error) {
 	switch change.Type {
 	case ChangeAddCollection:
 		return nil, fmt.Errorf("add collection requires full schema regeneration")
@@ -318,9 +376,72 @@ func (m *Migrator) changeToSQL(change *Change) ([]string, error) {
 	case ChangeDropIndex:
 		return []string{fmt.Sprintf("DROP INDEX IF EXISTS %s", change.Index.Name)}, nil
 
-	case ChangeModifyRules:
+	case ChangeModifyRules, ChangeModifyRoles, ChangeModifyUserMetadata:
 		return nil, nil
 
+	case ChangeAddHistory:
+		return NewSQLGenerator(nil).GenerateHistoryTable(&Collection{Name: change.Collection}), nil
+
+	case ChangeAddView:
+		return []string{NewSQLGenerator(nil).GenerateCreateView(change.NewView)}, nil
+
+	case ChangeDropView:
+		return []string{fmt.Sprintf("DROP TABLE IF EXISTS %s", change.OldView.Table())}, nil
+
+	case ChangeModifyView:
+		if change.OldView.Query == change.NewView.Query {
+			return nil, nil
+		}
+		return []string{
+			fmt.Sprintf("DROP TABLE IF EXISTS %s", change.OldView.Table()),
+			NewSQLGenerator(nil).GenerateCreateView(change.NewView),
+		}, nil
+
+	case ChangeModifyDefault:
+		// SQLite cannot alter a column's DEFAULT without rebuilding the
+		// table, and defaults are applied on insert, so the new default
+		// takes effect through the schema cache alone.
+		if !change.Backfill {
+			return nil, nil
+		}
+		def := change.NewField.SQLDefault()
+		if def == "" {
+			return nil, fmt.Errorf("cannot backfill field %q: default %q has no SQL value", change.Field, change.NewField.Default)
+		}
+		return []string{
+			fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL", change.Collection, change.Field, def, change.Field),
+		}, nil
+
+	case ChangeModifyOnUpdate:
+		col, ok := schema.Collections[change.Collection]
+		if !ok {
+			return nil, fmt.Errorf("collection %q not found", change.Collection)
+		}
+		// The other triggers already exist, so only the timestamp trigger
+		// is recreated, and only if some field still needs it.
+		gen := NewSQLGenerator(schema)
+		stmts := []string{gen.GenerateDropTrigger(col.Name + "_auto_update_timestamp")}
+		return append(stmts, gen.GenerateTriggers(col)...), nil
+
+	case ChangeAddCounter, ChangeModifyCounter:
+		col, ok := schema.Collections[change.Collection]
+		if !ok {
+			return nil, fmt.Errorf("collection %q not found", change.Collection)
+		}
+		var stmts []string
+		if change.OldCounter != nil {
+			stmts = change.OldCounter.DropTriggersSQL(change.Collection)
+		}
+		gen := NewSQLGenerator(schema)
+		stmts = append(stmts, gen.GenerateCounterTriggers(col, change.NewCounter)...)
+		if change.Backfill {
+			stmts = append(stmts, gen.GenerateCounterRecount(col, change.NewCounter))
+		}
+		return stmts, nil
+
+	case ChangeDropCounter:
+		return change.OldCounter.DropTriggersSQL(change.Collection), nil
+
 	default:
 		return nil, fmt.Errorf("change type %s requires manual migration", change.Type)
 	}
@@ -338,12 +459,25 @@ func (m *Migrator) ApplyUnsafeChanges(changes []*Change, schema *Schema) error {
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	// Counter triggers name columns and tables of other collections, which
+	// SQLite refuses to rename or drop while they exist, so they are
+	// recreated around the changes.
+	counterStmts, err := m.dropCounterTriggersSQLWithTx(tx)
+	if err != nil {
+		return fmt.Errorf("listing counter triggers: %w", err)
+	}
+	for _, stmt := range counterStmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("executing %q: %w", truncate(stmt, 100), err)
+		}
+	}
+
 	for _, change := range changes {
 		if change.Safe {
 			continue
 		}
 
-		stmts, err := m.unsafeChangeToSQLWithTx(tx, change)
+		stmts, err := m.unsafeChangeToSQLWithTx(tx, change, schema)
 		if err != nil {
 			return fmt.Errorf("generating SQL for %s: %w", change, err)
 		}
@@ -355,6 +489,20 @@ func (m *Migrator) ApplyUnsafeChanges(changes []*Change, schema *Schema) error {
 		}
 	}
 
+	if schema != nil {
+		gen := NewSQLGenerator(schema)
+		for _, name := range sortedNames(schema.Collections) {
+			col := schema.Collections[name]
+			for _, counter := range col.Counters {
+				for _, stmt := range gen.GenerateCounterTriggers(col, counter) {
+					if _, err := tx.Exec(stmt); err != nil {
+						return fmt.Errorf("executing %q: %w", truncate(stmt, 100), err)
+					}
+				}
+			}
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("committing transaction: %w", err)
 	}
@@ -363,15 +511,7 @@ func (m *Migrator) ApplyUnsafeChanges(changes []*Change, schema *Schema) error {
 		return fmt.Errorf("foreign key check failed after migration: %w", err)
 	}
 
-	if schema != nil {
-		for name, collection := range schema.Collections {
-			if err := m.SaveRulesToCache(name, collection.Rules); err != nil {
-				return fmt.Errorf("saving rules for %s: %w", name, err)
-			}
-		}
-	}
-
-	return nil
+	return m.saveSchemaToCache(schema)
 }
 
 func (m *Migrator) unsafeChangeToSQL(change *Change) ([]string, error) {
@@ -379,6 +519,12 @@ func (m *Migrator) unsafeChangeToSQL(change *Change) ([]string, error) {
 	case ChangeDropCollection:
 		return []string{
 			fmt.Sprintf("DROP TABLE IF EXISTS %s", change.Collection),
+			fmt.Sprintf("DROP TABLE IF EXISTS %s%s", change.Collection, HistoryTableSuffix),
+		}, nil
+
+	case ChangeDropHistory:
+		return []string{
+			fmt.Sprintf("DROP TABLE IF EXISTS %s%s", change.Collection, HistoryTableSuffix),
 		}, nil
 
 	case ChangeDropField:
@@ -392,18 +538,24 @@ func (m *Migrator) unsafeChangeToSQL(change *Change) ([]string, error) {
 	}
 }
 
-func (m *Migrator) unsafeChangeToSQLWithTx(tx *sql.Tx, change *Change) ([]string, error) {
+func (m *Migrator) unsafeChangeToSQLWithTx(tx *sql.Tx, change *Change, schema *Schema) ([]string, error) {
 	switch change.Type {
 	case ChangeDropCollection:
 		return []string{
 			fmt.Sprintf("DROP TABLE IF EXISTS %s", change.Collection),
+			fmt.Sprintf("DROP TABLE IF EXISTS %s%s", change.Collection, HistoryTableSuffix),
+		}, nil
+
+	case ChangeDropHistory:
+		return []string{
+			fmt.Sprintf("DROP TABLE IF EXISTS %s%s", change.Collection, HistoryTableSuffix),
 		}, nil
 
 	case ChangeDropField:
 		return m.dropColumnSQLWithTx(tx, change.Collection, change.OldField.Name)
 
 	case ChangeModifyField:
-		return m.modifyFieldSQLWithTx(tx, change)
+		return m.modifyFieldSQLWithTx(tx, change, schema)
 
 	default:
 		return nil, fmt.Errorf("unsupported unsafe change type: %s", change.Type)
@@ -436,6 +588,28 @@ func (m *Migrator) dropColumnSQLWithTx(tx *sql.Tx, table, column string) ([]stri
 	return stmts, nil
 }
 
+// dropCounterTriggersSQLWithTx returns the statements that drop every
+// counter trigger in the database.
+func (m *Migrator) dropCounterTriggersSQLWithTx(tx *sql.Tx) ([]string, error) {
+	rows, err := tx.Query(`SELECT name FROM sqlite_master WHERE type = 'trigger'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stmts []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if IsCounterTrigger(name) {
+			stmts = append(stmts, fmt.Sprintf("DROP TRIGGER IF EXISTS %s", name))
+		}
+	}
+	return stmts, rows.Err()
+}
+
 func (m *Migrator) dropTriggersSQL(table string) []string {
 	return []string{
 		fmt.Sprintf("DROP TRIGGER IF EXISTS %s_after_insert", table),
@@ -582,10 +756,70 @@ func (m *Migrator) modifyFieldSQL(change *Change) ([]string, error) {
 	return nil, fmt.Errorf("unsupported field modification")
 }
 
-func (m *Migrator) modifyFieldSQLWithTx(_ *sql.Tx, change *Change) ([]string, error) {
+func (m *Migrator) modifyFieldSQLWithTx(tx *sql.Tx, change *Change, schema *Schema) ([]string, error) {
+	if change.OldField.CaseInsensitive() != change.NewField.CaseInsensitive() {
+		return m.rebuildTableSQLWithTx(tx, change.Collection, schema)
+	}
 	return m.modifyFieldSQL(change)
 }
 
+// rebuildTableSQLWithTx recreates table from its definition in schema and
+// copies over the columns it shares with the live table. A column's
+// collation can only be changed this way: SQLite cannot alter a column, and
+// cannot drop one that is unique or indexed.
+func (m *Migrator) rebuildTableSQLWithTx(tx *sql.Tx, table string, schema *Schema) ([]string, error) {
+	if err := ValidateIdentifier(table); err != nil {
+		return nil, err
+	}
+	var col *Collection
+	if schema != nil {
+		col = schema.Collections[table]
+	}
+	if col == nil {
+		return nil, fmt.Errorf("rebuilding %s requires its definition in the schema", table)
+	}
+
+	rows, err := tx.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return nil, fmt.Errorf("querying columns: %w", err)
+	}
+	live := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning column name: %w", err)
+		}
+		live[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var columns []string
+	for _, f := range col.OrderedFields() {
+		if live[f.Name] {
+			columns = append(columns, f.Name)
+		}
+	}
+	copied := strings.Join(columns, ", ")
+
+	rebuilt := *col
+	rebuilt.Name = "_alyx_rebuild_" + table
+	gen := NewSQLGenerator(schema)
+
+	stmts := m.dropTriggersSQL(table)
+	stmts = append(stmts,
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", rebuilt.Name),
+		gen.GenerateCreateTable(&rebuilt),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", rebuilt.Name, copied, copied, table),
+		fmt.Sprintf("DROP TABLE %s", table),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", rebuilt.Name, table),
+	)
+	return append(stmts, gen.GenerateIndexes(col)...), nil
+}
+
 func (m *Migrator) changeColumnTypeSQL(table, column string, oldField, newField *Field) ([]string, error) {
 	if err := ValidateIdentifier(table); err != nil {
 		return nil, err
@@ -687,6 +921,7 @@ func (m *Migrator) addUniqueConstraintSQL(table, column string) ([]string, error
 
 func (m *Migrator) ValidateUnsafeChanges(changes []*Change) []ValidationError {
 	var errors []ValidationError
+	checkedUnique := make(map[string]bool)
 
 	for _, change := range changes {
 		if change.Safe {
@@ -695,16 +930,27 @@ func (m *Migrator) ValidateUnsafeChanges(changes []*Change) []ValidationError {
 
 		switch change.Type {
 		case ChangeModifyField:
-			if !change.OldField.Unique && change.NewField.Unique {
-				duplicates, err := m.checkDuplicates(change.Collection, change.OldField.Name)
+			path := fmt.Sprintf("%s.%s", change.Collection, change.OldField.Name)
+			// A field made both unique and case-insensitive has a change
+			// for each, but needs checking once.
+			tightened := !change.OldField.Unique || (change.NewField.CaseInsensitive() && !change.OldField.CaseInsensitive())
+			if change.NewField.Unique && tightened && !checkedUnique[path] {
+				checkedUnique[path] = true
+				nocase := change.NewField.CaseInsensitive()
+				duplicates, err := m.checkDuplicates(change.Collection, change.OldField.Name, nocase)
 				if err != nil {
 					errors = append(errors, ValidationError{
-						Path:    fmt.Sprintf("%s.%s", change.Collection, change.OldField.Name),
+						Path:    path,
 						Message: fmt.Sprintf("failed to check for duplicates: %v", err),
 					})
+				} else if duplicates > 0 && nocase {
+					errors = append(errors, ValidationError{
+						Path:    path,
+						Message: fmt.Sprintf("cannot add case-insensitive unique constraint: %d values are duplicated ignoring case", duplicates),
+					})
 				} else if duplicates > 0 {
 					errors = append(errors, ValidationError{
-						Path:    fmt.Sprintf("%s.%s", change.Collection, change.OldField.Name),
+						Path:    path,
 						Message: fmt.Sprintf("cannot add unique constraint: %d duplicate values exist", duplicates),
 					})
 				}
@@ -730,7 +976,37 @@ func (m *Migrator) ValidateUnsafeChanges(changes []*Change) []ValidationError {
 	return errors
 }
 
-func (m *Migrator) checkDuplicates(table, column string) (int, error) {
+// ValidateRoleChanges rejects removing roles that are still assigned to users.
+func (m *Migrator) ValidateRoleChanges(changes []*Change) []ValidationError {
+	var errors []ValidationError
+
+	for _, change := range changes {
+		if change.Type != ChangeModifyRoles {
+			continue
+		}
+		for _, role := range change.RemovedRoles {
+			var count int
+			err := m.db.QueryRow("SELECT COUNT(*) FROM _alyx_users WHERE role = ?", role).Scan(&count)
+			if err != nil {
+				errors = append(errors, ValidationError{
+					Path:    "roles",
+					Message: fmt.Sprintf("failed to count users with role %q: %v", role, err),
+				})
+			} else if count > 0 {
+				errors = append(errors, ValidationError{
+					Path:    "roles",
+					Message: fmt.Sprintf("cannot remove role %q: still assigned to %d user(s)", role, count),
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// checkDuplicates counts the values of column held by more than one row,
+// ignoring case if nocase is set.
+func (m *Migrator) checkDuplicates(table, column string, nocase bool) (int, error) {
 	if err := ValidateIdentifier(table); err != nil {
 		return 0, err
 	}
@@ -738,6 +1014,11 @@ func (m *Migrator) checkDuplicates(table, column string) (int, error) {
 		return 0, err
 	}
 
+	group := column
+	if nocase {
+		group += " COLLATE NOCASE"
+	}
+
 	var count int
 	query := fmt.Sprintf(`
 		SELECT COUNT(*) FROM (
@@ -746,7 +1027,7 @@ func (m *Migrator) checkDuplicates(table, column string) (int, error) {
 			GROUP BY %s 
 			HAVING COUNT(*) > 1
 		)
-	`, column, table, column, column)
+	`, column, table, column, group)
 
 	err := m.db.QueryRow(query).Scan(&count)
 	return count, err
@@ -823,6 +1104,55 @@ func (m *Migrator) NextVersion() (int, error) {
 	return maxVersion + 1, nil
 }
 
+// MigrationMismatch describes an applied migration that no longer agrees with
+// the migration files on disk.
+type MigrationMismatch struct {
+	Version string
+	Name    string
+	Reason  string
+}
+
+// VerifyMigrations checks every applied migration against its file. It reports
+// migrations whose file was edited after being applied and migrations whose
+// file is gone.
+func (m *Migrator) VerifyMigrations() ([]*MigrationMismatch, error) {
+	applied, err := m.AppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := m.loadMigrationFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[string]*Migration, len(files))
+	for _, f := range files {
+		byVersion[strconv.Itoa(f.Version)] = f
+	}
+
+	var mismatches []*MigrationMismatch
+	for _, a := range applied {
+		f, ok := byVersion[a.Version]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, &MigrationMismatch{
+				Version: a.Version,
+				Name:    a.Name,
+				Reason:  "migration file is missing",
+			})
+		case f.Checksum != a.Checksum:
+			mismatches = append(mismatches, &MigrationMismatch{
+				Version: a.Version,
+				Name:    a.Name,
+				Reason:  fmt.Sprintf("checksum changed since it was applied (applied %s, file %s)", a.Checksum, f.Checksum),
+			})
+		}
+	}
+
+	return mismatches, nil
+}
+
 func checksumBytes(data []byte) string {
 	h := sha256.Sum256(data)
 	return hex.EncodeToString(h[:8])
@@ -889,24 +1219,86 @@ func (m *Migrator) LoadRulesFromCache(collection string) (*Rules, error) {
 	return &rules, nil
 }
 
+// SaveCollectionToCache records a collection's rules and full configuration,
+// which InferFromDB merges with the structure it reads from the database.
+func (m *Migrator) SaveCollectionToCache(collection string, col *Collection) error {
+	var rulesJSON sql.NullString
+	if col.Rules != nil {
+		data, err := json.Marshal(col.Rules)
+		if err != nil {
+			return fmt.Errorf("marshaling rules: %w", err)
+		}
+		rulesJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	collectionJSON, err := json.Marshal(col)
+	if err != nil {
+		return fmt.Errorf("marshaling collection: %w", err)
+	}
+
+	_, err = m.db.Exec(`
+		INSERT INTO _alyx_schema_cache (collection, rules_json, collection_json)
+		VALUES (?, ?, ?)
+		ON CONFLICT(collection) DO UPDATE SET
+			rules_json = excluded.rules_json,
+			collection_json = excluded.collection_json,
+			updated_at = datetime('now')
+	`, collection, rulesJSON, string(collectionJSON))
+	return err
+}
+
+func (m *Migrator) saveSchemaToCache(schema *Schema) error {
+	if schema == nil {
+		return nil
+	}
+	for name, collection := range schema.Collections {
+		if err := m.SaveCollectionToCache(name, collection); err != nil {
+			return fmt.Errorf("caching collection %s: %w", name, err)
+		}
+	}
+	return m.SaveSchemaSnapshot(schema)
+}
+
+// EnsureRulesCacheSeeded fills the schema cache from schema for collections
+// that have no cached configuration yet, such as databases created before
+// the cache held more than rules. Cached rules are kept.
 func (m *Migrator) EnsureRulesCacheSeeded(schema *Schema) error {
 	if schema == nil {
 		return nil
 	}
 
-	var count int
-	err := m.db.QueryRow(`SELECT COUNT(*) FROM _alyx_schema_cache`).Scan(&count)
+	cached := make(map[string]bool)
+	rows, err := m.db.Query(`SELECT collection FROM _alyx_schema_cache WHERE collection_json IS NOT NULL`)
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
-
-	if count > 0 {
-		return nil
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("checking cache: %w", err)
+		}
+		cached[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("checking cache: %w", err)
 	}
 
 	for name, collection := range schema.Collections {
-		if err := m.SaveRulesToCache(name, collection.Rules); err != nil {
-			return fmt.Errorf("seeding rules for %s: %w", name, err)
+		if cached[name] {
+			continue
+		}
+		rules, err := m.LoadRulesFromCache(name)
+		if err != nil {
+			return fmt.Errorf("seeding cache for %s: %w", name, err)
+		}
+		seeded := *collection
+		if rules != nil {
+			seeded.Rules = rules
+		}
+		if err := m.SaveCollectionToCache(name, &seeded); err != nil {
+			return fmt.Errorf("seeding cache for %s: %w", name, err)
 		}
 	}
 	return nil
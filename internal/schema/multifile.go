@@ -0,0 +1,333 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IsDir reports whether path points to a schema directory rather than a single file.
+func IsDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// IsSchemaFile reports whether name has a YAML extension.
+func IsSchemaFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// ReadDir returns the contents of every *.yaml and *.yml file in dir, keyed by file name.
+// Subdirectories are not traversed.
+func ReadDir(dir string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema directory: %w", err)
+	}
+
+	files := make(map[string][]byte)
+	for _, entry := range entries {
+		if entry.IsDir() || !IsSchemaFile(entry.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading schema file %s: %w", entry.Name(), err)
+		}
+		files[entry.Name()] = data
+	}
+
+	return files, nil
+}
+
+// ParseDir parses and merges every schema file in dir.
+func ParseDir(dir string) (*Schema, error) {
+	files, err := ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no schema files found in %s", dir)
+	}
+	return ParseFiles(files)
+}
+
+// ParseFiles merges several schema documents keyed by file name into one schema.
+// Each collection, bucket, and function may only be defined in a single file.
+func ParseFiles(files map[string][]byte) (*Schema, error) {
+	merged, _, err := mergeFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	return buildSchema(merged)
+}
+
+// ReadSource returns the schema source at path. For a single file this is the file
+// content unchanged; for a directory it is the canonical YAML of the merged schema,
+// so hashes stay stable regardless of how definitions are split across files.
+func ReadSource(path string) ([]byte, error) {
+	if !IsDir(path) {
+		return os.ReadFile(path)
+	}
+
+	s, err := ParseDir(path)
+	if err != nil {
+		return nil, err
+	}
+	return Marshal(s)
+}
+
+// fileOwners records which file defines each collection, bucket, and function.
+type fileOwners struct {
+	collections map[string]string
+	buckets     map[string]string
+	functions   map[string]string
+	views       map[string]string
+	roles       map[string]string
+	presets     map[string]string
+
+	// userMetadata is the file declaring userMetadata, if any.
+	userMetadata string
+}
+
+func mergeFiles(files map[string][]byte) (*rawSchema, *fileOwners, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged := &rawSchema{
+		Collections: make(map[string]*rawCollection),
+		Buckets:     make(map[string]*rawBucket),
+		Functions:   make(map[string]*rawFunction),
+	}
+	owners := &fileOwners{
+		collections: make(map[string]string),
+		buckets:     make(map[string]string),
+		functions:   make(map[string]string),
+		views:       make(map[string]string),
+		roles:       make(map[string]string),
+		presets:     make(map[string]string),
+	}
+	versionFile := ""
+
+	for _, file := range names {
+		var raw rawSchema
+		if err := yaml.Unmarshal(files[file], &raw); err != nil {
+			return nil, nil, fmt.Errorf("parsing schema YAML in %s: %w", file, err)
+		}
+
+		if raw.Version != 0 {
+			if merged.Version != 0 && merged.Version != raw.Version {
+				return nil, nil, fmt.Errorf("schema version %d in %s conflicts with version %d in %s",
+					raw.Version, file, merged.Version, versionFile)
+			}
+			merged.Version = raw.Version
+			versionFile = file
+		}
+
+		if raw.UserMetadata != nil {
+			if owners.userMetadata != "" {
+				return nil, nil, fmt.Errorf("userMetadata is declared in both %s and %s", owners.userMetadata, file)
+			}
+			owners.userMetadata = file
+			merged.UserMetadata = raw.UserMetadata
+		}
+
+		for _, role := range raw.Roles {
+			if prev, ok := owners.roles[role]; ok {
+				return nil, nil, fmt.Errorf("role %q is declared in both %s and %s", role, prev, file)
+			}
+			owners.roles[role] = file
+			merged.Roles = append(merged.Roles, role)
+		}
+
+		for name, node := range raw.Presets {
+			if prev, ok := owners.presets[name]; ok {
+				return nil, nil, fmt.Errorf("preset %q is defined in both %s and %s", name, prev, file)
+			}
+			if merged.Presets == nil {
+				merged.Presets = make(map[string]yaml.Node)
+			}
+			owners.presets[name] = file
+			merged.Presets[name] = node
+		}
+
+		for name, col := range raw.Collections {
+			if prev, ok := owners.collections[name]; ok {
+				return nil, nil, fmt.Errorf("collection %q is defined in both %s and %s", name, prev, file)
+			}
+			owners.collections[name] = file
+			merged.Collections[name] = col
+		}
+
+		for name, bkt := range raw.Buckets {
+			if prev, ok := owners.buckets[name]; ok {
+				return nil, nil, fmt.Errorf("bucket %q is defined in both %s and %s", name, prev, file)
+			}
+			owners.buckets[name] = file
+			merged.Buckets[name] = bkt
+		}
+
+		for name, fn := range raw.Functions {
+			if prev, ok := owners.functions[name]; ok {
+				return nil, nil, fmt.Errorf("function %q is defined in both %s and %s", name, prev, file)
+			}
+			owners.functions[name] = file
+			merged.Functions[name] = fn
+		}
+
+		for name, v := range raw.Views {
+			if prev, ok := owners.views[name]; ok {
+				return nil, nil, fmt.Errorf("view %q is defined in both %s and %s", name, prev, file)
+			}
+			if merged.Views == nil {
+				merged.Views = make(map[string]*View)
+			}
+			owners.views[name] = file
+			merged.Views[name] = v
+		}
+	}
+
+	return merged, owners, nil
+}
+
+// writeDir writes s back into the schema directory, keeping each definition in the
+// file that already defines it. New collections are written to <name>.yaml, new
+// buckets to buckets.yaml, new functions to functions.yaml, new views to views.yaml,
+// new presets to presets.yaml, and new roles to roles.yaml. Files left with no
+// definitions are removed.
+func writeDir(dir string, s *Schema) error {
+	existing, err := ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	_, owners, err := mergeFiles(existing)
+	if err != nil {
+		return err
+	}
+
+	parts := make(map[string]*Schema)
+	part := func(file string) *Schema {
+		p, ok := parts[file]
+		if !ok {
+			p = &Schema{
+				Version:     s.Version,
+				Collections: make(map[string]*Collection),
+				Buckets:     make(map[string]*Bucket),
+				Functions:   make(map[string]*Function),
+			}
+			parts[file] = p
+		}
+		return p
+	}
+
+	for _, role := range s.Roles {
+		file, ok := owners.roles[role]
+		if !ok {
+			file = "roles.yaml"
+		}
+		p := part(file)
+		p.Roles = append(p.Roles, role)
+	}
+	if s.UserMetadata != nil {
+		file := owners.userMetadata
+		if file == "" {
+			file = "users.yaml"
+		}
+		part(file).UserMetadata = s.UserMetadata
+	}
+	for name, preset := range s.Presets {
+		file, ok := owners.presets[name]
+		if !ok {
+			file = "presets.yaml"
+		}
+		p := part(file)
+		if p.Presets == nil {
+			p.Presets = make(map[string]*FieldPreset)
+		}
+		p.Presets[name] = preset
+	}
+	for name, col := range s.Collections {
+		file, ok := owners.collections[name]
+		if !ok {
+			file = name + ".yaml"
+		}
+		part(file).Collections[name] = col
+	}
+	for name, bkt := range s.Buckets {
+		file, ok := owners.buckets[name]
+		if !ok {
+			file = "buckets.yaml"
+		}
+		part(file).Buckets[name] = bkt
+	}
+	for name, fn := range s.Functions {
+		file, ok := owners.functions[name]
+		if !ok {
+			file = "functions.yaml"
+		}
+		part(file).Functions[name] = fn
+	}
+	for name, v := range s.Views {
+		file, ok := owners.views[name]
+		if !ok {
+			file = "views.yaml"
+		}
+		p := part(file)
+		if p.Views == nil {
+			p.Views = make(map[string]*View)
+		}
+		p.Views[name] = v
+	}
+
+	contents := make(map[string][]byte, len(parts))
+	for file, p := range parts {
+		data, err := Marshal(p)
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", file, err)
+		}
+		contents[file] = data
+	}
+
+	return WriteFiles(dir, contents)
+}
+
+// WriteFiles replaces the schema files in dir with files, keyed by file name.
+// Existing schema files not present in files are removed.
+func WriteFiles(dir string, files map[string][]byte) error {
+	for name := range files {
+		if name != filepath.Base(name) || !IsSchemaFile(name) {
+			return fmt.Errorf("invalid schema file name %q", name)
+		}
+	}
+
+	existing, err := ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for name, data := range files {
+		if err := writeAtomic(filepath.Join(dir, name), data); err != nil {
+			return err
+		}
+	}
+
+	for name := range existing {
+		if _, keep := files[name]; keep {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("removing schema file %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
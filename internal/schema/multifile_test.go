@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const usersSchemaFile = `
+version: 1
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      email:
+        type: email
+`
+
+const postsSchemaFile = `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      author_id:
+        type: uuid
+        references: users.id
+buckets:
+  avatars:
+    backend: filesystem
+`
+
+func writeSchemaDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestParseDir(t *testing.T) {
+	dir := writeSchemaDir(t, map[string]string{
+		"users.yaml": usersSchemaFile,
+		"posts.yml":  postsSchemaFile,
+		"README.md":  "not a schema",
+	})
+
+	s, err := ParseFile(dir)
+	if err != nil {
+		t.Fatalf("ParseFile(dir) failed: %v", err)
+	}
+
+	if s.Version != 1 {
+		t.Errorf("expected version 1, got %d", s.Version)
+	}
+	if _, ok := s.Collections["users"]; !ok {
+		t.Error("expected users collection")
+	}
+	if _, ok := s.Collections["posts"]; !ok {
+		t.Error("expected posts collection")
+	}
+	if _, ok := s.Buckets["avatars"]; !ok {
+		t.Error("expected avatars bucket")
+	}
+}
+
+func TestParseFiles_DuplicateCollection(t *testing.T) {
+	_, err := ParseFiles(map[string][]byte{
+		"a.yaml": []byte(usersSchemaFile),
+		"b.yaml": []byte(usersSchemaFile),
+	})
+	if err == nil {
+		t.Fatal("expected duplicate collection error")
+	}
+	if !strings.Contains(err.Error(), "a.yaml") || !strings.Contains(err.Error(), "b.yaml") {
+		t.Errorf("expected error to name both files, got: %v", err)
+	}
+}
+
+func TestParseFiles_VersionConflict(t *testing.T) {
+	_, err := ParseFiles(map[string][]byte{
+		"users.yaml": []byte(usersSchemaFile),
+		"posts.yaml": []byte(strings.Replace(postsSchemaFile, "version: 1", "version: 2", 1)),
+	})
+	if err == nil {
+		t.Fatal("expected version conflict error")
+	}
+}
+
+func TestWriteFile_Dir(t *testing.T) {
+	dir := writeSchemaDir(t, map[string]string{
+		"users.yaml": usersSchemaFile,
+		"posts.yaml": postsSchemaFile,
+	})
+
+	s, err := ParseDir(dir)
+	if err != nil {
+		t.Fatalf("ParseDir failed: %v", err)
+	}
+
+	s.Collections["tags"] = &Collection{
+		Name: "tags",
+		Fields: map[string]*Field{
+			"id": {Name: "id", Type: FieldTypeID, Primary: true},
+		},
+		fieldOrder: []string{"id"},
+	}
+	delete(s.Collections, "posts")
+	delete(s.Buckets, "avatars")
+
+	if err := WriteFile(dir, s); err != nil {
+		t.Fatalf("WriteFile(dir) failed: %v", err)
+	}
+
+	files, err := ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if _, ok := files["posts.yaml"]; ok {
+		t.Error("expected emptied posts.yaml to be removed")
+	}
+	if !strings.Contains(string(files["users.yaml"]), "users:") {
+		t.Error("expected users to stay in users.yaml")
+	}
+	if !strings.Contains(string(files["tags.yaml"]), "tags:") {
+		t.Error("expected new collection in tags.yaml")
+	}
+
+	reloaded, err := ParseDir(dir)
+	if err != nil {
+		t.Fatalf("re-parsing directory failed: %v", err)
+	}
+	if len(reloaded.Collections) != 2 {
+		t.Errorf("expected 2 collections after round trip, got %d", len(reloaded.Collections))
+	}
+}
+
+func TestReadSource_DirIsCanonical(t *testing.T) {
+	split := writeSchemaDir(t, map[string]string{
+		"users.yaml": usersSchemaFile,
+		"posts.yaml": postsSchemaFile,
+	})
+	combined := writeSchemaDir(t, map[string]string{
+		"all.yaml": usersSchemaFile + strings.Replace(postsSchemaFile, "version: 1\ncollections:", "", 1),
+	})
+
+	a, err := ReadSource(split)
+	if err != nil {
+		t.Fatalf("ReadSource(split) failed: %v", err)
+	}
+	b, err := ReadSource(combined)
+	if err != nil {
+		t.Fatalf("ReadSource(combined) failed: %v", err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("expected identical canonical output:\n%s\nvs\n%s", a, b)
+	}
+}
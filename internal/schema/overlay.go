@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OverlayPath returns the environment overlay file for a schema path, e.g.
+// schema.yaml -> schema.production.yaml. For a schema directory the overlay
+// sits next to it: schema/ -> schema.production.yaml.
+func OverlayPath(path, env string) string {
+	path = filepath.Clean(path)
+	if IsDir(path) {
+		return path + "." + env + ".yaml"
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
+}
+
+// ParseFileWithEnv parses the schema at path and applies the overlay for env,
+// if one exists. An empty env behaves like ParseFile.
+func ParseFileWithEnv(path, env string) (*Schema, error) {
+	s, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if env == "" {
+		return s, nil
+	}
+
+	overlayPath := OverlayPath(path, env)
+	data, err := os.ReadFile(overlayPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("reading schema overlay: %w", err)
+	}
+
+	if err := ApplyOverlay(s, data); err != nil {
+		return nil, fmt.Errorf("schema overlay %s: %w", filepath.Base(overlayPath), err)
+	}
+	return s, nil
+}
+
+// ReadSourceWithEnv is like ReadSource, but when an overlay exists for env the
+// canonical YAML of the merged schema is returned instead.
+func ReadSourceWithEnv(path, env string) ([]byte, error) {
+	if env == "" {
+		return ReadSource(path)
+	}
+	if _, err := os.Stat(OverlayPath(path, env)); err != nil {
+		return ReadSource(path)
+	}
+
+	s, err := ParseFileWithEnv(path, env)
+	if err != nil {
+		return nil, err
+	}
+	return Marshal(s)
+}
+
+type rawOverlay struct {
+	Version     int                  `yaml:"version"`
+	Collections map[string]yaml.Node `yaml:"collections"`
+}
+
+// ApplyOverlay merges an environment overlay into s. Overlays may only change
+// collection rules and indexes; anything structural is rejected so environments
+// cannot drift apart. Rule operations are overridden individually, while an
+// indexes list replaces the collection's indexes entirely.
+func ApplyOverlay(s *Schema, data []byte) error {
+	var top map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &top); err != nil {
+		return fmt.Errorf("parsing overlay YAML: %w", err)
+	}
+	for key := range top {
+		if key != "version" && key != "collections" {
+			return fmt.Errorf("%q cannot be overridden; overlays may only change collection rules and indexes", key)
+		}
+	}
+
+	var raw rawOverlay
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parsing overlay YAML: %w", err)
+	}
+	if raw.Version != 0 && raw.Version != s.Version {
+		return fmt.Errorf("overlay version %d does not match schema version %d", raw.Version, s.Version)
+	}
+
+	for name, node := range raw.Collections {
+		col, ok := s.Collections[name]
+		if !ok {
+			return fmt.Errorf("collection %q does not exist in the base schema", name)
+		}
+
+		var sections map[string]yaml.Node
+		if err := node.Decode(&sections); err != nil {
+			return fmt.Errorf("collection %q: %w", name, err)
+		}
+
+		for key, value := range sections {
+			switch key {
+			case "rules":
+				rules, err := overlayRules(col.Rules, &value)
+				if err != nil {
+					return fmt.Errorf("collection %q: %w", name, err)
+				}
+				col.Rules = rules
+			case "indexes":
+				var indexes []*Index
+				if err := value.Decode(&indexes); err != nil {
+					return fmt.Errorf("collection %q: decoding indexes: %w", name, err)
+				}
+				col.Indexes = indexes
+			default:
+				return fmt.Errorf("collection %q: %q cannot be overridden; overlays may only change rules and indexes", name, key)
+			}
+		}
+	}
+
+	return Validate(s)
+}
+
+func overlayRules(base *Rules, node *yaml.Node) (*Rules, error) {
+	var ops map[string]string
+	if err := node.Decode(&ops); err != nil {
+		return nil, fmt.Errorf("decoding rules: %w", err)
+	}
+
+	merged := &Rules{}
+	if base != nil {
+		*merged = *base
+	}
+
+	for op, expr := range ops {
+		switch op {
+		case "create":
+			merged.Create = expr
+		case "read":
+			merged.Read = expr
+		case "update":
+			merged.Update = expr
+		case "delete":
+			merged.Delete = expr
+		case "download":
+			merged.Download = expr
+		case "history":
+			merged.History = expr
+		default:
+			return nil, fmt.Errorf("unknown rule operation %q", op)
+		}
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const overlayBaseSchema = `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+    rules:
+      create: "true"
+      read: "true"
+`
+
+func TestParseFileWithEnv(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.yaml")
+	if err := os.WriteFile(path, []byte(overlayBaseSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	overlay := `
+collections:
+  posts:
+    rules:
+      create: "auth.id != null"
+      history: "auth.role == 'admin'"
+    indexes:
+      - name: idx_posts_title
+        fields: [title]
+`
+	if err := os.WriteFile(filepath.Join(dir, "schema.production.yaml"), []byte(overlay), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := ParseFileWithEnv(path, "production")
+	if err != nil {
+		t.Fatalf("ParseFileWithEnv failed: %v", err)
+	}
+
+	posts := s.Collections["posts"]
+	if posts.Rules.Create != "auth.id != null" {
+		t.Errorf("expected overridden create rule, got %q", posts.Rules.Create)
+	}
+	if posts.Rules.Read != "true" {
+		t.Errorf("expected base read rule to be kept, got %q", posts.Rules.Read)
+	}
+	if posts.Rules.History != "auth.role == 'admin'" {
+		t.Errorf("expected overridden history rule, got %q", posts.Rules.History)
+	}
+	if len(posts.Indexes) != 1 {
+		t.Errorf("expected 1 index from overlay, got %d", len(posts.Indexes))
+	}
+
+	// Environments without an overlay file use the base schema.
+	s, err = ParseFileWithEnv(path, "staging")
+	if err != nil {
+		t.Fatalf("ParseFileWithEnv failed: %v", err)
+	}
+	if s.Collections["posts"].Rules.Create != "true" {
+		t.Error("expected base rules without an overlay")
+	}
+}
+
+func TestApplyOverlay_RejectsStructuralChanges(t *testing.T) {
+	tests := map[string]string{
+		"fields": `
+collections:
+  posts:
+    fields:
+      body:
+        type: text
+`,
+		"unknown collection": `
+collections:
+  comments:
+    rules:
+      read: "true"
+`,
+		"buckets": `
+buckets:
+  avatars:
+    backend: filesystem
+`,
+		"unknown rule": `
+collections:
+  posts:
+    rules:
+      publish: "true"
+`,
+	}
+
+	for name, overlay := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := Parse([]byte(overlayBaseSchema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ApplyOverlay(s, []byte(overlay))
+			if err == nil {
+				t.Fatal("expected overlay to be rejected")
+			}
+			if name == "fields" && !strings.Contains(err.Error(), "cannot be overridden") {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
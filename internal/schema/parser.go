@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,7 +16,18 @@ var (
 	IdentifierRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
 )
 
+// cronParser accepts the same cron expressions as the scheduler.
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// minScheduleInterval is the shortest interval schedule the scheduler runs.
+const minScheduleInterval = time.Second
+
+// ParseFile parses a schema file, or every schema file in path if it is a directory.
 func ParseFile(path string) (*Schema, error) {
+	if IsDir(path) {
+		return ParseDir(path)
+	}
+
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading schema file: %w", err)
@@ -21,20 +35,48 @@ func ParseFile(path string) (*Schema, error) {
 	return Parse(data)
 }
 
+// Parse parses a schema document. Errors carry a ValidationErrors, which
+// AsValidationErrors returns, giving each problem's path and, where it can
+// be found in data, its line and column.
 func Parse(data []byte) (*Schema, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing schema YAML: %w", yamlErrors(nil, err))
+	}
+
 	var raw rawSchema
-	if err := yaml.Unmarshal(data, &raw); err != nil {
-		return nil, fmt.Errorf("parsing schema YAML: %w", err)
+	if err := doc.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("parsing schema YAML: %w", yamlErrors(&doc, err))
+	}
+
+	s, err := buildSchema(&raw)
+	if err != nil {
+		if errs := AsValidationErrors(err); errs != nil {
+			locateErrors(&doc, errs)
+			return nil, errs
+		}
+		return nil, yamlErrors(&doc, err)
 	}
+	return s, nil
+}
 
+func buildSchema(raw *rawSchema) (*Schema, error) {
 	schema := &Schema{
-		Version:     raw.Version,
-		Collections: make(map[string]*Collection),
-		Buckets:     make(map[string]*Bucket),
+		Version:      raw.Version,
+		Roles:        raw.Roles,
+		Collections:  make(map[string]*Collection),
+		Buckets:      make(map[string]*Bucket),
+		UserMetadata: raw.UserMetadata,
+	}
+
+	var err error
+	schema.Presets, err = parsePresets(raw.Presets)
+	if err != nil {
+		return nil, err
 	}
 
 	for name, rawCol := range raw.Collections {
-		col, err := parseCollection(name, rawCol)
+		col, err := parseCollection(name, rawCol, schema.Presets)
 		if err != nil {
 			return nil, fmt.Errorf("collection %q: %w", name, err)
 		}
@@ -49,12 +91,13 @@ func Parse(data []byte) (*Schema, error) {
 		schema.Buckets[name] = bkt
 	}
 
-	var err error
 	schema.Functions, err = parseFunctions(raw.Functions)
 	if err != nil {
 		return nil, fmt.Errorf("parsing functions: %w", err)
 	}
 
+	schema.Views = parseViews(raw.Views)
+
 	if err := Validate(schema); err != nil {
 		return nil, err
 	}
@@ -64,28 +107,44 @@ func Parse(data []byte) (*Schema, error) {
 
 type rawSchema struct {
 	Version     int                       `yaml:"version"`
+	Roles       []string                  `yaml:"roles"`
 	Collections map[string]*rawCollection `yaml:"collections"`
 	Buckets     map[string]*rawBucket     `yaml:"buckets"`
 	Functions   map[string]*rawFunction   `yaml:"functions,omitempty"`
+	Views       map[string]*View          `yaml:"views,omitempty"`
+	Presets     map[string]yaml.Node      `yaml:"presets,omitempty"`
+
+	UserMetadata *MetadataSchema `yaml:"userMetadata,omitempty"`
 }
 
 type rawCollection struct {
-	Fields  yaml.Node `yaml:"fields"`
-	Indexes []*Index  `yaml:"indexes"`
-	Rules   *Rules    `yaml:"rules"`
+	Fields    yaml.Node        `yaml:"fields"`
+	Indexes   []*Index         `yaml:"indexes"`
+	Rules     *Rules           `yaml:"rules"`
+	Retention *RetentionPolicy `yaml:"retention"`
+	Docs      *CollectionDocs  `yaml:"docs"`
+	Tenant    *TenantConfig    `yaml:"tenant"`
+	History   yaml.Node        `yaml:"history"`
+	Checks    []*Check         `yaml:"checks"`
+	List      *ListConfig      `yaml:"list"`
+	Counters  []*Counter       `yaml:"counters"`
+	Expose    *bool            `yaml:"expose"`
+	Use       []string         `yaml:"use"`
 }
 
 type rawBucket struct {
-	Backend      string   `yaml:"backend"`
-	MaxFileSize  int64    `yaml:"max_file_size"`
-	MaxTotalSize int64    `yaml:"max_total_size"`
-	AllowedTypes []string `yaml:"allowed_types"`
-	Compression  bool     `yaml:"compression"`
-	Rules        *Rules   `yaml:"rules"`
+	Backend      string    `yaml:"backend"`
+	MaxFileSize  int64     `yaml:"max_file_size"`
+	MaxTotalSize int64     `yaml:"max_total_size"`
+	AllowedTypes []string  `yaml:"allowed_types"`
+	Compression  bool      `yaml:"compression"`
+	Rules        *Rules    `yaml:"rules"`
+	GC           *BucketGC `yaml:"gc"`
 }
 
 type rawFunction struct {
 	Runtime      string             `yaml:"runtime"`
+	Mode         string             `yaml:"mode,omitempty"`
 	Entrypoint   string             `yaml:"entrypoint"`
 	Path         string             `yaml:"path,omitempty"`
 	Description  string             `yaml:"description,omitempty"`
@@ -99,31 +158,67 @@ type rawFunction struct {
 	Routes       []FunctionRoute    `yaml:"routes,omitempty"`
 	Build        *FunctionBuild     `yaml:"build,omitempty"`
 	Rules        *FunctionRules     `yaml:"rules,omitempty"`
+	Input        *MetadataSchema    `yaml:"input,omitempty"`
+	Output       *MetadataSchema    `yaml:"output,omitempty"`
 }
 
-func parseCollection(name string, raw *rawCollection) (*Collection, error) {
+func parseCollection(name string, raw *rawCollection, presets map[string]*FieldPreset) (*Collection, error) {
 	col := &Collection{
-		Name:    name,
-		Fields:  make(map[string]*Field),
-		Indexes: raw.Indexes,
-		Rules:   raw.Rules,
+		Name:      name,
+		Fields:    make(map[string]*Field),
+		Indexes:   raw.Indexes,
+		Rules:     raw.Rules,
+		Retention: raw.Retention,
+		Docs:      raw.Docs,
+		Tenant:    raw.Tenant,
+		Checks:    raw.Checks,
+		List:      raw.List,
+		Counters:  raw.Counters,
+		Expose:    raw.Expose,
+		Use:       raw.Use,
+	}
+
+	history, err := parseHistory(&raw.History)
+	if err != nil {
+		return nil, err
+	}
+	col.History = history
+
+	// A collection made only of presets needs no fields block.
+	if raw.Fields.Kind != 0 || len(raw.Use) == 0 {
+		fields, order, err := parseFields(&raw.Fields)
+		if err != nil {
+			return nil, err
+		}
+		col.Fields = fields
+		col.SetFieldOrder(order)
+	}
+
+	if err := expandPresets(col, presets); err != nil {
+		return nil, err
 	}
+	return col, nil
+}
 
-	if raw.Fields.Kind != yaml.MappingNode {
-		return nil, fmt.Errorf("fields must be a mapping")
+// parseFields decodes a mapping of field definitions, returning the fields
+// and their declaration order.
+func parseFields(node *yaml.Node) (map[string]*Field, []string, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("fields must be a mapping")
 	}
 
-	fieldOrder := make([]string, 0, len(raw.Fields.Content)/2)
-	for i := 0; i < len(raw.Fields.Content); i += 2 {
-		keyNode := raw.Fields.Content[i]
-		valueNode := raw.Fields.Content[i+1]
+	fields := make(map[string]*Field, len(node.Content)/2)
+	fieldOrder := make([]string, 0, len(node.Content)/2)
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valueNode := node.Content[i+1]
 
 		fieldName := keyNode.Value
 		fieldOrder = append(fieldOrder, fieldName)
 
 		var field Field
 		if err := valueNode.Decode(&field); err != nil {
-			return nil, fmt.Errorf("field %q: %w", fieldName, err)
+			return nil, nil, fmt.Errorf("field %q: %w", fieldName, err)
 		}
 		field.Name = fieldName
 
@@ -136,11 +231,10 @@ func parseCollection(name string, raw *rawCollection) (*Collection, error) {
 			}
 		}
 
-		col.Fields[fieldName] = &field
+		fields[fieldName] = &field
 	}
 
-	col.SetFieldOrder(fieldOrder)
-	return col, nil
+	return fields, fieldOrder, nil
 }
 
 func parseBucket(name string, raw *rawBucket) (*Bucket, error) {
@@ -152,6 +246,7 @@ func parseBucket(name string, raw *rawBucket) (*Bucket, error) {
 		AllowedTypes: raw.AllowedTypes,
 		Compression:  raw.Compression,
 		Rules:        raw.Rules,
+		GC:           raw.GC,
 	}
 
 	return bucket, nil
@@ -167,6 +262,7 @@ func parseFunctions(raw map[string]*rawFunction) (map[string]*Function, error) {
 		fn := &Function{
 			Name:         name,
 			Runtime:      rawFunc.Runtime,
+			Mode:         rawFunc.Mode,
 			Entrypoint:   rawFunc.Entrypoint,
 			Path:         rawFunc.Path,
 			Description:  rawFunc.Description,
@@ -180,6 +276,8 @@ func parseFunctions(raw map[string]*rawFunction) (map[string]*Function, error) {
 			Routes:       rawFunc.Routes,
 			Build:        rawFunc.Build,
 			Rules:        rawFunc.Rules,
+			Input:        rawFunc.Input,
+			Output:       rawFunc.Output,
 		}
 
 		functions[name] = fn
@@ -189,16 +287,43 @@ func parseFunctions(raw map[string]*rawFunction) (map[string]*Function, error) {
 }
 
 type ValidationError struct {
-	Path    string
-	Message string
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	// Line and Column locate the problem in the parsed document, starting
+	// at 1. They are zero when unknown.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Err is the underlying error, when there is one.
+	Err error `json:"-"`
 }
 
 func (e *ValidationError) Error() string {
-	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	msg := e.Message
+	if e.Path != "" {
+		msg = e.Path + ": " + msg
+	}
+	if e.Line > 0 {
+		msg = fmt.Sprintf("line %d: %s", e.Line, msg)
+	}
+	return msg
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
 }
 
 type ValidationErrors []*ValidationError
 
+// Unwrap returns the individual errors, so errors.Is and errors.As see the
+// underlying error of each.
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
 func (e ValidationErrors) Error() string {
 	if len(e) == 0 {
 		return ""
@@ -230,6 +355,13 @@ func Validate(s *Schema) error {
 		})
 	}
 
+	errs = append(errs, validateRoles(s.Roles)...)
+	errs = append(errs, validatePresets(s.Presets)...)
+
+	if s.UserMetadata != nil {
+		errs = append(errs, validateMetadataSchema("userMetadata", s.UserMetadata, true)...)
+	}
+
 	for name, col := range s.Collections {
 		colErrs := validateCollection(name, col, s)
 		errs = append(errs, colErrs...)
@@ -245,12 +377,47 @@ func Validate(s *Schema) error {
 		errs = append(errs, fnErrs...)
 	}
 
+	for name, v := range s.Views {
+		errs = append(errs, validateView(name, v, s)...)
+	}
+
 	if len(errs) > 0 {
 		return errs
 	}
 	return nil
 }
 
+func validateRoles(roles []string) ValidationErrors {
+	var errs ValidationErrors
+	seen := make(map[string]bool, len(roles))
+
+	for i, role := range roles {
+		path := fmt.Sprintf("roles[%d]", i)
+		switch {
+		case role == RoleUser || role == RoleAdmin:
+			errs = append(errs, &ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("%q is a built-in role and cannot be declared", role),
+			})
+		case seen[role]:
+			errs = append(errs, &ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("role %q is declared more than once", role),
+			})
+		default:
+			if err := ValidateIdentifier(role); err != nil {
+				errs = append(errs, &ValidationError{
+					Path:    path,
+					Message: err.Error(),
+				})
+			}
+		}
+		seen[role] = true
+	}
+
+	return errs
+}
+
 func validateFunction(name string, fn *Function, s *Schema) ValidationErrors {
 	var errs ValidationErrors
 	path := fmt.Sprintf("functions.%s", name)
@@ -305,6 +472,22 @@ func validateFunction(name string, fn *Function, s *Schema) ValidationErrors {
 		})
 	}
 
+	switch fn.Mode {
+	case "", FunctionModeSubprocess:
+	case FunctionModeNative:
+		if fn.Runtime != "go" {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".mode",
+				Message: "native mode is only supported for the go runtime",
+			})
+		}
+	default:
+		errs = append(errs, &ValidationError{
+			Path:    path + ".mode",
+			Message: "must be one of: subprocess, native",
+		})
+	}
+
 	for i, hook := range fn.Hooks {
 		hookErrs := validateFunctionHook(path, i, &hook, s)
 		errs = append(errs, hookErrs...)
@@ -320,6 +503,13 @@ func validateFunction(name string, fn *Function, s *Schema) ValidationErrors {
 		errs = append(errs, routeErrs...)
 	}
 
+	if fn.Input != nil {
+		errs = append(errs, validateMetadataSchema(path+".input", fn.Input, true)...)
+	}
+	if fn.Output != nil {
+		errs = append(errs, validateMetadataSchema(path+".output", fn.Output, false)...)
+	}
+
 	return errs
 }
 
@@ -346,6 +536,32 @@ func validateFunctionHook(fnPath string, index int, hook *FunctionHook, s *Schem
 		}
 	}
 
+	if len(hook.Fields) > 0 {
+		switch {
+		case hook.Type != "database":
+			errs = append(errs, &ValidationError{
+				Path:    path + ".fields",
+				Message: "only applies to database hooks",
+			})
+		case hook.Source == "":
+			errs = append(errs, &ValidationError{
+				Path:    path + ".fields",
+				Message: "requires a source collection",
+			})
+		default:
+			if col, ok := s.Collections[hook.Source]; ok {
+				for i, field := range hook.Fields {
+					if _, exists := col.Fields[field]; !exists {
+						errs = append(errs, &ValidationError{
+							Path:    fmt.Sprintf("%s.fields[%d]", path, i),
+							Message: fmt.Sprintf("field %q does not exist in collection %q", field, hook.Source),
+						})
+					}
+				}
+			}
+		}
+	}
+
 	if hook.Type == "webhook" && hook.Verification == nil {
 		errs = append(errs, &ValidationError{
 			Path:    path + ".verification",
@@ -353,6 +569,53 @@ func validateFunctionHook(fnPath string, index int, hook *FunctionHook, s *Schem
 		})
 	}
 
+	if hook.Verification != nil {
+		errs = append(errs, validateWebhookVerification(path+".verification", hook.Verification)...)
+	}
+
+	return errs
+}
+
+func validateWebhookVerification(path string, v *FunctionWebhookVerification) ValidationErrors {
+	var errs ValidationErrors
+
+	switch v.Type {
+	case WebhookVerifyStripe, WebhookVerifyGitHub, WebhookVerifySlack:
+	case WebhookVerifyHMACSHA256, WebhookVerifyHMACSHA1, WebhookVerifyToken:
+		if v.Header == "" {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".header",
+				Message: fmt.Sprintf("required for %s verification", v.Type),
+			})
+		}
+	default:
+		errs = append(errs, &ValidationError{
+			Path:    path + ".type",
+			Message: "must be one of: stripe, github, slack, hmac-sha256, hmac-sha1, token",
+		})
+	}
+
+	if v.Secret == "" {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".secret",
+			Message: "required field",
+		})
+	}
+
+	if v.Tolerance != "" {
+		if v.Type != WebhookVerifyStripe && v.Type != WebhookVerifySlack {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".tolerance",
+				Message: "only applies to stripe and slack verification",
+			})
+		} else if d, err := time.ParseDuration(v.Tolerance); err != nil || d <= 0 {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".tolerance",
+				Message: "must be a positive duration like 5m",
+			})
+		}
+	}
+
 	return errs
 }
 
@@ -370,6 +633,83 @@ func validateFunctionSchedule(fnPath string, index int, schedule *FunctionSchedu
 		})
 	}
 
+	if schedule.Expression == "" {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".expression",
+			Message: "required field",
+		})
+	} else {
+		switch schedule.Type {
+		case "cron":
+			if _, err := cronParser.Parse(schedule.Expression); err != nil {
+				errs = append(errs, &ValidationError{
+					Path:    path + ".expression",
+					Message: fmt.Sprintf("invalid cron expression: %v", err),
+				})
+			}
+		case "interval":
+			if d, err := time.ParseDuration(schedule.Expression); err != nil || d < minScheduleInterval {
+				errs = append(errs, &ValidationError{
+					Path:    path + ".expression",
+					Message: fmt.Sprintf("must be a duration of at least %s, such as 5m", minScheduleInterval),
+				})
+			}
+		case "one_time":
+			if _, err := time.Parse(time.RFC3339, schedule.Expression); err != nil {
+				errs = append(errs, &ValidationError{
+					Path:    path + ".expression",
+					Message: "must be an RFC3339 timestamp such as 2026-12-31T23:59:59Z",
+				})
+			}
+		}
+	}
+
+	if schedule.Timezone != "" {
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".timezone",
+				Message: fmt.Sprintf("unknown time zone %q", schedule.Timezone),
+			})
+		}
+	}
+
+	switch schedule.Overlap {
+	case "", "skip", "queue", "allow":
+	default:
+		errs = append(errs, &ValidationError{
+			Path:    path + ".overlap",
+			Message: "must be one of: skip, queue, allow",
+		})
+	}
+
+	switch schedule.CatchUp {
+	case "", "none", "one", "all":
+	default:
+		errs = append(errs, &ValidationError{
+			Path:    path + ".catch_up",
+			Message: "must be one of: none, one, all",
+		})
+	}
+
+	if schedule.Jitter != "" {
+		jitter, err := time.ParseDuration(schedule.Jitter)
+		switch {
+		case err != nil || jitter < 0:
+			errs = append(errs, &ValidationError{
+				Path:    path + ".jitter",
+				Message: "must be a non-negative duration such as 30s",
+			})
+		case schedule.Type == "interval":
+			// Jitter as long as the interval could push a run past the next one.
+			if interval, err := time.ParseDuration(schedule.Expression); err == nil && jitter >= interval {
+				errs = append(errs, &ValidationError{
+					Path:    path + ".jitter",
+					Message: fmt.Sprintf("must be shorter than the interval (%s)", schedule.Expression),
+				})
+			}
+		}
+	}
+
 	return errs
 }
 
@@ -377,16 +717,68 @@ func validateFunctionRoute(fnPath string, index int, route *FunctionRoute) Valid
 	var errs ValidationErrors
 	path := fmt.Sprintf("%s.routes[%d]", fnPath, index)
 
-	if !strings.HasPrefix(route.Path, "/") {
+	switch {
+	case !strings.HasPrefix(route.Path, "/"):
 		errs = append(errs, &ValidationError{
 			Path:    path + ".path",
 			Message: "must start with /",
 		})
+	case strings.ContainsAny(route.Path, " \t\n?#"):
+		errs = append(errs, &ValidationError{
+			Path:    path + ".path",
+			Message: "must not contain whitespace, a query string or a fragment",
+		})
+	}
+
+	seen := make(map[string]bool, len(route.Methods))
+	for i, method := range route.Methods {
+		if !validRouteMethods[strings.ToUpper(method)] {
+			errs = append(errs, &ValidationError{
+				Path:    fmt.Sprintf("%s.methods[%d]", path, i),
+				Message: "must be one of: GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS",
+			})
+		}
+		if seen[strings.ToUpper(method)] {
+			errs = append(errs, &ValidationError{
+				Path:    fmt.Sprintf("%s.methods[%d]", path, i),
+				Message: fmt.Sprintf("duplicate method %s", method),
+			})
+		}
+		seen[strings.ToUpper(method)] = true
 	}
 
 	return errs
 }
 
+var validRouteMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true, "OPTIONS": true,
+}
+
+// StaleSchedules returns the paths of one_time schedules whose time is
+// before now. They never run again, which usually means the schedule is
+// left over, but they are not an error: restarting a server after a
+// one-time run must keep working.
+func (s *Schema) StaleSchedules(now time.Time) []string {
+	names := make([]string, 0, len(s.Functions))
+	for name := range s.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var paths []string
+	for _, name := range names {
+		for i, schedule := range s.Functions[name].Schedules {
+			if schedule.Type != "one_time" {
+				continue
+			}
+			if at, err := time.Parse(time.RFC3339, schedule.Expression); err == nil && at.Before(now) {
+				paths = append(paths, fmt.Sprintf("functions.%s.schedules[%d]", name, i))
+			}
+		}
+	}
+	return paths
+}
+
 func validateBucket(name string, b *Bucket) ValidationErrors {
 	var errs ValidationErrors
 	path := fmt.Sprintf("buckets.%s", name)
@@ -426,6 +818,25 @@ func validateBucket(name string, b *Bucket) ValidationErrors {
 		})
 	}
 
+	if b.GC != nil {
+		if _, err := ParseRetentionAge(b.GC.Interval); err != nil {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".gc.interval",
+				Message: fmt.Sprintf("invalid interval: %v", err),
+			})
+		}
+		if b.GC.GracePeriod != "" {
+			if _, err := ParseRetentionAge(b.GC.GracePeriod); err != nil {
+				errs = append(errs, &ValidationError{
+					Path:    path + ".gc.grace_period",
+					Message: fmt.Sprintf("invalid grace period: %v", err),
+				})
+			}
+		}
+	}
+
+	errs = append(errs, validateBucketRules(path+".rules", b.Rules)...)
+
 	for i, mimeType := range b.AllowedTypes {
 		if mimeType == "" {
 			errs = append(errs, &ValidationError{
@@ -465,6 +876,13 @@ func validateCollection(name string, col *Collection, s *Schema) ValidationError
 		})
 	}
 
+	if IsHistoryTable(name) {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf("collection names ending in %q are reserved for history tables", HistoryTableSuffix),
+		})
+	}
+
 	if len(col.Fields) == 0 {
 		errs = append(errs, &ValidationError{
 			Path:    path + ".fields",
@@ -495,6 +913,12 @@ func validateCollection(name string, col *Collection, s *Schema) ValidationError
 		})
 	}
 
+	for _, field := range col.OrderedFields() {
+		if field.Slug != nil {
+			errs = append(errs, validateFieldSlug(path+".fields."+field.Name, field, col)...)
+		}
+	}
+
 	for i, idx := range col.Indexes {
 		idxPath := fmt.Sprintf("%s.indexes[%d]", path, i)
 		if idx.Name == "" {
@@ -519,9 +943,251 @@ func validateCollection(name string, col *Collection, s *Schema) ValidationError
 		}
 	}
 
+	if col.Retention != nil {
+		errs = append(errs, validateRetention(path+".retention", col)...)
+	}
+
+	if col.Tenant != nil {
+		errs = append(errs, validateTenant(path+".tenant", col)...)
+	}
+
+	if col.History != nil {
+		errs = append(errs, validateHistory(path+".history", col)...)
+	}
+
+	if len(col.Checks) > 0 {
+		errs = append(errs, validateChecks(path+".checks", col)...)
+	}
+
+	if col.List != nil {
+		errs = append(errs, validateList(path+".list", col)...)
+	}
+
+	if len(col.Counters) > 0 {
+		errs = append(errs, validateCounters(path+".counters", col, s)...)
+	}
+
+	if col.Docs != nil {
+		errs = append(errs, validateDocs(path+".docs", col)...)
+	}
+
+	errs = append(errs, validateEncryptedUses(path, col)...)
+
+	return errs
+}
+
+func isCollectionOperation(name string) bool {
+	for _, op := range CollectionOperations {
+		if op == name {
+			return true
+		}
+	}
+	return false
+}
+
+func validateDocs(path string, col *Collection) ValidationErrors {
+	var errs ValidationErrors
+
+	ops := make([]string, 0, len(col.Docs.Operations))
+	for op := range col.Docs.Operations {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		if !isCollectionOperation(op) {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".operations." + op,
+				Message: fmt.Sprintf("unknown operation %q: must be one of %s", op, strings.Join(CollectionOperations, ", ")),
+			})
+		} else if col.Docs.Operations[op] == nil {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".operations." + op,
+				Message: "must be a mapping",
+			})
+		}
+	}
+
+	names := make([]string, 0, len(col.Docs.Examples))
+	for name := range col.Docs.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields := make([]string, 0, len(col.Docs.Examples[name]))
+		for field := range col.Docs.Examples[name] {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if _, ok := col.Fields[field]; !ok {
+				errs = append(errs, &ValidationError{
+					Path:    path + ".examples." + name + "." + field,
+					Message: fmt.Sprintf("field %q does not exist in collection", field),
+				})
+			}
+		}
+	}
+
+	return errs
+}
+
+func validateRetention(path string, col *Collection) ValidationErrors {
+	var errs ValidationErrors
+	r := col.Retention
+
+	if r.Field == "" {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".field",
+			Message: "required field",
+		})
+	} else if field, ok := col.Fields[r.Field]; !ok {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".field",
+			Message: fmt.Sprintf("field %q does not exist in collection", r.Field),
+		})
+	} else if field.Type != FieldTypeTimestamp {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".field",
+			Message: fmt.Sprintf("field %q must be a timestamp field", r.Field),
+		})
+	}
+
+	if r.MaxAge == "" && r.MaxRows == 0 {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: "at least one of max_age or max_rows is required",
+		})
+	}
+
+	if r.MaxAge != "" {
+		if _, err := ParseRetentionAge(r.MaxAge); err != nil {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".max_age",
+				Message: fmt.Sprintf("%v; use a duration like 90d, 2w or 36h", err),
+			})
+		}
+	}
+
+	if r.MaxRows < 0 {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".max_rows",
+			Message: "must be non-negative",
+		})
+	}
+
+	return errs
+}
+
+func validateList(path string, col *Collection) ValidationErrors {
+	var errs ValidationErrors
+	l := col.List
+
+	modifiers := l.SortModifiers()
+	for i, field := range l.SortFields() {
+		f, ok := col.Fields[field]
+		switch {
+		case field == "":
+			errs = append(errs, &ValidationError{
+				Path:    path + ".defaultSort",
+				Message: "empty sort field",
+			})
+		case !ok:
+			errs = append(errs, &ValidationError{
+				Path:    path + ".defaultSort",
+				Message: fmt.Sprintf("field %q does not exist in collection", field),
+			})
+		case !f.Type.IsSortable():
+			errs = append(errs, &ValidationError{
+				Path:    path + ".defaultSort",
+				Message: fmt.Sprintf("%s field %q cannot be sorted", f.Type, field),
+			})
+		}
+		if m := modifiers[i]; m != "" && m != "nullsfirst" && m != "nullslast" {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".defaultSort",
+				Message: fmt.Sprintf("unknown sort modifier %q: must be nullsfirst or nullslast", m),
+			})
+		}
+	}
+
+	if l.MaxLimit < 0 || l.MaxLimit > MaxListLimit {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".maxLimit",
+			Message: fmt.Sprintf("must be between 1 and %d", MaxListLimit),
+		})
+	}
+
+	if l.DefaultLimit < 0 {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".defaultLimit",
+			Message: "must be positive",
+		})
+	} else if l.MaxLimit > 0 && l.DefaultLimit > l.MaxLimit {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".defaultLimit",
+			Message: fmt.Sprintf("must not exceed maxLimit (%d)", l.MaxLimit),
+		})
+	}
+
+	return errs
+}
+
+var tenantSourceRegex = regexp.MustCompile(`^auth(\.[A-Za-z_][A-Za-z0-9_]*)+$`)
+
+func validateTenant(path string, col *Collection) ValidationErrors {
+	var errs ValidationErrors
+	t := col.Tenant
+
+	if t.Field == "" {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".field",
+			Message: "required field",
+		})
+	} else if field, ok := col.Fields[t.Field]; !ok {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".field",
+			Message: fmt.Sprintf("field %q does not exist in collection", t.Field),
+		})
+	} else if field.Primary {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".field",
+			Message: "the primary key cannot be the tenant field",
+		})
+	} else if !isIndexed(col, field) {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".field",
+			Message: fmt.Sprintf("field %q must be indexed: set index: true or make it the first field of an index", t.Field),
+		})
+	}
+
+	if t.Source == "" {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".source",
+			Message: "required field",
+		})
+	} else if !tenantSourceRegex.MatchString(t.Source) {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".source",
+			Message: "must be an auth path such as auth.metadata.org_id",
+		})
+	}
+
 	return errs
 }
 
+// isIndexed reports whether lookups on field can use an index.
+func isIndexed(col *Collection, field *Field) bool {
+	if field.Index || field.Unique {
+		return true
+	}
+	for _, idx := range col.Indexes {
+		if len(idx.Fields) > 0 && idx.Fields[0] == field.Name {
+			return true
+		}
+	}
+	return false
+}
+
 func validateField(path, name string, f *Field, s *Schema) ValidationErrors {
 	var errs ValidationErrors
 
@@ -533,6 +1199,12 @@ func validateField(path, name string, f *Field, s *Schema) ValidationErrors {
 	errs = append(errs, validateFieldSelect(path, f)...)
 	errs = append(errs, validateFieldRelation(path, f, s)...)
 	errs = append(errs, validateFieldFile(path, f, s)...)
+	errs = append(errs, validateFieldStorage(path, f, s)...)
+	errs = append(errs, validateFieldUserDelete(path, f)...)
+	errs = append(errs, validateFieldEncrypted(path, f)...)
+	errs = append(errs, validateFieldCollate(path, f)...)
+	errs = append(errs, validateFieldTSType(path, f)...)
+	errs = append(errs, validateFieldReadRule(path, f)...)
 
 	if f.Validate != nil {
 		errs = append(errs, validateFieldValidation(path+".validate", f)...)
@@ -610,6 +1282,45 @@ func validateFieldReferences(path string, f *Field, s *Schema) ValidationErrors
 	return errs
 }
 
+func validateFieldUserDelete(path string, f *Field) ValidationErrors {
+	var errs ValidationErrors
+
+	if f.OnUserDelete == "" {
+		return errs
+	}
+
+	if !f.OnUserDelete.IsValid() {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".onUserDelete",
+			Message: "must be one of: cascade, set_null, keep",
+		})
+		return errs
+	}
+
+	switch f.Type {
+	case FieldTypeUUID, FieldTypeString, FieldTypeID:
+	default:
+		errs = append(errs, &ValidationError{
+			Path:    path + ".onUserDelete",
+			Message: "only applies to uuid, string and id fields holding a user ID",
+		})
+	}
+
+	if f.Primary && f.OnUserDelete == UserDeleteSetNull {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".onUserDelete",
+			Message: "cannot use set_null on a primary key",
+		})
+	} else if f.OnUserDelete == UserDeleteSetNull && !f.Nullable {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".onUserDelete",
+			Message: "cannot use set_null on non-nullable field",
+		})
+	}
+
+	return errs
+}
+
 func validateFieldTimestamps(path string, f *Field) ValidationErrors {
 	var errs ValidationErrors
 
@@ -890,6 +1601,233 @@ func validateFieldFile(path string, f *Field, s *Schema) ValidationErrors {
 	return errs
 }
 
+func validateFieldStorage(path string, f *Field, s *Schema) ValidationErrors {
+	if f.Storage == "" {
+		return nil
+	}
+
+	path += ".storage"
+	switch {
+	case f.Type != FieldTypeBlob:
+		return ValidationErrors{{Path: path, Message: "storage can only be used with blob field type"}}
+	case s.Buckets[f.Storage] == nil:
+		return ValidationErrors{{Path: path, Message: fmt.Sprintf("referenced bucket %q does not exist", f.Storage)}}
+	case !f.Nullable:
+		// The content is uploaded after the document is created.
+		return ValidationErrors{{Path: path, Message: "blob fields stored in a bucket must be nullable"}}
+	}
+	return nil
+}
+
+func validateFieldEncrypted(path string, f *Field) ValidationErrors {
+	if !f.Encrypted {
+		return nil
+	}
+
+	var errs ValidationErrors
+	path += ".encrypted"
+	if f.Type != FieldTypeString && f.Type != FieldTypeText {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: "encrypted can only be used with string or text field types",
+		})
+	}
+	for _, c := range []struct {
+		set  bool
+		name string
+	}{
+		{f.Primary, "primary"},
+		{f.Unique, "unique"},
+		{f.Index, "index"},
+		{f.Slug != nil, "slug"},
+		{f.Default != "", "default"},
+	} {
+		if c.set {
+			errs = append(errs, &ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("encrypted fields cannot be combined with %s", c.name),
+			})
+		}
+	}
+	return errs
+}
+
+func validateFieldCollate(path string, f *Field) ValidationErrors {
+	if f.Collate == "" {
+		return nil
+	}
+
+	path += ".collate"
+	switch {
+	case !f.Collate.IsValid():
+		return ValidationErrors{{
+			Path:    path,
+			Message: fmt.Sprintf("invalid collate %q (must be binary or nocase)", f.Collate),
+		}}
+	case f.Type != FieldTypeString && f.Type != FieldTypeText && f.Type != FieldTypeEmail && f.Type != FieldTypeURL:
+		return ValidationErrors{{
+			Path:    path,
+			Message: "collate can only be used with string, text, email or url field types",
+		}}
+	case f.Encrypted:
+		return ValidationErrors{{
+			Path:    path,
+			Message: "collate cannot be used with encrypted fields",
+		}}
+	}
+	return nil
+}
+
+func validateFieldTSType(path string, f *Field) ValidationErrors {
+	if f.TSType == "" {
+		return nil
+	}
+	if f.Type != FieldTypeJSON {
+		return ValidationErrors{{
+			Path:    path + ".tsType",
+			Message: "tsType can only be used with json field types",
+		}}
+	}
+	if strings.TrimSpace(f.TSType) == "" || strings.ContainsAny(f.TSType, ";\n") {
+		return ValidationErrors{{
+			Path:    path + ".tsType",
+			Message: "tsType must be a single TypeScript type expression",
+		}}
+	}
+	return nil
+}
+
+// validateFieldReadRule rejects read rules on fields that are always needed
+// or never returned. The expression itself is compiled by the rules engine.
+func validateFieldReadRule(path string, f *Field) ValidationErrors {
+	if f.ReadRule == "" {
+		return nil
+	}
+	switch {
+	case f.Primary:
+		return ValidationErrors{{
+			Path:    path + ".readRule",
+			Message: "the primary key cannot have a read rule",
+		}}
+	case f.Internal:
+		return ValidationErrors{{
+			Path:    path + ".readRule",
+			Message: "internal fields are never returned and cannot have a read rule",
+		}}
+	}
+	return nil
+}
+
+// validateEncryptedUses rejects collection settings that would filter, sort
+// or index an encrypted field, since its stored values are ciphertext.
+func validateEncryptedUses(path string, col *Collection) ValidationErrors {
+	var errs ValidationErrors
+	encrypted := func(name string) bool {
+		f, ok := col.Fields[name]
+		return ok && f.Encrypted
+	}
+
+	for i, idx := range col.Indexes {
+		for _, name := range idx.Fields {
+			if encrypted(name) {
+				errs = append(errs, &ValidationError{
+					Path:    fmt.Sprintf("%s.indexes[%d].fields", path, i),
+					Message: fmt.Sprintf("field %q is encrypted and cannot be indexed", name),
+				})
+			}
+		}
+	}
+
+	if col.List != nil {
+		for _, name := range col.List.SortFields() {
+			if encrypted(name) {
+				errs = append(errs, &ValidationError{
+					Path:    path + ".list.defaultSort",
+					Message: fmt.Sprintf("field %q is encrypted and cannot be sorted", name),
+				})
+			}
+		}
+	}
+
+	if col.Tenant != nil && encrypted(col.Tenant.Field) {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".tenant.field",
+			Message: fmt.Sprintf("field %q is encrypted and cannot be filtered", col.Tenant.Field),
+		})
+	}
+
+	for _, f := range col.OrderedFields() {
+		if f.Slug != nil && encrypted(f.Slug.From) {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".fields." + f.Name + ".slug.from",
+				Message: fmt.Sprintf("field %q is encrypted; a slug would expose its value", f.Slug.From),
+			})
+		}
+	}
+
+	return errs
+}
+
+func validateFieldSlug(path string, f *Field, col *Collection) ValidationErrors {
+	var errs ValidationErrors
+	path += ".slug"
+
+	if f.Type != FieldTypeString {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: "slug config can only be used with string field type",
+		})
+	}
+	if !f.Unique {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: "slug fields must be unique",
+		})
+	}
+	if f.Default != "" {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: "slug fields cannot have a default",
+		})
+	}
+
+	if f.Slug.From == "" {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".from",
+			Message: "source field is required",
+		})
+	} else if src, ok := col.Fields[f.Slug.From]; !ok {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".from",
+			Message: fmt.Sprintf("field %q does not exist in collection", f.Slug.From),
+		})
+	} else if src == f {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".from",
+			Message: "a slug cannot be derived from itself",
+		})
+	} else if src.Type != FieldTypeString && src.Type != FieldTypeText {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".from",
+			Message: fmt.Sprintf("field %q must be a string or text field", f.Slug.From),
+		})
+	}
+
+	if f.Slug.MaxLength < 0 {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".maxLength",
+			Message: "must be non-negative",
+		})
+	} else if f.MaxLength != nil && f.Slug.Max() > *f.MaxLength {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".maxLength",
+			Message: fmt.Sprintf("must not exceed the field's maxLength (%d)", *f.MaxLength),
+		})
+	}
+
+	return errs
+}
+
 func validateFieldValidation(path string, f *Field) ValidationErrors {
 	var errs ValidationErrors
 	v := f.Validate
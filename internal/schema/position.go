@@ -0,0 +1,182 @@
+package schema
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlLineRegex matches the line prefix of yaml.v3 syntax and type errors.
+var yamlLineRegex = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// AsValidationErrors returns the validation errors err carries, or nil if
+// it carries none.
+func AsValidationErrors(err error) ValidationErrors {
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	return nil
+}
+
+// yamlErrors converts an error from decoding doc into validation errors,
+// one per line yaml.v3 reported. Errors without a line become a single
+// error without a position.
+func yamlErrors(doc *yaml.Node, err error) ValidationErrors {
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	errs := make(ValidationErrors, 0, len(messages))
+	for _, msg := range messages {
+		verr := &ValidationError{Message: msg}
+		if m := yamlLineRegex.FindStringSubmatch(msg); m != nil {
+			verr.Line, _ = strconv.Atoi(m[1])
+			verr.Message = m[2]
+			if doc != nil {
+				if path, node := nodeOnLine(doc, verr.Line); node != nil {
+					verr.Path = path
+					verr.Column = node.Column
+				}
+			}
+		}
+		errs = append(errs, verr)
+	}
+	return errs
+}
+
+// locateErrors sets the line and column of each error to those of the node
+// its path names in doc, or of the closest node on the way to it.
+func locateErrors(doc *yaml.Node, errs ValidationErrors) {
+	for _, e := range errs {
+		if e.Line != 0 || e.Path == "" {
+			continue
+		}
+		if node := locatePath(doc, e.Path); node != nil {
+			e.Line = node.Line
+			e.Column = node.Column
+		}
+	}
+}
+
+// locatePath resolves a validation path such as
+// collections.posts.fields.slug.validate.pattern or
+// functions.notify.hooks[0].type. A fully resolved path to a scalar gives
+// its value, so the position points at the offending value; otherwise it
+// gives the key of the deepest mapping entry reached.
+func locatePath(doc *yaml.Node, path string) *yaml.Node {
+	node := documentRoot(doc)
+	if node == nil {
+		return nil
+	}
+
+	var found *yaml.Node
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		key, indexes := splitIndexes(segment)
+
+		keyNode, value := mappingEntry(node, key)
+		if value == nil {
+			return found
+		}
+		found, node = keyNode, value
+
+		for _, index := range indexes {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return found
+			}
+			node = node.Content[index]
+			found = node
+		}
+
+		if i == len(segments)-1 && node.Kind == yaml.ScalarNode {
+			found = node
+		}
+	}
+	return found
+}
+
+// splitIndexes splits a path segment such as hooks[0] into its key and
+// indexes.
+func splitIndexes(segment string) (string, []int) {
+	key, rest, ok := strings.Cut(segment, "[")
+	if !ok {
+		return segment, nil
+	}
+	var indexes []int
+	for _, part := range strings.Split(rest, "[") {
+		index, err := strconv.Atoi(strings.TrimSuffix(part, "]"))
+		if err != nil {
+			break
+		}
+		indexes = append(indexes, index)
+	}
+	return key, indexes
+}
+
+// mappingEntry returns the key and value nodes of key in a mapping node.
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// nodeOnLine returns the innermost value node on line and its path. A
+// mapping or sequence starts on the line of its first entry, so children are
+// preferred to their parent.
+func nodeOnLine(doc *yaml.Node, line int) (string, *yaml.Node) {
+	var walk func(node *yaml.Node, path string) (string, *yaml.Node)
+	walk = func(node *yaml.Node, path string) (string, *yaml.Node) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				childPath := node.Content[i].Value
+				if path != "" {
+					childPath = path + "." + childPath
+				}
+				if p, n := walk(node.Content[i+1], childPath); n != nil {
+					return p, n
+				}
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				if p, n := walk(child, path+"["+strconv.Itoa(i)+"]"); n != nil {
+					return p, n
+				}
+			}
+		}
+		if node.Line == line {
+			return path, node
+		}
+		return "", nil
+	}
+
+	root := documentRoot(doc)
+	if root == nil {
+		return "", nil
+	}
+	return walk(root, "")
+}
+
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			return nil
+		}
+		return doc.Content[0]
+	}
+	return doc
+}
@@ -0,0 +1,153 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_ErrorPositions(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		path   string
+		line   int
+		column int
+		msg    string
+	}{
+		{
+			name: "syntax error",
+			yaml: `version: 1
+collections:
+  posts:
+    fields: [
+`,
+			line: 4,
+			msg:  "did not find expected node content",
+		},
+		{
+			name: "type mismatch",
+			yaml: `version: 1
+collections:
+  posts:
+    fields:
+      title:
+        type: string
+    retention:
+      max_rows: lots
+`,
+			path:   "collections.posts.retention.max_rows",
+			line:   8,
+			column: 17,
+			msg:    "cannot unmarshal",
+		},
+		{
+			name: "type mismatch in a field",
+			yaml: `version: 1
+collections:
+  posts:
+    fields:
+      title:
+        type: string
+        nullable: maybe
+`,
+			path:   "collections.posts.fields.title.nullable",
+			line:   7,
+			column: 19,
+			msg:    "cannot unmarshal",
+		},
+		{
+			name: "invalid field type",
+			yaml: `version: 1
+collections:
+  posts:
+    fields:
+      title:
+        type: strng
+`,
+			path:   "collections.posts.fields.title.type",
+			line:   6,
+			column: 15,
+			msg:    `invalid type "strng"`,
+		},
+		{
+			name: "nested validation",
+			yaml: `version: 1
+collections:
+  posts:
+    fields:
+      slug:
+        type: string
+        validate:
+          pattern: "[a-z"
+`,
+			path:   "collections.posts.fields.slug.validate.pattern",
+			line:   8,
+			column: 20,
+			msg:    "invalid regex pattern",
+		},
+		{
+			name: "indexed path",
+			yaml: `version: 1
+collections:
+  posts:
+    fields:
+      title:
+        type: string
+    indexes:
+      - name: idx_posts_missing
+        fields: [missing]
+`,
+			path:   "collections.posts.indexes[0].fields",
+			line:   9,
+			column: 9,
+			msg:    "missing",
+		},
+		{
+			name: "top-level key",
+			yaml: `version: 0
+collections:
+  posts:
+    fields:
+      title:
+        type: string
+`,
+			path:   "version",
+			line:   1,
+			column: 10,
+			msg:    "must be at least 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			errs := AsValidationErrors(err)
+			if len(errs) == 0 {
+				t.Fatalf("expected validation errors, got %v", err)
+			}
+
+			var got *ValidationError
+			for _, e := range errs {
+				if e.Path == tt.path {
+					got = e
+					break
+				}
+			}
+			if got == nil {
+				t.Fatalf("no error at %q in %v", tt.path, err)
+			}
+			if got.Line != tt.line || got.Column != tt.column {
+				t.Errorf("position = %d:%d, want %d:%d", got.Line, got.Column, tt.line, tt.column)
+			}
+			if !strings.Contains(got.Message, tt.msg) {
+				t.Errorf("message %q does not contain %q", got.Message, tt.msg)
+			}
+			if !strings.Contains(err.Error(), got.Message) {
+				t.Errorf("error %q does not include the message", err.Error())
+			}
+		})
+	}
+}
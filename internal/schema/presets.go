@@ -0,0 +1,225 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Built-in field presets.
+const (
+	PresetID         = "id"
+	PresetTimestamps = "timestamps"
+)
+
+// FieldPreset is a named group of fields that collections include with use.
+type FieldPreset struct {
+	Name   string
+	Fields map[string]*Field
+
+	fieldOrder []string
+}
+
+// FieldOrder returns the preset's field names in declaration order.
+func (p *FieldPreset) FieldOrder() []string {
+	return p.fieldOrder
+}
+
+// SetFieldOrder sets the order in which the preset's fields are added to a
+// collection.
+func (p *FieldPreset) SetFieldOrder(order []string) {
+	p.fieldOrder = order
+}
+
+// BuiltinPresets returns the presets available without declaring them:
+// id, an auto-generated primary key, and timestamps, created_at and
+// updated_at.
+func BuiltinPresets() map[string]*FieldPreset {
+	return map[string]*FieldPreset{
+		PresetID: {
+			Name: PresetID,
+			Fields: map[string]*Field{
+				"id": {Name: "id", Type: FieldTypeID, Primary: true, Default: "auto"},
+			},
+			fieldOrder: []string{"id"},
+		},
+		PresetTimestamps: {
+			Name: PresetTimestamps,
+			Fields: map[string]*Field{
+				"created_at": {Name: "created_at", Type: FieldTypeTimestamp, Default: "now"},
+				"updated_at": {Name: "updated_at", Type: FieldTypeTimestamp, Default: "now", OnUpdate: "now"},
+			},
+			fieldOrder: []string{"created_at", "updated_at"},
+		},
+	}
+}
+
+// lookupPreset returns the named preset, preferring one declared in the
+// schema over a built-in one.
+func lookupPreset(name string, presets map[string]*FieldPreset) *FieldPreset {
+	if p, ok := presets[name]; ok {
+		return p
+	}
+	return BuiltinPresets()[name]
+}
+
+// presetField records the preset a collection field was expanded from, and
+// the field as the preset defined it.
+type presetField struct {
+	preset string
+	field  *Field
+}
+
+// expandPresets adds the fields of the presets in col.Use to col, in use
+// order and ahead of the collection's own fields. A field the collection
+// declares itself overrides a preset field of the same name and takes its
+// position; a later preset overrides an earlier one.
+func expandPresets(col *Collection, presets map[string]*FieldPreset) error {
+	if len(col.Use) == 0 {
+		return nil
+	}
+
+	var order []string
+	expanded := make(map[string]*presetField)
+	for _, name := range col.Use {
+		p := lookupPreset(name, presets)
+		if p == nil {
+			return fmt.Errorf("use: unknown preset %q", name)
+		}
+		for _, fieldName := range p.FieldOrder() {
+			if _, ok := expanded[fieldName]; !ok {
+				order = append(order, fieldName)
+			}
+			expanded[fieldName] = &presetField{preset: name, field: p.Fields[fieldName]}
+		}
+	}
+
+	col.presetFields = expanded
+	for _, fieldName := range order {
+		if _, ok := col.Fields[fieldName]; ok {
+			continue
+		}
+		field := *expanded[fieldName].field
+		field.Name = fieldName
+		col.Fields[fieldName] = &field
+	}
+
+	for _, fieldName := range col.fieldOrder {
+		if _, ok := expanded[fieldName]; !ok {
+			order = append(order, fieldName)
+		}
+	}
+	col.fieldOrder = order
+	return nil
+}
+
+// PresetOf returns the preset the named field was expanded from, or "" if
+// the collection declares the field itself or it has changed since.
+func (c *Collection) PresetOf(fieldName string) string {
+	pf, ok := c.presetFields[fieldName]
+	if !ok {
+		return ""
+	}
+	field, ok := c.Fields[fieldName]
+	if !ok || !reflect.DeepEqual(marshalField(field), marshalField(pf.field)) {
+		return ""
+	}
+	return pf.preset
+}
+
+// writtenUse returns the presets to write in the collection's use list, and
+// the fields they cover, which are left out of its fields. A preset whose
+// fields were not all kept is dropped, and its remaining fields written out,
+// since use would add the removed ones back.
+func (c *Collection) writtenUse() ([]string, map[string]bool) {
+	if len(c.Use) == 0 {
+		return nil, nil
+	}
+
+	dropped := make(map[string]bool)
+	for fieldName, pf := range c.presetFields {
+		if _, ok := c.Fields[fieldName]; !ok {
+			dropped[pf.preset] = true
+		}
+	}
+
+	use := make([]string, 0, len(c.Use))
+	for _, name := range c.Use {
+		if !dropped[name] {
+			use = append(use, name)
+		}
+	}
+	covered := make(map[string]bool)
+	for fieldName := range c.presetFields {
+		if preset := c.PresetOf(fieldName); preset != "" && !dropped[preset] {
+			covered[fieldName] = true
+		}
+	}
+	return use, covered
+}
+
+// parsePresets parses the presets block, whose values are field mappings
+// like a collection's fields.
+func parsePresets(raw map[string]yaml.Node) (map[string]*FieldPreset, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	presets := make(map[string]*FieldPreset, len(raw))
+	for name, node := range raw {
+		fields, order, err := parseFields(&node)
+		if err != nil {
+			return nil, fmt.Errorf("preset %q: %w", name, err)
+		}
+		presets[name] = &FieldPreset{Name: name, Fields: fields, fieldOrder: order}
+	}
+	return presets, nil
+}
+
+func validatePresets(presets map[string]*FieldPreset) ValidationErrors {
+	var errs ValidationErrors
+
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := "presets." + name
+		if !IdentifierRegex.MatchString(name) {
+			errs = append(errs, &ValidationError{
+				Path:    path,
+				Message: "name must start with lowercase letter and contain only lowercase letters, numbers, and underscores",
+			})
+		}
+		p := presets[name]
+		if len(p.Fields) == 0 {
+			errs = append(errs, &ValidationError{Path: path, Message: "at least one field is required"})
+		}
+		for _, fieldName := range p.FieldOrder() {
+			errs = append(errs, validateFieldBasics(path+"."+fieldName, fieldName, p.Fields[fieldName])...)
+		}
+	}
+
+	return errs
+}
+
+// marshalPreset encodes a preset's fields as a mapping in field order.
+func marshalPreset(p *FieldPreset) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, fieldName := range p.FieldOrder() {
+		field, ok := p.Fields[fieldName]
+		if !ok {
+			continue
+		}
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(marshalField(field)); err != nil {
+			return nil, fmt.Errorf("encoding preset field %s.%s: %w", p.Name, fieldName, err)
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: fieldName}, valueNode)
+	}
+	return node, nil
+}
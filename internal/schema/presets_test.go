@@ -0,0 +1,244 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const presetsSchema = `
+version: 1
+presets:
+  audited:
+    created_by:
+      type: string
+      nullable: true
+    updated_at:
+      type: timestamp
+      nullable: true
+collections:
+  posts:
+    use: [id, timestamps, audited]
+    fields:
+      title:
+        type: string
+      created_at:
+        type: timestamp
+        nullable: true
+  tags:
+    use: [id]
+`
+
+func TestPresets_Expansion(t *testing.T) {
+	s, err := Parse([]byte(presetsSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	posts := s.Collections["posts"]
+	wantOrder := []string{"id", "created_at", "updated_at", "created_by", "title"}
+	if got := posts.FieldOrder(); !reflect.DeepEqual(got, wantOrder) {
+		t.Errorf("field order = %v, want %v", got, wantOrder)
+	}
+
+	if id := posts.Fields["id"]; id.Type != FieldTypeID || !id.Primary || id.Default != "auto" {
+		t.Errorf("id preset field not expanded: %+v", id)
+	}
+
+	// The collection's own definition overrides the preset's.
+	if f := posts.Fields["created_at"]; !f.Nullable || f.Default != "" {
+		t.Errorf("explicit created_at did not override preset: %+v", f)
+	}
+	if got := posts.PresetOf("created_at"); got != "" {
+		t.Errorf("PresetOf(created_at) = %q, want empty for an overridden field", got)
+	}
+
+	// A later preset overrides an earlier one.
+	if f := posts.Fields["updated_at"]; !f.Nullable || f.OnUpdate != "" {
+		t.Errorf("audited.updated_at did not override timestamps.updated_at: %+v", f)
+	}
+	if got := posts.PresetOf("updated_at"); got != "audited" {
+		t.Errorf("PresetOf(updated_at) = %q, want audited", got)
+	}
+
+	// A collection made only of presets needs no fields block.
+	if got := s.Collections["tags"].FieldOrder(); !reflect.DeepEqual(got, []string{"id"}) {
+		t.Errorf("tags field order = %v, want [id]", got)
+	}
+
+	sql := strings.Join(NewSQLGenerator(s).GenerateAll(), "\n")
+	if !strings.Contains(sql, "created_by TEXT") || !strings.Contains(sql, "updated_at TEXT") {
+		t.Errorf("expected preset columns in generated SQL, got:\n%s", sql)
+	}
+}
+
+func TestPresets_UserPresetShadowsBuiltin(t *testing.T) {
+	s, err := Parse([]byte(`
+version: 1
+presets:
+  id:
+    id:
+      type: uuid
+      primary: true
+      default: auto
+collections:
+  items:
+    use: [id]
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := s.Collections["items"].Fields["id"].Type; got != FieldTypeUUID {
+		t.Errorf("id type = %q, want the declared preset's uuid", got)
+	}
+}
+
+func TestPresets_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{
+			name: "unknown preset",
+			yaml: `
+version: 1
+collections:
+  items:
+    use: [id, missing]
+`,
+			want: `unknown preset "missing"`,
+		},
+		{
+			name: "invalid preset field",
+			yaml: `
+version: 1
+presets:
+  broken:
+    amount:
+      type: money
+collections:
+  items:
+    use: [id, broken]
+`,
+			want: "presets.broken.amount",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPresets_RoundTrip(t *testing.T) {
+	s, err := Parse([]byte(presetsSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	out := string(data)
+	if !strings.Contains(out, "use:\n            - id\n            - timestamps\n            - audited") {
+		t.Errorf("expected use list to be written, got:\n%s", out)
+	}
+	if strings.Contains(out, "created_by:\n                type") {
+		t.Errorf("expected preset fields to be left out of posts, got:\n%s", out)
+	}
+
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse of marshaled schema failed: %v\n%s", err, out)
+	}
+	for _, name := range []string{"posts", "tags"} {
+		want, got := s.Collections[name], reparsed.Collections[name]
+		if !reflect.DeepEqual(got.FieldOrder(), want.FieldOrder()) {
+			t.Errorf("%s field order = %v, want %v", name, got.FieldOrder(), want.FieldOrder())
+		}
+		for fieldName, f := range want.Fields {
+			if !reflect.DeepEqual(marshalField(got.Fields[fieldName]), marshalField(f)) {
+				t.Errorf("%s.%s changed across round trip: %+v", name, fieldName, got.Fields[fieldName])
+			}
+		}
+	}
+}
+
+func TestPresets_WriteAfterFieldRemoved(t *testing.T) {
+	s, err := Parse([]byte(presetsSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// Removing a preset field drops the preset from use, so writing it back
+	// does not restore the field; the preset's other fields stay.
+	posts := s.Collections["posts"]
+	delete(posts.Fields, "created_by")
+	posts.SetFieldOrder([]string{"id", "created_at", "updated_at", "title"})
+
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse of marshaled schema failed: %v\n%s", err, data)
+	}
+
+	got := reparsed.Collections["posts"]
+	if _, ok := got.Fields["created_by"]; ok {
+		t.Errorf("removed preset field came back:\n%s", data)
+	}
+	if f, ok := got.Fields["updated_at"]; !ok || !f.Nullable {
+		t.Errorf("expected audited.updated_at to be kept, got %+v", f)
+	}
+	if !reflect.DeepEqual(got.Use, []string{"id", "timestamps"}) {
+		t.Errorf("use = %v, want [id timestamps]", got.Use)
+	}
+}
+
+func TestPresets_WriteDir(t *testing.T) {
+	dir := writeSchemaDir(t, map[string]string{
+		"schema.yaml": presetsSchema,
+	})
+
+	s, err := ParseFile(dir)
+	if err != nil {
+		t.Fatalf("ParseFile(dir) failed: %v", err)
+	}
+	s.Presets["owned"] = &FieldPreset{
+		Name:       "owned",
+		Fields:     map[string]*Field{"owner_id": {Name: "owner_id", Type: FieldTypeString}},
+		fieldOrder: []string{"owner_id"},
+	}
+
+	if err := WriteFile(dir, s); err != nil {
+		t.Fatalf("WriteFile(dir) failed: %v", err)
+	}
+
+	files, err := ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(files["schema.yaml"]), "audited:") {
+		t.Errorf("expected audited preset to stay in schema.yaml, got:\n%s", files["schema.yaml"])
+	}
+	if !strings.Contains(string(files["presets.yaml"]), "owned:") {
+		t.Errorf("expected new preset in presets.yaml, got files %v", files)
+	}
+
+	reparsed, err := ParseFile(dir)
+	if err != nil {
+		t.Fatalf("ParseFile after write failed: %v", err)
+	}
+	if len(reparsed.Presets) != 2 || len(reparsed.Collections["posts"].Fields) != 5 {
+		t.Errorf("unexpected schema after write: presets %v, posts fields %v",
+			reparsed.Presets, reparsed.Collections["posts"].FieldOrder())
+	}
+}
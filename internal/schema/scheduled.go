@@ -0,0 +1,252 @@
+package schema
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scheduled apply states.
+const (
+	ScheduledApplyPending   = "scheduled"
+	ScheduledApplyRunning   = "running"
+	ScheduledApplySucceeded = "succeeded"
+	ScheduledApplyFailed    = "failed"
+	ScheduledApplyCancelled = "cancelled"
+)
+
+var (
+	// ErrApplyAlreadyScheduled is returned when scheduling an apply while
+	// another is waiting to run.
+	ErrApplyAlreadyScheduled = errors.New("a schema apply is already scheduled")
+	// ErrScheduledApplyNotFound is returned for an unknown scheduled apply.
+	ErrScheduledApplyNotFound = errors.New("scheduled apply not found")
+	// ErrScheduledApplyStarted is returned when cancelling an apply that has
+	// already run or started.
+	ErrScheduledApplyStarted = errors.New("scheduled apply has already started")
+)
+
+// ScheduledApply is a one-time apply of the pending schema changes at a
+// set time.
+type ScheduledApply struct {
+	ID     string    `json:"id"`
+	RunAt  time.Time `json:"run_at"`
+	Status string    `json:"status"`
+	// Maintenance puts the server in maintenance mode while the changes
+	// are applied.
+	Maintenance bool       `json:"maintenance"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Applied     int        `json:"applied"`
+	Errors      []string   `json:"errors,omitempty"`
+}
+
+// ScheduledApplyStore persists scheduled applies, so they survive restarts.
+type ScheduledApplyStore struct {
+	db *sql.DB
+}
+
+func NewScheduledApplyStore(db *sql.DB) *ScheduledApplyStore {
+	return &ScheduledApplyStore{db: db}
+}
+
+func (s *ScheduledApplyStore) Init() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS _alyx_scheduled_schema_applies (
+			id TEXT PRIMARY KEY,
+			run_at TEXT NOT NULL,
+			status TEXT NOT NULL,
+			maintenance INTEGER NOT NULL DEFAULT 0,
+			created_by TEXT,
+			created_at TEXT NOT NULL,
+			started_at TEXT,
+			finished_at TEXT,
+			applied INTEGER NOT NULL DEFAULT 0,
+			errors_json TEXT
+		)
+	`)
+	return err
+}
+
+// Schedule records an apply to run at runAt. Only one apply can wait at a
+// time.
+func (s *ScheduledApplyStore) Schedule(runAt time.Time, maintenance bool, createdBy string) (*ScheduledApply, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var waiting int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM _alyx_scheduled_schema_applies WHERE status = ?", ScheduledApplyPending).Scan(&waiting); err != nil {
+		return nil, fmt.Errorf("checking scheduled applies: %w", err)
+	}
+	if waiting > 0 {
+		return nil, ErrApplyAlreadyScheduled
+	}
+
+	apply := &ScheduledApply{
+		ID:          uuid.New().String(),
+		RunAt:       runAt.UTC().Truncate(time.Second),
+		Status:      ScheduledApplyPending,
+		Maintenance: maintenance,
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	_, err = tx.Exec(`
+		INSERT INTO _alyx_scheduled_schema_applies (id, run_at, status, maintenance, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, apply.ID, apply.RunAt.Format(time.RFC3339), apply.Status, boolInt(maintenance), apply.CreatedBy, apply.CreatedAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("inserting scheduled apply: %w", err)
+	}
+
+	return apply, tx.Commit()
+}
+
+// List returns the most recent scheduled applies, newest first.
+func (s *ScheduledApplyStore) List(limit int) ([]*ScheduledApply, error) {
+	return s.query("ORDER BY created_at DESC, rowid DESC LIMIT ?", limit)
+}
+
+// Get returns the scheduled apply with the given ID.
+func (s *ScheduledApplyStore) Get(id string) (*ScheduledApply, error) {
+	applies, err := s.query("WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(applies) == 0 {
+		return nil, ErrScheduledApplyNotFound
+	}
+	return applies[0], nil
+}
+
+// Due returns the waiting applies whose time has come.
+func (s *ScheduledApplyStore) Due(now time.Time) ([]*ScheduledApply, error) {
+	return s.query("WHERE status = ? AND run_at <= ? ORDER BY run_at", ScheduledApplyPending, now.UTC().Format(time.RFC3339))
+}
+
+// Cancel cancels a waiting apply.
+func (s *ScheduledApplyStore) Cancel(id string) (*ScheduledApply, error) {
+	res, err := s.db.Exec(`
+		UPDATE _alyx_scheduled_schema_applies SET status = ?, finished_at = ?
+		WHERE id = ? AND status = ?
+	`, ScheduledApplyCancelled, time.Now().UTC().Format(time.RFC3339), id, ScheduledApplyPending)
+	if err != nil {
+		return nil, fmt.Errorf("cancelling scheduled apply: %w", err)
+	}
+	apply, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return apply, ErrScheduledApplyStarted
+	}
+	return apply, nil
+}
+
+// Start marks a waiting apply as running. It reports false if the apply was
+// cancelled or claimed in the meantime.
+func (s *ScheduledApplyStore) Start(id string) (bool, error) {
+	res, err := s.db.Exec(`
+		UPDATE _alyx_scheduled_schema_applies SET status = ?, started_at = ?
+		WHERE id = ? AND status = ?
+	`, ScheduledApplyRunning, time.Now().UTC().Format(time.RFC3339), id, ScheduledApplyPending)
+	if err != nil {
+		return false, fmt.Errorf("starting scheduled apply: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Finish records the outcome of a running apply. It failed if errs is not
+// empty.
+func (s *ScheduledApplyStore) Finish(id string, applied int, errs []string) error {
+	status := ScheduledApplySucceeded
+	var errorsJSON sql.NullString
+	if len(errs) > 0 {
+		status = ScheduledApplyFailed
+		data, _ := json.Marshal(errs)
+		errorsJSON = sql.NullString{String: string(data), Valid: true}
+	}
+	_, err := s.db.Exec(`
+		UPDATE _alyx_scheduled_schema_applies SET status = ?, finished_at = ?, applied = ?, errors_json = ?
+		WHERE id = ?
+	`, status, time.Now().UTC().Format(time.RFC3339), applied, errorsJSON, id)
+	if err != nil {
+		return fmt.Errorf("finishing scheduled apply: %w", err)
+	}
+	return nil
+}
+
+// FailInterrupted marks applies left running by a previous process as
+// failed. It returns how many there were.
+func (s *ScheduledApplyStore) FailInterrupted() (int, error) {
+	data, _ := json.Marshal([]string{"interrupted by a server restart"})
+	res, err := s.db.Exec(`
+		UPDATE _alyx_scheduled_schema_applies SET status = ?, finished_at = ?, errors_json = ?
+		WHERE status = ?
+	`, ScheduledApplyFailed, time.Now().UTC().Format(time.RFC3339), string(data), ScheduledApplyRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failing interrupted applies: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *ScheduledApplyStore) query(clause string, args ...any) ([]*ScheduledApply, error) {
+	//nolint:gosec // clause is one of this file's constants
+	rows, err := s.db.Query(`
+		SELECT id, run_at, status, maintenance, created_by, created_at, started_at, finished_at, applied, errors_json
+		FROM _alyx_scheduled_schema_applies `+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying scheduled applies: %w", err)
+	}
+	defer rows.Close()
+
+	var applies []*ScheduledApply
+	for rows.Next() {
+		var a ScheduledApply
+		var runAt, createdAt string
+		var maintenance int
+		var createdBy, startedAt, finishedAt, errorsJSON sql.NullString
+		if err := rows.Scan(&a.ID, &runAt, &a.Status, &maintenance, &createdBy, &createdAt, &startedAt, &finishedAt, &a.Applied, &errorsJSON); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		a.Maintenance = maintenance == 1
+		a.CreatedBy = createdBy.String
+		a.RunAt, _ = time.Parse(time.RFC3339, runAt)
+		a.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		a.StartedAt = parseOptionalTime(startedAt)
+		a.FinishedAt = parseOptionalTime(finishedAt)
+		if errorsJSON.Valid {
+			_ = json.Unmarshal([]byte(errorsJSON.String), &a.Errors)
+		}
+		applies = append(applies, &a)
+	}
+	return applies, rows.Err()
+}
+
+func parseOptionalTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
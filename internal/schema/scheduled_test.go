@@ -0,0 +1,76 @@
+package schema
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduledApplyStore(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "scheduled.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := NewScheduledApplyStore(db)
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	runAt := time.Now().Add(time.Hour)
+	first, err := store.Schedule(runAt, false, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Schedule(runAt, false, "ci"); !errors.Is(err, ErrApplyAlreadyScheduled) {
+		t.Fatalf("expected ErrApplyAlreadyScheduled, got %v", err)
+	}
+
+	if due, _ := store.Due(time.Now()); len(due) != 0 {
+		t.Errorf("expected nothing due yet, got %d", len(due))
+	}
+	if due, _ := store.Due(runAt.Add(time.Minute)); len(due) != 1 || due[0].ID != first.ID {
+		t.Errorf("expected the apply to be due, got %v", due)
+	}
+
+	cancelled, err := store.Cancel(first.ID)
+	if err != nil || cancelled.Status != ScheduledApplyCancelled {
+		t.Fatalf("cancel: %v %+v", err, cancelled)
+	}
+	if _, err := store.Cancel(first.ID); !errors.Is(err, ErrScheduledApplyStarted) {
+		t.Errorf("second cancel: expected ErrScheduledApplyStarted, got %v", err)
+	}
+
+	// A new store over the same database sees the apply a previous process
+	// left running, and fails it.
+	second, err := store.Schedule(runAt, true, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started, err := store.Start(second.ID); err != nil || !started {
+		t.Fatalf("start: %v %v", started, err)
+	}
+	if started, _ := store.Start(second.ID); started {
+		t.Error("expected an apply to start only once")
+	}
+
+	restarted := NewScheduledApplyStore(db)
+	if n, err := restarted.FailInterrupted(); err != nil || n != 1 {
+		t.Fatalf("FailInterrupted = %d, %v", n, err)
+	}
+	got, err := restarted.Get(second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != ScheduledApplyFailed || len(got.Errors) != 1 || got.StartedAt == nil || got.FinishedAt == nil {
+		t.Errorf("unexpected interrupted apply %+v", got)
+	}
+
+	list, err := store.List(10)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("list: %v %d", err, len(list))
+	}
+}
@@ -1,8 +1,13 @@
 package schema
 
 import (
+	"database/sql"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
 )
 
 const baseTestSchema = `
@@ -125,6 +130,30 @@ collections:
 	}
 }
 
+func TestFieldOrder_Incomplete(t *testing.T) {
+	col := &Collection{
+		Name: "test",
+		Fields: map[string]*Field{
+			"id":    {Name: "id", Type: FieldTypeUUID, Primary: true},
+			"zeta":  {Name: "zeta", Type: FieldTypeString},
+			"alpha": {Name: "alpha", Type: FieldTypeString},
+			"beta":  {Name: "beta", Type: FieldTypeString},
+		},
+	}
+	col.SetFieldOrder([]string{"id", "zeta", "missing"})
+
+	expected := []string{"id", "zeta", "alpha", "beta"}
+	for i := 0; i < 10; i++ {
+		order := col.FieldOrder()
+		if strings.Join(order, ",") != strings.Join(expected, ",") {
+			t.Fatalf("expected %v, got %v", expected, order)
+		}
+		if fields := col.OrderedFields(); len(fields) != len(expected) || fields[2].Name != "alpha" {
+			t.Fatalf("expected OrderedFields to follow FieldOrder, got %d fields", len(fields))
+		}
+	}
+}
+
 func TestValidation_MissingPrimaryKey(t *testing.T) {
 	yaml := `
 version: 1
@@ -412,6 +441,22 @@ collections:
 	}
 }
 
+func TestChangedCollections(t *testing.T) {
+	changes := []*Change{
+		{Type: ChangeModifyRoles},
+		{Type: ChangeAddField, Collection: "posts", Field: "title"},
+		{Type: ChangeAddCollection, Collection: "comments"},
+		{Type: ChangeAddIndex, Collection: "posts"},
+	}
+	got := ChangedCollections(changes)
+	if len(got) != 2 || got[0] != "comments" || got[1] != "posts" {
+		t.Errorf("ChangedCollections() = %v, want [comments posts]", got)
+	}
+	if got := ChangedCollections(nil); got == nil || len(got) != 0 {
+		t.Errorf("ChangedCollections(nil) = %#v, want empty slice", got)
+	}
+}
+
 func TestDiffer_DropCollection(t *testing.T) {
 	oldYaml := `
 version: 1
@@ -607,3 +652,559 @@ collections:
 		t.Errorf("expected valid schema, got error: %v", err)
 	}
 }
+
+func TestRetentionPolicy(t *testing.T) {
+	base := `
+version: 1
+collections:
+  logs:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      message:
+        type: string
+      created_at:
+        type: timestamp
+        default: now
+    retention:
+`
+
+	tests := []struct {
+		name      string
+		retention string
+		wantErr   bool
+	}{
+		{"max age in days", "      field: created_at\n      max_age: 30d\n", false},
+		{"max age go duration", "      field: created_at\n      max_age: 12h\n", false},
+		{"max rows", "      field: created_at\n      max_rows: 1000\n", false},
+		{"missing field", "      max_age: 30d\n", true},
+		{"unknown field", "      field: updated_at\n      max_age: 30d\n", true},
+		{"non-timestamp field", "      field: message\n      max_age: 30d\n", true},
+		{"no limits", "      field: created_at\n", true},
+		{"invalid max age", "      field: created_at\n      max_age: forever\n", true},
+		{"negative max rows", "      field: created_at\n      max_rows: -1\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse([]byte(base + tt.retention))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected validation error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.Collections["logs"].Retention == nil {
+				t.Error("expected retention policy to be parsed")
+			}
+		})
+	}
+}
+
+func TestParseRetentionAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for input, want := range tests {
+		got, err := ParseRetentionAge(input)
+		if err != nil {
+			t.Errorf("ParseRetentionAge(%q) error: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseRetentionAge(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+const rolesTestSchema = `
+version: 1
+roles:
+  - editor
+  - moderator
+
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+`
+
+func TestParseRoles(t *testing.T) {
+	s, err := Parse([]byte(rolesTestSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	want := []string{"user", "admin", "editor", "moderator"}
+	got := s.AllRoles()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("AllRoles() = %v, want %v", got, want)
+	}
+
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if strings.Join(reparsed.Roles, ",") != "editor,moderator" {
+		t.Errorf("roles did not round-trip: %v", reparsed.Roles)
+	}
+}
+
+func TestParseRoles_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles string
+		want  string
+	}{
+		{"reserved", "[admin]", "built-in role"},
+		{"duplicate", "[editor, editor]", "more than once"},
+		{"invalid identifier", "[Editor]", "invalid identifier"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte("version: 1\nroles: " + tt.roles + "\n" + strings.TrimPrefix(baseTestSchema, "\nversion: 1\n")))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestDiffer_Roles(t *testing.T) {
+	old, _ := Parse([]byte(rolesTestSchema))
+	newSchema, _ := Parse([]byte(strings.Replace(rolesTestSchema, "  - moderator\n", "  - reviewer\n", 1)))
+
+	changes := NewDiffer().Diff(old, newSchema)
+	if len(changes) != 1 || changes[0].Type != ChangeModifyRoles {
+		t.Fatalf("expected a single roles change, got %v", changes)
+	}
+	if !changes[0].Safe {
+		t.Error("role changes should be safe")
+	}
+	if strings.Join(changes[0].RemovedRoles, ",") != "moderator" {
+		t.Errorf("expected moderator to be removed, got %v", changes[0].RemovedRoles)
+	}
+	if !strings.Contains(changes[0].String(), "add reviewer") {
+		t.Errorf("unexpected description %q", changes[0].String())
+	}
+
+	if changes := NewDiffer().Diff(old, old); len(changes) != 0 {
+		t.Errorf("expected no changes for identical roles, got %v", changes)
+	}
+}
+
+func TestMigrator_ValidateRoleChanges(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "roles.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE _alyx_users (id TEXT PRIMARY KEY, role TEXT NOT NULL DEFAULT 'user')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO _alyx_users (id, role) VALUES ('a', 'moderator'), ('b', 'moderator'), ('c', 'editor')`); err != nil {
+		t.Fatal(err)
+	}
+
+	old, _ := Parse([]byte(rolesTestSchema))
+	newSchema, _ := Parse([]byte(strings.Replace(rolesTestSchema, "  - moderator\n", "", 1)))
+	changes := NewDiffer().Diff(old, newSchema)
+
+	migrator := NewMigrator(db, "", "")
+	errs := migrator.ValidateRoleChanges(changes)
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, `"moderator"`) || !strings.Contains(errs[0].Message, "2 user(s)") {
+		t.Fatalf("expected removal of moderator to be rejected with user count, got %v", errs)
+	}
+
+	if err := migrator.ApplySafeChanges(changes, newSchema); err == nil {
+		t.Error("expected ApplySafeChanges to reject removing an assigned role")
+	}
+
+	if _, err := db.Exec(`UPDATE _alyx_users SET role = 'user' WHERE role = 'moderator'`); err != nil {
+		t.Fatal(err)
+	}
+	if errs := migrator.ValidateRoleChanges(changes); len(errs) != 0 {
+		t.Errorf("expected removal to be allowed once unassigned, got %v", errs)
+	}
+}
+
+const userMetadataTestSchema = `
+version: 1
+userMetadata:
+  additionalProperties: false
+  required: [display_name]
+  properties:
+    display_name:
+      type: string
+      maxLength: 10
+    theme:
+      type: string
+      enum: [light, dark]
+    age:
+      type: integer
+      minimum: 0
+    address:
+      type: object
+      properties:
+        city: { type: string }
+collections:
+  items:
+    fields:
+      id:
+        type: uuid
+        primary: true
+`
+
+func TestUserMetadata_Validate(t *testing.T) {
+	s, err := Parse([]byte(userMetadataTestSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if s.UserMetadata == nil || len(s.UserMetadata.Properties) != 4 {
+		t.Fatalf("expected userMetadata with 4 properties, got %+v", s.UserMetadata)
+	}
+
+	valid := map[string]any{"display_name": "Ada", "theme": "dark", "age": float64(36), "address": map[string]any{"city": "London", "zip": "N1"}}
+	if errs := s.UserMetadata.Validate(valid); len(errs) != 0 {
+		t.Errorf("expected valid metadata, got %v", errs)
+	}
+
+	invalid := map[string]any{"theme": "blue", "age": 1.5, "nickname": "x", "address": "London"}
+	errs := s.UserMetadata.Validate(invalid)
+	want := map[string]string{
+		"metadata.display_name": "is required",
+		"metadata.theme":        "must be one of: light, dark",
+		"metadata.age":          "must be an integer",
+		"metadata.nickname":     "is not a declared metadata key",
+		"metadata.address":      "must be an object",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for _, e := range errs {
+		if want[e.Path] != e.Message {
+			t.Errorf("unexpected error %s: %s", e.Path, e.Message)
+		}
+	}
+}
+
+func TestUserMetadata_InvalidDeclaration(t *testing.T) {
+	yaml := `
+version: 1
+userMetadata:
+  type: string
+  properties:
+    count: { type: counter }
+collections:
+  items:
+    fields:
+      id:
+        type: uuid
+        primary: true
+`
+	_, err := Parse([]byte(yaml))
+	if err == nil {
+		t.Fatal("expected invalid userMetadata to be rejected")
+	}
+	for _, want := range []string{"userMetadata.type: must be object", `userMetadata.properties.count.type: unknown type "counter"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error, got %v", want, err)
+		}
+	}
+}
+
+func TestFunctionIO(t *testing.T) {
+	yaml := `
+version: 1
+collections:
+  items:
+    fields:
+      id:
+        type: uuid
+        primary: true
+functions:
+  process_user:
+    runtime: node
+    entrypoint: index.js
+    input:
+      additionalProperties: false
+      required: [user_id]
+      properties:
+        user_id: { type: string }
+        notify: { type: boolean }
+    output:
+      type: array
+      items: { type: string }
+`
+	s, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	fn := s.Functions["process_user"]
+	if fn.TypeName() != "ProcessUser" || !fn.HasTypes() {
+		t.Errorf("unexpected type name %q", fn.TypeName())
+	}
+	if fn.Output.ValueType() != MetadataTypeArray {
+		t.Errorf("expected array output, got %q", fn.Output.ValueType())
+	}
+
+	errs := fn.Input.ValidateInput(map[string]any{"notify": "yes", "extra": 1.0})
+	want := map[string]string{
+		"input.user_id": "is required",
+		"input.notify":  "must be a boolean",
+		"input.extra":   "is not a declared input key",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for _, e := range errs {
+		if want[e.Path] != e.Message {
+			t.Errorf("unexpected error %s: %s", e.Path, e.Message)
+		}
+	}
+
+	invalid := `
+version: 1
+collections:
+  items:
+    fields:
+      id:
+        type: uuid
+        primary: true
+functions:
+  process_user:
+    runtime: node
+    entrypoint: index.js
+    input: { type: string }
+    output: { properties: { ok: { type: flag } } }
+`
+	_, err = Parse([]byte(invalid))
+	if err == nil {
+		t.Fatal("expected invalid function input/output to be rejected")
+	}
+	for _, want := range []string{"functions.process_user.input.type: must be object", `functions.process_user.output.properties.ok.type: unknown type "flag"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error, got %v", want, err)
+		}
+	}
+}
+
+func TestOnUserDelete(t *testing.T) {
+	yaml := `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+      author_id:
+        type: uuid
+        onUserDelete: cascade
+  comments:
+    fields:
+      id:
+        type: uuid
+        primary: true
+      author_id:
+        type: uuid
+        nullable: true
+        onUserDelete: set_null
+      editor_id:
+        type: uuid
+        nullable: true
+        onUserDelete: keep
+`
+	s, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	refs := s.UserReferences()
+	want := []UserReference{
+		{Collection: "comments", Field: "author_id", Action: UserDeleteSetNull},
+		{Collection: "posts", Field: "author_id", Action: UserDeleteCascade},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("expected %d references, got %+v", len(want), refs)
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("reference %d: expected %+v, got %+v", i, want[i], refs[i])
+		}
+	}
+
+	invalid := `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+      author_id:
+        type: uuid
+        onUserDelete: set_null
+      views:
+        type: int
+        onUserDelete: cascade
+`
+	_, err = Parse([]byte(invalid))
+	if err == nil {
+		t.Fatal("expected invalid onUserDelete to be rejected")
+	}
+	for _, want := range []string{"author_id.onUserDelete", "views.onUserDelete"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error, got %v", want, err)
+		}
+	}
+}
+
+func TestInferFromDB_LegacyCache(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "infer.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// A cache from before collection_json existed.
+	if _, err := db.Exec(`CREATE TABLE _alyx_schema_cache (collection TEXT PRIMARY KEY, rules_json TEXT, updated_at TEXT NOT NULL DEFAULT (datetime('now')))`); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Parse([]byte(`
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      status:
+        type: select
+        select:
+          values: [draft, published]
+        default: draft
+      title:
+        type: string
+        validate:
+          minLength: 3
+    rules:
+      read: "true"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator := NewMigrator(db, "", "")
+	if err := migrator.Init(); err != nil {
+		t.Fatalf("init should add collection_json to a legacy cache: %v", err)
+	}
+	for _, stmt := range NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inferred, err := InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inferred.Collections["posts"].Fields["status"].Type != FieldTypeString {
+		t.Errorf("expected uncached field to fall back to its column affinity")
+	}
+	var changes []string
+	for _, c := range NewDiffer().Diff(inferred, s) {
+		if c.Type != ChangeModifyRules {
+			changes = append(changes, c.String())
+		}
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected only the uncached rules to differ, got %v", changes)
+	}
+
+	if err := migrator.EnsureRulesCacheSeeded(s); err != nil {
+		t.Fatal(err)
+	}
+	inferred, err = InferFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := NewDiffer().Diff(inferred, s); len(changes) != 0 {
+		t.Errorf("expected no changes after seeding, got %v", changes)
+	}
+	status := inferred.Collections["posts"].Fields["status"]
+	if status.Type != FieldTypeSelect || status.Select == nil || status.Default != "draft" {
+		t.Errorf("expected cached select config, got %+v", status)
+	}
+	if v := inferred.Collections["posts"].Fields["title"].Validate; v == nil || v.MinLength == nil || *v.MinLength != 3 {
+		t.Errorf("expected cached validation, got %+v", v)
+	}
+}
+
+func TestSchemaSnapshot(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "snapshot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator := NewMigrator(db, "", "")
+	if err := migrator.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot, err := migrator.LoadSchemaSnapshot(); err != nil || snapshot != nil {
+		t.Fatalf("expected no snapshot before the first apply, got %v, %v", snapshot, err)
+	}
+
+	s, err := Parse([]byte(`
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+    rules:
+      read: "true"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().Add(-time.Second)
+	if err := migrator.ApplySchema(s); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := migrator.LoadSchemaSnapshot()
+	if err != nil || snapshot == nil {
+		t.Fatalf("expected a snapshot after applying, got %v, %v", snapshot, err)
+	}
+	if snapshot.UpdatedAt.Before(before) {
+		t.Errorf("unexpected snapshot time %v", snapshot.UpdatedAt)
+	}
+	if changes := NewDiffer().Diff(snapshot.Schema, s); len(changes) != 0 {
+		t.Errorf("expected the snapshot to match the applied schema, got %v", changes)
+	}
+	if !strings.Contains(string(snapshot.YAML), "title:") {
+		t.Errorf("expected canonical YAML, got:\n%s", snapshot.YAML)
+	}
+}
@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SlugPattern is the format of a valid slug: lowercase letters and digits in
+// runs separated by single hyphens.
+const SlugPattern = `^[a-z0-9]+(?:-[a-z0-9]+)*$`
+
+// RegenerateSlugKey is the update body key that regenerates a document's
+// slug fields from their source fields.
+const RegenerateSlugKey = "regenerate_slug"
+
+var slugRegex = regexp.MustCompile(SlugPattern)
+
+// IsValidSlug reports whether s matches SlugPattern.
+func IsValidSlug(s string) bool {
+	return slugRegex.MatchString(s)
+}
+
+// slugLetters transliterates letters that don't decompose into an ASCII base
+// letter and combining marks.
+var slugLetters = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d",
+	'þ': "th", 'ł': "l", 'ħ': "h", 'ı': "i", 'ŀ': "l", 'ŋ': "n",
+}
+
+// Slugify converts s to a URL-safe slug of at most maxLen bytes: accents are
+// stripped, letters are lowercased, and every other run of characters
+// becomes a single hyphen. Characters with no ASCII equivalent are dropped,
+// so the result may be empty.
+func Slugify(s string, maxLen int) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		var part string
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			part = string(r)
+		case slugLetters[r] != "":
+			part = slugLetters[r]
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			continue
+		default:
+			hyphen = b.Len() > 0
+			continue
+		}
+		if hyphen {
+			b.WriteByte('-')
+			hyphen = false
+		}
+		b.WriteString(part)
+	}
+	return truncateSlug(b.String(), maxLen)
+}
+
+// SlugWithSuffix returns base with "-n" appended, truncating base so the
+// result fits in maxLen bytes.
+func SlugWithSuffix(base string, n, maxLen int) string {
+	suffix := "-" + strconv.Itoa(n)
+	if maxLen > 0 && len(base)+len(suffix) > maxLen {
+		base = truncateSlug(base, maxLen-len(suffix))
+	}
+	if base == "" {
+		return strconv.Itoa(n)
+	}
+	return base + suffix
+}
+
+func truncateSlug(s string, maxLen int) string {
+	if maxLen <= 0 || len(s) <= maxLen {
+		return s
+	}
+	return strings.TrimRight(s[:maxLen], "-")
+}
@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in     string
+		maxLen int
+		want   string
+	}{
+		{"Hello, World!", 80, "hello-world"},
+		{"  Déjà vu -- Ångström  ", 80, "deja-vu-angstrom"},
+		{"Straße & Œuvre", 80, "strasse-oeuvre"},
+		{"Go 1.24 released", 80, "go-1-24-released"},
+		{"日本語", 80, ""},
+		{"a very long title indeed", 10, "a-very-lon"},
+		{"trailing cut here", 9, "trailing"},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.in, tt.maxLen); got != tt.want {
+			t.Errorf("Slugify(%q, %d) = %q, want %q", tt.in, tt.maxLen, got, tt.want)
+		}
+		if got := Slugify(tt.in, tt.maxLen); got != "" && !IsValidSlug(got) {
+			t.Errorf("Slugify(%q) = %q is not a valid slug", tt.in, got)
+		}
+	}
+}
+
+func TestSlugWithSuffix(t *testing.T) {
+	if got := SlugWithSuffix("hello-world", 2, 80); got != "hello-world-2" {
+		t.Errorf("got %q", got)
+	}
+	if got := SlugWithSuffix("hello-world", 12, 10); got != "hello-w-12" {
+		t.Errorf("got %q", got)
+	}
+	if got := SlugWithSuffix("hello-world", 3, 8); got != "hello-3" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParseSlugField(t *testing.T) {
+	valid := `
+version: 1
+collections:
+  posts:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      title: { type: string }
+      slug: { type: string, unique: true, slug: { from: title } }
+`
+	s, err := Parse([]byte(valid))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	f := s.Collections["posts"].Fields["slug"]
+	if !f.IsSlug() || f.Slug.Max() != DefaultSlugMaxLength || !s.Collections["posts"].HasSlugFields() {
+		t.Errorf("slug config = %+v", f.Slug)
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		want  string
+	}{
+		{"not unique", "{ type: string, slug: { from: title } }", "slug fields must be unique"},
+		{"missing source", "{ type: string, unique: true, slug: { from: body } }", `field "body" does not exist`},
+		{"non-string source", "{ type: string, unique: true, slug: { from: views } }", "must be a string or text field"},
+		{"self source", "{ type: string, unique: true, slug: { from: slug } }", "cannot be derived from itself"},
+		{"wrong type", "{ type: int, unique: true, slug: { from: title } }", "only be used with string field type"},
+		{"too long", "{ type: string, unique: true, maxLength: 40, slug: { from: title } }", "must not exceed the field's maxLength (40)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml := `
+version: 1
+collections:
+  posts:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      title: { type: string }
+      views: { type: int }
+      slug: ` + tt.field + "\n"
+			_, err := Parse([]byte(yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
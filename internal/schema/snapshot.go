@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// schemaSnapshotKey is the _alyx_schema_cache row holding the whole applied
+// schema. Collection names starting with _alyx are reserved, so it never
+// clashes with a collection's row.
+const schemaSnapshotKey = "_alyx_schema"
+
+// SaveSchemaSnapshot records schema, as canonical YAML, as the schema last
+// applied to the database. A server can boot from it when the schema file is
+// missing or older.
+func (m *Migrator) SaveSchemaSnapshot(schema *Schema) error {
+	data, err := Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("marshaling schema: %w", err)
+	}
+
+	_, err = m.db.Exec(`
+		INSERT INTO _alyx_schema_cache (collection, schema_yaml, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(collection) DO UPDATE SET
+			schema_yaml = excluded.schema_yaml,
+			updated_at = excluded.updated_at
+	`, schemaSnapshotKey, string(data), time.Now().UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("saving schema snapshot: %w", err)
+	}
+	return nil
+}
+
+// SchemaSnapshot is the schema last applied to the database.
+type SchemaSnapshot struct {
+	// YAML is the canonical YAML of the schema.
+	YAML      []byte
+	Schema    *Schema
+	UpdatedAt time.Time
+}
+
+// LoadSchemaSnapshot returns the schema last applied to the database, or nil
+// if none was recorded.
+func (m *Migrator) LoadSchemaSnapshot() (*SchemaSnapshot, error) {
+	var data sql.NullString
+	var updatedAt string
+	err := m.db.QueryRow(`
+		SELECT schema_yaml, updated_at FROM _alyx_schema_cache WHERE collection = ?
+	`, schemaSnapshotKey).Scan(&data, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !data.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading schema snapshot: %w", err)
+	}
+
+	parsed, err := Parse([]byte(data.String))
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema snapshot: %w", err)
+	}
+	snapshot := &SchemaSnapshot{YAML: []byte(data.String), Schema: parsed}
+	snapshot.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAt)
+	return snapshot, nil
+}
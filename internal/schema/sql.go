@@ -21,11 +21,24 @@ func (g *SQLGenerator) GenerateAll() []string {
 		statements = append(statements, g.GenerateCreateTable(col))
 		statements = append(statements, g.GenerateIndexes(col)...)
 		statements = append(statements, g.GenerateTriggers(col)...)
+		if col.History != nil {
+			statements = append(statements, g.GenerateHistoryTable(col)...)
+		}
+	}
+
+	for _, name := range sortedNames(g.schema.Views) {
+		statements = append(statements, g.GenerateCreateView(g.schema.Views[name]))
 	}
 
 	return statements
 }
 
+// GenerateCreateView creates a view's backing table with the columns of its
+// query and no rows. The view is filled by its first refresh.
+func (g *SQLGenerator) GenerateCreateView(v *View) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS SELECT * FROM (\n%s\n) WHERE 0", v.Table(), v.Query)
+}
+
 func (g *SQLGenerator) GenerateCreateTable(col *Collection) string {
 	var sb strings.Builder
 
@@ -60,6 +73,12 @@ func (g *SQLGenerator) generateColumnDef(f *Field) string {
 	parts = append(parts, f.Name)
 	parts = append(parts, f.Type.SQLiteType())
 
+	// Indexes on the column, including the one behind UNIQUE, inherit its
+	// collation.
+	if collate := f.Collate.SQL(); collate != "" {
+		parts = append(parts, collate)
+	}
+
 	if f.Primary {
 		parts = append(parts, "PRIMARY KEY")
 	}
@@ -109,6 +128,13 @@ func (g *SQLGenerator) GenerateIndexes(col *Collection) []string {
 	return indexes
 }
 
+// GenerateHistoryTable creates a collection's history table and its indexes.
+// History tables have no change triggers: they are not subscribable.
+func (g *SQLGenerator) GenerateHistoryTable(col *Collection) []string {
+	hist := col.HistoryCollection()
+	return append([]string{g.GenerateCreateTable(hist)}, g.GenerateIndexes(hist)...)
+}
+
 func (g *SQLGenerator) GenerateTriggers(col *Collection) []string {
 	var triggers []string
 
@@ -169,6 +195,10 @@ BEGIN
 END`, col.Name, col.Name, col.Name, strings.Join(autoUpdateFields, ", "), pk.Name, pk.Name))
 	}
 
+	for _, counter := range col.Counters {
+		triggers = append(triggers, g.GenerateCounterTriggers(col, counter)...)
+	}
+
 	return triggers
 }
 
@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTenant(t *testing.T) {
+	valid := `
+version: 1
+collections:
+  projects:
+    tenant: { field: org_id, source: auth.metadata.org_id }
+    fields:
+      id: { type: id, primary: true, default: auto }
+      org_id: { type: string, index: true }
+`
+	s, err := Parse([]byte(valid))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	tenant := s.Collections["projects"].Tenant
+	if tenant == nil || tenant.Field != "org_id" || tenant.Source != "auth.metadata.org_id" {
+		t.Fatalf("tenant = %+v", tenant)
+	}
+
+	tests := []struct {
+		name    string
+		tenant  string
+		indexes string
+		want    string
+	}{
+		{"missing field", "{ field: team_id, source: auth.metadata.org_id }", "", `field "team_id" does not exist`},
+		{"unindexed field", "{ field: name, source: auth.metadata.org_id }", "", `field "name" must be indexed`},
+		{"composite index", "{ field: name, source: auth.id }", "    indexes:\n      - { name: idx_projects_name, fields: [name, org_id] }\n", ""},
+		{"primary key", "{ field: id, source: auth.id }", "", "primary key cannot be the tenant field"},
+		{"bad source", "{ field: org_id, source: metadata.org_id }", "", "must be an auth path"},
+		{"missing source", "{ field: org_id }", "", "tenant.source: required field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml := `
+version: 1
+collections:
+  projects:
+    tenant: ` + tt.tenant + `
+    fields:
+      id: { type: id, primary: true, default: auto }
+      org_id: { type: string, index: true }
+      name: { type: string }
+` + tt.indexes
+			_, err := Parse([]byte(yaml))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantConfigResolve(t *testing.T) {
+	tenant := &TenantConfig{Field: "org_id", Source: "auth.metadata.org_id"}
+
+	value, ok := tenant.Resolve(map[string]any{"metadata": map[string]any{"org_id": "acme"}})
+	if !ok || value != "acme" {
+		t.Errorf("Resolve = %v, %v", value, ok)
+	}
+	for _, authCtx := range []map[string]any{nil, {"id": "u1"}, {"metadata": map[string]any{"org_id": ""}}} {
+		if _, ok := tenant.Resolve(authCtx); ok {
+			t.Errorf("Resolve(%v) found a tenant", authCtx)
+		}
+	}
+
+	if !tenant.Owns(map[string]any{"org_id": int64(7)}, 7) {
+		t.Error("expected numeric tenant values to match across types")
+	}
+	if tenant.Owns(map[string]any{"org_id": nil}, "acme") || tenant.Owns(map[string]any{"org_id": "globex"}, "acme") {
+		t.Error("expected document of another tenant not to be owned")
+	}
+}
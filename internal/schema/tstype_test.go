@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTSType(t *testing.T) {
+	s, err := Parse([]byte(`
+version: 1
+collections:
+  posts:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      tags: { type: json, tsType: "Array<{name: string}>" }
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("parse marshaled schema: %v\n%s", err, data)
+	}
+	if got := reparsed.Collections["posts"].Fields["tags"].TSType; got != "Array<{name: string}>" {
+		t.Errorf("tsType after round trip = %q:\n%s", got, data)
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		want  string
+	}{
+		{"string field", `{ type: string, tsType: "'a' | 'b'" }`, "only be used with json"},
+		{"blank", `{ type: json, tsType: " " }`, "single TypeScript type expression"},
+		{"statement", `{ type: json, tsType: "string; export const x = 1" }`, "single TypeScript type expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(`
+version: 1
+collections:
+  posts:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      extra: ` + tt.field + `
+`))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ package schema
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type FieldType string
@@ -37,6 +40,12 @@ func (t FieldType) IsValid() bool {
 	return false
 }
 
+// IsSortable reports whether lists can be sorted by fields of this type.
+// JSON and blob values have no meaningful order.
+func (t FieldType) IsSortable() bool {
+	return t != FieldTypeJSON && t != FieldTypeBlob
+}
+
 func (t FieldType) SQLiteType() string {
 	switch t {
 	case FieldTypeID, FieldTypeUUID, FieldTypeString, FieldTypeText, FieldTypeRichText, FieldTypeTimestamp,
@@ -167,6 +176,52 @@ func (a OnDeleteAction) SQL() string {
 	}
 }
 
+// UserDeleteAction is what happens to a document when the user whose ID
+// a field holds is deleted.
+// Collation is how a text field's values are compared, in equality
+// filters, unique constraints and indexes.
+type Collation string
+
+const (
+	CollationBinary Collation = "binary"
+	// CollationNocase ignores the case of ASCII letters, so Foo and foo are
+	// equal. Other characters are compared exactly.
+	CollationNocase Collation = "nocase"
+)
+
+func (c Collation) IsValid() bool {
+	switch c {
+	case CollationBinary, CollationNocase, "":
+		return true
+	default:
+		return false
+	}
+}
+
+// SQL returns the COLLATE clause for the collation, or "" for the default.
+func (c Collation) SQL() string {
+	if c == CollationNocase {
+		return "COLLATE NOCASE"
+	}
+	return ""
+}
+
+type UserDeleteAction string
+
+const (
+	UserDeleteKeep    UserDeleteAction = "keep"
+	UserDeleteCascade UserDeleteAction = "cascade"
+	UserDeleteSetNull UserDeleteAction = "set_null"
+)
+
+func (a UserDeleteAction) IsValid() bool {
+	switch a {
+	case UserDeleteKeep, UserDeleteCascade, UserDeleteSetNull, "":
+		return true
+	}
+	return false
+}
+
 type DefaultValue string
 
 const (
@@ -174,34 +229,412 @@ const (
 	DefaultNow  DefaultValue = "now"
 )
 
+// Built-in roles. Every schema has them; custom roles declared in the schema's
+// roles section may not reuse these names.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type Schema struct {
 	Version     int                    `yaml:"version"`
+	Roles       []string               `yaml:"roles,omitempty"`
 	Collections map[string]*Collection `yaml:"collections"`
 	Buckets     map[string]*Bucket     `yaml:"buckets"`
 	Functions   map[string]*Function   `yaml:"functions,omitempty"`
+	// Views are the schema's materialized views.
+	Views map[string]*View `yaml:"views,omitempty"`
+	// Presets are the field presets declared in the schema. Collections
+	// also have the built-in ones; see BuiltinPresets.
+	Presets map[string]*FieldPreset `yaml:"presets,omitempty"`
+
+	// UserMetadata declares the shape of user metadata. When nil, metadata
+	// is an arbitrary JSON object.
+	UserMetadata *MetadataSchema `yaml:"userMetadata,omitempty"`
+}
+
+// AllRoles returns the built-in roles followed by the schema's custom roles.
+func (s *Schema) AllRoles() []string {
+	roles := []string{RoleUser, RoleAdmin}
+	if s == nil {
+		return roles
+	}
+	return append(roles, s.Roles...)
+}
+
+// UserReference is a field holding a user ID, with what to do with its
+// documents when that user is deleted.
+type UserReference struct {
+	Collection string
+	Field      string
+	Action     UserDeleteAction
+}
+
+// UserReferences returns the fields that declare onUserDelete as cascade or
+// set_null, ordered by collection and field.
+func (s *Schema) UserReferences() []UserReference {
+	if s == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(s.Collections))
+	for name := range s.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var refs []UserReference
+	for _, name := range names {
+		for _, f := range s.Collections[name].OrderedFields() {
+			if f.OnUserDelete == UserDeleteCascade || f.OnUserDelete == UserDeleteSetNull {
+				refs = append(refs, UserReference{Collection: name, Field: f.Name, Action: f.OnUserDelete})
+			}
+		}
+	}
+	return refs
+}
+
+// HasEncryptedFields reports whether any collection declares an encrypted
+// field, which requires security.field_encryption_key to be set.
+func (s *Schema) HasEncryptedFields() bool {
+	if s == nil {
+		return false
+	}
+	for _, col := range s.Collections {
+		if len(col.EncryptedFields()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// TenantMetadataKeys returns the top-level user metadata keys that tenant
+// sources read, sorted. Users must not set these themselves, or they could
+// move themselves into another tenant.
+func (s *Schema) TenantMetadataKeys() []string {
+	if s == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var keys []string
+	for _, col := range s.Collections {
+		if col.Tenant == nil {
+			continue
+		}
+		if path := col.Tenant.SourcePath(); len(path) > 1 && path[0] == "metadata" && !seen[path[1]] {
+			seen[path[1]] = true
+			keys = append(keys, path[1])
+		}
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 type Collection struct {
-	Name    string            `yaml:"-"`
-	Fields  map[string]*Field `yaml:"fields"`
-	Indexes []*Index          `yaml:"indexes"`
-	Rules   *Rules            `yaml:"rules"`
+	Name      string            `yaml:"-"`
+	Fields    map[string]*Field `yaml:"fields"`
+	Indexes   []*Index          `yaml:"indexes"`
+	Rules     *Rules            `yaml:"rules"`
+	Retention *RetentionPolicy  `yaml:"retention"`
+	Docs      *CollectionDocs   `yaml:"docs"`
+	Tenant    *TenantConfig     `yaml:"tenant"`
+	History   *HistoryConfig    `yaml:"history"`
+	Checks    []*Check          `yaml:"checks"`
+	List      *ListConfig       `yaml:"list"`
+	// Counters keep count fields of other collections in sync with this
+	// collection's documents.
+	Counters []*Counter `yaml:"counters"`
+	// Expose, when false, keeps the collection out of the REST API, the
+	// OpenAPI spec, the generated SDKs and non-admin realtime subscriptions.
+	// Functions and the admin API can still use it. Nil means exposed.
+	Expose *bool `yaml:"expose"`
+	// Use names the presets whose fields the collection includes. Fields
+	// holds them expanded.
+	Use []string `yaml:"use"`
 
 	fieldOrder []string
+	// presetFields records the fields expanded from presets, by name.
+	presetFields map[string]*presetField
+}
+
+// Collection API operations, as named in a docs block.
+const (
+	OperationList   = "list"
+	OperationGet    = "get"
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// CollectionOperations lists the operation names a docs block may override.
+var CollectionOperations = []string{OperationList, OperationGet, OperationCreate, OperationUpdate, OperationDelete}
+
+// CollectionDocs customizes the generated API reference for a collection.
+// Examples are named example documents, each mapping field names to values.
+type CollectionDocs struct {
+	Description string                    `yaml:"description,omitempty" json:"description,omitempty"`
+	Operations  map[string]*OperationDocs `yaml:"operations,omitempty" json:"operations,omitempty"`
+	Examples    map[string]map[string]any `yaml:"examples,omitempty" json:"examples,omitempty"`
+}
+
+// Operation returns the overrides for the named operation, or nil.
+func (d *CollectionDocs) Operation(name string) *OperationDocs {
+	if d == nil {
+		return nil
+	}
+	return d.Operations[name]
+}
+
+// OperationDocs overrides the generated documentation for one operation.
+type OperationDocs struct {
+	Summary     string `yaml:"summary,omitempty" json:"summary,omitempty"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Deprecated  bool   `yaml:"deprecated,omitempty" json:"deprecated,omitempty"`
+}
+
+// TenantConfig scopes a collection to tenants: every document belongs to
+// the tenant in Field, and requests only see and write the documents of the
+// tenant that Source names in the auth context, e.g. auth.metadata.org_id.
+type TenantConfig struct {
+	Field  string `yaml:"field" json:"field"`
+	Source string `yaml:"source" json:"source"`
+}
+
+// SourcePath returns the keys Source selects below auth, so
+// "auth.metadata.org_id" gives ["metadata", "org_id"].
+func (t *TenantConfig) SourcePath() []string {
+	parts := strings.Split(t.Source, ".")
+	if len(parts) < 2 || parts[0] != "auth" {
+		return nil
+	}
+	return parts[1:]
+}
+
+// Resolve returns the tenant that Source names in an auth context. ok is
+// false if the value is missing, null or an empty string.
+func (t *TenantConfig) Resolve(authCtx map[string]any) (any, bool) {
+	path := t.SourcePath()
+	if len(path) == 0 {
+		return nil, false
+	}
+	var value any = authCtx
+	for _, key := range path {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		value = m[key]
+	}
+	if value == nil || value == "" {
+		return nil, false
+	}
+	return value, true
+}
+
+// Owns reports whether doc belongs to tenant.
+func (t *TenantConfig) Owns(doc map[string]any, tenant any) bool {
+	value, ok := doc[t.Field]
+	return ok && value != nil && fmt.Sprint(value) == fmt.Sprint(tenant)
+}
+
+// MaxListLimit is the largest page a list request may return.
+const MaxListLimit = 1000
+
+// DefaultListLimit is the page size of a list request without a limit.
+const DefaultListLimit = 100
+
+// ListConfig sets the defaults of a collection's list endpoint.
+type ListConfig struct {
+	// DefaultSort applies when a request has no sort, e.g. "-created_at" or
+	// "status,-published_at:nullslast".
+	DefaultSort string `yaml:"defaultSort,omitempty" json:"defaultSort,omitempty"`
+	// DefaultLimit is the page size when a request has no limit.
+	DefaultLimit int `yaml:"defaultLimit,omitempty" json:"defaultLimit,omitempty"`
+	// MaxLimit caps the page size; larger limits are clamped.
+	MaxLimit int `yaml:"maxLimit,omitempty" json:"maxLimit,omitempty"`
+}
+
+// Limits returns the default and maximum page size of a list request, applying
+// the global defaults when c is nil or leaves them unset.
+func (c *ListConfig) Limits() (defaultLimit, maxLimit int) {
+	defaultLimit, maxLimit = DefaultListLimit, MaxListLimit
+	if c == nil {
+		return defaultLimit, maxLimit
+	}
+	if c.MaxLimit > 0 {
+		maxLimit = min(c.MaxLimit, MaxListLimit)
+	}
+	if c.DefaultLimit > 0 {
+		defaultLimit = c.DefaultLimit
+	}
+	return min(defaultLimit, maxLimit), maxLimit
+}
+
+// SortFields returns the field names in DefaultSort.
+func (c *ListConfig) SortFields() []string {
+	if c == nil || c.DefaultSort == "" {
+		return nil
+	}
+	var fields []string
+	for _, part := range strings.Split(c.DefaultSort, ",") {
+		part, _, _ = strings.Cut(strings.TrimSpace(part), ":")
+		part = strings.TrimPrefix(strings.TrimPrefix(part, "-"), "+")
+		fields = append(fields, part)
+	}
+	return fields
 }
 
+// SortModifiers returns the modifiers after the fields in DefaultSort, such
+// as "nullslast", with "" for fields that have none.
+func (c *ListConfig) SortModifiers() []string {
+	if c == nil || c.DefaultSort == "" {
+		return nil
+	}
+	var modifiers []string
+	for _, part := range strings.Split(c.DefaultSort, ",") {
+		_, modifier, _ := strings.Cut(strings.TrimSpace(part), ":")
+		modifiers = append(modifiers, modifier)
+	}
+	return modifiers
+}
+
+// RetentionPolicy defines how long rows in a collection are kept.
+// Rows are pruned when older than MaxAge or when the collection
+// exceeds MaxRows (oldest first), ordered by Field.
+type RetentionPolicy struct {
+	Field   string `yaml:"field,omitempty" json:"field"`
+	MaxAge  string `yaml:"max_age,omitempty" json:"max_age,omitempty"`
+	MaxRows int64  `yaml:"max_rows,omitempty" json:"max_rows,omitempty"`
+}
+
+// MaxAgeDuration returns the parsed MaxAge, or zero if unset or invalid.
+func (p *RetentionPolicy) MaxAgeDuration() time.Duration {
+	if p == nil || p.MaxAge == "" {
+		return 0
+	}
+	d, err := ParseRetentionAge(p.MaxAge)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// ParseRetentionAge parses a retention age such as "90d", "2w" or "36h".
+// Day and week suffixes are supported in addition to time.ParseDuration units.
+func ParseRetentionAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	if unit > 0 {
+		n, err := strconv.Atoi(strings.TrimSpace(s[:len(s)-1]))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		if n <= 0 {
+			return 0, fmt.Errorf("duration must be positive")
+		}
+		return time.Duration(n) * unit, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive")
+	}
+	return d, nil
+}
+
+// Exposed reports whether the collection is served by the REST API. It is
+// true unless the schema sets expose: false.
+func (c *Collection) Exposed() bool {
+	return c.Expose == nil || *c.Expose
+}
+
+// FieldOrder returns the collection's field names in declaration order.
+// Fields missing from the recorded order, such as those added in code, follow
+// in alphabetical order, so the result never depends on map iteration.
 func (c *Collection) FieldOrder() []string {
-	return c.fieldOrder
+	order := make([]string, 0, len(c.Fields))
+	seen := make(map[string]bool, len(c.fieldOrder))
+	for _, name := range c.fieldOrder {
+		if _, ok := c.Fields[name]; ok && !seen[name] {
+			order = append(order, name)
+			seen[name] = true
+		}
+	}
+	if len(order) == len(c.Fields) {
+		return order
+	}
+
+	rest := make([]string, 0, len(c.Fields)-len(order))
+	for name := range c.Fields {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(order, rest...)
 }
 
 func (c *Collection) SetFieldOrder(order []string) {
 	c.fieldOrder = order
 }
 
+// OrderedFields returns the collection's fields in FieldOrder.
 func (c *Collection) OrderedFields() []*Field {
-	fields := make([]*Field, 0, len(c.fieldOrder))
-	for _, name := range c.fieldOrder {
-		if f, ok := c.Fields[name]; ok {
+	order := c.FieldOrder()
+	fields := make([]*Field, 0, len(order))
+	for _, name := range order {
+		fields = append(fields, c.Fields[name])
+	}
+	return fields
+}
+
+// OptionalOnCreate reports whether f may be omitted when creating a
+// document: it is nullable, has a default, or is filled in by the server as
+// a slug or the tenant field.
+func (c *Collection) OptionalOnCreate(f *Field) bool {
+	return f.Nullable || f.HasDefault() || f.IsSlug() || (c.Tenant != nil && c.Tenant.Field == f.Name)
+}
+
+// HasSlugFields reports whether any field is a server-generated slug.
+func (c *Collection) HasSlugFields() bool {
+	for _, f := range c.Fields {
+		if f.Slug != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// EncryptedFields returns the fields stored encrypted, in FieldOrder.
+func (c *Collection) EncryptedFields() []*Field {
+	var fields []*Field
+	for _, f := range c.OrderedFields() {
+		if f.Encrypted {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// ReadRuleFields returns the fields with a read rule, in FieldOrder.
+func (c *Collection) ReadRuleFields() []*Field {
+	var fields []*Field
+	for _, f := range c.OrderedFields() {
+		if f.ReadRule != "" {
 			fields = append(fields, f)
 		}
 	}
@@ -226,25 +659,50 @@ type FileConfig struct {
 }
 
 type Field struct {
-	Name       string           `yaml:"-"`
-	Type       FieldType        `yaml:"type"`
-	Primary    bool             `yaml:"primary"`
-	Unique     bool             `yaml:"unique"`
-	Nullable   bool             `yaml:"nullable"`
-	Index      bool             `yaml:"index"`
-	Default    string           `yaml:"default"`
-	References string           `yaml:"references"`
-	OnDelete   OnDeleteAction   `yaml:"onDelete"`
-	OnUpdate   string           `yaml:"onUpdate"`
-	Internal   bool             `yaml:"internal"`
-	Validate   *FieldValidation `yaml:"validate"`
-	RichText   *RichTextConfig  `yaml:"richtext"`
-	Select     *SelectConfig    `yaml:"select"`
-	Relation   *RelationConfig  `yaml:"relation"`
-	File       *FileConfig      `yaml:"file"`
+	Name       string         `yaml:"-"`
+	Type       FieldType      `yaml:"type"`
+	Primary    bool           `yaml:"primary"`
+	Unique     bool           `yaml:"unique"`
+	Nullable   bool           `yaml:"nullable"`
+	Index      bool           `yaml:"index"`
+	Default    string         `yaml:"default"`
+	References string         `yaml:"references"`
+	OnDelete   OnDeleteAction `yaml:"onDelete"`
+	OnUpdate   string         `yaml:"onUpdate"`
+	Internal   bool           `yaml:"internal"`
+	// OnUserDelete marks the field as holding a user ID and sets what
+	// happens to the document when that user is deleted.
+	OnUserDelete UserDeleteAction `yaml:"onUserDelete"`
+	Validate     *FieldValidation `yaml:"validate"`
+	RichText     *RichTextConfig  `yaml:"richtext"`
+	Select       *SelectConfig    `yaml:"select"`
+	Relation     *RelationConfig  `yaml:"relation"`
+	File         *FileConfig      `yaml:"file"`
+	Slug         *SlugConfig      `yaml:"slug"`
+	// Storage names the bucket a blob field's content is stored in. Unset,
+	// the content is stored in the blob column itself.
+	Storage string `yaml:"storage"`
+	// Encrypted stores a string or text field's values encrypted with the
+	// configured field encryption key. Encrypted fields cannot be
+	// filtered, sorted or indexed.
+	Encrypted bool `yaml:"encrypted"`
+	// Collate sets how a string field's values are compared. With nocase,
+	// equality filters, lookups and unique constraints ignore case.
+	Collate Collation `yaml:"collate"`
+	// TSType overrides the TypeScript type generated for a json field. It
+	// is emitted verbatim, so it may only refer to built-in types.
+	TSType string `yaml:"tsType"`
+	// ReadRule is a CEL expression that must allow a caller to see the
+	// field. Documents the caller can read are returned without it
+	// otherwise.
+	ReadRule string `yaml:"readRule"`
 
 	MinLength *int `yaml:"minLength"`
 	MaxLength *int `yaml:"maxLength"`
+
+	// unconfigured marks a field read from the database with no cached
+	// configuration, so its default and onUpdate are unknown.
+	unconfigured bool
 }
 
 // SelectConfig defines options for select field type.
@@ -266,6 +724,35 @@ type RelationConfig struct {
 	DisplayName string         `yaml:"displayName"`
 }
 
+// SlugConfig makes a string field a server-generated slug, derived from
+// another field when a document is created without one.
+type SlugConfig struct {
+	From      string `yaml:"from"`
+	MaxLength int    `yaml:"maxLength,omitempty"`
+}
+
+// DefaultSlugMaxLength is the slug length limit when maxLength is unset.
+const DefaultSlugMaxLength = 80
+
+// Max returns the slug length limit.
+func (c *SlugConfig) Max() int {
+	if c == nil || c.MaxLength <= 0 {
+		return DefaultSlugMaxLength
+	}
+	return c.MaxLength
+}
+
+// IsSlug reports whether the field is a server-generated slug.
+func (f *Field) IsSlug() bool {
+	return f.Slug != nil
+}
+
+// CaseInsensitive reports whether the field's values are compared ignoring
+// case.
+func (f *Field) CaseInsensitive() bool {
+	return f.Collate == CollationNocase
+}
+
 func (f *Field) HasDefault() bool {
 	return f.Default != ""
 }
@@ -523,10 +1010,37 @@ type Rules struct {
 	Update   string `yaml:"update"`
 	Delete   string `yaml:"delete"`
 	Download string `yaml:"download"`
+	// Upload gates uploads to a bucket. Unset, the create rule applies.
+	Upload string `yaml:"upload,omitempty"`
+	// History gates a history-enabled collection's history endpoint.
+	// Unset, the read rule applies.
+	History string `yaml:"history,omitempty"`
 }
 
 func (r *Rules) HasRules() bool {
-	return r != nil && (r.Create != "" || r.Read != "" || r.Update != "" || r.Delete != "" || r.Download != "")
+	return r != nil && (r.Create != "" || r.Read != "" || r.Update != "" || r.Delete != "" || r.Download != "" || r.Upload != "" || r.History != "")
+}
+
+// UploadRule returns the upload rule, falling back to the create rule.
+func (r *Rules) UploadRule() string {
+	if r == nil {
+		return ""
+	}
+	if r.Upload != "" {
+		return r.Upload
+	}
+	return r.Create
+}
+
+// HistoryRule returns the history rule, falling back to the read rule.
+func (r *Rules) HistoryRule() string {
+	if r == nil {
+		return ""
+	}
+	if r.History != "" {
+		return r.History
+	}
+	return r.Read
 }
 
 type Bucket struct {
@@ -537,6 +1051,41 @@ type Bucket struct {
 	AllowedTypes []string `yaml:"allowed_types"`
 	Compression  bool     `yaml:"compression"`
 	Rules        *Rules   `yaml:"rules"`
+	// GC schedules removal of the bucket's files that no document
+	// references.
+	GC *BucketGC `yaml:"gc"`
+}
+
+// BucketGC schedules orphaned file cleanup for a bucket. Every Interval,
+// files older than GracePeriod that no file field references are deleted.
+type BucketGC struct {
+	Interval    string `yaml:"interval" json:"interval"`
+	GracePeriod string `yaml:"grace_period,omitempty" json:"grace_period,omitempty"`
+}
+
+// IntervalDuration returns the parsed Interval, or zero if unset or invalid.
+func (g *BucketGC) IntervalDuration() time.Duration {
+	if g == nil {
+		return 0
+	}
+	d, err := ParseRetentionAge(g.Interval)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// GracePeriodDuration returns the parsed GracePeriod, or zero if unset or
+// invalid.
+func (g *BucketGC) GracePeriodDuration() time.Duration {
+	if g == nil || g.GracePeriod == "" {
+		return 0
+	}
+	d, err := ParseRetentionAge(g.GracePeriod)
+	if err != nil {
+		return 0
+	}
+	return d
 }
 
 type BucketRules struct {
@@ -546,10 +1095,23 @@ type BucketRules struct {
 	Delete string `yaml:"delete"`
 }
 
+// Function execution modes.
+const (
+	// FunctionModeSubprocess runs the function's source with its runtime on
+	// each invocation.
+	FunctionModeSubprocess = "subprocess"
+	// FunctionModeNative compiles a go function once and runs the binary on
+	// each invocation.
+	FunctionModeNative = "native"
+)
+
 // Function represents a serverless function definition in schema.
 type Function struct {
-	Name         string             `yaml:"-"`
-	Runtime      string             `yaml:"runtime"`
+	Name    string `yaml:"-"`
+	Runtime string `yaml:"runtime"`
+	// Mode is how the function is run: subprocess (the default) or, for
+	// go functions, native.
+	Mode         string             `yaml:"mode,omitempty"`
 	Entrypoint   string             `yaml:"entrypoint"`
 	Path         string             `yaml:"path,omitempty"`
 	Description  string             `yaml:"description,omitempty"`
@@ -563,6 +1125,29 @@ type Function struct {
 	Routes       []FunctionRoute    `yaml:"routes,omitempty"`
 	Build        *FunctionBuild     `yaml:"build,omitempty"`
 	Rules        *FunctionRules     `yaml:"rules,omitempty"`
+	// Input and Output declare the function's payload types for the
+	// generated API docs and SDKs. Input is also checked on invocation.
+	Input  *MetadataSchema `yaml:"input,omitempty"`
+	Output *MetadataSchema `yaml:"output,omitempty"`
+}
+
+// TypeName returns the name used for the function's generated types, such as
+// "ProcessUser" for process_user.
+func (f *Function) TypeName() string {
+	var b strings.Builder
+	for _, part := range strings.Split(f.Name, "_") {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]))
+		b.WriteString(part[1:])
+	}
+	return b.String()
+}
+
+// HasTypes reports whether the function declares its input or output.
+func (f *Function) HasTypes() bool {
+	return f.Input != nil || f.Output != nil
 }
 
 // FunctionRules defines CEL-based access control for function invocation.
@@ -577,16 +1162,28 @@ type FunctionHook struct {
 	Action       string                       `yaml:"action,omitempty"`
 	Mode         string                       `yaml:"mode,omitempty"`
 	Verification *FunctionWebhookVerification `yaml:"verification,omitempty"`
+	// Fields limits a database hook's update events to updates that change
+	// at least one of these fields of the source collection. Insert and
+	// delete events ignore it.
+	Fields []string `yaml:"fields,omitempty"`
 }
 
 // FunctionSchedule represents a cron/interval/one_time schedule trigger.
 type FunctionSchedule struct {
-	Name       string                 `yaml:"name"`
-	Type       string                 `yaml:"type"`
-	Expression string                 `yaml:"expression,omitempty"`
-	Timezone   string                 `yaml:"timezone,omitempty"`
-	Config     map[string]interface{} `yaml:"config,omitempty"`
-	Input      map[string]interface{} `yaml:"input,omitempty"`
+	Name       string `yaml:"name"`
+	Type       string `yaml:"type"`
+	Expression string `yaml:"expression,omitempty"`
+	Timezone   string `yaml:"timezone,omitempty"`
+	// Overlap is skip (default), queue, or allow: what happens when the
+	// schedule comes due while its previous run is still going.
+	Overlap string `yaml:"overlap,omitempty"`
+	// Jitter delays each run by up to this duration, such as 30s.
+	Jitter string `yaml:"jitter,omitempty"`
+	// CatchUp is none, one, or all: which fire times missed while the server
+	// was down run when it starts again.
+	CatchUp string                 `yaml:"catch_up,omitempty"`
+	Config  map[string]interface{} `yaml:"config,omitempty"`
+	Input   map[string]interface{} `yaml:"input,omitempty"`
 }
 
 // FunctionRoute represents an HTTP route trigger.
@@ -603,9 +1200,24 @@ type FunctionBuild struct {
 	Output  string   `yaml:"output,omitempty"`
 }
 
-// FunctionWebhookVerification represents HMAC verification config.
+// Webhook verification types.
+const (
+	WebhookVerifyStripe     = "stripe"
+	WebhookVerifyGitHub     = "github"
+	WebhookVerifySlack      = "slack"
+	WebhookVerifyHMACSHA256 = "hmac-sha256"
+	WebhookVerifyHMACSHA1   = "hmac-sha1"
+	WebhookVerifyToken      = "token"
+)
+
+// FunctionWebhookVerification represents webhook signature verification
+// config. The stripe, github and slack presets implement each provider's
+// signing scheme and default the header; hmac-sha256, hmac-sha1 and token
+// need an explicit header. Tolerance bounds the age of timestamped
+// signatures (stripe, slack).
 type FunctionWebhookVerification struct {
-	Type   string `yaml:"type"`
-	Header string `yaml:"header"`
-	Secret string `yaml:"secret"`
+	Type      string `yaml:"type"`
+	Header    string `yaml:"header,omitempty"`
+	Secret    string `yaml:"secret"`
+	Tolerance string `yaml:"tolerance,omitempty"`
 }
@@ -0,0 +1,447 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// View refresh policies.
+const (
+	// ViewRefreshOnWrite refreshes a view shortly after a write to any
+	// collection its query reads.
+	ViewRefreshOnWrite = "on_write"
+	// ViewRefreshInterval refreshes a view every Interval.
+	ViewRefreshInterval = "interval"
+)
+
+// ViewTablePrefix starts the name of every view's backing table.
+const ViewTablePrefix = "_alyx_view_"
+
+// MinViewInterval is the shortest refresh interval a view may declare.
+const MinViewInterval = time.Second
+
+// Views are named with a stricter pattern than collections, since the name
+// is part of the backing table's name.
+var viewNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// View is a materialized view: the result of a SELECT over the schema's
+// collections, stored in a backing table and served read-only at
+// /api/views/{name}.
+type View struct {
+	Name        string `yaml:"-"`
+	Description string `yaml:"description,omitempty"`
+	// Query is a single SELECT (or WITH ... SELECT) statement. It may only
+	// read declared collections.
+	Query string `yaml:"query"`
+	// Refresh is on_write or interval. When empty it is interval if
+	// Interval is set and on_write otherwise.
+	Refresh string `yaml:"refresh,omitempty"`
+	// Interval is how often an interval view is refreshed, such as "5m".
+	Interval string     `yaml:"interval,omitempty"`
+	Rules    *ViewRules `yaml:"rules,omitempty"`
+}
+
+// ViewRules holds a view's access rules. Views are read-only, so only read
+// applies; doc is the view row.
+type ViewRules struct {
+	Read string `yaml:"read"`
+}
+
+// Table returns the name of the view's backing table.
+func (v *View) Table() string {
+	return ViewTablePrefix + v.Name
+}
+
+// TypeName returns the view name in PascalCase, for generated names.
+func (v *View) TypeName() string {
+	var b strings.Builder
+	for _, part := range strings.Split(v.Name, "_") {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]))
+		b.WriteString(part[1:])
+	}
+	return b.String()
+}
+
+// RefreshPolicy returns the view's refresh policy with the default applied.
+func (v *View) RefreshPolicy() string {
+	switch {
+	case v.Refresh != "":
+		return v.Refresh
+	case v.Interval != "":
+		return ViewRefreshInterval
+	default:
+		return ViewRefreshOnWrite
+	}
+}
+
+// RefreshInterval returns how often an interval view is refreshed, or zero
+// for an on_write view.
+func (v *View) RefreshInterval() time.Duration {
+	if v.RefreshPolicy() != ViewRefreshInterval {
+		return 0
+	}
+	d, err := time.ParseDuration(v.Interval)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// Collections returns the collections the view's query reads, sorted.
+func (v *View) Collections() []string {
+	tables, err := viewQueryTables(v.Query)
+	if err != nil {
+		return nil
+	}
+	return tables
+}
+
+// ViewsReading returns the views whose query reads collection, sorted by
+// name.
+func (s *Schema) ViewsReading(collection string) []*View {
+	if s == nil {
+		return nil
+	}
+	var views []*View
+	for _, name := range sortedNames(s.Views) {
+		v := s.Views[name]
+		for _, c := range v.Collections() {
+			if c == collection {
+				views = append(views, v)
+				break
+			}
+		}
+	}
+	return views
+}
+
+func parseViews(raw map[string]*View) map[string]*View {
+	views := make(map[string]*View, len(raw))
+	for name, v := range raw {
+		if v == nil {
+			v = &View{}
+		}
+		v.Name = name
+		v.Query = strings.TrimRight(strings.TrimSpace(v.Query), "; \t\r\n")
+		views[name] = v
+	}
+	return views
+}
+
+func validateView(name string, v *View, s *Schema) ValidationErrors {
+	var errs ValidationErrors
+	path := fmt.Sprintf("views.%s", name)
+
+	if !viewNameRegex.MatchString(name) {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: "name must start with lowercase letter and contain only lowercase letters, numbers, and underscores",
+		})
+	}
+	if strings.HasPrefix(name, "_alyx") {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: "view names starting with '_alyx' are reserved",
+		})
+	}
+
+	if v.Query == "" {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".query",
+			Message: "required field",
+		})
+	} else if tables, err := viewQueryTables(v.Query); err != nil {
+		errs = append(errs, &ValidationError{
+			Path:    path + ".query",
+			Message: err.Error(),
+		})
+	} else {
+		for _, table := range tables {
+			var msg string
+			switch {
+			case strings.HasPrefix(table, "_alyx") || strings.HasPrefix(table, "sqlite_"):
+				msg = fmt.Sprintf("cannot read internal table %q", table)
+			case s.Collections[table] == nil:
+				msg = fmt.Sprintf("reads %q, which is not a declared collection", table)
+			default:
+				continue
+			}
+			errs = append(errs, &ValidationError{Path: path + ".query", Message: msg})
+		}
+	}
+
+	switch v.Refresh {
+	case "", ViewRefreshInterval, ViewRefreshOnWrite:
+	default:
+		errs = append(errs, &ValidationError{
+			Path:    path + ".refresh",
+			Message: "must be one of: on_write, interval",
+		})
+	}
+
+	switch {
+	case v.Refresh == ViewRefreshOnWrite && v.Interval != "":
+		errs = append(errs, &ValidationError{
+			Path:    path + ".interval",
+			Message: "only applies to views refreshed on an interval",
+		})
+	case v.Refresh == ViewRefreshInterval && v.Interval == "":
+		errs = append(errs, &ValidationError{
+			Path:    path + ".interval",
+			Message: "required when refresh is interval",
+		})
+	case v.Interval != "":
+		d, err := time.ParseDuration(v.Interval)
+		if err != nil {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".interval",
+				Message: fmt.Sprintf("invalid duration %q", v.Interval),
+			})
+		} else if d < MinViewInterval {
+			errs = append(errs, &ValidationError{
+				Path:    path + ".interval",
+				Message: fmt.Sprintf("must be at least %s", MinViewInterval),
+			})
+		}
+	}
+
+	return errs
+}
+
+// viewToken is a token of a view query. Unquoted words are lowercased.
+type viewToken struct {
+	text   string
+	word   bool // an identifier or keyword
+	quoted bool // a quoted identifier
+}
+
+func (t viewToken) is(keyword string) bool {
+	return t.word && !t.quoted && t.text == keyword
+}
+
+// tokenizeViewQuery splits query into words, string literals and
+// punctuation, dropping whitespace and comments. String literals become a
+// single "'" token, so their contents are never mistaken for SQL.
+func tokenizeViewQuery(query string) ([]viewToken, error) {
+	var toks []viewToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closer := c
+			if c == '[' {
+				closer = ']'
+			}
+			j := i + 1
+			var sb strings.Builder
+			for {
+				if j >= len(query) {
+					return nil, fmt.Errorf("unterminated quote")
+				}
+				if query[j] == closer {
+					// A doubled quote is an escaped quote, except in [brackets].
+					if closer != ']' && j+1 < len(query) && query[j+1] == closer {
+						sb.WriteByte(closer)
+						j += 2
+						continue
+					}
+					break
+				}
+				sb.WriteByte(query[j])
+				j++
+			}
+			if c == '\'' {
+				toks = append(toks, viewToken{text: "'"})
+			} else {
+				toks = append(toks, viewToken{text: strings.ToLower(sb.String()), word: true, quoted: true})
+			}
+			i = j + 1
+		case isWordByte(c) && (c < '0' || c > '9'):
+			j := i
+			for j < len(query) && isWordByte(query[j]) {
+				j++
+			}
+			toks = append(toks, viewToken{text: strings.ToLower(query[i:j]), word: true})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(query) && (isWordByte(query[j]) || query[j] == '.') {
+				j++
+			}
+			toks = append(toks, viewToken{text: query[i:j]})
+			i = j
+		default:
+			toks = append(toks, viewToken{text: string(c)})
+			i++
+		}
+	}
+	return toks, nil
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+// viewTableFunctions are the table-valued functions a view query may use.
+var viewTableFunctions = map[string]bool{"json_each": true, "json_tree": true}
+
+// viewAliasStop are the keywords that can follow a table in a FROM clause,
+// and so are not an alias for it.
+var viewAliasStop = map[string]bool{
+	"where": true, "group": true, "order": true, "limit": true, "having": true, "window": true,
+	"join": true, "inner": true, "left": true, "right": true, "full": true, "cross": true,
+	"natural": true, "outer": true, "on": true, "using": true, "indexed": true, "not": true,
+	"union": true, "except": true, "intersect": true,
+}
+
+// viewQueryTables checks that query is a single SELECT statement and
+// returns the tables it reads, sorted. Common table expressions and the
+// json_each and json_tree functions are not included; other table-valued
+// functions and schema-qualified names are errors.
+func viewQueryTables(query string) ([]string, error) {
+	toks, err := tokenizeViewQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	for len(toks) > 0 && toks[len(toks)-1].text == ";" {
+		toks = toks[:len(toks)-1]
+	}
+	if len(toks) == 0 || !(toks[0].is("select") || toks[0].is("with")) {
+		return nil, fmt.Errorf("must be a SELECT statement")
+	}
+
+	// skipParens returns the index after the parenthesis opened at i.
+	skipParens := func(i int) int {
+		depth := 0
+		for ; i < len(toks); i++ {
+			switch toks[i].text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return i
+	}
+	at := func(i int) viewToken {
+		if i < 0 || i >= len(toks) {
+			return viewToken{}
+		}
+		return toks[i]
+	}
+
+	ctes := make(map[string]bool)
+	for i, t := range toks {
+		switch {
+		case t.text == ";":
+			return nil, fmt.Errorf("must be a single statement")
+		case t.is("insert") || t.is("update") || t.is("delete") || (t.is("replace") && at(i+1).text != "("):
+			return nil, fmt.Errorf("must be a SELECT statement, not %s", strings.ToUpper(t.text))
+		case t.is("as"):
+			// WITH name [(columns)] AS [[NOT] MATERIALIZED] (
+			next := i + 1
+			if at(next).is("not") {
+				next++
+			}
+			if at(next).is("materialized") {
+				next++
+			}
+			if at(next).text != "(" {
+				continue
+			}
+			prev := i - 1
+			if at(prev).text == ")" {
+				for depth := 0; prev >= 0; prev-- {
+					if toks[prev].text == ")" {
+						depth++
+					} else if toks[prev].text == "(" {
+						depth--
+						if depth == 0 {
+							break
+						}
+					}
+				}
+				prev--
+			}
+			if at(prev).word {
+				ctes[at(prev).text] = true
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, t := range toks {
+		if !t.is("from") && !t.is("join") {
+			continue
+		}
+		// x IS [NOT] DISTINCT FROM y
+		if t.is("from") && at(i-1).is("distinct") {
+			continue
+		}
+		j := i + 1
+		for j < len(toks) {
+			ref := toks[j]
+			switch {
+			case ref.text == "(":
+				j = skipParens(j)
+			case ref.word:
+				if at(j+1).text == "." {
+					return nil, fmt.Errorf("qualified table name %q is not allowed", ref.text+"."+at(j+2).text)
+				}
+				if at(j+1).text == "(" {
+					if !viewTableFunctions[ref.text] {
+						return nil, fmt.Errorf("table-valued function %q is not allowed", ref.text)
+					}
+					j = skipParens(j + 1)
+				} else {
+					if !ctes[ref.text] {
+						seen[ref.text] = true
+					}
+					j++
+				}
+			default:
+				return nil, fmt.Errorf("expected a table after %s", strings.ToUpper(t.text))
+			}
+
+			if at(j).is("as") {
+				j += 2
+			} else if at(j).word && (at(j).quoted || !viewAliasStop[at(j).text]) {
+				j++
+			}
+			if !t.is("from") || at(j).text != "," {
+				break
+			}
+			j++
+		}
+	}
+
+	tables := make([]string, 0, len(seen))
+	for name := range seen {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
@@ -0,0 +1,182 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const viewTestCollections = `
+version: 1
+collections:
+  posts:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      author_id: { type: string }
+      created_at: { type: timestamp, default: now }
+  users:
+    fields:
+      id: { type: id, primary: true, default: auto }
+      name: { type: string }
+`
+
+func TestParseViews(t *testing.T) {
+	s, err := Parse([]byte(viewTestCollections + `
+views:
+  posts_per_author_day:
+    description: Posts per author per day
+    query: |
+      SELECT p.author_id, u.name, date(p.created_at) AS day, count(*) AS posts
+      FROM posts p JOIN users AS u ON u.id = p.author_id
+      GROUP BY 1, 2, 3;
+    interval: 5m
+    rules:
+      read: auth.role == 'admin'
+  post_count:
+    query: SELECT count(*) AS n FROM posts
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	v := s.Views["posts_per_author_day"]
+	if v.RefreshPolicy() != ViewRefreshInterval || v.RefreshInterval() != 5*time.Minute {
+		t.Errorf("refresh = %s every %s", v.RefreshPolicy(), v.RefreshInterval())
+	}
+	if strings.HasSuffix(v.Query, ";") {
+		t.Errorf("trailing semicolon kept: %q", v.Query)
+	}
+	if got := strings.Join(v.Collections(), ","); got != "posts,users" {
+		t.Errorf("Collections() = %s", got)
+	}
+	if v.Table() != "_alyx_view_posts_per_author_day" || v.TypeName() != "PostsPerAuthorDay" {
+		t.Errorf("Table() = %s, TypeName() = %s", v.Table(), v.TypeName())
+	}
+	if got := s.Views["post_count"].RefreshPolicy(); got != ViewRefreshOnWrite {
+		t.Errorf("default refresh = %s", got)
+	}
+	if got := len(s.ViewsReading("users")); got != 1 {
+		t.Errorf("ViewsReading(users) = %d views", got)
+	}
+
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("parse marshaled schema: %v\n%s", err, data)
+	}
+	if got := reparsed.Views["posts_per_author_day"]; got.Query != v.Query || got.Rules == nil || got.Interval != "5m" {
+		t.Errorf("view lost across round trip:\n%s", data)
+	}
+}
+
+func TestValidateViews(t *testing.T) {
+	tests := []struct {
+		name string
+		view string
+		want string
+	}{
+		{"cte", `{ query: "WITH recent AS (SELECT * FROM posts) SELECT count(*) FROM recent" }`, ""},
+		{"cte with columns", `{ query: "WITH t(a) AS MATERIALIZED (SELECT id FROM posts) SELECT a FROM t" }`, ""},
+		{"subquery and comma join", `{ query: "SELECT * FROM (SELECT id FROM posts) x, users WHERE x.id IN (SELECT id FROM users)" }`, ""},
+		{"json_each", `{ query: "SELECT j.value FROM posts, json_each(posts.author_id) AS j" }`, ""},
+		{"strings and comments", `{ query: "SELECT 'FROM secrets' AS s /* FROM secrets */ FROM posts -- FROM secrets" }`, ""},
+		{"distinct from", `{ query: "SELECT id FROM posts WHERE author_id IS NOT DISTINCT FROM id" }`, ""},
+		{"undeclared table", `{ query: "SELECT * FROM comments" }`, `reads "comments", which is not a declared collection`},
+		{"internal table", `{ query: "SELECT * FROM posts JOIN _alyx_users ON 1" }`, `cannot read internal table "_alyx_users"`},
+		{"sqlite table", `{ query: "SELECT * FROM sqlite_master" }`, `cannot read internal table "sqlite_master"`},
+		{"quoted internal table", `{ query: "SELECT * FROM \"_alyx_users\"" }`, `cannot read internal table "_alyx_users"`},
+		{"qualified", `{ query: "SELECT * FROM main.posts" }`, "qualified table name"},
+		{"table function", `{ query: "SELECT * FROM pragma_table_info('posts')" }`, `table-valued function "pragma_table_info"`},
+		{"not select", `{ query: "DELETE FROM posts" }`, "must be a SELECT statement"},
+		{"cte delete", `{ query: "WITH x AS (SELECT 1) DELETE FROM posts" }`, "not DELETE"},
+		{"two statements", `{ query: "SELECT 1 FROM posts; DROP TABLE posts" }`, "single statement"},
+		{"missing query", `{ refresh: on_write }`, "views.v.query: required field"},
+		{"bad refresh", `{ query: "SELECT 1", refresh: hourly }`, "must be one of: on_write, interval"},
+		{"interval without duration", `{ query: "SELECT 1", refresh: interval }`, "required when refresh is interval"},
+		{"on_write with interval", `{ query: "SELECT 1", refresh: on_write, interval: 5m }`, "only applies to views refreshed on an interval"},
+		{"bad interval", `{ query: "SELECT 1", interval: often }`, `invalid duration "often"`},
+		{"short interval", `{ query: "SELECT 1", interval: 10ms }`, "must be at least 1s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(viewTestCollections + "views:\n  v: " + tt.view + "\n"))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	_, err := Parse([]byte(viewTestCollections + "views:\n  Bad-Name: { query: \"SELECT 1\" }\n"))
+	if err == nil || !strings.Contains(err.Error(), "views.Bad-Name") {
+		t.Errorf("invalid view name: error = %v", err)
+	}
+}
+
+func TestDiffViews(t *testing.T) {
+	parse := func(views string) *Schema {
+		t.Helper()
+		s, err := Parse([]byte(viewTestCollections + views))
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		return s
+	}
+
+	old := parse(`
+views:
+  kept: { query: "SELECT count(*) AS n FROM posts" }
+  requeried: { query: "SELECT count(*) AS n FROM posts" }
+  retimed: { query: "SELECT count(*) AS n FROM users", interval: 1m }
+  dropped: { query: "SELECT 1 AS one" }
+`)
+	newSchema := parse(`
+views:
+  kept: { query: "SELECT count(*) AS n FROM posts" }
+  requeried: { query: "SELECT count(*) AS n FROM users" }
+  retimed: { query: "SELECT count(*) AS n FROM users", interval: 5m }
+  added: { query: "SELECT 1 AS one" }
+`)
+
+	changes := NewDiffer().Diff(old, newSchema)
+	got := make(map[string]*Change)
+	for _, c := range changes {
+		if !c.Safe {
+			t.Errorf("%s is not safe", c)
+		}
+		name := ""
+		if c.NewView != nil {
+			name = c.NewView.Name
+		} else if c.OldView != nil {
+			name = c.OldView.Name
+		}
+		got[name] = c
+	}
+	if len(changes) != 4 {
+		t.Fatalf("expected 4 changes, got %d: %v", len(changes), changes)
+	}
+	for name, want := range map[string]ChangeType{
+		"added": ChangeAddView, "dropped": ChangeDropView, "requeried": ChangeModifyView, "retimed": ChangeModifyView,
+	} {
+		if got[name] == nil || got[name].Type != want {
+			t.Errorf("%s: got %v, want %s", name, got[name], want)
+		}
+	}
+
+	m := &Migrator{}
+	stmts, err := m.changeToSQL(got["requeried"], nil)
+	if err != nil || len(stmts) != 2 || !strings.HasPrefix(stmts[0], "DROP TABLE IF EXISTS _alyx_view_requeried") {
+		t.Errorf("requeried SQL = %v, %v", stmts, err)
+	}
+	if stmts, err := m.changeToSQL(got["retimed"], nil); err != nil || len(stmts) != 0 {
+		t.Errorf("retimed SQL = %v, %v", stmts, err)
+	}
+}
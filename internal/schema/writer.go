@@ -9,7 +9,7 @@ import (
 )
 
 // Marshal serializes a Schema to YAML bytes.
-// Collections, Buckets, and Functions are sorted alphabetically by name.
+// Collections, Buckets, Functions, and Views are sorted alphabetically by name.
 // Field order within collections is preserved using Collection.FieldOrder().
 func Marshal(s *Schema) ([]byte, error) {
 	if s == nil {
@@ -18,10 +18,12 @@ func Marshal(s *Schema) ([]byte, error) {
 
 	// Build the raw schema structure for serialization
 	raw := &rawSchemaWriter{
-		Version:     s.Version,
-		Buckets:     make(map[string]*rawBucketWriter),
-		Collections: make(map[string]*rawCollectionWriter),
-		Functions:   make(map[string]*rawFunctionWriter),
+		Version:      s.Version,
+		Roles:        s.Roles,
+		UserMetadata: s.UserMetadata,
+		Buckets:      make(map[string]*rawBucketWriter),
+		Collections:  make(map[string]*rawCollectionWriter),
+		Functions:    make(map[string]*rawFunctionWriter),
 	}
 
 	// Convert buckets (sorted alphabetically)
@@ -40,6 +42,19 @@ func Marshal(s *Schema) ([]byte, error) {
 			AllowedTypes: bucket.AllowedTypes,
 			Compression:  bucket.Compression,
 			Rules:        bucket.Rules,
+			GC:           bucket.GC,
+		}
+	}
+
+	// Convert presets
+	if len(s.Presets) > 0 {
+		raw.Presets = make(map[string]*yaml.Node, len(s.Presets))
+		for name, p := range s.Presets {
+			node, err := marshalPreset(p)
+			if err != nil {
+				return nil, err
+			}
+			raw.Presets[name] = node
 		}
 	}
 
@@ -53,10 +68,22 @@ func Marshal(s *Schema) ([]byte, error) {
 	for _, name := range collectionNames {
 		col := s.Collections[name]
 		rawCol := &rawCollectionWriter{
-			Indexes: col.Indexes,
-			Rules:   col.Rules,
+			Indexes:   col.Indexes,
+			Rules:     col.Rules,
+			Retention: col.Retention,
+			Docs:      col.Docs,
+			Tenant:    col.Tenant,
+			History:   col.History,
+			Checks:    col.Checks,
+			List:      col.List,
+			Counters:  col.Counters,
+			Expose:    col.Expose,
 		}
 
+		// Fields that come unchanged from a preset are written as use
+		use, covered := col.writtenUse()
+		rawCol.Use = use
+
 		// Use yaml.Node to preserve field order
 		fieldsNode := &yaml.Node{
 			Kind: yaml.MappingNode,
@@ -64,6 +91,9 @@ func Marshal(s *Schema) ([]byte, error) {
 
 		// Add fields in the order specified by FieldOrder()
 		for _, fieldName := range col.FieldOrder() {
+			if covered[fieldName] {
+				continue
+			}
 			if field, ok := col.Fields[fieldName]; ok {
 				// Create key node
 				keyNode := &yaml.Node{
@@ -81,7 +111,9 @@ func Marshal(s *Schema) ([]byte, error) {
 			}
 		}
 
-		rawCol.Fields = fieldsNode
+		if len(fieldsNode.Content) > 0 || len(use) == 0 {
+			rawCol.Fields = fieldsNode
+		}
 		raw.Collections[name] = rawCol
 	}
 
@@ -97,6 +129,7 @@ func Marshal(s *Schema) ([]byte, error) {
 			fn := s.Functions[name]
 			raw.Functions[name] = &rawFunctionWriter{
 				Runtime:      fn.Runtime,
+				Mode:         fn.Mode,
 				Entrypoint:   fn.Entrypoint,
 				Path:         fn.Path,
 				Description:  fn.Description,
@@ -110,10 +143,16 @@ func Marshal(s *Schema) ([]byte, error) {
 				Routes:       fn.Routes,
 				Build:        fn.Build,
 				Rules:        fn.Rules,
+				Input:        fn.Input,
+				Output:       fn.Output,
 			}
 		}
 	}
 
+	if len(s.Views) > 0 {
+		raw.Views = s.Views
+	}
+
 	// Use yaml.v3 Node API to control field ordering
 	node := &yaml.Node{}
 	if err := node.Encode(raw); err != nil {
@@ -131,12 +170,24 @@ func Marshal(s *Schema) ([]byte, error) {
 
 // WriteFile writes a Schema to a file using atomic write pattern.
 // It writes to a temporary file first, then renames it to the target path.
+// If path is a schema directory, definitions are written back to the files that contain them.
 func WriteFile(path string, s *Schema) error {
+	if IsDir(path) {
+		if s == nil {
+			return fmt.Errorf("schema is nil")
+		}
+		return writeDir(path, s)
+	}
+
 	data, err := Marshal(s)
 	if err != nil {
 		return err
 	}
 
+	return writeAtomic(path, data)
+}
+
+func writeAtomic(path string, data []byte) error {
 	tmpPath := path + ".tmp"
 	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
 		return fmt.Errorf("writing temp file: %w", err)
@@ -153,76 +204,105 @@ func WriteFile(path string, s *Schema) error {
 // marshalField converts a Field to a fieldWriter for serialization.
 func marshalField(f *Field) *fieldWriter {
 	fw := &fieldWriter{
-		Type:       f.Type,
-		Primary:    f.Primary,
-		Unique:     f.Unique,
-		Nullable:   f.Nullable,
-		Index:      f.Index,
-		Default:    f.Default,
-		References: f.References,
-		OnDelete:   f.OnDelete,
-		OnUpdate:   f.OnUpdate,
-		Internal:   f.Internal,
-		Validate:   f.Validate,
-		RichText:   f.RichText,
-		Select:     f.Select,
-		Relation:   f.Relation,
-		File:       f.File,
-		MinLength:  f.MinLength,
-		MaxLength:  f.MaxLength,
+		Type:         f.Type,
+		Primary:      f.Primary,
+		Unique:       f.Unique,
+		Nullable:     f.Nullable,
+		Index:        f.Index,
+		Default:      f.Default,
+		References:   f.References,
+		OnDelete:     f.OnDelete,
+		OnUpdate:     f.OnUpdate,
+		Internal:     f.Internal,
+		OnUserDelete: f.OnUserDelete,
+		Validate:     f.Validate,
+		RichText:     f.RichText,
+		Select:       f.Select,
+		Relation:     f.Relation,
+		File:         f.File,
+		Slug:         f.Slug,
+		MinLength:    f.MinLength,
+		MaxLength:    f.MaxLength,
+		Storage:      f.Storage,
+		Encrypted:    f.Encrypted,
+		Collate:      f.Collate,
+		TSType:       f.TSType,
+		ReadRule:     f.ReadRule,
 	}
 	return fw
 }
 
 // rawSchemaWriter is the intermediate structure for YAML serialization.
 type rawSchemaWriter struct {
-	Version     int                             `yaml:"version"`
-	Buckets     map[string]*rawBucketWriter     `yaml:"buckets,omitempty"`
-	Collections map[string]*rawCollectionWriter `yaml:"collections"`
-	Functions   map[string]*rawFunctionWriter   `yaml:"functions,omitempty"`
+	Version      int                             `yaml:"version"`
+	Roles        []string                        `yaml:"roles,omitempty"`
+	UserMetadata *MetadataSchema                 `yaml:"userMetadata,omitempty"`
+	Presets      map[string]*yaml.Node           `yaml:"presets,omitempty"`
+	Buckets      map[string]*rawBucketWriter     `yaml:"buckets,omitempty"`
+	Collections  map[string]*rawCollectionWriter `yaml:"collections"`
+	Functions    map[string]*rawFunctionWriter   `yaml:"functions,omitempty"`
+	Views        map[string]*View                `yaml:"views,omitempty"`
 }
 
 // rawCollectionWriter represents a collection for serialization.
 type rawCollectionWriter struct {
-	Fields  *yaml.Node `yaml:"fields"`
-	Indexes []*Index   `yaml:"indexes,omitempty"`
-	Rules   *Rules     `yaml:"rules,omitempty"`
+	Use       []string         `yaml:"use,omitempty"`
+	Fields    *yaml.Node       `yaml:"fields,omitempty"`
+	Indexes   []*Index         `yaml:"indexes,omitempty"`
+	Rules     *Rules           `yaml:"rules,omitempty"`
+	Retention *RetentionPolicy `yaml:"retention,omitempty"`
+	Docs      *CollectionDocs  `yaml:"docs,omitempty"`
+	Tenant    *TenantConfig    `yaml:"tenant,omitempty"`
+	History   *HistoryConfig   `yaml:"history,omitempty"`
+	Checks    []*Check         `yaml:"checks,omitempty"`
+	List      *ListConfig      `yaml:"list,omitempty"`
+	Counters  []*Counter       `yaml:"counters,omitempty"`
+	Expose    *bool            `yaml:"expose,omitempty"`
 }
 
 // fieldWriter represents a field for serialization.
 type fieldWriter struct {
-	Type       FieldType        `yaml:"type"`
-	Primary    bool             `yaml:"primary,omitempty"`
-	Unique     bool             `yaml:"unique,omitempty"`
-	Nullable   bool             `yaml:"nullable,omitempty"`
-	Index      bool             `yaml:"index,omitempty"`
-	Default    string           `yaml:"default,omitempty"`
-	References string           `yaml:"references,omitempty"`
-	OnDelete   OnDeleteAction   `yaml:"onDelete,omitempty"`
-	OnUpdate   string           `yaml:"onUpdate,omitempty"`
-	Internal   bool             `yaml:"internal,omitempty"`
-	Validate   *FieldValidation `yaml:"validate,omitempty"`
-	RichText   *RichTextConfig  `yaml:"richtext,omitempty"`
-	Select     *SelectConfig    `yaml:"select,omitempty"`
-	Relation   *RelationConfig  `yaml:"relation,omitempty"`
-	File       *FileConfig      `yaml:"file,omitempty"`
-	MinLength  *int             `yaml:"minLength,omitempty"`
-	MaxLength  *int             `yaml:"maxLength,omitempty"`
+	Type         FieldType        `yaml:"type"`
+	Primary      bool             `yaml:"primary,omitempty"`
+	Unique       bool             `yaml:"unique,omitempty"`
+	Nullable     bool             `yaml:"nullable,omitempty"`
+	Index        bool             `yaml:"index,omitempty"`
+	Default      string           `yaml:"default,omitempty"`
+	References   string           `yaml:"references,omitempty"`
+	OnDelete     OnDeleteAction   `yaml:"onDelete,omitempty"`
+	OnUpdate     string           `yaml:"onUpdate,omitempty"`
+	Internal     bool             `yaml:"internal,omitempty"`
+	OnUserDelete UserDeleteAction `yaml:"onUserDelete,omitempty"`
+	Validate     *FieldValidation `yaml:"validate,omitempty"`
+	RichText     *RichTextConfig  `yaml:"richtext,omitempty"`
+	Select       *SelectConfig    `yaml:"select,omitempty"`
+	Relation     *RelationConfig  `yaml:"relation,omitempty"`
+	File         *FileConfig      `yaml:"file,omitempty"`
+	Slug         *SlugConfig      `yaml:"slug,omitempty"`
+	MinLength    *int             `yaml:"minLength,omitempty"`
+	MaxLength    *int             `yaml:"maxLength,omitempty"`
+	Storage      string           `yaml:"storage,omitempty"`
+	Encrypted    bool             `yaml:"encrypted,omitempty"`
+	Collate      Collation        `yaml:"collate,omitempty"`
+	TSType       string           `yaml:"tsType,omitempty"`
+	ReadRule     string           `yaml:"readRule,omitempty"`
 }
 
 // rawBucketWriter represents a bucket for serialization.
 type rawBucketWriter struct {
-	Backend      string   `yaml:"backend"`
-	MaxFileSize  int64    `yaml:"max_file_size,omitempty"`
-	MaxTotalSize int64    `yaml:"max_total_size,omitempty"`
-	AllowedTypes []string `yaml:"allowed_types,omitempty"`
-	Compression  bool     `yaml:"compression,omitempty"`
-	Rules        *Rules   `yaml:"rules,omitempty"`
+	Backend      string    `yaml:"backend"`
+	MaxFileSize  int64     `yaml:"max_file_size,omitempty"`
+	MaxTotalSize int64     `yaml:"max_total_size,omitempty"`
+	AllowedTypes []string  `yaml:"allowed_types,omitempty"`
+	Compression  bool      `yaml:"compression,omitempty"`
+	Rules        *Rules    `yaml:"rules,omitempty"`
+	GC           *BucketGC `yaml:"gc,omitempty"`
 }
 
 // rawFunctionWriter represents a function for serialization.
 type rawFunctionWriter struct {
 	Runtime      string             `yaml:"runtime"`
+	Mode         string             `yaml:"mode,omitempty"`
 	Entrypoint   string             `yaml:"entrypoint"`
 	Path         string             `yaml:"path,omitempty"`
 	Description  string             `yaml:"description,omitempty"`
@@ -236,4 +316,6 @@ type rawFunctionWriter struct {
 	Routes       []FunctionRoute    `yaml:"routes,omitempty"`
 	Build        *FunctionBuild     `yaml:"build,omitempty"`
 	Rules        *FunctionRules     `yaml:"rules,omitempty"`
+	Input        *MetadataSchema    `yaml:"input,omitempty"`
+	Output       *MetadataSchema    `yaml:"output,omitempty"`
 }
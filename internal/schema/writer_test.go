@@ -3,6 +3,7 @@ package schema
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -257,3 +258,43 @@ func TestMarshalEmptyOptionalFields(t *testing.T) {
 	yamlStr := string(data)
 	t.Logf("YAML with omitempty:\n%s", yamlStr)
 }
+
+func TestMarshalExpose(t *testing.T) {
+	s, err := Parse([]byte(`
+version: 1
+collections:
+  jobs:
+    expose: false
+    fields:
+      id:
+        type: uuid
+        primary: true
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if s.Collections["jobs"].Exposed() || !s.Collections["posts"].Exposed() {
+		t.Fatal("expected jobs unexposed and posts exposed")
+	}
+
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if got := strings.Count(string(data), "expose:"); got != 1 {
+		t.Errorf("expected expose written once, got %d:\n%s", got, data)
+	}
+
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse of marshaled schema failed: %v", err)
+	}
+	if reparsed.Collections["jobs"].Exposed() {
+		t.Error("expose: false lost in round trip")
+	}
+}
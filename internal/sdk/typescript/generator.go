@@ -5,16 +5,29 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/watzon/alyx/internal/buildinfo"
 	"github.com/watzon/alyx/internal/openapi"
 	"github.com/watzon/alyx/internal/schema"
 )
 
+// Representations of timestamp fields in the generated types.
+const (
+	DatesString = "string"
+	DatesDate   = "Date"
+)
+
 // Config holds configuration for TypeScript SDK generation.
 type Config struct {
 	OutputDir string
 	ServerURL string
+	// Dates is the type of timestamp fields: DatesString (the default) keeps
+	// the ISO strings the API returns, DatesDate revives them as Dates.
+	Dates string
+	// Version is the package.json version. Empty, the Alyx version is used.
+	Version string
 }
 
 // Generator generates TypeScript SDK from OpenAPI spec and schema.
@@ -31,13 +44,18 @@ func NewGenerator(cfg Config) *Generator {
 
 // Generate generates the complete TypeScript SDK.
 func (g *Generator) Generate(spec *openapi.Spec, s *schema.Schema) error {
+	if g.config.Dates != "" && g.config.Dates != DatesString && g.config.Dates != DatesDate {
+		return fmt.Errorf("unsupported dates type %q (must be %s or %s)", g.config.Dates, DatesString, DatesDate)
+	}
+
 	// Create output directory structure
 	if err := g.createDirectories(); err != nil {
 		return fmt.Errorf("creating directories: %w", err)
 	}
 
-	// Extract collection names
+	// Extract collection names and typed functions
 	collections := g.extractCollections(s)
+	functions := g.extractTypedFunctions(s)
 
 	// Generate package.json and tsconfig.json
 	if err := g.generatePackageJSON(); err != nil {
@@ -49,12 +67,12 @@ func (g *Generator) Generate(spec *openapi.Spec, s *schema.Schema) error {
 	}
 
 	// Generate types
-	if err := g.generateTypes(spec, collections); err != nil {
+	if err := g.generateTypes(spec, s, collections, functions); err != nil {
 		return fmt.Errorf("generating types: %w", err)
 	}
 
 	// Generate resources
-	if err := g.generateResources(collections); err != nil {
+	if err := g.generateResources(collections, functions); err != nil {
 		return fmt.Errorf("generating resources: %w", err)
 	}
 
@@ -101,15 +119,38 @@ func (g *Generator) extractCollections(s *schema.Schema) []string {
 	return collections
 }
 
+// extractTypedFunctions returns the functions that declare their input or
+// output, sorted by name.
+func (g *Generator) extractTypedFunctions(s *schema.Schema) []*schema.Function {
+	names := make([]string, 0, len(s.Functions))
+	for name, fn := range s.Functions {
+		if fn.HasTypes() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	functions := make([]*schema.Function, len(names))
+	for i, name := range names {
+		functions[i] = s.Functions[name]
+	}
+	return functions
+}
+
 func (g *Generator) generatePackageJSON() error {
+	version := g.config.Version
+	if version == "" {
+		version = buildinfo.Version
+	}
 	content := `{
   "name": "alyx-sdk",
-  "version": "1.0.0",
+  "version": ` + strconv.Quote(version) + `,
   "description": "TypeScript SDK for Alyx Backend-as-a-Service",
   "main": "index.ts",
   "types": "index.ts",
   "scripts": {
-    "build": "tsc"
+    "build": "tsc",
+    "test": "tsc && node --test dist/"
   },
   "dependencies": {},
   "devDependencies": {
@@ -142,19 +183,19 @@ func (g *Generator) generateTSConfig() error {
 	return os.WriteFile(filepath.Join(g.config.OutputDir, "tsconfig.json"), []byte(content), 0600)
 }
 
-func (g *Generator) generateTypes(spec *openapi.Spec, collections []string) error {
+func (g *Generator) generateTypes(spec *openapi.Spec, s *schema.Schema, collections []string, functions []*schema.Function) error {
 	// Generate collection types
-	if err := g.generateCollectionTypes(spec, collections); err != nil {
+	if err := g.generateCollectionTypes(spec, s, collections); err != nil {
 		return err
 	}
 
 	// Generate auth types
-	if err := g.generateAuthTypes(); err != nil {
+	if err := g.generateAuthTypes(spec); err != nil {
 		return err
 	}
 
 	// Generate function types
-	if err := g.generateFunctionTypes(); err != nil {
+	if err := g.generateFunctionTypes(spec, functions); err != nil {
 		return err
 	}
 
@@ -162,7 +203,7 @@ func (g *Generator) generateTypes(spec *openapi.Spec, collections []string) erro
 	return g.generateEventTypes()
 }
 
-func (g *Generator) generateCollectionTypes(spec *openapi.Spec, collections []string) error {
+func (g *Generator) generateCollectionTypes(spec *openapi.Spec, s *schema.Schema, collections []string) error {
 	var sb strings.Builder
 
 	sb.WriteString("// Auto-generated collection types\n\n")
@@ -172,27 +213,87 @@ func (g *Generator) generateCollectionTypes(spec *openapi.Spec, collections []st
 		// Find schema in spec
 		collectionSchema := spec.Components.Schemas[name]
 		inputSchema := spec.Components.Schemas[name+"Input"]
+		col := s.Collections[name]
+
+		for _, field := range col.OrderedFields() {
+			if field.TSType != "" {
+				sb.WriteString(fmt.Sprintf("export type %s = %s;\n\n", fieldTypeName(name, field.Name), field.TSType))
+			}
+		}
 
 		if collectionSchema != nil {
 			sb.WriteString(fmt.Sprintf("export interface %s {\n", capitalize(name)))
-			g.writeSchemaProperties(&sb, collectionSchema, "  ")
+			g.writeCollectionProperties(&sb, collectionSchema, col, false)
 			sb.WriteString("}\n\n")
 		}
 
 		if inputSchema != nil {
 			sb.WriteString(fmt.Sprintf("export interface %sInput {\n", capitalize(name)))
-			g.writeSchemaProperties(&sb, inputSchema, "  ")
+			g.writeCollectionProperties(&sb, inputSchema, col, true)
+			sb.WriteString("}\n\n")
+		}
+
+		if patchSchema := spec.Components.Schemas[name+"Patch"]; patchSchema != nil {
+			sb.WriteString("/** Fields to update. Absent fields are left unchanged; null clears a nullable field. */\n")
+			sb.WriteString(fmt.Sprintf("export interface %sPatch {\n", capitalize(name)))
+			g.writeCollectionProperties(&sb, patchSchema, col, true)
 			sb.WriteString("}\n\n")
 		}
 	}
 
+	g.writeFieldKinds(&sb, s, collections)
+
 	// Add list response type
 	sb.WriteString("export interface ListResponse<T> {\n")
 	sb.WriteString("  docs: T[];\n")
-	sb.WriteString("  total: number;\n")
+	sb.WriteString("  /** Omitted when the list was requested with total: 'none'. */\n")
+	sb.WriteString("  total?: number;\n")
+	sb.WriteString("  /** True when total is an estimate. */\n")
+	sb.WriteString("  total_estimated?: boolean;\n")
 	sb.WriteString("  limit: number;\n")
 	sb.WriteString("  offset: number;\n")
-	sb.WriteString("}\n")
+	sb.WriteString("}\n\n")
+
+	// Add sync types
+	sb.WriteString(`/** A document changed since the last pull, or the tombstone of a deleted one. */
+export interface SyncChange<T> {
+  id: string;
+  deleted?: boolean;
+  /** Revision of the document; send it as base_rev when changing the document. */
+  rev?: string;
+  document?: T;
+}
+
+export interface SyncPullResponse<T> {
+  changes: SyncChange<T>[];
+  /** The since of the next pull. */
+  cursor: string;
+  /** Whether the next pull already has changes. */
+  has_more: boolean;
+  /**
+   * Set when the changes after since are no longer retained. Discard the
+   * local copy of the collection and pull again from cursor, which starts a
+   * new snapshot.
+   */
+  resync_required?: boolean;
+}
+
+/** A change made while offline. Updates and deletes need the rev they were made to. */
+export type SyncMutation<TInput, TPatch = Partial<TInput>> =
+  | { op: 'create'; id: string; data: TInput }
+  | { op: 'update'; id: string; base_rev: string; data: TPatch }
+  | { op: 'delete'; id: string; base_rev: string };
+
+/** The outcome of a mutation. On conflict, document is the server's, or deleted is set. */
+export interface SyncResult<T> extends SyncChange<T> {
+  conflict?: boolean;
+}
+
+export interface SyncPushResponse<T> {
+  /** A result per mutation, in the order they were pushed. */
+  results: SyncResult<T>[];
+}
+`)
 
 	return os.WriteFile(filepath.Join(g.config.OutputDir, "types", "collections.ts"), []byte(sb.String()), 0600)
 }
@@ -223,6 +324,80 @@ func (g *Generator) writeSchemaProperties(sb *strings.Builder, s *openapi.Schema
 	}
 }
 
+// writeCollectionProperties writes the properties of a collection's document
+// or input schema, typing its timestamp fields per the Dates setting and
+// its json fields with their tsType. Nullable inputs also accept null.
+func (g *Generator) writeCollectionProperties(sb *strings.Builder, s *openapi.Schema, col *schema.Collection, input bool) {
+	props := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		props = append(props, name)
+	}
+	sort.Strings(props)
+
+	for _, name := range props {
+		prop := s.Properties[name]
+		tsType := g.schemaToTSType(prop)
+		if field, ok := col.Fields[name]; ok {
+			switch {
+			case field.Type == schema.FieldTypeTimestamp && g.config.Dates == DatesDate:
+				// Inputs also accept ISO strings, which the API parses.
+				tsType = "Date"
+				if input {
+					tsType = "Date | string"
+				}
+			case field.TSType != "":
+				tsType = fieldTypeName(col.Name, field.Name)
+			}
+		}
+		if input && prop.Nullable {
+			tsType += " | null"
+		}
+
+		optionalMarker := ""
+		if !contains(s.Required, name) {
+			optionalMarker = "?"
+		}
+		sb.WriteString(fmt.Sprintf("  %s%s: %s;\n", name, optionalMarker, tsType))
+	}
+}
+
+// writeFieldKinds writes the fieldKinds map, which lists the fields of each
+// collection whose JSON value needs converting: timestamps, which arrive as
+// ISO strings, and json fields. The client uses it to revive responses.
+func (g *Generator) writeFieldKinds(sb *strings.Builder, s *schema.Schema, collections []string) {
+	sb.WriteString("export type FieldKind = 'timestamp' | 'json';\n\n")
+	sb.WriteString("/** The fields of each collection with a kind that needs converting, by collection and field. */\n")
+	sb.WriteString("export const fieldKinds: Record<string, Record<string, FieldKind>> = {\n")
+	for _, name := range collections {
+		var kinds []string
+		for _, field := range s.Collections[name].OrderedFields() {
+			switch field.Type {
+			case schema.FieldTypeTimestamp:
+				kinds = append(kinds, fmt.Sprintf("%s: 'timestamp'", field.Name))
+			case schema.FieldTypeJSON:
+				kinds = append(kinds, fmt.Sprintf("%s: 'json'", field.Name))
+			}
+		}
+		if len(kinds) == 0 {
+			sb.WriteString(fmt.Sprintf("  %s: {},\n", name))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  %s: { %s },\n", name, strings.Join(kinds, ", ")))
+	}
+	sb.WriteString("};\n\n")
+}
+
+// fieldTypeName is the name of the type declared for a field's tsType, such
+// as PostsTags for posts.tags.
+func fieldTypeName(collection, field string) string {
+	var sb strings.Builder
+	sb.WriteString(capitalize(collection))
+	for _, part := range strings.Split(field, "_") {
+		sb.WriteString(capitalize(part))
+	}
+	return sb.String()
+}
+
 const (
 	tsTypeNumber = "number"
 )
@@ -252,6 +427,16 @@ func (g *Generator) schemaToTSType(s *openapi.Schema) string {
 		}
 		return "any[]"
 	case "object":
+		if len(s.Properties) > 0 {
+			var sb strings.Builder
+			sb.WriteString("{ ")
+			g.writeInlineProperties(&sb, s)
+			if s.AdditionalProperties != nil {
+				sb.WriteString("[key: string]: any; ")
+			}
+			sb.WriteString("}")
+			return sb.String()
+		}
 		if s.AdditionalProperties != nil {
 			return "Record<string, any>"
 		}
@@ -261,17 +446,74 @@ func (g *Generator) schemaToTSType(s *openapi.Schema) string {
 	}
 }
 
-func (g *Generator) generateAuthTypes() error {
+// writeInlineProperties writes the properties of s on a single line.
+func (g *Generator) writeInlineProperties(sb *strings.Builder, s *openapi.Schema) {
+	props := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		props = append(props, name)
+	}
+	sort.Strings(props)
+
+	for _, name := range props {
+		optionalMarker := ""
+		if !contains(s.Required, name) {
+			optionalMarker = "?"
+		}
+		sb.WriteString(fmt.Sprintf("%s%s: %s; ", name, optionalMarker, g.schemaToTSType(s.Properties[name])))
+	}
+}
+
+// userMetadataType renders the UserMetadata declaration from spec. Without a
+// declared shape, metadata is any JSON object.
+func (g *Generator) userMetadataType(spec *openapi.Spec) string {
+	meta, ok := spec.Components.Schemas["UserMetadata"]
+	if !ok || len(meta.Properties) == 0 {
+		return "export type UserMetadata = Record<string, any>;\n"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("export interface UserMetadata {\n")
+	g.writeSchemaProperties(&sb, meta, "  ")
+	if meta.AdditionalProperties != nil {
+		sb.WriteString("  [key: string]: any;\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// roleUnion renders the User role enum from spec as a TypeScript union.
+func roleUnion(spec *openapi.Spec) string {
+	roles := []string{"user", "admin"}
+	if user, ok := spec.Components.Schemas["User"]; ok {
+		if role, ok := user.Properties["role"]; ok && len(role.Enum) > 0 {
+			roles = role.Enum
+		}
+	}
+
+	quoted := make([]string, len(roles))
+	for i, r := range roles {
+		quoted[i] = "'" + r + "'"
+	}
+	return strings.Join(quoted, " | ")
+}
+
+func (g *Generator) generateAuthTypes(spec *openapi.Spec) error {
 	content := `// Auto-generated auth types
 
+export type UserRole = ` + roleUnion(spec) + `;
+
+` + g.userMetadataType(spec) + `
+/** A JSON merge patch for UserMetadata: null removes a key. */
+export type UserMetadataPatch = { [K in keyof UserMetadata]?: UserMetadata[K] | null };
+
 export interface User {
   id: string;
   email: string;
   verified: boolean;
-  role: 'user' | 'admin';
+  role: UserRole;
   created_at: string;
   updated_at: string;
-  metadata?: Record<string, any>;
+  metadata?: UserMetadata;
 }
 
 export interface TokenPair {
@@ -289,7 +531,11 @@ export interface AuthResponse {
 export interface RegisterInput {
   email: string;
   password: string;
-  metadata?: Record<string, any>;
+  metadata?: UserMetadata;
+}
+
+export interface UpdateMeInput {
+  metadata: UserMetadataPatch | null;
 }
 
 export interface LoginInput {
@@ -304,12 +550,14 @@ export interface RefreshInput {
 	return os.WriteFile(filepath.Join(g.config.OutputDir, "types", "auth.ts"), []byte(content), 0600)
 }
 
-func (g *Generator) generateFunctionTypes() error {
+func (g *Generator) generateFunctionTypes(spec *openapi.Spec, functions []*schema.Function) error {
 	content := `// Auto-generated function types
 
 export interface FunctionInfo {
   name: string;
   runtime: 'node' | 'python' | 'go';
+  status: 'ready' | 'error';
+  error?: string;
 }
 
 export interface FunctionInput {
@@ -327,17 +575,49 @@ export interface LogEntry {
   message: string;
   data?: Record<string, any>;
   timestamp?: string;
+  request_id?: string;
 }
 
-export interface FunctionResponse {
+export interface FunctionResponse<TOutput = Record<string, any>> {
   success: boolean;
-  output?: Record<string, any>;
+  output?: TOutput;
   error?: FunctionError;
   logs?: LogEntry[];
   duration_ms: number;
 }
 `
-	return os.WriteFile(filepath.Join(g.config.OutputDir, "types", "functions.ts"), []byte(content), 0600)
+
+	var sb strings.Builder
+	sb.WriteString(content)
+	for _, fn := range functions {
+		name := fn.TypeName()
+		sb.WriteString("\n")
+		g.writeNamedType(&sb, name+"Input", spec.Components.Schemas[name+"Input"])
+		sb.WriteString("\n")
+		g.writeNamedType(&sb, name+"Output", spec.Components.Schemas[name+"Output"])
+	}
+
+	return os.WriteFile(filepath.Join(g.config.OutputDir, "types", "functions.ts"), []byte(sb.String()), 0600)
+}
+
+// writeNamedType declares name as an interface when s is an object with
+// properties, and as a type alias otherwise.
+func (g *Generator) writeNamedType(sb *strings.Builder, name string, s *openapi.Schema) {
+	if s == nil {
+		sb.WriteString(fmt.Sprintf("export type %s = Record<string, any>;\n", name))
+		return
+	}
+	if s.Type != "object" || len(s.Properties) == 0 {
+		sb.WriteString(fmt.Sprintf("export type %s = %s;\n", name, g.schemaToTSType(s)))
+		return
+	}
+
+	sb.WriteString(fmt.Sprintf("export interface %s {\n", name))
+	g.writeSchemaProperties(sb, s, "  ")
+	if s.AdditionalProperties != nil {
+		sb.WriteString("  [key: string]: any;\n")
+	}
+	sb.WriteString("}\n")
 }
 
 func (g *Generator) generateEventTypes() error {
@@ -414,7 +694,12 @@ export interface ScheduleEventPayload {
 	return os.WriteFile(filepath.Join(g.config.OutputDir, "types", "events.ts"), []byte(content), 0600)
 }
 
-func (g *Generator) generateResources(collections []string) error {
+func (g *Generator) generateResources(collections []string, functions []*schema.Function) error {
+	// Generate the shared request helper
+	if err := g.generateRequestResource(); err != nil {
+		return err
+	}
+
 	// Generate collections resource
 	if err := g.generateCollectionsResource(collections); err != nil {
 		return err
@@ -426,7 +711,7 @@ func (g *Generator) generateResources(collections []string) error {
 	}
 
 	// Generate functions resource
-	if err := g.generateFunctionsResource(); err != nil {
+	if err := g.generateFunctionsResource(functions); err != nil {
 		return err
 	}
 
@@ -437,10 +722,37 @@ func (g *Generator) generateResources(collections []string) error {
 func (g *Generator) generateCollectionsResource(_ []string) error {
 	var sb strings.Builder
 
+	revive := g.config.Dates == DatesDate
+	// document returns the statement that returns the document the request
+	// resolves to, revived when timestamps are Dates.
+	document := func(call string) string {
+		if revive {
+			return "    return revive<T>(this.collectionName, await " + call + ");\n"
+		}
+		return "    return " + call + ";\n"
+	}
+
 	sb.WriteString("// Auto-generated collections resource\n\n")
-	sb.WriteString("import { ListResponse } from '../types/collections';\n\n")
+	if revive {
+		sb.WriteString("import { ListResponse, SyncMutation, SyncPullResponse, SyncPushResponse, fieldKinds } from '../types/collections';\n")
+		sb.WriteString("import { request, RequestOptions } from './request';\n\n")
+		sb.WriteString("/** Converts the ISO strings in a document's timestamp fields to Dates. */\n")
+		sb.WriteString("function revive<T>(collection: string, doc: any): T {\n")
+		sb.WriteString("  const kinds = fieldKinds[collection];\n")
+		sb.WriteString("  if (!kinds || !doc || typeof doc !== 'object') return doc;\n")
+		sb.WriteString("  for (const field of Object.keys(kinds)) {\n")
+		sb.WriteString("    if (kinds[field] === 'timestamp' && typeof doc[field] === 'string') {\n")
+		sb.WriteString("      doc[field] = new Date(doc[field]);\n")
+		sb.WriteString("    }\n")
+		sb.WriteString("  }\n")
+		sb.WriteString("  return doc;\n")
+		sb.WriteString("}\n\n")
+	} else {
+		sb.WriteString("import { ListResponse, SyncMutation, SyncPullResponse, SyncPushResponse } from '../types/collections';\n")
+		sb.WriteString("import { request, RequestOptions } from './request';\n\n")
+	}
 
-	sb.WriteString("export class CollectionClient<T, TInput = Partial<T>> {\n")
+	sb.WriteString("export class CollectionClient<T, TInput = Partial<T>, TPatch = Partial<TInput>> {\n")
 	sb.WriteString("  constructor(\n")
 	sb.WriteString("    private baseURL: string,\n")
 	sb.WriteString("    private collectionName: string,\n")
@@ -450,63 +762,143 @@ func (g *Generator) generateCollectionsResource(_ []string) error {
 	sb.WriteString("  async list(params?: {\n")
 	sb.WriteString("    limit?: number;\n")
 	sb.WriteString("    offset?: number;\n")
-	sb.WriteString("    sort?: string;\n")
+	sb.WriteString("    sort?: string | string[];\n")
 	sb.WriteString("    filter?: string[];\n")
-	sb.WriteString("  }): Promise<ListResponse<T>> {\n")
+	sb.WriteString("    total?: 'exact' | 'none' | 'estimate';\n")
+	sb.WriteString("  }, options?: RequestOptions): Promise<ListResponse<T>> {\n")
 	sb.WriteString("    const query = new URLSearchParams();\n")
 	sb.WriteString("    if (params?.limit) query.set('limit', params.limit.toString());\n")
 	sb.WriteString("    if (params?.offset) query.set('offset', params.offset.toString());\n")
-	sb.WriteString("    if (params?.sort) query.set('sort', params.sort);\n")
-	sb.WriteString("    if (params?.filter) params.filter.forEach(f => query.append('filter', f));\n\n")
-	sb.WriteString("    const response = await fetch(\n")
-	sb.WriteString("      `${this.baseURL}/api/collections/${this.collectionName}?${query}`,\n")
-	sb.WriteString("      { headers: this.getHeaders() }\n")
-	sb.WriteString("    );\n")
-	sb.WriteString("    if (!response.ok) throw new Error(`HTTP ${response.status}: ${await response.text()}`);\n")
-	sb.WriteString("    return response.json();\n")
+	sb.WriteString("    if (params?.sort) query.set('sort', Array.isArray(params.sort) ? params.sort.join(',') : params.sort);\n")
+	sb.WriteString("    if (params?.filter) params.filter.forEach(f => query.append('filter', f));\n")
+	sb.WriteString("    if (params?.total) query.set('total', params.total);\n\n")
+	listCall := "request<ListResponse<T>>(\n" +
+		"      `${this.baseURL}/api/collections/${this.collectionName}?${query}`,\n" +
+		"      { headers: this.getHeaders() },\n" +
+		"      options\n" +
+		"    )"
+	if revive {
+		sb.WriteString("    const body = await " + listCall + ";\n")
+		sb.WriteString("    body.docs = body.docs.map((doc: any) => revive<T>(this.collectionName, doc));\n")
+		sb.WriteString("    return body;\n")
+	} else {
+		sb.WriteString("    return " + listCall + ";\n")
+	}
 	sb.WriteString("  }\n\n")
 
-	sb.WriteString("  async get(id: string): Promise<T> {\n")
-	sb.WriteString("    const response = await fetch(\n")
-	sb.WriteString("      `${this.baseURL}/api/collections/${this.collectionName}/${id}`,\n")
-	sb.WriteString("      { headers: this.getHeaders() }\n")
-	sb.WriteString("    );\n")
-	sb.WriteString("    if (!response.ok) throw new Error(`HTTP ${response.status}: ${await response.text()}`);\n")
-	sb.WriteString("    return response.json();\n")
+	sb.WriteString("  async get(id: string, options?: RequestOptions): Promise<T> {\n")
+	sb.WriteString(document("request<T>(\n" +
+		"      `${this.baseURL}/api/collections/${this.collectionName}/${id}`,\n" +
+		"      { headers: this.getHeaders() },\n" +
+		"      options\n" +
+		"    )"))
 	sb.WriteString("  }\n\n")
 
-	sb.WriteString("  async create(data: TInput): Promise<T> {\n")
-	sb.WriteString("    const response = await fetch(\n")
-	sb.WriteString("      `${this.baseURL}/api/collections/${this.collectionName}`,\n")
-	sb.WriteString("      {\n")
-	sb.WriteString("        method: 'POST',\n")
-	sb.WriteString("        headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },\n")
-	sb.WriteString("        body: JSON.stringify(data),\n")
-	sb.WriteString("      }\n")
-	sb.WriteString("    );\n")
-	sb.WriteString("    if (!response.ok) throw new Error(`HTTP ${response.status}: ${await response.text()}`);\n")
-	sb.WriteString("    return response.json();\n")
+	sb.WriteString("  async create(data: TInput, options?: RequestOptions): Promise<T> {\n")
+	sb.WriteString(document("request<T>(\n" +
+		"      `${this.baseURL}/api/collections/${this.collectionName}`,\n" +
+		"      {\n" +
+		"        method: 'POST',\n" +
+		"        headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },\n" +
+		"        body: JSON.stringify(data),\n" +
+		"      },\n" +
+		"      options\n" +
+		"    )"))
 	sb.WriteString("  }\n\n")
 
-	sb.WriteString("  async update(id: string, data: TInput): Promise<T> {\n")
-	sb.WriteString("    const response = await fetch(\n")
-	sb.WriteString("      `${this.baseURL}/api/collections/${this.collectionName}/${id}`,\n")
-	sb.WriteString("      {\n")
-	sb.WriteString("        method: 'PATCH',\n")
-	sb.WriteString("        headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },\n")
-	sb.WriteString("        body: JSON.stringify(data),\n")
-	sb.WriteString("      }\n")
-	sb.WriteString("    );\n")
-	sb.WriteString("    if (!response.ok) throw new Error(`HTTP ${response.status}: ${await response.text()}`);\n")
-	sb.WriteString("    return response.json();\n")
+	sb.WriteString("  async update(id: string, data: TPatch, options?: RequestOptions): Promise<T> {\n")
+	sb.WriteString(document("request<T>(\n" +
+		"      `${this.baseURL}/api/collections/${this.collectionName}/${id}`,\n" +
+		"      {\n" +
+		"        method: 'PATCH',\n" +
+		"        headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },\n" +
+		"        body: JSON.stringify(data),\n" +
+		"      },\n" +
+		"      options\n" +
+		"    )"))
+	sb.WriteString("  }\n\n")
+
+	sb.WriteString("  async upsert(key: string, data: TInput, options?: RequestOptions): Promise<{ created: boolean; document: T }> {\n")
+	upsertCall := "request<{ created: boolean; document: T }>(\n" +
+		"      `${this.baseURL}/api/collections/${this.collectionName}/upsert?key=${encodeURIComponent(key)}`,\n" +
+		"      {\n" +
+		"        method: 'PUT',\n" +
+		"        headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },\n" +
+		"        body: JSON.stringify(data),\n" +
+		"      },\n" +
+		"      options\n" +
+		"    )"
+	if revive {
+		sb.WriteString("    const body = await " + upsertCall + ";\n")
+		sb.WriteString("    body.document = revive<T>(this.collectionName, body.document);\n")
+		sb.WriteString("    return body;\n")
+	} else {
+		sb.WriteString("    return " + upsertCall + ";\n")
+	}
 	sb.WriteString("  }\n\n")
 
-	sb.WriteString("  async delete(id: string): Promise<void> {\n")
-	sb.WriteString("    const response = await fetch(\n")
+	sb.WriteString("  async delete(id: string, options?: RequestOptions): Promise<void> {\n")
+	sb.WriteString("    await request<void>(\n")
 	sb.WriteString("      `${this.baseURL}/api/collections/${this.collectionName}/${id}`,\n")
-	sb.WriteString("      { method: 'DELETE', headers: this.getHeaders() }\n")
+	sb.WriteString("      { method: 'DELETE', headers: this.getHeaders() },\n")
+	sb.WriteString("      options\n")
 	sb.WriteString("    );\n")
-	sb.WriteString("    if (!response.ok) throw new Error(`HTTP ${response.status}: ${await response.text()}`);\n")
+	sb.WriteString("  }\n\n")
+
+	sb.WriteString("  /**\n")
+	sb.WriteString("   * Returns the documents changed since the cursor of the previous pull.\n")
+	sb.WriteString("   * Without since, pages through a snapshot of every readable document.\n")
+	sb.WriteString("   * Keep pulling with the returned cursor while has_more is set.\n")
+	sb.WriteString("   */\n")
+	sb.WriteString("  async pull(since?: string, params?: { limit?: number }, options?: RequestOptions): Promise<SyncPullResponse<T>> {\n")
+	sb.WriteString("    const query = new URLSearchParams();\n")
+	sb.WriteString("    if (since) query.set('since', since);\n")
+	sb.WriteString("    if (params?.limit) query.set('limit', params.limit.toString());\n")
+	sb.WriteString("    let body: SyncPullResponse<T>;\n")
+	sb.WriteString("    try {\n")
+	sb.WriteString("      body = await request<SyncPullResponse<T>>(\n")
+	sb.WriteString("        `${this.baseURL}/api/sync/${this.collectionName}?${query}`,\n")
+	sb.WriteString("        { headers: this.getHeaders() },\n")
+	sb.WriteString("        options\n")
+	sb.WriteString("      );\n")
+	sb.WriteString("    } catch (err) {\n")
+	sb.WriteString("      if (err instanceof Error && err.message.startsWith('HTTP 410:') && err.message.includes('RESYNC_REQUIRED')) {\n")
+	sb.WriteString("        return { changes: [], cursor: '', has_more: true, resync_required: true };\n")
+	sb.WriteString("      }\n")
+	sb.WriteString("      throw err;\n")
+	sb.WriteString("    }\n")
+	if revive {
+		sb.WriteString("    for (const change of body.changes) {\n")
+		sb.WriteString("      if (change.document) change.document = revive<T>(this.collectionName, change.document);\n")
+		sb.WriteString("    }\n")
+	}
+	sb.WriteString("    return body;\n")
+	sb.WriteString("  }\n\n")
+
+	sb.WriteString("  /**\n")
+	sb.WriteString("   * Applies offline mutations in a single transaction. Mutations whose\n")
+	sb.WriteString("   * base_rev is out of date are not applied; their results are marked\n")
+	sb.WriteString("   * conflict and carry the server's document.\n")
+	sb.WriteString("   */\n")
+	sb.WriteString("  async push(mutations: SyncMutation<TInput, TPatch>[], options?: RequestOptions): Promise<SyncPushResponse<T>> {\n")
+	pushCall := "request<SyncPushResponse<T>>(\n" +
+		"      `${this.baseURL}/api/sync/${this.collectionName}`,\n" +
+		"      {\n" +
+		"        method: 'POST',\n" +
+		"        headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },\n" +
+		"        body: JSON.stringify({ mutations }),\n" +
+		"      },\n" +
+		"      options\n" +
+		"    )"
+	if revive {
+		sb.WriteString("    const body = await " + pushCall + ";\n")
+		sb.WriteString("    for (const result of body.results) {\n")
+		sb.WriteString("      if (result.document) result.document = revive<T>(this.collectionName, result.document);\n")
+		sb.WriteString("    }\n")
+		sb.WriteString("    return body;\n")
+	} else {
+		sb.WriteString("    return " + pushCall + ";\n")
+	}
 	sb.WriteString("  }\n")
 	sb.WriteString("}\n")
 
@@ -516,7 +908,8 @@ func (g *Generator) generateCollectionsResource(_ []string) error {
 func (g *Generator) generateAuthResource() error {
 	content := `// Auto-generated auth resource
 
-import { User, AuthResponse, RegisterInput, LoginInput, RefreshInput } from '../types/auth';
+import { User, AuthResponse, RegisterInput, LoginInput, RefreshInput, UpdateMeInput } from '../types/auth';
+import { request, RequestOptions } from './request';
 
 export class AuthClient {
   constructor(
@@ -524,67 +917,98 @@ export class AuthClient {
     private getHeaders: () => Record<string, string>
   ) {}
 
-  async register(input: RegisterInput): Promise<AuthResponse> {
-    const response = await fetch(` + "`${this.baseURL}/api/auth/register`" + `, {
+  async register(input: RegisterInput, options?: RequestOptions): Promise<AuthResponse> {
+    return request<AuthResponse>(` + "`${this.baseURL}/api/auth/register`" + `, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(input),
-    });
-    if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
-    return response.json();
+    }, options);
   }
 
-  async login(input: LoginInput): Promise<AuthResponse> {
-    const response = await fetch(` + "`${this.baseURL}/api/auth/login`" + `, {
+  async login(input: LoginInput, options?: RequestOptions): Promise<AuthResponse> {
+    return request<AuthResponse>(` + "`${this.baseURL}/api/auth/login`" + `, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(input),
-    });
-    if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
-    return response.json();
+    }, options);
   }
 
-  async refresh(input: RefreshInput): Promise<AuthResponse> {
-    const response = await fetch(` + "`${this.baseURL}/api/auth/refresh`" + `, {
+  async refresh(input: RefreshInput, options?: RequestOptions): Promise<AuthResponse> {
+    return request<AuthResponse>(` + "`${this.baseURL}/api/auth/refresh`" + `, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(input),
-    });
-    if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
-    return response.json();
+    }, options);
   }
 
-  async logout(refreshToken: string): Promise<void> {
-    const response = await fetch(` + "`${this.baseURL}/api/auth/logout`" + `, {
+  async logout(refreshToken: string, options?: RequestOptions): Promise<void> {
+    await request<void>(` + "`${this.baseURL}/api/auth/logout`" + `, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ refresh_token: refreshToken }),
-    });
-    if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
+    }, options);
   }
 
-  async me(): Promise<User> {
-    const response = await fetch(` + "`${this.baseURL}/api/auth/me`" + `, {
+  async me(options?: RequestOptions): Promise<User> {
+    return request<User>(` + "`${this.baseURL}/api/auth/me`" + `, {
       headers: this.getHeaders(),
-    });
-    if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
-    return response.json();
+    }, options);
   }
 
-  async listProviders(): Promise<{ providers: string[] }> {
-    const response = await fetch(` + "`${this.baseURL}/api/auth/providers`" + `);
-    if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
-    return response.json();
+  async updateMe(input: UpdateMeInput, options?: RequestOptions): Promise<User> {
+    return request<User>(` + "`${this.baseURL}/api/auth/me`" + `, {
+      method: 'PATCH',
+      headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
+      body: JSON.stringify(input),
+    }, options);
+  }
+
+  async listProviders(options?: RequestOptions): Promise<{ providers: string[] }> {
+    return request<{ providers: string[] }>(` + "`${this.baseURL}/api/auth/providers`" + `, {}, options);
+  }
+
+  /** URL that starts an OAuth login. With a redirectUri from the server's allowlist, the login finishes there with a code for exchangeOAuthCode. */
+  getOAuthUrl(provider: string, redirectUri?: string): string {
+    const query = redirectUri ? ` + "`?redirect_uri=${encodeURIComponent(redirectUri)}`" + ` : '';
+    return ` + "`${this.baseURL}/api/auth/oauth/${encodeURIComponent(provider)}${query}`" + `;
+  }
+
+  /** Exchanges the one-time code an OAuth login sent to its redirect URI for the token pair. */
+  async exchangeOAuthCode(code: string, options?: RequestOptions): Promise<AuthResponse> {
+    return request<AuthResponse>(` + "`${this.baseURL}/api/auth/oauth/exchange`" + `, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ code }),
+    }, options);
   }
 }
 `
 	return os.WriteFile(filepath.Join(g.config.OutputDir, "resources", "auth.ts"), []byte(content), 0600)
 }
 
-func (g *Generator) generateFunctionsResource() error {
-	content := `// Auto-generated functions resource
+// reservedFunctionMethods are FunctionsClient members that a function's
+// method must not shadow.
+var reservedFunctionMethods = map[string]bool{
+	"list": true, "invoke": true, "stats": true, "reload": true, "call": true,
+}
+
+func (g *Generator) generateFunctionsResource(functions []*schema.Function) error {
+	imports := []string{"FunctionInfo", "FunctionInput", "FunctionError", "FunctionResponse"}
+	for _, fn := range functions {
+		imports = append(imports, fn.TypeName()+"Input", fn.TypeName()+"Output")
+	}
 
-import { FunctionInfo, FunctionInput, FunctionResponse } from '../types/functions';
+	var sb strings.Builder
+	sb.WriteString("// Auto-generated functions resource\n\n")
+	sb.WriteString(fmt.Sprintf("import { %s } from '../types/functions';\n", strings.Join(imports, ", ")))
+	sb.WriteString("import { request, RequestOptions } from './request';\n\n")
+	sb.WriteString(`/** Thrown by typed function methods when the function reports an error. */
+export class FunctionInvocationError extends Error {
+  constructor(public code: string, message: string, public details?: Record<string, any>) {
+    super(message);
+    this.name = 'FunctionInvocationError';
+  }
+}
 
 export class FunctionsClient {
   constructor(
@@ -592,49 +1016,80 @@ export class FunctionsClient {
     private getHeaders: () => Record<string, string>
   ) {}
 
-  async list(): Promise<{ functions: FunctionInfo[]; count: number }> {
-    const response = await fetch(` + "`${this.baseURL}/api/functions`" + `, {
+  async list(options?: RequestOptions): Promise<{ functions: FunctionInfo[]; count: number }> {
+    return request<{ functions: FunctionInfo[]; count: number }>(` + "`${this.baseURL}/api/functions`" + `, {
       headers: this.getHeaders(),
-    });
-    if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
-    return response.json();
+    }, options);
   }
 
-  async invoke(name: string, input?: FunctionInput): Promise<FunctionResponse> {
-    const response = await fetch(` + "`${this.baseURL}/api/functions/${name}`" + `, {
-      method: 'POST',
-      headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
-      body: JSON.stringify(input || {}),
-    });
-    if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
-    return response.json();
+  async invoke<TOutput = Record<string, any>>(name: string, input?: FunctionInput, options?: RequestOptions): Promise<FunctionResponse<TOutput>> {
+    return this.call<TOutput>(name, input || {}, options);
   }
 
-  async stats(): Promise<{ pools: Record<string, { ready: number; busy: number; total: number }> }> {
-    const response = await fetch(` + "`${this.baseURL}/api/functions/stats`" + `, {
+  async stats(options?: RequestOptions): Promise<{
+    pools: Record<string, { ready: number; busy: number; total: number }>;
+    builds: Record<string, { status: 'success' | 'failed'; started_at: string; duration_ms: number; output?: string; error?: string } | null>;
+  }> {
+    return request<{
+      pools: Record<string, { ready: number; busy: number; total: number }>;
+      builds: Record<string, { status: 'success' | 'failed'; started_at: string; duration_ms: number; output?: string; error?: string } | null>;
+    }>(` + "`${this.baseURL}/api/functions/stats`" + `, {
       headers: this.getHeaders(),
-    });
-    if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
-    return response.json();
+    }, options);
   }
 
-  async reload(): Promise<{ success: boolean; count: number; message: string }> {
-    const response = await fetch(` + "`${this.baseURL}/api/functions/reload`" + `, {
+  async reload(options?: RequestOptions): Promise<{ success: boolean; count: number; ready: number; failed: { name: string; error: string }[]; message: string }> {
+    return request<{ success: boolean; count: number; ready: number; failed: { name: string; error: string }[]; message: string }>(` + "`${this.baseURL}/api/functions/reload`" + `, {
       method: 'POST',
       headers: this.getHeaders(),
-    });
-    if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
-    return response.json();
+    }, options);
+  }
+`)
+
+	for _, fn := range functions {
+		typeName := fn.TypeName()
+		method := strings.ToLower(typeName[:1]) + typeName[1:]
+		if reservedFunctionMethods[method] {
+			method = "invoke" + typeName
+		}
+
+		sb.WriteString("\n")
+		if fn.Description != "" {
+			sb.WriteString(fmt.Sprintf("  /** %s */\n", fn.Description))
+		}
+		param := fmt.Sprintf("input: %sInput", typeName)
+		if fn.Input == nil {
+			param += " = {}"
+		}
+		sb.WriteString(fmt.Sprintf("  async %s(%s, options?: RequestOptions): Promise<%sOutput> {\n", method, param, typeName))
+		sb.WriteString(fmt.Sprintf("    const result = await this.call<%sOutput>('%s', input, options);\n", typeName, fn.Name))
+		sb.WriteString("    if (!result.success) {\n")
+		sb.WriteString("      const error: FunctionError = result.error || { code: 'FUNCTION_ERROR', message: 'Function failed' };\n")
+		sb.WriteString("      throw new FunctionInvocationError(error.code, error.message, error.details);\n")
+		sb.WriteString("    }\n")
+		sb.WriteString(fmt.Sprintf("    return result.output as %sOutput;\n", typeName))
+		sb.WriteString("  }\n")
+	}
+
+	sb.WriteString(`
+  private async call<TOutput>(name: string, input: unknown, options?: RequestOptions): Promise<FunctionResponse<TOutput>> {
+    return request<FunctionResponse<TOutput>>(` + "`${this.baseURL}/api/functions/${name}`" + `, {
+      method: 'POST',
+      headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
+      body: JSON.stringify(input),
+    }, options);
   }
 }
-`
-	return os.WriteFile(filepath.Join(g.config.OutputDir, "resources", "functions.ts"), []byte(content), 0600)
+`)
+
+	return os.WriteFile(filepath.Join(g.config.OutputDir, "resources", "functions.ts"), []byte(sb.String()), 0600)
 }
 
 func (g *Generator) generateEventsResource() error {
 	content := `// Auto-generated events resource
 
 import { Event, EventType, EventPayload, EventMetadata } from '../types/events';
+import { request, RequestOptions } from './request';
 
 export class EventsClient {
   constructor(
@@ -649,18 +1104,169 @@ export class EventsClient {
     payload: EventPayload;
     metadata?: EventMetadata;
     process_at?: string;
-  }): Promise<Event> {
-    const response = await fetch(` + "`${this.baseURL}/api/events`" + `, {
+  }, options?: RequestOptions): Promise<Event> {
+    return request<Event>(` + "`${this.baseURL}/api/events`" + `, {
       method: 'POST',
       headers: { ...this.getHeaders(), 'Content-Type': 'application/json' },
       body: JSON.stringify(event),
+    }, options);
+  }
+}
+`
+	return os.WriteFile(filepath.Join(g.config.OutputDir, "resources", "events.ts"), []byte(content), 0600)
+}
+
+// generateRequestResource writes the helper every resource sends requests
+// through, which applies the per-request options, and its tests.
+func (g *Generator) generateRequestResource() error {
+	content := `// Auto-generated request helper
+
+/** Options accepted as the last argument of every request method. */
+export interface RequestOptions {
+  /** Cancels the request when aborted. */
+  signal?: AbortSignal;
+  /** Cancels the request if it has not finished after this many milliseconds. */
+  timeoutMs?: number;
+  /** Headers to send in addition to, or instead of, the client's. */
+  headers?: Record<string, string>;
+}
+
+/** Thrown when a request is cancelled through its signal or its timeout. */
+export class AlyxAbortError extends Error {
+  /** Whether the request was cancelled because timeoutMs elapsed. */
+  readonly timedOut: boolean;
+
+  constructor(message: string, timedOut: boolean) {
+    super(message);
+    this.name = 'AlyxAbortError';
+    this.timedOut = timedOut;
+  }
+}
+
+/**
+ * Sends a request and returns its decoded JSON body, or undefined when it has
+ * none. Throws for a non-2xx response, and an AlyxAbortError when the request
+ * is cancelled before the body has been read.
+ */
+export async function request<T>(
+  url: string,
+  init: { method?: string; headers?: Record<string, string>; body?: string },
+  options?: RequestOptions
+): Promise<T> {
+  const controller = new AbortController();
+  const abort = () => controller.abort();
+  let timedOut = false;
+  let timer: ReturnType<typeof setTimeout> | undefined;
+
+  if (options?.signal?.aborted) {
+    abort();
+  } else {
+    options?.signal?.addEventListener('abort', abort);
+  }
+  if (options?.timeoutMs !== undefined) {
+    timer = setTimeout(() => {
+      timedOut = true;
+      abort();
+    }, options.timeoutMs);
+  }
+
+  try {
+    const response = await fetch(url, {
+      ...init,
+      headers: { ...init.headers, ...options?.headers },
+      signal: controller.signal,
     });
     if (!response.ok) throw new Error(` + "`HTTP ${response.status}: ${await response.text()}`" + `);
-    return response.json();
+    const text = await response.text();
+    return (text ? JSON.parse(text) : undefined) as T;
+  } catch (err) {
+    if (controller.signal.aborted) {
+      throw new AlyxAbortError(timedOut ? ` + "`Request timed out after ${options?.timeoutMs}ms`" + ` : 'Request aborted', timedOut);
+    }
+    throw err;
+  } finally {
+    clearTimeout(timer);
+    options?.signal?.removeEventListener('abort', abort);
   }
 }
 `
-	return os.WriteFile(filepath.Join(g.config.OutputDir, "resources", "events.ts"), []byte(content), 0600)
+	if err := os.WriteFile(filepath.Join(g.config.OutputDir, "resources", "request.ts"), []byte(content), 0600); err != nil {
+		return err
+	}
+
+	tests := `// Auto-generated tests for the request helper. Run with npm test.
+
+import { test } from 'node:test';
+import assert from 'node:assert/strict';
+import { request, AlyxAbortError } from './request';
+
+/** Replaces fetch for the duration of fn. */
+async function withFetch(mock: typeof fetch, fn: () => Promise<void>): Promise<void> {
+  const original = globalThis.fetch;
+  globalThis.fetch = mock;
+  try {
+    await fn();
+  } finally {
+    globalThis.fetch = original;
+  }
+}
+
+/** A fetch that never responds, and rejects once its signal is aborted, like fetch does. */
+const hangingFetch = ((_url: string | URL | Request, init?: RequestInit) =>
+  new Promise<Response>((_resolve, reject) => {
+    const aborted = () => reject(new DOMException('Aborted', 'AbortError'));
+    if (init?.signal?.aborted) aborted();
+    init?.signal?.addEventListener('abort', aborted);
+  })) as typeof fetch;
+
+test('a signal cancels a pending request', async () => {
+  await withFetch(hangingFetch, async () => {
+    const controller = new AbortController();
+    const pending = request('http://alyx.test/api/collections/posts', {}, { signal: controller.signal });
+    controller.abort();
+    await assert.rejects(pending, (err: unknown) => err instanceof AlyxAbortError && !err.timedOut);
+  });
+});
+
+test('an aborted signal cancels the request before it is sent', async () => {
+  await withFetch(hangingFetch, async () => {
+    const controller = new AbortController();
+    controller.abort();
+    await assert.rejects(
+      request('http://alyx.test/api/collections/posts', {}, { signal: controller.signal }),
+      AlyxAbortError
+    );
+  });
+});
+
+test('timeoutMs cancels a request that takes too long', async () => {
+  await withFetch(hangingFetch, async () => {
+    await assert.rejects(
+      request('http://alyx.test/api/collections/posts', {}, { timeoutMs: 10 }),
+      (err: unknown) => err instanceof AlyxAbortError && err.timedOut
+    );
+  });
+});
+
+test('headers are added to the request', async () => {
+  let sent: Record<string, string> = {};
+  const mock = (async (_url: string | URL | Request, init?: RequestInit) => {
+    sent = init?.headers as Record<string, string>;
+    return new Response('{"ok":true}', { status: 200 });
+  }) as typeof fetch;
+
+  await withFetch(mock, async () => {
+    const body = await request<{ ok: boolean }>(
+      'http://alyx.test/api/collections/posts',
+      { headers: { Authorization: 'Bearer token' } },
+      { headers: { 'X-Request-ID': 'abc' }, timeoutMs: 1000 }
+    );
+    assert.deepEqual(body, { ok: true });
+    assert.deepEqual(sent, { Authorization: 'Bearer token', 'X-Request-ID': 'abc' });
+  });
+});
+`
+	return os.WriteFile(filepath.Join(g.config.OutputDir, "resources", "request.test.ts"), []byte(tests), 0600)
 }
 
 func (g *Generator) generateClient(collections []string) error {
@@ -674,19 +1280,20 @@ func (g *Generator) generateClient(collections []string) error {
 
 	// Import collection types
 	for _, name := range collections {
-		sb.WriteString(fmt.Sprintf("import { %s, %sInput } from './types/collections';\n", capitalize(name), capitalize(name)))
+		sb.WriteString(fmt.Sprintf("import { %s, %sInput, %sPatch } from './types/collections';\n", capitalize(name), capitalize(name), capitalize(name)))
 	}
 
 	sb.WriteString("\nexport interface AlyxConfig {\n")
 	sb.WriteString("  url: string;\n")
 	sb.WriteString("  token?: string;\n")
+	sb.WriteString("  parentRequestId?: string;\n")
 	sb.WriteString("}\n\n")
 
 	sb.WriteString("export class AlyxClient {\n")
 	sb.WriteString("  private config: AlyxConfig;\n")
 	sb.WriteString("  public collections: {\n")
 	for _, name := range collections {
-		sb.WriteString(fmt.Sprintf("    %s: CollectionClient<%s, %sInput>;\n", name, capitalize(name), capitalize(name)))
+		sb.WriteString(fmt.Sprintf("    %s: CollectionClient<%s, %sInput, %sPatch>;\n", name, capitalize(name), capitalize(name), capitalize(name)))
 	}
 	sb.WriteString("  };\n")
 	sb.WriteString("  public auth: AuthClient;\n")
@@ -703,8 +1310,8 @@ func (g *Generator) generateClient(collections []string) error {
 		if i == len(collections)-1 {
 			comma = ""
 		}
-		sb.WriteString(fmt.Sprintf("      %s: new CollectionClient<%s, %sInput>(this.config.url, '%s', () => this.getHeaders())%s\n",
-			name, capitalize(name), capitalize(name), name, comma))
+		sb.WriteString(fmt.Sprintf("      %s: new CollectionClient<%s, %sInput, %sPatch>(this.config.url, '%s', () => this.getHeaders())%s\n",
+			name, capitalize(name), capitalize(name), capitalize(name), name, comma))
 	}
 	sb.WriteString("    };\n\n")
 
@@ -719,6 +1326,9 @@ func (g *Generator) generateClient(collections []string) error {
 	sb.WriteString("    if (this.config.token) {\n")
 	sb.WriteString("      headers['Authorization'] = `Bearer ${this.config.token}`;\n")
 	sb.WriteString("    }\n")
+	sb.WriteString("    if (this.config.parentRequestId) {\n")
+	sb.WriteString("      headers['X-Parent-Request-ID'] = this.config.parentRequestId;\n")
+	sb.WriteString("    }\n")
 	sb.WriteString("    return headers;\n")
 	sb.WriteString("  }\n")
 	sb.WriteString("}\n")
@@ -742,6 +1352,7 @@ export function getContext(): FunctionContext {
   const config: AlyxConfig = {
     url: process.env.ALYX_URL || 'http://localhost:8090',
     token: process.env.ALYX_INTERNAL_TOKEN,
+    parentRequestId: process.env.ALYX_REQUEST_ID,
   };
 
   let auth: User | null = null;
@@ -772,6 +1383,7 @@ export * from './types/collections';
 export * from './types/auth';
 export * from './types/functions';
 export * from './types/events';
+export * from './resources/request';
 export * from './resources/collections';
 export * from './resources/auth';
 export * from './resources/functions';
@@ -0,0 +1,67 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/watzon/alyx/internal/deploy"
+	"github.com/watzon/alyx/pkg/alyxtest"
+)
+
+func TestAdminReadPermission(t *testing.T) {
+	t.Parallel()
+	h := alyxtest.New(t, testSchema)
+
+	resp, err := h.Server.DeployService().CreateToken(&deploy.CreateTokenRequest{Name: "ci-check", Permissions: []string{string(deploy.PermissionRead)}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := resp.Token
+
+	for _, path := range []string{
+		"/api/admin/schema",
+		"/api/admin/config/schema",
+		"/api/admin/deploy/history",
+		"/api/admin/schema/pending-changes",
+		"/api/admin/logs",
+	} {
+		if w := h.Do(http.MethodGet, path, "", reader); w.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+	if w := h.Do(http.MethodPost, "/api/admin/deploy/prepare", `{"schema_hash": "abc", "functions_hash": "def"}`, reader); w.Code != http.StatusOK {
+		t.Errorf("deploy prepare: status %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               deploy.TokenPermission
+	}{
+		{http.MethodPost, "/api/admin/deploy/execute", `{}`, deploy.PermissionDeploy},
+		{http.MethodPost, "/api/admin/deploy/rollback", `{}`, deploy.PermissionRollback},
+		{http.MethodPost, "/api/admin/logs/clear", "", deploy.PermissionAdmin},
+		{http.MethodGet, "/api/admin/config/raw", "", deploy.PermissionAdmin},
+		{http.MethodPost, "/api/admin/tokens", `{"name": "escalate"}`, deploy.PermissionAdmin},
+	} {
+		w := h.Do(tc.method, tc.path, tc.body, reader)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.path, w.Code, http.StatusForbidden)
+			continue
+		}
+		var body struct {
+			Code    string         `json:"code"`
+			Details map[string]any `json:"details"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Code != "INSUFFICIENT_PERMISSION" || body.Details["required_permission"] != string(tc.want) {
+			t.Errorf("%s %s: unexpected error %s", tc.method, tc.path, w.Body.String())
+		}
+	}
+
+	if w := h.Do(http.MethodGet, "/api/admin/schema", "", "not-a-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("invalid token: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
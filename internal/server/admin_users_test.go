@@ -0,0 +1,42 @@
+package server_test
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/pkg/alyxtest"
+)
+
+func TestAdminUserExport(t *testing.T) {
+	h := alyxtest.New(t, testSchema)
+	admin := h.CreateUser("admin@example.com", "admin")
+	h.CreateUser("ada@example.com", "user")
+	h.CreateUser("grace@example.com", "user")
+
+	w := h.Do(http.MethodGet, "/api/admin/users/export?format=csv&role=user&sort_by=email&sort_dir=asc", "", h.Token(admin))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: status %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected a CSV content type, got %q", ct)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][1] != "ada@example.com" || records[2][1] != "grace@example.com" {
+		t.Fatalf("unexpected export: %v", records)
+	}
+	for _, column := range records[0] {
+		if strings.Contains(column, "password") {
+			t.Errorf("export must not include %s", column)
+		}
+	}
+
+	if w := h.Do(http.MethodGet, "/api/admin/users/export?format=xlsx", "", h.Token(admin)); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unsupported format, got %d", w.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package server_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/pkg/alyxtest"
+)
+
+// scrapeMetric returns the value of the sample named series, such as
+// alyx_auth_logins_total{result="success"}, in the /metrics output.
+func scrapeMetric(t *testing.T, handler http.Handler, series string) float64 {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", w.Code)
+	}
+
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || name != series {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", series, err)
+		}
+		return v
+	}
+	return 0
+}
+
+func postJSON(handler http.Handler, path, body string) int {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAuthMetrics(t *testing.T) {
+	// Metrics are global, so this test must not run in parallel.
+	handler := alyxtest.New(t, testSchema).Handler()
+
+	const (
+		successes    = `alyx_auth_logins_total{result="success"}`
+		failures     = `alyx_auth_logins_total{result="failure"}`
+		registration = `alyx_auth_registrations_total`
+		rateLimited  = `alyx_auth_rate_limited_total{endpoint="/api/auth/login"}`
+	)
+	startSuccesses := scrapeMetric(t, handler, successes)
+	startFailures := scrapeMetric(t, handler, failures)
+	startRegistrations := scrapeMetric(t, handler, registration)
+	startLimited := scrapeMetric(t, handler, rateLimited)
+
+	creds := `{"email":"metrics@example.com","password":"SecurePass123!"}`
+	if code := postJSON(handler, "/api/auth/register", creds); code != http.StatusCreated {
+		t.Fatalf("register: status %d", code)
+	}
+	if got := scrapeMetric(t, handler, registration) - startRegistrations; got != 1 {
+		t.Errorf("registrations moved by %v, want 1", got)
+	}
+
+	if code := postJSON(handler, "/api/auth/login", creds); code != http.StatusOK {
+		t.Fatalf("login: status %d", code)
+	}
+	if code := postJSON(handler, "/api/auth/login", `{"email":"metrics@example.com","password":"wrong-password"}`); code != http.StatusUnauthorized {
+		t.Fatalf("bad login: status %d", code)
+	}
+	if got := scrapeMetric(t, handler, successes) - startSuccesses; got != 1 {
+		t.Errorf("successful logins moved by %v, want 1", got)
+	}
+	if got := scrapeMetric(t, handler, failures) - startFailures; got != 1 {
+		t.Errorf("failed logins moved by %v, want 1", got)
+	}
+
+	// The test server allows 5 logins a minute; two have been used.
+	for range 3 {
+		postJSON(handler, "/api/auth/login", creds)
+	}
+	if code := postJSON(handler, "/api/auth/login", creds); code != http.StatusTooManyRequests {
+		t.Fatalf("limited login: status %d", code)
+	}
+	if got := scrapeMetric(t, handler, rateLimited) - startLimited; got != 1 {
+		t.Errorf("rate limited logins moved by %v, want 1", got)
+	}
+}
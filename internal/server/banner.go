@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/watzon/alyx/internal/buildinfo"
+)
+
+// banner returns the summary printed when the server starts listening on
+// addr: where it is reachable, its database and which subsystems are on.
+func (s *Server) banner(addr net.Addr) string {
+	serverCfg := s.cfg.Server
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		serverCfg.Port = tcp.Port
+	}
+	url := serverCfg.URL()
+
+	info := buildinfo.Get(nil)
+	header := "alyx " + info.Version
+	if info.Commit != "" {
+		header += " (" + info.Commit + ")"
+	}
+
+	database := "sqlite " + s.cfg.Database.Path
+	if turso := s.cfg.Database.Turso; turso != nil && turso.Enabled {
+		database += ", turso " + turso.URL
+	}
+
+	functions := "disabled"
+	if s.funcService != nil {
+		functions = "enabled"
+	} else if s.cfg.Functions.Enabled {
+		functions = "unavailable"
+	}
+
+	realtime := "disabled"
+	if s.broker != nil {
+		realtime = "enabled (" + s.cfg.Realtime.Mode + ")"
+	}
+
+	storage := "none"
+	if len(s.storageBackends) > 0 {
+		backends := slices.Clone(s.storageBackends)
+		slices.Sort(backends)
+		storage = strings.Join(backends, ", ")
+	}
+
+	docs := "disabled"
+	if s.cfg.Docs.Enabled {
+		docs = url + "/api/docs"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n  %s\n\n", header)
+	for _, line := range [][2]string{
+		{"Listening", url},
+		{"Database", database},
+		{"Functions", functions},
+		{"Realtime", realtime},
+		{"Storage", storage},
+		{"Docs", docs},
+	} {
+		fmt.Fprintf(&b, "  %-10s %s\n", line[0], line[1])
+	}
+	b.WriteString("\n")
+	return b.String()
+}
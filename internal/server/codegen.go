@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/codegen"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func regenerateClients(s *schema.Schema, cfg *config.Config) {
+	languages := make([]codegen.Language, 0, len(cfg.Dev.GenerateLanguages))
+	for _, langStr := range cfg.Dev.GenerateLanguages {
+		lang, err := codegen.ParseLanguage(langStr)
+		if err != nil {
+			log.Warn().Str("language", langStr).Msg("Unknown language, skipping")
+			continue
+		}
+		languages = append(languages, lang)
+	}
+
+	if len(languages) == 0 {
+		return
+	}
+
+	genCfg := &codegen.Config{
+		OutputDir:   cfg.Dev.GenerateOutput,
+		Languages:   languages,
+		ServerURL:   fmt.Sprintf("http://%s:%d", cfg.Server.Host, cfg.Server.Port),
+		PackageName: "alyx",
+		Dates:       cfg.Dev.GenerateDates,
+	}
+
+	if genCfg.OutputDir == "" {
+		genCfg.OutputDir = "./generated"
+	}
+
+	if err := codegen.GenerateAll(genCfg, s); err != nil {
+		log.Error().Err(err).Msg("Failed to regenerate client SDKs")
+		return
+	}
+
+	log.Info().
+		Strs("languages", cfg.Dev.GenerateLanguages).
+		Str("output", genCfg.OutputDir).
+		Msg("Client SDKs regenerated")
+}
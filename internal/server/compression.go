@@ -0,0 +1,282 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/server/handlers"
+)
+
+// brotliLevel trades ratio for speed. At level 1 brotli matches gzip's
+// latency on a 1MB JSON body with a better ratio; higher levels cost several
+// times more time for little gain (see BenchmarkCompression).
+const brotliLevel = 1
+
+// encoder is the common interface of the gzip and brotli writers.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(nil, brotliLevel)
+	}},
+}
+
+// compressedTypes are content types that gain nothing from compression,
+// even if a configured type pattern matches them.
+var compressedTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip",
+	"application/zstd", "application/x-brotli", "application/x-7z-compressed",
+	"application/vnd.rar", "application/pdf",
+}
+
+// CompressionMiddleware compresses responses with gzip or brotli, negotiated
+// via Accept-Encoding. Only bodies of a configured type and at least MinSize
+// bytes are compressed; WebSocket upgrades, event streams and HEAD requests
+// pass through untouched. Strong ETags on compressed responses are made weak,
+// since the compressed bytes differ from the representation they identify.
+func CompressionMiddleware(cfg config.CompressionConfig) Middleware {
+	types := make([]string, 0, len(cfg.Types))
+	for _, t := range cfg.Types {
+		types = append(types, strings.ToLower(strings.TrimSpace(t)))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" ||
+				strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       negotiateEncoding(r.Header.Get("Accept-Encoding")),
+				minSize:        cfg.MinSize,
+				types:          types,
+			}
+			defer cw.finish()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks br or gzip from an Accept-Encoding header,
+// preferring br when both are equally acceptable. It returns "" if neither
+// is accepted.
+func negotiateEncoding(header string) string {
+	brQ, gzipQ, anyQ := -1.0, -1.0, -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "br":
+			brQ = q
+		case "gzip":
+			gzipQ = q
+		case "*":
+			anyQ = q
+		}
+	}
+	if brQ < 0 {
+		brQ = anyQ
+	}
+	if gzipQ < 0 {
+		gzipQ = anyQ
+	}
+
+	switch {
+	case brQ > 0 && brQ >= gzipQ:
+		return "br"
+	case gzipQ > 0:
+		return "gzip"
+	default:
+		return ""
+	}
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// body is worth compressing, then writes it through an encoder or directly.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	types    []string
+
+	status   int
+	buf      []byte
+	decided  bool
+	hijacked bool
+	enc      encoder
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		_ = w.decide()
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide writes the header, with compression headers if the buffered body
+// qualifies, and flushes the buffer.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	h := w.Header()
+
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	compressible := w.compressible(h.Get("Content-Type"))
+	if compressible {
+		handlers.AddVary(h, "Accept-Encoding")
+	}
+
+	if compressible && w.encoding != "" && len(w.buf) >= w.minSize &&
+		w.status != http.StatusPartialContent && h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.enc = encoderPools[w.encoding].Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// compressible reports whether contentType matches a configured type and is
+// not already compressed.
+func (w *compressWriter) compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		return false
+	}
+	for _, prefix := range compressedTypes {
+		if strings.HasPrefix(mediaType, prefix) && mediaType != "image/svg+xml" {
+			return false
+		}
+	}
+	for _, t := range w.types {
+		if t == mediaType || t == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// finish writes a response that never reached minSize and closes the
+// encoder.
+func (w *compressWriter) finish() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided && w.status != 0 {
+		_ = w.decide()
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		w.enc.Reset(nil)
+		encoderPools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// Flush implements http.Flusher. Flushing commits to the compression
+// decision made on what has been written so far.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		_ = w.decide()
+	}
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker to support WebSocket upgrades.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("underlying ResponseWriter does not implement http.Hijacker")
+	}
+	w.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+func testCompressionConfig() config.CompressionConfig {
+	return config.CompressionConfig{
+		Enabled: true,
+		MinSize: 1024,
+		Types:   []string{"application/json", "text/plain"},
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"identity", ""},
+		{"gzip;q=0, *;q=0.1", "br"},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"title":"hello world"},`, 100)
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		contentType    string
+		body           string
+		headers        map[string]string
+		wantEncoding   string
+		wantVary       bool
+	}{
+		{name: "gzip", acceptEncoding: "gzip", contentType: "application/json", body: large, wantEncoding: "gzip", wantVary: true},
+		{name: "brotli", acceptEncoding: "gzip, br", contentType: "application/json; charset=utf-8", body: large, wantEncoding: "br", wantVary: true},
+		{name: "below min size", acceptEncoding: "gzip", contentType: "application/json", body: `{"ok":true}`, wantVary: true},
+		{name: "not accepted", contentType: "application/json", body: large, wantVary: true},
+		{name: "type not configured", acceptEncoding: "gzip", contentType: "text/html", body: large},
+		{name: "already compressed type", acceptEncoding: "gzip", contentType: "image/png", body: large},
+		{name: "already encoded", acceptEncoding: "gzip", contentType: "application/json", body: large, headers: map[string]string{"Content-Encoding": "zstd"}, wantEncoding: "zstd", wantVary: true},
+		{name: "head", method: http.MethodHead, acceptEncoding: "gzip", contentType: "application/json", body: large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CompressionMiddleware(testCompressionConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(http.StatusCreated)
+				// Write in small chunks to exercise buffering up to min size.
+				for i := 0; i < len(tt.body); i += 100 {
+					_, _ = io.WriteString(w, tt.body[i:min(i+100, len(tt.body))])
+				}
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/api/collections/posts", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Errorf("expected status 201, got %d", w.Code)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("expected Content-Encoding %q, got %q", tt.wantEncoding, got)
+			}
+			if got := w.Header().Get("Vary") == "Accept-Encoding"; got != tt.wantVary {
+				t.Errorf("expected Vary Accept-Encoding %v, got %q", tt.wantVary, w.Header().Get("Vary"))
+			}
+
+			var body io.Reader = w.Body
+			switch tt.wantEncoding {
+			case "gzip":
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("invalid gzip body: %v", err)
+				}
+				body = zr
+			case "br":
+				body = brotli.NewReader(w.Body)
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+			if string(got) != tt.body {
+				t.Errorf("body mismatch: got %d bytes, want %d", len(got), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressionMiddleware_ETag(t *testing.T) {
+	handler := CompressionMiddleware(testCompressionConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Vary", "Origin")
+		_, _ = w.Write(bytes.Repeat([]byte("a"), 2048))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get("ETag"); got != `W/"abc"` {
+		t.Errorf("expected weakened ETag, got %q", got)
+	}
+	if got := w.Header().Values("Vary"); len(got) != 2 || got[1] != "Accept-Encoding" {
+		t.Errorf("expected Accept-Encoding appended to Vary, got %v", got)
+	}
+}
+
+func TestCompressionMiddleware_SkipsUpgradeAndEventStream(t *testing.T) {
+	handler := CompressionMiddleware(testCompressionConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(*compressWriter); ok {
+			t.Error("expected response writer not to be wrapped")
+		}
+	}))
+
+	for _, header := range []map[string]string{
+		{"Upgrade": "websocket", "Connection": "Upgrade"},
+		{"Accept": "text/event-stream"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestCompressionMiddleware_NoContent(t *testing.T) {
+	handler := CompressionMiddleware(testCompressionConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent || w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 0 {
+		t.Errorf("expected bare 204, got %d %q with %d bytes", w.Code, w.Header().Get("Content-Encoding"), w.Body.Len())
+	}
+}
+
+// BenchmarkCompression shows the latency cost of compressing a 1MB JSON
+// list response with each encoding. Compare ns/op against the identity case
+// and the reported ratio to judge the tradeoff for a given network.
+func BenchmarkCompression(b *testing.B) {
+	docs := make([]map[string]any, 0, 5000)
+	for i := range cap(docs) {
+		docs = append(docs, map[string]any{
+			"id":         fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+			"title":      fmt.Sprintf("Post number %d", i),
+			"body":       "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor.",
+			"published":  i%2 == 0,
+			"created_at": "2024-01-01T00:00:00Z",
+		})
+	}
+	payload, _ := json.Marshal(map[string]any{"docs": docs, "total": len(docs)})
+	payload = payload[:min(len(payload), 1<<20)]
+
+	handler := CompressionMiddleware(testCompressionConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(payload)
+	}))
+
+	for _, encoding := range []string{"identity", "gzip", "br"} {
+		b.Run(encoding, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, "/api/collections/posts", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+
+			var size int
+			for b.Loop() {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				size = w.Body.Len()
+			}
+			b.ReportMetric(float64(size)/float64(len(payload)), "ratio")
+		})
+	}
+}
@@ -2,11 +2,15 @@ package server
 
 import (
 	"context"
+	"reflect"
+	"slices"
+	"sort"
 	"sync"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/watzon/alyx/internal/functions"
+	"github.com/watzon/alyx/internal/requestctx"
 )
 
 type DatabaseHookTrigger struct {
@@ -20,6 +24,9 @@ type DatabaseHook struct {
 	FunctionName string
 	Action       string // insert, update, delete
 	Mode         string // sync, async
+	// Fields, when set, limits update events to updates that change at
+	// least one of them.
+	Fields []string
 }
 
 func NewDatabaseHookTrigger(funcService *functions.Service) *DatabaseHookTrigger {
@@ -48,6 +55,7 @@ func (t *DatabaseHookTrigger) loadHooksFromFunctions() {
 				FunctionName: fn.Name,
 				Action:       hook.Action,
 				Mode:         hook.Mode,
+				Fields:       hook.Fields,
 			}
 			if dbHook.Mode == "" {
 				dbHook.Mode = "async"
@@ -60,6 +68,7 @@ func (t *DatabaseHookTrigger) loadHooksFromFunctions() {
 				Str("collection", hook.Source).
 				Str("action", hook.Action).
 				Str("mode", dbHook.Mode).
+				Strs("fields", dbHook.Fields).
 				Msg("Database hook registered")
 		}
 	}
@@ -75,10 +84,11 @@ func (t *DatabaseHookTrigger) OnInsert(ctx context.Context, collection string, d
 
 func (t *DatabaseHookTrigger) OnUpdate(ctx context.Context, collection string, document, previousDocument map[string]any) error {
 	return t.executeHooks(ctx, collection, "update", map[string]any{
-		"document":   document,
-		"previous":   previousDocument,
-		"collection": collection,
-		"action":     "update",
+		"document":       document,
+		"previous":       previousDocument,
+		"collection":     collection,
+		"action":         "update",
+		"changed_fields": changedFields(document, previousDocument),
 	})
 }
 
@@ -95,8 +105,14 @@ func (t *DatabaseHookTrigger) executeHooks(ctx context.Context, collection, acti
 	hooks := t.hooks[collection]
 	t.mu.RUnlock()
 
+	requestID := requestctx.RequestID(ctx)
+	input["metadata"] = map[string]any{
+		"request_id": requestID,
+	}
+
+	changed, _ := input["changed_fields"].([]string)
 	for _, hook := range hooks {
-		if hook.Action != action && hook.Action != "*" {
+		if !hook.matches(action, changed) {
 			continue
 		}
 
@@ -105,12 +121,13 @@ func (t *DatabaseHookTrigger) executeHooks(ctx context.Context, collection, acti
 			Str("collection", collection).
 			Str("action", action).
 			Str("mode", hook.Mode).
+			Str("request_id", requestID).
 			Msg("Executing database hook")
 
 		if hook.Mode == "sync" {
 			resp, err := t.funcService.Invoke(ctx, hook.FunctionName, input, nil)
 			if err != nil {
-				log.Error().Err(err).Str("function", hook.FunctionName).Msg("Sync hook failed")
+				log.Error().Err(err).Str("function", hook.FunctionName).Str("request_id", requestID).Msg("Sync hook failed")
 				return err
 			}
 			if !resp.Success {
@@ -121,12 +138,14 @@ func (t *DatabaseHookTrigger) executeHooks(ctx context.Context, collection, acti
 					Msg("Sync hook returned error")
 			}
 		} else {
+			// Detach from the request's cancellation but keep its values (request ID).
+			asyncCtx := context.WithoutCancel(ctx)
 			t.wg.Add(1)
 			go func(hookCopy DatabaseHook) {
 				defer t.wg.Done()
-				resp, err := t.funcService.Invoke(context.Background(), hookCopy.FunctionName, input, nil)
+				resp, err := t.funcService.Invoke(asyncCtx, hookCopy.FunctionName, input, nil)
 				if err != nil {
-					log.Error().Err(err).Str("function", hookCopy.FunctionName).Msg("Async hook failed")
+					log.Error().Err(err).Str("function", hookCopy.FunctionName).Str("request_id", requestID).Msg("Async hook failed")
 					return
 				}
 				if !resp.Success {
@@ -148,6 +167,41 @@ func (t *DatabaseHookTrigger) executeHooks(ctx context.Context, collection, acti
 	return nil
 }
 
+// matches reports whether the hook runs for an action. Updates must also
+// change one of the hook's fields, if it lists any.
+func (h DatabaseHook) matches(action string, changed []string) bool {
+	if h.Action != action && h.Action != "*" {
+		return false
+	}
+	if action != "update" || len(h.Fields) == 0 {
+		return true
+	}
+	for _, field := range h.Fields {
+		if slices.Contains(changed, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedFields returns the sorted names of the fields whose values differ
+// between document and previous.
+func changedFields(document, previous map[string]any) []string {
+	changed := []string{}
+	for name, value := range document {
+		if old, ok := previous[name]; !ok || !reflect.DeepEqual(old, value) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previous {
+		if _, ok := document[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
 func (t *DatabaseHookTrigger) Reload() {
 	t.mu.Lock()
 	t.hooks = make(map[string][]DatabaseHook)
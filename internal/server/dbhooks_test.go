@@ -0,0 +1,38 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDatabaseHookFields(t *testing.T) {
+	previous := map[string]any{"id": "p1", "title": "Draft", "status": "draft", "published": false}
+	retitled := map[string]any{"id": "p1", "title": "Final", "status": "draft", "published": false}
+	published := map[string]any{"id": "p1", "title": "Draft", "status": "live", "published": true}
+
+	if got := changedFields(retitled, previous); !reflect.DeepEqual(got, []string{"title"}) {
+		t.Errorf("changedFields = %v, want [title]", got)
+	}
+	if got := changedFields(published, previous); !reflect.DeepEqual(got, []string{"published", "status"}) {
+		t.Errorf("changedFields = %v, want [published status]", got)
+	}
+
+	hook := DatabaseHook{FunctionName: "notify", Action: "*", Fields: []string{"status", "published"}}
+	if hook.matches("update", changedFields(retitled, previous)) {
+		t.Error("expected an update of unrelated fields not to trigger the hook")
+	}
+	if !hook.matches("update", changedFields(published, previous)) {
+		t.Error("expected an update of a listed field to trigger the hook")
+	}
+	if !hook.matches("insert", nil) || !hook.matches("delete", nil) {
+		t.Error("expected inserts and deletes to ignore the fields filter")
+	}
+
+	unfiltered := DatabaseHook{FunctionName: "audit", Action: "update"}
+	if !unfiltered.matches("update", changedFields(retitled, previous)) {
+		t.Error("expected a hook without fields to run on every update")
+	}
+	if unfiltered.matches("insert", nil) {
+		t.Error("expected an update hook not to run on inserts")
+	}
+}
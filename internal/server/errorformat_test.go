@@ -0,0 +1,55 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/server/handlers"
+	"github.com/watzon/alyx/pkg/alyxtest"
+)
+
+// Errors written by middleware, before any handler runs, must follow
+// server.error_format like the handlers' own.
+func TestMiddlewareErrorsUseErrorFormat(t *testing.T) {
+	// The error format is global, so this test must not run in parallel.
+	t.Cleanup(func() { handlers.SetErrorFormat(config.ErrorFormatAlyx) })
+	h := alyxtest.New(t, testSchema, alyxtest.WithConfig(func(cfg *config.Config) {
+		cfg.Server.ErrorFormat = config.ErrorFormatProblem
+	}))
+	handler := h.Handler()
+
+	expectProblem := func(w *httptest.ResponseRecorder, status int, code string) {
+		t.Helper()
+		if w.Code != status {
+			t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("%s: expected application/problem+json, got %q", code, ct)
+		}
+		var problem handlers.ProblemDetails
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("%s: decode problem: %v", code, err)
+		}
+		if problem.Code != code || problem.Status != status || problem.Type != handlers.ProblemType(code) {
+			t.Errorf("unexpected problem for %s: %+v", code, problem)
+		}
+	}
+
+	expectProblem(h.Do(http.MethodGet, "/api/auth/me", "", ""), http.StatusUnauthorized, "UNAUTHORIZED")
+	expectProblem(h.Do(http.MethodGet, "/api/auth/me", "", "not-a-token"), http.StatusUnauthorized, "INVALID_TOKEN")
+
+	// The test server allows 5 logins a minute.
+	var w *httptest.ResponseRecorder
+	for range 6 {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"email":"nobody@example.com","password":"wrong-password"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "192.0.2.2:1234"
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+	}
+	expectProblem(w, http.StatusTooManyRequests, "RATE_LIMITED")
+}
@@ -0,0 +1,118 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/pkg/alyxtest"
+)
+
+const exposeSchema = `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+    rules:
+      create: "true"
+      read: "true"
+  jobs:
+    expose: false
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      payload:
+        type: string
+    rules:
+      create: "true"
+      read: "true"
+      update: "true"
+      delete: "true"
+`
+
+func TestUnexposedCollection(t *testing.T) {
+	t.Parallel()
+	h := alyxtest.New(t, exposeSchema)
+	user := h.Token(h.CreateUser("user@example.com", "user"))
+	admin := h.Token(h.CreateUser("admin@example.com", "admin"))
+
+	if w := h.Do(http.MethodPost, "/api/collections/posts", `{"title":"hi"}`, user); w.Code != http.StatusCreated {
+		t.Fatalf("create post: status %d: %s", w.Code, w.Body.String())
+	}
+
+	// Every REST route for the collection answers as if it did not exist,
+	// whatever its rules allow.
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodGet, "/api/collections/jobs", ""},
+		{http.MethodPost, "/api/collections/jobs", `{"payload":"x"}`},
+		{http.MethodGet, "/api/collections/jobs/abc", ""},
+		{http.MethodPatch, "/api/collections/jobs/abc", `{"payload":"y"}`},
+		{http.MethodDelete, "/api/collections/jobs/abc", ""},
+		{http.MethodPut, "/api/collections/jobs/upsert", `{"payload":"x"}`},
+	} {
+		for _, token := range []string{"", user} {
+			w := h.Do(req.method, req.path, req.body, token)
+			if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "COLLECTION_NOT_FOUND") {
+				t.Errorf("%s %s: expected 404 COLLECTION_NOT_FOUND, got %d: %s", req.method, req.path, w.Code, w.Body.String())
+			}
+		}
+	}
+
+	// Admins still reach it, which is how the admin data browser works.
+	w := h.Do(http.MethodPost, "/api/collections/jobs", `{"payload":"x"}`, admin)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("admin create job: status %d: %s", w.Code, w.Body.String())
+	}
+	if w := h.Do(http.MethodGet, "/api/collections/jobs", "", admin); w.Code != http.StatusOK {
+		t.Errorf("admin list jobs: status %d: %s", w.Code, w.Body.String())
+	}
+
+	w = h.Do(http.MethodGet, "/api/openapi.json", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("openapi: status %d", w.Code)
+	}
+	var spec struct {
+		Paths map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.Paths["/api/collections/posts"] == nil {
+		t.Error("expected posts in the spec")
+	}
+	for path := range spec.Paths {
+		if strings.Contains(path, "jobs") {
+			t.Errorf("unexposed collection in spec: %s", path)
+		}
+	}
+
+	w = h.Do(http.MethodGet, "/api/admin/schema", "", admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("admin schema: status %d: %s", w.Code, w.Body.String())
+	}
+	var schemaResp struct {
+		Collections []struct {
+			Name   string `json:"name"`
+			Expose bool   `json:"expose"`
+		} `json:"collections"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &schemaResp); err != nil {
+		t.Fatal(err)
+	}
+	exposed := make(map[string]bool)
+	for _, c := range schemaResp.Collections {
+		exposed[c.Name] = c.Expose
+	}
+	if !exposed["posts"] || exposed["jobs"] {
+		t.Errorf("expected posts exposed and jobs not, got %v", exposed)
+	}
+}
@@ -1,24 +1,42 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/http"
+	"net/mail"
+	"net/url"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/google/cel-go/cel"
 	"github.com/rs/zerolog/log"
 
+	"github.com/watzon/alyx/internal/advisor"
 	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/changefeed"
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database"
 	"github.com/watzon/alyx/internal/deploy"
+	"github.com/watzon/alyx/internal/email"
+	"github.com/watzon/alyx/internal/events"
+	"github.com/watzon/alyx/internal/fake"
 	"github.com/watzon/alyx/internal/functions"
+	"github.com/watzon/alyx/internal/realtime"
+	"github.com/watzon/alyx/internal/retention"
+	"github.com/watzon/alyx/internal/rules"
 	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/server/requestlog"
 	"github.com/watzon/alyx/internal/storage"
 )
 
@@ -35,8 +53,21 @@ type AdminHandlers struct {
 	startTime     time.Time
 	pendingStore  *schema.PendingChangesStore
 	migrator      *schema.Migrator
+	applySchedule *schema.ScheduledApplyStore
+	maintenance   func(bool)
+	sandboxReset  func(context.Context) (string, error)
+	scheduleStop  chan struct{}
+	scheduleWG    sync.WaitGroup
 	draftSchemas  map[string]string // session_id -> draft YAML content
 	schemaManager *schema.Manager
+	retention     *retention.Service
+	changeFeed    *changefeed.Feed
+	storage       *storage.Service
+	mailer        *email.Mailer
+	broker        *realtime.Broker
+	requestLogs   *requestlog.Store
+	schemaApplied func(s *schema.Schema, collections []string) error
+	events        events.Publisher
 }
 
 // NewAdminHandlers creates new admin handlers.
@@ -52,6 +83,7 @@ func NewAdminHandlers(deployService *deploy.Service, authService *auth.Service,
 		configPath:    configPath,
 		startTime:     time.Now(),
 		draftSchemas:  make(map[string]string),
+		events:        events.Nop,
 	}
 
 	if db != nil && db.DB != nil {
@@ -60,6 +92,10 @@ func NewAdminHandlers(deployService *deploy.Service, authService *auth.Service,
 			log.Error().Err(err).Msg("Failed to initialize pending changes store")
 		}
 		h.migrator = schema.NewMigrator(db.DB, schemaPath, "")
+		h.applySchedule = schema.NewScheduledApplyStore(db.DB)
+		if err := h.applySchedule.Init(); err != nil {
+			log.Error().Err(err).Msg("Failed to initialize scheduled apply store")
+		}
 	}
 
 	if schemaPath != "" {
@@ -69,120 +105,940 @@ func NewAdminHandlers(deployService *deploy.Service, authService *auth.Service,
 		}
 	}
 
-	return h
+	return h
+}
+
+// SetRetentionService sets the retention service used for retention previews.
+func (h *AdminHandlers) SetRetentionService(svc *retention.Service) {
+	h.retention = svc
+}
+
+// SetChangeFeed sets the change feed served by ChangeFeed.
+func (h *AdminHandlers) SetChangeFeed(feed *changefeed.Feed) {
+	h.changeFeed = feed
+}
+
+// SetStorageService sets the storage service orphaned file cleanup runs on.
+func (h *AdminHandlers) SetStorageService(svc *storage.Service) {
+	h.storage = svc
+}
+
+// SetMailer sets the mailer used for test emails.
+func (h *AdminHandlers) SetMailer(m *email.Mailer) {
+	h.mailer = m
+}
+
+// SetBroker sets the realtime broker inspected by the realtime endpoints.
+func (h *AdminHandlers) SetBroker(b *realtime.Broker) {
+	h.broker = b
+}
+
+// SetRequestLogs sets the request log store the index advisor reads.
+func (h *AdminHandlers) SetRequestLogs(store *requestlog.Store) {
+	h.requestLogs = store
+}
+
+// SetMaintenance sets the function that turns maintenance mode on and off
+// around scheduled schema applies that ask for it.
+func (h *AdminHandlers) SetMaintenance(fn func(bool)) {
+	h.maintenance = fn
+}
+
+// SetSandboxReset sets the function that recreates the dev sandbox database
+// and returns its path. Without it, sandbox resets are not found.
+func (h *AdminHandlers) SetSandboxReset(fn func(context.Context) (string, error)) {
+	h.sandboxReset = fn
+}
+
+// SetSchemaApplied sets the function called with the new schema and the
+// changed collection names after a schema apply, deploy or rollback.
+func (h *AdminHandlers) SetSchemaApplied(fn func(s *schema.Schema, collections []string) error) {
+	h.schemaApplied = fn
+}
+
+// SetEventPublisher sets where deploy, schema and config events are
+// published, for the realtime _admin channel.
+func (h *AdminHandlers) SetEventPublisher(p events.Publisher) {
+	if p == nil {
+		p = events.Nop
+	}
+	h.events = p
+}
+
+// SchemaChangedEvent is the data of a schema.changed event.
+type SchemaChangedEvent struct {
+	// Collections names the collections whose shape changed.
+	Collections []string `json:"collections"`
+}
+
+// ConfigUpdatedEvent is the data of a config.updated event.
+type ConfigUpdatedEvent struct {
+	Path string `json:"path"`
+	// Changed lists the fields set, as in the PATCH /api/admin/config
+	// response. It is absent when the whole file was replaced.
+	Changed []string `json:"changed,omitempty"`
+	// By names the admin token or user that made the change.
+	By string `json:"by"`
+}
+
+// notifySchemaApplied passes an applied schema to the schemaApplied function
+// and publishes a schema.changed event.
+func (h *AdminHandlers) notifySchemaApplied(s *schema.Schema, collections []string) {
+	if s == nil {
+		return
+	}
+	h.publishSchemaChanged(collections)
+	if h.schemaApplied == nil {
+		return
+	}
+	if err := h.schemaApplied(s, collections); err != nil {
+		log.Error().Err(err).Msg("Failed to reload applied schema")
+	}
+}
+
+func (h *AdminHandlers) publishSchemaChanged(collections []string) {
+	if collections == nil {
+		collections = []string{}
+	}
+	h.events.Publish(events.ChannelAdmin, events.SchemaChanged, &SchemaChangedEvent{Collections: collections})
+}
+
+// publishDeployExecuted publishes a deploy.executed event with the
+// deployment as recorded in the history.
+func (h *AdminHandlers) publishDeployExecuted(version string) {
+	deployment, err := h.deployService.Store().GetDeployment(version)
+	if err != nil {
+		log.Error().Err(err).Str("version", version).Msg("Failed to load deployment for event")
+		return
+	}
+	h.events.Publish(events.ChannelAdmin, events.DeployExecuted, deployment)
+}
+
+func (h *AdminHandlers) publishConfigUpdated(changed []string, by string) {
+	h.events.Publish(events.ChannelAdmin, events.ConfigUpdated, &ConfigUpdatedEvent{
+		Path:    h.configPath,
+		Changed: changed,
+		By:      by,
+	})
+}
+
+// requireAdminAuth validates either a JWT token from an admin user or a deploy token.
+// JWT-authenticated admin users have all permissions. Tokens created with
+// require_signing must sign the request; see deploy.SignRequest.
+func (h *AdminHandlers) requireAdminAuth(r *http.Request, perm deploy.TokenPermission) (*deploy.AdminToken, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, errors.New("missing authorization header")
+	}
+
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return nil, errors.New("invalid authorization header format")
+	}
+
+	tokenStr := parts[1]
+
+	if keyID, ok := deploy.SigningKeyID(tokenStr); ok {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		token, err := h.deployService.ValidateSignedRequest(keyID, r, body)
+		if err != nil {
+			return nil, err
+		}
+		return tokenWithPermission(token, perm)
+	}
+
+	if h.authService != nil {
+		claims, err := h.authService.ValidateToken(tokenStr)
+		if err == nil && claims != nil {
+			// Introspection reveals who other users' tokens belong to, so
+			// of signed-in users only admins may use it. Access tokens
+			// carry no role, so it is read from the user.
+			if perm == deploy.PermissionIntrospect {
+				user, err := h.authService.GetUserByID(r.Context(), claims.UserID)
+				if err != nil || !user.IsAdmin() {
+					return nil, &permissionError{required: perm}
+				}
+			}
+			return &deploy.AdminToken{
+				Name:        "jwt:" + claims.Email,
+				Permissions: []string{string(deploy.PermissionAdmin), string(deploy.PermissionDeploy), string(deploy.PermissionRollback)},
+			}, nil
+		}
+	}
+
+	token, err := h.deployService.ValidateToken(tokenStr)
+	if errors.Is(err, deploy.ErrTokenExpired) || errors.Is(err, deploy.ErrSignatureRequired) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, deploy.ErrInvalidToken
+	}
+
+	return tokenWithPermission(token, perm)
+}
+
+func tokenWithPermission(token *deploy.AdminToken, perm deploy.TokenPermission) (*deploy.AdminToken, error) {
+	if !token.HasPermission(perm) {
+		return nil, &permissionError{required: perm}
+	}
+	return token, nil
+}
+
+// RequirePermission wraps a handler that lives outside AdminHandlers, such as
+// the request log endpoints, in the same authentication as the admin
+// endpoints.
+func (h *AdminHandlers) RequirePermission(perm deploy.TokenPermission, next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.requireAdminAuth(r, perm); err != nil {
+			adminAuthError(w, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// permissionError is returned by requireAdminAuth for a valid token that
+// lacks the permission the endpoint requires.
+type permissionError struct {
+	required deploy.TokenPermission
+}
+
+func (e *permissionError) Error() string {
+	return fmt.Sprintf("token lacks the %q permission", e.required)
+}
+
+// adminAuthError writes the response for a requireAdminAuth failure: 403
+// naming the missing permission for a valid token, 401 otherwise. Signed
+// request failures get their own error codes, so the request log tells a
+// stale timestamp from a bad signature.
+func adminAuthError(w http.ResponseWriter, err error) {
+	var permErr *permissionError
+	if errors.As(err, &permErr) {
+		ErrorWithDetails(w, http.StatusForbidden, "INSUFFICIENT_PERMISSION", err.Error(), map[string]any{
+			"required_permission": permErr.required,
+		})
+		return
+	}
+
+	code := "UNAUTHORIZED"
+	switch {
+	case errors.Is(err, deploy.ErrSignatureRequired):
+		code = "SIGNATURE_REQUIRED"
+	case errors.Is(err, deploy.ErrSignatureExpired):
+		code = "SIGNATURE_EXPIRED"
+	case errors.Is(err, deploy.ErrInvalidSignature):
+		code = "INVALID_SIGNATURE"
+	case errors.Is(err, deploy.ErrSignatureReplayed):
+		code = "SIGNATURE_REPLAYED"
+	}
+	Error(w, http.StatusUnauthorized, code, err.Error())
+}
+
+// invalidSchema writes the 400 response for a schema that failed to parse.
+// The details list each problem with its path and, when known, the line and
+// column, so the editor can mark them.
+func invalidSchema(w http.ResponseWriter, err error) {
+	if errs := schema.AsValidationErrors(err); errs != nil {
+		ErrorWithDetails(w, http.StatusBadRequest, "INVALID_SCHEMA", err.Error(), errs)
+		return
+	}
+	Error(w, http.StatusBadRequest, "INVALID_SCHEMA", err.Error())
+}
+
+func (h *AdminHandlers) Stats(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	uptime := int64(time.Since(h.startTime).Seconds())
+
+	var userCount int
+	row := h.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM _alyx_users")
+	_ = row.Scan(&userCount)
+
+	var docCount int
+	if h.schema != nil {
+		for _, col := range h.schema.Collections {
+			var count int
+			row := h.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM "+col.Name)
+			if row.Scan(&count) == nil {
+				docCount += count
+			}
+		}
+	}
+
+	var funcCount int
+	if h.funcService != nil {
+		funcCount = len(h.funcService.ListFunctions())
+	}
+
+	var collectionCount int
+	if h.schema != nil {
+		collectionCount = len(h.schema.Collections)
+	}
+
+	JSON(w, http.StatusOK, map[string]any{
+		"uptime":      uptime,
+		"collections": collectionCount,
+		"documents":   docCount,
+		"users":       userCount,
+		"functions":   funcCount,
+	})
+}
+
+func (h *AdminHandlers) StorageStats(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	store := storage.NewStore(h.db)
+	stats, err := store.GetBucketStats(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get bucket stats")
+		InternalError(w, "Failed to get bucket stats")
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]any{
+		"buckets": stats,
+	})
+}
+
+// StorageGCRequest is the body of POST /api/admin/storage/{bucket}/gc.
+type StorageGCRequest struct {
+	// DryRun lists the files that would be deleted without deleting them.
+	DryRun bool `json:"dry_run"`
+	// GracePeriod keeps files younger than it, e.g. "24h" or "7d" (default
+	// 24h).
+	GracePeriod string `json:"grace_period,omitempty"`
+	// Cursor resumes a scan from a previous response's next_cursor.
+	Cursor string `json:"cursor,omitempty"`
+	// Limit is the number of files to scan before returning a next_cursor.
+	// Zero scans the whole bucket.
+	Limit int `json:"limit,omitempty"`
+	// Async runs the scan in the background and returns a job to poll.
+	Async bool `json:"async,omitempty"`
+}
+
+// StorageGC handles POST /api/admin/storage/{bucket}/gc. It deletes the
+// bucket's files that no file field references and that are older than the
+// grace period, or lists them on a dry run.
+func (h *AdminHandlers) StorageGC(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.storage == nil {
+		Error(w, http.StatusServiceUnavailable, "STORAGE_UNAVAILABLE", "Storage service is not available")
+		return
+	}
+
+	bucket := r.PathValue("bucket")
+	if _, ok := h.schema.Buckets[bucket]; !ok {
+		Error(w, http.StatusNotFound, "BUCKET_NOT_FOUND", "Bucket not found")
+		return
+	}
+
+	var req StorageGCRequest
+	if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true, AllowEmpty: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
+	if req.Limit < 0 {
+		BadRequest(w, "limit must be non-negative")
+		return
+	}
+
+	opts := storage.GCOptions{DryRun: req.DryRun, Cursor: req.Cursor, Limit: req.Limit}
+	if req.GracePeriod != "" {
+		if opts.GracePeriod, err = schema.ParseRetentionAge(req.GracePeriod); err != nil {
+			BadRequest(w, fmt.Sprintf("invalid grace_period: %v", err))
+			return
+		}
+	}
+
+	if req.Async {
+		job, err := h.storage.StartGC(r.Context(), bucket, opts)
+		if err != nil {
+			log.Error().Err(err).Str("bucket", bucket).Msg("Failed to start orphaned file cleanup")
+			InternalError(w, "Failed to start orphaned file cleanup")
+			return
+		}
+		JSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	result, err := h.storage.CollectGarbage(r.Context(), bucket, opts, nil)
+	if err != nil {
+		log.Error().Err(err).Str("bucket", bucket).Msg("Orphaned file cleanup failed")
+		InternalError(w, "Orphaned file cleanup failed")
+		return
+	}
+	JSON(w, http.StatusOK, result)
+}
+
+// StorageGCJob handles GET /api/admin/storage/gc/{job}, reporting the
+// progress or result of a background cleanup.
+func (h *AdminHandlers) StorageGCJob(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.storage == nil {
+		Error(w, http.StatusServiceUnavailable, "STORAGE_UNAVAILABLE", "Storage service is not available")
+		return
+	}
+
+	job := h.storage.GCJob(r.PathValue("job"))
+	if job == nil {
+		Error(w, http.StatusNotFound, "JOB_NOT_FOUND", "Cleanup job not found")
+		return
+	}
+	JSON(w, http.StatusOK, job)
+}
+
+// RetentionPreview handles GET /api/admin/retention/preview.
+// It reports how many rows each retention policy would delete without deleting anything.
+func (h *AdminHandlers) RetentionPreview(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.retention == nil {
+		Error(w, http.StatusServiceUnavailable, "RETENTION_UNAVAILABLE", "Retention service is not available")
+		return
+	}
+
+	previews, err := h.retention.Preview(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to preview retention")
+		InternalError(w, "Failed to preview retention")
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]any{
+		"enabled":     h.cfg != nil && h.cfg.Retention.Enabled,
+		"collections": previews,
+	})
+}
+
+// ChangeFeed handles GET /api/admin/changes. It returns committed changes
+// after ?since_seq= in commit order, at most ?limit= at a time.
+func (h *AdminHandlers) ChangeFeed(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.changeFeed == nil {
+		Error(w, http.StatusServiceUnavailable, "CHANGES_UNAVAILABLE", "Change feed is not enabled")
+		return
+	}
+
+	var since int64
+	if v := r.URL.Query().Get("since_seq"); v != "" {
+		since, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || since < 0 {
+			Error(w, http.StatusBadRequest, "INVALID_SINCE_SEQ", "since_seq must be a non-negative integer")
+			return
+		}
+	}
+
+	limit := changefeed.DefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > changefeed.MaxLimit {
+			Error(w, http.StatusBadRequest, "INVALID_LIMIT", fmt.Sprintf("limit must be between 1 and %d", changefeed.MaxLimit))
+			return
+		}
+	}
+
+	batch, err := h.changeFeed.Read(r.Context(), since, limit)
+	if errors.Is(err, changefeed.ErrCursorExpired) {
+		Error(w, http.StatusGone, "CURSOR_EXPIRED", "Changes after since_seq have been pruned; restart from a full export")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read change feed")
+		InternalError(w, "Failed to read change feed")
+		return
+	}
+
+	JSON(w, http.StatusOK, batch)
+}
+
+// IndexAdvisor handles GET /api/admin/advisor/indexes. It recommends indexes
+// for the list queries in the request log, optionally limited to those newer
+// than ?since= (a duration such as 1h).
+func (h *AdminHandlers) IndexAdvisor(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.requestLogs == nil || h.schema == nil || h.db == nil {
+		Error(w, http.StatusServiceUnavailable, "ADVISOR_UNAVAILABLE", "Index advisor is not available")
+		return
+	}
+
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			Error(w, http.StatusBadRequest, "INVALID_SINCE", "since must be a positive duration, e.g. 1h")
+			return
+		}
+		since = time.Now().Add(-d)
+	}
+
+	opts := advisor.Options{}
+	if v := r.URL.Query().Get("min_rows"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			Error(w, http.StatusBadRequest, "INVALID_MIN_ROWS", "min_rows must be a non-negative integer")
+			return
+		}
+		opts.MinRows = n
+	}
+
+	report, err := advisor.Analyze(r.Context(), h.db, h.schema, h.requestLogs.ListQueries(since), opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to analyze indexes")
+		InternalError(w, "Failed to analyze indexes")
+		return
+	}
+
+	JSON(w, http.StatusOK, report)
+}
+
+// ExplainRequest is the request body for a query plan preview. Its fields
+// are the list endpoint's query parameters.
+type ExplainRequest struct {
+	Filter []string `json:"filter,omitempty"`
+	Sort   string   `json:"sort,omitempty"`
+	Search string   `json:"search,omitempty"`
+	Limit  *int     `json:"limit,omitempty"`
+	Offset *int     `json:"offset,omitempty"`
+}
+
+// values returns the request as list query parameters.
+func (req *ExplainRequest) values() url.Values {
+	values := url.Values{"filter": req.Filter}
+	if req.Sort != "" {
+		values.Set("sort", req.Sort)
+	}
+	if req.Search != "" {
+		values.Set("search", req.Search)
+	}
+	if req.Limit != nil {
+		values.Set("limit", strconv.Itoa(*req.Limit))
+	}
+	if req.Offset != nil {
+		values.Set("offset", strconv.Itoa(*req.Offset))
+	}
+	return values
+}
+
+// ExplainQuery handles POST /api/admin/collections/{name}/explain. It
+// returns SQLite's plan for the statement the list endpoint would run,
+// without running it.
+func (h *AdminHandlers) ExplainQuery(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.schema == nil || h.db == nil {
+		Error(w, http.StatusServiceUnavailable, "EXPLAIN_UNAVAILABLE", "Query plans are not available")
+		return
+	}
+
+	name := r.PathValue("name")
+	col, ok := h.schema.Collections[name]
+	if !ok {
+		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
+		return
+	}
+
+	var req ExplainRequest
+	if r.ContentLength != 0 {
+		if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true}); decodeErr != nil {
+			invalidJSON(w, r, decodeErr)
+			return
+		}
+	}
+
+	opts, _, err := parseListOptions(req.values(), col.List)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_QUERY", err.Error())
+		return
+	}
+	// Only the rows are explained; the total is counted separately.
+	opts.Total = database.TotalNone
+
+	plan, err := database.NewCollection(h.db, col).Explain(r.Context(), opts)
+	if err != nil {
+		// Unknown or encrypted fields fail here, so the message is the
+		// admin's to fix.
+		Error(w, http.StatusBadRequest, "INVALID_QUERY", err.Error())
+		return
+	}
+
+	JSON(w, http.StatusOK, plan)
+}
+
+// FakeRequest is the request body for generating documents. Window is a
+// duration such as 30d; Until is an RFC 3339 time. Without a seed, a random
+// one is used and returned in the report.
+type FakeRequest struct {
+	Count   int    `json:"count"`
+	Seed    *int64 `json:"seed,omitempty"`
+	Window  string `json:"window,omitempty"`
+	Until   string `json:"until,omitempty"`
+	Parents int    `json:"parents,omitempty"`
+	Tenant  string `json:"tenant,omitempty"`
+}
+
+// FakeDocuments handles POST /api/admin/collections/{name}/fake. It inserts
+// generated documents into the collection, and into the empty collections
+// it references, in dev mode only.
+func (h *AdminHandlers) FakeDocuments(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if !h.isDevMode() {
+		Error(w, http.StatusForbidden, "DEV_MODE_REQUIRED", "Generating documents is only available in development mode")
+		return
+	}
+	if h.schema == nil || h.db == nil {
+		Error(w, http.StatusServiceUnavailable, "FAKE_UNAVAILABLE", "Document generation is not available")
+		return
+	}
+
+	name := r.PathValue("name")
+	if _, ok := h.schema.Collections[name]; !ok {
+		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
+		return
+	}
+
+	var req FakeRequest
+	if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
+	if req.Count < 1 || req.Count > fake.MaxCount {
+		BadRequest(w, fmt.Sprintf("count must be between 1 and %d", fake.MaxCount))
+		return
+	}
+
+	opts := fake.Options{Count: req.Count, Parents: req.Parents, Tenant: req.Tenant, Seed: rand.Int64()}
+	if req.Seed != nil {
+		opts.Seed = *req.Seed
+	}
+	if req.Window != "" {
+		if opts.Window, err = schema.ParseRetentionAge(req.Window); err != nil {
+			BadRequest(w, "window must be a positive duration, e.g. 30d")
+			return
+		}
+	}
+	if req.Until != "" {
+		if opts.Until, err = time.Parse(time.RFC3339, req.Until); err != nil {
+			BadRequest(w, "until must be an RFC 3339 time")
+			return
+		}
+	}
+
+	report, err := fake.Generate(r.Context(), h.db, h.schema, name, opts)
+	if err != nil {
+		// Generation fails on schemas it cannot satisfy, such as a
+		// required reference cycle, so the message is the admin's to fix.
+		ErrorWithDetails(w, http.StatusUnprocessableEntity, "FAKE_FAILED", err.Error(), report)
+		return
+	}
+
+	log.Info().Str("collection", name).Int("documents", report.Total).Int64("seed", report.Seed).Msg("Generated documents via admin API")
+	JSON(w, http.StatusCreated, report)
+}
+
+// maxCloseReason is the longest reason that fits in a WebSocket close frame.
+const maxCloseReason = 123
+
+// RealtimeConnections handles GET /api/admin/realtime/connections.
+func (h *AdminHandlers) RealtimeConnections(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.broker == nil {
+		Error(w, http.StatusServiceUnavailable, "REALTIME_UNAVAILABLE", "Realtime is not enabled")
+		return
+	}
+
+	conns := h.broker.Connections()
+	JSON(w, http.StatusOK, map[string]any{
+		"connections": conns,
+		"total":       len(conns),
+	})
+}
+
+// RealtimeSubscriptions handles GET /api/admin/realtime/subscriptions.
+// The optional collection query parameter filters by collection.
+func (h *AdminHandlers) RealtimeSubscriptions(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.broker == nil {
+		Error(w, http.StatusServiceUnavailable, "REALTIME_UNAVAILABLE", "Realtime is not enabled")
+		return
+	}
+
+	subs := h.broker.Subscriptions(r.URL.Query().Get("collection"))
+	JSON(w, http.StatusOK, map[string]any{
+		"subscriptions": subs,
+		"total":         len(subs),
+	})
+}
+
+// RealtimeDisconnect handles DELETE /api/admin/realtime/connections/{id}.
+// The client receives a policy violation close frame with the reason query
+// parameter, or "disconnected by admin".
+func (h *AdminHandlers) RealtimeDisconnect(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.broker == nil {
+		Error(w, http.StatusServiceUnavailable, "REALTIME_UNAVAILABLE", "Realtime is not enabled")
+		return
+	}
+
+	id := r.PathValue("id")
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "disconnected by admin"
+	}
+	if len(reason) > maxCloseReason {
+		reason = reason[:maxCloseReason]
+	}
+
+	if !h.broker.Disconnect(id, reason) {
+		Error(w, http.StatusNotFound, "CONNECTION_NOT_FOUND", "Connection not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]any{
+		"disconnected": true,
+		"id":           id,
+		"reason":       reason,
+	})
+}
+
+// MaintenanceRequest is the body of POST /api/admin/db/maintenance.
+type MaintenanceRequest struct {
+	Action string `json:"action"`
+	// Mode is the checkpoint mode for the checkpoint action (default TRUNCATE).
+	Mode string `json:"mode,omitempty"`
 }
 
-// requireAdminAuth validates either a JWT token from an admin user or a deploy token.
-// JWT-authenticated admin users have all permissions.
-func (h *AdminHandlers) requireAdminAuth(r *http.Request, perm deploy.TokenPermission) (*deploy.AdminToken, error) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return nil, errors.New("missing authorization header")
-	}
+// TestEmailRequest is the body of POST /api/admin/email/test.
+type TestEmailRequest struct {
+	To string `json:"to"`
+}
 
-	parts := strings.SplitN(authHeader, " ", 2)
-	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-		return nil, errors.New("invalid authorization header format")
+// TestEmail handles POST /api/admin/email/test. It sends a test message
+// synchronously and returns the full SMTP error on failure, so mail settings
+// can be checked without triggering an auth flow.
+func (h *AdminHandlers) TestEmail(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.requireAdminAuth(r, deploy.PermissionAdmin); err != nil {
+		adminAuthError(w, err)
+		return
 	}
 
-	tokenStr := parts[1]
+	var req TestEmailRequest
+	if err := DecodeJSON(r, &req, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	if _, err := mail.ParseAddress(req.To); err != nil {
+		BadRequest(w, "to must be a valid email address")
+		return
+	}
+	if h.mailer == nil {
+		Error(w, http.StatusServiceUnavailable, "EMAIL_UNAVAILABLE", "Email is not configured")
+		return
+	}
 
-	if h.authService != nil {
-		claims, err := h.authService.ValidateToken(tokenStr)
-		if err == nil && claims != nil {
-			return &deploy.AdminToken{
-				Name:        "jwt:" + claims.Email,
-				Permissions: []string{string(deploy.PermissionAdmin), string(deploy.PermissionDeploy), string(deploy.PermissionRollback)},
-			}, nil
+	resp := map[string]any{
+		"to":      req.To,
+		"enabled": h.cfg.Email.Enabled,
+	}
+	if h.cfg.Email.Enabled {
+		resp["smtp"] = map[string]any{
+			"host": h.cfg.Email.SMTP.Host,
+			"port": h.cfg.Email.SMTP.Port,
+			"tls":  h.cfg.Email.SMTP.TLS,
 		}
 	}
 
-	token, err := h.deployService.ValidateToken(tokenStr)
+	start := time.Now()
+	err := h.mailer.SendTest(r.Context(), req.To)
+	resp["duration_ms"] = time.Since(start).Milliseconds()
 	if err != nil {
-		return nil, errors.New("invalid token")
+		log.Error().Err(err).Str("to", req.To).Msg("Test email failed")
+		ErrorWithDetails(w, http.StatusBadGateway, "EMAIL_SEND_FAILED", err.Error(), resp)
+		return
 	}
 
-	if !token.HasPermission(perm) {
-		return nil, errors.New("insufficient permissions")
+	resp["success"] = true
+	if h.cfg.Email.Enabled {
+		resp["message"] = "Test email sent"
+	} else {
+		resp["message"] = "Email is disabled; the test message was written to the server log"
 	}
-
-	return token, nil
+	JSON(w, http.StatusOK, resp)
 }
 
-func (h *AdminHandlers) Stats(w http.ResponseWriter, r *http.Request) {
-	_, err := h.requireAdminAuth(r, deploy.PermissionDeploy)
+// DBMaintenance handles POST /api/admin/db/maintenance.
+// Supported actions are checkpoint, vacuum, analyze and optimize.
+func (h *AdminHandlers) DBMaintenance(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
-	uptime := int64(time.Since(h.startTime).Seconds())
+	var req MaintenanceRequest
+	if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
 
-	var userCount int
-	row := h.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM _alyx_users")
-	_ = row.Scan(&userCount)
+	ctx := r.Context()
+	start := time.Now()
+	sizeBefore, _ := h.db.Size(ctx)
+	walBefore, _ := h.db.WALSize()
 
-	var docCount int
-	if h.schema != nil {
-		for _, col := range h.schema.Collections {
-			var count int
-			row := h.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM "+col.Name)
-			if row.Scan(&count) == nil {
-				docCount += count
+	resp := map[string]any{"action": req.Action}
+
+	switch req.Action {
+	case "checkpoint":
+		mode := database.CheckpointTruncate
+		if req.Mode != "" {
+			parsed, parseErr := database.ParseCheckpointMode(req.Mode)
+			if parseErr != nil {
+				BadRequest(w, parseErr.Error())
+				return
 			}
+			mode = parsed
+		}
+		result, checkpointErr := h.db.Checkpoint(ctx, mode)
+		if checkpointErr != nil {
+			err = checkpointErr
+			break
+		}
+		resp["checkpoint"] = result
+	case "vacuum":
+		err = h.db.Vacuum(ctx)
+		if errors.Is(err, database.ErrInsufficientDiskSpace) {
+			Error(w, http.StatusInsufficientStorage, "INSUFFICIENT_DISK_SPACE", err.Error())
+			return
 		}
+	case "analyze":
+		err = h.db.Analyze(ctx)
+	case "optimize":
+		err = h.db.Optimize(ctx)
+	default:
+		BadRequest(w, "action must be checkpoint, vacuum, analyze or optimize")
+		return
 	}
-
-	var funcCount int
-	if h.funcService != nil {
-		funcCount = len(h.funcService.ListFunctions())
+	if err != nil {
+		log.Error().Err(err).Str("action", req.Action).Msg("Database maintenance failed")
+		Error(w, http.StatusInternalServerError, "MAINTENANCE_FAILED", err.Error())
+		return
 	}
 
-	var collectionCount int
-	if h.schema != nil {
-		collectionCount = len(h.schema.Collections)
-	}
+	sizeAfter, _ := h.db.Size(ctx)
+	walAfter, _ := h.db.WALSize()
+	duration := time.Since(start)
 
-	JSON(w, http.StatusOK, map[string]any{
-		"uptime":      uptime,
-		"collections": collectionCount,
-		"documents":   docCount,
-		"users":       userCount,
-		"functions":   funcCount,
-	})
+	log.Info().
+		Str("action", req.Action).
+		Dur("duration", duration).
+		Int64("size_before", sizeBefore).
+		Int64("size_after", sizeAfter).
+		Msg("Database maintenance completed")
+
+	resp["duration"] = duration.String()
+	resp["duration_ms"] = duration.Milliseconds()
+	resp["size_before"] = sizeBefore
+	resp["size_after"] = sizeAfter
+	resp["wal_size_before"] = walBefore
+	resp["wal_size_after"] = walAfter
+	JSON(w, http.StatusOK, resp)
 }
 
-func (h *AdminHandlers) StorageStats(w http.ResponseWriter, r *http.Request) {
-	_, err := h.requireAdminAuth(r, deploy.PermissionDeploy)
-	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+// SandboxReset handles POST /api/admin/sandbox/reset. It deletes the dev
+// sandbox database and creates it again from the current schema.
+func (h *AdminHandlers) SandboxReset(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.requireAdminAuth(r, deploy.PermissionAdmin); err != nil {
+		adminAuthError(w, err)
+		return
+	}
+	if h.sandboxReset == nil {
+		NotFound(w, "The sandbox is only available in dev mode")
 		return
 	}
 
-	store := storage.NewStore(h.db)
-	stats, err := store.GetBucketStats(r.Context())
+	path, err := h.sandboxReset(r.Context())
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get bucket stats")
-		InternalError(w, "Failed to get bucket stats")
+		log.Error().Err(err).Msg("Failed to reset sandbox database")
+		InternalError(w, "Failed to reset sandbox database")
 		return
 	}
 
-	JSON(w, http.StatusOK, map[string]any{
-		"buckets": stats,
-	})
+	log.Info().Str("path", path).Msg("Sandbox database reset")
+	JSON(w, http.StatusOK, map[string]any{"reset": true, "path": path})
 }
 
 // DeployPrepare handles POST /api/admin/deploy/prepare.
 func (h *AdminHandlers) DeployPrepare(w http.ResponseWriter, r *http.Request) {
-	token, err := h.requireAdminAuth(r, deploy.PermissionDeploy)
+	token, err := h.requireAdminAuth(r, deploy.PermissionRead)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
 	var req deploy.PrepareRequest
-	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -202,17 +1058,23 @@ func (h *AdminHandlers) DeployPrepare(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusOK, resp)
 }
 
-// DeployExecute handles POST /api/admin/deploy/execute.
+// DeployExecute handles POST /api/admin/deploy/execute. A body of type
+// application/x-alyx-bundle is a bundle archive; see deployExecuteBundle.
 func (h *AdminHandlers) DeployExecute(w http.ResponseWriter, r *http.Request) {
 	token, err := h.requireAdminAuth(r, deploy.PermissionDeploy)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
+		return
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), deploy.BundleContentType) {
+		h.deployExecuteBundle(w, r, token)
 		return
 	}
 
 	var req deploy.ExecuteRequest
-	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -234,6 +1096,59 @@ func (h *AdminHandlers) DeployExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.notifySchemaApplied(resp.Schema, resp.Collections)
+	h.publishDeployExecuted(resp.Version)
+
+	JSON(w, http.StatusOK, resp)
+}
+
+// deployExecuteBundle deploys a bundle archive uploaded as the request body.
+// The query names the prepared deployment: schema_hash, functions_hash and
+// current_version from the prepare response, plus an optional description.
+func (h *AdminHandlers) deployExecuteBundle(w http.ResponseWriter, r *http.Request, token *deploy.AdminToken) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, deploy.MaxBundleSize))
+	if err != nil {
+		Error(w, http.StatusRequestEntityTooLarge, "BUNDLE_TOO_LARGE", fmt.Sprintf("Bundle exceeds %d bytes", deploy.MaxBundleSize))
+		return
+	}
+
+	query := r.URL.Query()
+	req := &deploy.BundleExecuteRequest{
+		Bundle:         data,
+		SchemaHash:     query.Get("schema_hash"),
+		FunctionsHash:  query.Get("functions_hash"),
+		CurrentVersion: query.Get("current_version"),
+		Description:    query.Get("description"),
+	}
+
+	log.Info().
+		Str("token_name", token.Name).
+		Str("schema_hash", req.SchemaHash).
+		Int("bundle_size", len(data)).
+		Str("description", req.Description).
+		Msg("Deploy bundle request")
+
+	resp, err := h.deployService.ExecuteBundle(req, token.Name)
+	switch {
+	case errors.Is(err, deploy.ErrInvalidBundle):
+		Error(w, http.StatusBadRequest, "INVALID_BUNDLE", err.Error())
+		return
+	case errors.Is(err, deploy.ErrBundleMismatch):
+		Error(w, http.StatusConflict, "BUNDLE_MISMATCH", err.Error())
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Deploy bundle failed")
+		Error(w, http.StatusInternalServerError, "DEPLOY_ERROR", err.Error())
+		return
+	}
+
+	if resp.Activated {
+		h.publishSchemaChanged(resp.Collections)
+	} else {
+		h.notifySchemaApplied(resp.Schema, resp.Collections)
+	}
+	h.publishDeployExecuted(resp.Version)
+
 	JSON(w, http.StatusOK, resp)
 }
 
@@ -241,13 +1156,13 @@ func (h *AdminHandlers) DeployExecute(w http.ResponseWriter, r *http.Request) {
 func (h *AdminHandlers) DeployRollback(w http.ResponseWriter, r *http.Request) {
 	token, err := h.requireAdminAuth(r, deploy.PermissionRollback)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
 	var req deploy.RollbackRequest
-	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -264,14 +1179,20 @@ func (h *AdminHandlers) DeployRollback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if resp.Activated {
+		h.publishSchemaChanged(resp.Collections)
+	} else {
+		h.notifySchemaApplied(resp.Schema, resp.Collections)
+	}
+
 	JSON(w, http.StatusOK, resp)
 }
 
 // DeployHistory handles GET /api/admin/deploy/history.
 func (h *AdminHandlers) DeployHistory(w http.ResponseWriter, r *http.Request) {
-	_, err := h.requireAdminAuth(r, deploy.PermissionDeploy)
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -305,13 +1226,13 @@ func (h *AdminHandlers) TokenCreate(w http.ResponseWriter, r *http.Request) {
 	// For token creation, we require an existing admin token
 	creatorToken, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
 	var req deploy.CreateTokenRequest
-	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -324,10 +1245,16 @@ func (h *AdminHandlers) TokenCreate(w http.ResponseWriter, r *http.Request) {
 		req.Permissions = []string{string(deploy.PermissionDeploy)}
 	}
 
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		Error(w, http.StatusBadRequest, "INVALID_EXPIRY", "Token expiry must be in the future")
+		return
+	}
+
 	log.Info().
 		Str("creator", creatorToken.Name).
 		Str("name", req.Name).
 		Strs("permissions", req.Permissions).
+		Bool("require_signing", req.RequireSigning).
 		Msg("Creating admin token")
 
 	resp, err := h.deployService.CreateToken(&req, creatorToken.Name)
@@ -344,7 +1271,7 @@ func (h *AdminHandlers) TokenCreate(w http.ResponseWriter, r *http.Request) {
 func (h *AdminHandlers) TokenList(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -361,11 +1288,44 @@ func (h *AdminHandlers) TokenList(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// TokenRotate handles POST /api/admin/tokens/{name}/rotate.
+func (h *AdminHandlers) TokenRotate(w http.ResponseWriter, r *http.Request) {
+	rotator, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	name := r.PathValue("name")
+	if name == "" {
+		Error(w, http.StatusBadRequest, "MISSING_NAME", "Token name is required")
+		return
+	}
+
+	resp, err := h.deployService.RotateToken(name)
+	if errors.Is(err, deploy.ErrTokenNotFound) {
+		Error(w, http.StatusNotFound, "TOKEN_NOT_FOUND", "Token not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Token rotation failed")
+		Error(w, http.StatusInternalServerError, "TOKEN_ERROR", err.Error())
+		return
+	}
+
+	log.Info().
+		Str("rotator", rotator.Name).
+		Str("name", name).
+		Msg("Rotated admin token")
+
+	JSON(w, http.StatusOK, resp)
+}
+
 // TokenDelete handles DELETE /api/admin/tokens/{name}.
 func (h *AdminHandlers) TokenDelete(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -376,7 +1336,7 @@ func (h *AdminHandlers) TokenDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.deployService.DeleteToken(name); err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, deploy.ErrTokenNotFound) {
 			Error(w, http.StatusNotFound, "TOKEN_NOT_FOUND", "Token not found")
 			return
 		}
@@ -393,9 +1353,9 @@ func (h *AdminHandlers) TokenDelete(w http.ResponseWriter, r *http.Request) {
 
 // SchemaGet handles GET /api/admin/schema.
 func (h *AdminHandlers) SchemaGet(w http.ResponseWriter, r *http.Request) {
-	_, err := h.requireAdminAuth(r, deploy.PermissionDeploy)
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -435,22 +1395,114 @@ func (h *AdminHandlers) SchemaGet(w http.ResponseWriter, r *http.Request) {
 		return buckets[i]["name"].(string) < buckets[j]["name"].(string)
 	})
 
+	presets := make([]map[string]any, 0, len(h.schema.Presets))
+	for name, preset := range h.schema.Presets {
+		fields := make([]map[string]any, 0, len(preset.Fields))
+		for _, fieldName := range preset.FieldOrder() {
+			fields = append(fields, serializeField(preset.Fields[fieldName]))
+		}
+		presets = append(presets, map[string]any{
+			"name":   name,
+			"fields": fields,
+		})
+	}
+	// Sort presets by name
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i]["name"].(string) < presets[j]["name"].(string)
+	})
+
+	views := make([]map[string]any, 0, len(h.schema.Views))
+	for name, view := range h.schema.Views {
+		v := map[string]any{
+			"name":        name,
+			"query":       view.Query,
+			"refresh":     view.RefreshPolicy(),
+			"collections": view.Collections(),
+		}
+		if view.Interval != "" {
+			v["interval"] = view.Interval
+		}
+		if view.Description != "" {
+			v["description"] = view.Description
+		}
+		if view.Rules != nil && view.Rules.Read != "" {
+			v["rules"] = map[string]string{"read": view.Rules.Read}
+		}
+		views = append(views, v)
+	}
+	// Sort views by name
+	sort.Slice(views, func(i, j int) bool {
+		return views[i]["name"].(string) < views[j]["name"].(string)
+	})
+
 	JSON(w, http.StatusOK, map[string]any{
 		"version":     h.schema.Version,
 		"collections": collections,
 		"buckets":     buckets,
+		"presets":     presets,
+		"views":       views,
 	})
 }
 
+// SchemaGraph handles GET /api/admin/schema/graph. It returns the
+// entity-relationship graph of the schema with row counts, or Graphviz DOT
+// with ?format=dot.
+func (h *AdminHandlers) SchemaGraph(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "dot" {
+		Error(w, http.StatusBadRequest, "INVALID_FORMAT", "format must be json or dot")
+		return
+	}
+
+	graph := schema.BuildGraph(h.schema)
+	if h.db != nil {
+		for _, node := range graph.Nodes {
+			if node.Kind != schema.GraphNodeCollection {
+				continue
+			}
+			var count int64
+			// Collections that have not been migrated yet have no table.
+			row := h.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM \""+node.Name+"\"")
+			if row.Scan(&count) == nil {
+				node.RowCount = &count
+			}
+		}
+	}
+
+	if format == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(graph.DOT()))
+		return
+	}
+
+	JSON(w, http.StatusOK, graph)
+}
+
 func serializeCollection(col *schema.Collection) map[string]any {
 	fields := make([]map[string]any, 0, len(col.Fields))
 	for _, f := range col.OrderedFields() {
-		fields = append(fields, serializeField(f))
+		field := serializeField(f)
+		if preset := col.PresetOf(f.Name); preset != "" {
+			field["preset"] = preset
+		}
+		fields = append(fields, field)
 	}
 
 	collection := map[string]any{
 		"name":   col.Name,
 		"fields": fields,
+		"expose": col.Exposed(),
+	}
+
+	if len(col.Use) > 0 {
+		collection["use"] = col.Use
 	}
 
 	if len(col.Indexes) > 0 {
@@ -469,6 +1521,26 @@ func serializeCollection(col *schema.Collection) map[string]any {
 		collection["rules"] = rules
 	}
 
+	if col.Retention != nil {
+		collection["retention"] = col.Retention
+	}
+
+	if col.History != nil {
+		collection["history"] = col.History
+	}
+
+	if len(col.Checks) > 0 {
+		collection["checks"] = col.Checks
+	}
+
+	if col.List != nil {
+		collection["list"] = col.List
+	}
+
+	if col.Docs != nil {
+		collection["docs"] = col.Docs
+	}
+
 	return collection
 }
 
@@ -490,6 +1562,18 @@ func serializeField(f *schema.Field) map[string]any {
 	if f.OnDelete != "" {
 		field["onDelete"] = string(f.OnDelete)
 	}
+	if f.OnUserDelete != "" {
+		field["onUserDelete"] = string(f.OnUserDelete)
+	}
+	if f.Storage != "" {
+		field["storage"] = f.Storage
+	}
+	if f.Encrypted {
+		field["encrypted"] = true
+	}
+	if f.Collate != "" {
+		field["collate"] = string(f.Collate)
+	}
 	if f.Validate != nil {
 		validate := map[string]any{}
 		if f.Validate.MinLength != nil {
@@ -597,6 +1681,9 @@ func serializeRules(r *schema.Rules) map[string]string {
 	if r.Delete != "" {
 		rules["delete"] = r.Delete
 	}
+	if r.History != "" {
+		rules["history"] = r.History
+	}
 	return rules
 }
 
@@ -614,18 +1701,16 @@ func mustParseInt(s string) int64 {
 func (h *AdminHandlers) UserList(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
-	opts := auth.ListUsersOptions{
-		Limit:   20,
-		Offset:  0,
-		SortBy:  r.URL.Query().Get("sort_by"),
-		SortDir: r.URL.Query().Get("sort_dir"),
-		Search:  r.URL.Query().Get("search"),
-		Role:    r.URL.Query().Get("role"),
+	opts, err := userListOptions(r)
+	if err != nil {
+		BadRequest(w, err.Error())
+		return
 	}
+	opts.Limit = 20
 
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 		if limit := int(mustParseInt(limitStr)); limit > 0 {
@@ -633,27 +1718,177 @@ func (h *AdminHandlers) UserList(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		if offset := int(mustParseInt(offsetStr)); offset >= 0 {
-			opts.Offset = offset
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if offset := int(mustParseInt(offsetStr)); offset >= 0 {
+			opts.Offset = offset
+		}
+	}
+
+	result, err := h.authService.ListUsers(r.Context(), opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list users")
+		InternalError(w, "Failed to list users")
+		return
+	}
+
+	JSON(w, http.StatusOK, result)
+}
+
+// userListOptions parses the user list filters shared by UserList and
+// UserExport.
+func userListOptions(r *http.Request) (auth.ListUsersOptions, error) {
+	q := r.URL.Query()
+	opts := auth.ListUsersOptions{
+		SortBy:  q.Get("sort_by"),
+		SortDir: q.Get("sort_dir"),
+		Search:  q.Get("search"),
+		Role:    q.Get("role"),
+
+		PendingDeletion: q.Get("pending_deletion") == "true",
+	}
+
+	if v := q.Get("verified"); v != "" {
+		verified, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, errors.New("verified must be true or false")
+		}
+		opts.Verified = &verified
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"created_after", &opts.CreatedAfter},
+		{"created_before", &opts.CreatedBefore},
+	} {
+		if v := q.Get(param.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be an RFC 3339 timestamp", param.name)
+			}
+			*param.dst = &t
+		}
+	}
+
+	return opts, nil
+}
+
+// UserExport handles GET /api/admin/users/export. It streams every user
+// matching the list filters as CSV, without password hashes.
+func (h *AdminHandlers) UserExport(w http.ResponseWriter, r *http.Request) {
+	token, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if format := r.URL.Query().Get("format"); format != "" && format != "csv" {
+		BadRequest(w, "format must be csv")
+		return
+	}
+
+	opts, err := userListOptions(r)
+	if err != nil {
+		BadRequest(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "email", "verified", "role", "created_at", "updated_at", "deletion_requested_at"})
+
+	var exported int
+	err = h.authService.EachUser(r.Context(), opts, func(u *auth.User) error {
+		deletionRequestedAt := ""
+		if u.DeletionRequestedAt != nil {
+			deletionRequestedAt = u.DeletionRequestedAt.Format(time.RFC3339)
+		}
+		exported++
+		return cw.Write([]string{
+			u.ID,
+			u.Email,
+			strconv.FormatBool(u.Verified),
+			u.Role,
+			u.CreatedAt.Format(time.RFC3339),
+			u.UpdatedAt.Format(time.RFC3339),
+			deletionRequestedAt,
+		})
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	if err != nil {
+		// The header has been sent, so the export is cut short.
+		log.Error().Err(err).Int("exported", exported).Msg("Failed to export users")
+		return
+	}
+
+	log.Info().
+		Str("by", token.Name).
+		Int("exported", exported).
+		Msg("Users exported")
+}
+
+// UserBulk handles POST /api/admin/users/bulk. It applies one action to
+// many users in a single transaction and reports the result for each.
+func (h *AdminHandlers) UserBulk(w http.ResponseWriter, r *http.Request) {
+	token, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	var input auth.BulkUsersInput
+	if decodeErr := DecodeJSON(r, &input, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
+
+	results, err := h.authService.BulkUsers(r.Context(), input)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			BadRequest(w, err.Error())
+			return
+		}
+		log.Error().Err(err).Str("action", input.Action).Msg("Failed to apply bulk user change")
+		InternalError(w, "Failed to apply bulk user change")
+		return
+	}
+
+	var changed, failed []string
+	for _, result := range results {
+		if result.Success {
+			changed = append(changed, result.ID)
+		} else {
+			failed = append(failed, result.ID)
 		}
 	}
 
-	result, err := h.authService.ListUsers(r.Context(), opts)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to list users")
-		InternalError(w, "Failed to list users")
-		return
-	}
+	log.Info().
+		Str("by", token.Name).
+		Str("action", input.Action).
+		Str("role", input.Role).
+		Strs("changed", changed).
+		Strs("failed", failed).
+		Msg("Bulk user change applied")
 
-	JSON(w, http.StatusOK, result)
+	JSON(w, http.StatusOK, map[string]any{
+		"action":    input.Action,
+		"results":   results,
+		"succeeded": len(changed),
+		"failed":    len(failed),
+	})
 }
 
 // UserGet handles GET /api/admin/users/{id}.
 func (h *AdminHandlers) UserGet(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -681,13 +1916,13 @@ func (h *AdminHandlers) UserGet(w http.ResponseWriter, r *http.Request) {
 func (h *AdminHandlers) UserCreate(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
 	var input auth.CreateUserInput
-	if decodeErr := json.NewDecoder(r.Body).Decode(&input); decodeErr != nil {
-		BadRequest(w, "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &input, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -715,6 +1950,9 @@ func (h *AdminHandlers) UserCreate(w http.ResponseWriter, r *http.Request) {
 			BadRequest(w, err.Error())
 			return
 		}
+		if writeMetadataError(w, err) {
+			return
+		}
 		log.Error().Err(err).Msg("Failed to create user")
 		InternalError(w, "Failed to create user")
 		return
@@ -727,7 +1965,7 @@ func (h *AdminHandlers) UserCreate(w http.ResponseWriter, r *http.Request) {
 func (h *AdminHandlers) UserUpdate(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -738,8 +1976,8 @@ func (h *AdminHandlers) UserUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var input auth.UpdateUserInput
-	if decodeErr := json.NewDecoder(r.Body).Decode(&input); decodeErr != nil {
-		BadRequest(w, "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &input, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -757,6 +1995,9 @@ func (h *AdminHandlers) UserUpdate(w http.ResponseWriter, r *http.Request) {
 			BadRequest(w, err.Error())
 			return
 		}
+		if writeMetadataError(w, err) {
+			return
+		}
 		log.Error().Err(err).Str("user_id", id).Msg("Failed to update user")
 		InternalError(w, "Failed to update user")
 		return
@@ -769,7 +2010,7 @@ func (h *AdminHandlers) UserUpdate(w http.ResponseWriter, r *http.Request) {
 func (h *AdminHandlers) UserDelete(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -799,7 +2040,7 @@ func (h *AdminHandlers) UserDelete(w http.ResponseWriter, r *http.Request) {
 func (h *AdminHandlers) UserSetPassword(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -812,8 +2053,8 @@ func (h *AdminHandlers) UserSetPassword(w http.ResponseWriter, r *http.Request)
 	var input struct {
 		Password string `json:"password"`
 	}
-	if decodeErr := json.NewDecoder(r.Body).Decode(&input); decodeErr != nil {
-		BadRequest(w, "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &input, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -842,19 +2083,69 @@ func (h *AdminHandlers) UserSetPassword(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// UserRestore handles POST /api/admin/users/{id}/restore, cancelling a
+// pending account deletion.
+func (h *AdminHandlers) UserRestore(w http.ResponseWriter, r *http.Request) {
+	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		BadRequest(w, "User ID is required")
+		return
+	}
+
+	user, err := h.authService.RestoreUser(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			NotFound(w, "User not found")
+			return
+		}
+		log.Error().Err(err).Str("user_id", id).Msg("Failed to restore user")
+		InternalError(w, "Failed to restore user")
+		return
+	}
+
+	JSON(w, http.StatusOK, user)
+}
+
 func (h *AdminHandlers) isDevMode() bool {
 	return h.cfg != nil && h.cfg.Dev.Enabled
 }
 
 func (h *AdminHandlers) SchemaRawGet(w http.ResponseWriter, r *http.Request) {
-	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
-	if h.schemaPath == "" {
-		Error(w, http.StatusNotFound, "SCHEMA_NOT_FOUND", "Schema file path not configured")
+	if h.schemaPath == "" || !schemaFileExists(h.schemaPath) {
+		h.cachedSchemaRaw(w)
+		return
+	}
+
+	if schema.IsDir(h.schemaPath) {
+		files, readErr := schema.ReadDir(h.schemaPath)
+		if readErr != nil {
+			log.Error().Err(readErr).Str("path", h.schemaPath).Msg("Failed to read schema directory")
+			InternalError(w, "Failed to read schema directory")
+			return
+		}
+
+		contents := make(map[string]string, len(files))
+		for name, data := range files {
+			contents[name] = string(data)
+		}
+
+		JSON(w, http.StatusOK, map[string]any{
+			"files":  contents,
+			"path":   h.schemaPath,
+			"source": "file",
+		})
 		return
 	}
 
@@ -868,13 +2159,45 @@ func (h *AdminHandlers) SchemaRawGet(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusOK, map[string]any{
 		"content": string(content),
 		"path":    h.schemaPath,
+		"source":  "file",
+	})
+}
+
+func schemaFileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// cachedSchemaRaw responds with the canonical YAML of the schema last applied
+// to the database, for servers running without a schema file.
+func (h *AdminHandlers) cachedSchemaRaw(w http.ResponseWriter) {
+	if h.migrator == nil {
+		Error(w, http.StatusNotFound, "SCHEMA_NOT_FOUND", "Schema file not found")
+		return
+	}
+	snapshot, err := h.migrator.LoadSchemaSnapshot()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load cached schema")
+		InternalError(w, "Failed to load cached schema")
+		return
+	}
+	if snapshot == nil {
+		Error(w, http.StatusNotFound, "SCHEMA_NOT_FOUND", "Schema file not found and no schema is cached in the database")
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]any{
+		"content":   string(snapshot.YAML),
+		"path":      h.schemaPath,
+		"source":    "database",
+		"cached_at": snapshot.UpdatedAt,
 	})
 }
 
 func (h *AdminHandlers) SchemaRawUpdate(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -889,10 +2212,16 @@ func (h *AdminHandlers) SchemaRawUpdate(w http.ResponseWriter, r *http.Request)
 	}
 
 	var input struct {
-		Content string `json:"content"`
+		Content string            `json:"content"`
+		Files   map[string]string `json:"files"`
 	}
-	if decodeErr := json.NewDecoder(r.Body).Decode(&input); decodeErr != nil {
-		BadRequest(w, "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &input, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
+
+	if schema.IsDir(h.schemaPath) {
+		h.updateSchemaDir(w, input.Files)
 		return
 	}
 
@@ -901,8 +2230,17 @@ func (h *AdminHandlers) SchemaRawUpdate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	newSchema, err := schema.Parse([]byte(input.Content))
+	if err == nil {
+		err = rules.Check(newSchema)
+	}
+	if err != nil {
+		invalidSchema(w, err)
+		return
+	}
+
 	if err := h.schemaManager.UpdateFromYAML([]byte(input.Content)); err != nil {
-		Error(w, http.StatusBadRequest, "INVALID_SCHEMA", err.Error())
+		invalidSchema(w, err)
 		return
 	}
 
@@ -920,10 +2258,54 @@ func (h *AdminHandlers) SchemaRawUpdate(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// updateSchemaDir replaces the files of a schema directory. The submitted files are
+// written as-is after the merged schema validates; files not submitted are removed.
+func (h *AdminHandlers) updateSchemaDir(w http.ResponseWriter, files map[string]string) {
+	if len(files) == 0 {
+		BadRequest(w, "Files are required")
+		return
+	}
+
+	contents := make(map[string][]byte, len(files))
+	for name, content := range files {
+		if name != filepath.Base(name) || !schema.IsSchemaFile(name) {
+			BadRequest(w, fmt.Sprintf("Invalid schema file name %q", name))
+			return
+		}
+		contents[name] = []byte(content)
+	}
+
+	newSchema, err := schema.ParseFiles(contents)
+	if err == nil {
+		err = rules.Check(newSchema)
+	}
+	if err != nil {
+		invalidSchema(w, err)
+		return
+	}
+
+	if err := schema.WriteFiles(h.schemaPath, contents); err != nil {
+		log.Error().Err(err).Msg("Failed to save schema")
+		InternalError(w, "Failed to save schema")
+		return
+	}
+
+	if err := h.schemaManager.Load(); err != nil {
+		log.Error().Err(err).Msg("Failed to reload schema")
+	}
+
+	log.Info().Str("path", h.schemaPath).Int("files", len(files)).Msg("Schema directory updated via admin API")
+
+	JSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Schema updated successfully. Restart the server or wait for hot-reload to apply changes.",
+	})
+}
+
 func (h *AdminHandlers) ConfigRawGet(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -946,9 +2328,9 @@ func (h *AdminHandlers) ConfigRawGet(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AdminHandlers) ConfigRawUpdate(w http.ResponseWriter, r *http.Request) {
-	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	token, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -965,8 +2347,8 @@ func (h *AdminHandlers) ConfigRawUpdate(w http.ResponseWriter, r *http.Request)
 	var input struct {
 		Content string `json:"content"`
 	}
-	if decodeErr := json.NewDecoder(r.Body).Decode(&input); decodeErr != nil {
-		BadRequest(w, "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &input, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -982,17 +2364,85 @@ func (h *AdminHandlers) ConfigRawUpdate(w http.ResponseWriter, r *http.Request)
 	}
 
 	log.Info().Str("path", h.configPath).Msg("Config file updated via admin API")
+	h.publishConfigUpdated(nil, token.Name)
+
+	JSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Config updated successfully. Restart the server to apply changes.",
+	})
+}
+
+// ConfigPatchRequest is the body of PATCH /api/admin/config.
+type ConfigPatchRequest struct {
+	Ops []config.PatchOp `json:"ops"`
+}
+
+// ConfigPatch handles PATCH /api/admin/config. It sets individual fields of
+// the config file, keeping its comments and layout, and reports every
+// invalid operation at once. Secret values are never echoed or logged.
+func (h *AdminHandlers) ConfigPatch(w http.ResponseWriter, r *http.Request) {
+	token, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if !h.isDevMode() {
+		Error(w, http.StatusForbidden, "DEV_MODE_REQUIRED", "Config editing is only available in development mode")
+		return
+	}
+
+	if h.configPath == "" {
+		Error(w, http.StatusNotFound, "CONFIG_NOT_FOUND", "Config file path not configured")
+		return
+	}
+
+	var req ConfigPatchRequest
+	if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
+	if len(req.Ops) == 0 {
+		BadRequest(w, "ops is required")
+		return
+	}
+
+	fields, err := config.PatchFile(h.configPath, req.Ops)
+	var patchErrs config.PatchErrors
+	if errors.As(err, &patchErrs) {
+		ErrorWithDetails(w, http.StatusBadRequest, "INVALID_CONFIG_PATCH", "Some operations could not be applied", patchErrs)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("path", h.configPath).Msg("Failed to patch config file")
+		InternalError(w, "Failed to update config file")
+		return
+	}
+
+	changed := make([]string, len(fields))
+	for i, f := range fields {
+		changed[i] = f.Path
+		event := log.Info().Str("path", h.configPath).Str("field", f.Path).Str("by", token.Name)
+		if f.Sensitive {
+			event = event.Str("value", "changed")
+		} else {
+			event = event.Interface("value", req.Ops[i].Value)
+		}
+		event.Msg("Config field updated via admin API")
+	}
+	h.publishConfigUpdated(changed, token.Name)
 
 	JSON(w, http.StatusOK, map[string]any{
 		"success": true,
+		"changed": changed,
 		"message": "Config updated successfully. Restart the server to apply changes.",
 	})
 }
 
 func (h *AdminHandlers) ConfigSchemaGet(w http.ResponseWriter, r *http.Request) {
-	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -1000,10 +2450,150 @@ func (h *AdminHandlers) ConfigSchemaGet(w http.ResponseWriter, r *http.Request)
 	JSON(w, http.StatusOK, schema)
 }
 
+// RotateSecretRequest is the body of POST /api/admin/auth/rotate-secret.
+type RotateSecretRequest struct {
+	// Keep is how many previous secrets stay valid for verification (default 1).
+	Keep *int `json:"keep,omitempty"`
+}
+
+// RotateJWTSecret handles POST /api/admin/auth/rotate-secret. It generates a
+// new signing secret, rewrites the config file keeping the previous secret for
+// verification, and applies the new secrets without a restart. Outside
+// development mode it requires an admin deploy token rather than a user
+// session, since a session is itself signed with the secret being rotated.
+func (h *AdminHandlers) RotateJWTSecret(w http.ResponseWriter, r *http.Request) {
+	token, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if !h.isDevMode() && strings.HasPrefix(token.Name, "jwt:") {
+		Error(w, http.StatusForbidden, "DEPLOY_TOKEN_REQUIRED", "Secret rotation requires an admin deploy token outside development mode")
+		return
+	}
+
+	if h.configPath == "" {
+		Error(w, http.StatusNotFound, "CONFIG_NOT_FOUND", "Config file path not configured")
+		return
+	}
+
+	var req RotateSecretRequest
+	if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true, AllowEmpty: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
+	keep := 1
+	if req.Keep != nil {
+		keep = *req.Keep
+	}
+	if keep < 0 {
+		BadRequest(w, "keep must be non-negative")
+		return
+	}
+
+	secret, err := config.GenerateJWTSecret()
+	if err != nil {
+		InternalError(w, "Failed to generate secret")
+		return
+	}
+
+	rotation, err := config.RotateJWTSecret(h.configPath, secret, keep)
+	if err != nil {
+		log.Error().Err(err).Str("path", h.configPath).Msg("Failed to rotate JWT secret")
+		InternalError(w, "Failed to update config file")
+		return
+	}
+
+	resp := map[string]any{
+		"success":  true,
+		"retained": rotation.Retained,
+		"dropped":  rotation.Dropped,
+	}
+
+	// Reload so ${VAR} references in the retained secrets are resolved the
+	// same way as at startup.
+	env := ""
+	if h.cfg != nil {
+		env = h.cfg.Env
+	}
+	cfg, loadErr := config.Load(config.LoadOptions{ConfigFile: h.configPath, Env: env})
+	switch {
+	case loadErr != nil:
+		log.Warn().Err(loadErr).Msg("JWT secret rotated but the config could not be reloaded")
+		resp["applied"] = false
+		resp["message"] = "Config file updated, but it failed to load: " + loadErr.Error() + ". Fix it and restart the server."
+	case h.authService != nil:
+		h.authService.SetJWTSecrets(cfg.Auth.JWT.VerificationSecrets())
+		resp["applied"] = true
+		resp["message"] = "Secret rotated. New tokens are signed with the new secret; existing tokens stay valid until they expire."
+	}
+
+	log.Info().
+		Str("path", h.configPath).
+		Str("by", token.Name).
+		Int("retained", rotation.Retained).
+		Int("dropped", rotation.Dropped).
+		Msg("JWT secret rotated via admin API")
+	h.publishConfigUpdated([]string{"auth.jwt.secret", "auth.jwt.secrets"}, token.Name)
+
+	JSON(w, http.StatusOK, resp)
+}
+
+// IntrospectRequest is the request body for token introspection.
+type IntrospectRequest struct {
+	Tokens []string `json:"tokens"`
+}
+
+// Introspect handles POST /api/auth/introspect. It reports, for each access
+// token, whether it would authenticate a request and whose it is, so edge
+// middleware can check tokens without holding the signing secret. It needs
+// admin auth or a token with the introspect permission.
+func (h *AdminHandlers) Introspect(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.requireAdminAuth(r, deploy.PermissionIntrospect); err != nil {
+		adminAuthError(w, err)
+		return
+	}
+	if h.authService == nil {
+		Error(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", "Auth service not configured")
+		return
+	}
+
+	var req IntrospectRequest
+	if err := DecodeJSON(r, &req, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	if len(req.Tokens) == 0 {
+		BadRequest(w, "tokens is required")
+		return
+	}
+	if len(req.Tokens) > auth.MaxIntrospectTokens {
+		BadRequest(w, fmt.Sprintf("at most %d tokens can be introspected at once", auth.MaxIntrospectTokens))
+		return
+	}
+
+	results := make([]*auth.TokenIntrospection, len(req.Tokens))
+	for i, token := range req.Tokens {
+		result, err := h.authService.Introspect(r.Context(), token)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to introspect token")
+			InternalError(w, "Failed to introspect tokens")
+			return
+		}
+		results[i] = result
+	}
+
+	JSON(w, http.StatusOK, map[string]any{"tokens": results})
+}
+
 // ValidateRuleRequest is the request body for CEL rule validation.
 type ValidateRuleRequest struct {
 	Expression string   `json:"expression"`
 	Fields     []string `json:"fields,omitempty"`
+	// Kind is "rule" (the default) or "check", which validates a
+	// collection check against doc and old instead of the rule variables.
+	Kind string `json:"kind,omitempty"`
 }
 
 // ValidateRuleResponse is the response for CEL rule validation.
@@ -1016,15 +2606,25 @@ type ValidateRuleResponse struct {
 
 // ValidateRule handles POST /api/admin/schema/validate-rule.
 func (h *AdminHandlers) ValidateRule(w http.ResponseWriter, r *http.Request) {
-	_, err := h.requireAdminAuth(r, deploy.PermissionDeploy)
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
 	var req ValidateRuleRequest
-	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
-		BadRequest(w, "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &req, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
+
+	switch req.Kind {
+	case "", "rule":
+	case "check":
+		validateCheck(w, req.Expression)
+		return
+	default:
+		BadRequest(w, fmt.Sprintf("Unknown kind %q: expected rule or check", req.Kind))
 		return
 	}
 
@@ -1036,7 +2636,7 @@ func (h *AdminHandlers) ValidateRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	validationErr := validateCELExpression(req.Expression)
+	validationErr := rules.ValidateExpression(req.Expression)
 	if validationErr != nil {
 		resp := ValidateRuleResponse{
 			Valid: false,
@@ -1045,10 +2645,13 @@ func (h *AdminHandlers) ValidateRule(w http.ResponseWriter, r *http.Request) {
 
 		errStr := validationErr.Error()
 		if strings.Contains(errStr, "undeclared reference") {
-			resp.Hints = append(resp.Hints, "Available variables: auth, doc, request")
+			resp.Hints = append(resp.Hints, "Available variables: auth, doc, file, request")
 			resp.Hints = append(resp.Hints, "auth fields: id, email, verified, role, metadata")
 			resp.Hints = append(resp.Hints, "request fields: method, ip")
 		}
+		if strings.Contains(errStr, "undeclared reference") || strings.Contains(errStr, "exists()") {
+			resp.Hints = append(resp.Hints, celFunctionHints...)
+		}
 		if strings.Contains(errStr, "found no matching overload") {
 			resp.Hints = append(resp.Hints, "Check operator types match (e.g., comparing string to string)")
 		}
@@ -1063,28 +2666,49 @@ func (h *AdminHandlers) ValidateRule(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func validateCELExpression(expr string) error {
-	env, err := cel.NewEnv(
-		cel.Variable("auth", cel.MapType(cel.StringType, cel.DynType)),
-		cel.Variable("doc", cel.MapType(cel.StringType, cel.DynType)),
-		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
-	)
-	if err != nil {
-		return fmt.Errorf("creating CEL environment: %w", err)
+// validateCheck writes the ValidateRule response for a collection check.
+func validateCheck(w http.ResponseWriter, expr string) {
+	if expr == "" {
+		JSON(w, http.StatusOK, ValidateRuleResponse{
+			Valid: false,
+			Error: "Check expression is required",
+		})
+		return
 	}
 
-	_, issues := env.Compile(expr)
-	if issues != nil && issues.Err() != nil {
-		return issues.Err()
+	if err := schema.ValidateCheckExpression(expr); err != nil {
+		resp := ValidateRuleResponse{
+			Valid: false,
+			Error: err.Error(),
+		}
+		if strings.Contains(err.Error(), "undeclared reference") {
+			resp.Hints = append(resp.Hints, "Available variables: doc, old (empty on create)")
+		}
+		if strings.Contains(err.Error(), "found no matching overload") {
+			resp.Hints = append(resp.Hints, "Check operator types match (e.g., comparing string to string)")
+		}
+		JSON(w, http.StatusOK, resp)
+		return
 	}
 
-	return nil
+	JSON(w, http.StatusOK, ValidateRuleResponse{
+		Valid:   true,
+		Message: "Expression is valid",
+	})
+}
+
+// celFunctionHints describes the custom functions available in rules.
+var celFunctionHints = []string{
+	"exists(collection, filter): true if a document matches every field in filter, e.g. exists('members', {'org_id': doc.org_id, 'user_id': auth.id})",
+	fmt.Sprintf("exists() may be called at most %d times per rule and not inside all(), exists() or map()", rules.MaxExistsCalls),
+	"has(value, path): true if a dotted path is set in a JSON value, e.g. has(doc.metadata, 'billing.plan')",
+	"size(value): length of a string, list or map; size(null) is 0",
 }
 
 func (h *AdminHandlers) SchemaPendingChanges(w http.ResponseWriter, r *http.Request) {
-	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -1112,7 +2736,7 @@ func (h *AdminHandlers) SchemaPendingChanges(w http.ResponseWriter, r *http.Requ
 func (h *AdminHandlers) SchemaConfirmChanges(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -1144,25 +2768,36 @@ func (h *AdminHandlers) SchemaConfirmChanges(w http.ResponseWriter, r *http.Requ
 
 	changes := h.pendingStore.ToChanges(pending)
 
-	validationErrors := h.migrator.ValidateUnsafeChanges(changes)
+	validationErrors, err := h.applyPendingChanges(changes)
 	if len(validationErrors) > 0 {
-		errorDetails := make([]map[string]string, len(validationErrors))
-		for i, ve := range validationErrors {
-			errorDetails[i] = map[string]string{
-				"path":    ve.Path,
-				"message": ve.Message,
-			}
-		}
-		Error(w, http.StatusConflict, "VALIDATION_FAILED", "Some changes cannot be applied")
+		ErrorWithDetails(w, http.StatusConflict, "VALIDATION_FAILED", "Some changes cannot be applied", validationErrors)
 		return
 	}
-
-	if err := h.migrator.ApplyUnsafeChanges(changes, h.schema); err != nil {
+	if err != nil {
 		log.Error().Err(err).Msg("Failed to apply unsafe changes")
 		Error(w, http.StatusInternalServerError, "MIGRATION_FAILED", err.Error())
 		return
 	}
 
+	JSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Schema changes applied successfully",
+		"applied": len(changes),
+	})
+}
+
+// applyPendingChanges validates changes against the current data and, if
+// they pass, applies them, recreates the triggers and clears the pending
+// changes.
+func (h *AdminHandlers) applyPendingChanges(changes []*schema.Change) ([]schema.ValidationError, error) {
+	if validationErrors := h.migrator.ValidateUnsafeChanges(changes); len(validationErrors) > 0 {
+		return validationErrors, nil
+	}
+
+	if err := h.migrator.ApplyUnsafeChanges(changes, h.schema); err != nil {
+		return nil, err
+	}
+
 	if h.schema != nil {
 		gen := schema.NewSQLGenerator(h.schema)
 		for _, col := range h.schema.Collections {
@@ -1179,18 +2814,13 @@ func (h *AdminHandlers) SchemaConfirmChanges(w http.ResponseWriter, r *http.Requ
 	}
 
 	log.Info().Int("count", len(changes)).Msg("Applied unsafe schema changes")
-
-	JSON(w, http.StatusOK, map[string]any{
-		"success": true,
-		"message": "Schema changes applied successfully",
-		"applied": len(changes),
-	})
+	return nil, nil
 }
 
 func (h *AdminHandlers) SchemaCancelChanges(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -1216,7 +2846,7 @@ func (h *AdminHandlers) SchemaCancelChanges(w http.ResponseWriter, r *http.Reque
 func (h *AdminHandlers) SchemaDraftPreview(w http.ResponseWriter, r *http.Request) {
 	token, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -1228,8 +2858,8 @@ func (h *AdminHandlers) SchemaDraftPreview(w http.ResponseWriter, r *http.Reques
 	var input struct {
 		Content string `json:"content"`
 	}
-	if decodeErr := json.NewDecoder(r.Body).Decode(&input); decodeErr != nil {
-		BadRequest(w, "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &input, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -1240,7 +2870,11 @@ func (h *AdminHandlers) SchemaDraftPreview(w http.ResponseWriter, r *http.Reques
 
 	newSchema, parseErr := schema.Parse([]byte(input.Content))
 	if parseErr != nil {
-		Error(w, http.StatusBadRequest, "INVALID_SCHEMA", parseErr.Error())
+		invalidSchema(w, parseErr)
+		return
+	}
+	if err := rules.Check(newSchema); err != nil {
+		invalidSchema(w, err)
 		return
 	}
 
@@ -1284,7 +2918,7 @@ func (h *AdminHandlers) SchemaDraftPreview(w http.ResponseWriter, r *http.Reques
 func (h *AdminHandlers) SchemaDraftApply(w http.ResponseWriter, r *http.Request) {
 	token, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -1307,7 +2941,11 @@ func (h *AdminHandlers) SchemaDraftApply(w http.ResponseWriter, r *http.Request)
 
 	newSchema, parseErr := schema.Parse([]byte(draftContent))
 	if parseErr != nil {
-		Error(w, http.StatusBadRequest, "INVALID_SCHEMA", parseErr.Error())
+		invalidSchema(w, parseErr)
+		return
+	}
+	if err := rules.Check(newSchema); err != nil {
+		invalidSchema(w, err)
 		return
 	}
 
@@ -1364,6 +3002,8 @@ func (h *AdminHandlers) SchemaDraftApply(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	h.db.Stmts().Purge()
+
 	if err := schema.WriteFile(h.schemaPath, newSchema); err != nil {
 		log.Error().Err(err).Str("path", h.schemaPath).Msg("Failed to write schema file")
 		InternalError(w, "Failed to write schema file")
@@ -1378,6 +3018,8 @@ func (h *AdminHandlers) SchemaDraftApply(w http.ResponseWriter, r *http.Request)
 		Int("unsafe_changes", len(unsafeChanges)).
 		Msg("Applied schema changes and wrote to file")
 
+	h.notifySchemaApplied(newSchema, schema.ChangedCollections(diff))
+
 	JSON(w, http.StatusOK, map[string]any{
 		"success":       true,
 		"message":       "Schema applied successfully",
@@ -1389,7 +3031,7 @@ func (h *AdminHandlers) SchemaDraftApply(w http.ResponseWriter, r *http.Request)
 func (h *AdminHandlers) SchemaDraftCancel(w http.ResponseWriter, r *http.Request) {
 	token, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -1407,9 +3049,9 @@ func (h *AdminHandlers) SchemaDraftCancel(w http.ResponseWriter, r *http.Request
 
 // BucketList returns all buckets from schema.
 func (h *AdminHandlers) BucketList(w http.ResponseWriter, r *http.Request) {
-	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	_, err := h.requireAdminAuth(r, deploy.PermissionRead)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -1436,7 +3078,7 @@ func (h *AdminHandlers) BucketList(w http.ResponseWriter, r *http.Request) {
 func (h *AdminHandlers) BucketCreate(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -1456,8 +3098,8 @@ func (h *AdminHandlers) BucketCreate(w http.ResponseWriter, r *http.Request) {
 		MaxFileSize  int64    `json:"max_file_size"`
 		AllowedTypes []string `json:"allowed_types"`
 	}
-	if decodeErr := json.NewDecoder(r.Body).Decode(&input); decodeErr != nil {
-		BadRequest(w, "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &input, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -1496,7 +3138,7 @@ func (h *AdminHandlers) BucketCreate(w http.ResponseWriter, r *http.Request) {
 func (h *AdminHandlers) BucketUpdate(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
@@ -1521,8 +3163,8 @@ func (h *AdminHandlers) BucketUpdate(w http.ResponseWriter, r *http.Request) {
 		MaxFileSize  int64    `json:"max_file_size"`
 		AllowedTypes []string `json:"allowed_types"`
 	}
-	if decodeErr := json.NewDecoder(r.Body).Decode(&input); decodeErr != nil {
-		BadRequest(w, "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &input, DecodeOptions{Strict: true}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
 		return
 	}
 
@@ -1556,7 +3198,7 @@ func (h *AdminHandlers) BucketUpdate(w http.ResponseWriter, r *http.Request) {
 func (h *AdminHandlers) BucketDelete(w http.ResponseWriter, r *http.Request) {
 	_, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
 	if err != nil {
-		Error(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		adminAuthError(w, err)
 		return
 	}
 
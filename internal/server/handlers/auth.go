@@ -3,8 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"html/template"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -17,6 +20,7 @@ type AuthHandlers struct {
 	service             *auth.Service
 	cfg                 *config.AuthConfig
 	bruteForceProtector BruteForceProtector
+	rateLimits          RateLimitInspector
 }
 
 type BruteForceProtector interface {
@@ -25,6 +29,23 @@ type BruteForceProtector interface {
 	ClearAttempts(key string)
 }
 
+// RateLimitInspector reports a request's quota under the configured rate
+// limits without consuming any of it.
+type RateLimitInspector interface {
+	RateLimitStatus(r *http.Request) []RateLimitStatus
+}
+
+// RateLimitStatus is the caller's quota under one rate limit.
+type RateLimitStatus struct {
+	// Name is the limit's key under auth.rate_limit in the config.
+	Name          string    `json:"name"`
+	Limit         int       `json:"limit"`
+	Remaining     int       `json:"remaining"`
+	WindowSeconds float64   `json:"window_seconds"`
+	Reset         time.Time `json:"reset"`
+	ResetSeconds  int       `json:"reset_seconds"`
+}
+
 func NewAuthHandlers(db *database.DB, cfg *config.AuthConfig, bfp BruteForceProtector) *AuthHandlers {
 	return &AuthHandlers{
 		service:             auth.NewService(db, cfg),
@@ -37,6 +58,11 @@ func (h *AuthHandlers) Service() *auth.Service {
 	return h.service
 }
 
+// SetRateLimitInspector sets the source of the quotas reported by Limits.
+func (h *AuthHandlers) SetRateLimitInspector(inspector RateLimitInspector) {
+	h.rateLimits = inspector
+}
+
 func (h *AuthHandlers) Status(w http.ResponseWriter, r *http.Request) {
 	hasUsers, err := h.service.HasUsers(r.Context())
 	if err != nil {
@@ -53,8 +79,8 @@ func (h *AuthHandlers) Status(w http.ResponseWriter, r *http.Request) {
 
 func (h *AuthHandlers) Register(w http.ResponseWriter, r *http.Request) {
 	var input auth.RegisterInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if err := DecodeJSON(r, &input, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -75,16 +101,8 @@ func (h *AuthHandlers) Register(w http.ResponseWriter, r *http.Request) {
 			Error(w, http.StatusConflict, "USER_EXISTS", "User with this email already exists")
 		case errors.Is(err, auth.ErrRegistrationClosed):
 			Error(w, http.StatusForbidden, "REGISTRATION_CLOSED", "Registration is disabled")
-		case errors.Is(err, auth.ErrPasswordTooShort):
-			Error(w, http.StatusBadRequest, "PASSWORD_TOO_SHORT", "Password is too short")
-		case errors.Is(err, auth.ErrPasswordNoUppercase):
-			Error(w, http.StatusBadRequest, "PASSWORD_NO_UPPERCASE", "Password must contain an uppercase letter")
-		case errors.Is(err, auth.ErrPasswordNoLowercase):
-			Error(w, http.StatusBadRequest, "PASSWORD_NO_LOWERCASE", "Password must contain a lowercase letter")
-		case errors.Is(err, auth.ErrPasswordNoNumber):
-			Error(w, http.StatusBadRequest, "PASSWORD_NO_NUMBER", "Password must contain a number")
-		case errors.Is(err, auth.ErrPasswordNoSpecial):
-			Error(w, http.StatusBadRequest, "PASSWORD_NO_SPECIAL", "Password must contain a special character")
+		case writePasswordPolicyError(w, err):
+		case writeMetadataError(w, err):
 		default:
 			log.Error().Err(err).Msg("Failed to register user")
 			InternalError(w, "Failed to register user")
@@ -98,10 +116,46 @@ func (h *AuthHandlers) Register(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// writePasswordPolicyError writes the response for a password policy
+// violation and reports whether err was one.
+func writePasswordPolicyError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, auth.ErrPasswordTooShort):
+		Error(w, http.StatusBadRequest, "PASSWORD_TOO_SHORT", "Password is too short")
+	case errors.Is(err, auth.ErrPasswordNoUppercase):
+		Error(w, http.StatusBadRequest, "PASSWORD_NO_UPPERCASE", "Password must contain an uppercase letter")
+	case errors.Is(err, auth.ErrPasswordNoLowercase):
+		Error(w, http.StatusBadRequest, "PASSWORD_NO_LOWERCASE", "Password must contain a lowercase letter")
+	case errors.Is(err, auth.ErrPasswordNoNumber):
+		Error(w, http.StatusBadRequest, "PASSWORD_NO_NUMBER", "Password must contain a number")
+	case errors.Is(err, auth.ErrPasswordNoSpecial):
+		Error(w, http.StatusBadRequest, "PASSWORD_NO_SPECIAL", "Password must contain a special character")
+	default:
+		return false
+	}
+	return true
+}
+
+// writeMetadataError writes the response for metadata that does not match
+// the schema's userMetadata declaration and reports whether err was one.
+func writeMetadataError(w http.ResponseWriter, err error) bool {
+	var metaErr *auth.MetadataError
+	if !errors.As(err, &metaErr) {
+		return false
+	}
+
+	details := make([]database.ValidationError, len(metaErr.Errors))
+	for i, e := range metaErr.Errors {
+		details[i] = database.ValidationError{Field: e.Path, Code: "INVALID_METADATA", Message: e.Message}
+	}
+	ErrorWithDetails(w, http.StatusBadRequest, "INVALID_METADATA", metaErr.Error(), details)
+	return true
+}
+
 func (h *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
 	var input auth.LoginInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if err := DecodeJSON(r, &input, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -134,6 +188,8 @@ func (h *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
 			Error(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid email or password")
 		case errors.Is(err, auth.ErrEmailNotVerified):
 			Error(w, http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Email not verified")
+		case errors.Is(err, auth.ErrDeletionPending):
+			Error(w, http.StatusForbidden, "ACCOUNT_PENDING_DELETION", "Account is scheduled for deletion. Use the link in the deletion email to restore it.")
 		default:
 			log.Error().Err(err).Msg("Failed to login user")
 			InternalError(w, "Failed to login")
@@ -153,8 +209,8 @@ func (h *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
 
 func (h *AuthHandlers) Refresh(w http.ResponseWriter, r *http.Request) {
 	var input auth.RefreshInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if err := DecodeJSON(r, &input, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -189,8 +245,8 @@ func (h *AuthHandlers) Refresh(w http.ResponseWriter, r *http.Request) {
 
 func (h *AuthHandlers) Logout(w http.ResponseWriter, r *http.Request) {
 	var input auth.RefreshInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if err := DecodeJSON(r, &input, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -229,6 +285,326 @@ func (h *AuthHandlers) Me(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusOK, user)
 }
 
+// Limits handles GET /api/auth/me/limits, reporting the caller's remaining
+// quota under each rate limit. Checking does not count against any limit.
+func (h *AuthHandlers) Limits(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		Unauthorized(w, "Not authenticated")
+		return
+	}
+
+	limits := []RateLimitStatus{}
+	if h.rateLimits != nil {
+		limits = h.rateLimits.RateLimitStatus(r)
+	}
+
+	JSON(w, http.StatusOK, map[string]any{
+		"limits": limits,
+	})
+}
+
+// UpdateMeRequest is the body for PATCH /api/auth/me. Metadata is a JSON
+// merge patch: keys set to null are removed and other keys are merged into
+// the stored metadata. A null metadata value clears it.
+type UpdateMeRequest struct {
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// UpdateMe lets the signed-in user update their own metadata.
+func (h *AuthHandlers) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		Unauthorized(w, "Not authenticated")
+		return
+	}
+
+	var input UpdateMeRequest
+	if err := DecodeJSON(r, &input, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	if len(input.Metadata) == 0 {
+		BadRequest(w, "metadata is required")
+		return
+	}
+
+	var patch map[string]any
+	if err := json.Unmarshal(input.Metadata, &patch); err != nil {
+		BadRequest(w, "metadata must be an object or null")
+		return
+	}
+
+	updated, err := h.service.UpdateMetadata(r.Context(), user.ID, patch)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrUserNotFound):
+			NotFound(w, "User not found")
+		case writeMetadataError(w, err):
+		default:
+			log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to update metadata")
+			InternalError(w, "Failed to update metadata")
+		}
+		return
+	}
+
+	JSON(w, http.StatusOK, updated)
+}
+
+// DeleteMeRequest is the body for DELETE /api/auth/me. Password may be
+// omitted if the access token was issued within the last five minutes.
+type DeleteMeRequest struct {
+	Password string `json:"password"`
+}
+
+// DeleteMe schedules the signed-in user's account for deletion and signs them
+// out everywhere. The account is removed once the grace period has passed.
+func (h *AuthHandlers) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+	claims := auth.ClaimsFromContext(r.Context())
+	if user == nil || claims == nil {
+		Unauthorized(w, "Not authenticated")
+		return
+	}
+
+	var input DeleteMeRequest
+	if err := DecodeJSON(r, &input, DecodeOptions{Strict: true, AllowEmpty: true}); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+
+	updated, err := h.service.RequestDeletion(r.Context(), user.ID, input.Password, claims.IssuedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			Error(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid password")
+		case errors.Is(err, auth.ErrReauthRequired):
+			Error(w, http.StatusUnauthorized, "REAUTH_REQUIRED", "Confirm your password or sign in again to delete your account")
+		case errors.Is(err, auth.ErrUserNotFound):
+			NotFound(w, "User not found")
+		default:
+			log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to request account deletion")
+			InternalError(w, "Failed to delete account")
+		}
+		return
+	}
+
+	JSON(w, http.StatusAccepted, map[string]any{
+		"deletion_requested_at": updated.DeletionRequestedAt,
+		"purge_at":              updated.DeletionRequestedAt.Add(h.service.DeletionGracePeriod()),
+	})
+}
+
+// RestoreMe cancels a pending account deletion. Like Verify, the token is
+// read from the query string for GET and from the JSON body for POST.
+func (h *AuthHandlers) RestoreMe(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if r.Method == http.MethodPost {
+		var input VerifyRequest
+		if err := DecodeJSON(r, &input, DecodeOptions{Strict: true}); err != nil {
+			invalidJSON(w, r, err)
+			return
+		}
+		token = input.Token
+	}
+	if token == "" {
+		Error(w, http.StatusBadRequest, "TOKEN_REQUIRED", "Token is required")
+		return
+	}
+
+	user, err := h.service.RestoreAccount(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidEmailToken) {
+			Error(w, http.StatusBadRequest, "INVALID_TOKEN", "Restore link is invalid or has expired")
+			return
+		}
+		log.Error().Err(err).Msg("Failed to restore account")
+		InternalError(w, "Failed to restore account")
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]any{
+		"user": user,
+	})
+}
+
+// EmailRequest is the body for endpoints that send an email to an address.
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+// VerifyRequest is the body for POST /api/auth/verify.
+type VerifyRequest struct {
+	Token string `json:"token"`
+}
+
+// ResetPasswordRequest is the body for POST /api/auth/password/reset.
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// RequestVerification sends a new verification link. The response is the
+// same whether or not the address has an unverified account.
+func (h *AuthHandlers) RequestVerification(w http.ResponseWriter, r *http.Request) {
+	var input EmailRequest
+	if err := DecodeJSON(r, &input, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	if input.Email == "" {
+		Error(w, http.StatusBadRequest, "EMAIL_REQUIRED", "Email is required")
+		return
+	}
+
+	if err := h.service.RequestVerification(r.Context(), input.Email); err != nil {
+		log.Error().Err(err).Msg("Failed to request email verification")
+		InternalError(w, "Failed to request verification")
+		return
+	}
+
+	JSON(w, http.StatusAccepted, map[string]any{
+		"message": "If the account exists and is not yet verified, a verification email has been sent",
+	})
+}
+
+// Verify verifies an email address. The token is read from the query string
+// for GET, so the link in the verification email works directly, and from the
+// JSON body for POST.
+func (h *AuthHandlers) Verify(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if r.Method == http.MethodPost {
+		var input VerifyRequest
+		if err := DecodeJSON(r, &input, DecodeOptions{Strict: true}); err != nil {
+			invalidJSON(w, r, err)
+			return
+		}
+		token = input.Token
+	}
+	if token == "" {
+		Error(w, http.StatusBadRequest, "TOKEN_REQUIRED", "Token is required")
+		return
+	}
+
+	user, err := h.service.VerifyEmail(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidEmailToken) {
+			Error(w, http.StatusBadRequest, "INVALID_TOKEN", "Verification link is invalid or has expired")
+			return
+		}
+		log.Error().Err(err).Msg("Failed to verify email")
+		InternalError(w, "Failed to verify email")
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]any{
+		"user": user,
+	})
+}
+
+// ForgotPassword sends a password reset link. The response is the same
+// whether or not the address has an account.
+func (h *AuthHandlers) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input EmailRequest
+	if err := DecodeJSON(r, &input, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	if input.Email == "" {
+		Error(w, http.StatusBadRequest, "EMAIL_REQUIRED", "Email is required")
+		return
+	}
+
+	if err := h.service.RequestPasswordReset(r.Context(), input.Email); err != nil {
+		log.Error().Err(err).Msg("Failed to request password reset")
+		InternalError(w, "Failed to request password reset")
+		return
+	}
+
+	JSON(w, http.StatusAccepted, map[string]any{
+		"message": "If the account exists, a password reset email has been sent",
+	})
+}
+
+// ResetPassword sets a new password using the token from a reset email and
+// signs the user out of all sessions.
+func (h *AuthHandlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input ResetPasswordRequest
+	if err := DecodeJSON(r, &input, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	if input.Token == "" {
+		Error(w, http.StatusBadRequest, "TOKEN_REQUIRED", "Token is required")
+		return
+	}
+	if input.Password == "" {
+		Error(w, http.StatusBadRequest, "PASSWORD_REQUIRED", "Password is required")
+		return
+	}
+
+	if err := h.service.ResetPassword(r.Context(), input.Token, input.Password); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidEmailToken):
+			Error(w, http.StatusBadRequest, "INVALID_TOKEN", "Reset link is invalid or has expired")
+		case writePasswordPolicyError(w, err):
+		default:
+			log.Error().Err(err).Msg("Failed to reset password")
+			InternalError(w, "Failed to reset password")
+		}
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]any{
+		"message": "Password has been reset",
+	})
+}
+
+// resetPasswordPage is served for the default reset link when email.reset_url
+// does not point at a page in the application.
+var resetPasswordPage = template.Must(template.New("reset").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Reset password</title></head>
+<body style="font-family: sans-serif; max-width: 24rem; margin: 4rem auto;">
+  <h1>Reset password</h1>
+  <form id="reset">
+    <input type="password" name="password" placeholder="New password" required autofocus style="width: 100%; padding: 0.5rem;">
+    <button type="submit" style="margin-top: 1rem; padding: 0.5rem 1rem;">Set password</button>
+  </form>
+  <p id="result"></p>
+  <script>
+    const token = {{.}};
+    document.getElementById("reset").addEventListener("submit", async (e) => {
+      e.preventDefault();
+      const res = await fetch(location.pathname, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ token, password: e.target.password.value }),
+      });
+      const body = await res.json();
+      document.getElementById("result").textContent = res.ok ? body.message : (body.error ?? body.detail ?? "Reset failed");
+      if (res.ok) e.target.remove();
+    });
+  </script>
+</body>
+</html>
+`))
+
+// ResetPasswordPage serves a minimal form for the default password reset link.
+func (h *AuthHandlers) ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		Error(w, http.StatusBadRequest, "TOKEN_REQUIRED", "Token is required")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if err := resetPasswordPage.Execute(w, token); err != nil {
+		log.Error().Err(err).Msg("Failed to render password reset page")
+	}
+}
+
 func (h *AuthHandlers) Providers(w http.ResponseWriter, r *http.Request) {
 	providers := make([]string, 0)
 	for name, cfg := range h.cfg.OAuth {
@@ -242,7 +618,22 @@ func (h *AuthHandlers) Providers(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// JWKS handles GET /.well-known/jwks.json, publishing the public key access
+// tokens are signed with so edge middleware can verify them without the
+// server. It is only routed when an asymmetric algorithm is configured.
+func (h *AuthHandlers) JWKS(w http.ResponseWriter, r *http.Request) {
+	jwks, ok := h.service.JWKS()
+	if !ok {
+		NotFound(w, "Tokens are signed with a shared secret; no public keys are published")
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	JSON(w, http.StatusOK, jwks)
+}
+
 // OAuthRedirect initiates the OAuth flow by redirecting to the provider's auth URL.
+// An optional redirect_uri, which must be in auth.oauth.redirect_uri_allowlist,
+// makes the callback finish the login there instead of answering with JSON.
 func (h *AuthHandlers) OAuthRedirect(w http.ResponseWriter, r *http.Request) {
 	providerName := r.PathValue("provider")
 	if providerName == "" {
@@ -261,7 +652,13 @@ func (h *AuthHandlers) OAuthRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	state, err := h.service.OAuth().GenerateState()
+	appRedirect := r.URL.Query().Get("redirect_uri")
+	if appRedirect != "" && !h.cfg.RedirectURIAllowed(appRedirect) {
+		Error(w, http.StatusBadRequest, "INVALID_REDIRECT_URI", "redirect_uri is not in the allowlist")
+		return
+	}
+
+	state, err := h.service.OAuth().GenerateState(providerName, appRedirect)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to generate OAuth state")
 		InternalError(w, "Failed to generate OAuth state")
@@ -275,7 +672,12 @@ func (h *AuthHandlers) OAuthRedirect(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
 }
 
-// OAuthCallback handles the OAuth callback from the provider.
+// OAuthCallback handles the OAuth callback from the provider. When the login
+// was started with a redirect_uri, the user is sent there with a one-time code
+// for OAuthExchange, or with error and error_description if the login failed,
+// so tokens never appear in a URL. Otherwise the tokens are returned as JSON.
+//
+//nolint:gocyclo // Each failure of the provider flow has its own error code
 func (h *AuthHandlers) OAuthCallback(w http.ResponseWriter, r *http.Request) {
 	providerName := r.PathValue("provider")
 	if providerName == "" {
@@ -283,26 +685,15 @@ func (h *AuthHandlers) OAuthCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if errParam := r.URL.Query().Get("error"); errParam != "" {
-		errDesc := r.URL.Query().Get("error_description")
-		log.Warn().Str("provider", providerName).Str("error", errParam).Str("description", errDesc).Msg("OAuth provider returned error")
-		Error(w, http.StatusBadRequest, "OAUTH_ERROR", errDesc)
-		return
-	}
-
-	code := r.URL.Query().Get("code")
-	if code == "" {
-		Error(w, http.StatusBadRequest, "CODE_REQUIRED", "Authorization code is required")
-		return
-	}
-
-	state := r.URL.Query().Get("state")
-	if state == "" {
+	query := r.URL.Query()
+	stateParam := query.Get("state")
+	if stateParam == "" {
 		Error(w, http.StatusBadRequest, "STATE_REQUIRED", "State parameter is required")
 		return
 	}
 
-	if err := h.service.OAuth().ValidateState(state); err != nil {
+	state, err := h.service.OAuth().ValidateState(stateParam)
+	if err != nil {
 		if errors.Is(err, auth.ErrInvalidState) {
 			Error(w, http.StatusBadRequest, "INVALID_STATE", "Invalid state parameter")
 			return
@@ -315,15 +706,42 @@ func (h *AuthHandlers) OAuthCallback(w http.ResponseWriter, r *http.Request) {
 		InternalError(w, "Failed to validate OAuth state")
 		return
 	}
+	if state.Provider != strings.ToLower(providerName) {
+		Error(w, http.StatusBadRequest, "INVALID_STATE", "Invalid state parameter")
+		return
+	}
+
+	// Once the state is trusted, failures go back to the app that started
+	// the login.
+	fail := func(status int, code, message string) {
+		if state.RedirectURI == "" {
+			Error(w, status, code, message)
+			return
+		}
+		redirectToApp(w, r, state.RedirectURI, url.Values{"error": {code}, "error_description": {message}})
+	}
+
+	if errParam := query.Get("error"); errParam != "" {
+		errDesc := query.Get("error_description")
+		log.Warn().Str("provider", providerName).Str("error", errParam).Str("description", errDesc).Msg("OAuth provider returned error")
+		fail(http.StatusBadRequest, "OAUTH_ERROR", errDesc)
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		fail(http.StatusBadRequest, "CODE_REQUIRED", "Authorization code is required")
+		return
+	}
 
 	provider, err := h.service.OAuth().GetProvider(providerName)
 	if err != nil {
 		if errors.Is(err, auth.ErrProviderNotFound) {
-			Error(w, http.StatusNotFound, "PROVIDER_NOT_FOUND", "OAuth provider not found")
+			fail(http.StatusNotFound, "PROVIDER_NOT_FOUND", "OAuth provider not found")
 			return
 		}
 		log.Error().Err(err).Str("provider", providerName).Msg("Failed to get OAuth provider")
-		InternalError(w, "Failed to get OAuth provider")
+		fail(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get OAuth provider")
 		return
 	}
 
@@ -332,19 +750,19 @@ func (h *AuthHandlers) OAuthCallback(w http.ResponseWriter, r *http.Request) {
 	token, err := provider.ExchangeCode(r.Context(), code, redirectURI)
 	if err != nil {
 		log.Error().Err(err).Str("provider", providerName).Msg("Failed to exchange OAuth code")
-		Error(w, http.StatusBadRequest, "TOKEN_EXCHANGE_FAILED", "Failed to exchange authorization code")
+		fail(http.StatusBadRequest, "TOKEN_EXCHANGE_FAILED", "Failed to exchange authorization code")
 		return
 	}
 
 	userInfo, err := provider.GetUserInfo(r.Context(), token)
 	if err != nil {
 		log.Error().Err(err).Str("provider", providerName).Msg("Failed to get user info from OAuth provider")
-		Error(w, http.StatusBadRequest, "USER_INFO_FAILED", "Failed to get user information from provider")
+		fail(http.StatusBadRequest, "USER_INFO_FAILED", "Failed to get user information from provider")
 		return
 	}
 
 	if userInfo.Email == "" {
-		Error(w, http.StatusBadRequest, "EMAIL_REQUIRED", "Email is required from OAuth provider")
+		fail(http.StatusBadRequest, "EMAIL_REQUIRED", "Email is required from OAuth provider")
 		return
 	}
 
@@ -354,11 +772,60 @@ func (h *AuthHandlers) OAuthCallback(w http.ResponseWriter, r *http.Request) {
 	user, tokens, err := h.service.OAuthLogin(r.Context(), userInfo, userAgent, ipAddress)
 	if err != nil {
 		if errors.Is(err, auth.ErrAccountAlreadyLinked) {
-			Error(w, http.StatusConflict, "ACCOUNT_ALREADY_LINKED", "This OAuth account is already linked to another user")
+			fail(http.StatusConflict, "ACCOUNT_ALREADY_LINKED", "This OAuth account is already linked to another user")
+			return
+		}
+		if errors.Is(err, auth.ErrDeletionPending) {
+			fail(http.StatusForbidden, "ACCOUNT_PENDING_DELETION", "Account is scheduled for deletion. Use the link in the deletion email to restore it.")
 			return
 		}
 		log.Error().Err(err).Str("provider", providerName).Msg("Failed to complete OAuth login")
-		InternalError(w, "Failed to complete OAuth login")
+		fail(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to complete OAuth login")
+		return
+	}
+
+	if state.RedirectURI == "" {
+		JSON(w, http.StatusOK, map[string]any{
+			"user":   user,
+			"tokens": tokens,
+		})
+		return
+	}
+
+	loginCode, err := h.service.OAuth().IssueLoginCode(user, tokens)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to issue OAuth login code")
+		fail(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to complete OAuth login")
+		return
+	}
+	redirectToApp(w, r, state.RedirectURI, url.Values{"code": {loginCode}})
+}
+
+// OAuthExchangeRequest is the body of an OAuth login code exchange.
+type OAuthExchangeRequest struct {
+	Code string `json:"code"`
+}
+
+// OAuthExchange handles POST /api/auth/oauth/exchange, trading the one-time
+// code an OAuth callback sent to a redirect URI for the login's tokens.
+func (h *AuthHandlers) OAuthExchange(w http.ResponseWriter, r *http.Request) {
+	var req OAuthExchangeRequest
+	if err := DecodeJSON(r, &req, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	if req.Code == "" {
+		Error(w, http.StatusBadRequest, "CODE_REQUIRED", "Code is required")
+		return
+	}
+
+	user, tokens, err := h.service.OAuth().RedeemLoginCode(req.Code)
+	if err != nil {
+		if errors.Is(err, auth.ErrLoginCodeExpired) {
+			Error(w, http.StatusBadRequest, "CODE_EXPIRED", "Code has expired")
+			return
+		}
+		Error(w, http.StatusBadRequest, "INVALID_CODE", "Invalid or already used code")
 		return
 	}
 
@@ -368,6 +835,26 @@ func (h *AuthHandlers) OAuthCallback(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// redirectToApp sends the user to an allowed redirect URI with params added
+// to its query. The Referrer-Policy keeps the callback URL, which holds the
+// provider's code, from reaching the app.
+func redirectToApp(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		InternalError(w, "Invalid redirect URI")
+		return
+	}
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
 // buildRedirectURI constructs the OAuth callback URI from the request.
 func buildRedirectURI(r *http.Request, provider string) string {
 	scheme := "http"
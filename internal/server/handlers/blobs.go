@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/storage"
+)
+
+// blobTarget resolves the collection and blob field of a blob request and
+// checks op against the collection's rules for the document, writing the
+// error response and returning false if any of it fails.
+func (h *Handlers) blobTarget(w http.ResponseWriter, r *http.Request, op rules.Operation) (*database.Collection, *schema.Field, bool) {
+	collectionName := r.PathValue("collection")
+	id := r.PathValue("id")
+
+	col, err := h.getCollection(r, collectionName)
+	if err != nil {
+		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
+		return nil, nil, false
+	}
+
+	field, ok := col.Schema().Fields[r.PathValue("field")]
+	if !ok || field.Type != schema.FieldTypeBlob || field.Internal {
+		Error(w, http.StatusNotFound, "FIELD_NOT_FOUND", "Blob field not found")
+		return nil, nil, false
+	}
+
+	tenant, err := h.resolveTenant(r, col.Schema())
+	if err != nil {
+		tenantError(w, err)
+		return nil, nil, false
+	}
+
+	doc, err := col.FindOne(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !tenant.owns(doc)) {
+		Error(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
+		return nil, nil, false
+	}
+	if err != nil {
+		log.Error().Err(err).Str("collection", collectionName).Str("id", id).Msg("Failed to get document")
+		Error(w, http.StatusInternalServerError, "QUERY_ERROR", "Failed to get document")
+		return nil, nil, false
+	}
+
+	if err := h.checkAccess(r, collectionName, op, tenant, doc); err != nil {
+		if errors.Is(err, rules.ErrAccessDenied) {
+			h.accessDenied(w, r, err)
+			return nil, nil, false
+		}
+		log.Error().Err(err).Str("collection", collectionName).Msg("Rule evaluation failed")
+		InternalError(w, "Failed to check access")
+		return nil, nil, false
+	}
+
+	if field.Storage != "" && h.storageService == nil {
+		Error(w, http.StatusServiceUnavailable, "STORAGE_UNAVAILABLE", "File storage is not configured")
+		return nil, nil, false
+	}
+
+	return col, field, true
+}
+
+// PutBlob replaces the content of a blob field with the request body, under
+// the collection's update rule. The body's Content-Type is stored with it.
+// Content of fields with a storage bucket is streamed to the bucket;
+// otherwise it is stored in the blob column.
+func (h *Handlers) PutBlob(w http.ResponseWriter, r *http.Request) {
+	col, field, ok := h.blobTarget(w, r, rules.OpUpdate)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+
+	mimeType := database.DefaultBlobMimeType
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if _, _, err := mime.ParseMediaType(ct); err != nil {
+			Error(w, http.StatusBadRequest, "INVALID_CONTENT_TYPE", "Invalid Content-Type header")
+			return
+		}
+		mimeType = ct
+	}
+
+	previous, err := col.GetBlobInfo(r.Context(), id, field.Name)
+	if err != nil && !errors.Is(err, database.ErrBlobNotFound) {
+		log.Error().Err(err).Str("collection", col.Name()).Str("id", id).Msg("Failed to read blob metadata")
+		InternalError(w, "Failed to read blob")
+		return
+	}
+
+	var (
+		info    *database.BlobInfo
+		content []byte
+	)
+	if field.Storage != "" {
+		file, err := h.storageService.PutObject(r.Context(), field.Storage, field.Name, mimeType, r.Body, r.ContentLength)
+		if err != nil {
+			blobUploadError(w, r, err)
+			return
+		}
+		info = &database.BlobInfo{
+			Size:     file.Size,
+			MimeType: file.MimeType,
+			ETag:     file.Checksum,
+			Bucket:   field.Storage,
+			FileID:   file.ID,
+		}
+	} else {
+		content, err = io.ReadAll(r.Body)
+		if err != nil {
+			if bodyTooLarge(w, r, err) {
+				return
+			}
+			Error(w, http.StatusBadRequest, "INVALID_BODY", "Failed to read request body")
+			return
+		}
+		info = database.NewBlobInfo(content, mimeType)
+	}
+
+	err = h.dbFor(r.Context()).RunInTransaction(r.Context(), func(ctx context.Context) error {
+		if _, err := col.SetBlob(ctx, id, field.Name, content, info); err != nil {
+			return err
+		}
+		if previous != nil && previous.Bucket != "" {
+			database.AfterCommit(ctx, func(ctx context.Context) {
+				h.deleteBlobObject(ctx, previous)
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		if info.Bucket != "" {
+			h.deleteBlobObject(r.Context(), info)
+		}
+		if errors.Is(err, database.ErrNotFound) {
+			Error(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
+			return
+		}
+		log.Error().Err(err).Str("collection", col.Name()).Str("id", id).Str("field", field.Name).Msg("Failed to store blob")
+		Error(w, http.StatusInternalServerError, "UPDATE_ERROR", "Failed to store blob")
+		return
+	}
+
+	w.Header().Set("ETag", `"`+info.ETag+`"`)
+	JSON(w, http.StatusOK, info)
+}
+
+// blobUploadError writes the response for a failed upload of bucket content.
+func blobUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		BodyTooLarge(w, r, maxErr.Limit, "")
+	case errors.Is(err, storage.ErrFileTooLarge):
+		Error(w, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", err.Error())
+	case errors.Is(err, storage.ErrTypeNotAllowed):
+		Error(w, http.StatusBadRequest, "INVALID_FILE_TYPE", err.Error())
+	default:
+		log.Error().Err(err).Msg("Failed to store blob in bucket")
+		Error(w, http.StatusInternalServerError, "UPLOAD_ERROR", "Failed to store blob")
+	}
+}
+
+// GetBlob serves the content of a blob field under the collection's read
+// rule, with its stored Content-Type and its SHA-256 as a strong ETag.
+// Range, If-Range and conditional requests are supported, as are HEAD
+// requests.
+func (h *Handlers) GetBlob(w http.ResponseWriter, r *http.Request) {
+	col, field, ok := h.blobTarget(w, r, rules.OpRead)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+
+	info, err := col.GetBlobInfo(r.Context(), id, field.Name)
+	if errors.Is(err, database.ErrBlobNotFound) || errors.Is(err, database.ErrNotFound) {
+		Error(w, http.StatusNotFound, "BLOB_NOT_FOUND", "Blob has no content")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("collection", col.Name()).Str("id", id).Msg("Failed to read blob metadata")
+		InternalError(w, "Failed to read blob")
+		return
+	}
+
+	var content io.ReadSeeker
+	if info.Bucket != "" {
+		if h.storageService == nil {
+			Error(w, http.StatusServiceUnavailable, "STORAGE_UNAVAILABLE", "File storage is not configured")
+			return
+		}
+		object := &objectSeeker{
+			size: info.Size,
+			open: func() (io.ReadCloser, error) {
+				rc, _, err := h.storageService.GetObject(r.Context(), info.Bucket, info.FileID)
+				return rc, err
+			},
+		}
+		defer object.Close()
+		content = object
+	} else {
+		content, err = col.BlobReader(r.Context(), id, field.Name, info.Size)
+		if err != nil {
+			log.Error().Err(err).Str("collection", col.Name()).Str("id", id).Msg("Failed to open blob")
+			InternalError(w, "Failed to read blob")
+			return
+		}
+	}
+
+	etag := ""
+	if info.ETag != "" {
+		etag = `"` + info.ETag + `"`
+	}
+	setPrivateValidators(w, etag, info.UpdatedAt)
+	if etag == "" {
+		w.Header().Del("ETag")
+	}
+	w.Header().Set("Content-Type", info.MimeType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// ServeContent answers Range, If-Range and the conditional headers from
+	// the validators set above.
+	http.ServeContent(w, r, "", info.UpdatedAt, content)
+}
+
+// deleteBlobObject deletes the bucket file holding blob content that is no
+// longer referenced.
+func (h *Handlers) deleteBlobObject(ctx context.Context, info *database.BlobInfo) {
+	if h.storageService == nil {
+		return
+	}
+	if err := h.storageService.DeleteObject(ctx, info.Bucket, info.FileID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Error().Err(err).Str("bucket", info.Bucket).Str("file_id", info.FileID).Msg("Failed to delete blob content")
+	}
+}
+
+// bucketBlobs returns the BlobInfo of the document's blob fields whose
+// content is in a bucket, restricted to fields when it is non-nil.
+func (h *Handlers) bucketBlobs(ctx context.Context, col *schema.Collection, id string, fields map[string]bool) ([]*database.BlobInfo, error) {
+	coll := database.NewCollection(h.db, col)
+	var infos []*database.BlobInfo
+	for _, field := range col.OrderedFields() {
+		if field.Type != schema.FieldTypeBlob || (fields != nil && !fields[field.Name]) {
+			continue
+		}
+		info, err := coll.GetBlobInfo(ctx, id, field.Name)
+		if errors.Is(err, database.ErrBlobNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info.Bucket != "" {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// objectSeeker makes a bucket file seekable for http.ServeContent. Backends
+// only stream files from the start, so reading after a seek reopens the
+// file and skips to the offset; ServeContent seeks once per range.
+type objectSeeker struct {
+	open func() (io.ReadCloser, error)
+	size int64
+
+	pos      int64
+	rc       io.ReadCloser
+	rcOffset int64
+}
+
+func (s *objectSeeker) Read(p []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	if s.rc != nil && s.rcOffset != s.pos {
+		_ = s.Close()
+	}
+	if s.rc == nil {
+		rc, err := s.open()
+		if err != nil {
+			return 0, err
+		}
+		if seeker, ok := rc.(io.Seeker); ok {
+			_, err = seeker.Seek(s.pos, io.SeekStart)
+		} else {
+			_, err = io.CopyN(io.Discard, rc, s.pos)
+		}
+		if err != nil {
+			_ = rc.Close()
+			return 0, err
+		}
+		s.rc, s.rcOffset = rc, s.pos
+	}
+
+	n, err := s.rc.Read(p)
+	s.pos += int64(n)
+	s.rcOffset += int64(n)
+	return n, err
+}
+
+func (s *objectSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+func (s *objectSeeker) Close() error {
+	if s.rc == nil {
+		return nil
+	}
+	err := s.rc.Close()
+	s.rc = nil
+	return err
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/storage"
+)
+
+func setupBlobHandlers(t *testing.T) *Handlers {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(tmpDir, "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(`
+version: 1
+buckets:
+  media:
+    backend: filesystem
+collections:
+  clips:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+      thumb:
+        type: blob
+        nullable: true
+      video:
+        type: blob
+        nullable: true
+        storage: media
+`))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+
+	backends := map[string]storage.Backend{
+		"filesystem": storage.NewFilesystemBackend(filepath.Join(tmpDir, "storage")),
+	}
+	h := New(db, s, config.Default(), nil)
+	h.SetStorageService(storage.NewService(db, backends, s, config.Default(), nil))
+	return h
+}
+
+func createBlobDoc(t *testing.T, h *Handlers, body string) string {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/collections/clips", strings.NewReader(body))
+	req.SetPathValue("collection", "clips")
+	w := httptest.NewRecorder()
+	h.CreateDocument(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create document: %d %s", w.Code, w.Body.String())
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	return doc["id"].(string)
+}
+
+func blobRequest(method, id, field string, body []byte) *http.Request {
+	req := httptest.NewRequest(method, "/api/collections/clips/"+id+"/blob/"+field, bytes.NewReader(body))
+	req.SetPathValue("collection", "clips")
+	req.SetPathValue("id", id)
+	req.SetPathValue("field", field)
+	return req
+}
+
+func getBlobDoc(t *testing.T, h *Handlers, id string) map[string]any {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/collections/clips/"+id, nil)
+	req.SetPathValue("collection", "clips")
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	h.GetDocument(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get document: %d %s", w.Code, w.Body.String())
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	return doc
+}
+
+func TestBlobColumnUploadAndRange(t *testing.T) {
+	h := setupBlobHandlers(t)
+	id := createBlobDoc(t, h, `{"title":"a"}`)
+
+	content := []byte("0123456789abcdefghij")
+	req := blobRequest(http.MethodPut, id, "thumb", content)
+	req.Header.Set("Content-Type", "image/webp")
+	w := httptest.NewRecorder()
+	h.PutBlob(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("put blob: %d %s", w.Code, w.Body.String())
+	}
+
+	sum := sha256.Sum256(content)
+	etag := hex.EncodeToString(sum[:])
+
+	thumb, ok := getBlobDoc(t, h, id)["thumb"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected blob metadata in document, got %v", getBlobDoc(t, h, id)["thumb"])
+	}
+	if thumb["size"] != float64(len(content)) || thumb["mime"] != "image/webp" || thumb["etag"] != etag {
+		t.Errorf("unexpected blob metadata: %v", thumb)
+	}
+
+	w = httptest.NewRecorder()
+	h.GetBlob(w, blobRequest(http.MethodGet, id, "thumb", nil))
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Fatalf("get blob: %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "image/webp" {
+		t.Errorf("expected Content-Type image/webp, got %q", got)
+	}
+	if got := w.Header().Get("ETag"); got != `"`+etag+`"` {
+		t.Errorf("expected ETag %q, got %q", etag, got)
+	}
+
+	req = blobRequest(http.MethodGet, id, "thumb", nil)
+	req.Header.Set("Range", "bytes=5-9")
+	w = httptest.NewRecorder()
+	h.GetBlob(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "56789" {
+		t.Fatalf("range request: %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 5-9/20" {
+		t.Errorf("expected Content-Range bytes 5-9/20, got %q", got)
+	}
+
+	req = blobRequest(http.MethodGet, id, "thumb", nil)
+	req.Header.Set("If-None-Match", `"`+etag+`"`)
+	w = httptest.NewRecorder()
+	h.GetBlob(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for matching If-None-Match, got %d", w.Code)
+	}
+}
+
+func TestBlobJSONInputExposesMetadata(t *testing.T) {
+	h := setupBlobHandlers(t)
+	content := []byte("hello blob")
+	id := createBlobDoc(t, h, `{"title":"a","thumb":"`+base64.StdEncoding.EncodeToString(content)+`"}`)
+
+	thumb, ok := getBlobDoc(t, h, id)["thumb"].(map[string]any)
+	if !ok || thumb["size"] != float64(len(content)) || thumb["mime"] != database.DefaultBlobMimeType {
+		t.Fatalf("unexpected blob metadata: %v", thumb)
+	}
+
+	w := httptest.NewRecorder()
+	h.GetBlob(w, blobRequest(http.MethodGet, id, "thumb", nil))
+	if w.Body.String() != string(content) {
+		t.Errorf("expected decoded content, got %q", w.Body.String())
+	}
+}
+
+func TestBlobNotFound(t *testing.T) {
+	h := setupBlobHandlers(t)
+	id := createBlobDoc(t, h, `{"title":"a"}`)
+
+	w := httptest.NewRecorder()
+	h.GetBlob(w, blobRequest(http.MethodGet, id, "thumb", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for empty blob, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.GetBlob(w, blobRequest(http.MethodGet, id, "title", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for non-blob field, got %d", w.Code)
+	}
+}
+
+func TestBlobBucketStorage(t *testing.T) {
+	h := setupBlobHandlers(t)
+	id := createBlobDoc(t, h, `{"title":"a"}`)
+
+	content := bytes.Repeat([]byte("video-frame "), 100)
+	req := blobRequest(http.MethodPut, id, "video", content)
+	req.Header.Set("Content-Type", "video/mp4")
+	w := httptest.NewRecorder()
+	h.PutBlob(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("put blob: %d %s", w.Code, w.Body.String())
+	}
+
+	video, ok := getBlobDoc(t, h, id)["video"].(map[string]any)
+	if !ok || video["size"] != float64(len(content)) || video["mime"] != "video/mp4" {
+		t.Fatalf("unexpected blob metadata: %v", video)
+	}
+
+	col, err := h.getCollection(httptest.NewRequest(http.MethodGet, "/", nil), "clips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := col.GetBlobInfo(context.Background(), id, "video")
+	if err != nil {
+		t.Fatalf("GetBlobInfo: %v", err)
+	}
+	if info.Bucket != "media" || info.FileID == "" {
+		t.Fatalf("expected content in media bucket, got %+v", info)
+	}
+
+	req = blobRequest(http.MethodGet, id, "video", nil)
+	req.Header.Set("Range", "bytes=12-23")
+	w = httptest.NewRecorder()
+	h.GetBlob(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "video-frame " {
+		t.Fatalf("range request: %d %q", w.Code, w.Body.String())
+	}
+
+	// Replacing the content removes the previous file.
+	w = httptest.NewRecorder()
+	h.PutBlob(w, blobRequest(http.MethodPut, id, "video", []byte("short")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("replace blob: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := h.storageService.GetMetadata(context.Background(), "media", info.FileID); err != storage.ErrNotFound {
+		t.Errorf("expected previous file to be deleted, got %v", err)
+	}
+
+	// JSON input for bucket content is rejected.
+	req = httptest.NewRequest(http.MethodPatch, "/api/collections/clips/"+id, strings.NewReader(`{"video":"aGVsbG8="}`))
+	req.SetPathValue("collection", "clips")
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	h.UpdateDocument(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for JSON bucket content, got %d: %s", w.Code, w.Body.String())
+	}
+
+	current, err := col.GetBlobInfo(context.Background(), id, "video")
+	if err != nil {
+		t.Fatalf("GetBlobInfo: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/collections/clips/"+id, nil)
+	req.SetPathValue("collection", "clips")
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	h.DeleteDocument(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("delete document: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := h.storageService.GetMetadata(context.Background(), "media", current.FileID); err != storage.ErrNotFound {
+		t.Errorf("expected blob file to be deleted with the document, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const checksSchemaYAML = `
+version: 1
+collections:
+  bookings:
+    fields:
+      id:
+        type: string
+        primary: true
+      start_date:
+        type: timestamp
+      end_date:
+        type: timestamp
+    checks:
+      - name: ends_after_start
+        expr: doc.end_date >= doc.start_date
+        message: End date must not be before the start date
+    rules:
+      create: "true"
+      update: "true"
+`
+
+func TestDocumentChecks(t *testing.T) {
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(checksSchemaYAML))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatal(err)
+	}
+	h := New(db, s, config.Default(), engine)
+
+	writes := []struct {
+		method  string
+		id      string
+		body    map[string]any
+		handler func(http.ResponseWriter, *http.Request)
+		status  int
+	}{
+		{http.MethodPost, "", map[string]any{"id": "e1", "start_date": "2024-05-02T00:00:00Z", "end_date": "2024-05-01T00:00:00Z"}, h.CreateDocument, http.StatusUnprocessableEntity},
+		{http.MethodPost, "", map[string]any{"id": "e1", "start_date": "2024-05-01T00:00:00Z", "end_date": "2024-05-03T00:00:00Z"}, h.CreateDocument, http.StatusCreated},
+		// Only end_date is sent; the check sees the stored start_date.
+		{http.MethodPatch, "e1", map[string]any{"end_date": "2024-04-30T00:00:00Z"}, h.UpdateDocument, http.StatusUnprocessableEntity},
+		{http.MethodPatch, "e1", map[string]any{"end_date": "2024-05-02T00:00:00Z"}, h.UpdateDocument, http.StatusOK},
+	}
+	for i, wr := range writes {
+		w := httptest.NewRecorder()
+		wr.handler(w, historyRequest(wr.method, "bookings", wr.id, "user", wr.body))
+		if w.Code != wr.status {
+			t.Fatalf("write %d: expected status %d, got %d: %s", i, wr.status, w.Code, w.Body.String())
+		}
+		if wr.status != http.StatusUnprocessableEntity {
+			continue
+		}
+		var resp struct {
+			Error   string            `json:"error"`
+			Code    string            `json:"code"`
+			Details map[string]string `json:"details"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Code != "CHECK_FAILED" || resp.Details["check"] != "ends_after_start" || resp.Error != "End date must not be before the start date" {
+			t.Errorf("write %d: unexpected response %s", i, w.Body.String())
+		}
+	}
+}
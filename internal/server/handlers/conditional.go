@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// etagLength is how many hex characters of the SHA-256 digest an ETag keeps.
+const etagLength = 32
+
+// weakETag returns a weak validator hashing parts in order. Validators are
+// weak because responses may be compressed or re-encoded on the way out.
+func weakETag(parts ...string) string {
+	hash := sha256.New()
+	for _, p := range parts {
+		hash.Write([]byte(p))
+		hash.Write([]byte{0})
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil))[:etagLength] + `"`
+}
+
+// viewerKey identifies whose permissions a response was computed with, so
+// users that may see different documents never share a validator.
+func viewerKey(r *http.Request) string {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return "anonymous"
+	}
+	return user.ID + ":" + user.Role
+}
+
+// ruleInputsKey identifies everything besides the documents that read rules
+// see: the auth context, including metadata, the request and the tenant.
+// Listings computed with different inputs may hold different documents.
+func (h *Handlers) ruleInputsKey(r *http.Request, tenant *tenantScope) string {
+	evalCtx := h.evalContext(r, tenant, nil)
+	data, err := json.Marshal([]any{evalCtx.Auth, evalCtx.Request, evalCtx.Tenant})
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// listETag derives a validator for a collection listing from the
+// collection's change count, the query string, the read rules,
+// and the rule inputs of the request. Deletes leave no timestamp behind, so
+// listings carry no Last-Modified.
+//
+// It returns "" when the listing can change without the collection
+// changing: for collections whose changes are not recorded, and for read
+// rules that call exists(), which reads other collections.
+func (h *Handlers) listETag(r *http.Request, col *schema.Collection, tenant *tenantScope) (string, error) {
+	var readRule string
+	if rules := col.Rules; rules != nil {
+		readRule = rules.Read
+	}
+	// Field read rules change which fields the viewer gets.
+	for _, field := range col.ReadRuleFields() {
+		readRule += "\n" + field.Name + ":" + field.ReadRule
+	}
+	if strings.Contains(readRule, "exists(") {
+		return "", nil
+	}
+
+	version, ok, err := h.docs.Version(r.Context(), col)
+	if err != nil || !ok {
+		return "", err
+	}
+	inputs := h.ruleInputsKey(r, tenant)
+	if inputs == "" {
+		return "", nil
+	}
+
+	etag := weakETag(
+		col.Name,
+		inputs,
+		readRule,
+		strconv.FormatInt(version.Changes, 10),
+		r.URL.Query().Encode(),
+	)
+	return etag, nil
+}
+
+// documentLastModified returns the value of the collection's auto-update
+// timestamp field in doc, or the zero time if it has none.
+func documentLastModified(col *schema.Collection, doc database.Row) time.Time {
+	for _, field := range col.OrderedFields() {
+		if !field.IsAutoUpdateTimestamp() {
+			continue
+		}
+		switch v := doc[field.Name].(type) {
+		case time.Time:
+			return v
+		case string:
+			t, _ := time.Parse(time.RFC3339, v)
+			return t
+		}
+		return time.Time{}
+	}
+	return time.Time{}
+}
+
+// setPrivateValidators marks a response as cacheable only by the requesting
+// client and only after revalidation, and records its validators.
+func setPrivateValidators(w http.ResponseWriter, etag string, lastModified time.Time) {
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "private, no-cache")
+	AddVary(h, "Authorization")
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// checkNotModified writes 304 Not Modified and returns true when the
+// request's conditional headers match etag or lastModified. If-None-Match
+// takes precedence over If-Modified-Since, as in RFC 9110.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else {
+		ims := r.Header.Get("If-Modified-Since")
+		if ims == "" || lastModified.IsZero() {
+			return false
+		}
+		since, err := http.ParseTime(ims)
+		if err != nil || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches performs the weak comparison If-None-Match requires against
+// a comma-separated list of entity tags.
+func etagMatches(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// AddVary adds value to the Vary header unless it is already listed.
+func AddVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
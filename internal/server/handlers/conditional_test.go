@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/retention"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func setupConditionalHandlers(t *testing.T) (*Handlers, *database.DB) {
+	t.Helper()
+
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+      updated_at:
+        type: timestamp
+        default: now
+        onUpdate: now
+`))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+	if _, err := db.ExecContext(context.Background(),
+		`INSERT INTO posts (id, title, updated_at) VALUES ('p1', 'First', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+
+	return New(db, s, config.Default(), nil), db
+}
+
+func conditionalMux(h *Handlers, docs *DocsHandler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/collections/{collection}", h.ListDocuments)
+	mux.HandleFunc("GET /api/collections/{collection}/{id}", h.GetDocument)
+	mux.HandleFunc("HEAD /api/collections/{collection}", HeadHandler(h.ListDocuments))
+	mux.HandleFunc("HEAD /api/collections/{collection}/{id}", HeadHandler(h.GetDocument))
+	if docs != nil {
+		mux.HandleFunc("GET /api/openapi.json", docs.OpenAPISpec)
+	}
+	return mux
+}
+
+func conditionalGet(t *testing.T, handler http.Handler, path, etag string, user *auth.User) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if user != nil {
+		req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestOpenAPISpecETag(t *testing.T) {
+	h, _ := setupConditionalHandlers(t)
+	mux := conditionalMux(h, NewDocsHandler(h.schema, config.Default()))
+
+	first := conditionalGet(t, mux, "/api/openapi.json", "", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %q", first.Code, etag)
+	}
+
+	if w := conditionalGet(t, mux, "/api/openapi.json", etag, nil); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected empty 304, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if w := conditionalGet(t, mux, "/api/openapi.json", `W/"other", `+etag, nil); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for matching ETag in list, got %d", w.Code)
+	}
+	if w := conditionalGet(t, mux, "/api/openapi.json", `W/"stale"`, nil); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for stale ETag, got %d", w.Code)
+	}
+
+	cfg := config.Default()
+	cfg.Docs.Version = "2.0.0"
+	other := conditionalMux(h, NewDocsHandler(h.schema, cfg))
+	if w := conditionalGet(t, other, "/api/openapi.json", etag, nil); w.Code != http.StatusOK {
+		t.Errorf("expected config change to invalidate ETag, got %d", w.Code)
+	}
+}
+
+func TestGetDocumentETag(t *testing.T) {
+	h, db := setupConditionalHandlers(t)
+	mux := conditionalMux(h, nil)
+
+	first := conditionalGet(t, mux, "/api/collections/posts/p1", "", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %q", first.Code, etag)
+	}
+	if got := first.Header().Get("Last-Modified"); got != "Mon, 01 Jan 2024 00:00:00 GMT" {
+		t.Errorf("expected Last-Modified from updated_at, got %q", got)
+	}
+
+	if w := conditionalGet(t, mux, "/api/collections/posts/p1", etag, nil); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/collections/posts/p1", nil)
+	req.Header.Set("If-Modified-Since", "Mon, 01 Jan 2024 00:00:00 GMT")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for If-Modified-Since, got %d", w.Code)
+	}
+
+	alice := &auth.User{ID: "alice", Role: "user"}
+	if w := conditionalGet(t, mux, "/api/collections/posts/p1", etag, alice); w.Code != http.StatusOK {
+		t.Errorf("expected another viewer not to share the validator, got %d", w.Code)
+	}
+
+	if _, err := db.ExecContext(context.Background(), `UPDATE posts SET title = 'Edited' WHERE id = 'p1'`); err != nil {
+		t.Fatal(err)
+	}
+	if w := conditionalGet(t, mux, "/api/collections/posts/p1", etag, nil); w.Code != http.StatusOK {
+		t.Errorf("expected 200 after update, got %d", w.Code)
+	}
+}
+
+func TestListDocumentsETag(t *testing.T) {
+	h, db := setupConditionalHandlers(t)
+	mux := conditionalMux(h, nil)
+	ctx := context.Background()
+
+	first := conditionalGet(t, mux, "/api/collections/posts?limit=10", "", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %q", first.Code, etag)
+	}
+	if got := first.Header().Get("Cache-Control"); got != "private, no-cache" {
+		t.Errorf("expected private Cache-Control, got %q", got)
+	}
+
+	if w := conditionalGet(t, mux, "/api/collections/posts?limit=10", etag, nil); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", w.Code)
+	}
+	if w := conditionalGet(t, mux, "/api/collections/posts?limit=20", etag, nil); w.Code != http.StatusOK {
+		t.Errorf("expected different query not to share the validator, got %d", w.Code)
+	}
+
+	admin := &auth.User{ID: "u1", Role: "admin"}
+	user := &auth.User{ID: "u1", Role: "user"}
+	adminETag := conditionalGet(t, mux, "/api/collections/posts?limit=10", "", admin).Header().Get("ETag")
+	userETag := conditionalGet(t, mux, "/api/collections/posts?limit=10", "", user).Header().Get("ETag")
+	if adminETag == userETag || adminETag == etag {
+		t.Errorf("expected validators to differ by viewer, got %q and %q", adminETag, userETag)
+	}
+	if w := conditionalGet(t, mux, "/api/collections/posts?limit=10", adminETag, user); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for another role's validator, got %d", w.Code)
+	}
+
+	col, err := h.getCollection(httptest.NewRequest(http.MethodGet, "/", nil), "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := col.Create(ctx, database.Row{"title": "Second"}); err != nil {
+		t.Fatal(err)
+	}
+	if w := conditionalGet(t, mux, "/api/collections/posts?limit=10", etag, nil); w.Code != http.StatusOK {
+		t.Errorf("expected 200 after insert, got %d", w.Code)
+	}
+
+	// An update that leaves the row count and the latest timestamp alone,
+	// as one within the same second would, must still change the validator.
+	etag = conditionalGet(t, mux, "/api/collections/posts?limit=10", "", nil).Header().Get("ETag")
+	if _, err := db.ExecContext(ctx, `UPDATE posts SET title = 'Edited', updated_at = updated_at WHERE id = 'p1'`); err != nil {
+		t.Fatal(err)
+	}
+	if w := conditionalGet(t, mux, "/api/collections/posts?limit=10", etag, nil); w.Code != http.StatusOK {
+		t.Errorf("expected 200 after an update in the same second, got %d", w.Code)
+	}
+}
+
+func TestListDocumentsETagSuppressedPurge(t *testing.T) {
+	h, _ := setupConditionalHandlers(t)
+	mux := conditionalMux(h, nil)
+	ctx := context.Background()
+	path := "/api/collections/posts?limit=10"
+
+	col, err := h.getCollection(httptest.NewRequest(http.MethodGet, "/", nil), "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := col.Create(ctx, database.Row{"title": "Second"}); err != nil {
+		t.Fatal(err)
+	}
+	etag := conditionalGet(t, mux, path, "", nil).Header().Get("ETag")
+
+	// A suppressed purge deletes the change rows it writes, so the latest
+	// change ID is the same before and after it.
+	h.schema.Collections["posts"].Retention = &schema.RetentionPolicy{Field: "updated_at", MaxAge: "1d"}
+	svc := retention.NewService(h.db, h.schema, &config.RetentionConfig{
+		Enabled:          true,
+		Interval:         time.Hour,
+		BatchSize:        10,
+		SuppressRealtime: true,
+	})
+	pruned, err := svc.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if pruned["posts"] != 1 {
+		t.Fatalf("expected 1 pruned row, got %d", pruned["posts"])
+	}
+
+	if w := conditionalGet(t, mux, path, etag, nil); w.Code != http.StatusOK {
+		t.Errorf("expected 200 after a suppressed purge, got %d", w.Code)
+	}
+}
+
+func TestListDocumentsETagRuleInputs(t *testing.T) {
+	h, _ := setupConditionalHandlers(t)
+	mux := conditionalMux(h, nil)
+	path := "/api/collections/posts?limit=10"
+
+	free := &auth.User{ID: "u1", Role: "user", Metadata: map[string]any{"plan": "free"}}
+	pro := &auth.User{ID: "u1", Role: "user", Metadata: map[string]any{"plan": "pro"}}
+	etag := conditionalGet(t, mux, path, "", free).Header().Get("ETag")
+	if w := conditionalGet(t, mux, path, etag, pro); w.Code != http.StatusOK {
+		t.Errorf("expected 200 once the viewer's metadata changed, got %d", w.Code)
+	}
+
+	h.schema.Collections["posts"].Rules = &schema.Rules{Read: "exists('posts', {'title': 'First'})"}
+	if w := conditionalGet(t, mux, path, "", free); w.Header().Get("ETag") != "" {
+		t.Errorf("expected no ETag for a read rule calling exists(), got %q", w.Header().Get("ETag"))
+	}
+}
+
+func TestHeadMatchesGet(t *testing.T) {
+	h, _ := setupConditionalHandlers(t)
+	mux := conditionalMux(h, nil)
+
+	for _, path := range []string{"/api/collections/posts", "/api/collections/posts/p1"} {
+		get := conditionalGet(t, mux, path, "", nil)
+		req := httptest.NewRequest(http.MethodHead, path, nil)
+		head := httptest.NewRecorder()
+		mux.ServeHTTP(head, req)
+
+		if head.Code != get.Code {
+			t.Errorf("HEAD %s: status %d, GET gave %d", path, head.Code, get.Code)
+		}
+		if head.Body.Len() != 0 {
+			t.Errorf("HEAD %s: expected an empty body, got %q", path, head.Body.String())
+		}
+		for _, name := range []string{"ETag", "Content-Type", TotalCountHeader} {
+			if head.Header().Get(name) != get.Header().Get(name) {
+				t.Errorf("HEAD %s: %s = %q, GET gave %q", path, name, head.Header().Get(name), get.Header().Get(name))
+			}
+		}
+	}
+	if got := conditionalGet(t, mux, "/api/collections/posts", "", nil).Header().Get(TotalCountHeader); got != "1" {
+		t.Errorf("expected %s 1, got %q", TotalCountHeader, got)
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`"x", W/"abc"`, true},
+		{`*`, true},
+		{`"abcd"`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `W/"abc"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
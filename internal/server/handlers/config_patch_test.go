@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/events"
+)
+
+func TestConfigPatch(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(dir, "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	configPath := filepath.Join(dir, "alyx.yaml")
+	if err := os.WriteFile(configPath, []byte("# Project config\nauth:\n  jwt:\n    access_ttl: 10m # short\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	authService := auth.NewService(db, &config.AuthConfig{
+		JWT:      config.JWTConfig{Secret: "testsecret12345678901234567890123456", Issuer: "test", AccessTTL: time.Minute, RefreshTTL: time.Hour},
+		Password: config.PasswordConfig{MinLength: 8},
+	})
+	_, tokens, err := authService.Register(context.Background(), auth.RegisterInput{Email: "admin@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	cfg := config.Default()
+	cfg.Dev.Enabled = true
+	h := NewAdminHandlers(nil, authService, db, nil, nil, cfg, "", configPath)
+	recorder := &eventRecorder{}
+	h.SetEventPublisher(recorder)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPatch, "/api/admin/config", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		w := httptest.NewRecorder()
+		h.ConfigPatch(w, req)
+		return w
+	}
+
+	w := patch(`{"ops": [{"path": "auth.jwt.access_ttl", "value": "30m"}, {"path": "auth.jwt.secret", "value": "a-new-secret-that-is-long-enough-for-jwt"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "a-new-secret") {
+		t.Errorf("response echoes the secret: %s", w.Body.String())
+	}
+	if len(recorder.events) != 1 || recorder.events[0].channel != events.ChannelAdmin || recorder.events[0].event != events.ConfigUpdated {
+		t.Fatalf("expected config.updated on _admin, got %+v", recorder.events)
+	}
+	if update := recorder.events[0].data.(*ConfigUpdatedEvent); update.Path != configPath || len(update.Changed) != 2 || update.By != "jwt:admin@example.com" {
+		t.Errorf("unexpected config.updated data %+v", update)
+	}
+	data, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(data), "access_ttl: 30m # short") || !strings.Contains(string(data), "# Project config") {
+		t.Errorf("unexpected config after patch:\n%s", data)
+	}
+
+	w = patch(`{"ops": [{"path": "auth.jwt.access_ttl", "value": "1h"}, {"path": "auth.nope", "value": 1}, {"path": "server.port", "value": "80"}]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	var resp struct {
+		Details []config.PatchOpError `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Details) != 2 || resp.Details[0].Path != "auth.nope" || resp.Details[1].Path != "server.port" {
+		t.Errorf("unexpected details %+v", resp.Details)
+	}
+	if after, _ := os.ReadFile(configPath); string(after) != string(data) {
+		t.Errorf("expected a rejected patch to leave the file alone, got:\n%s", after)
+	}
+	if len(recorder.events) != 1 {
+		t.Errorf("expected no event for a rejected patch, got %+v", recorder.events[1:])
+	}
+}
+
+type publishedEvent struct {
+	channel string
+	event   events.Type
+	data    any
+}
+
+// eventRecorder is an events.Publisher that keeps what it is sent.
+type eventRecorder struct {
+	events []publishedEvent
+}
+
+func (r *eventRecorder) Publish(channel string, event events.Type, data any) {
+	r.events = append(r.events, publishedEvent{channel, event, data})
+}
@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/watzon/alyx/internal/metrics"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// MaxJSONDepth is how deeply DecodeJSON lets arrays and objects nest by
+// default.
+const MaxJSONDepth = 32
+
+// DecodeOptions controls DecodeJSON.
+type DecodeOptions struct {
+	// Strict rejects object keys that match no field of the destination
+	// struct, at any depth. Maps and interface values accept any key.
+	Strict bool
+	// AllowEmpty accepts an empty body, leaving dst unchanged.
+	AllowEmpty bool
+	// MaxDepth overrides MaxJSONDepth.
+	MaxDepth int
+}
+
+// Reasons DecodeJSON rejects a body, reported as JSONError.Reason and as the
+// reason label of the alyx_http_json_decode_errors_total metric.
+const (
+	JSONErrorSyntax        = "syntax"
+	JSONErrorType          = "type"
+	JSONErrorDepth         = "depth"
+	JSONErrorTrailingData  = "trailing_data"
+	JSONErrorUnknownFields = "unknown_fields"
+	JSONErrorEmpty         = "empty"
+	JSONErrorTooLarge      = "too_large"
+)
+
+// JSONError describes why a request body was rejected.
+type JSONError struct {
+	Reason  string
+	Message string
+	// UnknownFields lists the rejected keys for JSONErrorUnknownFields.
+	UnknownFields []UnknownField
+	// Limit is the body size limit for JSONErrorTooLarge.
+	Limit int64
+}
+
+func (e *JSONError) Error() string {
+	return e.Message
+}
+
+// UnknownField is a key that matches no field. Field is a path such as
+// ops[1].value for nested keys, and Suggestion the closest valid name, if
+// one is close enough to be a likely typo.
+type UnknownField struct {
+	Field      string `json:"field"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// DecodeJSON decodes the request body into dst. The body must hold exactly
+// one JSON value nested no deeper than the depth limit; see DecodeOptions
+// for the rest. Failures are *JSONError values, which invalidJSON writes as
+// the response.
+func DecodeJSON(r *http.Request, dst any, opts DecodeOptions) error {
+	if err := decodeJSON(r.Body, dst, opts); err != nil {
+		metrics.RecordJSONDecodeError(err.Reason)
+		return err
+	}
+	return nil
+}
+
+func decodeJSON(body io.Reader, dst any, opts DecodeOptions) *JSONError {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return &JSONError{
+				Reason:  JSONErrorTooLarge,
+				Message: fmt.Sprintf("Request body exceeds the %d byte limit", maxErr.Limit),
+				Limit:   maxErr.Limit,
+			}
+		}
+		return &JSONError{Reason: JSONErrorSyntax, Message: "Failed to read request body"}
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if opts.AllowEmpty {
+			return nil
+		}
+		return &JSONError{Reason: JSONErrorEmpty, Message: "Request body is empty; expected a JSON value"}
+	}
+
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = MaxJSONDepth
+	}
+	if exceedsDepth(data, maxDepth) {
+		return &JSONError{
+			Reason:  JSONErrorDepth,
+			Message: fmt.Sprintf("Invalid JSON body: arrays and objects nest deeper than %d levels", maxDepth),
+		}
+	}
+
+	if opts.Strict && json.Valid(data) {
+		if unknown := unknownFields(data, reflect.TypeOf(dst), ""); len(unknown) > 0 {
+			return unknownFieldsError(unknown, "")
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return &JSONError{
+			Reason:  JSONErrorTrailingData,
+			Message: fmt.Sprintf("Invalid JSON body: unexpected data after the JSON value at offset %d", dec.InputOffset()),
+		}
+	}
+	return nil
+}
+
+func decodeError(err error) *JSONError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &JSONError{
+			Reason:  JSONErrorSyntax,
+			Message: fmt.Sprintf("Invalid JSON body: %s at offset %d", syntaxErr.Error(), syntaxErr.Offset),
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &JSONError{Reason: JSONErrorSyntax, Message: "Invalid JSON body: unexpected end of input"}
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return &JSONError{
+				Reason:  JSONErrorType,
+				Message: fmt.Sprintf("Invalid JSON body: expected %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value),
+			}
+		}
+		return &JSONError{
+			Reason:  JSONErrorType,
+			Message: fmt.Sprintf("Invalid JSON body: field '%s' must be %s, got %s", typeErr.Field, jsonTypeName(typeErr.Type), typeErr.Value),
+		}
+	default:
+		return &JSONError{Reason: JSONErrorType, Message: "Invalid JSON body: " + err.Error()}
+	}
+}
+
+// jsonTypeName describes the JSON a Go type decodes from.
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return t.String()
+}
+
+// exceedsDepth reports whether arrays and objects in data nest deeper than
+// limit. It only tracks brackets outside strings, so it is cheap enough to run
+// before decoding.
+func exceedsDepth(data []byte, limit int) bool {
+	depth := 0
+	inString, escaped := false, false
+	for _, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+			if depth > limit {
+				return true
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return false
+}
+
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// unknownFields returns the keys in data that encoding/json would ignore
+// when decoding into t, with path as the location of data.
+func unknownFields(data []byte, t reflect.Type, path string) []UnknownField {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return nil
+	}
+
+	var unknown []UnknownField
+	switch t.Kind() {
+	case reflect.Struct:
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(data, &obj) != nil {
+			return nil
+		}
+		fields := jsonFields(t)
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			field, ok := lookupJSONField(fields, key)
+			if !ok {
+				unknown = append(unknown, UnknownField{Field: joinPath(path, key), Suggestion: nearestName(key, names)})
+				continue
+			}
+			unknown = append(unknown, unknownFields(obj[key], field, joinPath(path, key))...)
+		}
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		if json.Unmarshal(data, &items) != nil {
+			return nil
+		}
+		for i, item := range items {
+			unknown = append(unknown, unknownFields(item, t.Elem(), path+"["+strconv.Itoa(i)+"]")...)
+		}
+	case reflect.Map:
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(data, &obj) != nil {
+			return nil
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			unknown = append(unknown, unknownFields(obj[key], t.Elem(), joinPath(path, key))...)
+		}
+	}
+	return unknown
+}
+
+// jsonFields maps the JSON names of a struct's fields, including promoted
+// ones, to their types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for embedded, et := range jsonFields(ft) {
+					if _, ok := fields[embedded]; !ok {
+						fields[embedded] = et
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// lookupJSONField matches key as encoding/json does: exactly, or else
+// ignoring case.
+func lookupJSONField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if t, ok := fields[key]; ok {
+		return t, true
+	}
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// unknownFieldsError reports unknown keys. collection names the collection
+// for document bodies.
+func unknownFieldsError(unknown []UnknownField, collection string) *JSONError {
+	quoted := make([]string, len(unknown))
+	for i, u := range unknown {
+		quoted[i] = "'" + u.Field + "'"
+	}
+
+	message := "Unknown field " + quoted[0]
+	if len(unknown) > 1 {
+		message = "Unknown fields " + strings.Join(quoted, ", ")
+	}
+	if collection != "" {
+		message += " in collection '" + collection + "'"
+	}
+	if len(unknown) == 1 && unknown[0].Suggestion != "" {
+		message += "; did you mean '" + unknown[0].Suggestion + "'?"
+	}
+	return &JSONError{Reason: JSONErrorUnknownFields, Message: message, UnknownFields: unknown}
+}
+
+// checkDocumentFields rejects the top-level keys of a document body that
+// are not fields of col, which the database would otherwise drop silently.
+// Extra names the other keys the endpoint accepts.
+func checkDocumentFields(col *schema.Collection, data map[string]any, extra ...string) error {
+	var unknown []UnknownField
+	for key := range data {
+		if _, ok := col.Fields[key]; ok || slices.Contains(extra, key) {
+			continue
+		}
+		unknown = append(unknown, UnknownField{Field: key})
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(col.Fields))
+	for name := range col.Fields {
+		names = append(names, name)
+	}
+	slices.SortFunc(unknown, func(a, b UnknownField) int { return strings.Compare(a.Field, b.Field) })
+	for i := range unknown {
+		unknown[i].Suggestion = nearestName(unknown[i].Field, names)
+	}
+
+	metrics.RecordJSONDecodeError(JSONErrorUnknownFields)
+	return unknownFieldsError(unknown, col.Name)
+}
+
+// nearestName returns the candidate closest to name, if it is close enough
+// to be a likely typo.
+func nearestName(name string, candidates []string) string {
+	best, bestDistance := "", 0
+	for _, candidate := range candidates {
+		d := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if best == "" || d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best == "" || bestDistance > max(2, len(name)/3) || bestDistance >= len(name) {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b, counting an
+// adjacent transposition as one edit.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
+}
+
+// invalidJSON writes the response for an error from DecodeJSON or
+// checkDocumentFields.
+func invalidJSON(w http.ResponseWriter, r *http.Request, err error) {
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) {
+		ErrorWithRequest(w, r, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+	switch jsonErr.Reason {
+	case JSONErrorTooLarge:
+		BodyTooLarge(w, r, jsonErr.Limit, "")
+	case JSONErrorUnknownFields:
+		ErrorWithRequestAndDetails(w, r, http.StatusBadRequest, "UNKNOWN_FIELDS", jsonErr.Message, map[string]any{
+			"fields": jsonErr.UnknownFields,
+		})
+	default:
+		ErrorWithRequest(w, r, http.StatusBadRequest, "INVALID_JSON", jsonErr.Message)
+	}
+}
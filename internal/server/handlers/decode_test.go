@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type decodeTarget struct {
+	Name string `json:"name"`
+	Ops  []struct {
+		Op    string `json:"op"`
+		Value any    `json:"value"`
+	} `json:"ops"`
+	Meta map[string]any `json:"meta"`
+}
+
+func decodeBody(body string, opts DecodeOptions) error {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	var dst decodeTarget
+	return DecodeJSON(req, &dst, opts)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		opts   DecodeOptions
+		reason string
+		msg    string
+	}{
+		{name: "valid", body: `{"name": "a", "ops": [{"op": "set", "value": 1}]}`},
+		{name: "syntax", body: `{"name": }`, reason: JSONErrorSyntax, msg: "at offset"},
+		{name: "truncated", body: `{"name": "a"`, reason: JSONErrorSyntax, msg: "unexpected end of input"},
+		{name: "empty", body: "  ", reason: JSONErrorEmpty},
+		{name: "empty allowed", body: "", opts: DecodeOptions{AllowEmpty: true}},
+		{name: "trailing data", body: `{"name": "a"} {"name": "b"}`, reason: JSONErrorTrailingData},
+		{name: "trailing whitespace", body: "{\"name\": \"a\"}\n"},
+		{name: "type", body: `{"name": 5}`, reason: JSONErrorType, msg: "field 'name' must be a string, got number"},
+		{name: "top-level type", body: `[]`, reason: JSONErrorType, msg: "expected an object, got array"},
+		{name: "depth", body: `{"meta": ` + strings.Repeat("[", 40) + strings.Repeat("]", 40) + `}`, reason: JSONErrorDepth},
+		{name: "custom depth", body: `{"meta": {"a": {"b": 1}}}`, opts: DecodeOptions{MaxDepth: 2}, reason: JSONErrorDepth},
+		{name: "unknown field ignored", body: `{"nmae": "a"}`},
+		{name: "unknown field", body: `{"nmae": "a"}`, opts: DecodeOptions{Strict: true}, reason: JSONErrorUnknownFields, msg: "did you mean 'name'?"},
+		{name: "map keys accepted", body: `{"meta": {"anything": {"goes": 1}}}`, opts: DecodeOptions{Strict: true}},
+		{name: "case-insensitive match", body: `{"Name": "a"}`, opts: DecodeOptions{Strict: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decodeBody(tt.body, tt.opts)
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var jsonErr *JSONError
+			if !errors.As(err, &jsonErr) {
+				t.Fatalf("expected *JSONError, got %v", err)
+			}
+			if jsonErr.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q (%s)", jsonErr.Reason, tt.reason, jsonErr.Message)
+			}
+			if !strings.Contains(jsonErr.Message, tt.msg) {
+				t.Errorf("message %q does not contain %q", jsonErr.Message, tt.msg)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONUnknownNestedFields(t *testing.T) {
+	err := decodeBody(`{"name": "a", "ops": [{"op": "set"}, {"op": "set", "valeu": 1}], "extra": true}`, DecodeOptions{Strict: true})
+
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) || jsonErr.Reason != JSONErrorUnknownFields {
+		t.Fatalf("expected unknown fields error, got %v", err)
+	}
+	want := []UnknownField{{Field: "extra"}, {Field: "ops[1].valeu", Suggestion: "value"}}
+	if len(jsonErr.UnknownFields) != len(want) {
+		t.Fatalf("unknown fields = %+v, want %+v", jsonErr.UnknownFields, want)
+	}
+	for i := range want {
+		if jsonErr.UnknownFields[i] != want[i] {
+			t.Errorf("unknown field %d = %+v, want %+v", i, jsonErr.UnknownFields[i], want[i])
+		}
+	}
+}
+
+func TestDecodeJSONTooLarge(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "`+strings.Repeat("a", 100)+`"}`))
+	req.Body = http.MaxBytesReader(w, req.Body, 16)
+
+	var dst decodeTarget
+	err := DecodeJSON(req, &dst, DecodeOptions{})
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) || jsonErr.Reason != JSONErrorTooLarge || jsonErr.Limit != 16 {
+		t.Fatalf("expected too large error with limit 16, got %+v", err)
+	}
+
+	invalidJSON(w, req, err)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestInvalidJSONResponse(t *testing.T) {
+	err := decodeBody(`{"nmae": "a"}`, DecodeOptions{Strict: true})
+	w := httptest.NewRecorder()
+	invalidJSON(w, httptest.NewRequest(http.MethodPost, "/", nil), err)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	var resp struct {
+		Code    string `json:"code"`
+		Details struct {
+			Fields []UnknownField `json:"fields"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Code != "UNKNOWN_FIELDS" || len(resp.Details.Fields) != 1 || resp.Details.Fields[0].Suggestion != "name" {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+}
+
+func TestCreateDocumentUnknownField(t *testing.T) {
+	h := setupHistoryHandlers(t)
+
+	w := httptest.NewRecorder()
+	h.CreateDocument(w, historyRequest(http.MethodPost, "invoices", "", "user", map[string]any{"id": "inv1", "amuont": 100}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "UNKNOWN_FIELDS") || !strings.Contains(body, "did you mean 'amount'?") {
+		t.Errorf("expected unknown field error suggesting 'amount', got %s", body)
+	}
+}
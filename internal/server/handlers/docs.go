@@ -2,7 +2,12 @@ package handlers
 
 import (
 	"fmt"
+	"html"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/openapi"
@@ -10,9 +15,18 @@ import (
 )
 
 type DocsHandler struct {
-	schema    *schema.Schema
-	cfg       *config.Config
-	specCache []byte
+	schema *schema.Schema
+	cfg    *config.Config
+
+	mu   sync.Mutex
+	json *specDocument
+	yaml *specDocument
+}
+
+// specDocument is the spec serialized in one format, with its ETag.
+type specDocument struct {
+	data []byte
+	etag string
 }
 
 func NewDocsHandler(s *schema.Schema, cfg *config.Config) *DocsHandler {
@@ -23,56 +37,136 @@ func NewDocsHandler(s *schema.Schema, cfg *config.Config) *DocsHandler {
 }
 
 func (h *DocsHandler) OpenAPISpec(w http.ResponseWriter, r *http.Request) {
-	if h.specCache == nil {
-		serverURL := fmt.Sprintf("http://%s", h.cfg.Server.Address())
-		if r.TLS != nil {
-			serverURL = fmt.Sprintf("https://%s", r.Host)
-		} else if fwdProto := r.Header.Get("X-Forwarded-Proto"); fwdProto != "" {
-			serverURL = fmt.Sprintf("%s://%s", fwdProto, r.Host)
-		}
+	h.serveSpec(w, r, "application/json")
+}
 
-		spec := openapi.Generate(h.schema, openapi.GeneratorConfig{
-			Title:       h.cfg.Docs.Title,
-			Description: h.cfg.Docs.Description,
-			Version:     h.cfg.Docs.Version,
-			ServerURL:   serverURL,
-		})
-
-		data, err := spec.JSON()
-		if err != nil {
-			Error(w, http.StatusInternalServerError, "SPEC_ERROR", "Failed to generate OpenAPI spec")
-			return
-		}
-		h.specCache = data
+// OpenAPISpecYAML serves the spec as YAML.
+func (h *DocsHandler) OpenAPISpecYAML(w http.ResponseWriter, r *http.Request) {
+	h.serveSpec(w, r, "application/yaml")
+}
+
+func (h *DocsHandler) serveSpec(w http.ResponseWriter, r *http.Request, contentType string) {
+	doc, err := h.document(r, contentType)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "SPEC_ERROR", "Failed to generate OpenAPI spec")
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	// The spec is generated from the schema and docs config alone, so its
+	// hash changes exactly when either does.
+	w.Header().Set("ETag", doc.etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if checkNotModified(w, r, doc.etag, time.Time{}) {
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(h.specCache)
+	_, _ = w.Write(doc.data)
+}
+
+// document returns the spec in the format for contentType, generating and
+// caching it on first use.
+func (h *DocsHandler) document(r *http.Request, contentType string) (*specDocument, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	asYAML := contentType == "application/yaml"
+	cached := &h.json
+	if asYAML {
+		cached = &h.yaml
+	}
+	if *cached != nil {
+		return *cached, nil
+	}
+
+	serverURL := fmt.Sprintf("http://%s", h.cfg.Server.Address())
+	if r.TLS != nil {
+		serverURL = fmt.Sprintf("https://%s", r.Host)
+	} else if fwdProto := r.Header.Get("X-Forwarded-Proto"); fwdProto != "" {
+		serverURL = fmt.Sprintf("%s://%s", fwdProto, r.Host)
+	}
+
+	spec := openapi.Generate(h.schema, openapi.GeneratorConfig{
+		Title:       h.cfg.Docs.Title,
+		Description: h.cfg.Docs.Description,
+		Version:     h.cfg.Docs.Version,
+		ServerURL:   serverURL,
+		ErrorFormat: h.cfg.Server.ErrorFormat,
+	})
+
+	var data []byte
+	var err error
+	if asYAML {
+		data, err = spec.YAML()
+	} else {
+		data, err = spec.JSON()
+	}
+	if err != nil {
+		return nil, err
+	}
+	*cached = &specDocument{data: data, etag: weakETag(string(data))}
+	return *cached, nil
 }
 
+// RedirectToDefaultUI redirects /api/docs and /api/docs/ to the default UI.
+// The Location is relative so the redirect also works behind a proxy that
+// serves the API under a subpath.
+func (h *DocsHandler) RedirectToDefaultUI(w http.ResponseWriter, r *http.Request) {
+	target := h.cfg.Docs.DefaultUI()
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		target = "docs/" + target
+	}
+	w.Header().Set("Location", target)
+	w.WriteHeader(http.StatusFound)
+}
+
+// DocsUI serves the documentation UI named by the ui path value, if it is
+// enabled. UIs load the spec with a relative URL, from /api/docs/openapi.json.
 func (h *DocsHandler) DocsUI(w http.ResponseWriter, r *http.Request) {
-	var html string
+	ui := r.PathValue("ui")
+	uis := h.cfg.Docs.EnabledUIs()
+	if !slices.Contains(uis, ui) {
+		NotFoundWithRequest(w, r, fmt.Sprintf("Documentation UI %q is not enabled", ui))
+		return
+	}
 
-	switch h.cfg.Docs.UI {
+	title := html.EscapeString(h.cfg.Docs.Title)
+	nav := docsNav(ui, uis)
+
+	var page string
+	switch ui {
 	case "swagger":
-		html = swaggerUIHTML(h.cfg.Docs.Title)
+		page = swaggerUIHTML(title, nav)
 	case "redoc":
-		html = redocHTML(h.cfg.Docs.Title)
+		page = redocHTML(title, nav)
 	case "stoplight":
-		html = stoplightHTML(h.cfg.Docs.Title)
-	case "scalar":
-		fallthrough
+		page = stoplightHTML(title, nav)
 	default:
-		html = scalarHTML(h.cfg.Docs.Title)
+		page = scalarHTML(title, nav)
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte(html))
+	_, _ = w.Write([]byte(page))
+}
+
+// docsNav is a bar linking to the other enabled UIs and the spec downloads.
+func docsNav(current string, uis []string) string {
+	var b strings.Builder
+	b.WriteString(`<nav style="display:flex;gap:1rem;justify-content:flex-end;padding:0.5rem 1rem;font:14px sans-serif;border-bottom:1px solid #ddd">`)
+	for _, ui := range uis {
+		if ui == current {
+			fmt.Fprintf(&b, `<strong>%s</strong>`, ui)
+		} else {
+			fmt.Fprintf(&b, `<a href="%s">%s</a>`, ui, ui)
+		}
+	}
+	b.WriteString(`<a href="openapi.json" download>openapi.json</a><a href="openapi.yaml" download>openapi.yaml</a></nav>`)
+	return b.String()
 }
 
-func scalarHTML(title string) string {
+func scalarHTML(title, nav string) string {
 	return fmt.Sprintf(`<!DOCTYPE html>
 <html>
 <head>
@@ -81,13 +175,14 @@ func scalarHTML(title string) string {
   <meta name="viewport" content="width=device-width, initial-scale=1" />
 </head>
 <body>
-  <script id="api-reference" data-url="/api/openapi.json"></script>
+  %s
+  <script id="api-reference" data-url="openapi.json"></script>
   <script src="https://cdn.jsdelivr.net/npm/@scalar/api-reference"></script>
 </body>
-</html>`, title)
+</html>`, title, nav)
 }
 
-func swaggerUIHTML(title string) string {
+func swaggerUIHTML(title, nav string) string {
 	return fmt.Sprintf(`<!DOCTYPE html>
 <html>
 <head>
@@ -97,13 +192,14 @@ func swaggerUIHTML(title string) string {
   <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css" />
 </head>
 <body>
+  %s
   <div id="swagger-ui"></div>
   <script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
   <script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-standalone-preset.js"></script>
   <script>
     window.onload = () => {
       SwaggerUIBundle({
-        url: '/api/openapi.json',
+        url: 'openapi.json',
         dom_id: '#swagger-ui',
         presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
         layout: 'StandaloneLayout'
@@ -111,10 +207,10 @@ func swaggerUIHTML(title string) string {
     };
   </script>
 </body>
-</html>`, title)
+</html>`, title, nav)
 }
 
-func redocHTML(title string) string {
+func redocHTML(title, nav string) string {
 	return fmt.Sprintf(`<!DOCTYPE html>
 <html>
 <head>
@@ -125,13 +221,14 @@ func redocHTML(title string) string {
   <style>body { margin: 0; padding: 0; }</style>
 </head>
 <body>
-  <redoc spec-url='/api/openapi.json'></redoc>
+  %s
+  <redoc spec-url='openapi.json'></redoc>
   <script src="https://cdn.jsdelivr.net/npm/redoc@latest/bundles/redoc.standalone.js"></script>
 </body>
-</html>`, title)
+</html>`, title, nav)
 }
 
-func stoplightHTML(title string) string {
+func stoplightHTML(title, nav string) string {
 	return fmt.Sprintf(`<!DOCTYPE html>
 <html>
 <head>
@@ -140,13 +237,14 @@ func stoplightHTML(title string) string {
   <meta name="viewport" content="width=device-width, initial-scale=1" />
 </head>
 <body>
+  %s
   <elements-api
-    apiDescriptionUrl="/api/openapi.json"
+    apiDescriptionUrl="openapi.json"
     router="hash"
     layout="sidebar"
   />
   <script src="https://unpkg.com/@stoplight/elements/web-components.min.js"></script>
   <link rel="stylesheet" href="https://unpkg.com/@stoplight/elements/styles.min.css" />
 </body>
-</html>`, title)
+</html>`, title, nav)
 }
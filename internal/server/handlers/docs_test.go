@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+func docsMux(docs *DocsHandler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/docs", docs.RedirectToDefaultUI)
+	mux.HandleFunc("GET /api/docs/{$}", docs.RedirectToDefaultUI)
+	mux.HandleFunc("GET /api/docs/openapi.json", docs.OpenAPISpec)
+	mux.HandleFunc("GET /api/docs/openapi.yaml", docs.OpenAPISpecYAML)
+	mux.HandleFunc("GET /api/docs/{ui}", docs.DocsUI)
+	return mux
+}
+
+func TestDocsUIs(t *testing.T) {
+	h, _ := setupConditionalHandlers(t)
+	cfg := config.Default()
+	cfg.Docs.UIs = []string{"swagger", "redoc"}
+	mux := docsMux(NewDocsHandler(h.schema, cfg))
+
+	for path, want := range map[string]string{"/api/docs": "docs/swagger", "/api/docs/": "swagger"} {
+		w := conditionalGet(t, mux, path, "", nil)
+		if w.Code != http.StatusFound || w.Header().Get("Location") != want {
+			t.Errorf("GET %s: expected redirect to %s, got %d %q", path, want, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	w := conditionalGet(t, mux, "/api/docs/redoc", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for an enabled UI, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "spec-url='openapi.json'") || strings.Contains(body, "/api/openapi.json") {
+		t.Error("expected the UI to load the spec with a relative URL")
+	}
+	if !strings.Contains(body, `href="swagger"`) || !strings.Contains(body, `href="openapi.yaml"`) {
+		t.Error("expected links to the other UI and the spec downloads")
+	}
+
+	if w := conditionalGet(t, mux, "/api/docs/scalar", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a UI that is not enabled, got %d", w.Code)
+	}
+}
+
+func TestDocsSpecDownloads(t *testing.T) {
+	h, _ := setupConditionalHandlers(t)
+	mux := docsMux(NewDocsHandler(h.schema, config.Default()))
+
+	jsonSpec := conditionalGet(t, mux, "/api/docs/openapi.json", "", nil)
+	yamlSpec := conditionalGet(t, mux, "/api/docs/openapi.yaml", "", nil)
+	if got := jsonSpec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected application/json, got %q", got)
+	}
+	if got := yamlSpec.Header().Get("Content-Type"); got != "application/yaml" {
+		t.Errorf("expected application/yaml, got %q", got)
+	}
+	if !strings.HasPrefix(yamlSpec.Body.String(), "openapi: ") {
+		t.Errorf("expected block YAML starting with the openapi version, got %.40q", yamlSpec.Body.String())
+	}
+
+	etag := yamlSpec.Header().Get("ETag")
+	if etag == "" || etag == jsonSpec.Header().Get("ETag") {
+		t.Fatalf("expected a distinct ETag per format, got %q", etag)
+	}
+	if w := conditionalGet(t, mux, "/api/docs/openapi.yaml", etag, nil); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", w.Code)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const explainSchemaYAML = `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      status:
+        type: string
+        index: true
+      title:
+        type: string
+`
+
+func TestExplainQuery(t *testing.T) {
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(explainSchemaYAML))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+
+	authService := auth.NewService(db, &config.AuthConfig{
+		JWT:      config.JWTConfig{Secret: "testsecret12345678901234567890123456", Issuer: "test", AccessTTL: time.Minute, RefreshTTL: time.Hour},
+		Password: config.PasswordConfig{MinLength: 8},
+	})
+	_, tokens, err := authService.Register(context.Background(), auth.RegisterInput{Email: "admin@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	h := NewAdminHandlers(nil, authService, db, s, nil, config.Default(), "", "")
+
+	explain := func(collection string, body any) (*httptest.ResponseRecorder, database.QueryPlan) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/collections/"+collection+"/explain", bytes.NewReader(data))
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		req.SetPathValue("name", collection)
+		w := httptest.NewRecorder()
+		h.ExplainQuery(w, req)
+		var plan database.QueryPlan
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &plan); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return w, plan
+	}
+
+	w, plan := explain("posts", map[string]any{"filter": []string{"status:eq:published"}, "sort": "status", "limit": 5})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !plan.UsesIndex || len(plan.Args) != 1 || len(plan.Steps) == 0 {
+		t.Errorf("expected an indexed filter to use an index, got %s", w.Body.String())
+	}
+
+	w, plan = explain("posts", map[string]any{"filter": []string{"title:eq:hello"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if plan.UsesIndex || plan.Note == "" {
+		t.Errorf("expected an unindexed filter to scan, got %s", w.Body.String())
+	}
+
+	if w, _ := explain("posts", map[string]any{"filter": []string{"nope"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid filter, got %d", w.Code)
+	}
+	if w, _ := explain("missing", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown collection, got %d", w.Code)
+	}
+}
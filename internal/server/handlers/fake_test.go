@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/fake"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const fakeSchemaYAML = `
+version: 1
+collections:
+  authors:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      name:
+        type: string
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      author_id:
+        type: uuid
+        references: authors.id
+      status:
+        type: select
+        select:
+          values: [draft, published]
+          maxSelect: 1
+`
+
+func TestFakeDocuments(t *testing.T) {
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(fakeSchemaYAML))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+
+	authService := auth.NewService(db, &config.AuthConfig{
+		JWT:      config.JWTConfig{Secret: "testsecret12345678901234567890123456", Issuer: "test", AccessTTL: time.Minute, RefreshTTL: time.Hour},
+		Password: config.PasswordConfig{MinLength: 8},
+	})
+	_, tokens, err := authService.Register(context.Background(), auth.RegisterInput{Email: "admin@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	cfg := config.Default()
+	h := NewAdminHandlers(nil, authService, db, s, nil, cfg, "", "")
+
+	generate := func(collection, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/collections/"+collection+"/fake", bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		req.SetPathValue("name", collection)
+		w := httptest.NewRecorder()
+		h.FakeDocuments(w, req)
+		return w
+	}
+
+	if w := generate("posts", `{"count": 5}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 outside dev mode, got %d: %s", w.Code, w.Body.String())
+	}
+
+	cfg.Dev.Enabled = true
+	w := generate("posts", `{"count": 5, "seed": 9, "parents": 3, "window": "7d"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var report fake.Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.Seed != 9 || report.Total != 8 || len(report.Collections) != 2 || report.Collections[0].Collection != "authors" {
+		t.Errorf("unexpected report: %s", w.Body.String())
+	}
+
+	var orphans int
+	if err := db.QueryRow("SELECT COUNT(*) FROM posts WHERE author_id NOT IN (SELECT id FROM authors)").Scan(&orphans); err != nil || orphans != 0 {
+		t.Errorf("expected every post to reference an author, got %d orphans (%v)", orphans, err)
+	}
+
+	for body, want := range map[string]int{
+		`{"count": 0}`:                     http.StatusBadRequest,
+		`{"count": 1, "window": "soon"}`:   http.StatusBadRequest,
+		`{"count": 1, "until": "tuesday"}`: http.StatusBadRequest,
+	} {
+		if w := generate("posts", body); w.Code != want {
+			t.Errorf("%s: expected %d, got %d: %s", body, want, w.Code, w.Body.String())
+		}
+	}
+	if w := generate("missing", `{"count": 1}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown collection, got %d", w.Code)
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const fieldRulesSchemaYAML = `
+version: 1
+collections:
+  profiles:
+    history:
+      snapshot: true
+    fields:
+      id:
+        type: string
+        primary: true
+      owner:
+        type: string
+      name:
+        type: string
+      email:
+        type: string
+        readRule: "auth.id == doc.owner || auth.role == 'admin'"
+    rules:
+      read: "true"
+      create: "true"
+      update: "true"
+`
+
+func setupFieldRulesHandlers(t *testing.T) *Handlers {
+	t.Helper()
+
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(fieldRulesSchemaYAML))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.ExecContext(context.Background(),
+		"INSERT INTO profiles (id, owner, name, email) VALUES ('p1', 'alice', 'Alice', 'alice@example.com')"); err != nil {
+		t.Fatal(err)
+	}
+	return New(db, s, config.Default(), engine)
+}
+
+func fieldRulesRequest(id, userID string) *http.Request {
+	target := "/api/collections/profiles"
+	if id != "" {
+		target += "/" + id
+	}
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.SetPathValue("collection", "profiles")
+	if id != "" {
+		req.SetPathValue("id", id)
+	}
+	if userID == "" {
+		return req
+	}
+	user := &auth.User{ID: userID, Email: userID + "@example.com", Role: "user"}
+	return req.WithContext(auth.ContextWithUser(req.Context(), user))
+}
+
+func TestFieldReadRules(t *testing.T) {
+	h := setupFieldRulesHandlers(t)
+
+	tests := []struct {
+		name      string
+		userID    string
+		wantEmail bool
+	}{
+		{"owner", "alice", true},
+		{"other user", "bob", false},
+		{"anonymous", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.GetDocument(w, fieldRulesRequest("p1", tt.userID))
+			if w.Code != http.StatusOK {
+				t.Fatalf("get: expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var doc map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := doc["email"]; ok != tt.wantEmail {
+				t.Errorf("get: email present = %v, want %v: %v", ok, tt.wantEmail, doc)
+			}
+			if doc["name"] != "Alice" {
+				t.Errorf("get: expected the other fields, got %v", doc)
+			}
+
+			w = httptest.NewRecorder()
+			h.ListDocuments(w, fieldRulesRequest("", tt.userID))
+			if w.Code != http.StatusOK {
+				t.Fatalf("list: expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Docs []map[string]any `json:"docs"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Docs) != 1 {
+				t.Fatalf("list: expected 1 document, got %d", len(resp.Docs))
+			}
+			if _, ok := resp.Docs[0]["email"]; ok != tt.wantEmail {
+				t.Errorf("list: email present = %v, want %v: %v", ok, tt.wantEmail, resp.Docs[0])
+			}
+		})
+	}
+}
+
+func TestFieldReadRulesHistory(t *testing.T) {
+	h := setupFieldRulesHandlers(t)
+
+	body := bytes.NewBufferString(`{"email": "alice@example.org"}`)
+	req := httptest.NewRequest(http.MethodPatch, "/api/collections/profiles/p1", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("collection", "profiles")
+	req.SetPathValue("id", "p1")
+	user := &auth.User{ID: "alice", Email: "alice@example.com", Role: "user"}
+	w := httptest.NewRecorder()
+	h.UpdateDocument(w, req.WithContext(auth.ContextWithUser(req.Context(), user)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name      string
+		userID    string
+		wantEmail bool
+	}{
+		{"owner", "alice", true},
+		{"other user", "bob", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := fieldRulesRequest("p1", tt.userID)
+			w := httptest.NewRecorder()
+			h.GetHistory(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("history: expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Docs []struct {
+					Changes  map[string]any `json:"changes"`
+					Snapshot map[string]any `json:"snapshot"`
+				} `json:"docs"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Docs) != 1 || resp.Docs[0].Snapshot["name"] != "Alice" {
+				t.Fatalf("history: expected one entry with a snapshot, got %s", w.Body.String())
+			}
+			entry := resp.Docs[0]
+			if _, ok := entry.Changes["email"]; ok != tt.wantEmail {
+				t.Errorf("history: email in changes = %v, want %v: %v", ok, tt.wantEmail, entry.Changes)
+			}
+			if _, ok := entry.Snapshot["email"]; ok != tt.wantEmail {
+				t.Errorf("history: email in snapshot = %v, want %v: %v", ok, tt.wantEmail, entry.Snapshot)
+			}
+		})
+	}
+}
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"encoding/json"
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +14,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/rules"
 	"github.com/watzon/alyx/internal/storage"
 )
 
@@ -31,6 +32,9 @@ func NewFileHandlers(service *storage.Service, tusService *storage.TUSService, s
 	}
 }
 
+// Upload handles POST /api/files/{bucket}. The multipart body is streamed:
+// the "file" part is passed to the storage backend as it is read rather than
+// buffered in memory or spooled to disk.
 func (h *FileHandlers) Upload(w http.ResponseWriter, r *http.Request) {
 	bucket := r.PathValue("bucket")
 	if bucket == "" {
@@ -38,31 +42,63 @@ func (h *FileHandlers) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
+	reader, err := r.MultipartReader()
+	if err != nil {
 		Error(w, http.StatusBadRequest, "INVALID_FORM", "Invalid multipart form")
 		return
 	}
 
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		Error(w, http.StatusBadRequest, "FILE_REQUIRED", "File is required")
-		return
+	var part *multipart.Part
+	for {
+		part, err = reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			Error(w, http.StatusBadRequest, "FILE_REQUIRED", "File is required")
+			return
+		}
+		if err != nil {
+			if !bodyTooLarge(w, r, err) {
+				Error(w, http.StatusBadRequest, "INVALID_FORM", "Invalid multipart form")
+			}
+			return
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			break
+		}
 	}
-	defer file.Close()
+	defer part.Close()
 
-	// Validate file type
-	if err := ValidateFileUpload(header); err != nil {
+	// Validate file type from the first bytes without consuming them.
+	file := bufio.NewReaderSize(part, 512)
+	head, err := file.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		if !bodyTooLarge(w, r, err) {
+			Error(w, http.StatusBadRequest, "INVALID_FORM", "Invalid multipart form")
+		}
+		return
+	}
+	if err := validateFileType(part.Header.Get("Content-Type"), head); err != nil {
 		Error(w, http.StatusBadRequest, "INVALID_FILE_TYPE", err.Error())
 		return
 	}
 
-	uploaded, err := h.service.Upload(r.Context(), bucket, header.Filename, file, header.Size)
+	uploaded, err := h.service.Upload(r.Context(), bucket, part.FileName(), file, -1)
 	if err != nil {
+		if errors.Is(err, rules.ErrAccessDenied) {
+			Forbidden(w, "Access denied by the bucket's upload rule")
+			return
+		}
 		if errors.Is(err, storage.ErrNotFound) {
 			Error(w, http.StatusNotFound, "BUCKET_NOT_FOUND", "Bucket not found")
 			return
 		}
-		log.Error().Err(err).Str("bucket", bucket).Str("filename", header.Filename).Msg("Failed to upload file")
+		if errors.Is(err, storage.ErrFileTooLarge) {
+			Error(w, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", err.Error())
+			return
+		}
+		if bodyTooLarge(w, r, err) {
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Str("filename", part.FileName()).Msg("Failed to upload file")
 		Error(w, http.StatusInternalServerError, "UPLOAD_ERROR", "Failed to upload file")
 		return
 	}
@@ -70,6 +106,17 @@ func (h *FileHandlers) Upload(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusCreated, uploaded)
 }
 
+// bodyTooLarge writes a 413 response and reports true if err came from
+// reading past the request body limit.
+func bodyTooLarge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		return false
+	}
+	BodyTooLarge(w, r, maxErr.Limit, "")
+	return true
+}
+
 // AllowedMIMETypes for file uploads
 var AllowedMIMETypes = map[string]bool{
 	"image/jpeg":       true,
@@ -103,8 +150,18 @@ func ValidateFileUpload(fh *multipart.FileHeader) error {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
+	return validateFileType(contentType, buffer[:n])
+}
+
+// validateFileType checks that the content type detected from the first
+// bytes of a file matches its declared type.
+func validateFileType(contentType string, head []byte) error {
+	if !AllowedMIMETypes[contentType] {
+		return fmt.Errorf("unsupported file type: %s", contentType)
+	}
+
 	// Detect actual content type from magic bytes
-	detectedType := http.DetectContentType(buffer[:n])
+	detectedType := http.DetectContentType(head)
 
 	// Verify detected type matches declared type (allowing for charset variations in text types)
 	if detectedType != contentType {
@@ -154,6 +211,10 @@ func (h *FileHandlers) List(w http.ResponseWriter, r *http.Request) {
 
 	files, total, err := h.service.List(r.Context(), bucket, search, mimeType, offset, limit)
 	if err != nil {
+		if errors.Is(err, rules.ErrAccessDenied) {
+			Forbidden(w, "Access denied by the bucket's read rule")
+			return
+		}
 		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to list files")
 		Error(w, http.StatusInternalServerError, "LIST_ERROR", "Failed to list files")
 		return
@@ -186,6 +247,10 @@ func (h *FileHandlers) GetMetadata(w http.ResponseWriter, r *http.Request) {
 			Error(w, http.StatusNotFound, "FILE_NOT_FOUND", "File not found")
 			return
 		}
+		if errors.Is(err, rules.ErrAccessDenied) {
+			Forbidden(w, "Access denied by the bucket's read rule")
+			return
+		}
 		log.Error().Err(err).Str("bucket", bucket).Str("file_id", fileID).Msg("Failed to get file metadata")
 		Error(w, http.StatusInternalServerError, "METADATA_ERROR", "Failed to get file metadata")
 		return
@@ -228,12 +293,18 @@ func (h *FileHandlers) Sign(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = h.service.GetMetadata(r.Context(), bucket, fileID)
+	// A signed URL lets anyone holding it download the file, so only
+	// callers the download rule allows may sign one.
+	_, err = h.service.CheckDownload(r.Context(), bucket, fileID)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			Error(w, http.StatusNotFound, "FILE_NOT_FOUND", "File not found")
 			return
 		}
+		if errors.Is(err, rules.ErrAccessDenied) {
+			Forbidden(w, "Access denied by the bucket's download rule")
+			return
+		}
 		log.Error().Err(err).Str("bucket", bucket).Str("file_id", fileID).Msg("Failed to get file metadata")
 		Error(w, http.StatusInternalServerError, "METADATA_ERROR", "Failed to get file metadata")
 		return
@@ -271,6 +342,9 @@ func (h *FileHandlers) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A valid signed URL stands in for the download rule, which was
+	// checked when it was signed.
+	open := h.service.Download
 	token := r.URL.Query().Get("token")
 	if token != "" {
 		if err := h.validateToken(token, fileID, bucket, "download"); err != nil {
@@ -281,14 +355,19 @@ func (h *FileHandlers) Download(w http.ResponseWriter, r *http.Request) {
 			Error(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid or tampered token")
 			return
 		}
+		open = h.service.GetObject
 	}
 
-	rc, file, err := h.service.Download(r.Context(), bucket, fileID)
+	rc, file, err := open(r.Context(), bucket, fileID)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			Error(w, http.StatusNotFound, "FILE_NOT_FOUND", "File not found")
 			return
 		}
+		if errors.Is(err, rules.ErrAccessDenied) {
+			Forbidden(w, "Access denied by the bucket's download rule")
+			return
+		}
 		log.Error().Err(err).Str("bucket", bucket).Str("file_id", fileID).Msg("Failed to download file")
 		Error(w, http.StatusInternalServerError, "DOWNLOAD_ERROR", "Failed to download file")
 		return
@@ -317,6 +396,9 @@ func (h *FileHandlers) View(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A valid signed URL stands in for the download rule, which was
+	// checked when it was signed.
+	open := h.service.Download
 	token := r.URL.Query().Get("token")
 	if token != "" {
 		if err := h.validateToken(token, fileID, bucket, "view"); err != nil {
@@ -327,14 +409,19 @@ func (h *FileHandlers) View(w http.ResponseWriter, r *http.Request) {
 			Error(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid or tampered token")
 			return
 		}
+		open = h.service.GetObject
 	}
 
-	rc, file, err := h.service.Download(r.Context(), bucket, fileID)
+	rc, file, err := open(r.Context(), bucket, fileID)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			Error(w, http.StatusNotFound, "FILE_NOT_FOUND", "File not found")
 			return
 		}
+		if errors.Is(err, rules.ErrAccessDenied) {
+			Forbidden(w, "Access denied by the bucket's download rule")
+			return
+		}
 		log.Error().Err(err).Str("bucket", bucket).Str("file_id", fileID).Msg("Failed to view file")
 		Error(w, http.StatusInternalServerError, "VIEW_ERROR", "Failed to view file")
 		return
@@ -368,6 +455,10 @@ func (h *FileHandlers) Delete(w http.ResponseWriter, r *http.Request) {
 			Error(w, http.StatusNotFound, "FILE_NOT_FOUND", "File not found")
 			return
 		}
+		if errors.Is(err, rules.ErrAccessDenied) {
+			Forbidden(w, "Access denied by the bucket's delete rule")
+			return
+		}
 		log.Error().Err(err).Str("bucket", bucket).Str("file_id", fileID).Msg("Failed to delete file")
 		Error(w, http.StatusInternalServerError, "DELETE_ERROR", "Failed to delete file")
 		return
@@ -387,8 +478,8 @@ func (h *FileHandlers) BatchDelete(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		IDs []string `json:"ids"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid request body")
+	if err := DecodeJSON(r, &req, DecodeOptions{}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
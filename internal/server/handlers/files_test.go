@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"mime/multipart"
@@ -11,8 +12,10 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/watzon/alyx/internal/auth"
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
 	"github.com/watzon/alyx/internal/schema"
 	"github.com/watzon/alyx/internal/storage"
 )
@@ -527,3 +530,199 @@ func TestFileHandlersDeletedFileWithValidToken(t *testing.T) {
 		t.Errorf("Status = %d, want %d (file deleted should return 404, not 403)", w.Code, http.StatusNotFound)
 	}
 }
+
+// sizeTrackingReader counts the bytes read from the request body.
+type sizeTrackingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (s *sizeTrackingReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+	return n, err
+}
+
+// trackingBackend records how much of the request body had been read when
+// the upload reached the backend.
+type trackingBackend struct {
+	storage.Backend
+	body      *sizeTrackingReader
+	readAtPut int64
+}
+
+func (b *trackingBackend) Put(ctx context.Context, bucket, key string, r io.Reader, size int64) error {
+	b.readAtPut = b.body.n
+	return b.Backend.Put(ctx, bucket, key, r, size)
+}
+
+func TestFileHandlersUploadStreams(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(tmpDir, "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	backend := &trackingBackend{Backend: storage.NewFilesystemBackend(filepath.Join(tmpDir, "storage"))}
+	s := &schema.Schema{
+		Buckets: map[string]*schema.Bucket{
+			"uploads": {Name: "uploads", Backend: "local", MaxFileSize: 48 << 20},
+		},
+	}
+	service := storage.NewService(db, map[string]storage.Backend{"local": backend}, s, &config.Config{}, nil)
+	handlers := NewFileHandlers(service, nil, nil)
+
+	upload := func(size int) *httptest.ResponseRecorder {
+		pr, pw := io.Pipe()
+		writer := multipart.NewWriter(pw)
+		go func() {
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Type", "text/plain")
+			h.Set("Content-Disposition", `form-data; name="file"; filename="big.txt"`)
+			part, err := writer.CreatePart(h)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			chunk := bytes.Repeat([]byte("a"), 1<<20)
+			for written := 0; written < size; written += len(chunk) {
+				if _, err := part.Write(chunk[:min(len(chunk), size-written)]); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+			pw.CloseWithError(writer.Close())
+		}()
+
+		backend.body = &sizeTrackingReader{r: pr}
+		req := httptest.NewRequest(http.MethodPost, "/api/files/uploads", backend.body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.SetPathValue("bucket", "uploads")
+		w := httptest.NewRecorder()
+		handlers.Upload(w, req)
+		_, _ = io.Copy(io.Discard, pr)
+		return w
+	}
+
+	// Larger than the 32MB multipart memory buffer ParseMultipartForm uses.
+	const size = 40 << 20
+	w := upload(size)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+	if backend.readAtPut >= 1<<20 {
+		t.Errorf("expected upload to reach the backend before the body was read, %d bytes were buffered", backend.readAtPut)
+	}
+
+	var file storage.File
+	if err := json.NewDecoder(w.Body).Decode(&file); err != nil {
+		t.Fatalf("Decode response failed: %v", err)
+	}
+	if file.Size != size {
+		t.Errorf("Size = %d, want %d", file.Size, size)
+	}
+
+	w = upload(50 << 20)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestFileHandlersBucketRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(tmpDir, "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := &schema.Schema{
+		Buckets: map[string]*schema.Bucket{
+			"private": {
+				Name:    "private",
+				Backend: "local",
+				Rules: &schema.Rules{
+					Upload:   "has(auth.id)",
+					Download: "has(auth.id) && auth.id == file.owner_id",
+				},
+			},
+		},
+	}
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]storage.Backend{"local": storage.NewFilesystemBackend(filepath.Join(tmpDir, "storage"))}
+	service := storage.NewService(db, backends, s, &config.Config{}, engine)
+	handlers := NewFileHandlers(service, nil, storage.NewSignedURLService([]byte("test-secret-key-for-signing")))
+
+	upload := func(ctx context.Context) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Type", "text/plain")
+		h.Set("Content-Disposition", `form-data; name="file"; filename="test.txt"`)
+		part, _ := writer.CreatePart(h)
+		_, _ = part.Write([]byte("Hello, World!"))
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/files/private", body).WithContext(ctx)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.SetPathValue("bucket", "private")
+		w := httptest.NewRecorder()
+		handlers.Upload(w, req)
+		return w
+	}
+	get := func(ctx context.Context, handler http.HandlerFunc, path, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+		req.SetPathValue("bucket", "private")
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	if w := upload(context.Background()); w.Code != http.StatusForbidden {
+		t.Fatalf("anonymous upload: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	owner := auth.ContextWithUser(context.Background(), &auth.User{ID: "owner"})
+	w := upload(owner)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload: status %d: %s", w.Code, w.Body.String())
+	}
+	var file storage.File
+	if err := json.NewDecoder(w.Body).Decode(&file); err != nil {
+		t.Fatal(err)
+	}
+	if file.OwnerID != "owner" {
+		t.Errorf("owner_id = %q, want owner", file.OwnerID)
+	}
+
+	other := auth.ContextWithUser(context.Background(), &auth.User{ID: "other"})
+	path := "/api/files/private/" + file.ID
+	if w := get(other, handlers.Download, path+"/download", file.ID); w.Code != http.StatusForbidden {
+		t.Errorf("download by another user: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := get(other, handlers.Sign, path+"/sign", file.ID); w.Code != http.StatusForbidden {
+		t.Errorf("signing by another user: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	// The owner can share the file through a signed URL.
+	w = get(owner, handlers.Sign, path+"/sign", file.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("signing by the owner: status %d", w.Code)
+	}
+	var signed map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&signed); err != nil {
+		t.Fatal(err)
+	}
+	w = get(context.Background(), handlers.Download, path+"/download?token="+signed["token"].(string), file.ID)
+	if w.Code != http.StatusOK || w.Body.String() != "Hello, World!" {
+		t.Errorf("download with a signed URL: status %d, body %q", w.Code, w.Body.String())
+	}
+}
@@ -2,7 +2,8 @@ package handlers
 
 import (
 	"encoding/base64"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/database"
 	"github.com/watzon/alyx/internal/functions"
 )
 
@@ -98,12 +100,21 @@ func (h *FunctionHandlers) Invoke(w http.ResponseWriter, r *http.Request) {
 			input["_files"] = files
 		}
 	} else if r.ContentLength > 0 {
-		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-			Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body: "+err.Error())
+		if err := DecodeJSON(r, &input, DecodeOptions{}); err != nil {
+			invalidJSON(w, r, err)
 			return
 		}
 	}
 
+	if errs := h.service.ValidateInput(funcDef, input); len(errs) > 0 {
+		details := make([]database.ValidationError, len(errs))
+		for i, e := range errs {
+			details[i] = database.ValidationError{Field: e.Path, Code: "INVALID_INPUT", Message: e.Message}
+		}
+		ErrorWithDetails(w, http.StatusUnprocessableEntity, "INVALID_INPUT", "Input does not match the function's declared input", details)
+		return
+	}
+
 	// Build auth context from request
 	var authCtx *functions.AuthContext
 	if user := auth.UserFromContext(r.Context()); user != nil {
@@ -128,6 +139,11 @@ func (h *FunctionHandlers) Invoke(w http.ResponseWriter, r *http.Request) {
 
 	// Invoke function
 	resp, err := h.service.Invoke(r.Context(), functionName, input, authCtx)
+	var unavailable *functions.UnavailableError
+	if errors.As(err, &unavailable) {
+		Error(w, http.StatusServiceUnavailable, "FUNCTION_UNAVAILABLE", unavailable.Error())
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Str("function", functionName).Msg("Function invocation failed")
 		Error(w, http.StatusInternalServerError, "INVOCATION_ERROR", "Failed to invoke function: "+err.Error())
@@ -158,9 +174,10 @@ func (h *FunctionHandlers) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	JSON(w, http.StatusOK, map[string]any{
+	resp := map[string]any{
 		"name":         funcDef.Name,
 		"runtime":      funcDef.Runtime,
+		"mode":         funcDef.Mode,
 		"path":         funcDef.Path,
 		"entrypoint":   funcDef.Path,
 		"description":  funcDef.Description,
@@ -169,7 +186,14 @@ func (h *FunctionHandlers) Get(w http.ResponseWriter, r *http.Request) {
 		"timeout":      funcDef.Timeout,
 		"memory":       funcDef.Memory,
 		"env":          funcDef.Env,
-	})
+		"status":       funcDef.Status,
+		"error":        funcDef.Error,
+		"build":        funcDef.LastBuild,
+	}
+	if funcDef.NativeFallback != "" {
+		resp["native_fallback"] = funcDef.NativeFallback
+	}
+	JSON(w, http.StatusOK, resp)
 }
 
 // List handles GET /api/functions.
@@ -179,10 +203,15 @@ func (h *FunctionHandlers) List(w http.ResponseWriter, r *http.Request) {
 	// Build response
 	result := make([]map[string]any, 0, len(funcs))
 	for _, fn := range funcs {
-		result = append(result, map[string]any{
+		item := map[string]any{
 			"name":    fn.Name,
 			"runtime": fn.Runtime,
-		})
+			"status":  fn.Status,
+		}
+		if fn.Error != "" {
+			item["error"] = fn.Error
+		}
+		result = append(result, item)
 	}
 
 	JSON(w, http.StatusOK, map[string]any{
@@ -206,7 +235,8 @@ func (h *FunctionHandlers) Stats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	JSON(w, http.StatusOK, map[string]any{
-		"pools": result,
+		"pools":  result,
+		"builds": h.service.BuildStats(),
 	})
 }
 
@@ -219,10 +249,27 @@ func (h *FunctionHandlers) Reload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	funcs := h.service.ListFunctions()
+	failed := make([]map[string]any, 0)
+	for _, fn := range funcs {
+		if fn.Status == functions.FunctionStatusError {
+			failed = append(failed, map[string]any{
+				"name":  fn.Name,
+				"error": fn.Error,
+			})
+		}
+	}
+
+	message := "Functions reloaded successfully"
+	if len(failed) > 0 {
+		message = fmt.Sprintf("Functions reloaded, %d of %d failed validation", len(failed), len(funcs))
+	}
+
 	JSON(w, http.StatusOK, map[string]any{
 		"success": true,
 		"count":   len(funcs),
-		"message": "Functions reloaded successfully",
+		"ready":   len(funcs) - len(failed),
+		"failed":  failed,
+		"message": message,
 	})
 }
 
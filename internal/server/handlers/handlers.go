@@ -4,18 +4,26 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"maps"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/changefeed"
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database"
 	"github.com/watzon/alyx/internal/rules"
 	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/server/requestlog"
 	"github.com/watzon/alyx/internal/storage"
+	"github.com/watzon/alyx/internal/store"
+	"github.com/watzon/alyx/internal/views"
 )
 
 type HandlerFunc func(http.ResponseWriter, *http.Request)
@@ -24,49 +32,252 @@ var errFileWrongBucket = errors.New("file belongs to wrong bucket")
 
 type Handlers struct {
 	db             *database.DB
+	docs           store.DocumentStore
 	schema         *schema.Schema
 	cfg            *config.Config
 	rules          *rules.Engine
 	hookTrigger    database.HookTrigger
 	storageService *storage.Service
+	views          *views.Service
+	changeFeed     *changefeed.Feed
 }
 
 func New(db *database.DB, s *schema.Schema, cfg *config.Config, rulesEngine *rules.Engine) *Handlers {
 	return &Handlers{
 		db:     db,
+		docs:   store.NewSQLiteDocuments(db, nil),
 		schema: s,
 		cfg:    cfg,
 		rules:  rulesEngine,
 	}
 }
 
+// dbFor returns the database the request is routed to, which is the sandbox
+// database for sandboxed requests in dev mode.
+func (h *Handlers) dbFor(ctx context.Context) *database.DB {
+	return database.DBFromContext(ctx, h.db)
+}
+
 func (h *Handlers) SetStorageService(service *storage.Service) {
 	h.storageService = service
 }
 
 func (h *Handlers) SetHookTrigger(trigger database.HookTrigger) {
 	h.hookTrigger = trigger
+	h.docs = store.NewSQLiteDocuments(h.db, trigger)
 }
 
 func (h *Handlers) Rules() *rules.Engine {
 	return h.rules
 }
 
-func (h *Handlers) checkAccess(r *http.Request, collection string, op rules.Operation, doc map[string]any) error {
+func (h *Handlers) checkAccess(r *http.Request, collection string, op rules.Operation, tenant *tenantScope, doc map[string]any) error {
 	if h.rules == nil {
 		return nil
 	}
 
+	return h.rules.CheckAccess(collection, op, h.evalContext(r, tenant, doc))
+}
+
+// accessDenied writes the 403 for a rule denial and records it in the
+// request log as RULE_DENIED. With rule denial logging enabled, the log entry
+// also gets the values the rule read, and the response names the rule.
+func (h *Handlers) accessDenied(w http.ResponseWriter, r *http.Request, err error) {
+	var denial *rules.Denial
+	if !errors.As(err, &denial) {
+		Forbidden(w, "Access denied")
+		return
+	}
+
+	if h.cfg == nil || (!h.cfg.Dev.Enabled && !h.cfg.Logging.RuleDenials) {
+		Forbidden(w, "Access denied")
+		requestlog.RecordError(w, "RULE_DENIED", "Access denied", map[string]any{
+			"rule_denied": map[string]any{"collection": denial.Collection, "operation": denial.Operation},
+		})
+		return
+	}
+
+	ErrorWithRequestAndDetails(w, r, http.StatusForbidden, "FORBIDDEN", "Access denied", map[string]any{
+		"rule_denied": map[string]any{
+			"collection": denial.Collection,
+			"operation":  denial.Operation,
+			"rule":       denial.Rule,
+			"references": denial.References,
+		},
+	})
+	requestlog.RecordError(w, "RULE_DENIED", "Access denied", map[string]any{"rule_denied": denial})
+}
+
+func (h *Handlers) evalContext(r *http.Request, tenant *tenantScope, doc map[string]any) *rules.EvalContext {
 	user := auth.UserFromContext(r.Context())
 	claims := auth.ClaimsFromContext(r.Context())
 
-	evalCtx := &rules.EvalContext{
+	// HEAD is a GET without the body, so rules see it as one.
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	return &rules.EvalContext{
 		Auth:    rules.BuildAuthContext(user, claims),
 		Doc:     doc,
-		Request: rules.BuildRequestContext(r.Method, extractClientIP(r)),
+		Request: rules.BuildRequestContext(method, extractClientIP(r)),
+		Tenant:  tenant.ruleValue(),
+		Context: r.Context(),
+	}
+}
+
+// redactFields removes from docs the fields whose read rule hides them from
+// the caller. Collections without field rules are left alone.
+func (h *Handlers) redactFields(r *http.Request, collection string, tenant *tenantScope, docs ...database.Row) {
+	if h.rules == nil || !h.rules.HasFieldRules(collection) {
+		return
+	}
+	evalCtx := h.evalContext(r, tenant, nil)
+	for _, doc := range docs {
+		h.rules.RedactFields(collection, evalCtx, doc)
+	}
+}
+
+// findReadable lists documents the read rule allows. The rule is applied with
+// the cheapest strategy the engine can find for it: once per request, as SQL
+// filters, or, failing both, against the matching rows in batches.
+// It also returns the filters the query ran with, including those derived
+// from the rule.
+func (h *Handlers) findReadable(r *http.Request, col *schema.Collection, tenant *tenantScope, opts *database.QueryOptions) (*database.QueryResult, []*database.Filter, error) {
+	if h.rules == nil {
+		result, err := h.docs.List(r.Context(), col, opts)
+		return result, opts.Filters, err
+	}
+
+	evalCtx := h.evalContext(r, tenant, nil)
+	plan, err := h.rules.PlanList(col.Name, rules.OpRead, evalCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Debug().
+		Str("collection", col.Name).
+		Str("strategy", string(plan.Strategy)).
+		Int("filters", len(plan.Filters)).
+		Msg("Planned read rule for list")
+
+	switch plan.Strategy {
+	case rules.ListStrategyConstant:
+		if !plan.Allowed {
+			return nil, nil, h.rules.Deny(col.Name, rules.OpRead, evalCtx)
+		}
+		result, err := h.docs.List(r.Context(), col, opts)
+		return result, opts.Filters, err
+	case rules.ListStrategySQL:
+		planned := *opts
+		planned.Filters = append(append([]*database.Filter{}, opts.Filters...), plan.Filters...)
+		result, err := h.docs.List(r.Context(), col, &planned)
+		return result, planned.Filters, err
+	case rules.ListStrategyPerRow:
+		result, err := h.findReadablePerRow(r, col, opts, evalCtx)
+		return result, opts.Filters, err
+	default:
+		result, err := h.docs.List(r.Context(), col, opts)
+		return result, opts.Filters, err
+	}
+}
+
+// Per-row read rules are checked against batches of perRowBatchSize rows,
+// and one list request checks at most perRowScanLimit rows.
+const (
+	perRowBatchSize = 200
+	perRowScanLimit = 10000
+)
+
+// findReadablePerRow pages through the rows matching opts in batches,
+// keeping those the read rule allows, and stops once the requested page is
+// full. A rule that fails to evaluate for a row denies that row. Unless the
+// scan reached the last row, the total is extrapolated from the share of
+// rows allowed so far and marked as an estimate.
+func (h *Handlers) findReadablePerRow(r *http.Request, col *schema.Collection, opts *database.QueryOptions, evalCtx *rules.EvalContext) (*database.QueryResult, error) {
+	batch := *opts
+	batch.Sorts = withPrimaryKeyOrder(col, opts.Sorts)
+	batch.Limit = perRowBatchSize
+
+	var (
+		docs      []database.Row
+		allowed   int64
+		scanned   int64
+		matched   int64
+		exhausted bool
+	)
+	pageFull := func() bool {
+		return opts.Limit > 0 && len(docs) >= opts.Limit
+	}
+
+	for !pageFull() && scanned < perRowScanLimit {
+		batch.Offset = int(scanned)
+		result, err := h.docs.List(r.Context(), col, &batch)
+		if err != nil {
+			return nil, err
+		}
+		if scanned == 0 {
+			matched = result.Total
+			batch.Total = database.TotalNone
+		}
+
+		processed := 0
+		for _, doc := range result.Docs {
+			if pageFull() {
+				break
+			}
+			processed++
+			evalCtx.Doc = doc
+			ok, err := h.rules.Evaluate(col.Name, rules.OpRead, evalCtx)
+			if err != nil {
+				log.Debug().Err(err).Str("collection", col.Name).Msg("Read rule failed for row, skipping it")
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if allowed >= int64(opts.Offset) {
+				docs = append(docs, doc)
+			}
+			allowed++
+		}
+		scanned += int64(processed)
+		if processed == len(result.Docs) && len(result.Docs) < perRowBatchSize {
+			exhausted = true
+			break
+		}
+	}
+	if opts.Total == database.TotalExact && scanned >= matched {
+		exhausted = true
 	}
 
-	return h.rules.CheckAccess(collection, op, evalCtx)
+	result := &database.QueryResult{Docs: docs, Total: allowed}
+	if !exhausted && opts.Total != database.TotalNone {
+		if scanned > 0 && matched > scanned {
+			result.Total = allowed + (matched-scanned)*allowed/scanned
+		}
+		result.Estimated = true
+	}
+	if docs == nil {
+		result.Docs = []database.Row{}
+	}
+	return result, nil
+}
+
+// withPrimaryKeyOrder appends the primary key to sorts, so that batches of
+// a scan follow a stable order.
+func withPrimaryKeyOrder(col *schema.Collection, sorts []*database.Sort) []*database.Sort {
+	pk := col.PrimaryKeyField()
+	if pk == nil {
+		return sorts
+	}
+	for _, s := range sorts {
+		if s.Field == pk.Name {
+			return sorts
+		}
+	}
+	return append(append([]*database.Sort{}, sorts...), &database.Sort{Field: pk.Name, Order: database.SortAsc})
 }
 
 func extractClientIP(r *http.Request) string {
@@ -84,11 +295,25 @@ func extractClientIP(r *http.Request) string {
 	return ip
 }
 
-func (h *Handlers) getCollection(name string) (*database.Collection, error) {
+// collectionSchema returns the named collection for a REST request. A
+// collection with expose: false is only served to admins, for the admin data
+// browser; anyone else gets not found.
+func (h *Handlers) collectionSchema(r *http.Request, name string) (*schema.Collection, error) {
 	col, ok := h.schema.Collections[name]
-	if !ok {
+	if !ok || (!col.Exposed() && !isAdmin(r)) {
 		return nil, errors.New("collection not found")
 	}
+	return col, nil
+}
+
+// getCollection looks up a collection like collectionSchema, for the
+// handlers that use SQLite features beyond the document store, such as
+// blobs, history and upserts.
+func (h *Handlers) getCollection(r *http.Request, name string) (*database.Collection, error) {
+	col, err := h.collectionSchema(r, name)
+	if err != nil {
+		return nil, err
+	}
 	coll := database.NewCollection(h.db, col)
 	if h.hookTrigger != nil {
 		coll.SetHookTrigger(h.hookTrigger)
@@ -96,6 +321,15 @@ func (h *Handlers) getCollection(name string) (*database.Collection, error) {
 	return coll, nil
 }
 
+// isAdmin reports whether the request is from a user with the admin role.
+func isAdmin(r *http.Request) bool {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return user.IsAdmin()
+	}
+	claims := auth.ClaimsFromContext(r.Context())
+	return claims != nil && claims.Role == auth.RoleAdmin
+}
+
 func (h *Handlers) validateFileFields(ctx context.Context, collSchema *schema.Collection, data database.Row) error {
 	if h.storageService == nil {
 		return nil
@@ -271,45 +505,95 @@ func (h *Handlers) handleFileFieldUpdates(ctx context.Context, collSchema *schem
 func (h *Handlers) ListDocuments(w http.ResponseWriter, r *http.Request) {
 	collectionName := r.PathValue("collection")
 
-	col, err := h.getCollection(collectionName)
+	col, err := h.collectionSchema(r, collectionName)
 	if err != nil {
 		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
 		return
 	}
 
-	opts, err := parseQueryOptions(r)
+	tenant, err := h.resolveTenant(r, col)
+	if err != nil {
+		tenantError(w, err)
+		return
+	}
+
+	opts, clampedFrom, err := parseQueryOptions(r, col.List)
 	if err != nil {
 		Error(w, http.StatusBadRequest, "INVALID_QUERY", err.Error())
 		return
 	}
+	if clampedFrom > 0 {
+		w.Header().Set(LimitClampedHeader, strconv.Itoa(clampedFrom))
+	}
+	if filter := tenant.filter(); filter != nil {
+		opts.Filters = append(opts.Filters, filter)
+	}
 
-	result, err := col.Find(r.Context(), opts)
+	etag, err := h.listETag(r, col, tenant)
+	if err != nil {
+		log.Error().Err(err).Str("collection", collectionName).Msg("Failed to read collection version")
+		Error(w, http.StatusInternalServerError, "QUERY_ERROR", "Failed to query documents")
+		return
+	}
+	if etag != "" {
+		setPrivateValidators(w, etag, time.Time{})
+		if checkNotModified(w, r, etag, time.Time{}) {
+			return
+		}
+	}
+
+	result, filters, err := h.findReadable(r, col, tenant, opts)
+	if errors.Is(err, rules.ErrAccessDenied) {
+		h.accessDenied(w, r, err)
+		return
+	}
+	if errors.Is(err, database.ErrEncryptedField) || errors.Is(err, database.ErrInvalidSort) {
+		Error(w, http.StatusBadRequest, "INVALID_QUERY", err.Error())
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Str("collection", collectionName).Msg("Failed to list documents")
 		Error(w, http.StatusInternalServerError, "QUERY_ERROR", "Failed to query documents")
 		return
 	}
 
-	JSON(w, http.StatusOK, map[string]any{
+	requestlog.RecordListQuery(w, listQueryShape(collectionName, filters, opts, len(result.Docs)))
+	h.redactFields(r, collectionName, tenant, result.Docs...)
+
+	resp := map[string]any{
 		"docs":   result.Docs,
-		"total":  result.Total,
 		"limit":  opts.Limit,
 		"offset": opts.Offset,
-	})
+	}
+	if opts.Total != database.TotalNone {
+		resp["total"] = result.Total
+		w.Header().Set(TotalCountHeader, strconv.FormatInt(result.Total, 10))
+	}
+	if result.Estimated {
+		resp["total_estimated"] = true
+	}
+
+	JSON(w, http.StatusOK, resp)
 }
 
 func (h *Handlers) GetDocument(w http.ResponseWriter, r *http.Request) {
 	collectionName := r.PathValue("collection")
 	id := r.PathValue("id")
 
-	col, err := h.getCollection(collectionName)
+	col, err := h.collectionSchema(r, collectionName)
 	if err != nil {
 		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
 		return
 	}
 
-	doc, err := col.FindOne(r.Context(), id)
-	if errors.Is(err, database.ErrNotFound) {
+	tenant, err := h.resolveTenant(r, col)
+	if err != nil {
+		tenantError(w, err)
+		return
+	}
+
+	doc, err := h.docs.Get(r.Context(), col, id)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !tenant.owns(doc)) {
 		Error(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
 		return
 	}
@@ -319,9 +603,9 @@ func (h *Handlers) GetDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.checkAccess(r, collectionName, rules.OpRead, doc); err != nil {
+	if err := h.checkAccess(r, collectionName, rules.OpRead, tenant, doc); err != nil {
 		if errors.Is(err, rules.ErrAccessDenied) {
-			Forbidden(w, "Access denied")
+			h.accessDenied(w, r, err)
 			return
 		}
 		log.Error().Err(err).Str("collection", collectionName).Msg("Rule evaluation failed")
@@ -332,34 +616,64 @@ func (h *Handlers) GetDocument(w http.ResponseWriter, r *http.Request) {
 	expandStr := r.URL.Query().Get("expand")
 	if expandStr != "" {
 		expandFields := strings.Split(expandStr, ",")
-		if err := h.expandFileFields(r.Context(), col.Schema(), doc, expandFields); err != nil {
+		if err := h.expandFileFields(r.Context(), col, doc, expandFields); err != nil {
 			log.Error().Err(err).Str("collection", collectionName).Msg("Failed to expand file fields")
 			Error(w, http.StatusInternalServerError, "EXPAND_ERROR", "Failed to expand file fields")
 			return
 		}
 	}
+	h.redactFields(r, collectionName, tenant, doc)
 
-	JSON(w, http.StatusOK, doc)
+	data, err := json.Marshal(doc)
+	if err != nil {
+		InternalError(w, "Failed to encode document")
+		return
+	}
+	lastModified := documentLastModified(col, doc)
+	etag := weakETag(viewerKey(r), string(data))
+	setPrivateValidators(w, etag, lastModified)
+	if checkNotModified(w, r, etag, lastModified) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(data, '\n'))
 }
 
 func (h *Handlers) CreateDocument(w http.ResponseWriter, r *http.Request) {
 	collectionName := r.PathValue("collection")
 
-	col, err := h.getCollection(collectionName)
+	col, err := h.collectionSchema(r, collectionName)
 	if err != nil {
 		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
 		return
 	}
 
+	tenant, err := h.resolveTenant(r, col)
+	if err != nil {
+		tenantError(w, err)
+		return
+	}
+
 	var data database.Row
-	if decodeErr := json.NewDecoder(r.Body).Decode(&data); decodeErr != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &data, DecodeOptions{}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
+	if err := checkDocumentFields(col, data); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+
+	if err := tenant.assign(data); err != nil {
+		tenantError(w, err)
 		return
 	}
 
-	if accessErr := h.checkAccess(r, collectionName, rules.OpCreate, data); accessErr != nil {
+	if accessErr := h.checkAccess(r, collectionName, rules.OpCreate, tenant, data); accessErr != nil {
 		if errors.Is(accessErr, rules.ErrAccessDenied) {
-			Forbidden(w, "Access denied")
+			h.accessDenied(w, r, accessErr)
 			return
 		}
 		log.Error().Err(accessErr).Str("collection", collectionName).Msg("Rule evaluation failed")
@@ -367,12 +681,17 @@ func (h *Handlers) CreateDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if verrs := database.ValidateInput(col.Schema(), data, true); verrs.HasErrors() {
+	if verrs := database.ValidateInput(col, data, true); verrs.HasErrors() {
 		ErrorWithDetails(w, http.StatusBadRequest, "VALIDATION_ERROR", verrs.Errors[0].Message, verrs.Errors)
 		return
 	}
 
-	if err := h.validateFileFields(r.Context(), col.Schema(), data); err != nil {
+	if err := col.RunChecks(data, nil); err != nil {
+		checkFailed(w, err)
+		return
+	}
+
+	if err := h.validateFileFields(r.Context(), col, data); err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			Error(w, http.StatusBadRequest, "FILE_NOT_FOUND", "Referenced file does not exist")
 			return
@@ -386,7 +705,11 @@ func (h *Handlers) CreateDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	doc, err := col.Create(r.Context(), data)
+	var doc database.Row
+	err = h.docs.WithTx(r.Context(), func(ctx context.Context) error {
+		doc, err = h.docs.Create(ctx, col, data)
+		return err
+	})
 	if err != nil {
 		if ce := database.AsConstraintError(err); ce != nil {
 			Error(w, http.StatusBadRequest, constraintErrorCode(ce), ce.Message)
@@ -397,6 +720,8 @@ func (h *Handlers) CreateDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.redactFields(r, collectionName, tenant, doc)
+	setLocation(w, col, doc)
 	JSON(w, http.StatusCreated, doc)
 }
 
@@ -404,14 +729,20 @@ func (h *Handlers) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 	collectionName := r.PathValue("collection")
 	id := r.PathValue("id")
 
-	col, err := h.getCollection(collectionName)
+	col, err := h.collectionSchema(r, collectionName)
 	if err != nil {
 		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
 		return
 	}
 
-	existingDoc, err := col.FindOne(r.Context(), id)
-	if errors.Is(err, database.ErrNotFound) {
+	tenant, err := h.resolveTenant(r, col)
+	if err != nil {
+		tenantError(w, err)
+		return
+	}
+
+	existingDoc, err := h.docs.Get(r.Context(), col, id)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !tenant.owns(existingDoc)) {
 		Error(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
 		return
 	}
@@ -421,9 +752,9 @@ func (h *Handlers) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if accessErr := h.checkAccess(r, collectionName, rules.OpUpdate, existingDoc); accessErr != nil {
+	if accessErr := h.checkAccess(r, collectionName, rules.OpUpdate, tenant, existingDoc); accessErr != nil {
 		if errors.Is(accessErr, rules.ErrAccessDenied) {
-			Forbidden(w, "Access denied")
+			h.accessDenied(w, r, accessErr)
 			return
 		}
 		log.Error().Err(accessErr).Str("collection", collectionName).Msg("Rule evaluation failed")
@@ -432,17 +763,39 @@ func (h *Handlers) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var data database.Row
-	if decodeErr := json.NewDecoder(r.Body).Decode(&data); decodeErr != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if decodeErr := DecodeJSON(r, &data, DecodeOptions{}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
+	if err := checkDocumentFields(col, data, schema.RegenerateSlugKey); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
-	if verrs := database.ValidateInput(col.Schema(), data, false); verrs.HasErrors() {
+	if err := tenant.checkWrite(data); err != nil {
+		tenantError(w, err)
+		return
+	}
+
+	// Absent keys are left alone; an explicit null clears the field.
+	if verrs := database.ValidateNulls(col, data); verrs.HasErrors() {
+		ErrorWithDetails(w, http.StatusUnprocessableEntity, "VALIDATION_ERROR", verrs.Errors[0].Message, verrs.Errors)
+		return
+	}
+	if verrs := database.ValidateInput(col, data, false); verrs.HasErrors() {
 		ErrorWithDetails(w, http.StatusBadRequest, "VALIDATION_ERROR", verrs.Errors[0].Message, verrs.Errors)
 		return
 	}
 
-	if err := h.validateFileFields(r.Context(), col.Schema(), data); err != nil {
+	merged := make(database.Row, len(existingDoc)+len(data))
+	maps.Copy(merged, existingDoc)
+	maps.Copy(merged, data)
+	if err := col.RunChecks(merged, existingDoc); err != nil {
+		checkFailed(w, err)
+		return
+	}
+
+	if err := h.validateFileFields(r.Context(), col, data); err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			Error(w, http.StatusBadRequest, "FILE_NOT_FOUND", "Referenced file does not exist")
 			return
@@ -456,13 +809,42 @@ func (h *Handlers) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.handleFileFieldUpdates(r.Context(), col.Schema(), existingDoc, data); err != nil {
-		log.Error().Err(err).Str("collection", collectionName).Msg("Failed to handle file field updates")
-		Error(w, http.StatusInternalServerError, "UPDATE_ERROR", "Failed to handle file field updates")
-		return
+	var clearedBlobs map[string]bool
+	for name, value := range data {
+		if field, ok := col.Fields[name]; ok && field.Storage != "" && value == nil {
+			if clearedBlobs == nil {
+				clearedBlobs = map[string]bool{}
+			}
+			clearedBlobs[name] = true
+		}
 	}
 
-	doc, err := col.Update(r.Context(), id, data)
+	var doc database.Row
+	err = h.docs.WithTx(r.Context(), func(ctx context.Context) error {
+		var blobs []*database.BlobInfo
+		if clearedBlobs != nil {
+			if blobs, err = h.bucketBlobs(ctx, col, id, clearedBlobs); err != nil {
+				return err
+			}
+		}
+		doc, err = h.docs.Update(ctx, col, id, data)
+		if err != nil {
+			return err
+		}
+		for _, info := range blobs {
+			h.docs.AfterCommit(ctx, func(ctx context.Context) {
+				h.deleteBlobObject(ctx, info)
+			})
+		}
+		// Replaced files are only removed once the new references are
+		// committed.
+		h.docs.AfterCommit(ctx, func(ctx context.Context) {
+			if err := h.handleFileFieldUpdates(ctx, col, existingDoc, data); err != nil {
+				log.Error().Err(err).Str("collection", collectionName).Msg("Failed to handle file field updates")
+			}
+		})
+		return nil
+	})
 	if errors.Is(err, database.ErrNotFound) {
 		Error(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
 		return
@@ -477,6 +859,7 @@ func (h *Handlers) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.redactFields(r, collectionName, tenant, doc)
 	JSON(w, http.StatusOK, doc)
 }
 
@@ -484,14 +867,20 @@ func (h *Handlers) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 	collectionName := r.PathValue("collection")
 	id := r.PathValue("id")
 
-	col, err := h.getCollection(collectionName)
+	col, err := h.collectionSchema(r, collectionName)
 	if err != nil {
 		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
 		return
 	}
 
-	existingDoc, err := col.FindOne(r.Context(), id)
-	if errors.Is(err, database.ErrNotFound) {
+	tenant, err := h.resolveTenant(r, col)
+	if err != nil {
+		tenantError(w, err)
+		return
+	}
+
+	existingDoc, err := h.docs.Get(r.Context(), col, id)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !tenant.owns(existingDoc)) {
 		Error(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
 		return
 	}
@@ -501,9 +890,9 @@ func (h *Handlers) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if accessErr := h.checkAccess(r, collectionName, rules.OpDelete, existingDoc); accessErr != nil {
+	if accessErr := h.checkAccess(r, collectionName, rules.OpDelete, tenant, existingDoc); accessErr != nil {
 		if errors.Is(accessErr, rules.ErrAccessDenied) {
-			Forbidden(w, "Access denied")
+			h.accessDenied(w, r, accessErr)
 			return
 		}
 		log.Error().Err(accessErr).Str("collection", collectionName).Msg("Rule evaluation failed")
@@ -511,7 +900,26 @@ func (h *Handlers) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = col.Delete(r.Context(), id)
+	err = h.docs.WithTx(r.Context(), func(ctx context.Context) error {
+		blobs, err := h.bucketBlobs(ctx, col, id, nil)
+		if err != nil {
+			return err
+		}
+		if err := h.docs.Delete(ctx, col, id); err != nil {
+			return err
+		}
+		for _, info := range blobs {
+			h.docs.AfterCommit(ctx, func(ctx context.Context) {
+				h.deleteBlobObject(ctx, info)
+			})
+		}
+		h.docs.AfterCommit(ctx, func(ctx context.Context) {
+			if err := h.deleteFileFieldsOnCascade(ctx, col, existingDoc); err != nil {
+				log.Error().Err(err).Str("collection", collectionName).Msg("Failed to delete cascade files")
+			}
+		})
+		return nil
+	})
 	if errors.Is(err, database.ErrNotFound) {
 		Error(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
 		return
@@ -522,56 +930,139 @@ func (h *Handlers) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.deleteFileFieldsOnCascade(r.Context(), col.Schema(), existingDoc); err != nil {
-		log.Error().Err(err).Str("collection", collectionName).Msg("Failed to delete cascade files")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listQueryShape normalizes a list query for the request log.
+func listQueryShape(collection string, filters []*database.Filter, opts *database.QueryOptions, returned int) *requestlog.ListQuery {
+	q := &requestlog.ListQuery{
+		Collection: collection,
+		Search:     opts.Search != "",
+		Limit:      opts.Limit,
+		Returned:   returned,
+	}
+	for _, f := range filters {
+		q.Filters = append(q.Filters, requestlog.QueryFilter{Field: f.Field, Op: string(f.Op)})
+	}
+	for _, s := range opts.Sorts {
+		q.Sorts = append(q.Sorts, requestlog.QuerySort{Field: s.Field, Desc: s.Order == database.SortDesc})
+	}
+	return q
+}
+
+// LimitClampedHeader is set on list responses whose requested limit exceeded
+// the collection's maximum, to the limit requested; the limit used is in the
+// response body.
+const LimitClampedHeader = "X-Alyx-Limit-Clamped"
+
+// TotalCountHeader is set on list responses that counted their documents, to
+// the same total as the response body.
+const TotalCountHeader = "X-Total-Count"
+
+// setLocation points the Location header of a 201 response at the created
+// document.
+func setLocation(w http.ResponseWriter, col *schema.Collection, doc database.Row) {
+	pk := col.PrimaryKeyField()
+	if pk == nil || doc[pk.Name] == nil {
+		return
+	}
+	w.Header().Set("Location", "/api/collections/"+url.PathEscape(col.Name)+"/"+url.PathEscape(fmt.Sprint(doc[pk.Name])))
+}
+
+// HeadHandler serves HEAD requests with fn, the handler of the matching GET
+// route, so that they get the same status and headers, including ETag and
+// X-Total-Count, without a body.
+func HeadHandler(fn HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fn(bodylessWriter{w}, r)
 	}
+}
 
-	w.WriteHeader(http.StatusNoContent)
+// bodylessWriter discards the body written to it.
+type bodylessWriter struct {
+	http.ResponseWriter
 }
 
-func parseQueryOptions(r *http.Request) (*database.QueryOptions, error) {
-	opts := &database.QueryOptions{
-		Limit:  100,
+func (w bodylessWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// parseQueryOptions parses the query of a list request, applying the
+// collection's list defaults. clampedFrom is the requested limit if it was
+// lowered to the maximum, or zero.
+func parseQueryOptions(r *http.Request, list *schema.ListConfig) (opts *database.QueryOptions, clampedFrom int, err error) {
+	return parseListOptions(r.URL.Query(), list)
+}
+
+// parseListOptions parses the list endpoint's query parameters.
+func parseListOptions(query url.Values, list *schema.ListConfig) (opts *database.QueryOptions, clampedFrom int, err error) {
+	defaultLimit, maxLimit := list.Limits()
+	opts = &database.QueryOptions{
+		Limit:  defaultLimit,
 		Offset: 0,
 	}
-	query := r.URL.Query()
 
-	if err := parsePaginationOptions(query, opts); err != nil {
-		return nil, err
+	clampedFrom, err = parsePaginationOptions(query, opts, maxLimit)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	if err := parseFilterOptions(query, opts); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	parseSortAndExpandOptions(query, opts)
+	if err := parseSortAndExpandOptions(query, opts); err != nil {
+		return nil, 0, err
+	}
+	if len(opts.Sorts) == 0 && list != nil && list.DefaultSort != "" {
+		if opts.Sorts, err = database.ParseSorts(list.DefaultSort); err != nil {
+			return nil, 0, err
+		}
+	}
 
 	opts.Search = query.Get("search")
 
-	return opts, nil
+	switch mode := database.TotalMode(query.Get("total")); mode {
+	case "":
+		opts.Total = database.TotalExact
+	case database.TotalExact, database.TotalNone, database.TotalEstimate:
+		opts.Total = mode
+	default:
+		return nil, 0, errors.New("invalid total parameter: must be none, exact or estimate")
+	}
+
+	return opts, clampedFrom, nil
 }
 
-func parsePaginationOptions(query map[string][]string, opts *database.QueryOptions) error {
+func parsePaginationOptions(query map[string][]string, opts *database.QueryOptions, maxLimit int) (clampedFrom int, err error) {
+	setLimit := func(limit int) {
+		if limit > maxLimit {
+			clampedFrom = limit
+			limit = maxLimit
+		}
+		opts.Limit = limit
+	}
+
 	if limitStr := getQueryParam(query, "limit"); limitStr != "" {
 		limit, err := strconv.Atoi(limitStr)
 		if err != nil || limit < 0 {
-			return errors.New("invalid limit parameter")
+			return 0, errors.New("invalid limit parameter")
 		}
-		opts.Limit = min(limit, 1000)
+		setLimit(limit)
 	}
 
 	if perPageStr := getQueryParam(query, "perPage"); perPageStr != "" {
 		perPage, err := strconv.Atoi(perPageStr)
 		if err != nil || perPage < 0 {
-			return errors.New("invalid perPage parameter")
+			return 0, errors.New("invalid perPage parameter")
 		}
-		opts.Limit = min(perPage, 1000)
+		setLimit(perPage)
 	}
 
 	if offsetStr := getQueryParam(query, "offset"); offsetStr != "" {
 		offset, err := strconv.Atoi(offsetStr)
 		if err != nil || offset < 0 {
-			return errors.New("invalid offset parameter")
+			return 0, errors.New("invalid offset parameter")
 		}
 		opts.Offset = offset
 	}
@@ -579,12 +1070,12 @@ func parsePaginationOptions(query map[string][]string, opts *database.QueryOptio
 	if pageStr := getQueryParam(query, "page"); pageStr != "" {
 		page, err := strconv.Atoi(pageStr)
 		if err != nil || page < 1 {
-			return errors.New("invalid page parameter")
+			return 0, errors.New("invalid page parameter")
 		}
 		opts.Offset = (page - 1) * opts.Limit
 	}
 
-	return nil
+	return clampedFrom, nil
 }
 
 func parseFilterOptions(query map[string][]string, opts *database.QueryOptions) error {
@@ -598,17 +1089,37 @@ func parseFilterOptions(query map[string][]string, opts *database.QueryOptions)
 	return nil
 }
 
-func parseSortAndExpandOptions(query map[string][]string, opts *database.QueryOptions) {
-	if sortStr := getQueryParam(query, "sort"); sortStr != "" {
-		for _, s := range strings.Split(sortStr, ",") {
-			field, order := database.ParseSortString(strings.TrimSpace(s))
-			opts.Sorts = append(opts.Sorts, &database.Sort{Field: field, Order: order})
+// parseSortAndExpandOptions parses sort and expand. Repeated sort parameters
+// are the same as one comma separated sort.
+func parseSortAndExpandOptions(query map[string][]string, opts *database.QueryOptions) error {
+	if sortStr := strings.Join(query["sort"], ","); sortStr != "" {
+		sorts, err := database.ParseSorts(sortStr)
+		if err != nil {
+			return err
 		}
+		opts.Sorts = sorts
 	}
 
 	if expandStr := getQueryParam(query, "expand"); expandStr != "" {
 		opts.Expand = strings.Split(expandStr, ",")
 	}
+	return nil
+}
+
+// checkFailed writes the 422 response for a failed collection check.
+func checkFailed(w http.ResponseWriter, err error) {
+	var ce *schema.CheckError
+	if !errors.As(err, &ce) {
+		InternalError(w, "Failed to evaluate checks")
+		return
+	}
+	details := map[string]string{"check": ce.Check.Name}
+	if ce.Err != nil {
+		// A check that cannot be evaluated, say comparing a null field,
+		// fails like one that evaluates to false.
+		details["error"] = ce.Err.Error()
+	}
+	ErrorWithDetails(w, http.StatusUnprocessableEntity, "CHECK_FAILED", ce.Check.FailureMessage(), details)
 }
 
 func constraintErrorCode(ce *database.ConstraintError) string {
@@ -4,15 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/watzon/alyx/internal/auth"
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/functions"
+	"github.com/watzon/alyx/internal/rules"
 	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/server/requestlog"
 )
 
 func setupTestHandlers(t *testing.T) (*Handlers, *database.DB) {
@@ -47,6 +54,9 @@ collections:
       active:
         type: bool
         default: true
+      bio:
+        type: string
+        nullable: true
       created_at:
         type: timestamp
         default: now
@@ -131,6 +141,63 @@ func TestReadiness(t *testing.T) {
 	}
 }
 
+type failingProber struct{}
+
+func (failingProber) Version(context.Context, functions.Runtime) (string, error) {
+	return "", errors.New("executable file not found")
+}
+
+func TestFunctionsHealth(t *testing.T) {
+	_, db := setupTestHandlers(t)
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "hello"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hello", "index.js"), []byte("module.exports = {}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	funcService, err := functions.NewService(&functions.ServiceConfig{
+		FunctionsDir: dir,
+		Config:       &config.Default().Functions,
+		Schema: &schema.Schema{Functions: map[string]*schema.Function{
+			"hello": {Runtime: "node", Entrypoint: "index.js"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	funcService.SetProber(failingProber{})
+
+	h := NewHealthHandlers(db, nil, funcService, "test")
+
+	w := httptest.NewRecorder()
+	h.Readiness(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected readiness to ignore functions by default, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.Health(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var resp HealthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Components["functions"].Status != HealthStatusUnhealthy {
+		t.Errorf("expected functions status 'unhealthy', got %+v", resp.Components["functions"])
+	}
+	if resp.Status != HealthStatusDegraded {
+		t.Errorf("expected status 'degraded', got %v", resp.Status)
+	}
+
+	h.SetFunctionsEnabled(true)
+	w = httptest.NewRecorder()
+	h.Readiness(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
+
 func TestCreateAndGetDocument(t *testing.T) {
 	h, _ := setupTestHandlers(t)
 
@@ -158,6 +225,9 @@ func TestCreateAndGetDocument(t *testing.T) {
 	if created["name"] != "Alice" {
 		t.Errorf("expected name 'Alice', got %v", created["name"])
 	}
+	if loc := w.Header().Get("Location"); loc != "/api/collections/users/"+id {
+		t.Errorf("expected Location /api/collections/users/%s, got %q", id, loc)
+	}
 
 	req = httptest.NewRequest(http.MethodGet, "/api/collections/users/"+id, nil)
 	req.SetPathValue("collection", "users")
@@ -222,6 +292,363 @@ func TestListDocuments(t *testing.T) {
 	}
 }
 
+func TestListDocumentsTotalMode(t *testing.T) {
+	h, db := setupTestHandlers(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		db.ExecContext(ctx, "INSERT INTO users (id, name, email, active, created_at) VALUES (?, ?, ?, 1, datetime('now'))",
+			"user-"+string(rune('a'+i)),
+			"User "+string(rune('A'+i)),
+			"user"+string(rune('a'+i))+"@example.com")
+	}
+
+	list := func(query string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, "/api/collections/users?"+query, nil)
+		req.SetPathValue("collection", "users")
+		w := httptest.NewRecorder()
+		h.ListDocuments(w, req)
+
+		var resp map[string]any
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	code, resp := list("total=none")
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if _, ok := resp["total"]; ok {
+		t.Errorf("expected total to be omitted, got %v", resp["total"])
+	}
+	if docs, _ := resp["docs"].([]any); len(docs) != 3 {
+		t.Errorf("expected 3 docs, got %v", resp["docs"])
+	}
+
+	_, resp = list("total=estimate")
+	if resp["total"] != float64(3) || resp["total_estimated"] != true {
+		t.Errorf("expected estimated total 3, got %v (estimated=%v)", resp["total"], resp["total_estimated"])
+	}
+
+	if code, _ := list("total=sometimes"); code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid total mode, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestListDocumentsListConfig(t *testing.T) {
+	h, db := setupTestHandlers(t)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		db.ExecContext(ctx, "INSERT INTO users (id, name, email, active, created_at) VALUES (?, ?, ?, 1, ?)",
+			"user-"+string(rune('a'+i)),
+			"User "+string(rune('A'+i)),
+			"user"+string(rune('a'+i))+"@example.com",
+			fmt.Sprintf("2024-01-0%dT00:00:00Z", i+1))
+	}
+	h.schema.Collections["users"].List = &schema.ListConfig{DefaultSort: "-created_at", DefaultLimit: 2, MaxLimit: 3}
+
+	list := func(query string) (*httptest.ResponseRecorder, []string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/collections/users?"+query, nil)
+		req.SetPathValue("collection", "users")
+		w := httptest.NewRecorder()
+		h.ListDocuments(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var resp struct {
+			Docs []map[string]any `json:"docs"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		ids := make([]string, len(resp.Docs))
+		for i, doc := range resp.Docs {
+			ids[i] = doc["id"].(string)
+		}
+		return w, ids
+	}
+
+	w, ids := list("")
+	if fmt.Sprint(ids) != "[user-e user-d]" {
+		t.Errorf("expected the default limit and newest first, got %v", ids)
+	}
+	if got := w.Header().Get(LimitClampedHeader); got != "" {
+		t.Errorf("expected no clamp header, got %q", got)
+	}
+
+	w, ids = list("limit=50")
+	if len(ids) != 3 {
+		t.Errorf("expected limit clamped to 3, got %d docs", len(ids))
+	}
+	if got := w.Header().Get(LimitClampedHeader); got != "50" {
+		t.Errorf("expected clamp header with the requested limit 50, got %q", got)
+	}
+
+	if _, ids = list("sort=name&limit=1"); fmt.Sprint(ids) != "[user-a]" {
+		t.Errorf("expected an explicit sort to replace the default, got %v", ids)
+	}
+}
+
+func TestListDocumentsSort(t *testing.T) {
+	h, db := setupTestHandlers(t)
+
+	for _, u := range []struct{ id, name, bio string }{
+		{"user-a", "Bea", "b"},
+		{"user-b", "Al", ""},
+		{"user-c", "Bea", ""},
+		{"user-d", "Al", "a"},
+	} {
+		var bio any
+		if u.bio != "" {
+			bio = u.bio
+		}
+		if _, err := db.ExecContext(context.Background(),
+			`INSERT INTO users (id, name, email, bio) VALUES (?, ?, ?, ?)`,
+			u.id, u.name, u.id+"@example.com", bio); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	list := func(query string) (*httptest.ResponseRecorder, string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/collections/users?"+query, nil)
+		req.SetPathValue("collection", "users")
+		w := httptest.NewRecorder()
+		h.ListDocuments(w, req)
+
+		var resp struct {
+			Docs []map[string]any `json:"docs"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		ids := make([]string, len(resp.Docs))
+		for i, doc := range resp.Docs {
+			ids[i] = doc["id"].(string)
+		}
+		return w, fmt.Sprint(ids)
+	}
+
+	tests := []struct {
+		query string
+		ids   string
+	}{
+		{"sort=-name,id", "[user-a user-c user-b user-d]"},
+		{"sort=-name&sort=id", "[user-a user-c user-b user-d]"},
+		{"sort=bio,id", "[user-b user-c user-d user-a]"},
+		{"sort=bio:nullslast,id", "[user-d user-a user-b user-c]"},
+		{"sort=-bio:nullsfirst,-id", "[user-c user-b user-a user-d]"},
+	}
+	for _, tt := range tests {
+		w, ids := list(tt.query)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d: %s", tt.query, w.Code, w.Body.String())
+			continue
+		}
+		if ids != tt.ids {
+			t.Errorf("%s: got %s, want %s", tt.query, ids, tt.ids)
+		}
+	}
+
+	for _, query := range []string{"sort=missing", "sort=name,-missing", "sort=name:nullsmiddle", "sort=name,,id"} {
+		if w, _ := list(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d: %s", query, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestListDocumentsReadRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		code  int
+		names []string
+	}{
+		{"constant allow", "request.method == 'GET'", http.StatusOK, []string{"User A", "User B", "User C", "User D", "User E"}},
+		{"constant deny", "request.method == 'POST'", http.StatusForbidden, nil},
+		{"sql", "doc.active == true", http.StatusOK, []string{"User A", "User C", "User E"}},
+		{"per row", "doc.name == 'User B' || doc.name == 'User D'", http.StatusOK, []string{"User B", "User D"}},
+		// auth.id is missing for anonymous requests, so the rule fails to
+		// evaluate for the rows it does not short-circuit; those are denied.
+		{"per row with evaluation errors", "doc.email == auth.id || doc.name == 'User C'", http.StatusOK, []string{"User C"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := setupTestHandlers(t)
+			ctx := context.Background()
+
+			for i := 0; i < 5; i++ {
+				db.ExecContext(ctx, "INSERT INTO users (id, name, email, active, created_at) VALUES (?, ?, ?, ?, datetime('now', ?))",
+					"user-"+string(rune('a'+i)),
+					"User "+string(rune('A'+i)),
+					"user"+string(rune('a'+i))+"@example.com",
+					(i+1)%2,
+					fmt.Sprintf("+%d seconds", i))
+			}
+
+			engine, err := rules.NewEngine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.schema.Collections["users"].Rules = &schema.Rules{Read: tt.rule}
+			if err := engine.LoadSchema(h.schema); err != nil {
+				t.Fatal(err)
+			}
+			h.rules = engine
+
+			req := httptest.NewRequest(http.MethodGet, "/api/collections/users?sort=created_at&limit=100", nil)
+			req.SetPathValue("collection", "users")
+			w := httptest.NewRecorder()
+
+			h.ListDocuments(w, req)
+
+			if w.Code != tt.code {
+				t.Fatalf("expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Docs  []map[string]any `json:"docs"`
+				Total int              `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			var names []string
+			for _, doc := range resp.Docs {
+				names = append(names, doc["name"].(string))
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.names) {
+				t.Errorf("expected %v, got %v", tt.names, names)
+			}
+			if resp.Total != len(tt.names) {
+				t.Errorf("expected total %d, got %d", len(tt.names), resp.Total)
+			}
+		})
+	}
+}
+
+func TestListDocumentsPerRowScan(t *testing.T) {
+	h, db := setupTestHandlers(t)
+	ctx := context.Background()
+
+	const rows = 2*perRowBatchSize + 50
+	for i := 0; i < rows; i++ {
+		db.ExecContext(ctx, "INSERT INTO users (id, name, email, active) VALUES (?, ?, ?, ?)",
+			fmt.Sprintf("user-%04d", i), fmt.Sprintf("User %04d", i), fmt.Sprintf("user%d@example.com", i), i%2)
+	}
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.schema.Collections["users"].Rules = &schema.Rules{Read: "doc.active == true || doc.name == 'nobody'"}
+	if err := engine.LoadSchema(h.schema); err != nil {
+		t.Fatal(err)
+	}
+	h.rules = engine
+
+	list := func(query string) (names []string, total int, estimated bool) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/collections/users?sort=name&"+query, nil)
+		req.SetPathValue("collection", "users")
+		w := httptest.NewRecorder()
+		h.ListDocuments(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Docs           []map[string]any `json:"docs"`
+			Total          int              `json:"total"`
+			TotalEstimated bool             `json:"total_estimated"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		for _, doc := range resp.Docs {
+			names = append(names, doc["name"].(string))
+		}
+		return names, resp.Total, resp.TotalEstimated
+	}
+
+	// The page lies in the second batch; the scan stops there and
+	// extrapolates the total.
+	names, total, estimated := list("limit=2&offset=120")
+	if fmt.Sprint(names) != "[User 0241 User 0243]" {
+		t.Errorf("expected [User 0241 User 0243], got %v", names)
+	}
+	if !estimated || total != rows/2 {
+		t.Errorf("expected estimated total %d, got %d (estimated %v)", rows/2, total, estimated)
+	}
+
+	// A page past the last readable row scans everything, so the total is exact.
+	names, total, estimated = list("limit=10&offset=220")
+	if len(names) != 5 || estimated || total != rows/2 {
+		t.Errorf("expected last 5 rows with exact total %d, got %v, %d (estimated %v)", rows/2, names, total, estimated)
+	}
+}
+
+func TestRuleDenialDetails(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		t.Run(fmt.Sprintf("debug=%v", debug), func(t *testing.T) {
+			h, db := setupTestHandlers(t)
+			ctx := context.Background()
+			if _, err := db.ExecContext(ctx, "INSERT INTO users (id, name, email) VALUES ('u1', 'User A', 'a@example.com')"); err != nil {
+				t.Fatal(err)
+			}
+
+			engine, err := rules.NewEngine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.schema.Collections["users"].Rules = &schema.Rules{Read: "auth.role == 'admin' || doc.email == auth.email"}
+			if err := engine.LoadSchema(h.schema); err != nil {
+				t.Fatal(err)
+			}
+			h.rules = engine
+			h.cfg.Logging.RuleDenials = debug
+
+			store := requestlog.NewStore(10)
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /api/collections/{collection}/{id}", h.GetDocument)
+			handler := requestlog.Middleware(store)(mux)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/collections/users/u1", nil)
+			req = req.WithContext(auth.ContextWithUser(req.Context(), &auth.User{ID: "u2", Email: "b@example.com", Role: "user"}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != "FORBIDDEN" {
+				t.Errorf("expected FORBIDDEN code, got %q", resp.Code)
+			}
+			if got := resp.Details != nil; got != debug {
+				t.Errorf("expected rule_denied in response %v, got %v", debug, resp.Details)
+			}
+
+			entries := store.List(requestlog.FilterOptions{ErrorCode: "RULE_DENIED"}).Entries
+			if len(entries) != 1 {
+				t.Fatalf("expected one RULE_DENIED log entry, got %d", len(entries))
+			}
+			denied := entries[0].ErrorDetails.(map[string]any)["rule_denied"]
+			denial, ok := denied.(*rules.Denial)
+			if ok != debug {
+				t.Fatalf("expected full denial in log %v, got %#v", debug, denied)
+			}
+			if debug && (denial.Values["auth.role"] != "user" || denial.Values["doc.email"] != "a@example.com") {
+				t.Errorf("unexpected logged values %v", denial.Values)
+			}
+		})
+	}
+}
+
 func TestUpdateDocument(t *testing.T) {
 	h, _ := setupTestHandlers(t)
 
@@ -260,6 +687,64 @@ func TestUpdateDocument(t *testing.T) {
 	}
 }
 
+func TestUpdateDocumentNulls(t *testing.T) {
+	h, _ := setupTestHandlers(t)
+
+	body := bytes.NewBufferString(`{"name":"Bob","email":"bob@example.com","bio":"Hello"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/collections/users", body)
+	req.SetPathValue("collection", "users")
+	w := httptest.NewRecorder()
+	h.CreateDocument(w, req)
+
+	var created map[string]any
+	json.Unmarshal(w.Body.Bytes(), &created)
+	id := created["id"].(string)
+
+	patch := func(body string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodPatch, "/api/collections/users/"+id, bytes.NewBufferString(body))
+		req.SetPathValue("collection", "users")
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		h.UpdateDocument(w, req)
+		var doc map[string]any
+		json.Unmarshal(w.Body.Bytes(), &doc)
+		return w.Code, doc
+	}
+
+	code, doc := patch(`{"bio":null}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %v", http.StatusOK, code, doc)
+	}
+	if v, ok := doc["bio"]; !ok || v != nil {
+		t.Errorf("expected bio to be cleared, got %v", doc)
+	}
+	if doc["name"] != "Bob" {
+		t.Errorf("expected name unchanged, got %v", doc["name"])
+	}
+
+	code, doc = patch(`{"name":"Robert"}`)
+	if code != http.StatusOK || doc["name"] != "Robert" || doc["bio"] != nil {
+		t.Errorf("expected only name to change, got %d: %v", code, doc)
+	}
+
+	code, doc = patch(`{"name":null}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d for null on a required field, got %d: %v", http.StatusUnprocessableEntity, code, doc)
+	}
+	if fields, _ := doc["details"].([]any); len(fields) != 1 {
+		t.Errorf("expected one field error, got %v", doc)
+	}
+
+	// An upsert that updates the document validates nulls the same way.
+	req = httptest.NewRequest(http.MethodPut, "/api/collections/users?key=email", bytes.NewBufferString(`{"email":"bob@example.com","name":null}`))
+	req.SetPathValue("collection", "users")
+	w = httptest.NewRecorder()
+	h.UpsertDocument(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d for a null upsert on a required field, got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+}
+
 func TestDeleteDocument(t *testing.T) {
 	h, _ := setupTestHandlers(t)
 
@@ -297,6 +782,74 @@ func TestDeleteDocument(t *testing.T) {
 	}
 }
 
+// committedHooks records how many users each hook call could see from
+// outside the request's transaction, and fails every call.
+type committedHooks struct {
+	db    *database.DB
+	calls []string
+}
+
+func (c *committedHooks) record(ctx context.Context, action string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	var count int
+	if err := c.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		return err
+	}
+	c.calls = append(c.calls, fmt.Sprintf("%s:%d", action, count))
+	return errors.New("injected hook failure")
+}
+
+func (c *committedHooks) OnInsert(ctx context.Context, _ string, _ map[string]any) error {
+	return c.record(ctx, "insert")
+}
+
+func (c *committedHooks) OnUpdate(ctx context.Context, _ string, _, _ map[string]any) error {
+	return c.record(ctx, "update")
+}
+
+func (c *committedHooks) OnDelete(ctx context.Context, _ string, _ map[string]any) error {
+	return c.record(ctx, "delete")
+}
+
+func TestMutationsCommitBeforeHooks(t *testing.T) {
+	h, db := setupTestHandlers(t)
+	hooks := &committedHooks{db: db}
+	h.SetHookTrigger(hooks)
+
+	send := func(method, id, body string, handler func(http.ResponseWriter, *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/collections/users/"+id, bytes.NewBufferString(body))
+		req.SetPathValue("collection", "users")
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	w := send(http.MethodPost, "", `{"name":"Dana","email":"dana@example.com"}`, h.CreateDocument)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected a failing hook not to fail the committed create, got %d: %s", w.Code, w.Body.String())
+	}
+	var created map[string]any
+	json.Unmarshal(w.Body.Bytes(), &created)
+	id := created["id"].(string)
+
+	if w := send(http.MethodPost, "", `{"name":"Dup","email":"dana@example.com"}`, h.CreateDocument); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a duplicate email, got %d", w.Code)
+	}
+	if w := send(http.MethodPatch, id, `{"name":"Dana S."}`, h.UpdateDocument); w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodDelete, id, "", h.DeleteDocument); w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Each hook saw its write committed; the failed create fired none.
+	if fmt.Sprint(hooks.calls) != "[insert:1 update:1 delete:0]" {
+		t.Errorf("hook calls = %v", hooks.calls)
+	}
+}
+
 func TestGetDocumentNotFound(t *testing.T) {
 	h, _ := setupTestHandlers(t)
 
@@ -312,6 +865,24 @@ func TestGetDocumentNotFound(t *testing.T) {
 	}
 }
 
+func TestHeadDocumentNotFound(t *testing.T) {
+	h, _ := setupTestHandlers(t)
+
+	req := httptest.NewRequest(http.MethodHead, "/api/collections/users/nonexistent", nil)
+	req.SetPathValue("collection", "users")
+	req.SetPathValue("id", "nonexistent")
+	w := httptest.NewRecorder()
+
+	HeadHandler(h.GetDocument)(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected an empty body, got %q", w.Body.String())
+	}
+}
+
 func TestCollectionNotFound(t *testing.T) {
 	h, _ := setupTestHandlers(t)
 
@@ -341,6 +912,58 @@ func TestInvalidJSON(t *testing.T) {
 	}
 }
 
+func TestErrorFormatProblemJSON(t *testing.T) {
+	SetErrorFormat(config.ErrorFormatProblem)
+	t.Cleanup(func() { SetErrorFormat(config.ErrorFormatAlyx) })
+
+	h, _ := setupTestHandlers(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/collections/users/nonexistent", nil)
+	req.SetPathValue("collection", "users")
+	req.SetPathValue("id", "nonexistent")
+	w := httptest.NewRecorder()
+
+	h.GetDocument(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("expected application/problem+json, got %q", ct)
+	}
+
+	var problem ProblemDetails
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if problem.Type != "urn:alyx:error:document-not-found" {
+		t.Errorf("expected stable type URI, got %q", problem.Type)
+	}
+	if problem.Status != http.StatusNotFound || problem.Title != "Not Found" {
+		t.Errorf("unexpected status/title: %d %q", problem.Status, problem.Title)
+	}
+	if problem.Code != "DOCUMENT_NOT_FOUND" || problem.Detail == "" {
+		t.Errorf("expected code and detail extensions, got %+v", problem)
+	}
+}
+
+func TestErrorFormatDefault(t *testing.T) {
+	w := httptest.NewRecorder()
+	BadRequest(w, "bad input")
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if resp.Error != "bad input" || resp.Code != "BAD_REQUEST" {
+		t.Errorf("unexpected error body: %+v", resp)
+	}
+}
+
 func init() {
 	os.Setenv("TZ", "UTC")
 }
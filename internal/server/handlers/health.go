@@ -4,8 +4,10 @@ import (
 	"context"
 	"net/http"
 	"runtime"
+	"strings"
 	"time"
 
+	"github.com/watzon/alyx/internal/buildinfo"
 	"github.com/watzon/alyx/internal/database"
 	"github.com/watzon/alyx/internal/functions"
 	"github.com/watzon/alyx/internal/realtime"
@@ -16,6 +18,10 @@ type HealthHandlers struct {
 	broker      *realtime.Broker
 	funcService *functions.Service
 	version     string
+	features    []string
+
+	functionsEnabled bool
+	requireFunctions bool
 }
 
 func NewHealthHandlers(db *database.DB, broker *realtime.Broker, funcService *functions.Service, version string) *HealthHandlers {
@@ -27,6 +33,19 @@ func NewHealthHandlers(db *database.DB, broker *realtime.Broker, funcService *fu
 	}
 }
 
+// SetFunctionsEnabled tells the health check that functions are enabled, so
+// that a function service that failed to start is reported. With
+// requireReady, /health/ready fails while the functions are unhealthy.
+func (h *HealthHandlers) SetFunctionsEnabled(requireReady bool) {
+	h.functionsEnabled = true
+	h.requireFunctions = requireReady
+}
+
+// SetFeatures sets the enabled subsystems reported by GET /api/version.
+func (h *HealthHandlers) SetFeatures(features []string) {
+	h.features = features
+}
+
 type HealthStatus string
 
 const (
@@ -39,6 +58,18 @@ type ComponentHealth struct {
 	Status  HealthStatus `json:"status"`
 	Latency string       `json:"latency,omitempty"`
 	Message string       `json:"message,omitempty"`
+	Details any          `json:"details,omitempty"`
+}
+
+// FunctionsHealthDetails describes the functions component of the health
+// check.
+type FunctionsHealthDetails struct {
+	// Runtime is how functions are run.
+	Runtime string `json:"runtime"`
+	// Functions is how many functions are registered.
+	Functions int `json:"functions"`
+	// Runtimes are the language runtimes the functions need.
+	Runtimes []functions.RuntimeHealth `json:"runtimes"`
 }
 
 type HealthResponse struct {
@@ -74,8 +105,8 @@ func (h *HealthHandlers) Health(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if h.funcService != nil {
-		funcHealth := h.checkFunctions()
+	if h.funcService != nil || h.functionsEnabled {
+		funcHealth := h.checkFunctions(ctx)
 		components["functions"] = funcHealth
 		if funcHealth.Status != HealthStatusHealthy && overallStatus == HealthStatusHealthy {
 			overallStatus = HealthStatusDegraded
@@ -138,8 +169,17 @@ func (h *HealthHandlers) checkBroker() ComponentHealth {
 	}
 }
 
-func (h *HealthHandlers) checkFunctions() ComponentHealth {
+// checkFunctions runs the version command of each runtime the registered
+// functions need. The component is degraded when some of them fail and
+// unhealthy when all of them do.
+func (h *HealthHandlers) checkFunctions(ctx context.Context) ComponentHealth {
 	if h.funcService == nil {
+		if h.functionsEnabled {
+			return ComponentHealth{
+				Status:  HealthStatusUnhealthy,
+				Message: "function service failed to start",
+			}
+		}
 		return ComponentHealth{
 			Status:  HealthStatusHealthy,
 			Message: "disabled",
@@ -154,9 +194,35 @@ func (h *HealthHandlers) checkFunctions() ComponentHealth {
 		}
 	}
 
-	return ComponentHealth{
-		Status: HealthStatusHealthy,
+	start := time.Now()
+	runtimes := h.funcService.CheckRuntimes(ctx)
+	latency := time.Since(start)
+
+	var unavailable []string
+	for _, rt := range runtimes {
+		if !rt.Available() {
+			unavailable = append(unavailable, rt.Command)
+		}
 	}
+
+	health := ComponentHealth{
+		Status:  HealthStatusHealthy,
+		Latency: latency.String(),
+		Details: FunctionsHealthDetails{
+			Runtime:   "subprocess",
+			Functions: len(funcs),
+			Runtimes:  runtimes,
+		},
+	}
+	switch {
+	case len(unavailable) > 0 && len(unavailable) == len(runtimes):
+		health.Status = HealthStatusUnhealthy
+		health.Message = "no function runtime is available: " + strings.Join(unavailable, ", ")
+	case len(unavailable) > 0:
+		health.Status = HealthStatusDegraded
+		health.Message = "function runtimes unavailable: " + strings.Join(unavailable, ", ")
+	}
+	return health
 }
 
 func (h *HealthHandlers) Liveness(w http.ResponseWriter, r *http.Request) {
@@ -177,11 +243,24 @@ func (h *HealthHandlers) Readiness(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if h.requireFunctions && h.checkFunctions(ctx).Status == HealthStatusUnhealthy {
+		JSON(w, http.StatusServiceUnavailable, map[string]string{
+			"status": "not ready",
+			"reason": "functions unavailable",
+		})
+		return
+	}
+
 	JSON(w, http.StatusOK, map[string]string{
 		"status": "ready",
 	})
 }
 
+// Version reports the build information of the running server.
+func (h *HealthHandlers) Version(w http.ResponseWriter, r *http.Request) {
+	JSON(w, http.StatusOK, buildinfo.Get(h.features))
+}
+
 type RuntimeStats struct {
 	GoVersion    string `json:"go_version"`
 	NumGoroutine int    `json:"num_goroutine"`
@@ -211,12 +290,25 @@ func (h *HealthHandlers) Stats(w http.ResponseWriter, r *http.Request) {
 
 	if h.db != nil {
 		dbStats := h.db.Stats()
-		resp["database"] = map[string]any{
+		database := map[string]any{
 			"open_connections": dbStats.OpenConnections,
 			"in_use":           dbStats.InUse,
 			"idle":             dbStats.Idle,
 			"max_open":         dbStats.MaxOpenConnections,
 		}
+		if size, err := h.db.Size(r.Context()); err == nil {
+			database["size"] = size
+		}
+		if walSize, err := h.db.WALSize(); err == nil {
+			database["wal_size"] = walSize
+		}
+		if last := h.db.LastCheckpoint(); !last.IsZero() {
+			database["last_checkpoint"] = last.UTC().Format(time.RFC3339)
+		}
+		resp["database"] = database
+		if stmts := h.db.Stmts(); stmts != nil {
+			resp["stmt_cache"] = stmts.Stats()
+		}
 	}
 
 	if h.broker != nil {
@@ -224,6 +316,8 @@ func (h *HealthHandlers) Stats(w http.ResponseWriter, r *http.Request) {
 		resp["realtime"] = map[string]any{
 			"connections":   brokerStats.Connections,
 			"subscriptions": brokerStats.Subscriptions,
+			"mode":          brokerStats.Mode,
+			"evictions":     brokerStats.Evictions,
 		}
 	}
 
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// GetHistory handles GET /api/collections/{collection}/{id}/history, listing
+// the changes recorded for a document, newest first. The history rule is
+// evaluated with doc set to the document or, once it is deleted, to its
+// values before the delete.
+func (h *Handlers) GetHistory(w http.ResponseWriter, r *http.Request) {
+	collectionName := r.PathValue("collection")
+	id := r.PathValue("id")
+
+	col, err := h.getCollection(r, collectionName)
+	if err != nil {
+		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
+		return
+	}
+	if col.Schema().History == nil {
+		Error(w, http.StatusNotFound, "HISTORY_NOT_ENABLED", "Collection does not record history")
+		return
+	}
+
+	opts := &database.QueryOptions{}
+	defaultLimit, maxLimit := (*schema.ListConfig)(nil).Limits()
+	opts.Limit = defaultLimit
+	clampedFrom, err := parsePaginationOptions(r.URL.Query(), opts, maxLimit)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_QUERY", err.Error())
+		return
+	}
+	if clampedFrom > 0 {
+		w.Header().Set(LimitClampedHeader, strconv.Itoa(clampedFrom))
+	}
+
+	tenant, err := h.resolveTenant(r, col.Schema())
+	if err != nil {
+		tenantError(w, err)
+		return
+	}
+
+	doc, err := col.FindOne(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		doc, err = col.LastDeleted(r.Context(), id)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("collection", collectionName).Str("id", id).Msg("Failed to get document")
+		Error(w, http.StatusInternalServerError, "QUERY_ERROR", "Failed to get document")
+		return
+	}
+	if doc == nil || !tenant.owns(doc) {
+		Error(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
+		return
+	}
+
+	if err := h.checkAccess(r, collectionName, rules.OpHistory, tenant, doc); err != nil {
+		if errors.Is(err, rules.ErrAccessDenied) {
+			h.accessDenied(w, r, err)
+			return
+		}
+		log.Error().Err(err).Str("collection", collectionName).Msg("Rule evaluation failed")
+		InternalError(w, "Failed to check access")
+		return
+	}
+
+	result, err := col.History(r.Context(), id, opts)
+	if err != nil {
+		log.Error().Err(err).Str("collection", collectionName).Str("id", id).Msg("Failed to read history")
+		Error(w, http.StatusInternalServerError, "QUERY_ERROR", "Failed to read history")
+		return
+	}
+
+	h.redactHistory(r, collectionName, tenant, doc, result.Docs)
+
+	JSON(w, http.StatusOK, map[string]any{
+		"docs":   result.Docs,
+		"total":  result.Total,
+		"limit":  opts.Limit,
+		"offset": opts.Offset,
+	})
+}
+
+// redactHistory removes from the changes and snapshot of each history entry
+// the fields whose read rule hides them from the caller. The rules see doc,
+// the document the history rule was checked against.
+func (h *Handlers) redactHistory(r *http.Request, collection string, tenant *tenantScope, doc database.Row, entries []database.Row) {
+	if h.rules == nil || !h.rules.HasFieldRules(collection) {
+		return
+	}
+	hidden := h.rules.HiddenFields(collection, h.evalContext(r, tenant, doc))
+	if len(hidden) == 0 {
+		return
+	}
+	for _, entry := range entries {
+		for _, key := range []string{"changes", "snapshot"} {
+			values, ok := entry[key].(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, field := range hidden {
+				delete(values, field)
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const historySchemaYAML = `
+version: 1
+collections:
+  invoices:
+    history: true
+    fields:
+      id:
+        type: string
+        primary: true
+      amount:
+        type: int
+      note:
+        type: string
+        nullable: true
+    rules:
+      read: "true"
+      create: "true"
+      update: "true"
+      delete: "true"
+      history: "auth.role == 'admin'"
+  notes:
+    fields:
+      id:
+        type: string
+        primary: true
+    rules:
+      read: "true"
+`
+
+func setupHistoryHandlers(t *testing.T) *Handlers {
+	t.Helper()
+
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(historySchemaYAML))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatal(err)
+	}
+
+	return New(db, s, config.Default(), engine)
+}
+
+func historyRequest(method, collection, id, role string, body any) *http.Request {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, "/api/collections/"+collection, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("collection", collection)
+	if id != "" {
+		req.SetPathValue("id", id)
+	}
+	user := &auth.User{ID: "u-" + role, Email: role + "@example.com", Role: role}
+	return req.WithContext(auth.ContextWithUser(req.Context(), user))
+}
+
+func TestGetHistory(t *testing.T) {
+	h := setupHistoryHandlers(t)
+
+	writes := []struct {
+		method  string
+		id      string
+		body    any
+		handler func(http.ResponseWriter, *http.Request)
+		status  int
+	}{
+		{http.MethodPost, "", map[string]any{"id": "inv1", "amount": 100}, h.CreateDocument, http.StatusCreated},
+		{http.MethodPatch, "inv1", map[string]any{"amount": 150}, h.UpdateDocument, http.StatusOK},
+		{http.MethodPatch, "inv1", map[string]any{"amount": 150}, h.UpdateDocument, http.StatusOK},
+		{http.MethodDelete, "inv1", nil, h.DeleteDocument, http.StatusNoContent},
+	}
+	for _, wr := range writes {
+		w := httptest.NewRecorder()
+		wr.handler(w, historyRequest(wr.method, "invoices", wr.id, "user", wr.body))
+		if w.Code != wr.status {
+			t.Fatalf("%s: expected status %d, got %d: %s", wr.method, wr.status, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.GetHistory(w, historyRequest(http.MethodGet, "invoices", "inv1", "user", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a non-admin, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.GetHistory(w, historyRequest(http.MethodGet, "invoices", "inv1", "admin", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Docs []struct {
+			DocID   string                    `json:"doc_id"`
+			Action  string                    `json:"action"`
+			Actor   string                    `json:"actor"`
+			Changes map[string]map[string]any `json:"changes"`
+		} `json:"docs"`
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	// The no-op update is not recorded.
+	if resp.Total != 3 || len(resp.Docs) != 3 {
+		t.Fatalf("expected 3 entries, got %d: %s", resp.Total, w.Body.String())
+	}
+	actions := []string{resp.Docs[0].Action, resp.Docs[1].Action, resp.Docs[2].Action}
+	if actions[0] != "delete" || actions[1] != "update" || actions[2] != "create" {
+		t.Errorf("expected newest first, got %v", actions)
+	}
+	update := resp.Docs[1]
+	if update.DocID != "inv1" || update.Actor != "u-user" {
+		t.Errorf("unexpected update entry %+v", update)
+	}
+	if len(update.Changes) != 1 || update.Changes["amount"]["from"] != 100.0 || update.Changes["amount"]["to"] != 150.0 {
+		t.Errorf("expected amount 100 -> 150, got %v", update.Changes)
+	}
+
+	w = httptest.NewRecorder()
+	h.GetHistory(w, historyRequest(http.MethodGet, "invoices", "missing", "admin", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown document, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.GetHistory(w, historyRequest(http.MethodGet, "notes", "n1", "admin", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 without history, got %d", w.Code)
+	}
+}
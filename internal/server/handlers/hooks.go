@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/rs/zerolog/log"
@@ -75,8 +74,8 @@ func (h *HookHandlers) Create(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	var req CreateHookRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		BadRequest(w, "Invalid JSON body: "+err.Error())
+	if err := DecodeJSON(r, &req, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -132,8 +131,8 @@ func (h *HookHandlers) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req UpdateHookRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		BadRequest(w, "Invalid JSON body: "+err.Error())
+	if err := DecodeJSON(r, &req, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -63,8 +62,8 @@ func (h *InternalHandlers) Query(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req QueryRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if err := DecodeJSON(r, &req, DecodeOptions{}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -117,7 +116,7 @@ func (h *InternalHandlers) executeQuery(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	collection := database.NewCollection(h.db, col)
+	collection := database.NewCollection(h.dbFor(r), col)
 
 	opts := &database.QueryOptions{
 		Limit:  req.Limit,
@@ -141,11 +140,19 @@ func (h *InternalHandlers) executeQuery(w http.ResponseWriter, r *http.Request,
 	}
 
 	if req.Sort != "" {
-		field, order := database.ParseSortString(req.Sort)
-		opts.Sorts = append(opts.Sorts, &database.Sort{Field: field, Order: order})
+		sorts, err := database.ParseSorts(req.Sort)
+		if err != nil {
+			Error(w, http.StatusBadRequest, "INVALID_QUERY", err.Error())
+			return
+		}
+		opts.Sorts = sorts
 	}
 
 	result, err := collection.Find(r.Context(), opts)
+	if errors.Is(err, database.ErrInvalidSort) {
+		Error(w, http.StatusBadRequest, "INVALID_QUERY", err.Error())
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Str("collection", req.Collection).Msg("Internal query failed")
 		Error(w, http.StatusInternalServerError, "QUERY_ERROR", "Query failed")
@@ -165,8 +172,8 @@ func (h *InternalHandlers) Exec(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req ExecRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if err := DecodeJSON(r, &req, DecodeOptions{}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -181,7 +188,7 @@ func (h *InternalHandlers) Exec(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	collection := database.NewCollection(h.db, col)
+	collection := database.NewCollection(h.dbFor(r), col)
 
 	switch req.Operation {
 	case "insert":
@@ -256,8 +263,8 @@ func (h *InternalHandlers) Transaction(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req TransactionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+	if err := DecodeJSON(r, &req, DecodeOptions{}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -292,6 +299,19 @@ func (h *InternalHandlers) validateToken(r *http.Request) error {
 	return nil
 }
 
+// dbFor returns the database the request's internal token was generated
+// for, falling back to the primary database.
+func (h *InternalHandlers) dbFor(r *http.Request) *database.DB {
+	if h.tokenStore == nil {
+		return h.db
+	}
+	_, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if db := h.tokenStore.DB(token); db != nil {
+		return db
+	}
+	return h.db
+}
+
 // handleExecError handles errors from database exec operations.
 func (h *InternalHandlers) handleExecError(w http.ResponseWriter, collection, operation string, err error) {
 	if ce := database.AsConstraintError(err); ce != nil {
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -53,6 +54,7 @@ func (h *LogsHandlers) parseStringFilters(query map[string][]string, opts *reque
 	opts.Path = getQueryParam(query, "path")
 	opts.ExcludePathPrefix = getQueryParam(query, "exclude_path_prefix")
 	opts.UserID = getQueryParam(query, "user_id")
+	opts.ErrorCode = getQueryParam(query, "error_code")
 }
 
 func (h *LogsHandlers) parseStatusFilters(query map[string][]string, opts *requestlog.FilterOptions) {
@@ -99,6 +101,60 @@ func (h *LogsHandlers) Stats(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusOK, stats)
 }
 
+// Metrics handles GET /api/admin/logs/metrics.
+func (h *LogsHandlers) Metrics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := requestlog.MetricsOptions{
+		Window: time.Hour,
+		Bucket: time.Minute,
+	}
+
+	if v := query.Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			Error(w, http.StatusBadRequest, "INVALID_WINDOW", "window must be a positive duration, e.g. 1h")
+			return
+		}
+		opts.Window = d
+	}
+	if v := query.Get("bucket"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second {
+			Error(w, http.StatusBadRequest, "INVALID_BUCKET", "bucket must be a duration of at least 1s, e.g. 1m")
+			return
+		}
+		opts.Bucket = d
+	}
+	if opts.Bucket > opts.Window {
+		Error(w, http.StatusBadRequest, "INVALID_BUCKET", "bucket must not be longer than window")
+		return
+	}
+	if (opts.Window+opts.Bucket-1)/opts.Bucket > requestlog.MaxMetricsBuckets {
+		Error(w, http.StatusBadRequest, "INVALID_BUCKET",
+			fmt.Sprintf("window must not span more than %d buckets", requestlog.MaxMetricsBuckets))
+		return
+	}
+
+	switch v := query.Get("group_by"); v {
+	case "", requestlog.GroupByPath:
+		opts.GroupBy = v
+	default:
+		Error(w, http.StatusBadRequest, "INVALID_GROUP_BY", "group_by must be path")
+		return
+	}
+	if v := query.Get("max_groups"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > requestlog.MaxGroupsLimit {
+			Error(w, http.StatusBadRequest, "INVALID_MAX_GROUPS",
+				fmt.Sprintf("max_groups must be between 1 and %d", requestlog.MaxGroupsLimit))
+			return
+		}
+		opts.MaxGroups = n
+	}
+
+	JSON(w, http.StatusOK, h.store.Metrics(opts))
+}
+
 // Clear handles POST /api/admin/logs/clear.
 func (h *LogsHandlers) Clear(w http.ResponseWriter, r *http.Request) {
 	h.store.Clear()
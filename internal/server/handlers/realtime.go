@@ -37,6 +37,10 @@ func (h *RealtimeHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request
 	user := auth.UserFromContext(r.Context())
 	claims := auth.ClaimsFromContext(r.Context())
 	client.AuthContext = rules.BuildAuthContext(user, claims)
+	client.RemoteAddr = r.RemoteAddr
+	if user != nil {
+		client.UserID = user.ID
+	}
 
 	h.broker.RegisterClient(client)
 
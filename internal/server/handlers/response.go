@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/requestctx"
+	"github.com/watzon/alyx/internal/server/requestlog"
 )
 
 type ErrorResponse struct {
@@ -27,42 +32,106 @@ func JSON(w http.ResponseWriter, status int, data any) {
 	}
 }
 
-func errorResponse(r *http.Request, code, message string, details any) ErrorResponse {
-	resp := ErrorResponse{
-		Error:     message,
+// ProblemDetails is an RFC 7807 error body, used when server.error_format is
+// "problem+json". The Alyx code, details, and request ID are carried as
+// extension members.
+type ProblemDetails struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ProblemTypePrefix prefixes the stable problem type URI for each error code.
+const ProblemTypePrefix = "urn:alyx:error:"
+
+var problemFormat atomic.Bool
+
+// SetErrorFormat selects the body written by the error helpers: "alyx"
+// (ErrorResponse) or "problem+json" (ProblemDetails).
+func SetErrorFormat(format string) {
+	problemFormat.Store(format == config.ErrorFormatProblem)
+}
+
+// ProblemType returns the problem type URI for an error code, e.g.
+// NOT_FOUND -> urn:alyx:error:not-found.
+func ProblemType(code string) string {
+	if code == "" {
+		return "about:blank"
+	}
+	return ProblemTypePrefix + strings.ReplaceAll(strings.ToLower(code), "_", "-")
+}
+
+// writeError is the single formatter behind every error helper. r may be nil,
+// in which case no request ID or instance is included.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string, details any) {
+	var requestID string
+	if r != nil {
+		requestID = requestctx.RequestID(r.Context())
+	}
+	requestlog.RecordError(w, code, message, nil)
+
+	if !problemFormat.Load() {
+		JSON(w, status, ErrorResponse{
+			Error:     message,
+			Code:      code,
+			Details:   details,
+			RequestID: requestID,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	problem := ProblemDetails{
+		Type:      ProblemType(code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    message,
 		Code:      code,
 		Details:   details,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		RequestID: requestID,
 	}
 	if r != nil {
-		resp.RequestID = requestctx.RequestID(r.Context())
+		problem.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
-	return resp
 }
 
 func Error(w http.ResponseWriter, status int, code string, message string) {
-	JSON(w, status, ErrorResponse{
-		Error:     message,
-		Code:      code,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-	})
+	writeError(w, nil, status, code, message, nil)
 }
 
 func ErrorWithRequest(w http.ResponseWriter, r *http.Request, status int, code string, message string) {
-	JSON(w, status, errorResponse(r, code, message, nil))
+	writeError(w, r, status, code, message, nil)
 }
 
 func ErrorWithDetails(w http.ResponseWriter, status int, code string, message string, details any) {
-	JSON(w, status, ErrorResponse{
-		Error:     message,
-		Code:      code,
-		Details:   details,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-	})
+	writeError(w, nil, status, code, message, details)
 }
 
 func ErrorWithRequestAndDetails(w http.ResponseWriter, r *http.Request, status int, code string, message string, details any) {
-	JSON(w, status, errorResponse(r, code, message, details))
+	writeError(w, r, status, code, message, details)
+}
+
+// BodyTooLarge writes the 413 response for a request body over limit bytes.
+// setting names the config option the limit comes from, if known.
+func BodyTooLarge(w http.ResponseWriter, r *http.Request, limit int64, setting string) {
+	message := fmt.Sprintf("Request body exceeds the %d byte limit", limit)
+	details := map[string]any{"limit": limit}
+	if setting != "" {
+		message += " set by " + setting
+		details["setting"] = setting
+	}
+	ErrorWithRequestAndDetails(w, r, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE", message, details)
 }
 
 func NotFound(w http.ResponseWriter, message string) {
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
 	"time"
 
@@ -87,8 +87,8 @@ func (h *ScheduleHandlers) Create(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	var req CreateScheduleRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		BadRequest(w, "Invalid JSON body: "+err.Error())
+	if err := DecodeJSON(r, &req, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -111,18 +111,12 @@ func (h *ScheduleHandlers) Create(w http.ResponseWriter, r *http.Request) {
 	if req.Timezone == "" {
 		req.Timezone = "UTC"
 	}
-
-	now := time.Now().UTC()
-	nextRun, err := scheduler.CalculateNextRun(&scheduler.Schedule{
-		Type:       req.Type,
-		Expression: req.Expression,
-		Timezone:   req.Timezone,
-	}, now)
-	if err != nil {
-		BadRequest(w, "Invalid schedule expression: "+err.Error())
+	if err := req.Config.Validate(); err != nil {
+		BadRequest(w, "Invalid schedule config: "+err.Error())
 		return
 	}
 
+	now := time.Now().UTC()
 	schedule := &scheduler.Schedule{
 		ID:         uuid.New().String(),
 		Name:       req.Name,
@@ -130,14 +124,18 @@ func (h *ScheduleHandlers) Create(w http.ResponseWriter, r *http.Request) {
 		Type:       req.Type,
 		Expression: req.Expression,
 		Timezone:   req.Timezone,
-		NextRun:    &nextRun,
 		Enabled:    req.Enabled,
 		Config:     req.Config,
 		CreatedAt:  now,
 		UpdatedAt:  now,
 	}
 
-	if err := h.store.Create(ctx, schedule); err != nil {
+	if _, _, err := scheduler.NextFire(schedule, now); err != nil {
+		BadRequest(w, "Invalid schedule expression: "+err.Error())
+		return
+	}
+
+	if err := h.create(ctx, schedule); err != nil {
 		log.Error().Err(err).Msg("Failed to create schedule")
 		InternalError(w, "Failed to create schedule: "+err.Error())
 		return
@@ -164,8 +162,8 @@ func (h *ScheduleHandlers) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req UpdateScheduleRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		BadRequest(w, "Invalid JSON body: "+err.Error())
+	if err := DecodeJSON(r, &req, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -182,22 +180,24 @@ func (h *ScheduleHandlers) Update(w http.ResponseWriter, r *http.Request) {
 		schedule.Enabled = *req.Enabled
 	}
 	if req.Config != nil {
+		if err := req.Config.Validate(); err != nil {
+			BadRequest(w, "Invalid schedule config: "+err.Error())
+			return
+		}
 		schedule.Config = *req.Config
 	}
 
-	if req.Expression != nil || req.Timezone != nil {
-		now := time.Now().UTC()
-		nextRun, calcErr := scheduler.CalculateNextRun(schedule, now)
-		if calcErr != nil {
+	reschedule := req.Expression != nil || req.Timezone != nil || req.Config != nil
+	if reschedule {
+		if _, _, calcErr := scheduler.NextFire(schedule, time.Now().UTC()); calcErr != nil {
 			BadRequest(w, "Invalid schedule expression: "+calcErr.Error())
 			return
 		}
-		schedule.NextRun = &nextRun
 	}
 
 	schedule.UpdatedAt = time.Now().UTC()
 
-	if err := h.store.Update(ctx, schedule); err != nil {
+	if err := h.update(ctx, schedule, reschedule); err != nil {
 		log.Error().Err(err).Str("id", id).Msg("Failed to update schedule")
 		InternalError(w, "Failed to update schedule: "+err.Error())
 		return
@@ -206,6 +206,37 @@ func (h *ScheduleHandlers) Update(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusOK, schedule)
 }
 
+// create stores a new schedule, first due at its next fire time plus its
+// jitter. It goes through the scheduler when there is one, so that the
+// schedule's state is initialized.
+func (h *ScheduleHandlers) create(ctx context.Context, schedule *scheduler.Schedule) error {
+	if h.scheduler != nil {
+		return h.scheduler.Create(ctx, schedule)
+	}
+	_, nextRun, err := scheduler.NextFire(schedule, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	schedule.NextRun = &nextRun
+	return h.store.Create(ctx, schedule)
+}
+
+// update stores a changed schedule. When its timing changed, it moves to its
+// next fire time after now.
+func (h *ScheduleHandlers) update(ctx context.Context, schedule *scheduler.Schedule, reschedule bool) error {
+	switch {
+	case reschedule && h.scheduler != nil:
+		return h.scheduler.Reschedule(ctx, schedule)
+	case reschedule:
+		_, nextRun, err := scheduler.NextFire(schedule, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		schedule.NextRun = &nextRun
+	}
+	return h.store.Update(ctx, schedule)
+}
+
 // Delete handles DELETE /api/schedules/{id}.
 func (h *ScheduleHandlers) Delete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func TestSchemaRawGet_Source(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(dir, "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	authService := auth.NewService(db, &config.AuthConfig{
+		JWT:      config.JWTConfig{Secret: "testsecret12345678901234567890123456", Issuer: "test", AccessTTL: time.Minute, RefreshTTL: time.Hour},
+		Password: config.PasswordConfig{MinLength: 8},
+	})
+	_, tokens, err := authService.Register(context.Background(), auth.RegisterInput{Email: "admin@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	schemaPath := filepath.Join(dir, "schema.yaml")
+	h := NewAdminHandlers(nil, authService, db, nil, nil, config.Default(), schemaPath, "")
+
+	get := func() (int, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/schema/raw", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		w := httptest.NewRecorder()
+		h.SchemaRawGet(w, req)
+		var body map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, body
+	}
+
+	migrator := schema.NewMigrator(db.DB, "", "")
+	if err := migrator.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := get(); code != http.StatusNotFound {
+		t.Fatalf("expected status %d without a file or cached schema, got %d", http.StatusNotFound, code)
+	}
+
+	s, err := schema.Parse([]byte("version: 1\ncollections:\n  posts:\n    fields:\n      id:\n        type: uuid\n        primary: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.ApplySchema(s); err != nil {
+		t.Fatal(err)
+	}
+	code, body := get()
+	if code != http.StatusOK || body["source"] != "database" || !strings.Contains(body["content"].(string), "posts:") {
+		t.Errorf("expected the cached schema, got %d %v", code, body)
+	}
+
+	if err := os.WriteFile(schemaPath, []byte("version: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	code, body = get()
+	if code != http.StatusOK || body["source"] != "file" || body["content"] != "version: 1\n" {
+		t.Errorf("expected the schema file, got %d %v", code, body)
+	}
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/deploy"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// scheduledApplyPollInterval is how often the scheduler looks for applies
+// that are due.
+const scheduledApplyPollInterval = 15 * time.Second
+
+// scheduledApplyHistory is the number of scheduled applies listed.
+const scheduledApplyHistory = 20
+
+// ScheduleChangesRequest is the body of POST /api/admin/schema/changes/schedule.
+type ScheduleChangesRequest struct {
+	// RunAt is when to apply the pending changes, in RFC 3339.
+	RunAt string `json:"run_at"`
+	// Maintenance puts the server in maintenance mode while they are
+	// applied.
+	Maintenance bool `json:"maintenance"`
+}
+
+// SchemaScheduleChanges handles POST /api/admin/schema/changes/schedule. It
+// schedules the pending unsafe changes to be applied once, at run_at. The
+// changes are validated against the data now and again before they run.
+func (h *AdminHandlers) SchemaScheduleChanges(w http.ResponseWriter, r *http.Request) {
+	token, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.pendingStore == nil || h.migrator == nil || h.applySchedule == nil {
+		Error(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Migration services not initialized")
+		return
+	}
+
+	var req ScheduleChangesRequest
+	if err := DecodeJSON(r, &req, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	runAt, err := time.Parse(time.RFC3339, req.RunAt)
+	if err != nil {
+		BadRequest(w, "run_at must be an RFC 3339 time, e.g. 2026-01-02T03:00:00Z")
+		return
+	}
+	if !runAt.After(time.Now()) {
+		BadRequest(w, "run_at must be in the future")
+		return
+	}
+
+	pending, err := h.pendingStore.ListUnsafe()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list pending changes")
+		InternalError(w, "Failed to list pending changes")
+		return
+	}
+	if len(pending) == 0 {
+		Error(w, http.StatusBadRequest, "NO_PENDING_CHANGES", "There are no pending changes to schedule")
+		return
+	}
+	if validationErrors := h.migrator.ValidateUnsafeChanges(h.pendingStore.ToChanges(pending)); len(validationErrors) > 0 {
+		ErrorWithDetails(w, http.StatusConflict, "VALIDATION_FAILED", "Some changes cannot be applied", validationErrors)
+		return
+	}
+
+	apply, err := h.applySchedule.Schedule(runAt, req.Maintenance, token.Name)
+	if errors.Is(err, schema.ErrApplyAlreadyScheduled) {
+		Error(w, http.StatusConflict, "ALREADY_SCHEDULED", "A schema apply is already scheduled; cancel it first")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to schedule schema apply")
+		InternalError(w, "Failed to schedule schema apply")
+		return
+	}
+
+	log.Info().
+		Str("id", apply.ID).
+		Time("run_at", apply.RunAt).
+		Bool("maintenance", apply.Maintenance).
+		Int("changes", len(pending)).
+		Str("by", token.Name).
+		Msg("Scheduled schema apply")
+
+	JSON(w, http.StatusCreated, apply)
+}
+
+// SchemaScheduledChanges handles GET /api/admin/schema/changes/scheduled,
+// listing recent scheduled applies and their outcomes, newest first.
+func (h *AdminHandlers) SchemaScheduledChanges(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.requireAdminAuth(r, deploy.PermissionRead); err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.applySchedule == nil {
+		Error(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Migration services not initialized")
+		return
+	}
+
+	applies, err := h.applySchedule.List(scheduledApplyHistory)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list scheduled applies")
+		InternalError(w, "Failed to list scheduled applies")
+		return
+	}
+	if applies == nil {
+		applies = []*schema.ScheduledApply{}
+	}
+
+	JSON(w, http.StatusOK, map[string]any{"scheduled": applies})
+}
+
+// SchemaCancelScheduledChanges handles DELETE
+// /api/admin/schema/changes/scheduled/{id}. Only applies that have not
+// started can be cancelled.
+func (h *AdminHandlers) SchemaCancelScheduledChanges(w http.ResponseWriter, r *http.Request) {
+	token, err := h.requireAdminAuth(r, deploy.PermissionAdmin)
+	if err != nil {
+		adminAuthError(w, err)
+		return
+	}
+
+	if h.applySchedule == nil {
+		Error(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Migration services not initialized")
+		return
+	}
+
+	apply, err := h.applySchedule.Cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, schema.ErrScheduledApplyNotFound):
+		Error(w, http.StatusNotFound, "NOT_FOUND", "Scheduled apply not found")
+		return
+	case errors.Is(err, schema.ErrScheduledApplyStarted):
+		Error(w, http.StatusConflict, "ALREADY_STARTED", "Scheduled apply is "+apply.Status)
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to cancel scheduled apply")
+		InternalError(w, "Failed to cancel scheduled apply")
+		return
+	}
+
+	log.Info().Str("id", apply.ID).Str("by", token.Name).Msg("Cancelled scheduled schema apply")
+
+	JSON(w, http.StatusOK, apply)
+}
+
+// StartScheduledApplies starts running scheduled applies when they are due.
+// Applies left running by a previous process are marked failed first.
+func (h *AdminHandlers) StartScheduledApplies(ctx context.Context) {
+	if h.applySchedule == nil || h.pendingStore == nil || h.migrator == nil {
+		return
+	}
+	if n, err := h.applySchedule.FailInterrupted(); err != nil {
+		log.Error().Err(err).Msg("Failed to recover scheduled schema applies")
+	} else if n > 0 {
+		log.Warn().Int("count", n).Msg("Scheduled schema apply was interrupted by a restart")
+	}
+
+	h.scheduleStop = make(chan struct{})
+	h.scheduleWG.Add(1)
+
+	go func() {
+		defer h.scheduleWG.Done()
+
+		ticker := time.NewTicker(scheduledApplyPollInterval)
+		defer ticker.Stop()
+
+		for {
+			h.runDueApplies(time.Now())
+
+			select {
+			case <-h.scheduleStop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// StopScheduledApplies stops the scheduler and waits for a running apply
+// to finish.
+func (h *AdminHandlers) StopScheduledApplies() {
+	if h.scheduleStop == nil {
+		return
+	}
+	close(h.scheduleStop)
+	h.scheduleWG.Wait()
+	h.scheduleStop = nil
+}
+
+// runDueApplies runs the scheduled applies due at now.
+func (h *AdminHandlers) runDueApplies(now time.Time) {
+	due, err := h.applySchedule.Due(now)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list due schema applies")
+		return
+	}
+	for _, apply := range due {
+		h.runScheduledApply(apply)
+	}
+}
+
+// runScheduledApply applies the pending changes for a due apply and records
+// the outcome.
+func (h *AdminHandlers) runScheduledApply(apply *schema.ScheduledApply) {
+	started, err := h.applySchedule.Start(apply.ID)
+	if err != nil {
+		log.Error().Err(err).Str("id", apply.ID).Msg("Failed to start scheduled schema apply")
+		return
+	}
+	if !started {
+		return
+	}
+
+	logger := log.With().Str("id", apply.ID).Logger()
+	logger.Info().Bool("maintenance", apply.Maintenance).Msg("Running scheduled schema apply")
+
+	if apply.Maintenance && h.maintenance != nil {
+		h.maintenance(true)
+		defer h.maintenance(false)
+	}
+
+	applied, errs := h.applyScheduled()
+	if err := h.applySchedule.Finish(apply.ID, applied, errs); err != nil {
+		logger.Error().Err(err).Msg("Failed to record scheduled schema apply")
+	}
+	if len(errs) > 0 {
+		logger.Error().Strs("errors", errs).Msg("Scheduled schema apply failed")
+		return
+	}
+	logger.Info().Int("applied", applied).Msg("Scheduled schema apply finished")
+}
+
+// applyScheduled applies the pending changes as they are now, returning how
+// many were applied and any errors.
+func (h *AdminHandlers) applyScheduled() (int, []string) {
+	pending, err := h.pendingStore.ListUnsafe()
+	if err != nil {
+		return 0, []string{"listing pending changes: " + err.Error()}
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	changes := h.pendingStore.ToChanges(pending)
+	validationErrors, err := h.applyPendingChanges(changes)
+	if len(validationErrors) > 0 {
+		errs := make([]string, len(validationErrors))
+		for i, ve := range validationErrors {
+			errs[i] = ve.Path + ": " + ve.Message
+		}
+		return 0, errs
+	}
+	if err != nil {
+		return 0, []string{err.Error()}
+	}
+	return len(changes), nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func TestScheduledSchemaApply(t *testing.T) {
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(explainSchemaYAML))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	if _, err := db.ExecContext(context.Background(), "CREATE TABLE legacy (id TEXT PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+
+	authService := auth.NewService(db, &config.AuthConfig{
+		JWT:      config.JWTConfig{Secret: "testsecret12345678901234567890123456", Issuer: "test", AccessTTL: time.Minute, RefreshTTL: time.Hour},
+		Password: config.PasswordConfig{MinLength: 8},
+	})
+	_, tokens, err := authService.Register(context.Background(), auth.RegisterInput{Email: "admin@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	h := NewAdminHandlers(nil, authService, db, s, nil, config.Default(), "", "")
+	if err := h.migrator.Init(); err != nil {
+		t.Fatal(err)
+	}
+	var maintenance []bool
+	h.SetMaintenance(func(on bool) { maintenance = append(maintenance, on) })
+
+	request := func(method, id string, body any, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, "/api/admin/schema/changes/scheduled", bytes.NewReader(data))
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	runAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if w := request(http.MethodPost, "", map[string]any{"run_at": time.Now().Add(-time.Hour).Format(time.RFC3339)}, h.SchemaScheduleChanges); w.Code != http.StatusBadRequest {
+		t.Errorf("past run_at: expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(http.MethodPost, "", map[string]any{"run_at": runAt}, h.SchemaScheduleChanges); w.Code != http.StatusBadRequest {
+		t.Errorf("no pending changes: expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := h.pendingStore.Store([]*schema.Change{{Type: schema.ChangeDropCollection, Collection: "legacy", Description: "drop legacy"}}); err != nil {
+		t.Fatal(err)
+	}
+	w := request(http.MethodPost, "", map[string]any{"run_at": runAt, "maintenance": true}, h.SchemaScheduleChanges)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("schedule: expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var scheduled schema.ScheduledApply
+	json.Unmarshal(w.Body.Bytes(), &scheduled)
+	if scheduled.Status != schema.ScheduledApplyPending || !scheduled.Maintenance {
+		t.Errorf("schedule: unexpected %+v", scheduled)
+	}
+	if w := request(http.MethodPost, "", map[string]any{"run_at": runAt}, h.SchemaScheduleChanges); w.Code != http.StatusConflict {
+		t.Errorf("second schedule: expected status 409, got %d", w.Code)
+	}
+
+	// Nothing is due yet.
+	h.runDueApplies(time.Now())
+	if len(maintenance) != 0 {
+		t.Fatalf("apply ran early")
+	}
+
+	h.runDueApplies(time.Now().Add(2 * time.Hour))
+	if len(maintenance) != 2 || !maintenance[0] || maintenance[1] {
+		t.Errorf("expected maintenance on then off, got %v", maintenance)
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'legacy'").Scan(&count)
+	if count != 0 {
+		t.Error("expected the legacy table to be dropped")
+	}
+	if pending, _ := h.pendingStore.HasPending(); pending {
+		t.Error("expected pending changes to be cleared")
+	}
+
+	w = request(http.MethodGet, "", nil, h.SchemaScheduledChanges)
+	var list struct {
+		Scheduled []schema.ScheduledApply `json:"scheduled"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Scheduled) != 1 || list.Scheduled[0].Status != schema.ScheduledApplySucceeded || list.Scheduled[0].Applied != 1 {
+		t.Fatalf("list: unexpected %s", w.Body.String())
+	}
+
+	if w := request(http.MethodDelete, scheduled.ID, nil, h.SchemaCancelScheduledChanges); w.Code != http.StatusConflict {
+		t.Errorf("cancel finished: expected status 409, got %d", w.Code)
+	}
+	if w := request(http.MethodDelete, "missing", nil, h.SchemaCancelScheduledChanges); w.Code != http.StatusNotFound {
+		t.Errorf("cancel unknown: expected status 404, got %d", w.Code)
+	}
+}
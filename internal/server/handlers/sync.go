@@ -0,0 +1,725 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/changefeed"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/storage"
+)
+
+// Sync mutation operations.
+const (
+	SyncOpCreate = "create"
+	SyncOpUpdate = "update"
+	SyncOpDelete = "delete"
+)
+
+// maxSyncMutations is the largest batch a single push applies.
+const maxSyncMutations = 500
+
+// errSyncConflict is returned inside the push transaction when a document
+// changed after its mutation was checked.
+var errSyncConflict = errors.New("document changed during sync")
+
+// SyncChange is a document in a sync pull, or the tombstone of a deleted one.
+type SyncChange struct {
+	ID      string `json:"id"`
+	Deleted bool   `json:"deleted,omitempty"`
+	// Rev identifies the stored document, and is the base_rev of a later
+	// mutation of it.
+	Rev      string       `json:"rev,omitempty"`
+	Document database.Row `json:"document,omitempty"`
+}
+
+// SyncPullResponse is the body of a sync pull.
+type SyncPullResponse struct {
+	Changes []*SyncChange `json:"changes"`
+	// Cursor is the since of the next pull.
+	Cursor string `json:"cursor"`
+	// HasMore reports whether the next pull already has changes.
+	HasMore bool `json:"has_more"`
+}
+
+// SyncMutation is a change made by a client while it was offline.
+type SyncMutation struct {
+	Op string `json:"op"`
+	// ID is the document's primary key, generated by the client for creates.
+	ID string `json:"id"`
+	// BaseRev is the rev of the document the client changed. Updates and
+	// deletes require it.
+	BaseRev string       `json:"base_rev,omitempty"`
+	Data    database.Row `json:"data,omitempty"`
+}
+
+// SyncPushRequest is the body of a sync push.
+type SyncPushRequest struct {
+	Mutations []*SyncMutation `json:"mutations"`
+}
+
+// SyncResult is the outcome of a mutation. A conflict carries the server's
+// document, or Deleted if there is none, for the client to resolve.
+type SyncResult struct {
+	SyncChange
+	Conflict bool `json:"conflict,omitempty"`
+}
+
+// SyncPushResponse is the body of a sync push, with a result per mutation in
+// request order.
+type SyncPushResponse struct {
+	Results []*SyncResult `json:"results"`
+}
+
+// SetChangeFeed sets the change feed sync pulls read from. Without one, the
+// sync endpoints are unavailable.
+func (h *Handlers) SetChangeFeed(feed *changefeed.Feed) {
+	h.changeFeed = feed
+}
+
+// documentRevision identifies the stored state of doc, before field read
+// rules or expansion apply.
+func documentRevision(doc database.Row) string {
+	data, _ := json.Marshal(doc)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:etagLength]
+}
+
+// syncCursor is a position in a collection's sync stream. While the initial
+// snapshot is being paged through, after is the primary key of the last
+// document returned and seq the change the snapshot started at; afterwards
+// seq is the last change returned.
+type syncCursor struct {
+	seq      int64
+	after    string
+	snapshot bool
+}
+
+// parseSyncCursor parses since. An empty since starts a snapshot.
+func parseSyncCursor(since string) (syncCursor, error) {
+	if since == "" {
+		return syncCursor{seq: -1, snapshot: true}, nil
+	}
+	seq, after, snapshot := strings.Cut(since, ":")
+	n, err := strconv.ParseInt(seq, 10, 64)
+	if err != nil || n < 0 || (snapshot && after == "") {
+		return syncCursor{}, errors.New("invalid since cursor")
+	}
+	return syncCursor{seq: n, after: after, snapshot: snapshot}, nil
+}
+
+func (c syncCursor) String() string {
+	if c.snapshot {
+		return strconv.FormatInt(c.seq, 10) + ":" + c.after
+	}
+	return strconv.FormatInt(c.seq, 10)
+}
+
+// readable reports whether the read rule lets the request see doc.
+func (h *Handlers) readable(r *http.Request, collection string, tenant *tenantScope, doc database.Row) (bool, error) {
+	if !tenant.owns(doc) {
+		return false, nil
+	}
+	err := h.checkAccess(r, collection, rules.OpRead, tenant, doc)
+	if errors.Is(err, rules.ErrAccessDenied) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// syncCollection resolves the collection and tenant of a sync request,
+// writing the error response and returning false if it cannot be served.
+func (h *Handlers) syncCollection(w http.ResponseWriter, r *http.Request) (*database.Collection, *tenantScope, bool) {
+	col, err := h.getCollection(r, r.PathValue("collection"))
+	if err != nil {
+		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
+		return nil, nil, false
+	}
+	if h.changeFeed == nil {
+		Error(w, http.StatusServiceUnavailable, "SYNC_UNAVAILABLE", "Sync requires the change feed; set changes.enabled")
+		return nil, nil, false
+	}
+	if col.Schema().PrimaryKeyField() == nil {
+		Error(w, http.StatusBadRequest, "SYNC_UNSUPPORTED", "Collection has no primary key")
+		return nil, nil, false
+	}
+	tenant, err := h.resolveTenant(r, col.Schema())
+	if err != nil {
+		tenantError(w, err)
+		return nil, nil, false
+	}
+	return col, tenant, true
+}
+
+// SyncPull handles GET /api/sync/{collection}?since=<cursor>, returning the
+// documents that changed since the cursor and tombstones for those deleted.
+// Without since it pages through a snapshot of every readable document;
+// pulling on with the cursors it returns then continues with the changes
+// made since the snapshot began. A cursor older than the change feed's
+// retention gets 410 RESYNC_REQUIRED, after which the client discards its
+// copy and starts a new snapshot.
+//
+// Documents the read rule hides are left out. A tombstone is left out only
+// if the collection's history shows the caller could not read the document
+// before it was deleted.
+func (h *Handlers) SyncPull(w http.ResponseWriter, r *http.Request) {
+	col, tenant, ok := h.syncCollection(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, err := parseSyncCursor(r.URL.Query().Get("since"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_CURSOR", err.Error())
+		return
+	}
+
+	limit := changefeed.DefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > changefeed.MaxLimit {
+			Error(w, http.StatusBadRequest, "INVALID_LIMIT", fmt.Sprintf("limit must be between 1 and %d", changefeed.MaxLimit))
+			return
+		}
+	}
+
+	var resp *SyncPullResponse
+	if cursor.snapshot {
+		resp, err = h.pullSnapshot(r, col, tenant, cursor, limit)
+	} else {
+		resp, err = h.pullChanges(r, col, tenant, cursor, limit)
+	}
+	if errors.Is(err, changefeed.ErrCursorExpired) {
+		Error(w, http.StatusGone, "RESYNC_REQUIRED", "Changes after the cursor are no longer retained; pull without since to resync")
+		return
+	}
+	if errors.Is(err, rules.ErrAccessDenied) {
+		h.accessDenied(w, r, err)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("collection", col.Name()).Msg("Failed to pull changes")
+		Error(w, http.StatusInternalServerError, "SYNC_ERROR", "Failed to read changes")
+		return
+	}
+
+	JSON(w, http.StatusOK, resp)
+}
+
+// pullSnapshot returns the next page of readable documents in primary key
+// order.
+func (h *Handlers) pullSnapshot(r *http.Request, col *database.Collection, tenant *tenantScope, cursor syncCursor, limit int) (*SyncPullResponse, error) {
+	if cursor.seq < 0 {
+		head, err := h.changeFeed.Head(r.Context())
+		if err != nil {
+			return nil, err
+		}
+		cursor.seq = head
+	}
+
+	pk := col.Schema().PrimaryKeyField()
+	opts := &database.QueryOptions{
+		Limit: limit + 1,
+		Sorts: []*database.Sort{{Field: pk.Name, Order: database.SortAsc}},
+		Total: database.TotalNone,
+	}
+	if cursor.after != "" {
+		opts.Filters = append(opts.Filters, &database.Filter{Field: pk.Name, Op: database.OpGt, Value: cursor.after})
+	}
+	if filter := tenant.filter(); filter != nil {
+		opts.Filters = append(opts.Filters, filter)
+	}
+
+	result, _, err := h.findReadable(r, col.Schema(), tenant, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := result.Docs
+	resp := &SyncPullResponse{}
+	if len(docs) > limit {
+		docs = docs[:limit]
+		resp.HasMore = true
+		cursor.after = fmt.Sprint(docs[len(docs)-1][pk.Name])
+	} else {
+		cursor.after = ""
+		cursor.snapshot = false
+	}
+	resp.Changes = h.syncChanges(r, col, tenant, docs)
+	resp.Cursor = cursor.String()
+	return resp, nil
+}
+
+// pullChanges returns the documents changed after the cursor, each once in
+// the order of its latest change.
+func (h *Handlers) pullChanges(r *http.Request, col *database.Collection, tenant *tenantScope, cursor syncCursor, limit int) (*SyncPullResponse, error) {
+	batch, err := h.changeFeed.ReadCollection(r.Context(), col.Name(), cursor.seq, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	seen := make(map[string]bool, len(batch.Changes))
+	for _, change := range slices.Backward(batch.Changes) {
+		if !seen[change.DocID] {
+			seen[change.DocID] = true
+			ids = append(ids, change.DocID)
+		}
+	}
+	slices.Reverse(ids)
+
+	changes := make([]*SyncChange, 0, len(ids))
+	var docs []database.Row
+	for _, id := range ids {
+		doc, err := col.FindOne(r.Context(), id)
+		if errors.Is(err, database.ErrNotFound) {
+			deleted, err := col.LastDeleted(r.Context(), id)
+			if err != nil {
+				return nil, err
+			}
+			if deleted != nil {
+				if ok, err := h.readable(r, col.Name(), tenant, deleted); err != nil || !ok {
+					if err != nil {
+						return nil, err
+					}
+					continue
+				}
+			}
+			changes = append(changes, &SyncChange{ID: id, Deleted: true})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := h.readable(r, col.Name(), tenant, doc); err != nil || !ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		docs = append(docs, doc)
+		changes = append(changes, nil)
+	}
+
+	// Fill in the documents in order, with their revisions taken before
+	// field read rules apply.
+	synced := h.syncChanges(r, col, tenant, docs)
+	for i := range changes {
+		if changes[i] == nil {
+			changes[i], synced = synced[0], synced[1:]
+		}
+	}
+
+	return &SyncPullResponse{
+		Changes: changes,
+		Cursor:  syncCursor{seq: batch.NextSeq}.String(),
+		HasMore: batch.HasMore,
+	}, nil
+}
+
+// syncChanges returns docs as sync changes, with their revisions and with
+// the fields the caller may not read removed.
+func (h *Handlers) syncChanges(r *http.Request, col *database.Collection, tenant *tenantScope, docs []database.Row) []*SyncChange {
+	pk := col.Schema().PrimaryKeyField()
+	changes := make([]*SyncChange, len(docs))
+	for i, doc := range docs {
+		changes[i] = &SyncChange{ID: fmt.Sprint(doc[pk.Name]), Rev: documentRevision(doc), Document: doc}
+	}
+	h.redactFields(r, col.Name(), tenant, docs...)
+	return changes
+}
+
+// syncFailure is the error response for a mutation that cannot be applied,
+// which fails the whole push.
+type syncFailure struct {
+	status  int
+	code    string
+	message string
+	details map[string]any
+}
+
+func (f *syncFailure) Error() string {
+	return f.message
+}
+
+func invalidMutation(format string, args ...any) *syncFailure {
+	return &syncFailure{status: http.StatusBadRequest, code: "INVALID_MUTATION", message: fmt.Sprintf(format, args...)}
+}
+
+// syncWrite is a mutation that passed its checks, to be applied in the push
+// transaction.
+type syncWrite struct {
+	index    int
+	mutation *SyncMutation
+	// existing is the document the checks ran against, nil for a create,
+	// and rev its revision.
+	existing database.Row
+	rev      string
+}
+
+// SyncPush handles POST /api/sync/{collection}, applying a batch of offline
+// mutations in a single transaction. A mutation whose base_rev is no longer
+// the document's revision, or that creates a document that already exists,
+// is not applied: its result carries the server's document and conflict:
+// true. Any other failure, such as a rule denial or a validation error,
+// fails the whole batch with the index of the mutation in its details.
+//
+// Each mutation is checked against the create, update or delete rule before
+// the transaction, as the REST endpoints do, and the documents are looked up
+// again inside it; if one changed in between, the push fails with a conflict
+// and can be retried.
+//
+//nolint:gocyclo // Checks and applies three kinds of mutation
+func (h *Handlers) SyncPush(w http.ResponseWriter, r *http.Request) {
+	col, tenant, ok := h.syncCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var req SyncPushRequest
+	if err := DecodeJSON(r, &req, DecodeOptions{}); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	if len(req.Mutations) > maxSyncMutations {
+		Error(w, http.StatusBadRequest, "TOO_MANY_MUTATIONS", fmt.Sprintf("A push may contain at most %d mutations", maxSyncMutations))
+		return
+	}
+
+	results := make([]*SyncResult, len(req.Mutations))
+	var writes []*syncWrite
+	seen := make(map[string]bool, len(req.Mutations))
+	for i, m := range req.Mutations {
+		var result *SyncResult
+		var write *syncWrite
+		var err error = invalidMutation("mutation is required")
+		if m != nil {
+			if seen[m.ID] {
+				err = invalidMutation("document %q appears in more than one mutation", m.ID)
+			} else {
+				seen[m.ID] = true
+				result, write, err = h.planSyncMutation(r, col, tenant, m)
+			}
+		}
+
+		var failure *syncFailure
+		if errors.As(err, &failure) {
+			details := map[string]any{"mutation": i}
+			maps.Copy(details, failure.details)
+			ErrorWithDetails(w, failure.status, failure.code, fmt.Sprintf("Mutation %d: %s", i, failure.message), details)
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Str("collection", col.Name()).Int("mutation", i).Msg("Failed to check sync mutation")
+			Error(w, http.StatusInternalServerError, "SYNC_ERROR", "Failed to apply mutations")
+			return
+		}
+		if write != nil {
+			write.index = i
+			writes = append(writes, write)
+		}
+		results[i] = result
+	}
+
+	docs := make([]database.Row, len(req.Mutations))
+	failed := -1
+	err := h.dbFor(r.Context()).RunInTransaction(r.Context(), func(ctx context.Context) error {
+		for _, write := range writes {
+			doc, err := h.applySyncWrite(ctx, col, tenant, write)
+			if err != nil {
+				failed = write.index
+				return err
+			}
+			docs[write.index] = doc
+		}
+		return nil
+	})
+	if errors.Is(err, errSyncConflict) || errors.Is(err, database.ErrNotFound) {
+		Error(w, http.StatusConflict, "SYNC_CONFLICT", "Documents changed during sync, retry the request")
+		return
+	}
+	if err != nil {
+		if ce := database.AsConstraintError(err); ce != nil {
+			ErrorWithDetails(w, http.StatusBadRequest, constraintErrorCode(ce), fmt.Sprintf("Mutation %d: %s", failed, ce.Message), map[string]any{"mutation": failed})
+			return
+		}
+		log.Error().Err(err).Str("collection", col.Name()).Msg("Failed to apply sync mutations")
+		Error(w, http.StatusInternalServerError, "SYNC_ERROR", "Failed to apply mutations")
+		return
+	}
+
+	for _, write := range writes {
+		id := write.mutation.ID
+		if doc := docs[write.index]; doc != nil {
+			results[write.index] = &SyncResult{SyncChange: *h.syncChanges(r, col, tenant, []database.Row{doc})[0]}
+		} else {
+			results[write.index] = &SyncResult{SyncChange: SyncChange{ID: id, Deleted: true}}
+		}
+	}
+
+	JSON(w, http.StatusOK, SyncPushResponse{Results: results})
+}
+
+// planSyncMutation checks m against the current document. It returns the
+// result of a mutation that needs no write, such as a conflict, or the write
+// to apply.
+func (h *Handlers) planSyncMutation(r *http.Request, col *database.Collection, tenant *tenantScope, m *SyncMutation) (*SyncResult, *syncWrite, error) {
+	if m.ID == "" {
+		return nil, nil, invalidMutation("id is required")
+	}
+	pk := col.Schema().PrimaryKeyField()
+	if v, ok := m.Data[pk.Name]; ok && v != nil && fmt.Sprint(v) != m.ID {
+		return nil, nil, invalidMutation("data.%s does not match id", pk.Name)
+	}
+
+	existing, err := col.FindOne(r.Context(), m.ID)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !tenant.owns(existing)) {
+		existing, err = nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var rev string
+	if existing != nil {
+		rev = documentRevision(existing)
+	}
+
+	switch m.Op {
+	case SyncOpCreate:
+		if existing != nil {
+			result, err := h.syncConflict(r, col, tenant, existing)
+			return result, nil, err
+		}
+		if m.Data == nil {
+			m.Data = database.Row{}
+		}
+		m.Data[pk.Name] = m.ID
+		if err := checkDocumentFields(col.Schema(), m.Data); err != nil {
+			return nil, nil, unknownFieldsFailure(err)
+		}
+		if err := tenant.assign(m.Data); err != nil {
+			return nil, nil, tenantFailure(err)
+		}
+		if err := h.checkMutationAccess(r, col.Name(), rules.OpCreate, tenant, m.Data); err != nil {
+			return nil, nil, err
+		}
+		if verrs := database.ValidateInput(col.Schema(), m.Data, true); verrs.HasErrors() {
+			return nil, nil, validationFailure(http.StatusBadRequest, verrs)
+		}
+		if err := col.Schema().RunChecks(m.Data, nil); err != nil {
+			return nil, nil, checkFailure(err)
+		}
+
+	case SyncOpUpdate, SyncOpDelete:
+		if m.BaseRev == "" {
+			return nil, nil, invalidMutation("base_rev is required to %s a document", m.Op)
+		}
+		if existing == nil {
+			deleted := &SyncResult{SyncChange: SyncChange{ID: m.ID, Deleted: true}, Conflict: m.Op == SyncOpUpdate}
+			return deleted, nil, nil
+		}
+		if rev != m.BaseRev {
+			result, err := h.syncConflict(r, col, tenant, existing)
+			return result, nil, err
+		}
+		if m.Op == SyncOpDelete {
+			if err := h.checkMutationAccess(r, col.Name(), rules.OpDelete, tenant, existing); err != nil {
+				return nil, nil, err
+			}
+			return nil, &syncWrite{mutation: m, existing: existing, rev: rev}, nil
+		}
+
+		if err := h.checkMutationAccess(r, col.Name(), rules.OpUpdate, tenant, existing); err != nil {
+			return nil, nil, err
+		}
+		if m.Data == nil {
+			m.Data = database.Row{}
+		}
+		if err := checkDocumentFields(col.Schema(), m.Data, schema.RegenerateSlugKey); err != nil {
+			return nil, nil, unknownFieldsFailure(err)
+		}
+		if err := tenant.checkWrite(m.Data); err != nil {
+			return nil, nil, tenantFailure(err)
+		}
+		if verrs := database.ValidateNulls(col.Schema(), m.Data); verrs.HasErrors() {
+			return nil, nil, validationFailure(http.StatusUnprocessableEntity, verrs)
+		}
+		if verrs := database.ValidateInput(col.Schema(), m.Data, false); verrs.HasErrors() {
+			return nil, nil, validationFailure(http.StatusBadRequest, verrs)
+		}
+		merged := make(database.Row, len(existing)+len(m.Data))
+		maps.Copy(merged, existing)
+		maps.Copy(merged, m.Data)
+		if err := col.Schema().RunChecks(merged, existing); err != nil {
+			return nil, nil, checkFailure(err)
+		}
+
+	default:
+		return nil, nil, invalidMutation("op must be one of: %s, %s, %s", SyncOpCreate, SyncOpUpdate, SyncOpDelete)
+	}
+
+	if err := h.validateFileFields(r.Context(), col.Schema(), m.Data); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, nil, &syncFailure{status: http.StatusBadRequest, code: "FILE_NOT_FOUND", message: "Referenced file does not exist"}
+		case errors.Is(err, errFileWrongBucket):
+			return nil, nil, &syncFailure{status: http.StatusBadRequest, code: "FILE_WRONG_BUCKET", message: "File belongs to wrong bucket"}
+		}
+		return nil, nil, err
+	}
+
+	return nil, &syncWrite{mutation: m, existing: existing, rev: rev}, nil
+}
+
+// syncConflict returns the conflict result for a mutation of existing. The
+// document is left out if the caller may not read it.
+func (h *Handlers) syncConflict(r *http.Request, col *database.Collection, tenant *tenantScope, existing database.Row) (*SyncResult, error) {
+	ok, err := h.readable(r, col.Name(), tenant, existing)
+	if err != nil {
+		return nil, err
+	}
+	change := h.syncChanges(r, col, tenant, []database.Row{existing})[0]
+	if !ok {
+		change.Document = nil
+	}
+	return &SyncResult{SyncChange: *change, Conflict: true}, nil
+}
+
+// checkMutationAccess evaluates the rule for op, turning a denial into a
+// failure of the mutation.
+func (h *Handlers) checkMutationAccess(r *http.Request, collection string, op rules.Operation, tenant *tenantScope, doc database.Row) error {
+	err := h.checkAccess(r, collection, op, tenant, doc)
+	if errors.Is(err, rules.ErrAccessDenied) {
+		return &syncFailure{status: http.StatusForbidden, code: "FORBIDDEN", message: "Access denied"}
+	}
+	return err
+}
+
+func tenantFailure(err error) *syncFailure {
+	code, message := tenantErrorCode(err)
+	return &syncFailure{status: http.StatusForbidden, code: code, message: message}
+}
+
+func validationFailure(status int, verrs *database.ValidationErrors) *syncFailure {
+	return &syncFailure{
+		status:  status,
+		code:    "VALIDATION_ERROR",
+		message: verrs.Errors[0].Message,
+		details: map[string]any{"errors": verrs.Errors},
+	}
+}
+
+// unknownFieldsFailure is the failure for a mutation whose data has keys
+// that are not fields of the collection, as invalidJSON writes it.
+func unknownFieldsFailure(err error) error {
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) {
+		return err
+	}
+	return &syncFailure{
+		status:  http.StatusBadRequest,
+		code:    "UNKNOWN_FIELDS",
+		message: jsonErr.Message,
+		details: map[string]any{"fields": jsonErr.UnknownFields},
+	}
+}
+
+// checkFailure is the failure for a collection check, as checkFailed writes
+// it.
+func checkFailure(err error) error {
+	var ce *schema.CheckError
+	if !errors.As(err, &ce) {
+		return err
+	}
+	details := map[string]any{"check": ce.Check.Name}
+	if ce.Err != nil {
+		details["error"] = ce.Err.Error()
+	}
+	return &syncFailure{status: http.StatusUnprocessableEntity, code: "CHECK_FAILED", message: ce.Check.FailureMessage(), details: details}
+}
+
+// applySyncWrite applies a checked mutation in the push transaction and
+// returns the written document, or nil for a delete.
+func (h *Handlers) applySyncWrite(ctx context.Context, col *database.Collection, tenant *tenantScope, write *syncWrite) (database.Row, error) {
+	m := write.mutation
+	current, err := col.FindOne(ctx, m.ID)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !tenant.owns(current)) {
+		current, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if (current == nil) != (write.existing == nil) || (current != nil && documentRevision(current) != write.rev) {
+		return nil, errSyncConflict
+	}
+
+	switch m.Op {
+	case SyncOpCreate:
+		return col.Create(ctx, m.Data)
+
+	case SyncOpDelete:
+		blobs, err := h.bucketBlobs(ctx, col.Schema(), m.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := col.Delete(ctx, m.ID); err != nil {
+			return nil, err
+		}
+		for _, info := range blobs {
+			database.AfterCommit(ctx, func(ctx context.Context) {
+				h.deleteBlobObject(ctx, info)
+			})
+		}
+		database.AfterCommit(ctx, func(ctx context.Context) {
+			if err := h.deleteFileFieldsOnCascade(ctx, col.Schema(), write.existing); err != nil {
+				log.Error().Err(err).Str("collection", col.Name()).Msg("Failed to delete cascade files")
+			}
+		})
+		return nil, nil
+
+	default:
+		var clearedBlobs map[string]bool
+		for name, value := range m.Data {
+			if field, ok := col.Schema().Fields[name]; ok && field.Storage != "" && value == nil {
+				if clearedBlobs == nil {
+					clearedBlobs = map[string]bool{}
+				}
+				clearedBlobs[name] = true
+			}
+		}
+		var blobs []*database.BlobInfo
+		if clearedBlobs != nil {
+			if blobs, err = h.bucketBlobs(ctx, col.Schema(), m.ID, clearedBlobs); err != nil {
+				return nil, err
+			}
+		}
+		doc, err := col.Update(ctx, m.ID, m.Data)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range blobs {
+			database.AfterCommit(ctx, func(ctx context.Context) {
+				h.deleteBlobObject(ctx, info)
+			})
+		}
+		database.AfterCommit(ctx, func(ctx context.Context) {
+			if err := h.handleFileFieldUpdates(ctx, col.Schema(), write.existing, m.Data); err != nil {
+				log.Error().Err(err).Str("collection", col.Name()).Msg("Failed to handle file field updates")
+			}
+		})
+		return doc, nil
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+var (
+	errTenantRequired = errors.New("no tenant in auth context")
+	errTenantMismatch = errors.New("document belongs to a different tenant")
+	errTenantOverride = errors.New("tenant override requires admin")
+)
+
+// tenantAll is the ?tenant= value with which an admin reaches the documents
+// of every tenant.
+const tenantAll = "*"
+
+// tenantScope is the tenant a request to a tenant-scoped collection acts
+// within. A nil scope means the collection is not tenant-scoped.
+type tenantScope struct {
+	config *schema.TenantConfig
+	value  any
+	// all is set when an admin passed ?tenant=*.
+	all bool
+}
+
+// resolveTenant returns the tenant for a request to col, read from the auth
+// context at the collection's tenant source. Admins may instead name a
+// tenant, or * for all tenants, with the tenant query parameter.
+func (h *Handlers) resolveTenant(r *http.Request, col *schema.Collection) (*tenantScope, error) {
+	if col.Tenant == nil {
+		return nil, nil
+	}
+
+	scope := &tenantScope{config: col.Tenant}
+	authCtx := rules.BuildAuthContext(auth.UserFromContext(r.Context()), auth.ClaimsFromContext(r.Context()))
+
+	if query := r.URL.Query(); query.Has("tenant") {
+		if authCtx["role"] != schema.RoleAdmin {
+			return nil, errTenantOverride
+		}
+		switch override := query.Get("tenant"); override {
+		case tenantAll:
+			scope.all = true
+		case "":
+			return nil, errTenantRequired
+		default:
+			scope.value = override
+		}
+		return scope, nil
+	}
+
+	value, ok := col.Tenant.Resolve(authCtx)
+	if !ok {
+		return nil, errTenantRequired
+	}
+	scope.value = value
+	return scope, nil
+}
+
+// ruleValue is the tenant exposed to rules, or nil.
+func (s *tenantScope) ruleValue() any {
+	if s == nil || s.all {
+		return nil
+	}
+	return s.value
+}
+
+// filter restricts a list query to the tenant's documents.
+func (s *tenantScope) filter() *database.Filter {
+	if s == nil || s.all {
+		return nil
+	}
+	return &database.Filter{Field: s.config.Field, Op: database.OpEq, Value: s.value}
+}
+
+// owns reports whether doc is visible within the scope.
+func (s *tenantScope) owns(doc database.Row) bool {
+	if s == nil || s.all {
+		return true
+	}
+	return s.config.Owns(doc, s.value)
+}
+
+// assign sets the tenant field of a document being created, rejecting a
+// client-supplied value for another tenant.
+func (s *tenantScope) assign(data database.Row) error {
+	if s == nil || s.all {
+		return nil
+	}
+	if err := s.checkWrite(data); err != nil {
+		return err
+	}
+	data[s.config.Field] = s.value
+	return nil
+}
+
+// checkWrite rejects a write that would move a document to another tenant.
+func (s *tenantScope) checkWrite(data database.Row) error {
+	if s == nil || s.all {
+		return nil
+	}
+	if value, ok := data[s.config.Field]; ok && value != nil && value != "" && fmt.Sprint(value) != fmt.Sprint(s.value) {
+		return errTenantMismatch
+	}
+	return nil
+}
+
+// tenantError writes the response for a resolveTenant or assign error.
+func tenantError(w http.ResponseWriter, err error) {
+	code, message := tenantErrorCode(err)
+	Error(w, http.StatusForbidden, code, message)
+}
+
+// tenantErrorCode returns the error code and message of the 403 for a
+// resolveTenant or assign error.
+func tenantErrorCode(err error) (code, message string) {
+	switch {
+	case errors.Is(err, errTenantOverride):
+		return "FORBIDDEN", "Only admins can choose a tenant"
+	case errors.Is(err, errTenantMismatch):
+		return "TENANT_MISMATCH", "The document's tenant does not match your tenant"
+	default:
+		return "TENANT_REQUIRED", "This collection requires a tenant, and none was found for this request"
+	}
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const tenantSchemaYAML = `
+version: 1
+collections:
+  projects:
+    tenant:
+      field: org_id
+      source: auth.metadata.org_id
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      org_id:
+        type: string
+        index: true
+      name:
+        type: string
+    rules:
+      read: "doc.org_id == tenant"
+      create: "true"
+      update: "true"
+      delete: "true"
+`
+
+func setupTenantHandlers(t *testing.T) *Handlers {
+	t.Helper()
+
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(tenantSchemaYAML))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(db, s, config.Default(), engine)
+
+	for _, doc := range []struct{ id, org, name string }{
+		{"p1", "acme", "Acme One"},
+		{"p2", "acme", "Acme Two"},
+		{"p3", "globex", "Globex One"},
+	} {
+		if _, err := db.ExecContext(context.Background(), "INSERT INTO projects (id, org_id, name) VALUES (?, ?, ?)", doc.id, doc.org, doc.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return h
+}
+
+func tenantRequest(method, target, org, role string, body any) *http.Request {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("collection", "projects")
+
+	user := &auth.User{ID: "u-" + org, Email: org + "@example.com", Role: role}
+	if org != "" {
+		user.Metadata = map[string]any{"org_id": org}
+	}
+	return req.WithContext(auth.ContextWithUser(req.Context(), user))
+}
+
+func listNames(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	var resp struct {
+		Docs []map[string]any `json:"docs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	var names []string
+	for _, doc := range resp.Docs {
+		names = append(names, doc["name"].(string))
+	}
+	return names
+}
+
+func TestTenantList(t *testing.T) {
+	h := setupTenantHandlers(t)
+
+	w := httptest.NewRecorder()
+	h.ListDocuments(w, tenantRequest(http.MethodGet, "/api/collections/projects?sort=id", "acme", "user", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if names := listNames(t, w); len(names) != 2 || names[0] != "Acme One" || names[1] != "Acme Two" {
+		t.Errorf("expected acme projects, got %v", names)
+	}
+
+	w = httptest.NewRecorder()
+	h.ListDocuments(w, tenantRequest(http.MethodGet, "/api/collections/projects", "", "user", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 without a tenant, got %d", w.Code)
+	}
+}
+
+func TestTenantCrossTenantAccess(t *testing.T) {
+	h := setupTenantHandlers(t)
+
+	tests := []struct {
+		name    string
+		method  string
+		body    any
+		handler func(http.ResponseWriter, *http.Request)
+	}{
+		{"get", http.MethodGet, nil, h.GetDocument},
+		{"update", http.MethodPatch, map[string]any{"name": "Taken"}, h.UpdateDocument},
+		{"delete", http.MethodDelete, nil, h.DeleteDocument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tenantRequest(tt.method, "/api/collections/projects/p3", "acme", "user", tt.body)
+			req.SetPathValue("id", "p3")
+			w := httptest.NewRecorder()
+			tt.handler(w, req)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("expected status 404, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	req := tenantRequest(http.MethodGet, "/api/collections/projects/p3", "globex", "user", nil)
+	req.SetPathValue("id", "p3")
+	w := httptest.NewRecorder()
+	h.GetDocument(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected owning tenant to read p3, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTenantCreate(t *testing.T) {
+	h := setupTenantHandlers(t)
+
+	w := httptest.NewRecorder()
+	h.CreateDocument(w, tenantRequest(http.MethodPost, "/api/collections/projects", "acme", "user", map[string]any{"name": "New"}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var doc map[string]any
+	json.Unmarshal(w.Body.Bytes(), &doc)
+	if doc["org_id"] != "acme" {
+		t.Errorf("expected org_id acme, got %v", doc["org_id"])
+	}
+
+	w = httptest.NewRecorder()
+	h.CreateDocument(w, tenantRequest(http.MethodPost, "/api/collections/projects", "acme", "user", map[string]any{"name": "Sneaky", "org_id": "globex"}))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for another tenant's org_id, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := tenantRequest(http.MethodPatch, "/api/collections/projects/p1", "acme", "user", map[string]any{"org_id": "globex"})
+	req.SetPathValue("id", "p1")
+	w = httptest.NewRecorder()
+	h.UpdateDocument(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 moving a document to another tenant, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTenantAdminOverride(t *testing.T) {
+	h := setupTenantHandlers(t)
+	h.schema.Collections["projects"].Rules = &schema.Rules{Read: "true", Create: "true", Update: "true", Delete: "true"}
+	if err := h.rules.LoadSchema(h.schema); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ListDocuments(w, tenantRequest(http.MethodGet, "/api/collections/projects?tenant=*", "", "admin", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if names := listNames(t, w); len(names) != 3 {
+		t.Errorf("expected all projects, got %v", names)
+	}
+
+	w = httptest.NewRecorder()
+	h.ListDocuments(w, tenantRequest(http.MethodGet, "/api/collections/projects?tenant=globex", "", "admin", nil))
+	if names := listNames(t, w); len(names) != 1 || names[0] != "Globex One" {
+		t.Errorf("expected globex projects, got %v", names)
+	}
+
+	w = httptest.NewRecorder()
+	h.ListDocuments(w, tenantRequest(http.MethodGet, "/api/collections/projects?tenant=*", "acme", "user", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a non-admin override, got %d", w.Code)
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/storage"
+)
+
+// errUpsertConflict is returned inside the upsert transaction when the
+// document found for the key is no longer the one the rules were checked
+// against.
+var errUpsertConflict = errors.New("document for upsert key changed")
+
+// UpsertResponse is the body of an upsert response.
+type UpsertResponse struct {
+	Created  bool         `json:"created"`
+	Document database.Row `json:"document"`
+}
+
+// upsertKey returns the field named by key if documents can be upserted by
+// it: the primary key or a unique field that is not encrypted.
+func upsertKey(col *schema.Collection, key string) (*schema.Field, error) {
+	if key == "" {
+		return nil, errors.New("key query parameter is required")
+	}
+	field, ok := col.Fields[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", key)
+	}
+	if !field.Primary && !field.Unique {
+		return nil, fmt.Errorf("field %q is not the primary key or unique", key)
+	}
+	if field.Encrypted {
+		return nil, fmt.Errorf("field %q is encrypted", key)
+	}
+	return field, nil
+}
+
+// UpsertDocument handles PUT /api/collections/{collection}/upsert?key=field.
+// The document whose key field has the body's value is updated, with the
+// update rule evaluated against it; when there is none, the body is created
+// under the create rule.
+//
+// The lookup is repeated inside the write transaction, which holds the write
+// lock, and the request fails with a conflict if its result changed since
+// the rules were checked. A single INSERT ... ON CONFLICT DO UPDATE is not
+// used because which rule applies, and which hooks and history entry
+// follow, must be known before writing.
+//
+//nolint:gocyclo // Combines the create and update request flows
+func (h *Handlers) UpsertDocument(w http.ResponseWriter, r *http.Request) {
+	collectionName := r.PathValue("collection")
+
+	col, err := h.getCollection(r, collectionName)
+	if err != nil {
+		Error(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "Collection not found")
+		return
+	}
+
+	key, err := upsertKey(col.Schema(), r.URL.Query().Get("key"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_UPSERT_KEY", err.Error())
+		return
+	}
+
+	tenant, err := h.resolveTenant(r, col.Schema())
+	if err != nil {
+		tenantError(w, err)
+		return
+	}
+
+	var data database.Row
+	if decodeErr := DecodeJSON(r, &data, DecodeOptions{}); decodeErr != nil {
+		invalidJSON(w, r, decodeErr)
+		return
+	}
+	if err := checkDocumentFields(col.Schema(), data, schema.RegenerateSlugKey); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+
+	value := data[key.Name]
+	if value == nil {
+		Error(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Field %q is required to upsert", key.Name))
+		return
+	}
+
+	// A document of another tenant is treated as absent, so creating it
+	// fails on the unique key as a plain create would.
+	lookup := func(ctx context.Context) (database.Row, error) {
+		doc, err := col.FindBy(ctx, key.Name, value)
+		if errors.Is(err, database.ErrNotFound) || (err == nil && !tenant.owns(doc)) {
+			return nil, nil
+		}
+		return doc, err
+	}
+
+	existingDoc, err := lookup(r.Context())
+	if err != nil {
+		log.Error().Err(err).Str("collection", collectionName).Msg("Failed to get document for upsert")
+		Error(w, http.StatusInternalServerError, "QUERY_ERROR", "Failed to get document")
+		return
+	}
+	created := existingDoc == nil
+
+	op, ruleDoc := rules.OpCreate, data
+	if created {
+		err = tenant.assign(data)
+	} else {
+		op, ruleDoc = rules.OpUpdate, existingDoc
+		err = tenant.checkWrite(data)
+	}
+	if err != nil {
+		tenantError(w, err)
+		return
+	}
+
+	if accessErr := h.checkAccess(r, collectionName, op, tenant, ruleDoc); accessErr != nil {
+		if errors.Is(accessErr, rules.ErrAccessDenied) {
+			h.accessDenied(w, r, accessErr)
+			return
+		}
+		log.Error().Err(accessErr).Str("collection", collectionName).Msg("Rule evaluation failed")
+		InternalError(w, "Failed to check access")
+		return
+	}
+
+	// On update, absent keys are left alone; an explicit null clears the
+	// field.
+	if !created {
+		if verrs := database.ValidateNulls(col.Schema(), data); verrs.HasErrors() {
+			ErrorWithDetails(w, http.StatusUnprocessableEntity, "VALIDATION_ERROR", verrs.Errors[0].Message, verrs.Errors)
+			return
+		}
+	}
+	if verrs := database.ValidateInput(col.Schema(), data, created); verrs.HasErrors() {
+		ErrorWithDetails(w, http.StatusBadRequest, "VALIDATION_ERROR", verrs.Errors[0].Message, verrs.Errors)
+		return
+	}
+
+	if created {
+		err = col.Schema().RunChecks(data, nil)
+	} else {
+		merged := make(database.Row, len(existingDoc)+len(data))
+		maps.Copy(merged, existingDoc)
+		maps.Copy(merged, data)
+		err = col.Schema().RunChecks(merged, existingDoc)
+	}
+	if err != nil {
+		checkFailed(w, err)
+		return
+	}
+
+	if err := h.validateFileFields(r.Context(), col.Schema(), data); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			Error(w, http.StatusBadRequest, "FILE_NOT_FOUND", "Referenced file does not exist")
+			return
+		}
+		if errors.Is(err, errFileWrongBucket) {
+			Error(w, http.StatusBadRequest, "FILE_WRONG_BUCKET", "File belongs to wrong bucket")
+			return
+		}
+		log.Error().Err(err).Str("collection", collectionName).Msg("File field validation failed")
+		Error(w, http.StatusInternalServerError, "VALIDATION_ERROR", "Failed to validate file fields")
+		return
+	}
+
+	var clearedBlobs map[string]bool
+	if !created {
+		for name, value := range data {
+			if field, ok := col.Schema().Fields[name]; ok && field.Storage != "" && value == nil {
+				if clearedBlobs == nil {
+					clearedBlobs = map[string]bool{}
+				}
+				clearedBlobs[name] = true
+			}
+		}
+	}
+
+	pk := col.Schema().PrimaryKeyField()
+	var doc database.Row
+	err = h.dbFor(r.Context()).RunInTransaction(r.Context(), func(ctx context.Context) error {
+		current, err := lookup(ctx)
+		if err != nil {
+			return err
+		}
+		if created {
+			if current != nil {
+				return errUpsertConflict
+			}
+			doc, err = col.Create(ctx, data)
+			return err
+		}
+		id := fmt.Sprint(existingDoc[pk.Name])
+		if current == nil || fmt.Sprint(current[pk.Name]) != id {
+			return errUpsertConflict
+		}
+
+		var blobs []*database.BlobInfo
+		if clearedBlobs != nil {
+			if blobs, err = h.bucketBlobs(ctx, col.Schema(), id, clearedBlobs); err != nil {
+				return err
+			}
+		}
+		doc, err = col.Update(ctx, id, data)
+		if err != nil {
+			return err
+		}
+		for _, info := range blobs {
+			database.AfterCommit(ctx, func(ctx context.Context) {
+				h.deleteBlobObject(ctx, info)
+			})
+		}
+		database.AfterCommit(ctx, func(ctx context.Context) {
+			if err := h.handleFileFieldUpdates(ctx, col.Schema(), existingDoc, data); err != nil {
+				log.Error().Err(err).Str("collection", collectionName).Msg("Failed to handle file field updates")
+			}
+		})
+		return nil
+	})
+	if errors.Is(err, errUpsertConflict) || errors.Is(err, database.ErrNotFound) {
+		Error(w, http.StatusConflict, "UPSERT_CONFLICT", "Document changed during upsert, retry the request")
+		return
+	}
+	if err != nil {
+		if ce := database.AsConstraintError(err); ce != nil {
+			Error(w, http.StatusBadRequest, constraintErrorCode(ce), ce.Message)
+			return
+		}
+		log.Error().Err(err).Str("collection", collectionName).Msg("Failed to upsert document")
+		Error(w, http.StatusInternalServerError, "UPSERT_ERROR", "Failed to upsert document")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	h.redactFields(r, collectionName, tenant, doc)
+	if created {
+		setLocation(w, col.Schema(), doc)
+	}
+	JSON(w, status, UpsertResponse{Created: created, Document: doc})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const upsertSchemaYAML = `
+version: 1
+collections:
+  contacts:
+    fields:
+      id:
+        type: id
+        primary: true
+        default: auto
+      external_id:
+        type: string
+        unique: true
+      name:
+        type: string
+    rules:
+      create: "true"
+      update: doc.name != 'frozen'
+`
+
+func TestUpsertDocument(t *testing.T) {
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(upsertSchemaYAML))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatal(err)
+	}
+	h := New(db, s, config.Default(), engine)
+
+	upsert := func(key string, body map[string]any) (*httptest.ResponseRecorder, UpsertResponse) {
+		t.Helper()
+		req := historyRequest(http.MethodPut, "contacts", "", "user", body)
+		req.URL.RawQuery = "key=" + key
+		w := httptest.NewRecorder()
+		h.UpsertDocument(w, req)
+		var resp UpsertResponse
+		if w.Code < 300 {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return w, resp
+	}
+
+	for _, key := range []string{"", "name", "missing"} {
+		if w, _ := upsert(key, map[string]any{"name": "Ada"}); w.Code != http.StatusBadRequest {
+			t.Errorf("key %q: expected 400, got %d: %s", key, w.Code, w.Body.String())
+		}
+	}
+	if w, _ := upsert("external_id", map[string]any{"name": "Ada"}); w.Code != http.StatusBadRequest {
+		t.Errorf("missing key value: expected 400, got %d", w.Code)
+	}
+
+	w, created := upsert("external_id", map[string]any{"external_id": "crm-1", "name": "Ada"})
+	if w.Code != http.StatusCreated || !created.Created || created.Document["name"] != "Ada" {
+		t.Fatalf("create: got %d: %s", w.Code, w.Body.String())
+	}
+	id := created.Document["id"]
+
+	w, updated := upsert("external_id", map[string]any{"external_id": "crm-1", "name": "frozen"})
+	if w.Code != http.StatusOK || updated.Created || updated.Document["id"] != id || updated.Document["name"] != "frozen" {
+		t.Fatalf("update: got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The update rule is evaluated against the stored document.
+	if w, _ := upsert("external_id", map[string]any{"external_id": "crm-1", "name": "Grace"}); w.Code != http.StatusForbidden {
+		t.Errorf("update rule: expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w, byID := upsert("id", map[string]any{"id": "c2", "external_id": "crm-2", "name": "Linus"})
+	if w.Code != http.StatusCreated || byID.Document["id"] != "c2" {
+		t.Fatalf("create by primary key: got %d: %s", w.Code, w.Body.String())
+	}
+	w, byID = upsert("id", map[string]any{"id": "c2", "name": "Ken"})
+	if w.Code != http.StatusOK || byID.Created || byID.Document["external_id"] != "crm-2" || byID.Document["name"] != "Ken" {
+		t.Fatalf("update by primary key: got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/views"
+)
+
+// View staleness headers. ViewRefreshedAtHeader is the time of the view's
+// last successful refresh and ViewAgeHeader the seconds since; both are
+// omitted until the view has been refreshed once.
+const (
+	ViewRefreshedAtHeader = "X-Alyx-View-Refreshed-At"
+	ViewAgeHeader         = "X-Alyx-View-Age"
+)
+
+// SetViewService sets the service materialized views are read from.
+func (h *Handlers) SetViewService(svc *views.Service) {
+	h.views = svc
+}
+
+// GetView handles GET /api/views/{name}, listing a materialized view's rows
+// in query order. The view's read rule is applied like a collection's, with
+// doc set to the row.
+func (h *Handlers) GetView(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	if h.views == nil || h.views.View(name) == nil {
+		Error(w, http.StatusNotFound, "VIEW_NOT_FOUND", "View not found")
+		return
+	}
+
+	opts := &database.QueryOptions{}
+	defaultLimit, maxLimit := (*schema.ListConfig)(nil).Limits()
+	opts.Limit = defaultLimit
+	clampedFrom, err := parsePaginationOptions(r.URL.Query(), opts, maxLimit)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_QUERY", err.Error())
+		return
+	}
+	if clampedFrom > 0 {
+		w.Header().Set(LimitClampedHeader, strconv.Itoa(clampedFrom))
+	}
+
+	status, err := h.views.Status(r.Context(), name)
+	if err != nil {
+		log.Error().Err(err).Str("view", name).Msg("Failed to read view status")
+		Error(w, http.StatusInternalServerError, "QUERY_ERROR", "Failed to query view")
+		return
+	}
+	if status.RefreshedAt != nil {
+		w.Header().Set(ViewRefreshedAtHeader, status.RefreshedAt.UTC().Format(time.RFC3339))
+		age := max(int64(time.Since(*status.RefreshedAt).Seconds()), 0)
+		w.Header().Set(ViewAgeHeader, strconv.FormatInt(age, 10))
+	}
+
+	docs, total, err := h.readView(r, name, opts)
+	if errors.Is(err, rules.ErrAccessDenied) {
+		h.accessDenied(w, r, err)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("view", name).Msg("Failed to read view")
+		Error(w, http.StatusInternalServerError, "QUERY_ERROR", "Failed to query view")
+		return
+	}
+
+	resp := map[string]any{
+		"docs":         docs,
+		"total":        total,
+		"limit":        opts.Limit,
+		"offset":       opts.Offset,
+		"refreshed_at": status.RefreshedAt,
+	}
+	JSON(w, http.StatusOK, resp)
+}
+
+// readView returns the page of a view's rows the read rule allows, and the
+// number of rows it allows in total. A rule that does not reference doc is
+// evaluated once; any other is evaluated against every row.
+func (h *Handlers) readView(r *http.Request, name string, opts *database.QueryOptions) ([]database.Row, int64, error) {
+	ctx := r.Context()
+	target := rules.ViewTarget(name)
+	limit := opts.Limit
+	if limit == 0 {
+		limit = -1
+	}
+
+	strategy := rules.ListStrategyUnrestricted
+	evalCtx := h.evalContext(r, nil, nil)
+	if h.rules != nil {
+		plan, err := h.rules.PlanList(target, rules.OpRead, evalCtx)
+		if err != nil {
+			return nil, 0, err
+		}
+		if plan.Strategy == rules.ListStrategyConstant && !plan.Allowed {
+			return nil, 0, h.rules.Deny(target, rules.OpRead, evalCtx)
+		}
+		strategy = plan.Strategy
+	}
+
+	if strategy != rules.ListStrategyPerRow {
+		docs, err := h.views.Rows(ctx, name, limit, opts.Offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		total, err := h.views.Count(ctx, name)
+		return docs, total, err
+	}
+
+	all, err := h.views.Rows(ctx, name, -1, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	docs := make([]database.Row, 0, len(all))
+	for _, doc := range all {
+		evalCtx.Doc = doc
+		allowed, err := h.rules.Evaluate(target, rules.OpRead, evalCtx)
+		if err != nil {
+			return nil, 0, err
+		}
+		if allowed {
+			docs = append(docs, doc)
+		}
+	}
+
+	start := min(opts.Offset, len(docs))
+	end := len(docs)
+	if limit > 0 {
+		end = min(start+limit, len(docs))
+	}
+	return docs[start:end], int64(len(docs)), nil
+}
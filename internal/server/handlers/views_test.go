@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/views"
+)
+
+func TestGetView(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		query string
+		code  int
+		names []string
+		total int64
+	}{
+		{"no rule", "", "?limit=2&offset=1", http.StatusOK, []string{"User B", "User C"}, 5},
+		{"constant deny", "request.method == 'POST'", "", http.StatusForbidden, nil, 0},
+		{"per row", "doc.active == 1", "?limit=2&offset=1", http.StatusOK, []string{"User C", "User E"}, 3},
+		{"invalid limit", "", "?limit=abc", http.StatusBadRequest, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := setupTestHandlers(t)
+			ctx := context.Background()
+
+			for i := 0; i < 5; i++ {
+				db.ExecContext(ctx, "INSERT INTO users (id, name, email, active) VALUES (?, ?, ?, ?)",
+					fmt.Sprintf("user-%d", i), "User "+string(rune('A'+i)), fmt.Sprintf("user%d@example.com", i), (i+1)%2)
+			}
+
+			view := &schema.View{Name: "user_names", Query: "SELECT name, active FROM users ORDER BY name"}
+			if tt.rule != "" {
+				view.Rules = &schema.ViewRules{Read: tt.rule}
+			}
+			h.schema.Views = map[string]*schema.View{view.Name: view}
+
+			engine, err := rules.NewEngine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := engine.LoadSchema(h.schema); err != nil {
+				t.Fatal(err)
+			}
+			h.rules = engine
+
+			svc := views.NewService(db, h.schema)
+			svc.RunDue(ctx)
+			h.SetViewService(svc)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/views/user_names"+tt.query, nil)
+			req.SetPathValue("name", "user_names")
+			w := httptest.NewRecorder()
+
+			h.GetView(w, req)
+
+			if w.Code != tt.code {
+				t.Fatalf("expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			if w.Header().Get(ViewRefreshedAtHeader) == "" || w.Header().Get(ViewAgeHeader) == "" {
+				t.Errorf("missing staleness headers: %v", w.Header())
+			}
+
+			var resp struct {
+				Docs  []map[string]any `json:"docs"`
+				Total int64            `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Total != tt.total {
+				t.Errorf("total = %d, want %d", resp.Total, tt.total)
+			}
+			var names []string
+			for _, doc := range resp.Docs {
+				names = append(names, doc["name"].(string))
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.names) {
+				t.Errorf("names = %v, want %v", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestGetViewNotFound(t *testing.T) {
+	h, _ := setupTestHandlers(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/views/missing", nil)
+	req.SetPathValue("name", "missing")
+	w := httptest.NewRecorder()
+
+	h.GetView(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
 	"time"
 
@@ -81,8 +80,8 @@ func (h *WebhookHandlers) Create(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	var req CreateWebhookRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		BadRequest(w, "Invalid JSON body: "+err.Error())
+	if err := DecodeJSON(r, &req, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -97,6 +96,12 @@ func (h *WebhookHandlers) Create(w http.ResponseWriter, r *http.Request) {
 	if len(req.Methods) == 0 {
 		req.Methods = []string{"POST"}
 	}
+	if req.Verification != nil {
+		if err := req.Verification.Validate(); err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+	}
 
 	endpoint := &webhooks.WebhookEndpoint{
 		ID:           uuid.New().String(),
@@ -135,8 +140,8 @@ func (h *WebhookHandlers) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req UpdateWebhookRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		BadRequest(w, "Invalid JSON body: "+err.Error())
+	if err := DecodeJSON(r, &req, DecodeOptions{Strict: true}); err != nil {
+		invalidJSON(w, r, err)
 		return
 	}
 
@@ -150,6 +155,10 @@ func (h *WebhookHandlers) Update(w http.ResponseWriter, r *http.Request) {
 		endpoint.Methods = *req.Methods
 	}
 	if req.Verification != nil {
+		if err := req.Verification.Validate(); err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
 		endpoint.Verification = req.Verification
 	}
 	if req.Enabled != nil {
@@ -0,0 +1,86 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/deploy"
+	"github.com/watzon/alyx/pkg/alyxtest"
+)
+
+const testSchema = `
+version: 1
+collections:
+  users:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      name:
+        type: string
+      email:
+        type: string
+        unique: true
+`
+
+func TestIntrospect(t *testing.T) {
+	t.Parallel()
+	h := alyxtest.New(t, testSchema)
+
+	user := h.CreateUser("edge@example.com", "user")
+	accessToken := h.Token(user)
+
+	createToken := func(name string, perms ...string) string {
+		t.Helper()
+		resp, err := h.Server.DeployService().CreateToken(&deploy.CreateTokenRequest{Name: name, Permissions: perms}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Token
+	}
+	introspector := createToken("edge", string(deploy.PermissionIntrospect))
+	deployer := createToken("ci", string(deploy.PermissionDeploy))
+
+	body := fmt.Sprintf(`{"tokens": [%q, "not-a-token"]}`, accessToken)
+	w := h.Do(http.MethodPost, "/api/auth/introspect", body, introspector)
+	if w.Code != http.StatusOK {
+		t.Fatalf("introspect: status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Tokens []map[string]any `json:"tokens"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Tokens) != 2 {
+		t.Fatalf("expected 2 results, got %s", w.Body.String())
+	}
+	if got := resp.Tokens[0]; got["active"] != true || got["user_id"] != user.ID || got["role"] != "user" || got["expires_at"] == nil {
+		t.Errorf("unexpected result for a valid token: %v", got)
+	}
+	if got := resp.Tokens[1]; len(got) != 1 || got["active"] != false {
+		t.Errorf("unexpected result for an invalid token: %v", got)
+	}
+
+	if w := h.Do(http.MethodPost, "/api/auth/introspect", body, deployer); w.Code != http.StatusForbidden {
+		t.Errorf("deploy token: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	// Signed-in users cannot look up other users' tokens; admins can.
+	if w := h.Do(http.MethodPost, "/api/auth/introspect", body, accessToken); w.Code != http.StatusForbidden {
+		t.Errorf("user JWT: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	admin := h.CreateUser("admin@example.com", "admin")
+	if w := h.Do(http.MethodPost, "/api/auth/introspect", body, h.Token(admin)); w.Code != http.StatusOK {
+		t.Errorf("admin JWT: status %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	tooMany := `{"tokens": [` + strings.TrimSuffix(strings.Repeat(`"t",`, 21), ",") + `]}`
+	if w := h.Do(http.MethodPost, "/api/auth/introspect", tooMany, introspector); w.Code != http.StatusBadRequest {
+		t.Errorf("21 tokens: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -12,10 +12,15 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/metrics"
 	"github.com/watzon/alyx/internal/requestctx"
+	"github.com/watzon/alyx/internal/server/handlers"
+	"github.com/watzon/alyx/internal/tracing"
 )
 
 func RecoveryMiddleware(next http.Handler) http.Handler {
@@ -28,9 +33,7 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 					Str("path", r.URL.Path).
 					Msg("Panic recovered")
 
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				_, _ = w.Write([]byte(`{"error":"internal server error"}`))
+				handlers.InternalErrorWithRequest(w, r, "internal server error")
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -40,13 +43,17 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 func RequestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestID := r.Header.Get("X-Request-ID")
-		if requestID == "" {
+		if !requestctx.ValidRequestID(requestID) {
 			requestID = uuid.New().String()
 		}
 
 		ctx := requestctx.WithRequestID(r.Context(), requestID)
 		ctx = requestctx.WithRequestTime(ctx, time.Now())
 
+		if parentID := r.Header.Get("X-Parent-Request-ID"); requestctx.ValidRequestID(parentID) {
+			ctx = requestctx.WithParentRequestID(ctx, parentID)
+		}
+
 		w.Header().Set("X-Request-ID", requestID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -112,6 +119,7 @@ func (w *responseWriter) Flush() {
 }
 
 func CORSMiddleware(cfg config.CORSConfig) Middleware {
+	patterns := cfg.OriginPatterns()
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			origin := r.Header.Get("Origin")
@@ -124,6 +132,16 @@ func CORSMiddleware(cfg config.CORSConfig) Middleware {
 						break
 					}
 				}
+				for _, p := range patterns {
+					if allowed {
+						break
+					}
+					allowed = p.Match(origin)
+				}
+
+				// The response depends on the origin whenever it is
+				// reflected, so caches must key on it.
+				w.Header().Add("Vary", "Origin")
 
 				if allowed {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
@@ -159,11 +177,81 @@ func CORSMiddleware(cfg config.CORSConfig) Middleware {
 	}
 }
 
+// MaxBodySizeMiddleware limits every request body to maxSize bytes.
 func MaxBodySizeMiddleware(maxSize int64) Middleware {
+	return BodyLimitMiddleware(BodyLimits{MaxBodySize: maxSize, MaxUploadSize: maxSize})
+}
+
+// multipartOverhead is the allowance for multipart boundaries and part
+// headers on top of a bucket's max file size.
+const multipartOverhead = 64 * 1024
+
+// uploadPrefixes are the route prefixes whose bodies are file uploads.
+var uploadPrefixes = []string{"/api/files/", "/api/tus/"}
+
+// BodyLimits holds the request body limit for each route class. A zero limit
+// disables the check for that class.
+type BodyLimits struct {
+	MaxBodySize   int64
+	MaxUploadSize int64
+	// BucketLimit returns a bucket's max file size, or zero if it has none.
+	BucketLimit func(bucket string) int64
+}
+
+// limit returns the body limit for r and the setting it comes from.
+func (l BodyLimits) limit(r *http.Request) (int64, string) {
+	for _, prefix := range uploadPrefixes {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			continue
+		}
+		bucket, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if l.BucketLimit != nil && bucket != "" {
+			if max := l.BucketLimit(bucket); max > 0 {
+				if prefix == "/api/files/" {
+					max += multipartOverhead
+				}
+				return max, "buckets." + bucket + ".max_file_size"
+			}
+		}
+		return l.MaxUploadSize, "server.max_upload_size"
+	}
+
+	if r.Method == http.MethodPut && isBlobPath(r.URL.Path) {
+		return l.MaxUploadSize, "server.max_upload_size"
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/api/functions/") &&
+		strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return l.MaxUploadSize, "server.max_upload_size"
+	}
+	return l.MaxBodySize, "server.max_body_size"
+}
+
+// isBlobPath reports whether path is a blob field's content,
+// /api/collections/{collection}/{id}/blob/{field}.
+func isBlobPath(path string) bool {
+	rest, ok := strings.CutPrefix(path, "/api/collections/")
+	if !ok {
+		return false
+	}
+	parts := strings.Split(rest, "/")
+	return len(parts) == 4 && parts[2] == "blob"
+}
+
+// BodyLimitMiddleware limits request bodies by route class: uploads get
+// MaxUploadSize (or the bucket's max file size), everything else
+// MaxBodySize. Requests whose Content-Length exceeds the limit are rejected
+// with 413 before the handler runs; longer bodies fail when read.
+func BodyLimitMiddleware(limits BodyLimits) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			maxSize, setting := limits.limit(r)
+			if maxSize <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
 			if r.ContentLength > maxSize {
-				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				handlers.BodyTooLarge(w, r, maxSize, setting)
 				return
 			}
 			r.Body = http.MaxBytesReader(w, r.Body, maxSize)
@@ -172,6 +260,21 @@ func MaxBodySizeMiddleware(maxSize int64) Middleware {
 	}
 }
 
+// MaintenanceMiddleware answers API requests with 503 while maintenance
+// reports true. Admin endpoints stay available so the operation can be
+// watched, and health checks and the admin UI are not under /api.
+func MaintenanceMiddleware(maintenance func() bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maintenance() && strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/api/admin/") {
+				handlers.Error(w, http.StatusServiceUnavailable, "MAINTENANCE", "The server is in maintenance mode; try again shortly")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/metrics" {
@@ -197,6 +300,53 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// TracingMiddleware starts a server span for each request, continuing any
+// trace propagated in the incoming traceparent header. route resolves the
+// request to its registered route pattern so spans are named by template
+// rather than by concrete path.
+func TracingMiddleware(route func(*http.Request) string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !tracing.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			pattern := route(r)
+			if _, path, ok := strings.Cut(pattern, " "); ok {
+				pattern = path
+			}
+			if pattern == "" {
+				pattern = normalizePath(r.URL.Path)
+			}
+
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracing.Start(ctx, r.Method+" "+pattern,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", pattern),
+					attribute.String("url.path", r.URL.Path),
+					attribute.String("request.id", requestctx.RequestID(ctx)),
+				),
+			)
+			defer span.End()
+
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				status:         http.StatusOK,
+			}
+
+			next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.response.status_code", wrapped.status))
+			if wrapped.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(wrapped.status))
+			}
+		})
+	}
+}
+
 func normalizePath(path string) string {
 	parts := strings.Split(path, "/")
 	for i, part := range parts {
@@ -13,6 +13,7 @@ import (
 
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/requestctx"
+	"github.com/watzon/alyx/internal/server/handlers"
 )
 
 func TestRecoveryMiddleware(t *testing.T) {
@@ -41,6 +42,24 @@ func TestRecoveryMiddleware(t *testing.T) {
 	}
 }
 
+func TestRecoveryMiddleware_ProblemFormat(t *testing.T) {
+	handlers.SetErrorFormat(config.ErrorFormatProblem)
+	t.Cleanup(func() { handlers.SetErrorFormat(config.ErrorFormatAlyx) })
+
+	wrapped := RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	}))
+	w := httptest.NewRecorder()
+	wrapped.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected a 500 problem, got %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"code":"INTERNAL_ERROR"`) {
+		t.Errorf("expected INTERNAL_ERROR code, got %s", w.Body.String())
+	}
+}
+
 func TestRecoveryMiddleware_NoError(t *testing.T) {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -122,6 +141,49 @@ func TestRequestIDMiddleware_ExistingID(t *testing.T) {
 	}
 }
 
+func TestRequestIDMiddleware_InvalidID(t *testing.T) {
+	invalidIDs := []string{
+		"has spaces",
+		"line\nbreak",
+		strings.Repeat("a", 129),
+	}
+
+	for _, invalid := range invalidIDs {
+		var capturedCtx *http.Request
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			capturedCtx = r
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", invalid)
+		w := httptest.NewRecorder()
+
+		RequestIDMiddleware(handler).ServeHTTP(w, req)
+
+		requestID := requestctx.RequestID(capturedCtx.Context())
+		if requestID == invalid || requestID == "" {
+			t.Errorf("expected invalid ID %q to be replaced, got %q", invalid, requestID)
+		}
+	}
+}
+
+func TestRequestIDMiddleware_ParentID(t *testing.T) {
+	var capturedCtx *http.Request
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedCtx = r
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Parent-Request-ID", "parent-123")
+	w := httptest.NewRecorder()
+
+	RequestIDMiddleware(handler).ServeHTTP(w, req)
+
+	if got := requestctx.ParentRequestID(capturedCtx.Context()); got != "parent-123" {
+		t.Errorf("expected parent request ID %q, got %q", "parent-123", got)
+	}
+}
+
 func TestLoggingMiddleware(t *testing.T) {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -205,6 +267,43 @@ func TestCORSMiddleware(t *testing.T) {
 			expectMethods: true,
 			expectHeaders: true,
 		},
+		{
+			name: "preflight from pattern-matched origin",
+			corsConfig: config.CORSConfig{
+				Enabled:               true,
+				AllowedOriginPatterns: []string{"https://*.example.com"},
+				AllowCredentials:      true,
+			},
+			origin:        "https://app.example.com",
+			method:        http.MethodOptions,
+			expectOrigin:  "https://app.example.com",
+			expectCreds:   true,
+			expectStatus:  http.StatusNoContent,
+			expectMethods: true,
+			expectHeaders: true,
+		},
+		{
+			name: "preflight from origin outside pattern",
+			corsConfig: config.CORSConfig{
+				Enabled:               true,
+				AllowedOriginPatterns: []string{"https://*.example.com"},
+			},
+			origin:       "https://app.example.com.evil.com",
+			method:       http.MethodOptions,
+			expectOrigin: "",
+			expectStatus: http.StatusNoContent,
+		},
+		{
+			name: "pattern does not match nested subdomain",
+			corsConfig: config.CORSConfig{
+				Enabled:               true,
+				AllowedOriginPatterns: []string{"https://*.example.com"},
+			},
+			origin:       "https://a.b.example.com",
+			method:       http.MethodOptions,
+			expectOrigin: "",
+			expectStatus: http.StatusNoContent,
+		},
 		{
 			name: "exposed headers",
 			corsConfig: config.CORSConfig{
@@ -349,6 +448,77 @@ func TestMaxBodySizeMiddleware_ContentLengthCheck(t *testing.T) {
 	}
 }
 
+func TestBodyLimitMiddleware(t *testing.T) {
+	limits := BodyLimits{
+		MaxBodySize:   100,
+		MaxUploadSize: 1000,
+		BucketLimit: func(bucket string) int64 {
+			if bucket == "avatars" {
+				return 10
+			}
+			return 0
+		},
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		bodySize    int
+		setting     string
+	}{
+		{"json within limit", "", "/api/collections/posts", "application/json", 100, ""},
+		{"json over limit", "", "/api/collections/posts", "application/json", 101, "server.max_body_size"},
+		{"upload within limit", "", "/api/files/docs", "multipart/form-data; boundary=x", 1000, ""},
+		{"upload over limit", "", "/api/files/docs", "multipart/form-data; boundary=x", 1001, "server.max_upload_size"},
+		{"bucket override", "", "/api/files/avatars", "multipart/form-data; boundary=x", 10 + multipartOverhead + 1, "buckets.avatars.max_file_size"},
+		{"tus chunk", "", "/api/tus/avatars/123", "application/offset+octet-stream", 11, "buckets.avatars.max_file_size"},
+		{"function multipart", "", "/api/functions/resize", "multipart/form-data; boundary=x", 500, ""},
+		{"function json", "", "/api/functions/resize", "application/json", 500, "server.max_body_size"},
+		{"blob upload", http.MethodPut, "/api/collections/posts/1/blob/cover", "image/png", 1000, ""},
+		{"blob upload over limit", http.MethodPut, "/api/collections/posts/1/blob/cover", "image/png", 1001, "server.max_upload_size"},
+		{"document put", http.MethodPut, "/api/collections/posts/1", "application/json", 101, "server.max_body_size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := BodyLimitMiddleware(limits)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, tt.path, bytes.NewReader(bytes.Repeat([]byte("a"), tt.bodySize)))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if tt.setting == "" {
+				if w.Code != http.StatusOK {
+					t.Errorf("expected status 200, got %d", w.Code)
+				}
+				return
+			}
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("expected status 413, got %d", w.Code)
+			}
+			var resp struct {
+				Code    string         `json:"code"`
+				Details map[string]any `json:"details"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Code != "BODY_TOO_LARGE" || resp.Details["setting"] != tt.setting {
+				t.Errorf("expected BODY_TOO_LARGE naming %s, got %+v", tt.setting, resp)
+			}
+		})
+	}
+}
+
 func TestMiddlewareChain(t *testing.T) {
 	var order []string
 
@@ -552,3 +722,29 @@ func TestIsNumeric(t *testing.T) {
 		})
 	}
 }
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	on := false
+	handler := MaintenanceMiddleware(func() bool { return on })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		path string
+		on   bool
+		want int
+	}{
+		{"/api/collections/posts", false, http.StatusOK},
+		{"/api/collections/posts", true, http.StatusServiceUnavailable},
+		{"/api/admin/schema/changes/scheduled", true, http.StatusOK},
+		{"/health", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		on = tt.on
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s (maintenance %v): expected status %d, got %d", tt.path, tt.on, tt.want, w.Code)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package server
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/metrics"
+	"github.com/watzon/alyx/internal/server/handlers"
 )
 
 // RateLimiter implements token bucket algorithm for rate limiting.
@@ -42,8 +46,28 @@ func NewRateLimiter(rule config.RateLimitRule) *RateLimiter {
 	return rl
 }
 
+// Quota is a client's standing under a rate limit.
+type Quota struct {
+	Limit     int
+	Remaining int
+	// Reset is when the window ends and the quota is refilled.
+	Reset time.Time
+}
+
+// Rule returns the limit and window the limiter enforces.
+func (rl *RateLimiter) Rule() config.RateLimitRule {
+	return rl.rule
+}
+
 // Allow checks if a request from the given key is allowed.
 func (rl *RateLimiter) Allow(key string) bool {
+	_, ok := rl.Take(key)
+	return ok
+}
+
+// Take consumes a token for key if one is left, reporting whether the
+// request is allowed and the quota that remains after it.
+func (rl *RateLimiter) Take(key string) (Quota, bool) {
 	rl.mu.RLock()
 	b, exists := rl.buckets[key]
 	rl.mu.RUnlock()
@@ -72,12 +96,44 @@ func (rl *RateLimiter) Allow(key string) bool {
 		b.lastRefill = now
 	}
 
-	if b.tokens > 0 {
+	allowed := b.tokens > 0
+	if allowed {
 		b.tokens--
-		return true
 	}
 
-	return false
+	return Quota{
+		Limit:     rl.rule.Max,
+		Remaining: b.tokens,
+		Reset:     b.lastRefill.Add(rl.rule.Window),
+	}, allowed
+}
+
+// Peek returns the quota for key without consuming a token. A key with no
+// requests in the current window has the full quota, and its window would
+// start now.
+func (rl *RateLimiter) Peek(key string) Quota {
+	now := time.Now()
+	quota := Quota{
+		Limit:     rl.rule.Max,
+		Remaining: rl.rule.Max,
+		Reset:     now.Add(rl.rule.Window),
+	}
+
+	rl.mu.RLock()
+	b, exists := rl.buckets[key]
+	rl.mu.RUnlock()
+	if !exists {
+		return quota
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Sub(b.lastRefill) < rl.rule.Window {
+		quota.Remaining = b.tokens
+		quota.Reset = b.lastRefill.Add(rl.rule.Window)
+	}
+	return quota
 }
 
 func (rl *RateLimiter) cleanupLoop() {
@@ -107,24 +163,80 @@ func (rl *RateLimiter) Stop() {
 	rl.wg.Wait()
 }
 
-// Middleware returns an HTTP middleware that rate limits requests.
+// Middleware returns an HTTP middleware that rate limits requests. Responses
+// carry the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers
+// of the IETF RateLimit header fields draft, and Retry-After when limited.
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
-		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-			ip = forwarded
-		}
-		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-			ip = realIP
-		}
+		quota, allowed := rl.Take(clientKey(r))
+		resetSeconds := setRateLimitHeaders(w, quota)
 
-		if !rl.Allow(ip) {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusTooManyRequests)
-			_, _ = w.Write([]byte(`{"error":"rate limit exceeded","message":"Too many requests. Please try again later."}`))
+		if !allowed {
+			metrics.RecordAuthRateLimited(r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(resetSeconds))
+			handlers.ErrorWithRequest(w, r, http.StatusTooManyRequests, "RATE_LIMITED", "Too many requests. Please try again later.")
 			return
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// clientKey returns the key requests from the client are limited under.
+func clientKey(r *http.Request) string {
+	ip := r.RemoteAddr
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ip = forwarded
+	}
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		ip = realIP
+	}
+	return ip
+}
+
+// setRateLimitHeaders sets the RateLimit headers for quota and returns the
+// number of seconds until it resets.
+func setRateLimitHeaders(w http.ResponseWriter, quota Quota) int {
+	resetSeconds := resetSeconds(quota.Reset, time.Now())
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(quota.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(quota.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(resetSeconds))
+	return resetSeconds
+}
+
+// resetSeconds returns the whole seconds from now until reset, rounded up.
+func resetSeconds(reset, now time.Time) int {
+	return max(int(math.Ceil(reset.Sub(now).Seconds())), 0)
+}
+
+// RateLimitStatus reports the quota of the request's client under each
+// configured rate limit, without consuming any.
+func (s *Server) RateLimitStatus(r *http.Request) []handlers.RateLimitStatus {
+	limiters := []struct {
+		name    string
+		limiter *RateLimiter
+	}{
+		{"login", s.loginLimiter},
+		{"register", s.registerLimiter},
+		{"password_reset", s.passwordLimiter},
+	}
+
+	key := clientKey(r)
+	now := time.Now()
+	statuses := make([]handlers.RateLimitStatus, 0, len(limiters))
+	for _, l := range limiters {
+		if l.limiter == nil {
+			continue
+		}
+		quota := l.limiter.Peek(key)
+		statuses = append(statuses, handlers.RateLimitStatus{
+			Name:          l.name,
+			Limit:         quota.Limit,
+			Remaining:     quota.Remaining,
+			WindowSeconds: l.limiter.Rule().Window.Seconds(),
+			Reset:         quota.Reset,
+			ResetSeconds:  resetSeconds(quota.Reset, now),
+		})
+	}
+	return statuses
+}
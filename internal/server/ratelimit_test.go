@@ -1,6 +1,8 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -184,3 +186,79 @@ func TestRateLimiter_Stop(t *testing.T) {
 		t.Error("Stop channel should be closed")
 	}
 }
+
+func TestRateLimiter_PeekDoesNotConsume(t *testing.T) {
+	rule := config.RateLimitRule{
+		Max:    2,
+		Window: time.Minute,
+	}
+
+	rl := NewRateLimiter(rule)
+	defer rl.Stop()
+
+	if q := rl.Peek("key"); q.Limit != 2 || q.Remaining != 2 {
+		t.Errorf("Expected full quota for unseen key, got %+v", q)
+	}
+
+	q, ok := rl.Take("key")
+	if !ok || q.Remaining != 1 {
+		t.Fatalf("Expected first request allowed with 1 remaining, got %+v %v", q, ok)
+	}
+
+	for i := 0; i < 3; i++ {
+		if peek := rl.Peek("key"); peek.Remaining != 1 || !peek.Reset.Equal(q.Reset) {
+			t.Errorf("Peek %d changed the quota: %+v", i+1, peek)
+		}
+	}
+
+	rl.mu.RLock()
+	count := len(rl.buckets)
+	rl.mu.RUnlock()
+	rl.Peek("other")
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	if len(rl.buckets) != count {
+		t.Error("Peek should not create buckets")
+	}
+}
+
+func TestRateLimiter_MiddlewareHeaders(t *testing.T) {
+	rule := config.RateLimitRule{
+		Max:    1,
+		Window: 30 * time.Second,
+	}
+
+	rl := NewRateLimiter(rule)
+	defer rl.Stop()
+
+	handler := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Limit"); got != "1" {
+		t.Errorf("Expected RateLimit-Limit 1, got %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Reset"); got != "30" {
+		t.Errorf("Expected RateLimit-Reset 30, got %q", got)
+	}
+	if got := w.Header().Get("Retry-After"); got != "" {
+		t.Errorf("Expected no Retry-After on allowed request, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != w.Header().Get("RateLimit-Reset") || got == "" {
+		t.Errorf("Expected Retry-After to match RateLimit-Reset, got %q and %q", got, w.Header().Get("RateLimit-Reset"))
+	}
+}
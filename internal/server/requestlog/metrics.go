@@ -0,0 +1,259 @@
+package requestlog
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultMaxGroups is the number of groups Metrics reports when
+	// MetricsOptions.MaxGroups is unset.
+	DefaultMaxGroups = 20
+	// MaxGroupsLimit caps MetricsOptions.MaxGroups.
+	MaxGroupsLimit = 100
+	// MaxMetricsBuckets caps the number of buckets in a window.
+	MaxMetricsBuckets = 1440
+
+	// OtherGroup collects the entries of groups past the group cap.
+	OtherGroup = "(other)"
+	// UnmatchedRoute groups entries for requests that matched no route.
+	UnmatchedRoute = "(unmatched)"
+
+	// Latency histogram buckets grow by 10% from 10µs, so percentiles are
+	// estimated within 10% with bounded memory per bucket.
+	histogramBase   = 0.01
+	histogramGrowth = 1.1
+)
+
+// GroupByPath groups metrics by route pattern.
+const GroupByPath = "path"
+
+// MetricsOptions specifies the window and grouping of Metrics.
+type MetricsOptions struct {
+	// Window is how far back from End to aggregate.
+	Window time.Duration
+	// Bucket is the width of each bucket. Buckets are aligned to multiples
+	// of Bucket, and the last one contains End.
+	Bucket time.Duration
+	// End is the end of the window; zero means now.
+	End time.Time
+	// GroupBy is "" for a single series or GroupByPath for a series per
+	// route pattern.
+	GroupBy string
+	// MaxGroups caps the number of series; the entries of the least busy
+	// groups are reported under OtherGroup.
+	MaxGroups int
+}
+
+// Metrics is the aggregate of the request log over a window.
+type Metrics struct {
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	BucketSeconds float64   `json:"bucket_seconds"`
+	GroupBy       string    `json:"group_by,omitempty"`
+	// Truncated reports that groups past the cap were merged into OtherGroup.
+	Truncated bool     `json:"truncated"`
+	Series    []Series `json:"series"`
+}
+
+// Series is the metrics of one group. Buckets cover the whole window in
+// order, including empty ones, so every series has the same length.
+type Series struct {
+	// Route is the route pattern of the group; empty when not grouped.
+	Route   string          `json:"route,omitempty"`
+	Total   MetricsBucket   `json:"total"`
+	Buckets []MetricsBucket `json:"buckets"`
+}
+
+// MetricsBucket is the aggregate of the requests in one bucket. Latency
+// percentiles are in milliseconds and zero for empty buckets.
+type MetricsBucket struct {
+	Start     time.Time `json:"start"`
+	Count     int       `json:"count"`
+	Errors4xx int       `json:"errors_4xx"`
+	Errors5xx int       `json:"errors_5xx"`
+	ErrorRate float64   `json:"error_rate"`
+	P50MS     float64   `json:"p50_ms"`
+	P95MS     float64   `json:"p95_ms"`
+	P99MS     float64   `json:"p99_ms"`
+}
+
+// accumulator aggregates entries into a bucket without keeping them.
+type accumulator struct {
+	count     int
+	errors4xx int
+	errors5xx int
+	maxMS     float64
+	// histogram counts durations by histogramIndex.
+	histogram map[int]int
+}
+
+func (a *accumulator) add(e *Entry) {
+	a.count++
+	switch {
+	case e.Status >= 500:
+		a.errors5xx++
+	case e.Status >= 400:
+		a.errors4xx++
+	}
+	if e.DurationMS > a.maxMS {
+		a.maxMS = e.DurationMS
+	}
+	if a.histogram == nil {
+		a.histogram = make(map[int]int)
+	}
+	a.histogram[histogramIndex(e.DurationMS)]++
+}
+
+func (a *accumulator) merge(o *accumulator) {
+	a.count += o.count
+	a.errors4xx += o.errors4xx
+	a.errors5xx += o.errors5xx
+	a.maxMS = math.Max(a.maxMS, o.maxMS)
+	for i, n := range o.histogram {
+		if a.histogram == nil {
+			a.histogram = make(map[int]int)
+		}
+		a.histogram[i] += n
+	}
+}
+
+func (a *accumulator) bucket(start time.Time) MetricsBucket {
+	b := MetricsBucket{Start: start, Count: a.count, Errors4xx: a.errors4xx, Errors5xx: a.errors5xx}
+	if a.count == 0 {
+		return b
+	}
+	b.ErrorRate = float64(a.errors4xx+a.errors5xx) / float64(a.count)
+
+	indexes := make([]int, 0, len(a.histogram))
+	for i := range a.histogram {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	b.P50MS = a.percentile(indexes, 0.50)
+	b.P95MS = a.percentile(indexes, 0.95)
+	b.P99MS = a.percentile(indexes, 0.99)
+	return b
+}
+
+// percentile returns the upper bound of the histogram bucket holding the
+// p-th duration, capped at the largest duration seen.
+func (a *accumulator) percentile(indexes []int, p float64) float64 {
+	rank := int(math.Ceil(p * float64(a.count)))
+	seen := 0
+	for _, i := range indexes {
+		seen += a.histogram[i]
+		if seen >= rank {
+			return math.Min(histogramUpperBound(i), a.maxMS)
+		}
+	}
+	return a.maxMS
+}
+
+func histogramIndex(ms float64) int {
+	if ms <= histogramBase {
+		return 0
+	}
+	return int(math.Ceil(math.Log(ms/histogramBase) / math.Log(histogramGrowth)))
+}
+
+func histogramUpperBound(i int) float64 {
+	return histogramBase * math.Pow(histogramGrowth, float64(i))
+}
+
+// group is the per-bucket accumulators of one series.
+type group struct {
+	route   string
+	total   int
+	buckets []accumulator
+}
+
+// Metrics aggregates the entries in the window into per-bucket request
+// counts, error counts and latency percentiles. It makes a single pass over
+// the store, holding only a latency histogram per bucket. Groups are route
+// patterns, so there are at most as many as the router has routes.
+func (s *Store) Metrics(opts MetricsOptions) Metrics {
+	if opts.End.IsZero() {
+		opts.End = time.Now()
+	}
+	n := int((opts.Window + opts.Bucket - 1) / opts.Bucket)
+	end := opts.End.Truncate(opts.Bucket).Add(opts.Bucket)
+	start := end.Add(-time.Duration(n) * opts.Bucket)
+
+	groups := make(map[string]*group)
+	var order []*group
+
+	s.mu.RLock()
+	for i := 0; i < s.count; i++ {
+		entry := &s.entries[(s.head-1-i+s.capacity)%s.capacity]
+		if entry.Timestamp.Before(start) || !entry.Timestamp.Before(end) {
+			continue
+		}
+
+		key := ""
+		if opts.GroupBy == GroupByPath {
+			key = entry.Route
+			if key == "" {
+				key = UnmatchedRoute
+			}
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{route: key, buckets: make([]accumulator, n)}
+			groups[key] = g
+			order = append(order, g)
+		}
+		g.total++
+		g.buckets[int(entry.Timestamp.Sub(start)/opts.Bucket)].add(entry)
+	}
+	s.mu.RUnlock()
+
+	// Keep the busiest groups, merging the rest.
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].total != order[j].total {
+			return order[i].total > order[j].total
+		}
+		return order[i].route < order[j].route
+	})
+	maxGroups := opts.MaxGroups
+	if maxGroups <= 0 {
+		maxGroups = DefaultMaxGroups
+	}
+	maxGroups = min(maxGroups, MaxGroupsLimit)
+	truncated := false
+	if len(order) > maxGroups {
+		truncated = true
+		other := &group{route: OtherGroup, buckets: make([]accumulator, n)}
+		for _, g := range order[maxGroups-1:] {
+			other.total += g.total
+			for i := range g.buckets {
+				other.buckets[i].merge(&g.buckets[i])
+			}
+		}
+		order = append(order[:maxGroups-1], other)
+	}
+	if len(order) == 0 && opts.GroupBy == "" {
+		order = append(order, &group{buckets: make([]accumulator, n)})
+	}
+
+	m := Metrics{
+		Start:         start,
+		End:           end,
+		BucketSeconds: opts.Bucket.Seconds(),
+		GroupBy:       opts.GroupBy,
+		Truncated:     truncated,
+		Series:        make([]Series, 0, len(order)),
+	}
+	for _, g := range order {
+		series := Series{Route: g.route, Buckets: make([]MetricsBucket, n)}
+		var total accumulator
+		for i := range g.buckets {
+			series.Buckets[i] = g.buckets[i].bucket(start.Add(time.Duration(i) * opts.Bucket))
+			total.merge(&g.buckets[i])
+		}
+		series.Total = total.bucket(start)
+		m.Series = append(m.Series, series)
+	}
+	return m
+}
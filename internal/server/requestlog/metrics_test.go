@@ -0,0 +1,137 @@
+package requestlog
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStore_Metrics(t *testing.T) {
+	store := NewStore(200)
+	end := time.Date(2026, 1, 1, 12, 2, 30, 0, time.UTC)
+	bucket0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Outside the window.
+	store.Add(Entry{Timestamp: bucket0.Add(-time.Minute), Status: 200, DurationMS: 1})
+	for i := 1; i <= 100; i++ {
+		store.Add(Entry{Timestamp: bucket0.Add(time.Second), Status: 200, DurationMS: float64(i)})
+	}
+	store.Add(Entry{Timestamp: bucket0.Add(2*time.Minute + 10*time.Second), Status: 404, DurationMS: 5})
+	store.Add(Entry{Timestamp: bucket0.Add(2*time.Minute + 20*time.Second), Status: 503, DurationMS: 7})
+
+	m := store.Metrics(MetricsOptions{Window: 3 * time.Minute, Bucket: time.Minute, End: end})
+
+	if !m.Start.Equal(bucket0) || !m.End.Equal(bucket0.Add(3*time.Minute)) {
+		t.Errorf("window = %s..%s, want aligned to %s", m.Start, m.End, bucket0)
+	}
+	if len(m.Series) != 1 || len(m.Series[0].Buckets) != 3 {
+		t.Fatalf("expected 1 series of 3 buckets, got %+v", m.Series)
+	}
+
+	buckets := m.Series[0].Buckets
+	if buckets[0].Count != 100 || buckets[1].Count != 0 || buckets[2].Count != 2 {
+		t.Errorf("counts = %d %d %d, want 100 0 2", buckets[0].Count, buckets[1].Count, buckets[2].Count)
+	}
+	if !buckets[1].Start.Equal(bucket0.Add(time.Minute)) {
+		t.Errorf("empty bucket start = %s", buckets[1].Start)
+	}
+	for _, tt := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"p50", buckets[0].P50MS, 50},
+		{"p95", buckets[0].P95MS, 95},
+		{"p99", buckets[0].P99MS, 99},
+	} {
+		// The histogram estimates percentiles within 10%.
+		if tt.got < tt.want || tt.got > tt.want*histogramGrowth {
+			t.Errorf("%s = %v, want within 10%% above %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if buckets[2].Errors4xx != 1 || buckets[2].Errors5xx != 1 || buckets[2].ErrorRate != 1 {
+		t.Errorf("unexpected error counts %+v", buckets[2])
+	}
+	if buckets[2].P99MS != 7 {
+		t.Errorf("p99 = %v, want capped at max duration 7", buckets[2].P99MS)
+	}
+
+	total := m.Series[0].Total
+	if total.Count != 102 || math.Abs(total.ErrorRate-2.0/102) > 1e-9 {
+		t.Errorf("unexpected total %+v", total)
+	}
+}
+
+func TestStore_MetricsGroupByPath(t *testing.T) {
+	store := NewStore(100)
+	now := time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	for _, path := range []string{"/api/collections/posts/abc", "/api/collections/posts/def", "/api/collections/posts/ghi"} {
+		store.Add(Entry{Timestamp: now, Path: path, Route: "/api/collections/{collection}/{id}", Status: 200})
+	}
+	store.Add(Entry{Timestamp: now, Path: "/api/collections/posts", Route: "/api/collections/{collection}", Status: 200})
+	store.Add(Entry{Timestamp: now, Path: "/api/collections/posts", Route: "/api/collections/{collection}", Status: 200})
+	store.Add(Entry{Timestamp: now, Path: "/nope", Status: 404})
+
+	m := store.Metrics(MetricsOptions{Window: time.Minute, Bucket: time.Minute, End: now, GroupBy: GroupByPath})
+	if m.Truncated || len(m.Series) != 3 {
+		t.Fatalf("expected 3 series, got %+v", m.Series)
+	}
+	want := []struct {
+		route string
+		count int
+	}{
+		{"/api/collections/{collection}/{id}", 3},
+		{"/api/collections/{collection}", 2},
+		{UnmatchedRoute, 1},
+	}
+	for i, w := range want {
+		if m.Series[i].Route != w.route || m.Series[i].Total.Count != w.count {
+			t.Errorf("series %d = %s (%d), want %s (%d)", i, m.Series[i].Route, m.Series[i].Total.Count, w.route, w.count)
+		}
+	}
+
+	m = store.Metrics(MetricsOptions{Window: time.Minute, Bucket: time.Minute, End: now, GroupBy: GroupByPath, MaxGroups: 2})
+	if !m.Truncated || len(m.Series) != 2 {
+		t.Fatalf("expected 2 series when capped, got %+v", m.Series)
+	}
+	if m.Series[1].Route != OtherGroup || m.Series[1].Total.Count != 3 {
+		t.Errorf("expected other group with 3 requests, got %s (%d)", m.Series[1].Route, m.Series[1].Total.Count)
+	}
+}
+
+func TestStore_MetricsEmpty(t *testing.T) {
+	m := NewStore(10).Metrics(MetricsOptions{Window: time.Hour, Bucket: time.Minute})
+	if len(m.Series) != 1 || len(m.Series[0].Buckets) != 60 {
+		t.Fatalf("expected one zero-filled series of 60 buckets, got %d series", len(m.Series))
+	}
+	if m.Series[0].Total.Count != 0 || m.Series[0].Total.P99MS != 0 {
+		t.Errorf("expected empty totals, got %+v", m.Series[0].Total)
+	}
+}
+
+func TestMiddleware_RecordRoute(t *testing.T) {
+	store := NewStore(10)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/collections/{collection}/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	handler := Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		RecordRoute(w, r.Pattern)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/collections/posts/abc", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	entries := store.List(FilterOptions{}).Entries
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[1].Route != "/api/collections/{collection}/{id}" {
+		t.Errorf("Route = %q, want pattern without method", entries[1].Route)
+	}
+	if entries[0].Route != "" {
+		t.Errorf("Route = %q, want empty for unmatched request", entries[0].Route)
+	}
+}
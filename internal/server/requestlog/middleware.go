@@ -32,6 +32,7 @@ func Middleware(store *Store) func(http.Handler) http.Handler {
 
 			entry := Entry{
 				ID:         requestID,
+				ParentID:   requestctx.ParentRequestID(r.Context()),
 				Timestamp:  start,
 				Method:     r.Method,
 				Path:       r.URL.Path,
@@ -43,7 +44,12 @@ func Middleware(store *Store) func(http.Handler) http.Handler {
 				BytesOut:   int64(wrapped.bytes),
 				ClientIP:   extractClientIP(r),
 				UserAgent:  r.UserAgent(),
+				Error:      wrapped.err,
+				ErrorCode:  wrapped.errCode,
 			}
+			entry.ErrorDetails = wrapped.errDetails
+			entry.ListQuery = wrapped.listQuery
+			entry.Route = wrapped.route
 
 			if user := auth.UserFromContext(r.Context()); user != nil {
 				entry.UserID = user.ID
@@ -93,6 +99,69 @@ type responseCapture struct {
 	http.ResponseWriter
 	status int
 	bytes  int
+
+	err        string
+	errCode    string
+	errDetails any
+	listQuery  *ListQuery
+	route      string
+}
+
+// RecordError attaches an error to the log entry for the request w is
+// serving. It is a no-op if the request is not being logged. Any details
+// replace those recorded before.
+func RecordError(w http.ResponseWriter, code, message string, details any) {
+	capture := findCapture(w)
+	if capture == nil {
+		return
+	}
+	capture.err = message
+	capture.errCode = code
+	if details != nil {
+		capture.errDetails = details
+	}
+}
+
+// RecordListQuery attaches the normalized list query to the log entry for
+// the request w is serving. It is a no-op if the request is not being logged.
+func RecordListQuery(w http.ResponseWriter, q *ListQuery) {
+	if capture := findCapture(w); capture != nil {
+		capture.listQuery = q
+	}
+}
+
+// RecordRoute attaches the pattern of the route serving the request w is
+// serving to its log entry, without the method and host. It is a no-op if
+// the request is not being logged.
+func RecordRoute(w http.ResponseWriter, pattern string) {
+	capture := findCapture(w)
+	if capture == nil {
+		return
+	}
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		pattern = pattern[i:]
+	}
+	capture.route = pattern
+}
+
+func findCapture(w http.ResponseWriter) *responseCapture {
+	for w != nil {
+		if capture, ok := w.(*responseCapture); ok {
+			return capture
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = unwrapper.Unwrap()
+	}
+	return nil
+}
+
+// Unwrap lets http.ResponseController and RecordError reach the underlying
+// writer.
+func (w *responseCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
 func (w *responseCapture) WriteHeader(status int) {
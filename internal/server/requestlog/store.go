@@ -9,22 +9,75 @@ import (
 
 // Entry represents a single HTTP request log entry.
 type Entry struct {
-	ID         string            `json:"id"`
-	Timestamp  time.Time         `json:"timestamp"`
-	Method     string            `json:"method"`
-	Path       string            `json:"path"`
-	Query      string            `json:"query,omitempty"`
-	Status     int               `json:"status"`
-	Duration   time.Duration     `json:"duration"`
-	DurationMS float64           `json:"duration_ms"`
-	BytesIn    int64             `json:"bytes_in"`
-	BytesOut   int64             `json:"bytes_out"`
-	ClientIP   string            `json:"client_ip"`
-	UserAgent  string            `json:"user_agent,omitempty"`
-	UserID     string            `json:"user_id,omitempty"`
-	Error      string            `json:"error,omitempty"`
-	ErrorCode  string            `json:"error_code,omitempty"`
-	Headers    map[string]string `json:"headers,omitempty"`
+	ID        string    `json:"id"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	// Route is the pattern of the route that served the request, such as
+	// /api/collections/{collection}/{id}; empty if none matched.
+	Route      string        `json:"route,omitempty"`
+	Query      string        `json:"query,omitempty"`
+	Status     int           `json:"status"`
+	Duration   time.Duration `json:"duration"`
+	DurationMS float64       `json:"duration_ms"`
+	BytesIn    int64         `json:"bytes_in"`
+	BytesOut   int64         `json:"bytes_out"`
+	ClientIP   string        `json:"client_ip"`
+	UserAgent  string        `json:"user_agent,omitempty"`
+	UserID     string        `json:"user_id,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	ErrorCode  string        `json:"error_code,omitempty"`
+	// ErrorDetails is structured context recorded by the handler, such as
+	// the rule that denied access.
+	ErrorDetails any               `json:"error_details,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	// ListQuery is the normalized query of a document list request.
+	ListQuery *ListQuery `json:"list_query,omitempty"`
+}
+
+// ListQuery is the shape of a document list query with the filter values
+// dropped, so requests differing only in values compare equal.
+type ListQuery struct {
+	Collection string        `json:"collection"`
+	Filters    []QueryFilter `json:"filters,omitempty"`
+	Sorts      []QuerySort   `json:"sorts,omitempty"`
+	Search     bool          `json:"search,omitempty"`
+	Limit      int           `json:"limit"`
+	// Returned is the number of documents in the response.
+	Returned int `json:"returned"`
+}
+
+// QueryFilter is a filtered field and operator.
+type QueryFilter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+}
+
+// QuerySort is a sort field and direction.
+type QuerySort struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc,omitempty"`
+}
+
+// Key identifies the query shape, ignoring the limit and result size.
+func (q *ListQuery) Key() string {
+	var sb strings.Builder
+	sb.WriteString(q.Collection)
+	for _, f := range q.Filters {
+		sb.WriteString("|f:" + f.Field + ":" + f.Op)
+	}
+	for _, s := range q.Sorts {
+		if s.Desc {
+			sb.WriteString("|s:-" + s.Field)
+		} else {
+			sb.WriteString("|s:" + s.Field)
+		}
+	}
+	if q.Search {
+		sb.WriteString("|search")
+	}
+	return sb.String()
 }
 
 // Store is a thread-safe ring buffer for request logs.
@@ -68,6 +121,7 @@ type FilterOptions struct {
 	MinStatus         int
 	MaxStatus         int
 	UserID            string
+	ErrorCode         string
 	Since             time.Time
 	Until             time.Time
 	Limit             int
@@ -146,6 +200,9 @@ func (s *Store) matchesStringFilters(entry Entry, opts FilterOptions) bool {
 	if opts.UserID != "" && entry.UserID != opts.UserID {
 		return false
 	}
+	if opts.ErrorCode != "" && entry.ErrorCode != opts.ErrorCode {
+		return false
+	}
 	return true
 }
 
@@ -172,6 +229,26 @@ func (s *Store) matchesTimeFilters(entry Entry, opts FilterOptions) bool {
 	return true
 }
 
+// ListQueries returns the list queries recorded since the given time,
+// newest first.
+func (s *Store) ListQueries(since time.Time) []ListQuery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var queries []ListQuery
+	for i := 0; i < s.count; i++ {
+		entry := s.entries[(s.head-1-i+s.capacity)%s.capacity]
+		if entry.ListQuery == nil {
+			continue
+		}
+		if !since.IsZero() && entry.Timestamp.Before(since) {
+			break
+		}
+		queries = append(queries, *entry.ListQuery)
+	}
+	return queries
+}
+
 // Count returns the number of entries currently in the store.
 func (s *Store) Count() int {
 	s.mu.RLock()
@@ -1,6 +1,8 @@
 package requestlog
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -55,6 +57,74 @@ func TestStore_FilterByMethod(t *testing.T) {
 	}
 }
 
+func TestStore_FilterByErrorCode(t *testing.T) {
+	store := NewStore(10)
+
+	store.Add(Entry{ID: "1", Status: 403, ErrorCode: "RULE_DENIED"})
+	store.Add(Entry{ID: "2", Status: 403, ErrorCode: "FORBIDDEN"})
+	store.Add(Entry{ID: "3", Status: 200})
+
+	result := store.List(FilterOptions{ErrorCode: "RULE_DENIED"})
+	if result.Total != 1 || result.Entries[0].ID != "1" {
+		t.Errorf("expected only entry 1, got %+v", result.Entries)
+	}
+}
+
+func TestMiddleware_RecordError(t *testing.T) {
+	store := NewStore(10)
+	handler := Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RecordError(w, "RULE_DENIED", "Access denied", map[string]any{"rule": "false"})
+		RecordError(w, "RULE_DENIED", "Access denied", nil)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/collections/posts", nil))
+
+	entries := store.List(FilterOptions{}).Entries
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.ErrorCode != "RULE_DENIED" || entry.Error != "Access denied" || entry.Status != http.StatusForbidden {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if details, ok := entry.ErrorDetails.(map[string]any); !ok || details["rule"] != "false" {
+		t.Errorf("expected details to be kept, got %v", entry.ErrorDetails)
+	}
+
+	// Writers outside the middleware are ignored.
+	RecordError(httptest.NewRecorder(), "X", "y", nil)
+}
+
+func TestMiddleware_RecordListQuery(t *testing.T) {
+	store := NewStore(10)
+	handler := Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/collections/posts" {
+			RecordListQuery(w, &ListQuery{
+				Collection: "posts",
+				Filters:    []QueryFilter{{Field: "status", Op: "eq"}},
+				Sorts:      []QuerySort{{Field: "created_at", Desc: true}},
+				Returned:   3,
+			})
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/collections/posts", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/health", nil))
+
+	queries := store.ListQueries(time.Time{})
+	if len(queries) != 1 {
+		t.Fatalf("expected 1 list query, got %d", len(queries))
+	}
+	if got := queries[0].Key(); got != "posts|f:status:eq|s:-created_at" {
+		t.Errorf("Key() = %q", got)
+	}
+	if len(store.ListQueries(time.Now().Add(time.Minute))) != 0 {
+		t.Error("expected since to exclude older queries")
+	}
+}
+
 func TestStore_FilterByStatus(t *testing.T) {
 	store := NewStore(10)
 
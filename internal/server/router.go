@@ -1,11 +1,15 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
 	"github.com/watzon/alyx/internal/adminui"
 	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/buildinfo"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/deploy"
 	"github.com/watzon/alyx/internal/functions"
 	"github.com/watzon/alyx/internal/metrics"
 	"github.com/watzon/alyx/internal/server/handlers"
@@ -14,10 +18,12 @@ import (
 )
 
 type Router struct {
-	server       *Server
-	mux          *http.ServeMux
-	middlewares  []Middleware
-	mainHandlers *handlers.Handlers
+	server        *Server
+	mux           *http.ServeMux
+	middlewares   []Middleware
+	mainHandlers  *handlers.Handlers
+	authService   *auth.Service
+	adminHandlers *handlers.AdminHandlers
 }
 
 type Middleware func(http.Handler) http.Handler
@@ -37,15 +43,38 @@ func NewRouter(srv *Server) *Router {
 func (r *Router) setupMiddleware() {
 	r.Use(RecoveryMiddleware)
 	r.Use(RequestIDMiddleware)
+	if r.server.cfg.Server.Compression.Enabled {
+		r.Use(CompressionMiddleware(r.server.cfg.Server.Compression))
+	}
+	r.Use(TracingMiddleware(func(req *http.Request) string {
+		_, pattern := r.mux.Handler(req)
+		return pattern
+	}))
 	r.Use(MetricsMiddleware)
 	r.Use(LoggingMiddleware)
 	r.Use(requestlog.Middleware(r.server.RequestLogs()))
-	r.Use(MaxBodySizeMiddleware(r.server.cfg.Server.MaxBodySize))
+	r.Use(MaintenanceMiddleware(r.server.Maintenance))
+	r.Use(BodyLimitMiddleware(BodyLimits{
+		MaxBodySize:   r.server.cfg.Server.MaxBodySize,
+		MaxUploadSize: r.server.cfg.Server.MaxUploadSize,
+		BucketLimit: func(name string) int64 {
+			if s := r.server.Schema(); s != nil {
+				if bucket, ok := s.Buckets[name]; ok {
+					return bucket.MaxFileSize
+				}
+			}
+			return 0
+		},
+	}))
 
 	if r.server.cfg.Server.CORS.Enabled {
 		r.Use(CORSMiddleware(r.server.cfg.Server.CORS))
 	}
 
+	if sb := r.server.Sandbox(); sb != nil {
+		r.Use(SandboxMiddleware(sb, r.server.cfg.Dev.Sandbox))
+	}
+
 	if r.server.TransactionManager() != nil {
 		r.Use(transactions.Middleware(r.server.TransactionManager()))
 	}
@@ -63,58 +92,118 @@ func (r *Router) SetHookTrigger(trigger *DatabaseHookTrigger) {
 
 func (r *Router) setupRoutes() {
 	h := handlers.New(r.server.DB(), r.server.Schema(), r.server.Config(), r.server.Rules())
+	h.SetViewService(r.server.ViewService())
+	h.SetChangeFeed(r.server.ChangeFeed())
 	r.mainHandlers = h
 
 	authHandlers := handlers.NewAuthHandlers(r.server.DB(), &r.server.cfg.Auth, r.server.BruteForceProtector())
+	authHandlers.SetRateLimitInspector(r.server)
 	authService := authHandlers.Service()
+	authService.SetRoles(r.server.Schema().AllRoles())
+	authService.SetUserMetadata(r.server.Schema().UserMetadata)
+	authService.SetTenantMetadataKeys(r.server.Schema().TenantMetadataKeys())
+	authService.SetUserReferences(r.server.Schema().UserReferences())
+	authService.SetMetrics(metrics.AuthMetrics{})
+	authService.SetErrorWriter(handlers.ErrorWithRequest)
+	r.authService = authService
+	if mailer := r.server.Mailer(); mailer != nil {
+		authService.SetMailer(mailer)
+	}
+	if broker := r.server.Broker(); broker != nil {
+		authService.SetEventPublisher(broker)
+	}
 
 	if r.server.cfg.AdminUI.Enabled {
 		uiHandler := adminui.New(&r.server.cfg.AdminUI)
-		basePath := r.server.cfg.AdminUI.Path
+		basePath := strings.TrimSuffix(r.server.cfg.AdminUI.Path, "/")
 		r.mux.Handle("GET "+basePath+"/{path...}", http.StripPrefix(basePath, uiHandler))
-		r.mux.Handle("GET "+basePath, http.RedirectHandler(basePath+"/", http.StatusMovedPermanently))
+		r.mux.Handle("GET "+basePath, uiHandler.RedirectToBase())
+		if basePath != "/admin" {
+			r.mux.Handle("GET /admin", uiHandler.RedirectToBase())
+			r.mux.Handle("GET /admin/{$}", uiHandler.RedirectToBase())
+		}
 	}
 
 	healthHandlers := handlers.NewHealthHandlers(
 		r.server.DB(),
 		r.server.Broker(),
 		r.server.FuncService(),
-		"0.1.0",
+		buildinfo.Version,
 	)
+	healthHandlers.SetFeatures(r.server.Config().Features())
+	if cfg := r.server.Config(); cfg.Functions.Enabled {
+		healthHandlers.SetFunctionsEnabled(cfg.Health.RequireFunctions)
+	}
 	r.mux.HandleFunc("GET /", r.wrap(healthHandlers.Liveness))
 	r.mux.HandleFunc("GET /health", r.wrap(healthHandlers.Health))
 	r.mux.HandleFunc("GET /health/live", r.wrap(healthHandlers.Liveness))
 	r.mux.HandleFunc("GET /health/ready", r.wrap(healthHandlers.Readiness))
 	r.mux.HandleFunc("GET /health/stats", r.wrap(healthHandlers.Stats))
+	r.mux.HandleFunc("GET /api/version", r.wrap(healthHandlers.Version))
 	r.mux.Handle("GET /metrics", metrics.Handler())
 
 	r.mux.HandleFunc("GET /api/config", r.wrap(h.Config))
 	r.mux.HandleFunc("GET /api/collections/{collection}", r.wrapWithOptionalAuth(h.ListDocuments, authService))
+	r.mux.HandleFunc("HEAD /api/collections/{collection}", r.wrapWithOptionalAuth(handlers.HeadHandler(h.ListDocuments), authService))
 	r.mux.HandleFunc("POST /api/collections/{collection}", r.wrapWithOptionalAuth(h.CreateDocument, authService))
+	r.mux.HandleFunc("PUT /api/collections/{collection}/upsert", r.wrapWithOptionalAuth(h.UpsertDocument, authService))
 	r.mux.HandleFunc("GET /api/collections/{collection}/{id}", r.wrapWithOptionalAuth(h.GetDocument, authService))
+	r.mux.HandleFunc("HEAD /api/collections/{collection}/{id}", r.wrapWithOptionalAuth(handlers.HeadHandler(h.GetDocument), authService))
 	r.mux.HandleFunc("PATCH /api/collections/{collection}/{id}", r.wrapWithOptionalAuth(h.UpdateDocument, authService))
 	r.mux.HandleFunc("PUT /api/collections/{collection}/{id}", r.wrapWithOptionalAuth(h.UpdateDocument, authService))
 	r.mux.HandleFunc("DELETE /api/collections/{collection}/{id}", r.wrapWithOptionalAuth(h.DeleteDocument, authService))
+	r.mux.HandleFunc("GET /api/collections/{collection}/{id}/history", r.wrapWithOptionalAuth(h.GetHistory, authService))
+	r.mux.HandleFunc("GET /api/collections/{collection}/{id}/blob/{field}", r.wrapWithOptionalAuth(h.GetBlob, authService))
+	r.mux.HandleFunc("PUT /api/collections/{collection}/{id}/blob/{field}", r.wrapWithOptionalAuth(h.PutBlob, authService))
+	r.mux.HandleFunc("GET /api/views/{name}", r.wrapWithOptionalAuth(h.GetView, authService))
+	r.mux.HandleFunc("GET /api/sync/{collection}", r.wrapWithOptionalAuth(h.SyncPull, authService))
+	r.mux.HandleFunc("POST /api/sync/{collection}", r.wrapWithOptionalAuth(h.SyncPush, authService))
 	r.mux.HandleFunc("GET /api/auth/status", r.wrap(authHandlers.Status))
 	r.mux.Handle("POST /api/auth/register", r.server.RegisterLimiter().Middleware(http.HandlerFunc(r.wrap(authHandlers.Register))))
 	r.mux.Handle("POST /api/auth/login", r.server.LoginLimiter().Middleware(http.HandlerFunc(r.wrap(authHandlers.Login))))
 	r.mux.HandleFunc("POST /api/auth/refresh", r.wrap(authHandlers.Refresh))
 	r.mux.HandleFunc("POST /api/auth/logout", r.wrap(authHandlers.Logout))
 	r.mux.HandleFunc("GET /api/auth/providers", r.wrap(authHandlers.Providers))
+	if r.server.cfg.Auth.JWT.Asymmetric() {
+		r.mux.HandleFunc("GET /.well-known/jwks.json", r.wrap(authHandlers.JWKS))
+	}
 	r.mux.HandleFunc("GET /api/auth/oauth/{provider}", r.wrap(authHandlers.OAuthRedirect))
 	r.mux.HandleFunc("GET /api/auth/oauth/{provider}/callback", r.wrap(authHandlers.OAuthCallback))
+	r.mux.Handle("POST /api/auth/oauth/exchange", r.server.LoginLimiter().Middleware(http.HandlerFunc(r.wrap(authHandlers.OAuthExchange))))
 	r.mux.HandleFunc("GET /api/auth/me", r.wrapWithAuth(authHandlers.Me, authHandlers.Service()))
+	r.mux.HandleFunc("PATCH /api/auth/me", r.wrapWithAuth(authHandlers.UpdateMe, authHandlers.Service()))
+	r.mux.HandleFunc("DELETE /api/auth/me", r.wrapWithAuth(authHandlers.DeleteMe, authHandlers.Service()))
+	r.mux.HandleFunc("GET /api/auth/me/limits", r.wrapWithAuth(authHandlers.Limits, authHandlers.Service()))
+	r.mux.Handle("GET /api/auth/me/restore", r.server.PasswordLimiter().Middleware(http.HandlerFunc(r.wrap(authHandlers.RestoreMe))))
+	r.mux.Handle("POST /api/auth/me/restore", r.server.PasswordLimiter().Middleware(http.HandlerFunc(r.wrap(authHandlers.RestoreMe))))
+	r.mux.Handle("POST /api/auth/verify/request", r.server.PasswordLimiter().Middleware(http.HandlerFunc(r.wrap(authHandlers.RequestVerification))))
+	r.mux.HandleFunc("GET /api/auth/verify", r.wrap(authHandlers.Verify))
+	r.mux.HandleFunc("POST /api/auth/verify", r.wrap(authHandlers.Verify))
+	r.mux.Handle("POST /api/auth/password/forgot", r.server.PasswordLimiter().Middleware(http.HandlerFunc(r.wrap(authHandlers.ForgotPassword))))
+	r.mux.HandleFunc("GET /api/auth/password/reset", r.wrap(authHandlers.ResetPasswordPage))
+	r.mux.Handle("POST /api/auth/password/reset", r.server.LoginLimiter().Middleware(http.HandlerFunc(r.wrap(authHandlers.ResetPassword))))
 
 	if r.server.cfg.Docs.Enabled {
 		docs := handlers.NewDocsHandler(r.server.Schema(), r.server.Config())
 		r.mux.HandleFunc("GET /api/openapi.json", r.wrap(docs.OpenAPISpec))
-		r.mux.HandleFunc("GET /api/docs", r.wrap(docs.DocsUI))
-		r.mux.HandleFunc("GET /api/docs/", r.wrap(docs.DocsUI))
+		r.mux.HandleFunc("GET /api/docs", r.wrap(docs.RedirectToDefaultUI))
+		r.mux.HandleFunc("GET /api/docs/{$}", r.wrap(docs.RedirectToDefaultUI))
+		r.mux.HandleFunc("GET /api/docs/openapi.json", r.wrap(docs.OpenAPISpec))
+		r.mux.HandleFunc("GET /api/docs/openapi.yaml", r.wrap(docs.OpenAPISpecYAML))
+		r.mux.HandleFunc("GET /api/docs/{ui}", r.wrap(docs.DocsUI))
 	}
 
 	if r.server.cfg.Realtime.Enabled && r.server.Broker() != nil {
 		rt := handlers.NewRealtimeHandler(r.server.Broker())
-		r.mux.HandleFunc("GET /api/realtime", rt.HandleWebSocket)
+		r.mux.HandleFunc("GET /api/realtime", r.wrapWithOptionalAuth(func(w http.ResponseWriter, req *http.Request) {
+			if database.DBFromContext(req.Context(), nil) != nil {
+				if broker := r.server.Sandbox().Broker(); broker != nil {
+					handlers.NewRealtimeHandler(broker).HandleWebSocket(w, req)
+					return
+				}
+			}
+			rt.HandleWebSocket(w, req)
+		}, authService))
 	}
 
 	if r.server.cfg.Functions.Enabled && r.server.FuncService() != nil {
@@ -137,11 +226,6 @@ func (r *Router) setupRoutes() {
 		r.mux.HandleFunc("POST /internal/v1/db/tx", r.wrap(internalHandlers.Transaction))
 	}
 
-	logsHandlers := handlers.NewLogsHandlers(r.server.RequestLogs())
-	r.mux.HandleFunc("GET /api/admin/logs", r.wrap(logsHandlers.List))
-	r.mux.HandleFunc("GET /api/admin/logs/stats", r.wrap(logsHandlers.Stats))
-	r.mux.HandleFunc("POST /api/admin/logs/clear", r.wrap(logsHandlers.Clear))
-
 	if r.server.StorageService() != nil {
 		fileHandlers := handlers.NewFileHandlers(
 			r.server.StorageService(),
@@ -226,35 +310,84 @@ func (r *Router) setupRoutes() {
 			r.server.SchemaPath(),
 			r.server.ConfigPath(),
 		)
+		adminHandlers.SetRetentionService(r.server.RetentionService())
+		adminHandlers.SetChangeFeed(r.server.ChangeFeed())
+		adminHandlers.SetStorageService(r.server.StorageService())
+		adminHandlers.SetMailer(r.server.Mailer())
+		adminHandlers.SetRequestLogs(r.server.RequestLogs())
+		adminHandlers.SetSchemaApplied(r.server.SchemaApplied)
+		adminHandlers.SetMaintenance(r.server.SetMaintenance)
+		if sb := r.server.Sandbox(); sb != nil {
+			adminHandlers.SetSandboxReset(func(ctx context.Context) (string, error) {
+				return sb.Path(), sb.Reset(ctx)
+			})
+		}
+		r.adminHandlers = adminHandlers
+		if r.server.cfg.Realtime.Enabled {
+			adminHandlers.SetBroker(r.server.Broker())
+		}
+		if broker := r.server.Broker(); broker != nil {
+			adminHandlers.SetEventPublisher(broker)
+		}
 		r.mux.HandleFunc("GET /api/admin/stats", r.wrap(adminHandlers.Stats))
 		r.mux.HandleFunc("GET /api/admin/storage/stats", r.wrap(adminHandlers.StorageStats))
+		r.mux.HandleFunc("POST /api/admin/storage/{bucket}/gc", r.wrap(adminHandlers.StorageGC))
+		r.mux.HandleFunc("GET /api/admin/storage/gc/{job}", r.wrap(adminHandlers.StorageGCJob))
+		r.mux.HandleFunc("GET /api/admin/retention/preview", r.wrap(adminHandlers.RetentionPreview))
+		r.mux.HandleFunc("GET /api/admin/changes", r.wrap(adminHandlers.ChangeFeed))
+		r.mux.HandleFunc("GET /api/admin/advisor/indexes", r.wrap(adminHandlers.IndexAdvisor))
+		r.mux.HandleFunc("POST /api/admin/collections/{name}/explain", r.wrap(adminHandlers.ExplainQuery))
+		r.mux.HandleFunc("POST /api/admin/collections/{name}/fake", r.wrap(adminHandlers.FakeDocuments))
+		r.mux.HandleFunc("POST /api/admin/db/maintenance", r.wrap(adminHandlers.DBMaintenance))
+		r.mux.HandleFunc("POST /api/admin/sandbox/reset", r.wrap(adminHandlers.SandboxReset))
+		r.mux.HandleFunc("POST /api/admin/email/test", r.wrap(adminHandlers.TestEmail))
+		r.mux.HandleFunc("GET /api/admin/realtime/connections", r.wrap(adminHandlers.RealtimeConnections))
+		r.mux.HandleFunc("DELETE /api/admin/realtime/connections/{id}", r.wrap(adminHandlers.RealtimeDisconnect))
+		r.mux.HandleFunc("GET /api/admin/realtime/subscriptions", r.wrap(adminHandlers.RealtimeSubscriptions))
 		r.mux.HandleFunc("POST /api/admin/deploy/prepare", r.wrap(adminHandlers.DeployPrepare))
 		r.mux.HandleFunc("POST /api/admin/deploy/execute", r.wrap(adminHandlers.DeployExecute))
 		r.mux.HandleFunc("POST /api/admin/deploy/rollback", r.wrap(adminHandlers.DeployRollback))
 		r.mux.HandleFunc("GET /api/admin/deploy/history", r.wrap(adminHandlers.DeployHistory))
 		r.mux.HandleFunc("GET /api/admin/schema", r.wrap(adminHandlers.SchemaGet))
+		r.mux.HandleFunc("GET /api/admin/schema/graph", r.wrap(adminHandlers.SchemaGraph))
 		r.mux.HandleFunc("GET /api/admin/schema/raw", r.wrap(adminHandlers.SchemaRawGet))
 		r.mux.HandleFunc("PUT /api/admin/schema/raw", r.wrap(adminHandlers.SchemaRawUpdate))
 		r.mux.HandleFunc("POST /api/admin/schema/validate-rule", r.wrap(adminHandlers.ValidateRule))
 		r.mux.HandleFunc("GET /api/admin/schema/pending-changes", r.wrap(adminHandlers.SchemaPendingChanges))
 		r.mux.HandleFunc("POST /api/admin/schema/confirm-changes", r.wrap(adminHandlers.SchemaConfirmChanges))
 		r.mux.HandleFunc("POST /api/admin/schema/cancel-changes", r.wrap(adminHandlers.SchemaCancelChanges))
+		r.mux.HandleFunc("POST /api/admin/schema/changes/schedule", r.wrap(adminHandlers.SchemaScheduleChanges))
+		r.mux.HandleFunc("GET /api/admin/schema/changes/scheduled", r.wrap(adminHandlers.SchemaScheduledChanges))
+		r.mux.HandleFunc("DELETE /api/admin/schema/changes/scheduled/{id}", r.wrap(adminHandlers.SchemaCancelScheduledChanges))
 		r.mux.HandleFunc("PUT /api/admin/schema", r.wrap(adminHandlers.SchemaDraftPreview))
 		r.mux.HandleFunc("POST /api/admin/schema/apply", r.wrap(adminHandlers.SchemaDraftApply))
 		r.mux.HandleFunc("DELETE /api/admin/schema/draft", r.wrap(adminHandlers.SchemaDraftCancel))
 		r.mux.HandleFunc("GET /api/admin/config/raw", r.wrap(adminHandlers.ConfigRawGet))
 		r.mux.HandleFunc("PUT /api/admin/config/raw", r.wrap(adminHandlers.ConfigRawUpdate))
+		r.mux.HandleFunc("PATCH /api/admin/config", r.wrap(adminHandlers.ConfigPatch))
 		r.mux.HandleFunc("GET /api/admin/config/schema", r.wrap(adminHandlers.ConfigSchemaGet))
+		r.mux.HandleFunc("POST /api/admin/auth/rotate-secret", r.wrap(adminHandlers.RotateJWTSecret))
+		r.mux.HandleFunc("POST /api/auth/introspect", r.wrap(adminHandlers.Introspect))
 		r.mux.HandleFunc("POST /api/admin/tokens", r.wrap(adminHandlers.TokenCreate))
 		r.mux.HandleFunc("GET /api/admin/tokens", r.wrap(adminHandlers.TokenList))
 		r.mux.HandleFunc("DELETE /api/admin/tokens/{name}", r.wrap(adminHandlers.TokenDelete))
+		r.mux.HandleFunc("POST /api/admin/tokens/{name}/rotate", r.wrap(adminHandlers.TokenRotate))
 
 		r.mux.HandleFunc("GET /api/admin/users", r.wrap(adminHandlers.UserList))
 		r.mux.HandleFunc("POST /api/admin/users", r.wrap(adminHandlers.UserCreate))
+		r.mux.HandleFunc("GET /api/admin/users/export", r.wrap(adminHandlers.UserExport))
+		r.mux.HandleFunc("POST /api/admin/users/bulk", r.wrap(adminHandlers.UserBulk))
 		r.mux.HandleFunc("GET /api/admin/users/{id}", r.wrap(adminHandlers.UserGet))
 		r.mux.HandleFunc("PATCH /api/admin/users/{id}", r.wrap(adminHandlers.UserUpdate))
 		r.mux.HandleFunc("DELETE /api/admin/users/{id}", r.wrap(adminHandlers.UserDelete))
 		r.mux.HandleFunc("POST /api/admin/users/{id}/password", r.wrap(adminHandlers.UserSetPassword))
+		r.mux.HandleFunc("POST /api/admin/users/{id}/restore", r.wrap(adminHandlers.UserRestore))
+
+		logsHandlers := handlers.NewLogsHandlers(r.server.RequestLogs())
+		r.mux.HandleFunc("GET /api/admin/logs", r.wrap(adminHandlers.RequirePermission(deploy.PermissionRead, logsHandlers.List)))
+		r.mux.HandleFunc("GET /api/admin/logs/stats", r.wrap(adminHandlers.RequirePermission(deploy.PermissionRead, logsHandlers.Stats)))
+		r.mux.HandleFunc("GET /api/admin/logs/metrics", r.wrap(adminHandlers.RequirePermission(deploy.PermissionRead, logsHandlers.Metrics)))
+		r.mux.HandleFunc("POST /api/admin/logs/clear", r.wrap(adminHandlers.RequirePermission(deploy.PermissionAdmin, logsHandlers.Clear)))
 
 		r.mux.HandleFunc("GET /api/admin/buckets", r.wrap(adminHandlers.BucketList))
 		r.mux.HandleFunc("POST /api/admin/buckets", r.wrap(adminHandlers.BucketCreate))
@@ -290,7 +423,11 @@ func (r *Router) wrapWithOptionalAuth(fn handlers.HandlerFunc, authService *auth
 }
 
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	handler := http.Handler(r.mux)
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mux.ServeHTTP(w, req)
+		// The mux sets the pattern it matched on the request it was given.
+		requestlog.RecordRoute(w, req.Pattern)
+	}))
 
 	for i := len(r.middlewares) - 1; i >= 0; i-- {
 		handler = r.middlewares[i](handler)
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/realtime"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/server/handlers"
+)
+
+// SandboxHeader opts a request into the sandbox database in dev mode.
+const SandboxHeader = "X-Alyx-Sandbox"
+
+// Sandbox is a throwaway database that dev mode routes requests to instead of
+// the primary one. It is created on first use with the current schema, and
+// optionally a copy of the primary data, and is recreated by Reset or a
+// schema change. Only collection reads and writes, realtime subscriptions and
+// function callbacks use it; auth, files, views, admin endpoints and exists()
+// in rules keep using the primary database.
+type Sandbox struct {
+	cfg     *config.Config
+	primary *database.DB
+	rules   *rules.Engine
+	path    string
+
+	mu     sync.Mutex
+	schema *schema.Schema
+	db     *database.DB
+	broker *realtime.Broker
+}
+
+// NewSandbox returns a sandbox next to the primary database. Nothing is
+// created until the sandbox is first used.
+func NewSandbox(cfg *config.Config, primary *database.DB, s *schema.Schema, rulesEngine *rules.Engine) *Sandbox {
+	return &Sandbox{
+		cfg:     cfg,
+		primary: primary,
+		rules:   rulesEngine,
+		schema:  s,
+		path:    SandboxPath(cfg.Database.Path),
+	}
+}
+
+// SandboxPath returns where the sandbox for the database at path lives, such
+// as data/alyx.sandbox.db for data/alyx.db.
+func SandboxPath(path string) string {
+	if path == "" || path == ":memory:" {
+		return ":memory:"
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".sandbox" + ext
+}
+
+// Path returns the sandbox database file.
+func (sb *Sandbox) Path() string {
+	return sb.path
+}
+
+// DB returns the sandbox database, creating it if needed.
+func (sb *Sandbox) DB(ctx context.Context) (*database.DB, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	if sb.db == nil {
+		if err := sb.open(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return sb.db, nil
+}
+
+// Broker returns the realtime broker watching the sandbox, or nil if realtime
+// is disabled or the sandbox has not been created.
+func (sb *Sandbox) Broker() *realtime.Broker {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.broker
+}
+
+// Reset deletes the sandbox database and creates it again from scratch.
+func (sb *Sandbox) Reset(ctx context.Context) error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	if err := sb.discard(); err != nil {
+		return err
+	}
+	return sb.open(ctx)
+}
+
+// UpdateSchema discards the sandbox so that it is created with newSchema the
+// next time it is used.
+func (sb *Sandbox) UpdateSchema(newSchema *schema.Schema) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	sb.schema = newSchema
+	if sb.db == nil {
+		return
+	}
+	if err := sb.discard(); err != nil {
+		log.Warn().Err(err).Msg("Failed to discard sandbox database")
+	}
+}
+
+// Close closes the sandbox database, leaving its file in place.
+func (sb *Sandbox) Close() error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.close()
+}
+
+func (sb *Sandbox) open(ctx context.Context) error {
+	if sb.cfg.Dev.SandboxSeed && sb.path != ":memory:" {
+		if err := sb.seed(ctx); err != nil {
+			return err
+		}
+	}
+
+	dbCfg := sb.cfg.Database
+	dbCfg.Path = sb.path
+	dbCfg.Turso = nil
+	db, err := database.Open(&dbCfg)
+	if err != nil {
+		return fmt.Errorf("opening sandbox database: %w", err)
+	}
+
+	for _, stmt := range schema.NewSQLGenerator(sb.schema).GenerateAll() {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return fmt.Errorf("applying schema to sandbox database: %w", err)
+		}
+	}
+	if err := db.ConfigureFieldEncryption(&sb.cfg.Security, sb.schema); err != nil {
+		db.Close()
+		return fmt.Errorf("configuring sandbox field encryption: %w", err)
+	}
+
+	if sb.cfg.Realtime.Enabled {
+		broker := realtime.NewBroker(db, sb.schema, sb.rules, &realtime.BrokerConfig{
+			PollInterval:      sb.cfg.Realtime.PollInterval.Milliseconds(),
+			Mode:              realtime.DetectionMode(sb.cfg.Realtime.Mode),
+			MaxConnections:    sb.cfg.Realtime.MaxConnections,
+			BufferSize:        sb.cfg.Realtime.ChangeBufferSize,
+			PingInterval:      sb.cfg.Realtime.PingInterval,
+			MaxMissedPongs:    sb.cfg.Realtime.MaxMissedPongs,
+			WriteTimeout:      sb.cfg.Realtime.WriteTimeout,
+			SlowClientTimeout: sb.cfg.Realtime.SlowClientTimeout,
+		})
+		// The broker outlives the request that created the sandbox.
+		if err := broker.Start(context.WithoutCancel(ctx)); err != nil {
+			db.Close()
+			return fmt.Errorf("starting sandbox realtime broker: %w", err)
+		}
+		sb.broker = broker
+	}
+
+	sb.db = db
+	log.Info().Str("path", sb.path).Bool("seeded", sb.cfg.Dev.SandboxSeed).Msg("Sandbox database created")
+	return nil
+}
+
+// seed copies the primary database to the sandbox path.
+func (sb *Sandbox) seed(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Dir(sb.path), 0o755); err != nil {
+		return fmt.Errorf("creating sandbox directory: %w", err)
+	}
+	if _, err := sb.primary.ExecContext(ctx, "VACUUM INTO ?", sb.path); err != nil {
+		return fmt.Errorf("seeding sandbox database: %w", err)
+	}
+	return nil
+}
+
+func (sb *Sandbox) close() error {
+	if sb.broker != nil {
+		sb.broker.Stop()
+		sb.broker = nil
+	}
+	if sb.db == nil {
+		return nil
+	}
+	err := sb.db.Close()
+	sb.db = nil
+	return err
+}
+
+// discard closes the sandbox and deletes its files.
+func (sb *Sandbox) discard() error {
+	if err := sb.close(); err != nil {
+		log.Warn().Err(err).Msg("Error closing sandbox database")
+	}
+	if sb.path == ":memory:" {
+		return nil
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Remove(sb.path + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing sandbox database: %w", err)
+		}
+	}
+	return nil
+}
+
+// SandboxMiddleware routes requests to the sandbox database when always is
+// set or the request has an X-Alyx-Sandbox: true header. Sandboxed responses
+// carry the same header. It is only installed in dev mode.
+func SandboxMiddleware(sb *Sandbox, always bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !always && !strings.EqualFold(r.Header.Get(SandboxHeader), "true") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.URL.Query().Get("tx_id") != "" {
+				handlers.Error(w, http.StatusBadRequest, "SANDBOX_TRANSACTION", "Transactions are not available in the sandbox")
+				return
+			}
+
+			db, err := sb.DB(r.Context())
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to create sandbox database")
+				handlers.InternalError(w, "Failed to create sandbox database")
+				return
+			}
+
+			w.Header().Set(SandboxHeader, "true")
+			next.ServeHTTP(w, r.WithContext(database.WithDB(r.Context(), db)))
+		})
+	}
+}
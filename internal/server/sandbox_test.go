@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSandboxPath(t *testing.T) {
+	tests := map[string]string{
+		"data/alyx.db": "data/alyx.sandbox.db",
+		"app.sqlite":   "app.sandbox.sqlite",
+		"data/alyx":    "data/alyx.sandbox",
+		":memory:":     ":memory:",
+	}
+	for path, want := range tests {
+		if got := SandboxPath(path); got != want {
+			t.Errorf("SandboxPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	base := setupTestServer(t)
+	base.cfg.Dev.Enabled = true
+	s := New(base.cfg, base.db, base.schema)
+	t.Cleanup(func() { s.sandbox.Close() })
+	handler := s.router
+
+	do := func(method, path, body string, sandboxed bool) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if sandboxed {
+			req.Header.Set(SandboxHeader, "true")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	count := func(sandboxed bool) int {
+		t.Helper()
+		w := do(http.MethodGet, "/api/collections/users", "", sandboxed)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list: status %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Docs []map[string]any `json:"docs"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return len(resp.Docs)
+	}
+
+	w := do(http.MethodPost, "/api/collections/users", `{"name":"Sandy","email":"sandy@example.com"}`, true)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get(SandboxHeader) != "true" {
+		t.Errorf("expected a sandboxed response to carry %s", SandboxHeader)
+	}
+	if n := count(true); n != 1 {
+		t.Errorf("sandbox has %d users, want 1", n)
+	}
+	if n := count(false); n != 0 {
+		t.Errorf("primary has %d users, want 0", n)
+	}
+
+	if w := do(http.MethodGet, "/api/collections/users?tx_id=abc", "", true); w.Code != http.StatusBadRequest {
+		t.Errorf("sandboxed transaction: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = do(http.MethodPost, "/api/auth/register", `{"email":"admin@example.com","password":"SecurePass123!"}`, false)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", w.Code, w.Body.String())
+	}
+	var registered struct {
+		Tokens struct {
+			AccessToken string `json:"access_token"`
+		} `json:"tokens"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &registered); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/sandbox/reset", nil)
+	req.Header.Set("Authorization", "Bearer "+registered.Tokens.AccessToken)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reset: status %d: %s", w.Code, w.Body.String())
+	}
+	if n := count(true); n != 0 {
+		t.Errorf("sandbox has %d users after a reset, want 0", n)
+	}
+}
+
+func TestSandbox_NotInProduction(t *testing.T) {
+	s := setupTestServer(t)
+	if s.Sandbox() != nil {
+		t.Fatal("expected no sandbox outside dev mode")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/collections/users", nil)
+	req.Header.Set(SandboxHeader, "true")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Header().Get(SandboxHeader) != "" {
+		t.Errorf("expected the sandbox header to be ignored outside dev mode")
+	}
+}
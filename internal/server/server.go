@@ -4,26 +4,38 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/buildinfo"
+	"github.com/watzon/alyx/internal/changefeed"
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database"
 	"github.com/watzon/alyx/internal/deploy"
+	"github.com/watzon/alyx/internal/email"
 	"github.com/watzon/alyx/internal/events"
 	"github.com/watzon/alyx/internal/executions"
 	"github.com/watzon/alyx/internal/functions"
 	"github.com/watzon/alyx/internal/hooks"
 	"github.com/watzon/alyx/internal/realtime"
+	"github.com/watzon/alyx/internal/retention"
 	"github.com/watzon/alyx/internal/rules"
 	"github.com/watzon/alyx/internal/scheduler"
 	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/server/handlers"
 	"github.com/watzon/alyx/internal/server/requestlog"
 	"github.com/watzon/alyx/internal/storage"
+	"github.com/watzon/alyx/internal/store"
+	"github.com/watzon/alyx/internal/tracing"
 	"github.com/watzon/alyx/internal/transactions"
+	"github.com/watzon/alyx/internal/views"
 	"github.com/watzon/alyx/internal/webhooks"
 )
 
@@ -40,11 +52,18 @@ type Server struct {
 	deployService       *deploy.Service
 	requestLogs         *requestlog.Store
 	httpServer          *http.Server
+	listener            net.Listener
 	router              *Router
 	storageService      *storage.Service
+	storageBackends     []string
 	tusService          *storage.TUSService
 	signedService       *storage.SignedURLService
 	cleanupService      *storage.CleanupService
+	gcService           *storage.GCService
+	retentionService    *retention.Service
+	changeFeed          *changefeed.Feed
+	viewService         *views.Service
+	checkpointer        *database.Checkpointer
 	eventBus            *events.EventBus
 	webhookStore        *webhooks.Store
 	webhookRetryWorker  *webhooks.RetryWorker
@@ -54,8 +73,13 @@ type Server struct {
 	scheduler           *scheduler.Scheduler
 	loginLimiter        *RateLimiter
 	registerLimiter     *RateLimiter
+	passwordLimiter     *RateLimiter
+	mailer              *email.Mailer
 	bruteForceProtector *BruteForceProtector
 	transactionManager  *transactions.Manager
+	sandbox             *Sandbox
+	tracingShutdown     func(context.Context) error
+	maintenance         atomic.Bool
 	mu                  sync.RWMutex
 }
 
@@ -89,20 +113,42 @@ func New(cfg *config.Config, db *database.DB, s *schema.Schema, opts ...Option)
 		opt(srv)
 	}
 
+	handlers.SetErrorFormat(cfg.Server.ErrorFormat)
+
+	for _, warning := range config.Warnings(cfg) {
+		log.Warn().Str("field", warning.Field).Msg(warning.Message)
+	}
+
 	rulesEngine, err := rules.NewEngine()
 	if err != nil {
 		log.Warn().Err(err).Msg("Failed to create rules engine, access control disabled")
-	} else if err := rulesEngine.LoadSchema(s); err != nil {
-		log.Warn().Err(err).Msg("Failed to load schema rules, access control disabled")
-		rulesEngine = nil
+	} else {
+		rulesEngine.SetStore(store.NewSQLiteDocuments(db, nil))
+		if err := rulesEngine.LoadSchema(s); err != nil {
+			log.Warn().Err(err).Msg("Failed to load schema rules, access control disabled")
+			rulesEngine = nil
+		}
 	}
 	srv.rules = rulesEngine
 
 	if cfg.Realtime.Enabled {
+		cleanupAge := cfg.Realtime.CleanupAge
+		if cfg.Changes.Enabled {
+			// Keep changes until change feed consumers are done with them.
+			cleanupAge = max(cleanupAge, cfg.Changes.Retention)
+		}
 		brokerCfg := &realtime.BrokerConfig{
-			PollInterval:   cfg.Realtime.PollInterval.Milliseconds(),
-			MaxConnections: cfg.Realtime.MaxConnections,
-			BufferSize:     cfg.Realtime.ChangeBufferSize,
+			PollInterval:    cfg.Realtime.PollInterval.Milliseconds(),
+			Mode:            realtime.DetectionMode(cfg.Realtime.Mode),
+			MaxConnections:  cfg.Realtime.MaxConnections,
+			BufferSize:      cfg.Realtime.ChangeBufferSize,
+			CleanupInterval: cfg.Realtime.CleanupInterval,
+			CleanupAge:      cleanupAge,
+
+			PingInterval:      cfg.Realtime.PingInterval,
+			MaxMissedPongs:    cfg.Realtime.MaxMissedPongs,
+			WriteTimeout:      cfg.Realtime.WriteTimeout,
+			SlowClientTimeout: cfg.Realtime.SlowClientTimeout,
 		}
 		srv.broker = realtime.NewBroker(db, s, rulesEngine, brokerCfg)
 	}
@@ -137,11 +183,14 @@ func New(cfg *config.Config, db *database.DB, s *schema.Schema, opts ...Option)
 		}
 	}
 
-	schemaPath := "schema.yaml"
-	deployService := deploy.NewService(db.DB, schemaPath, cfg.Functions.Path, "migrations")
+	deployService := deploy.NewService(db.DB, srv.schemaPath, cfg.Functions.Path, "migrations")
+	if fc := db.FieldCipher(); fc != nil {
+		deployService.SetKeyCipher(fc)
+	}
 	if err := deployService.Init(); err != nil {
 		log.Warn().Err(err).Msg("Failed to initialize deploy service")
 	} else {
+		deployService.SetActivate(srv.ActivateDeployment)
 		srv.deployService = deployService
 	}
 
@@ -187,21 +236,53 @@ func New(cfg *config.Config, db *database.DB, s *schema.Schema, opts ...Option)
 			}
 
 			backends[name] = backend
+			srv.storageBackends = append(srv.storageBackends, fmt.Sprintf("%s (%s)", name, backendCfg.Type))
 		}
 
 		if len(backends) > 0 {
 			srv.storageService = storage.NewService(db, backends, s, cfg, rulesEngine)
 			srv.tusService = storage.NewTUSService(db, backends, s, cfg, "./tmp")
-			srv.signedService = storage.NewSignedURLService([]byte(cfg.Auth.JWT.Secret))
+			srv.signedService = storage.NewSignedURLService([]byte(cfg.Auth.JWT.SigningSecret()))
 			srv.cleanupService = storage.NewCleanupService(storage.NewTUSStore(db), "./tmp", 1*time.Hour)
+			srv.gcService = storage.NewGCService(srv.storageService)
 		}
 	}
 
 	srv.loginLimiter = NewRateLimiter(cfg.Auth.RateLimit.Login)
 	srv.registerLimiter = NewRateLimiter(cfg.Auth.RateLimit.Register)
+	passwordRule := cfg.Auth.RateLimit.PasswordReset
+	if passwordRule.Max < 1 || passwordRule.Window <= 0 {
+		passwordRule = config.Default().Auth.RateLimit.PasswordReset
+	}
+	srv.passwordLimiter = NewRateLimiter(passwordRule)
 	srv.bruteForceProtector = NewBruteForceProtector(5, 15*time.Minute)
 
+	mailer, err := email.New(&cfg.Email, cfg.Server.URL())
+	if err != nil {
+		log.Error().Err(err).Str("templates_dir", cfg.Email.TemplatesDir).Msg("Failed to load email templates, using built-in templates")
+		builtin := cfg.Email
+		builtin.TemplatesDir = ""
+		mailer, _ = email.New(&builtin, cfg.Server.URL())
+	}
+	srv.mailer = mailer
+	if !cfg.Email.Enabled {
+		log.Info().Msg("Email is disabled; verification and password reset links will be logged")
+	}
+
 	srv.transactionManager = transactions.NewManager(db)
+	srv.retentionService = retention.NewService(db, s, &cfg.Retention)
+	if cfg.Changes.Enabled {
+		srv.changeFeed = changefeed.New(db, s, &cfg.Changes, cfg.Realtime.Enabled)
+	}
+	srv.viewService = views.NewService(db, s)
+	db.SetWriteListener(srv.viewService.NotifyWrite)
+	if cfg.Database.Checkpoint.Enabled && cfg.Database.WALMode() {
+		srv.checkpointer = database.NewCheckpointer(db, &cfg.Database.Checkpoint)
+	}
+
+	if cfg.Dev.Enabled {
+		srv.sandbox = NewSandbox(cfg, db, s, rulesEngine)
+	}
 
 	srv.router = NewRouter(srv)
 	srv.httpServer = &http.Server{
@@ -215,10 +296,62 @@ func New(cfg *config.Config, db *database.DB, s *schema.Schema, opts ...Option)
 	return srv
 }
 
+// Start starts the server and serves requests until it is shut down.
 func (s *Server) Start(ctx context.Context) error {
+	if _, err := s.Listen(ctx); err != nil {
+		return err
+	}
+	return s.Serve()
+}
+
+// Listen binds the server's address and starts its background services,
+// returning the bound address. The address is bound first, so a port in use
+// fails before anything is started. Serve then serves requests.
+func (s *Server) Listen(ctx context.Context) (net.Addr, error) {
+	ln, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return nil, err
+	}
+	s.listener = ln
+
 	log.Info().
-		Str("addr", s.cfg.Server.Address()).
+		Str("addr", ln.Addr().String()).
+		Str("version", buildinfo.Version).
 		Msg("Starting server")
+	fmt.Fprint(os.Stderr, s.banner(ln.Addr()))
+
+	if err := s.startServices(ctx); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln.Addr(), nil
+}
+
+// Serve serves requests on the address bound by Listen until the server is
+// shut down.
+func (s *Server) Serve() error {
+	if s.listener == nil {
+		return errors.New("server is not listening")
+	}
+	err := s.httpServer.Serve(s.listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) startServices(ctx context.Context) error {
+	shutdownTracing, err := tracing.Setup(ctx, &s.cfg.Observability.Tracing)
+	if err != nil {
+		return fmt.Errorf("setting up tracing: %w", err)
+	}
+	s.tracingShutdown = shutdownTracing
+	if tracing.Enabled() {
+		log.Info().
+			Str("endpoint", s.cfg.Observability.Tracing.Endpoint).
+			Float64("sample_ratio", s.cfg.Observability.Tracing.SampleRatio).
+			Msg("Tracing enabled")
+	}
 
 	if s.broker != nil {
 		if err := s.broker.Start(ctx); err != nil {
@@ -263,11 +396,36 @@ func (s *Server) Start(ctx context.Context) error {
 		log.Info().Msg("Storage cleanup service started")
 	}
 
-	err := s.httpServer.ListenAndServe()
-	if errors.Is(err, http.ErrServerClosed) {
-		return nil
+	if s.gcService != nil {
+		s.gcService.Start(ctx)
 	}
-	return err
+
+	if s.retentionService != nil && s.cfg.Retention.Enabled {
+		s.retentionService.Start(ctx)
+	}
+
+	if s.changeFeed != nil {
+		s.changeFeed.Start(ctx)
+	}
+
+	if s.viewService != nil {
+		s.viewService.Start(ctx)
+	}
+
+	if s.checkpointer != nil {
+		s.checkpointer.Start(ctx)
+	}
+
+	if s.router != nil && s.router.authService != nil {
+		s.router.authService.StartDeletionSweeper(ctx)
+		s.router.authService.StartSessionMetrics(ctx)
+	}
+
+	if s.router != nil && s.router.adminHandlers != nil {
+		s.router.adminHandlers.StartScheduledApplies(ctx)
+	}
+
+	return nil
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
@@ -301,6 +459,43 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		log.Info().Msg("Storage cleanup service stopped")
 	}
 
+	if s.gcService != nil {
+		s.gcService.Stop()
+	}
+
+	if s.retentionService != nil && s.cfg.Retention.Enabled {
+		s.retentionService.Stop()
+		log.Info().Msg("Retention service stopped")
+	}
+
+	if s.changeFeed != nil {
+		s.changeFeed.Stop()
+		log.Info().Msg("Change feed stopped")
+	}
+
+	if s.viewService != nil {
+		s.viewService.Stop()
+	}
+
+	if s.checkpointer != nil {
+		s.checkpointer.Stop()
+		log.Info().Msg("WAL checkpointer stopped")
+	}
+
+	if s.router != nil && s.router.authService != nil {
+		s.router.authService.Stop()
+	}
+
+	if s.router != nil && s.router.adminHandlers != nil {
+		s.router.adminHandlers.StopScheduledApplies()
+	}
+
+	if s.sandbox != nil {
+		if err := s.sandbox.Close(); err != nil {
+			log.Warn().Err(err).Msg("Error closing sandbox database")
+		}
+	}
+
 	if s.transactionManager != nil {
 		if err := s.transactionManager.Close(); err != nil {
 			log.Warn().Err(err).Msg("Error closing transaction manager")
@@ -314,11 +509,22 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	if s.registerLimiter != nil {
 		s.registerLimiter.Stop()
 	}
+	if s.passwordLimiter != nil {
+		s.passwordLimiter.Stop()
+	}
 	if s.bruteForceProtector != nil {
 		s.bruteForceProtector.Stop()
 	}
 
-	return s.httpServer.Shutdown(ctx)
+	err := s.httpServer.Shutdown(ctx)
+
+	if s.tracingShutdown != nil {
+		if tErr := s.tracingShutdown(ctx); tErr != nil {
+			log.Warn().Err(tErr).Msg("Error flushing traces")
+		}
+	}
+
+	return err
 }
 
 func (s *Server) DB() *database.DB {
@@ -333,10 +539,26 @@ func (s *Server) Config() *config.Config {
 	return s.cfg
 }
 
+// Handler returns the router serving the whole API, for mounting the server
+// without Listen, such as in an httptest.Server.
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// AuthService returns the auth service behind the /api/auth endpoints.
+func (s *Server) AuthService() *auth.Service {
+	return s.router.authService
+}
+
 func (s *Server) Broker() *realtime.Broker {
 	return s.broker
 }
 
+// Sandbox returns the dev sandbox, or nil outside dev mode.
+func (s *Server) Sandbox() *Sandbox {
+	return s.sandbox
+}
+
 func (s *Server) Rules() *rules.Engine {
 	return s.rules
 }
@@ -361,6 +583,33 @@ func (s *Server) CleanupService() *storage.CleanupService {
 	return s.cleanupService
 }
 
+func (s *Server) RetentionService() *retention.Service {
+	return s.retentionService
+}
+
+// ChangeFeed returns the change feed, or nil when it is disabled.
+func (s *Server) ChangeFeed() *changefeed.Feed {
+	return s.changeFeed
+}
+
+// SetMaintenance turns maintenance mode on or off. While it is on, API
+// requests other than admin requests are answered with 503.
+func (s *Server) SetMaintenance(on bool) {
+	if s.maintenance.Swap(on) != on {
+		log.Info().Bool("maintenance", on).Msg("Maintenance mode changed")
+	}
+}
+
+// Maintenance reports whether maintenance mode is on.
+func (s *Server) Maintenance() bool {
+	return s.maintenance.Load()
+}
+
+// ViewService returns the service that refreshes materialized views.
+func (s *Server) ViewService() *views.Service {
+	return s.viewService
+}
+
 func (s *Server) SchemaPath() string {
 	return s.schemaPath
 }
@@ -384,22 +633,80 @@ func (s *Server) GetCollection(name string) (*database.Collection, error) {
 }
 
 // UpdateSchema replaces the server's schema and reloads dependent components.
+// A schema whose rules fail to compile is rejected and the current schema
+// stays in place.
 func (s *Server) UpdateSchema(newSchema *schema.Schema) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.schema = newSchema
-
 	if s.rules != nil {
 		if err := s.rules.LoadSchema(newSchema); err != nil {
-			log.Warn().Err(err).Msg("Failed to reload schema rules")
+			return fmt.Errorf("loading schema rules: %w", err)
 		}
 	}
 
+	s.schema = newSchema
+
+	// Cached statements may reference dropped or altered tables.
+	if s.db != nil && s.db.Stmts() != nil {
+		s.db.Stmts().Purge()
+	}
+
 	if s.broker != nil {
 		s.broker.UpdateSchema(newSchema)
 	}
 
+	if s.retentionService != nil {
+		s.retentionService.UpdateSchema(newSchema)
+	}
+
+	if s.changeFeed != nil {
+		s.changeFeed.UpdateSchema(newSchema)
+	}
+
+	if s.storageService != nil {
+		s.storageService.UpdateSchema(newSchema)
+	}
+
+	if s.viewService != nil {
+		s.viewService.UpdateSchema(newSchema)
+	}
+
+	if s.sandbox != nil {
+		s.sandbox.UpdateSchema(newSchema)
+	}
+
+	if s.router != nil && s.router.authService != nil {
+		s.router.authService.SetRoles(newSchema.AllRoles())
+		s.router.authService.SetUserMetadata(newSchema.UserMetadata)
+		s.router.authService.SetTenantMetadataKeys(newSchema.TenantMetadataKeys())
+		s.router.authService.SetUserReferences(newSchema.UserReferences())
+	}
+
+	return nil
+}
+
+// SchemaApplied installs a schema whose changes have just been applied to the
+// database, from the dev file watcher, the admin schema editor or a deploy.
+// Besides reloading the server like UpdateSchema, it tells realtime clients
+// subscribed to the system channel which collections changed and, in dev
+// mode with dev.auto_generate on, regenerates the client SDKs.
+func (s *Server) SchemaApplied(newSchema *schema.Schema, collections []string) error {
+	if err := s.UpdateSchema(newSchema); err != nil {
+		return err
+	}
+
+	if s.broker != nil {
+		s.broker.BroadcastSystem(&realtime.SystemPayload{
+			Action:      realtime.SystemActionSchemaChanged,
+			Collections: collections,
+		})
+	}
+
+	if s.cfg.Dev.Enabled && s.cfg.Dev.AutoGenerate && len(s.cfg.Dev.GenerateLanguages) > 0 {
+		regenerateClients(newSchema, s.cfg)
+	}
+
 	return nil
 }
 
@@ -421,6 +728,19 @@ func (s *Server) ReloadFunctions() error {
 	return nil
 }
 
+// ActivateDeployment installs a deployed schema like SchemaApplied and
+// reloads functions from it, for deploy bundles that also replace the
+// functions directory.
+func (s *Server) ActivateDeployment(newSchema *schema.Schema, collections []string) error {
+	if err := s.SchemaApplied(newSchema, collections); err != nil {
+		return err
+	}
+	if s.funcService != nil {
+		s.funcService.UpdateSchema(newSchema)
+	}
+	return s.ReloadFunctions()
+}
+
 func (s *Server) DeployService() *deploy.Service {
 	return s.deployService
 }
@@ -437,6 +757,17 @@ func (s *Server) RegisterLimiter() *RateLimiter {
 	return s.registerLimiter
 }
 
+// PasswordLimiter rate limits endpoints that send verification and password
+// reset emails.
+func (s *Server) PasswordLimiter() *RateLimiter {
+	return s.passwordLimiter
+}
+
+// Mailer returns the mailer for auth and test emails.
+func (s *Server) Mailer() *email.Mailer {
+	return s.mailer
+}
+
 func (s *Server) BruteForceProtector() *BruteForceProtector {
 	return s.bruteForceProtector
 }
@@ -2,11 +2,15 @@ package server
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/watzon/alyx/internal/buildinfo"
 	"github.com/watzon/alyx/internal/config"
 	"github.com/watzon/alyx/internal/database"
 	"github.com/watzon/alyx/internal/schema"
@@ -32,6 +36,10 @@ func setupTestServer(t *testing.T) *Server {
 			Path: dbPath,
 		},
 		Auth: config.AuthConfig{
+			JWT: config.JWTConfig{
+				AccessTTL:  15 * time.Minute,
+				RefreshTTL: time.Hour,
+			},
 			RateLimit: config.AuthRateLimitConfig{
 				Login: config.RateLimitRule{
 					Max:    5,
@@ -162,6 +170,60 @@ func TestServer_StartStop(t *testing.T) {
 	}
 }
 
+func TestServer_ListenReturnsBoundAddress(t *testing.T) {
+	server := setupTestServer(t)
+	server.httpServer.Addr = "127.0.0.1:0"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr, err := server.Listen(ctx)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	if tcpAddr, ok := addr.(*net.TCPAddr); !ok || tcpAddr.Port == 0 {
+		t.Fatalf("expected a bound TCP port, got %v", addr)
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Serve() }()
+
+	resp, err := http.Get("http://" + addr.String() + "/health/live")
+	if err != nil {
+		t.Fatalf("request to bound address failed: %v", err)
+	}
+	resp.Body.Close()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		t.Errorf("shutdown failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("unexpected serve error: %v", err)
+	}
+}
+
+func TestServer_Banner(t *testing.T) {
+	server := setupTestServer(t)
+	server.cfg.Docs.Enabled = true
+
+	banner := server.banner(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4321})
+	for _, want := range []string{
+		"alyx " + buildinfo.Version,
+		"Listening  http://localhost:4321\n",
+		"Database   sqlite " + server.cfg.Database.Path + "\n",
+		"Functions  disabled\n",
+		"Realtime   disabled\n",
+		"Storage    none\n",
+		"Docs       http://localhost:4321/api/docs\n",
+	} {
+		if !strings.Contains(banner, want) {
+			t.Errorf("expected banner to contain %q, got:\n%s", want, banner)
+		}
+	}
+}
+
 func TestServer_GracefulShutdown(t *testing.T) {
 	server := setupTestServer(t)
 
@@ -229,6 +291,48 @@ collections:
 	}
 }
 
+func TestServer_SchemaApplied(t *testing.T) {
+	server := setupTestServer(t)
+	output := t.TempDir()
+	server.cfg.Dev = config.DevConfig{
+		Enabled:           true,
+		AutoGenerate:      true,
+		GenerateLanguages: []string{"typescript"},
+		GenerateOutput:    output,
+	}
+
+	newSchema, err := schema.Parse([]byte(`
+version: 1
+collections:
+  posts:
+    fields:
+      id: { type: uuid, primary: true, default: auto }
+      title: { type: string }
+`))
+	if err != nil {
+		t.Fatalf("parse new schema: %v", err)
+	}
+
+	if err := server.SchemaApplied(newSchema, []string{"posts", "users"}); err != nil {
+		t.Fatalf("SchemaApplied: %v", err)
+	}
+	if server.Schema() != newSchema {
+		t.Error("schema was not updated")
+	}
+	if _, err := os.Stat(filepath.Join(output, "typescript")); err != nil {
+		t.Errorf("expected client SDK to be regenerated: %v", err)
+	}
+
+	server.cfg.Dev.Enabled = false
+	server.cfg.Dev.GenerateOutput = filepath.Join(output, "prod")
+	if err := server.SchemaApplied(newSchema, nil); err != nil {
+		t.Fatalf("SchemaApplied: %v", err)
+	}
+	if _, err := os.Stat(server.cfg.Dev.GenerateOutput); !os.IsNotExist(err) {
+		t.Errorf("expected no regeneration outside dev mode, got %v", err)
+	}
+}
+
 func TestServer_Accessors(t *testing.T) {
 	server := setupTestServer(t)
 
@@ -0,0 +1,268 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/server/handlers"
+	"github.com/watzon/alyx/pkg/alyxtest"
+)
+
+const syncSchema = `
+version: 1
+collections:
+  notes:
+    fields:
+      id:
+        type: string
+        primary: true
+      owner:
+        type: string
+      title:
+        type: string
+    rules:
+      create: doc.owner == auth.id
+      read: doc.owner == auth.id
+      update: doc.owner == auth.id
+      delete: doc.owner == auth.id
+`
+
+func withChangeFeed(cfg *config.Config) {
+	cfg.Changes = config.ChangesConfig{Enabled: true, Retention: time.Hour, Include: config.ChangesIncludeKeys}
+}
+
+func TestSyncPull(t *testing.T) {
+	t.Parallel()
+	h := alyxtest.New(t, syncSchema, alyxtest.WithConfig(withChangeFeed))
+	alice := h.CreateUser("alice@example.com", "user")
+	bob := h.CreateUser("bob@example.com", "user")
+	token := h.Token(alice)
+
+	notes := h.Collection("notes")
+	for _, doc := range []database.Row{
+		{"id": "a", "owner": alice.ID, "title": "A"},
+		{"id": "b", "owner": alice.ID, "title": "B"},
+		{"id": "c", "owner": bob.ID, "title": "C"},
+		{"id": "d", "owner": alice.ID, "title": "D"},
+	} {
+		if _, err := notes.Create(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pull := func(since string) handlers.SyncPullResponse {
+		t.Helper()
+		w := h.Do(http.MethodGet, "/api/sync/notes?limit=2&since="+since, "", token)
+		if w.Code != http.StatusOK {
+			t.Fatalf("pull since %q: status %d: %s", since, w.Code, w.Body.String())
+		}
+		var resp handlers.SyncPullResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	ids := func(resp handlers.SyncPullResponse) string {
+		var ids []string
+		for _, change := range resp.Changes {
+			id := change.ID
+			if change.Deleted {
+				id += "-"
+			}
+			ids = append(ids, id)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	// The snapshot pages through the readable documents.
+	first := pull("")
+	if ids(first) != "a,b" || !first.HasMore || !strings.Contains(first.Cursor, ":") {
+		t.Fatalf("unexpected first snapshot page: %+v", first)
+	}
+	if first.Changes[0].Rev == "" || first.Changes[0].Document["title"] != "A" {
+		t.Errorf("expected the document and its revision, got %+v", first.Changes[0])
+	}
+	second := pull(first.Cursor)
+	if ids(second) != "d" || second.HasMore || strings.Contains(second.Cursor, ":") {
+		t.Fatalf("unexpected last snapshot page: %+v", second)
+	}
+	if got := pull(second.Cursor); len(got.Changes) != 0 {
+		t.Fatalf("expected no changes after the snapshot, got %s", ids(got))
+	}
+
+	// Changes since the snapshot come once per document, with tombstones.
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPatch, "/api/collections/notes/a", `{"title":"A1"}`},
+		{http.MethodPatch, "/api/collections/notes/a", `{"title":"A2"}`},
+		{http.MethodDelete, "/api/collections/notes/b", ""},
+	} {
+		if w := h.Do(req.method, req.path, req.body, token); w.Code >= 300 {
+			t.Fatalf("%s %s: status %d: %s", req.method, req.path, w.Code, w.Body.String())
+		}
+	}
+	if _, err := notes.Update(context.Background(), "c", database.Row{"title": "C1"}); err != nil {
+		t.Fatal(err)
+	}
+	changes := handlers.SyncPullResponse{Cursor: second.Cursor, HasMore: true}
+	var all []*handlers.SyncChange
+	for changes.HasMore {
+		changes = pull(changes.Cursor)
+		all = append(all, changes.Changes...)
+	}
+	if got := ids(handlers.SyncPullResponse{Changes: all}); got != "a,b-" {
+		t.Fatalf("unexpected changes: %s", got)
+	}
+	if all[0].Document["title"] != "A2" {
+		t.Errorf("expected the current document, got %v", all[0].Document)
+	}
+
+	// Once the history after a cursor is pruned, the client must resync.
+	if _, err := h.DB.Exec("DELETE FROM _alyx_changes WHERE id < (SELECT MAX(id) FROM _alyx_changes)"); err != nil {
+		t.Fatal(err)
+	}
+	w := h.Do(http.MethodGet, "/api/sync/notes?since="+second.Cursor, "", token)
+	if w.Code != http.StatusGone || !strings.Contains(w.Body.String(), "RESYNC_REQUIRED") {
+		t.Fatalf("expected 410 RESYNC_REQUIRED, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := h.Do(http.MethodGet, "/api/sync/notes?since=x", "", token); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid cursor, got %d", w.Code)
+	}
+}
+
+func TestSyncPush(t *testing.T) {
+	t.Parallel()
+	h := alyxtest.New(t, syncSchema, alyxtest.WithConfig(withChangeFeed))
+	alice := h.CreateUser("alice@example.com", "user")
+	bob := h.CreateUser("bob@example.com", "user")
+	token := h.Token(alice)
+
+	notes := h.Collection("notes")
+	for _, doc := range []database.Row{
+		{"id": "a", "owner": alice.ID, "title": "A"},
+		{"id": "b", "owner": alice.ID, "title": "B"},
+		{"id": "c", "owner": alice.ID, "title": "C"},
+		{"id": "d", "owner": bob.ID, "title": "D"},
+	} {
+		if _, err := notes.Create(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := h.Do(http.MethodGet, "/api/sync/notes", "", token)
+	var snapshot handlers.SyncPullResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &snapshot); err != nil || len(snapshot.Changes) != 3 {
+		t.Fatalf("unexpected snapshot %d: %s", w.Code, w.Body.String())
+	}
+	revs := map[string]string{}
+	for _, change := range snapshot.Changes {
+		revs[change.ID] = change.Rev
+	}
+
+	// Another client changes b first, so the offline edit of b conflicts.
+	if _, err := notes.Update(context.Background(), "b", database.Row{"title": "B from elsewhere"}); err != nil {
+		t.Fatal(err)
+	}
+
+	push := func(mutations ...map[string]any) *handlers.SyncPushResponse {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{"mutations": mutations})
+		w := h.Do(http.MethodPost, "/api/sync/notes", string(body), token)
+		if w.Code != http.StatusOK {
+			t.Fatalf("push: status %d: %s", w.Code, w.Body.String())
+		}
+		var resp handlers.SyncPushResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return &resp
+	}
+
+	resp := push(
+		map[string]any{"op": "create", "id": "e", "data": map[string]any{"owner": alice.ID, "title": "E"}},
+		map[string]any{"op": "update", "id": "a", "base_rev": revs["a"], "data": map[string]any{"title": "A1"}},
+		map[string]any{"op": "update", "id": "b", "base_rev": revs["b"], "data": map[string]any{"title": "B1"}},
+		map[string]any{"op": "delete", "id": "c", "base_rev": revs["c"]},
+	)
+	if len(resp.Results) != 4 {
+		t.Fatalf("expected a result per mutation, got %+v", resp.Results)
+	}
+	created, updated, conflict, deleted := resp.Results[0], resp.Results[1], resp.Results[2], resp.Results[3]
+	if created.Conflict || created.Document["title"] != "E" || created.Rev == "" {
+		t.Errorf("unexpected create result: %+v", created)
+	}
+	if updated.Conflict || updated.Document["title"] != "A1" || updated.Rev == revs["a"] {
+		t.Errorf("unexpected update result: %+v", updated)
+	}
+	if !conflict.Conflict || conflict.Document["title"] != "B from elsewhere" {
+		t.Errorf("expected a conflict carrying the server document, got %+v", conflict)
+	}
+	if !deleted.Deleted || deleted.Conflict {
+		t.Errorf("unexpected delete result: %+v", deleted)
+	}
+	if doc, err := notes.FindOne(context.Background(), "b"); err != nil || doc["title"] != "B from elsewhere" {
+		t.Errorf("expected the conflicting update not to be applied, got %v, %v", doc, err)
+	}
+
+	// The revision a push returns is the one pulls report.
+	w = h.Do(http.MethodGet, "/api/sync/notes", "", token)
+	if err := json.Unmarshal(w.Body.Bytes(), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range snapshot.Changes {
+		if change.ID == "a" && change.Rev != updated.Rev {
+			t.Errorf("pulled rev %s, pushed %s", change.Rev, updated.Rev)
+		}
+	}
+
+	// A rejected mutation fails the whole batch.
+	body := `{"mutations":[
+		{"op":"update","id":"a","base_rev":"` + updated.Rev + `","data":{"title":"A2"}},
+		{"op":"update","id":"d","base_rev":"x","data":{"title":"D1"}},
+		{"op":"create","id":"f","data":{"owner":"` + bob.ID + `","title":"F"}}
+	]}`
+	w = h.Do(http.MethodPost, "/api/sync/notes", body, token)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"mutation":2`) {
+		t.Fatalf("expected 403 for the third mutation, got %d: %s", w.Code, w.Body.String())
+	}
+	if doc, _ := notes.FindOne(context.Background(), "a"); doc["title"] != "A1" {
+		t.Errorf("expected no mutation of a failed push to be applied, got %v", doc["title"])
+	}
+
+	w = h.Do(http.MethodPost, "/api/sync/notes", `{"mutations":[{"op":"update","id":"a","data":{"title":"x"}}]}`, token)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_MUTATION") {
+		t.Errorf("expected 400 without base_rev, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = h.Do(http.MethodPost, "/api/sync/notes", `{"mutations":[{"op":"update","id":"a","base_rev":"`+updated.Rev+`","data":{"title":null}}]}`, token)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "VALIDATION_ERROR") {
+		t.Errorf("expected 422 for a null on a required field, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, mutation := range []string{
+		`{"op":"create","id":"g","data":{"owner":"` + alice.ID + `","tilte":"G"}}`,
+		`{"op":"update","id":"a","base_rev":"` + updated.Rev + `","data":{"tilte":"A2"}}`,
+	} {
+		w = h.Do(http.MethodPost, "/api/sync/notes", `{"mutations":[`+mutation+`]}`, token)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "UNKNOWN_FIELDS") ||
+			!strings.Contains(w.Body.String(), "did you mean 'title'?") {
+			t.Errorf("expected 400 for an unknown field, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSyncRequiresChangeFeed(t *testing.T) {
+	t.Parallel()
+	h := alyxtest.New(t, syncSchema)
+	token := h.Token(h.CreateUser("alice@example.com", "user"))
+
+	if w := h.Do(http.MethodGet, "/api/sync/notes", "", token); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without the change feed, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/tracing"
+)
+
+func installTestTracer(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider(&config.TracingConfig{SampleRatio: 1}, sdktrace.WithSyncer(exporter))
+	tracing.Install(tp)
+	t.Cleanup(tracing.Uninstall)
+
+	return exporter
+}
+
+func spanAttr(span tracetest.SpanStub, key string) (string, bool) {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key {
+			return attr.Value.Emit(), true
+		}
+	}
+	return "", false
+}
+
+func TestTracing_RequestSpans(t *testing.T) {
+	exporter := installTestTracer(t)
+	server := setupTestServer(t)
+	exporter.Reset()
+
+	const (
+		traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentSpanID = "00f067aa0ba902b7"
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/collections/users?filter=name:eq:alice", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentSpanID+"-01")
+	w := httptest.NewRecorder()
+
+	server.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	spans := exporter.GetSpans()
+
+	var serverSpan *tracetest.SpanStub
+	var dbSpans []tracetest.SpanStub
+	for i, span := range spans {
+		switch span.SpanKind {
+		case trace.SpanKindServer:
+			serverSpan = &spans[i]
+		case trace.SpanKindClient:
+			if strings.HasPrefix(span.Name, "db ") {
+				dbSpans = append(dbSpans, span)
+			}
+		}
+	}
+
+	if serverSpan == nil {
+		t.Fatalf("expected a server span, got %d spans", len(spans))
+	}
+	if serverSpan.Name != "GET /api/collections/{collection}" {
+		t.Errorf("expected span named by route template, got %q", serverSpan.Name)
+	}
+	if got := serverSpan.SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("expected trace ID %s from traceparent, got %s", traceID, got)
+	}
+	if got := serverSpan.Parent.SpanID().String(); got != parentSpanID {
+		t.Errorf("expected parent span %s, got %s", parentSpanID, got)
+	}
+	if route, _ := spanAttr(*serverSpan, "http.route"); route != "/api/collections/{collection}" {
+		t.Errorf("expected http.route attribute, got %q", route)
+	}
+	if status, _ := spanAttr(*serverSpan, "http.response.status_code"); status != "200" {
+		t.Errorf("expected status attribute 200, got %q", status)
+	}
+
+	if len(dbSpans) == 0 {
+		t.Fatal("expected database query spans")
+	}
+	for _, span := range dbSpans {
+		if span.SpanContext.TraceID() != serverSpan.SpanContext.TraceID() {
+			t.Errorf("db span %q is not part of the request trace", span.Name)
+		}
+		if text, _ := spanAttr(span, "db.query.text"); strings.Contains(text, "alice") {
+			t.Errorf("expected literals to be normalized out of %q", text)
+		}
+	}
+}
+
+func TestTracing_DisabledIsNoop(t *testing.T) {
+	server := setupTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/collections/users", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+
+	server.router.ServeHTTP(w, req)
+
+	if tracing.Enabled() {
+		t.Fatal("expected tracing to be disabled by default")
+	}
+
+	_, span := tracing.Start(req.Context(), "test")
+	if span.IsRecording() || span.SpanContext().IsValid() {
+		t.Error("expected a no-op span when tracing is disabled")
+	}
+}
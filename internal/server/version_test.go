@@ -0,0 +1,42 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/watzon/alyx/internal/buildinfo"
+	"github.com/watzon/alyx/pkg/alyxtest"
+)
+
+func TestVersionEndpoint(t *testing.T) {
+	t.Parallel()
+	h := alyxtest.New(t, exposeSchema)
+
+	w := h.Do(http.MethodGet, "/api/version", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("version: status %d: %s", w.Code, w.Body.String())
+	}
+	var info buildinfo.Info
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != buildinfo.Version || info.GoVersion == "" {
+		t.Errorf("unexpected version info: %+v", info)
+	}
+	if !slices.Contains(info.Features, "docs") {
+		t.Errorf("expected docs in features, got %v", info.Features)
+	}
+
+	w = h.Do(http.MethodGet, "/health", "", "")
+	var health struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if health.Version != buildinfo.Version {
+		t.Errorf("health version = %q, want %q", health.Version, buildinfo.Version)
+	}
+}
@@ -10,8 +10,10 @@ import (
 )
 
 var (
-	ErrNotFound      = errors.New("file not found")
-	ErrInvalidConfig = errors.New("invalid backend configuration")
+	ErrNotFound       = errors.New("file not found")
+	ErrInvalidConfig  = errors.New("invalid backend configuration")
+	ErrFileTooLarge   = errors.New("file exceeds maximum size")
+	ErrTypeNotAllowed = errors.New("mime type not allowed")
 )
 
 type Backend interface {
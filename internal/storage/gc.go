@@ -0,0 +1,374 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// DefaultGCGracePeriod is how old an unreferenced file must be before
+// garbage collection deletes it. Younger files may belong to an upload
+// whose document has not been saved yet.
+const DefaultGCGracePeriod = 24 * time.Hour
+
+// gcPageSize is the number of files checked per query.
+const gcPageSize = 500
+
+// gcFinishedJobTTL is how long a finished job's result stays available.
+const gcFinishedJobTTL = time.Hour
+
+// GCOptions configures a garbage collection run over a bucket.
+type GCOptions struct {
+	// GracePeriod keeps files younger than it. Zero means
+	// DefaultGCGracePeriod.
+	GracePeriod time.Duration
+	// DryRun reports the candidates without deleting them.
+	DryRun bool
+	// Cursor resumes a scan after the file with this ID.
+	Cursor string
+	// Limit stops the scan after this many files, returning a NextCursor to
+	// resume from. Zero scans the whole bucket.
+	Limit int
+}
+
+// GCResult reports a garbage collection run.
+type GCResult struct {
+	Bucket  string    `json:"bucket"`
+	DryRun  bool      `json:"dry_run"`
+	Cutoff  time.Time `json:"cutoff"`
+	Scanned int       `json:"scanned"`
+	// Candidates are the unreferenced files older than the cutoff. On a
+	// dry run they are only listed.
+	Candidates []*File `json:"candidates"`
+	Deleted    int     `json:"deleted"`
+	// Bytes is the total size of the candidates.
+	Bytes int64 `json:"bytes"`
+	// NextCursor is set when the scan stopped at Limit with files left.
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// CollectGarbage finds the files in bucket older than the grace period
+// that no file field references, and deletes them unless opts.DryRun is
+// set. Files are scanned in ID order, a page at a time. progress, if not
+// nil, is called with the result so far after each page.
+func (s *Service) CollectGarbage(ctx context.Context, bucket string, opts GCOptions, progress func(*GCResult)) (*GCResult, error) {
+	sch := s.currentSchema()
+	if _, ok := sch.Buckets[bucket]; !ok {
+		return nil, fmt.Errorf("bucket not found: %s", bucket)
+	}
+	grace := opts.GracePeriod
+	if grace <= 0 {
+		grace = DefaultGCGracePeriod
+	}
+
+	result := &GCResult{
+		Bucket:     bucket,
+		DryRun:     opts.DryRun,
+		Cutoff:     time.Now().UTC().Add(-grace).Truncate(time.Second),
+		Candidates: []*File{},
+	}
+	fields := fileFieldsFor(sch, bucket)
+	userID := ""
+	if user := auth.UserFromContext(ctx); user != nil {
+		userID = user.ID
+	}
+
+	cursor := opts.Cursor
+	for {
+		pageSize := gcPageSize
+		if opts.Limit > 0 {
+			pageSize = min(pageSize, opts.Limit-result.Scanned)
+		}
+		files, err := s.store.listBefore(ctx, bucket, result.Cutoff, cursor, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return result, nil
+		}
+		result.Scanned += len(files)
+		cursor = files[len(files)-1].ID
+
+		referenced, err := s.referencedFiles(ctx, fields, files)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if referenced[file.ID] {
+				continue
+			}
+			result.Candidates = append(result.Candidates, file)
+			result.Bytes += file.Size
+			if opts.DryRun {
+				continue
+			}
+			if err := s.remove(ctx, bucket, file.ID); err != nil && !errors.Is(err, ErrNotFound) {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", file.ID, err))
+				continue
+			}
+			result.Deleted++
+			log.Info().
+				Str("bucket", bucket).
+				Str("file_id", file.ID).
+				Str("name", file.Name).
+				Int64("size", file.Size).
+				Str("user_id", userID).
+				Msg("Deleted orphaned file")
+		}
+
+		if progress != nil {
+			progress(result)
+		}
+		if len(files) < pageSize {
+			return result, nil
+		}
+		if opts.Limit > 0 && result.Scanned >= opts.Limit {
+			result.NextCursor = cursor
+			return result, nil
+		}
+	}
+}
+
+// fileFieldsFor returns the collection fields, by collection, that
+// reference files in bucket.
+func fileFieldsFor(sch *schema.Schema, bucket string) map[string][]string {
+	fields := make(map[string][]string)
+	for name, col := range sch.Collections {
+		for _, field := range col.OrderedFields() {
+			if field.Type == schema.FieldTypeFile && field.File != nil && field.File.Bucket == bucket {
+				fields[name] = append(fields[name], field.Name)
+			}
+		}
+	}
+	return fields
+}
+
+// referencedFiles returns the IDs of files referenced by any of fields.
+func (s *Service) referencedFiles(ctx context.Context, fields map[string][]string, files []*File) (map[string]bool, error) {
+	ids := make([]any, len(files))
+	for i, file := range files {
+		ids[i] = file.ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	referenced := make(map[string]bool)
+	for collection, names := range fields {
+		for _, field := range names {
+			//nolint:gosec // Collection and field names come from the validated schema
+			query := fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE %s IN (%s)", field, collection, field, placeholders)
+			rows, err := s.db.QueryContext(ctx, query, ids...)
+			if err != nil {
+				return nil, fmt.Errorf("checking references in %s.%s: %w", collection, field, err)
+			}
+			for rows.Next() {
+				var id string
+				if err := rows.Scan(&id); err != nil {
+					rows.Close()
+					return nil, err
+				}
+				referenced[id] = true
+			}
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return referenced, nil
+}
+
+// listBefore returns up to limit files in bucket created before cutoff,
+// with IDs after the given one, in ID order.
+func (s *Store) listBefore(ctx context.Context, bucket string, cutoff time.Time, after string, limit int) ([]*File, error) {
+	query := `
+		SELECT id, bucket, name, path, mime_type, size, checksum,
+		       compressed, compression_type, original_size, metadata,
+		       version, created_at, updated_at, owner_id
+		FROM _alyx_files
+		WHERE bucket = ? AND created_at < ? AND id > ?
+		ORDER BY id LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, query, bucket, cutoff.UTC().Format(time.RFC3339), after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying file metadata: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanFiles(rows)
+}
+
+// GC job states.
+const (
+	GCJobRunning   = "running"
+	GCJobCompleted = "completed"
+	GCJobFailed    = "failed"
+)
+
+// GCJob is a garbage collection run in the background.
+type GCJob struct {
+	ID         string     `json:"id"`
+	Bucket     string     `json:"bucket"`
+	Status     string     `json:"status"`
+	Result     *GCResult  `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// gcJobs tracks background garbage collection runs in memory.
+type gcJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*GCJob
+}
+
+// StartGC runs CollectGarbage in the background and returns its job. The
+// job is updated after every page, so GCJob reports progress.
+func (s *Service) StartGC(ctx context.Context, bucket string, opts GCOptions) (*GCJob, error) {
+	if _, ok := s.currentSchema().Buckets[bucket]; !ok {
+		return nil, fmt.Errorf("bucket not found: %s", bucket)
+	}
+
+	job := &GCJob{
+		ID:        uuid.New().String(),
+		Bucket:    bucket,
+		Status:    GCJobRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	s.gcJobs.mu.Lock()
+	s.gcJobs.prune()
+	s.gcJobs.jobs[job.ID] = job
+	s.gcJobs.mu.Unlock()
+
+	// The run outlives the request that started it.
+	ctx = auth.ContextWithUser(context.Background(), auth.UserFromContext(ctx))
+	go func() {
+		result, err := s.CollectGarbage(ctx, bucket, opts, func(progress *GCResult) {
+			s.gcJobs.mu.Lock()
+			snapshot := *progress
+			job.Result = &snapshot
+			s.gcJobs.mu.Unlock()
+		})
+
+		s.gcJobs.mu.Lock()
+		defer s.gcJobs.mu.Unlock()
+		now := time.Now().UTC()
+		job.FinishedAt = &now
+		if err != nil {
+			job.Status = GCJobFailed
+			job.Error = err.Error()
+			log.Error().Err(err).Str("bucket", bucket).Str("job_id", job.ID).Msg("Orphaned file cleanup failed")
+			return
+		}
+		job.Status = GCJobCompleted
+		job.Result = result
+	}()
+
+	return s.GCJob(job.ID), nil
+}
+
+// GCJob returns a copy of the job with the given ID, or nil if there is
+// none.
+func (s *Service) GCJob(id string) *GCJob {
+	s.gcJobs.mu.Lock()
+	defer s.gcJobs.mu.Unlock()
+	job, ok := s.gcJobs.jobs[id]
+	if !ok {
+		return nil
+	}
+	snapshot := *job
+	return &snapshot
+}
+
+// prune forgets jobs that finished over gcFinishedJobTTL ago. The caller
+// holds mu.
+func (j *gcJobs) prune() {
+	if j.jobs == nil {
+		j.jobs = make(map[string]*GCJob)
+	}
+	for id, job := range j.jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > gcFinishedJobTTL {
+			delete(j.jobs, id)
+		}
+	}
+}
+
+// GCService runs garbage collection for the buckets whose schema sets gc,
+// each on its own interval.
+type GCService struct {
+	service *Service
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewGCService creates a scheduler for service's buckets.
+func NewGCService(service *Service) *GCService {
+	return &GCService{service: service, done: make(chan struct{})}
+}
+
+// Start begins a loop for every bucket with a gc schedule.
+func (g *GCService) Start(ctx context.Context) {
+	for name, bucket := range g.service.currentSchema().Buckets {
+		interval := bucket.GC.IntervalDuration()
+		if interval <= 0 {
+			continue
+		}
+		g.wg.Add(1)
+		go g.loop(ctx, name, interval)
+
+		log.Info().
+			Str("bucket", name).
+			Dur("interval", interval).
+			Msg("Orphaned file cleanup scheduled")
+	}
+}
+
+// Stop halts the loops and waits for running collections to finish.
+func (g *GCService) Stop() {
+	close(g.done)
+	g.wg.Wait()
+}
+
+func (g *GCService) loop(ctx context.Context, bucket string, interval time.Duration) {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg, ok := g.service.currentSchema().Buckets[bucket]
+			if !ok || cfg.GC == nil {
+				continue
+			}
+			result, err := g.service.CollectGarbage(ctx, bucket, GCOptions{GracePeriod: cfg.GC.GracePeriodDuration()}, nil)
+			if err != nil {
+				log.Error().Err(err).Str("bucket", bucket).Msg("Orphaned file cleanup failed")
+				continue
+			}
+			if result.Deleted > 0 || len(result.Errors) > 0 {
+				log.Info().
+					Str("bucket", bucket).
+					Int("deleted", result.Deleted).
+					Int64("bytes", result.Bytes).
+					Int("errors", len(result.Errors)).
+					Msg("Cleaned up orphaned files")
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/rules"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+func TestServiceBucketRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(tmpDir, "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(`
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      published:
+        type: bool
+        default: false
+      attachment:
+        type: file
+        nullable: true
+        file:
+          bucket: private
+buckets:
+  private:
+    backend: local
+    rules:
+      upload: "has(auth.id) && file.size <= 10"
+      download: "auth.id == file.owner_id || (has(doc.published) && doc.published)"
+      delete: "auth.id == file.owner_id"
+`))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadSchema(s); err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]Backend{"local": NewFilesystemBackend(filepath.Join(tmpDir, "storage"))}
+	service := NewService(db, backends, s, &config.Config{}, engine)
+
+	owner := auth.ContextWithUser(context.Background(), &auth.User{ID: "owner"})
+	other := auth.ContextWithUser(context.Background(), &auth.User{ID: "other"})
+
+	if _, err := service.Upload(context.Background(), "private", "a.txt", strings.NewReader("hello"), -1); !errors.Is(err, rules.ErrAccessDenied) {
+		t.Errorf("anonymous upload: expected access denied, got %v", err)
+	}
+	if _, err := service.Upload(owner, "private", "big.txt", strings.NewReader("far too long for the rule"), -1); !errors.Is(err, rules.ErrAccessDenied) {
+		t.Errorf("oversized upload: expected access denied, got %v", err)
+	}
+	if _, total, err := service.store.List(context.Background(), "private", "", "", 0, 10); err != nil || total != 0 {
+		t.Errorf("expected denied uploads to leave no files, got %d, %v", total, err)
+	}
+
+	file, err := service.Upload(owner, "private", "a.txt", strings.NewReader("hello"), -1)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if file.OwnerID != "owner" {
+		t.Errorf("OwnerID = %q, want owner", file.OwnerID)
+	}
+
+	if _, _, err := service.Download(other, "private", file.ID); !errors.Is(err, rules.ErrAccessDenied) {
+		t.Errorf("download by another user: expected access denied, got %v", err)
+	}
+	rc, _, err := service.Download(owner, "private", file.ID)
+	if err != nil {
+		t.Fatalf("download by owner: %v", err)
+	}
+	rc.Close()
+
+	// Referenced by a published post, anyone may download it.
+	if _, err := db.Exec(`INSERT INTO posts (id, published, attachment) VALUES ('p1', 1, ?)`, file.ID); err != nil {
+		t.Fatal(err)
+	}
+	rc, _, err = service.Download(other, "private", file.ID)
+	if err != nil {
+		t.Fatalf("download of a published post's file: %v", err)
+	}
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(rc)
+	rc.Close()
+	if buf.String() != "hello" {
+		t.Errorf("downloaded %q", buf.String())
+	}
+
+	if err := service.Delete(other, "private", file.ID); !errors.Is(err, rules.ErrAccessDenied) {
+		t.Errorf("delete by another user: expected access denied, got %v", err)
+	}
+	if err := service.Delete(owner, "private", file.ID); err != nil {
+		t.Errorf("delete by owner: %v", err)
+	}
+}
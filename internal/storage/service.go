@@ -4,10 +4,13 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
+	"sync/atomic"
 
 	"github.com/google/uuid"
 
@@ -22,45 +25,94 @@ type Service struct {
 	db       *database.DB
 	store    *Store
 	backends map[string]Backend
-	schema   *schema.Schema
+	schema   atomic.Pointer[schema.Schema]
 	cfg      *config.Config
 	rules    *rules.Engine
+	gcJobs   gcJobs
 }
 
 func NewService(db *database.DB, backends map[string]Backend, s *schema.Schema, cfg *config.Config, rulesEngine *rules.Engine) *Service {
-	return &Service{
+	svc := &Service{
 		db:       db,
 		store:    NewStore(db),
 		backends: backends,
-		schema:   s,
 		cfg:      cfg,
 		rules:    rulesEngine,
 	}
+	svc.schema.Store(s)
+	return svc
 }
 
+// UpdateSchema replaces the schema buckets and file fields are looked up in.
+func (s *Service) UpdateSchema(sch *schema.Schema) {
+	s.schema.Store(sch)
+}
+
+func (s *Service) currentSchema() *schema.Schema {
+	return s.schema.Load()
+}
+
+// Upload streams r to the bucket's backend. A negative size means the size
+// is unknown, as with streamed multipart uploads; the bucket's max file size
+// is then enforced while reading and the stored size is the bytes read.
+//
+// The file is owned by the authenticated user. The bucket's upload rule is
+// checked before anything is read, with what is known of the file then, and
+// again once its size and type are known; a file the second check denies is
+// removed from the backend.
 func (s *Service) Upload(ctx context.Context, bucket, filename string, r io.Reader, size int64) (*File, error) {
-	bucketCfg, ok := s.schema.Buckets[bucket]
+	bucketCfg, ok := s.currentSchema().Buckets[bucket]
 	if !ok {
 		return nil, fmt.Errorf("bucket not found: %s", bucket)
 	}
 
-	if s.rules != nil {
-		user := auth.UserFromContext(ctx)
-		claims := auth.ClaimsFromContext(ctx)
+	owner := ""
+	if user := auth.UserFromContext(ctx); user != nil {
+		owner = user.ID
+	} else if claims := auth.ClaimsFromContext(ctx); claims != nil {
+		owner = claims.UserID
+	}
 
-		evalCtx := &rules.EvalContext{
-			Auth: rules.BuildAuthContext(user, claims),
+	var check func(*File) error
+	if s.rules != nil {
+		check = func(file *File) error {
+			return s.checkFileAccess(ctx, bucket, file, rules.OpUpload, nil)
 		}
-
-		if err := s.rules.CheckAccess(bucket, rules.OpCreate, evalCtx); err != nil {
-			return nil, fmt.Errorf("access denied: %w", err)
+		// A rule that reads the size or type cannot be decided yet and
+		// fails to evaluate; only a denial is final.
+		early := &File{Bucket: bucket, Name: filename, Size: size, OwnerID: owner}
+		if err := check(early); errors.Is(err, rules.ErrAccessDenied) {
+			return nil, err
 		}
 	}
 
+	return s.put(ctx, bucket, bucketCfg, filename, r, size, "", owner, check)
+}
+
+// PutObject stores r in bucket like Upload, without checking the bucket's
+// rules: for content the caller authorizes itself, such as blob fields,
+// which follow their collection's rules. A mimeType other than "" is stored
+// instead of the detected type, and still has to match the bucket's allowed
+// types.
+func (s *Service) PutObject(ctx context.Context, bucket, filename, mimeType string, r io.Reader, size int64) (*File, error) {
+	bucketCfg, ok := s.currentSchema().Buckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("bucket not found: %s", bucket)
+	}
+	return s.put(ctx, bucket, bucketCfg, filename, r, size, mimeType, "", nil)
+}
+
+// put stores r and its metadata. check, when not nil, is called with the
+// file once it is stored and before its metadata is; if it fails, the file
+// is removed.
+func (s *Service) put(ctx context.Context, bucket string, bucketCfg *schema.Bucket, filename string, r io.Reader, size int64, mimeType, owner string, check func(*File) error) (*File, error) {
 	if bucketCfg.MaxFileSize > 0 && size > bucketCfg.MaxFileSize {
-		return nil, fmt.Errorf("file size %d exceeds maximum %d", size, bucketCfg.MaxFileSize)
+		return nil, fmt.Errorf("%w: file size %d exceeds maximum %d", ErrFileTooLarge, size, bucketCfg.MaxFileSize)
 	}
 
+	counter := &countingReader{r: r, max: bucketCfg.MaxFileSize}
+	r = counter
+
 	backend, ok := s.backends[bucketCfg.Backend]
 	if !ok {
 		return nil, fmt.Errorf("backend not found: %s", bucketCfg.Backend)
@@ -73,7 +125,9 @@ func (s *Service) Upload(ctx context.Context, bucket, filename string, r io.Read
 	}
 	buf = buf[:n]
 
-	mimeType := http.DetectContentType(buf)
+	if mimeType == "" {
+		mimeType = http.DetectContentType(buf)
+	}
 
 	if len(bucketCfg.AllowedTypes) > 0 {
 		allowed := false
@@ -84,7 +138,7 @@ func (s *Service) Upload(ctx context.Context, bucket, filename string, r io.Read
 			}
 		}
 		if !allowed {
-			return nil, fmt.Errorf("mime type %s not allowed", mimeType)
+			return nil, fmt.Errorf("%w: %s", ErrTypeNotAllowed, mimeType)
 		}
 	}
 
@@ -95,8 +149,15 @@ func (s *Service) Upload(ctx context.Context, bucket, filename string, r io.Read
 	teeReader := io.TeeReader(io.MultiReader(strings.NewReader(string(buf)), r), hasher)
 
 	if err := backend.Put(ctx, bucket, fileID, teeReader, size); err != nil {
+		_ = backend.Delete(ctx, bucket, fileID)
+		if errors.Is(err, ErrFileTooLarge) {
+			return nil, fmt.Errorf("%w: file exceeds maximum %d", ErrFileTooLarge, bucketCfg.MaxFileSize)
+		}
 		return nil, fmt.Errorf("storing file: %w", err)
 	}
+	if size < 0 {
+		size = counter.n
+	}
 
 	checksum := hex.EncodeToString(hasher.Sum(nil))
 
@@ -108,6 +169,14 @@ func (s *Service) Upload(ctx context.Context, bucket, filename string, r io.Read
 		MimeType: mimeType,
 		Size:     size,
 		Checksum: checksum,
+		OwnerID:  owner,
+	}
+
+	if check != nil {
+		if err := check(file); err != nil {
+			_ = backend.Delete(ctx, bucket, fileID)
+			return nil, err
+		}
 	}
 
 	if err := s.store.Create(ctx, file); err != nil {
@@ -125,27 +194,103 @@ func (s *Service) Download(ctx context.Context, bucket, fileID string) (io.ReadC
 	}
 
 	if s.rules != nil {
-		if err := s.checkFileAccess(ctx, bucket, file, rules.OpDownload); err != nil {
+		if err := s.checkDownload(ctx, bucket, file); err != nil {
 			return nil, nil, err
 		}
 	}
 
-	bucketCfg, ok := s.schema.Buckets[bucket]
+	rc, err := s.open(ctx, bucket, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rc, file, nil
+}
+
+// CheckDownload returns the metadata of a file if the bucket's download rule
+// lets the caller in ctx download it.
+func (s *Service) CheckDownload(ctx context.Context, bucket, fileID string) (*File, error) {
+	file, err := s.store.Get(ctx, bucket, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if s.rules != nil {
+		if err := s.checkDownload(ctx, bucket, file); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// checkDownload checks the bucket's download rule, which sees the document
+// referencing the file, if any, as doc.
+func (s *Service) checkDownload(ctx context.Context, bucket string, file *File) error {
+	doc, err := s.owningDocument(ctx, bucket, file.ID)
+	if err != nil {
+		return err
+	}
+	return s.checkFileAccess(ctx, bucket, file, rules.OpDownload, doc)
+}
+
+// owningDocument returns the first document found whose file field
+// references the file, or nil if none does.
+func (s *Service) owningDocument(ctx context.Context, bucket, fileID string) (map[string]any, error) {
+	sch := s.currentSchema()
+	fields := fileFieldsFor(sch, bucket)
+
+	collections := make([]string, 0, len(fields))
+	for name := range fields {
+		collections = append(collections, name)
+	}
+	sort.Strings(collections)
+
+	for _, name := range collections {
+		col := database.NewCollection(s.db, sch.Collections[name])
+		for _, field := range fields[name] {
+			result, err := col.Find(ctx, &database.QueryOptions{
+				Filters: []*database.Filter{{Field: field, Op: database.OpEq, Value: fileID}},
+				Limit:   1,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("finding document referencing file in %s.%s: %w", name, field, err)
+			}
+			if len(result.Docs) > 0 {
+				return result.Docs[0], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// GetObject opens a file like Download, without checking the bucket's rules.
+func (s *Service) GetObject(ctx context.Context, bucket, fileID string) (io.ReadCloser, *File, error) {
+	file, err := s.store.Get(ctx, bucket, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rc, err := s.open(ctx, bucket, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rc, file, nil
+}
+
+func (s *Service) open(ctx context.Context, bucket, fileID string) (io.ReadCloser, error) {
+	bucketCfg, ok := s.currentSchema().Buckets[bucket]
 	if !ok {
-		return nil, nil, fmt.Errorf("bucket not found: %s", bucket)
+		return nil, fmt.Errorf("bucket not found: %s", bucket)
 	}
 
 	backend, ok := s.backends[bucketCfg.Backend]
 	if !ok {
-		return nil, nil, fmt.Errorf("backend not found: %s", bucketCfg.Backend)
+		return nil, fmt.Errorf("backend not found: %s", bucketCfg.Backend)
 	}
 
 	rc, err := backend.Get(ctx, bucket, fileID)
 	if err != nil {
-		return nil, nil, fmt.Errorf("retrieving file: %w", err)
+		return nil, fmt.Errorf("retrieving file: %w", err)
 	}
-
-	return rc, file, nil
+	return rc, nil
 }
 
 func (s *Service) GetMetadata(ctx context.Context, bucket, fileID string) (*File, error) {
@@ -155,7 +300,7 @@ func (s *Service) GetMetadata(ctx context.Context, bucket, fileID string) (*File
 	}
 
 	if s.rules != nil {
-		if err := s.checkFileAccess(ctx, bucket, file, rules.OpRead); err != nil {
+		if err := s.checkFileAccess(ctx, bucket, file, rules.OpRead, nil); err != nil {
 			return nil, err
 		}
 	}
@@ -170,12 +315,25 @@ func (s *Service) Delete(ctx context.Context, bucket, fileID string) error {
 	}
 
 	if s.rules != nil {
-		if err := s.checkFileAccess(ctx, bucket, file, rules.OpDelete); err != nil {
+		if err := s.checkFileAccess(ctx, bucket, file, rules.OpDelete, nil); err != nil {
 			return err
 		}
 	}
 
-	bucketCfg, ok := s.schema.Buckets[bucket]
+	return s.remove(ctx, bucket, fileID)
+}
+
+// DeleteObject deletes a file like Delete, without checking the bucket's
+// rules.
+func (s *Service) DeleteObject(ctx context.Context, bucket, fileID string) error {
+	if _, err := s.store.Get(ctx, bucket, fileID); err != nil {
+		return err
+	}
+	return s.remove(ctx, bucket, fileID)
+}
+
+func (s *Service) remove(ctx context.Context, bucket, fileID string) error {
+	bucketCfg, ok := s.currentSchema().Buckets[bucket]
 	if !ok {
 		return fmt.Errorf("bucket not found: %s", bucket)
 	}
@@ -197,7 +355,7 @@ func (s *Service) Delete(ctx context.Context, bucket, fileID string) error {
 }
 
 func (s *Service) List(ctx context.Context, bucket, search, mimeType string, offset, limit int) ([]*File, int, error) {
-	if _, ok := s.schema.Buckets[bucket]; !ok {
+	if _, ok := s.currentSchema().Buckets[bucket]; !ok {
 		return nil, 0, fmt.Errorf("bucket not found: %s", bucket)
 	}
 
@@ -206,7 +364,8 @@ func (s *Service) List(ctx context.Context, bucket, search, mimeType string, off
 		claims := auth.ClaimsFromContext(ctx)
 
 		evalCtx := &rules.EvalContext{
-			Auth: rules.BuildAuthContext(user, claims),
+			Auth:    rules.BuildAuthContext(user, claims),
+			Context: ctx,
 		}
 
 		if err := s.rules.CheckAccess(bucket, rules.OpRead, evalCtx); err != nil {
@@ -217,21 +376,37 @@ func (s *Service) List(ctx context.Context, bucket, search, mimeType string, off
 	return s.store.List(ctx, bucket, search, mimeType, offset, limit)
 }
 
-func (s *Service) checkFileAccess(ctx context.Context, bucket string, file *File, op rules.Operation) error {
+// checkFileAccess checks the bucket's rule for op, which sees the file as
+// file and doc, when not nil, as doc. Unknown properties, such as the size of
+// a file not yet read, are left out.
+func (s *Service) checkFileAccess(ctx context.Context, bucket string, file *File, op rules.Operation, doc map[string]any) error {
 	user := auth.UserFromContext(ctx)
 	claims := auth.ClaimsFromContext(ctx)
 
 	fileCtx := map[string]any{
-		"id":        file.ID,
-		"name":      file.Name,
-		"mime_type": file.MimeType,
-		"size":      file.Size,
-		"bucket":    file.Bucket,
+		"name":     file.Name,
+		"bucket":   file.Bucket,
+		"owner_id": nil,
+	}
+	if file.ID != "" {
+		fileCtx["id"] = file.ID
+	}
+	if file.MimeType != "" {
+		fileCtx["mime"] = file.MimeType
+		fileCtx["mime_type"] = file.MimeType
+	}
+	if file.Size >= 0 {
+		fileCtx["size"] = file.Size
+	}
+	if file.OwnerID != "" {
+		fileCtx["owner_id"] = file.OwnerID
 	}
 
 	evalCtx := &rules.EvalContext{
-		Auth: rules.BuildAuthContext(user, claims),
-		File: fileCtx,
+		Auth:    rules.BuildAuthContext(user, claims),
+		Doc:     doc,
+		File:    fileCtx,
+		Context: ctx,
 	}
 
 	if file.Metadata != nil && file.Metadata["file_security"] == "true" {
@@ -297,3 +472,20 @@ func matchesMimeType(mimeType, pattern string) bool {
 
 	return baseMimeType == pattern
 }
+
+// countingReader counts the bytes read from r and fails with ErrFileTooLarge
+// once more than max bytes have been read. A zero max disables the limit.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.max > 0 && c.n > c.max {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
@@ -24,6 +24,7 @@ type File struct {
 	CompressionType string            `json:"compression_type,omitempty"`
 	OriginalSize    int64             `json:"original_size,omitempty"`
 	Metadata        map[string]string `json:"metadata,omitempty"`
+	OwnerID         string            `json:"owner_id,omitempty"`
 	Version         int               `json:"version"`
 	CreatedAt       time.Time         `json:"created_at"`
 	UpdatedAt       time.Time         `json:"updated_at"`
@@ -72,8 +73,8 @@ func (s *Store) Create(ctx context.Context, file *File) error {
 		INSERT INTO _alyx_files (
 			id, bucket, name, path, mime_type, size, checksum,
 			compressed, compression_type, original_size, metadata,
-			version, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			version, created_at, updated_at, owner_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err = s.db.ExecContext(ctx, query,
@@ -91,6 +92,7 @@ func (s *Store) Create(ctx context.Context, file *File) error {
 		file.Version,
 		file.CreatedAt.UTC().Format(time.RFC3339),
 		file.UpdatedAt.UTC().Format(time.RFC3339),
+		nullString(file.OwnerID),
 	)
 	if err != nil {
 		return fmt.Errorf("inserting file metadata: %w", err)
@@ -104,7 +106,7 @@ func (s *Store) Get(ctx context.Context, bucket, fileID string) (*File, error) {
 	query := `
 		SELECT id, bucket, name, path, mime_type, size, checksum,
 		       compressed, compression_type, original_size, metadata,
-		       version, created_at, updated_at
+		       version, created_at, updated_at, owner_id
 		FROM _alyx_files
 		WHERE id = ? AND bucket = ?
 	`
@@ -150,7 +152,7 @@ func (s *Store) List(ctx context.Context, bucket, search, mimeType string, offse
 	query := `
 		SELECT id, bucket, name, path, mime_type, size, checksum,
 		       compressed, compression_type, original_size, metadata,
-		       version, created_at, updated_at
+		       version, created_at, updated_at, owner_id
 		FROM _alyx_files
 		` + whereClause + ` ORDER BY created_at DESC LIMIT ? OFFSET ?`
 	args = append(args, limit, offset)
@@ -228,7 +230,7 @@ func (s *Store) GetBucketStats(ctx context.Context) ([]BucketStats, error) {
 // scanFile scans a single row into a File struct.
 func (s *Store) scanFile(row *sql.Row) (*File, error) {
 	var file File
-	var checksum, compressionType, metadataJSON sql.NullString
+	var checksum, compressionType, metadataJSON, ownerID sql.NullString
 	var originalSize sql.NullInt64
 	var createdAt, updatedAt string
 	var compressed int
@@ -248,6 +250,7 @@ func (s *Store) scanFile(row *sql.Row) (*File, error) {
 		&file.Version,
 		&createdAt,
 		&updatedAt,
+		&ownerID,
 	)
 	if err != nil {
 		return nil, err
@@ -264,6 +267,7 @@ func (s *Store) scanFile(row *sql.Row) (*File, error) {
 		file.OriginalSize = originalSize.Int64
 	}
 	file.Compressed = compressed == 1
+	file.OwnerID = ownerID.String
 
 	// Deserialize metadata
 	if metadataJSON.Valid && metadataJSON.String != "" {
@@ -294,7 +298,7 @@ func (s *Store) scanFiles(rows *sql.Rows) ([]*File, error) {
 
 	for rows.Next() {
 		var file File
-		var checksum, compressionType, metadataJSON sql.NullString
+		var checksum, compressionType, metadataJSON, ownerID sql.NullString
 		var originalSize sql.NullInt64
 		var createdAt, updatedAt string
 		var compressed int
@@ -314,6 +318,7 @@ func (s *Store) scanFiles(rows *sql.Rows) ([]*File, error) {
 			&file.Version,
 			&createdAt,
 			&updatedAt,
+			&ownerID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scanning file row: %w", err)
@@ -330,6 +335,7 @@ func (s *Store) scanFiles(rows *sql.Rows) ([]*File, error) {
 			file.OriginalSize = originalSize.Int64
 		}
 		file.Compressed = compressed == 1
+		file.OwnerID = ownerID.String
 
 		// Deserialize metadata
 		if metadataJSON.Valid && metadataJSON.String != "" {
@@ -19,6 +19,7 @@ CREATE TABLE IF NOT EXISTS _alyx_files (
     version INTEGER DEFAULT 1,
     created_at TEXT,
     updated_at TEXT,
+    owner_id TEXT,
     UNIQUE(bucket, path)
 )`
 }
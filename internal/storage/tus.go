@@ -53,7 +53,7 @@ func (s *TUSService) CreateUpload(ctx context.Context, bucket string, size int64
 	}
 
 	if bucketCfg.MaxFileSize > 0 && size > bucketCfg.MaxFileSize {
-		return nil, fmt.Errorf("file size %d exceeds maximum %d", size, bucketCfg.MaxFileSize)
+		return nil, fmt.Errorf("%w: file size %d exceeds maximum %d", ErrFileTooLarge, size, bucketCfg.MaxFileSize)
 	}
 
 	uploadID := uuid.New().String()
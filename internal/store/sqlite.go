@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// SQLiteDocuments is the DocumentStore for a SQLite database. Requests
+// routed to another database with database.WithDB, such as sandboxed ones,
+// use that database.
+type SQLiteDocuments struct {
+	db    *database.DB
+	hooks database.HookTrigger
+}
+
+var _ DocumentStore = (*SQLiteDocuments)(nil)
+
+// NewSQLiteDocuments returns a DocumentStore for db. hooks, which may be nil,
+// is run for every write.
+func NewSQLiteDocuments(db *database.DB, hooks database.HookTrigger) *SQLiteDocuments {
+	return &SQLiteDocuments{db: db, hooks: hooks}
+}
+
+func (s *SQLiteDocuments) collection(col *schema.Collection) *database.Collection {
+	c := database.NewCollection(s.db, col)
+	if s.hooks != nil {
+		c.SetHookTrigger(s.hooks)
+	}
+	return c
+}
+
+func (s *SQLiteDocuments) Get(ctx context.Context, col *schema.Collection, id string) (Document, error) {
+	return s.collection(col).FindOne(ctx, id)
+}
+
+func (s *SQLiteDocuments) List(ctx context.Context, col *schema.Collection, opts *ListOptions) (*ListResult, error) {
+	return s.collection(col).Find(ctx, opts)
+}
+
+func (s *SQLiteDocuments) Create(ctx context.Context, col *schema.Collection, data Document) (Document, error) {
+	return s.collection(col).Create(ctx, data)
+}
+
+func (s *SQLiteDocuments) Update(ctx context.Context, col *schema.Collection, id string, data Document) (Document, error) {
+	return s.collection(col).Update(ctx, id, data)
+}
+
+func (s *SQLiteDocuments) Delete(ctx context.Context, col *schema.Collection, id string) error {
+	return s.collection(col).Delete(ctx, id)
+}
+
+func (s *SQLiteDocuments) Count(ctx context.Context, col *schema.Collection, filters []*Filter) (int64, error) {
+	return s.collection(col).Count(ctx, filters)
+}
+
+// Exists runs a single-row query. Booleans are compared as the 0 and 1
+// SQLite stores them as.
+func (s *SQLiteDocuments) Exists(ctx context.Context, col *schema.Collection, filters []*Filter) (bool, error) {
+	q := database.NewQuery(col.Name).Select("1").Limit(1)
+	for _, f := range filters {
+		value := f.Value
+		if b, ok := value.(bool); ok {
+			value = 0
+			if b {
+				value = 1
+			}
+		}
+		q.Filter(f.Field, f.Op, value)
+	}
+	query, args := q.Build()
+
+	var one int
+	err := database.DBFromContext(ctx, s.db).QueryRowContext(ctx, query, args...).Scan(&one)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SQLiteDocuments) Version(ctx context.Context, col *schema.Collection) (Version, bool, error) {
+	return s.collection(col).Version(ctx)
+}
+
+func (s *SQLiteDocuments) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return database.DBFromContext(ctx, s.db).RunInTransaction(ctx, fn)
+}
+
+func (s *SQLiteDocuments) AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	database.AfterCommit(ctx, fn)
+}
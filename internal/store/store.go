@@ -0,0 +1,67 @@
+// Package store defines the storage interfaces the collection handlers, the
+// rules engine and the auth service depend on, so that another database can
+// be added without changing them. The SQLite implementations wrap the
+// database package.
+//
+// Implementations report failures with the database package's errors, such
+// as database.ErrNotFound, *database.ConstraintError and
+// database.ErrEncryptedField, so callers handle every backend alike.
+package store
+
+import (
+	"context"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// Document is a collection document, keyed by field name.
+type Document = database.Row
+
+// ListOptions selects, orders and pages the documents List returns.
+type ListOptions = database.QueryOptions
+
+// ListResult is a page of documents and, unless ListOptions.Total is
+// database.TotalNone, the number of documents matching the filters.
+type ListResult = database.QueryResult
+
+// Filter is a condition on a single field.
+type Filter = database.Filter
+
+// Version summarizes a collection's contents; see DocumentStore.Version.
+type Version = database.CollectionVersion
+
+// DocumentStore reads and writes the documents of schema collections.
+// Writes validate, convert and default values as described by the collection
+// schema, and run the database hooks configured for the store.
+type DocumentStore interface {
+	// Get returns the document with the given primary key.
+	Get(ctx context.Context, col *schema.Collection, id string) (Document, error)
+	// List returns the documents matching opts.
+	List(ctx context.Context, col *schema.Collection, opts *ListOptions) (*ListResult, error)
+	// Create inserts a document and returns it as stored.
+	Create(ctx context.Context, col *schema.Collection, data Document) (Document, error)
+	// Update changes the given fields of a document and returns it as
+	// stored.
+	Update(ctx context.Context, col *schema.Collection, id string, data Document) (Document, error)
+	// Delete removes a document.
+	Delete(ctx context.Context, col *schema.Collection, id string) error
+	// Count returns the number of documents matching every filter.
+	Count(ctx context.Context, col *schema.Collection, filters []*Filter) (int64, error)
+	// Exists reports whether any document matches every filter.
+	Exists(ctx context.Context, col *schema.Collection, filters []*Filter) (bool, error)
+	// Version returns a value that changes whenever any document of the
+	// collection does. ok is false if the store cannot track the
+	// collection's changes.
+	Version(ctx context.Context, col *schema.Collection) (version Version, ok bool, err error)
+
+	// WithTx runs fn in a transaction that the store's methods called with
+	// fn's context join. The transaction commits when fn returns nil and
+	// rolls back otherwise. When ctx already carries a transaction, fn joins
+	// it.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// AfterCommit defers fn until the transaction WithTx opened for ctx has
+	// committed, and drops it on rollback. Outside WithTx, fn runs right
+	// away.
+	AfterCommit(ctx context.Context, fn func(ctx context.Context))
+}
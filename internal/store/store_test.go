@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const testSchema = `
+version: 1
+collections:
+  tasks:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+      done:
+        type: bool
+        default: false
+`
+
+func openTestDB(t *testing.T) (*database.DB, *schema.Collection) {
+	t.Helper()
+
+	db, err := database.Open(&config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "store.db")})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s, err := schema.Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("execute DDL: %v", err)
+		}
+	}
+	return db, s.Collections["tasks"]
+}
+
+func TestSQLiteDocuments(t *testing.T) {
+	db, col := openTestDB(t)
+	docs := NewSQLiteDocuments(db, nil)
+	ctx := context.Background()
+
+	created, err := docs.Create(ctx, col, Document{"title": "Write tests"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	id, _ := created["id"].(string)
+
+	if _, err := docs.Update(ctx, col, id, Document{"done": true}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := docs.Get(ctx, col, id)
+	if err != nil || got["done"] != true || got["title"] != "Write tests" {
+		t.Fatalf("Get after update = %v, %v", got, err)
+	}
+
+	for done, want := range map[bool]bool{true: true, false: false} {
+		found, err := docs.Exists(ctx, col, []*Filter{{Field: "done", Op: database.OpEq, Value: done}})
+		if err != nil || found != want {
+			t.Errorf("Exists(done = %v) = %v, %v; want %v", done, found, err, want)
+		}
+	}
+
+	failed := errors.New("roll back")
+	err = docs.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := docs.Create(ctx, col, Document{"title": "Rolled back"}); err != nil {
+			return err
+		}
+		docs.AfterCommit(ctx, func(context.Context) { t.Error("expected AfterCommit work to be dropped on rollback") })
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("WithTx = %v, want %v", err, failed)
+	}
+
+	result, err := docs.List(ctx, col, &ListOptions{})
+	if err != nil || len(result.Docs) != 1 || result.Total != 1 {
+		t.Fatalf("List = %+v, %v; want only the committed document", result, err)
+	}
+	if n, err := docs.Count(ctx, col, nil); err != nil || n != 1 {
+		t.Errorf("Count = %d, %v; want 1", n, err)
+	}
+
+	if err := docs.Delete(ctx, col, id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := docs.Get(ctx, col, id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Get after delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteUsers(t *testing.T) {
+	db, _ := openTestDB(t)
+	users := NewSQLiteUsers(db)
+	ctx := context.Background()
+
+	if has, err := users.HasUsers(ctx); err != nil || has {
+		t.Fatalf("HasUsers on empty store = %v, %v", has, err)
+	}
+
+	now := time.Now().UTC()
+	first := &User{ID: "u1", Email: "ada@example.com", CreatedAt: now, UpdatedAt: now}
+	if err := users.CreateRegistered(ctx, first, "hash1", true); err != nil {
+		t.Fatalf("CreateRegistered: %v", err)
+	}
+	second := &User{ID: "u2", Email: "bob@example.com", CreatedAt: now, UpdatedAt: now}
+	if err := users.CreateRegistered(ctx, second, "hash2", true); err != nil {
+		t.Fatalf("CreateRegistered: %v", err)
+	}
+	if first.Role != RoleAdmin || second.Role != RoleUser {
+		t.Errorf("expected only the first user to be admin, got %q and %q", first.Role, second.Role)
+	}
+
+	verified := true
+	if err := users.Update(ctx, "u2", UserUpdate{Verified: &verified}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	err := users.UpdateMetadata(ctx, "u2", func(m map[string]any) (map[string]any, error) {
+		return map[string]any{"plan": "pro"}, nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateMetadata: %v", err)
+	}
+
+	user, hash, err := users.GetWithPassword(ctx, "bob@example.com")
+	if err != nil || hash != "hash2" || !user.Verified || user.Metadata["plan"] != "pro" {
+		t.Fatalf("GetWithPassword = %+v, %q, %v", user, hash, err)
+	}
+
+	if replaced, err := users.ReplacePasswordHash(ctx, "u2", "stale", "hash3"); err != nil || replaced {
+		t.Errorf("ReplacePasswordHash with a stale hash = %v, %v; want false", replaced, err)
+	}
+
+	list, total, err := users.List(ctx, ListUsersOptions{Role: RoleUser, Limit: 10, SortBy: "email", SortDir: "asc"})
+	if err != nil || total != 1 || len(list) != 1 || list[0].ID != "u2" {
+		t.Fatalf("List = %v, %d, %v", list, total, err)
+	}
+
+	if err := users.Delete(ctx, "u2"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := users.Get(ctx, "u2"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Get after delete = %v, want ErrUserNotFound", err)
+	}
+	if err := users.Delete(ctx, "u2"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("second Delete = %v, want ErrUserNotFound", err)
+	}
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUserNotFound is returned when no user matches.
+var ErrUserNotFound = errors.New("user not found")
+
+// User is a stored user account.
+type User struct {
+	ID        string         `json:"id"`
+	Email     string         `json:"email"`
+	Verified  bool           `json:"verified"`
+	Role      string         `json:"role"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	// DeletionRequestedAt is set while the user's own deletion request is
+	// in its grace period.
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty"`
+}
+
+// UserRole constants for the built-in role system.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// IsAdmin returns true if the user has admin role.
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// ListUsersOptions contains options for listing users.
+type ListUsersOptions struct {
+	Limit   int
+	Offset  int
+	SortBy  string
+	SortDir string // "asc" or "desc"
+	Search  string // Search in email
+	Role    string // Filter by role
+	// Verified, when set, limits results to verified or unverified users.
+	Verified *bool
+	// CreatedAfter and CreatedBefore limit results by creation time.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// PendingDeletion limits results to users with a deletion request in
+	// its grace period.
+	PendingDeletion bool
+}
+
+// UserUpdate holds the user fields to change. Nil fields are left alone.
+type UserUpdate struct {
+	Email    *string
+	Verified *bool
+	Role     *string
+	Metadata *map[string]any
+}
+
+// UserStore reads and writes user accounts. Emails are stored as given;
+// callers normalize them. Sessions, tokens and linked OAuth accounts are
+// kept by the auth service.
+type UserStore interface {
+	// HasUsers reports whether any user exists.
+	HasUsers(ctx context.Context) (bool, error)
+	// Get returns the user with the given ID.
+	Get(ctx context.Context, id string) (*User, error)
+	// GetByEmail returns the user with the given email.
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	// GetWithPassword returns the user with the given email and their
+	// password hash, which is empty for accounts without a password.
+	GetWithPassword(ctx context.Context, email string) (*User, string, error)
+
+	// Create inserts a user. An empty role stores the default role.
+	Create(ctx context.Context, user *User, passwordHash string) error
+	// CreateRegistered inserts a self-registered user and sets user.Role.
+	// With adminIfFirst, the user is made admin if no other user exists;
+	// the check is atomic with the insert.
+	CreateRegistered(ctx context.Context, user *User, passwordHash string, adminIfFirst bool) error
+
+	// List returns a page of the users matching opts, which must be
+	// normalized, and the number of users matching its filters.
+	List(ctx context.Context, opts ListUsersOptions) ([]*User, int, error)
+	// Each calls fn for every user matching opts, in order, ignoring Limit
+	// and Offset. Iteration stops at the first error fn returns.
+	Each(ctx context.Context, opts ListUsersOptions, fn func(*User) error) error
+
+	// Update applies the fields set in update and bumps updated_at.
+	Update(ctx context.Context, id string, update UserUpdate) error
+	// UpdateMetadata replaces a user's metadata with the result of fn,
+	// which is given the stored metadata. The read and the write are
+	// atomic. An error from fn is returned unchanged.
+	UpdateMetadata(ctx context.Context, id string, fn func(map[string]any) (map[string]any, error)) error
+	// SetPasswordHash stores a new password hash and bumps updated_at.
+	SetPasswordHash(ctx context.Context, id, passwordHash string) error
+	// ReplacePasswordHash swaps oldHash for newHash, leaving updated_at
+	// alone. It reports false if the stored hash is no longer oldHash.
+	ReplacePasswordHash(ctx context.Context, id, oldHash, newHash string) (bool, error)
+
+	// Delete removes a user.
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,403 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/watzon/alyx/internal/database"
+)
+
+const userColumns = "id, email, verified, role, created_at, updated_at, metadata, deletion_requested_at"
+
+// SQLiteUsers is the UserStore for a SQLite database, kept in the
+// _alyx_users table.
+type SQLiteUsers struct {
+	db *database.DB
+}
+
+var _ UserStore = (*SQLiteUsers)(nil)
+
+// NewSQLiteUsers returns a UserStore for db.
+func NewSQLiteUsers(db *database.DB) *SQLiteUsers {
+	return &SQLiteUsers{db: db}
+}
+
+func (s *SQLiteUsers) HasUsers(ctx context.Context) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM _alyx_users LIMIT 1)`
+	var exists bool
+	err := s.db.QueryRowContext(ctx, query).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("checking for users: %w", err)
+	}
+	return exists, nil
+}
+
+func (s *SQLiteUsers) Get(ctx context.Context, id string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM _alyx_users WHERE id = ?`
+	return scanUser(s.db.Stmts().QueryRowContext(ctx, query, id))
+}
+
+func (s *SQLiteUsers) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM _alyx_users WHERE email = ?`
+	return scanUser(s.db.Stmts().QueryRowContext(ctx, query, email))
+}
+
+func (s *SQLiteUsers) GetWithPassword(ctx context.Context, email string) (*User, string, error) {
+	query := `SELECT password_hash, ` + userColumns + ` FROM _alyx_users WHERE email = ?`
+	row := s.db.Stmts().QueryRowContext(ctx, query, email)
+
+	var passwordHash sql.NullString
+	user, err := scanUserColumns(row.Scan, &passwordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrUserNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return user, passwordHash.String, nil
+}
+
+func (s *SQLiteUsers) Create(ctx context.Context, user *User, passwordHash string) error {
+	metadata, err := encodeMetadata(user.Metadata)
+	if err != nil {
+		return err
+	}
+
+	if user.Role == "" {
+		query := `INSERT INTO _alyx_users (id, email, password_hash, verified, created_at, updated_at, metadata) VALUES (?, ?, ?, ?, ?, ?, ?)`
+		_, err = s.db.ExecContext(ctx, query,
+			user.ID,
+			user.Email,
+			passwordHash,
+			user.Verified,
+			user.CreatedAt.Format(time.RFC3339),
+			user.UpdatedAt.Format(time.RFC3339),
+			metadata,
+		)
+		return err
+	}
+
+	query := `INSERT INTO _alyx_users (id, email, password_hash, verified, role, created_at, updated_at, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = s.db.ExecContext(ctx, query,
+		user.ID,
+		user.Email,
+		passwordHash,
+		user.Verified,
+		user.Role,
+		user.CreatedAt.Format(time.RFC3339),
+		user.UpdatedAt.Format(time.RFC3339),
+		metadata,
+	)
+	return err
+}
+
+// CreateRegistered runs the empty-table check inside the INSERT, so two
+// concurrent signups cannot both become admin.
+func (s *SQLiteUsers) CreateRegistered(ctx context.Context, user *User, passwordHash string, adminIfFirst bool) error {
+	query := `INSERT INTO _alyx_users (id, email, password_hash, verified, role, created_at, updated_at, metadata)
+		SELECT ?, ?, ?, ?, CASE WHEN ? AND NOT EXISTS (SELECT 1 FROM _alyx_users) THEN ? ELSE ? END, ?, ?, ?
+		RETURNING role`
+
+	metadata, err := encodeMetadata(user.Metadata)
+	if err != nil {
+		return err
+	}
+
+	return s.db.QueryRowContext(ctx, query,
+		user.ID,
+		user.Email,
+		passwordHash,
+		user.Verified,
+		adminIfFirst, RoleAdmin, RoleUser,
+		user.CreatedAt.Format(time.RFC3339),
+		user.UpdatedAt.Format(time.RFC3339),
+		metadata,
+	).Scan(&user.Role)
+}
+
+func (s *SQLiteUsers) List(ctx context.Context, opts ListUsersOptions) ([]*User, int, error) {
+	whereClause, args := userWhereClause(opts)
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM _alyx_users" + whereClause
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting users: %w", err)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM _alyx_users%s ORDER BY %s %s LIMIT ? OFFSET ?",
+		userColumns, whereClause, opts.SortBy, strings.ToUpper(opts.SortDir),
+	)
+	args = append(args, opts.Limit, opts.Offset)
+
+	users := make([]*User, 0)
+	err := s.queryUsers(ctx, query, args, func(user *User) error {
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *SQLiteUsers) Each(ctx context.Context, opts ListUsersOptions, fn func(*User) error) error {
+	whereClause, args := userWhereClause(opts)
+	query := fmt.Sprintf(
+		"SELECT %s FROM _alyx_users%s ORDER BY %s %s",
+		userColumns, whereClause, opts.SortBy, strings.ToUpper(opts.SortDir),
+	)
+	return s.queryUsers(ctx, query, args, fn)
+}
+
+func (s *SQLiteUsers) queryUsers(ctx context.Context, query string, args []any, fn func(*User) error) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("querying users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, scanErr := scanUserColumns(rows.Scan)
+		if scanErr != nil {
+			return scanErr
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating users: %w", err)
+	}
+	return nil
+}
+
+func userWhereClause(opts ListUsersOptions) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if opts.Search != "" {
+		conditions = append(conditions, "email LIKE ?")
+		args = append(args, "%"+opts.Search+"%")
+	}
+	if opts.Role != "" {
+		conditions = append(conditions, "role = ?")
+		args = append(args, opts.Role)
+	}
+	if opts.Verified != nil {
+		conditions = append(conditions, "verified = ?")
+		args = append(args, *opts.Verified)
+	}
+	if opts.CreatedAfter != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, opts.CreatedAfter.UTC().Format(time.RFC3339))
+	}
+	if opts.CreatedBefore != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, opts.CreatedBefore.UTC().Format(time.RFC3339))
+	}
+	if opts.PendingDeletion {
+		conditions = append(conditions, "deletion_requested_at IS NOT NULL")
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (s *SQLiteUsers) Update(ctx context.Context, id string, update UserUpdate) error {
+	var updates []string
+	var args []any
+
+	if update.Email != nil {
+		updates = append(updates, "email = ?")
+		args = append(args, *update.Email)
+	}
+	if update.Verified != nil {
+		updates = append(updates, "verified = ?")
+		args = append(args, *update.Verified)
+	}
+	if update.Role != nil {
+		updates = append(updates, "role = ?")
+		args = append(args, *update.Role)
+	}
+	if update.Metadata != nil {
+		metadata, err := encodeMetadata(*update.Metadata)
+		if err != nil {
+			return err
+		}
+		updates = append(updates, "metadata = ?")
+		args = append(args, metadata)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	updates = append(updates, "updated_at = ?")
+	args = append(args, time.Now().UTC().Format(time.RFC3339), id)
+
+	query := fmt.Sprintf("UPDATE _alyx_users SET %s WHERE id = ?", strings.Join(updates, ", "))
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("updating user: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteUsers) UpdateMetadata(ctx context.Context, id string, fn func(map[string]any) (map[string]any, error)) error {
+	return s.db.Transaction(ctx, func(tx *database.Tx) error {
+		var metadataJSON sql.NullString
+		err := tx.QueryRowContext(ctx, "SELECT metadata FROM _alyx_users WHERE id = ?", id).Scan(&metadataJSON)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("reading metadata: %w", err)
+		}
+
+		metadata, err := decodeMetadata(metadataJSON)
+		if err != nil {
+			return err
+		}
+		if metadata, err = fn(metadata); err != nil {
+			return err
+		}
+
+		encoded, err := encodeMetadata(metadata)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE _alyx_users SET metadata = ?, updated_at = ? WHERE id = ?",
+			encoded, time.Now().UTC().Format(time.RFC3339), id,
+		)
+		if err != nil {
+			return fmt.Errorf("updating metadata: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteUsers) SetPasswordHash(ctx context.Context, id, passwordHash string) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE _alyx_users SET password_hash = ?, updated_at = ? WHERE id = ?",
+		passwordHash, time.Now().UTC().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		return fmt.Errorf("updating password: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (s *SQLiteUsers) ReplacePasswordHash(ctx context.Context, id, oldHash, newHash string) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE _alyx_users SET password_hash = ? WHERE id = ? AND password_hash = ?",
+		newHash, id, oldHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *SQLiteUsers) Delete(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM _alyx_users WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("deleting user: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// requireAffected returns ErrUserNotFound if a statement changed no rows.
+func requireAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func scanUser(row *sql.Row) (*User, error) {
+	user, err := scanUserColumns(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// scanUserColumns scans userColumns, after any leading destinations.
+func scanUserColumns(scan func(dest ...any) error, leading ...any) (*User, error) {
+	user := &User{}
+	var metadataJSON, deletionRequestedAt sql.NullString
+	var role sql.NullString
+	var createdAt, updatedAt string
+
+	dest := append(leading, &user.ID, &user.Email, &user.Verified, &role, &createdAt, &updatedAt, &metadataJSON, &deletionRequestedAt)
+	if err := scan(dest...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("scanning user: %w", err)
+	}
+
+	user.Role = role.String
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+	user.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	user.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	user.DeletionRequestedAt = parseOptionalTime(deletionRequestedAt)
+
+	metadata, err := decodeMetadata(metadataJSON)
+	if err != nil {
+		return nil, err
+	}
+	user.Metadata = metadata
+	return user, nil
+}
+
+// parseOptionalTime parses a nullable RFC 3339 column.
+func parseOptionalTime(v sql.NullString) *time.Time {
+	if !v.Valid || v.String == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, v.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// encodeMetadata converts metadata to the JSON text stored in the metadata
+// column, or nil when there is none.
+func encodeMetadata(metadata map[string]any) (any, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("encoding metadata: %w", err)
+	}
+	return string(data), nil
+}
+
+func decodeMetadata(metadataJSON sql.NullString) (map[string]any, error) {
+	if !metadataJSON.Valid || strings.TrimSpace(metadataJSON.String) == "" {
+		return nil, nil
+	}
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(metadataJSON.String), &metadata); err != nil {
+		return nil, fmt.Errorf("decoding metadata: %w", err)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,221 @@
+// Package tracing provides optional OpenTelemetry tracing for Alyx.
+//
+// Tracing is off unless a provider is installed with Setup or Install. While
+// off, every helper in this package returns immediately with a no-op span, so
+// instrumented code paths pay only for an atomic load.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/watzon/alyx/internal/config"
+)
+
+const instrumentationName = "github.com/watzon/alyx"
+
+var (
+	enabled    atomic.Bool
+	propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+)
+
+// Setup installs an OTLP/HTTP exporter according to cfg. When tracing is
+// disabled nothing is installed and the returned shutdown function is a no-op.
+func Setup(ctx context.Context, cfg *config.TracingConfig) (func(context.Context) error, error) {
+	if cfg == nil || !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if strings.Contains(cfg.Endpoint, "://") {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	} else {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	tp := NewProvider(cfg, sdktrace.WithBatcher(exporter))
+	Install(tp)
+
+	return func(ctx context.Context) error {
+		Uninstall()
+		return tp.Shutdown(ctx)
+	}, nil
+}
+
+// NewProvider creates a tracer provider with Alyx's resource and sampler
+// settings. Additional options (such as a span processor) are appended.
+func NewProvider(cfg *config.TracingConfig, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	serviceName := config.DefaultTracingServiceName
+	ratio := config.DefaultTracingSampleRatio
+	if cfg != nil {
+		if cfg.ServiceName != "" {
+			serviceName = cfg.ServiceName
+		}
+		ratio = cfg.SampleRatio
+	}
+
+	base := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	}
+	return sdktrace.NewTracerProvider(append(base, opts...)...)
+}
+
+// Install makes tp the global tracer provider and turns instrumentation on.
+func Install(tp trace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator)
+	enabled.Store(true)
+}
+
+// Uninstall turns instrumentation off and restores the no-op provider.
+func Uninstall() {
+	enabled.Store(false)
+	otel.SetTracerProvider(noop.NewTracerProvider())
+}
+
+// Enabled reports whether a tracer provider is installed.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Start starts a span as a child of any span in ctx.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !enabled.Load() {
+		return ctx, noop.Span{}
+	}
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End records err on span (if any) and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract returns ctx with the remote span context carried in header, if any.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if !enabled.Load() {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Inject writes the span context in ctx into header as traceparent/tracestate.
+func Inject(ctx context.Context, header http.Header) {
+	if !enabled.Load() {
+		return
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectMap writes the span context in ctx into a string map, using the same
+// keys as Inject. Existing keys are overwritten.
+func InjectMap(ctx context.Context, m map[string]string) {
+	if !enabled.Load() {
+		return
+	}
+	propagator.Inject(ctx, propagation.MapCarrier(m))
+}
+
+// ExtractMap returns ctx with the remote span context carried in m, if any.
+// Keys are matched case-insensitively so stored HTTP headers work as-is.
+func ExtractMap(ctx context.Context, m map[string]string) context.Context {
+	if !enabled.Load() {
+		return ctx
+	}
+	carrier := make(propagation.MapCarrier, len(m))
+	for k, v := range m {
+		carrier[strings.ToLower(k)] = v
+	}
+	return propagator.Extract(ctx, carrier)
+}
+
+// Env returns TRACEPARENT/TRACESTATE environment entries for a subprocess,
+// following the OpenTelemetry environment carrier convention.
+func Env(ctx context.Context) []string {
+	if !enabled.Load() {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	env := make([]string, 0, len(carrier))
+	for k, v := range carrier {
+		env = append(env, strings.ToUpper(k)+"="+v)
+	}
+	return env
+}
+
+// SetUserID records the authenticated user on the current span.
+func SetUserID(ctx context.Context, userID string) {
+	if !enabled.Load() || userID == "" {
+		return
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("enduser.id", userID))
+}
+
+// StartQuery starts a client span for a SQL statement. The statement is
+// normalized so literal values never end up in trace backends.
+func StartQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	if !enabled.Load() {
+		return ctx, noop.Span{}
+	}
+
+	statement := NormalizeStatement(query)
+	operation := statement
+	if i := strings.IndexByte(statement, ' '); i > 0 {
+		operation = statement[:i]
+	}
+	operation = strings.ToUpper(operation)
+
+	return otel.Tracer(instrumentationName).Start(ctx, "db "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "sqlite"),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.query.text", statement),
+		),
+	)
+}
+
+var (
+	stringLiteralPattern  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numericLiteralPattern = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	whitespacePattern     = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeStatement collapses whitespace and replaces string and numeric
+// literals with "?".
+func NormalizeStatement(query string) string {
+	query = stringLiteralPattern.ReplaceAllString(query, "?")
+	query = numericLiteralPattern.ReplaceAllString(query, "?")
+	query = whitespacePattern.ReplaceAllString(query, " ")
+	return strings.TrimSpace(query)
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNormalizeStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "SELECT * FROM users WHERE id = ?",
+			want:  "SELECT * FROM users WHERE id = ?",
+		},
+		{
+			query: "SELECT *\n\t FROM users\n WHERE name = 'O''Brien' AND age > 42",
+			want:  "SELECT * FROM users WHERE name = ? AND age > ?",
+		},
+		{
+			query: "UPDATE posts_v2 SET score = 1.5 LIMIT 10",
+			want:  "UPDATE posts_v2 SET score = ? LIMIT ?",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeStatement(tt.query); got != tt.want {
+			t.Errorf("NormalizeStatement(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	Install(NewProvider(nil, sdktrace.WithSyncer(exporter)))
+	t.Cleanup(Uninstall)
+
+	ctx, span := Start(context.Background(), "parent")
+	defer span.End()
+	traceID := span.SpanContext().TraceID().String()
+
+	env := Env(ctx)
+	if len(env) == 0 || !strings.HasPrefix(env[0], "TRACEPARENT=") || !strings.Contains(env[0], traceID) {
+		t.Fatalf("expected TRACEPARENT env entry for trace %s, got %v", traceID, env)
+	}
+
+	headers := map[string]string{}
+	InjectMap(ctx, headers)
+
+	stored := map[string]string{"Traceparent": headers["traceparent"]}
+	_, child := Start(ExtractMap(context.Background(), stored), "child")
+	defer child.End()
+
+	if got := child.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("expected child to continue trace %s, got %s", traceID, got)
+	}
+}
+
+func TestDisabledHelpers(t *testing.T) {
+	if Enabled() {
+		t.Fatal("expected tracing to be disabled")
+	}
+
+	ctx, span := Start(context.Background(), "noop")
+	if span.IsRecording() {
+		t.Error("expected a non-recording span")
+	}
+	if env := Env(ctx); env != nil {
+		t.Errorf("expected no env entries, got %v", env)
+	}
+}
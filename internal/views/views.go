@@ -0,0 +1,482 @@
+// Package views maintains the backing tables of materialized views.
+package views
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+// ErrViewNotFound is returned for a view the schema does not declare.
+var ErrViewNotFound = errors.New("view not found")
+
+// DefaultTick is how often the service checks for views due a refresh. It
+// also bounds how long an on_write view lags behind a write.
+const DefaultTick = time.Second
+
+// Service refreshes materialized views in the background.
+//
+// On startup and after each schema change, backing tables whose view query
+// changed are recreated and tables of views no longer in the schema are
+// dropped. on_write views are refreshed on the next tick after a write to a
+// collection they read, and interval views once their interval has passed.
+// Only writes made through database.Collection are seen; rows changed with
+// raw SQL reach on_write views on their next refresh.
+type Service struct {
+	db   *database.DB
+	tick time.Duration
+
+	mu        sync.RWMutex
+	schema    *schema.Schema
+	readers   map[string][]string // collection -> on_write views reading it
+	dirty     map[string]bool
+	refreshed map[string]time.Time
+	resync    bool
+
+	refreshMu sync.Mutex
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// Status is the outcome of a view's latest refresh.
+type Status struct {
+	Name string `json:"name"`
+	// RefreshedAt is when the view was last refreshed successfully, or nil
+	// if it has not been yet.
+	RefreshedAt *time.Time    `json:"refreshed_at,omitempty"`
+	Duration    time.Duration `json:"duration"`
+	Rows        int64         `json:"rows"`
+	// Error is the error of the latest refresh, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// NewService creates a view refresh service for the views in s.
+func NewService(db *database.DB, s *schema.Schema) *Service {
+	svc := &Service{
+		db:        db,
+		tick:      DefaultTick,
+		dirty:     make(map[string]bool),
+		refreshed: make(map[string]time.Time),
+		done:      make(chan struct{}),
+	}
+	svc.UpdateSchema(s)
+	return svc
+}
+
+// UpdateSchema replaces the schema the service refreshes views from. Backing
+// tables are brought in line with it on the next tick.
+func (s *Service) UpdateSchema(sch *schema.Schema) {
+	readers := make(map[string][]string)
+	if sch != nil {
+		for _, name := range sortedViewNames(sch) {
+			v := sch.Views[name]
+			if v.RefreshPolicy() != schema.ViewRefreshOnWrite {
+				continue
+			}
+			for _, col := range v.Collections() {
+				readers[col] = append(readers[col], name)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schema = sch
+	s.readers = readers
+	s.resync = true
+}
+
+// View returns the named view, or nil if the schema does not declare it.
+func (s *Service) View(name string) *schema.View {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.schema == nil {
+		return nil
+	}
+	return s.schema.Views[name]
+}
+
+// NotifyWrite marks the on_write views reading collection for a refresh. It
+// is meant to be the database's write listener.
+func (s *Service) NotifyWrite(collection string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range s.readers[collection] {
+		s.dirty[name] = true
+	}
+}
+
+// Start begins the background refresh loop.
+func (s *Service) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go s.loop(ctx)
+}
+
+// Stop halts the background refresh loop and waits for it to finish.
+func (s *Service) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+func (s *Service) loop(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	for {
+		s.RunDue(ctx)
+
+		select {
+		case <-s.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue syncs backing tables with the schema if it changed, then refreshes
+// every view that is due. Failures are logged and recorded in the view's
+// status.
+func (s *Service) RunDue(ctx context.Context) {
+	s.mu.Lock()
+	resync := s.resync
+	s.resync = false
+	s.mu.Unlock()
+
+	if resync {
+		if err := s.Sync(ctx); err != nil {
+			log.Error().Err(err).Msg("Failed to sync materialized views")
+			s.mu.Lock()
+			s.resync = true
+			s.mu.Unlock()
+			return
+		}
+	}
+
+	for _, name := range s.due(time.Now()) {
+		if err := s.Refresh(ctx, name); err != nil {
+			log.Error().Err(err).Str("view", name).Msg("Failed to refresh view")
+		}
+	}
+}
+
+// due returns the views marked dirty or whose interval has passed.
+func (s *Service) due(now time.Time) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.schema == nil {
+		return nil
+	}
+
+	var names []string
+	for _, name := range sortedViewNames(s.schema) {
+		v := s.schema.Views[name]
+		last, refreshed := s.refreshed[name]
+		switch {
+		case s.dirty[name], !refreshed:
+			names = append(names, name)
+		case v.RefreshPolicy() == schema.ViewRefreshInterval && now.Sub(last) >= v.RefreshInterval():
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type viewRecord struct {
+	query       string
+	definition  string
+	refreshedAt *time.Time
+}
+
+// Sync brings the backing tables in line with the schema. A view whose
+// table is missing or was created from a different query gets a new, empty
+// table; tables of views no longer in the schema are dropped.
+func (s *Service) Sync(ctx context.Context) error {
+	s.mu.RLock()
+	sch := s.schema
+	s.mu.RUnlock()
+
+	records, err := s.records(ctx)
+	if err != nil {
+		return err
+	}
+
+	var declared map[string]*schema.View
+	if sch != nil {
+		declared = sch.Views
+	}
+
+	for _, name := range sortedViewNames(sch) {
+		v := declared[name]
+		definition, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encoding view %s: %w", name, err)
+		}
+
+		rec, ok := records[name]
+		exists, err := s.tableExists(ctx, v.Table())
+		if err != nil {
+			return err
+		}
+
+		if ok && exists && rec.query == v.Query {
+			if rec.definition != string(definition) {
+				if _, err := s.db.ExecContext(ctx, `UPDATE _alyx_views SET definition = ? WHERE name = ?`, string(definition), name); err != nil {
+					return fmt.Errorf("updating view %s: %w", name, err)
+				}
+			}
+			s.mu.Lock()
+			if rec.refreshedAt != nil {
+				s.refreshed[name] = *rec.refreshedAt
+			} else {
+				delete(s.refreshed, name)
+			}
+			s.mu.Unlock()
+			continue
+		}
+
+		err = s.db.Transaction(ctx, func(tx *database.Tx) error {
+			if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+v.Table()); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, schema.NewSQLGenerator(sch).GenerateCreateView(v)); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO _alyx_views (name, definition) VALUES (?, ?)
+				ON CONFLICT(name) DO UPDATE SET
+					definition = excluded.definition,
+					refreshed_at = NULL,
+					refresh_ms = NULL,
+					row_count = NULL,
+					error = NULL
+			`, name, string(definition))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("creating view %s: %w", name, err)
+		}
+		log.Info().Str("view", name).Msg("Created view table")
+
+		s.mu.Lock()
+		delete(s.refreshed, name)
+		s.mu.Unlock()
+	}
+
+	for name := range records {
+		if _, ok := declared[name]; ok {
+			continue
+		}
+		err := s.db.Transaction(ctx, func(tx *database.Tx) error {
+			if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+schema.ViewTablePrefix+name); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `DELETE FROM _alyx_views WHERE name = ?`, name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("dropping view %s: %w", name, err)
+		}
+		log.Info().Str("view", name).Msg("Dropped view table")
+
+		s.mu.Lock()
+		delete(s.refreshed, name)
+		delete(s.dirty, name)
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (s *Service) records(ctx context.Context) (map[string]*viewRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name, definition, refreshed_at FROM _alyx_views`)
+	if err != nil {
+		return nil, fmt.Errorf("reading views: %w", err)
+	}
+	defer rows.Close()
+
+	records := make(map[string]*viewRecord)
+	for rows.Next() {
+		var name string
+		var refreshedAt sql.NullString
+		rec := &viewRecord{}
+		if err := rows.Scan(&name, &rec.definition, &refreshedAt); err != nil {
+			return nil, fmt.Errorf("reading views: %w", err)
+		}
+		var v schema.View
+		if err := json.Unmarshal([]byte(rec.definition), &v); err == nil {
+			rec.query = v.Query
+		}
+		rec.refreshedAt = parseTime(refreshedAt)
+		records[name] = rec
+	}
+	return records, rows.Err()
+}
+
+func (s *Service) tableExists(ctx context.Context, table string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table,
+	).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("checking table %s: %w", table, err)
+	}
+	return n > 0, nil
+}
+
+// Refresh replaces the rows of the named view with the current result of its
+// query, in a single transaction, so readers see either the old rows or the
+// new ones.
+func (s *Service) Refresh(ctx context.Context, name string) error {
+	v := s.View(name)
+	if v == nil {
+		return ErrViewNotFound
+	}
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	// Writes from here on mark the view again.
+	s.mu.Lock()
+	delete(s.dirty, name)
+	s.mu.Unlock()
+
+	start := time.Now()
+	var rows int64
+	err := s.db.Transaction(ctx, func(tx *database.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+v.Table()); err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s SELECT * FROM (\n%s\n)", v.Table(), v.Query))
+		if err != nil {
+			return err
+		}
+		rows, _ = result.RowsAffected()
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE _alyx_views SET refreshed_at = ?, refresh_ms = ?, row_count = ?, error = NULL
+			WHERE name = ?
+		`, formatTime(start), time.Since(start).Milliseconds(), rows, name)
+		return err
+	})
+	if err != nil {
+		if _, recErr := s.db.ExecContext(ctx, `UPDATE _alyx_views SET error = ? WHERE name = ?`, err.Error(), name); recErr != nil {
+			log.Warn().Err(recErr).Str("view", name).Msg("Failed to record view refresh error")
+		}
+		// Not retried on every tick: the next write or interval retries it.
+		s.mu.Lock()
+		s.refreshed[name] = start
+		s.mu.Unlock()
+		return fmt.Errorf("refreshing view %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	s.refreshed[name] = start
+	s.mu.Unlock()
+
+	log.Debug().
+		Str("view", name).
+		Int64("rows", rows).
+		Dur("duration", time.Since(start)).
+		Msg("Refreshed view")
+	return nil
+}
+
+// Status returns the outcome of the named view's latest refresh.
+func (s *Service) Status(ctx context.Context, name string) (*Status, error) {
+	if s.View(name) == nil {
+		return nil, ErrViewNotFound
+	}
+
+	var refreshedAt, refreshErr sql.NullString
+	var refreshMS, rowCount sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT refreshed_at, refresh_ms, row_count, error FROM _alyx_views WHERE name = ?
+	`, name).Scan(&refreshedAt, &refreshMS, &rowCount, &refreshErr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Status{Name: name}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading view status: %w", err)
+	}
+
+	return &Status{
+		Name:        name,
+		RefreshedAt: parseTime(refreshedAt),
+		Duration:    time.Duration(refreshMS.Int64) * time.Millisecond,
+		Rows:        rowCount.Int64,
+		Error:       refreshErr.String,
+	}, nil
+}
+
+// Rows returns the named view's rows in query order, skipping offset rows.
+// A negative limit returns every remaining row.
+func (s *Service) Rows(ctx context.Context, name string, limit, offset int) ([]database.Row, error) {
+	v := s.View(name)
+	if v == nil {
+		return nil, ErrViewNotFound
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT * FROM %s ORDER BY rowid LIMIT ? OFFSET ?", v.Table()), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("reading view %s: %w", name, err)
+	}
+	defer rows.Close()
+	return database.ScanRows(rows)
+}
+
+// Count returns the number of rows in the named view.
+func (s *Service) Count(ctx context.Context, name string) (int64, error) {
+	v := s.View(name)
+	if v == nil {
+		return 0, ErrViewNotFound
+	}
+
+	var n int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+v.Table()).Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting view %s: %w", name, err)
+	}
+	return n, nil
+}
+
+func sortedViewNames(s *schema.Schema) []string {
+	if s == nil {
+		return nil
+	}
+	names := make([]string, 0, len(s.Views))
+	for name := range s.Views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,220 @@
+package views
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+)
+
+const testCollections = `
+version: 1
+collections:
+  posts:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      author:
+        type: string
+`
+
+func testSchema(t *testing.T, views string) *schema.Schema {
+	t.Helper()
+	s, err := schema.Parse([]byte(testCollections + views))
+	if err != nil {
+		t.Fatalf("Failed to parse test schema: %v", err)
+	}
+	return s
+}
+
+func setup(t *testing.T, s *schema.Schema) (*Service, *database.DB) {
+	t.Helper()
+	db, err := database.Open(&config.DatabaseConfig{Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to execute SQL: %v\nSQL: %s", err, stmt)
+		}
+	}
+	svc := NewService(db, s)
+	db.SetWriteListener(svc.NotifyWrite)
+	return svc, db
+}
+
+func createPost(t *testing.T, db *database.DB, s *schema.Schema, author string) {
+	t.Helper()
+	col := database.NewCollection(db, s.Collections["posts"])
+	if _, err := col.Create(context.Background(), database.Row{"author": author}); err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+}
+
+func TestRefreshOnWrite(t *testing.T) {
+	s := testSchema(t, `
+views:
+  posts_per_author:
+    query: SELECT author, count(*) AS posts FROM posts GROUP BY author ORDER BY author
+`)
+	svc, db := setup(t, s)
+	ctx := context.Background()
+
+	createPost(t, db, s, "ann")
+	svc.RunDue(ctx)
+
+	rows, err := svc.Rows(ctx, "posts_per_author", -1, 0)
+	if err != nil {
+		t.Fatalf("Rows: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["author"] != "ann" {
+		t.Fatalf("rows = %v", rows)
+	}
+
+	status, err := svc.Status(ctx, "posts_per_author")
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.RefreshedAt == nil || status.Rows != 1 || status.Error != "" {
+		t.Errorf("status = %+v", status)
+	}
+
+	// Nothing changed, so nothing is due.
+	if due := svc.due(time.Now()); len(due) != 0 {
+		t.Errorf("due without writes = %v", due)
+	}
+
+	createPost(t, db, s, "bob")
+	createPost(t, db, s, "bob")
+	if due := svc.due(time.Now()); len(due) != 1 {
+		t.Fatalf("due after write = %v", due)
+	}
+	svc.RunDue(ctx)
+
+	rows, err = svc.Rows(ctx, "posts_per_author", 1, 1)
+	if err != nil {
+		t.Fatalf("Rows: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["author"] != "bob" || rows[0]["posts"] != int64(2) {
+		t.Errorf("second page = %v", rows)
+	}
+	if n, _ := svc.Count(ctx, "posts_per_author"); n != 2 {
+		t.Errorf("Count = %d, want 2", n)
+	}
+}
+
+func TestRefreshInterval(t *testing.T) {
+	s := testSchema(t, `
+views:
+  post_count:
+    query: SELECT count(*) AS n FROM posts
+    interval: 1m
+`)
+	svc, db := setup(t, s)
+	ctx := context.Background()
+
+	svc.RunDue(ctx)
+	createPost(t, db, s, "ann")
+
+	if due := svc.due(time.Now()); len(due) != 0 {
+		t.Errorf("interval view due after write: %v", due)
+	}
+	if due := svc.due(time.Now().Add(time.Minute)); len(due) != 1 {
+		t.Errorf("interval view not due after its interval: %v", due)
+	}
+}
+
+func TestSyncSchemaChanges(t *testing.T) {
+	s := testSchema(t, `
+views:
+  authors:
+    query: SELECT DISTINCT author FROM posts
+  dropped:
+    query: SELECT count(*) AS n FROM posts
+`)
+	svc, db := setup(t, s)
+	ctx := context.Background()
+
+	createPost(t, db, s, "ann")
+	svc.RunDue(ctx)
+
+	// Unchanged queries keep their rows and refresh state across a restart.
+	restarted := NewService(db, s)
+	if err := restarted.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if due := restarted.due(time.Now()); len(due) != 0 {
+		t.Errorf("due after restart = %v", due)
+	}
+
+	restarted.UpdateSchema(testSchema(t, `
+views:
+  authors:
+    query: SELECT DISTINCT author AS name FROM posts
+`))
+	if err := restarted.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = '_alyx_view_dropped'`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("dropped view table still exists (n=%d, err=%v)", n, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM _alyx_views`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("view records = %d, err = %v", n, err)
+	}
+
+	status, err := restarted.Status(ctx, "authors")
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.RefreshedAt != nil {
+		t.Errorf("recreated view kept its refresh time")
+	}
+
+	restarted.RunDue(ctx)
+	rows, err := restarted.Rows(ctx, "authors", -1, 0)
+	if err != nil {
+		t.Fatalf("Rows: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["name"] != "ann" {
+		t.Errorf("rows after query change = %v", rows)
+	}
+}
+
+func TestRefreshErrorRecorded(t *testing.T) {
+	s := testSchema(t, `
+views:
+  broken:
+    query: SELECT author FROM posts
+`)
+	svc, db := setup(t, s)
+	ctx := context.Background()
+	svc.RunDue(ctx)
+
+	// Dropping the table the view reads makes its refresh fail.
+	if _, err := db.Exec(`DROP TABLE posts`); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	if err := svc.Refresh(ctx, "broken"); err == nil {
+		t.Fatal("expected refresh error")
+	}
+
+	status, err := svc.Status(ctx, "broken")
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.Error == "" || status.RefreshedAt == nil {
+		t.Errorf("status = %+v, want an error and the previous refresh time", status)
+	}
+	if _, err := svc.Status(ctx, "missing"); err != ErrViewNotFound {
+		t.Errorf("Status(missing) error = %v", err)
+	}
+}
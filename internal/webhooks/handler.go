@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -63,12 +64,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var verificationError string
 
 	if endpoint.Verification != nil {
-		// Extract signature from headers
-		headers := h.extractHeaders(r)
-		signature := ExtractSignature(headers, endpoint.Verification.Header)
-
-		// Verify signature
-		result := VerifySignature(endpoint.Verification, body, signature)
+		result := Verify(endpoint.Verification, h.extractHeaders(r), body, time.Now())
 		verified = result.Valid
 
 		if !result.Valid {
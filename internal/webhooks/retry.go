@@ -12,8 +12,12 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/tracing"
 )
 
 type RetryConfig struct {
@@ -145,7 +149,18 @@ func (w *RetryWorker) retryWebhook(webhook *QueuedWebhook) error {
 		Int("attempt", webhook.Attempt+1).
 		Msg("Retrying webhook delivery")
 
-	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, webhook.EndpointURL, bytes.NewReader([]byte(webhook.Payload)))
+	// Continue the trace that enqueued the delivery, if one was captured.
+	ctx, span := tracing.Start(tracing.ExtractMap(w.ctx, webhook.Headers), "webhook.deliver",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("webhook.id", webhook.WebhookID),
+			attribute.Int("webhook.attempt", webhook.Attempt+1),
+			attribute.String("url.full", webhook.EndpointURL),
+		),
+	)
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.EndpointURL, bytes.NewReader([]byte(webhook.Payload)))
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
@@ -154,13 +169,17 @@ func (w *RetryWorker) retryWebhook(webhook *QueuedWebhook) error {
 		req.Header.Set(key, value)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
 
 	resp, err := w.httpClient.Do(req)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return w.handleRetryFailure(webhook, fmt.Sprintf("HTTP request failed: %v", err))
 	}
 	defer resp.Body.Close()
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
@@ -309,6 +328,15 @@ func (w *RetryWorker) EnqueueWebhook(ctx context.Context, webhookID, endpointURL
 		VALUES (?, ?, ?, ?, ?, 0, 'pending', ?, ?)
 	`
 
+	if tracing.Enabled() {
+		withTrace := make(map[string]string, len(headers)+2)
+		for k, v := range headers {
+			withTrace[k] = v
+		}
+		tracing.InjectMap(ctx, withTrace)
+		headers = withTrace
+	}
+
 	headersJSON, err := json.Marshal(headers)
 	if err != nil {
 		return fmt.Errorf("marshaling headers: %w", err)
@@ -15,8 +15,9 @@ type WebhookEndpoint struct {
 
 // WebhookVerification contains configuration for webhook signature verification.
 type WebhookVerification struct {
-	Type        string // Verification type: "hmac-sha256", "hmac-sha1"
-	Header      string // Header containing signature (e.g., "X-Hub-Signature")
-	Secret      string // Secret key for HMAC verification
-	SkipInvalid bool   // If true, pass verification result to function; if false, reject with 401
+	Type        string        // Verification type: "stripe", "github", "slack", "hmac-sha256", "hmac-sha1", "token"
+	Header      string        // Header containing signature (e.g., "X-Hub-Signature"); presets have a default
+	Secret      string        // Secret key for HMAC verification, or the expected token
+	Tolerance   time.Duration // Maximum signature age for timestamped schemes (0 uses DefaultTolerance)
+	SkipInvalid bool          // If true, pass verification result to function; if false, reject with 401
 }
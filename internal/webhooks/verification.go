@@ -4,12 +4,220 @@ import (
 	"crypto/hmac"
 	"crypto/sha1" // #nosec G505 - SHA1 required for compatibility with some webhook providers
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 	"hash"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// Verification types.
+const (
+	VerificationHMACSHA256 = "hmac-sha256"
+	VerificationHMACSHA1   = "hmac-sha1"
+	VerificationStripe     = "stripe"
+	VerificationGitHub     = "github"
+	VerificationSlack      = "slack"
+	VerificationToken      = "token"
+)
+
+// DefaultTolerance is the maximum age of a timestamped signature (Stripe,
+// Slack) when no tolerance is configured. Older requests are rejected as
+// possible replays.
+const DefaultTolerance = 5 * time.Minute
+
+// slackTimestampHeader carries the request timestamp Slack signs.
+const slackTimestampHeader = "X-Slack-Request-Timestamp"
+
+// presetHeaders are the signature headers the provider presets read by default.
+var presetHeaders = map[string]string{
+	VerificationStripe: "Stripe-Signature",
+	VerificationGitHub: "X-Hub-Signature-256",
+	VerificationSlack:  "X-Slack-Signature",
+}
+
+// SignatureHeader returns the header holding the signature, falling back to
+// the preset's default header.
+func (v *WebhookVerification) SignatureHeader() string {
+	if v.Header != "" {
+		return v.Header
+	}
+	return presetHeaders[v.Type]
+}
+
+// ToleranceOrDefault returns the configured tolerance, or DefaultTolerance.
+func (v *WebhookVerification) ToleranceOrDefault() time.Duration {
+	if v.Tolerance > 0 {
+		return v.Tolerance
+	}
+	return DefaultTolerance
+}
+
+// Validate checks that the fields the verification type needs are set.
+func (v *WebhookVerification) Validate() error {
+	switch v.Type {
+	case VerificationStripe, VerificationGitHub, VerificationSlack:
+	case VerificationHMACSHA256, VerificationHMACSHA1, VerificationToken:
+		if v.Header == "" {
+			return fmt.Errorf("verification type %s requires a header", v.Type)
+		}
+	default:
+		return fmt.Errorf("unsupported verification type: %s", v.Type)
+	}
+	if v.Secret == "" {
+		return fmt.Errorf("verification type %s requires a secret", v.Type)
+	}
+	if v.Tolerance < 0 {
+		return fmt.Errorf("verification tolerance must not be negative")
+	}
+	return nil
+}
+
+// Verify checks a webhook request against the configured verification
+// scheme. now is used to enforce the tolerance window of timestamped schemes.
+func Verify(verification *WebhookVerification, headers map[string]string, body []byte, now time.Time) *VerificationResult {
+	if verification == nil {
+		return &VerificationResult{
+			Valid:  true,
+			Method: "none",
+		}
+	}
+
+	header := verification.SignatureHeader()
+	signature := ExtractSignature(headers, header)
+	if signature == "" {
+		return failed(verification.Type, "missing %s header", header)
+	}
+
+	switch verification.Type {
+	case VerificationHMACSHA256, VerificationHMACSHA1:
+		return VerifySignature(verification, body, signature)
+	case VerificationGitHub:
+		return verifyGitHub(verification, body, signature)
+	case VerificationStripe:
+		return verifyStripe(verification, body, signature, now)
+	case VerificationSlack:
+		return verifySlack(verification, headers, body, signature, now)
+	case VerificationToken:
+		return verifyToken(verification, signature)
+	default:
+		return failed(verification.Type, "unsupported verification type: %s", verification.Type)
+	}
+}
+
+// verifyGitHub checks an X-Hub-Signature-256 header: "sha256=" followed by
+// the hex HMAC-SHA256 of the body.
+func verifyGitHub(verification *WebhookVerification, body []byte, signature string) *VerificationResult {
+	hexSig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return failed(VerificationGitHub, "signature must start with sha256=")
+	}
+	return compareHex(VerificationGitHub, computeHMAC(verification.Secret, body), hexSig)
+}
+
+// verifyStripe checks a Stripe-Signature header ("t=<unix>,v1=<hex>,...").
+// Each v1 value is the hex HMAC-SHA256 of "<t>.<body>"; any match is accepted.
+func verifyStripe(verification *WebhookVerification, body []byte, signature string, now time.Time) *VerificationResult {
+	var timestamp string
+	var candidates []string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			candidates = append(candidates, value)
+		}
+	}
+	if timestamp == "" {
+		return failed(VerificationStripe, "no timestamp in Stripe-Signature header")
+	}
+	if len(candidates) == 0 {
+		return failed(VerificationStripe, "no v1 signature in Stripe-Signature header")
+	}
+	if result := checkTimestamp(VerificationStripe, timestamp, verification.ToleranceOrDefault(), now); result != nil {
+		return result
+	}
+
+	expected := computeHMAC(verification.Secret, []byte(timestamp+"."+string(body)))
+	for _, candidate := range candidates {
+		if actual, err := hex.DecodeString(candidate); err == nil && hmac.Equal(expected, actual) {
+			return &VerificationResult{Valid: true, Method: VerificationStripe}
+		}
+	}
+	return failed(VerificationStripe, "signature mismatch")
+}
+
+// verifySlack checks an X-Slack-Signature header: "v0=" followed by the hex
+// HMAC-SHA256 of "v0:<X-Slack-Request-Timestamp>:<body>".
+func verifySlack(verification *WebhookVerification, headers map[string]string, body []byte, signature string, now time.Time) *VerificationResult {
+	hexSig, ok := strings.CutPrefix(signature, "v0=")
+	if !ok {
+		return failed(VerificationSlack, "signature must start with v0=")
+	}
+	timestamp := ExtractSignature(headers, slackTimestampHeader)
+	if timestamp == "" {
+		return failed(VerificationSlack, "missing %s header", slackTimestampHeader)
+	}
+	if result := checkTimestamp(VerificationSlack, timestamp, verification.ToleranceOrDefault(), now); result != nil {
+		return result
+	}
+	return compareHex(VerificationSlack, computeHMAC(verification.Secret, []byte("v0:"+timestamp+":"+string(body))), hexSig)
+}
+
+// verifyToken compares a shared token, optionally sent as "Bearer <token>".
+func verifyToken(verification *WebhookVerification, signature string) *VerificationResult {
+	token := strings.TrimPrefix(signature, "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(verification.Secret)) != 1 {
+		return failed(VerificationToken, "token mismatch")
+	}
+	return &VerificationResult{Valid: true, Method: VerificationToken}
+}
+
+// checkTimestamp rejects Unix timestamps further than tolerance from now, in
+// either direction. It returns nil if the timestamp is acceptable.
+func checkTimestamp(method, timestamp string, tolerance time.Duration, now time.Time) *VerificationResult {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return failed(method, "invalid timestamp %q", timestamp)
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return failed(method, "timestamp outside the %s tolerance window", tolerance)
+	}
+	return nil
+}
+
+func computeHMAC(secret string, message []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+func compareHex(method string, expected []byte, actualHex string) *VerificationResult {
+	actual, err := hex.DecodeString(actualHex)
+	if err != nil {
+		return failed(method, "invalid signature format: %v", err)
+	}
+	if !hmac.Equal(expected, actual) {
+		return failed(method, "signature mismatch")
+	}
+	return &VerificationResult{Valid: true, Method: method}
+}
+
+func failed(method, format string, args ...any) *VerificationResult {
+	return &VerificationResult{
+		Valid:  false,
+		Error:  fmt.Sprintf(format, args...),
+		Method: method,
+	}
+}
+
 // VerificationResult contains the result of webhook signature verification.
 type VerificationResult struct {
 	Valid  bool   // Whether signature is valid
@@ -17,7 +225,8 @@ type VerificationResult struct {
 	Method string // Verification method used
 }
 
-// VerifySignature verifies a webhook signature using the configured verification method.
+// VerifySignature verifies a generic HMAC signature (hmac-sha256 or
+// hmac-sha1) of the body. Use Verify for the provider presets.
 func VerifySignature(verification *WebhookVerification, body []byte, signature string) *VerificationResult {
 	if verification == nil {
 		return &VerificationResult{
@@ -30,12 +239,12 @@ func VerifySignature(verification *WebhookVerification, body []byte, signature s
 	var method string
 
 	switch verification.Type {
-	case "hmac-sha256":
+	case VerificationHMACSHA256:
 		h = hmac.New(sha256.New, []byte(verification.Secret))
-		method = "hmac-sha256"
-	case "hmac-sha1":
+		method = VerificationHMACSHA256
+	case VerificationHMACSHA1:
 		h = hmac.New(sha1.New, []byte(verification.Secret))
-		method = "hmac-sha1"
+		method = VerificationHMACSHA1
 	default:
 		return &VerificationResult{
 			Valid:  false,
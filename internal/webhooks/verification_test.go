@@ -5,7 +5,9 @@ import (
 	"crypto/sha1" // #nosec G505 - SHA1 required for testing webhook compatibility
 	"crypto/sha256"
 	"encoding/hex"
+	"strings"
 	"testing"
+	"time"
 )
 
 const testSecret = "my-secret-key"
@@ -255,3 +257,140 @@ func TestVerifySignature_ConstantTime(t *testing.T) {
 		t.Errorf("Expected invalid signature")
 	}
 }
+
+// Provider examples below are taken from each provider's documentation.
+const (
+	// https://docs.github.com/en/webhooks/using-webhooks/validating-webhook-deliveries
+	githubSecret    = "It's a Secret to Everybody"
+	githubBody      = "Hello, World!"
+	githubSignature = "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+
+	// https://api.slack.com/authentication/verifying-requests-from-slack
+	slackSecret    = "8f742231b10e8888abcd99yyyzzz85a5"
+	slackTimestamp = "1531420618"
+	slackBody      = "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+	slackSignature = "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+)
+
+func TestVerify_GitHub(t *testing.T) {
+	v := &WebhookVerification{Type: VerificationGitHub, Secret: githubSecret}
+
+	result := Verify(v, map[string]string{"X-Hub-Signature-256": githubSignature}, []byte(githubBody), time.Now())
+	if !result.Valid {
+		t.Fatalf("expected documented signature to verify, got %q", result.Error)
+	}
+
+	result = Verify(v, map[string]string{"X-Hub-Signature-256": strings.TrimPrefix(githubSignature, "sha256=")}, []byte(githubBody), time.Now())
+	if result.Valid || result.Error != "signature must start with sha256=" {
+		t.Errorf("expected prefix error, got %+v", result)
+	}
+
+	result = Verify(v, map[string]string{}, []byte(githubBody), time.Now())
+	if result.Valid || result.Error != "missing X-Hub-Signature-256 header" {
+		t.Errorf("expected missing header error, got %+v", result)
+	}
+}
+
+func TestVerify_Slack(t *testing.T) {
+	v := &WebhookVerification{Type: VerificationSlack, Secret: slackSecret}
+	headers := map[string]string{
+		"X-Slack-Signature":         slackSignature,
+		"X-Slack-Request-Timestamp": slackTimestamp,
+	}
+	signedAt := time.Unix(1531420618, 0)
+
+	if result := Verify(v, headers, []byte(slackBody), signedAt.Add(time.Minute)); !result.Valid {
+		t.Fatalf("expected documented signature to verify, got %q", result.Error)
+	}
+
+	result := Verify(v, headers, []byte(slackBody), signedAt.Add(DefaultTolerance+time.Second))
+	if result.Valid || result.Error != "timestamp outside the 5m0s tolerance window" {
+		t.Errorf("expected replay to be rejected, got %+v", result)
+	}
+
+	v.Tolerance = time.Hour
+	if result := Verify(v, headers, []byte(slackBody), signedAt.Add(30*time.Minute)); !result.Valid {
+		t.Errorf("expected configured tolerance to apply, got %q", result.Error)
+	}
+
+	result = Verify(v, headers, []byte(slackBody+"&extra=1"), signedAt)
+	if result.Valid || result.Error != "signature mismatch" {
+		t.Errorf("expected tampered body to fail, got %+v", result)
+	}
+}
+
+func TestVerify_Stripe(t *testing.T) {
+	// Stripe's docs show the header format but not the secret behind their
+	// sample, so this signs a payload with the documented scheme: the v1 value
+	// is the HMAC-SHA256 of "<t>.<body>".
+	secret := "whsec_test_secret"
+	body := `{"id":"evt_test_webhook","object":"event"}`
+	timestamp := "1492774577"
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "." + body))
+	v1 := hex.EncodeToString(h.Sum(nil))
+	signedAt := time.Unix(1492774577, 0)
+
+	v := &WebhookVerification{Type: VerificationStripe, Secret: secret}
+	header := "t=" + timestamp + ",v1=" + strings.Repeat("0", 64) + ",v1=" + v1 + ",v0=6ffbb59b2300aae63f272406069a9788598b792a944a07aba816edb039989a39"
+
+	if result := Verify(v, map[string]string{"Stripe-Signature": header}, []byte(body), signedAt); !result.Valid {
+		t.Fatalf("expected any matching v1 signature to verify, got %q", result.Error)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		now    time.Time
+		want   string
+	}{
+		{"no timestamp", "v1=" + v1, signedAt, "no timestamp in Stripe-Signature header"},
+		{"no v1", "t=" + timestamp + ",v0=" + v1, signedAt, "no v1 signature in Stripe-Signature header"},
+		{"too old", header, signedAt.Add(10 * time.Minute), "timestamp outside the 5m0s tolerance window"},
+		{"from the future", header, signedAt.Add(-10 * time.Minute), "timestamp outside the 5m0s tolerance window"},
+		{"wrong timestamp", "t=1492774578,v1=" + v1, signedAt, "signature mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Verify(v, map[string]string{"Stripe-Signature": tt.header}, []byte(body), tt.now)
+			if result.Valid || result.Error != tt.want {
+				t.Errorf("expected %q, got %+v", tt.want, result)
+			}
+		})
+	}
+}
+
+func TestVerify_Token(t *testing.T) {
+	v := &WebhookVerification{Type: VerificationToken, Header: "Authorization", Secret: "s3cret"}
+
+	if result := Verify(v, map[string]string{"Authorization": "Bearer s3cret"}, nil, time.Now()); !result.Valid {
+		t.Errorf("expected bearer token to verify, got %q", result.Error)
+	}
+	if result := Verify(v, map[string]string{"authorization": "s3cret"}, nil, time.Now()); !result.Valid {
+		t.Errorf("expected raw token to verify, got %q", result.Error)
+	}
+	if result := Verify(v, map[string]string{"Authorization": "Bearer nope"}, nil, time.Now()); result.Valid || result.Error != "token mismatch" {
+		t.Errorf("expected token mismatch, got %+v", result)
+	}
+}
+
+func TestWebhookVerification_Validate(t *testing.T) {
+	tests := []struct {
+		v    WebhookVerification
+		want string
+	}{
+		{WebhookVerification{Type: VerificationStripe, Secret: "x"}, ""},
+		{WebhookVerification{Type: VerificationGitHub}, "requires a secret"},
+		{WebhookVerification{Type: VerificationToken, Secret: "x"}, "requires a header"},
+		{WebhookVerification{Type: "md5", Secret: "x"}, "unsupported verification type"},
+	}
+	for _, tt := range tests {
+		err := tt.v.Validate()
+		if tt.want == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.v.Type, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: expected %q, got %v", tt.v.Type, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package alyxtest runs a complete Alyx server against an in-memory SQLite
+// database, for testing handlers, rules and clients without a deployment.
+//
+// Functions are disabled, so no container runtime is needed, and every
+// harness gets its own database and temporary directory, so tests using it
+// can run in parallel. Background services such as the realtime broker, the
+// scheduler and retention sweeps are not started.
+package alyxtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/watzon/alyx/internal/auth"
+	"github.com/watzon/alyx/internal/config"
+	"github.com/watzon/alyx/internal/database"
+	"github.com/watzon/alyx/internal/schema"
+	"github.com/watzon/alyx/internal/server"
+)
+
+// Password is the password of every user made with CreateUser.
+const Password = "alyxtest-Password1!"
+
+// Harness is a running server and handles to its parts.
+type Harness struct {
+	// Config is the configuration the server was built with.
+	Config *config.Config
+	// DB is the in-memory database.
+	DB *database.DB
+	// Schema is the applied schema.
+	Schema *schema.Schema
+	// Server is the server. It is not listening; use HTTP or Do.
+	Server *server.Server
+	// HTTP serves the full router over a loopback connection.
+	HTTP *httptest.Server
+	// Auth is the auth service behind /api/auth.
+	Auth *auth.Service
+	// Dir is a temporary directory holding the schema file and the
+	// functions directory.
+	Dir string
+
+	t testing.TB
+}
+
+// Option adjusts the configuration before the server is built.
+type Option func(*config.Config)
+
+// WithConfig returns an Option that calls fn with the configuration.
+func WithConfig(fn func(*config.Config)) Option {
+	return Option(fn)
+}
+
+// New starts a harness with the schema in schemaYAML. Everything is torn
+// down when the test finishes.
+func New(t testing.TB, schemaYAML string, opts ...Option) *Harness {
+	t.Helper()
+
+	s, err := schema.Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatalf("alyxtest: parsing schema: %v", err)
+	}
+	h := newHarness(t, s, opts)
+	if err := os.WriteFile(filepath.Join(h.Dir, "schema.yaml"), []byte(schemaYAML), 0o644); err != nil {
+		t.Fatalf("alyxtest: writing schema: %v", err)
+	}
+	return h
+}
+
+// NewWithSchema starts a harness with an already parsed schema.
+func NewWithSchema(t testing.TB, s *schema.Schema, opts ...Option) *Harness {
+	t.Helper()
+	return newHarness(t, s, opts)
+}
+
+func newHarness(t testing.TB, s *schema.Schema, opts []Option) *Harness {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := testConfig(dir)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	db, err := database.Open(&cfg.Database)
+	if err != nil {
+		t.Fatalf("alyxtest: opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, stmt := range schema.NewSQLGenerator(s).GenerateAll() {
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("alyxtest: applying schema: %v", err)
+		}
+	}
+
+	srv := server.New(cfg, db, s, server.WithSchemaPath(filepath.Join(dir, "schema.yaml")))
+	httpServer := httptest.NewServer(srv.Handler())
+	t.Cleanup(httpServer.Close)
+
+	return &Harness{
+		Config: cfg,
+		DB:     db,
+		Schema: s,
+		Server: srv,
+		HTTP:   httpServer,
+		Auth:   srv.AuthService(),
+		Dir:    dir,
+		t:      t,
+	}
+}
+
+// testConfig returns the default configuration with everything that needs
+// Docker, the network or the working directory turned off or moved into dir.
+func testConfig(dir string) *config.Config {
+	cfg := config.Default()
+	cfg.Server.Host = "127.0.0.1"
+	cfg.Server.Port = 0
+	cfg.Database.Path = ":memory:"
+	cfg.Database.Checkpoint.Enabled = false
+	cfg.Auth.JWT.Secret = "alyxtest-secret-that-is-at-least-32-bytes"
+	cfg.Auth.Password.Hash.Bcrypt.Cost = bcrypt.MinCost
+	cfg.Functions.Enabled = false
+	cfg.Functions.Path = filepath.Join(dir, "functions")
+	cfg.Realtime.Enabled = false
+	cfg.Retention.Enabled = false
+	cfg.AdminUI.Enabled = false
+	return cfg
+}
+
+// Collection returns the named collection, failing the test if it does not
+// exist.
+func (h *Harness) Collection(name string) *database.Collection {
+	h.t.Helper()
+
+	col, err := h.Server.GetCollection(name)
+	if err != nil {
+		h.t.Fatalf("alyxtest: %v", err)
+	}
+	return col
+}
+
+// CreateUser creates a verified user with role, or the default role if role
+// is empty. The user's password is Password.
+func (h *Harness) CreateUser(email, role string) *auth.User {
+	h.t.Helper()
+
+	user, err := h.Auth.CreateUserByAdmin(context.Background(), auth.CreateUserInput{
+		Email:    email,
+		Password: Password,
+		Verified: true,
+		Role:     role,
+	})
+	if err != nil {
+		h.t.Fatalf("alyxtest: creating user %s: %v", email, err)
+	}
+	return user
+}
+
+// Token returns an access token for user.
+func (h *Harness) Token(user *auth.User) string {
+	h.t.Helper()
+
+	token, _, err := h.Auth.IssueAccessToken(user)
+	if err != nil {
+		h.t.Fatalf("alyxtest: issuing token: %v", err)
+	}
+	return token
+}
+
+// Do sends a request straight to the router and returns the recorded
+// response. A non-empty body is sent as JSON, and a non-empty token as a
+// bearer token.
+func (h *Harness) Do(method, path, body, token string) *httptest.ResponseRecorder {
+	h.t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.Server.Handler().ServeHTTP(w, req)
+	return w
+}
+
+// Handler returns the router, for tests that build their own requests.
+func (h *Harness) Handler() http.Handler {
+	return h.Server.Handler()
+}
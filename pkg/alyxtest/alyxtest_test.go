@@ -0,0 +1,77 @@
+package alyxtest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const notesSchema = `
+version: 1
+roles: [editor]
+collections:
+  notes:
+    fields:
+      id:
+        type: uuid
+        primary: true
+        default: auto
+      title:
+        type: string
+    rules:
+      read: "auth.id != ''"
+      create: "auth.role == 'editor'"
+`
+
+func TestHarness(t *testing.T) {
+	t.Parallel()
+	h := New(t, notesSchema)
+
+	editor := h.Token(h.CreateUser("editor@example.com", "editor"))
+	reader := h.Token(h.CreateUser("reader@example.com", ""))
+
+	if w := h.Do(http.MethodPost, "/api/collections/notes", `{"title":"hello"}`, reader); w.Code != http.StatusForbidden {
+		t.Errorf("create as a user: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := h.Do(http.MethodPost, "/api/collections/notes", `{"title":"hello"}`, editor); w.Code != http.StatusCreated {
+		t.Fatalf("create as an editor: status %d: %s", w.Code, w.Body.String())
+	}
+
+	count, err := h.Collection("notes").Count(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("notes has %d documents, want 1", count)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, h.HTTP.URL+"/api/collections/notes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+reader)
+	resp, err := h.HTTP.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("list over HTTP: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestHarness_Isolated(t *testing.T) {
+	t.Parallel()
+
+	for range 2 {
+		h := New(t, notesSchema)
+		if w := h.Do(http.MethodPost, "/api/collections/notes", `{"title":"x"}`, h.Token(h.CreateUser("editor@example.com", "editor"))); w.Code != http.StatusCreated {
+			t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+		}
+		w := h.Do(http.MethodGet, "/api/collections/notes", "", h.Token(h.CreateUser("reader@example.com", "user")))
+		if got := strings.Count(w.Body.String(), `"title"`); got != 1 {
+			t.Errorf("expected each harness to see only its own note, got %s", w.Body.String())
+		}
+	}
+}
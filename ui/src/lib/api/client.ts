@@ -7,6 +7,14 @@ export interface ApiError {
 	details?: { field: string; code: string; message: string }[];
 }
 
+/** A schema problem from an INVALID_SCHEMA error's details. */
+export interface SchemaIssue {
+	path: string;
+	message: string;
+	line?: number;
+	column?: number;
+}
+
 interface ApiResponse<T> {
 	data?: T;
 	error?: ApiError;
@@ -261,6 +269,7 @@ export interface User {
 	created_at: string;
 	updated_at: string;
 	metadata?: Record<string, unknown>;
+	deletion_requested_at?: string;
 }
 
 export interface Collection {
@@ -268,6 +277,8 @@ export interface Collection {
 	fields: Field[];
 	indexes?: Index[];
 	rules?: Rules;
+	/** False for collections without a REST API, which only admins and functions can use. */
+	expose?: boolean;
 }
 
 export type RichTextPreset = 'minimal' | 'basic' | 'standard' | 'full';
@@ -380,11 +391,53 @@ export interface StorageStats {
 	buckets: StorageBucketStats[];
 }
 
+export interface StorageGCParams {
+	dry_run?: boolean;
+	grace_period?: string;
+	cursor?: string;
+	limit?: number;
+	async?: boolean;
+}
+
+export interface StorageGCResult {
+	bucket: string;
+	dry_run: boolean;
+	cutoff: string;
+	scanned: number;
+	candidates: FileMetadata[];
+	deleted: number;
+	bytes: number;
+	next_cursor?: string;
+	errors?: string[];
+}
+
+export interface StorageGCJob {
+	id: string;
+	bucket: string;
+	status: 'running' | 'completed' | 'failed';
+	result?: StorageGCResult;
+	error?: string;
+	started_at: string;
+	finished_at?: string;
+}
+
+export type FunctionStatus = 'ready' | 'error';
+
+export interface BuildResult {
+	status: 'success' | 'failed';
+	started_at: string;
+	duration_ms: number;
+	output?: string;
+	error?: string;
+}
+
 export interface FunctionInfo {
 	name: string;
 	runtime: string;
 	path: string;
 	enabled: boolean;
+	status: FunctionStatus;
+	error?: string;
 }
 
 export interface FunctionDetail {
@@ -399,6 +452,9 @@ export interface FunctionDetail {
 	enabled: boolean;
 	env?: Record<string, string>;
 	dependencies?: string[];
+	status: FunctionStatus;
+	error?: string;
+	build?: BuildResult | null;
 }
 
 export interface FunctionInvokeResponse {
@@ -457,6 +513,7 @@ export interface RequestLogEntry {
 	user_id?: string;
 	error?: string;
 	error_code?: string;
+	error_details?: Record<string, unknown>;
 }
 
 export interface RequestLogListResponse {
@@ -493,6 +550,8 @@ export const auth = {
 export interface SchemaRaw {
 	content: string;
 	path: string;
+	source: 'file' | 'database';
+	cached_at?: string;
 }
 
 export interface ValidateRuleResponse {
@@ -502,6 +561,84 @@ export interface ValidateRuleResponse {
 	hints?: string[];
 }
 
+export interface SchemaGraphNode {
+	id: string;
+	kind: 'collection' | 'bucket';
+	name: string;
+	fields?: { name: string; type: string; primary?: boolean; unique?: boolean; nullable?: boolean }[];
+	rowCount?: number;
+	backend?: string;
+}
+
+export interface SchemaGraphEdge {
+	id: string;
+	kind: 'reference' | 'relation' | 'file';
+	source: string;
+	target: string;
+	field: string;
+	targetField?: string;
+	onDelete?: string;
+	selfReference?: boolean;
+	cyclic?: boolean;
+}
+
+export interface SchemaGraph {
+	nodes: SchemaGraphNode[];
+	edges: SchemaGraphEdge[];
+}
+
+export interface IndexRecommendation {
+	collection: string;
+	index: { Name: string; Fields: string[]; Unique: boolean; Order: string };
+	yaml: string;
+	reason: string;
+	query: string;
+	requests: number;
+	plan: string;
+	rows_scanned: number;
+	rows_returned: number;
+	benefit: number;
+}
+
+export interface UnusedIndex {
+	collection: string;
+	name: string;
+	fields: string[];
+	queries: number;
+}
+
+export interface IndexAdvisorReport {
+	generated_at: string;
+	queries: number;
+	shapes: number;
+	recommendations: IndexRecommendation[];
+	unused_indexes: UnusedIndex[];
+}
+
+export interface QueryPlanStep {
+	id: number;
+	detail: string;
+	children?: QueryPlanStep[];
+}
+
+export interface QueryPlan {
+	sql: string;
+	args: unknown[];
+	steps: QueryPlanStep[];
+	uses_index: boolean;
+	indexes?: string[];
+	estimated_rows?: number;
+	note: string;
+}
+
+export interface ExplainQueryParams {
+	filter?: string[];
+	sort?: string;
+	search?: string;
+	limit?: number;
+	offset?: number;
+}
+
 export interface ConfigRaw {
 	content: string;
 	path: string;
@@ -576,9 +713,18 @@ export const admin = {
 	stats: () => api.get<Stats>('/admin/stats'),
 
 	storageStats: () => api.get<StorageStats>('/admin/storage/stats'),
+	storageGC: (bucket: string, params: StorageGCParams) =>
+		api.post<StorageGCResult | StorageGCJob>(`/admin/storage/${encodeURIComponent(bucket)}/gc`, params),
+	storageGCJob: (id: string) => api.get<StorageGCJob>(`/admin/storage/gc/${encodeURIComponent(id)}`),
 
 	schema: () => api.get<Schema>('/admin/schema'),
 
+	schemaGraph: () => api.get<SchemaGraph>('/admin/schema/graph'),
+	indexAdvisor: (since?: string) =>
+		api.get<IndexAdvisorReport>(`/admin/advisor/indexes${since ? `?since=${encodeURIComponent(since)}` : ''}`),
+	explainQuery: (collection: string, params: ExplainQueryParams) =>
+		api.post<QueryPlan>(`/admin/collections/${encodeURIComponent(collection)}/explain`, params),
+
 	schemaRaw: {
 		get: () => api.get<SchemaRaw>('/admin/schema/raw'),
 		update: (content: string) =>
@@ -597,6 +743,9 @@ export const admin = {
 	validateRule: (expression: string, fields?: string[]) =>
 		api.post<ValidateRuleResponse>('/admin/schema/validate-rule', { expression, fields }),
 
+	validateCheck: (expression: string) =>
+		api.post<ValidateRuleResponse>('/admin/schema/validate-rule', { expression, kind: 'check' }),
+
 	pendingChanges: {
 		list: () => api.get<PendingChangesResponse>('/admin/schema/pending-changes'),
 		confirm: () => api.post<{ success: boolean; message: string; applied: number }>('/admin/schema/confirm-changes'),
@@ -621,6 +770,7 @@ export const admin = {
 			sort_dir?: 'asc' | 'desc';
 			search?: string;
 			role?: string;
+			pending_deletion?: boolean;
 		}) => {
 			const query = new URLSearchParams();
 			if (params?.limit) query.set('limit', String(params.limit));
@@ -629,6 +779,7 @@ export const admin = {
 			if (params?.sort_dir) query.set('sort_dir', params.sort_dir);
 			if (params?.search) query.set('search', params.search);
 			if (params?.role) query.set('role', params.role);
+			if (params?.pending_deletion) query.set('pending_deletion', 'true');
 			const qs = query.toString();
 			return api.get<{ users: User[]; total: number }>(`/admin/users${qs ? `?${qs}` : ''}`);
 		},
@@ -639,7 +790,8 @@ export const admin = {
 			api.patch<User>(`/admin/users/${id}`, data),
 		delete: (id: string) => api.delete<{ deleted: boolean; id: string }>(`/admin/users/${id}`),
 		setPassword: (id: string, password: string) =>
-			api.post<{ success: boolean }>(`/admin/users/${id}/password`, { password })
+			api.post<{ success: boolean }>(`/admin/users/${id}/password`, { password }),
+		restore: (id: string) => api.post<User>(`/admin/users/${id}/restore`)
 	},
 
 	buckets: {
@@ -652,8 +804,13 @@ export const admin = {
 	},
 
 	tokens: {
-		list: () => api.get<{ tokens: { name: string; created_at: string }[] }>('/admin/tokens'),
-		create: (name: string) => api.post<{ token: string }>('/admin/tokens', { name }),
+		list: () =>
+			api.get<{
+				tokens: { name: string; permissions: string[]; created_at: string; expires_at?: string; last_used_at?: string }[];
+			}>('/admin/tokens'),
+		create: (name: string, options?: { permissions?: string[]; expires_at?: string }) =>
+			api.post<{ token: string }>('/admin/tokens', { name, ...options }),
+		rotate: (name: string) => api.post<{ token: string }>(`/admin/tokens/${name}/rotate`),
 		delete: (name: string) => api.delete(`/admin/tokens/${name}`)
 	},
 
@@ -722,6 +879,7 @@ export const admin = {
 			min_status?: number;
 			max_status?: number;
 			user_id?: string;
+			error_code?: string;
 			since?: string;
 			until?: string;
 		}) => {
@@ -735,6 +893,7 @@ export const admin = {
 			if (params?.min_status) query.set('min_status', String(params.min_status));
 			if (params?.max_status) query.set('max_status', String(params.max_status));
 			if (params?.user_id) query.set('user_id', params.user_id);
+			if (params?.error_code) query.set('error_code', params.error_code);
 			if (params?.since) query.set('since', params.since);
 			if (params?.until) query.set('until', params.until);
 			const qs = query.toString();
@@ -749,6 +908,45 @@ export const config = {
 	get: () => api.get<ServerConfig>('/config')
 };
 
+export interface SystemEvent {
+	action: 'schema_changed';
+	collections: string[];
+}
+
+export const realtime = {
+	// Listens on the realtime system channel, reconnecting while the server is
+	// unreachable. Returns a function that stops listening.
+	onSystemEvent: (callback: (event: SystemEvent) => void): (() => void) => {
+		const url = `${location.origin.replace(/^http/, 'ws')}${BASE_URL}/realtime`;
+		let ws: WebSocket | null = null;
+		let retry: ReturnType<typeof setTimeout> | null = null;
+		let stopped = false;
+
+		const connect = () => {
+			ws = new WebSocket(url);
+			ws.onopen = () => ws?.send(JSON.stringify({ type: 'subscribe', payload: { channel: 'system' } }));
+			ws.onmessage = (event) => {
+				const msg = JSON.parse(event.data);
+				if (msg.type === 'system') callback(msg.payload as SystemEvent);
+			};
+			ws.onclose = () => {
+				if (!stopped) retry = setTimeout(connect, 5000);
+			};
+		};
+		connect();
+
+		return () => {
+			stopped = true;
+			if (retry) clearTimeout(retry);
+			ws?.close();
+		};
+	}
+};
+
+// The data browser acts across tenants: tenant=* lifts tenant scoping for
+// admins and is ignored by collections that aren't tenant-scoped.
+const allTenants = 'tenant=*';
+
 export const collections = {
 	list: (collection: string, params?: { filter?: string; sort?: string; page?: number; perPage?: number; search?: string }) => {
 		const query = new URLSearchParams();
@@ -757,22 +955,22 @@ export const collections = {
 		if (params?.page) query.set('page', String(params.page));
 		if (params?.perPage) query.set('perPage', String(params.perPage));
 		if (params?.search) query.set('search', params.search);
-		const qs = query.toString();
+		query.set('tenant', '*');
 		return api.get<{ docs: Record<string, unknown>[]; total: number; limit: number; offset: number }>(
-			`/collections/${collection}${qs ? `?${qs}` : ''}`
+			`/collections/${collection}?${query.toString()}`
 		);
 	},
 
 	get: (collection: string, id: string) =>
-		api.get<Record<string, unknown>>(`/collections/${collection}/${id}`),
+		api.get<Record<string, unknown>>(`/collections/${collection}/${id}?${allTenants}`),
 
 	create: (collection: string, data: Record<string, unknown>) =>
-		api.post<Record<string, unknown>>(`/collections/${collection}`, data),
+		api.post<Record<string, unknown>>(`/collections/${collection}?${allTenants}`, data),
 
 	update: (collection: string, id: string, data: Record<string, unknown>) =>
-		api.patch<Record<string, unknown>>(`/collections/${collection}/${id}`, data),
+		api.patch<Record<string, unknown>>(`/collections/${collection}/${id}?${allTenants}`, data),
 
-	delete: (collection: string, id: string) => api.delete(`/collections/${collection}/${id}`)
+	delete: (collection: string, id: string) => api.delete(`/collections/${collection}/${id}?${allTenants}`)
 };
 
 export interface FileMetadata {
@@ -1 +1,2 @@
 export { default as YamlEditor } from './yaml-editor.svelte';
+export type { YamlMarker } from './yaml-editor.svelte';